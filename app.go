@@ -3,7 +3,9 @@
 import (
 	"archive/zip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,23 +14,33 @@ import (
 	"path/filepath"
 	"regexp"
 	goruntime "runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"latex-translator/internal/compiler"
+	"latex-translator/internal/compiler/compilelimit"
 	"latex-translator/internal/config"
+	"latex-translator/internal/decisions"
 	"latex-translator/internal/downloader"
 	"latex-translator/internal/errors"
 	"latex-translator/internal/github"
+	"latex-translator/internal/hooks"
+	"latex-translator/internal/htmlexport"
 	"latex-translator/internal/license"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
 	"latex-translator/internal/parser"
 	"latex-translator/internal/pdf"
+	"latex-translator/internal/pdfserve"
+	"latex-translator/internal/postprocess"
+	"latex-translator/internal/qa"
 	"latex-translator/internal/results"
 	"latex-translator/internal/translator"
 	"latex-translator/internal/types"
 	"latex-translator/internal/validator"
+	"latex-translator/internal/workdir"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -37,8 +49,26 @@ import (
 const (
 	EventOriginalPDFReady   = "original-pdf-ready"
 	EventTranslatedPDFReady = "translated-pdf-ready"
+	EventFixReviewRequested = "fix-review-requested"
+
+	EventMainTexChoiceRequested = "main-tex-choice-requested"
+	EventMissingPackages        = "missing-packages"
+	EventCompileErrors          = "compile-errors"
+	EventInterruptedJobs        = "interrupted-jobs"
+	// The file and chunk being translated, with the status of each translation progress
+	EventTranslationProgress = "translation-progress"
+	// A preview of the translated document (front matter and first chapter), built while the
+	// full document compiles
+	EventTranslatedPDFPreviewReady = "translated-pdf-preview-ready"
 )
 
+// previewDirName is the directory of the translated document's preview build in the extract
+// directory; the output_ prefix keeps it out of copies of the source
+const previewDirName = "output_preview"
+
+// manualFixLogName is the compile log saved next to the translated LaTeX when fixes are skipped
+const manualFixLogName = "manual_fix_compile.log"
+
 // Default GitHub repository settings
 const (
 	DefaultGitHubOwner = "rapidaicoder"
@@ -71,20 +101,143 @@ type App struct {
 	statusMu       sync.RWMutex
 	statusCallback StatusCallback
 
+	// Warnings of the current processing session, for status reporting (guarded by statusMu)
+	warnings []string
+
+	// Debug capture of the current or last translation, reported in the status until the
+	// next one starts (guarded by statusMu)
+	debugCapture *translator.DebugCapture
+
 	// Cancellation support
 	cancelFunc context.CancelFunc
 
+	// Skipping the remaining compile-error fixes (cancels only the fix loop)
+	fixCancelFunc context.CancelFunc
+	fixMu         sync.Mutex
+
+	// Risky fixes waiting for ApproveFix, keyed by proposal ID, guarded by fixMu
+	pendingFixes map[string]chan bool
+
+	// Asks about a risky fix on the command line (setFixReviewPrompt); without it the GUI
+	// reviews risky fixes, and they are rejected when there is no GUI
+	fixReviewPrompt func(proposal compiler.FixProposal) bool
+
+	// Duplicate submission detection: the job running in this process and the
+	// registry of jobs running in all processes
+	jobs               *results.JobRegistry
+	activeJob          *runningJob
+	jobMu              sync.Mutex
+	// Paper work directories in use by the jobs of this process; cleanup keeps them. Held
+	// while cleaning so a job does not start in a directory being removed.
+	activeWorkspaces map[string]int
+	workspaceMu      sync.Mutex
+	allowDuplicateJobs bool
+	// Translate sources that already appear to be Chinese instead of refusing them
+	forceTranslate bool
+
+	// Script of the translation for this session (CLI --variant); empty uses the config
+	variantOverride types.ChineseVariant
+
+	// Language of the translation for this session (CLI --lang); empty uses the config
+	languageOverride types.TargetLanguage
+
+	// Glossary file for this session (CLI --glossary); empty uses the config
+	glossaryOverride string
+
+	// Prompt preset for this session (CLI --prompt-preset); empty uses the config
+	promptPresetOverride string
+
+	// Layout of the bilingual PDF generated with each translation (CLI --bilingual-layout);
+	// empty is side by side
+	bilingualLayout types.BilingualLayout
+
+	// Main tex file of the jobs of this session (CLI --main-tex), relative to the extracted
+	// source; empty ranks the candidates and lets the GUI choose between strong ones
+	mainTexOverride string
+
+	// Compile process limit for this session (CLI --max-compiles); 0 uses the config
+	compileLimitOverride int
+
+	// Completion hook for this session (CLI --on-complete); empty uses the config
+	completeHookOverride string
+
+	// PDF loaded for translation, reported to the completion hook
+	pdfSource string
+
+	// Token usage of the current translation job, recorded in its library entry
+	usage *translator.UsageMeter
+
+	// Install missing LaTeX packages while compiling for this session (CLI --auto-install-packages)
+	autoInstallOverride bool
+
+	// Remove the \includeonly of main files for this session (CLI --ignore-includeonly)
+	ignoreIncludeOnlyOverride bool
+
+	// Quick translation mode for the following jobs (GUI toggle / CLI --quick), guarded by jobMu
+	quickMode bool
+
+	// No-compile mode for the following jobs (GUI toggle / CLI --no-compile), guarded by jobMu:
+	// translate without a TeX distribution and export HTML instead of PDFs. noCompilePDF also
+	// runs the PDF compiled by arXiv through the PDF translator.
+	noCompile    bool
+	noCompilePDF bool
+
+	// Per-file translate/copy overrides for the following jobs (GUI chunk preview /
+	// CLI --translate-files, --copy-files), guarded by jobMu
+	fileOverrides decisions.Overrides
+
+	// Job downloaded by PrepareJob and waiting for ConfirmJob, guarded by jobMu
+	preparedJob *preparedJob
+
+	// Main tex file candidates waiting for ChooseMainTex, guarded by jobMu
+	mainTexChoice *mainTexChoice
+
+	// Job of this process to resume at the next launch if the application is closed while it
+	// runs (trackJob), guarded by jobMu, and the queue it is saved to
+	trackedJob  *results.ResumeEntry
+	resumeQueue *results.ResumeQueue
+
+	// Status of the tracked job persisted on every update, and the jobs a crash of the
+	// previous process interrupted (recoverInterruptedJobs), guarded by jobMu
+	journal         *results.StatusJournal
+	interruptedJobs []results.JournalEntry
+
 	// Last process result for download
 	lastResult *types.ProcessResult
 
+	// Errors and warnings new in the translated build of the last job whose translated
+	// compile failed after the original compiled, guarded by statusMu
+	lastLogDiff []compiler.LogDiffGroup
+
+	// Version-diff translation support: translations of a previous version keyed by
+	// relative file path, and the reuse statistics of the current run
+	referenceTranslations map[string]*types.TranslationPair
+	reuseStats            *types.ReuseStats
+	// What the translator retried and repaired in the current run, for the quality report
+	translationStats *qa.TranslationStats
+	// The \includeonly directive of the current job's main file and how it was handled
+	includeOnly *types.IncludeOnlyInfo
+
 	// PDF translation support
 	pdfTranslator *pdf.PDFTranslator
 
+	// Large PDFs served to the webview by token URL instead of data URL
+	pdfFiles *pdfserve.Registry
+
 	// isWailsRuntime indicates if the app is running in a Wails environment
 	// This is used to safely skip EventsEmit calls during tests
 	isWailsRuntime bool
 }
 
+// runningJob is a ProcessSource job of this process. Duplicate submissions of the same
+// input wait on done and share its result.
+type runningJob struct {
+	key    string
+	done   chan struct{}
+	result *types.ProcessResult
+	err    error
+}
+
 // safeEmit safely emits an event to the frontend.
 // It only emits events when running in a Wails environment.
 func (a *App) safeEmit(eventName string, data ...interface{}) {
@@ -111,6 +264,7 @@ func NewApp() *App {
 			Progress: 0,
 			Message:  "",
 		},
+		pdfFiles: pdfserve.NewRegistry(),
 	}
 }
 
@@ -123,6 +277,7 @@ func NewAppWithConfig(configPath string) (*App, error) {
 			Progress: 0,
 			Message:  "",
 		},
+		pdfFiles: pdfserve.NewRegistry(),
 	}
 
 	// Initialize config manager
@@ -164,6 +319,9 @@ func (a *App) startup(ctx context.Context) {
 		logger.Error("failed to initialize work directory", err)
 	}
 
+	// The webview loads the PDFs of the work and results directories by path
+	a.pdfFiles.SetRoots(a.pdfRoots)
+
 	// Initialize downloader with work directory
 	a.downloader = downloader.NewSourceDownloader(a.workDir)
 	logger.Debug("downloader initialized", logger.String("workDir", a.workDir))
@@ -243,15 +401,32 @@ func (a *App) startup(ctx context.Context) {
 		logger.String("baseURL", baseURL),
 		logger.Int("concurrency", concurrency))
 	a.translator = translator.NewTranslationEngineWithConfig(apiKey, model, baseURL, 0, concurrency)
+	a.translator.SetMaxNetworkPause(a.config.GetMaxNetworkPause())
+	a.translator.SetStallWindow(a.config.GetStallWindow())
+	a.translator.SetRequestTimeout(a.config.GetRequestTimeout())
+	a.translator.SetIndexSortKeys(a.config.GetIndexSortKeys())
+	a.translator.SetTranslateComments(a.config.GetTranslateComments())
+	a.translator.SetTranslateBibliography(a.config.GetTranslateBibliography())
+	a.translator.SetChunkOverlap(a.config.GetChunkOverlap())
+	a.translator.SetRequestShape(a.config.GetRequestShape())
+	a.translator.SetProvider(a.config.GetProvider())
+	a.applyChineseVariant()
 
 	// Initialize compiler with default compiler from config
 	defaultCompiler := a.config.GetDefaultCompiler()
 	// Use 10 minute timeout for large projects
 	a.compiler = compiler.NewLaTeXCompiler(defaultCompiler, a.workDir, 10*time.Minute)
-	logger.Debug("compiler initialized", logger.String("compiler", defaultCompiler))
+	// latexmk runs biber, makeindex and as many passes as the document needs
+	a.compiler.SetUseLatexmk(compiler.LatexmkInstalled())
+	a.applyAutoInstallPackages()
+	a.applyCompileLimit()
+	logger.Debug("compiler initialized",
+		logger.String("compiler", defaultCompiler),
+		logger.Bool("latexmk", compiler.LatexmkInstalled()))
 
 	// Initialize validator with API key and base URL from config
 	a.validator = validator.NewSyntaxValidatorWithConfig(apiKey, model, baseURL, 0)
+	a.validator.SetProvider(a.config.GetProvider())
 	logger.Debug("validator initialized", logger.String("baseURL", baseURL))
 
 	// Initialize PDF translator with config
@@ -287,9 +462,22 @@ func (a *App) startup(ctx context.Context) {
 		logger.Warn("failed to initialize result manager", logger.Err(err))
 	} else {
 		a.results = resultMgr
+		a.jobs = results.NewJobRegistry(resultMgr.GetBaseDir())
+		a.resumeQueue = results.NewResumeQueue(resultMgr.GetBaseDir())
 		logger.Debug("result manager initialized", logger.String("baseDir", resultMgr.GetBaseDir()))
 	}
 
+	// A status journal left behind by the previous process is a job interrupted by a crash
+	if configDir, err := config.GetConfigDir(); err != nil {
+		logger.Warn("failed to locate status journal", logger.Err(err))
+	} else {
+		a.journal = results.NewStatusJournal(configDir)
+		a.recoverInterruptedJobs()
+	}
+
+	// Keep the paper directories of the work directory within the cleanup policy
+	go a.applyWorkspacePolicy()
+
 	// Initialize error manager
 	errorMgr, err := errors.NewErrorManager("")
 	if err != nil {
@@ -433,11 +621,22 @@ func (a *App) ReloadConfig() error {
 		logger.Int("concurrency", concurrency))
 	if a.translator != nil {
 		a.translator = translator.NewTranslationEngineWithConfig(apiKey, model, baseURL, 0, concurrency)
+		a.translator.SetMaxNetworkPause(a.config.GetMaxNetworkPause())
+		a.translator.SetStallWindow(a.config.GetStallWindow())
+		a.translator.SetRequestTimeout(a.config.GetRequestTimeout())
+		a.translator.SetIndexSortKeys(a.config.GetIndexSortKeys())
+		a.translator.SetTranslateComments(a.config.GetTranslateComments())
+		a.translator.SetTranslateBibliography(a.config.GetTranslateBibliography())
+		a.translator.SetChunkOverlap(a.config.GetChunkOverlap())
+		a.translator.SetRequestShape(a.config.GetRequestShape())
+		a.translator.SetProvider(a.config.GetProvider())
+		a.applyChineseVariant()
 	}
 
 	// Update validator with new API key and base URL
 	if a.validator != nil {
 		a.validator = validator.NewSyntaxValidatorWithConfig(apiKey, model, baseURL, 0)
+		a.validator.SetProvider(a.config.GetProvider())
 	}
 
 	// Update compiler with new default compiler
@@ -445,6 +644,7 @@ func (a *App) ReloadConfig() error {
 	if a.compiler != nil {
 		a.compiler.SetCompiler(defaultCompiler)
 	}
+	a.applyCompileLimit()
 
 	// Update work directory if configured
 	configWorkDir := a.config.GetWorkDirectory()
@@ -531,6 +731,10 @@ func (a *App) applyLicenseLLMConfig() {
 		); err != nil {
 			logger.Warn("failed to save license LLM config", logger.Err(err))
 		}
+		// Claude licenses are served through the Anthropic Messages API
+		if err := a.config.SetProvider(types.NormalizeProvider(activationData.LLMType)); err != nil {
+			logger.Warn("failed to save license LLM provider", logger.Err(err))
+		}
 	}
 
 	// Update translator with new config
@@ -542,6 +746,16 @@ func (a *App) applyLicenseLLMConfig() {
 			0,
 			concurrency,
 		)
+		a.translator.SetMaxNetworkPause(a.config.GetMaxNetworkPause())
+		a.translator.SetStallWindow(a.config.GetStallWindow())
+		a.translator.SetRequestTimeout(a.config.GetRequestTimeout())
+		a.translator.SetIndexSortKeys(a.config.GetIndexSortKeys())
+		a.translator.SetTranslateComments(a.config.GetTranslateComments())
+		a.translator.SetTranslateBibliography(a.config.GetTranslateBibliography())
+		a.translator.SetChunkOverlap(a.config.GetChunkOverlap())
+		a.translator.SetRequestShape(a.config.GetRequestShape())
+		a.translator.SetProvider(a.config.GetProvider())
+		a.applyChineseVariant()
 	}
 
 	// Update validator with new config
@@ -552,6 +766,7 @@ func (a *App) applyLicenseLLMConfig() {
 			effectiveBaseURL, // Use effective base URL
 			0,
 		)
+		a.validator.SetProvider(a.config.GetProvider())
 	}
 
 	// Update PDF translator with new config
@@ -584,11 +799,61 @@ func (a *App) GetStatus() *types.Status {
 
 	// Return a copy to prevent external modification
 	return &types.Status{
-		Phase:    a.status.Phase,
-		Progress: a.status.Progress,
-		Message:  a.status.Message,
-		Error:    a.status.Error,
+		Phase:           a.status.Phase,
+		Progress:        a.status.Progress,
+		Message:         a.status.Message,
+		Error:           a.status.Error,
+		CachedChunks:    a.status.CachedChunks,
+		// Only runs that captured something have files to attach to a bug report
+		DebugCaptureDir: captureDirIfUsed(a.debugCapture),
+		Translation:     copyTranslationDetail(a.status.Translation),
+	}
+}
+
+// copyTranslationDetail returns a copy of detail, nil for nil
+func copyTranslationDetail(detail *types.TranslationDetail) *types.TranslationDetail {
+	if detail == nil {
+		return nil
+	}
+	c := *detail
+	return &c
+}
+
+// captureDirIfUsed returns the run directory of a debug capture that saved chunks, or ""
+func captureDirIfUsed(c *translator.DebugCapture) string {
+	if c.Count() == 0 {
+		return ""
 	}
+	return c.Dir()
+}
+
+// GetWarnings returns the warnings of the current processing session.
+// This method is thread-safe.
+func (a *App) GetWarnings() []string {
+	a.statusMu.RLock()
+	defer a.statusMu.RUnlock()
+	return append([]string(nil), a.warnings...)
+}
+
+// addWarning records a warning of the current processing session
+func (a *App) addWarning(warning string) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.warnings = append(a.warnings, warning)
+}
+
+// setCachedChunks records how many chunks of the current translation came from the chunk cache
+func (a *App) setCachedChunks(n int) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.status.CachedChunks = n
+}
+
+// resetWarnings clears the warnings at the start of a processing session
+func (a *App) resetWarnings() {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.warnings = nil
 }
 
 // IsProcessing returns true if a translation task is currently in progress.
@@ -628,27 +893,261 @@ func (a *App) IsPDFTranslating() bool {
 }
 
 // IsAnyTranslationInProgress returns true if any translation task (LaTeX or PDF) is in progress.
-// This is used for close confirmation dialog.
+// GetCloseSummary lists the work for the close confirmation dialog.
 func (a *App) IsAnyTranslationInProgress() bool {
 	return a.IsProcessing() || a.IsPDFTranslating()
 }
 
+// GetCloseSummary returns the work that closing the application would cancel: the running
+// LaTeX and PDF translations, the jobs waiting for confirmation and the fixes waiting for
+// review. The close confirmation dialog lists its items.
+func (a *App) GetCloseSummary() *types.CloseSummary {
+	summary := &types.CloseSummary{}
+
+	if a.IsProcessing() {
+		status := a.GetStatus()
+		summary.LaTeXPhase = string(status.Phase)
+		summary.LaTeXProgress = status.Progress
+		summary.LaTeXMessage = status.Message
+		summary.Items = append(summary.Items, fmt.Sprintf("LaTeX 翻译：%s（%d%%）", status.Message, status.Progress))
+	}
+	if a.IsPDFTranslating() {
+		status := a.pdfTranslator.GetStatus()
+		summary.PDFPhase = string(status.Phase)
+		summary.PDFProgress = status.Progress
+		summary.Items = append(summary.Items, fmt.Sprintf("PDF 翻译：%s（%d%%）", status.Message, status.Progress))
+	}
+
+	a.jobMu.Lock()
+	if a.preparedJob != nil && !a.preparedJob.confirmed {
+		summary.QueuedJobs++
+	}
+	a.jobMu.Unlock()
+	if summary.QueuedJobs > 0 {
+		summary.Items = append(summary.Items, fmt.Sprintf("%d 个已下载、等待确认的任务", summary.QueuedJobs))
+	}
+
+	a.fixMu.Lock()
+	summary.PendingFixes = len(a.pendingFixes)
+	a.fixMu.Unlock()
+	if summary.PendingFixes > 0 {
+		summary.Items = append(summary.Items, fmt.Sprintf("%d 个等待确认的编译修复", summary.PendingFixes))
+	}
+
+	summary.Busy = len(summary.Items) > 0
+	return summary
+}
+
+// trackJob records the job running in this process so that closing the application queues
+// it for the next launch, and starts its status journal. The returned function restores the
+// previously tracked job; the journal is cleared once the outermost job returns.
+func (a *App) trackJob(entry results.ResumeEntry) func() {
+	a.jobMu.Lock()
+	previous := a.trackedJob
+	a.trackedJob = &entry
+	a.jobMu.Unlock()
+	if previous == nil {
+		arxivID := entry.LibraryID
+		if arxivID == "" {
+			arxivID = results.ExtractArxivID(entry.Input)
+		}
+		a.journal.Begin(entry.Input, arxivID)
+	}
+	return func() {
+		a.jobMu.Lock()
+		a.trackedJob = previous
+		a.jobMu.Unlock()
+		if previous == nil {
+			if err := a.journal.Clear(); err != nil {
+				logger.Warn("failed to clear status journal", logger.Err(err))
+			}
+		}
+	}
+}
+
+// shutdownForExit stops the running work in order before the application exits: the
+// interrupted jobs are queued for the next launch, the LaTeX and PDF translations and the
+// fix reviews are cancelled, the job gets up to timeout to checkpoint its partial result,
+// and the TeX processes still running are killed with their child processes.
+func (a *App) shutdownForExit(timeout time.Duration) {
+	logger.Info("orderly shutdown for exit")
+	now := time.Now()
+	var queued []results.ResumeEntry
+
+	a.jobMu.Lock()
+	if a.trackedJob != nil {
+		entry := *a.trackedJob
+		entry.Phase = string(a.GetStatus().Phase)
+		entry.InterruptedAt = now
+		queued = append(queued, entry)
+	}
+	if job := a.preparedJob; job != nil && !job.confirmed {
+		queued = append(queued, results.ResumeEntry{Input: job.input, NeedsConfirmation: true, InterruptedAt: now})
+	}
+	a.jobMu.Unlock()
+
+	if len(queued) > 0 && a.resumeQueue != nil {
+		if err := a.resumeQueue.Add(queued...); err != nil {
+			logger.Warn("failed to save resume queue", logger.Err(err))
+		} else {
+			logger.Info("interrupted jobs queued for the next launch", logger.Int("jobs", len(queued)))
+			// The queue resumes the job; the journal must not offer it a second time
+			a.journal.Clear()
+		}
+	}
+
+	// Cancelling the job also ends its fix loop, which rejects the pending fix reviews
+	if a.IsProcessing() {
+		a.CancelProcess()
+	}
+	if a.IsPDFTranslating() {
+		a.CancelPDFTranslation()
+	}
+
+	// The cancelled job saves its partial result to the library before it returns
+	deadline := time.Now().Add(timeout)
+	for a.IsProcessing() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	a.jobMu.Lock()
+	job := a.activeJob
+	a.jobMu.Unlock()
+	if job != nil {
+		select {
+		case <-job.done:
+		case <-time.After(time.Until(deadline)):
+			logger.Warn("job did not finish before exit", logger.String("key", job.key))
+		}
+	}
+
+	compiler.StopAllProcesses()
+}
+
+// TakeInterruptedJobs returns the jobs interrupted by closing the application last time, in
+// order, and empties the queue. The frontend resumes them after startup.
+func (a *App) TakeInterruptedJobs() []results.ResumeEntry {
+	if a.resumeQueue == nil {
+		return nil
+	}
+	entries, err := a.resumeQueue.Take()
+	if err != nil {
+		logger.Warn("failed to read resume queue", logger.Err(err))
+		return nil
+	}
+	if len(entries) > 0 {
+		logger.Info("resuming jobs interrupted at the last exit", logger.Int("jobs", len(entries)))
+	}
+	return entries
+}
+
+// recoverInterruptedJobs reads the status journal a crash of the previous process left
+// behind. A job whose work directory still exists is saved to its library entry as
+// cancelled, so ContinueTranslation resumes it with the files translated before the crash.
+func (a *App) recoverInterruptedJobs() {
+	entry, err := a.journal.Load()
+	if err != nil {
+		logger.Warn("failed to read status journal", logger.Err(err))
+	}
+	if entry == nil {
+		return
+	}
+	defer a.journal.Clear()
+
+	logger.Info("found job interrupted by a crash",
+		logger.String("input", entry.Input),
+		logger.String("phase", entry.Phase),
+		logger.Int("progress", entry.Progress))
+	if entry.ArxivID == "" || entry.WorkDir == "" || a.results == nil {
+		logger.Info("interrupted job cannot be continued: no library entry or work directory")
+		return
+	}
+	if _, err := os.Stat(entry.WorkDir); err != nil {
+		logger.Info("work directory of interrupted job is gone", logger.String("workDir", entry.WorkDir))
+		return
+	}
+	info, err := a.results.LoadPaperInfo(entry.ArxivID)
+	if err != nil {
+		logger.Info("interrupted job has no library entry", logger.String("arxivID", entry.ArxivID))
+		return
+	}
+	if info.Status == results.StatusComplete {
+		return
+	}
+
+	// The library holds the sources as extracted; the work directory also has the
+	// checkpoint and the translated files
+	sourceDir := a.results.GetLatexSourceDir(entry.ArxivID)
+	if err := copyDir(entry.WorkDir, sourceDir); err != nil {
+		logger.Warn("failed to save work directory of interrupted job", logger.Err(err))
+		return
+	}
+	info.SourceDir = sourceDir
+	info.HasLatexSource = true
+	if info.MainTexFile == "" {
+		info.MainTexFile = entry.MainTexFile
+	}
+	info.Status = results.StatusCancelled
+	info.ErrorMessage = fmt.Sprintf("程序异常退出（可继续，中断于 %d%%）", entry.Progress)
+	if err := a.results.SavePaperInfo(info); err != nil {
+		logger.Warn("failed to save interrupted job", logger.Err(err))
+		return
+	}
+
+	a.jobMu.Lock()
+	a.interruptedJobs = append(a.interruptedJobs, *entry)
+	a.jobMu.Unlock()
+	logger.Info("interrupted job can be continued", logger.String("arxivID", entry.ArxivID))
+}
+
+// domReady is called once the frontend is loaded; it offers to continue the jobs
+// interrupted by a crash
+func (a *App) domReady(ctx context.Context) {
+	if jobs := a.GetInterruptedJobs(); len(jobs) > 0 {
+		a.safeEmit(EventInterruptedJobs, jobs)
+	}
+}
+
+// GetInterruptedJobs returns the jobs a crash of the previous process interrupted, with the
+// phase and progress they had reached. ContinueTranslation resumes them by ArxivID.
+func (a *App) GetInterruptedJobs() []results.JournalEntry {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	return append([]results.JournalEntry(nil), a.interruptedJobs...)
+}
+
 // updateStatus updates the current status and notifies the callback.
 func (a *App) updateStatus(phase types.ProcessPhase, progress int, message string) {
+	a.setStatus(phase, progress, message, nil)
+}
+
+// updateTranslationStatus updates the status with the progress of the file being translated
+// and emits it to the frontend
+func (a *App) updateTranslationStatus(progress int, message string, detail types.TranslationDetail) {
+	a.setStatus(types.PhaseTranslating, progress, message, &detail)
+	a.safeEmit(EventTranslationProgress, detail)
+}
+
+// setStatus updates the current status and notifies the callback. The translation detail
+// only stays while translation progress reports it.
+func (a *App) setStatus(phase types.ProcessPhase, progress int, message string, detail *types.TranslationDetail) {
 	a.statusMu.Lock()
 	a.status.Phase = phase
 	a.status.Progress = progress
 	a.status.Message = message
 	a.status.Error = ""
+	a.status.Translation = detail
+	a.journal.Record(string(phase), progress, message)
 
 	// Get callback while holding lock
 	callback := a.statusCallback
 	// Make a copy for the callback
 	statusCopy := &types.Status{
-		Phase:    a.status.Phase,
-		Progress: a.status.Progress,
-		Message:  a.status.Message,
-		Error:    a.status.Error,
+		Phase:        a.status.Phase,
+		Progress:     a.status.Progress,
+		Message:      a.status.Message,
+		Error:        a.status.Error,
+		CachedChunks: a.status.CachedChunks,
+		Translation:  copyTranslationDetail(a.status.Translation),
 	}
 	a.statusMu.Unlock()
 
@@ -668,10 +1167,12 @@ func (a *App) updateStatusError(errorMsg string) {
 	callback := a.statusCallback
 	// Make a copy for the callback
 	statusCopy := &types.Status{
-		Phase:    a.status.Phase,
-		Progress: a.status.Progress,
-		Message:  a.status.Message,
-		Error:    a.status.Error,
+		Phase:        a.status.Phase,
+		Progress:     a.status.Progress,
+		Message:      a.status.Message,
+		Error:        a.status.Error,
+		CachedChunks: a.status.CachedChunks,
+		Translation:  copyTranslationDetail(a.status.Translation),
 	}
 	a.statusMu.Unlock()
 
@@ -727,19 +1228,48 @@ func (a *App) ProcessSourceWithForce(input string, force bool) (*types.ProcessRe
 		logger.String("input", input),
 		logger.Bool("force", force))
 
+	// A duplicate submission of the running job shares its result
+	if job := a.findRunningJob(a.jobKey(input)); job != nil {
+		return a.attachToJob(job)
+	}
+
 	// Check if already processing to prevent duplicate calls
 	if a.IsProcessing() {
 		logger.Warn("ProcessSourceWithForce called while already processing, ignoring duplicate call")
 		return nil, types.NewAppError(types.ErrInternal, "已有翻译任务正在进行中", nil)
 	}
 
+	// Refuse before deleting results another process is still writing
+	if err := a.checkOtherProcessJob(a.jobKey(input)); err != nil {
+		return nil, err
+	}
+
 	// Check for existing translation
 	existingInfo, err := a.CheckExistingTranslation(input)
 	if err != nil {
 		logger.Warn("failed to check existing translation", logger.Err(err))
 		// Continue anyway - don't block translation due to check failure
 	} else if existingInfo != nil && existingInfo.Exists {
-		if existingInfo.IsComplete {
+		if a.variantMismatch(existingInfo.PaperInfo) {
+			// The stored translation is in the other script: start fresh instead of
+			// returning or continuing it
+			logger.Info("existing translation uses a different Chinese variant, re-translating",
+				logger.String("arxivID", existingInfo.PaperInfo.ArxivID),
+				logger.String("storedVariant", existingInfo.PaperInfo.ChineseVariant),
+				logger.String("variant", string(a.chineseVariant())))
+			if existingInfo.PaperInfo.ArxivID != "" {
+				if err := a.results.DeletePaper(existingInfo.PaperInfo.ArxivID); err != nil {
+					logger.Warn("failed to delete existing paper", logger.Err(err))
+				}
+			}
+		} else if existingInfo.IsComplete && a.replacesQuickTranslation(existingInfo.PaperInfo) {
+			// A full translation replaces a stored quick-mode translation
+			logger.Info("existing translation was made in quick mode, re-translating",
+				logger.String("arxivID", existingInfo.PaperInfo.ArxivID))
+			if err := a.results.DeletePaper(existingInfo.PaperInfo.ArxivID); err != nil {
+				logger.Warn("failed to delete existing paper", logger.Err(err))
+			}
+		} else if existingInfo.IsComplete {
 			// Translation is complete
 			if force {
 				// User wants to re-translate - delete existing and start fresh
@@ -769,7 +1299,11 @@ func (a *App) ProcessSourceWithForce(input string, force bool) (*types.ProcessRe
 					logger.String("arxivID", existingInfo.PaperInfo.ArxivID))
 				return a.ContinueTranslation(existingInfo.PaperInfo.ArxivID)
 			} else {
-				// User wants to restart from scratch - delete existing
+				// User wants to restart from scratch - delete existing and the chunks
+				// translated so far
+				if existingInfo.PaperInfo != nil && existingInfo.PaperInfo.SourceDir != "" && existingInfo.PaperInfo.MainTexFile != "" {
+					a.removeChunkCache(filepath.Join(existingInfo.PaperInfo.SourceDir, existingInfo.PaperInfo.MainTexFile))
+				}
 				if existingInfo.PaperInfo != nil && existingInfo.PaperInfo.ArxivID != "" {
 					logger.Info("deleting existing translation for restart",
 						logger.String("arxivID", existingInfo.PaperInfo.ArxivID))
@@ -797,49 +1331,236 @@ func (a *App) ProcessSourceWithForce(input string, force bool) (*types.ProcessRe
 //  7. Compile translated document to PDF
 //  8. Return ProcessResult with both PDF paths
 //
+// Submitting the same input again while it is being processed attaches to the running
+// job in this process, and is refused when another process (GUI or CLI) is running it
+// unless duplicates are allowed with SetAllowDuplicateJobs.
+//
 // Validates: Requirements 1.1-1.5, 2.1-2.5, 3.1-3.5
 func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 	logger.Info("starting source processing", logger.String("input", input))
 
-	// Check if already processing to prevent duplicate calls
-	if a.IsProcessing() {
+	key := a.jobKey(input)
+	job, attached := a.startOrAttachJob(key)
+	if attached {
+		return a.attachToJob(job)
+	}
+	if job == nil {
 		logger.Warn("ProcessSource called while already processing, ignoring duplicate call")
 		return nil, types.NewAppError(types.ErrInternal, "已有翻译任务正在进行中", nil)
 	}
+	defer a.finishJob(job)
+	defer a.trackJob(results.ResumeEntry{Input: input})()
 
-	// Create a cancellable context for this processing session
-	ctx, cancel := context.WithCancel(a.ctx)
-	a.cancelFunc = cancel
-	defer func() {
-		a.cancelFunc = nil
-	}()
+	release, err := a.registerJob(key, input)
+	if err != nil {
+		job.err = err
+		return nil, err
+	}
+	defer release()
 
-	// Reset status to idle at the start
-	a.updateStatus(types.PhaseIdle, 0, "开始处理...")
+	started := time.Now()
+	tokensBefore := a.tokensUsed()
+	finishScratch := a.redirectToScratch()
+	leaveWorkspace := a.enterPaperWorkspace(input)
+	job.result, job.err = a.processSource(input)
+	leaveWorkspace()
+	finishScratch(job.result)
 
-	// Step 1: Parse input to determine source type
-	a.updateStatus(types.PhaseDownloading, 5, "解析输入...")
-	logger.Debug("parsing input")
+	payload := hooks.Payload{
+		Source:          input,
+		Mode:            hooks.ModeLaTeX,
+		TokensUsed:      max(a.tokensUsed()-tokensBefore, 0),
+		DurationSeconds: time.Since(started).Seconds(),
+	}
+	if job.result != nil {
+		payload.OriginalPDF = job.result.OriginalPDFPath
+		payload.TranslatedPDF = job.result.TranslatedPDFPath
+		payload.BilingualPDF = job.result.BilingualPDFPath
+	}
+	a.notifyCompletion(payload, job.err)
+	return job.result, job.err
+}
+
+// preparedJob is a job whose source PrepareJob downloaded for the confirmation summary;
+// ConfirmJob translates it without downloading again
+type preparedJob struct {
+	id            string
+	input         string
+	sourceInfo    *types.SourceInfo
+	sourceArchive string
+	confirmed     bool
+}
+
+// Rough throughput used for the time estimate of the confirmation summary
+const (
+	secondsPerChunk   = translator.EstimatedSecondsPerChunk
+	compileMinutes    = 3 // compiling the original and the translation
+	outputTokenFactor = translator.EstimatedOutputTokenFactor
+)
+
+// Red flag thresholds of the confirmation summary
+const (
+	hugeProjectTexFiles = 50
+	hugeProjectBytes    = 100 << 20
+	hugeProjectTokens   = 500000
+)
+
+// PrepareJob fetches the arXiv metadata of input, downloads and extracts its source and
+// estimates the cost of translating it, without calling the model. The returned summary
+// has a JobID to pass to ConfirmJob, which translates the downloaded source; it has no
+// JobID when the source cannot be translated (see RedFlags). Only the latest prepared job
+// is kept.
+func (a *App) PrepareJob(input string) (*types.JobSummary, error) {
+	logger.Info("preparing job", logger.String("input", input))
+	if a.IsProcessing() {
+		return nil, types.NewAppError(types.ErrInternal, "已有翻译任务正在进行中", nil)
+	}
 
 	sourceType, err := parser.ParseInput(input)
 	if err != nil {
-		logger.Error("input parsing failed", err, logger.String("input", input))
-		a.updateStatusError(fmt.Sprintf("下载失败: %v", err))
 		return nil, err
 	}
+	summary := &types.JobSummary{Input: input}
 
-	logger.Info("input parsed successfully", logger.String("sourceType", string(sourceType)))
+	if sourceType != types.SourceTypeLocalZip {
+		if arxivID := results.ExtractArxivID(input); arxivID != "" {
+			summary.ArxivID = arxivID
+			a.updateStatus(types.PhaseDownloading, 5, "获取论文信息...")
+			if meta, err := a.downloader.FetchArxivMetadata(arxivID); err != nil {
+				logger.Warn("failed to fetch arXiv metadata", logger.Err(err))
+				summary.RedFlags = append(summary.RedFlags, fmt.Sprintf("无法获取 arXiv 论文信息: %v", err))
+			} else {
+				meta.FillJobSummary(summary)
+			}
+		}
+	}
 
-	// Check for cancellation
-	if ctx.Err() != nil {
-		logger.Warn("processing cancelled")
-		a.updateStatusError("已取消")
-		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
+	finishScratch := a.redirectToScratch()
+	leaveWorkspace := a.enterPaperWorkspace(input)
+	sourceInfo, sourceArchive, err := a.downloadSource(input, sourceType)
+	leaveWorkspace()
+	finishScratch(nil)
+	if err != nil {
+		summary.RedFlags = append(summary.RedFlags, downloader.SourceRedFlag(err))
+		return summary, nil
 	}
 
-	// Step 2: Download/extract source code based on type
-	var sourceInfo *types.SourceInfo
+	preview, err := a.PreviewChunking(sourceInfo.ExtractDir)
+	if err != nil {
+		summary.RedFlags = append(summary.RedFlags, fmt.Sprintf("未找到主 tex 文件: %v", err))
+		a.updateStatus(types.PhaseIdle, 0, "")
+		return summary, nil
+	}
+	summary.MainTexFile = preview.MainTexFile
+	summary.TotalChunks = preview.TotalChunks
+	summary.EstimatedTokens = preview.EstimatedTokens * (1 + outputTokenFactor)
+	summary.TexFiles, summary.SourceSize = sourceStats(sourceInfo.ExtractDir)
+	if summary.Title == "" {
+		if content, err := os.ReadFile(filepath.Join(sourceInfo.ExtractDir, preview.MainTexFile)); err == nil {
+			summary.Title = results.ExtractTitleFromTeX(string(content))
+		}
+	}
+	concurrency := 1
+	if a.config != nil && a.config.GetConcurrency() > 1 {
+		concurrency = a.config.GetConcurrency()
+	}
+	summary.EstimatedMinutes = (preview.TotalChunks*secondsPerChunk/concurrency+59)/60 + compileMinutes
+
+	if summary.TexFiles > hugeProjectTexFiles || summary.SourceSize > hugeProjectBytes || summary.EstimatedTokens > hugeProjectTokens {
+		summary.RedFlags = append(summary.RedFlags, fmt.Sprintf("项目较大（%d 个 tex 文件，%.1f MB，约 %d token），翻译耗时和费用较高",
+			summary.TexFiles, float64(summary.SourceSize)/(1<<20), summary.EstimatedTokens))
+	}
+
+	job := &preparedJob{
+		id:            fmt.Sprintf("job-%d", time.Now().UnixNano()),
+		input:         input,
+		sourceInfo:    sourceInfo,
+		sourceArchive: sourceArchive,
+	}
+	a.jobMu.Lock()
+	previous := a.preparedJob
+	a.preparedJob = job
+	a.jobMu.Unlock()
+	if previous != nil {
+		os.RemoveAll(previous.sourceInfo.ExtractDir)
+	}
+	summary.JobID = job.id
+
+	logger.Info("job prepared",
+		logger.String("jobID", job.id),
+		logger.String("extractDir", sourceInfo.ExtractDir),
+		logger.Int("chunks", summary.TotalChunks),
+		logger.Int("estimatedTokens", summary.EstimatedTokens))
+	a.updateStatus(types.PhaseIdle, 0, "等待确认...")
+	return summary, nil
+}
+
+// sourceStats counts the tex files and bytes of an extracted source
+func sourceStats(dir string) (texFiles int, size int64) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		size += info.Size()
+		if strings.EqualFold(filepath.Ext(path), ".tex") {
+			texFiles++
+		}
+		return nil
+	})
+	return texFiles, size
+}
+
+// ConfirmJob translates a job prepared by PrepareJob, reusing its downloaded source
+func (a *App) ConfirmJob(jobID string) (*types.ProcessResult, error) {
+	logger.Info("job confirmed", logger.String("jobID", jobID))
+	a.jobMu.Lock()
+	job := a.preparedJob
+	if job == nil || job.id != jobID {
+		a.jobMu.Unlock()
+		return nil, types.NewAppError(types.ErrInvalidInput, "任务不存在或已过期，请重新提交", nil)
+	}
+	job.confirmed = true
+	a.jobMu.Unlock()
+	return a.ProcessSource(job.input)
+}
+
+// DiscardJob drops a job prepared by PrepareJob and deletes its downloaded source
+func (a *App) DiscardJob(jobID string) {
+	a.jobMu.Lock()
+	job := a.preparedJob
+	if job == nil || job.id != jobID {
+		a.jobMu.Unlock()
+		return
+	}
+	a.preparedJob = nil
+	a.jobMu.Unlock()
+	logger.Info("prepared job discarded", logger.String("jobID", jobID))
+	os.RemoveAll(job.sourceInfo.ExtractDir)
+	a.updateStatus(types.PhaseIdle, 0, "")
+}
+
+// takeConfirmedJob returns the confirmed prepared job of input, if any, and forgets it
+func (a *App) takeConfirmedJob(input string) *preparedJob {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	job := a.preparedJob
+	if job == nil || !job.confirmed || job.input != input {
+		return nil
+	}
+	a.preparedJob = nil
+	if _, err := os.Stat(job.sourceInfo.ExtractDir); err != nil {
+		return nil
+	}
+	return job
+}
 
+// downloadSource downloads (arXiv ID or URL) and extracts the source of a job, or extracts
+// a local archive. It returns the source and the archive it came from, for the provenance
+// checksum.
+func (a *App) downloadSource(input string, sourceType types.SourceType) (*types.SourceInfo, string, error) {
+	var sourceInfo *types.SourceInfo
+	var sourceArchive string
+	var err error
 	switch sourceType {
 	case types.SourceTypeURL:
 		a.updateStatus(types.PhaseDownloading, 10, "下载 URL 源码...")
@@ -848,17 +1569,18 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		if err != nil {
 			logger.Error("download from URL failed", err, logger.String("url", input))
 			a.updateStatusError(fmt.Sprintf("下载失败: %v", err))
-			return nil, err
+			return nil, "", err
 		}
 
 		// Extract the downloaded archive
 		a.updateStatus(types.PhaseExtracting, 20, "解压源码...")
 		logger.Debug("extracting downloaded archive")
+		sourceArchive = sourceInfo.ExtractDir
 		sourceInfo, err = a.downloader.ExtractZip(sourceInfo.ExtractDir)
 		if err != nil {
 			logger.Error("extraction failed", err)
 			a.updateStatusError(fmt.Sprintf("解压失败: %v", err))
-			return nil, err
+			return nil, "", err
 		}
 		sourceInfo.SourceType = types.SourceTypeURL
 		sourceInfo.OriginalRef = input
@@ -875,12 +1597,13 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 			if arxivID != "" {
 				a.recordError(arxivID, arxivID, input, errors.StageDownload, err.Error())
 			}
-			return nil, err
+			return nil, "", err
 		}
 
 		// Extract the downloaded archive
 		a.updateStatus(types.PhaseExtracting, 20, "解压源码...")
 		logger.Debug("extracting downloaded archive")
+		sourceArchive = sourceInfo.ExtractDir
 		sourceInfo, err = a.downloader.ExtractZip(sourceInfo.ExtractDir)
 		if err != nil {
 			logger.Error("extraction failed", err)
@@ -890,7 +1613,7 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 			if arxivID != "" {
 				a.recordError(arxivID, arxivID, input, errors.StageExtract, err.Error())
 			}
-			return nil, err
+			return nil, "", err
 		}
 		sourceInfo.SourceType = types.SourceTypeArxivID
 		sourceInfo.OriginalRef = input
@@ -898,39 +1621,346 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 	case types.SourceTypeLocalZip:
 		a.updateStatus(types.PhaseExtracting, 15, "解压本地文件...")
 		logger.Info("extracting local zip file", logger.String("path", input))
+		sourceArchive = input
 		sourceInfo, err = a.downloader.ExtractZip(input)
 		if err != nil {
 			logger.Error("extraction failed", err, logger.String("path", input))
 			a.updateStatusError(fmt.Sprintf("解压失败: %v", err))
-			return nil, err
+			return nil, "", err
 		}
 
 	default:
 		err := types.NewAppError(types.ErrInvalidInput, "不支持的输入类型", nil)
 		logger.Error("unsupported input type", err)
 		a.updateStatusError(err.Error())
-		return nil, err
+		return nil, "", err
 	}
+	return sourceInfo, sourceArchive, nil
+}
 
-	// Check for cancellation
-	if ctx.Err() != nil {
-		logger.Warn("processing cancelled")
-		a.updateStatusError("已取消")
-		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
-	}
+// CheckWorkDirectory checks a work directory setting before it is saved: whether it is
+// inside a sync folder (OneDrive, Dropbox, ...) and whether files can be written there.
+func (a *App) CheckWorkDirectory(path string) *types.WorkDirCheck {
+	return workdir.Check(path)
+}
 
-	// Step 2.5: Preprocess tex files to fix common issues
-	a.updateStatus(types.PhaseExtracting, 22, "预处理 LaTeX 源文件...")
-	logger.Info("preprocessing tex files", logger.String("extractDir", sourceInfo.ExtractDir))
-	if err := compiler.PreprocessTexFiles(sourceInfo.ExtractDir); err != nil {
-		// Log warning but don't fail - preprocessing is best-effort
-		logger.Warn("preprocessing failed", logger.Err(err))
+// redirectToScratch moves a job to a local scratch directory when the work directory is
+// in a sync folder or not writable: sync clients lock files while uploading them and
+// every LaTeX pass rewrites the aux files. The returned function restores the work
+// directory and publishes the final PDFs and tex files of the job to it.
+func (a *App) redirectToScratch() func(result *types.ProcessResult) {
+	noop := func(*types.ProcessResult) {}
+	workDir := a.workDir
+	if workDir == "" || a.isTemporaryWorkDir() || a.downloader == nil {
+		return noop
+	}
+	check := workdir.Check(workDir)
+	if !check.UseScratch {
+		return noop
+	}
+	scratch, err := workdir.ScratchDir(workDir)
+	if err != nil {
+		logger.Warn("failed to create local scratch directory, using work directory",
+			logger.String("workDir", workDir), logger.Err(err))
+		return noop
+	}
+
+	logger.Info("running job in local scratch directory",
+		logger.String("workDir", workDir),
+		logger.String("scratch", scratch),
+		logger.String("syncProvider", check.SyncProvider),
+		logger.Bool("writable", check.Writable))
+	a.downloader.SetWorkDir(scratch)
+
+	return func(result *types.ProcessResult) {
+		a.downloader.SetWorkDir(workDir)
+		a.addWarning(check.Message)
+		if result != nil && check.Writable {
+			a.publishFromScratch(result, scratch, workDir)
+		}
+	}
+}
+
+// paperWorkspaceName returns the name of the work directory of input: the library ID of
+// the paper, which is the arXiv ID or md5_ and the hash of a local zip file. Other inputs
+// are named by a hash of the input.
+func paperWorkspaceName(input string) string {
+	if arxivID := results.ExtractArxivID(input); arxivID != "" {
+		return results.SanitizeFileName(arxivID)
+	}
+	if md5Hash, err := results.CalculateFileMD5(input); err == nil {
+		return "md5_" + md5Hash[:16]
+	}
+	sum := sha256.Sum256([]byte(input))
+	return "input_" + hex.EncodeToString(sum[:8])
+}
+
+// enterPaperWorkspace points the downloader at the directory of input under its current
+// work directory, so every source gets its own predictable subdirectory. The returned
+// function restores the previous directory.
+func (a *App) enterPaperWorkspace(input string) func() {
+	if a.downloader == nil {
+		return func() {}
+	}
+	a.workspaceMu.Lock()
+	defer a.workspaceMu.Unlock()
+
+	parent := a.downloader.GetWorkDir()
+	name := paperWorkspaceName(input)
+	dir := workdir.PaperDir(parent, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Warn("failed to create paper work directory, using work directory",
+			logger.String("dir", dir), logger.Err(err))
+		return func() {}
+	}
+	// Touch the directory so cleanup sees it as recently used even before new files appear
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+
+	if a.activeWorkspaces == nil {
+		a.activeWorkspaces = make(map[string]int)
+	}
+	a.activeWorkspaces[name]++
+	a.downloader.SetWorkDir(dir)
+
+	return func() {
+		a.workspaceMu.Lock()
+		defer a.workspaceMu.Unlock()
+		a.downloader.SetWorkDir(parent)
+		if a.activeWorkspaces[name]--; a.activeWorkspaces[name] <= 0 {
+			delete(a.activeWorkspaces, name)
+		}
+	}
+}
+
+// workspaceProtection returns which paper directories cleanup must keep: those of running
+// jobs, of the job waiting for ConfirmJob and of library papers that are in progress or can be resumed, which is every status
+// but complete. Call with workspaceMu held.
+func (a *App) workspaceProtection() func(name string) bool {
+	protected := make(map[string]bool)
+	for name := range a.activeWorkspaces {
+		protected[name] = true
+	}
+	a.jobMu.Lock()
+	if a.preparedJob != nil {
+		protected[paperWorkspaceName(a.preparedJob.input)] = true
+	}
+	a.jobMu.Unlock()
+	if a.results != nil {
+		papers, err := a.results.ListPapers()
+		if err != nil {
+			logger.Warn("failed to list papers for work directory cleanup", logger.Err(err))
+		}
+		for _, paper := range papers {
+			if paper.Status != results.StatusComplete {
+				protected[results.SanitizeFileName(paper.ArxivID)] = true
+			}
+		}
+	}
+	return func(name string) bool { return protected[name] }
+}
+
+// GetWorkspaceUsage reports the disk usage of the work directory: the size and last use of
+// every paper directory and the size of everything else
+func (a *App) GetWorkspaceUsage() (*types.WorkspaceUsage, error) {
+	if a.workDir == "" {
+		return nil, types.NewAppError(types.ErrInternal, "工作目录未初始化", nil)
+	}
+	a.workspaceMu.Lock()
+	protected := a.workspaceProtection()
+	a.workspaceMu.Unlock()
+
+	usage, err := workdir.Usage(a.workDir, protected)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "读取工作目录失败", err)
+	}
+	return usage, nil
+}
+
+// CleanWorkspaces removes the least recently used paper directories by the cleanup policy
+// of the settings, keeping at most keepRecent of them; a negative keepRecent uses the
+// setting. Directories of running jobs and of papers that can be resumed are kept.
+func (a *App) CleanWorkspaces(keepRecent int) (*types.WorkspaceCleanResult, error) {
+	policy := a.GetWorkspacePolicy()
+	if keepRecent >= 0 {
+		policy.KeepRecent = keepRecent
+	}
+	return a.cleanWorkspaces(policy, false)
+}
+
+// cleanWorkspaces applies policy to the paper directories of the work directory
+func (a *App) cleanWorkspaces(policy types.WorkspacePolicy, dryRun bool) (*types.WorkspaceCleanResult, error) {
+	if a.workDir == "" {
+		return nil, types.NewAppError(types.ErrInternal, "工作目录未初始化", nil)
+	}
+	a.workspaceMu.Lock()
+	defer a.workspaceMu.Unlock()
+
+	result, err := workdir.Clean(a.workDir, policy, a.workspaceProtection(), dryRun, time.Now())
+	if err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "清理工作目录失败", err)
+	}
+	logger.Info("cleaned paper work directories",
+		logger.String("workDir", a.workDir),
+		logger.Bool("dryRun", dryRun),
+		logger.Int("removed", len(result.Removed)),
+		logger.Int("kept", result.Kept),
+		logger.Int("freedMB", int(result.FreedBytes>>20)))
+	return result, nil
+}
+
+// applyWorkspacePolicy runs the cleanup policy of the settings at startup
+func (a *App) applyWorkspacePolicy() {
+	if a.workDir == "" || a.isTemporaryWorkDir() {
+		return
+	}
+	if _, err := a.cleanWorkspaces(a.GetWorkspacePolicy(), false); err != nil {
+		logger.Warn("work directory cleanup failed", logger.Err(err))
+	}
+}
+
+// GetWorkspacePolicy returns the cleanup policy of the paper directories of the work directory
+func (a *App) GetWorkspacePolicy() types.WorkspacePolicy {
+	if a.config == nil {
+		return workdir.DefaultPolicy
+	}
+	return a.config.GetWorkspacePolicy()
+}
+
+// SetWorkspacePolicy saves the cleanup policy of the paper directories; 0 means no limit
+func (a *App) SetWorkspacePolicy(policy types.WorkspacePolicy) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	return a.config.SetWorkspacePolicy(policy)
+}
+
+// publishFromScratch copies the final artifacts of a job run in scratch to the work
+// directory and points the result at the copies. Aux files and logs stay in scratch.
+func (a *App) publishFromScratch(result *types.ProcessResult, scratch, workDir string) {
+	if result.SourceInfo != nil && result.SourceInfo.ExtractDir != "" {
+		sourceDir := result.SourceInfo.ExtractDir
+		target, ok := workdir.Relocate(sourceDir, scratch, workDir)
+		if !ok {
+			target = filepath.Join(workDir, filepath.Base(sourceDir))
+		}
+		copied, err := workdir.Publish(sourceDir, target)
+		if err != nil {
+			logger.Error("failed to publish results to work directory", err,
+				logger.String("sourceDir", sourceDir), logger.String("target", target))
+			a.addWarning(fmt.Sprintf("无法把结果复制到工作目录 %s: %v，结果保留在 %s", workDir, err, sourceDir))
+			return
+		}
+		logger.Info("published results to work directory",
+			logger.String("target", target), logger.Int("files", copied))
+		result.PublishedDir = target
+	}
+
+	// Result files outside the source directory are copied one by one
+	for _, path := range []*string{&result.OriginalPDFPath, &result.TranslatedPDFPath, &result.BilingualPDFPath,
+		&result.TranslatedHTMLPath, &result.TranslatedTexPath} {
+		if *path == "" {
+			continue
+		}
+		if result.SourceInfo != nil && result.PublishedDir != "" {
+			if published, ok := workdir.Relocate(*path, result.SourceInfo.ExtractDir, result.PublishedDir); ok {
+				*path = published
+				continue
+			}
+		}
+		if published, ok := workdir.Relocate(*path, scratch, workDir); ok {
+			if err := workdir.CopyFile(*path, published); err != nil {
+				logger.Warn("failed to publish result file", logger.String("path", *path), logger.Err(err))
+				continue
+			}
+			*path = published
+		}
+	}
+}
+
+// processSource runs the translation flow of ProcessSource
+func (a *App) processSource(input string) (*types.ProcessResult, error) {
+	// Create a cancellable context for this processing session
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.cancelFunc = cancel
+	defer func() {
+		a.cancelFunc = nil
+	}()
+
+	// Reset status to idle at the start
+	a.updateStatus(types.PhaseIdle, 0, "开始处理...")
+	a.resetWarnings()
+	a.statusMu.Lock()
+	a.lastLogDiff = nil
+	a.statusMu.Unlock()
+	a.beginUsage(nil)
+	jobStart := time.Now()
+	a.warnContextWindow()
+
+	// Quick mode applies to the whole job even if it is toggled while the job runs
+	quick := a.IsQuickMode()
+	noCompile, noCompilePDF := a.noCompileSettings()
+	if noCompile {
+		logger.Info("processing without compiling", logger.Bool("translateArxivPDF", noCompilePDF))
+	}
+	if quick {
+		logger.Info("processing in quick mode", logger.String("downgrades", strings.Join(quickModeDowngrades, "; ")))
+		a.translator.SetChunkSize(translator.QuickChunkSize)
+		defer a.translator.SetChunkSize(0)
+	}
+
+	// Step 1: Parse input to determine source type
+	a.updateStatus(types.PhaseDownloading, 5, "解析输入...")
+	logger.Debug("parsing input")
+
+	sourceType, err := parser.ParseInput(input)
+	if err != nil {
+		logger.Error("input parsing failed", err, logger.String("input", input))
+		a.updateStatusError(fmt.Sprintf("下载失败: %v", err))
+		return nil, err
+	}
+
+	logger.Info("input parsed successfully", logger.String("sourceType", string(sourceType)))
+
+	// Check for cancellation
+	if ctx.Err() != nil {
+		logger.Warn("processing cancelled")
+		a.updateStatusError("已取消")
+		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
+	}
+
+	// Step 2: Download/extract source code based on type; a job confirmed after PrepareJob
+	// reuses the source downloaded for its summary
+	var sourceInfo *types.SourceInfo
+	var sourceArchive string // downloaded or local archive, used for provenance checksum
+	if prepared := a.takeConfirmedJob(input); prepared != nil {
+		logger.Info("using source downloaded for confirmation",
+			logger.String("jobID", prepared.id),
+			logger.String("extractDir", prepared.sourceInfo.ExtractDir))
+		sourceInfo, sourceArchive = prepared.sourceInfo, prepared.sourceArchive
+	} else if sourceInfo, sourceArchive, err = a.downloadSource(input, sourceType); err != nil {
+		return nil, err
+	}
+
+	// Check for cancellation
+	if ctx.Err() != nil {
+		logger.Warn("processing cancelled")
+		a.updateStatusError("已取消")
+		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
+	}
+
+	// Step 2.5: Preprocess tex files to fix common issues
+	a.updateStatus(types.PhaseExtracting, 22, "预处理 LaTeX 源文件...")
+	logger.Info("preprocessing tex files", logger.String("extractDir", sourceInfo.ExtractDir))
+	if err := compiler.PreprocessTexFiles(sourceInfo.ExtractDir); err != nil {
+		// Log warning but don't fail - preprocessing is best-effort
+		logger.Warn("preprocessing failed", logger.Err(err))
 	}
 
 	// Step 3: Find main tex file
 	a.updateStatus(types.PhaseExtracting, 25, "查找主 tex 文件...")
 	logger.Debug("finding main tex file", logger.String("extractDir", sourceInfo.ExtractDir))
-	mainTexFile, err := a.downloader.FindMainTexFile(sourceInfo.ExtractDir)
+	candidates, err := a.findMainTexCandidates(sourceInfo.ExtractDir)
 	if err != nil {
 		logger.Error("failed to find main tex file", err)
 		a.updateStatusError(fmt.Sprintf("未找到主 tex 文件: %v", err))
@@ -943,10 +1973,23 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		}
 		return nil, err
 	}
+	mainTexCandidates, err := a.chooseMainTex(ctx, candidates)
+	if err != nil {
+		logger.Warn("processing cancelled while choosing the main tex file")
+		a.updateStatusError("已取消")
+		return nil, types.NewAppError(types.ErrInternal, "已取消", err)
+	}
+	mainTexFile := mainTexCandidates[0]
 	sourceInfo.MainTexFile = mainTexFile
-	logger.Info("found main tex file", logger.String("mainTexFile", mainTexFile))
+	logger.Info("found main tex file",
+		logger.String("mainTexFile", mainTexFile),
+		logger.Int("candidates", len(mainTexCandidates)))
 
 	mainTexPath := filepath.Join(sourceInfo.ExtractDir, mainTexFile)
+	a.journal.SetWorkDir(sourceInfo.ExtractDir, mainTexFile)
+
+	// Step 3.5: Handle \includeonly the same way for the original and the translated build
+	a.includeOnly = a.applyIncludeOnly(mainTexPath)
 
 	// Extract arXiv ID and title for saving intermediate results
 	arxivID := results.ExtractArxivID(input)
@@ -991,54 +2034,116 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		// For very large projects (50+ files), use even longer timeout
 		if texFileCount > 50 {
 			a.compiler = compiler.NewLaTeXCompiler(a.compiler.GetCompiler(), a.workDir, 15*time.Minute)
+			a.compiler.SetUseLatexmk(compiler.LatexmkInstalled())
+			a.applyAutoInstallPackages()
 			logger.Info("using extended timeout for very large project", 
 				logger.String("timeout", "15 minutes"))
 		}
 	}
 
-	// Step 4: Compile original document to PDF
-	a.updateStatus(types.PhaseCompiling, 30, "编译原始文档...")
-	logger.Info("compiling original document", logger.String("texPath", mainTexPath))
-	originalOutputDir := filepath.Join(sourceInfo.ExtractDir, "output_original")
-	originalResult, err := a.compiler.Compile(mainTexPath, originalOutputDir)
-	if err != nil {
-		logger.Error("original document compilation failed", err)
-		a.updateStatusError(fmt.Sprintf("原始文档编译失败: %v", err))
-		// Save intermediate result on error
-		if arxivID != "" {
-			a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, err.Error(), "", "")
-			// 记录原始编译错误
-			a.recordError(arxivID, title, input, errors.StageOriginalCompile, err.Error())
-		}
-		return nil, err
+	// Quick mode compiles each document only once (the compiler may have been replaced above)
+	if quick {
+		a.compiler.SetMaxPasses(1)
+		defer func() {
+			a.compiler.SetMaxPasses(0)
+		}()
 	}
-	if !originalResult.Success {
-		err := types.NewAppErrorWithDetails(types.ErrCompile, "原始文档编译失败", originalResult.ErrorMsg, nil)
-		logger.Error("original document compilation failed", err, logger.String("errorMsg", originalResult.ErrorMsg))
-		a.updateStatusError(err.Error())
-		// Save intermediate result on error
+
+	// Step 4: Compile original document to PDF (skipped in no-compile mode)
+	var originalResult *types.CompileResult
+	if noCompile {
+		logger.Info("skipping original compilation in no-compile mode")
+		a.updateStatus(types.PhaseCompiling, 30, "未编译模式，跳过原始文档编译...")
+	} else {
+		// Missing packages would fail the compilation deep in the log; report them up front,
+		// unless the compiler installs them
+		a.updateStatus(types.PhaseCompiling, 29, "检查 LaTeX 宏包...")
+		if a.autoInstallPackages() {
+			logger.Debug("missing packages are installed while compiling, skipping package check")
+		} else if report := compiler.FindMissingPackages(sourceInfo.ExtractDir); report != nil {
+			err := missingPackagesError(report)
+			logger.Error("LaTeX packages missing", err)
+			a.safeEmit(EventMissingPackages, report)
+			a.updateStatusError(err.Error())
+			if arxivID != "" {
+				a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, err.Error(), "", "")
+				a.recordError(arxivID, title, input, errors.StageOriginalCompile, err.Error())
+			}
+			return nil, err
+		}
+
+		// Without a Chinese font the translation would compile to pages of empty boxes
+		if a.targetLanguage().IsChinese() {
+			if check := compiler.CheckCJKFonts(); !check.Usable() {
+				err := missingFontsError()
+				logger.Error("no Chinese font installed", err)
+				a.updateStatusError(err.Error())
+				if arxivID != "" {
+					a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, err.Error(), "", "")
+					a.recordError(arxivID, title, input, errors.StageOriginalCompile, err.Error())
+				}
+				return nil, err
+			}
+		}
+
+		a.updateStatus(types.PhaseCompiling, 30, "编译原始文档...")
+		logger.Info("compiling original document", logger.String("texPath", mainTexPath))
+		originalOutputDir := filepath.Join(sourceInfo.ExtractDir, "output_original")
+		originalResult, err = a.compileOriginalWithFallback(ctx, sourceInfo, mainTexCandidates, originalOutputDir)
+		if err != nil && ctx.Err() != nil {
+			return nil, a.finishCancelled(arxivID, title, input, sourceInfo, "")
+		}
+		if sourceInfo.MainTexFile != mainTexFile {
+			// The original compile fell back to another candidate; continue with it
+			mainTexFile = sourceInfo.MainTexFile
+			mainTexPath = filepath.Join(sourceInfo.ExtractDir, mainTexFile)
+			if arxivID != "" {
+				if mainTexContent, readErr := os.ReadFile(mainTexPath); readErr == nil {
+					if fallbackTitle := results.ExtractTitleFromTeX(string(mainTexContent)); fallbackTitle != "" {
+						title = fallbackTitle
+					}
+				}
+			}
+		}
+		if err != nil {
+			logger.Error("original document compilation failed", err)
+			a.emitCompileErrors(errors.StageOriginalCompile, originalResult)
+			a.updateStatusError(fmt.Sprintf("原始文档编译失败: %v", err))
+			// Save intermediate result on error
+			if arxivID != "" {
+				a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, err.Error(), "", "")
+				// 记录原始编译错误
+				a.recordError(arxivID, title, input, errors.StageOriginalCompile, err.Error())
+			}
+			return nil, err
+		}
+		if !originalResult.Success {
+			err := types.NewAppErrorWithDetails(types.ErrCompile, "原始文档编译失败", originalResult.ErrorMsg, nil)
+			logger.Error("original document compilation failed", err, logger.String("errorMsg", originalResult.ErrorMsg))
+			a.emitCompileErrors(errors.StageOriginalCompile, originalResult)
+			a.updateStatusError(err.Error())
+			// Save intermediate result on error
+			if arxivID != "" {
+				a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, originalResult.ErrorMsg, "", "")
+				// 记录原始编译错误
+				a.recordError(arxivID, title, input, errors.StageOriginalCompile, originalResult.ErrorMsg)
+			}
+			return nil, err
+		}
+		logger.Info("original document compiled successfully", logger.String("pdfPath", originalResult.PDFPath), logger.Int("passes", originalResult.Passes))
+
+		// Save intermediate result after original compilation
 		if arxivID != "" {
-			a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, originalResult.ErrorMsg, "", "")
-			// 记录原始编译错误
-			a.recordError(arxivID, title, input, errors.StageOriginalCompile, originalResult.ErrorMsg)
+			a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusOriginalCompiled, "", originalResult.PDFPath, "")
 		}
-		return nil, err
-	}
-	logger.Info("original document compiled successfully", logger.String("pdfPath", originalResult.PDFPath))
 
-	// Save intermediate result after original compilation
-	if arxivID != "" {
-		a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusOriginalCompiled, "", originalResult.PDFPath, "")
+		// Emit event to frontend to display original PDF
+		a.safeEmit(EventOriginalPDFReady, originalResult.PDFPath)
 	}
 
-	// Emit event to frontend to display original PDF
-	a.safeEmit(EventOriginalPDFReady, originalResult.PDFPath)
-
 	// Check for cancellation
 	if ctx.Err() != nil {
-		logger.Warn("processing cancelled")
-		a.updateStatusError("已取消")
-		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
+		return nil, a.finishCancelled(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult))
 	}
 
 	// Step 5: Read and translate tex content
@@ -1048,12 +2153,18 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 	a.updateStatus(types.PhaseTranslating, 42, "开始翻译文档...")
 
 	// Translate main file and all input files
-	translatedFiles, totalTokens, err := a.translateAllTexFiles(mainTexPath, sourceInfo.ExtractDir, func(current, total int, message string) {
+	translatedFiles, totalTokens, err := a.translateAllTexFiles(ctx, mainTexPath, sourceInfo.ExtractDir, func(p fileProgress) {
 		// Calculate progress: translation phase is from 42% to 58%
 		progressRange := 16 // 58 - 42
-		progress := 42 + (current * progressRange / total)
-		a.updateStatus(types.PhaseTranslating, progress, message)
+		progress := 42 + (p.Percent * progressRange / 100)
+		a.updateTranslationStatus(progress, p.Message, p.Detail)
 	})
+	if err != nil && ctx.Err() != nil {
+		return nil, a.finishCancelled(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult))
+	}
+	if translator.IsBudgetExceeded(err) {
+		return nil, a.finishBudgetExceeded(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult), err)
+	}
 	if err != nil {
 		logger.Error("translation failed", err)
 		a.updateStatusError(fmt.Sprintf("下载失败: %v", err))
@@ -1066,6 +2177,10 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		return nil, err
 	}
 	logger.Info("translation completed", logger.Int("tokensUsed", totalTokens), logger.Int("filesTranslated", len(translatedFiles)))
+	if a.reuseStats != nil {
+		a.updateStatus(types.PhaseTranslating, 58, fmt.Sprintf("复用旧版本译文 %.1f%% (%d/%d 段)",
+			a.reuseStats.Percent(), a.reuseStats.ReusedParagraphs, a.reuseStats.TotalParagraphs))
+	}
 
 	// Save intermediate result after translation
 	if arxivID != "" {
@@ -1076,13 +2191,16 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 	// mainTexFile is the relative path (e.g., "deep-representation-learning-book-main\book-main.tex")
 	// which matches the keys in translatedFiles
 	mainFileName := mainTexFile
+
+	// Step 5.5: Post-process all translated files with the canonical pipeline (the same
+	// passes as the batch and test tools, so the same translation gives the same tex)
+	postOptions := postprocess.Options{Variant: a.chineseVariant(), Language: a.targetLanguage(), QuickMode: quick}
+	a.postProcessTranslations(translatedFiles, sourceInfo.ExtractDir, mainFileName, postOptions)
 	translatedContent := translatedFiles[mainFileName]
 
 	// Check for cancellation
 	if ctx.Err() != nil {
-		logger.Warn("processing cancelled")
-		a.updateStatusError("已取消")
-		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
+		return nil, a.finishCancelled(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult))
 	}
 
 	// Step 6: Validate and fix syntax errors (only for main file)
@@ -1097,7 +2215,10 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		logger.Int("maxSyntaxFixSize", maxSyntaxFixSize),
 		logger.Int("contentSize", len(translatedContent)))
 
-	if len(translatedContent) <= maxSyntaxFixSize {
+	if quick {
+		logger.Info("skipping syntax validation in quick mode")
+		a.updateStatus(types.PhaseValidating, 65, "快速模式跳过语法验证...")
+	} else if len(translatedContent) <= maxSyntaxFixSize {
 		a.updateStatus(types.PhaseValidating, 60, "验证翻译后的语法...")
 		logger.Debug("validating translated content")
 		validationResult, err := a.validator.Validate(translatedContent)
@@ -1139,17 +2260,11 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 						logger.Int("originalLines", originalLineCount),
 						logger.Int("fixedLines", fixedLineCount))
 					
-					// IMPORTANT: Re-apply reference-based fixes after LLM syntax fix
+					// IMPORTANT: Run the pipeline again after the LLM syntax fix
 					// The LLM may have re-introduced wrongly commented environments
-					// Read original content for reference-based fixes
-					originalMainPath := filepath.Join(sourceInfo.ExtractDir, mainFileName)
-					if originalContent, readErr := os.ReadFile(originalMainPath); readErr == nil {
-						translatedContent = translator.ApplyReferenceBasedFixes(translatedContent, string(originalContent))
-						logger.Info("re-applied reference-based fixes after syntax fix")
-					}
-					
-					// Update the main file in translatedFiles
+					translatedContent = a.postProcessFile(mainFileName, translatedContent, sourceInfo.ExtractDir, mainFileName, postOptions)
 					translatedFiles[mainFileName] = translatedContent
+					logger.Info("re-ran post-processing after syntax fix")
 				}
 			}
 		}
@@ -1163,90 +2278,29 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 
 	// Check for cancellation
 	if ctx.Err() != nil {
-		logger.Warn("processing cancelled")
-		a.updateStatusError("已取消")
-		return nil, types.NewAppError(types.ErrInternal, "已取消", ctx.Err())
+		return nil, a.finishCancelled(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult))
 	}
 
 	// Step 7: Save all translated tex files
 	a.updateStatus(types.PhaseValidating, 70, "保存翻译文件...")
 
-	// Ensure ctex package is included for Chinese support in main file
-	// Get the latest content from translatedFiles (it may have been updated by syntax fix)
-	translatedContent = translatedFiles[mainFileName]
-	translatedContent = ensureCtexPackage(translatedContent)
-	translatedFiles[mainFileName] = translatedContent
-
 	logger.Info("saving translated files",
 		logger.Int("fileCount", len(translatedFiles)),
 		logger.String("mainFileName", mainFileName))
 
-	// Save all translated files, applying QuickFixWithReference to each
+	// Save all translated files (already post-processed); the originals and the saved
+	// files are compared in the quality report
+	originals := make(map[string]string, len(translatedFiles))
+	savedPaths := make(map[string]string, len(translatedFiles))
 	for relPath, content := range translatedFiles {
-		// Read original content for reference-based fixes
+		// Read the original for the backup of input files
 		originalPath := filepath.Join(sourceInfo.ExtractDir, relPath)
 		originalContent, err := os.ReadFile(originalPath)
 		var originalStr string
 		if err == nil {
 			originalStr = string(originalContent)
-		} else {
-			logger.Debug("could not read original file for reference fix",
-				logger.String("relPath", relPath),
-				logger.String("error", err.Error()))
-		}
-
-		// Apply QuickFixWithReference to fix common translation issues
-		// This includes fixing wrongly commented environments like \begin{abstract}
-		fixedContent, wasFixed := compiler.QuickFixWithReference(content, originalStr)
-		if wasFixed {
-			logger.Info("applied QuickFixWithReference to translated file",
-				logger.String("relPath", relPath))
-			content = fixedContent
-			translatedFiles[relPath] = content
 		}
-
-		// Check if content has thebibliography before fix
-		hasBibBefore := strings.Contains(content, `\begin{thebibliography}`)
-		logger.Debug("before fixDuplicateThebibliographyInPreamble",
-			logger.String("relPath", relPath),
-			logger.Bool("hasThebibliography", hasBibBefore))
-
-		// Fix duplicate thebibliography in preamble (before \begin{document})
-		// This can happen when LLM incorrectly inserts .bbl content during translation
-		content = fixDuplicateThebibliographyInPreamble(content)
-		translatedFiles[relPath] = content
-
-		// Check if content has thebibliography after fix
-		hasBibAfter := strings.Contains(content, `\begin{thebibliography}`)
-		logger.Debug("after fixDuplicateThebibliographyInPreamble",
-			logger.String("relPath", relPath),
-			logger.Bool("hasThebibliography", hasBibAfter))
-
-		// IMPORTANT: Fix incomplete tabular column specs AFTER all other fixes
-		// This must be done last because QuickFix might remove the closing braces we add
-		content = translator.FixIncompleteTabularColumnSpec(content)
-		translatedFiles[relPath] = content
-
-		// CRITICAL: Fix split comment lines in preamble AFTER all other fixes
-		// This handles cases where LLM splits "% comment" into two lines:
-		//   Line N:   %
-		//   Line N+1: comment text (without %)
-		// This must be done last because other fixes might re-introduce the problem
-		content = fixSplitCommentLinesInPreamble(content)
-		translatedFiles[relPath] = content
-
-		// CRITICAL: Fix merged comment lines in preamble
-		// This handles cases where LLM merges comment text with the next line:
-		//   Original: "% comment text\n\usepackage{pkg}"
-		//   Broken:   "% comment text\usepackage{pkg}" (on same line)
-		// This must be done after fixSplitCommentLinesInPreamble
-		content = fixMergedCommentLinesInPreamble(content, originalStr)
-		translatedFiles[relPath] = content
-
-		// Add Chinese font support for LuaLaTeX compilation
-		// This is necessary because translated documents contain Chinese characters
-		content = addChineseFontSupport(content)
-		translatedFiles[relPath] = content
+		originals[relPath] = originalStr
 
 		var savePath string
 		if relPath == mainFileName {
@@ -1262,6 +2316,17 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		} else {
 			// Input files are saved in place (overwrite original)
 			savePath = filepath.Join(sourceInfo.ExtractDir, relPath)
+			// Keep a copy of the original so a later version can reuse this translation
+			if originalStr != "" {
+				backupPath := filepath.Join(sourceInfo.ExtractDir, results.OriginalSourcesDirName, relPath+results.OriginalSourceSuffix)
+				if _, statErr := os.Stat(backupPath); os.IsNotExist(statErr) {
+					if mkErr := os.MkdirAll(filepath.Dir(backupPath), 0755); mkErr == nil {
+						if writeErr := os.WriteFile(backupPath, []byte(originalStr), 0644); writeErr != nil {
+							logger.Warn("failed to back up original input file", logger.Err(writeErr))
+						}
+					}
+				}
+			}
 		}
 
 		logger.Info("saving translated file",
@@ -1284,6 +2349,7 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 			a.updateStatusError(fmt.Sprintf("保存翻译文件失败: %v", err))
 			return nil, types.NewAppError(types.ErrInternal, "保存翻译文件失败", err)
 		}
+		savedPaths[relPath] = savePath
 	}
 
 	// Update translatedContent with the fixed version
@@ -1301,17 +2367,35 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 	}
 	translatedOutputDir := filepath.Join(sourceInfo.ExtractDir, "output_translated")
 
+	// Without compiling, the job ends with the translated tex and its HTML export
+	if noCompile {
+		return a.finishWithoutCompile(sourceInfo, translatedTexPath, translatedContent, arxivID, title, sourceID, sourceArchive, jobStart, noCompilePDF)
+	}
+
+	// Chinese references and English references sort and render correctly together
+	if a.tagBibliographyLanguages(translatedTexPath) {
+		if tagged, err := os.ReadFile(translatedTexPath); err == nil {
+			translatedContent = string(tagged)
+		}
+	}
+
 	// Step 8: Compile translated document with hierarchical auto-fix
 	// Strategy (3-level hierarchical fix):
 	// Level 1: Rule-based fixes (fast, reliable for common issues)
 	// Level 2: Simple LLM fixes (moderate cost, handles most errors)
 	// Level 3: Agent-based fixes (higher cost, handles complex errors)
 	var translatedResult *types.CompileResult
+	var fixStats *qa.FixStats
 
-	// First attempt: compile without fixes
+	// First attempt: compile without fixes, with a preview of the first chapter meanwhile
 	a.updateStatus(types.PhaseCompiling, 75, "编译中文文档...")
+	stopPreview := a.startTranslatedPreview(ctx, sourceInfo.ExtractDir, translatedTexPath)
+	defer stopPreview()
 	logger.Info("compiling translated document", logger.String("texPath", translatedTexPath))
-	translatedResult, err = a.compiler.CompileWithXeLaTeX(translatedTexPath, translatedOutputDir)
+	translatedResult, err = a.compileTranslated(ctx, translatedTexPath, translatedOutputDir)
+	if ctx.Err() != nil {
+		return nil, a.finishCancelled(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult))
+	}
 
 	// If compilation failed, use hierarchical fix strategy
 	if err != nil || !translatedResult.Success {
@@ -1323,6 +2407,20 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		baseURL := a.config.GetBaseURL()
 		model := a.config.GetModel()
 		fixer := compiler.NewLaTeXFixerWithAgent(apiKey, baseURL, model, model, true)
+		fixer.SetProvider(a.config.GetProvider())
+		fixer.SetUsageMeter(a.usage)
+		if a.translator != nil {
+			fixer.SetRepairer(a.translator)
+		}
+		if quick {
+			fixer.SetMaxFixLevel(compiler.FixLevelRule)
+		}
+		if originalResult != nil && originalResult.Success {
+			fixer.SetOriginalLog(originalResult.Log)
+		}
+		fixCtx, endFixLoop := a.beginFixLoop(ctx)
+		fixer.SetContext(fixCtx)
+		a.enableFixReview(fixCtx, fixer)
 
 		// Construct translated tex file path (add "translated_" prefix to filename only)
 		mainTexDir := filepath.Dir(mainTexFile)
@@ -1356,23 +2454,34 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 				a.updateStatus(types.PhaseValidating, progress, message)
 			},
 		)
+		endFixLoop()
+		fixStats = qa.NewFixStats(fixResult)
 
 		if fixErr != nil {
 			logger.Error("hierarchical fix process failed", fixErr)
 		}
 
+		if ctx.Err() != nil {
+			return nil, a.finishCancelled(arxivID, title, input, sourceInfo, compiledPDFPath(originalResult))
+		}
+		if fixResult != nil && fixResult.Aborted {
+			return nil, a.finishWithManualFix(arxivID, title, input, sourceInfo, originalResult.PDFPath, translatedTexPath, fixResult.LastCompileLog)
+		}
+
 		if fixResult != nil && fixResult.Success {
 			logger.Info("hierarchical fix succeeded",
 				logger.Int("totalIterations", fixResult.TotalIterations),
 				logger.Int("ruleAttempts", fixResult.RuleFixAttempts),
 				logger.Int("llmAttempts", fixResult.LLMFixAttempts),
-				logger.Int("agentAttempts", fixResult.AgentFixAttempts))
+				logger.Int("agentAttempts", fixResult.AgentFixAttempts),
+				logger.String("history", strings.Join(fixResult.History, ", ")))
 
 			// Compile one more time to get the final result
-			translatedResult, err = a.compiler.CompileWithXeLaTeX(translatedTexPath, translatedOutputDir)
+			translatedResult, err = a.compileTranslated(ctx, translatedTexPath, translatedOutputDir)
 		} else {
 			logger.Warn("hierarchical fix did not succeed",
-				logger.String("description", fixResult.Description))
+				logger.String("description", fixResult.Description),
+				logger.String("history", strings.Join(fixResult.History, ", ")))
 		}
 	}
 
@@ -1384,6 +2493,10 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		}
 		err := types.NewAppErrorWithDetails(types.ErrCompile, "中文文档编译失败", errMsg, nil)
 		logger.Error("translated document compilation failed after all fix attempts", err)
+		if originalResult != nil && originalResult.Success {
+			a.recordLogDiff(originalResult.Log, translatedResult)
+		}
+		a.emitCompileErrors(errors.StageTranslatedCompile, translatedResult)
 		a.updateStatusError(err.Error())
 		// Save intermediate result on compilation error - still save the source for later retry
 		if arxivID != "" {
@@ -1394,24 +2507,59 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		return nil, err
 	}
 
-	logger.Info("translated document compiled successfully", logger.String("pdfPath", translatedResult.PDFPath))
+	logger.Info("translated document compiled successfully", logger.String("pdfPath", translatedResult.PDFPath), logger.Int("passes", translatedResult.Passes))
+	if translatedResult.IndexMissing {
+		a.addWarning("文档包含索引（\\printindex），但索引没有生成（makeindex 未安装或运行失败），译文 PDF 中没有索引")
+	}
 	// Emit event to frontend to display translated PDF
+	stopPreview()
 	a.safeEmit(EventTranslatedPDFReady, translatedResult.PDFPath)
 
-	// Step 9: Generate bilingual PDF
-	a.updateStatus(types.PhaseCompiling, 95, "生成双语对照 PDF...")
+	// Step 9: Generate bilingual PDF (skipped in quick mode)
 	bilingualPDFPath := ""
-	bilingualOutputPath := filepath.Join(sourceInfo.ExtractDir, "bilingual_"+sourceID+".pdf")
-	generator := pdf.NewPDFGenerator(sourceInfo.ExtractDir)
-	if err := generator.GenerateSideBySidePDF(originalResult.PDFPath, translatedResult.PDFPath, bilingualOutputPath); err != nil {
-		logger.Warn("failed to generate bilingual PDF", logger.Err(err))
-		// 双语 PDF 生成失败不影响主流程，但记录错误
+	if quick {
+		logger.Info("skipping bilingual PDF in quick mode")
+	} else {
+		a.updateStatus(types.PhaseCompiling, 95, "生成双语对照 PDF...")
+		bilingualOutputPath := filepath.Join(sourceInfo.ExtractDir, "bilingual_"+sourceID+".pdf")
+		generator := pdf.NewPDFGenerator(sourceInfo.ExtractDir)
+		// DownloadBilingualPDF hands out this file, so it is aligned the same way
+		generator.AlignBySections = true
+		if err := generateBilingualPDF(generator, a.bilingualPDFLayout(), originalResult.PDFPath, translatedResult.PDFPath, bilingualOutputPath); err != nil {
+			logger.Warn("failed to generate bilingual PDF", logger.Err(err))
+			// 双语 PDF 生成失败不影响主流程，但记录错误
+			if arxivID != "" {
+				a.recordError(arxivID, title, input, errors.StagePDFGeneration, err.Error())
+			}
+		} else {
+			bilingualPDFPath = bilingualOutputPath
+			logger.Info("bilingual PDF generated", logger.String("path", bilingualPDFPath))
+		}
+	}
+
+	// Step 9.1: Check that the produced PDFs embed all fonts (before embedding provenance,
+	// since strict mode may rewrite the files)
+	a.updateStatus(types.PhaseCompiling, 96, "检查字体嵌入...")
+	fontAudits, fontErr := a.auditOutputFonts(translatedResult.PDFPath, bilingualPDFPath)
+	if fontErr != nil {
+		logger.Error("produced PDFs do not embed all fonts", fontErr)
+		a.updateStatusError(fontErr.Error())
 		if arxivID != "" {
-			a.recordError(arxivID, title, input, errors.StagePDFGeneration, err.Error())
+			a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusError, fontErr.Error(), originalResult.PDFPath, translatedResult.PDFPath)
+			a.recordError(arxivID, title, input, errors.StagePDFGeneration, fontErr.Error())
+		}
+		return nil, fontErr
+	}
+
+	// Step 9.2: Embed provenance into the produced PDFs
+	provenance := a.buildProvenance(sourceArchive, jobStart)
+	for _, producedPDF := range []string{translatedResult.PDFPath, bilingualPDFPath} {
+		if producedPDF == "" {
+			continue
+		}
+		if err := pdf.EmbedProvenance(producedPDF, provenance); err != nil {
+			logger.Warn("failed to embed provenance", logger.String("pdfPath", producedPDF), logger.Err(err))
 		}
-	} else {
-		bilingualPDFPath = bilingualOutputPath
-		logger.Info("bilingual PDF generated", logger.String("path", bilingualPDFPath))
 	}
 
 	// Step 9.5: Check page count difference (suspicious error detection)
@@ -1424,11 +2572,17 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 			logger.Int("originalPages", pageCountResult.OriginalPages),
 			logger.Int("translatedPages", pageCountResult.TranslatedPages),
 			logger.Float64("diffPercent", pageCountResult.DiffPercent*100))
-		if arxivID != "" {
+		if quick {
+			// Quick mode skips the fixes that usually explain a page difference; only note it
+			a.addWarning("快速模式提示: " + errorMsg)
+		} else if arxivID != "" {
 			a.recordError(arxivID, title, input, errors.StagePageCountMismatch, errorMsg)
 		}
 	}
 
+	// Step 9.6: Write the quality report next to the translated PDF
+	qualityReportPath := a.writeQualityReport(sourceID, originals, savedPaths, fixStats, pageCountResult, translatedResult.PDFPath)
+
 	// Step 10: Complete
 	a.updateStatus(types.PhaseComplete, 100, "翻译完成")
 	logger.Info("source processing completed successfully",
@@ -1442,11 +2596,21 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 		BilingualPDFPath:  bilingualPDFPath,
 		SourceInfo:        sourceInfo,
 		SourceID:          sourceID,
+		Provenance:        provenance,
+		ReuseStats:        a.reuseStats,
+		FontAudits:        fontAudits,
+		QuickMode:         quick,
+		QualityReportPath: qualityReportPath,
 	}
 
 	// Store result for download
 	a.lastResult = result
 
+	// Remember a context window that worked for a model we know nothing about
+	if a.config != nil {
+		a.config.RecordWorkingContextWindow(a.config.GetModel(), a.config.GetContextWindow())
+	}
+
 	// Save to permanent storage if this is an arXiv paper (arxivID and title already extracted earlier)
 	if arxivID != "" {
 		if err := a.saveResultToPermanentStorage(result, arxivID, title); err != nil {
@@ -1463,296 +2627,541 @@ func (a *App) ProcessSource(input string) (*types.ProcessResult, error) {
 	return result, nil
 }
 
-// extractCompileErrors extracts error information from LaTeX compilation log
-func extractCompileErrors(log string) []types.SyntaxError {
-	var errors []types.SyntaxError
-	lines := strings.Split(log, "\n")
-
-	// Common LaTeX error patterns - more specific patterns first
-	errorPatterns := []struct {
-		pattern *regexp.Regexp
-		errType string
-	}{
-		{regexp.MustCompile(`Undefined control sequence`), "undefined_command"},
-		{regexp.MustCompile(`Missing \$ inserted`), "math_mode"},
-		{regexp.MustCompile(`Missing \{ inserted`), "brace"},
-		{regexp.MustCompile(`Missing \} inserted`), "brace"},
-		{regexp.MustCompile(`Extra \}`), "brace"},
-		{regexp.MustCompile(`Runaway argument`), "runaway"},
-		{regexp.MustCompile(`Package .+ Error`), "package_error"},
-		{regexp.MustCompile(`LaTeX Error`), "latex_error"},
-		{regexp.MustCompile(`^! `), "latex_error"},
-		{regexp.MustCompile(`^l\.(\d+)`), "line_error"},
-	}
-
-	for i, line := range lines {
-		for _, ep := range errorPatterns {
-			if ep.pattern.MatchString(line) {
-				// Try to extract line number
-				lineNum := i + 1
-				linePattern := regexp.MustCompile(`l\.(\d+)`)
-				if matches := linePattern.FindStringSubmatch(line); len(matches) > 1 {
-					fmt.Sscanf(matches[1], "%d", &lineNum)
-				}
-
-				errors = append(errors, types.SyntaxError{
-					Line:    lineNum,
-					Column:  1,
-					Message: strings.TrimSpace(line),
-					Type:    ep.errType,
-				})
-				break
-			}
-		}
+// uncompiledNotice heads the HTML export of a translation that was not compiled
+const uncompiledNotice = "未编译：本页由译文 LaTeX 源码直接转换，公式、图片和排版仅供阅读参考。安装 LaTeX 后可重新翻译生成 PDF。"
+
+// finishWithoutCompile ends a no-compile job: it exports the translated main file as HTML,
+// optionally translates the PDF compiled by arXiv, and saves the result to the library as
+// uncompiled. The translated tex files are already saved next to the originals.
+func (a *App) finishWithoutCompile(sourceInfo *types.SourceInfo, translatedTexPath, translatedContent, arxivID, title, sourceID, sourceArchive string, jobStart time.Time, translateArxivPDF bool) (*types.ProcessResult, error) {
+	// Export the translated document as HTML
+	a.updateStatus(types.PhaseValidating, 80, "生成 HTML 预览...")
+	texDir := filepath.Dir(translatedTexPath)
+	page := htmlexport.RenderHTML(translatedContent, htmlexport.Options{
+		Title:  title,
+		Notice: uncompiledNotice,
+		ReadInput: func(name string) (string, bool) {
+			content, err := os.ReadFile(filepath.Join(texDir, filepath.FromSlash(name)))
+			return string(content), err == nil
+		},
+	})
+	htmlPath := strings.TrimSuffix(translatedTexPath, filepath.Ext(translatedTexPath)) + ".html"
+	if err := os.WriteFile(htmlPath, []byte(page), 0644); err != nil {
+		logger.Error("failed to write HTML export", err, logger.String("path", htmlPath))
+		a.updateStatusError(fmt.Sprintf("保存 HTML 预览失败: %v", err))
+		return nil, types.NewAppError(types.ErrInternal, "保存 HTML 预览失败", err)
 	}
+	logger.Info("translation exported as HTML", logger.String("htmlPath", htmlPath))
 
-	return errors
-}
+	result := &types.ProcessResult{
+		SourceInfo:         sourceInfo,
+		SourceID:           sourceID,
+		Provenance:         a.buildProvenance(sourceArchive, jobStart),
+		ReuseStats:         a.reuseStats,
+		Uncompiled:         true,
+		TranslatedHTMLPath: htmlPath,
+		TranslatedTexPath:  translatedTexPath,
+	}
 
-// extractErrorFile extracts the file name where the error occurred from the compilation log
-func extractErrorFile(log string, baseDir string) string {
-	lines := strings.Split(log, "\n")
+	// Optionally translate the PDF compiled by arXiv for a visual version; failures only warn
+	if translateArxivPDF {
+		if arxivID == "" {
+			a.addWarning("未编译模式: 只有 arXiv 论文可以翻译 arXiv 提供的 PDF")
+		} else if err := a.translateArxivPDF(arxivID, sourceInfo.ExtractDir, result); err != nil {
+			logger.Warn("failed to translate arXiv PDF", logger.String("arxivID", arxivID), logger.Err(err))
+			a.addWarning(fmt.Sprintf("未编译模式: 翻译 arXiv PDF 失败: %v", err))
+		}
+	}
 
-	// Pattern to match file being processed: (./path/to/file.tex or ./file.tex
-	filePattern := regexp.MustCompile(`\(\.?/?([^\s\(\)]+\.tex)`)
+	a.updateStatus(types.PhaseComplete, 100, "翻译完成（未编译）")
+	logger.Info("source processing completed without compiling",
+		logger.String("translatedTex", translatedTexPath),
+		logger.String("translatedHTML", htmlPath),
+		logger.String("translatedPDF", result.TranslatedPDFPath))
 
-	// Track the most recently opened file before an error
-	var currentFile string
+	a.lastResult = result
+	if a.config != nil {
+		a.config.RecordWorkingContextWindow(a.config.GetModel(), a.config.GetContextWindow())
+	}
 
-	for _, line := range lines {
-		// Check for file opening
-		matches := filePattern.FindAllStringSubmatch(line, -1)
-		for _, match := range matches {
-			if len(match) > 1 {
-				currentFile = match[1]
-				// Normalize path separators
-				currentFile = filepath.Clean(currentFile)
-			}
+	if arxivID != "" {
+		if err := a.saveResultToPermanentStorage(result, arxivID, title); err != nil {
+			logger.Warn("failed to save result to permanent storage", logger.Err(err))
 		}
-
-		// Check for error indicators
-		if strings.HasPrefix(line, "!") || strings.Contains(line, "Error") {
-			if currentFile != "" {
-				return currentFile
+		if a.errorMgr != nil {
+			if err := a.errorMgr.RemoveError(arxivID); err != nil {
+				logger.Warn("failed to remove error record after successful translation", logger.Err(err))
 			}
 		}
 	}
 
-	return ""
+	return result, nil
 }
 
-// fixCompileErrors uses LLM to fix compilation errors
-// For large files, it only sends the relevant code sections around the errors
-func (a *App) fixCompileErrors(content string, errors []types.SyntaxError, compileLog string) (string, error) {
-	if a.translator == nil {
-		return "", types.NewAppError(types.ErrConfig, "translator not initialized", nil)
+// translateArxivPDF downloads the PDF compiled by arXiv and runs it through the PDF
+// translator, filling in the original and translated PDF paths of the result
+func (a *App) translateArxivPDF(arxivID, destDir string, result *types.ProcessResult) error {
+	if a.pdfTranslator == nil {
+		return types.NewAppError(types.ErrInternal, "PDF 翻译器未初始化", nil)
 	}
 
-	// Calculate max content size based on context window
-	contextWindow := a.config.GetContextWindow()
-	maxContentSize := contextWindow * 3 / 2 // Same calculation as syntax validation
+	a.updateStatus(types.PhaseDownloading, 85, "下载 arXiv PDF...")
+	pdfPath, err := a.downloader.DownloadArxivPDF(arxivID, filepath.Join(destDir, "output_original"))
+	if err != nil {
+		return err
+	}
+	result.OriginalPDFPath = pdfPath
+	a.safeEmit(EventOriginalPDFReady, pdfPath)
 
-	// Build error descriptions
-	var errorDescriptions []string
-	for _, err := range errors {
-		errorDescriptions = append(errorDescriptions, fmt.Sprintf("- Line %d: %s (%s)", err.Line, err.Message, err.Type))
+	a.updateStatus(types.PhaseTranslating, 90, "翻译 arXiv PDF...")
+	if _, err := a.pdfTranslator.LoadPDF(pdfPath); err != nil {
+		return err
 	}
+	translation, err := a.pdfTranslator.TranslatePDF()
+	if err != nil {
+		return err
+	}
+	result.TranslatedPDFPath = translation.TranslatedPDFPath
+	a.safeEmit(EventTranslatedPDFReady, translation.TranslatedPDFPath)
+	return nil
+}
 
-	// Truncate log if too long
-	logExcerpt := compileLog
-	if len(logExcerpt) > 2000 {
-		logExcerpt = logExcerpt[len(logExcerpt)-2000:]
+// OpenTranslatedHTML opens the HTML export of an uncompiled library entry in the browser
+func (a *App) OpenTranslatedHTML(arxivID string) error {
+	if a.results == nil {
+		return types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
 	}
+	info, err := a.results.LoadPaperInfo(arxivID)
+	if err != nil {
+		return types.NewAppError(types.ErrFileNotFound, "论文不存在", err)
+	}
+	return a.OpenHTMLInSystem(info.TranslatedHTML)
+}
 
-	// For large files, extract only the relevant sections around errors
-	contentToFix := content
-	isPartialFix := false
+// OpenHTMLInSystem opens an HTML export with the system's default browser
+func (a *App) OpenHTMLInSystem(htmlPath string) error {
+	if htmlPath == "" {
+		return types.NewAppError(types.ErrInvalidInput, "HTML 路径为空", nil)
+	}
+	if _, err := os.Stat(htmlPath); err != nil {
+		return types.NewAppError(types.ErrFileNotFound, "HTML 文件不存在", err)
+	}
 
-	if len(content) > maxContentSize && len(errors) > 0 {
-		isPartialFix = true
-		contentToFix = extractErrorSections(content, errors, maxContentSize)
-		logger.Info("using partial content for compile fix",
-			logger.Int("originalSize", len(content)),
-			logger.Int("extractedSize", len(contentToFix)),
-			logger.Int("errorCount", len(errors)))
-	}
-
-	var prompt string
-	if isPartialFix {
-		prompt = fmt.Sprintf(`Fix the following LaTeX compilation errors. Only the relevant sections around the errors are shown.
-
-COMPILATION ERRORS:
-%s
-
-COMPILATION LOG (last part):
-%s
-
-IMPORTANT RULES:
-1. Fix ONLY the compilation errors in the sections shown
-2. Output ONLY the corrected sections in the same format (with line markers)
-3. Keep the "=== SECTION: lines X-Y ===" markers in your output
-4. Common fixes include:
-   - Adding missing packages
-   - Fixing undefined control sequences
-   - Fixing unmatched braces or brackets
-   - Fixing math mode issues
-5. Preserve all Chinese text
-
-SECTIONS TO FIX:
-%s`, strings.Join(errorDescriptions, "\n"), logExcerpt, contentToFix)
-	} else {
-		prompt = fmt.Sprintf(`Fix the following LaTeX compilation errors. The document failed to compile with XeLaTeX.
+	logger.Info("opening HTML export in browser", logger.String("path", htmlPath))
+	runtime.BrowserOpenURL(a.ctx, "file:///"+filepath.ToSlash(htmlPath))
+	return nil
+}
 
-COMPILATION ERRORS:
-%s
+// buildProvenance collects the build and job information embedded into produced PDFs.
+func (a *App) buildProvenance(sourceArchive string, jobStart time.Time) *types.Provenance {
+	provenance := &types.Provenance{
+		ToolVersion:  AppVersion,
+		GitCommit:    GitCommit,
+		JobTimestamp: jobStart.Format(time.RFC3339),
+		// The pass list is recorded for every job so reports tell which pipeline produced the tex
+		PostProcessing: strings.Join(postprocess.Describe(), ","),
+	}
+	if a.translator != nil {
+		provenance.Model = a.translator.GetModel()
+	}
+	prompt, _ := a.promptTemplate()
+	provenance.PromptHash = prompt.Hash()
+	provenance.PromptPreset = prompt.Name
+	if path := a.GetGlossaryPath(); path != "" {
+		if glossary, err := translator.LoadGlossary(path); err == nil {
+			provenance.GlossaryHash = glossary.Hash()
+		}
+	}
+	if sourceArchive != "" {
+		if sum, err := results.CalculateFileSHA256(sourceArchive); err == nil {
+			provenance.SourceSHA256 = sum
+		} else {
+			logger.Warn("failed to hash source archive", logger.String("path", sourceArchive), logger.Err(err))
+		}
+	}
+	return provenance
+}
 
-COMPILATION LOG (last part):
-%s
+// GetProvenance reads the provenance information embedded in a produced PDF.
+// If the PDF carries no provenance but lives in a library entry, the entry's
+// provenance.json is returned instead.
+func (a *App) GetProvenance(pdfPath string) (*types.Provenance, error) {
+	provenance, err := pdf.ReadProvenance(pdfPath)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "读取来源信息失败", err)
+	}
+	if provenance != nil {
+		return provenance, nil
+	}
 
-IMPORTANT RULES:
-1. Fix ONLY the compilation errors - do not change anything else
-2. Common fixes include:
-   - Adding missing packages
-   - Fixing undefined control sequences by replacing with valid commands
-   - Fixing unmatched braces or brackets
-   - Fixing math mode issues
-   - Removing or replacing incompatible commands
-3. Preserve all Chinese text and translations
-4. Keep the ctex package for Chinese support
-5. Output ONLY the corrected LaTeX content - no explanations
+	// Fall back to the provenance.json saved next to the PDF in the library
+	paperDir := filepath.Dir(filepath.Clean(pdfPath))
+	if a.results != nil && filepath.Dir(paperDir) == filepath.Clean(a.results.GetBaseDir()) {
+		if saved, loadErr := a.results.LoadProvenance(filepath.Base(paperDir)); loadErr == nil {
+			return saved, nil
+		}
+	}
+	return nil, types.NewAppError(types.ErrFileNotFound, "该 PDF 不包含来源信息", nil)
+}
 
-DOCUMENT TO FIX:
-%s`, strings.Join(errorDescriptions, "\n"), logExcerpt, contentToFix)
+// GetDocumentOutline returns the chapters and sections of a LaTeX file, extracted from the
+// source so the outline is available for navigation before any PDF has been compiled.
+func (a *App) GetDocumentOutline(texPath string) ([]*types.OutlineEntry, error) {
+	content, err := os.ReadFile(texPath)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "无法读取 tex 文件", err)
 	}
+	outline := parser.ExtractOutline(string(content))
+	logger.Debug("document outline extracted",
+		logger.String("file", texPath),
+		logger.Int("entries", len(parser.FlattenOutline(outline))))
+	return outline, nil
+}
+
+// PreviewChunking shows how a paper will be split into chunks for translation, without
+// calling the model. input is anything ProcessSource accepts (arXiv ID, URL, local archive)
+// or an already extracted source directory. The chunks are computed by the same code as
+// the translation itself, so translating the paper afterwards sends exactly these chunks.
+func (a *App) PreviewChunking(input string) (*types.ChunkingPreview, error) {
+	logger.Info("previewing chunking", logger.String("input", input))
 
-	// Use translator to fix (it has the LLM connection)
-	result, err := a.translator.TranslateChunk(prompt)
+	sourceInfo, err := a.fetchPreviewSource(input)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// For partial fixes, merge the fixed sections back into the original content
-	if isPartialFix {
-		return mergeFixedSections(content, result, errors), nil
+	candidates, err := a.findMainTexCandidates(sourceInfo.ExtractDir)
+	if err != nil {
+		return nil, err
 	}
+	mainTexPath := filepath.Join(sourceInfo.ExtractDir, candidates[0].Path)
 
-	return result, nil
-}
+	files, err := collectTranslationFiles(mainTexPath, sourceInfo.ExtractDir)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &types.ChunkingPreview{
+		ExtractDir:  sourceInfo.ExtractDir,
+		MainTexFile: candidates[0].Path,
+	}
+	overrides := a.getFileOverrides()
+	var excluded map[string]bool
+	if !a.ignoreIncludeOnly() {
+		excluded = includeOnlyExclusions(mainTexPath, detectIncludeOnly(mainTexPath))
+	}
+	for _, relPath := range files {
+		fullPath := resolveTranslationFilePath(relPath, mainTexPath, sourceInfo.ExtractDir)
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, types.NewAppError(types.ErrFileNotFound, fmt.Sprintf("读取文件失败: %s", relPath), err)
+		}
 
-// extractErrorSections extracts code sections around error lines
-func extractErrorSections(content string, errors []types.SyntaxError, maxSize int) string {
-	lines := strings.Split(content, "\n")
-	totalLines := len(lines)
+		// Files kept as they are by translateAllTexFiles are listed without chunks
+		decision := decidePaperFile(relPath, fullPath, content, overrides, excluded)
+		file := types.FileChunkPreview{File: relPath, Size: len(content), Decision: &decision}
+		if decision.Decision == types.FileDecisionTranslate {
+			file.Chunks, file.SkippedDataBlobs = translator.PreviewChunks(string(content))
+		} else {
+			file.Skipped = true
+			file.SkipReason = decision.Reason
+		}
 
-	// Collect unique error line numbers
-	errorLines := make(map[int]bool)
-	for _, err := range errors {
-		if err.Line > 0 && err.Line <= totalLines {
-			errorLines[err.Line] = true
+		for _, chunk := range file.Chunks {
+			preview.EstimatedTokens += chunk.EstimatedTokens
 		}
+		preview.TotalChunks += len(file.Chunks)
+		preview.Files = append(preview.Files, file)
+	}
+
+	logger.Info("chunking preview ready",
+		logger.String("mainTexFile", preview.MainTexFile),
+		logger.Int("files", len(preview.Files)),
+		logger.Int("totalChunks", preview.TotalChunks))
+	return preview, nil
+}
+
+// fetchPreviewSource downloads and extracts the source of input for a preview or estimate,
+// with the same preprocessing as ProcessSource. An extracted source directory is used as it is.
+func (a *App) fetchPreviewSource(input string) (*types.SourceInfo, error) {
+	if stat, err := os.Stat(input); err == nil && stat.IsDir() {
+		return &types.SourceInfo{ExtractDir: input, OriginalRef: input}, nil
+	}
+
+	sourceType, err := parser.ParseInput(input)
+	if err != nil {
+		return nil, err
+	}
+	var sourceInfo *types.SourceInfo
+	switch sourceType {
+	case types.SourceTypeURL:
+		sourceInfo, err = a.downloader.DownloadFromURL(input)
+	case types.SourceTypeArxivID:
+		sourceInfo, err = a.downloader.DownloadByID(input)
+	case types.SourceTypeLocalZip:
+		sourceInfo = &types.SourceInfo{ExtractDir: input}
+	default:
+		return nil, types.NewAppError(types.ErrInvalidInput, "不支持的输入类型", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sourceInfo, err = a.downloader.ExtractZip(sourceInfo.ExtractDir); err != nil {
+		return nil, err
+	}
+
+	// Same preprocessing as ProcessSource, so the previewed files match the translated ones
+	if err := compiler.PreprocessTexFiles(sourceInfo.ExtractDir); err != nil {
+		logger.Warn("preprocessing failed", logger.Err(err))
+	}
+	return sourceInfo, nil
+}
+
+// EstimateSource estimates the chunks, tokens and translation time of a paper without
+// calling the model: it downloads and extracts input like PreviewChunking and runs
+// TranslationEngine.EstimateTranslation on every file the translation would send to the
+// model, with the configured model, concurrency, language and quick mode.
+func (a *App) EstimateSource(input string) (*types.SourceEstimate, error) {
+	logger.Info("estimating source", logger.String("input", input))
+
+	sourceInfo, err := a.fetchPreviewSource(input)
+	if err != nil {
+		return nil, err
 	}
+	candidates, err := a.findMainTexCandidates(sourceInfo.ExtractDir)
+	if err != nil {
+		return nil, err
+	}
+	mainTexPath := filepath.Join(sourceInfo.ExtractDir, candidates[0].Path)
+	files, err := collectTranslationFiles(mainTexPath, sourceInfo.ExtractDir)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := a.estimationEngine()
+	estimate := &types.SourceEstimate{
+		Input:       input,
+		ExtractDir:  sourceInfo.ExtractDir,
+		MainTexFile: candidates[0].Path,
+		Concurrency: engine.GetConcurrency(),
+	}
+	overrides := a.getFileOverrides()
+	var excluded map[string]bool
+	if !a.ignoreIncludeOnly() {
+		excluded = includeOnlyExclusions(mainTexPath, detectIncludeOnly(mainTexPath))
+	}
+	for _, relPath := range files {
+		fullPath := resolveTranslationFilePath(relPath, mainTexPath, sourceInfo.ExtractDir)
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, types.NewAppError(types.ErrFileNotFound, fmt.Sprintf("读取文件失败: %s", relPath), err)
+		}
 
-	// If no valid line numbers, return truncated content
-	if len(errorLines) == 0 {
-		if len(content) > maxSize {
-			return content[:maxSize] + "\n... [truncated]"
+		decision := decidePaperFile(relPath, fullPath, content, overrides, excluded)
+		file := types.FileEstimate{File: relPath, Decision: &decision}
+		if decision.Decision == types.FileDecisionTranslate {
+			file.Estimate = engine.EstimateTranslation(string(content))
 		}
-		return content
+
+		// translateAllTexFiles translates the files one after another
+		estimate.Total.Chunks += file.Estimate.Chunks
+		estimate.Total.InputTokens += file.Estimate.InputTokens
+		estimate.Total.OutputTokens += file.Estimate.OutputTokens
+		estimate.Total.EstimatedSeconds += file.Estimate.EstimatedSeconds
+		estimate.Files = append(estimate.Files, file)
 	}
 
-	// Calculate context lines per error based on available space
-	contextPerError := maxSize / (len(errorLines) * 100) // rough estimate: 100 chars per line
-	if contextPerError < 10 {
-		contextPerError = 10
+	logger.Info("source estimated",
+		logger.String("mainTexFile", estimate.MainTexFile),
+		logger.Int("files", len(estimate.Files)),
+		logger.Int("chunks", estimate.Total.Chunks),
+		logger.Int("inputTokens", estimate.Total.InputTokens),
+		logger.Int("outputTokens", estimate.Total.OutputTokens))
+	return estimate, nil
+}
+
+// estimationEngine returns a translator set up like the one of the next job (model,
+// concurrency, language, quick mode chunk size) for estimates; it never calls the model
+func (a *App) estimationEngine() *translator.TranslationEngine {
+	model, concurrency := "", 0
+	if a.config != nil {
+		model = a.config.GetModel()
+		concurrency = a.config.GetConcurrency()
 	}
-	if contextPerError > 50 {
-		contextPerError = 50
+	engine := translator.NewTranslationEngineWithConfig("", model, "", 0, concurrency)
+	engine.SetTargetLanguage(a.targetLanguage())
+	engine.SetChineseVariant(a.chineseVariant(), nil)
+	if a.IsQuickMode() {
+		engine.SetChunkSize(translator.QuickChunkSize)
 	}
+	return engine
+}
 
-	// Extract sections around each error
-	var sections []string
-	processedRanges := make(map[string]bool)
+// maxMainTexAttempts bounds how many main file candidates are tried when the
+// original document fails to compile.
+const maxMainTexAttempts = 3
 
-	for lineNum := range errorLines {
-		startLine := lineNum - contextPerError
-		if startLine < 1 {
-			startLine = 1
+// compileOriginalWithFallback compiles the original document, starting with the
+// top-ranked main file candidate. If that compile fails with a structural error
+// (e.g. "Missing \begin{document}"), the next candidates are tried in order.
+// On success with a fallback candidate, sourceInfo.MainTexFile is switched to it
+// and sourceInfo.MainTexFallbackFrom records the original choice.
+// If every attempt fails, the result of the first candidate is returned.
+func (a *App) compileOriginalWithFallback(ctx context.Context, sourceInfo *types.SourceInfo, candidates []string, outputDir string) (*types.CompileResult, error) {
+	var firstResult *types.CompileResult
+	var firstErr error
+
+	for i, candidate := range candidates {
+		if i >= maxMainTexAttempts {
+			break
 		}
-		endLine := lineNum + contextPerError
-		if endLine > totalLines {
-			endLine = totalLines
+		if i > 0 {
+			logger.Info("retrying original compilation with alternate main file",
+				logger.String("candidate", candidate),
+				logger.Int("attempt", i+1))
+			a.updateStatus(types.PhaseCompiling, 30, fmt.Sprintf("首选主文件编译失败，尝试 %s...", filepath.ToSlash(candidate)))
 		}
 
-		// Skip if this range overlaps with already processed range
-		rangeKey := fmt.Sprintf("%d-%d", startLine, endLine)
-		if processedRanges[rangeKey] {
-			continue
+		texPath := filepath.Join(sourceInfo.ExtractDir, candidate)
+		result, err := a.compiler.CompileContext(ctx, texPath, outputDir)
+		if err == nil && result.Success {
+			if i > 0 {
+				sourceInfo.MainTexFallbackFrom = candidates[0]
+				sourceInfo.MainTexFile = candidate
+				logger.Info("original compilation succeeded with alternate main file",
+					logger.String("mainTexFile", candidate),
+					logger.String("fallbackFrom", candidates[0]))
+				a.updateStatus(types.PhaseCompiling, 35, fmt.Sprintf("主文件自动切换为 %s", filepath.ToSlash(candidate)))
+			}
+			return result, nil
 		}
-		processedRanges[rangeKey] = true
 
-		// Extract the section
-		sectionLines := lines[startLine-1 : endLine]
-		section := fmt.Sprintf("=== SECTION: lines %d-%d ===\n%s\n=== END SECTION ===",
-			startLine, endLine, strings.Join(sectionLines, "\n"))
-		sections = append(sections, section)
+		if i == 0 {
+			firstResult, firstErr = result, err
+		}
+		if ctx.Err() != nil || !compiler.IsStructuralCompileError(result) {
+			break
+		}
+		logger.Warn("main file candidate is not a complete document",
+			logger.String("candidate", candidate))
 	}
 
-	return strings.Join(sections, "\n\n")
+	return firstResult, firstErr
 }
 
-// mergeFixedSections merges fixed sections back into the original content
-func mergeFixedSections(original string, fixed string, errors []types.SyntaxError) string {
-	// Parse the fixed sections
-	sectionPattern := regexp.MustCompile(`=== SECTION: lines (\d+)-(\d+) ===\n([\s\S]*?)\n=== END SECTION ===`)
-	matches := sectionPattern.FindAllStringSubmatch(fixed, -1)
+// emitCompileErrors sends the errors parsed from the log of a failed compilation to the
+// frontend, which lists them with their file and line
+func (a *App) emitCompileErrors(stage errors.ErrorStage, result *types.CompileResult) {
+	if result == nil {
+		return
+	}
+	var compileErrors []types.CompileError
+	for _, e := range result.Errors {
+		if e.Severity == types.SeverityError {
+			compileErrors = append(compileErrors, e)
+		}
+	}
+	if len(compileErrors) == 0 {
+		return
+	}
+	a.safeEmit(EventCompileErrors, types.CompileErrorsReport{Stage: string(stage), Errors: compileErrors})
+}
 
-	if len(matches) == 0 {
-		// No section markers found, return fixed content as-is (might be full content)
-		logger.Warn("no section markers found in fixed content, using as-is")
-		return fixed
+// recordLogDiff keeps the errors and warnings the translated build added to the log of the
+// successful original build, for the CLI to print with the failure
+func (a *App) recordLogDiff(originalLog string, translatedResult *types.CompileResult) {
+	if translatedResult == nil || translatedResult.Log == "" {
+		return
 	}
+	diff := compiler.CompareCompileLogs(originalLog, translatedResult.Log)
+	logger.Info("compared translated build log with original",
+		logger.Int("files", len(diff)),
+		logger.Int("novelErrors", len(compiler.NovelErrors(diff))))
+	a.statusMu.Lock()
+	a.lastLogDiff = diff
+	a.statusMu.Unlock()
+}
+
+// compileLogDiff returns the entries new in the translated build of the last job whose
+// translated compile failed after the original compiled; nil otherwise
+func (a *App) compileLogDiff() []compiler.LogDiffGroup {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	return a.lastLogDiff
+}
 
-	lines := strings.Split(original, "\n")
+// readCompileLog returns the LaTeX log that compiling mainTexFile left in outputDir, empty
+// when there is none
+func readCompileLog(outputDir, mainTexFile string) string {
+	base := strings.TrimSuffix(filepath.Base(mainTexFile), filepath.Ext(mainTexFile))
+	data, err := os.ReadFile(filepath.Join(outputDir, base+".log"))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
 
-	for _, match := range matches {
-		if len(match) < 4 {
-			continue
-		}
+// fixCompileErrors uses LLM to fix compilation errors
+// For large files, it only sends the relevant code sections around the errors
+func (a *App) fixCompileErrors(content string, errors []types.CompileError, compileLog string) (string, error) {
+	if a.translator == nil {
+		return "", types.NewAppError(types.ErrConfig, "translator not initialized", nil)
+	}
 
-		var startLine, endLine int
-		fmt.Sscanf(match[1], "%d", &startLine)
-		fmt.Sscanf(match[2], "%d", &endLine)
-		fixedContent := match[3]
+	// Calculate max content size based on context window
+	contextWindow := a.config.GetContextWindow()
+	maxContentSize := contextWindow * 3 / 2 // Same calculation as syntax validation
 
-		// Validate line numbers
-		if startLine < 1 || endLine > len(lines) || startLine > endLine {
-			logger.Warn("invalid section line numbers",
-				logger.Int("startLine", startLine),
-				logger.Int("endLine", endLine),
-				logger.Int("totalLines", len(lines)))
-			continue
-		}
+	request := translator.RepairRequest{Content: content}
+	var errorLines []int
+	for _, err := range errors {
+		request.Errors = append(request.Errors, err.String())
+		errorLines = append(errorLines, err.Line)
+	}
 
-		// Replace the section
-		fixedLines := strings.Split(fixedContent, "\n")
+	// Truncate log if too long
+	request.LogExcerpt = compileLog
+	if len(request.LogExcerpt) > 2000 {
+		request.LogExcerpt = request.LogExcerpt[len(request.LogExcerpt)-2000:]
+	}
 
-		// Build new lines array
-		newLines := make([]string, 0, len(lines))
-		newLines = append(newLines, lines[:startLine-1]...)
-		newLines = append(newLines, fixedLines...)
-		if endLine < len(lines) {
-			newLines = append(newLines, lines[endLine:]...)
+	// For large files, send only the relevant sections around errors
+	if len(content) > maxContentSize && len(errors) > 0 {
+		// Context lines per error based on available space, ~100 chars per line
+		contextPerError := maxContentSize / (len(errors) * 100)
+		if contextPerError < 10 {
+			contextPerError = 10
+		}
+		if contextPerError > 50 {
+			contextPerError = 50
+		}
+		if sections := translator.RepairSectionsAround(content, errorLines, contextPerError); len(sections) > 0 {
+			request.Content = ""
+			request.Sections = sections
+			logger.Info("using partial content for compile fix",
+				logger.Int("originalSize", len(content)),
+				logger.Int("sections", len(sections)),
+				logger.Int("errorCount", len(errors)))
 		}
-		lines = newLines
 	}
 
-	return strings.Join(lines, "\n")
+	result, err := a.translator.RepairLaTeX(request)
+	if err != nil {
+		return "", err
+	}
+
+	// For partial fixes, merge the fixed sections back into the original content
+	if len(request.Sections) > 0 {
+		return translator.MergeRepairSections(content, result.Sections), nil
+	}
+	return result.Content, nil
 }
 
 // applyRuleBasedFixes applies rule-based fixes for common LaTeX errors caused by translation
-// Returns the fixed content and a boolean indicating if any fixes were applied
+// Returns the fixed content and a boolean indicating if any fixes were applied.
+// Verbatim-like environments are masked, so code listings are neither counted nor changed.
 func applyRuleBasedFixes(content string, compileLog string) (string, bool) {
-	fixed := content
+	fixed, restoreVerbatim := parser.ProtectVerbatim(content)
 	anyFixed := false
 
 	// Rule 1: Fix translated LaTeX commands (Chinese -> English)
@@ -1837,108 +3246,1695 @@ func applyRuleBasedFixes(content string, compileLog string) (string, bool) {
 			logger.Debug("rule-based fix: removed extra closing braces",
 				logger.Int("count", closeBraces-openBraces))
 		}
-	}
+	}
+
+	// Rule 4: Fix missing $ for math mode errors
+	if strings.Contains(compileLog, "Missing $ inserted") {
+		standalonePattern := regexp.MustCompile(`([^$\\])([_^])(\{[^}]+\}|[a-zA-Z0-9])([^$])`)
+		if standalonePattern.MatchString(fixed) {
+			fixed = standalonePattern.ReplaceAllString(fixed, "$1$$2$3$$4")
+			anyFixed = true
+			logger.Debug("rule-based fix: wrapped standalone subscript/superscript in math mode")
+		}
+	}
+
+	// Rule 5: Fix "Lonely \item" errors - \end{itemize}\item should be on separate lines
+	if strings.Contains(compileLog, "Lonely \\item") || strings.Contains(compileLog, "missing list environment") {
+		endItemizeItemPattern := regexp.MustCompile(`(\\end\{itemize\})\s*(\\item\b)`)
+		if endItemizeItemPattern.MatchString(fixed) {
+			fixed = endItemizeItemPattern.ReplaceAllString(fixed, "$1\n$2")
+			anyFixed = true
+			logger.Debug("rule-based fix: separated \\end{itemize} and \\item onto different lines")
+		}
+
+		endEnumerateItemPattern := regexp.MustCompile(`(\\end\{enumerate\})\s*(\\item\b)`)
+		if endEnumerateItemPattern.MatchString(fixed) {
+			fixed = endEnumerateItemPattern.ReplaceAllString(fixed, "$1\n$2")
+			anyFixed = true
+			logger.Debug("rule-based fix: separated \\end{enumerate} and \\item onto different lines")
+		}
+
+		endDescriptionItemPattern := regexp.MustCompile(`(\\end\{description\})\s*(\\item\b)`)
+		if endDescriptionItemPattern.MatchString(fixed) {
+			fixed = endDescriptionItemPattern.ReplaceAllString(fixed, "$1\n$2")
+			anyFixed = true
+			logger.Debug("rule-based fix: separated \\end{description} and \\item onto different lines")
+		}
+	}
+
+	// Rule 6: Fix \end{...}\begin{...} on same line
+	endBeginPattern := regexp.MustCompile(`(\\end\{[^}]+\})\s*(\\begin\{[^}]+\})`)
+	if endBeginPattern.MatchString(fixed) {
+		fixed = endBeginPattern.ReplaceAllString(fixed, "$1\n$2")
+		anyFixed = true
+		logger.Debug("rule-based fix: separated \\end and \\begin onto different lines")
+	}
+
+	// Rule 7: Fix \end{...}\section on same line
+	endSectionPattern := regexp.MustCompile(`(\\end\{[^}]+\})\s*(\\(?:section|subsection|subsubsection|paragraph|chapter)\b)`)
+	if endSectionPattern.MatchString(fixed) {
+		fixed = endSectionPattern.ReplaceAllString(fixed, "$1\n\n$2")
+		anyFixed = true
+		logger.Debug("rule-based fix: separated \\end and section command onto different lines")
+	}
+
+	// Rule 8: Fix \begin{} without matching \end{}
+	beginPattern := regexp.MustCompile(`\\begin\{([^}]+)\}`)
+	endPattern := regexp.MustCompile(`\\end\{([^}]+)\}`)
+	beginMatches := beginPattern.FindAllStringSubmatch(fixed, -1)
+	endMatches := endPattern.FindAllStringSubmatch(fixed, -1)
+
+	beginCounts := make(map[string]int)
+	endCounts := make(map[string]int)
+	for _, m := range beginMatches {
+		beginCounts[m[1]]++
+	}
+	for _, m := range endMatches {
+		endCounts[m[1]]++
+	}
+
+	for env, count := range beginCounts {
+		if endCounts[env] < count {
+			for i := 0; i < count-endCounts[env]; i++ {
+				fixed += fmt.Sprintf("\n\\end{%s}", env)
+				anyFixed = true
+				logger.Debug("rule-based fix: added missing \\end", logger.String("env", env))
+			}
+		}
+	}
+
+	return restoreVerbatim(fixed), anyFixed
+}
+
+// containsChinese checks if a string contains Chinese characters
+func containsChinese(s string) bool {
+	for _, r := range s {
+		if r >= 0x4e00 && r <= 0x9fff {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelProcess cancels the current processing operation.
+// It signals the processing goroutine to stop at the next cancellation check point.
+func (a *App) CancelProcess() error {
+	logger.Info("cancel process requested")
+	if a.cancelFunc != nil {
+		a.cancelFunc()
+		a.updateStatusError("已取消")
+		logger.Info("process cancelled successfully")
+		return nil
+	}
+	logger.Warn("no process to cancel")
+	return types.NewAppError(types.ErrInternal, "没有正在进行的处理", nil)
+}
+
+// SetAllowDuplicateJobs allows starting a job for an input that another process is
+// already translating, instead of refusing it
+func (a *App) SetAllowDuplicateJobs(allow bool) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	a.allowDuplicateJobs = allow
+}
+
+// SetForceTranslate translates the following jobs also when their source already appears
+// to be Chinese, instead of refusing them with ErrSourceAlreadyChinese
+func (a *App) SetForceTranslate(force bool) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	a.forceTranslate = force
+}
+
+// SetQuickMode enables or disables the quick translation mode for the following jobs.
+// Quick mode trades fidelity for speed; GetQuickModeDowngrades lists what it gives up.
+func (a *App) SetQuickMode(enabled bool) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	a.quickMode = enabled
+	logger.Info("quick mode changed", logger.Bool("enabled", enabled))
+}
+
+// IsQuickMode reports whether the following jobs run in quick translation mode
+func (a *App) IsQuickMode() bool {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	return a.quickMode
+}
+
+// SetNoCompileMode enables or disables the no-compile mode for the following jobs. Without
+// compiling, a job needs no TeX distribution: it saves the translated tex files and an
+// HTML export. With translateArxivPDF, the PDF compiled by arXiv is also run through the
+// PDF translator for a visual version.
+func (a *App) SetNoCompileMode(enabled, translateArxivPDF bool) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	a.noCompile = enabled
+	a.noCompilePDF = enabled && translateArxivPDF
+	logger.Info("no-compile mode changed", logger.Bool("enabled", enabled), logger.Bool("translateArxivPDF", a.noCompilePDF))
+}
+
+// IsNoCompileMode reports whether the following jobs run without compiling
+func (a *App) IsNoCompileMode() bool {
+	enabled, _ := a.noCompileSettings()
+	return enabled
+}
+
+// noCompileSettings returns the no-compile mode and whether it translates the arXiv PDF
+func (a *App) noCompileSettings() (bool, bool) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	return a.noCompile, a.noCompilePDF
+}
+
+// SetFileOverrides overrides the translate/copy decision of single files of multi-file
+// projects for the following jobs. Files are given as paths relative to the project
+// directory or as bare file names; decisions.json lists the decisions and the rules.
+func (a *App) SetFileOverrides(translate, copy []string) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	a.fileOverrides = decisions.NewOverrides(translate, copy)
+	logger.Info("file overrides changed", logger.String("overrides", a.fileOverrides.String()))
+}
+
+// getFileOverrides returns the per-file overrides of the following jobs
+func (a *App) getFileOverrides() decisions.Overrides {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	return a.fileOverrides
+}
+
+// quickModeDowngrades lists what quick mode gives up compared to a full translation
+var quickModeDowngrades = []string{
+	fmt.Sprintf("更大的翻译分块（%d 字符，默认 %d），请求更少但上下文更粗", translator.QuickChunkSize, translator.MaxChunkSize),
+	"跳过 LLM 语法验证",
+	"每个文档只编译一遍，交叉引用、参考文献和目录可能显示为 ??",
+	"编译错误只做规则修复，不调用 LLM 和 Agent 修复",
+	"不生成双语对照 PDF",
+	"页数差异检查只作为提示，不记录为错误",
+	"译文首页加上“快速模式”提示",
+}
+
+// GetQuickModeDowngrades returns what quick mode gives up compared to a full translation
+func (a *App) GetQuickModeDowngrades() []string {
+	return append([]string(nil), quickModeDowngrades...)
+}
+
+// GetChineseVariant returns the script of the translated Chinese text: "zh-Hans" or "zh-Hant"
+func (a *App) GetChineseVariant() string {
+	return string(a.chineseVariant())
+}
+
+// SetChineseVariant saves the script of the translated Chinese text ("zh-Hans" or "zh-Hant")
+// and applies it to the following translations
+func (a *App) SetChineseVariant(variant string) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetChineseVariant(variant); err != nil {
+		return err
+	}
+	logger.Info("Chinese variant changed", logger.String("variant", string(a.config.GetChineseVariant())))
+	a.applyChineseVariant()
+	return nil
+}
+
+// GetTargetLanguage returns the language of the translation, e.g. "zh" or "ja"
+func (a *App) GetTargetLanguage() string {
+	return string(a.targetLanguage())
+}
+
+// SetTargetLanguage saves the language of the translation and applies it to the following
+// translations
+func (a *App) SetTargetLanguage(lang string) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetTargetLanguage(lang); err != nil {
+		return err
+	}
+	logger.Info("target language changed", logger.String("language", string(a.config.GetTargetLanguage())))
+	a.applyChineseVariant()
+	return nil
+}
+
+// GetGlossaryPath returns the glossary file applied to translations, empty when none is set
+func (a *App) GetGlossaryPath() string {
+	if a.glossaryOverride != "" {
+		return a.glossaryOverride
+	}
+	if a.config != nil {
+		return a.config.GetGlossaryPath()
+	}
+	return ""
+}
+
+// SetGlossaryPath checks and saves the glossary file applied to the following translations;
+// an empty path removes the glossary
+func (a *App) SetGlossaryPath(path string) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	path = strings.TrimSpace(path)
+	if path != "" {
+		if _, err := translator.LoadGlossary(path); err != nil {
+			return err
+		}
+	}
+	if err := a.config.SetGlossaryPath(path); err != nil {
+		return err
+	}
+	logger.Info("glossary changed", logger.String("path", path))
+	return nil
+}
+
+// GetPromptPresets returns the names of the built-in prompt presets for the settings
+func (a *App) GetPromptPresets() []string {
+	return translator.PromptPresets()
+}
+
+// GetPromptPreset returns the prompt preset of translations
+func (a *App) GetPromptPreset() string {
+	if a.promptPresetOverride != "" {
+		return a.promptPresetOverride
+	}
+	if a.config != nil {
+		return a.config.GetPromptPreset()
+	}
+	return translator.PromptPresetDefault
+}
+
+// SetPromptPreset checks and saves the prompt preset of the following translations
+func (a *App) SetPromptPreset(preset string) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	preset = strings.TrimSpace(preset)
+	if _, err := translator.PromptPresetTemplate(preset); err != nil {
+		return err
+	}
+	if err := a.config.SetPromptPreset(preset); err != nil {
+		return err
+	}
+	logger.Info("prompt preset changed", logger.String("preset", preset))
+	return nil
+}
+
+// GetDebugCaptureDir returns the directory the prompts and responses of failed or damaged
+// chunks are saved to, resolved against the work directory; empty when the capture is off
+func (a *App) GetDebugCaptureDir() string {
+	if a.config == nil {
+		return ""
+	}
+	dir := a.config.GetDebugCaptureDir()
+	if dir == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(a.workDir, dir)
+}
+
+// SetDebugCaptureDir saves the directory the prompts and responses of failed or damaged
+// chunks are saved to, one subdirectory per translation; empty turns the capture off
+func (a *App) SetDebugCaptureDir(dir string) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetDebugCaptureDir(dir); err != nil {
+		return err
+	}
+	logger.Info("debug capture directory changed", logger.String("dir", a.GetDebugCaptureDir()))
+	return nil
+}
+
+// GetOnCompleteHook returns the webhook URL or command notified when a translation finishes
+// or fails: the one given on the command line, otherwise the saved one; empty when none
+func (a *App) GetOnCompleteHook() string {
+	if a.completeHookOverride != "" {
+		return a.completeHookOverride
+	}
+	if a.config == nil {
+		return ""
+	}
+	return a.config.GetOnCompleteHook()
+}
+
+// SetOnCompleteHook saves the webhook URL (HTTPS) or command notified when a translation
+// finishes or fails; empty removes it
+func (a *App) SetOnCompleteHook(hook string) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := hooks.Validate(hook); err != nil {
+		return types.NewAppError(types.ErrInvalidInput, err.Error(), err)
+	}
+	if err := a.config.SetOnCompleteHook(hook); err != nil {
+		return err
+	}
+	logger.Info("completion hook changed", logger.Bool("set", strings.TrimSpace(hook) != ""), logger.Bool("webhook", hooks.IsWebhook(hook)))
+	return nil
+}
+
+// UseOnCompleteHook sets the webhook URL or command notified when a translation finishes
+// or fails for this session (CLI --on-complete), without saving it
+func (a *App) UseOnCompleteHook(hook string) error {
+	if err := hooks.Validate(hook); err != nil {
+		return err
+	}
+	a.completeHookOverride = strings.TrimSpace(hook)
+	return nil
+}
+
+// notifyCompletion runs the completion hook, if any, for a translation that ended with err.
+// In the GUI the hook runs in the background so the result is shown at once; on the command
+// line it is delivered before returning, as the process may exit right after.
+func (a *App) notifyCompletion(payload hooks.Payload, err error) {
+	hook := a.GetOnCompleteHook()
+	if hook == "" {
+		return
+	}
+	payload.Status = hooks.StatusSuccess
+	if err != nil {
+		payload.Status = hooks.StatusError
+		payload.Error = err.Error()
+	}
+	payload.FinishedAt = time.Now()
+	if a.isWailsRuntime {
+		go hooks.Run(hook, payload)
+		return
+	}
+	hooks.Run(hook, payload)
+}
+
+// tokensUsed returns the tokens used by the translator so far, across translations
+func (a *App) tokensUsed() int {
+	if a.translator == nil {
+		return 0
+	}
+	return a.translator.Progress().TokensUsed
+}
+
+// beginUsage starts metering the LLM requests of a translation job: the chunks of the
+// translator with their retries, the syntax fixes of the validator and the compile fixer.
+// A continued job carries over the usage recorded by its earlier runs.
+func (a *App) beginUsage(previous *results.PaperInfo) {
+	a.usage = translator.NewUsageMeter()
+	if previous != nil && previous.InputTokens+previous.OutputTokens > 0 {
+		a.usage.Add(previous.Model, translator.Usage{PromptTokens: previous.InputTokens, CompletionTokens: previous.OutputTokens})
+	}
+	if a.translator != nil {
+		a.translator.SetUsageMeter(a.usage)
+	}
+	if a.validator != nil {
+		a.validator.SetUsageMeter(a.usage)
+	}
+}
+
+// recordUsage stores the token usage of the current job and its estimated cost in its
+// library entry
+func (a *App) recordUsage(info *results.PaperInfo) {
+	total := a.usage.Total()
+	if total.Requests == 0 {
+		return
+	}
+	info.InputTokens = total.InputTokens
+	info.OutputTokens = total.OutputTokens
+	info.Model = total.Model
+	for _, m := range a.usage.Models() {
+		info.EstimatedCost += a.estimateCost(m.Model, m.InputTokens, m.OutputTokens)
+	}
+}
+
+// estimateCost returns the cost in US dollars of the tokens used with a model, 0 when its
+// price is unknown
+func (a *App) estimateCost(model string, inputTokens, outputTokens int) float64 {
+	if a.config == nil {
+		return 0
+	}
+	return a.config.EstimateCost(model, inputTokens, outputTokens)
+}
+
+// GetUsageStats returns the token usage and estimated cost of the papers of the library
+// translated since the given time; a zero time covers the whole library
+func (a *App) GetUsageStats(since time.Time) (*results.UsageStats, error) {
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	stats, err := a.results.UsageStats(since)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "读取用量统计失败", err)
+	}
+	return stats, nil
+}
+
+// newDebugCapture returns the debug capture of a translation, or nil when the capture is
+// off. It replaces the capture reported in the status.
+func (a *App) newDebugCapture() *translator.DebugCapture {
+	var capture *translator.DebugCapture
+	if dir := a.GetDebugCaptureDir(); dir != "" {
+		capture = translator.NewDebugCapture(dir, translator.MaxCaptureRuns)
+	}
+	a.statusMu.Lock()
+	a.debugCapture = capture
+	a.statusMu.Unlock()
+	return capture
+}
+
+// UseGlossary sets the glossary file for this session only, without saving it (CLI --glossary)
+func (a *App) UseGlossary(path string) error {
+	if _, err := translator.LoadGlossary(path); err != nil {
+		return err
+	}
+	a.glossaryOverride = path
+	return nil
+}
+
+// UsePromptPreset sets the prompt preset for this session only, without saving it
+// (CLI --prompt-preset)
+func (a *App) UsePromptPreset(preset string) error {
+	if _, err := translator.PromptPresetTemplate(preset); err != nil {
+		return err
+	}
+	a.promptPresetOverride = preset
+	return nil
+}
+
+// UseBilingualLayout sets the layout of the bilingual PDF generated with each translation
+// for this session (CLI --bilingual-layout): "side-by-side" or "interleaved"
+func (a *App) UseBilingualLayout(layout string) error {
+	l, err := types.ParseBilingualLayout(layout)
+	if err != nil {
+		return err
+	}
+	a.bilingualLayout = l
+	return nil
+}
+
+// bilingualPDFLayout returns the layout of the bilingual PDF generated with each translation
+func (a *App) bilingualPDFLayout() types.BilingualLayout {
+	if a.bilingualLayout == "" {
+		return types.BilingualSideBySide
+	}
+	return a.bilingualLayout
+}
+
+// UseMainTexFile pins the main tex file of the jobs of this session (CLI --main-tex), relative
+// to the extracted source, instead of the top ranked candidate
+func (a *App) UseMainTexFile(path string) {
+	if path == "" {
+		a.mainTexOverride = ""
+		return
+	}
+	a.mainTexOverride = filepath.Clean(filepath.FromSlash(path))
+}
+
+// findMainTexCandidates ranks the main tex file candidates of an extracted source, the file
+// pinned by UseMainTexFile first even if it is no candidate
+func (a *App) findMainTexCandidates(dir string) ([]downloader.TexCandidate, error) {
+	candidates, err := a.downloader.FindMainTexCandidates(dir)
+	if a.mainTexOverride == "" {
+		return candidates, err
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, a.mainTexOverride)); statErr != nil {
+		return nil, types.NewAppErrorWithDetails(types.ErrFileNotFound, "指定的主 tex 文件不存在", a.mainTexOverride, statErr)
+	}
+
+	pinned := []downloader.TexCandidate{{Path: a.mainTexOverride, Strong: true}}
+	for _, c := range candidates {
+		if c.Path == a.mainTexOverride {
+			pinned[0] = c
+		} else {
+			pinned = append(pinned, c)
+		}
+	}
+	logger.Info("using pinned main tex file", logger.String("file", a.mainTexOverride))
+	return pinned, nil
+}
+
+// mainTexChoice is a job waiting for the user to choose its main tex file
+type mainTexChoice struct {
+	candidates []downloader.TexCandidate
+	chosen     chan string
+}
+
+// chooseMainTex returns the paths of the main tex file candidates in the order they are
+// compiled in, the main file first. When several candidates are strong and no file is pinned,
+// the GUI chooses by the main-tex-choice-requested event and ChooseMainTex; without a GUI
+// the top candidate is taken. Cancelling the job (ctx) returns its error.
+func (a *App) chooseMainTex(ctx context.Context, candidates []downloader.TexCandidate) ([]string, error) {
+	paths := make([]string, len(candidates))
+	strong := 0
+	for i, c := range candidates {
+		paths[i] = c.Path
+		if c.Strong {
+			strong++
+		}
+	}
+	if strong < 2 || a.mainTexOverride != "" {
+		return paths, nil
+	}
+	if !a.isWailsRuntime {
+		logger.Warn("several likely main tex files, using the top one (pin another with --main-tex)",
+			logger.String("file", paths[0]),
+			logger.Int("strongCandidates", strong))
+		return paths, nil
+	}
+
+	choice := &mainTexChoice{candidates: candidates, chosen: make(chan string, 1)}
+	a.jobMu.Lock()
+	a.mainTexChoice = choice
+	a.jobMu.Unlock()
+	defer func() {
+		a.jobMu.Lock()
+		if a.mainTexChoice == choice {
+			a.mainTexChoice = nil
+		}
+		a.jobMu.Unlock()
+	}()
+
+	a.statusMu.RLock()
+	progress := a.status.Progress
+	a.statusMu.RUnlock()
+	a.updateStatus(types.PhaseExtracting, progress, fmt.Sprintf("发现 %d 个可能的主 tex 文件，等待选择...", strong))
+	a.safeEmit(EventMainTexChoiceRequested, candidates)
+
+	select {
+	case chosen := <-choice.chosen:
+		ordered := []string{chosen}
+		for _, p := range paths {
+			if p != chosen {
+				ordered = append(ordered, p)
+			}
+		}
+		return ordered, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ChooseMainTex answers the main-tex-choice-requested event with the path of the chosen
+// candidate, as given in the event
+func (a *App) ChooseMainTex(path string) error {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	choice := a.mainTexChoice
+	if choice == nil {
+		logger.Warn("no main tex file choice pending", logger.String("file", path))
+		return types.NewAppError(types.ErrInvalidInput, "当前没有等待选择主 tex 文件的任务", nil)
+	}
+	for _, c := range choice.candidates {
+		if c.Path == path {
+			logger.Info("main tex file chosen", logger.String("file", path))
+			a.mainTexChoice = nil
+			choice.chosen <- path
+			return nil
+		}
+	}
+	return types.NewAppErrorWithDetails(types.ErrInvalidInput, "所选文件不是候选主 tex 文件", path, nil)
+}
+
+// loadGlossary returns the glossary of a job: the terms of the glossary file, if any, to
+// which the translator adds the terms it learns from the first chunk of each file. A file
+// that cannot be read is reported and the job runs with the learned terms only.
+func (a *App) loadGlossary() *translator.Glossary {
+	path := a.GetGlossaryPath()
+	if path == "" {
+		return translator.NewGlossary()
+	}
+	glossary, err := translator.LoadGlossary(path)
+	if err != nil {
+		logger.Warn("failed to load glossary", logger.String("path", path), logger.Err(err))
+		a.addWarning(fmt.Sprintf("术语表无法加载，未使用: %v", err))
+		return translator.NewGlossary()
+	}
+	return glossary
+}
+
+// promptTemplate returns the prompt template of translations: the user's template file, if
+// any, or the preset
+func (a *App) promptTemplate() (*translator.PromptTemplate, error) {
+	path := ""
+	if a.config != nil {
+		path = a.config.GetPromptTemplatePath()
+	}
+	return translator.LoadPromptTemplate(a.GetPromptPreset(), path)
+}
+
+// loadPromptTemplate returns the prompt template of a job. A template file that cannot be
+// read or lacks a required placeholder is reported and the job runs with the default one.
+func (a *App) loadPromptTemplate() *translator.PromptTemplate {
+	prompt, err := a.promptTemplate()
+	if err != nil {
+		logger.Warn("failed to load prompt template", logger.String("preset", prompt.Name), logger.Err(err))
+		a.addWarning(fmt.Sprintf("提示词模板无法使用: %v", err))
+	}
+	return prompt
+}
+
+// GetMaxConcurrentCompiles returns how many LaTeX processes may run at the same time
+func (a *App) GetMaxConcurrentCompiles() int {
+	return compilelimit.Max()
+}
+
+// GetRequestTimeoutSeconds returns how long one translation request may take before it is
+// cancelled and retried
+func (a *App) GetRequestTimeoutSeconds() int {
+	if a.config == nil {
+		return config.DefaultRequestTimeoutSeconds
+	}
+	return int(a.config.GetRequestTimeout() / time.Second)
+}
+
+// SetRequestTimeout saves how long one translation request may take before it is cancelled
+// and retried, in seconds (0 restores the default), and applies it immediately
+func (a *App) SetRequestTimeout(seconds int) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetRequestTimeout(seconds); err != nil {
+		return err
+	}
+	if a.translator != nil {
+		a.translator.SetRequestTimeout(a.config.GetRequestTimeout())
+	}
+	logger.Info("request timeout changed", logger.Int("seconds", seconds))
+	return nil
+}
+
+// SetTranslationBudget saves the budget of one translation run of a document: the minutes
+// and tokens after which translation stops, keeping the finished part to continue later.
+// 0 removes a limit; the budget applies from the next translation.
+func (a *App) SetTranslationBudget(minutes, tokens int) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetTranslationBudget(minutes, tokens); err != nil {
+		return err
+	}
+	logger.Info("translation budget changed", logger.Int("minutes", minutes), logger.Int("tokens", tokens))
+	return nil
+}
+
+// translationBudget returns the budget of a translation run from the settings
+func (a *App) translationBudget() translator.TranslationBudget {
+	if a.config == nil {
+		return translator.TranslationBudget{}
+	}
+	duration, tokens := a.config.GetTranslationBudget()
+	return translator.TranslationBudget{MaxDuration: duration, MaxTokens: tokens}
+}
+
+// SetMaxConcurrentCompiles saves how many LaTeX processes may run at the same time across
+// all features and applies it immediately; compiles beyond the limit wait for a slot
+func (a *App) SetMaxConcurrentCompiles(n int) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetMaxConcurrentCompiles(n); err != nil {
+		return err
+	}
+	logger.Info("max concurrent compiles changed", logger.Int("limit", n))
+	a.applyCompileLimit()
+	return nil
+}
+
+// GetStrictFontEmbedding returns whether produced PDFs must embed all fonts
+func (a *App) GetStrictFontEmbedding() bool {
+	return a.config != nil && a.config.GetStrictFontEmbedding()
+}
+
+// SetStrictFontEmbedding saves whether produced PDFs must embed all fonts. In strict mode
+// PDFs with non-embedded fonts are rewritten with Ghostscript, and the job fails with the
+// reason if that does not embed every font.
+func (a *App) SetStrictFontEmbedding(strict bool) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetStrictFontEmbedding(strict); err != nil {
+		return err
+	}
+	logger.Info("strict font embedding changed", logger.Bool("strict", strict))
+	return nil
+}
+
+// GetTranslateComments returns whether full-line comments are translated
+func (a *App) GetTranslateComments() bool {
+	return a.config != nil && a.config.GetTranslateComments()
+}
+
+// SetTranslateComments saves whether full-line comments are translated. By default runs of
+// comment lines are kept out of the chunks sent to the model and put back verbatim.
+func (a *App) SetTranslateComments(translate bool) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetTranslateComments(translate); err != nil {
+		return err
+	}
+	if a.translator != nil {
+		a.translator.SetTranslateComments(translate)
+	}
+	logger.Info("comment translation changed", logger.Bool("translate", translate))
+	return nil
+}
+
+// GetTranslateBibliography returns whether the venues and notes of reference list entries
+// are translated
+func (a *App) GetTranslateBibliography() bool {
+	return a.config != nil && a.config.GetTranslateBibliography()
+}
+
+// SetTranslateBibliography saves whether the venues and notes of reference list entries are
+// translated. By default thebibliography environments are kept verbatim as one block.
+func (a *App) SetTranslateBibliography(translate bool) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetTranslateBibliography(translate); err != nil {
+		return err
+	}
+	if a.translator != nil {
+		a.translator.SetTranslateBibliography(translate)
+	}
+	logger.Info("bibliography translation changed", logger.Bool("translate", translate))
+	return nil
+}
+
+// GetChunkOverlap returns whether each chunk is sent with the end of the previous one as context
+func (a *App) GetChunkOverlap() bool {
+	return a.config != nil && a.config.GetChunkOverlap()
+}
+
+// SetChunkOverlap saves whether each chunk is sent with the last paragraph of the previous
+// one as read-only context, for sentences continuing across chunks
+func (a *App) SetChunkOverlap(overlap bool) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetChunkOverlap(overlap); err != nil {
+		return err
+	}
+	if a.translator != nil {
+		a.translator.SetChunkOverlap(overlap)
+	}
+	logger.Info("chunk overlap changed", logger.Bool("overlap", overlap))
+	return nil
+}
+
+// CheckContextWindow compares a context window with the known size of a model and returns
+// the recommended value. The frontend calls it while the settings are edited and on save.
+func (a *App) CheckContextWindow(model string, contextWindow int) *types.ContextWindowAdvice {
+	if a.config == nil {
+		return &types.ContextWindowAdvice{Model: model, Configured: contextWindow}
+	}
+	return a.config.CheckContextWindow(model, contextWindow)
+}
+
+// ApplyRecommendedContextWindow sets the context window to the value recommended for the
+// configured model. The next job batches with the new window.
+func (a *App) ApplyRecommendedContextWindow() (*types.ContextWindowAdvice, error) {
+	if a.config == nil {
+		return nil, types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	advice, err := a.config.ApplyRecommendedContextWindow()
+	if err != nil {
+		return advice, err
+	}
+	if err := a.ReloadConfig(); err != nil {
+		return advice, err
+	}
+	return advice, nil
+}
+
+// warnContextWindow adds a warning to the job when the configured context window does not
+// fit the configured model
+func (a *App) warnContextWindow() {
+	if a.config == nil {
+		return
+	}
+	advice := a.config.CheckConfiguredContextWindow()
+	if !advice.Mismatch {
+		return
+	}
+	logger.Warn("context window does not fit the model",
+		logger.String("model", advice.Model),
+		logger.Int("contextWindow", advice.Configured),
+		logger.Int("modelMax", advice.ModelMax),
+		logger.Int("recommended", advice.Recommended))
+	a.addWarning(advice.Message + "（可在设置中点击“使用推荐值”，命令行可使用 --auto-context）")
+}
+
+// auditOutputFonts lists the fonts of produced PDFs with their embedded status. Missing
+// fonts are reported as warnings; in strict mode they are remediated first, and the
+// returned error tells what is still not embedded and how to fix it.
+func (a *App) auditOutputFonts(pdfPaths ...string) ([]*types.FontAudit, error) {
+	strict := a.GetStrictFontEmbedding()
+	var audits []*types.FontAudit
+	var failures []string
+	for _, pdfPath := range pdfPaths {
+		if pdfPath == "" {
+			continue
+		}
+		var audit *types.FontAudit
+		var err error
+		if strict {
+			audit, err = pdf.EnsureFontsEmbedded(pdfPath)
+		} else {
+			audit, err = pdf.AuditFonts(pdfPath)
+		}
+		if err != nil {
+			logger.Warn("failed to audit PDF fonts", logger.String("pdfPath", pdfPath), logger.Err(err))
+			if strict {
+				failures = append(failures, fmt.Sprintf("%s: %v", filepath.Base(pdfPath), err))
+			}
+			continue
+		}
+		audits = append(audits, audit)
+		logger.Info("PDF font audit",
+			logger.String("pdfPath", pdfPath),
+			logger.Int("fonts", len(audit.Fonts)),
+			logger.String("notEmbedded", strings.Join(audit.NotEmbedded, ", ")),
+			logger.Bool("remediated", audit.Remediated))
+
+		if audit.FullyEmbedded() {
+			continue
+		}
+		warning := fmt.Sprintf("%s 有未嵌入的字体: %s", filepath.Base(pdfPath), strings.Join(audit.NotEmbedded, ", "))
+		if strict {
+			failures = append(failures, fmt.Sprintf("%s: %s", filepath.Base(pdfPath), audit.RemediationError))
+		} else {
+			a.addWarning(warning + "（期刊投稿或归档前可在设置中开启严格字体嵌入）")
+		}
+	}
+
+	if len(failures) > 0 {
+		return audits, types.NewAppErrorWithDetails(types.ErrCompile, "PDF 未能嵌入全部字体", strings.Join(failures, "\n"), nil)
+	}
+	return audits, nil
+}
+
+// UseMaxConcurrentCompiles sets the compile process limit for this session only, without
+// saving it (CLI --max-compiles)
+func (a *App) UseMaxConcurrentCompiles(n int) {
+	a.compileLimitOverride = n
+	a.applyCompileLimit()
+}
+
+// applyCompileLimit applies the session override or the configured compile process limit
+func (a *App) applyCompileLimit() {
+	switch {
+	case a.compileLimitOverride > 0:
+		compilelimit.SetMax(a.compileLimitOverride)
+	case a.config != nil:
+		compilelimit.SetMax(a.config.GetMaxConcurrentCompiles())
+	}
+}
+
+// GetAutoInstallPackages returns whether packages missing from the TeX distribution are
+// installed while compiling
+func (a *App) GetAutoInstallPackages() bool {
+	return a.config != nil && a.config.GetAutoInstallPackages()
+}
+
+// SetAutoInstallPackages saves whether packages missing from the TeX distribution are
+// installed while compiling: on the fly with MiKTeX, with tlmgr with TeX Live. Off by default,
+// as tlmgr changes the TeX installation and may need write access to it.
+func (a *App) SetAutoInstallPackages(enabled bool) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetAutoInstallPackages(enabled); err != nil {
+		return err
+	}
+	a.applyAutoInstallPackages()
+	logger.Info("automatic package installation changed", logger.Bool("enabled", enabled))
+	return nil
+}
+
+// UseAutoInstallPackages installs missing packages while compiling for this session only,
+// without saving it (CLI --auto-install-packages)
+func (a *App) UseAutoInstallPackages() {
+	a.autoInstallOverride = true
+	a.applyAutoInstallPackages()
+}
+
+// autoInstallPackages reports whether missing packages are installed while compiling: the
+// session override or the configured option
+func (a *App) autoInstallPackages() bool {
+	return a.autoInstallOverride || a.GetAutoInstallPackages()
+}
+
+// applyAutoInstallPackages applies the automatic package installation to the compiler
+func (a *App) applyAutoInstallPackages() {
+	if a.compiler != nil {
+		a.compiler.SetAutoInstallPackages(a.autoInstallPackages())
+	}
+}
+
+// GetIgnoreIncludeOnly returns whether the \includeonly directive of a main file is removed
+// so the whole document is translated and compiled
+func (a *App) GetIgnoreIncludeOnly() bool {
+	return a.config != nil && a.config.GetIgnoreIncludeOnly()
+}
+
+// SetIgnoreIncludeOnly saves whether the \includeonly directive of a main file is removed.
+// Off by default: only the files it lists are translated and the directive is kept, so the
+// original and the translated PDF hold the same chapters.
+func (a *App) SetIgnoreIncludeOnly(ignore bool) error {
+	if a.config == nil {
+		return types.NewAppError(types.ErrConfig, "配置管理器未初始化", nil)
+	}
+	if err := a.config.SetIgnoreIncludeOnly(ignore); err != nil {
+		return err
+	}
+	logger.Info("\\includeonly handling changed", logger.Bool("ignore", ignore))
+	return nil
+}
+
+// UseIgnoreIncludeOnly removes the \includeonly directive of main files for this session
+// only, without saving it (CLI --ignore-includeonly)
+func (a *App) UseIgnoreIncludeOnly() {
+	a.ignoreIncludeOnlyOverride = true
+}
+
+// ignoreIncludeOnly reports whether the \includeonly directive is removed: the session
+// override or the configured option
+func (a *App) ignoreIncludeOnly() bool {
+	return a.ignoreIncludeOnlyOverride || a.GetIgnoreIncludeOnly()
+}
+
+// detectIncludeOnly reads the \includeonly directive of a main file; nil when it has none
+func detectIncludeOnly(mainTexPath string) *types.IncludeOnlyInfo {
+	content, err := os.ReadFile(mainTexPath)
+	if err != nil {
+		return nil
+	}
+	return compiler.DetectIncludeOnly(string(content))
+}
+
+// applyIncludeOnly handles the \includeonly directive of the main file before the original
+// is compiled, so both builds contain the same files: the directive is removed when it is
+// ignored, otherwise the files it leaves out are kept untranslated. Returns nil when the
+// main file has none.
+func (a *App) applyIncludeOnly(mainTexPath string) *types.IncludeOnlyInfo {
+	info := detectIncludeOnly(mainTexPath)
+	if info == nil {
+		return nil
+	}
+	if a.ignoreIncludeOnly() {
+		stripped, err := compiler.StripIncludeOnly(mainTexPath)
+		if err != nil {
+			logger.Warn("failed to remove \\includeonly", logger.String("path", mainTexPath), logger.Err(err))
+		}
+		info.Stripped = stripped
+	}
+	logger.Info("main file uses \\includeonly",
+		logger.String("directive", info.Directive),
+		logger.Int("excluded", len(info.Excluded)),
+		logger.Bool("stripped", info.Stripped))
+	a.addWarning(compiler.FormatIncludeOnly(info))
+	return info
+}
+
+// includeOnlyExclusions returns the cleaned paths of the files left out by a respected
+// \includeonly directive; nil when every file is translated
+func includeOnlyExclusions(mainTexPath string, info *types.IncludeOnlyInfo) map[string]bool {
+	if info == nil || info.Stripped || len(info.Excluded) == 0 {
+		return nil
+	}
+	excluded := make(map[string]bool, len(info.Excluded))
+	for _, name := range info.Excluded {
+		excluded[filepath.Join(filepath.Dir(mainTexPath), filepath.FromSlash(name))] = true
+	}
+	return excluded
+}
+
+// decidePaperFile decides how a file of a paper is handled: the decision rules, with the
+// files left out by \includeonly kept as they are unless overridden
+func decidePaperFile(relPath, fullPath string, content []byte, overrides decisions.Overrides, excluded map[string]bool) types.FileDecision {
+	decision := decisions.Decide(relPath, content, decisions.Paper, overrides)
+	if excluded[filepath.Clean(fullPath)] && decision.Decision == types.FileDecisionTranslate && decision.Rule != decisions.RuleOverride {
+		decision = decisions.Record(relPath, content, types.FileDecisionCopy, decisions.RuleIncludeOnly, nil)
+	}
+	return decision
+}
+
+// UseChineseVariant sets the script of the translated Chinese text for this session only,
+// without saving it (CLI --variant)
+func (a *App) UseChineseVariant(variant string) error {
+	v, err := types.ParseChineseVariant(variant)
+	if err != nil {
+		return err
+	}
+	a.variantOverride = v
+	a.applyChineseVariant()
+	return nil
+}
+
+// chineseVariant returns the script of the translation: the session override if set,
+// otherwise the configured one
+func (a *App) chineseVariant() types.ChineseVariant {
+	if a.variantOverride != "" {
+		return a.variantOverride
+	}
+	if a.config != nil {
+		return a.config.GetChineseVariant()
+	}
+	return types.ChineseSimplified
+}
+
+// UseTargetLanguage sets the language of the translation for this session only, without
+// saving it (CLI --lang)
+func (a *App) UseTargetLanguage(lang string) error {
+	l, err := types.ParseTargetLanguage(lang)
+	if err != nil {
+		return err
+	}
+	a.languageOverride = l
+	a.applyChineseVariant()
+	return nil
+}
+
+// targetLanguage returns the language of the translation: the session override if set,
+// otherwise the configured one
+func (a *App) targetLanguage() types.TargetLanguage {
+	if a.languageOverride != "" {
+		return a.languageOverride
+	}
+	if a.config != nil {
+		return a.config.GetTargetLanguage()
+	}
+	return types.LanguageChinese
+}
+
+// applyChineseVariant passes the current language, script and the phrases exempt from
+// conversion to the translator
+func (a *App) applyChineseVariant() {
+	if a.translator == nil {
+		return
+	}
+	var phrases map[string]string
+	if a.config != nil {
+		phrases = a.config.GetChineseVariantPhrases()
+	}
+	a.translator.SetTargetLanguage(a.targetLanguage())
+	a.translator.SetChineseVariant(a.chineseVariant(), phrases)
+}
+
+// compileTranslated compiles a translated document with the engine its language needs,
+// LuaLaTeX for Japanese (luatexja) and XeLaTeX otherwise, falling back to the other engines
+// when that one fails. The compilation stops when ctx is done.
+func (a *App) compileTranslated(ctx context.Context, texPath, outputDir string) (*types.CompileResult, error) {
+	result, err := a.compiler.CompileTranslatedWithFallbackContext(ctx, texPath, outputDir, a.translatedEngine())
+	if result != nil && result.Success {
+		logger.Info("translated document compiled",
+			logger.String("engine", result.Engine),
+			logger.String("preprocessors", strings.Join(result.Preprocessors, ",")))
+	}
+	return result, err
+}
+
+// translatedEngine returns the engine a translated document is compiled with first
+func (a *App) translatedEngine() string {
+	if a.targetLanguage() == types.LanguageJapanese {
+		return compiler.CompilerLuaLaTeX
+	}
+	return compiler.CompilerXeLaTeX
+}
+
+// startTranslatedPreview starts building a preview of the translated document, its front
+// matter and first chapter, to show while the full document compiles. The preview compiles
+// a copy of the source in its own directory, so its aux files and fixes cannot affect the
+// full build, and emits EventTranslatedPDFPreviewReady when it succeeds; failures are only
+// logged. It must be called before the full compilation starts, which rewrites the tex file.
+// The returned function stops the preview; no event is emitted after it returns.
+func (a *App) startTranslatedPreview(ctx context.Context, extractDir, translatedTexPath string) func() {
+	// Only the GUI shows the preview, and with a single compile slot it would delay the
+	// full build
+	if !a.isWailsRuntime || compilelimit.Max() < 2 {
+		return func() {}
+	}
+	texPath, err := prepareTranslatedPreview(extractDir, translatedTexPath)
+	if err != nil {
+		logger.Info("translated preview not built", logger.Err(err))
+		return func() {}
+	}
+	if texPath == "" {
+		logger.Debug("translated document too short for a preview")
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var mu sync.Mutex
+	stopped := false
+	go func() {
+		previewDir := filepath.Join(extractDir, previewDirName)
+		defer os.RemoveAll(filepath.Join(previewDir, "src"))
+		result, err := a.compiler.CompilePreviewContext(ctx, texPath, filepath.Join(previewDir, "pdf"), a.translatedEngine())
+		if err != nil || result == nil || !result.Success {
+			if ctx.Err() == nil {
+				logger.Info("translated preview failed to compile", logger.Err(err))
+			}
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		logger.Info("translated preview compiled", logger.String("pdfPath", result.PDFPath))
+		a.safeEmit(EventTranslatedPDFPreviewReady, result.PDFPath)
+	}()
+	return func() {
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		cancel()
+	}
+}
+
+// prepareTranslatedPreview copies the source in extractDir to the preview directory and
+// shortens the copy of the translated main file with compiler.PreviewDocument. Returns the
+// path of the shortened copy, or "" when the document is too short for a preview.
+func prepareTranslatedPreview(extractDir, translatedTexPath string) (string, error) {
+	data, err := os.ReadFile(translatedTexPath)
+	if err != nil {
+		return "", err
+	}
+	content, ok := compiler.PreviewDocument(string(data))
+	if !ok {
+		return "", nil
+	}
+	rel, err := filepath.Rel(extractDir, translatedTexPath)
+	if err != nil {
+		return "", err
+	}
+
+	previewDir := filepath.Join(extractDir, previewDirName)
+	if err := os.RemoveAll(previewDir); err != nil {
+		return "", err
+	}
+	srcDir := filepath.Join(previewDir, "src")
+	if err := copyDir(extractDir, srcDir); err != nil {
+		return "", err
+	}
+	texPath := filepath.Join(srcDir, rel)
+	if err := os.WriteFile(texPath, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return texPath, nil
+}
+
+// variantMismatch reports whether a stored translation was made in a different script than
+// the current one, so it must not be returned, continued or reused
+func (a *App) variantMismatch(info *results.PaperInfo) bool {
+	return info != nil && types.NormalizeChineseVariant(info.ChineseVariant) != a.chineseVariant()
+}
+
+// replacesQuickTranslation reports whether a stored translation was made in quick mode
+// and the current mode is full quality, so the stored one should be replaced
+func (a *App) replacesQuickTranslation(info *results.PaperInfo) bool {
+	return info != nil && info.ArxivID != "" && info.TranslationMode == results.TranslationModeQuick && !a.IsQuickMode()
+}
+
+// jobKey returns the key identifying a job for input with the current translation options
+func (a *App) jobKey(input string) string {
+	options := ""
+	if a.config != nil {
+		options = a.config.GetBaseURL() + "|" + a.config.GetModel()
+	}
+	// Simplified and traditional translations of the same input are different jobs
+	options += "|" + string(a.chineseVariant())
+	// So are translations into different languages
+	if lang := a.targetLanguage(); !lang.IsChinese() {
+		options += "|lang:" + string(lang)
+	}
+	// So are quick and full translations
+	if a.IsQuickMode() {
+		options += "|quick"
+	}
+	// And translations that are not compiled
+	if a.IsNoCompileMode() {
+		options += "|nocompile"
+	}
+	// And translations with different per-file decisions
+	if overrides := a.getFileOverrides(); !overrides.Empty() {
+		options += "|files:" + overrides.String()
+	}
+	return results.JobKey(input, options)
+}
+
+// startOrAttachJob makes key the running job of this process. If a job with the same key
+// is already running it is returned with attached set; if a different job is running,
+// nil is returned.
+func (a *App) startOrAttachJob(key string) (job *runningJob, attached bool) {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+
+	if a.activeJob != nil {
+		if a.activeJob.key == key {
+			return a.activeJob, true
+		}
+		return nil, false
+	}
+	if a.IsProcessing() {
+		return nil, false
+	}
+	a.activeJob = &runningJob{key: key, done: make(chan struct{})}
+	return a.activeJob, false
+}
+
+// findRunningJob returns the running job of this process with the given key, if any
+func (a *App) findRunningJob(key string) *runningJob {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	if a.activeJob != nil && a.activeJob.key == key {
+		return a.activeJob
+	}
+	return nil
+}
+
+// attachToJob waits for a running job and returns its result
+func (a *App) attachToJob(job *runningJob) (*types.ProcessResult, error) {
+	logger.Info("duplicate submission, attaching to the running job", logger.String("key", job.key))
+	<-job.done
+	return job.result, job.err
+}
+
+// finishJob marks the running job as done and releases the callers attached to it
+func (a *App) finishJob(job *runningJob) {
+	a.jobMu.Lock()
+	if a.activeJob == job {
+		a.activeJob = nil
+	}
+	a.jobMu.Unlock()
+	close(job.done)
+}
+
+// registerJob records the job in the cross-process registry and keeps its heartbeat and
+// progress up to date until the returned function is called. Fails if another process is
+// running the same job, unless duplicates are allowed.
+// Registry errors only disable the check and never block translation.
+func (a *App) registerJob(key, input string) (func(), error) {
+	if a.jobs == nil {
+		return func() {}, nil
+	}
+
+	other, err := a.jobs.Register(results.ActiveJob{Key: key, Input: input})
+	if err != nil {
+		logger.Warn("failed to register job", logger.Err(err))
+		return func() {}, nil
+	}
+	if other != nil {
+		if !a.duplicateJobsAllowed() {
+			return nil, duplicateJobError(other)
+		}
+		logger.Warn("same input is being translated in another process, continuing as requested",
+			logger.Int("pid", other.PID),
+			logger.String("host", other.Host))
+		return func() {}, nil
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(results.JobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				status := a.GetStatus()
+				if err := a.jobs.Heartbeat(key, status.Progress, string(status.Phase)); err != nil {
+					logger.Debug("job heartbeat failed", logger.Err(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		if err := a.jobs.Unregister(key); err != nil {
+			logger.Warn("failed to unregister job", logger.Err(err))
+		}
+	}, nil
+}
+
+// checkOtherProcessJob fails if another process is running the job with key
+func (a *App) checkOtherProcessJob(key string) error {
+	if a.jobs == nil || a.duplicateJobsAllowed() {
+		return nil
+	}
+	active, err := a.jobs.Active()
+	if err != nil {
+		logger.Warn("failed to read job registry", logger.Err(err))
+		return nil
+	}
+	for _, job := range active {
+		if job.Key == key && !job.IsCurrentProcess() {
+			return duplicateJobError(job)
+		}
+	}
+	return nil
+}
+
+// duplicateJobsAllowed reports whether SetAllowDuplicateJobs was enabled
+func (a *App) duplicateJobsAllowed() bool {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	return a.allowDuplicateJobs
+}
+
+// forceTranslateEnabled reports whether SetForceTranslate was enabled
+func (a *App) forceTranslateEnabled() bool {
+	a.jobMu.Lock()
+	defer a.jobMu.Unlock()
+	return a.forceTranslate
+}
+
+// duplicateJobError describes the job another process is running
+func duplicateJobError(job *results.ActiveJob) error {
+	logger.Warn("same input is being translated in another process",
+		logger.String("input", job.Input),
+		logger.Int("pid", job.PID),
+		logger.String("host", job.Host),
+		logger.Int("progress", job.Progress))
+	phase := job.Phase
+	if phase == "" {
+		phase = "准备中"
+	}
+	return types.NewAppErrorWithDetails(types.ErrDuplicateJob,
+		fmt.Sprintf("该论文正在另一进程中翻译（已完成 %d%%）", job.Progress),
+		fmt.Sprintf("进程 %d，主机 %s，阶段 %s，开始于 %s；如确需重复翻译，请使用 --allow-duplicate",
+			job.PID, job.Host, phase, job.StartedAt.Format("2006-01-02 15:04:05")),
+		nil)
+}
+
+// SkipRemainingFixes stops the automatic compile-error fix loop while keeping the translation.
+// The job then ends in the "needs manual fix" state with the best-so-far translated LaTeX
+// and the latest compile log saved, ready for hand editing and ReprocessFromTranslatedTex.
+// CancelProcess still aborts the whole job.
+func (a *App) SkipRemainingFixes() error {
+	a.fixMu.Lock()
+	cancel := a.fixCancelFunc
+	a.fixMu.Unlock()
+
+	if cancel == nil {
+		logger.Warn("no fix loop to skip")
+		return types.NewAppError(types.ErrInvalidInput, "当前没有正在进行的自动修复", nil)
+	}
+	logger.Info("skip remaining fixes requested")
+	cancel()
+
+	a.statusMu.RLock()
+	progress := a.status.Progress
+	a.statusMu.RUnlock()
+	a.updateStatus(types.PhaseValidating, progress, "正在跳过剩余修复...")
+	return nil
+}
+
+// setFixReviewPrompt sets the function asking about risky compile fixes in CLI mode. It
+// returns whether the fix is accepted.
+func (a *App) setFixReviewPrompt(prompt func(proposal compiler.FixProposal) bool) {
+	a.fixMu.Lock()
+	a.fixReviewPrompt = prompt
+	a.fixMu.Unlock()
+}
+
+// ApproveFix accepts or rejects a risky compile fix announced by the fix-review-requested
+// event. A rejected fix is not applied; the fixer moves on to the next fix level.
+func (a *App) ApproveFix(fixID string, accept bool) error {
+	a.fixMu.Lock()
+	decision, ok := a.pendingFixes[fixID]
+	delete(a.pendingFixes, fixID)
+	a.fixMu.Unlock()
+
+	if !ok {
+		logger.Warn("no pending fix to approve", logger.String("fixID", fixID))
+		return types.NewAppError(types.ErrInvalidInput, "该修复已处理或不存在", nil)
+	}
+	logger.Info("fix review decided", logger.String("fixID", fixID), logger.Bool("accept", accept))
+	decision <- accept
+	return nil
+}
+
+// enableFixReview makes fixer ask before applying fixes above the default risk threshold:
+// on the command line with the prompt of setFixReviewPrompt, in the GUI by the
+// fix-review-requested event and ApproveFix. Without either, risky fixes are rejected.
+// Skipping the fix loop (ctx) rejects the pending fix.
+func (a *App) enableFixReview(ctx context.Context, fixer *compiler.LaTeXFixer) {
+	fixer.SetFixReviewer(func(proposal compiler.FixProposal) bool {
+		a.fixMu.Lock()
+		prompt := a.fixReviewPrompt
+		a.fixMu.Unlock()
+		if prompt != nil {
+			return prompt(proposal)
+		}
+		if !a.isWailsRuntime {
+			logger.Info("risky fix rejected in non-interactive mode",
+				logger.String("fixID", proposal.ID),
+				logger.String("file", proposal.File))
+			return false
+		}
+
+		decision := make(chan bool, 1)
+		a.fixMu.Lock()
+		if a.pendingFixes == nil {
+			a.pendingFixes = make(map[string]chan bool)
+		}
+		a.pendingFixes[proposal.ID] = decision
+		a.fixMu.Unlock()
 
-	// Rule 4: Fix missing $ for math mode errors
-	if strings.Contains(compileLog, "Missing $ inserted") {
-		standalonePattern := regexp.MustCompile(`([^$\\])([_^])(\{[^}]+\}|[a-zA-Z0-9])([^$])`)
-		if standalonePattern.MatchString(fixed) {
-			fixed = standalonePattern.ReplaceAllString(fixed, "$1$$2$3$$4")
-			anyFixed = true
-			logger.Debug("rule-based fix: wrapped standalone subscript/superscript in math mode")
+		a.statusMu.RLock()
+		progress := a.status.Progress
+		a.statusMu.RUnlock()
+		a.updateStatus(types.PhaseValidating, progress, fmt.Sprintf("等待确认对 %s 的修复...", proposal.File))
+		a.safeEmit(EventFixReviewRequested, proposal)
+
+		select {
+		case accept := <-decision:
+			return accept
+		case <-ctx.Done():
+			a.fixMu.Lock()
+			delete(a.pendingFixes, proposal.ID)
+			a.fixMu.Unlock()
+			return false
 		}
+	}, compiler.DefaultFixReviewPolicy)
+}
+
+// IsFixInProgress reports whether the automatic compile-error fix loop is running
+func (a *App) IsFixInProgress() bool {
+	a.fixMu.Lock()
+	defer a.fixMu.Unlock()
+	return a.fixCancelFunc != nil
+}
+
+// beginFixLoop returns the context of a fix loop, cancelled by SkipRemainingFixes or when
+// parent is cancelled. The returned function must be called when the loop ends.
+func (a *App) beginFixLoop(parent context.Context) (context.Context, func()) {
+	if parent == nil {
+		parent = context.Background()
 	}
+	ctx, cancel := context.WithCancel(parent)
+	a.fixMu.Lock()
+	a.fixCancelFunc = cancel
+	a.fixMu.Unlock()
 
-	// Rule 5: Fix "Lonely \item" errors - \end{itemize}\item should be on separate lines
-	if strings.Contains(compileLog, "Lonely \\item") || strings.Contains(compileLog, "missing list environment") {
-		endItemizeItemPattern := regexp.MustCompile(`(\\end\{itemize\})\s*(\\item\b)`)
-		if endItemizeItemPattern.MatchString(fixed) {
-			fixed = endItemizeItemPattern.ReplaceAllString(fixed, "$1\n$2")
-			anyFixed = true
-			logger.Debug("rule-based fix: separated \\end{itemize} and \\item onto different lines")
+	return ctx, func() {
+		a.fixMu.Lock()
+		a.fixCancelFunc = nil
+		a.fixMu.Unlock()
+		cancel()
+	}
+}
+
+// finishWithManualFix ends a job whose fix loop was skipped. The translated LaTeX is kept
+// as far as it was fixed, the latest compile log is saved next to it, and the library entry
+// is marked as needing a manual fix so ReprocessFromTranslatedTex can pick it up later.
+func (a *App) finishWithManualFix(arxivID, title, input string, sourceInfo *types.SourceInfo, originalPDF, translatedTexPath, compileLog string) error {
+	logPath := filepath.Join(filepath.Dir(translatedTexPath), manualFixLogName)
+	if compileLog != "" {
+		if err := os.WriteFile(logPath, []byte(compileLog), 0644); err != nil {
+			logger.Warn("failed to save compile log for manual fix", logger.Err(err))
 		}
+	}
 
-		endEnumerateItemPattern := regexp.MustCompile(`(\\end\{enumerate\})\s*(\\item\b)`)
-		if endEnumerateItemPattern.MatchString(fixed) {
-			fixed = endEnumerateItemPattern.ReplaceAllString(fixed, "$1\n$2")
-			anyFixed = true
-			logger.Debug("rule-based fix: separated \\end{enumerate} and \\item onto different lines")
+	// Point to the library copy when there is one; that is what ReprocessFromTranslatedTex compiles
+	editPath := translatedTexPath
+	if arxivID != "" && a.results != nil {
+		if relPath, err := filepath.Rel(sourceInfo.ExtractDir, translatedTexPath); err == nil {
+			editPath = filepath.Join(a.results.GetLatexSourceDir(arxivID), relPath)
+			logPath = filepath.Join(filepath.Dir(editPath), manualFixLogName)
 		}
+	}
 
-		endDescriptionItemPattern := regexp.MustCompile(`(\\end\{description\})\s*(\\item\b)`)
-		if endDescriptionItemPattern.MatchString(fixed) {
-			fixed = endDescriptionItemPattern.ReplaceAllString(fixed, "$1\n$2")
-			anyFixed = true
-			logger.Debug("rule-based fix: separated \\end{description} and \\item onto different lines")
+	hint := fmt.Sprintf("请手动修复 %s（编译日志: %s），然后在结果列表中点击“重新编译”", editPath, logPath)
+	a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusNeedsManualFix, hint, originalPDF, "")
+
+	logger.Info("job finished in manual-fix state",
+		logger.String("arxivID", arxivID),
+		logger.String("translatedTex", editPath),
+		logger.String("compileLog", logPath))
+	a.updateStatusError("已跳过剩余修复，需要手动修复: " + hint)
+	return types.NewAppErrorWithDetails(types.ErrNeedsManualFix, "已跳过剩余修复，需要手动修复", hint, nil)
+}
+
+// finishCancelled ends a cancelled job without discarding its work: the source, the
+// translation checkpoint, any translated tex and the original PDF are saved to the library
+// as a cancelled entry, which ContinueTranslation resumes. It returns the ErrCancelled error
+// whose details list the preserved artifacts.
+func (a *App) finishCancelled(arxivID, title, input string, sourceInfo *types.SourceInfo, originalPDF string) error {
+	logger.Warn("processing cancelled, keeping partial result", logger.String("arxivID", arxivID))
+	artifacts := a.saveResumable(arxivID, title, input, sourceInfo, originalPDF, "已取消（可继续）")
+	a.updateStatusError("已取消（可继续）")
+	return types.NewAppErrorWithDetails(types.ErrCancelled, "已取消（可继续）", strings.Join(artifacts, "\n"), context.Canceled)
+}
+
+// finishBudgetExceeded ends a job whose translation ran out of its time or token budget
+// like a cancelled one: the finished part is saved as a cancelled entry, which
+// ContinueTranslation resumes with a new budget. It returns the ErrBudgetExceeded error
+// whose details give the limit reached and list the preserved artifacts.
+func (a *App) finishBudgetExceeded(arxivID, title, input string, sourceInfo *types.SourceInfo, originalPDF string, cause error) error {
+	logger.Warn("translation budget exceeded, keeping partial result", logger.String("arxivID", arxivID), logger.Err(cause))
+	message := "已达到翻译预算（可继续）"
+	artifacts := a.saveResumable(arxivID, title, input, sourceInfo, originalPDF, message)
+	if appErr, ok := cause.(*types.AppError); ok && appErr.Details != "" {
+		artifacts = append([]string{appErr.Details}, artifacts...)
+	}
+	a.updateStatusError(message)
+	return types.NewAppErrorWithDetails(types.ErrBudgetExceeded, message, strings.Join(artifacts, "\n"), cause)
+}
+
+// saveResumable saves a job stopped before it finished to the library as a cancelled entry
+// with the given message and returns the descriptions of the preserved artifacts
+func (a *App) saveResumable(arxivID, title, input string, sourceInfo *types.SourceInfo, originalPDF, message string) []string {
+	var artifacts []string
+	if arxivID != "" && a.results != nil {
+		a.saveIntermediateResult(arxivID, title, input, sourceInfo, results.StatusCancelled, message, originalPDF, "")
+		if info, err := a.results.LoadPaperInfo(arxivID); err == nil {
+			if info.OriginalPDF != "" {
+				artifacts = append(artifacts, "原始 PDF: "+info.OriginalPDF)
+			}
+			if info.SourceDir != "" {
+				artifacts = append(artifacts, "LaTeX 源码: "+info.SourceDir)
+				if checkpoint := results.LoadCheckpoint(info.SourceDir); checkpoint != nil {
+					artifacts = append(artifacts, fmt.Sprintf("已翻译文件: %d 个 (%s)", len(checkpoint.Files), results.CheckpointPath(info.SourceDir)))
+				}
+				if info.MainTexFile != "" {
+					translatedTex := filepath.Join(info.SourceDir, "translated_"+info.MainTexFile)
+					if _, err := os.Stat(translatedTex); err == nil {
+						artifacts = append(artifacts, "译文: "+translatedTex)
+					}
+				}
+			}
+		}
+	} else if sourceInfo != nil {
+		// Nothing to save the entry under; point to the work directory instead
+		if originalPDF != "" {
+			artifacts = append(artifacts, "原始 PDF: "+originalPDF)
 		}
+		artifacts = append(artifacts, "LaTeX 源码: "+sourceInfo.ExtractDir)
 	}
+	return artifacts
+}
 
-	// Rule 6: Fix \end{...}\begin{...} on same line
-	endBeginPattern := regexp.MustCompile(`(\\end\{[^}]+\})\s*(\\begin\{[^}]+\})`)
-	if endBeginPattern.MatchString(fixed) {
-		fixed = endBeginPattern.ReplaceAllString(fixed, "$1\n$2")
-		anyFixed = true
-		logger.Debug("rule-based fix: separated \\end and \\begin onto different lines")
+// ReprocessFromTranslatedTex recompiles a paper from its saved (hand-edited) translated LaTeX
+// without translating again. It is the next step for papers in the "needs manual fix" state.
+func (a *App) ReprocessFromTranslatedTex(arxivID string) (*types.ProcessResult, error) {
+	logger.Info("ReprocessFromTranslatedTex called", logger.String("arxivID", arxivID))
+
+	if arxivID == "" {
+		return nil, types.NewAppError(types.ErrInvalidInput, "arXiv ID 不能为空", nil)
+	}
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
 	}
 
-	// Rule 7: Fix \end{...}\section on same line
-	endSectionPattern := regexp.MustCompile(`(\\end\{[^}]+\})\s*(\\(?:section|subsection|subsubsection|paragraph|chapter)\b)`)
-	if endSectionPattern.MatchString(fixed) {
-		fixed = endSectionPattern.ReplaceAllString(fixed, "$1\n\n$2")
-		anyFixed = true
-		logger.Debug("rule-based fix: separated \\end and section command onto different lines")
+	info, err := a.results.LoadPaperInfo(arxivID)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "论文不存在", err)
+	}
+	if !info.HasLatexSource || info.SourceDir == "" || info.MainTexFile == "" {
+		return nil, types.NewAppError(types.ErrFileNotFound, "没有保存的 LaTeX 源码", nil)
 	}
 
-	// Rule 8: Fix \begin{} without matching \end{}
-	beginPattern := regexp.MustCompile(`\\begin\{([^}]+)\}`)
-	endPattern := regexp.MustCompile(`\\end\{([^}]+)\}`)
-	beginMatches := beginPattern.FindAllStringSubmatch(fixed, -1)
-	endMatches := endPattern.FindAllStringSubmatch(fixed, -1)
+	translatedRel := filepath.Join(filepath.Dir(info.MainTexFile), "translated_"+filepath.Base(info.MainTexFile))
+	if _, err := os.Stat(filepath.Join(info.SourceDir, translatedRel)); err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "未找到翻译后的 tex 文件", err)
+	}
 
-	beginCounts := make(map[string]int)
-	endCounts := make(map[string]int)
-	for _, m := range beginMatches {
-		beginCounts[m[1]]++
+	// Compile a copy so a failed attempt does not touch the library files
+	workDir := filepath.Join(workdir.PaperDir(a.workDir, results.SanitizeFileName(arxivID)), fmt.Sprintf("reprocess_%d", time.Now().Unix()))
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "创建工作目录失败", err)
 	}
-	for _, m := range endMatches {
-		endCounts[m[1]]++
+	if err := copyDir(info.SourceDir, workDir); err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "复制源文件失败", err)
 	}
 
-	for env, count := range beginCounts {
-		if endCounts[env] < count {
-			for i := 0; i < count-endCounts[env]; i++ {
-				fixed += fmt.Sprintf("\n\\end{%s}", env)
-				anyFixed = true
-				logger.Debug("rule-based fix: added missing \\end", logger.String("env", env))
-			}
-		}
+	sourceInfo := &types.SourceInfo{
+		ExtractDir:  workDir,
+		MainTexFile: info.MainTexFile,
+		SourceType:  types.SourceTypeArxivID,
+		OriginalRef: arxivID,
 	}
 
-	return fixed, anyFixed
+	return a.compileTranslatedDocument(a.ctx, sourceInfo, arxivID, info.Title, info.OriginalPDF,
+		filepath.Join(workDir, translatedRel), filepath.Join(workDir, "output_translated"))
 }
 
-// containsChinese checks if a string contains Chinese characters
-func containsChinese(s string) bool {
-	for _, r := range s {
-		if r >= 0x4e00 && r <= 0x9fff {
-			return true
+// RetryFailedChunks translates again only the chunks of a library paper that kept their
+// original text, patches its saved translated tex files in place and recompiles the paper.
+// Chunks that fail again keep their markers and stay listed in the library entry.
+func (a *App) RetryFailedChunks(arxivID string) (*types.ProcessResult, error) {
+	logger.Info("RetryFailedChunks called", logger.String("arxivID", arxivID))
+
+	if arxivID == "" {
+		return nil, types.NewAppError(types.ErrInvalidInput, "arXiv ID 不能为空", nil)
+	}
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	if a.translator == nil {
+		return nil, types.NewAppError(types.ErrConfig, "翻译器未初始化，请先配置 API 密钥", nil)
+	}
+
+	info, err := a.results.LoadPaperInfo(arxivID)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "论文不存在", err)
+	}
+	if !info.HasLatexSource || info.SourceDir == "" {
+		return nil, types.NewAppError(types.ErrFileNotFound, "没有保存的 LaTeX 源码", nil)
+	}
+	failed := translator.FailedChunksInDir(info.SourceDir)
+	if len(failed) == 0 {
+		return nil, types.NewAppError(types.ErrInvalidInput, "这篇论文没有翻译失败的分块", nil)
+	}
+
+	files := make([]string, 0, len(failed))
+	for rel := range failed {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, rel := range files {
+		result, err := a.translator.RetryFailedChunks(ctx, filepath.Join(info.SourceDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		if len(result.FailedChunks) > 0 {
+			a.addWarning(fmt.Sprintf("%s: %s", rel, translator.FormatFailedChunksSummary(result)))
 		}
 	}
-	return false
-}
 
-// CancelProcess cancels the current processing operation.
-// It signals the processing goroutine to stop at the next cancellation check point.
-func (a *App) CancelProcess() error {
-	logger.Info("cancel process requested")
-	if a.cancelFunc != nil {
-		a.cancelFunc()
-		a.updateStatusError("已取消")
-		logger.Info("process cancelled successfully")
-		return nil
+	info.FailedChunks = translator.FailedChunksInDir(info.SourceDir)
+	if err := a.results.SavePaperInfo(info); err != nil {
+		logger.Warn("failed to save paper info", logger.Err(err))
 	}
-	logger.Warn("no process to cancel")
-	return types.NewAppError(types.ErrInternal, "没有正在进行的处理", nil)
+	return a.ReprocessFromTranslatedTex(arxivID)
 }
 
 // GetPaperCategories returns all available paper categories for the frontend.
@@ -2005,36 +5001,58 @@ func (a *App) GetSettings() *types.Config {
 	}
 
 	logger.Debug("returning masked key", logger.String("maskedKey", maskedKey))
+	requestShape := a.config.GetRequestShape()
 
 	return &types.Config{
-		OpenAIAPIKey:       maskedKey,
-		OpenAIBaseURL:      cfg.OpenAIBaseURL,
-		OpenAIModel:        cfg.OpenAIModel,
-		ContextWindow:      cfg.ContextWindow,
-		DefaultCompiler:    cfg.DefaultCompiler,
-		WorkDirectory:      cfg.WorkDirectory,
-		Concurrency:        cfg.Concurrency,
-		GitHubToken:        maskedGitHubToken,
-		GitHubOwner:        cfg.GitHubOwner,
-		GitHubRepo:         cfg.GitHubRepo,
-		LibraryPageSize:    cfg.LibraryPageSize,
-		SharePromptEnabled: cfg.SharePromptEnabled,
-	}
-}
-
-// TestAPIConnection tests the API connection with the provided settings.
-// Returns nil if successful, or an error message if the test fails.
-func (a *App) TestAPIConnection(apiKey, baseURL, model string) error {
-	logger.Info("testing API connection", logger.String("baseURL", baseURL), logger.String("model", model))
+		OpenAIAPIKey:          maskedKey,
+		OpenAIBaseURL:         cfg.OpenAIBaseURL,
+		OpenAIModel:           cfg.OpenAIModel,
+		ContextWindow:         cfg.ContextWindow,
+		DefaultCompiler:       cfg.DefaultCompiler,
+		WorkDirectory:         cfg.WorkDirectory,
+		Concurrency:           cfg.Concurrency,
+		GitHubToken:           maskedGitHubToken,
+		GitHubOwner:           cfg.GitHubOwner,
+		GitHubRepo:            cfg.GitHubRepo,
+		LibraryPageSize:       cfg.LibraryPageSize,
+		SharePromptEnabled:    cfg.SharePromptEnabled,
+		ChineseVariant:        string(a.config.GetChineseVariant()),
+		MaxConcurrentCompiles: a.config.GetMaxConcurrentCompiles(),
+		Proxy:                 a.config.GetProxy(),
+		RequestShape:          &requestShape,
+		Provider:              a.config.GetProvider(),
+	}
+}
+
+// ConnectionTestResult reports how TestAPIConnection reached the API
+type ConnectionTestResult struct {
+	// ProxyUsed is set when the request went through a proxy, from the settings or
+	// from the HTTP_PROXY/HTTPS_PROXY environment variables
+	ProxyUsed bool `json:"proxy_used"`
+	// Proxy is the proxy URL without its password
+	Proxy string `json:"proxy,omitempty"`
+	// Adjustments describes the changes the server needed on the request body, such as
+	// max_tokens renamed to max_completion_tokens; empty when the settings worked as is
+	Adjustments []string `json:"adjustments,omitempty"`
+	// RequestShape is the request shape that worked, to be saved with the settings
+	RequestShape types.RequestShape `json:"request_shape"`
+}
+
+// TestAPIConnection tests the API connection with the provided settings, including the
+// proxy of the settings form (empty: the environment variables), its request shape and its
+// provider (openai or anthropic), and reports whether the request went through a proxy and which adjustments of the
+// request body the server needed.
+func (a *App) TestAPIConnection(apiKey, baseURL, model, proxy string, requestShape types.RequestShape, provider string) (*ConnectionTestResult, error) {
+	logger.Info("testing API connection", logger.String("provider", provider), logger.String("baseURL", baseURL), logger.String("model", model))
 
 	if apiKey == "" {
-		return types.NewAppError(types.ErrConfig, "API Key 不能为空", nil)
+		return nil, types.NewAppError(types.ErrConfig, "API Key 不能为空", nil)
 	}
 	if baseURL == "" {
-		return types.NewAppError(types.ErrConfig, "API Base URL 不能为空", nil)
+		return nil, types.NewAppError(types.ErrConfig, "API Base URL 不能为空", nil)
 	}
 	if model == "" {
-		return types.NewAppError(types.ErrConfig, "模型名称不能为空", nil)
+		return nil, types.NewAppError(types.ErrConfig, "模型名称不能为空", nil)
 	}
 
 	// If apiKey starts with asterisks (masked key), use the existing key from config
@@ -2044,29 +5062,52 @@ func (a *App) TestAPIConnection(apiKey, baseURL, model string) error {
 			actualKey = a.config.GetAPIKey()
 		}
 		if actualKey == "" {
-			return types.NewAppError(types.ErrConfig, "请输入新?API Key", nil)
+			return nil, types.NewAppError(types.ErrConfig, "请输入新?API Key", nil)
 		}
 		logger.Debug("using existing API key for test", logger.Int("keyLength", len(actualKey)))
 	}
 
+	transport, err := netproxy.NewTransportWithProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	result := &ConnectionTestResult{}
+	if req, err := http.NewRequest(http.MethodPost, baseURL, nil); err == nil {
+		if proxyURL, _ := transport.Proxy(req); proxyURL != nil {
+			result.ProxyUsed = true
+			result.Proxy = proxyURL.Redacted()
+		}
+	}
+
 	// Create a test translator with the provided settings (30 second timeout)
 	testTranslator := translator.NewTranslationEngineWithConfig(actualKey, model, baseURL, 30*time.Second, 1)
+	testTranslator.SetTransport(transport)
+	testTranslator.SetRequestShape(requestShape)
+	testTranslator.SetProvider(provider)
 
 	// Use the dedicated test connection method
-	err := testTranslator.TestConnection()
+	result.Adjustments, err = testTranslator.TestConnectionWithAdjustments()
+	result.RequestShape = testTranslator.RequestShape()
 	if err != nil {
-		logger.Error("API connection test failed", err)
-		return err
+		logger.Error("API connection test failed", err, logger.Bool("proxyUsed", result.ProxyUsed), logger.String("proxy", result.Proxy))
+		if appErr, ok := err.(*types.AppError); ok && appErr.Code == types.ErrNetwork && result.ProxyUsed {
+			return nil, types.NewAppErrorWithDetails(types.ErrNetwork, "API 连接失败（经代理 "+result.Proxy+"），请检查代理设置", appErr.Details, appErr)
+		}
+		return nil, err
 	}
 
-	logger.Info("API connection test successful")
-	return nil
+	logger.Info("API connection test successful",
+		logger.Bool("proxyUsed", result.ProxyUsed),
+		logger.String("proxy", result.Proxy),
+		logger.String("adjustments", strings.Join(result.Adjustments, "; ")))
+	return result, nil
 }
 
 // SaveSettings saves the application settings from the frontend.
 // This method is exposed to the frontend via Wails bindings.
-func (a *App) SaveSettings(apiKey, baseURL, model string, contextWindow int, compiler, workDir string, concurrency int, githubToken, githubOwner, githubRepo string, libraryPageSize int, sharePromptEnabled bool) error {
+func (a *App) SaveSettings(apiKey, baseURL, model string, contextWindow int, compiler, workDir string, concurrency int, githubToken, githubOwner, githubRepo string, libraryPageSize int, sharePromptEnabled bool, proxy string, requestShape types.RequestShape, provider string) error {
 	logger.Info("saving settings from frontend",
+		logger.String("provider", provider),
 		logger.String("baseURL", baseURL),
 		logger.String("model", model),
 		logger.Int("concurrency", concurrency),
@@ -2096,6 +5137,18 @@ func (a *App) SaveSettings(apiKey, baseURL, model string, contextWindow int, com
 		logger.Error("failed to update config", err)
 		return err
 	}
+	if err := a.config.SetProxy(proxy); err != nil {
+		logger.Error("failed to save proxy", err)
+		return err
+	}
+	if err := a.config.SetRequestShape(requestShape); err != nil {
+		logger.Error("failed to save request shape", err)
+		return err
+	}
+	if err := a.config.SetProvider(provider); err != nil {
+		logger.Error("failed to save provider", err)
+		return err
+	}
 
 	// Save GitHub token to config (not settings.json anymore)
 	if githubToken != "" && !strings.HasPrefix(githubToken, "****") {
@@ -2210,26 +5263,44 @@ func (a *App) ClearInputHistory() {
 	a.config.ClearInputHistory()
 }
 
-// GetPDFDataURL reads a PDF file and returns it as a data URL for display in iframe.
+// GetPDFDataURL returns a URL for displaying a PDF file in an iframe.
 // This is needed because Wails WebView doesn't allow loading file:// URLs directly.
+// Files up to pdfserve.DataURLLimit are returned as data URLs; larger files are
+// registered with the asset server and returned as /pdf/token/<token> URLs, which the
+// viewer loads lazily with range requests instead of base64-encoding the whole file
+// over the bridge.
 func (a *App) GetPDFDataURL(pdfPath string) (string, error) {
 	if pdfPath == "" {
 		return "", types.NewAppError(types.ErrInvalidInput, "PDF 路径为空", nil)
 	}
 
-	// Read the PDF file
-	data, err := os.ReadFile(pdfPath)
+	url, err := a.pdfFiles.URL(pdfPath, pdfserve.DataURLLimit)
 	if err != nil {
 		logger.Error("failed to read PDF file", err, logger.String("path", pdfPath))
-		return "", types.NewAppError(types.ErrFileNotFound, "无法读取 PDF 文件", err)
+		return "", err
 	}
 
-	// Encode as base64 data URL
-	encoded := base64.StdEncoding.EncodeToString(data)
-	dataURL := "data:application/pdf;base64," + encoded
+	logger.Debug("PDF URL ready",
+		logger.String("path", pdfPath),
+		logger.Bool("dataURL", strings.HasPrefix(url, "data:")))
+	return url, nil
+}
+
+// PDFFiles returns the registry of PDFs served to the webview by token URL
+func (a *App) PDFFiles() *pdfserve.Registry {
+	return a.pdfFiles
+}
 
-	logger.Debug("PDF converted to data URL", logger.String("path", pdfPath), logger.Int("size", len(data)))
-	return dataURL, nil
+// pdfRoots returns the directories whose PDFs the webview may load by path
+func (a *App) pdfRoots() []string {
+	roots := []string{a.workDir}
+	if a.config != nil {
+		roots = append(roots, a.config.GetWorkDirectory())
+	}
+	if a.results != nil {
+		roots = append(roots, a.results.GetBaseDir())
+	}
+	return roots
 }
 
 // OpenPDFInSystem opens a PDF file using the system's default PDF viewer.
@@ -2259,8 +5330,19 @@ func (a *App) OpenURLInBrowser(url string) {
 type StartupCheckResult struct {
 	LaTeXInstalled bool   `json:"latex_installed"`
 	LaTeXVersion   string `json:"latex_version"`
+	// LatexmkInstalled means documents are compiled with latexmk, which runs biber, makeindex
+	// and as many passes as they need; without it the passes are run one by one
+	LatexmkInstalled bool   `json:"latexmk_installed"`
+	LatexmkVersion   string `json:"latexmk_version"`
+	// CJKFontsAvailable means ctex finds a Chinese font: the one of its default fontset or
+	// CJKFont, which is then selected in the translated preamble
+	CJKFontsAvailable bool   `json:"cjk_fonts_available"`
+	CJKFont           string `json:"cjk_font"`
 	LLMConfigured  bool   `json:"llm_configured"`
 	LLMError       string `json:"llm_error"`
+	// NoCompileSuggested offers the no-compile mode: without LaTeX the translation can still
+	// be exported as tex and HTML
+	NoCompileSuggested bool `json:"no_compile_suggested"`
 }
 
 // CheckStartupRequirements checks if LaTeX is installed and LLM is properly configured.
@@ -2274,6 +5356,22 @@ func (a *App) CheckStartupRequirements() *StartupCheckResult {
 	logger.Info("LaTeX check result",
 		logger.Bool("installed", result.LaTeXInstalled),
 		logger.String("version", result.LaTeXVersion))
+	result.NoCompileSuggested = !result.LaTeXInstalled
+	if result.LaTeXInstalled {
+		result.LatexmkInstalled, result.LatexmkVersion = a.checkLatexmkInstallation()
+		logger.Info("latexmk check result",
+			logger.Bool("installed", result.LatexmkInstalled),
+			logger.String("version", result.LatexmkVersion))
+
+		fonts := compiler.CheckCJKFonts()
+		result.CJKFontsAvailable = fonts.Usable()
+		if !fonts.DefaultsUsable {
+			result.CJKFont = fonts.Best()
+		}
+		logger.Info("Chinese font check result",
+			logger.Bool("available", result.CJKFontsAvailable),
+			logger.String("font", result.CJKFont))
+	}
 
 	// Check LLM configuration
 	result.LLMConfigured, result.LLMError = a.checkLLMConfiguration()
@@ -2299,6 +5397,39 @@ func (a *App) checkLaTeXInstallation() (bool, string) {
 	return false, ""
 }
 
+// missingPackagesError returns the error of a compilation stopped by missing packages,
+// naming them and the command installing them
+func missingPackagesError(report *types.MissingPackagesReport) *types.AppError {
+	details := "缺少宏包: " + strings.Join(report.Packages, ", ")
+	if report.InstallCommand != "" {
+		details += "\n安装命令: " + report.InstallCommand
+	} else {
+		details += "\n请使用 TeX 发行版的包管理器安装后重试"
+	}
+	return types.NewAppErrorWithDetails(types.ErrMissingPackages, "LaTeX 宏包未安装", details, nil)
+}
+
+// missingFontsError returns the error of a translation stopped because no Chinese font is
+// installed, with the ways to install one
+func missingFontsError() *types.AppError {
+	details := "ctex 默认字体及 Noto Sans CJK、Source Han Sans、SimSun、Fandol 均未找到\n安装方法:\n  " +
+		strings.Join(compiler.CJKFontInstallSuggestions(), "\n  ")
+	return types.NewAppErrorWithDetails(types.ErrMissingFonts, "未安装中文字体", details, nil)
+}
+
+// checkLatexmkInstallation checks if latexmk is installed, which the compiler then prefers
+// over running the passes one by one.
+func (a *App) checkLatexmkInstallation() (bool, string) {
+	if !compiler.LatexmkInstalled() {
+		return false, ""
+	}
+	version, err := a.getCompilerVersion("latexmk")
+	if err != nil {
+		return true, ""
+	}
+	return true, version
+}
+
 // getCompilerVersion gets the version string of a LaTeX compiler.
 func (a *App) getCompilerVersion(compiler string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -2316,10 +5447,11 @@ func (a *App) getCompilerVersion(compiler string) (string, error) {
 		return "", err
 	}
 
-	// Extract first line as version info
-	lines := strings.Split(string(output), "\n")
-	if len(lines) > 0 {
-		return strings.TrimSpace(lines[0]), nil
+	// Extract first line as version info (latexmk starts with an empty line)
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
 	}
 
 	return "", fmt.Errorf("no version output")
@@ -2347,7 +5479,7 @@ func (a *App) checkLLMConfiguration() (bool, string) {
 	}
 
 	// Test the connection
-	err := a.TestAPIConnection(apiKey, baseURL, model)
+	_, err := a.TestAPIConnection(apiKey, baseURL, model, a.config.GetProxy(), a.config.GetRequestShape(), a.config.GetProvider())
 	if err != nil {
 		return false, err.Error()
 	}
@@ -2384,7 +5516,7 @@ func (a *App) DownloadChinesePDF() (string, error) {
 	// Generate default filename based on source ID
 	defaultFilename := "translated.pdf"
 	if a.lastResult.SourceID != "" {
-		defaultFilename = a.lastResult.SourceID + ".pdf"
+		defaultFilename = results.SanitizeFileName(a.lastResult.SourceID) + ".pdf"
 	}
 
 	// Open save dialog
@@ -2419,10 +5551,16 @@ func (a *App) DownloadChinesePDF() (string, error) {
 	return savePath, nil
 }
 
-// DownloadBilingualPDF saves the bilingual PDF (English left, Chinese right) to a user-selected location.
-// If the bilingual PDF was already generated during translation, it will be copied directly.
+// DownloadBilingualPDF saves the bilingual PDF to a user-selected location. layout is
+// "side-by-side" (English left, Chinese right; the default) or "interleaved" (English and
+// Chinese pages alternating, for printing on A4).
+// If the bilingual PDF was already generated in that layout during translation, it will be copied directly.
 // Otherwise, it will be generated on-demand using LaTeX.
-func (a *App) DownloadBilingualPDF() (string, error) {
+func (a *App) DownloadBilingualPDF(layout string) (string, error) {
+	bilingualLayout, err := types.ParseBilingualLayout(layout)
+	if err != nil {
+		return "", err
+	}
 	if a.lastResult == nil {
 		return "", types.NewAppError(types.ErrInvalidInput, "没有可下载的内容", nil)
 	}
@@ -2431,14 +5569,21 @@ func (a *App) DownloadBilingualPDF() (string, error) {
 	}
 
 	// Generate default filename based on source ID with _biling suffix
+	title := "保存中英对照 PDF"
 	defaultFilename := "bilingual.pdf"
+	suffix := "_biling.pdf"
+	if bilingualLayout == types.BilingualInterleaved {
+		title = "保存中英交替页 PDF"
+		defaultFilename = "bilingual_interleaved.pdf"
+		suffix = "_biling_interleaved.pdf"
+	}
 	if a.lastResult.SourceID != "" {
-		defaultFilename = a.lastResult.SourceID + "_biling.pdf"
+		defaultFilename = results.SanitizeFileName(a.lastResult.SourceID) + suffix
 	}
 
 	// Open save dialog
 	savePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
-		Title:           "保存中英对照 PDF",
+		Title:           title,
 		DefaultFilename: defaultFilename,
 		Filters: []runtime.FileFilter{
 			{DisplayName: "PDF 文件 (*.pdf)", Pattern: "*.pdf"},
@@ -2452,8 +5597,8 @@ func (a *App) DownloadBilingualPDF() (string, error) {
 		return "", nil // User cancelled
 	}
 
-	// Check if bilingual PDF already exists (generated during translation)
-	if a.lastResult.BilingualPDFPath != "" {
+	// Check if bilingual PDF already exists (generated during translation in the session's layout)
+	if bilingualLayout == a.bilingualPDFLayout() && a.lastResult.BilingualPDFPath != "" {
 		if _, err := os.Stat(a.lastResult.BilingualPDFPath); err == nil {
 			// Copy the existing bilingual PDF
 			srcData, err := os.ReadFile(a.lastResult.BilingualPDFPath)
@@ -2473,9 +5618,11 @@ func (a *App) DownloadBilingualPDF() (string, error) {
 	// Bilingual PDF not available, generate it on-demand
 	logger.Info("generating bilingual PDF on-demand")
 	generator := pdf.NewPDFGenerator(a.workDir)
+	// Chinese translations run shorter; pad pages so each section starts on the same spread
+	generator.AlignBySections = true
 
-	// Generate side-by-side PDF: English (original) on left, Chinese (translated) on right
-	err = generator.GenerateSideBySidePDF(
+	// Generate the bilingual PDF: English (original) left or first, Chinese (translated) right or second
+	err = generateBilingualPDF(generator, bilingualLayout,
 		a.lastResult.OriginalPDFPath,   // Left: English original
 		a.lastResult.TranslatedPDFPath, // Right: Chinese translation
 		savePath,
@@ -2490,6 +5637,14 @@ func (a *App) DownloadBilingualPDF() (string, error) {
 	return savePath, nil
 }
 
+// generateBilingualPDF generates a bilingual PDF in the given layout
+func generateBilingualPDF(generator *pdf.PDFGenerator, layout types.BilingualLayout, originalPDF, translatedPDF, outputPath string) error {
+	if layout == types.BilingualInterleaved {
+		return generator.GenerateInterleavedPDF(originalPDF, translatedPDF, outputPath)
+	}
+	return generator.GenerateSideBySidePDF(originalPDF, translatedPDF, outputPath)
+}
+
 // downloadBilingualPDFAsZip is a fallback method that creates a zip with both PDFs
 // when LaTeX-based side-by-side generation fails (e.g., LaTeX not installed)
 func (a *App) downloadBilingualPDFAsZip(originalSavePath string) (string, error) {
@@ -2547,7 +5702,7 @@ func (a *App) DownloadLatexZip() (string, error) {
 	// Generate default filename based on source ID
 	defaultFilename := "translated_latex.zip"
 	if a.lastResult.SourceID != "" {
-		defaultFilename = a.lastResult.SourceID + "_latex.zip"
+		defaultFilename = results.SanitizeFileName(a.lastResult.SourceID) + "_latex.zip"
 	}
 
 	// Open save dialog
@@ -2631,6 +5786,9 @@ func (a *App) LoadPDF(filePath string) (*pdf.PDFInfo, error) {
 		return nil, types.NewAppError(types.ErrInternal, "PDF 翻译器未初始化", nil)
 	}
 
+	// The viewer shows the opened PDF by its path, wherever it is
+	a.pdfFiles.Allow(filePath)
+	a.pdfSource = filePath
 	return a.pdfTranslator.LoadPDF(filePath)
 }
 
@@ -2644,7 +5802,21 @@ func (a *App) TranslatePDF() (*pdf.TranslationResult, error) {
 		return nil, types.NewAppError(types.ErrInternal, "PDF 翻译器未初始化", nil)
 	}
 
-	return a.pdfTranslator.TranslatePDF()
+	started := time.Now()
+	result, err := a.pdfTranslator.TranslatePDF()
+	payload := hooks.Payload{
+		Source:          a.pdfSource,
+		Mode:            hooks.ModePDF,
+		DurationSeconds: time.Since(started).Seconds(),
+	}
+	if result != nil {
+		result.EstimatedCost = a.estimateCost(result.Model, result.InputTokens, result.OutputTokens)
+		payload.OriginalPDF = result.OriginalPDFPath
+		payload.TranslatedPDF = result.TranslatedPDFPath
+		payload.TokensUsed = result.TokensUsed
+	}
+	a.notifyCompletion(payload, err)
+	return result, err
 }
 
 // GetPDFStatus returns the current PDF translation status.
@@ -2720,6 +5892,140 @@ func (a *App) DeleteTranslatedPaper(arxivID string) error {
 	return nil
 }
 
+// GetLibraryPapers returns page (1-based) of the papers of the library whose title, arXiv ID
+// or file name matches query, newest first, with the disk space of each. A pageSize of 0
+// uses the page size of the settings.
+func (a *App) GetLibraryPapers(page, pageSize int, query string) (*results.LibraryPage, error) {
+	logger.Debug("GetLibraryPapers called", logger.Int("page", page), logger.Int("pageSize", pageSize), logger.String("query", query))
+
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	if pageSize <= 0 {
+		pageSize = config.DefaultLibraryPageSize
+		if a.config != nil && a.config.GetLibraryPageSize() > 0 {
+			pageSize = a.config.GetLibraryPageSize()
+		}
+	}
+
+	library, err := a.results.SearchPapers(page, pageSize, query)
+	if err != nil {
+		logger.Error("failed to list papers", err)
+		return nil, types.NewAppError(types.ErrInternal, "获取论文列表失败", err)
+	}
+	return library, nil
+}
+
+// DeleteLibraryPaper deletes a paper of the library with all its files
+func (a *App) DeleteLibraryPaper(arxivID string) error {
+	logger.Info("DeleteLibraryPaper called", logger.String("arxivID", arxivID))
+
+	if a.results == nil {
+		return types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	if arxivID == "" {
+		return types.NewAppError(types.ErrInvalidInput, "arXiv ID 不能为空", nil)
+	}
+	if !a.results.PaperExists(arxivID) {
+		return types.NewAppError(types.ErrFileNotFound, fmt.Sprintf("论文 %s 不在结果库中", arxivID), nil)
+	}
+	return a.DeleteTranslatedPaper(arxivID)
+}
+
+// GetLibraryStats returns the number of papers of the library and its disk usage
+func (a *App) GetLibraryStats() (*results.LibraryStats, error) {
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	stats, err := a.results.Stats()
+	if err != nil {
+		logger.Error("failed to compute library stats", err)
+		return nil, types.NewAppError(types.ErrInternal, "获取结果库统计失败", err)
+	}
+	return stats, nil
+}
+
+// ExportLibrary asks for a zip file and exports the papers with the given IDs, or the
+// whole library when arxivIDs is empty, to it. It returns the path of the archive, or an
+// empty string if the dialog was cancelled.
+func (a *App) ExportLibrary(arxivIDs []string) (string, error) {
+	logger.Info("ExportLibrary called", logger.Int("papers", len(arxivIDs)))
+
+	savePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "导出结果库",
+		DefaultFilename: fmt.Sprintf("latex-translator-library-%s.zip", time.Now().Format("20060102")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "ZIP 文件 (*.zip)", Pattern: "*.zip"},
+		},
+	})
+	if err != nil {
+		logger.Error("save dialog error", err)
+		return "", types.NewAppError(types.ErrInternal, "打开保存对话框失败", err)
+	}
+	if savePath == "" {
+		return "", nil // User cancelled
+	}
+
+	if _, err := a.ExportLibraryTo(savePath, arxivIDs); err != nil {
+		return "", err
+	}
+	return savePath, nil
+}
+
+// ExportLibraryTo exports the papers with the given IDs, or the whole library when
+// arxivIDs is empty, to a zip archive at outputZip
+func (a *App) ExportLibraryTo(outputZip string, arxivIDs []string) (*results.LibraryManifest, error) {
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	manifest, err := a.results.ExportLibrary(outputZip, arxivIDs)
+	if err != nil {
+		logger.Error("failed to export library", err, logger.String("path", outputZip))
+		return nil, types.NewAppError(types.ErrInternal, "导出结果库失败", err)
+	}
+	logger.Info("library exported", logger.String("path", outputZip), logger.Int("papers", len(manifest.Papers)))
+	return manifest, nil
+}
+
+// ImportLibrary asks for a library archive written by ExportLibrary and imports its
+// papers. Papers already in the library are skipped unless overwrite is set. It returns
+// nil if the dialog was cancelled.
+func (a *App) ImportLibrary(overwrite bool) (*results.ImportReport, error) {
+	logger.Info("ImportLibrary called", logger.Bool("overwrite", overwrite))
+
+	zipPath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "导入结果库",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "ZIP 文件 (*.zip)", Pattern: "*.zip"},
+		},
+	})
+	if err != nil {
+		logger.Error("open dialog error", err)
+		return nil, types.NewAppError(types.ErrInternal, "打开文件对话框失败", err)
+	}
+	if zipPath == "" {
+		return nil, nil // User cancelled
+	}
+	return a.ImportLibraryFrom(zipPath, overwrite)
+}
+
+// ImportLibraryFrom imports the papers of the library archive at zipPath
+func (a *App) ImportLibraryFrom(zipPath string, overwrite bool) (*results.ImportReport, error) {
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+	report, err := a.results.ImportLibrary(zipPath, overwrite)
+	if err != nil {
+		logger.Error("failed to import library", err, logger.String("path", zipPath))
+		return nil, types.NewAppErrorWithDetails(types.ErrInvalidInput, "导入结果库失败", err.Error(), err)
+	}
+	logger.Info("library imported", logger.String("path", zipPath),
+		logger.Int("imported", report.Imported),
+		logger.Int("skipped", report.Skipped),
+		logger.Int("failed", report.Failed))
+	return report, nil
+}
+
 // RetranslateFromArxiv re-downloads and re-translates a paper from arXiv
 func (a *App) RetranslateFromArxiv(arxivID string) (*types.ProcessResult, error) {
 	logger.Info("RetranslateFromArxiv called", logger.String("arxivID", arxivID))
@@ -2739,6 +6045,93 @@ func (a *App) RetranslateFromArxiv(arxivID string) (*types.ProcessResult, error)
 	return a.ProcessSource(arxivID)
 }
 
+// UpgradeToFullTranslation re-translates a paper stored from a quick-mode translation in
+// full quality, replacing the quick-mode result. Quick mode is off for this job regardless
+// of the current setting.
+func (a *App) UpgradeToFullTranslation(arxivID string) (*types.ProcessResult, error) {
+	logger.Info("UpgradeToFullTranslation called", logger.String("arxivID", arxivID))
+
+	if arxivID == "" {
+		return nil, types.NewAppError(types.ErrInvalidInput, "arXiv ID 不能为空", nil)
+	}
+
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+
+	info, err := a.results.LoadPaperInfo(arxivID)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "论文不存在", err)
+	}
+	if info.TranslationMode != results.TranslationModeQuick {
+		return nil, types.NewAppError(types.ErrInvalidInput, "该译文不是快速模式译文，无需升级", nil)
+	}
+
+	if a.IsQuickMode() {
+		a.SetQuickMode(false)
+		defer a.SetQuickMode(true)
+	}
+
+	input := info.OriginalInput
+	if input == "" {
+		input = info.ArxivID
+	}
+	return a.ProcessSourceWithForce(input, true)
+}
+
+// TranslateNewVersion translates the latest arXiv version of a previously translated paper.
+// Paragraphs that are unchanged since the stored translation reuse it verbatim (including
+// manual corrections); only changed and new paragraphs are sent to the LLM.
+func (a *App) TranslateNewVersion(arxivID string) (*types.ProcessResult, error) {
+	logger.Info("TranslateNewVersion called", logger.String("arxivID", arxivID))
+
+	if arxivID == "" {
+		return nil, types.NewAppError(types.ErrInvalidInput, "arXiv ID 不能为空", nil)
+	}
+
+	if a.results == nil {
+		return nil, types.NewAppError(types.ErrInternal, "结果管理器未初始化", nil)
+	}
+
+	info, err := a.results.LoadPaperInfo(arxivID)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "论文不存在", err)
+	}
+	if a.variantMismatch(info) {
+		return nil, a.variantMismatchError(info)
+	}
+
+	// Load the previous translation into memory before ProcessSource overwrites the stored files
+	pairs, err := a.results.LoadTranslationPairs(arxivID)
+	if err != nil || len(pairs) == 0 {
+		return nil, types.NewAppError(types.ErrFileNotFound, "未找到可复用的旧版本译文", err)
+	}
+	logger.Info("loaded previous version translation", logger.Int("files", len(pairs)))
+
+	a.referenceTranslations = pairs
+	defer func() {
+		a.referenceTranslations = nil
+	}()
+
+	// Download the latest version: drop any explicit version suffix (e.g. "v1")
+	input := arxivVersionSuffixPattern.ReplaceAllString(info.ArxivID, "")
+	return a.ProcessSource(input)
+}
+
+// variantMismatchError is returned when a stored translation in the other script would be reused
+func (a *App) variantMismatchError(info *results.PaperInfo) error {
+	stored := types.NormalizeChineseVariant(info.ChineseVariant)
+	return types.NewAppErrorWithDetails(
+		types.ErrInvalidInput,
+		fmt.Sprintf("已有译文为%s，与当前设置（%s）不一致", stored.DisplayName(), a.chineseVariant().DisplayName()),
+		"请重新翻译，或在设置中切换回"+stored.DisplayName(),
+		nil,
+	)
+}
+
+// arxivVersionSuffixPattern matches an explicit version suffix of an arXiv ID
+var arxivVersionSuffixPattern = regexp.MustCompile(`v\d+$`)
+
 // ContinueTranslation continues a previously failed or incomplete translation
 // It uses the saved source files and tries to continue from where it left off
 // The method intelligently resumes from the last successful phase based on saved status
@@ -2760,6 +6153,12 @@ func (a *App) ContinueTranslation(arxivID string) (*types.ProcessResult, error)
 		return nil, types.NewAppError(types.ErrFileNotFound, "论文不存在", err)
 	}
 
+	// Continuing would mix simplified and traditional text
+	if a.variantMismatch(info) {
+		return nil, a.variantMismatchError(info)
+	}
+	defer a.trackJob(results.ResumeEntry{LibraryID: arxivID})()
+
 	// Check if we have source files to continue from
 	if !info.HasLatexSource || info.SourceDir == "" {
 		logger.Info("no source files available, re-downloading from original input")
@@ -2786,7 +6185,7 @@ func (a *App) ContinueTranslation(arxivID string) (*types.ProcessResult, error)
 		logger.String("status", string(info.Status)))
 
 	// Copy source to work directory for processing
-	workDir := filepath.Join(a.workDir, fmt.Sprintf("continue_%s_%d", arxivID, time.Now().Unix()))
+	workDir := filepath.Join(workdir.PaperDir(a.workDir, results.SanitizeFileName(arxivID)), fmt.Sprintf("continue_%d", time.Now().Unix()))
 	if err := os.MkdirAll(workDir, 0755); err != nil {
 		return nil, types.NewAppError(types.ErrInternal, "创建工作目录失败", err)
 	}
@@ -2812,9 +6211,21 @@ func (a *App) ContinueTranslation(arxivID string) (*types.ProcessResult, error)
 		}
 		sourceInfo.MainTexFile = mainTexFile
 	}
+	a.journal.SetWorkDir(workDir, sourceInfo.MainTexFile)
+
+	// A cancelled job resumes after the last phase it finished: compiling when the translated
+	// tex was saved, otherwise translating (reusing the checkpointed files)
+	status := info.Status
+	if status == results.StatusCancelled {
+		status = results.StatusOriginalCompiled
+		if _, err := os.Stat(filepath.Join(workDir, "translated_"+sourceInfo.MainTexFile)); err == nil {
+			status = results.StatusTranslated
+		}
+	}
 
 	// Continue processing based on status - intelligently resume from last successful phase
-	return a.continueProcessingFromStatus(sourceInfo, arxivID, info.Title, info.Status, info.OriginalPDF)
+	a.beginUsage(info)
+	return a.continueProcessingFromStatus(sourceInfo, arxivID, info.Title, status, info.OriginalPDF)
 }
 
 // continueProcessingFromStatus continues processing based on the saved status
@@ -2825,6 +6236,9 @@ func (a *App) continueProcessingFromStatus(sourceInfo *types.SourceInfo, arxivID
 	defer func() {
 		a.cancelFunc = nil
 	}()
+	a.statusMu.Lock()
+	a.lastLogDiff = nil
+	a.statusMu.Unlock()
 
 	mainTexPath := filepath.Join(sourceInfo.ExtractDir, sourceInfo.MainTexFile)
 	translatedTexPath := filepath.Join(sourceInfo.ExtractDir, "translated_"+sourceInfo.MainTexFile)
@@ -2833,6 +6247,9 @@ func (a *App) continueProcessingFromStatus(sourceInfo *types.SourceInfo, arxivID
 
 	var originalPDFPath string
 
+	// The original may have been compiled already; keep handling \includeonly as it was
+	a.includeOnly = detectIncludeOnly(mainTexPath)
+
 	logger.Info("continuing from status",
 		logger.String("status", string(status)),
 		logger.String("savedOriginalPDF", savedOriginalPDF))
@@ -2851,12 +6268,16 @@ func (a *App) continueProcessingFromStatus(sourceInfo *types.SourceInfo, arxivID
 			} else {
 				// Compile original document first
 				a.updateStatus(types.PhaseCompiling, 30, "编译原始文档...")
-				originalResult, err := a.compiler.Compile(mainTexPath, originalOutputDir)
+				originalResult, err := a.compiler.CompileContext(ctx, mainTexPath, originalOutputDir)
+				if ctx.Err() != nil {
+					return nil, a.finishCancelled(arxivID, title, arxivID, sourceInfo, "")
+				}
 				if err != nil || !originalResult.Success {
 					errMsg := "原始文档编译失败"
 					if originalResult != nil && originalResult.ErrorMsg != "" {
 						errMsg = originalResult.ErrorMsg
 					}
+					a.emitCompileErrors(errors.StageOriginalCompile, originalResult)
 					a.updateStatusError(errMsg)
 					return nil, types.NewAppError(types.ErrCompile, errMsg, err)
 				}
@@ -2865,7 +6286,7 @@ func (a *App) continueProcessingFromStatus(sourceInfo *types.SourceInfo, arxivID
 			}
 
 			// Go directly to compile translated document
-			return a.compileTranslatedDocument(sourceInfo, arxivID, title, originalPDFPath, translatedTexPath, translatedOutputDir)
+			return a.compileTranslatedDocument(ctx, sourceInfo, arxivID, title, originalPDFPath, translatedTexPath, translatedOutputDir)
 		}
 		// Translated file doesn't exist, fall through to full processing
 		logger.Warn("translated file not found, starting from beginning")
@@ -2895,15 +6316,20 @@ func (a *App) continueProcessingFromStatus(sourceInfo *types.SourceInfo, arxivID
 		if err := compiler.PreprocessTexFiles(sourceInfo.ExtractDir); err != nil {
 			logger.Warn("preprocessing failed", logger.Err(err))
 		}
+		a.includeOnly = a.applyIncludeOnly(mainTexPath)
 
 		// Compile original document
 		a.updateStatus(types.PhaseCompiling, 30, "编译原始文档...")
-		originalResult, err := a.compiler.Compile(mainTexPath, originalOutputDir)
+		originalResult, err := a.compiler.CompileContext(ctx, mainTexPath, originalOutputDir)
+		if ctx.Err() != nil {
+			return nil, a.finishCancelled(arxivID, title, arxivID, sourceInfo, "")
+		}
 		if err != nil || !originalResult.Success {
 			errMsg := "原始文档编译失败"
 			if originalResult != nil && originalResult.ErrorMsg != "" {
 				errMsg = originalResult.ErrorMsg
 			}
+			a.emitCompileErrors(errors.StageOriginalCompile, originalResult)
 			a.updateStatusError(errMsg)
 			a.saveIntermediateResult(arxivID, title, arxivID, sourceInfo, results.StatusError, errMsg, "", "")
 			return nil, types.NewAppError(types.ErrCompile, errMsg, err)
@@ -2915,7 +6341,7 @@ func (a *App) continueProcessingFromStatus(sourceInfo *types.SourceInfo, arxivID
 
 		// Check for cancellation
 		if ctx.Err() != nil {
-			return nil, types.NewAppError(types.ErrInternal, "处理已取消", ctx.Err())
+			return nil, a.finishCancelled(arxivID, title, arxivID, sourceInfo, originalPDFPath)
 		}
 
 		// Continue with translation and compilation
@@ -2935,10 +6361,16 @@ func (a *App) translateAndCompile(sourceInfo *types.SourceInfo, arxivID, title,
 
 	// Translate
 	a.updateStatus(types.PhaseTranslating, 42, "开始翻译文档...")
-	translatedFiles, _, err := a.translateAllTexFiles(mainTexPath, sourceInfo.ExtractDir, func(current, total int, message string) {
-		progress := 42 + (current * 16 / total)
-		a.updateStatus(types.PhaseTranslating, progress, message)
+	translatedFiles, _, err := a.translateAllTexFiles(ctx, mainTexPath, sourceInfo.ExtractDir, func(p fileProgress) {
+		progress := 42 + (p.Percent * 16 / 100)
+		a.updateTranslationStatus(progress, p.Message, p.Detail)
 	})
+	if err != nil && ctx.Err() != nil {
+		return nil, a.finishCancelled(arxivID, title, arxivID, sourceInfo, originalPDFPath)
+	}
+	if translator.IsBudgetExceeded(err) {
+		return nil, a.finishBudgetExceeded(arxivID, title, arxivID, sourceInfo, originalPDFPath, err)
+	}
 	if err != nil {
 		a.updateStatusError(fmt.Sprintf("翻译失败: %v", err))
 		a.saveIntermediateResult(arxivID, title, arxivID, sourceInfo, results.StatusError, err.Error(), originalPDFPath, "")
@@ -2949,38 +6381,60 @@ func (a *App) translateAndCompile(sourceInfo *types.SourceInfo, arxivID, title,
 
 	// Check for cancellation
 	if ctx.Err() != nil {
-		return nil, types.NewAppError(types.ErrInternal, "处理已取消", ctx.Err())
+		return nil, a.finishCancelled(arxivID, title, arxivID, sourceInfo, originalPDFPath)
 	}
 
 	// Save translated files
 	a.updateStatus(types.PhaseValidating, 60, "保存翻译文件...")
-	translatedContent := translatedFiles[mainFileName]
-	translatedContent = ensureCtexPackage(translatedContent)
-	translatedFiles[mainFileName] = translatedContent
+	a.postProcessTranslations(translatedFiles, sourceInfo.ExtractDir, mainFileName, postprocess.Options{Variant: a.chineseVariant(), Language: a.targetLanguage()})
 
 	for relPath, content := range translatedFiles {
-		fixedContent, _ := compiler.QuickFix(content)
 		var savePath string
 		if relPath == mainFileName {
 			savePath = filepath.Join(sourceInfo.ExtractDir, "translated_"+relPath)
 		} else {
 			savePath = filepath.Join(sourceInfo.ExtractDir, relPath)
 		}
-		if err := os.WriteFile(savePath, []byte(fixedContent), 0644); err != nil {
+		if err := os.WriteFile(savePath, []byte(content), 0644); err != nil {
 			logger.Warn("failed to save translated file", logger.String("path", savePath), logger.Err(err))
 		}
 	}
 
 	// Compile translated document
-	return a.compileTranslatedDocument(sourceInfo, arxivID, title, originalPDFPath, translatedTexPath, translatedOutputDir)
+	return a.compileTranslatedDocument(ctx, sourceInfo, arxivID, title, originalPDFPath, translatedTexPath, translatedOutputDir)
+}
+
+// tagBibliographyLanguages tags the Chinese entries of the bib databases of a translated
+// main file for its bibliography strategy (see postprocess.TagBibliographyLanguages) and
+// saves the main file. Returns whether the main file changed.
+func (a *App) tagBibliographyLanguages(translatedTexPath string) bool {
+	content, err := os.ReadFile(translatedTexPath)
+	if err != nil {
+		return false
+	}
+	tagged, entries := postprocess.TagBibliographyLanguages(string(content), filepath.Dir(translatedTexPath))
+	if entries == 0 {
+		return false
+	}
+	if err := os.WriteFile(translatedTexPath, []byte(tagged), 0644); err != nil {
+		logger.Warn("failed to save main file with tagged bibliography", logger.Err(err))
+		return false
+	}
+	return true
 }
 
-// compileTranslatedDocument handles the final compilation phase
-func (a *App) compileTranslatedDocument(sourceInfo *types.SourceInfo, arxivID, title, originalPDFPath, translatedTexPath, translatedOutputDir string) (*types.ProcessResult, error) {
+// compileTranslatedDocument handles the final compilation phase; cancelling ctx stops it
+func (a *App) compileTranslatedDocument(ctx context.Context, sourceInfo *types.SourceInfo, arxivID, title, originalPDFPath, translatedTexPath, translatedOutputDir string) (*types.ProcessResult, error) {
 	a.updateStatus(types.PhaseCompiling, 75, "编译中文文档...")
 	a.saveIntermediateResult(arxivID, title, arxivID, sourceInfo, results.StatusCompiling, "", originalPDFPath, "")
+	a.tagBibliographyLanguages(translatedTexPath)
 
-	translatedResult, err := a.compiler.CompileWithXeLaTeX(translatedTexPath, translatedOutputDir)
+	stopPreview := a.startTranslatedPreview(ctx, sourceInfo.ExtractDir, translatedTexPath)
+	defer stopPreview()
+	translatedResult, err := a.compileTranslated(ctx, translatedTexPath, translatedOutputDir)
+	if ctx.Err() != nil {
+		return nil, a.finishCancelled(arxivID, title, arxivID, sourceInfo, originalPDFPath)
+	}
 
 	if err != nil || !translatedResult.Success {
 		// Try hierarchical fix
@@ -2988,6 +6442,18 @@ func (a *App) compileTranslatedDocument(sourceInfo *types.SourceInfo, arxivID, t
 		baseURL := a.config.GetBaseURL()
 		model := a.config.GetModel()
 		fixer := compiler.NewLaTeXFixerWithAgent(apiKey, baseURL, model, model, true)
+		fixer.SetProvider(a.config.GetProvider())
+		fixer.SetUsageMeter(a.usage)
+		if a.translator != nil {
+			fixer.SetRepairer(a.translator)
+		}
+		originalLog := readCompileLog(filepath.Join(sourceInfo.ExtractDir, "output_original"), sourceInfo.MainTexFile)
+		if originalLog != "" {
+			fixer.SetOriginalLog(originalLog)
+		}
+		fixCtx, endFixLoop := a.beginFixLoop(a.ctx)
+		fixer.SetContext(fixCtx)
+		a.enableFixReview(fixCtx, fixer)
 
 		fixResult, _ := fixer.HierarchicalFixCompilationErrors(
 			sourceInfo.ExtractDir,
@@ -3003,9 +6469,13 @@ func (a *App) compileTranslatedDocument(sourceInfo *types.SourceInfo, arxivID, t
 				a.updateStatus(types.PhaseValidating, progress, message)
 			},
 		)
+		endFixLoop()
 
+		if fixResult != nil && fixResult.Aborted {
+			return nil, a.finishWithManualFix(arxivID, title, arxivID, sourceInfo, originalPDFPath, translatedTexPath, fixResult.LastCompileLog)
+		}
 		if fixResult != nil && fixResult.Success {
-			translatedResult, err = a.compiler.CompileWithXeLaTeX(translatedTexPath, translatedOutputDir)
+			translatedResult, err = a.compileTranslated(ctx, translatedTexPath, translatedOutputDir)
 		}
 	}
 
@@ -3014,11 +6484,16 @@ func (a *App) compileTranslatedDocument(sourceInfo *types.SourceInfo, arxivID, t
 		if translatedResult != nil && translatedResult.ErrorMsg != "" {
 			errMsg = translatedResult.ErrorMsg
 		}
+		if originalLog := readCompileLog(filepath.Join(sourceInfo.ExtractDir, "output_original"), sourceInfo.MainTexFile); originalLog != "" {
+			a.recordLogDiff(originalLog, translatedResult)
+		}
+		a.emitCompileErrors(errors.StageTranslatedCompile, translatedResult)
 		a.updateStatusError(errMsg)
 		a.saveIntermediateResult(arxivID, title, arxivID, sourceInfo, results.StatusError, errMsg, originalPDFPath, "")
 		return nil, types.NewAppError(types.ErrCompile, errMsg, err)
 	}
 
+	stopPreview()
 	a.safeEmit(EventTranslatedPDFReady, translatedResult.PDFPath)
 
 	// Complete
@@ -3058,10 +6533,12 @@ func (a *App) OpenPaperResult(arxivID string) (*types.ProcessResult, error) {
 	}
 
 	result := &types.ProcessResult{
-		OriginalPDFPath:   info.OriginalPDF,
-		TranslatedPDFPath: info.TranslatedPDF,
-		BilingualPDFPath:  info.BilingualPDF,
-		SourceID:          arxivID,
+		OriginalPDFPath:    info.OriginalPDF,
+		TranslatedPDFPath:  info.TranslatedPDF,
+		BilingualPDFPath:   info.BilingualPDF,
+		SourceID:           arxivID,
+		Uncompiled:         info.TranslationMode == results.TranslationModeUncompiled,
+		TranslatedHTMLPath: info.TranslatedHTML,
 	}
 
 	// Store result for download
@@ -3168,12 +6645,14 @@ func (a *App) saveIntermediateResult(arxivID, title, originalInput string, sourc
 	latexDst := a.results.GetLatexSourceDir(arxivID)
 	hasLatexSource := false
 	mainTexFile := ""
+	mainTexFallbackFrom := ""
 	if sourceInfo != nil && sourceInfo.ExtractDir != "" {
 		if err := copyDir(sourceInfo.ExtractDir, latexDst); err != nil {
 			logger.Warn("failed to copy LaTeX source", logger.Err(err))
 		} else {
 			hasLatexSource = true
 			mainTexFile = sourceInfo.MainTexFile
+			mainTexFallbackFrom = sourceInfo.MainTexFallbackFrom
 		}
 	}
 
@@ -3190,10 +6669,16 @@ func (a *App) saveIntermediateResult(arxivID, title, originalInput string, sourc
 		ErrorMessage:   errorMsg,
 		OriginalInput:  originalInput,
 		MainTexFile:    mainTexFile,
+		MainTexFallbackFrom: mainTexFallbackFrom,
 		SourceType:     sourceType,
 		SourceMD5:      sourceMD5,
 		SourceFileName: sourceFileName,
+		ChineseVariant: string(a.chineseVariant()),
 	}
+	if hasLatexSource {
+		info.FailedChunks = translator.FailedChunksInDir(latexDst)
+	}
+	a.recordUsage(info)
 
 	if err := a.results.SavePaperInfo(info); err != nil {
 		logger.Error("failed to save paper info", err)
@@ -3245,16 +6730,31 @@ func (a *App) saveResultToPermanentStorage(result *types.ProcessResult, arxivID,
 		}
 	}
 
+	// Copy the HTML export of an uncompiled translation
+	htmlDst := ""
+	if result.TranslatedHTMLPath != "" {
+		htmlDst = a.results.GetTranslatedHTMLPath(arxivID)
+		if err := copyFile(result.TranslatedHTMLPath, htmlDst); err != nil {
+			logger.Warn("failed to copy HTML export", logger.Err(err))
+			htmlDst = ""
+		}
+	}
+
 	// Copy LaTeX source directory
 	latexDst := a.results.GetLatexSourceDir(arxivID)
 	hasLatexSource := false
 	mainTexFile := ""
+	mainTexFallbackFrom := ""
 	if result.SourceInfo != nil && result.SourceInfo.ExtractDir != "" {
 		if err := copyDir(result.SourceInfo.ExtractDir, latexDst); err != nil {
 			logger.Warn("failed to copy LaTeX source", logger.Err(err))
 		} else {
 			hasLatexSource = true
 			mainTexFile = result.SourceInfo.MainTexFile
+			mainTexFallbackFrom = result.SourceInfo.MainTexFallbackFrom
+			// The job is complete; a later continue or upgrade must not reuse its checkpoint
+			results.ClearCheckpoint(latexDst)
+			a.removeChunkCache(filepath.Join(result.SourceInfo.ExtractDir, result.SourceInfo.MainTexFile))
 		}
 	}
 
@@ -3270,13 +6770,38 @@ func (a *App) saveResultToPermanentStorage(result *types.ProcessResult, arxivID,
 		HasLatexSource: hasLatexSource,
 		Status:         results.StatusComplete,
 		MainTexFile:    mainTexFile,
+		MainTexFallbackFrom: mainTexFallbackFrom,
+		ChineseVariant: string(a.chineseVariant()),
+	}
+	if hasLatexSource {
+		info.FailedChunks = translator.FailedChunksInDir(latexDst)
+	}
+	if result.QuickMode {
+		info.TranslationMode = results.TranslationModeQuick
 	}
+	if result.Uncompiled {
+		// Only record the PDFs that were actually produced
+		info.TranslationMode = results.TranslationModeUncompiled
+		info.TranslatedHTML = htmlDst
+		if result.OriginalPDFPath == "" {
+			info.OriginalPDF = ""
+		}
+		if result.TranslatedPDFPath == "" {
+			info.TranslatedPDF = ""
+		}
+		info.BilingualPDF = ""
+	}
+	a.recordUsage(info)
 
 	if err := a.results.SavePaperInfo(info); err != nil {
 		logger.Error("failed to save paper info", err)
 		return err
 	}
 
+	if err := a.results.SaveProvenance(arxivID, result.Provenance); err != nil {
+		logger.Warn("failed to save provenance", logger.Err(err))
+	}
+
 	logger.Info("result saved to permanent storage", logger.String("arxivID", arxivID))
 	return nil
 }
@@ -3303,6 +6828,14 @@ func copyFile(src, dst string) error {
 	return err
 }
 
+// compiledPDFPath returns the PDF of a compile result, or "" when there is none
+func compiledPDFPath(result *types.CompileResult) string {
+	if result == nil || !result.Success {
+		return ""
+	}
+	return result.PDFPath
+}
+
 // copyDir recursively copies a directory
 func copyDir(src, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
@@ -3392,839 +6925,264 @@ func (a *App) SaveTranslatedPDF(sourcePath string) (string, error) {
 	srcData, err := os.ReadFile(sourcePath)
 	if err != nil {
 		logger.Error("failed to read source PDF", err)
-		return "", types.NewAppError(types.ErrFileNotFound, "读取源 PDF 失败", err)
-	}
-
-	if err := os.WriteFile(savePath, srcData, 0644); err != nil {
-		logger.Error("failed to write PDF", err)
-		return "", types.NewAppError(types.ErrInternal, "保存 PDF 失败", err)
-	}
-
-	logger.Info("Translated PDF saved", logger.String("path", savePath))
-	return savePath, nil
-}
-
-// OpenPDFFileDialog opens a file selection dialog for selecting PDF files.
-// Returns the selected file path or empty string if cancelled.
-// This method is exposed to the frontend via Wails bindings.
-// Requirements: 1.1 - 用户选择本地 PDF 文件
-func (a *App) OpenPDFFileDialog() string {
-	logger.Debug("opening PDF file dialog")
-	selection, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
-		Title: "选择 PDF 文件",
-		Filters: []runtime.FileFilter{
-			{
-				DisplayName: "PDF ļ (*.pdf)",
-				Pattern:     "*.pdf",
-			},
-			{
-				DisplayName: "所有文?(*.*)",
-				Pattern:     "*.*",
-			},
-		},
-	})
-	if err != nil {
-		logger.Error("PDF file dialog error", err)
-		return ""
-	}
-	logger.Debug("PDF file selected", logger.String("path", selection))
-	return selection
-}
-
-// ZipWriter is a helper for creating zip archives
-type ZipWriter struct {
-	writer *zip.Writer
-}
-
-// NewZipWriter creates a new ZipWriter
-func NewZipWriter(w io.Writer) *ZipWriter {
-	return &ZipWriter{writer: zip.NewWriter(w)}
-}
-
-// AddFile adds a file to the zip archive
-func (z *ZipWriter) AddFile(name string, content []byte) error {
-	f, err := z.writer.Create(name)
-	if err != nil {
-		return err
-	}
-	_, err = f.Write(content)
-	return err
-}
-
-// Close closes the zip writer
-func (z *ZipWriter) Close() error {
-	return z.writer.Close()
-}
-
-// addChineseFontSupport adds Chinese font support to the preamble for LuaLaTeX compilation.
-// This is necessary because translated documents contain Chinese characters that require
-// proper font configuration.
-func addChineseFontSupport(content string) string {
-	// Find \begin{document} position
-	beginDocIdx := strings.Index(content, `\begin{document}`)
-	if beginDocIdx == -1 {
-		logger.Debug("addChineseFontSupport: no \\begin{document} found")
-		return content
-	}
-
-	preamble := content[:beginDocIdx]
-	body := content[beginDocIdx:]
-
-	// Check if Chinese font support is already present
-	if strings.Contains(preamble, `luatexja-fontspec`) || strings.Contains(preamble, `\setCJKmainfont`) {
-		logger.Debug("addChineseFontSupport: Chinese font support already present")
-		return content
-	}
-
-	// Check if fontspec is already loaded
-	hasFontspec := strings.Contains(preamble, `\usepackage{fontspec}`)
-
-	// Chinese font support packages
-	chineseFontSupport := `
-% Chinese font support for LuaLaTeX (auto-added by translator)
-`
-	if !hasFontspec {
-		chineseFontSupport += `\usepackage{fontspec}
-`
-	}
-	chineseFontSupport += `\usepackage{luatexja-fontspec}
-\setmainjfont{SimSun}[BoldFont=SimHei]
-\setsansjfont{SimHei}
-`
-
-	// Find a good insertion point - after \documentclass or after geometry package
-	insertIdx := -1
-
-	// Try to insert after geometry package
-	geometryIdx := strings.Index(preamble, `\usepackage`)
-	if geometryIdx != -1 {
-		// Find the end of the first \usepackage line
-		lineEnd := strings.Index(preamble[geometryIdx:], "\n")
-		if lineEnd != -1 {
-			insertIdx = geometryIdx + lineEnd + 1
-		}
-	}
-
-	// If no good insertion point found, insert right after \documentclass line
-	if insertIdx == -1 {
-		docclassIdx := strings.Index(preamble, `\documentclass`)
-		if docclassIdx != -1 {
-			lineEnd := strings.Index(preamble[docclassIdx:], "\n")
-			if lineEnd != -1 {
-				insertIdx = docclassIdx + lineEnd + 1
-			}
-		}
-	}
-
-	if insertIdx == -1 {
-		// Fallback: insert at the beginning of preamble
-		insertIdx = 0
-	}
-
-	newPreamble := preamble[:insertIdx] + chineseFontSupport + preamble[insertIdx:]
-	logger.Info("added Chinese font support to preamble")
-
-	return newPreamble + body
-}
-
-// fixDuplicateThebibliographyInPreamble removes thebibliography environment from preamble.
-// The thebibliography environment should NEVER be in the preamble (before \begin{document}).
-// This can happen when LLM incorrectly inserts .bbl content during translation.
-func fixDuplicateThebibliographyInPreamble(content string) string {
-	// Find \begin{document} position
-	beginDocIdx := strings.Index(content, `\begin{document}`)
-	if beginDocIdx == -1 {
-		logger.Debug("fixDuplicateThebibliographyInPreamble: no \\begin{document} found")
-		return content
-	}
-
-	preamble := content[:beginDocIdx]
-	body := content[beginDocIdx:]
-
-	// Check if thebibliography exists in preamble
-	preambleHasBib := strings.Contains(preamble, `\begin{thebibliography}`)
-
-	logger.Debug("fixDuplicateThebibliographyInPreamble: checking for thebibliography in preamble",
-		logger.Bool("preambleHasBib", preambleHasBib))
-
-	if !preambleHasBib {
-		// No thebibliography in preamble, return as is
-		return content
-	}
-
-	logger.Info("fixDuplicateThebibliographyInPreamble: found thebibliography in preamble, removing")
-
-	// Remove thebibliography environment from preamble
-	// Find the start and end of the thebibliography environment in preamble
-	bibStartIdx := strings.Index(preamble, `\begin{thebibliography}`)
-	if bibStartIdx == -1 {
-		return content
-	}
-
-	bibEndIdx := strings.Index(preamble[bibStartIdx:], `\end{thebibliography}`)
-	if bibEndIdx == -1 {
-		logger.Warn("fixDuplicateThebibliographyInPreamble: no \\end{thebibliography} found in preamble")
-		return content
-	}
-	bibEndIdx += bibStartIdx + len(`\end{thebibliography}`)
-
-	// Remove the thebibliography environment from preamble
-	// Also remove any trailing newlines
-	for bibEndIdx < len(preamble) && (preamble[bibEndIdx] == '\n' || preamble[bibEndIdx] == '\r') {
-		bibEndIdx++
-	}
-
-	newPreamble := preamble[:bibStartIdx] + preamble[bibEndIdx:]
-	logger.Info("removed thebibliography from preamble",
-		logger.Int("removedBytes", bibEndIdx-bibStartIdx))
-
-	return newPreamble + body
-}
-
-// fixSplitCommentLinesInPreamble fixes cases where LLM translation incorrectly splits
-// comment lines in the preamble, putting "%" on one line and the comment text on the next.
-// This is critical because uncommented text in the preamble causes "Missing \begin{document}" errors.
-//
-// Pattern detected:
-//
-//	Line N:   % (or "% ")
-//	Line N+1: comment text (without %)
-//
-// This function merges such split lines or comments the orphaned text.
-func fixSplitCommentLinesInPreamble(content string) string {
-	// Find \begin{document}
-	beginDocIdx := strings.Index(content, `\begin{document}`)
-	if beginDocIdx == -1 {
-		return content
-	}
-
-	preamble := content[:beginDocIdx]
-	body := content[beginDocIdx:]
-
-	lines := strings.Split(preamble, "\n")
-	fixed := false
-
-	// Common comment indicators that suggest a line should be commented
-	commentIndicators := []string{
-		"recommended", "optional", "packages", "figures", "typesetting",
-		"hyperref", "hyperlinks", "resulting", "build breaks",
-		"comment out", "following", "attempt", "algorithmic",
-		"initial blind", "submitted", "review", "preprint",
-		"accepted", "camera-ready", "submission", "setting:",
-		"above.", "better", "work together", "for the",
-		"use the", "instead use", "if accepted", "for preprint",
-		"spans a page", "please comment", "use the following",
-		"with \\usepackage", "nohyperref",
-	}
-
-	// Process lines to fix split comments
-	for i := 0; i < len(lines)-1; i++ {
-		trimmed := strings.TrimSpace(lines[i])
-
-		// Check if this line is just "%" or "% " (isolated percent sign)
-		if trimmed == "%" || trimmed == "% " {
-			// Check the next line
-			nextLine := lines[i+1]
-			nextTrimmed := strings.TrimSpace(nextLine)
-
-			// Skip if next line is empty or already commented
-			if nextTrimmed == "" || strings.HasPrefix(nextTrimmed, "%") {
-				continue
-			}
-
-			// Check if next line looks like comment text
-			lowerNext := strings.ToLower(nextTrimmed)
-			shouldComment := false
-
-			for _, indicator := range commentIndicators {
-				if strings.Contains(lowerNext, indicator) {
-					shouldComment = true
-					break
-				}
-			}
-
-			// Special case: line starts with a LaTeX command but contains comment-like text
-			// e.g., "\usepackage{icml2026} with \usepackage[nohyperref]{icml2026} above."
-			// This should be fully commented
-			if strings.HasPrefix(nextTrimmed, "\\") {
-				// Check if it contains comment indicators
-				for _, indicator := range commentIndicators {
-					if strings.Contains(lowerNext, indicator) {
-						shouldComment = true
-						break
-					}
-				}
-			}
-
-			// Also check if the line doesn't look like valid LaTeX
-			// (no backslash commands, no braces at start)
-			if !shouldComment && !strings.HasPrefix(nextTrimmed, "\\") {
-				if !strings.ContainsAny(nextTrimmed[:min(10, len(nextTrimmed))], "\\{}$") {
-					// Line starts with regular text - likely a comment
-					if len(nextTrimmed) > 5 {
-						shouldComment = true
-					}
-				}
-			}
-
-			if shouldComment {
-				// Comment the next line
-				leadingWhitespace := nextLine[:len(nextLine)-len(strings.TrimLeft(nextLine, " \t"))]
-				lines[i+1] = leadingWhitespace + "% " + nextTrimmed
-				fixed = true
-				logger.Info("fixSplitCommentLinesInPreamble: commented orphaned text",
-					logger.Int("lineNum", i+1),
-					logger.String("text", nextTrimmed[:min(50, len(nextTrimmed))]))
-			}
-		}
-	}
-
-	if fixed {
-		return strings.Join(lines, "\n") + body
-	}
-
-	return content
-}
-
-// fixMergedCommentLinesInPreamble fixes cases where LLM translation incorrectly merges
-// comment text with the next line in the preamble.
-// This is critical because it can cause LaTeX commands to be commented out or syntax errors.
-//
-// Pattern detected:
-//
-//	Original: "% comment text\n\usepackage{pkg}"
-//	Broken:   "% comment text\usepackage{pkg}" (merged on same line)
-//
-// This function splits such merged lines back into separate lines by comparing with original.
-func fixMergedCommentLinesInPreamble(content, original string) string {
-	if original == "" {
-		return content
-	}
-
-	// Find \begin{document}
-	beginDocIdx := strings.Index(content, `\begin{document}`)
-	if beginDocIdx == -1 {
-		return content
-	}
-
-	origBeginDocIdx := strings.Index(original, `\begin{document}`)
-	if origBeginDocIdx == -1 {
-		return content
-	}
-
-	preamble := content[:beginDocIdx]
-	body := content[beginDocIdx:]
-	origPreamble := original[:origBeginDocIdx]
-
-	lines := strings.Split(preamble, "\n")
-	origLines := strings.Split(origPreamble, "\n")
-	fixed := false
-
-	// Build a set of lines from original that are pure comment lines (not commenting out code)
-	// A pure comment line is one where the text after % doesn't start with a LaTeX command
-	origPureCommentLines := make(map[string]bool)
-	for _, line := range origLines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "%%") {
-			// Check if this is a pure comment (not commenting out a command)
-			afterPercent := strings.TrimSpace(strings.TrimPrefix(trimmed, "%"))
-			if afterPercent != "" && !strings.HasPrefix(afterPercent, "\\") {
-				// This is a pure comment line (text, not a commented-out command)
-				origPureCommentLines[trimmed] = true
-			}
-		}
-	}
-
-	// Build a set of uncommented lines from original (lines that should NOT be commented)
-	origUncommentedLines := make(map[string]bool)
-	for _, line := range origLines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" && !strings.HasPrefix(trimmed, "%") {
-			origUncommentedLines[trimmed] = true
-		}
-	}
-
-	// Now check each line in the translated preamble
-	var newLines []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		leadingWhitespace := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
-
-		// Case 1: Check for non-comment lines that have comment text merged with a command
-		// Pattern: "above.\usepackage{...}" or "following:\newcommand{...}"
-		// These are cases where comment continuation text lost its % and got merged with the next line
-		if !strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "\\") && len(trimmed) > 5 {
-			// Look for text followed by a LaTeX command
-			textCmdPattern := regexp.MustCompile(`^([^\\]+)(\\[a-zA-Z]+.*)$`)
-			if matches := textCmdPattern.FindStringSubmatch(trimmed); len(matches) == 3 {
-				textPart := strings.TrimSpace(matches[1])
-				commandPart := strings.TrimSpace(matches[2])
-
-				// Check if the text part looks like comment continuation
-				// (ends with punctuation or common comment words)
-				lowerText := strings.ToLower(textPart)
-				isCommentContinuation := false
-
-				// Check for common comment endings
-				commentEndings := []string{":", ".", ",", "above", "below", "following", "use", "instead"}
-				for _, ending := range commentEndings {
-					if strings.HasSuffix(lowerText, ending) {
-						isCommentContinuation = true
-						break
-					}
-				}
-
-				// Also check if the command part exists as an uncommented line in original
-				commandExistsInOrig := false
-				for origLine := range origUncommentedLines {
-					if strings.HasPrefix(origLine, commandPart) || strings.HasPrefix(commandPart, origLine) {
-						commandExistsInOrig = true
-						break
-					}
-				}
-
-				if isCommentContinuation && commandExistsInOrig {
-					// Split: comment the text part, keep command uncommented
-					newLines = append(newLines, leadingWhitespace+"% "+textPart)
-					newLines = append(newLines, leadingWhitespace+commandPart)
-					fixed = true
-					logger.Info("fixMergedCommentLinesInPreamble: split and commented merged line",
-						logger.String("text", textPart),
-						logger.String("command", commandPart))
-					continue
-				}
-			}
-		}
-
-		// Case 2: Check for comment lines where comment text is merged with a command
-		// Pattern: "% comment text\usepackage{...}"
-		// But NOT: "% \usepackage{...}" (this is intentionally commented out)
-		if strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "%%") {
-			afterPercent := strings.TrimSpace(strings.TrimPrefix(trimmed, "%"))
-
-			// Skip if this is a commented-out command (starts with \)
-			if strings.HasPrefix(afterPercent, "\\") {
-				newLines = append(newLines, line)
-				continue
-			}
-
-			// Look for pattern: "text \command" or "text\command"
-			// The text part should have at least 3 characters of actual text
-			mergedPattern := regexp.MustCompile(`^(.{3,}?)(\\[a-zA-Z]+.*)$`)
-			if matches := mergedPattern.FindStringSubmatch(afterPercent); len(matches) == 3 {
-				textPart := strings.TrimSpace(matches[1])
-				commandPart := strings.TrimSpace(matches[2])
-
-				// Verify this is a real merge by checking:
-				// 1. The text part looks like comment text (not just whitespace or symbols)
-				// 2. The command part exists as an uncommented line in original
-				hasLetters := regexp.MustCompile(`[a-zA-Z]{2,}`).MatchString(textPart)
-				commandExistsInOrig := false
-				for origLine := range origUncommentedLines {
-					if strings.HasPrefix(origLine, commandPart) || strings.HasPrefix(commandPart, origLine) {
-						commandExistsInOrig = true
-						break
-					}
-				}
-
-				if hasLetters && commandExistsInOrig {
-					// Split the line: keep comment part as comment, uncomment the command
-					newLines = append(newLines, leadingWhitespace+"% "+textPart)
-					newLines = append(newLines, leadingWhitespace+commandPart)
-					fixed = true
-					logger.Info("fixMergedCommentLinesInPreamble: split merged comment line",
-						logger.String("comment", textPart),
-						logger.String("command", commandPart))
-					continue
-				}
-			}
-		}
-
-		newLines = append(newLines, line)
+		return "", types.NewAppError(types.ErrFileNotFound, "读取源 PDF 失败", err)
 	}
 
-	if fixed {
-		return strings.Join(newLines, "\n") + body
+	if err := os.WriteFile(savePath, srcData, 0644); err != nil {
+		logger.Error("failed to write PDF", err)
+		return "", types.NewAppError(types.ErrInternal, "保存 PDF 失败", err)
 	}
 
-	return content
+	logger.Info("Translated PDF saved", logger.String("path", savePath))
+	return savePath, nil
 }
 
-// ensureCtexPackage ensures the ctex package is included in the LaTeX document for Chinese support.
-// It adds \usepackage{ctex} after \documentclass if not already present.
-// It also fixes microtype compatibility issues with XeLaTeX and removes conflicting CJK packages.
-func ensureCtexPackage(content string) string {
-	// Check if ctex is already included (must be uncommented)
-	// We need to check line by line to avoid matching commented lines like "% \usepackage{ctex}"
-	hasUncommentedCtex := false
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		// Skip commented lines
-		if strings.HasPrefix(trimmed, "%") {
-			continue
-		}
-		// Check for ctex package (with or without options)
-		if strings.Contains(line, "\\usepackage{ctex}") || 
-		   (strings.Contains(line, "\\usepackage[") && strings.Contains(line, "ctex}")) {
-			hasUncommentedCtex = true
-			break
-		}
+// OpenPDFFileDialog opens a file selection dialog for selecting PDF files.
+// Returns the selected file path or empty string if cancelled.
+// This method is exposed to the frontend via Wails bindings.
+// Requirements: 1.1 - 用户选择本地 PDF 文件
+func (a *App) OpenPDFFileDialog() string {
+	logger.Debug("opening PDF file dialog")
+	selection, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "选择 PDF 文件",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "PDF ļ (*.pdf)",
+				Pattern:     "*.pdf",
+			},
+			{
+				DisplayName: "所有文?(*.*)",
+				Pattern:     "*.*",
+			},
+		},
+	})
+	if err != nil {
+		logger.Error("PDF file dialog error", err)
+		return ""
 	}
-	
-	if hasUncommentedCtex {
-		logger.Debug("ctex package already present (uncommented)")
-	} else {
-		// Find the first uncommented \documentclass line and add \usepackage{ctex} after it
-		var result []string
-		added := false
-
-		for _, line := range lines {
-			result = append(result, line)
-			// Skip if already added or if line is commented
-			if added || strings.HasPrefix(strings.TrimSpace(line), "%") {
-				continue
-			}
-			// Check if this line contains \documentclass
-			if strings.Contains(line, "\\documentclass") {
-				result = append(result, "\\usepackage{ctex}")
-				added = true
-				logger.Info("added ctex package for Chinese support")
-			}
-		}
+	logger.Debug("PDF file selected", logger.String("path", selection))
+	return selection
+}
 
-		if added {
-			content = strings.Join(result, "\n")
-		} else {
-			logger.Warn("could not find \\documentclass to add ctex package")
-		}
-	}
+// ZipWriter is a helper for creating zip archives
+type ZipWriter struct {
+	writer *zip.Writer
+}
 
-	// Remove CJKutf8 package which conflicts with ctex/xeCJK
-	// When using XeLaTeX with ctex, xeCJK is used instead and CJKutf8 is incompatible
-	if strings.Contains(content, "\\usepackage{CJKutf8}") {
-		content = strings.Replace(content, "\\usepackage{CJKutf8}", "% \\usepackage{CJKutf8} % Commented out - using ctex instead", 1)
-		logger.Info("commented out CJKutf8 package (conflicts with ctex)")
-	}
+// NewZipWriter creates a new ZipWriter
+func NewZipWriter(w io.Writer) *ZipWriter {
+	return &ZipWriter{writer: zip.NewWriter(w)}
+}
 
-	// Remove CJK* environment which is not defined when using xeCJK
-	// Pattern: \begin{CJK*}{...}{...} ... \end{CJK*}
-	cjkBeginPattern := regexp.MustCompile(`\\begin\{CJK\*\}\{[^}]*\}\{[^}]*\}\s*\n?`)
-	if cjkBeginPattern.MatchString(content) {
-		content = cjkBeginPattern.ReplaceAllString(content, "% CJK* environment removed - using ctex instead\n")
-		logger.Info("removed \\begin{CJK*} (conflicts with ctex)")
+// AddFile adds a file to the zip archive
+func (z *ZipWriter) AddFile(name string, content []byte) error {
+	f, err := z.writer.Create(name)
+	if err != nil {
+		return err
 	}
+	_, err = f.Write(content)
+	return err
+}
 
-	cjkEndPattern := regexp.MustCompile(`\\end\{CJK\*\}\s*\n?`)
-	if cjkEndPattern.MatchString(content) {
-		content = cjkEndPattern.ReplaceAllString(content, "% \\end{CJK*} removed\n")
-		logger.Info("removed \\end{CJK*} (conflicts with ctex)")
-	}
+// Close closes the zip writer
+func (z *ZipWriter) Close() error {
+	return z.writer.Close()
+}
 
-	// Fix microtype compatibility with XeLaTeX
-	// microtype with expansion/protrusion can cause "Cannot use XeTeXglyph" errors
-	// Replace \usepackage{microtype} with a XeLaTeX-compatible version
-	if strings.Contains(content, "\\usepackage{microtype}") {
-		content = strings.Replace(content, "\\usepackage{microtype}",
-			"\\usepackage[protrusion=false,expansion=false]{microtype}", 1)
-		logger.Info("fixed microtype package for XeLaTeX compatibility")
+// postProcessTranslations runs the post-processing pipeline on every translated file in
+// place. The originals are read from baseDir, so this must run before translated input
+// files overwrite them.
+func (a *App) postProcessTranslations(translatedFiles map[string]string, baseDir, mainFileName string, opts postprocess.Options) {
+	logger.Info("post-processing translated files",
+		logger.Int("fileCount", len(translatedFiles)),
+		logger.String("passes", strings.Join(postprocess.Describe(), ", ")))
+	for relPath, content := range translatedFiles {
+		translatedFiles[relPath] = a.postProcessFile(relPath, content, baseDir, mainFileName, opts)
 	}
+}
 
-	// Also handle microtype with options
-	microtypePattern := regexp.MustCompile(`\\usepackage\[([^\]]*)\]\{microtype\}`)
-	if microtypePattern.MatchString(content) {
-		// Check if protrusion/expansion are already disabled
-		if !strings.Contains(content, "protrusion=false") {
-			content = microtypePattern.ReplaceAllString(content,
-				"\\usepackage[protrusion=false,expansion=false]{microtype}")
-			logger.Info("fixed microtype package options for XeLaTeX compatibility")
-		}
+// postProcessFile runs the post-processing pipeline on one translated file
+func (a *App) postProcessFile(relPath, content, baseDir, mainFileName string, opts postprocess.Options) string {
+	original, err := os.ReadFile(filepath.Join(baseDir, relPath))
+	if err != nil {
+		logger.Debug("could not read original file for reference fixes",
+			logger.String("relPath", relPath),
+			logger.Err(err))
 	}
+	return postprocess.Run(postprocess.File{
+		Content:  content,
+		Original: string(original),
+		Main:     relPath == mainFileName,
+	}, opts)
+}
 
-	// Fix nested tabular structures that were split across multiple lines
-	content = fixNestedTabularStructure(content)
+// chunkCacheDirName is the directory in the work directory holding the chunk caches of
+// translations that have not completed
+const chunkCacheDirName = "chunk_cache"
 
-	return content
+// chunkCachePath returns the chunk cache file of a source. The source is identified by the
+// content of its main tex file, so a continued, re-submitted or re-downloaded translation
+// of the same source finds the chunks of the run that stopped.
+func (a *App) chunkCachePath(mainTexPath string) string {
+	content, err := os.ReadFile(mainTexPath)
+	if err != nil || a.workDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(filepath.Base(mainTexPath)+"\x00"), content...))
+	return filepath.Join(a.workDir, chunkCacheDirName, hex.EncodeToString(sum[:8])+".jsonl")
 }
 
-// fixNestedTabularStructure fixes nested tabular structures that were incorrectly split across multiple lines.
-// This happens when the translator breaks \begin{tabular}...\end{tabular} into multiple lines,
-// which causes LaTeX compilation errors like "Missing \cr inserted".
-func fixNestedTabularStructure(content string) string {
-	// Pattern to match nested tabular that should be on single line
-	// e.g., \begin{tabular}[c]{@{}c@{}}...\end{tabular}}
-	pattern := regexp.MustCompile(`(\\begin\{tabular\}\[[^\]]+\]\{[^}]+\})([\s\S]*?)(\\end\{tabular\}\})`)
-	
-	fixed := false
-	content = pattern.ReplaceAllStringFunc(content, func(match string) string {
-		// Check if the match spans multiple lines
-		if strings.Contains(match, "\n") {
-			// Merge into single line, replacing newlines with spaces
-			result := strings.ReplaceAll(match, "\n", " ")
-			result = strings.ReplaceAll(result, "\r", "")
-			// Clean up multiple spaces
-			for strings.Contains(result, "  ") {
-				result = strings.ReplaceAll(result, "  ", " ")
-			}
-			fixed = true
-			return result
-		}
-		return match
-	})
-	
-	if fixed {
-		logger.Debug("fixed nested tabular structures that were split across lines")
-	}
-	
-	// Fix standalone } on a line after \end{tabular} or \end{tabular}}
-	// Pattern: \end{tabular}\n}\n or \end{tabular}}\n}\n -> remove the extra }
-	extraBracePattern := regexp.MustCompile(`(\\end\{tabular\}\}?)\s*\n\}\s*\n`)
-	for extraBracePattern.MatchString(content) {
-		content = extraBracePattern.ReplaceAllString(content, "$1\n")
-		logger.Debug("removed extra closing braces after tabular")
+// openChunkCache opens the chunk cache of a source; nil when it cannot be used
+func (a *App) openChunkCache(mainTexPath string) *translator.ChunkCache {
+	path := a.chunkCachePath(mainTexPath)
+	if path == "" {
+		return nil
 	}
-	
-	// Fix \end{table without closing brace - this happens when LLM corrupts the structure
-	// Pattern: \end{table followed by space or backslash (not })
-	// e.g., \end{table \subsection -> \end{table} \subsection
-	incompleteEndTablePattern := regexp.MustCompile(`\\end\{table([^}*])`)
-	if incompleteEndTablePattern.MatchString(content) {
-		content = incompleteEndTablePattern.ReplaceAllString(content, "\\end{table}$1")
-		logger.Debug("fixed incomplete \\end{table} commands")
+	cache, err := translator.OpenChunkCache(path)
+	if err != nil {
+		logger.Warn("failed to open chunk cache, translating without it", logger.String("path", path), logger.Err(err))
+		return nil
 	}
-	
-	// Fix \end{tabular without closing brace
-	incompleteEndTabularPattern := regexp.MustCompile(`\\end\{tabular([^}*])`)
-	if incompleteEndTabularPattern.MatchString(content) {
-		content = incompleteEndTabularPattern.ReplaceAllString(content, "\\end{tabular}$1")
-		logger.Debug("fixed incomplete \\end{tabular} commands")
+	if n := cache.Len(); n > 0 {
+		logger.Info("resuming translation from chunk cache", logger.String("path", path), logger.Int("chunks", n))
 	}
-	
-	// Fix \multirow{ without proper closing - ensure nested tabular inside multirow is complete
-	// Pattern: \multirow{...}{\begin{tabular}...\end{tabular} (missing final })
-	// This is complex, so we use a simpler approach: ensure all \begin{tabular} have matching \end{tabular}}
-	
-	return content
+	return cache
 }
 
-// needsTranslation checks if a tex file contains translatable content.
-// Files that only contain LaTeX command definitions or tables don't need translation.
-func needsTranslation(content string) bool {
-	// Count different types of content
-	lines := strings.Split(content, "\n")
-	
-	commandDefCount := 0
-	tableStructureCount := 0
-	textContentCount := 0
-	
-	// Check if the file is primarily a table
-	hasTableEnv := strings.Contains(content, "\\begin{table") || strings.Contains(content, "\\begin{tabular")
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Skip empty lines and comments
-		if trimmed == "" || strings.HasPrefix(trimmed, "%") {
-			continue
-		}
-		
-		// Count command definitions (including the whole line)
-		if strings.HasPrefix(trimmed, "\\newcommand") ||
-			strings.HasPrefix(trimmed, "\\renewcommand") ||
-			strings.HasPrefix(trimmed, "\\def") ||
-			strings.HasPrefix(trimmed, "\\let") ||
-			strings.HasPrefix(trimmed, "\\DeclareMathOperator") ||
-			strings.HasPrefix(trimmed, "\\newlength") ||
-			strings.HasPrefix(trimmed, "\\setlength") ||
-			strings.HasPrefix(trimmed, "\\newcounter") ||
-			strings.HasPrefix(trimmed, "\\setcounter") ||
-			strings.HasPrefix(trimmed, "\\DeclareOption") ||
-			strings.HasPrefix(trimmed, "\\ProcessOptions") ||
-			strings.HasPrefix(trimmed, "\\RequirePackage") ||
-			strings.HasPrefix(trimmed, "\\ProvidesPackage") ||
-			strings.HasPrefix(trimmed, "\\ProvidesClass") {
-			commandDefCount++
-			continue
-		}
-		
-		// Count table structure lines (tabular, toprule, midrule, etc.)
-		if strings.Contains(trimmed, "\\begin{tabular") ||
-			strings.Contains(trimmed, "\\end{tabular") ||
-			strings.Contains(trimmed, "\\begin{table") ||
-			strings.Contains(trimmed, "\\end{table") ||
-			strings.Contains(trimmed, "\\toprule") ||
-			strings.Contains(trimmed, "\\midrule") ||
-			strings.Contains(trimmed, "\\bottomrule") ||
-			strings.Contains(trimmed, "\\hline") ||
-			strings.Contains(trimmed, "\\cline") ||
-			strings.Contains(trimmed, "\\multicolumn") ||
-			strings.Contains(trimmed, "\\resizebox") ||
-			strings.Contains(trimmed, "\\centering") ||
-			strings.Contains(trimmed, "\\caption") ||
-			strings.Contains(trimmed, "\\label") ||
-			strings.HasPrefix(trimmed, "&") ||
-			strings.HasSuffix(trimmed, "\\\\") {
-			tableStructureCount++
-			continue
-		}
-		
-		// Skip lines that are mostly LaTeX commands or symbols
-		if strings.HasPrefix(trimmed, "\\") || 
-			strings.HasPrefix(trimmed, "{") || 
-			strings.HasPrefix(trimmed, "}") || 
-			strings.HasPrefix(trimmed, "&") ||
-			strings.HasPrefix(trimmed, "$") {
-			continue
-		}
-		
-		// Count lines that look like actual prose text content
-		// Must have multiple words and not be just numbers/symbols
-		wordCount := len(regexp.MustCompile(`[a-zA-Z]{3,}`).FindAllString(trimmed, -1))
-		if wordCount >= 3 {
-			textContentCount++
-		}
-	}
-	
-	// If the file is primarily a table with very little prose, skip translation
-	if hasTableEnv && textContentCount < 5 {
-		return false
-	}
-	
-	// If the file is mostly command definitions with very little text, skip translation
-	totalSignificant := commandDefCount + tableStructureCount + textContentCount
-	if totalSignificant > 0 {
-		nonTextRatio := float64(commandDefCount+tableStructureCount) / float64(totalSignificant)
-		// If more than 80% of significant lines are non-text (commands or table structure), skip translation
-		if nonTextRatio > 0.8 && textContentCount < 5 {
-			return false
-		}
+// removeChunkCache deletes the chunk cache of a source once its translation completed or
+// is restarted from scratch
+func (a *App) removeChunkCache(mainTexPath string) {
+	path := a.chunkCachePath(mainTexPath)
+	if path == "" {
+		return
 	}
-	
-	// Also skip if there's very little text content overall
-	if textContentCount < 3 {
-		return false
+	if err := os.Remove(path); err == nil {
+		logger.Info("removed chunk cache", logger.String("path", path))
+	} else if !os.IsNotExist(err) {
+		logger.Warn("failed to remove chunk cache", logger.String("path", path), logger.Err(err))
 	}
-	
-	return true
 }
 
-// findInputFiles finds all tex files referenced by \input or \include commands in the given content.
-// It recursively scans all referenced files to find nested \input commands.
-// It returns a list of relative file paths in the order they should be processed.
-// baseDir should be the directory containing the main tex file (not the extraction root)
-func findInputFiles(content string, baseDir string) []string {
-	seen := make(map[string]bool)
-	var files []string
-
-	// Use recursive helper function
-	findInputFilesRecursive(content, baseDir, seen, &files, "")
-
-	return files
+// fileProgress is the progress reported by translateAllTexFiles: the share of the
+// document translated in percent, a message for display and the file being translated
+type fileProgress struct {
+	Percent int
+	Message string
+	Detail  types.TranslationDetail
 }
 
-// findInputFilesRecursive recursively finds all tex files referenced by \input or \include commands.
-// It handles nested \input commands by scanning each found file for additional references.
-func findInputFilesRecursive(content string, baseDir string, seen map[string]bool, files *[]string, currentDir string) {
-	// Pattern to match \input{...} and \include{...}
-	// Also handles \input{path/file} without .tex extension
-	inputPattern := regexp.MustCompile(`\\(?:input|include)\s*\{([^}]+)\}`)
-
-	matches := inputPattern.FindAllStringSubmatch(content, -1)
-	for _, match := range matches {
-		if len(match) > 1 {
-			filePath := strings.TrimSpace(match[1])
-			// Add .tex extension if not present
-			if !strings.HasSuffix(filePath, ".tex") {
-				filePath = filePath + ".tex"
-			}
-
-			// Handle relative paths from the current file's directory
-			if currentDir != "" && !filepath.IsAbs(filePath) && !strings.HasPrefix(filePath, currentDir) {
-				// Check if file exists relative to current directory first
-				relToCurrentPath := filepath.Join(currentDir, filePath)
-				fullPathFromCurrent := filepath.Join(baseDir, relToCurrentPath)
-				if _, err := os.Stat(fullPathFromCurrent); err == nil {
-					filePath = relToCurrentPath
-				}
+// translateAllTexFiles translates the main tex file and all referenced input files.
+// It returns a map of file paths to their raw translated content; postProcessTranslations
+// turns them into the files to compile. The translations are checkpointed in baseDir, so
+// a job that is cancelled (checked between files) or fails keeps the files it finished,
+// and a continued job reuses the checkpointed files whose original is unchanged. Within a
+// file, chunks translated by a run that stopped are served from the chunk cache.
+func (a *App) translateAllTexFiles(ctx context.Context, mainTexPath string, baseDir string, progressCallback func(p fileProgress)) (map[string]string, int, error) {
+	translations := make(map[string]string)
+	totalTokens := 0
+	a.reuseStats = nil
+	a.translationStats = &qa.TranslationStats{}
+
+	// Each run gets the full budget: a document stopped by the budget continues with a new one
+	a.translator.StartBudget(a.translationBudget())
+	defer a.translator.StartBudget(translator.TranslationBudget{})
+
+	// Every translated chunk is written to the chunk cache right away
+	cachedBefore := a.translator.Progress().CachedChunks
+	a.setCachedChunks(0)
+	if cache := a.openChunkCache(mainTexPath); cache != nil {
+		a.translator.SetChunkCache(cache)
+		defer func() {
+			a.translator.SetChunkCache(nil)
+			cache.Close()
+			if n := a.translator.Progress().CachedChunks - cachedBefore; n > 0 {
+				a.addWarning(fmt.Sprintf("%d 个分块使用了上次中断前已完成的译文，没有重新翻译", n))
 			}
+		}()
+	}
 
-			// Normalize path separators
-			filePath = filepath.Clean(filePath)
-
-			// Check if file exists
-			fullPath := filepath.Join(baseDir, filePath)
-			if _, err := os.Stat(fullPath); err == nil {
-				if !seen[filePath] {
-					seen[filePath] = true
-					*files = append(*files, filePath)
-					logger.Debug("found input file", logger.String("file", filePath))
-
-					// Recursively scan this file for more \input commands
-					nestedContent, err := os.ReadFile(fullPath)
-					if err == nil {
-						// Get the directory of the current file for relative path resolution
-						fileDir := filepath.Dir(filePath)
-						findInputFilesRecursive(string(nestedContent), baseDir, seen, files, fileDir)
-					}
-				}
-			} else {
-				logger.Debug("input file not found", logger.String("file", filePath), logger.String("fullPath", fullPath))
+	// Failed and damaged chunk requests are saved for bug reports when the capture is on
+	if capture := a.newDebugCapture(); capture != nil {
+		a.translator.SetDebugCapture(capture)
+		defer func() {
+			a.translator.SetDebugCapture(nil)
+			if n := capture.Count(); n > 0 {
+				a.addWarning(fmt.Sprintf("%d 个分块的提示词和响应已保存到 %s，可附在问题报告中", n, capture.Dir()))
 			}
-		}
+		}()
 	}
-}
 
-// translateAllTexFiles translates the main tex file and all referenced input files.
-// It returns a map of file paths to their translated content.
-func (a *App) translateAllTexFiles(mainTexPath string, baseDir string, progressCallback func(current, total int, message string)) (map[string]string, int, error) {
-	results := make(map[string]string)
-	originalContents := make(map[string]string) // Store original content for reference-based fixes
-	totalTokens := 0
+	// The glossary keeps terms consistent across chunks and files; the force-corrected
+	// terms are reported when the job ends
+	var glossaryCorrections []types.GlossaryCorrection
+	a.translator.SetGlossary(a.loadGlossary())
+	a.translator.SetPromptTemplate(a.loadPromptTemplate())
+	defer func() {
+		a.translator.SetGlossary(nil)
+		a.translator.SetPromptTemplate(nil)
+		if len(glossaryCorrections) > 0 {
+			a.addWarning(translator.FormatGlossaryCorrections(glossaryCorrections))
+		}
+	}()
 
-	// Read main tex file
-	mainContent, err := os.ReadFile(mainTexPath)
+	allFiles, err := collectTranslationFiles(mainTexPath, baseDir)
 	if err != nil {
-		return nil, 0, types.NewAppError(types.ErrFileNotFound, "读取主 tex 文件失败", err)
+		return nil, 0, err
 	}
-
-	// Find all input files
-	inputFiles := findInputFiles(string(mainContent), filepath.Dir(mainTexPath))
-	logger.Info("found input files", logger.Int("count", len(inputFiles)))
-
-	// Get the relative path of main file from baseDir
-	mainFileRel, err := filepath.Rel(baseDir, mainTexPath)
-	if err != nil {
-		// If we can't get relative path, use the basename
-		mainFileRel = filepath.Base(mainTexPath)
-		logger.Warn("failed to get relative path for main file, using basename",
-			logger.String("mainTexPath", mainTexPath),
-			logger.String("baseDir", baseDir),
-			logger.String("mainFileRel", mainFileRel))
+	if err := a.checkSourceLanguage(mainTexPath, baseDir, allFiles); err != nil {
+		return nil, 0, err
 	}
 
-	// Collect all files to translate (main file + input files)
-	allFiles := []string{mainFileRel}
-	allFiles = append(allFiles, inputFiles...)
-
+	// The progress band spans every file of the document, translated or not
 	totalFiles := len(allFiles)
 	currentFile := 0
+	tokensBefore := a.translator.Progress().TokensUsed
+	detail := func(relPath string, chunk translator.ChunkProgress) types.TranslationDetail {
+		return types.TranslationDetail{
+			File:        filepath.ToSlash(relPath),
+			FileIndex:   currentFile,
+			TotalFiles:  totalFiles,
+			Chunk:       chunk.Chunk,
+			TotalChunks: chunk.TotalChunks,
+			Section:     chunk.Section,
+			TokensUsed:  max(a.translator.Progress().TokensUsed-tokensBefore, 0),
+		}
+	}
+	reportFileDone := func(relPath, message string) {
+		if progressCallback != nil {
+			progressCallback(fileProgress{
+				Percent: currentFile * 100 / totalFiles,
+				Message: fmt.Sprintf("%s [%s, %d/%d 文件]", message, relPath, currentFile, totalFiles),
+				Detail:  detail(relPath, translator.ChunkProgress{}),
+			})
+		}
+	}
+
+	// Translations finished so far, saved as checkpoint when the job stops early
+	checkpoint := results.LoadCheckpoint(baseDir)
+	finished := make(map[string]*types.TranslationPair)
+	saveCheckpoint := func() {
+		if err := results.SaveCheckpoint(baseDir, finished); err != nil {
+			logger.Warn("failed to save translation checkpoint", logger.Err(err))
+		}
+	}
+
+	// Record the decision for every file in decisions.json, also when a file fails
+	overrides := a.getFileOverrides()
+	excluded := includeOnlyExclusions(mainTexPath, a.includeOnly)
+	mainRel, _ := filepath.Rel(baseDir, mainTexPath)
+	decisionLog := decisions.NewLog(baseDir, mainRel)
+	defer a.saveDecisionLog(decisionLog, baseDir)
 
 	// Translate each file
 	for _, relPath := range allFiles {
-		currentFile++
-		
-		// Construct full path - handle both relative paths from baseDir and from main file dir
-		var fullPath string
-		if filepath.IsAbs(relPath) {
-			fullPath = relPath
-		} else {
-			// Try relative to baseDir first
-			fullPath = filepath.Join(baseDir, relPath)
-			if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-				// If not found, try relative to main file directory
-				fullPath = filepath.Join(filepath.Dir(mainTexPath), relPath)
-			}
+		if ctx.Err() != nil {
+			logger.Warn("translation cancelled", logger.Int("finishedFiles", len(finished)))
+			saveCheckpoint()
+			return nil, totalTokens, ctx.Err()
 		}
+		currentFile++
+		fullPath := resolveTranslationFilePath(relPath, mainTexPath, baseDir)
 
 		logger.Info("processing file for translation",
 			logger.String("relPath", relPath),
@@ -4240,66 +7198,270 @@ func (a *App) translateAllTexFiles(mainTexPath string, baseDir string, progressC
 				logger.String("baseDir", baseDir),
 				logger.String("mainTexPath", mainTexPath))
 			// Don't skip - return error to fail fast
+			decisionLog.Files = append(decisionLog.Files, decisions.Record(relPath, nil, types.FileDecisionFailed, decisions.RuleReadFailed, err))
 			return nil, 0, types.NewAppError(types.ErrFileNotFound, fmt.Sprintf("读取文件失败: %s", relPath), err)
 		}
 
-		// Store original content for reference-based fixes
-		originalContents[relPath] = string(content)
-
 		logger.Debug("file read successfully",
 			logger.String("file", relPath),
 			logger.Int("contentLength", len(content)))
 
-		// Skip empty files
-		if len(strings.TrimSpace(string(content))) == 0 {
-			logger.Debug("skipping empty file", logger.String("file", relPath))
-			results[relPath] = string(content)
+		// Keep empty files and files that don't need translation (e.g., pure command
+		// definition files, files left out by \includeonly) as they are, unless overridden
+		decision := decidePaperFile(relPath, fullPath, content, overrides, excluded)
+		decisionLog.Files = append(decisionLog.Files, decision)
+		if decision.Decision != types.FileDecisionTranslate {
+			logger.Info("keeping file as is",
+				logger.String("file", relPath),
+				logger.String("rule", decision.Rule))
+			translations[relPath] = string(content)
+			reportFileDone(relPath, "原样保留")
 			continue
 		}
 
-		// Skip files that don't need translation (e.g., pure command definition files)
-		if !needsTranslation(string(content)) {
-			logger.Info("skipping file without translatable content", logger.String("file", relPath))
-			results[relPath] = string(content)
+		// Reuse the translation finished before the job was cancelled
+		if translated, ok := checkpoint.Lookup(relPath, string(content)); ok {
+			logger.Info("reusing checkpointed translation", logger.String("file", relPath))
+			translations[relPath] = translated
+			finished[relPath] = &types.TranslationPair{Original: string(content), Translated: translated}
+			reportFileDone(relPath, "使用已完成的译文")
 			continue
 		}
 
 		logger.Info("translating file", logger.String("file", relPath), logger.Int("current", currentFile), logger.Int("total", totalFiles))
 
 		// Translate with progress callback
-		result, err := a.translator.TranslateTeXWithProgress(string(content), func(chunkCurrent, chunkTotal int, message string) {
+		chunkProgressCallback := func(chunk translator.ChunkProgress) {
+			a.setCachedChunks(a.translator.Progress().CachedChunks - cachedBefore)
 			if progressCallback != nil {
 				// Calculate overall progress
-				fileProgress := float64(currentFile-1) / float64(totalFiles)
-				chunkProgress := float64(chunkCurrent) / float64(chunkTotal) / float64(totalFiles)
-				overallProgress := int((fileProgress + chunkProgress) * 100)
-				progressCallback(overallProgress, 100, fmt.Sprintf("翻译 %s (%d/%d 文件, %d/%d 分块)...", relPath, currentFile, totalFiles, chunkCurrent, chunkTotal))
+				filesDone := float64(currentFile-1) / float64(totalFiles)
+				chunksDone := float64(chunk.Chunk) / float64(chunk.TotalChunks) / float64(totalFiles)
+				overallProgress := int((filesDone + chunksDone) * 100)
+				// The translator's message names the section being translated (from the source outline)
+				message := chunk.Message
+				if totalFiles > 1 {
+					message = fmt.Sprintf("%s [%s, %d/%d 文件]", message, relPath, currentFile, totalFiles)
+				}
+				if budget := a.translator.BudgetStatus(); budget != "" {
+					message = fmt.Sprintf("%s（%s）", message, budget)
+				}
+				progressCallback(fileProgress{Percent: overallProgress, Message: message, Detail: detail(relPath, chunk)})
 			}
-		})
+		}
+
+		var result *types.TranslationResult
+		if reference := a.referenceTranslations[filepath.ToSlash(relPath)]; reference != nil {
+			// Version-diff mode: reuse the previous version's translation for unchanged paragraphs
+			result, err = a.translator.TranslateTeXWithReferenceContext(ctx, string(content), reference, chunkProgressCallback)
+		} else {
+			// Cancelling the job tears down the chunk requests in flight
+			result, err = a.translator.TranslateTeXWithProgressContext(ctx, string(content), chunkProgressCallback)
+		}
 
 		if err != nil {
 			logger.Error("failed to translate file", err, logger.String("file", relPath))
+			decisionLog.Files[len(decisionLog.Files)-1] = decisions.Record(relPath, content, types.FileDecisionFailed, decisions.RuleTranslationFailed, err)
+			saveCheckpoint()
 			return nil, 0, err
 		}
 
-		// Apply reference-based fixes using original content
+		a.translationStats.Add(result)
+
+		if result.NetworkPauses > 0 {
+			logger.Info("translation paused for network outages",
+				logger.String("file", relPath),
+				logger.Int("pauses", result.NetworkPauses),
+				logger.Float64("pausedSeconds", result.NetworkPausedSecs))
+			a.addWarning(fmt.Sprintf("%s: 网络中断 %d 次，暂停 %.0f 秒", relPath, result.NetworkPauses, result.NetworkPausedSecs))
+		}
+
+		if result.Stalls > 0 {
+			logger.Info("restarted stalled translation requests",
+				logger.String("file", relPath),
+				logger.Int("stalls", result.Stalls))
+			a.addWarning(fmt.Sprintf("%s: 翻译请求 %d 次无响应，已重建连接重试", relPath, result.Stalls))
+		}
+
+		if result.Generator != "" {
+			logger.Info("translated a generated source",
+				logger.String("file", relPath),
+				logger.String("generator", result.Generator))
+			a.addWarning(fmt.Sprintf("%s: 由 %s 生成，代码块和辅助宏已原样保留", relPath, result.Generator))
+		}
+
+		if result.ParagraphBreakFixes > 0 {
+			logger.Info("restored paragraph breaks changed by the model",
+				logger.String("file", relPath),
+				logger.Int("fixes", result.ParagraphBreakFixes))
+			a.addWarning(fmt.Sprintf("%s: 已按原文修正 %d 处段落分隔", relPath, result.ParagraphBreakFixes))
+		}
+
+		if result.Continuations > 0 || result.TruncatedChunks > 0 {
+			logger.Info("completed truncated model output with continuations",
+				logger.String("file", relPath),
+				logger.Int("continuations", result.Continuations),
+				logger.Int("truncatedChunks", result.TruncatedChunks))
+			a.addWarning(fmt.Sprintf("%s: %s", relPath, translator.FormatTruncationSummary(result)))
+		}
+
+		if result.SplitChunks > 0 {
+			logger.Info("translated truncated chunks in pieces",
+				logger.String("file", relPath),
+				logger.Int("splitChunks", result.SplitChunks))
+			a.addWarning(fmt.Sprintf("%s: %s", relPath, translator.FormatSplitSummary(result)))
+		}
+
+		if len(result.FailedChunks) > 0 {
+			logger.Warn("kept the original text of failed chunks",
+				logger.String("file", relPath),
+				logger.String("chunks", translator.FormatChunkNumbers(result.FailedChunks)))
+			a.addWarning(fmt.Sprintf("%s: %s", relPath, translator.FormatFailedChunksSummary(result)))
+		}
+
+		if len(result.GlossaryCorrections) > 0 {
+			logger.Info("force-corrected glossary terms",
+				logger.String("file", relPath),
+				logger.Int("terms", len(result.GlossaryCorrections)))
+			glossaryCorrections = translator.MergeGlossaryCorrections(glossaryCorrections, result.GlossaryCorrections)
+		}
+
+		if result.ReuseStats != nil {
+			if a.reuseStats == nil {
+				a.reuseStats = &types.ReuseStats{}
+			}
+			a.reuseStats.Add(result.ReuseStats)
+			logger.Info("reused previous version translation",
+				logger.String("file", relPath),
+				logger.Int("reusedParagraphs", result.ReuseStats.ReusedParagraphs),
+				logger.Int("totalParagraphs", result.ReuseStats.TotalParagraphs))
+		}
+
+		// Reference-based fixes are applied by the post-processing pipeline
 		translatedContent := result.TranslatedContent
-		
-		if original, ok := originalContents[relPath]; ok {
-			fixedContent, wasFixed := compiler.QuickFixWithReference(translatedContent, original)
-			if wasFixed {
-				logger.Info("applied reference-based fixes to translated file",
-					logger.String("relPath", relPath))
-				translatedContent = fixedContent
+
+		// Report embedded data blobs that were kept out of translation
+		if len(result.SkippedDataBlobs) > 0 {
+			summary := translator.FormatDataBlobSummary(result.SkippedDataBlobs)
+			a.addWarning(fmt.Sprintf("%s: %s", relPath, summary))
+			for _, blob := range result.SkippedDataBlobs {
+				logger.Info("skipped embedded data blob",
+					logger.String("file", relPath),
+					logger.String("blob", translator.DescribeDataBlob(blob)))
+			}
+			if progressCallback != nil {
+				progressCallback(fileProgress{
+					Percent: currentFile * 100 / totalFiles,
+					Message: fmt.Sprintf("%s: %s", relPath, summary),
+					Detail:  detail(relPath, translator.ChunkProgress{}),
+				})
 			}
 		}
 
-		results[relPath] = translatedContent
+		translations[relPath] = translatedContent
+		finished[relPath] = &types.TranslationPair{Original: string(content), Translated: translatedContent}
 		totalTokens += result.TokensUsed
 		logger.Info("file translated", logger.String("file", relPath), logger.Int("tokens", result.TokensUsed))
 	}
 
-	return results, totalTokens, nil
+	// Keep the complete translation until the job is stored, so cancelling during
+	// compilation does not lose it
+	saveCheckpoint()
+	return translations, totalTokens, nil
+}
+
+// saveDecisionLog writes the per-file decisions of a translation, with the support files
+// of the project, to decisions.json in the project directory
+func (a *App) saveDecisionLog(log *types.FileDecisionLog, baseDir string) {
+	decisions.AddSupportFiles(log, baseDir)
+	path, err := decisions.Save(log, baseDir)
+	if err != nil {
+		logger.Warn("failed to write decision log", logger.String("path", path), logger.Err(err))
+		return
+	}
+	logger.Info("decision log written",
+		logger.String("path", path),
+		logger.String("summary", decisions.Summary(log)))
+}
+
+// collectTranslationFiles returns the files translated for a document, relative to
+// baseDir: the main file followed by the files it pulls in via \input, \include, \subfile,
+// \import and \subimport, at any depth (downloader.FindTexDependencies). Each file is
+// listed once; referenced files missing from the source are skipped.
+func collectTranslationFiles(mainTexPath string, baseDir string) ([]string, error) {
+	if _, err := os.Stat(mainTexPath); err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "读取主 tex 文件失败", err)
+	}
+
+	// Get the relative path of main file from baseDir
+	mainFileRel, err := filepath.Rel(baseDir, mainTexPath)
+	if err != nil {
+		// If we can't get relative path, use the basename
+		mainFileRel = filepath.Base(mainTexPath)
+		logger.Warn("failed to get relative path for main file, using basename",
+			logger.String("mainTexPath", mainTexPath),
+			logger.String("baseDir", baseDir),
+			logger.String("mainFileRel", mainFileRel))
+	}
+
+	// Find all input files
+	deps := downloader.FindTexDependencies(baseDir, mainFileRel, downloader.DefaultInputDepth)
+	logger.Info("found input files",
+		logger.Int("count", len(deps.Files)),
+		logger.Int("missing", len(deps.Missing)))
+
+	// Collect all files to translate (main file + input files)
+	allFiles := []string{mainFileRel}
+	return append(allFiles, deps.Files...), nil
+}
+
+// checkSourceLanguage refuses a translation to Chinese of a source whose prose already is
+// Chinese, which would spend the whole token budget for nothing, unless SetForceTranslate
+// was enabled
+func (a *App) checkSourceLanguage(mainTexPath string, baseDir string, files []string) error {
+	if a.targetLanguage() != types.LanguageChinese {
+		return nil
+	}
+	contents := make([]string, 0, len(files))
+	for _, relPath := range files {
+		content, err := os.ReadFile(resolveTranslationFilePath(relPath, mainTexPath, baseDir))
+		if err != nil {
+			// Reported by the translation of the file
+			continue
+		}
+		contents = append(contents, string(content))
+	}
+
+	lang := translator.DetectSourceLanguage(contents...)
+	force := a.forceTranslateEnabled()
+	logger.Info("source language detected",
+		logger.Float64("cjkRatio", lang.CJKRatio),
+		logger.Int("letters", lang.Letters),
+		logger.Bool("alreadyChinese", lang.Chinese()),
+		logger.Bool("force", force))
+	if !lang.Chinese() {
+		return nil
+	}
+	if force {
+		a.addWarning(fmt.Sprintf("源文档似乎已是中文（中文字符占比 %.0f%%），已按要求继续翻译", lang.CJKRatio*100))
+		return nil
+	}
+	return translator.AlreadyChineseError(lang)
+}
+
+// resolveTranslationFilePath returns the full path of a file returned by collectTranslationFiles
+func resolveTranslationFilePath(relPath string, mainTexPath string, baseDir string) string {
+	// Handle both relative paths from baseDir and from main file dir
+	if filepath.IsAbs(relPath) {
+		return relPath
+	}
+	// Try relative to baseDir first
+	fullPath := filepath.Join(baseDir, relPath)
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		// If not found, try relative to main file directory
+		fullPath = filepath.Join(filepath.Dir(mainTexPath), relPath)
+	}
+	return fullPath
 }
 
 // ==================== GitHub Share Methods ====================
@@ -4402,7 +7564,7 @@ func (a *App) CheckShareStatus() (*ShareCheckResult, error) {
 	}
 
 	// Check if files exist on GitHub
-	safeID := strings.ReplaceAll(arxivID, "/", "_")
+	safeID := results.SanitizeFileName(arxivID)
 	chinesePath := fmt.Sprintf("%s_cn.pdf", safeID)
 	bilingualPath := fmt.Sprintf("%s_bilingual.pdf", safeID)
 
@@ -4493,7 +7655,7 @@ func (a *App) CheckShareStatusWithCategory(categoryID string) (*ShareCheckResult
 	}
 
 	// Build new file paths with category
-	safeID := strings.ReplaceAll(arxivID, "/", "_")
+	safeID := results.SanitizeFileName(arxivID)
 	var chinesePath, bilingualPath string
 	if categoryID != "" {
 		chinesePath = fmt.Sprintf("%s_%s_cn.pdf", safeID, categoryID)
@@ -4582,7 +7744,7 @@ func (a *App) ShareToGitHub(categoryID string, uploadChinese, uploadBilingual bo
 	// Create uploader
 	uploader := github.NewUploader(githubToken, owner, repo)
 
-	safeID := strings.ReplaceAll(arxivID, "/", "_")
+	safeID := results.SanitizeFileName(arxivID)
 	result := &ShareResult{Success: true}
 	var uploadedCount int
 
@@ -4767,7 +7929,7 @@ func (a *App) CheckShareStatusForPaper(arxivID string) (*ShareCheckResult, error
 	}
 
 	// Check if files exist on GitHub
-	safeID := strings.ReplaceAll(arxivID, "/", "_")
+	safeID := results.SanitizeFileName(arxivID)
 	chinesePath := fmt.Sprintf("%s_cn.pdf", safeID)
 	bilingualPath := fmt.Sprintf("%s_bilingual.pdf", safeID)
 
@@ -4833,7 +7995,7 @@ func (a *App) SharePaperToGitHub(arxivID string, categoryID string, uploadChines
 	// Create uploader
 	uploader := github.NewUploader(githubToken, owner, repo)
 
-	safeID := strings.ReplaceAll(arxivID, "/", "_")
+	safeID := results.SanitizeFileName(arxivID)
 	result := &ShareResult{Success: true}
 	var uploadedCount int
 
@@ -5066,7 +8228,9 @@ func (a *App) DownloadGitHubTranslation(arxivID, fileType, saveDir string) (stri
 		return "", types.NewAppError(types.ErrInternal, "创建目录失败: "+err.Error(), err)
 	}
 
-	// Full path for the downloaded file (using actual filename from GitHub)
+	// Full path for the downloaded file (using actual filename from GitHub, made safe for the local filesystem)
+	ext := filepath.Ext(filename)
+	filename = results.SanitizeFileName(strings.TrimSuffix(filepath.Base(filename), ext)) + ext
 	savePath := filepath.Join(saveDir, filename)
 
 	// Download the file
@@ -5441,10 +8605,14 @@ func (a *App) GetArxivPaperMetadata(arxivID string) (*ArxivPaperMetadata, error)
 
 	// Create HTTP client with timeout
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: netproxy.NewTransport(),
 	}
 
-	// Make request
+	// Make request, spaced like the downloads by the shared arXiv rate limiter
+	if err := downloader.WaitForRequest(context.Background()); err != nil {
+		return nil, types.NewAppError(types.ErrAPICall, "获取论文信息失败: "+err.Error(), err)
+	}
 	resp, err := client.Get(apiURL)
 	if err != nil {
 		logger.Error("failed to fetch arXiv metadata", err)
@@ -5534,7 +8702,8 @@ func (a *App) FetchAndDecodeGitHubToken() (string, error) {
 
 	// Create HTTP client with timeout
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: netproxy.NewTransport(),
 	}
 
 	// Fetch the content
@@ -6020,6 +9189,45 @@ func (a *App) recordError(id, title, input string, stage errors.ErrorStage, erro
 	}
 }
 
+// writeQualityReport compares the saved translated files (after the compile fixes) with
+// their originals and writes the quality report next to the translated PDF. Returns the
+// path of the JSON report, "" when it could not be written.
+func (a *App) writeQualityReport(sourceID string, originals, savedPaths map[string]string, fixStats *qa.FixStats, pages *pdf.PageCountResult, translatedPDFPath string) string {
+	relPaths := make([]string, 0, len(savedPaths))
+	for relPath := range savedPaths {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	var original, translated strings.Builder
+	for _, relPath := range relPaths {
+		content, err := os.ReadFile(savedPaths[relPath])
+		if err != nil {
+			logger.Warn("failed to read translated file for the quality report", logger.String("path", savedPaths[relPath]), logger.Err(err))
+			continue
+		}
+		original.WriteString(originals[relPath] + "\n")
+		translated.WriteString(string(content) + "\n")
+	}
+
+	lang := a.targetLanguage()
+	report := qa.CompareTeX(original.String(), translated.String(), lang)
+	report.Source = sourceID
+	report.Translation = a.translationStats
+	report.Fixes = fixStats
+	report.Pages = pages
+	report.IncludeOnly = a.includeOnly
+	report.Evaluate(lang)
+
+	path, err := report.Save(filepath.Dir(translatedPDFPath))
+	if err != nil {
+		logger.Warn("failed to write quality report", logger.Err(err))
+		return ""
+	}
+	logger.Info("quality report written", logger.String("path", path), logger.Int("issues", len(report.Issues)))
+	return path
+}
+
 // checkPageCountDifference 检查翻译前后的页数差异
 // 如果翻译后页数比原始页数少超过15%，返回可疑结果
 func (a *App) checkPageCountDifference(originalPDFPath, translatedPDFPath string) *pdf.PageCountResult {
@@ -82,7 +82,10 @@ cd ..
 
 echo.
 echo [3/4] 构建 Windows 可执行文件...
-wails build -clean -platform windows/amd64
+REM 记录 git commit，嵌入到生成 PDF 的来源信息中
+set GIT_COMMIT=
+for /f %%i in ('git rev-parse --short HEAD 2^>nul') do set GIT_COMMIT=%%i
+wails build -clean -platform windows/amd64 -ldflags "-X main.GitCommit=%GIT_COMMIT%"
 if %errorlevel% neq 0 (
     echo 错误: Wails 构建失败
     pause
@@ -1,10 +1,16 @@
-// Batch process arXiv papers: download, compile, translate
+// Batch process arXiv papers: download, compile, translate.
+//
+// Every paper's outcome is recorded in the results library and the error manager shared
+// with the app, so batch papers show up in the GUI library (tagged "批量") and failures in
+// its error list. The library is also what decides which papers still need work; the
+// arxiv_good_id.txt/arxiv_bad_id.txt/arxiv_bug_id.txt files are only written as exports.
 package main
 
 import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,7 +19,11 @@ import (
 
 	"latex-translator/internal/compiler"
 	"latex-translator/internal/downloader"
+	"latex-translator/internal/errors"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/postprocess"
+	"latex-translator/internal/results"
+	"latex-translator/internal/statusfile"
 	"latex-translator/internal/translator"
 )
 
@@ -38,9 +48,22 @@ type ProcessResult struct {
 	Translated    bool
 	PDFGenerated  bool
 	Error         string
+	Stage         errors.ErrorStage // stage that failed, when Error is set
 	FixesApplied  []string
+	Title         string
+	MainTexFile   string
+	ExtractDir    string
+	OriginalPDF   string
+	TranslatedPDF string
 }
 
+// Batch phases, recorded as the last phase of a paper in the library
+const (
+	phaseCompile   = "batch_compile"
+	phaseTranslate = "batch_translate"
+	phaseFix       = "batch_fix"
+)
+
 var (
 	goodIDs    []string
 	badIDs     []string
@@ -49,6 +72,8 @@ var (
 	badMutex   sync.Mutex
 	bugMutex   sync.Mutex
 	config     *Config
+	resultMgr  *results.ResultManager
+	errorMgr   *errors.ErrorManager
 )
 
 func loadConfig() *Config {
@@ -137,8 +162,225 @@ func addBugID(id string) {
 }
 
 
+// paperStatusPath returns the path of the status file of a paper
+func paperStatusPath(workDir, arxivID string) string {
+	return filepath.Join(workDir, "status", strings.ReplaceAll(arxivID, "/", "_")+".json")
+}
+
+// setPhase records the current phase of a paper in its status file
+func setPhase(status *statusfile.Writer, phase string, percent int, message string) {
+	status.Update(func(s *statusfile.Status) {
+		s.Phase = phase
+		s.Percent = percent
+		s.Message = message
+	})
+	status.Flush()
+}
+
+// openLibrary opens the results library and the error manager shared with the app
+func openLibrary() {
+	var err error
+	if resultMgr, err = results.NewResultManager(""); err != nil {
+		fmt.Printf("Failed to open results library: %v\n", err)
+		os.Exit(1)
+	}
+	if errorMgr, err = errors.NewErrorManager(""); err != nil {
+		fmt.Printf("Failed to open error manager: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Results library: %s\n", resultMgr.GetBaseDir())
+}
+
+// libraryEntry returns the library entry of a paper, or nil if it has none
+func libraryEntry(arxivID string) *results.PaperInfo {
+	existing, err := resultMgr.CheckExistingTranslation(arxivID, results.SourceTypeArxiv)
+	if err != nil || !existing.Exists {
+		return nil
+	}
+	return existing.PaperInfo
+}
+
+// isBatchEntry reports whether a library entry was produced by this tool and last went
+// through one of the given phases
+func isBatchEntry(info *results.PaperInfo, phases ...string) bool {
+	if info == nil || info.Origin != results.OriginBatch {
+		return false
+	}
+	for _, phase := range phases {
+		if info.LastPhase == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// recordOutcome records the outcome of a phase for a paper in the results library and the
+// error manager. Successful papers get their PDFs (and, once complete, their LaTeX source)
+// copied into the library like papers translated in the app.
+func recordOutcome(phase string, result *ProcessResult, status results.TranslationStatus) {
+	id := result.ArxivID
+	info := libraryEntry(id)
+	if info == nil {
+		info = &results.PaperInfo{
+			ArxivID:       id,
+			OriginalInput: id,
+			SourceType:    results.SourceTypeArxiv,
+			Origin:        results.OriginBatch,
+		}
+	}
+	if result.Title != "" {
+		info.Title = result.Title
+	} else if info.Title == "" {
+		info.Title = id
+	}
+	if result.MainTexFile != "" {
+		info.MainTexFile = result.MainTexFile
+	}
+	info.TranslatedAt = time.Now()
+	info.LastPhase = phase
+	info.Status = status
+	info.ErrorMessage = result.Error
+
+	if result.OriginalPDF != "" {
+		dst := resultMgr.GetOriginalPDFPath(id)
+		if err := copyFile(result.OriginalPDF, dst); err != nil {
+			logger.Warn("failed to copy original PDF", logger.String("arxivID", id), logger.Err(err))
+		} else {
+			info.OriginalPDF = dst
+		}
+	}
+	if result.TranslatedPDF != "" {
+		dst := resultMgr.GetTranslatedPDFPath(id)
+		if err := copyFile(result.TranslatedPDF, dst); err != nil {
+			logger.Warn("failed to copy translated PDF", logger.String("arxivID", id), logger.Err(err))
+		} else {
+			info.TranslatedPDF = dst
+		}
+	}
+	if status == results.StatusComplete && result.ExtractDir != "" {
+		dst := resultMgr.GetLatexSourceDir(id)
+		if err := copyDir(result.ExtractDir, dst); err != nil {
+			logger.Warn("failed to copy LaTeX source", logger.String("arxivID", id), logger.Err(err))
+		} else {
+			info.SourceDir = dst
+			info.HasLatexSource = true
+		}
+	}
+
+	if err := resultMgr.SavePaperInfo(info); err != nil {
+		logger.Error("failed to save paper info", err, logger.String("arxivID", id))
+	}
+
+	if result.Error != "" {
+		stage := result.Stage
+		if stage == "" {
+			stage = errors.StageTranslation
+		}
+		if err := errorMgr.RecordError(id, info.Title, id, stage, result.Error); err != nil {
+			logger.Warn("failed to record error", logger.String("arxivID", id), logger.Err(err))
+		}
+	} else if _, failed := errorMgr.GetError(id); failed {
+		if err := errorMgr.RemoveError(id); err != nil {
+			logger.Warn("failed to remove error record", logger.String("arxivID", id), logger.Err(err))
+		}
+	}
+}
+
+// importLegacyState creates library entries for papers only known from the text files of
+// earlier versions of this tool, so that their progress is not lost
+func importLegacyState() {
+	legacy := []struct {
+		file   string
+		phase  string
+		status results.TranslationStatus
+		stage  errors.ErrorStage
+	}{
+		{"arxiv_good_id.txt", phaseCompile, results.StatusOriginalCompiled, ""},
+		{"arxiv_bad_id.txt", phaseCompile, results.StatusError, errors.StageOriginalCompile},
+		{"arxiv_bug_id.txt", phaseTranslate, results.StatusError, errors.StageTranslatedCompile},
+	}
+	imported := 0
+	for _, l := range legacy {
+		ids, _ := readIDsFromFile(l.file)
+		for _, id := range ids {
+			if libraryEntry(id) != nil {
+				continue
+			}
+			result := &ProcessResult{ArxivID: id, Stage: l.stage}
+			if l.status == results.StatusError {
+				result.Error = "imported from " + l.file
+			}
+			recordOutcome(l.phase, result, l.status)
+			imported++
+		}
+	}
+	if imported > 0 {
+		fmt.Printf("Imported %d papers from legacy id files into the results library\n", imported)
+	}
+}
+
+// readTitle returns the title of a paper from its main tex file
+func readTitle(mainTexPath string) string {
+	content, err := os.ReadFile(mainTexPath)
+	if err != nil {
+		return ""
+	}
+	return results.ExtractTitleFromTeX(string(content))
+}
+
+// originalPDFPath returns the PDF produced by phase 1, or "" if there is none
+func originalPDFPath(extractDir, mainTexFile string) string {
+	path := filepath.Join(extractDir, "output_original", strings.TrimSuffix(mainTexFile, filepath.Ext(mainTexFile))+".pdf")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// copyFile copies a file from src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// copyDir recursively copies a directory, skipping compile output directories
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), "output_") {
+			return filepath.SkipDir
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
 // Phase 1: Download and compile original papers
-func processOriginal(arxivID string, workDir string) *ProcessResult {
+func processOriginal(arxivID string, workDir string, status *statusfile.Writer) *ProcessResult {
 	result := &ProcessResult{ArxivID: arxivID}
 	
 	extractDir := filepath.Join(workDir, strings.ReplaceAll(arxivID, "/", "_")+"_extracted")
@@ -148,18 +390,22 @@ func processOriginal(arxivID string, workDir string) *ProcessResult {
 		logger.Info("using existing extraction", logger.String("arxivID", arxivID))
 	} else {
 		// Download
+		setPhase(status, "downloading", 10, "下载源码...")
 		dl := downloader.NewSourceDownloader(workDir)
 		sourceInfo, err := dl.DownloadByID(arxivID)
 		if err != nil {
 			result.Error = fmt.Sprintf("download failed: %v", err)
+			result.Stage = errors.StageDownload
 			return result
 		}
 		result.Downloaded = true
 
 		// Extract
+		setPhase(status, "extracting", 30, "解压源码...")
 		sourceInfo, err = dl.ExtractZip(sourceInfo.ExtractDir)
 		if err != nil {
 			result.Error = fmt.Sprintf("extract failed: %v", err)
+			result.Stage = errors.StageExtract
 			return result
 		}
 		extractDir = sourceInfo.ExtractDir
@@ -170,15 +416,20 @@ func processOriginal(arxivID string, workDir string) *ProcessResult {
 	mainTexFile, err := dl.FindMainTexFile(extractDir)
 	if err != nil {
 		result.Error = fmt.Sprintf("find main tex failed: %v", err)
+		result.Stage = errors.StageExtract
 		return result
 	}
 
 	mainTexPath := filepath.Join(extractDir, mainTexFile)
+	result.ExtractDir = extractDir
+	result.MainTexFile = mainTexFile
+	result.Title = readTitle(mainTexPath)
 
 	// Preprocess
 	compiler.PreprocessTexFiles(extractDir)
 
 	// Compile original
+	setPhase(status, "compiling", 60, "编译原始文档...")
 	comp := compiler.NewLaTeXCompiler("pdflatex", workDir, 10*time.Minute)
 	outputDir := filepath.Join(extractDir, "output_original")
 	compResult, err := comp.Compile(mainTexPath, outputDir)
@@ -187,17 +438,19 @@ func processOriginal(arxivID string, workDir string) *ProcessResult {
 		if compResult != nil && compResult.ErrorMsg != "" {
 			result.Error = compResult.ErrorMsg
 		}
+		result.Stage = errors.StageOriginalCompile
 		return result
 	}
 
 	result.Compiled = true
 	result.Downloaded = true
+	result.OriginalPDF = compResult.PDFPath
 	return result
 }
 
 // Phase 2: Translate and generate PDF
-func processTranslate(arxivID string, workDir string) *ProcessResult {
-	result := &ProcessResult{ArxivID: arxivID, Downloaded: true, Compiled: true}
+func processTranslate(arxivID string, workDir string, status *statusfile.Writer) *ProcessResult {
+	result := &ProcessResult{ArxivID: arxivID, Downloaded: true, Compiled: true, Stage: errors.StageTranslation}
 	
 	extractDir := filepath.Join(workDir, strings.ReplaceAll(arxivID, "/", "_")+"_extracted")
 	
@@ -206,10 +459,14 @@ func processTranslate(arxivID string, workDir string) *ProcessResult {
 	mainTexFile, err := dl.FindMainTexFile(extractDir)
 	if err != nil {
 		result.Error = fmt.Sprintf("find main tex failed: %v", err)
+		result.Stage = errors.StageExtract
 		return result
 	}
 
 	mainTexPath := filepath.Join(extractDir, mainTexFile)
+	result.ExtractDir = extractDir
+	result.MainTexFile = mainTexFile
+	result.Title = readTitle(mainTexPath)
 
 	// Read content
 	content, err := os.ReadFile(mainTexPath)
@@ -241,74 +498,77 @@ func processTranslate(arxivID string, workDir string) *ProcessResult {
 	}
 
 	engine := translator.NewTranslationEngineWithConfig(apiKey, model, baseURL, 0, 3)
+	setPhase(status, "translating", 0, "翻译中...")
 	transResult, err := engine.TranslateTeXWithProgress(string(content), func(current, total int, message string) {
-		// Progress callback
+		progress := engine.Progress()
+		status.Update(func(s *statusfile.Status) {
+			if total > 0 {
+				s.Percent = current * 80 / total
+			}
+			s.Message = message
+			s.Section = progress.Section
+			s.Chunk = current
+			s.TotalChunks = total
+			s.TokensUsed = progress.TokensUsed
+		})
 	})
 	if err != nil {
 		result.Error = fmt.Sprintf("translate failed: %v", err)
 		return result
 	}
 	result.Translated = true
+	status.Update(func(s *statusfile.Status) { s.TokensUsed = transResult.TokensUsed })
+	if transResult.ParagraphBreakFixes > 0 {
+		status.Warn(fmt.Sprintf("已按原文修正 %d 处段落分隔", transResult.ParagraphBreakFixes))
+	}
+	if transResult.NetworkPauses > 0 {
+		status.Warn(fmt.Sprintf("网络中断 %d 次，暂停 %.0f 秒", transResult.NetworkPauses, transResult.NetworkPausedSecs))
+	}
+	if transResult.Stalls > 0 {
+		status.Warn(fmt.Sprintf("翻译请求 %d 次无响应，已重建连接重试", transResult.Stalls))
+	}
+	if transResult.Generator != "" {
+		status.Warn(fmt.Sprintf("由 %s 生成，代码块和辅助宏已原样保留", transResult.Generator))
+	}
+	if transResult.Continuations > 0 || transResult.TruncatedChunks > 0 {
+		status.Warn(translator.FormatTruncationSummary(transResult))
+	}
 
-	// Add ctex package
-	translatedContent := ensureCtexPackage(transResult.TranslatedContent)
+	// Run the same post-processing pipeline as the GUI
+	translatedContent := postprocess.Run(postprocess.File{
+		Content:  transResult.TranslatedContent,
+		Original: string(content),
+		Main:     true,
+	}, postprocess.Options{})
 
 	// Save translated file
 	translatedTexPath := filepath.Join(extractDir, "translated_"+mainTexFile)
+	translatedContent, _ = postprocess.TagBibliographyLanguages(translatedContent, filepath.Dir(translatedTexPath))
 	if err := os.WriteFile(translatedTexPath, []byte(translatedContent), 0644); err != nil {
 		result.Error = fmt.Sprintf("save translated failed: %v", err)
 		return result
 	}
 
 	// Compile translated
+	setPhase(status, "compiling", 85, "编译译文...")
 	comp := compiler.NewLaTeXCompiler("xelatex", workDir, 10*time.Minute)
 	outputDir := filepath.Join(extractDir, "output_translated")
-	compResult, err := comp.CompileWithXeLaTeX(translatedTexPath, outputDir)
+	compResult, err := comp.CompileTranslatedWithFallback(translatedTexPath, outputDir, compiler.CompilerXeLaTeX)
 	if err != nil || !compResult.Success {
 		result.Error = fmt.Sprintf("compile translated failed: %v", err)
 		if compResult != nil && compResult.ErrorMsg != "" {
 			result.Error = compResult.ErrorMsg
 		}
+		result.Stage = errors.StageTranslatedCompile
 		return result
 	}
 
 	result.PDFGenerated = true
+	result.TranslatedPDF = compResult.PDFPath
+	result.OriginalPDF = originalPDFPath(extractDir, mainTexFile)
 	return result
 }
 
-func ensureCtexPackage(content string) string {
-	if strings.Contains(content, "\\usepackage{ctex}") || strings.Contains(content, "\\usepackage[") && strings.Contains(content, "ctex") {
-		return content
-	}
-
-	lines := strings.Split(content, "\n")
-	var result []string
-	added := false
-
-	for _, line := range lines {
-		result = append(result, line)
-		if added || strings.HasPrefix(strings.TrimSpace(line), "%") {
-			continue
-		}
-		if strings.Contains(line, "\\documentclass") {
-			result = append(result, "\\usepackage{ctex}")
-			added = true
-		}
-	}
-
-	if added {
-		content = strings.Join(result, "\n")
-	}
-
-	// Fix microtype for XeLaTeX
-	if strings.Contains(content, "\\usepackage{microtype}") {
-		content = strings.Replace(content, "\\usepackage{microtype}",
-			"\\usepackage[protrusion=false,expansion=false]{microtype}", 1)
-	}
-
-	return content
-}
-
 
 func main() {
 	config = loadConfig()
@@ -326,6 +586,9 @@ func main() {
 		phase = os.Args[1]
 	}
 
+	openLibrary()
+	importLegacyState()
+
 	switch phase {
 	case "compile", "1":
 		runPhase1(workDir)
@@ -358,47 +621,41 @@ func runPhase1(workDir string) {
 	}
 	fmt.Printf("Total papers to process: %d\n", len(allIDs))
 
-	// Check for existing progress
-	existingGood, _ := readIDsFromFile("arxiv_good_id.txt")
-	existingBad, _ := readIDsFromFile("arxiv_bad_id.txt")
-	processed := make(map[string]bool)
-	for _, id := range existingGood {
-		processed[id] = true
-	}
-	for _, id := range existingBad {
-		processed[id] = true
-	}
-
-	// Filter out already processed
+	// Papers with a library entry (from an earlier run or from the app) are done
 	var toProcess []string
 	for _, id := range allIDs {
-		if !processed[id] {
+		if libraryEntry(id) == nil {
 			toProcess = append(toProcess, id)
 		}
 	}
-	fmt.Printf("Already processed: %d, Remaining: %d\n", len(processed), len(toProcess))
+	fmt.Printf("Already processed: %d, Remaining: %d\n", len(allIDs)-len(toProcess), len(toProcess))
 
 	// Process papers
 	successCount := 0
 	failCount := 0
+	batchStatus := statusfile.NewBatchWriter(filepath.Join(workDir, statusfile.BatchFileName), "compile", toProcess)
 	
 	for i, id := range toProcess {
 		fmt.Printf("\n[%d/%d] Processing %s...\n", i+1, len(toProcess), id)
 		
-		result := processOriginal(id, workDir)
+		status := batchStatus.Track(id, paperStatusPath(workDir, id))
+		status.Start(statusfile.DefaultInterval, nil)
+		result := processOriginal(id, workDir, status)
+		finishPaperStatus(status, result.Compiled, result.Error)
 		
+		// The id files are kept as exports for older scripts
 		if result.Compiled {
 			fmt.Printf("  ✓ Success\n")
+			recordOutcome(phaseCompile, result, results.StatusOriginalCompiled)
 			appendToFile("arxiv_good_id.txt", []string{id})
 			successCount++
 		} else {
 			fmt.Printf("  ✗ Failed: %s\n", result.Error)
+			recordOutcome(phaseCompile, result, results.StatusError)
 			appendToFile("arxiv_bad_id.txt", []string{id})
 			failCount++
 		}
-
-		// Rate limiting for arXiv
-		time.Sleep(3 * time.Second)
+		// Downloads are spaced by the downloader's shared arXiv rate limiter
 	}
 
 	fmt.Printf("\n=== Phase 1 Complete ===\n")
@@ -408,48 +665,49 @@ func runPhase1(workDir string) {
 func runPhase2(workDir string) {
 	fmt.Println("\n=== Phase 2: Translate and Generate PDFs ===")
 	
-	// Read good IDs
-	goodIDList, err := readIDsFromFile("arxiv_good_id.txt")
+	allIDs, err := readIDsFromFile("arxiv_id.txt")
 	if err != nil {
-		fmt.Printf("Failed to read arxiv_good_id.txt: %v\n", err)
+		fmt.Printf("Failed to read arxiv_id.txt: %v\n", err)
 		return
 	}
-	fmt.Printf("Papers to translate: %d\n", len(goodIDList))
-
-	// Check for existing progress
-	existingBug, _ := readIDsFromFile("arxiv_bug_id.txt")
-	processed := make(map[string]bool)
-	for _, id := range existingBug {
-		processed[id] = true
-	}
 
-	// Check which ones already have translated PDFs
+	// Papers whose original compiled in phase 1 and that were not translated yet
 	var toTranslate []string
-	for _, id := range goodIDList {
-		if processed[id] {
+	compiled := 0
+	for _, id := range allIDs {
+		info := libraryEntry(id)
+		if info == nil || info.Origin != results.OriginBatch {
 			continue
 		}
-		extractDir := filepath.Join(workDir, strings.ReplaceAll(id, "/", "_")+"_extracted")
-		translatedPDF := filepath.Join(extractDir, "output_translated")
-		if _, err := os.Stat(translatedPDF); err != nil {
+		if info.Status == results.StatusOriginalCompiled {
 			toTranslate = append(toTranslate, id)
 		}
+		if info.LastPhase != phaseCompile || info.Status == results.StatusOriginalCompiled {
+			compiled++
+		}
 	}
-	fmt.Printf("Already translated: %d, Remaining: %d\n", len(goodIDList)-len(toTranslate), len(toTranslate))
+	fmt.Printf("Papers to translate: %d\n", compiled)
+	fmt.Printf("Already translated: %d, Remaining: %d\n", compiled-len(toTranslate), len(toTranslate))
 
 	successCount := 0
 	failCount := 0
+	batchStatus := statusfile.NewBatchWriter(filepath.Join(workDir, statusfile.BatchFileName), "translate", toTranslate)
 
 	for i, id := range toTranslate {
 		fmt.Printf("\n[%d/%d] Translating %s...\n", i+1, len(toTranslate), id)
 		
-		result := processTranslate(id, workDir)
+		status := batchStatus.Track(id, paperStatusPath(workDir, id))
+		status.Start(statusfile.DefaultInterval, nil)
+		result := processTranslate(id, workDir, status)
+		finishPaperStatus(status, result.PDFGenerated, result.Error)
 		
 		if result.PDFGenerated {
 			fmt.Printf("  ✓ Success\n")
+			recordOutcome(phaseTranslate, result, results.StatusComplete)
 			successCount++
 		} else {
 			fmt.Printf("  ✗ Failed: %s\n", result.Error)
+			recordOutcome(phaseTranslate, result, results.StatusError)
 			appendToFile("arxiv_bug_id.txt", []string{id})
 			failCount++
 		}
@@ -465,43 +723,61 @@ func runPhase2(workDir string) {
 func runPhase3(workDir string) {
 	fmt.Println("\n=== Phase 3: Fix and Retry Failed Translations ===")
 	
-	// Read bug IDs
-	bugIDList, err := readIDsFromFile("arxiv_bug_id.txt")
+	allIDs, err := readIDsFromFile("arxiv_id.txt")
 	if err != nil {
-		fmt.Printf("No arxiv_bug_id.txt found or empty\n")
+		fmt.Printf("Failed to read arxiv_id.txt: %v\n", err)
+		return
+	}
+
+	// Papers whose translation or translated compile failed
+	var bugIDList []string
+	for _, id := range allIDs {
+		info := libraryEntry(id)
+		if isBatchEntry(info, phaseTranslate, phaseFix) && info.Status == results.StatusError {
+			bugIDList = append(bugIDList, id)
+		}
+	}
+	if len(bugIDList) == 0 {
+		fmt.Printf("No failed translations in the results library\n")
 		return
 	}
 	fmt.Printf("Papers to fix: %d\n", len(bugIDList))
 
 	fixedCount := 0
 	stillBroken := []string{}
+	batchStatus := statusfile.NewBatchWriter(filepath.Join(workDir, statusfile.BatchFileName), "fix", bugIDList)
 
 	for i, id := range bugIDList {
 		fmt.Printf("\n[%d/%d] Fixing %s...\n", i+1, len(bugIDList), id)
 		
 		// Try to fix and recompile
-		result := tryFixAndTranslate(id, workDir)
+		status := batchStatus.Track(id, paperStatusPath(workDir, id))
+		status.Start(statusfile.DefaultInterval, nil)
+		result := tryFixAndTranslate(id, workDir, status)
+		finishPaperStatus(status, result.PDFGenerated, result.Error)
 		
 		if result.PDFGenerated {
 			fmt.Printf("  ✓ Fixed successfully\n")
+			recordOutcome(phaseFix, result, results.StatusComplete)
 			fixedCount++
 		} else {
 			fmt.Printf("  ✗ Still broken: %s\n", result.Error)
+			recordOutcome(phaseFix, result, results.StatusError)
 			stillBroken = append(stillBroken, id)
 		}
 
 		time.Sleep(2 * time.Second)
 	}
 
-	// Update bug file with remaining broken IDs
+	// Update the exported bug file with remaining broken IDs
 	writeIDsToFile("arxiv_bug_id.txt", stillBroken)
 
 	fmt.Printf("\n=== Phase 3 Complete ===\n")
 	fmt.Printf("Fixed: %d, Still broken: %d\n", fixedCount, len(stillBroken))
 }
 
-func tryFixAndTranslate(arxivID string, workDir string) *ProcessResult {
-	result := &ProcessResult{ArxivID: arxivID, Downloaded: true, Compiled: true}
+func tryFixAndTranslate(arxivID string, workDir string, status *statusfile.Writer) *ProcessResult {
+	result := &ProcessResult{ArxivID: arxivID, Downloaded: true, Compiled: true, Stage: errors.StageTranslatedCompile}
 	
 	extractDir := filepath.Join(workDir, strings.ReplaceAll(arxivID, "/", "_")+"_extracted")
 	
@@ -510,15 +786,19 @@ func tryFixAndTranslate(arxivID string, workDir string) *ProcessResult {
 	mainTexFile, err := dl.FindMainTexFile(extractDir)
 	if err != nil {
 		result.Error = fmt.Sprintf("find main tex failed: %v", err)
+		result.Stage = errors.StageExtract
 		return result
 	}
+	result.ExtractDir = extractDir
+	result.MainTexFile = mainTexFile
+	result.Title = readTitle(filepath.Join(extractDir, mainTexFile))
 
 	translatedTexPath := filepath.Join(extractDir, "translated_"+mainTexFile)
 	
 	// Check if translated file exists
 	if _, err := os.Stat(translatedTexPath); os.IsNotExist(err) {
 		// Need to translate first
-		return processTranslate(arxivID, workDir)
+		return processTranslate(arxivID, workDir, status)
 	}
 
 	// Try to apply fixes to the translated file
@@ -537,9 +817,10 @@ func tryFixAndTranslate(arxivID string, workDir string) *ProcessResult {
 	}
 
 	// Try to compile again
+	setPhase(status, "compiling", 85, "重新编译译文...")
 	comp := compiler.NewLaTeXCompiler("xelatex", workDir, 10*time.Minute)
 	outputDir := filepath.Join(extractDir, "output_translated")
-	compResult, err := comp.CompileWithXeLaTeX(translatedTexPath, outputDir)
+	compResult, err := comp.CompileTranslatedWithFallback(translatedTexPath, outputDir, compiler.CompilerXeLaTeX)
 	if err != nil || !compResult.Success {
 		result.Error = fmt.Sprintf("compile still failed: %v", err)
 		if compResult != nil && compResult.ErrorMsg != "" {
@@ -550,34 +831,27 @@ func tryFixAndTranslate(arxivID string, workDir string) *ProcessResult {
 
 	result.PDFGenerated = true
 	result.Translated = true
+	result.TranslatedPDF = compResult.PDFPath
+	result.OriginalPDF = originalPDFPath(extractDir, mainTexFile)
 	return result
 }
 
-func applyTranslationFixes(content string) string {
-	// Fix 1: Ensure ctex package
-	content = ensureCtexPackage(content)
-	
-	// Fix 2: Fix common XeLaTeX issues
-	// Remove incompatible packages
-	incompatiblePackages := []string{
-		"\\usepackage{times}",
-		"\\usepackage{mathptmx}",
-	}
-	for _, pkg := range incompatiblePackages {
-		content = strings.Replace(content, pkg, "% "+pkg+" % disabled for XeLaTeX", 1)
-	}
-
-	// Fix 3: Fix fontenc for XeLaTeX
-	if strings.Contains(content, "\\usepackage[T1]{fontenc}") {
-		content = strings.Replace(content, "\\usepackage[T1]{fontenc}", 
-			"% \\usepackage[T1]{fontenc} % disabled for XeLaTeX", 1)
+// finishPaperStatus writes the final status of a paper
+func finishPaperStatus(status *statusfile.Writer, success bool, errMsg string) {
+	if success {
+		status.Finish(nil)
+		return
 	}
-
-	// Fix 4: Fix inputenc for XeLaTeX
-	if strings.Contains(content, "\\usepackage[utf8]{inputenc}") {
-		content = strings.Replace(content, "\\usepackage[utf8]{inputenc}",
-			"% \\usepackage[utf8]{inputenc} % disabled for XeLaTeX", 1)
+	if errMsg == "" {
+		errMsg = "failed"
 	}
+	status.Finish(fmt.Errorf("%s", errMsg))
+}
 
-	return content
+// applyTranslationFixes prepares a translated main file for XeLaTeX with the shared preamble
+// fixes; CompileTranslatedWithFallback adapts it again for the engine it ends up using
+func applyTranslationFixes(content string) string {
+	content = compiler.EnsureCJKSupport(content, compiler.CompilerXeLaTeX)
+	content = compiler.DisableIncompatiblePackages(content, compiler.CompilerXeLaTeX)
+	return compiler.FixMicrotype(content)
 }
@@ -10,7 +10,7 @@ import (
 	"fmt"
 	"os"
 
-	"latex-translator/internal/pdf"
+	"latex-translator/internal/qa"
 )
 
 func main() {
@@ -43,21 +43,16 @@ func main() {
 	fmt.Printf("  Original:   %s\n", originalPath)
 	fmt.Printf("  Translated: %s\n\n", translatedPath)
 
-	// Create validator
-	validator := pdf.NewContentValidator("")
-
-	// Run validation
-	result, err := validator.ValidateContent(originalPath, translatedPath)
+	report, err := qa.ComparePDFs(originalPath, translatedPath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Print formatted result
-	fmt.Println(pdf.FormatValidationResult(result))
+	fmt.Print(qa.FormatText(report))
 
 	// Exit with error code if content is incomplete
-	if !result.IsComplete {
+	if !report.Content.IsComplete {
 		os.Exit(2)
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -11,6 +12,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"latex-translator/internal/downloader"
+	"latex-translator/internal/netproxy"
 )
 
 // ArxivEntry 表示一篇 arXiv 论文
@@ -117,11 +121,15 @@ var searchQueries = []string{
 
 var (
 	httpClient = &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   30 * time.Second,
+		Transport: netproxy.NewTransport(),
 	}
 	
 	// 用于限制并发检查源码
 	semaphore = make(chan struct{}, 5)
+
+	// 源码检查经过 downloader 的共享限速器（每 3 秒 1 次请求），429/503 时自动退避
+	sourceChecker = downloader.NewSourceDownloader("")
 )
 
 func main() {
@@ -204,9 +212,6 @@ func main() {
 			if currentCount%50 == 0 {
 				saveProgress(allEntries)
 			}
-			
-			// arXiv API 限制：每 3 秒最多 1 次请求
-			time.Sleep(3 * time.Second)
 		}
 	}
 	
@@ -282,6 +287,10 @@ func searchArxiv(query string, start, maxResults int) ([]ArxivEntry, error) {
 	
 	apiURL := "http://export.arxiv.org/api/query?" + params.Encode()
 	
+	// arXiv API 限制：每 3 秒最多 1 次请求，与源码检查共用限速器
+	if err := downloader.WaitForRequest(context.Background()); err != nil {
+		return nil, err
+	}
 	resp, err := httpClient.Get(apiURL)
 	if err != nil {
 		return nil, err
@@ -319,47 +328,12 @@ func searchArxiv(query string, start, maxResults int) ([]ArxivEntry, error) {
 // hasLatexSource 检查论文是否有 LaTeX 源码
 // 通过 HEAD 请求检查 e-print 端点
 func hasLatexSource(arxivID string) bool {
-	// arXiv 源码下载地址
-	sourceURL := fmt.Sprintf("https://arxiv.org/e-print/%s", arxivID)
-	
-	req, err := http.NewRequest("HEAD", sourceURL, nil)
+	available, err := sourceChecker.CheckSourceAvailable(arxivID)
 	if err != nil {
+		fmt.Printf("  检查 %s 源码失败: %v\n", arxivID, err)
 		return false
 	}
-	
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	
-	// 检查响应状态和内容类型
-	if resp.StatusCode != http.StatusOK {
-		return false
-	}
-	
-	contentType := resp.Header.Get("Content-Type")
-	// LaTeX 源码通常是 gzip 压缩的 tar 包
-	// application/x-eprint-tar, application/gzip, application/x-gzip
-	if strings.Contains(contentType, "gzip") || 
-	   strings.Contains(contentType, "tar") ||
-	   strings.Contains(contentType, "x-eprint") {
-		return true
-	}
-	
-	// 有些可能是单个 tex 文件
-	if strings.Contains(contentType, "x-tex") ||
-	   strings.Contains(contentType, "text/plain") {
-		return true
-	}
-	
-	// 排除纯 PDF（没有源码）
-	if strings.Contains(contentType, "pdf") {
-		return false
-	}
-	
-	// 其他情况也可能有源码
-	return resp.StatusCode == http.StatusOK
+	return available
 }
 
 func extractArxivID(idURL string) string {
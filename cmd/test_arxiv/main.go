@@ -6,12 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 
 	"latex-translator/internal/compiler"
 	"latex-translator/internal/downloader"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/postprocess"
 	"latex-translator/internal/translator"
 )
 
@@ -186,11 +185,13 @@ func main() {
 
 	fmt.Printf("Translation completed! Tokens used: %d\n", result.TokensUsed)
 
-	// Add ctex package for Chinese support
-	translatedContent := ensureCtexPackage(result.TranslatedContent)
-	
-	// Fix caption and other syntax issues
-	translatedContent = fixTranslationIssues(translatedContent)
+	// Run the same post-processing pipeline as the GUI, with the Chinese support of LuaLaTeX,
+	// which is tried first
+	translatedContent := postprocess.Run(postprocess.File{
+		Content:  result.TranslatedContent,
+		Original: string(content),
+		Main:     true,
+	}, postprocess.Options{Engine: compiler.CompilerLuaLaTeX})
 
 	// Save translated file
 	translatedTexPath := filepath.Join(sourceInfo.ExtractDir, "translated_"+mainTexFile)
@@ -250,139 +251,3 @@ func splitLines(s string) []string {
 	return lines
 }
 
-// ensureCtexPackage adds ctex package for Chinese support if not already present.
-// It also fixes microtype compatibility issues with XeLaTeX.
-func ensureCtexPackage(content string) string {
-	// Check if ctex is already included (must be uncommented)
-	// We need to check line by line to avoid matching commented lines like "% \usepackage{ctex}"
-	hasUncommentedCtex := false
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		// Skip commented lines
-		if strings.HasPrefix(trimmed, "%") {
-			continue
-		}
-		// Check for ctex package (with or without options)
-		if strings.Contains(line, "\\usepackage{ctex}") || 
-		   (strings.Contains(line, "\\usepackage[") && strings.Contains(line, "ctex}")) {
-			hasUncommentedCtex = true
-			break
-		}
-	}
-	
-	if hasUncommentedCtex {
-		logger.Debug("ctex package already present (uncommented)")
-	} else {
-		// Find the first uncommented \documentclass line and add \usepackage{ctex} after it
-		var result []string
-		added := false
-
-		for _, line := range lines {
-			result = append(result, line)
-			// Skip if already added or if line is commented
-			if added || strings.HasPrefix(strings.TrimSpace(line), "%") {
-				continue
-			}
-			// Check if this line contains \documentclass
-			if strings.Contains(line, "\\documentclass") {
-				result = append(result, "\\usepackage{ctex}")
-				added = true
-				logger.Info("added ctex package for Chinese support")
-			}
-		}
-
-		if added {
-			content = strings.Join(result, "\n")
-		} else {
-			logger.Warn("could not find \\documentclass to add ctex package")
-		}
-	}
-
-	// Fix microtype compatibility with XeLaTeX
-	// microtype with expansion/protrusion can cause "Cannot use XeTeXglyph" errors
-	// Replace \usepackage{microtype} with a XeLaTeX-compatible version
-	if strings.Contains(content, "\\usepackage{microtype}") {
-		content = strings.Replace(content, "\\usepackage{microtype}",
-			"\\usepackage[protrusion=false,expansion=false]{microtype}", 1)
-		logger.Info("fixed microtype package for XeLaTeX compatibility")
-	}
-
-	// Also handle microtype with options
-	microtypePattern := regexp.MustCompile(`\\usepackage\[([^\]]*)\]\{microtype\}`)
-	if microtypePattern.MatchString(content) {
-		// Check if protrusion/expansion are already disabled
-		if !strings.Contains(content, "protrusion=false") {
-			content = microtypePattern.ReplaceAllString(content,
-				"\\usepackage[protrusion=false,expansion=false]{microtype}")
-			logger.Info("fixed microtype package options for XeLaTeX compatibility")
-		}
-	}
-
-	// Fix nested tabular structures that were split across multiple lines
-	content = fixNestedTabularStructure(content)
-
-	return content
-}
-
-// fixNestedTabularStructure fixes nested tabular structures that were incorrectly split across multiple lines.
-func fixNestedTabularStructure(content string) string {
-	// Pattern to match nested tabular that should be on single line
-	pattern := regexp.MustCompile(`(\\begin\{tabular\}\[[^\]]+\]\{[^}]+\})([\s\S]*?)(\\end\{tabular\}\})`)
-	
-	fixed := false
-	content = pattern.ReplaceAllStringFunc(content, func(match string) string {
-		if strings.Contains(match, "\n") {
-			result := strings.ReplaceAll(match, "\n", " ")
-			result = strings.ReplaceAll(result, "\r", "")
-			for strings.Contains(result, "  ") {
-				result = strings.ReplaceAll(result, "  ", " ")
-			}
-			fixed = true
-			return result
-		}
-		return match
-	})
-	
-	if fixed {
-		logger.Debug("fixed nested tabular structures that were split across lines")
-	}
-	
-	// Remove extra } after \end{tabular} or \end{tabular}}
-	extraBracePattern := regexp.MustCompile(`(\\end\{tabular\}\}?)\s*\n\}\s*\n`)
-	for extraBracePattern.MatchString(content) {
-		content = extraBracePattern.ReplaceAllString(content, "$1\n")
-		logger.Debug("removed extra closing braces after tabular")
-	}
-	
-	// Fix \end{table without closing brace - this happens when LLM corrupts the structure
-	// Pattern: \end{table followed by space or backslash (not })
-	incompleteEndTablePattern := regexp.MustCompile(`\\end\{table([^}*])`)
-	if incompleteEndTablePattern.MatchString(content) {
-		content = incompleteEndTablePattern.ReplaceAllString(content, "\\end{table}$1")
-		logger.Debug("fixed incomplete \\end{table} commands")
-	}
-	
-	// Fix \end{tabular without closing brace
-	incompleteEndTabularPattern := regexp.MustCompile(`\\end\{tabular([^}*])`)
-	if incompleteEndTabularPattern.MatchString(content) {
-		content = incompleteEndTabularPattern.ReplaceAllString(content, "\\end{tabular}$1")
-		logger.Debug("fixed incomplete \\end{tabular} commands")
-	}
-	
-	return content
-}
-
-// fixTranslationIssues fixes common translation issues
-func fixTranslationIssues(content string) string {
-	// Fix 1: Fix unbalanced braces in \textbf{\textcolor{...}{\ARCH} patterns
-	pattern1 := regexp.MustCompile(`\\textbf\{\\textcolor\{([^}]+)\}\{\\ARCH\}\s*与`)
-	content = pattern1.ReplaceAllString(content, `\textbf{\textcolor{$1}{\ARCH}}与`)
-	
-	// Fix 2: Fix any remaining unbalanced braces in textbf/textcolor combinations
-	pattern2 := regexp.MustCompile(`\\textbf\{\\textcolor\{orange\}\{\\ARCH\}\s+`)
-	content = pattern2.ReplaceAllString(content, `\textbf{\textcolor{orange}{\ARCH}} `)
-	
-	logger.Info("applied translation fixes")
-	return content
-}
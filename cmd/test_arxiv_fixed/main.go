@@ -6,12 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
 
 	"latex-translator/internal/compiler"
 	"latex-translator/internal/downloader"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/postprocess"
 	"latex-translator/internal/translator"
 )
 
@@ -186,11 +185,12 @@ func main() {
 
 	fmt.Printf("Translation completed! Tokens used: %d\n", result.TokensUsed)
 
-	// Add ctex package for Chinese support
-	translatedContent := ensureCtexPackage(result.TranslatedContent)
-	
-	// Fix caption issues
-	translatedContent = fixCaptionIssues(translatedContent)
+	// Run the same post-processing pipeline as the GUI
+	translatedContent := postprocess.Run(postprocess.File{
+		Content:  result.TranslatedContent,
+		Original: string(content),
+		Main:     true,
+	}, postprocess.Options{})
 
 	// Save translated file
 	translatedTexPath := filepath.Join(sourceInfo.ExtractDir, "translated_"+mainTexFile)
@@ -240,69 +240,3 @@ func splitLines(s string) []string {
 	return lines
 }
 
-// ensureCtexPackage adds ctex package for Chinese support if not already present.
-// It also fixes microtype compatibility issues with XeLaTeX.
-func ensureCtexPackage(content string) string {
-	// Check if ctex is already included
-	if strings.Contains(content, "\\usepackage{ctex}") || (strings.Contains(content, "\\usepackage[") && strings.Contains(content, "ctex")) {
-		logger.Debug("ctex package already present")
-	} else {
-		// Find the first uncommented \documentclass line and add \usepackage{ctex} after it
-		lines := strings.Split(content, "\n")
-		var result []string
-		added := false
-
-		for _, line := range lines {
-			result = append(result, line)
-			// Skip if already added or if line is commented
-			if added || strings.HasPrefix(strings.TrimSpace(line), "%") {
-				continue
-			}
-			// Check if this line contains \documentclass
-			if strings.Contains(line, "\\documentclass") {
-				result = append(result, "\\usepackage{ctex}")
-				added = true
-				logger.Info("added ctex package for Chinese support")
-			}
-		}
-
-		if added {
-			content = strings.Join(result, "\n")
-		} else {
-			logger.Warn("could not find \\documentclass to add ctex package")
-		}
-	}
-
-	// Fix microtype compatibility with XeLaTeX
-	// microtype with expansion/protrusion can cause "Cannot use XeTeXglyph" errors
-	// Replace \usepackage{microtype} with a XeLaTeX-compatible version
-	if strings.Contains(content, "\\usepackage{microtype}") {
-		content = strings.Replace(content, "\\usepackage{microtype}",
-			"\\usepackage[protrusion=false,expansion=false]{microtype}", 1)
-		logger.Info("fixed microtype package for XeLaTeX compatibility")
-	}
-
-	// Also handle microtype with options
-	microtypePattern := regexp.MustCompile(`\\usepackage\[([^\]]*)\]\{microtype\}`)
-	if microtypePattern.MatchString(content) {
-		// Check if protrusion/expansion are already disabled
-		if !strings.Contains(content, "protrusion=false") {
-			content = microtypePattern.ReplaceAllString(content,
-				"\\usepackage[protrusion=false,expansion=false]{microtype}")
-			logger.Info("fixed microtype package options for XeLaTeX compatibility")
-		}
-	}
-
-	return content
-}
-
-// fixCaptionIssues fixes common caption-related issues in translated content
-func fixCaptionIssues(content string) string {
-	// Fix unbalanced braces in \textbf{\textcolor{...}{\ARCH} patterns
-	// Pattern: \textbf{\textcolor{color}{\ARCH} should be \textbf{\textcolor{color}{\ARCH}}
-	pattern := regexp.MustCompile(`\\textbf\{\\textcolor\{([^}]+)\}\{\\ARCH\}\s+`)
-	content = pattern.ReplaceAllString(content, `\textbf{\textcolor{$1}{\ARCH}} `)
-	
-	logger.Info("fixed caption brace issues")
-	return content
-}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"latex-translator/internal/parser"
 )
 
 func main() {
@@ -175,6 +177,9 @@ func compileLatex(mainFile string) error {
 }
 
 func validateLatexSyntax(content string) error {
+	// Code listings may hold unbalanced braces
+	content = parser.BlankVerbatim(content)
+
 	// Check for unmatched braces
 	openBraces := strings.Count(content, "{")
 	closeBraces := strings.Count(content, "}")
@@ -14,7 +14,7 @@ import './style.css';
 import './app.css';
 
 // Wails runtime for file dialogs and events
-import { EventsOn } from '../wailsjs/runtime/runtime.js';
+import { EventsOn, ClipboardSetText } from '../wailsjs/runtime/runtime.js';
 
 // Error Management module
 import { initErrorManagement } from './errors.js';
@@ -38,7 +38,7 @@ let ProcessSource, ProcessSourceWithForce, CheckExistingTranslation, GetStatus,
 let OpenPDFFileDialog, LoadPDF, TranslatePDF, GetPDFStatus, CancelPDFTranslation, GetTranslatedPDFPath, SaveTranslatedPDF;
 
 // Results Management bindings
-let ListTranslatedPapers, DeleteTranslatedPaper, RetranslateFromArxiv, OpenPaperResult, ContinueTranslation;
+let ListTranslatedPapers, DeleteTranslatedPaper, RetranslateFromArxiv, OpenPaperResult, ContinueTranslation, TakeInterruptedJobs, GetInterruptedJobs;
 
 // GitHub Share bindings
 let CheckShareStatus, ShareToGitHub, TestGitHubConnection, CheckShareStatusForPaper, SharePaperToGitHub, CheckShareStatusWithCategory;
@@ -52,6 +52,60 @@ let ListErrors, RetryFromError, ClearError, ClearAllErrors, ExportErrorsToFile,
 // Paper Categories binding
 let GetPaperCategories;
 
+// Chunking preview bindings
+let PreviewChunking, SetFileOverrides, EstimateSource;
+
+// Job confirmation bindings
+let PrepareJob, ConfirmJob, DiscardJob;
+
+// Manual-fix handoff bindings
+let SkipRemainingFixes, ReprocessFromTranslatedTex, ApproveFix, RetryFailedChunks;
+
+// Main tex file choice binding
+let ChooseMainTex;
+
+// Chinese script binding
+let SetChineseVariant;
+
+// Target language binding
+let SetTargetLanguage;
+
+// Glossary binding
+let SetGlossaryPath;
+
+// Prompt preset binding
+let SetPromptPreset;
+
+// Request timeout and translation budget bindings
+let SetRequestTimeout, SetTranslationBudget;
+
+// Compile process limit binding
+let SetMaxConcurrentCompiles;
+let SetStrictFontEmbedding;
+let CheckWorkDirectory;
+// Work directory cleanup bindings
+let GetWorkspacePolicy, SetWorkspacePolicy, GetWorkspaceUsage, CleanWorkspaces;
+// Comment translation binding
+let SetTranslateComments;
+// Bibliography translation binding
+let SetTranslateBibliography;
+// Chunk overlap binding
+let SetChunkOverlap;
+// Automatic package installation binding
+let SetAutoInstallPackages;
+// \includeonly handling binding
+let SetIgnoreIncludeOnly;
+
+// Quick mode bindings
+let SetQuickMode, GetQuickModeDowngrades, UpgradeToFullTranslation;
+let SetNoCompileMode, OpenTranslatedHTML, OpenHTMLInSystem;
+
+// Binding to translate sources that already appear to be Chinese
+let SetForceTranslate;
+
+// Context window bindings
+let CheckContextWindow, ApplyRecommendedContextWindow;
+
 // Paper categories cache
 let paperCategories = [];
 
@@ -97,6 +151,8 @@ async function initBindings() {
         RetranslateFromArxiv = App.RetranslateFromArxiv;
         OpenPaperResult = App.OpenPaperResult;
         ContinueTranslation = App.ContinueTranslation;
+        TakeInterruptedJobs = App.TakeInterruptedJobs;
+        GetInterruptedJobs = App.GetInterruptedJobs;
         // GitHub Share bindings
         CheckShareStatus = App.CheckShareStatus;
         ShareToGitHub = App.ShareToGitHub;
@@ -122,6 +178,61 @@ async function initBindings() {
         ReportErrorsToGitHub = App.ReportErrorsToGitHub;
         // Paper Categories binding
         GetPaperCategories = App.GetPaperCategories;
+        // Chunking preview bindings
+        PreviewChunking = App.PreviewChunking;
+        SetFileOverrides = App.SetFileOverrides;
+        EstimateSource = App.EstimateSource;
+        PrepareJob = App.PrepareJob;
+        ConfirmJob = App.ConfirmJob;
+        DiscardJob = App.DiscardJob;
+        // Manual-fix handoff bindings
+        SkipRemainingFixes = App.SkipRemainingFixes;
+        ApproveFix = App.ApproveFix;
+        ReprocessFromTranslatedTex = App.ReprocessFromTranslatedTex;
+        RetryFailedChunks = App.RetryFailedChunks;
+        // Main tex file choice binding
+        ChooseMainTex = App.ChooseMainTex;
+        // Chinese script binding
+        SetChineseVariant = App.SetChineseVariant;
+        // Target language binding
+        SetTargetLanguage = App.SetTargetLanguage;
+        // Glossary binding
+        SetGlossaryPath = App.SetGlossaryPath;
+        // Prompt preset binding
+        SetPromptPreset = App.SetPromptPreset;
+        // Request timeout and translation budget bindings
+        SetRequestTimeout = App.SetRequestTimeout;
+        SetTranslationBudget = App.SetTranslationBudget;
+        // Compile process limit binding
+        SetMaxConcurrentCompiles = App.SetMaxConcurrentCompiles;
+        SetStrictFontEmbedding = App.SetStrictFontEmbedding;
+        CheckWorkDirectory = App.CheckWorkDirectory;
+        // Work directory cleanup bindings
+        GetWorkspacePolicy = App.GetWorkspacePolicy;
+        SetWorkspacePolicy = App.SetWorkspacePolicy;
+        GetWorkspaceUsage = App.GetWorkspaceUsage;
+        CleanWorkspaces = App.CleanWorkspaces;
+        // Comment translation binding
+        SetTranslateComments = App.SetTranslateComments;
+        // Bibliography translation binding
+        SetTranslateBibliography = App.SetTranslateBibliography;
+        // Chunk overlap binding
+        SetChunkOverlap = App.SetChunkOverlap;
+        // Automatic package installation binding
+        SetAutoInstallPackages = App.SetAutoInstallPackages;
+        // \includeonly handling binding
+        SetIgnoreIncludeOnly = App.SetIgnoreIncludeOnly;
+        // Quick mode bindings
+        SetQuickMode = App.SetQuickMode;
+        GetQuickModeDowngrades = App.GetQuickModeDowngrades;
+        SetNoCompileMode = App.SetNoCompileMode;
+        OpenTranslatedHTML = App.OpenTranslatedHTML;
+        OpenHTMLInSystem = App.OpenHTMLInSystem;
+        UpgradeToFullTranslation = App.UpgradeToFullTranslation;
+        SetForceTranslate = App.SetForceTranslate;
+        // Context window bindings
+        CheckContextWindow = App.CheckContextWindow;
+        ApplyRecommendedContextWindow = App.ApplyRecommendedContextWindow;
         return true;
     } catch (error) {
         console.warn('Backend bindings not available yet:', error);
@@ -146,12 +257,12 @@ async function initBindings() {
                 work_directory: ''
             };
         };
-        SaveSettings = async (apiKey, baseUrl, model, contextWindow, compiler, workDir) => {
+        SaveSettings = async (apiKey, baseUrl, model, contextWindow, compiler, workDir, concurrency, githubToken, githubOwner, githubRepo, libraryPageSize, sharePromptEnabled, proxy, requestShape, provider) => {
             console.log('Mock SaveSettings called');
         };
-        TestAPIConnection = async (apiKey, baseUrl, model) => {
+        TestAPIConnection = async (apiKey, baseUrl, model, proxy, requestShape, provider) => {
             console.log('Mock TestAPIConnection called');
-            return null;
+            return { proxy_used: !!proxy, proxy: proxy, request_shape: requestShape };
         };
         OpenFileDialog = async () => {
             console.log('Mock OpenFileDialog called');
@@ -286,14 +397,21 @@ let inputSource;
 let btnBrowse;
 let btnProcess;
 let btnCancel;
+let btnSkipFixes;
+let quickModeCheckbox;
+let noCompileCheckbox;
+let noCompilePdfCheckbox;
+let btnUpgradeFull;
 let btnSettings;
 let pdfLeftIframe;
 let pdfRightIframe;
 let pdfLeftPlaceholder;
 let pdfRightPlaceholder;
+let pdfRightPreviewBadge;
 let statusDot;
 let statusMessage;
 let statusPhase;
+let statusDetail;
 let progressContainer;
 let progressFill;
 let progressText;
@@ -309,9 +427,35 @@ let settingApiKey;
 let settingBaseUrl;
 let settingModel;
 let settingContextWindow;
+let contextWindowAdvice;
+let contextWindowAdviceText;
 let settingCompiler;
+let settingChineseVariant;
+let settingTargetLanguage;
+let settingGlossary;
+let settingPromptPreset;
+let settingTranslateComments;
+let settingTranslateBibliography;
+let settingChunkOverlap;
+let settingMaxCompiles;
+let settingStrictFonts;
+let settingAutoInstallPackages;
+let settingIgnoreIncludeOnly;
 let settingWorkdir;
+let settingWorkspaceKeep;
+let settingWorkspaceMaxGB;
+let settingWorkspaceMaxAge;
+let workspaceUsage;
+let btnCleanWorkspaces;
 let settingConcurrency;
+let settingRequestTimeout;
+let settingBudgetMinutes;
+let settingBudgetTokens;
+let settingProxy;
+let settingProvider;
+let settingMaxTokensField;
+let settingOmitTemperature;
+let settingOmitResponseFormat;
 let settingLibraryPageSize;
 let btnBrowseWorkdir;
 let btnSettingsCancel;
@@ -326,6 +470,16 @@ let btnAbout;
 let aboutModalClose;
 let btnAboutClose;
 
+// Chunking preview modal elements
+let chunkPreviewModal;
+let btnPreviewChunks;
+let btnEstimate;
+let chunkPreviewTitle;
+let chunkPreviewModalClose;
+let btnChunkPreviewClose;
+let chunkPreviewSummary;
+let chunkPreviewContent;
+
 // Results management modal elements
 let resultsModal;
 let btnResults;
@@ -413,6 +567,29 @@ let sharePromptModalClose;
 let btnSharePromptCancel;
 let btnSharePromptConfirm;
 
+// Job Summary Modal elements
+let jobSummaryModal;
+let jobSummaryDetails;
+let jobSummaryFlags;
+let btnJobSummaryConfirm;
+let jobSummaryResolve = null;
+
+// Fix Review Modal elements
+let fixReviewModal;
+let fixReviewDetails;
+let fixReviewReasons;
+let fixReviewDiff;
+let fixReviewQueue = [];
+
+// Main Tex Choice Modal elements
+let mainTexChoiceModal;
+let mainTexChoiceList;
+let missingPackagesModal;
+let missingPackagesList;
+let missingPackagesCommand;
+let compileErrorsModal;
+let compileErrorsList;
+
 // Translate Confirm Modal elements
 let translateConfirmModal;
 let translateConfirmModalClose;
@@ -486,6 +663,7 @@ let latexStatus;
 let latexDetail;
 let latexAction;
 let latexDownloadLink;
+let latexNoCompileLink;
 let llmSpinner;
 let llmStatus;
 let llmDetail;
@@ -519,6 +697,7 @@ let apiTestPassed = false;
 
 // Track original LLM settings to detect changes
 let originalLlmSettings = {
+    provider: 'openai',
     apiKey: '',
     baseUrl: '',
     model: ''
@@ -649,14 +828,21 @@ function initElements() {
     btnBrowse = document.getElementById('btn-browse');
     btnProcess = document.getElementById('btn-process');
     btnCancel = document.getElementById('btn-cancel');
+    btnSkipFixes = document.getElementById('btn-skip-fixes');
+    quickModeCheckbox = document.getElementById('quick-mode-checkbox');
+    noCompileCheckbox = document.getElementById('no-compile-checkbox');
+    noCompilePdfCheckbox = document.getElementById('no-compile-pdf-checkbox');
+    btnUpgradeFull = document.getElementById('btn-upgrade-full');
     btnSettings = document.getElementById('btn-settings');
     pdfLeftIframe = document.getElementById('pdf-left-iframe');
     pdfRightIframe = document.getElementById('pdf-right-iframe');
     pdfLeftPlaceholder = document.getElementById('pdf-left-placeholder');
     pdfRightPlaceholder = document.getElementById('pdf-right-placeholder');
+    pdfRightPreviewBadge = document.getElementById('pdf-right-preview-badge');
     statusDot = document.getElementById('status-dot');
     statusMessage = document.getElementById('status-message');
     statusPhase = document.getElementById('status-phase');
+    statusDetail = document.getElementById('status-detail');
     progressContainer = document.getElementById('progress-container');
     progressFill = document.getElementById('progress-fill');
     progressText = document.getElementById('progress-text');
@@ -672,11 +858,37 @@ function initElements() {
     settingBaseUrl = document.getElementById('setting-base-url');
     settingModel = document.getElementById('setting-model');
     settingContextWindow = document.getElementById('setting-context-window');
+    contextWindowAdvice = document.getElementById('context-window-advice');
+    contextWindowAdviceText = document.getElementById('context-window-advice-text');
     settingCompiler = document.getElementById('setting-compiler');
+    settingChineseVariant = document.getElementById('setting-chinese-variant');
+    settingTargetLanguage = document.getElementById('setting-target-language');
+    settingGlossary = document.getElementById('setting-glossary');
+    settingPromptPreset = document.getElementById('setting-prompt-preset');
+    settingTranslateComments = document.getElementById('setting-translate-comments');
+    settingTranslateBibliography = document.getElementById('setting-translate-bibliography');
+    settingChunkOverlap = document.getElementById('setting-chunk-overlap');
+    settingMaxCompiles = document.getElementById('setting-max-compiles');
+    settingStrictFonts = document.getElementById('setting-strict-fonts');
+    settingAutoInstallPackages = document.getElementById('setting-auto-install-packages');
+    settingIgnoreIncludeOnly = document.getElementById('setting-ignore-includeonly');
     settingWorkdir = document.getElementById('setting-workdir');
     settingConcurrency = document.getElementById('setting-concurrency');
+    settingRequestTimeout = document.getElementById('setting-request-timeout');
+    settingBudgetMinutes = document.getElementById('setting-budget-minutes');
+    settingBudgetTokens = document.getElementById('setting-budget-tokens');
+    settingProxy = document.getElementById('setting-proxy');
+    settingProvider = document.getElementById('setting-provider');
+    settingMaxTokensField = document.getElementById('setting-max-tokens-field');
+    settingOmitTemperature = document.getElementById('setting-omit-temperature');
+    settingOmitResponseFormat = document.getElementById('setting-omit-response-format');
     settingLibraryPageSize = document.getElementById('setting-library-page-size');
     btnBrowseWorkdir = document.getElementById('btn-browse-workdir');
+    settingWorkspaceKeep = document.getElementById('setting-workspace-keep');
+    settingWorkspaceMaxGB = document.getElementById('setting-workspace-max-gb');
+    settingWorkspaceMaxAge = document.getElementById('setting-workspace-max-age');
+    workspaceUsage = document.getElementById('workspace-usage');
+    btnCleanWorkspaces = document.getElementById('btn-clean-workspaces');
     btnSettingsCancel = document.getElementById('btn-settings-cancel');
     btnSettingsSave = document.getElementById('btn-settings-save');
     btnTestConnection = document.getElementById('btn-test-connection');
@@ -689,6 +901,16 @@ function initElements() {
     aboutModalClose = document.getElementById('about-modal-close');
     btnAboutClose = document.getElementById('btn-about-close');
 
+    // Chunking preview modal elements
+    chunkPreviewModal = document.getElementById('chunk-preview-modal');
+    btnPreviewChunks = document.getElementById('btn-preview-chunks');
+    btnEstimate = document.getElementById('btn-estimate');
+    chunkPreviewTitle = document.getElementById('chunk-preview-title');
+    chunkPreviewModalClose = document.getElementById('chunk-preview-modal-close');
+    btnChunkPreviewClose = document.getElementById('btn-chunk-preview-close');
+    chunkPreviewSummary = document.getElementById('chunk-preview-summary');
+    chunkPreviewContent = document.getElementById('chunk-preview-content');
+
     // Results management modal elements
     resultsModal = document.getElementById('results-modal');
     btnResults = document.getElementById('btn-results');
@@ -731,6 +953,7 @@ function initElements() {
     latexDetail = document.getElementById('latex-detail');
     latexAction = document.getElementById('latex-action');
     latexDownloadLink = document.getElementById('latex-download-link');
+    latexNoCompileLink = document.getElementById('latex-no-compile-link');
     llmSpinner = document.getElementById('llm-spinner');
     llmStatus = document.getElementById('llm-status');
     llmDetail = document.getElementById('llm-detail');
@@ -810,6 +1033,27 @@ function initElements() {
     btnSharePromptCancel = document.getElementById('btn-share-prompt-cancel');
     btnSharePromptConfirm = document.getElementById('btn-share-prompt-confirm');
 
+    // Job Summary Modal elements
+    jobSummaryModal = document.getElementById('job-summary-modal');
+    jobSummaryDetails = document.getElementById('job-summary-details');
+    jobSummaryFlags = document.getElementById('job-summary-flags');
+    btnJobSummaryConfirm = document.getElementById('btn-job-summary-confirm');
+
+    // Fix Review Modal elements
+    fixReviewModal = document.getElementById('fix-review-modal');
+    fixReviewDetails = document.getElementById('fix-review-details');
+    fixReviewReasons = document.getElementById('fix-review-reasons');
+    fixReviewDiff = document.getElementById('fix-review-diff');
+
+    // Main Tex Choice Modal elements
+    mainTexChoiceModal = document.getElementById('main-tex-choice-modal');
+    mainTexChoiceList = document.getElementById('main-tex-choice-list');
+    missingPackagesModal = document.getElementById('missing-packages-modal');
+    missingPackagesList = document.getElementById('missing-packages-list');
+    missingPackagesCommand = document.getElementById('missing-packages-command');
+    compileErrorsModal = document.getElementById('compile-errors-modal');
+    compileErrorsList = document.getElementById('compile-errors-list');
+
     // Translate Confirm Modal elements
     translateConfirmModal = document.getElementById('translate-confirm-modal');
     translateConfirmModalClose = document.getElementById('translate-confirm-modal-close');
@@ -839,6 +1083,22 @@ function setupEventListeners() {
     // Cancel button click
     btnCancel.addEventListener('click', handleCancel);
 
+    // Skip remaining fixes button click
+    btnSkipFixes.addEventListener('click', handleSkipFixes);
+
+    // Quick mode toggle and upgrade of a quick-mode result
+    quickModeCheckbox.addEventListener('change', handleQuickModeChange);
+    btnUpgradeFull.addEventListener('click', () => {
+        if (currentResult && currentResult.source_id) {
+            upgradePaper(currentResult.source_id);
+        }
+    });
+    initQuickModeHint();
+
+    // No-compile mode toggle (translate without a LaTeX installation)
+    noCompileCheckbox.addEventListener('change', handleNoCompileChange);
+    noCompilePdfCheckbox.addEventListener('change', handleNoCompileChange);
+
     // Browse button click
     btnBrowse.addEventListener('click', handleBrowse);
 
@@ -849,6 +1109,7 @@ function setupEventListeners() {
     btnDownload.addEventListener('click', toggleDownloadMenu);
     document.getElementById('download-chinese-pdf').addEventListener('click', () => downloadFile('chinese'));
     document.getElementById('download-bilingual-pdf').addEventListener('click', () => downloadFile('bilingual'));
+    document.getElementById('download-bilingual-interleaved').addEventListener('click', () => downloadFile('bilingual-interleaved'));
     document.getElementById('download-latex-zip').addEventListener('click', () => downloadFile('latex'));
 
     // Close dropdown when clicking outside
@@ -864,6 +1125,7 @@ function setupEventListeners() {
     btnSettingsSave.addEventListener('click', saveSettings);
     btnTestConnection.addEventListener('click', testConnection);
     btnBrowseWorkdir.addEventListener('click', browseWorkdir);
+    btnCleanWorkspaces.addEventListener('click', cleanWorkspaces);
 
     // Settings tabs events
     document.querySelectorAll('.settings-tab').forEach(tab => {
@@ -885,10 +1147,19 @@ function setupEventListeners() {
     }
 
     // Reset test status when settings change
+    settingProvider.addEventListener('change', onProviderChange);
     settingApiKey.addEventListener('input', resetTestStatus);
     settingBaseUrl.addEventListener('input', resetTestStatus);
     settingModel.addEventListener('input', resetTestStatus);
 
+    // Suggest a context window that fits the selected model
+    settingModel.addEventListener('change', updateContextWindowAdvice);
+    settingContextWindow.addEventListener('change', updateContextWindowAdvice);
+    const btnUseRecommendedContext = document.getElementById('btn-use-recommended-context');
+    if (btnUseRecommendedContext) {
+        btnUseRecommendedContext.addEventListener('click', useRecommendedContextWindow);
+    }
+
     // Close modal when clicking overlay (but not modal content)
     settingsModal.addEventListener('mousedown', (e) => {
         // Only close if clicking directly on the overlay, not on any child elements
@@ -919,6 +1190,17 @@ function setupEventListeners() {
         OpenURLInBrowser('https://github.com/RapidAI/RapidTrans');
     });
 
+    // Chunking preview modal events
+    btnPreviewChunks.addEventListener('click', openChunkPreview);
+    btnEstimate.addEventListener('click', openEstimate);
+    chunkPreviewModalClose.addEventListener('click', closeChunkPreview);
+    btnChunkPreviewClose.addEventListener('click', closeChunkPreview);
+    chunkPreviewModal.addEventListener('mousedown', (e) => {
+        if (e.target === chunkPreviewModal) {
+            closeChunkPreview();
+        }
+    });
+
     // Results management modal events
     btnResults.addEventListener('click', openResults);
     resultsModalClose.addEventListener('click', closeResults);
@@ -956,6 +1238,13 @@ function setupEventListeners() {
         const url = await GetLaTeXDownloadURL();
         OpenURLInBrowser(url);
     });
+    latexNoCompileLink.addEventListener('click', async (e) => {
+        e.preventDefault();
+        noCompileCheckbox.checked = true;
+        await handleNoCompileChange();
+        // LaTeX is no longer required; the LLM still is
+        btnContinue.disabled = !checkLlmItem.classList.contains('success');
+    });
 
     // Enter key in input field
     inputSource.addEventListener('keypress', (e) => {
@@ -987,6 +1276,14 @@ function setupEventListeners() {
     // Listen for status updates from backend (if using events)
     EventsOn('status-update', handleStatusUpdate);
 
+    // Listen for per-file translation progress
+    EventsOn('translation-progress', (detail) => {
+        latexModeStatus.translation = detail;
+        if (currentMode === 'latex' && latexModeStatus.phase === 'translating') {
+            updateTranslationDetail(detail);
+        }
+    });
+
     // Listen for PDF ready events
     EventsOn('original-pdf-ready', (data) => {
         console.log('Original PDF ready:', data);
@@ -999,6 +1296,13 @@ function setupEventListeners() {
         }
     });
 
+    // A preview of the front matter and first chapter, shown while the full document compiles
+    EventsOn('translated-pdf-preview-ready', (pdfPath) => {
+        console.log('Translated PDF preview ready:', pdfPath);
+        loadPDF('right', pdfPath);
+        setTranslatedPreviewBadge('预览 · 完整编译中');
+    });
+
     EventsOn('translated-pdf-ready', (data) => {
         console.log('Translated PDF ready:', data);
         if (typeof data === 'string') {
@@ -1010,6 +1314,26 @@ function setupEventListeners() {
         }
     });
 
+    // Risky compile fixes wait for the user's decision
+    EventsOn('fix-review-requested', (proposal) => {
+        fixReviewQueue.push(proposal);
+        if (fixReviewQueue.length === 1) {
+            showFixReviewModal(proposal);
+        }
+    });
+
+    // Sources holding several likely main tex files wait for the user's choice
+    EventsOn('main-tex-choice-requested', showMainTexChoiceModal);
+
+    // Packages missing from the TeX installation stop the job before compiling
+    EventsOn('missing-packages', showMissingPackagesModal);
+
+    // Failed compilations list the errors parsed from the LaTeX log
+    EventsOn('compile-errors', showCompileErrorsModal);
+
+    // Jobs interrupted by a crash of the previous run can be continued
+    EventsOn('interrupted-jobs', offerInterruptedJobs);
+
     // PDF Translation mode event listeners
     setupPdfModeEventListeners();
 
@@ -1062,6 +1386,25 @@ function setupEventListeners() {
         }
     });
 
+    // Job Summary Modal event listeners
+    document.getElementById('job-summary-modal-close').addEventListener('click', () => closeJobSummaryModal(false));
+    document.getElementById('btn-job-summary-cancel').addEventListener('click', () => closeJobSummaryModal(false));
+    btnJobSummaryConfirm.addEventListener('click', () => closeJobSummaryModal(true));
+
+    // Fix Review Modal event listeners
+    document.getElementById('btn-fix-review-reject').addEventListener('click', () => decideFixReview(false));
+    document.getElementById('btn-fix-review-accept').addEventListener('click', () => decideFixReview(true));
+
+    // Main Tex Choice Modal event listeners
+    document.getElementById('btn-main-tex-choice-confirm').addEventListener('click', confirmMainTexChoice);
+    document.getElementById('btn-copy-install-command').addEventListener('click', copyInstallCommand);
+    document.getElementById('missing-packages-modal-close').addEventListener('click', () => {
+        missingPackagesModal.classList.remove('visible');
+    });
+    document.getElementById('compile-errors-modal-close').addEventListener('click', () => {
+        compileErrorsModal.classList.remove('visible');
+    });
+
     // Translate Confirm Modal event listeners
     translateConfirmModalClose.addEventListener('click', closeTranslateConfirmModal);
     btnTranslateCancel.addEventListener('click', closeTranslateConfirmModal);
@@ -1107,7 +1450,7 @@ function toggleDownloadMenu(e) {
 
 /**
  * Download file based on type
- * @param {string} type - 'chinese', 'bilingual', or 'latex'
+ * @param {string} type - 'chinese', 'bilingual', 'bilingual-interleaved', or 'latex'
  */
 async function downloadFile(type) {
     downloadMenu.classList.remove('show');
@@ -1129,11 +1472,18 @@ async function downloadFile(type) {
                 break;
             case 'bilingual':
                 showToast('正在生成中英对照 PDF...', 'info');
-                savePath = await DownloadBilingualPDF();
+                savePath = await DownloadBilingualPDF('side-by-side');
                 if (savePath) {
                     showToast('中英对照 PDF 已保存', 'success');
                 }
                 break;
+            case 'bilingual-interleaved':
+                showToast('正在生成中英交替页 PDF...', 'info');
+                savePath = await DownloadBilingualPDF('interleaved');
+                if (savePath) {
+                    showToast('中英交替页 PDF 已保存', 'success');
+                }
+                break;
             case 'latex':
                 showToast('正在打包 LaTeX 文件...', 'info');
                 savePath = await DownloadLatexZip();
@@ -1393,8 +1743,14 @@ async function handleProcess() {
                 console.error('ProcessSourceWithForce error:', forceError);
                 stopStatusPolling();
                 const errorMsg = forceError.message || forceError.toString() || '处理失败';
-                updateStatus('error', 0, errorMsg);
-                showError(errorMsg);
+                if (isCancelledWithPartialResult(errorMsg)) {
+                    handleCancelledResult(errorMsg);
+                } else if (isSourceAlreadyChinese(errorMsg)) {
+                    await translateChineseSourceAnyway(errorMsg, () => ProcessSourceWithForce(input, userChoice));
+                } else {
+                    updateStatus('error', 0, errorMsg);
+                    showError(errorMsg);
+                }
             } finally {
                 setProcessingState(false);
             }
@@ -1405,17 +1761,41 @@ async function handleProcess() {
         // Continue with normal processing if check fails
     }
 
-    // No existing translation, proceed normally
+    // No existing translation: download the source and show what will be translated
+    // before spending tokens
     setProcessingState(true);
     resetPDFViewers();
     updateStatus('idle', 0, '开始处理...');
 
+    let jobId = '';
+    if (PrepareJob) {
+        try {
+            startStatusPolling();
+            const summary = await PrepareJob(input);
+            stopStatusPolling();
+            const confirmed = await showJobSummaryModal(summary);
+            if (!confirmed) {
+                if (summary && summary.job_id) {
+                    DiscardJob(summary.job_id);
+                }
+                updateStatus('idle', 0, '已取消');
+                setProcessingState(false);
+                return;
+            }
+            jobId = summary.job_id;
+        } catch (prepareError) {
+            // Without a summary the job runs as before
+            console.warn('Failed to prepare job:', prepareError);
+            stopStatusPolling();
+        }
+    }
+
     try {
         // Start status polling
         startStatusPolling();
 
-        // Call backend ProcessSource
-        const result = await ProcessSource(input);
+        // Translate the confirmed job with its downloaded source, or download it now
+        const result = jobId ? await ConfirmJob(jobId) : await ProcessSource(input);
 
         // Stop status polling
         stopStatusPolling();
@@ -1427,13 +1807,90 @@ async function handleProcess() {
 
         // Extract error message
         const errorMsg = error.message || error.toString() || '处理失败';
-        updateStatus('error', 0, errorMsg);
-        showError(errorMsg);
+        if (isCancelledWithPartialResult(errorMsg)) {
+            handleCancelledResult(errorMsg);
+        } else if (isSourceAlreadyChinese(errorMsg)) {
+            await translateChineseSourceAnyway(errorMsg, () => ProcessSource(input));
+        } else {
+            updateStatus('error', 0, errorMsg);
+            showError(errorMsg);
+        }
     } finally {
         setProcessingState(false);
     }
 }
 
+/**
+ * Check whether a processing error is a cancellation whose partial result was saved,
+ * including a translation stopped by its time or token budget
+ */
+function isCancelledWithPartialResult(errorMsg) {
+    return typeof errorMsg === 'string' && (errorMsg.startsWith('已取消（可继续）') || isBudgetExceeded(errorMsg));
+}
+
+/**
+ * Check whether a processing error is a translation stopped by its time or token budget
+ */
+function isBudgetExceeded(errorMsg) {
+    return typeof errorMsg === 'string' && errorMsg.startsWith('已达到翻译预算（可继续）');
+}
+
+/**
+ * Check whether a processing error refused a source that already appears to be Chinese
+ */
+function isSourceAlreadyChinese(errorMsg) {
+    return typeof errorMsg === 'string' && errorMsg.startsWith('源文档似乎已是中文');
+}
+
+/**
+ * Ask whether a source that already appears to be Chinese should be translated anyway,
+ * and run the job again with the language check disabled if so
+ */
+async function translateChineseSourceAnyway(errorMsg, run) {
+    const confirmed = await showConfirmDialog(
+        `${errorMsg}\n\n文档似乎已是中文，仍要翻译吗？`, '源文档已是中文', '仍要翻译', '取消');
+    if (!confirmed || !SetForceTranslate) {
+        updateStatus('idle', 0, '已取消');
+        return;
+    }
+
+    await SetForceTranslate(true);
+    try {
+        updateStatus('idle', 0, '开始翻译...');
+        startStatusPolling();
+        const result = await run();
+        stopStatusPolling();
+        handleProcessResult(result);
+    } catch (error) {
+        console.error('Processing error:', error);
+        stopStatusPolling();
+        const retryMsg = error.message || error.toString() || '处理失败';
+        if (isCancelledWithPartialResult(retryMsg)) {
+            handleCancelledResult(retryMsg);
+        } else {
+            updateStatus('error', 0, retryMsg);
+            showError(retryMsg);
+        }
+    } finally {
+        await SetForceTranslate(false);
+    }
+}
+
+/**
+ * Handle a cancelled job: the original PDF stays in the left pane and the partial
+ * result can be continued from the results list
+ */
+function handleCancelledResult(errorMsg) {
+    console.log('Cancelled with partial result:', errorMsg);
+    if (isBudgetExceeded(errorMsg)) {
+        updateStatus('idle', 0, '已达到翻译预算（可继续）');
+        showToast('已达到翻译预算，已完成的部分已保存，可在结果列表中继续翻译', 'warning');
+        return;
+    }
+    updateStatus('idle', 0, '已取消（可继续）');
+    showToast('已取消，已完成的部分已保存，可在结果列表中继续翻译', 'info');
+}
+
 /**
  * Handle the process result
  */
@@ -1462,7 +1919,23 @@ async function handleProcessResult(result) {
             loadPDF('right', result.translated_pdf_path, arxivId);
         }
 
-        showToast('处理完成，可以下载结果', 'success');
+        if (result.uncompiled) {
+            // Nothing was compiled: show the HTML export instead of PDFs
+            showToast('翻译完成（未编译），已在浏览器中打开 HTML 预览，译文 tex 可在“下载”中获取', 'success');
+            if (result.translated_html_path) {
+                OpenHTMLInSystem(result.translated_html_path).catch(error => {
+                    console.warn('Failed to open HTML export:', error);
+                });
+            }
+            setProcessingState(false);
+            return;
+        }
+
+        if (result.quick_mode) {
+            showToast('快速模式翻译完成，译文质量较低，可点击“升级为完整翻译”', 'warning');
+        } else {
+            showToast('处理完成，可以下载结果', 'success');
+        }
         
         // Check if share prompt is enabled and prompt user to share
         try {
@@ -1533,6 +2006,87 @@ async function handleCancel() {
     }
 }
 
+/**
+ * Load the list of quick mode downgrades into the toggle's tooltip
+ */
+async function initQuickModeHint() {
+    if (!GetQuickModeDowngrades) {
+        return;
+    }
+    try {
+        const downgrades = await GetQuickModeDowngrades();
+        if (downgrades && downgrades.length > 0) {
+            document.getElementById('quick-mode-toggle').title =
+                '快速模式以质量换速度：\n' + downgrades.map(d => '• ' + d).join('\n');
+        }
+    } catch (error) {
+        console.warn('Failed to load quick mode downgrades:', error);
+    }
+}
+
+/**
+ * Handle the quick mode toggle: apply it to the following translations
+ */
+async function handleQuickModeChange() {
+    const enabled = quickModeCheckbox.checked;
+    try {
+        if (SetQuickMode) {
+            await SetQuickMode(enabled);
+        }
+        if (enabled) {
+            showToast('已开启快速模式：分块更大、只编译一遍、仅规则修复、不生成双语 PDF', 'info');
+        }
+    } catch (error) {
+        console.error('Failed to set quick mode:', error);
+        quickModeCheckbox.checked = !enabled;
+        showToast('设置快速模式失败: ' + (error.message || error), 'error');
+    }
+}
+
+/**
+ * Handle the no-compile toggles: apply them to the following translations
+ */
+async function handleNoCompileChange() {
+    const enabled = noCompileCheckbox.checked;
+    document.getElementById('no-compile-pdf-toggle').style.display = enabled ? 'inline-flex' : 'none';
+    try {
+        if (SetNoCompileMode) {
+            await SetNoCompileMode(enabled, enabled && noCompilePdfCheckbox.checked);
+        }
+        if (enabled) {
+            showToast('已开启未编译模式：不编译 PDF，只生成译文 tex 文件和 HTML 预览', 'info');
+        }
+    } catch (error) {
+        console.error('Failed to set no-compile mode:', error);
+        noCompileCheckbox.checked = !enabled;
+        showToast('设置未编译模式失败: ' + (error.message || error), 'error');
+    }
+}
+
+/**
+ * Show the upgrade button when the current result was translated in quick mode
+ */
+function updateUpgradeButton() {
+    const show = !isProcessing && currentResult && currentResult.quick_mode && currentResult.source_id;
+    btnUpgradeFull.style.display = show ? 'inline-block' : 'none';
+}
+
+/**
+ * Handle the skip-fixes button click: stop only the automatic fix loop and keep the translation
+ */
+async function handleSkipFixes() {
+    try {
+        btnSkipFixes.disabled = true;
+        await SkipRemainingFixes();
+        showToast('正在跳过剩余修复，译文将保留以便手动修复', 'info');
+    } catch (error) {
+        console.error('Skip fixes error:', error);
+        showToast('跳过修复失败: ' + (error.message || error), 'warning');
+    } finally {
+        btnSkipFixes.disabled = false;
+    }
+}
+
 /**
  * Handle status update from backend event
  * @param {Object} status - Status object from backend
@@ -1557,7 +2111,8 @@ function startStatusPolling() {
                     phase: status.phase, 
                     progress: status.progress, 
                     message: status.message || '就绪', 
-                    error: status.error || null 
+                    error: status.error || null,
+                    translation: status.translation || null
                 };
                 // Only update display if we're in LaTeX mode
                 if (currentMode === 'latex') {
@@ -1591,10 +2146,17 @@ function setProcessingState(processing) {
     btnProcess.disabled = processing;
     btnProcess.style.display = processing ? 'none' : 'inline-block';
     btnCancel.style.display = processing ? 'inline-block' : 'none';
+    if (!processing) {
+        btnSkipFixes.style.display = 'none';
+    }
+    updateUpgradeButton();
 
     // Update input state
     inputSource.disabled = processing;
     btnBrowse.disabled = processing;
+    quickModeCheckbox.disabled = processing;
+    noCompileCheckbox.disabled = processing;
+    noCompilePdfCheckbox.disabled = processing;
 
     // Update progress container visibility
     if (processing) {
@@ -1643,10 +2205,16 @@ function updateStatus(phase, progress, message, error) {
 
     // Update phase display
     statusPhase.textContent = phaseDisplayNames[phase] || phase;
+    updateTranslationDetail(null);
 
     // Update progress bar
     progressFill.style.width = `${progress}%`;
     progressText.textContent = `${progress}%`;
+
+    // The automatic compile-error fixes run in the validating phase
+    if (btnSkipFixes && currentMode === 'latex') {
+        btnSkipFixes.style.display = isProcessing && phase === 'validating' ? 'inline-block' : 'none';
+    }
 }
 
 /**
@@ -1655,7 +2223,7 @@ function updateStatus(phase, progress, message, error) {
  * @param {Object} status - 状态对象 { phase, progress, message, error }
  */
 function applyStatusDisplay(status) {
-    const { phase, progress, message, error } = status;
+    const { phase, progress, message, error, translation } = status;
 
     // Update status dot
     statusDot.className = 'status-dot';
@@ -1681,11 +2249,42 @@ function applyStatusDisplay(status) {
     // Update phase display
     statusPhase.textContent = phaseDisplayNames[phase] || phase;
 
+    // Update per-file translation detail
+    updateTranslationDetail(phase === 'translating' ? translation : null);
+
+    // A preview shown when the full compile fails stays, marked as such
+    if (phase === 'error' && pdfRightPreviewBadge.style.display !== 'none') {
+        setTranslatedPreviewBadge('预览 · 完整编译失败');
+    }
+
     // Update progress bar
     progressFill.style.width = `${progress}%`;
     progressText.textContent = `${progress}%`;
 }
 
+/**
+ * Show which file and chunk is being translated next to the phase
+ * @param {Object|null} detail - TranslationDetail from the backend, null to clear
+ */
+function updateTranslationDetail(detail) {
+    if (!statusDetail) return;
+    if (!detail || !detail.total_chunks) {
+        statusDetail.textContent = '';
+        statusDetail.title = '';
+        return;
+    }
+    const parts = [];
+    if (detail.total_files > 1) {
+        parts.push(`文件 ${detail.file_index}/${detail.total_files}: ${detail.file}`);
+    }
+    parts.push(`分块 ${detail.chunk}/${detail.total_chunks}`);
+    if (detail.tokens_used > 0) {
+        parts.push(`${detail.tokens_used} tokens`);
+    }
+    statusDetail.textContent = parts.join(' · ');
+    statusDetail.title = detail.section ? `${detail.file} - ${detail.section}` : detail.file;
+}
+
 /**
  * Reset PDF viewers to placeholder state
  */
@@ -1699,6 +2298,16 @@ function resetPDFViewers() {
     // Clear iframe sources - use about:blank to prevent loading main page
     pdfLeftIframe.src = 'about:blank';
     pdfRightIframe.src = 'about:blank';
+    setTranslatedPreviewBadge(null);
+}
+
+/**
+ * Mark the translated PDF viewer as showing a preview of the document
+ * @param {string|null} text - Badge text, or null when the full document is shown
+ */
+function setTranslatedPreviewBadge(text) {
+    pdfRightPreviewBadge.textContent = text || '';
+    pdfRightPreviewBadge.style.display = text ? '' : 'none';
 }
 
 /**
@@ -1714,6 +2323,10 @@ async function loadPDF(side, pdfPath, arxivId) {
     if (!pdfPath) {
         return;
     }
+    if (side === 'right') {
+        // Any other translated PDF replaces the preview
+        setTranslatedPreviewBadge(null);
+    }
 
     console.log('Loading PDF:', side, pdfPath, 'arXiv ID:', arxivId);
 
@@ -1934,9 +2547,28 @@ async function openSettings() {
         settingBaseUrl.value = settings.openai_base_url || 'https://api.openai.com/v1';
         settingModel.value = settings.openai_model || 'gpt-4';
         settingContextWindow.value = settings.context_window || 8192;
+        updateContextWindowAdvice();
         settingCompiler.value = settings.default_compiler || 'pdflatex';
+        settingChineseVariant.value = settings.chinese_variant || 'zh-Hans';
+        settingTargetLanguage.value = settings.target_language || 'zh';
+        settingGlossary.value = settings.glossary_path || '';
+        settingPromptPreset.value = settings.prompt_preset || 'default';
+        settingTranslateComments.checked = settings.translate_comments === true;
+        settingTranslateBibliography.checked = settings.translate_bibliography === true;
+        settingChunkOverlap.checked = settings.chunk_overlap === true;
+        settingMaxCompiles.value = settings.max_concurrent_compiles || 2;
+        settingStrictFonts.checked = settings.strict_font_embedding === true;
+        settingAutoInstallPackages.checked = settings.auto_install_packages === true;
+        settingIgnoreIncludeOnly.checked = settings.ignore_includeonly === true;
         settingWorkdir.value = settings.work_directory || '';
+        loadWorkspacePolicy();
         settingConcurrency.value = settings.concurrency || 3;
+        settingRequestTimeout.value = settings.request_timeout_seconds || 120;
+        settingBudgetMinutes.value = settings.budget_minutes || '';
+        settingBudgetTokens.value = settings.budget_tokens || '';
+        settingProxy.value = settings.proxy || '';
+        settingProvider.value = settings.provider || 'openai';
+        applyRequestShape(settings.request_shape);
         settingLibraryPageSize.value = settings.library_page_size || 20;
         
         // Share prompt setting (default to true if not set)
@@ -1947,6 +2579,7 @@ async function openSettings() {
 
         // Store original LLM settings to detect changes
         originalLlmSettings = {
+            provider: settings.provider || 'openai',
             apiKey: settings.openai_api_key || '',
             baseUrl: settings.openai_base_url || 'https://api.openai.com/v1',
             model: settings.openai_model || 'gpt-4'
@@ -2133,6 +2766,183 @@ function closeAbout() {
     aboutModal.classList.remove('visible');
 }
 
+/**
+ * Open the chunking preview modal for the current input.
+ * Shows how the paper will be split for translation; no LLM calls are made.
+ */
+async function openChunkPreview() {
+    const input = inputSource.value.trim();
+    if (!input) {
+        showError('请输入 arXiv URL、arXiv ID 或本地 zip 文件路径');
+        return;
+    }
+
+    chunkPreviewTitle.textContent = '翻译分块预览';
+    chunkPreviewSummary.textContent = '正在准备源码并计算分块...';
+    chunkPreviewContent.innerHTML = '';
+    chunkPreviewModal.classList.add('visible');
+    btnPreviewChunks.disabled = true;
+
+    try {
+        const preview = await PreviewChunking(input);
+        renderChunkPreview(preview);
+    } catch (error) {
+        console.error('Failed to preview chunking:', error);
+        chunkPreviewSummary.textContent = '分块预览失败: ' + (error.message || error);
+    } finally {
+        btnPreviewChunks.disabled = false;
+    }
+}
+
+// Per-file translate/copy overrides chosen in the chunking preview, keyed by file
+const fileOverrides = {};
+
+/**
+ * Render the decision of a file: the rule, its metrics and a selector to override it
+ */
+function renderFileDecision(file) {
+    const decision = file.decision;
+    if (!decision) {
+        return '';
+    }
+    const current = fileOverrides[file.file] || '';
+    const options = [['', '自动'], ['translate', '翻译'], ['copy', '原样复制']]
+        .map(([value, label]) => `<option value="${value}"${value === current ? ' selected' : ''}>${label}</option>`)
+        .join('');
+    const m = decision.metrics;
+    return `<select class="chunk-preview-decision" data-file="${escapeHtml(file.file)}">${options}</select>
+        <div class="chunk-preview-metrics">规则 ${escapeHtml(decision.rule)}: ${escapeHtml(decision.reason)} · 正文 ${m.prose_lines} 行，命令定义 ${m.command_lines} 行，表格 ${m.table_lines} 行，代码比例 ${Math.round(m.code_ratio * 100)}%，中日韩文字 ${Math.round(m.cjk_ratio * 100)}%</div>`;
+}
+
+/**
+ * Apply a per-file override chosen in the chunking preview and preview again
+ */
+async function changeFileDecision(file, decision) {
+    if (decision) {
+        fileOverrides[file] = decision;
+    } else {
+        delete fileOverrides[file];
+    }
+    const files = Object.keys(fileOverrides);
+    try {
+        await SetFileOverrides(
+            files.filter(f => fileOverrides[f] === 'translate'),
+            files.filter(f => fileOverrides[f] === 'copy'));
+        await openChunkPreview();
+    } catch (error) {
+        console.error('Failed to set file overrides:', error);
+        showToast('设置文件处理方式失败: ' + (error.message || error), 'error');
+    }
+}
+
+/**
+ * Render the chunking preview as one table per file, with the decision of each file
+ */
+function renderChunkPreview(preview) {
+    chunkPreviewSummary.textContent = `主文件: ${preview.main_tex_file}，共 ${preview.total_chunks} 个分块，估算输入 ${preview.estimated_tokens} token`;
+
+    let html = '';
+    for (const file of preview.files || []) {
+        if (file.skipped) {
+            html += `<div class="chunk-preview-file">${escapeHtml(file.file)} <span class="chunk-preview-note">原样复制: ${escapeHtml(file.skip_reason || '')}</span>${renderFileDecision(file)}</div>`;
+            continue;
+        }
+        html += `<div class="chunk-preview-file">${escapeHtml(file.file)} <span class="chunk-preview-note">${file.size} 字节，${file.chunks.length} 个分块</span>${renderFileDecision(file)}</div>`;
+        html += '<table class="chunk-preview-table"><thead><tr><th>#</th><th>章节</th><th>字节范围</th><th>估算 token</th><th>受保护环境</th><th>开头 / 结尾</th></tr></thead><tbody>';
+        for (const chunk of file.chunks) {
+            html += `<tr>
+                <td>${chunk.index}</td>
+                <td>${escapeHtml(chunk.section || '-')}</td>
+                <td>${chunk.start}-${chunk.end}</td>
+                <td>${chunk.estimated_tokens}</td>
+                <td>${escapeHtml((chunk.environments || []).join(', ') || '-')}</td>
+                <td><pre>${escapeHtml(chunk.head)}</pre><pre>… ${escapeHtml(chunk.tail)}</pre></td>
+            </tr>`;
+        }
+        html += '</tbody></table>';
+    }
+    chunkPreviewContent.innerHTML = html;
+    chunkPreviewContent.querySelectorAll('.chunk-preview-decision').forEach(select => {
+        select.addEventListener('change', () => changeFileDecision(select.dataset.file, select.value));
+    });
+}
+
+/**
+ * Open the cost estimate of the current input in the chunking preview modal.
+ * Downloads and extracts the source; no LLM calls are made.
+ */
+async function openEstimate() {
+    const input = inputSource.value.trim();
+    if (!input) {
+        showError('请输入 arXiv URL、arXiv ID 或本地 zip 文件路径');
+        return;
+    }
+    if (!EstimateSource) {
+        return;
+    }
+
+    chunkPreviewTitle.textContent = '翻译消耗估算';
+    chunkPreviewSummary.textContent = '正在准备源码并估算消耗...';
+    chunkPreviewContent.innerHTML = '';
+    chunkPreviewModal.classList.add('visible');
+    btnEstimate.disabled = true;
+
+    try {
+        const estimate = await EstimateSource(input);
+        renderEstimate(estimate);
+    } catch (error) {
+        console.error('Failed to estimate source:', error);
+        chunkPreviewSummary.textContent = '估算失败: ' + (error.message || error);
+    } finally {
+        btnEstimate.disabled = false;
+    }
+}
+
+/**
+ * Format an estimated number of seconds as minutes and seconds
+ */
+function formatEstimatedDuration(seconds) {
+    if (seconds < 60) {
+        return `${seconds} 秒`;
+    }
+    return `${Math.floor(seconds / 60)} 分 ${seconds % 60} 秒`;
+}
+
+/**
+ * Render the cost estimate as one row per file
+ */
+function renderEstimate(estimate) {
+    const total = estimate.total;
+    chunkPreviewSummary.textContent = `主文件: ${estimate.main_tex_file}，共 ${total.chunks} 个分块，估算输入 ${total.input_tokens} token、输出 ${total.output_tokens} token，翻译约 ${formatEstimatedDuration(total.estimated_seconds)}（并发 ${estimate.concurrency}，不含编译）`;
+
+    let html = '<table class="chunk-preview-table"><thead><tr><th>文件</th><th>分块</th><th>输入 token</th><th>输出 token</th><th>耗时</th><th>说明</th></tr></thead><tbody>';
+    for (const file of estimate.files || []) {
+        let note = '-';
+        if (file.decision && file.decision.decision !== 'translate') {
+            note = '不翻译: ' + file.decision.reason;
+        } else if (file.estimate.mostly_code) {
+            note = '主要是代码或绘图，不发送给模型';
+        }
+        html += `<tr>
+            <td>${escapeHtml(file.file)}</td>
+            <td>${file.estimate.chunks}</td>
+            <td>${file.estimate.input_tokens}</td>
+            <td>${file.estimate.output_tokens}</td>
+            <td>${formatEstimatedDuration(file.estimate.estimated_seconds)}</td>
+            <td>${escapeHtml(note)}</td>
+        </tr>`;
+    }
+    html += '</tbody></table>';
+    chunkPreviewContent.innerHTML = html;
+}
+
+/**
+ * Close the chunking preview modal
+ */
+function closeChunkPreview() {
+    chunkPreviewModal.classList.remove('visible');
+}
+
 /**
  * Open the results management modal
  */
@@ -2193,9 +3003,14 @@ function createPaperItem(paper) {
     const statusClass = isComplete ? 'status-complete' : (isError ? 'status-error' : 'status-pending');
 
     // Show continue button for incomplete/error translations
-    const showContinue = !isComplete;
-    const showView = isComplete || paper.original_pdf;
-    const showShare = isComplete; // Only show share for completed translations
+    const needsManualFix = status === 'needs_manual_fix';
+    const showContinue = !isComplete && !needsManualFix;
+    const isUncompiled = paper.translation_mode === 'uncompiled';
+    const showView = isUncompiled ? !!(paper.original_pdf || paper.translated_pdf) : (isComplete || paper.original_pdf);
+    const showShare = isComplete && !isUncompiled; // Only show share for completed translations with a PDF
+    const showHtml = isUncompiled && paper.translated_html;
+    const showUpgrade = isComplete && paper.translation_mode === 'quick';
+    const failedChunks = Object.values(paper.failed_chunks || {}).reduce((n, chunks) => n + chunks.length, 0);
 
     item.innerHTML = `
         <span class="paper-icon">${isComplete ? '📄' : (isError ? '❌' : '⏳')}</span>
@@ -2204,14 +3019,21 @@ function createPaperItem(paper) {
             <div class="paper-meta">
                 <span class="paper-arxiv-id">${escapeHtml(paper.arxiv_id)}</span>
                 <span class="paper-status ${statusClass}">${statusText}</span>
+                ${paper.origin === 'batch' ? '<span class="paper-origin" title="由批量处理工具生成">批量</span>' : ''}
+                ${paper.translation_mode === 'quick' ? '<span class="paper-origin paper-quick" title="快速模式译文，质量较低">快速</span>' : ''}
+                ${isUncompiled ? '<span class="paper-origin paper-uncompiled" title="未编译：只有译文 tex 和 HTML 预览">未编译</span>' : ''}
                 <span class="paper-date">${paper.translated_at}</span>
             </div>
             ${paper.error_message ? `<div class="paper-error" title="${escapeHtml(paper.error_message)}">错误: ${escapeHtml(paper.error_message.substring(0, 50))}${paper.error_message.length > 50 ? '...' : ''}</div>` : ''}
         </div>
         <div class="paper-actions">
             ${showView ? '<button class="paper-btn paper-btn-view" title="查看">👁️ 查看</button>' : ''}
+            ${showHtml ? '<button class="paper-btn paper-btn-html" title="在浏览器中打开 HTML 预览">🌐 HTML</button>' : ''}
             ${showShare ? '<button class="paper-btn paper-btn-share" title="分享到 GitHub">📤 分享</button>' : ''}
+            ${showUpgrade ? '<button class="paper-btn paper-btn-upgrade" title="以完整质量重新翻译并替换快速模式译文">⬆️ 升级为完整翻译</button>' : ''}
             ${showContinue ? '<button class="paper-btn paper-btn-continue" title="继续翻译">▶️ 继续</button>' : ''}
+            ${needsManualFix ? '<button class="paper-btn paper-btn-reprocess" title="手动修复译文后重新编译（不重新翻译）">🛠️ 重新编译</button>' : ''}
+            ${failedChunks > 0 ? `<button class="paper-btn paper-btn-retry-chunks" title="${failedChunks} 个分块翻译失败、保留了原文；只重新翻译这些分块并重新编译">🔁 重试失败分块</button>` : ''}
             <button class="paper-btn paper-btn-retranslate" title="重新翻译">🔄 重译</button>
             <button class="paper-btn paper-btn-delete" title="删除">🗑️</button>
         </div>
@@ -2221,12 +3043,28 @@ function createPaperItem(paper) {
     if (showView) {
         item.querySelector('.paper-btn-view').addEventListener('click', () => viewPaper(paper.arxiv_id));
     }
+    if (showHtml) {
+        item.querySelector('.paper-btn-html').addEventListener('click', () => {
+            OpenTranslatedHTML(paper.arxiv_id).catch(error => {
+                showToast('打开 HTML 预览失败: ' + (error.message || error), 'error');
+            });
+        });
+    }
     if (showShare) {
         item.querySelector('.paper-btn-share').addEventListener('click', () => sharePaper(paper.arxiv_id));
     }
+    if (showUpgrade) {
+        item.querySelector('.paper-btn-upgrade').addEventListener('click', () => upgradePaper(paper.arxiv_id));
+    }
     if (showContinue) {
         item.querySelector('.paper-btn-continue').addEventListener('click', () => continuePaper(paper.arxiv_id));
     }
+    if (needsManualFix) {
+        item.querySelector('.paper-btn-reprocess').addEventListener('click', () => reprocessPaper(paper.arxiv_id));
+    }
+    if (failedChunks > 0) {
+        item.querySelector('.paper-btn-retry-chunks').addEventListener('click', () => reprocessPaper(paper.arxiv_id, true));
+    }
     item.querySelector('.paper-btn-retranslate').addEventListener('click', () => retranslatePaper(paper.arxiv_id));
     item.querySelector('.paper-btn-delete').addEventListener('click', () => deletePaper(paper.arxiv_id, item));
 
@@ -2246,7 +3084,9 @@ function getStatusText(status) {
         'translated': '已翻译',
         'compiling': '编译中',
         'complete': '完成',
-        'error': '错误'
+        'error': '错误',
+        'needs_manual_fix': '需手动修复',
+        'cancelled': '已取消（可继续）'
     };
     return statusMap[status] || status;
 }
@@ -2410,12 +3250,21 @@ async function retranslatePaper(arxivId) {
 }
 
 /**
- * Continue a previously failed or incomplete translation
+ * Re-translate a quick-mode translation in full quality, replacing it
  */
-async function continuePaper(arxivId) {
-    // 检查是否正在翻译中
+async function upgradePaper(arxivId) {
     if (isProcessing) {
-        showToast('翻译进行中，请等待当前翻译完成后再继续', 'warning');
+        showToast('翻译进行中，请等待当前翻译完成后再升级', 'warning');
+        return;
+    }
+
+    const confirmed = await showConfirmDialog(
+        `确定要以完整质量重新翻译论文 ${arxivId} 吗？\n\n完成后将替换快速模式译文。`,
+        '⬆️ 升级为完整翻译',
+        '升级',
+        '取消'
+    );
+    if (!confirmed) {
         return;
     }
 
@@ -2423,7 +3272,37 @@ async function continuePaper(arxivId) {
         closeResults();
         setProcessingState(true);
         resetPDFViewers();
-        updateStatus('idle', 0, '继续翻译...');
+        updateStatus('idle', 0, '开始完整翻译...');
+        startStatusPolling();
+
+        const result = await UpgradeToFullTranslation(arxivId);
+
+        stopStatusPolling();
+        await handleProcessResult(result);
+    } catch (error) {
+        console.error('Failed to upgrade paper:', error);
+        stopStatusPolling();
+        updateStatus('error', 0, error.message || '完整翻译失败');
+        showToast('完整翻译失败: ' + (error.message || error), 'error');
+        setProcessingState(false);
+    }
+}
+
+/**
+ * Continue a previously failed or incomplete translation
+ */
+async function continuePaper(arxivId) {
+    // 检查是否正在翻译中
+    if (isProcessing) {
+        showToast('翻译进行中，请等待当前翻译完成后再继续', 'warning');
+        return;
+    }
+
+    try {
+        closeResults();
+        setProcessingState(true);
+        resetPDFViewers();
+        updateStatus('idle', 0, '继续翻译...');
         startStatusPolling();
 
         const result = await ContinueTranslation(arxivId);
@@ -2455,6 +3334,52 @@ async function continuePaper(arxivId) {
     }
 }
 
+/**
+ * Recompile a paper from its hand-fixed translated LaTeX; with retryFailedChunks the chunks
+ * that kept their original text are translated again first
+ */
+async function reprocessPaper(arxivId, retryFailedChunks = false) {
+    if (isProcessing) {
+        showToast('翻译进行中，请等待当前翻译完成后再重新编译', 'warning');
+        return;
+    }
+
+    try {
+        closeResults();
+        setProcessingState(true);
+        resetPDFViewers();
+        updateStatus(retryFailedChunks ? 'translating' : 'compiling', 75, retryFailedChunks ? '重试翻译失败的分块...' : '重新编译译文...');
+        startStatusPolling();
+
+        const result = retryFailedChunks ? await RetryFailedChunks(arxivId) : await ReprocessFromTranslatedTex(arxivId);
+
+        stopStatusPolling();
+
+        if (result) {
+            currentResult = result;
+            updateStatus('complete', 100, '编译完成');
+            downloadDropdown.style.display = 'inline-block';
+            btnShare.style.display = 'inline-block';
+
+            if (result.original_pdf_path) {
+                loadPDF('left', result.original_pdf_path, arxivId);
+            }
+            if (result.translated_pdf_path) {
+                loadPDF('right', result.translated_pdf_path, arxivId);
+            }
+
+            showToast('编译完成', 'success');
+        }
+    } catch (error) {
+        console.error('Failed to reprocess translated tex:', error);
+        stopStatusPolling();
+        updateStatus('error', 0, error.message || '重新编译失败');
+        showToast('重新编译失败: ' + (error.message || error), 'error');
+    } finally {
+        setProcessingState(false);
+    }
+}
+
 /**
  * Delete a translated paper
  */
@@ -2761,13 +3686,14 @@ async function performStartupCheck() {
         console.log('Startup check result:', result);
 
         // Update LaTeX check result
-        updateLatexCheckResult(result.latex_installed, result.latex_version);
+        updateLatexCheckResult(result.latex_installed, result.latex_version, result.latexmk_installed, result.cjk_fonts_available, result.cjk_font);
+        latexNoCompileLink.style.display = result.no_compile_suggested ? 'inline' : 'none';
 
         // Update LLM check result
         updateLlmCheckResult(result.llm_configured, result.llm_error);
 
         // Enable continue button if all checks pass
-        btnContinue.disabled = !(result.latex_installed && result.llm_configured);
+        btnContinue.disabled = !((result.latex_installed || noCompileCheckbox.checked) && result.llm_configured);
 
     } catch (error) {
         console.error('Startup check error:', error);
@@ -2802,13 +3728,18 @@ function resetStartupCheckUI() {
 /**
  * Update LaTeX check result in UI
  */
-function updateLatexCheckResult(installed, version) {
+function updateLatexCheckResult(installed, version, latexmkInstalled, cjkFontsAvailable, cjkFont) {
     latexSpinner.style.display = 'none';
     latexStatus.style.display = 'inline';
 
     if (installed) {
         latexStatus.textContent = '✅';
-        latexDetail.textContent = version || '已安装';
+        latexDetail.textContent = (version || '已安装') + (latexmkInstalled ? '（使用 latexmk 编译）' : '（未检测到 latexmk，逐遍编译）');
+        if (cjkFontsAvailable === false) {
+            latexDetail.textContent += '；未找到中文字体，请安装 Noto Sans CJK 或 Fandol 字体';
+        } else if (cjkFont) {
+            latexDetail.textContent += '；中文字体: ' + cjkFont;
+        }
         checkLatexItem.classList.add('success');
         checkLatexItem.classList.remove('error');
         latexAction.style.display = 'none';
@@ -2868,6 +3799,7 @@ function resetTestStatus() {
 
     // Check if LLM settings have changed from original
     const llmChanged = (
+        settingProvider.value !== originalLlmSettings.provider ||
         currentApiKey !== originalLlmSettings.apiKey ||
         currentBaseUrl !== originalLlmSettings.baseUrl ||
         currentModel !== originalLlmSettings.model
@@ -2925,8 +3857,15 @@ async function testConnection() {
     btnTestConnection.disabled = true;
 
     try {
-        await TestAPIConnection(apiKey, baseUrl, model);
-        testStatus.textContent = '✅ LLM 测试成功';
+        const result = await TestAPIConnection(apiKey, baseUrl, model, settingProxy.value.trim(), currentRequestShape(), settingProvider.value);
+        testStatus.textContent = result && result.proxy_used
+            ? '✅ LLM 测试成功（经代理 ' + result.proxy + '）'
+            : '✅ LLM 测试成功（直接连接）';
+        if (result && result.adjustments && result.adjustments.length > 0) {
+            // Keep the shape that worked so it is saved with the settings
+            applyRequestShape(result.request_shape);
+            testStatus.textContent += '，已调整请求：' + result.adjustments.join('、');
+        }
         testStatus.className = 'test-status success';
         apiTestPassed = true;
         showToast('LLM 连接测试成功', 'success');
@@ -2942,6 +3881,45 @@ async function testConnection() {
     }
 }
 
+// Default API base URL of each provider
+const providerBaseUrls = {
+    openai: 'https://api.openai.com/v1',
+    anthropic: 'https://api.anthropic.com/v1'
+};
+
+/**
+ * Switch the base URL to the default of the new provider unless a custom one is set
+ */
+function onProviderChange() {
+    const baseUrl = settingBaseUrl.value.trim();
+    if (!baseUrl || Object.values(providerBaseUrls).includes(baseUrl)) {
+        settingBaseUrl.value = providerBaseUrls[settingProvider.value] || providerBaseUrls.openai;
+    }
+    settingModel.placeholder = settingProvider.value === 'anthropic' ? 'claude-sonnet-4-5' : 'gpt-4';
+    resetTestStatus();
+}
+
+/**
+ * Read the request shape from the advanced LLM settings
+ */
+function currentRequestShape() {
+    return {
+        max_tokens_field: settingMaxTokensField.value,
+        omit_temperature: settingOmitTemperature.checked,
+        omit_response_format: settingOmitResponseFormat.checked
+    };
+}
+
+/**
+ * Show a request shape in the advanced LLM settings
+ */
+function applyRequestShape(shape) {
+    shape = shape || {};
+    settingMaxTokensField.value = shape.max_tokens_field || 'max_tokens';
+    settingOmitTemperature.checked = shape.omit_temperature === true;
+    settingOmitResponseFormat.checked = shape.omit_response_format === true;
+}
+
 /**
  * Save settings to backend
  */
@@ -2962,6 +3940,7 @@ async function saveSettings() {
 
     // Check if LLM settings have changed
     const llmChanged = (
+        settingProvider.value !== originalLlmSettings.provider ||
         apiKey !== originalLlmSettings.apiKey ||
         baseUrl !== originalLlmSettings.baseUrl ||
         model !== originalLlmSettings.model
@@ -3016,7 +3995,56 @@ async function saveSettings() {
         const githubRepo = DEFAULT_GITHUB_REPO;
 
         // Save to backend
-        await SaveSettings(apiKey, baseUrl, model, contextWindow, compiler, workDir, concurrency, githubToken, githubOwner, githubRepo, libraryPageSize, sharePromptEnabled);
+        const proxy = settingProxy.value.trim();
+        await SaveSettings(apiKey, baseUrl, model, contextWindow, compiler, workDir, concurrency, githubToken, githubOwner, githubRepo, libraryPageSize, sharePromptEnabled, proxy, currentRequestShape(), settingProvider.value);
+        if (SetChineseVariant) {
+            await SetChineseVariant(settingChineseVariant.value);
+        }
+        if (SetTargetLanguage) {
+            await SetTargetLanguage(settingTargetLanguage.value);
+        }
+        if (SetGlossaryPath) {
+            await SetGlossaryPath(settingGlossary.value.trim());
+        }
+        if (SetPromptPreset) {
+            await SetPromptPreset(settingPromptPreset.value);
+        }
+        if (SetTranslateComments) {
+            await SetTranslateComments(settingTranslateComments.checked);
+        }
+        if (SetTranslateBibliography) {
+            await SetTranslateBibliography(settingTranslateBibliography.checked);
+        }
+        if (SetChunkOverlap) {
+            await SetChunkOverlap(settingChunkOverlap.checked);
+        }
+        if (SetRequestTimeout) {
+            await SetRequestTimeout(Math.max(parseInt(settingRequestTimeout.value) || 120, 1));
+        }
+        if (SetTranslationBudget) {
+            const budgetMinutes = Math.max(parseInt(settingBudgetMinutes.value) || 0, 0);
+            const budgetTokens = Math.max(parseInt(settingBudgetTokens.value) || 0, 0);
+            await SetTranslationBudget(budgetMinutes, budgetTokens);
+        }
+        if (SetMaxConcurrentCompiles) {
+            const maxCompiles = Math.min(Math.max(parseInt(settingMaxCompiles.value) || 2, 1), 16);
+            await SetMaxConcurrentCompiles(maxCompiles);
+        }
+        if (SetStrictFontEmbedding) {
+            await SetStrictFontEmbedding(settingStrictFonts.checked);
+        }
+        if (SetAutoInstallPackages) {
+            await SetAutoInstallPackages(settingAutoInstallPackages.checked);
+        }
+        if (SetIgnoreIncludeOnly) {
+            await SetIgnoreIncludeOnly(settingIgnoreIncludeOnly.checked);
+        }
+        if (SetWorkspacePolicy) {
+            await SetWorkspacePolicy(workspacePolicyFromForm());
+        }
+        const contextAdvice = await updateContextWindowAdvice();
+        // Warn when the work directory is in a sync folder (OneDrive, Dropbox, ...) or read-only
+        const workDirCheck = workDir && CheckWorkDirectory ? await CheckWorkDirectory(workDir) : null;
 
         // Handle first-time setup completion
         // Validates: Requirements 4.4, 4.5
@@ -3031,12 +4059,144 @@ async function saveSettings() {
         } else {
             showToast('设置已保存', 'success');
         }
+        if (contextAdvice && contextAdvice.mismatch) {
+            showToast(contextAdvice.message, 'warning');
+        }
+        if (workDirCheck && workDirCheck.message) {
+            showToast(workDirCheck.message, 'warning');
+        }
     } catch (error) {
         console.error('Error saving settings:', error);
         showToast('保存设置失败: ' + (error.message || error), 'error');
     }
 }
 
+/**
+ * Fill the work directory cleanup fields and show the disk usage of the work directory
+ */
+async function loadWorkspacePolicy() {
+    if (!GetWorkspacePolicy) {
+        return;
+    }
+    try {
+        const policy = await GetWorkspacePolicy();
+        settingWorkspaceKeep.value = policy.keep_recent || '';
+        settingWorkspaceMaxGB.value = policy.max_size_gb || '';
+        settingWorkspaceMaxAge.value = policy.max_age_days || '';
+    } catch (error) {
+        console.error('Error loading work directory cleanup policy:', error);
+    }
+    await updateWorkspaceUsage();
+}
+
+/**
+ * Read the work directory cleanup policy from the settings form; empty fields are no limit
+ */
+function workspacePolicyFromForm() {
+    return {
+        keep_recent: Math.max(parseInt(settingWorkspaceKeep.value) || 0, 0),
+        max_size_gb: Math.max(parseFloat(settingWorkspaceMaxGB.value) || 0, 0),
+        max_age_days: Math.max(parseInt(settingWorkspaceMaxAge.value) || 0, 0)
+    };
+}
+
+/**
+ * Show the disk usage of the paper directories under the cleanup fields
+ */
+async function updateWorkspaceUsage() {
+    if (!GetWorkspaceUsage) {
+        return;
+    }
+    try {
+        const usage = await GetWorkspaceUsage();
+        const papers = usage.papers || [];
+        const kept = papers.filter(paper => paper.protected).length;
+        workspaceUsage.textContent = `当前占用：${papers.length} 篇论文 ${formatFileSize(usage.papers_size)}` +
+            (kept > 0 ? `（其中 ${kept} 篇未完成，清理时保留）` : '') +
+            `，其他文件 ${formatFileSize(usage.other_size)}`;
+    } catch (error) {
+        workspaceUsage.textContent = '';
+    }
+}
+
+/**
+ * Save the cleanup policy of the form and apply it to the work directory now
+ */
+async function cleanWorkspaces() {
+    if (!CleanWorkspaces) {
+        return;
+    }
+    try {
+        if (SetWorkspacePolicy) {
+            await SetWorkspacePolicy(workspacePolicyFromForm());
+        }
+        const result = await CleanWorkspaces(-1);
+        const removed = (result.removed || []).length;
+        if (removed === 0) {
+            showToast('没有需要清理的论文目录', 'info');
+        } else {
+            showToast(`已删除 ${removed} 个论文目录，释放 ${formatFileSize(result.freed_bytes)}`, 'success');
+        }
+        if (result.errors && result.errors.length > 0) {
+            showToast('部分目录删除失败: ' + result.errors.join('; '), 'warning');
+        }
+    } catch (error) {
+        console.error('Error cleaning work directory:', error);
+        showToast('清理工作目录失败: ' + (error.message || error), 'error');
+    }
+    await updateWorkspaceUsage();
+}
+
+/**
+ * Check the context window in the settings form against the model and show the suggestion
+ * when they do not fit. Returns the advice, or null when it is not available.
+ */
+async function updateContextWindowAdvice() {
+    if (!CheckContextWindow || !contextWindowAdvice) {
+        return null;
+    }
+    const model = settingModel.value.trim();
+    const contextWindow = parseInt(settingContextWindow.value) || 8192;
+    try {
+        const advice = await CheckContextWindow(model, contextWindow);
+        contextWindowAdvice.dataset.recommended = advice.recommended || '';
+        if (advice.mismatch) {
+            contextWindowAdviceText.textContent = advice.message;
+            contextWindowAdvice.style.display = 'flex';
+        } else {
+            contextWindowAdvice.style.display = 'none';
+        }
+        return advice;
+    } catch (error) {
+        console.warn('Failed to check context window:', error);
+        return null;
+    }
+}
+
+/**
+ * Put the recommended context window into the settings form
+ */
+async function useRecommendedContextWindow() {
+    const recommended = parseInt(contextWindowAdvice.dataset.recommended);
+    if (!recommended) {
+        return;
+    }
+    settingContextWindow.value = recommended;
+    contextWindowAdvice.style.display = 'none';
+
+    // The model is saved already: apply right away so the next job uses the new window
+    if (ApplyRecommendedContextWindow && settingModel.value.trim() === originalLlmSettings.model) {
+        try {
+            await ApplyRecommendedContextWindow();
+            showToast(`上下文窗口已设为推荐值 ${recommended}`, 'success');
+            return;
+        } catch (error) {
+            console.warn('Failed to apply recommended context window:', error);
+        }
+    }
+    showToast(`已填入推荐值 ${recommended}，保存设置后生效`, 'info');
+}
+
 /**
  * Initialize the application
  */
@@ -3131,9 +4291,122 @@ async function init() {
     // Set initial state
     updateStatus('idle', 0, '就绪');
 
+    // Resume the jobs interrupted by closing the application, then offer the ones a crash
+    // interrupted
+    resumeInterruptedJobs().then(loadInterruptedJobs);
+
     console.log('LaTeX 翻译器前端已初始化');
 }
 
+/**
+ * Resume the jobs interrupted by closing the application last time, one after another.
+ * Jobs that were still waiting for confirmation show their summary again.
+ */
+async function resumeInterruptedJobs() {
+    if (!TakeInterruptedJobs) return;
+    let entries = [];
+    try {
+        entries = await TakeInterruptedJobs() || [];
+    } catch (error) {
+        console.warn('Failed to load interrupted jobs:', error);
+        return;
+    }
+
+    for (const entry of entries) {
+        if (entry.needs_confirmation) {
+            inputSource.value = entry.input;
+            await handleProcess();
+            continue;
+        }
+
+        setProcessingState(true);
+        resetPDFViewers();
+        updateStatus('idle', 0, '继续上次退出时未完成的翻译...');
+        showToast('继续上次退出时未完成的翻译', 'info');
+        startStatusPolling();
+        try {
+            const result = entry.library_id
+                ? await ContinueTranslation(entry.library_id)
+                : await ProcessSourceWithForce(entry.input, true);
+            stopStatusPolling();
+            handleProcessResult(result);
+        } catch (error) {
+            stopStatusPolling();
+            const errorMsg = error.message || error.toString() || '处理失败';
+            if (isCancelledWithPartialResult(errorMsg)) {
+                handleCancelledResult(errorMsg);
+            } else {
+                updateStatus('error', 0, errorMsg);
+                showError(errorMsg);
+            }
+        } finally {
+            setProcessingState(false);
+        }
+    }
+}
+
+/**
+ * Load the jobs a crash of the previous run interrupted, in case the event announcing them
+ * arrived before the listener was registered
+ */
+async function loadInterruptedJobs() {
+    if (!GetInterruptedJobs) return;
+    try {
+        offerInterruptedJobs(await GetInterruptedJobs() || []);
+    } catch (error) {
+        console.warn('Failed to load jobs interrupted by a crash:', error);
+    }
+}
+
+// arXiv IDs of the crash-interrupted jobs already offered, so each is offered once
+const offeredInterruptedJobs = new Set();
+
+/**
+ * Offer to continue the jobs a crash of the previous run interrupted, e.g.
+ * "继续上次中断的翻译 2301.00001（中断于 62%）？", through ContinueTranslation
+ * @param {Array} jobs - Journal entries of the interrupted jobs
+ */
+async function offerInterruptedJobs(jobs) {
+    for (const job of jobs || []) {
+        if (!job.arxiv_id || offeredInterruptedJobs.has(job.arxiv_id)) continue;
+        offeredInterruptedJobs.add(job.arxiv_id);
+
+        const resume = await showConfirmDialog(
+            `上次程序异常退出，${job.arxiv_id} 的翻译中断于 ${job.progress}%（${job.message || job.phase}）。是否继续该翻译？`,
+            '继续中断的翻译', '继续', '稍后'
+        );
+        if (!resume) {
+            showToast('可稍后在翻译历史中继续该论文', 'info');
+            continue;
+        }
+        if (isProcessing) {
+            showToast('已有翻译任务正在进行中，请稍后在翻译历史中继续', 'warning');
+            continue;
+        }
+
+        setProcessingState(true);
+        resetPDFViewers();
+        updateStatus('idle', 0, `继续上次中断的翻译 ${job.arxiv_id}...`);
+        startStatusPolling();
+        try {
+            const result = await ContinueTranslation(job.arxiv_id);
+            stopStatusPolling();
+            handleProcessResult(result);
+        } catch (error) {
+            stopStatusPolling();
+            const errorMsg = error.message || error.toString() || '处理失败';
+            if (isCancelledWithPartialResult(errorMsg)) {
+                handleCancelledResult(errorMsg);
+            } else {
+                updateStatus('error', 0, errorMsg);
+                showError(errorMsg);
+            }
+        } finally {
+            setProcessingState(false);
+        }
+    }
+}
+
 /**
  * Continue initialization after mode selection is complete
  * Called from modeSelector.js after successful activation or mode selection
@@ -3158,6 +4431,10 @@ async function initMainInterface() {
     // Set initial state
     updateStatus('idle', 0, '就绪');
 
+    // Resume the jobs interrupted by closing the application, then offer the ones a crash
+    // interrupted
+    resumeInterruptedJobs().then(loadInterruptedJobs);
+
     console.log('Main interface initialized');
 }
 
@@ -3188,7 +4465,8 @@ async function performStartupCheckOnInit() {
             showStartupCheckModal();
 
             // Update UI with results
-            updateLatexCheckResult(result.latex_installed, result.latex_version);
+            updateLatexCheckResult(result.latex_installed, result.latex_version, result.latexmk_installed, result.cjk_fonts_available, result.cjk_font);
+            latexNoCompileLink.style.display = result.no_compile_suggested ? 'inline' : 'none';
             
             if (needsLlmCheck) {
                 updateLlmCheckResult(result.llm_configured, result.llm_error);
@@ -3198,7 +4476,7 @@ async function performStartupCheckOnInit() {
             }
 
             // Enable continue button if all required checks pass
-            btnContinue.disabled = !(result.latex_installed && llmOk);
+            btnContinue.disabled = !((result.latex_installed || noCompileCheckbox.checked) && llmOk);
         }
     } catch (error) {
         console.error('Initial startup check error:', error);
@@ -4001,6 +5279,269 @@ async function handleArxivSearch() {
     }
 }
 
+/**
+ * Format a byte count for the job summary
+ */
+function formatSourceSize(bytes) {
+    if (bytes >= 1024 * 1024) {
+        return (bytes / 1024 / 1024).toFixed(1) + ' MB';
+    }
+    return Math.max(1, Math.round(bytes / 1024)) + ' KB';
+}
+
+/**
+ * Show the summary of a prepared job and ask whether to translate it
+ * @param {object} summary - Job summary from PrepareJob
+ * @returns {Promise<boolean>} - true when the user starts the translation
+ */
+function showJobSummaryModal(summary) {
+    return new Promise((resolve) => {
+        jobSummaryResolve = resolve;
+
+        const rows = [];
+        if (summary.arxiv_id) rows.push(['arXiv ID:', summary.arxiv_id]);
+        rows.push(['论文标题:', summary.title || '-']);
+        if (summary.authors && summary.authors.length) rows.push(['作者:', summary.authors.join(', ')]);
+        if (summary.primary_category) {
+            const others = (summary.categories || []).filter(c => c !== summary.primary_category);
+            rows.push(['分类:', summary.primary_category + (others.length ? `（另见 ${others.join(', ')}）` : '')]);
+        }
+        if (summary.published) {
+            let dates = summary.published;
+            if (summary.updated && summary.updated !== summary.published) {
+                dates += `，最新版本 ${summary.updated}`;
+            }
+            rows.push(['提交日期:', dates]);
+        }
+        if (summary.versions) rows.push(['版本数:', String(summary.versions)]);
+        if (summary.arxiv_id) rows.push(['许可证:', summary.license || '未知']);
+        if (summary.job_id) {
+            rows.push(['源码:', `${summary.main_tex_file}，${summary.tex_files} 个 tex 文件，${formatSourceSize(summary.source_size)}`]);
+            rows.push(['预计消耗:', `约 ${summary.estimated_tokens.toLocaleString()} token（${summary.total_chunks} 个分块）`]);
+            rows.push(['预计耗时:', `约 ${summary.estimated_minutes} 分钟`]);
+        }
+
+        jobSummaryDetails.innerHTML = '';
+        for (const [label, value] of rows) {
+            const item = document.createElement('div');
+            item.className = 'paper-preview-item';
+            const labelEl = document.createElement('span');
+            labelEl.className = 'preview-label';
+            labelEl.textContent = label;
+            const valueEl = document.createElement('span');
+            valueEl.className = 'preview-value';
+            valueEl.textContent = value;
+            item.appendChild(labelEl);
+            item.appendChild(valueEl);
+            jobSummaryDetails.appendChild(item);
+        }
+
+        jobSummaryFlags.innerHTML = '';
+        for (const flag of summary.red_flags || []) {
+            const li = document.createElement('li');
+            li.textContent = flag;
+            jobSummaryFlags.appendChild(li);
+        }
+        jobSummaryFlags.style.display = (summary.red_flags && summary.red_flags.length) ? 'block' : 'none';
+
+        // Jobs without a usable source cannot be started
+        btnJobSummaryConfirm.disabled = !summary.job_id;
+        jobSummaryModal.classList.add('visible');
+    });
+}
+
+/**
+ * Close the job summary modal
+ * @param {boolean} result - whether the user starts the translation
+ */
+function closeJobSummaryModal(result) {
+    jobSummaryModal.classList.remove('visible');
+    if (jobSummaryResolve) {
+        jobSummaryResolve(result);
+        jobSummaryResolve = null;
+    }
+}
+
+/**
+ * Show a risky compile fix with its diff
+ * @param {object} proposal - Fix proposal from the fix-review-requested event
+ */
+function showFixReviewModal(proposal) {
+    const levels = ['规则修复', 'LLM 修复', 'Agent 修复'];
+    const risk = proposal.risk || {};
+    const rows = [
+        ['文件:', proposal.file],
+        ['修复方式:', levels[proposal.level] || '-'],
+        ['改动:', `+${risk.lines_added || 0} / -${risk.lines_removed || 0} 行`],
+    ];
+    if (proposal.description) rows.push(['说明:', proposal.description]);
+
+    fixReviewDetails.innerHTML = '';
+    for (const [label, value] of rows) {
+        const item = document.createElement('div');
+        item.className = 'paper-preview-item';
+        const labelEl = document.createElement('span');
+        labelEl.className = 'preview-label';
+        labelEl.textContent = label;
+        const valueEl = document.createElement('span');
+        valueEl.className = 'preview-value';
+        valueEl.textContent = value;
+        item.appendChild(labelEl);
+        item.appendChild(valueEl);
+        fixReviewDetails.appendChild(item);
+    }
+
+    fixReviewReasons.innerHTML = '';
+    for (const reason of proposal.reasons || []) {
+        const li = document.createElement('li');
+        li.textContent = reason;
+        fixReviewReasons.appendChild(li);
+    }
+
+    fixReviewDiff.innerHTML = '';
+    for (const line of (proposal.diff || '').split('\n')) {
+        const span = document.createElement('span');
+        if (line.startsWith('@@')) {
+            span.className = 'diff-hunk';
+        } else if (line.startsWith('+') && !line.startsWith('+++')) {
+            span.className = 'diff-add';
+        } else if (line.startsWith('-') && !line.startsWith('---')) {
+            span.className = 'diff-del';
+        }
+        span.textContent = line + '\n';
+        fixReviewDiff.appendChild(span);
+    }
+
+    fixReviewModal.classList.add('visible');
+}
+
+/**
+ * Accept or reject the fix shown in the fix review modal and show the next one
+ * @param {boolean} accept - whether to apply the fix
+ */
+async function decideFixReview(accept) {
+    const proposal = fixReviewQueue.shift();
+    fixReviewModal.classList.remove('visible');
+    if (proposal && ApproveFix) {
+        try {
+            await ApproveFix(proposal.id, accept);
+        } catch (error) {
+            // The fix loop was skipped or cancelled in the meantime
+            console.warn('Failed to approve fix:', error);
+        }
+    }
+    if (fixReviewQueue.length > 0) {
+        showFixReviewModal(fixReviewQueue[0]);
+    }
+}
+
+/**
+ * Show the main tex file candidates of a source, the top ranked one selected
+ * @param {Array} candidates - candidates of the main-tex-choice-requested event, best first
+ */
+function showMainTexChoiceModal(candidates) {
+    mainTexChoiceList.innerHTML = '';
+    (candidates || []).forEach((candidate, index) => {
+        const item = document.createElement('label');
+        item.className = 'paper-preview-item main-tex-choice-item';
+        const radio = document.createElement('input');
+        radio.type = 'radio';
+        radio.name = 'main-tex-choice';
+        radio.value = candidate.path;
+        radio.checked = index === 0;
+        const nameEl = document.createElement('span');
+        nameEl.className = 'preview-label';
+        nameEl.textContent = candidate.path + (index === 0 ? ' (推荐)' : '');
+        const infoEl = document.createElement('span');
+        infoEl.className = 'preview-value';
+        infoEl.textContent = `引用 ${candidate.inputs} 个文件, 约 ${candidate.words} 词`;
+        item.appendChild(radio);
+        item.appendChild(nameEl);
+        item.appendChild(infoEl);
+        mainTexChoiceList.appendChild(item);
+    });
+    mainTexChoiceModal.classList.add('visible');
+}
+
+/**
+ * Show the LaTeX packages missing from the TeX installation and the command installing them
+ * @param {object} report - MissingPackagesReport with packages and install_command
+ */
+function showMissingPackagesModal(report) {
+    missingPackagesList.innerHTML = '';
+    (report.packages || []).forEach((name) => {
+        const item = document.createElement('li');
+        item.textContent = name;
+        missingPackagesList.appendChild(item);
+    });
+    const command = report.install_command || '';
+    missingPackagesCommand.textContent = command || '未识别 TeX 发行版，请使用其包管理器安装以上宏包';
+    document.getElementById('btn-copy-install-command').style.display = command ? 'inline-block' : 'none';
+    missingPackagesModal.dataset.command = command;
+    missingPackagesModal.classList.add('visible');
+}
+
+/**
+ * Show the errors of a failed compilation with their file, line and source context
+ * @param {object} report - CompileErrorsReport with stage and errors
+ */
+function showCompileErrorsModal(report) {
+    const errors = report.errors || [];
+    const stage = report.stage === 'translated_compile' ? '译文' : '原始文档';
+    document.getElementById('compile-errors-title').textContent = `${stage}编译失败，LaTeX 日志中有 ${errors.length} 个错误：`;
+    compileErrorsList.innerHTML = '';
+    errors.forEach((error) => {
+        const item = document.createElement('li');
+        const location = document.createElement('div');
+        location.className = 'compile-error-location';
+        location.textContent = error.line ? `${error.file || ''} 第 ${error.line} 行` : (error.file || '位置未知');
+        const message = document.createElement('div');
+        message.className = 'compile-error-message';
+        message.textContent = error.message;
+        item.appendChild(location);
+        item.appendChild(message);
+        if (error.context) {
+            const context = document.createElement('pre');
+            context.className = 'compile-error-context';
+            context.textContent = error.context;
+            item.appendChild(context);
+        }
+        compileErrorsList.appendChild(item);
+    });
+    compileErrorsModal.classList.add('visible');
+}
+
+/**
+ * Copy the install command of the missing packages modal to the clipboard
+ */
+async function copyInstallCommand() {
+    const button = document.getElementById('btn-copy-install-command');
+    try {
+        await ClipboardSetText(missingPackagesModal.dataset.command || '');
+        button.textContent = '已复制';
+    } catch (error) {
+        console.warn('Failed to copy install command:', error);
+        button.textContent = '复制失败';
+    }
+    setTimeout(() => { button.textContent = '复制安装命令'; }, 2000);
+}
+
+/**
+ * Translate the source with the main tex file selected in the main tex choice modal
+ */
+async function confirmMainTexChoice() {
+    const selected = mainTexChoiceList.querySelector('input[name="main-tex-choice"]:checked');
+    mainTexChoiceModal.classList.remove('visible');
+    if (selected && ChooseMainTex) {
+        try {
+            await ChooseMainTex(selected.value);
+        } catch (error) {
+            // The job was cancelled in the meantime
+            console.warn('Failed to choose main tex file:', error);
+        }
+    }
+}
+
 /**
  * Show translate confirm modal
  * @param {string} arxivId - arXiv ID
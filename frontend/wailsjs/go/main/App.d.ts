@@ -16,10 +16,16 @@ export function ActivateLicense(arg1:string):Promise<main.ActivationResult>;
 
 export function AddInputHistory(arg1:string,arg2:string):Promise<void>;
 
+export function ApplyRecommendedContextWindow():Promise<types.ContextWindowAdvice>;
+
+export function ApproveFix(arg1:string,arg2:boolean):Promise<void>;
+
 export function CancelPDFTranslation():Promise<void>;
 
 export function CancelProcess():Promise<void>;
 
+export function CheckContextWindow(arg1:string,arg2:number):Promise<types.ContextWindowAdvice>;
+
 export function CheckExistingTranslation(arg1:string):Promise<results.ExistingTranslationInfo>;
 
 export function CheckLicenseValidity():Promise<main.LicenseValidityResult>;
@@ -32,19 +38,31 @@ export function CheckShareStatusWithCategory(arg1:string):Promise<main.ShareChec
 
 export function CheckStartupRequirements():Promise<main.StartupCheckResult>;
 
+export function CheckWorkDirectory(arg1:string):Promise<types.WorkDirCheck>;
+
+export function ChooseMainTex(arg1:string):Promise<void>;
+
+export function CleanWorkspaces(arg1:number):Promise<types.WorkspaceCleanResult>;
+
 export function ClearAllErrors():Promise<void>;
 
 export function ClearError(arg1:string):Promise<void>;
 
 export function ClearInputHistory():Promise<void>;
 
+export function ConfirmJob(arg1:string):Promise<types.ProcessResult>;
+
 export function ContinueTranslation(arg1:string):Promise<types.ProcessResult>;
 
+export function DeleteLibraryPaper(arg1:string):Promise<void>;
+
 export function DeleteTranslatedPaper(arg1:string):Promise<void>;
 
+export function DiscardJob(arg1:string):Promise<void>;
+
 export function DownloadAndOpenGitHubTranslation(arg1:string,arg2:string,arg3:boolean):Promise<main.DownloadAndOpenResult>;
 
-export function DownloadBilingualPDF():Promise<string>;
+export function DownloadBilingualPDF(arg1:string):Promise<string>;
 
 export function DownloadChinesePDF():Promise<string>;
 
@@ -54,52 +72,116 @@ export function DownloadLatexZip():Promise<string>;
 
 export function EnsureGitHubToken():Promise<void>;
 
+export function EstimateSource(arg1:string):Promise<types.SourceEstimate>;
+
 export function ExportErrorIDsToFile():Promise<string>;
 
 export function ExportErrorsToFile():Promise<string>;
 
+export function ExportLibrary(arg1:Array<string>):Promise<string>;
+
+export function ExportLibraryTo(arg1:string,arg2:Array<string>):Promise<results.LibraryManifest>;
+
 export function FetchAndDecodeGitHubToken():Promise<string>;
 
 export function GetArxivPaperMetadata(arg1:string):Promise<main.ArxivPaperMetadata>;
 
+export function GetAutoInstallPackages():Promise<boolean>;
+
+export function GetChineseVariant():Promise<string>;
+
+export function GetChunkOverlap():Promise<boolean>;
+
+export function GetCloseSummary():Promise<types.CloseSummary>;
+
 export function GetCompiler():Promise<compiler.LaTeXCompiler>;
 
 export function GetConfig():Promise<config.ConfigManager>;
 
+export function GetDebugCaptureDir():Promise<string>;
+
+export function GetDocumentOutline(arg1:string):Promise<Array<types.OutlineEntry>>;
+
 export function GetDownloader():Promise<downloader.SourceDownloader>;
 
+export function GetGlossaryPath():Promise<string>;
+
+export function GetIgnoreIncludeOnly():Promise<boolean>;
+
 export function GetInputHistory():Promise<Array<types.InputHistoryItem>>;
 
+export function GetInterruptedJobs():Promise<Array<results.JournalEntry>>;
+
 export function GetLaTeXDownloadURL():Promise<string>;
 
 export function GetLastInput():Promise<string>;
 
+export function GetLibraryPapers(arg1:number,arg2:number,arg3:string):Promise<results.LibraryPage>;
+
+export function GetLibraryStats():Promise<results.LibraryStats>;
+
 export function GetLicenseInfo():Promise<main.LicenseDisplayInfo>;
 
+export function GetMaxConcurrentCompiles():Promise<number>;
+
+export function GetOnCompleteHook():Promise<string>;
+
 export function GetPDFDataURL(arg1:string):Promise<string>;
 
 export function GetPDFStatus():Promise<pdf.PDFStatus>;
 
 export function GetPaperCategories():Promise<Array<types.PaperCategory>>;
 
+export function GetPromptPreset():Promise<string>;
+
+export function GetPromptPresets():Promise<Array<string>>;
+
+export function GetProvenance(arg1:string):Promise<types.Provenance>;
+
+export function GetQuickModeDowngrades():Promise<Array<string>>;
+
+export function GetRequestTimeoutSeconds():Promise<number>;
+
 export function GetResultsDirectory():Promise<string>;
 
 export function GetSettings():Promise<types.Config>;
 
 export function GetStatus():Promise<types.Status>;
 
+export function GetStrictFontEmbedding():Promise<boolean>;
+
+export function GetTargetLanguage():Promise<string>;
+
+export function GetTranslateBibliography():Promise<boolean>;
+
+export function GetTranslateComments():Promise<boolean>;
+
 export function GetTranslatedPDFPath():Promise<string>;
 
 export function GetTranslator():Promise<translator.TranslationEngine>;
 
+export function GetUsageStats(arg1:any):Promise<results.UsageStats>;
+
 export function GetValidator():Promise<validator.SyntaxValidator>;
 
 export function GetWorkDir():Promise<string>;
 
 export function GetWorkMode():Promise<string>;
 
+export function GetWorkspacePolicy():Promise<types.WorkspacePolicy>;
+
+export function GetWorkspaceUsage():Promise<types.WorkspaceUsage>;
+
+export function ImportLibrary(arg1:boolean):Promise<results.ImportReport>;
+
+export function ImportLibraryFrom(arg1:string,arg2:boolean):Promise<results.ImportReport>;
+
 export function IsAnyTranslationInProgress():Promise<boolean>;
 
+export function IsFixInProgress():Promise<boolean>;
+
+export function IsNoCompileMode():Promise<boolean>;
+
 export function IsPDFTranslating():Promise<boolean>;
 
 export function IsProcessing():Promise<boolean>;
@@ -118,14 +200,22 @@ export function OpenDirectoryDialog():Promise<string>;
 
 export function OpenFileDialog():Promise<string>;
 
+export function OpenHTMLInSystem(arg1:string):Promise<void>;
+
 export function OpenPDFFileDialog():Promise<string>;
 
 export function OpenPDFInSystem(arg1:string):Promise<void>;
 
 export function OpenPaperResult(arg1:string):Promise<types.ProcessResult>;
 
+export function OpenTranslatedHTML(arg1:string):Promise<void>;
+
 export function OpenURLInBrowser(arg1:string):Promise<void>;
 
+export function PrepareJob(arg1:string):Promise<types.JobSummary>;
+
+export function PreviewChunking(arg1:string):Promise<types.ChunkingPreview>;
+
 export function ProcessSource(arg1:string):Promise<types.ProcessResult>;
 
 export function ProcessSourceWithForce(arg1:string,arg2:boolean):Promise<types.ProcessResult>;
@@ -138,36 +228,98 @@ export function RemoveInputHistory(arg1:string):Promise<void>;
 
 export function ReportErrorsToGitHub():Promise<github.IssueCreateResult>;
 
+export function ReprocessFromTranslatedTex(arg1:string):Promise<types.ProcessResult>;
+
 export function RequestSerialNumber(arg1:string):Promise<main.RequestSNResult>;
 
 export function RetranslateFromArxiv(arg1:string):Promise<types.ProcessResult>;
 
+export function RetryFailedChunks(arg1:string):Promise<types.ProcessResult>;
+
 export function RetryFromError(arg1:string):Promise<types.ProcessResult>;
 
 export function SaveLastInput(arg1:string):Promise<void>;
 
-export function SaveSettings(arg1:string,arg2:string,arg3:string,arg4:number,arg5:string,arg6:string,arg7:number,arg8:string,arg9:string,arg10:string,arg11:number,arg12:boolean):Promise<void>;
+export function SaveSettings(arg1:string,arg2:string,arg3:string,arg4:number,arg5:string,arg6:string,arg7:number,arg8:string,arg9:string,arg10:string,arg11:number,arg12:boolean,arg13:string,arg14:types.RequestShape,arg15:string):Promise<void>;
 
 export function SaveTranslatedPDF(arg1:string):Promise<string>;
 
 export function SearchGitHubTranslation(arg1:string):Promise<github.TranslationSearchResult>;
 
+export function SetAllowDuplicateJobs(arg1:boolean):Promise<void>;
+
+export function SetAutoInstallPackages(arg1:boolean):Promise<void>;
+
+export function SetChineseVariant(arg1:string):Promise<void>;
+
+export function SetChunkOverlap(arg1:boolean):Promise<void>;
+
+export function SetDebugCaptureDir(arg1:string):Promise<void>;
+
+export function SetFileOverrides(arg1:Array<string>,arg2:Array<string>):Promise<void>;
+
+export function SetForceTranslate(arg1:boolean):Promise<void>;
+
+export function SetGlossaryPath(arg1:string):Promise<void>;
+
+export function SetIgnoreIncludeOnly(arg1:boolean):Promise<void>;
+
+export function SetMaxConcurrentCompiles(arg1:number):Promise<void>;
+
+export function SetNoCompileMode(arg1:boolean,arg2:boolean):Promise<void>;
+
+export function SetOnCompleteHook(arg1:string):Promise<void>;
+
+export function SetPromptPreset(arg1:string):Promise<void>;
+
+export function SetQuickMode(arg1:boolean):Promise<void>;
+
+export function SetRequestTimeout(arg1:number):Promise<void>;
+
 export function SetStatusCallback(arg1:main.StatusCallback):Promise<void>;
 
+export function SetStrictFontEmbedding(arg1:boolean):Promise<void>;
+
+export function SetTargetLanguage(arg1:string):Promise<void>;
+
+export function SetTranslateBibliography(arg1:boolean):Promise<void>;
+
+export function SetTranslateComments(arg1:boolean):Promise<void>;
+
+export function SetTranslationBudget(arg1:number,arg2:number):Promise<void>;
+
 export function SetWailsRuntime(arg1:boolean):Promise<void>;
 
 export function SetWorkDir(arg1:string):Promise<void>;
 
 export function SetWorkMode(arg1:string):Promise<void>;
 
+export function SetWorkspacePolicy(arg1:types.WorkspacePolicy):Promise<void>;
+
 export function SharePaperToGitHub(arg1:string,arg2:string,arg3:boolean,arg4:boolean):Promise<main.ShareResult>;
 
 export function ShareToGitHub(arg1:string,arg2:boolean,arg3:boolean):Promise<main.ShareResult>;
 
-export function TestAPIConnection(arg1:string,arg2:string,arg3:string):Promise<void>;
+export function SkipRemainingFixes():Promise<void>;
+
+export function TakeInterruptedJobs():Promise<Array<results.ResumeEntry>>;
+
+export function TestAPIConnection(arg1:string,arg2:string,arg3:string,arg4:string,arg5:types.RequestShape,arg6:string):Promise<main.ConnectionTestResult>;
 
 export function TestGitHubConnection(arg1:string):Promise<void>;
 
+export function TranslateNewVersion(arg1:string):Promise<types.ProcessResult>;
+
 export function TranslatePDF():Promise<pdf.TranslationResult>;
 
 export function UpdateGitHubToken():Promise<void>;
+
+export function UpgradeToFullTranslation(arg1:string):Promise<types.ProcessResult>;
+
+export function UseAutoInstallPackages():Promise<void>;
+
+export function UseChineseVariant(arg1:string):Promise<void>;
+
+export function UseGlossary(arg1:string):Promise<void>;
+
+export function UseMaxConcurrentCompiles(arg1:number):Promise<void>;
@@ -10,6 +10,14 @@ export function AddInputHistory(arg1, arg2) {
   return window['go']['main']['App']['AddInputHistory'](arg1, arg2);
 }
 
+export function ApplyRecommendedContextWindow() {
+  return window['go']['main']['App']['ApplyRecommendedContextWindow']();
+}
+
+export function ApproveFix(arg1, arg2) {
+  return window['go']['main']['App']['ApproveFix'](arg1, arg2);
+}
+
 export function CancelPDFTranslation() {
   return window['go']['main']['App']['CancelPDFTranslation']();
 }
@@ -18,6 +26,10 @@ export function CancelProcess() {
   return window['go']['main']['App']['CancelProcess']();
 }
 
+export function CheckContextWindow(arg1, arg2) {
+  return window['go']['main']['App']['CheckContextWindow'](arg1, arg2);
+}
+
 export function CheckExistingTranslation(arg1) {
   return window['go']['main']['App']['CheckExistingTranslation'](arg1);
 }
@@ -42,6 +54,18 @@ export function CheckStartupRequirements() {
   return window['go']['main']['App']['CheckStartupRequirements']();
 }
 
+export function CheckWorkDirectory(arg1) {
+  return window['go']['main']['App']['CheckWorkDirectory'](arg1);
+}
+
+export function ChooseMainTex(arg1) {
+  return window['go']['main']['App']['ChooseMainTex'](arg1);
+}
+
+export function CleanWorkspaces(arg1) {
+  return window['go']['main']['App']['CleanWorkspaces'](arg1);
+}
+
 export function ClearAllErrors() {
   return window['go']['main']['App']['ClearAllErrors']();
 }
@@ -54,20 +78,32 @@ export function ClearInputHistory() {
   return window['go']['main']['App']['ClearInputHistory']();
 }
 
+export function ConfirmJob(arg1) {
+  return window['go']['main']['App']['ConfirmJob'](arg1);
+}
+
 export function ContinueTranslation(arg1) {
   return window['go']['main']['App']['ContinueTranslation'](arg1);
 }
 
+export function DeleteLibraryPaper(arg1) {
+  return window['go']['main']['App']['DeleteLibraryPaper'](arg1);
+}
+
 export function DeleteTranslatedPaper(arg1) {
   return window['go']['main']['App']['DeleteTranslatedPaper'](arg1);
 }
 
+export function DiscardJob(arg1) {
+  return window['go']['main']['App']['DiscardJob'](arg1);
+}
+
 export function DownloadAndOpenGitHubTranslation(arg1, arg2, arg3) {
   return window['go']['main']['App']['DownloadAndOpenGitHubTranslation'](arg1, arg2, arg3);
 }
 
-export function DownloadBilingualPDF() {
-  return window['go']['main']['App']['DownloadBilingualPDF']();
+export function DownloadBilingualPDF(arg1) {
+  return window['go']['main']['App']['DownloadBilingualPDF'](arg1);
 }
 
 export function DownloadChinesePDF() {
@@ -86,6 +122,10 @@ export function EnsureGitHubToken() {
   return window['go']['main']['App']['EnsureGitHubToken']();
 }
 
+export function EstimateSource(arg1) {
+  return window['go']['main']['App']['EstimateSource'](arg1);
+}
+
 export function ExportErrorIDsToFile() {
   return window['go']['main']['App']['ExportErrorIDsToFile']();
 }
@@ -94,6 +134,14 @@ export function ExportErrorsToFile() {
   return window['go']['main']['App']['ExportErrorsToFile']();
 }
 
+export function ExportLibrary(arg1) {
+  return window['go']['main']['App']['ExportLibrary'](arg1);
+}
+
+export function ExportLibraryTo(arg1, arg2) {
+  return window['go']['main']['App']['ExportLibraryTo'](arg1, arg2);
+}
+
 export function FetchAndDecodeGitHubToken() {
   return window['go']['main']['App']['FetchAndDecodeGitHubToken']();
 }
@@ -102,6 +150,22 @@ export function GetArxivPaperMetadata(arg1) {
   return window['go']['main']['App']['GetArxivPaperMetadata'](arg1);
 }
 
+export function GetAutoInstallPackages() {
+  return window['go']['main']['App']['GetAutoInstallPackages']();
+}
+
+export function GetChineseVariant() {
+  return window['go']['main']['App']['GetChineseVariant']();
+}
+
+export function GetChunkOverlap() {
+  return window['go']['main']['App']['GetChunkOverlap']();
+}
+
+export function GetCloseSummary() {
+  return window['go']['main']['App']['GetCloseSummary']();
+}
+
 export function GetCompiler() {
   return window['go']['main']['App']['GetCompiler']();
 }
@@ -110,14 +174,34 @@ export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetDebugCaptureDir() {
+  return window['go']['main']['App']['GetDebugCaptureDir']();
+}
+
+export function GetDocumentOutline(arg1) {
+  return window['go']['main']['App']['GetDocumentOutline'](arg1);
+}
+
 export function GetDownloader() {
   return window['go']['main']['App']['GetDownloader']();
 }
 
+export function GetGlossaryPath() {
+  return window['go']['main']['App']['GetGlossaryPath']();
+}
+
+export function GetIgnoreIncludeOnly() {
+  return window['go']['main']['App']['GetIgnoreIncludeOnly']();
+}
+
 export function GetInputHistory() {
   return window['go']['main']['App']['GetInputHistory']();
 }
 
+export function GetInterruptedJobs() {
+  return window['go']['main']['App']['GetInterruptedJobs']();
+}
+
 export function GetLaTeXDownloadURL() {
   return window['go']['main']['App']['GetLaTeXDownloadURL']();
 }
@@ -126,10 +210,26 @@ export function GetLastInput() {
   return window['go']['main']['App']['GetLastInput']();
 }
 
+export function GetLibraryPapers(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetLibraryPapers'](arg1, arg2, arg3);
+}
+
+export function GetLibraryStats() {
+  return window['go']['main']['App']['GetLibraryStats']();
+}
+
 export function GetLicenseInfo() {
   return window['go']['main']['App']['GetLicenseInfo']();
 }
 
+export function GetMaxConcurrentCompiles() {
+  return window['go']['main']['App']['GetMaxConcurrentCompiles']();
+}
+
+export function GetOnCompleteHook() {
+  return window['go']['main']['App']['GetOnCompleteHook']();
+}
+
 export function GetPDFDataURL(arg1) {
   return window['go']['main']['App']['GetPDFDataURL'](arg1);
 }
@@ -142,6 +242,26 @@ export function GetPaperCategories() {
   return window['go']['main']['App']['GetPaperCategories']();
 }
 
+export function GetPromptPreset() {
+  return window['go']['main']['App']['GetPromptPreset']();
+}
+
+export function GetPromptPresets() {
+  return window['go']['main']['App']['GetPromptPresets']();
+}
+
+export function GetProvenance(arg1) {
+  return window['go']['main']['App']['GetProvenance'](arg1);
+}
+
+export function GetQuickModeDowngrades() {
+  return window['go']['main']['App']['GetQuickModeDowngrades']();
+}
+
+export function GetRequestTimeoutSeconds() {
+  return window['go']['main']['App']['GetRequestTimeoutSeconds']();
+}
+
 export function GetResultsDirectory() {
   return window['go']['main']['App']['GetResultsDirectory']();
 }
@@ -154,6 +274,22 @@ export function GetStatus() {
   return window['go']['main']['App']['GetStatus']();
 }
 
+export function GetStrictFontEmbedding() {
+  return window['go']['main']['App']['GetStrictFontEmbedding']();
+}
+
+export function GetTargetLanguage() {
+  return window['go']['main']['App']['GetTargetLanguage']();
+}
+
+export function GetTranslateBibliography() {
+  return window['go']['main']['App']['GetTranslateBibliography']();
+}
+
+export function GetTranslateComments() {
+  return window['go']['main']['App']['GetTranslateComments']();
+}
+
 export function GetTranslatedPDFPath() {
   return window['go']['main']['App']['GetTranslatedPDFPath']();
 }
@@ -162,6 +298,10 @@ export function GetTranslator() {
   return window['go']['main']['App']['GetTranslator']();
 }
 
+export function GetUsageStats(arg1) {
+  return window['go']['main']['App']['GetUsageStats'](arg1);
+}
+
 export function GetValidator() {
   return window['go']['main']['App']['GetValidator']();
 }
@@ -174,10 +314,34 @@ export function GetWorkMode() {
   return window['go']['main']['App']['GetWorkMode']();
 }
 
+export function GetWorkspacePolicy() {
+  return window['go']['main']['App']['GetWorkspacePolicy']();
+}
+
+export function GetWorkspaceUsage() {
+  return window['go']['main']['App']['GetWorkspaceUsage']();
+}
+
+export function ImportLibrary(arg1) {
+  return window['go']['main']['App']['ImportLibrary'](arg1);
+}
+
+export function ImportLibraryFrom(arg1, arg2) {
+  return window['go']['main']['App']['ImportLibraryFrom'](arg1, arg2);
+}
+
 export function IsAnyTranslationInProgress() {
   return window['go']['main']['App']['IsAnyTranslationInProgress']();
 }
 
+export function IsFixInProgress() {
+  return window['go']['main']['App']['IsFixInProgress']();
+}
+
+export function IsNoCompileMode() {
+  return window['go']['main']['App']['IsNoCompileMode']();
+}
+
 export function IsPDFTranslating() {
   return window['go']['main']['App']['IsPDFTranslating']();
 }
@@ -214,6 +378,10 @@ export function OpenFileDialog() {
   return window['go']['main']['App']['OpenFileDialog']();
 }
 
+export function OpenHTMLInSystem(arg1) {
+  return window['go']['main']['App']['OpenHTMLInSystem'](arg1);
+}
+
 export function OpenPDFFileDialog() {
   return window['go']['main']['App']['OpenPDFFileDialog']();
 }
@@ -226,10 +394,22 @@ export function OpenPaperResult(arg1) {
   return window['go']['main']['App']['OpenPaperResult'](arg1);
 }
 
+export function OpenTranslatedHTML(arg1) {
+  return window['go']['main']['App']['OpenTranslatedHTML'](arg1);
+}
+
 export function OpenURLInBrowser(arg1) {
   return window['go']['main']['App']['OpenURLInBrowser'](arg1);
 }
 
+export function PrepareJob(arg1) {
+  return window['go']['main']['App']['PrepareJob'](arg1);
+}
+
+export function PreviewChunking(arg1) {
+  return window['go']['main']['App']['PreviewChunking'](arg1);
+}
+
 export function ProcessSource(arg1) {
   return window['go']['main']['App']['ProcessSource'](arg1);
 }
@@ -254,6 +434,10 @@ export function ReportErrorsToGitHub() {
   return window['go']['main']['App']['ReportErrorsToGitHub']();
 }
 
+export function ReprocessFromTranslatedTex(arg1) {
+  return window['go']['main']['App']['ReprocessFromTranslatedTex'](arg1);
+}
+
 export function RequestSerialNumber(arg1) {
   return window['go']['main']['App']['RequestSerialNumber'](arg1);
 }
@@ -262,6 +446,10 @@ export function RetranslateFromArxiv(arg1) {
   return window['go']['main']['App']['RetranslateFromArxiv'](arg1);
 }
 
+export function RetryFailedChunks(arg1) {
+  return window['go']['main']['App']['RetryFailedChunks'](arg1);
+}
+
 export function RetryFromError(arg1) {
   return window['go']['main']['App']['RetryFromError'](arg1);
 }
@@ -270,8 +458,8 @@ export function SaveLastInput(arg1) {
   return window['go']['main']['App']['SaveLastInput'](arg1);
 }
 
-export function SaveSettings(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11, arg12) {
-  return window['go']['main']['App']['SaveSettings'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11, arg12);
+export function SaveSettings(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11, arg12, arg13, arg14, arg15) {
+  return window['go']['main']['App']['SaveSettings'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11, arg12, arg13, arg14, arg15);
 }
 
 export function SaveTranslatedPDF(arg1) {
@@ -282,10 +470,90 @@ export function SearchGitHubTranslation(arg1) {
   return window['go']['main']['App']['SearchGitHubTranslation'](arg1);
 }
 
+export function SetAllowDuplicateJobs(arg1) {
+  return window['go']['main']['App']['SetAllowDuplicateJobs'](arg1);
+}
+
+export function SetAutoInstallPackages(arg1) {
+  return window['go']['main']['App']['SetAutoInstallPackages'](arg1);
+}
+
+export function SetChineseVariant(arg1) {
+  return window['go']['main']['App']['SetChineseVariant'](arg1);
+}
+
+export function SetChunkOverlap(arg1) {
+  return window['go']['main']['App']['SetChunkOverlap'](arg1);
+}
+
+export function SetDebugCaptureDir(arg1) {
+  return window['go']['main']['App']['SetDebugCaptureDir'](arg1);
+}
+
+export function SetFileOverrides(arg1, arg2) {
+  return window['go']['main']['App']['SetFileOverrides'](arg1, arg2);
+}
+
+export function SetForceTranslate(arg1) {
+  return window['go']['main']['App']['SetForceTranslate'](arg1);
+}
+
+export function SetGlossaryPath(arg1) {
+  return window['go']['main']['App']['SetGlossaryPath'](arg1);
+}
+
+export function SetIgnoreIncludeOnly(arg1) {
+  return window['go']['main']['App']['SetIgnoreIncludeOnly'](arg1);
+}
+
+export function SetMaxConcurrentCompiles(arg1) {
+  return window['go']['main']['App']['SetMaxConcurrentCompiles'](arg1);
+}
+
+export function SetNoCompileMode(arg1, arg2) {
+  return window['go']['main']['App']['SetNoCompileMode'](arg1, arg2);
+}
+
+export function SetOnCompleteHook(arg1) {
+  return window['go']['main']['App']['SetOnCompleteHook'](arg1);
+}
+
+export function SetPromptPreset(arg1) {
+  return window['go']['main']['App']['SetPromptPreset'](arg1);
+}
+
+export function SetQuickMode(arg1) {
+  return window['go']['main']['App']['SetQuickMode'](arg1);
+}
+
+export function SetRequestTimeout(arg1) {
+  return window['go']['main']['App']['SetRequestTimeout'](arg1);
+}
+
 export function SetStatusCallback(arg1) {
   return window['go']['main']['App']['SetStatusCallback'](arg1);
 }
 
+export function SetStrictFontEmbedding(arg1) {
+  return window['go']['main']['App']['SetStrictFontEmbedding'](arg1);
+}
+
+export function SetTargetLanguage(arg1) {
+  return window['go']['main']['App']['SetTargetLanguage'](arg1);
+}
+
+export function SetTranslateBibliography(arg1) {
+  return window['go']['main']['App']['SetTranslateBibliography'](arg1);
+}
+
+export function SetTranslateComments(arg1) {
+  return window['go']['main']['App']['SetTranslateComments'](arg1);
+}
+
+export function SetTranslationBudget(arg1, arg2) {
+  return window['go']['main']['App']['SetTranslationBudget'](arg1, arg2);
+}
+
 export function SetWailsRuntime(arg1) {
   return window['go']['main']['App']['SetWailsRuntime'](arg1);
 }
@@ -298,6 +566,10 @@ export function SetWorkMode(arg1) {
   return window['go']['main']['App']['SetWorkMode'](arg1);
 }
 
+export function SetWorkspacePolicy(arg1) {
+  return window['go']['main']['App']['SetWorkspacePolicy'](arg1);
+}
+
 export function SharePaperToGitHub(arg1, arg2, arg3, arg4) {
   return window['go']['main']['App']['SharePaperToGitHub'](arg1, arg2, arg3, arg4);
 }
@@ -306,14 +578,26 @@ export function ShareToGitHub(arg1, arg2, arg3) {
   return window['go']['main']['App']['ShareToGitHub'](arg1, arg2, arg3);
 }
 
-export function TestAPIConnection(arg1, arg2, arg3) {
-  return window['go']['main']['App']['TestAPIConnection'](arg1, arg2, arg3);
+export function SkipRemainingFixes() {
+  return window['go']['main']['App']['SkipRemainingFixes']();
+}
+
+export function TakeInterruptedJobs() {
+  return window['go']['main']['App']['TakeInterruptedJobs']();
+}
+
+export function TestAPIConnection(arg1, arg2, arg3, arg4, arg5, arg6) {
+  return window['go']['main']['App']['TestAPIConnection'](arg1, arg2, arg3, arg4, arg5, arg6);
 }
 
 export function TestGitHubConnection(arg1) {
   return window['go']['main']['App']['TestGitHubConnection'](arg1);
 }
 
+export function TranslateNewVersion(arg1) {
+  return window['go']['main']['App']['TranslateNewVersion'](arg1);
+}
+
 export function TranslatePDF() {
   return window['go']['main']['App']['TranslatePDF']();
 }
@@ -321,3 +605,23 @@ export function TranslatePDF() {
 export function UpdateGitHubToken() {
   return window['go']['main']['App']['UpdateGitHubToken']();
 }
+
+export function UpgradeToFullTranslation(arg1) {
+  return window['go']['main']['App']['UpgradeToFullTranslation'](arg1);
+}
+
+export function UseAutoInstallPackages() {
+  return window['go']['main']['App']['UseAutoInstallPackages']();
+}
+
+export function UseChineseVariant(arg1) {
+  return window['go']['main']['App']['UseChineseVariant'](arg1);
+}
+
+export function UseGlossary(arg1) {
+  return window['go']['main']['App']['UseGlossary'](arg1);
+}
+
+export function UseMaxConcurrentCompiles(arg1) {
+  return window['go']['main']['App']['UseMaxConcurrentCompiles'](arg1);
+}
@@ -228,6 +228,42 @@ export namespace main {
 	        this.authors = source["authors"];
 	    }
 	}
+	export class ConnectionTestResult {
+	    proxy_used: boolean;
+	    proxy?: string;
+	    adjustments?: string[];
+	    request_shape: types.RequestShape;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConnectionTestResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxy_used = source["proxy_used"];
+	        this.proxy = source["proxy"];
+	        this.adjustments = source["adjustments"];
+	        this.request_shape = this.convertValues(source["request_shape"], types.RequestShape);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class DownloadAndOpenResult {
 	    success: boolean;
 	    chinese_path?: string;
@@ -361,8 +397,13 @@ export namespace main {
 	export class StartupCheckResult {
 	    latex_installed: boolean;
 	    latex_version: string;
+	    latexmk_installed: boolean;
+	    latexmk_version: string;
+	    cjk_fonts_available: boolean;
+	    cjk_font: string;
 	    llm_configured: boolean;
 	    llm_error: string;
+	    no_compile_suggested: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new StartupCheckResult(source);
@@ -372,8 +413,13 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.latex_installed = source["latex_installed"];
 	        this.latex_version = source["latex_version"];
+	        this.latexmk_installed = source["latexmk_installed"];
+	        this.latexmk_version = source["latexmk_version"];
+	        this.cjk_fonts_available = source["cjk_fonts_available"];
+	        this.cjk_font = source["cjk_font"];
 	        this.llm_configured = source["llm_configured"];
 	        this.llm_error = source["llm_error"];
+	        this.no_compile_suggested = source["no_compile_suggested"];
 	    }
 	}
 
@@ -518,6 +564,11 @@ export namespace pdf {
 	    translated_blocks: number;
 	    cached_blocks: number;
 	    tokens_used: number;
+	    input_tokens: number;
+	    output_tokens: number;
+	    model?: string;
+	    estimated_cost?: number;
+	    outline_entries: number;
 	    page_count_result?: PageCountResult;
 	    content_validation?: ContentValidationResult;
 	
@@ -533,6 +584,11 @@ export namespace pdf {
 	        this.translated_blocks = source["translated_blocks"];
 	        this.cached_blocks = source["cached_blocks"];
 	        this.tokens_used = source["tokens_used"];
+	        this.input_tokens = source["input_tokens"];
+	        this.output_tokens = source["output_tokens"];
+	        this.model = source["model"];
+	        this.estimated_cost = source["estimated_cost"];
+	        this.outline_entries = source["outline_entries"];
 	        this.page_count_result = this.convertValues(source["page_count_result"], PageCountResult);
 	        this.content_validation = this.convertValues(source["content_validation"], ContentValidationResult);
 	    }
@@ -575,9 +631,18 @@ export namespace results {
 	    last_phase?: string;
 	    original_input?: string;
 	    main_tex_file?: string;
+	    main_tex_fallback_from?: string;
 	    source_type?: string;
 	    source_md5?: string;
 	    source_file_name?: string;
+	    chinese_variant?: string;
+	    origin?: string;
+	    translation_mode?: string;
+	    input_tokens?: number;
+	    output_tokens?: number;
+	    model?: string;
+	    estimated_cost?: number;
+	    failed_chunks?: {[key: string]: number[]};
 	
 	    static createFrom(source: any = {}) {
 	        return new PaperInfo(source);
@@ -598,9 +663,18 @@ export namespace results {
 	        this.last_phase = source["last_phase"];
 	        this.original_input = source["original_input"];
 	        this.main_tex_file = source["main_tex_file"];
+	        this.main_tex_fallback_from = source["main_tex_fallback_from"];
 	        this.source_type = source["source_type"];
 	        this.source_md5 = source["source_md5"];
 	        this.source_file_name = source["source_file_name"];
+	        this.chinese_variant = source["chinese_variant"];
+	        this.origin = source["origin"];
+	        this.translation_mode = source["translation_mode"];
+	        this.input_tokens = source["input_tokens"];
+	        this.output_tokens = source["output_tokens"];
+	        this.model = source["model"];
+	        this.estimated_cost = source["estimated_cost"];
+	        this.failed_chunks = source["failed_chunks"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -659,86 +733,95 @@ export namespace results {
 		    return a;
 		}
 	}
-
-}
-
-export namespace translator {
+	export class ImportResult {
+	    arxiv_id: string;
+	    title: string;
+	    status: string;
+	    error?: string;
 	
-	export class TranslationEngine {
+	    static createFrom(source: any = {}) {
+	        return new ImportResult(source);
+	    }
 	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.arxiv_id = source["arxiv_id"];
+	        this.title = source["title"];
+	        this.status = source["status"];
+	        this.error = source["error"];
+	    }
+	}
+	export class ImportReport {
+	    version: number;
+	    results: ImportResult[];
+	    imported: number;
+	    skipped: number;
+	    failed: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new TranslationEngine(source);
+	        return new ImportReport(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	
+	        this.version = source["version"];
+	        this.results = this.convertValues(source["results"], ImportResult);
+	        this.imported = source["imported"];
+	        this.skipped = source["skipped"];
+	        this.failed = source["failed"];
 	    }
-	}
-
-}
-
-export namespace types {
 	
-	export class InputHistoryItem {
-	    input: string;
-	    timestamp: number;
-	    type: string;
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ManifestPaper {
+	    arxiv_id: string;
+	    title: string;
+	    dir: string;
+	    base_dir: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new InputHistoryItem(source);
+	        return new ManifestPaper(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.input = source["input"];
-	        this.timestamp = source["timestamp"];
-	        this.type = source["type"];
+	        this.arxiv_id = source["arxiv_id"];
+	        this.title = source["title"];
+	        this.dir = source["dir"];
+	        this.base_dir = source["base_dir"];
 	    }
 	}
-	export class Config {
-	    openai_api_key: string;
-	    openai_base_url: string;
-	    openai_model: string;
-	    context_window: number;
-	    default_compiler: string;
-	    work_directory: string;
-	    last_input: string;
-	    input_history: InputHistoryItem[];
-	    concurrency: number;
-	    github_token: string;
-	    github_owner: string;
-	    github_repo: string;
-	    library_page_size: number;
-	    share_prompt_enabled: boolean;
-	    work_mode?: string;
-	    serial_number?: string;
-	    encrypted_license_info?: string;
+	export class LibraryManifest {
+	    version: number;
+	    // Go type: time
+	    exported_at: any;
+	    papers: ManifestPaper[];
 	
 	    static createFrom(source: any = {}) {
-	        return new Config(source);
+	        return new LibraryManifest(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.openai_api_key = source["openai_api_key"];
-	        this.openai_base_url = source["openai_base_url"];
-	        this.openai_model = source["openai_model"];
-	        this.context_window = source["context_window"];
-	        this.default_compiler = source["default_compiler"];
-	        this.work_directory = source["work_directory"];
-	        this.last_input = source["last_input"];
-	        this.input_history = this.convertValues(source["input_history"], InputHistoryItem);
-	        this.concurrency = source["concurrency"];
-	        this.github_token = source["github_token"];
-	        this.github_owner = source["github_owner"];
-	        this.github_repo = source["github_repo"];
-	        this.library_page_size = source["library_page_size"];
-	        this.share_prompt_enabled = source["share_prompt_enabled"];
-	        this.work_mode = source["work_mode"];
-	        this.serial_number = source["serial_number"];
-	        this.encrypted_license_info = source["encrypted_license_info"];
+	        this.version = source["version"];
+	        this.exported_at = this.convertValues(source["exported_at"], null);
+	        this.papers = this.convertValues(source["papers"], ManifestPaper);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -759,61 +842,157 @@ export namespace types {
 		    return a;
 		}
 	}
+	export class LibraryPaper {
+	    arxiv_id: string;
+	    title: string;
+	    // Go type: time
+	    translated_at: any;
+	    original_pdf: string;
+	    translated_pdf: string;
+	    bilingual_pdf?: string;
+	    source_dir: string;
+	    has_latex_source: boolean;
+	    status: string;
+	    error_message?: string;
+	    last_phase?: string;
+	    original_input?: string;
+	    main_tex_file?: string;
+	    main_tex_fallback_from?: string;
+	    source_type?: string;
+	    source_md5?: string;
+	    source_file_name?: string;
+	    chinese_variant?: string;
+	    origin?: string;
+	    translation_mode?: string;
+	    size_bytes: number;
 	
-	export class PaperCategory {
-	    id: string;
-	    name: string;
-	    description: string;
+	    static createFrom(source: any = {}) {
+	        return new LibraryPaper(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.arxiv_id = source["arxiv_id"];
+	        this.title = source["title"];
+	        this.translated_at = this.convertValues(source["translated_at"], null);
+	        this.original_pdf = source["original_pdf"];
+	        this.translated_pdf = source["translated_pdf"];
+	        this.bilingual_pdf = source["bilingual_pdf"];
+	        this.source_dir = source["source_dir"];
+	        this.has_latex_source = source["has_latex_source"];
+	        this.status = source["status"];
+	        this.error_message = source["error_message"];
+	        this.last_phase = source["last_phase"];
+	        this.original_input = source["original_input"];
+	        this.main_tex_file = source["main_tex_file"];
+	        this.main_tex_fallback_from = source["main_tex_fallback_from"];
+	        this.source_type = source["source_type"];
+	        this.source_md5 = source["source_md5"];
+	        this.source_file_name = source["source_file_name"];
+	        this.chinese_variant = source["chinese_variant"];
+	        this.origin = source["origin"];
+	        this.translation_mode = source["translation_mode"];
+	        this.size_bytes = source["size_bytes"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LibraryPage {
+	    papers: LibraryPaper[];
+	    total: number;
+	    page: number;
+	    page_size: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new PaperCategory(source);
+	        return new LibraryPage(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.id = source["id"];
-	        this.name = source["name"];
-	        this.description = source["description"];
+	        this.papers = this.convertValues(source["papers"], LibraryPaper);
+	        this.total = source["total"];
+	        this.page = source["page"];
+	        this.page_size = source["page_size"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
-	export class SourceInfo {
-	    source_type: string;
-	    original_ref: string;
-	    extract_dir: string;
-	    main_tex_file: string;
-	    all_tex_files: string[];
+	export class LibraryStats {
+	    total_papers: number;
+	    complete_papers: number;
+	    disk_usage: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new SourceInfo(source);
+	        return new LibraryStats(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.source_type = source["source_type"];
-	        this.original_ref = source["original_ref"];
-	        this.extract_dir = source["extract_dir"];
-	        this.main_tex_file = source["main_tex_file"];
-	        this.all_tex_files = source["all_tex_files"];
+	        this.total_papers = source["total_papers"];
+	        this.complete_papers = source["complete_papers"];
+	        this.disk_usage = source["disk_usage"];
 	    }
 	}
-	export class ProcessResult {
-	    original_pdf_path: string;
-	    translated_pdf_path: string;
-	    bilingual_pdf_path: string;
-	    source_info?: SourceInfo;
-	    source_id: string;
+	export class JournalEntry {
+	    input?: string;
+	    arxiv_id?: string;
+	    phase: string;
+	    progress: number;
+	    message?: string;
+	    work_dir?: string;
+	    main_tex_file?: string;
+	    // Go type: time
+	    started_at: any;
+	    // Go type: time
+	    updated_at: any;
 	
 	    static createFrom(source: any = {}) {
-	        return new ProcessResult(source);
+	        return new JournalEntry(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.original_pdf_path = source["original_pdf_path"];
-	        this.translated_pdf_path = source["translated_pdf_path"];
-	        this.bilingual_pdf_path = source["bilingual_pdf_path"];
-	        this.source_info = this.convertValues(source["source_info"], SourceInfo);
-	        this.source_id = source["source_id"];
+	        this.input = source["input"];
+	        this.arxiv_id = source["arxiv_id"];
+	        this.phase = source["phase"];
+	        this.progress = source["progress"];
+	        this.message = source["message"];
+	        this.work_dir = source["work_dir"];
+	        this.main_tex_file = source["main_tex_file"];
+	        this.started_at = this.convertValues(source["started_at"], null);
+	        this.updated_at = this.convertValues(source["updated_at"], null);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -835,25 +1014,1128 @@ export namespace types {
 		}
 	}
 	
-	export class Status {
-	    phase: string;
-	    progress: number;
-	    message: string;
-	    error?: string;
+	export class ResumeEntry {
+	    input?: string;
+	    library_id?: string;
+	    phase?: string;
+	    needs_confirmation?: boolean;
+	    // Go type: time
+	    interrupted_at: any;
 	
 	    static createFrom(source: any = {}) {
-	        return new Status(source);
+	        return new ResumeEntry(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.input = source["input"];
+	        this.library_id = source["library_id"];
 	        this.phase = source["phase"];
-	        this.progress = source["progress"];
-	        this.message = source["message"];
-	        this.error = source["error"];
+	        this.needs_confirmation = source["needs_confirmation"];
+	        this.interrupted_at = this.convertValues(source["interrupted_at"], null);
 	    }
-	}
-
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ModelUsageStats {
+	    model: string;
+	    papers: number;
+	    input_tokens: number;
+	    output_tokens: number;
+	    estimated_cost: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ModelUsageStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.model = source["model"];
+	        this.papers = source["papers"];
+	        this.input_tokens = source["input_tokens"];
+	        this.output_tokens = source["output_tokens"];
+	        this.estimated_cost = source["estimated_cost"];
+	    }
+	}
+	export class UsageStats {
+	    // Go type: time
+	    since: any;
+	    papers: number;
+	    input_tokens: number;
+	    output_tokens: number;
+	    total_tokens: number;
+	    estimated_cost: number;
+	    avg_tokens_per_paper: number;
+	    avg_cost_per_paper: number;
+	    models: ModelUsageStats[];
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.since = this.convertValues(source["since"], null);
+	        this.papers = source["papers"];
+	        this.input_tokens = source["input_tokens"];
+	        this.output_tokens = source["output_tokens"];
+	        this.total_tokens = source["total_tokens"];
+	        this.estimated_cost = source["estimated_cost"];
+	        this.avg_tokens_per_paper = source["avg_tokens_per_paper"];
+	        this.avg_cost_per_paper = source["avg_cost_per_paper"];
+	        this.models = this.convertValues(source["models"], ModelUsageStats);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace translator {
+	
+	export class TranslationEngine {
+	
+	
+	    static createFrom(source: any = {}) {
+	        return new TranslationEngine(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	
+	    }
+	}
+
+}
+
+export namespace types {
+	
+	export class InputHistoryItem {
+	    input: string;
+	    timestamp: number;
+	    type: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new InputHistoryItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.input = source["input"];
+	        this.timestamp = source["timestamp"];
+	        this.type = source["type"];
+	    }
+	}
+	export class ChunkPreview {
+	    index: number;
+	    start: number;
+	    end: number;
+	    head: string;
+	    tail: string;
+	    environments?: string[];
+	    estimated_tokens: number;
+	    section?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChunkPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.index = source["index"];
+	        this.start = source["start"];
+	        this.end = source["end"];
+	        this.head = source["head"];
+	        this.tail = source["tail"];
+	        this.environments = source["environments"];
+	        this.estimated_tokens = source["estimated_tokens"];
+	        this.section = source["section"];
+	    }
+	}
+	export class DataBlob {
+	    kind: string;
+	    name?: string;
+	    line: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DataBlob(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.name = source["name"];
+	        this.line = source["line"];
+	        this.size = source["size"];
+	    }
+	}
+	export class FileMetrics {
+	    size: number;
+	    prose_lines: number;
+	    command_lines: number;
+	    table_lines: number;
+	    code_ratio: number;
+	    cjk_ratio: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileMetrics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.size = source["size"];
+	        this.prose_lines = source["prose_lines"];
+	        this.command_lines = source["command_lines"];
+	        this.table_lines = source["table_lines"];
+	        this.code_ratio = source["code_ratio"];
+	        this.cjk_ratio = source["cjk_ratio"];
+	    }
+	}
+	export class FileDecision {
+	    file: string;
+	    kind: string;
+	    decision: string;
+	    rule: string;
+	    reason: string;
+	    metrics: FileMetrics;
+	    override?: string;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileDecision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.file = source["file"];
+	        this.kind = source["kind"];
+	        this.decision = source["decision"];
+	        this.rule = source["rule"];
+	        this.reason = source["reason"];
+	        this.metrics = this.convertValues(source["metrics"], FileMetrics);
+	        this.override = source["override"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class FileChunkPreview {
+	    file: string;
+	    size: number;
+	    skipped: boolean;
+	    skip_reason?: string;
+	    skipped_data_blobs?: DataBlob[];
+	    chunks: ChunkPreview[];
+	    decision?: FileDecision;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileChunkPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.file = source["file"];
+	        this.size = source["size"];
+	        this.skipped = source["skipped"];
+	        this.skip_reason = source["skip_reason"];
+	        this.skipped_data_blobs = this.convertValues(source["skipped_data_blobs"], DataBlob);
+	        this.chunks = this.convertValues(source["chunks"], ChunkPreview);
+	        this.decision = this.convertValues(source["decision"], FileDecision);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ChunkingPreview {
+	    extract_dir: string;
+	    main_tex_file: string;
+	    files: FileChunkPreview[];
+	    total_chunks: number;
+	    estimated_tokens: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChunkingPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.extract_dir = source["extract_dir"];
+	        this.main_tex_file = source["main_tex_file"];
+	        this.files = this.convertValues(source["files"], FileChunkPreview);
+	        this.total_chunks = source["total_chunks"];
+	        this.estimated_tokens = source["estimated_tokens"];
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ContextWindowAdvice {
+	    model: string;
+	    configured: number;
+	    model_max?: number;
+	    recommended: number;
+	    known: boolean;
+	    learned?: boolean;
+	    mismatch: boolean;
+	    message?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ContextWindowAdvice(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.model = source["model"];
+	        this.configured = source["configured"];
+	        this.model_max = source["model_max"];
+	        this.recommended = source["recommended"];
+	        this.known = source["known"];
+	        this.learned = source["learned"];
+	        this.mismatch = source["mismatch"];
+	        this.message = source["message"];
+	    }
+	}
+	export class RequestShape {
+	    omit_temperature?: boolean;
+	    max_tokens_field?: string;
+	    omit_response_format?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RequestShape(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.omit_temperature = source["omit_temperature"];
+	        this.max_tokens_field = source["max_tokens_field"];
+	        this.omit_response_format = source["omit_response_format"];
+	    }
+	}
+	export class WorkspacePolicy {
+	    keep_recent: number;
+	    max_age_days: number;
+	    max_size_gb: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspacePolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.keep_recent = source["keep_recent"];
+	        this.max_age_days = source["max_age_days"];
+	        this.max_size_gb = source["max_size_gb"];
+	    }
+	}
+	export class Config {
+	    openai_api_key: string;
+	    openai_base_url: string;
+	    openai_model: string;
+	    provider?: string;
+	    context_window: number;
+	    default_compiler: string;
+	    work_directory: string;
+	    last_input: string;
+	    input_history: InputHistoryItem[];
+	    concurrency: number;
+	    max_network_pause_minutes?: number;
+	    request_timeout_seconds?: number;
+	    budget_minutes?: number;
+	    budget_tokens?: number;
+	    chinese_variant?: string;
+	    target_language?: string;
+	    chinese_variant_phrases?: {[key: string]: string};
+	    max_concurrent_compiles?: number;
+	    strict_font_embedding?: boolean;
+	    model_context_windows?: {[key: string]: number};
+	    model_prices?: {[key: string]: ModelPrice};
+	    learned_context_windows?: {[key: string]: number};
+	    index_sort_keys?: string;
+	    glossary_path?: string;
+	    prompt_preset?: string;
+	    prompt_template_path?: string;
+	    translate_comments?: boolean;
+	    translate_bibliography?: boolean;
+	    chunk_overlap?: boolean;
+	    auto_install_packages?: boolean;
+	    ignore_includeonly?: boolean;
+	    debug_capture_dir?: string;
+	    on_complete_hook?: string;
+	    proxy?: string;
+	    request_shape?: RequestShape;
+	    workspace_policy?: WorkspacePolicy;
+	    github_token: string;
+	    github_owner: string;
+	    github_repo: string;
+	    library_page_size: number;
+	    share_prompt_enabled: boolean;
+	    work_mode?: string;
+	    serial_number?: string;
+	    encrypted_license_info?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Config(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.openai_api_key = source["openai_api_key"];
+	        this.openai_base_url = source["openai_base_url"];
+	        this.openai_model = source["openai_model"];
+	        this.provider = source["provider"];
+	        this.context_window = source["context_window"];
+	        this.default_compiler = source["default_compiler"];
+	        this.work_directory = source["work_directory"];
+	        this.last_input = source["last_input"];
+	        this.input_history = this.convertValues(source["input_history"], InputHistoryItem);
+	        this.concurrency = source["concurrency"];
+	        this.max_network_pause_minutes = source["max_network_pause_minutes"];
+	        this.request_timeout_seconds = source["request_timeout_seconds"];
+	        this.budget_minutes = source["budget_minutes"];
+	        this.budget_tokens = source["budget_tokens"];
+	        this.chinese_variant = source["chinese_variant"];
+	        this.target_language = source["target_language"];
+	        this.chinese_variant_phrases = source["chinese_variant_phrases"];
+	        this.max_concurrent_compiles = source["max_concurrent_compiles"];
+	        this.strict_font_embedding = source["strict_font_embedding"];
+	        this.model_context_windows = source["model_context_windows"];
+	        this.model_prices = this.convertValues(source["model_prices"], ModelPrice, true);
+	        this.learned_context_windows = source["learned_context_windows"];
+	        this.index_sort_keys = source["index_sort_keys"];
+	        this.glossary_path = source["glossary_path"];
+	        this.prompt_preset = source["prompt_preset"];
+	        this.prompt_template_path = source["prompt_template_path"];
+	        this.translate_comments = source["translate_comments"];
+	        this.translate_bibliography = source["translate_bibliography"];
+	        this.chunk_overlap = source["chunk_overlap"];
+	        this.auto_install_packages = source["auto_install_packages"];
+	        this.ignore_includeonly = source["ignore_includeonly"];
+	        this.debug_capture_dir = source["debug_capture_dir"];
+	        this.on_complete_hook = source["on_complete_hook"];
+	        this.proxy = source["proxy"];
+	        this.request_shape = this.convertValues(source["request_shape"], RequestShape);
+	        this.workspace_policy = this.convertValues(source["workspace_policy"], WorkspacePolicy);
+	        this.github_token = source["github_token"];
+	        this.github_owner = source["github_owner"];
+	        this.github_repo = source["github_repo"];
+	        this.library_page_size = source["library_page_size"];
+	        this.share_prompt_enabled = source["share_prompt_enabled"];
+	        this.work_mode = source["work_mode"];
+	        this.serial_number = source["serial_number"];
+	        this.encrypted_license_info = source["encrypted_license_info"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class CloseSummary {
+	    busy: boolean;
+	    latex_phase?: string;
+	    latex_progress: number;
+	    latex_message?: string;
+	    pdf_phase?: string;
+	    pdf_progress: number;
+	    queued_jobs: number;
+	    pending_fixes: number;
+	    items?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new CloseSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.busy = source["busy"];
+	        this.latex_phase = source["latex_phase"];
+	        this.latex_progress = source["latex_progress"];
+	        this.latex_message = source["latex_message"];
+	        this.pdf_phase = source["pdf_phase"];
+	        this.pdf_progress = source["pdf_progress"];
+	        this.queued_jobs = source["queued_jobs"];
+	        this.pending_fixes = source["pending_fixes"];
+	        this.items = source["items"];
+	    }
+	}
+	export class JobSummary {
+	    job_id: string;
+	    input: string;
+	    arxiv_id?: string;
+	    title: string;
+	    authors?: string[];
+	    primary_category?: string;
+	    categories?: string[];
+	    published?: string;
+	    updated?: string;
+	    versions?: number;
+	    license?: string;
+	    withdrawn: boolean;
+	    main_tex_file?: string;
+	    tex_files: number;
+	    source_size: number;
+	    total_chunks: number;
+	    estimated_tokens: number;
+	    estimated_minutes: number;
+	    red_flags?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new JobSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.job_id = source["job_id"];
+	        this.input = source["input"];
+	        this.arxiv_id = source["arxiv_id"];
+	        this.title = source["title"];
+	        this.authors = source["authors"];
+	        this.primary_category = source["primary_category"];
+	        this.categories = source["categories"];
+	        this.published = source["published"];
+	        this.updated = source["updated"];
+	        this.versions = source["versions"];
+	        this.license = source["license"];
+	        this.withdrawn = source["withdrawn"];
+	        this.main_tex_file = source["main_tex_file"];
+	        this.tex_files = source["tex_files"];
+	        this.source_size = source["source_size"];
+	        this.total_chunks = source["total_chunks"];
+	        this.estimated_tokens = source["estimated_tokens"];
+	        this.estimated_minutes = source["estimated_minutes"];
+	        this.red_flags = source["red_flags"];
+	    }
+	}
+	export class TranslationEstimate {
+	    chunks: number;
+	    input_tokens: number;
+	    output_tokens: number;
+	    estimated_seconds: number;
+	    mostly_code: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TranslationEstimate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.chunks = source["chunks"];
+	        this.input_tokens = source["input_tokens"];
+	        this.output_tokens = source["output_tokens"];
+	        this.estimated_seconds = source["estimated_seconds"];
+	        this.mostly_code = source["mostly_code"];
+	    }
+	}
+	export class FileEstimate {
+	    file: string;
+	    decision?: FileDecision;
+	    estimate: TranslationEstimate;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileEstimate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.file = source["file"];
+	        this.decision = this.convertValues(source["decision"], FileDecision);
+	        this.estimate = this.convertValues(source["estimate"], TranslationEstimate);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SourceEstimate {
+	    input: string;
+	    extract_dir: string;
+	    main_tex_file: string;
+	    concurrency: number;
+	    files: FileEstimate[];
+	    total: TranslationEstimate;
+	
+	    static createFrom(source: any = {}) {
+	        return new SourceEstimate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.input = source["input"];
+	        this.extract_dir = source["extract_dir"];
+	        this.main_tex_file = source["main_tex_file"];
+	        this.concurrency = source["concurrency"];
+	        this.files = this.convertValues(source["files"], FileEstimate);
+	        this.total = this.convertValues(source["total"], TranslationEstimate);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OutlineEntry {
+	    command: string;
+	    level: number;
+	    number?: string;
+	    title: string;
+	    starred?: boolean;
+	    appendix?: boolean;
+	    start: number;
+	    children?: OutlineEntry[];
+	
+	    static createFrom(source: any = {}) {
+	        return new OutlineEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.command = source["command"];
+	        this.level = source["level"];
+	        this.number = source["number"];
+	        this.title = source["title"];
+	        this.starred = source["starred"];
+	        this.appendix = source["appendix"];
+	        this.start = source["start"];
+	        this.children = this.convertValues(source["children"], OutlineEntry);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class PaperCategory {
+	    id: string;
+	    name: string;
+	    description: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PaperCategory(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	    }
+	}
+	export class Provenance {
+	    tool_version: string;
+	    git_commit?: string;
+	    model: string;
+	    prompt_hash: string;
+	    prompt_preset?: string;
+	    glossary_hash?: string;
+	    source_sha256?: string;
+	    job_timestamp: string;
+	    post_processing?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Provenance(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tool_version = source["tool_version"];
+	        this.git_commit = source["git_commit"];
+	        this.model = source["model"];
+	        this.prompt_hash = source["prompt_hash"];
+	        this.prompt_preset = source["prompt_preset"];
+	        this.glossary_hash = source["glossary_hash"];
+	        this.source_sha256 = source["source_sha256"];
+	        this.job_timestamp = source["job_timestamp"];
+	        this.post_processing = source["post_processing"];
+	    }
+	}
+	export class SourceInfo {
+	    source_type: string;
+	    original_ref: string;
+	    extract_dir: string;
+	    main_tex_file: string;
+	    all_tex_files: string[];
+	    main_tex_fallback_from?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SourceInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.source_type = source["source_type"];
+	        this.original_ref = source["original_ref"];
+	        this.extract_dir = source["extract_dir"];
+	        this.main_tex_file = source["main_tex_file"];
+	        this.all_tex_files = source["all_tex_files"];
+	        this.main_tex_fallback_from = source["main_tex_fallback_from"];
+	    }
+	}
+	export class ReuseStats {
+	    total_paragraphs: number;
+	    reused_paragraphs: number;
+	    total_bytes: number;
+	    reused_bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReuseStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.total_paragraphs = source["total_paragraphs"];
+	        this.reused_paragraphs = source["reused_paragraphs"];
+	        this.total_bytes = source["total_bytes"];
+	        this.reused_bytes = source["reused_bytes"];
+	    }
+	}
+	export class PDFFont {
+	    name: string;
+	    type: string;
+	    embedded: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PDFFont(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.type = source["type"];
+	        this.embedded = source["embedded"];
+	    }
+	}
+	export class FontAudit {
+	    pdf_path: string;
+	    fonts: PDFFont[];
+	    not_embedded?: string[];
+	    remediated?: boolean;
+	    remediation_error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FontAudit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.pdf_path = source["pdf_path"];
+	        this.fonts = this.convertValues(source["fonts"], PDFFont);
+	        this.not_embedded = source["not_embedded"];
+	        this.remediated = source["remediated"];
+	        this.remediation_error = source["remediation_error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProcessResult {
+	    original_pdf_path: string;
+	    translated_pdf_path: string;
+	    bilingual_pdf_path: string;
+	    source_info?: SourceInfo;
+	    source_id: string;
+	    provenance?: Provenance;
+	    reuse_stats?: ReuseStats;
+	    font_audits?: FontAudit[];
+	    quick_mode?: boolean;
+	    uncompiled?: boolean;
+	    translated_html_path?: string;
+	    translated_tex_path?: string;
+	    published_dir?: string;
+	    quality_report_path?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProcessResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.original_pdf_path = source["original_pdf_path"];
+	        this.translated_pdf_path = source["translated_pdf_path"];
+	        this.bilingual_pdf_path = source["bilingual_pdf_path"];
+	        this.source_info = this.convertValues(source["source_info"], SourceInfo);
+	        this.source_id = source["source_id"];
+	        this.provenance = this.convertValues(source["provenance"], Provenance);
+	        this.reuse_stats = this.convertValues(source["reuse_stats"], ReuseStats);
+	        this.font_audits = this.convertValues(source["font_audits"], FontAudit);
+	        this.quick_mode = source["quick_mode"];
+	        this.uncompiled = source["uncompiled"];
+	        this.translated_html_path = source["translated_html_path"];
+	        this.translated_tex_path = source["translated_tex_path"];
+	        this.published_dir = source["published_dir"];
+	        this.quality_report_path = source["quality_report_path"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class TranslationDetail {
+	    file: string;
+	    file_index: number;
+	    total_files: number;
+	    chunk: number;
+	    total_chunks: number;
+	    section?: string;
+	    tokens_used: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TranslationDetail(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.file = source["file"];
+	        this.file_index = source["file_index"];
+	        this.total_files = source["total_files"];
+	        this.chunk = source["chunk"];
+	        this.total_chunks = source["total_chunks"];
+	        this.section = source["section"];
+	        this.tokens_used = source["tokens_used"];
+	    }
+	}
+	
+	export class Status {
+	    phase: string;
+	    progress: number;
+	    message: string;
+	    error?: string;
+	    cached_chunks?: number;
+	    debug_capture_dir?: string;
+	    translation?: TranslationDetail;
+	
+	    static createFrom(source: any = {}) {
+	        return new Status(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.phase = source["phase"];
+	        this.progress = source["progress"];
+	        this.message = source["message"];
+	        this.error = source["error"];
+	        this.cached_chunks = source["cached_chunks"];
+	        this.debug_capture_dir = source["debug_capture_dir"];
+	        this.translation = this.convertValues(source["translation"], TranslationDetail);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+	export class WorkDirCheck {
+	    path: string;
+	    sync_provider?: string;
+	    writable: boolean;
+	    probe_error?: string;
+	    use_scratch: boolean;
+	    message?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkDirCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.sync_provider = source["sync_provider"];
+	        this.writable = source["writable"];
+	        this.probe_error = source["probe_error"];
+	        this.use_scratch = source["use_scratch"];
+	        this.message = source["message"];
+	    }
+	}
+	export class ModelPrice {
+	    input: number;
+	    output: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ModelPrice(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.input = source["input"];
+	        this.output = source["output"];
+	    }
+	}
+	export class PaperWorkspace {
+	    name: string;
+	    path: string;
+	    size_bytes: number;
+	    last_used: string;
+	    protected?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PaperWorkspace(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.size_bytes = source["size_bytes"];
+	        this.last_used = source["last_used"];
+	        this.protected = source["protected"];
+	    }
+	}
+	export class WorkspaceCleanResult {
+	    dry_run: boolean;
+	    removed: PaperWorkspace[];
+	    freed_bytes: number;
+	    kept: number;
+	    errors?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceCleanResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.dry_run = source["dry_run"];
+	        this.removed = this.convertValues(source["removed"], PaperWorkspace);
+	        this.freed_bytes = source["freed_bytes"];
+	        this.kept = source["kept"];
+	        this.errors = source["errors"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class WorkspaceUsage {
+	    root: string;
+	    papers: PaperWorkspace[];
+	    papers_size: number;
+	    other_size: number;
+	    total_size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceUsage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.root = source["root"];
+	        this.papers = this.convertValues(source["papers"], PaperWorkspace);
+	        this.papers_size = source["papers_size"];
+	        this.other_size = source["other_size"];
+	        this.total_size = source["total_size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
 }
 
 export namespace validator {
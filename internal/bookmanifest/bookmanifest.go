@@ -0,0 +1,174 @@
+// Package bookmanifest records, for the book CLI, which source each translated file was
+// produced from: the SHA-256 of the English file, the model and the prompt preset, in
+// translation-manifest.json of the output directory. A re-run of the book translates only
+// the files that are new or whose source changed since, and optionally those translated
+// with another model or preset. The format is documented in docs/DECISIONS_JSON.md.
+package bookmanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// SchemaVersion is the version of the manifest schema
+	SchemaVersion = 1
+	// FileName is the name of the manifest in the output directory
+	FileName = "translation-manifest.json"
+)
+
+// Status is what a re-run does with a file of the book
+type Status string
+
+const (
+	// StatusNew files have no output yet and are translated
+	StatusNew Status = "new"
+	// StatusChanged files changed since their translation and are translated again
+	StatusChanged Status = "changed"
+	// StatusSettingsChanged files were translated with another model or prompt preset and
+	// are translated again (--invalidate-on-model-change)
+	StatusSettingsChanged Status = "settings-changed"
+	// StatusUnchanged files are skipped
+	StatusUnchanged Status = "unchanged"
+	// StatusUntracked files have an output written before the manifest existed; they are
+	// skipped and their current source is recorded
+	StatusUntracked Status = "untracked"
+)
+
+// NeedsTranslation reports whether a file with the status is translated
+func (s Status) NeedsTranslation() bool {
+	return s == StatusNew || s == StatusChanged || s == StatusSettingsChanged
+}
+
+// Entry is the record of one output file
+type Entry struct {
+	SourceSHA256 string `json:"source_sha256"`
+	// Model and PromptPreset are empty for files copied verbatim
+	Model        string `json:"model,omitempty"`
+	PromptPreset string `json:"prompt_preset,omitempty"`
+	UpdatedAt    string `json:"updated_at"` // RFC 3339
+}
+
+// Manifest is the record of the output files of a book, keyed by the path of their source
+// relative to the book directory. It is safe for concurrent use by the file workers.
+type Manifest struct {
+	SchemaVersion int              `json:"schema_version"`
+	Files         map[string]Entry `json:"files"`
+
+	mu   sync.Mutex
+	path string
+
+	// Settings of the current run
+	model                   string
+	promptPreset            string
+	invalidateOnModelChange bool
+}
+
+// Open loads the manifest of an output directory, or starts an empty one when there is
+// none yet. model and promptPreset are those of the current run; with
+// invalidateOnModelChange, files translated with others need translating again.
+func Open(outputDir, model, promptPreset string, invalidateOnModelChange bool) (*Manifest, error) {
+	m := &Manifest{
+		SchemaVersion:           SchemaVersion,
+		Files:                   make(map[string]Entry),
+		path:                    filepath.Join(outputDir, FileName),
+		model:                   model,
+		promptPreset:            promptPreset,
+		invalidateOnModelChange: invalidateOnModelChange,
+	}
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]Entry)
+	}
+	return m, nil
+}
+
+// Path returns where the manifest is saved
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// Hash returns the hex SHA-256 of a source file
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Status tells whether the file at relPath with content needs translating; outputExists
+// is whether its translated file is in the output directory
+func (m *Manifest) Status(relPath string, content []byte, outputExists bool) Status {
+	if !outputExists {
+		return StatusNew
+	}
+	m.mu.Lock()
+	entry, ok := m.Files[filepath.ToSlash(relPath)]
+	m.mu.Unlock()
+	switch {
+	case !ok:
+		return StatusUntracked
+	case entry.SourceSHA256 != Hash(content):
+		return StatusChanged
+	case m.invalidateOnModelChange && entry.Model != "" &&
+		(entry.Model != m.model || entry.PromptPreset != m.promptPreset):
+		return StatusSettingsChanged
+	}
+	return StatusUnchanged
+}
+
+// Record saves that the output of relPath was produced from content: translated with the
+// model and preset of the run, or copied verbatim. The manifest is written at once, so an
+// interrupted run keeps the files finished so far.
+func (m *Manifest) Record(relPath string, content []byte, translated bool) error {
+	entry := Entry{SourceSHA256: Hash(content), UpdatedAt: time.Now().Format(time.RFC3339)}
+	if translated {
+		entry.Model = m.model
+		entry.PromptPreset = m.promptPreset
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files[filepath.ToSlash(relPath)] = entry
+	return m.saveLocked()
+}
+
+// Adopt records the current source of a file whose output predates the manifest, keeping
+// an existing record
+func (m *Manifest) Adopt(relPath string, content []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := filepath.ToSlash(relPath)
+	if _, ok := m.Files[key]; ok {
+		return nil
+	}
+	m.Files[key] = Entry{SourceSHA256: Hash(content), UpdatedAt: time.Now().Format(time.RFC3339)}
+	return m.saveLocked()
+}
+
+// saveLocked writes the manifest; m.mu is held
+func (m *Manifest) saveLocked() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
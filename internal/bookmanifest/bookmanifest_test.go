@@ -0,0 +1,99 @@
+package bookmanifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestStatus(t *testing.T) {
+	dir := t.TempDir()
+	chapter := []byte("\\chapter{Introduction}\nSome text.\n")
+
+	m, err := Open(dir, "gpt-4o", "math", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Status("ch01.tex", chapter, false); got != StatusNew {
+		t.Errorf("file without output = %s, want new", got)
+	}
+	if got := m.Status("ch01.tex", chapter, true); got != StatusUntracked {
+		t.Errorf("output without record = %s, want untracked", got)
+	}
+	if err := m.Record(filepath.Join("chapters", "ch01.tex"), chapter, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Record("macros.tex", []byte("\\newcommand{\\R}{\\mathbb{R}}\n"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run reads what the first one recorded
+	m, err = Open(dir, "gpt-4o", "math", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := m.Files["chapters/ch01.tex"]; entry.SourceSHA256 != Hash(chapter) || entry.Model != "gpt-4o" || entry.PromptPreset != "math" {
+		t.Errorf("recorded entry = %+v", entry)
+	}
+	if got := m.Status(filepath.Join("chapters", "ch01.tex"), chapter, true); got != StatusUnchanged {
+		t.Errorf("unchanged source = %s", got)
+	}
+	if got := m.Status("chapters/ch01.tex", append(chapter, "More text.\n"...), true); got != StatusChanged {
+		t.Errorf("edited source = %s, want changed", got)
+	}
+	if got := m.Status("chapters/ch01.tex", chapter, false); got != StatusNew {
+		t.Errorf("deleted output = %s, want new", got)
+	}
+
+	// Another model only matters when asked for
+	m, _ = Open(dir, "gpt-5", "math", false)
+	if got := m.Status("chapters/ch01.tex", chapter, true); got != StatusUnchanged {
+		t.Errorf("other model without invalidation = %s", got)
+	}
+	m, _ = Open(dir, "gpt-5", "math", true)
+	if got := m.Status("chapters/ch01.tex", chapter, true); got != StatusSettingsChanged || !got.NeedsTranslation() {
+		t.Errorf("other model with invalidation = %s", got)
+	}
+	if got := m.Status("macros.tex", []byte("\\newcommand{\\R}{\\mathbb{R}}\n"), true); got != StatusUnchanged {
+		t.Errorf("copied file with invalidation = %s, want unchanged", got)
+	}
+	m, _ = Open(dir, "gpt-4o", "cs", true)
+	if got := m.Status("chapters/ch01.tex", chapter, true); got != StatusSettingsChanged {
+		t.Errorf("other prompt preset with invalidation = %s", got)
+	}
+}
+
+func TestManifestAdopt(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Open(dir, "gpt-4o", "default", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := []byte("Old text.\n")
+	if err := m.Adopt("ch01.tex", old); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Status("ch01.tex", old, true); got != StatusUnchanged {
+		t.Errorf("adopted file = %s", got)
+	}
+	// Adopting keeps the record of a translated file
+	if err := m.Record("ch02.tex", old, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Adopt("ch02.tex", []byte("New text.\n")); err != nil {
+		t.Fatal(err)
+	}
+	if entry := m.Files["ch02.tex"]; entry.SourceSHA256 != Hash(old) || entry.Model != "gpt-4o" {
+		t.Errorf("adopt replaced a record: %+v", entry)
+	}
+}
+
+func TestOpenInvalidManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, "gpt-4o", "default", false); err == nil {
+		t.Error("Open() accepted an invalid manifest")
+	}
+}
@@ -16,6 +16,8 @@ import (
 
 	"latex-translator/internal/editor"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
+	"latex-translator/internal/translator"
 )
 
 // AgentTool represents a tool that the agent can use
@@ -56,6 +58,7 @@ type LaTeXAgentFixer struct {
 	texDir     string
 	compiler   *LaTeXCompiler
 	outputDir  string
+	usage      *translator.UsageMeter // tokens of the model calls are added to it (nil: not metered)
 	// Editor tools
 	lineEditor      *editor.LineEditor
 	encodingHandler *editor.EncodingHandler
@@ -84,7 +87,7 @@ func NewLaTeXAgentFixer(apiKey, apiURL, model string) *LaTeXAgentFixer {
 		apiKey:          apiKey,
 		apiURL:          apiURL,
 		model:           model,
-		client:          &http.Client{Timeout: 300 * time.Second},
+		client:          netproxy.NewClient(300 * time.Second),
 		maxSteps:        10,
 		lineEditor:      lineEditor,
 		encodingHandler: encodingHandler,
@@ -881,12 +884,15 @@ func (f *LaTeXAgentFixer) callLLM(ctx context.Context, messages []map[string]int
 				} `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage translator.Usage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &apiResp); err != nil {
 		return nil, fmt.Errorf("failed to parse API response: %w", err)
 	}
 
+	f.usage.Add(f.model, apiResp.Usage)
+
 	if len(apiResp.Choices) == 0 {
 		return nil, fmt.Errorf("API returned no choices")
 	}
@@ -0,0 +1,163 @@
+package compiler
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+)
+
+// tlmgrTimeout bounds one tlmgr install run, which downloads from a CTAN mirror
+const tlmgrTimeout = 10 * time.Minute
+
+// missingFilePattern matches the error LaTeX prints for a package or class it cannot
+// find, e.g. "! LaTeX Error: File `algorithm2e.sty' not found."
+var missingFilePattern = regexp.MustCompile("! LaTeX Error: File `([^']+)\\.(?:sty|cls)' not found")
+
+// SetAutoInstallPackages sets whether packages missing from the TeX distribution are
+// installed during compilation: MiKTeX installs them on the fly when the engine loads
+// them, with TeX Live they are installed with tlmgr before and between the passes.
+// Installed packages are listed in the compile log and in CompileResult.
+func (c *LaTeXCompiler) SetAutoInstallPackages(enabled bool) {
+	c.autoInstall = enabled
+}
+
+// miktexAutoInstall reports whether the engine runs with MiKTeX's on-the-fly installer
+func (c *LaTeXCompiler) miktexAutoInstall() bool {
+	return c.autoInstall && DetectTeXDistribution() == DistributionMiKTeX
+}
+
+// installerEnv returns the environment enabling MiKTeX's on-the-fly installer without
+// asking, which would block a headless compilation
+func (c *LaTeXCompiler) installerEnv() []string {
+	if !c.miktexAutoInstall() {
+		return nil
+	}
+	return []string{"MIKTEX_AUTOINSTALL=1"}
+}
+
+// installerArgs returns the engine arguments enabling MiKTeX's on-the-fly installer
+func (c *LaTeXCompiler) installerArgs() []string {
+	if !c.miktexAutoInstall() {
+		return nil
+	}
+	return []string{"--enable-installer"}
+}
+
+// packageInstaller installs the packages one compilation is missing and records them.
+// A nil installer, for compilers without auto-install, installs nothing.
+type packageInstaller struct {
+	distribution string
+	installed    []string        // LaTeX packages installed, in installation order
+	attempted    map[string]bool // packages already tried, each is installed at most once
+	ctx          context.Context // context of the compilation, cancelling tlmgr
+}
+
+// newPackageInstaller returns the installer of a compilation, or nil when auto-install is
+// off or no supported distribution is found
+func (c *LaTeXCompiler) newPackageInstaller() *packageInstaller {
+	if !c.autoInstall {
+		return nil
+	}
+	distribution := DetectTeXDistribution()
+	if distribution == "" {
+		logger.Warn("automatic package installation needs MiKTeX or TeX Live (tlmgr), disabled")
+		return nil
+	}
+	return &packageInstaller{distribution: distribution, attempted: make(map[string]bool), ctx: c.context()}
+}
+
+// prepare installs the packages required by the sources under texDir that kpsewhich
+// cannot find, before the first pass
+func (p *packageInstaller) prepare(texDir string, allLogs []string) []string {
+	if p == nil {
+		return allLogs
+	}
+	report := FindMissingPackages(texDir)
+	if report == nil {
+		return allLogs
+	}
+	return p.install(report.Packages, allLogs)
+}
+
+// installFromLog installs the packages and classes a pass reported not found. Returns
+// whether any was installed, in which case the pass is worth repeating.
+func (p *packageInstaller) installFromLog(log string, allLogs []string) (bool, []string) {
+	// MiKTeX installs on the fly; a file still reported missing could not be installed
+	if p == nil || p.distribution != DistributionTeXLive {
+		return false, allLogs
+	}
+	var packages []string
+	for _, m := range missingFilePattern.FindAllStringSubmatch(log, -1) {
+		packages = append(packages, m[1])
+	}
+	before := len(p.installed)
+	allLogs = p.install(packages, allLogs)
+	return len(p.installed) > before, allLogs
+}
+
+// install installs the packages not tried yet. With MiKTeX they are only recorded, the
+// engine installs them when it loads them; finish keeps the ones actually installed.
+func (p *packageInstaller) install(packages []string, allLogs []string) []string {
+	var pending []string
+	for _, name := range packages {
+		name = filepath.Base(name)
+		if !p.attempted[name] {
+			p.attempted[name] = true
+			pending = append(pending, name)
+		}
+	}
+	if len(pending) == 0 {
+		return allLogs
+	}
+
+	if p.distribution == DistributionMiKTeX {
+		logger.Info("packages left to the MiKTeX on-the-fly installer", logger.String("packages", strings.Join(pending, ",")))
+		p.installed = append(p.installed, pending...)
+		return allLogs
+	}
+
+	command := InstallCommand(p.distribution, pending)
+	logger.Info("installing missing packages", logger.String("command", command))
+	ctx, cancel := processContext(p.ctx, tlmgrTimeout)
+	defer cancel()
+	cmd := commandContext(ctx, "tlmgr", append([]string{"install"}, distPackageNames(pending)...)...)
+	hideWindow(cmd)
+	output, err := cmd.CombinedOutput()
+	allLogs = append(allLogs, "=== "+command+" ===", string(output))
+	if err != nil {
+		// Usually missing write access to the TeX Live tree; the pass reports the package missing
+		logger.Warn("tlmgr install failed", logger.String("command", command), logger.Err(err))
+		return allLogs
+	}
+	p.installed = append(p.installed, pending...)
+	return allLogs
+}
+
+// finish returns the packages installed during the compilation and appends them to the
+// logs. The packages left to MiKTeX count only when kpsewhich finds them afterwards.
+func (p *packageInstaller) finish(allLogs []string) ([]string, []string) {
+	if p == nil || len(p.installed) == 0 {
+		return nil, allLogs
+	}
+	installed := p.installed
+	if p.distribution == DistributionMiKTeX {
+		found, ok := kpsewhichFound(installed)
+		if ok {
+			installed = nil
+			for _, name := range p.installed {
+				if found[name] {
+					installed = append(installed, name)
+				}
+			}
+		}
+	}
+	if len(installed) == 0 {
+		return nil, allLogs
+	}
+	logger.Info("packages installed during compilation", logger.String("packages", strings.Join(installed, ",")))
+	return installed, append(allLogs, "=== Installed packages ===", strings.Join(installed, " "))
+}
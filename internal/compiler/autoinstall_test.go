@@ -0,0 +1,51 @@
+package compiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutoInstallDisabledByDefault(t *testing.T) {
+	c := NewLaTeXCompiler("", "", 0)
+	if c.newPackageInstaller() != nil || c.installerArgs() != nil || c.installerEnv() != nil {
+		t.Fatal("package installation enabled by default")
+	}
+
+	// Without auto-install the compilation goes on as before
+	var installer *packageInstaller
+	logs := installer.prepare(t.TempDir(), []string{"log"})
+	installed, logs := installer.installFromLog("! LaTeX Error: File `foo.sty' not found.", logs)
+	packages, logs := installer.finish(logs)
+	if installed || packages != nil || !reflect.DeepEqual(logs, []string{"log"}) {
+		t.Errorf("nil installer changed the compilation: installed=%v packages=%v logs=%v", installed, packages, logs)
+	}
+}
+
+func TestMissingFilePattern(t *testing.T) {
+	log := "(./main.tex\n" +
+		"! LaTeX Error: File `algorithm2e.sty' not found.\n" +
+		"Type X to quit or <RETURN> to proceed,\n" +
+		"! LaTeX Error: File `IEEEtran.cls' not found.\n" +
+		"! LaTeX Error: File `figure.png' not found.\n"
+	var got []string
+	for _, m := range missingFilePattern.FindAllStringSubmatch(log, -1) {
+		got = append(got, m[1])
+	}
+	if want := []string{"algorithm2e", "IEEEtran"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing files = %v, want %v", got, want)
+	}
+}
+
+func TestPackageInstallerMiKTeX(t *testing.T) {
+	p := &packageInstaller{distribution: DistributionMiKTeX, attempted: make(map[string]bool)}
+	p.install([]string{"algorithm2e", "styles/xcolor"}, nil)
+	p.install([]string{"xcolor", "booktabs"}, nil)
+	if want := []string{"algorithm2e", "xcolor", "booktabs"}; !reflect.DeepEqual(p.installed, want) {
+		t.Errorf("installed = %v, want %v", p.installed, want)
+	}
+
+	// MiKTeX installs on the fly; a pass reporting a file missing is not repeated
+	if installed, _ := p.installFromLog("! LaTeX Error: File `geometry.sty' not found.", nil); installed {
+		t.Error("MiKTeX pass repeated for a missing file")
+	}
+}
@@ -0,0 +1,222 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+var (
+	// bibliographyCommandPattern matches BibTeX's \bibliography{db1,db2}
+	bibliographyCommandPattern = regexp.MustCompile(`\\bibliography\s*\{([^}]*)\}`)
+	// addbibresourcePattern matches biblatex's \addbibresource[options]{file.bib}
+	addbibresourcePattern = regexp.MustCompile(`\\addbibresource\s*(?:\[[^\]]*\])?\s*\{([^}]+)\}`)
+)
+
+// bibliographyCommand is an uncommented \bibliography or \addbibresource command
+type bibliographyCommand struct {
+	start, end int      // position of the command in the document
+	databases  []string // database files named by the command, with their extension
+	biblatex   bool     // \addbibresource, whose .bbl is read by biblatex itself
+}
+
+// findBibliographyCommands returns the \bibliography and \addbibresource commands of a
+// document in order, ignoring comments. \bibliography names its databases without the
+// .bib extension and may name several; \addbibresource names one file with extension.
+func findBibliographyCommands(content string) []bibliographyCommand {
+	text := uncommentedText(content)
+	var commands []bibliographyCommand
+	for _, m := range bibliographyCommandPattern.FindAllStringSubmatchIndex(text, -1) {
+		cmd := bibliographyCommand{start: m[0], end: m[1]}
+		for _, name := range strings.Split(text[m[2]:m[3]], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				if !strings.HasSuffix(name, ".bib") {
+					name += ".bib"
+				}
+				cmd.databases = append(cmd.databases, name)
+			}
+		}
+		commands = append(commands, cmd)
+	}
+	for _, m := range addbibresourcePattern.FindAllStringSubmatchIndex(text, -1) {
+		commands = append(commands, bibliographyCommand{
+			start:     m[0],
+			end:       m[1],
+			databases: []string{strings.TrimSpace(text[m[2]:m[3]])},
+			biblatex:  true,
+		})
+	}
+	// \bibliography and \addbibresource rarely mix; keep the document order when they do
+	for i := 1; i < len(commands); i++ {
+		for j := i; j > 0 && commands[j].start < commands[j-1].start; j-- {
+			commands[j], commands[j-1] = commands[j-1], commands[j]
+		}
+	}
+	return commands
+}
+
+// missingBibDatabases returns the databases named by the commands that are not in texDir
+func missingBibDatabases(commands []bibliographyCommand, texDir string) []string {
+	var missing []string
+	for _, cmd := range commands {
+		for _, db := range cmd.databases {
+			if _, err := os.Stat(filepath.Join(texDir, filepath.FromSlash(db))); os.IsNotExist(err) {
+				missing = append(missing, db)
+			}
+		}
+	}
+	return missing
+}
+
+// findShippedBbl returns the content of a .bbl file of texDir generated in the given
+// format: BibTeX's thebibliography environment, or biblatex's own format. The .bbl of
+// the job comes first, then the one of the original document of a translation, the ones
+// named like a database, and any other .bbl.
+func findShippedBbl(texDir, texBaseName string, commands []bibliographyCommand, biblatex bool) (string, string, bool) {
+	names := []string{texBaseName + ".bbl", strings.TrimPrefix(texBaseName, "translated_") + ".bbl"}
+	for _, cmd := range commands {
+		for _, db := range cmd.databases {
+			names = append(names, strings.TrimSuffix(filepath.Base(db), ".bib")+".bbl")
+		}
+	}
+	if entries, err := os.ReadDir(texDir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".bbl") {
+				names = append(names, e.Name())
+			}
+		}
+	}
+
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(texDir, name))
+		if err != nil || len(content) == 0 {
+			continue
+		}
+		if isBiblatexBbl(string(content)) == biblatex && (biblatex || strings.Contains(string(content), `\begin{thebibliography}`)) {
+			return string(content), name, true
+		}
+	}
+	return "", "", false
+}
+
+// isBiblatexBbl reports whether a .bbl file was written by biber or biblatex's BibTeX
+// backend rather than by a BibTeX style
+func isBiblatexBbl(bbl string) bool {
+	return strings.Contains(bbl, "biblatex auxiliary file") || strings.Contains(bbl, `\refsection{`) || strings.Contains(bbl, `\datalist[`)
+}
+
+// inlineBibliography replaces the first uncommented \bibliography{...} command with the
+// content of a .bbl file, so the bibliography is typeset without running BibTeX; LaTeX
+// stopping early on a translation error cannot lose it either. Documents that already
+// hold a thebibliography environment or whose \bibliography is in the preamble are left
+// alone. Returns the modified content and whether the replacement was made.
+func inlineBibliography(texContent string, bblContent string) (string, bool) {
+	// Inlining again would duplicate the reference list
+	if strings.Contains(texContent, `\begin{thebibliography}`) {
+		logger.Debug("skipping inlineBibliography - file already has thebibliography environment")
+		return texContent, false
+	}
+
+	var cmd *bibliographyCommand
+	for _, c := range findBibliographyCommands(texContent) {
+		if !c.biblatex {
+			cmd = &c
+			break
+		}
+	}
+	if cmd == nil {
+		return texContent, false
+	}
+
+	// A thebibliography environment in the preamble would not compile
+	if beginDocIdx := strings.Index(texContent, `\begin{document}`); beginDocIdx != -1 && cmd.start < beginDocIdx {
+		logger.Debug("skipping inlineBibliography - \\bibliography is in preamble",
+			logger.Int("bibIdx", cmd.start),
+			logger.Int("beginDocIdx", beginDocIdx))
+		return texContent, false
+	}
+
+	// The breakurl package's \burl of the .bbl does not work with xelatex
+	fixedBblContent := fixBblForXelatex(bblContent)
+	result := texContent[:cmd.start] + fixedBblContent + texContent[cmd.end:]
+
+	logger.Debug("inlined bibliography content",
+		logger.Int("bblLength", len(fixedBblContent)))
+	return result, true
+}
+
+// fixMissingBibFile handles sources that ship the generated .bbl but not the .bib
+// databases named by \bibliography, as many arXiv sources do: BibTeX cannot run, so the
+// .bbl is inlined in place of the \bibliography command. Returns the modified content and
+// whether the .bbl was inlined.
+func fixMissingBibFile(texContent string, texDir string, texBaseName string) (string, bool) {
+	var commands []bibliographyCommand
+	for _, cmd := range findBibliographyCommands(texContent) {
+		if !cmd.biblatex {
+			commands = append(commands, cmd)
+		}
+	}
+	missing := missingBibDatabases(commands, texDir)
+	if len(missing) == 0 {
+		return texContent, false
+	}
+
+	bbl, bblName, ok := findShippedBbl(texDir, texBaseName, commands, false)
+	if !ok {
+		logger.Warn("no valid .bbl file found to replace missing .bib file",
+			logger.String("texDir", texDir),
+			logger.String("missing", strings.Join(missing, ",")))
+		return texContent, false
+	}
+	logger.Info("found .bbl file to inline",
+		logger.String("bbl", bblName),
+		logger.String("missing", strings.Join(missing, ",")))
+	return inlineBibliography(texContent, bbl)
+}
+
+// usePrebuiltBiblatexBbl handles biblatex documents whose \addbibresource databases are
+// missing but whose biblatex .bbl ships with the source. biblatex reads the .bbl of the
+// job itself, so it is installed as <job>.bbl next to the source and in the output
+// directory, and biber must not run, since it would fail without the databases. Returns
+// whether the .bbl was installed.
+func usePrebuiltBiblatexBbl(texContent string, texDir string, outputDir string, texBaseName string) bool {
+	var commands []bibliographyCommand
+	for _, cmd := range findBibliographyCommands(texContent) {
+		if cmd.biblatex {
+			commands = append(commands, cmd)
+		}
+	}
+	missing := missingBibDatabases(commands, texDir)
+	if len(missing) == 0 {
+		return false
+	}
+
+	bbl, bblName, ok := findShippedBbl(texDir, texBaseName, commands, true)
+	if !ok {
+		logger.Warn("no biblatex .bbl file found to replace missing bibliography resources",
+			logger.String("texDir", texDir),
+			logger.String("missing", strings.Join(missing, ",")))
+		return false
+	}
+
+	for _, dir := range []string{texDir, outputDir} {
+		if dir == "" {
+			continue
+		}
+		target := filepath.Join(dir, texBaseName+".bbl")
+		if filepath.Join(texDir, bblName) == target {
+			continue
+		}
+		if err := os.WriteFile(target, []byte(bbl), 0644); err != nil {
+			logger.Warn("failed to install biblatex .bbl file", logger.Err(err), logger.String("path", target))
+			return false
+		}
+	}
+	logger.Info("using shipped biblatex .bbl file for missing bibliography resources",
+		logger.String("bbl", bblName),
+		logger.String("missing", strings.Join(missing, ",")))
+	return true
+}
@@ -0,0 +1,139 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBbl = "\\begin{thebibliography}{1}\n\\bibitem{a} A. Author.\n\\end{thebibliography}\n"
+
+func TestFindBibliographyCommands(t *testing.T) {
+	content := "% \\bibliography{old}\n\\bibliography{refs, more ,extra.bib}\n\\addbibresource[label=x]{biblatex.bib}\n"
+	commands := findBibliographyCommands(content)
+	if len(commands) != 2 {
+		t.Fatalf("found %d commands, want 2: %+v", len(commands), commands)
+	}
+	if want := []string{"refs.bib", "more.bib", "extra.bib"}; !reflect.DeepEqual(commands[0].databases, want) || commands[0].biblatex {
+		t.Errorf("\\bibliography = %+v, want databases %v", commands[0], want)
+	}
+	if got := content[commands[0].start:commands[0].end]; got != `\bibliography{refs, more ,extra.bib}` {
+		t.Errorf("\\bibliography span = %q", got)
+	}
+	if !commands[1].biblatex || !reflect.DeepEqual(commands[1].databases, []string{"biblatex.bib"}) {
+		t.Errorf("\\addbibresource = %+v", commands[1])
+	}
+}
+
+func TestInlineBibliography(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string // empty when the content must not change
+	}{
+		{
+			name:    "same line as end of document",
+			content: "\\begin{document}\nText.\n\\bibliography{refs}\\end{document}\n",
+			want:    "\\begin{document}\nText.\n" + testBbl + "\\end{document}\n",
+		},
+		{
+			name:    "commented out first",
+			content: "\\begin{document}\n%\\bibliography{old}\n\\bibliographystyle{plain}\n\\bibliography{refs,more}\n\\end{document}\n",
+			want:    "\\begin{document}\n%\\bibliography{old}\n\\bibliographystyle{plain}\n" + testBbl + "\n\\end{document}\n",
+		},
+		{
+			name:    "only commented out",
+			content: "\\begin{document}\n% \\bibliography{refs}\n\\end{document}\n",
+		},
+		{
+			name:    "in preamble",
+			content: "\\bibliography{refs}\n\\begin{document}\n\\end{document}\n",
+		},
+		{
+			name:    "already inlined",
+			content: "\\begin{document}\n" + testBbl + "\\bibliography{refs}\n\\end{document}\n",
+		},
+	}
+	for _, tt := range tests {
+		got, ok := inlineBibliography(tt.content, testBbl)
+		if tt.want == "" {
+			if ok || got != tt.content {
+				t.Errorf("%s: content changed to %q", tt.name, got)
+			}
+			continue
+		}
+		if !ok || got != tt.want {
+			t.Errorf("%s: got %q (%v), want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+// writeFiles writes files with the given contents to a new directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFixMissingBibFile(t *testing.T) {
+	tex := "\\begin{document}\n\\bibliography{refs,extra}\n\\end{document}\n"
+
+	// The .bbl of the original document is used for its translation
+	dir := writeFiles(t, map[string]string{"main.bbl": testBbl})
+	got, ok := fixMissingBibFile(tex, dir, "translated_main")
+	if !ok || !strings.Contains(got, `\bibitem{a}`) || strings.Contains(got, `\bibliography{`) {
+		t.Errorf("fixMissingBibFile() = %q, %v", got, ok)
+	}
+
+	// BibTeX runs when the databases are there
+	dir = writeFiles(t, map[string]string{"main.bbl": testBbl, "refs.bib": "", "extra.bib": ""})
+	if _, ok := fixMissingBibFile(tex, dir, "main"); ok {
+		t.Error("inlined the .bbl although the .bib files exist")
+	}
+
+	// One missing database is enough to make BibTeX fail
+	dir = writeFiles(t, map[string]string{"refs.bbl": testBbl, "refs.bib": ""})
+	if _, ok := fixMissingBibFile(tex, dir, "paper"); !ok {
+		t.Error("did not inline the .bbl named like a database")
+	}
+
+	// A biblatex .bbl cannot be inlined
+	dir = writeFiles(t, map[string]string{"main.bbl": "% $ biblatex auxiliary file $\n\\refsection{0}\n\\endrefsection\n"})
+	if _, ok := fixMissingBibFile(tex, dir, "main"); ok {
+		t.Error("inlined a biblatex .bbl")
+	}
+}
+
+func TestUsePrebuiltBiblatexBbl(t *testing.T) {
+	tex := "\\usepackage{biblatex}\n\\addbibresource{refs.bib}\n\\begin{document}\n\\printbibliography\n\\end{document}\n"
+	bbl := "% $ biblatex auxiliary file $\n\\refsection{0}\n\\datalist[entry]{nty/global//global/global}\n\\endrefsection\n"
+	dir := writeFiles(t, map[string]string{"main.bbl": bbl})
+	outDir := t.TempDir()
+
+	if !usePrebuiltBiblatexBbl(tex, dir, outDir, "translated_main") {
+		t.Fatal("usePrebuiltBiblatexBbl() = false")
+	}
+	for _, d := range []string{dir, outDir} {
+		if data, err := os.ReadFile(filepath.Join(d, "translated_main.bbl")); err != nil || string(data) != bbl {
+			t.Errorf(".bbl not installed in %s: %v", d, err)
+		}
+	}
+
+	// \bibliography documents and BibTeX .bbl files are left to fixMissingBibFile
+	if usePrebuiltBiblatexBbl("\\bibliography{refs}\n", dir, outDir, "main") {
+		t.Error("used a biblatex .bbl for \\bibliography")
+	}
+	if usePrebuiltBiblatexBbl(tex, writeFiles(t, map[string]string{"main.bbl": testBbl}), outDir, "main") {
+		t.Error("used a BibTeX .bbl for \\addbibresource")
+	}
+	if usePrebuiltBiblatexBbl(tex, writeFiles(t, map[string]string{"main.bbl": bbl, "refs.bib": ""}), outDir, "main") {
+		t.Error("skipped biber although the .bib file exists")
+	}
+}
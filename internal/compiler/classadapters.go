@@ -0,0 +1,139 @@
+package compiler
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// ClassAdapter is the preamble adaptation of the document classes the generic Chinese setup
+// breaks. The CJK package is loaded by the post-processing (EnsureCJKSupport);
+// the packages and class options are adapted before each compile of the translated document.
+// With pdfLaTeX every class gets CJKutf8 from the cjkutf8 preprocessor.
+type ClassAdapter struct {
+	Name    string   // adapter name for logs
+	Classes []string // document classes the adapter applies to
+
+	// CJKPackage typesets Chinese with XeLaTeX: "xeCJK" for classes ctex breaks or changes
+	// the layout of, empty for ctex. LuaLaTeX always uses luatexja-fontspec.
+	CJKPackage string
+	CJKOptions string // options of CJKPackage, e.g. "CJKmath=true"
+
+	// DisablePackages are commented out: the class loads them itself (a second load with
+	// other options is an option clash) or they conflict with its fonts
+	DisablePackages []string
+	// ClassOptions are added to \documentclass
+	ClassOptions []string
+}
+
+// classAdapters are the class adapters; a new class only needs an entry here
+var classAdapters = []ClassAdapter{
+	{
+		// ctex breaks revtex's front matter; revtex loads natbib itself
+		Name:            "revtex",
+		Classes:         []string{"revtex4", "revtex4-1", "revtex4-2"},
+		CJKPackage:      "xeCJK",
+		DisablePackages: []string{"natbib"},
+	},
+	{
+		// ctex breaks acmart's front matter. acmart loads newtxmath, which defines the
+		// amssymb symbols (loading amssymb again fails with "\Bbbk already defined") and
+		// has no CJK glyphs, so Chinese in math is typeset with the CJK font. The translation
+		// is not the ACM version of record: nonacm drops the ACM reference format and
+		// copyright block.
+		Name:            "acmart",
+		Classes:         []string{"acmart"},
+		CJKPackage:      "xeCJK",
+		CJKOptions:      "CJKmath=true",
+		DisablePackages: []string{"amssymb", "mathptmx", "times"},
+		ClassOptions:    []string{"nonacm"},
+	},
+	{
+		// ctex stretches the lines by 1.3, which overflows IEEEtran's fixed two-column
+		// layout and inflates the page count; xeCJK only adds the fonts
+		Name:       "ieeetran",
+		Classes:    []string{"IEEEtran"},
+		CJKPackage: "xeCJK",
+	},
+}
+
+// documentClassLinePattern matches \documentclass with its options and class
+var documentClassLinePattern = regexp.MustCompile(`\\documentclass\s*(?:\[([^\]]*)\])?\s*\{([^}]+)\}`)
+
+// DocumentClass returns the class of the first uncommented \documentclass, or ""
+func DocumentClass(content string) string {
+	if m := documentClassLinePattern.FindStringSubmatch(uncommentedText(content)); m != nil {
+		return strings.TrimSpace(m[2])
+	}
+	return ""
+}
+
+// ClassAdapterFor returns the adapter of a document class, or nil when the class needs none
+func ClassAdapterFor(class string) *ClassAdapter {
+	for i := range classAdapters {
+		for _, c := range classAdapters[i].Classes {
+			if c == class {
+				return &classAdapters[i]
+			}
+		}
+	}
+	return nil
+}
+
+// adaptClassPreamble applies the adapter of the document's class: its disabled packages are
+// commented out and its class options added
+func adaptClassPreamble(content string) (string, bool) {
+	class := DocumentClass(content)
+	adapter := ClassAdapterFor(class)
+	if adapter == nil {
+		return content, false
+	}
+	changed := false
+	if len(adapter.ClassOptions) > 0 {
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			m := documentClassLinePattern.FindStringSubmatchIndex(codeOf(line))
+			if m == nil {
+				continue
+			}
+			if fixed, added := addClassOptions(line, m, adapter.ClassOptions); added {
+				lines[i] = fixed
+				changed = true
+				logger.Info("added class options", logger.String("adapter", adapter.Name), logger.String("line", fixed))
+			}
+			break
+		}
+		content = strings.Join(lines, "\n")
+	}
+	for _, name := range adapter.DisablePackages {
+		var disabled bool
+		content, disabled = disablePackage(content, name, "disabled for "+class, nil)
+		changed = changed || disabled
+	}
+	return content, changed
+}
+
+// addClassOptions adds the options missing from the \documentclass match m of line
+func addClassOptions(line string, m []int, options []string) (string, bool) {
+	var existing []string
+	if m[2] >= 0 {
+		for _, opt := range strings.Split(line[m[2]:m[3]], ",") {
+			if opt = strings.TrimSpace(opt); opt != "" {
+				existing = append(existing, opt)
+			}
+		}
+	}
+	var missing []string
+	for _, opt := range options {
+		if !slices.Contains(existing, opt) {
+			missing = append(missing, opt)
+		}
+	}
+	if len(missing) == 0 {
+		return line, false
+	}
+	class := line[m[4]:m[5]]
+	return line[:m[0]] + "\\documentclass[" + strings.Join(append(existing, missing...), ",") + "]{" + class + "}" + line[m[1]:], true
+}
@@ -0,0 +1,146 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdaptClassPreamble(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    []string
+		unwant  []string
+		changed bool
+	}{
+		{
+			name: "acmart",
+			doc:  "\\documentclass[sigconf, review]{acmart}\n\\usepackage{amssymb}\n\\usepackage{amsmath}\n\\begin{document}\n\\usepackage{times}\n\\end{document}\n",
+			want: []string{
+				"\\documentclass[sigconf,review,nonacm]{acmart}\n",
+				"% \\usepackage{amssymb} % disabled for acmart\n\\usepackage{amsmath}\n",
+				"\\begin{document}\n\\usepackage{times}\n",
+			},
+			changed: true,
+		},
+		{
+			name:    "acmart with nonacm",
+			doc:     "\\documentclass[nonacm]{acmart}\n\\usepackage{amsmath}\n",
+			want:    []string{"\\documentclass[nonacm]{acmart}\n"},
+			changed: false,
+		},
+		{
+			name:    "revtex",
+			doc:     "\\documentclass[aps,prl]{revtex4-2}\n\\usepackage[numbers]{natbib}\n% \\usepackage{natbib}\n",
+			want:    []string{"\\documentclass[aps,prl]{revtex4-2}\n% \\usepackage[numbers]{natbib} % disabled for revtex4-2\n% \\usepackage{natbib}\n"},
+			changed: true,
+		},
+		{
+			name:    "other class",
+			doc:     "\\documentclass{article}\n\\usepackage{natbib}\n\\usepackage{amssymb}\n",
+			unwant:  []string{"disabled"},
+			changed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := adaptClassPreamble(tt.doc)
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("missing %q in\n%s", s, got)
+				}
+			}
+			for _, s := range tt.unwant {
+				if strings.Contains(got, s) {
+					t.Errorf("unexpected %q in\n%s", s, got)
+				}
+			}
+			if again, changed := adaptClassPreamble(got); changed || again != got {
+				t.Errorf("second pass changed the document:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestClassAdapterFor(t *testing.T) {
+	for _, class := range []string{"revtex4-1", "revtex4-2", "acmart", "IEEEtran"} {
+		if adapter := ClassAdapterFor(class); adapter == nil || adapter.CJKPackage != "xeCJK" {
+			t.Errorf("ClassAdapterFor(%q) = %+v", class, adapter)
+		}
+	}
+	if adapter := ClassAdapterFor("article"); adapter != nil {
+		t.Errorf("ClassAdapterFor(article) = %+v", adapter)
+	}
+	if got := DocumentClass("% \\documentclass{book}\n\\documentclass [11pt] { IEEEtran }\n"); got != "IEEEtran" {
+		t.Errorf("DocumentClass() = %q", got)
+	}
+}
+
+func TestPreprocessXeCJKSetupForPDFLaTeX(t *testing.T) {
+	doc := "\\documentclass{revtex4-2}\n" +
+		"\\usepackage{xeCJK}\n" +
+		"\\IfFontExistsTF{SimSun}{\\setCJKmainfont{SimSun}}{%\n" +
+		"\\setCJKmainfont{FandolSong-Regular.otf}}\n" +
+		"\\usepackage{natbib}\n" +
+		"\\begin{document}\n中文\n\\end{document}\n"
+	got, applied := PreprocessForEngine(doc, CompilerPDFLaTeX)
+	if want := []string{"class", "cjkutf8"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if strings.Contains(line, "xeCJK") || strings.Contains(line, "Font") || strings.Contains(line, "natbib") {
+			if !strings.HasPrefix(line, "% ") {
+				t.Errorf("line left active for pdfLaTeX: %q", line)
+			}
+		}
+	}
+}
+
+// classFixtures are minimal translated documents of the classes with adapters
+var classFixtures = map[string]string{
+	"revtex4-2": "\\documentclass[aps,prl]{revtex4-2}\n\\usepackage{natbib}\n\\begin{document}\n\\title{标题}\n\\author{作者}\n\\begin{abstract}\n摘要内容。\n\\end{abstract}\n\\maketitle\n\\section{引言}\n中文正文。\n\\end{document}\n",
+	"acmart":    "\\documentclass[sigconf]{acmart}\n\\usepackage{amssymb}\n\\begin{document}\n\\title{标题}\n\\author{作者}\n\\maketitle\n\\section{引言}\n中文正文 $\\mathbb{R}$。\n\\end{document}\n",
+	"IEEEtran":  "\\documentclass[conference]{IEEEtran}\n\\begin{document}\n\\title{标题}\n\\author{作者}\n\\maketitle\n\\section{引言}\n中文正文。\n\\end{document}\n",
+}
+
+// TestClassAdaptersCompile compiles the translated document of each adapted class with
+// XeLaTeX. Skipped when XeLaTeX or the class is not installed.
+func TestClassAdaptersCompile(t *testing.T) {
+	if _, err := exec.LookPath("xelatex"); err != nil {
+		t.Skip("xelatex not installed")
+	}
+	for class, doc := range classFixtures {
+		t.Run(class, func(t *testing.T) {
+			if out, err := exec.Command("kpsewhich", class+".cls").Output(); err != nil || strings.TrimSpace(string(out)) == "" {
+				t.Skipf("%s.cls not installed", class)
+			}
+			content := EnsureCJKSupport(doc, CompilerXeLaTeX)
+			content, _ = PreprocessForEngine(content, CompilerXeLaTeX)
+
+			dir := t.TempDir()
+			texPath := filepath.Join(dir, "main.tex")
+			if err := os.WriteFile(texPath, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+			c := NewLaTeXCompiler(CompilerXeLaTeX, dir, 5*time.Minute)
+			result, err := c.CompileWithXeLaTeXContext(ctx, texPath, dir)
+			if err != nil {
+				t.Fatalf("compile failed: %v", err)
+			}
+			if !result.Success {
+				t.Fatalf("compile failed: %s\n%s", result.ErrorMsg, content)
+			}
+		})
+	}
+}
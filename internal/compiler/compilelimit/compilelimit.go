@@ -0,0 +1,107 @@
+// Package compilelimit limits how many LaTeX processes run at the same time.
+//
+// The limit is process-wide and shared by every code path that runs LaTeX: the compiler
+// package (original and translated documents, fix-loop probes, per-chapter and bilingual
+// compiles) and the PDF generators. It lives in its own package so that packages which
+// cannot depend on the compiler package can still take part.
+package compilelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"latex-translator/internal/logger"
+)
+
+// DefaultMax is the default number of LaTeX processes that may run at the same time.
+// xelatex with CJK fonts easily takes several hundred MB, so a low default keeps
+// per-chapter compiles, bilingual generation and the fix loop from thrashing machines
+// with little memory.
+const DefaultMax = 2
+
+// slots counts the running compile processes. Waiting compiles select on changed, which
+// is closed and replaced whenever a slot is released or the limit changes, together with
+// their context.
+var slots = struct {
+	mu      sync.Mutex
+	changed chan struct{}
+	limit   int
+	active  int
+	waiting int
+}{limit: DefaultMax, changed: make(chan struct{})}
+
+// notifyLocked wakes the waiting compiles to check for a free slot; slots.mu must be held
+func notifyLocked() {
+	close(slots.changed)
+	slots.changed = make(chan struct{})
+}
+
+// SetMax sets how many compile processes may run at the same time. Values below 1 restore
+// the default. Lowering the limit does not stop running processes; new ones wait until
+// the number of running processes is below the new limit.
+func SetMax(n int) {
+	if n < 1 {
+		n = DefaultMax
+	}
+	slots.mu.Lock()
+	slots.limit = n
+	// Raising the limit may let waiting compiles start
+	notifyLocked()
+	slots.mu.Unlock()
+	logger.Debug("max concurrent compiles set", logger.Int("limit", n))
+}
+
+// Max returns how many compile processes may run at the same time
+func Max() int {
+	slots.mu.Lock()
+	defer slots.mu.Unlock()
+	return slots.limit
+}
+
+// Acquire blocks until a compile process may start and returns the function releasing the
+// slot, to be called once the process has exited. what describes the process in the log
+// (e.g. "xelatex main.tex"). When ctx is done first, Acquire returns ctx.Err() without
+// taking a slot.
+func Acquire(ctx context.Context, what string) (release func(), err error) {
+	slots.mu.Lock()
+	if slots.active >= slots.limit {
+		slots.waiting++
+		logger.Info("compile waiting for a free slot",
+			logger.String("process", what),
+			logger.Int("running", slots.active),
+			logger.Int("waiting", slots.waiting),
+			logger.Int("limit", slots.limit))
+		start := time.Now()
+		for slots.active >= slots.limit {
+			changed := slots.changed
+			slots.mu.Unlock()
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				slots.mu.Lock()
+				slots.waiting--
+				slots.mu.Unlock()
+				logger.Info("compile cancelled while waiting for a slot", logger.String("process", what))
+				return nil, ctx.Err()
+			}
+			slots.mu.Lock()
+		}
+		slots.waiting--
+		logger.Info("compile slot acquired",
+			logger.String("process", what),
+			logger.String("waited", time.Since(start).Round(time.Millisecond).String()))
+	}
+	slots.active++
+	slots.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			slots.mu.Lock()
+			slots.active--
+			notifyLocked()
+			slots.mu.Unlock()
+		})
+	}, nil
+}
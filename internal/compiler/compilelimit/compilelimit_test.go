@@ -0,0 +1,130 @@
+package compilelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runConcurrently starts n simulated compiles at once and returns the highest number that
+// held a slot at the same time
+func runConcurrently(n int) int32 {
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := Acquire(context.Background(), "xelatex test.tex")
+			if err != nil {
+				panic(err)
+			}
+			defer release()
+			now := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	return peak
+}
+
+func TestLimitSerializesCompiles(t *testing.T) {
+	defer SetMax(DefaultMax)
+
+	SetMax(1)
+	if peak := runConcurrently(5); peak != 1 {
+		t.Errorf("limit 1: %d compiles ran at the same time", peak)
+	}
+
+	SetMax(3)
+	if peak := runConcurrently(8); peak > 3 || peak < 2 {
+		t.Errorf("limit 3: peak of %d concurrent compiles", peak)
+	}
+}
+
+func TestSetMaxReleasesWaiters(t *testing.T) {
+	defer SetMax(DefaultMax)
+	SetMax(1)
+
+	release, _ := Acquire(context.Background(), "first")
+	acquired := make(chan func())
+	go func() {
+		second, _ := Acquire(context.Background(), "second")
+		acquired <- second
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second compile started while the only slot was taken")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Raising the limit lets the waiting compile start without a release
+	SetMax(2)
+	select {
+	case second := <-acquired:
+		second()
+	case <-time.After(time.Second):
+		t.Fatal("waiting compile did not start after raising the limit")
+	}
+
+	release()
+	release() // releasing twice must not free a second slot
+	if slots.active != 0 {
+		t.Errorf("active = %d after all releases, want 0", slots.active)
+	}
+}
+
+func TestCancelledWaiterGetsNoSlot(t *testing.T) {
+	defer SetMax(DefaultMax)
+	SetMax(1)
+
+	release, _ := Acquire(context.Background(), "running compile")
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		second, err := Acquire(ctx, "cancelled compile")
+		if second != nil {
+			second()
+		}
+		result <- err
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("compile started while the only slot was taken")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("Acquire() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelled compile still waiting for a slot")
+	}
+
+	slots.mu.Lock()
+	active, waiting := slots.active, slots.waiting
+	slots.mu.Unlock()
+	if active != 1 || waiting != 0 {
+		t.Errorf("active = %d, waiting = %d after the cancelled wait, want 1 and 0", active, waiting)
+	}
+
+	// A context cancelled before a slot is free never takes one
+	if second, err := Acquire(ctx, "already cancelled"); err == nil || second != nil {
+		t.Error("Acquire() with a cancelled context took a slot")
+	}
+}
@@ -11,10 +11,10 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
-	"syscall"
 	"time"
 	"unicode"
 
+	"latex-translator/internal/compiler/compilelimit"
 	"latex-translator/internal/editor"
 	"latex-translator/internal/logger"
 	"latex-translator/internal/types"
@@ -33,11 +33,25 @@ const (
 // DefaultTimeout is the default compilation timeout
 const DefaultTimeout = 5 * time.Minute
 
+// MaxCompilePasses is the maximum number of LaTeX runs per compilation.
+// Documents with a TOC, cross-references or citations need two to three runs.
+const MaxCompilePasses = 3
+
+// rerunPattern matches the log messages LaTeX and common packages print when
+// another run is required to get references right
+var rerunPattern = regexp.MustCompile(`Rerun to get|Label\(s\) may have changed|Please rerun LaTeX|Please \(re\)run Biber|Please \(re\)run BibTeX|Rerun LaTeX`)
+
 // LaTeXCompiler is responsible for compiling LaTeX documents
 type LaTeXCompiler struct {
-	compiler string        // "pdflatex" or "xelatex"
-	workDir  string        // working directory
-	timeout  time.Duration // compilation timeout
+	compiler    string        // "pdflatex" or "xelatex"
+	workDir     string        // working directory
+	timeout     time.Duration // compilation timeout
+	maxPasses   int           // LaTeX runs per compilation (0 means MaxCompilePasses)
+	latexmk     bool          // run latexmk instead of the manual pass sequence when installed
+	autoInstall bool          // install packages missing from the TeX distribution while compiling
+
+	// Context of the compilation in progress, set on the copy made for it; nil is never cancelled
+	ctx context.Context
 }
 
 // NewLaTeXCompiler creates a new LaTeXCompiler instance
@@ -55,10 +69,45 @@ func NewLaTeXCompiler(compiler string, workDir string, timeout time.Duration) *L
 	}
 }
 
+// withContext returns a copy of the compiler whose TeX processes are killed when ctx is done
+func (c *LaTeXCompiler) withContext(ctx context.Context) *LaTeXCompiler {
+	withCtx := *c
+	withCtx.ctx = ctx
+	return &withCtx
+}
+
+// context returns the context of the compilation in progress
+func (c *LaTeXCompiler) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// cancelledResult is the result of a compilation stopped because its context is done or
+// StopAllProcesses was called
+func (c *LaTeXCompiler) cancelledResult(log string) (*types.CompileResult, error) {
+	cause := context.Cause(c.context())
+	if cause == nil {
+		cause = processCtx.Err()
+	}
+	return &types.CompileResult{Success: false, Log: log, ErrorMsg: "compilation cancelled"},
+		types.NewAppError(types.ErrCompile, "compilation cancelled", cause)
+}
+
 // Compile compiles a tex file to PDF using the default compiler.
+//
+// Deprecated: use CompileContext, which stops the compilation when its context is done.
+func (c *LaTeXCompiler) Compile(texPath string, outputDir string) (*types.CompileResult, error) {
+	return c.CompileContext(c.context(), texPath, outputDir)
+}
+
+// CompileContext compiles a tex file to PDF using the default compiler.
 // It automatically selects xelatex if the document contains Chinese characters.
 // It also applies QuickFix to fix common LaTeX issues before compilation.
-func (c *LaTeXCompiler) Compile(texPath string, outputDir string) (*types.CompileResult, error) {
+// When ctx is done the running TeX process is killed and the compilation fails.
+func (c *LaTeXCompiler) CompileContext(ctx context.Context, texPath string, outputDir string) (*types.CompileResult, error) {
+	c = c.withContext(ctx)
 	logger.Info("compiling tex file", logger.String("texPath", texPath), logger.String("outputDir", outputDir))
 
 	// Step 1: Fix encoding issues automatically
@@ -113,7 +162,16 @@ func (c *LaTeXCompiler) Compile(texPath string, outputDir string) (*types.Compil
 }
 
 // CompileWithXeLaTeX compiles a tex file using xelatex (for Chinese documents)
+//
+// Deprecated: use CompileWithXeLaTeXContext, which stops the compilation when its context is done.
 func (c *LaTeXCompiler) CompileWithXeLaTeX(texPath string, outputDir string) (*types.CompileResult, error) {
+	return c.CompileWithXeLaTeXContext(c.context(), texPath, outputDir)
+}
+
+// CompileWithXeLaTeXContext compiles a tex file using xelatex (for Chinese documents).
+// When ctx is done the running TeX process is killed and the compilation fails.
+func (c *LaTeXCompiler) CompileWithXeLaTeXContext(ctx context.Context, texPath string, outputDir string) (*types.CompileResult, error) {
+	c = c.withContext(ctx)
 	logger.Info("compiling with XeLaTeX", logger.String("texPath", texPath))
 
 	// Read the tex file
@@ -139,14 +197,32 @@ func (c *LaTeXCompiler) CompileWithXeLaTeX(texPath string, outputDir string) (*t
 }
 
 // CompileWithPDFLaTeX compiles a tex file using pdflatex
+//
+// Deprecated: use CompileWithPDFLaTeXContext, which stops the compilation when its context is done.
 func (c *LaTeXCompiler) CompileWithPDFLaTeX(texPath string, outputDir string) (*types.CompileResult, error) {
+	return c.CompileWithPDFLaTeXContext(c.context(), texPath, outputDir)
+}
+
+// CompileWithPDFLaTeXContext compiles a tex file using pdflatex.
+// When ctx is done the running TeX process is killed and the compilation fails.
+func (c *LaTeXCompiler) CompileWithPDFLaTeXContext(ctx context.Context, texPath string, outputDir string) (*types.CompileResult, error) {
+	c = c.withContext(ctx)
 	logger.Info("compiling with PDFLaTeX", logger.String("texPath", texPath))
 	return c.compileWithCompiler(texPath, outputDir, CompilerPDFLaTeX)
 }
 
 // CompileWithLuaLaTeX compiles a tex file using lualatex
-// LuaLaTeX has better UTF-8 support than XeLaTeX, especially for Chinese characters on Windows
+//
+// Deprecated: use CompileWithLuaLaTeXContext, which stops the compilation when its context is done.
 func (c *LaTeXCompiler) CompileWithLuaLaTeX(texPath string, outputDir string) (*types.CompileResult, error) {
+	return c.CompileWithLuaLaTeXContext(c.context(), texPath, outputDir)
+}
+
+// CompileWithLuaLaTeXContext compiles a tex file using lualatex
+// LuaLaTeX has better UTF-8 support than XeLaTeX, especially for Chinese characters on Windows.
+// When ctx is done the running TeX process is killed and the compilation fails.
+func (c *LaTeXCompiler) CompileWithLuaLaTeXContext(ctx context.Context, texPath string, outputDir string) (*types.CompileResult, error) {
+	c = c.withContext(ctx)
 	logger.Info("compiling with LuaLaTeX", logger.String("texPath", texPath))
 	
 	// Apply QuickFix before compilation
@@ -257,6 +333,9 @@ func (c *LaTeXCompiler) compileWithCompiler(texPath string, outputDir string, co
 	if err == nil && result.Success {
 		return result, nil
 	}
+	if c.context().Err() != nil {
+		return result, err
+	}
 
 	// If compilation failed, try to fix and recompile
 	logger.Info("initial compilation failed, attempting automatic fixes", logger.String("texPath", texPath))
@@ -380,6 +459,8 @@ func (c *LaTeXCompiler) tryCompile(texPath string, outputDir string, compiler st
 					logger.String("texPath", absTexPath))
 				skipBibtex = true
 			}
+		} else if usePrebuiltBiblatexBbl(string(texContent), texDir, absOutputDir, texBaseName) {
+			skipBibtex = true
 		}
 	}
 
@@ -421,95 +502,28 @@ func (c *LaTeXCompiler) tryCompile(texPath string, outputDir string, compiler st
 		}
 	}
 
-	// First pass: compile to generate .aux file
-	logger.Debug("first compilation pass")
-	log1, err := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
-	allLogs = append(allLogs, "=== First Pass ===", log1)
-	if err != nil {
-		// Continue even if first pass has errors - we still want to try bibtex
-		logger.Warn("first pass had errors, continuing", logger.Err(err))
-	}
-
-	// Copy .aux file from output directory to source directory
-	// This is needed because xelatex with -output-directory tries to read .aux from source dir at \end{document}
-	if absOutputDir != texDir {
-		auxSrc := filepath.Join(absOutputDir, texBaseName+".aux")
-		auxDst := filepath.Join(texDir, texBaseName+".aux")
-		if auxContent, err := os.ReadFile(auxSrc); err == nil {
-			if err := os.WriteFile(auxDst, auxContent, 0644); err != nil {
-				logger.Warn("failed to copy .aux file to source directory", logger.Err(err))
-			} else {
-				logger.Debug("copied .aux file to source directory")
-			}
-		}
-	}
-
-	// Check if there's a .bib file or bibliography commands
-	auxPath := filepath.Join(absOutputDir, texBaseName+".aux")
-	needsBibtex := c.checkNeedsBibtex(auxPath, texDir)
-
-	if needsBibtex && !skipBibtex {
-		// Run bibtex
-		logger.Debug("running bibtex")
-		bibtexLog, bibtexErr := c.runBibtex(texBaseName, texDir, absOutputDir)
-		allLogs = append(allLogs, "=== BibTeX ===", bibtexLog)
-		if bibtexErr != nil {
-			logger.Warn("bibtex had errors", logger.Err(bibtexErr))
-		}
-
-		// Copy .bbl file from output directory to source directory
-		// This is needed because LaTeX runs in texDir but bibtex outputs to absOutputDir
-		bblSrc := filepath.Join(absOutputDir, texBaseName+".bbl")
-		bblDst := filepath.Join(texDir, texBaseName+".bbl")
-		if bblContent, err := os.ReadFile(bblSrc); err == nil {
-			if err := os.WriteFile(bblDst, bblContent, 0644); err != nil {
-				logger.Warn("failed to copy .bbl file", logger.Err(err))
-			} else {
-				logger.Debug("copied .bbl file to source directory")
-			}
-		}
-
-		// Second pass: resolve citations
-		logger.Debug("second compilation pass")
-		log2, _ := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
-		allLogs = append(allLogs, "=== Second Pass ===", log2)
-		// Copy .aux file after second pass
-		copyAuxFile(absOutputDir, texDir, texBaseName)
-
-		// Third pass: resolve cross-references
-		logger.Debug("third compilation pass")
-		log3, _ := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
-		allLogs = append(allLogs, "=== Third Pass ===", log3)
-		// Copy .aux file after third pass
-		copyAuxFile(absOutputDir, texDir, texBaseName)
-	} else if skipBibtex {
-		// We have a pre-existing .bbl file (from original document), run additional passes to resolve citations
-		logger.Debug("using pre-existing .bbl file, running additional compilation passes")
-
-		// Second pass: resolve citations
-		logger.Debug("second compilation pass")
-		log2, _ := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
-		allLogs = append(allLogs, "=== Second Pass ===", log2)
-		// Copy .aux file after second pass
-		copyAuxFile(absOutputDir, texDir, texBaseName)
-
-		// Third pass: resolve cross-references
-		logger.Debug("third compilation pass")
-		log3, _ := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
-		allLogs = append(allLogs, "=== Third Pass ===", log3)
-		// Copy .aux file after third pass
-		copyAuxFile(absOutputDir, texDir, texBaseName)
+	// Run the engine until the references are resolved: through latexmk when it is
+	// enabled, or pass by pass with bibtex/biber and makeindex in between
+	var passes int
+	var lastPassLog string
+	installer := c.newPackageInstaller()
+	allLogs = installer.prepare(texDir, allLogs)
+	if c.latexmkEnabled() {
+		passes, lastPassLog, allLogs = c.runLatexmk(compiler, texFileName, texBaseName, texDir, absOutputDir, skipBibtex, installer, allLogs)
 	} else {
-		// Just run a second pass for cross-references
-		logger.Debug("second compilation pass (no bibtex needed)")
-		log2, _ := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
-		allLogs = append(allLogs, "=== Second Pass ===", log2)
-		// Copy .aux file after second pass
-		copyAuxFile(absOutputDir, texDir, texBaseName)
+		passes, lastPassLog, allLogs = c.runPasses(compiler, texFileName, texBaseName, texDir, absOutputDir, skipBibtex, installer, allLogs)
 	}
+	var installedPackages []string
+	installedPackages, allLogs = installer.finish(allLogs)
 
 	// Combine all logs
 	combinedLog := strings.Join(allLogs, "\n")
+	if c.context().Err() != nil {
+		logger.Warn("compilation cancelled", logger.String("texPath", absTexPath), logger.Int("passes", passes))
+		return c.cancelledResult(combinedLog)
+	}
+	// Earlier passes may fail on references that later passes resolve; the last one counts
+	logErrors := ParseLog(lastPassLog)
 
 	// Determine PDF path
 	pdfName := texBaseName + ".pdf"
@@ -518,29 +532,114 @@ func (c *LaTeXCompiler) tryCompile(texPath string, outputDir string, compiler st
 	// Verify PDF was created
 	if _, err := os.Stat(pdfPath); os.IsNotExist(err) {
 		logger.Error("PDF file was not generated", nil, logger.String("expectedPath", pdfPath))
+		errorMsg := "PDF file was not generated"
+		for _, e := range logErrors {
+			if e.Severity == types.SeverityError {
+				errorMsg += ": " + e.String()
+				break
+			}
+		}
 		return &types.CompileResult{
-			Success:  false,
-			Log:      combinedLog,
-			ErrorMsg: "PDF file was not generated",
-		}, types.NewAppError(types.ErrCompile, "PDF file was not generated", nil)
+			Success:           false,
+			Log:               combinedLog,
+			ErrorMsg:          errorMsg,
+			Passes:            passes,
+			InstalledPackages: installedPackages,
+			Errors:            logErrors,
+		}, types.NewAppError(types.ErrCompile, errorMsg, nil)
+	}
+
+	// \printindex reads the .ind file; LaTeX reports it missing when makeindex failed
+	indexMissing := strings.Contains(lastPassLog, "No file "+texBaseName+".ind")
+	if indexMissing {
+		logger.Warn("document prints an index but the index file was not generated", logger.String("texPath", absTexPath))
 	}
 
-	logger.Info("compilation completed successfully", logger.String("pdfPath", pdfPath))
+	logger.Info("compilation completed successfully", logger.String("pdfPath", pdfPath), logger.Int("passes", passes))
 	return &types.CompileResult{
-		Success: true,
-		PDFPath: pdfPath,
-		Log:     combinedLog,
+		Success:           true,
+		PDFPath:           pdfPath,
+		Log:               combinedLog,
+		Passes:            passes,
+		IndexMissing:      indexMissing,
+		InstalledPackages: installedPackages,
+		Errors:            logErrors,
 	}, nil
 }
 
+// runPasses runs LaTeX up to MaxCompilePasses times. After the first pass the bibliography
+// is processed (bibtex or biber) if needed; further passes run only while LaTeX asks for a
+// rerun or the .aux/.toc files still change, so the final PDF has resolved references.
+// A pass after which the installer installed missing packages is repeated on top of the limit.
+// Returns the number of passes, the log of the last pass and the extended logs.
+func (c *LaTeXCompiler) runPasses(compiler, texFileName, texBaseName, texDir, absOutputDir string, skipBibtex bool, installer *packageInstaller, allLogs []string) (int, string, []string) {
+	passes := 0
+	limit := c.passLimit()
+	bibliographyDone := false
+	indexSource := ""
+	lastPassLog := ""
+	for passes < limit && c.context().Err() == nil {
+		passes++
+		before := readRerunState(absOutputDir, texBaseName)
+		logger.Debug("compilation pass", logger.Int("pass", passes))
+		passLog, err := c.runCompiler(compiler, texFileName, texDir, absOutputDir)
+		allLogs = append(allLogs, fmt.Sprintf("=== Pass %d ===", passes), passLog)
+		lastPassLog = passLog
+		if err != nil {
+			// Continue even if a pass has errors - documents often still produce a PDF
+			logger.Warn("compilation pass had errors, continuing", logger.Int("pass", passes), logger.Err(err))
+		}
+		var installed bool
+		if installed, allLogs = installer.installFromLog(passLog, allLogs); installed {
+			limit++
+			continue
+		}
+
+		// Copy .aux file from output directory to source directory
+		// This is needed because xelatex with -output-directory tries to read .aux from source dir at \end{document}
+		copyAuxFile(absOutputDir, texDir, texBaseName)
+
+		bibliographyRan := false
+		if !bibliographyDone {
+			bibliographyDone = true
+			if !skipBibtex {
+				bibliographyRan, allLogs = c.processBibliography(texBaseName, texDir, absOutputDir, allLogs)
+			} else {
+				// Pre-existing .bbl (inlined), one more pass resolves the citations
+				bibliographyRan = true
+			}
+		}
+
+		// The index is rebuilt whenever a pass writes different entries (page numbers)
+		var indexRan bool
+		indexRan, indexSource, allLogs = c.processIndex(texBaseName, texDir, absOutputDir, indexSource, allLogs)
+
+		if bibliographyRan || indexRan {
+			continue
+		}
+		if !NeedsRerun(passLog) && readRerunState(absOutputDir, texBaseName) == before {
+			logger.Debug("aux files stable, stopping compilation passes", logger.Int("passes", passes))
+			break
+		}
+	}
+	return passes, lastPassLog, allLogs
+}
+
 // runCompiler executes a single compilation pass
 func (c *LaTeXCompiler) runCompiler(compiler string, texFileName string, texDir string, outputDir string) (string, error) {
-	args := buildCompilerArgs(compiler, texFileName, outputDir, texDir)
+	args := append(c.installerArgs(), buildCompilerArgs(compiler, texFileName, outputDir, texDir)...)
+
+	// Wait for a slot before starting the timeout, queueing is not part of the compile time
+	release, err := compilelimit.Acquire(c.context(), compiler + " " + texFileName)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	ctx, cancel := processContext(c.context(), c.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, compiler, args...)
+	cmd := commandContext(ctx, compiler, args...)
 	cmd.Dir = texDir
 
 	// Set TEXINPUTS to include the source directory so LaTeX can find .sty, .cls, etc.
@@ -552,21 +651,16 @@ func (c *LaTeXCompiler) runCompiler(compiler string, texFileName string, texDir
 	}
 	// The trailing path separator means "also search default paths"
 	texInputs := fmt.Sprintf("TEXINPUTS=.%s%s%s", pathSep, texDir, pathSep)
-	cmd.Env = append(os.Environ(), texInputs)
+	cmd.Env = append(append(os.Environ(), texInputs), c.installerEnv()...)
 
 	// Hide console window on Windows
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-		}
-	}
+	hideWindow(cmd)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	log := combineOutput(stdout.String(), stderr.String())
 
 	if ctx.Err() == context.DeadlineExceeded {
@@ -578,7 +672,13 @@ func (c *LaTeXCompiler) runCompiler(compiler string, texFileName string, texDir
 
 // runBibtex executes bibtex to process bibliography
 func (c *LaTeXCompiler) runBibtex(baseName string, texDir string, outputDir string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	release, err := compilelimit.Acquire(c.context(), "bibtex " + baseName)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := processContext(c.context(), 2*time.Minute)
 	defer cancel()
 
 	// bibtex needs to run in the output directory where .aux file is
@@ -589,7 +689,7 @@ func (c *LaTeXCompiler) runBibtex(baseName string, texDir string, outputDir stri
 		workDir = texDir
 	}
 
-	cmd := exec.CommandContext(ctx, "bibtex", baseName)
+	cmd := commandContext(ctx, "bibtex", baseName)
 	cmd.Dir = workDir
 
 	// Set BIBINPUTS to include the source directory for .bib files
@@ -606,23 +706,175 @@ func (c *LaTeXCompiler) runBibtex(baseName string, texDir string, outputDir stri
 	cmd.Env = append(os.Environ(), bibInputs, bstInputs)
 
 	// Hide console window on Windows
-	if runtime.GOOS == "windows" {
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			HideWindow:    true,
-			CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-		}
-	}
+	hideWindow(cmd)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	log := combineOutput(stdout.String(), stderr.String())
 
 	return log, err
 }
 
+// processBibliography runs biber (biblatex with the biber backend) or bibtex after the
+// first pass if the document has a bibliography, and copies the resulting .bbl next to
+// the tex file. Returns whether a bibliography tool was run, and the extended logs.
+func (c *LaTeXCompiler) processBibliography(texBaseName string, texDir string, outputDir string, allLogs []string) (bool, []string) {
+	if _, err := os.Stat(filepath.Join(outputDir, texBaseName+".bcf")); err == nil {
+		logger.Debug("running biber")
+		biberLog, biberErr := c.runBiber(texBaseName, texDir, outputDir)
+		allLogs = append(allLogs, "=== Biber ===", biberLog)
+		if biberErr != nil {
+			logger.Warn("biber had errors", logger.Err(biberErr))
+		}
+	} else if c.checkNeedsBibtex(filepath.Join(outputDir, texBaseName+".aux"), texDir) {
+		logger.Debug("running bibtex")
+		bibtexLog, bibtexErr := c.runBibtex(texBaseName, texDir, outputDir)
+		allLogs = append(allLogs, "=== BibTeX ===", bibtexLog)
+		if bibtexErr != nil {
+			logger.Warn("bibtex had errors", logger.Err(bibtexErr))
+		}
+	} else {
+		return false, allLogs
+	}
+
+	// Copy .bbl file from output directory to source directory
+	// This is needed because LaTeX runs in texDir but bibtex/biber output to outputDir
+	if outputDir != texDir {
+		bblSrc := filepath.Join(outputDir, texBaseName+".bbl")
+		bblDst := filepath.Join(texDir, texBaseName+".bbl")
+		if bblContent, err := os.ReadFile(bblSrc); err == nil {
+			if err := os.WriteFile(bblDst, bblContent, 0644); err != nil {
+				logger.Warn("failed to copy .bbl file", logger.Err(err))
+			} else {
+				logger.Debug("copied .bbl file to source directory")
+			}
+		}
+	}
+	return true, allLogs
+}
+
+// processIndex runs makeindex (or xindy when makeindex is not installed) when the last
+// pass wrote index entries (.idx) other than the ones the current .ind was made from, and
+// copies the .ind next to the tex file. Returns whether the index was rebuilt, the .idx
+// content it was built from, and the extended logs.
+func (c *LaTeXCompiler) processIndex(texBaseName string, texDir string, outputDir string, lastSource string, allLogs []string) (bool, string, []string) {
+	idx, err := os.ReadFile(filepath.Join(outputDir, texBaseName+".idx"))
+	if err != nil || len(idx) == 0 || string(idx) == lastSource {
+		return false, lastSource, allLogs
+	}
+
+	logger.Debug("building index", logger.Int("idxBytes", len(idx)))
+	indexLog, indexErr := c.runMakeindex(texBaseName, texDir, outputDir)
+	allLogs = append(allLogs, "=== Index ===", indexLog)
+	if indexErr != nil {
+		logger.Warn("index generation had errors", logger.Err(indexErr))
+	}
+
+	if outputDir != texDir {
+		if ind, err := os.ReadFile(filepath.Join(outputDir, texBaseName+".ind")); err == nil {
+			if err := os.WriteFile(filepath.Join(texDir, texBaseName+".ind"), ind, 0644); err != nil {
+				logger.Warn("failed to copy .ind file", logger.Err(err))
+			}
+		}
+	}
+	return true, string(idx), allLogs
+}
+
+// runMakeindex executes makeindex, or texindy when makeindex is not available, on the
+// .idx file in the output directory
+func (c *LaTeXCompiler) runMakeindex(baseName string, texDir string, outputDir string) (string, error) {
+	release, err := compilelimit.Acquire(c.context(), "makeindex " + baseName)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := processContext(c.context(), 2*time.Minute)
+	defer cancel()
+
+	workDir := outputDir
+	if outputDir == "" {
+		workDir = texDir
+	}
+
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("makeindex"); err == nil {
+		args := []string{}
+		// A style file next to the source (e.g. main.ist) is used like LaTeX's own run would
+		if _, err := os.Stat(filepath.Join(texDir, baseName+".ist")); err == nil {
+			args = append(args, "-s", filepath.Join(texDir, baseName+".ist"))
+		}
+		cmd = commandContext(ctx, "makeindex", append(args, baseName+".idx")...)
+	} else {
+		cmd = commandContext(ctx, "texindy", "-C", "utf8", "-o", baseName+".ind", baseName+".idx")
+	}
+	cmd.Dir = workDir
+
+	// Hide console window on Windows
+	hideWindow(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	return combineOutput(stdout.String(), stderr.String()), err
+}
+
+// runBiber executes biber to process a biblatex bibliography
+func (c *LaTeXCompiler) runBiber(baseName string, texDir string, outputDir string) (string, error) {
+	release, err := compilelimit.Acquire(c.context(), "biber " + baseName)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := processContext(c.context(), 2*time.Minute)
+	defer cancel()
+
+	workDir := outputDir
+	if outputDir == "" {
+		workDir = texDir
+	}
+
+	// --input-directory lets biber find .bib files next to the tex source
+	cmd := commandContext(ctx, "biber", "--input-directory", texDir, baseName)
+	cmd.Dir = workDir
+
+	// Hide console window on Windows
+	hideWindow(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	return combineOutput(stdout.String(), stderr.String()), err
+}
+
+// NeedsRerun reports whether a LaTeX log asks for another run to resolve
+// cross-references, citations or the table of contents
+func NeedsRerun(log string) bool {
+	return rerunPattern.MatchString(log)
+}
+
+// readRerunState returns the content of the files that change between passes while
+// references are still being resolved (.aux, .toc). Identical state before and after
+// a pass means another pass would not change the output.
+func readRerunState(outputDir string, baseName string) string {
+	var sb strings.Builder
+	for _, ext := range []string{".aux", ".toc"} {
+		if content, err := os.ReadFile(filepath.Join(outputDir, baseName+ext)); err == nil {
+			sb.WriteString(ext)
+			sb.Write(content)
+		}
+	}
+	return sb.String()
+}
+
 // copyAuxFile copies the .aux file from output directory to source directory
 // This is needed because xelatex with -output-directory tries to read .aux from source dir at \end{document}
 func copyAuxFile(outputDir, texDir, baseName string) {
@@ -698,6 +950,21 @@ func combineOutput(stdout, stderr string) string {
 	return strings.Join(parts, "\n")
 }
 
+// SetMaxPasses limits the number of LaTeX runs per compilation. A single pass is faster
+// but leaves references, citations and the table of contents unresolved. 0 restores
+// MaxCompilePasses.
+func (c *LaTeXCompiler) SetMaxPasses(n int) {
+	c.maxPasses = n
+}
+
+// passLimit returns the number of LaTeX runs per compilation
+func (c *LaTeXCompiler) passLimit() int {
+	if c.maxPasses > 0 && c.maxPasses < MaxCompilePasses {
+		return c.maxPasses
+	}
+	return MaxCompilePasses
+}
+
 // GetCompiler returns the default compiler
 func (c *LaTeXCompiler) GetCompiler() string {
 	return c.compiler
@@ -718,57 +985,6 @@ func (c *LaTeXCompiler) SetTimeout(timeout time.Duration) {
 	c.timeout = timeout
 }
 
-// inlineBibliography replaces \bibliography{...} command with the actual .bbl content.
-// This is useful for translated documents where LaTeX might stop early due to errors,
-// preventing the \bibliography command from being processed.
-// It also fixes compatibility issues with xelatex by redefining problematic commands.
-// Returns the modified content and a boolean indicating if the replacement was made.
-func inlineBibliography(texContent string, bblContent string) (string, bool) {
-	// Check if the file already has thebibliography environment
-	// If so, don't inline again to avoid duplicates
-	if strings.Contains(texContent, `\begin{thebibliography}`) {
-		logger.Debug("skipping inlineBibliography - file already has thebibliography environment")
-		return texContent, false
-	}
-
-	// Pattern to match \bibliography{...} command (only non-commented lines)
-	// This matches \bibliography{refs} or \bibliography{refs,other} etc.
-	// Use multiline mode to ensure we only match lines that don't start with %
-	bibPattern := regexp.MustCompile(`(?m)^([^%\n]*)\\bibliography\{[^}]+\}[^\n]*`)
-
-	if !bibPattern.MatchString(texContent) {
-		return texContent, false
-	}
-
-	// Check if the \bibliography command is in the preamble (before \begin{document})
-	// If so, skip inlining to avoid putting thebibliography in the preamble
-	beginDocIdx := strings.Index(texContent, `\begin{document}`)
-	if beginDocIdx != -1 {
-		match := bibPattern.FindStringIndex(texContent)
-		if match != nil && match[0] < beginDocIdx {
-			logger.Debug("skipping inlineBibliography - \\bibliography is in preamble",
-				logger.Int("bibIdx", match[0]),
-				logger.Int("beginDocIdx", beginDocIdx))
-			return texContent, false
-		}
-	}
-
-	// Fix xelatex compatibility issues in the .bbl content
-	// The breakurl package's \burl command uses \pdf@box which is not available in xelatex
-	// We need to redefine \burl to use a simpler implementation
-	fixedBblContent := fixBblForXelatex(bblContent)
-
-	// Replace \bibliography{...} with the fixed .bbl content
-	// The .bbl file contains the \begin{thebibliography}...\end{thebibliography} environment
-	// Preserve any content before \bibliography on the same line (captured in $1)
-	result := bibPattern.ReplaceAllString(texContent, "${1}"+fixedBblContent)
-
-	logger.Debug("inlined bibliography content",
-		logger.Int("bblLength", len(fixedBblContent)))
-
-	return result, true
-}
-
 // fixBblForXelatex fixes compatibility issues in .bbl content for xelatex.
 // The main issue is that the breakurl package's \burl command uses \pdf@box
 // which is not available in xelatex. We redefine \burl to use a simpler implementation.
@@ -802,126 +1018,6 @@ func fixBblForXelatex(bblContent string) string {
 	return bblContent
 }
 
-// fixMissingBibFile checks if the tex file references a .bib file via \bibliography{} command
-// but the .bib file doesn't exist. If a .bbl file exists (common in arXiv submissions),
-// it inlines the .bbl content to replace the \bibliography{} command.
-// This handles cases where arXiv source only includes pre-compiled .bbl but tex references .bib.
-// Returns the modified content and a boolean indicating if any fix was applied.
-func fixMissingBibFile(texContent string, texDir string, texBaseName string) (string, bool) {
-	// Check if the file already has thebibliography environment
-	// If so, don't inline again to avoid duplicates
-	if strings.Contains(texContent, `\begin{thebibliography}`) {
-		logger.Debug("skipping fixMissingBibFile - file already has thebibliography environment")
-		return texContent, false
-	}
-
-	// Pattern to match \bibliography{...} command and extract the bib file name(s)
-	// Use multiline mode to match only non-commented lines
-	// The pattern matches \bibliography{...} only if it's not preceded by % on the same line
-	bibPattern := regexp.MustCompile(`(?m)^[^%\n]*\\bibliography\{([^}]+)\}`)
-	matches := bibPattern.FindStringSubmatch(texContent)
-	
-	if len(matches) < 2 {
-		// No uncommented \bibliography command found
-		return texContent, false
-	}
-	
-	// Check if the \bibliography command is in the preamble (before \begin{document})
-	// If so, skip inlining to avoid putting thebibliography in the preamble
-	beginDocIdx := strings.Index(texContent, `\begin{document}`)
-	if beginDocIdx != -1 {
-		bibIdx := strings.Index(texContent, matches[0])
-		if bibIdx != -1 && bibIdx < beginDocIdx {
-			logger.Debug("skipping fixMissingBibFile - \\bibliography is in preamble",
-				logger.Int("bibIdx", bibIdx),
-				logger.Int("beginDocIdx", beginDocIdx))
-			return texContent, false
-		}
-	}
-	
-	// Extract bib file names (can be comma-separated)
-	bibNames := strings.Split(matches[1], ",")
-	allBibFilesExist := true
-	
-	for _, bibName := range bibNames {
-		bibName = strings.TrimSpace(bibName)
-		if bibName == "" {
-			continue
-		}
-		
-		// Check if .bib file exists
-		bibPath := filepath.Join(texDir, bibName)
-		if !strings.HasSuffix(bibPath, ".bib") {
-			bibPath += ".bib"
-		}
-		
-		if _, err := os.Stat(bibPath); os.IsNotExist(err) {
-			allBibFilesExist = false
-			logger.Debug("bib file not found", logger.String("bibPath", bibPath))
-			break
-		}
-	}
-	
-	if allBibFilesExist {
-		// All .bib files exist, no need to fix
-		return texContent, false
-	}
-	
-	// .bib file(s) missing, look for .bbl file
-	// Try multiple possible .bbl file names:
-	// 1. Same name as the tex file (most common)
-	// 2. Same name as the first bib file referenced
-	possibleBblNames := []string{
-		texBaseName + ".bbl",
-	}
-	
-	// Add bib file names as possible bbl names
-	for _, bibName := range bibNames {
-		bibName = strings.TrimSpace(bibName)
-		if bibName != "" {
-			possibleBblNames = append(possibleBblNames, bibName+".bbl")
-		}
-	}
-	
-	// Also scan directory for any .bbl file
-	files, err := os.ReadDir(texDir)
-	if err == nil {
-		for _, f := range files {
-			if strings.HasSuffix(f.Name(), ".bbl") {
-				possibleBblNames = append(possibleBblNames, f.Name())
-			}
-		}
-	}
-	
-	// Try to find and read a .bbl file
-	var bblContent string
-	var bblFound bool
-	for _, bblName := range possibleBblNames {
-		bblPath := filepath.Join(texDir, bblName)
-		if content, err := os.ReadFile(bblPath); err == nil && len(content) > 0 {
-			// Verify it's a valid .bbl file (should contain thebibliography environment)
-			if strings.Contains(string(content), "\\begin{thebibliography}") {
-				bblContent = string(content)
-				bblFound = true
-				logger.Info("found .bbl file to inline",
-					logger.String("bblPath", bblPath),
-					logger.Int("size", len(content)))
-				break
-			}
-		}
-	}
-	
-	if !bblFound {
-		logger.Warn("no valid .bbl file found to replace missing .bib file",
-			logger.String("texDir", texDir))
-		return texContent, false
-	}
-	
-	// Inline the .bbl content using the existing inlineBibliography function
-	return inlineBibliography(texContent, bblContent)
-}
-
-
 // autoFixEncoding automatically fixes encoding issues in all .tex files in the directory
 // This is integrated into the compilation process to ensure Chinese characters are properly detected
 func (c *LaTeXCompiler) autoFixEncoding(mainTexPath string, texDir string) error {
@@ -1047,3 +1143,28 @@ func (c *LaTeXCompiler) ensureChineseSupport(texPath string, content string) {
 	logger.Debug("ensureChineseSupport is disabled to avoid conflicts")
 	return
 }
+
+// structuralErrorPatterns are log fragments showing that the compiled file is not
+// a complete LaTeX document, which usually means the wrong main file was chosen.
+var structuralErrorPatterns = []string{
+	`Missing \begin{document}`,
+	`no legal \end found`,
+	`File ended while scanning`,
+	`\begin{document} ended by`,
+	`No pages of output`,
+}
+
+// IsStructuralCompileError reports whether a failed compilation looks like it was
+// caused by compiling a file that is not a complete document (e.g. a standalone
+// figure or an \input fragment) rather than by an error inside the document.
+func IsStructuralCompileError(result *types.CompileResult) bool {
+	if result == nil || result.Success {
+		return false
+	}
+	for _, pattern := range structuralErrorPatterns {
+		if strings.Contains(result.Log, pattern) || strings.Contains(result.ErrorMsg, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -9,14 +9,19 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/cloudwego/eino/compose"
+	einoagent "github.com/cloudwego/eino/flow/agent"
 	"github.com/cloudwego/eino/flow/agent/react"
 	"github.com/cloudwego/eino/schema"
+	template "github.com/cloudwego/eino/utils/callbacks"
 	"github.com/cloudwego/eino-ext/components/model/openai"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/translator"
 )
 
 // EinoAgentFixer uses the eino framework's ReAct agent for intelligent LaTeX error fixing
@@ -28,6 +33,7 @@ type EinoAgentFixer struct {
 	compiler  *LaTeXCompiler
 	outputDir string
 	maxSteps  int
+	usage     *translator.UsageMeter // tokens of the model calls are added to it (nil: not metered)
 }
 
 // NewEinoAgentFixer creates a new eino-based agent fixer
@@ -323,7 +329,7 @@ func (f *EinoAgentFixer) FixWithEinoAgent(
 
 	response, err := agent.Generate(ctx, []*schema.Message{
 		schema.UserMessage(userMessage),
-	})
+	}, einoagent.WithComposeOptions(compose.WithCallbacks(f.usageHandler())))
 	if err != nil {
 		logger.Error("eino agent failed", err)
 		return result, fmt.Errorf("agent execution failed: %w", err)
@@ -357,6 +363,23 @@ func (f *EinoAgentFixer) FixWithEinoAgent(
 	return result, nil
 }
 
+// usageHandler returns a callback handler adding the tokens of each model call of the
+// agent to the usage meter
+func (f *EinoAgentFixer) usageHandler() callbacks.Handler {
+	return template.NewHandlerHelper().ChatModel(&template.ModelCallbackHandler{
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, output *model.CallbackOutput) context.Context {
+			if output != nil && output.TokenUsage != nil {
+				f.usage.Add(f.model, translator.Usage{
+					PromptTokens:     output.TokenUsage.PromptTokens,
+					CompletionTokens: output.TokenUsage.CompletionTokens,
+					TotalTokens:      output.TokenUsage.TotalTokens,
+				})
+			}
+			return ctx
+		},
+	}).Handler()
+}
+
 func (f *EinoAgentFixer) buildSystemPrompt() string {
 	return `You are a LaTeX debugging agent. Your job is to fix compilation errors by locating the problem, understanding it, and making precise fixes.
 
@@ -0,0 +1,256 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// EnginePreprocessor adapts a document to an engine before it is compiled. Apply returns
+// the adapted content and whether it changed anything.
+type EnginePreprocessor struct {
+	Name  string
+	Apply func(content string) (string, bool)
+}
+
+// enginePreprocessors are the adaptations applied before compiling with each engine, in order
+var enginePreprocessors = map[string][]EnginePreprocessor{
+	CompilerXeLaTeX: {
+		{Name: "class", Apply: adaptClassPreamble},
+		{Name: "ctex", Apply: convertCJKutf8ToCtex},
+		{Name: "cjk-font", Apply: selectCJKFont},
+		{Name: "fontenc", Apply: disableLegacyFontenc},
+		{Name: "inputenc", Apply: disableInputenc},
+		{Name: "legacy-fonts", Apply: disableLegacyFontPackages},
+		{Name: "microtype", Apply: disableMicrotypeExpansion},
+	},
+	CompilerLuaLaTeX: {
+		{Name: "class", Apply: adaptClassPreamble},
+		{Name: "ctex", Apply: convertCJKutf8ToCtex},
+		{Name: "cjk-font", Apply: selectCJKFont},
+		{Name: "fontenc", Apply: disableLegacyFontenc},
+		{Name: "inputenc", Apply: disableInputenc},
+		{Name: "legacy-fonts", Apply: disableLegacyFontPackages},
+	},
+	CompilerPDFLaTeX: {
+		{Name: "class", Apply: adaptClassPreamble},
+		{Name: "cjkutf8", Apply: convertToCJKutf8},
+	},
+}
+
+// fallbackOrder is the order the engines are tried in after the preferred one
+var fallbackOrder = []string{CompilerXeLaTeX, CompilerLuaLaTeX, CompilerPDFLaTeX}
+
+var (
+	// cjkBeginPattern matches the opening of a CJK* environment, e.g. "\begin{CJK*}{UTF8}{gbsn}"
+	cjkBeginPattern = regexp.MustCompile(`\\begin\{CJK\*\}\{[^}]*\}\{[^}]*\}\s*\n?`)
+	// cjkEndPattern matches the closing of a CJK* environment
+	cjkEndPattern = regexp.MustCompile(`\\end\{CJK\*\}\s*\n?`)
+	// unicodeCJKPackagePattern matches the CJK and font packages that need XeLaTeX or LuaLaTeX
+	unicodeCJKPackagePattern = regexp.MustCompile(`^\s*\\usepackage(?:\[[^\]]*\])?\{(ctex|xeCJK|fontspec|luatexja|luatexja-fontspec|kotex)\}`)
+	// unicodeFontCommandPattern matches the font setup commands of those packages, and the
+	// fontspec font tests of the font chains added with xeCJK and luatexja-fontspec
+	unicodeFontCommandPattern = regexp.MustCompile(`^\s*\\(setCJK(?:main|sans|mono)font|setCJKfamilyfont|set(?:main|sans|mono)j?font|ctexset|xeCJKsetup|IfFontExistsTF)\b`)
+	// ctexClassPattern matches the ctex document classes, e.g. "\documentclass{ctexart}"
+	ctexClassPattern = regexp.MustCompile(`(\\documentclass(?:\[[^\]]*\])?\{)ctex(art|rep|book|beamer)\}`)
+)
+
+// PreprocessForEngine applies the preprocessors of engine to content. Returns the adapted
+// content and the names of the preprocessors that changed it.
+func PreprocessForEngine(content, engine string) (string, []string) {
+	var applied []string
+	for _, p := range enginePreprocessors[engine] {
+		if fixed, changed := p.Apply(content); changed {
+			content = fixed
+			applied = append(applied, p.Name)
+		}
+	}
+	return content, applied
+}
+
+// CompileTranslatedWithFallback compiles a translated document with the preferred engine and,
+// when that fails, with the other engines in turn: XeLaTeX, LuaLaTeX, then pdfLaTeX with
+// CJKutf8. Each attempt starts from the document as it was before the first one, adapted by
+// the preprocessors of its engine; fallback engines that are not installed are skipped.
+// The result reports the engine that produced the PDF and the preprocessors that ran. When
+// every engine fails, the document is left as the preferred engine compiled it and the
+// result of that engine is returned.
+//
+// Deprecated: use CompileTranslatedWithFallbackContext, which stops the compilation when its
+// context is done.
+func (c *LaTeXCompiler) CompileTranslatedWithFallback(texPath, outputDir, preferred string) (*types.CompileResult, error) {
+	return c.CompileTranslatedWithFallbackContext(c.context(), texPath, outputDir, preferred)
+}
+
+// CompileTranslatedWithFallbackContext is CompileTranslatedWithFallback stopping when ctx is
+// done: the running TeX process is killed and no further engine is tried.
+func (c *LaTeXCompiler) CompileTranslatedWithFallbackContext(ctx context.Context, texPath, outputDir, preferred string) (*types.CompileResult, error) {
+	c = c.withContext(ctx)
+	if preferred == "" {
+		preferred = CompilerXeLaTeX
+	}
+	original, err := os.ReadFile(texPath)
+	if err != nil {
+		logger.Error("failed to read tex file", err, logger.String("texPath", texPath))
+		return &types.CompileResult{
+			Success:  false,
+			ErrorMsg: fmt.Sprintf("failed to read tex file: %v", err),
+		}, types.NewAppError(types.ErrFileNotFound, "failed to read tex file", err)
+	}
+
+	var firstResult *types.CompileResult
+	var firstErr error
+	var firstContent []byte
+	for _, engine := range fallbackEngines(preferred) {
+		if processCtx.Err() != nil || ctx.Err() != nil {
+			break
+		}
+		if engine != preferred {
+			if _, err := exec.LookPath(engine); err != nil {
+				logger.Debug("fallback engine not installed, skipped", logger.String("engine", engine))
+				continue
+			}
+		}
+
+		content, applied := PreprocessForEngine(string(original), engine)
+		if err := os.WriteFile(texPath, []byte(content), 0644); err != nil {
+			logger.Warn("failed to write engine adaptations", logger.String("engine", engine), logger.Err(err))
+		}
+		result, err := c.compileWithEngine(texPath, outputDir, engine)
+		if result == nil {
+			result = &types.CompileResult{ErrorMsg: fmt.Sprintf("%s compilation failed", engine)}
+		}
+		result.Engine = engine
+		result.Preprocessors = applied
+		if err == nil && result.Success {
+			if engine != preferred {
+				logger.Warn("compiled with a fallback engine",
+					logger.String("preferred", preferred),
+					logger.String("engine", engine),
+					logger.String("preprocessors", strings.Join(applied, ",")))
+			}
+			return result, nil
+		}
+
+		logger.Warn("compilation failed, trying the next engine",
+			logger.String("engine", engine),
+			logger.String("error", result.ErrorMsg))
+		if firstResult == nil {
+			firstResult, firstErr = result, err
+			// Keep the fixes the preferred engine's compilation wrote, for the fixer
+			firstContent, _ = os.ReadFile(texPath)
+			if firstContent == nil {
+				firstContent = []byte(content)
+			}
+		}
+	}
+
+	if firstResult == nil {
+		return c.cancelledResult("")
+	}
+	if err := os.WriteFile(texPath, firstContent, 0644); err != nil {
+		logger.Warn("failed to restore the preferred engine's document", logger.Err(err))
+	}
+	return firstResult, firstErr
+}
+
+// fallbackEngines returns the engines to try, the preferred one first
+func fallbackEngines(preferred string) []string {
+	engines := []string{preferred}
+	for _, engine := range fallbackOrder {
+		if engine != preferred {
+			engines = append(engines, engine)
+		}
+	}
+	return engines
+}
+
+// compileWithEngine compiles with the given engine through its Compile* method
+func (c *LaTeXCompiler) compileWithEngine(texPath, outputDir, engine string) (*types.CompileResult, error) {
+	switch engine {
+	case CompilerLuaLaTeX:
+		return c.CompileWithLuaLaTeXContext(c.context(), texPath, outputDir)
+	case CompilerPDFLaTeX:
+		return c.CompileWithPDFLaTeXContext(c.context(), texPath, outputDir)
+	default:
+		return c.CompileWithXeLaTeXContext(c.context(), texPath, outputDir)
+	}
+}
+
+// convertCJKutf8ToCtex replaces the CJKutf8 package and its CJK* environment with ctex, for
+// the Unicode engines
+func convertCJKutf8ToCtex(content string) (string, bool) {
+	if !hasUncommentedPackage(content, "CJKutf8") && !cjkBeginPattern.MatchString(uncommentedText(content)) {
+		return content, false
+	}
+	if !hasUncommentedPackage(content, "ctex") {
+		content, _ = insertAfterDocumentclass(content, "\\usepackage{ctex}")
+	}
+	content, _ = removeCJKutf8(content)
+	return content, true
+}
+
+// convertToCJKutf8 adapts a CJK document to pdfLaTeX: the packages and font setup that need
+// XeLaTeX or LuaLaTeX are commented out, the ctex classes become the standard ones and the
+// body is typeset in a CJK* environment of CJKutf8
+func convertToCJKutf8(content string) (string, bool) {
+	if !strings.Contains(content, "\\begin{document}") {
+		return content, false
+	}
+	font := cjkFontFor(content)
+	changed := false
+
+	if ctexClassPattern.MatchString(content) {
+		content = ctexClassPattern.ReplaceAllStringFunc(content, func(match string) string {
+			m := ctexClassPattern.FindStringSubmatch(match)
+			class := map[string]string{"art": "article", "rep": "report", "book": "book", "beamer": "beamer"}[m[2]]
+			return m[1] + class + "}"
+		})
+		changed = true
+	}
+
+	if fixed, disabled := disableUnicodePackages(content); disabled {
+		content = fixed
+		changed = true
+	}
+
+	if !hasUncommentedPackage(content, "CJKutf8") {
+		if fixed, added := insertAfterDocumentclass(content, "\\usepackage{CJKutf8}"); added {
+			content = fixed
+			changed = true
+		}
+	}
+	if !cjkBeginPattern.MatchString(uncommentedText(content)) {
+		content = strings.Replace(content, "\\begin{document}", "\\begin{document}\n\\begin{CJK*}{UTF8}{"+font+"}", 1)
+		if end := strings.LastIndex(content, "\\end{document}"); end >= 0 {
+			content = content[:end] + "\\end{CJK*}\n" + content[end:]
+		}
+		changed = true
+	}
+	if changed {
+		logger.Info("adapted CJK support for pdfLaTeX (CJKutf8)", logger.String("font", font))
+	}
+	return content, changed
+}
+
+// cjkFontFor returns the CJKutf8 font family for the language of a document: Japanese with
+// luatexja, Korean with kotex, traditional Chinese with its font setup, simplified Chinese
+// otherwise
+func cjkFontFor(content string) string {
+	switch {
+	case hasUncommentedPackage(content, "luatexja"):
+		return "min"
+	case hasUncommentedPackage(content, "kotex"):
+		return "mj"
+	case strings.Contains(content, "% Traditional Chinese fonts and names"):
+		return "bsmi"
+	default:
+		return "gbsn"
+	}
+}
@@ -0,0 +1,103 @@
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ctexDocument = "\\documentclass{article}\n" +
+	"\\usepackage{ctex}\n" +
+	"\\usepackage[T1]{fontenc}\n" +
+	"\\usepackage[utf8]{inputenc}\n" +
+	"\\usepackage{microtype}\n" +
+	"\\begin{document}\n" +
+	"中文\n" +
+	"\\end{document}\n"
+
+func TestPreprocessForXeLaTeX(t *testing.T) {
+	stubCJKFonts(t, CJKFontCheck{DefaultsUsable: true})
+	got, applied := PreprocessForEngine(ctexDocument, CompilerXeLaTeX)
+	if want := []string{"fontenc", "inputenc", "microtype"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	for _, s := range []string{
+		"% \\usepackage[T1]{fontenc} % disabled for XeLaTeX/LuaLaTeX",
+		"% \\usepackage[utf8]{inputenc} % disabled for XeLaTeX/LuaLaTeX",
+		"\\usepackage[protrusion=false,expansion=false]{microtype}",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("missing %q in\n%s", s, got)
+		}
+	}
+
+	// Adapting twice changes nothing more
+	if _, applied := PreprocessForEngine(got, CompilerXeLaTeX); applied != nil {
+		t.Errorf("second pass applied %v", applied)
+	}
+}
+
+func TestPreprocessForPDFLaTeX(t *testing.T) {
+	doc := strings.Replace(ctexDocument, "\\usepackage{microtype}", "\\setCJKmainfont{SimSun}", 1)
+	got, applied := PreprocessForEngine(doc, CompilerPDFLaTeX)
+	if want := []string{"cjkutf8"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	want := "\\documentclass{article}\n" +
+		"\\usepackage{CJKutf8}\n" +
+		"% \\usepackage{ctex} % disabled for pdfLaTeX\n" +
+		"\\usepackage[T1]{fontenc}\n" +
+		"\\usepackage[utf8]{inputenc}\n" +
+		"% \\setCJKmainfont{SimSun} % disabled for pdfLaTeX\n" +
+		"\\begin{document}\n" +
+		"\\begin{CJK*}{UTF8}{gbsn}\n" +
+		"中文\n" +
+		"\\end{CJK*}\n" +
+		"\\end{document}\n"
+	if got != want {
+		t.Errorf("PreprocessForEngine() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestConvertToCJKutf8Fonts(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{"\\documentclass{ctexart}\n\\begin{document}\n\\end{document}", "\\documentclass{article}\n\\usepackage{CJKutf8}\n\\begin{document}\n\\begin{CJK*}{UTF8}{gbsn}\n\\end{CJK*}\n\\end{document}"},
+		{"\\documentclass{article}\n\\usepackage{luatexja}\n\\begin{document}\n\\end{document}", "{UTF8}{min}"},
+		{"\\documentclass{article}\n\\usepackage{kotex}\n\\begin{document}\n\\end{document}", "{UTF8}{mj}"},
+	}
+	for _, tt := range tests {
+		if got, _ := convertToCJKutf8(tt.doc); !strings.Contains(got, tt.want) {
+			t.Errorf("convertToCJKutf8(%q) =\n%s\nwant to contain %q", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestConvertCJKutf8ToCtex(t *testing.T) {
+	doc := "\\documentclass{article}\n\\usepackage{CJKutf8}\n\\begin{document}\n\\begin{CJK*}{UTF8}{gbsn}\n中文\n\\end{CJK*}\n\\end{document}"
+	stubCJKFonts(t, CJKFontCheck{DefaultsUsable: true})
+	got, applied := PreprocessForEngine(doc, CompilerLuaLaTeX)
+	if !reflect.DeepEqual(applied, []string{"ctex"}) {
+		t.Errorf("applied = %v, want [ctex]", applied)
+	}
+	if !hasUncommentedPackage(got, "ctex") || hasUncommentedPackage(got, "CJKutf8") || cjkBeginPattern.MatchString(got) {
+		t.Errorf("CJKutf8 not replaced by ctex:\n%s", got)
+	}
+}
+
+func TestFallbackEngines(t *testing.T) {
+	tests := []struct {
+		preferred string
+		want      []string
+	}{
+		{CompilerXeLaTeX, []string{"xelatex", "lualatex", "pdflatex"}},
+		{CompilerLuaLaTeX, []string{"lualatex", "xelatex", "pdflatex"}},
+	}
+	for _, tt := range tests {
+		if got := fallbackEngines(tt.preferred); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fallbackEngines(%q) = %v, want %v", tt.preferred, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package compiler
+
+import "os/exec"
+
+// hideWindow does nothing on platforms without console windows
+func hideWindow(cmd *exec.Cmd) {}
+
+// killProcessTree kills a process; its children end with the closed pipes
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package compiler
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// hideWindow hides the console window of a LaTeX process on Windows
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+}
+
+// killProcessTree kills a process and its child processes (latexmk, xdvipdfmx, ...)
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+	hideWindow(kill)
+	if err := kill.Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package compiler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"latex-translator/internal/logger"
+)
+
+// FixReviewPolicy is the risk threshold above which an LLM-proposed fix is reviewed by the
+// user before it is applied
+type FixReviewPolicy struct {
+	MaxLinesTouched     int  `json:"max_lines_touched"`     // added plus removed lines
+	MaxNetDeletions     int  `json:"max_net_deletions"`     // removed minus added lines
+	ReviewProtectedEnvs bool `json:"review_protected_envs"` // review every fix that changes a protected environment
+}
+
+// DefaultFixReviewPolicy reviews fixes touching more than 30 lines, deleting more than 10
+// lines net or changing math, tables, figures, code or the bibliography
+var DefaultFixReviewPolicy = FixReviewPolicy{
+	MaxLinesTouched:     30,
+	MaxNetDeletions:     10,
+	ReviewProtectedEnvs: true,
+}
+
+// reviewProtectedEnvironments are environments whose content a compile fix should leave alone
+var reviewProtectedEnvironments = map[string]bool{
+	"equation": true, "equation*": true, "align": true, "align*": true, "gather": true, "gather*": true,
+	"multline": true, "multline*": true, "eqnarray": true, "eqnarray*": true,
+	"table": true, "table*": true, "tabular": true, "tabular*": true, "tabularx": true, "longtable": true,
+	"figure": true, "figure*": true, "tikzpicture": true, "algorithm": true, "algorithmic": true,
+	"verbatim": true, "lstlisting": true, "minted": true, "thebibliography": true,
+}
+
+// reviewEnvPattern matches \begin{env} and \end{env}
+var reviewEnvPattern = regexp.MustCompile(`\\(begin|end)\{([^}]+)\}`)
+
+// FixRisk is the risk of a proposed fix of one file
+type FixRisk struct {
+	LinesAdded    int      `json:"lines_added"`
+	LinesRemoved  int      `json:"lines_removed"`
+	LinesTouched  int      `json:"lines_touched"`
+	NetDeletions  int      `json:"net_deletions"`            // removed minus added lines, 0 when the fix grows the file
+	ProtectedEnvs []string `json:"protected_envs,omitempty"` // protected environments the fix changes
+}
+
+// Exceeds returns why the risk is above the policy's threshold, or nil when the fix can be
+// applied automatically
+func (r FixRisk) Exceeds(policy FixReviewPolicy) []string {
+	var reasons []string
+	if policy.MaxLinesTouched > 0 && r.LinesTouched > policy.MaxLinesTouched {
+		reasons = append(reasons, fmt.Sprintf("修改了 %d 行", r.LinesTouched))
+	}
+	if policy.MaxNetDeletions > 0 && r.NetDeletions > policy.MaxNetDeletions {
+		reasons = append(reasons, fmt.Sprintf("净删除 %d 行", r.NetDeletions))
+	}
+	if policy.ReviewProtectedEnvs && len(r.ProtectedEnvs) > 0 {
+		reasons = append(reasons, "修改了受保护的环境: "+strings.Join(r.ProtectedEnvs, ", "))
+	}
+	return reasons
+}
+
+// FixProposal is a risky fix of one file waiting for review
+type FixProposal struct {
+	ID          string   `json:"id"`
+	File        string   `json:"file"`
+	Level       FixLevel `json:"level"`
+	Description string   `json:"description"` // the fixer's description of the fix
+	Diff        string   `json:"diff"`        // unified diff of the file
+	Risk        FixRisk  `json:"risk"`
+	Reasons     []string `json:"reasons"` // why the fix needs review
+}
+
+// FixReviewer decides on a risky fix; it blocks until the user accepted (true) or rejected
+// (false) the proposal. A rejected fix is not applied and the fixer moves on to the next level.
+type FixReviewer func(proposal FixProposal) bool
+
+// fixProposalSeq numbers the fix proposals of this process
+var fixProposalSeq int64
+
+// SetFixReviewer sets the reviewer of fixes above the policy's threshold. Without a reviewer
+// every fix is applied automatically.
+func (f *LaTeXFixer) SetFixReviewer(reviewer FixReviewer, policy FixReviewPolicy) {
+	f.reviewer = reviewer
+	f.reviewPolicy = policy
+}
+
+// reviewFix reports whether a fix of a file may be applied: fixes below the risk threshold
+// pass, riskier ones are passed to the reviewer
+func (f *LaTeXFixer) reviewFix(level FixLevel, filePath, filename, fixedContent, description string) bool {
+	if f.reviewer == nil {
+		return true
+	}
+	before, err := os.ReadFile(filePath)
+	if err != nil {
+		return true
+	}
+	lines := diffLines(string(before), fixedContent)
+	risk := assessFixRisk(lines)
+	reasons := risk.Exceeds(f.reviewPolicy)
+	if len(reasons) == 0 {
+		return true
+	}
+
+	proposal := FixProposal{
+		ID:          fmt.Sprintf("fix-%d", atomic.AddInt64(&fixProposalSeq, 1)),
+		File:        filename,
+		Level:       level,
+		Description: description,
+		Diff:        formatUnifiedDiff(filename, lines),
+		Risk:        risk,
+		Reasons:     reasons,
+	}
+	logger.Info("risky fix waiting for review",
+		logger.String("id", proposal.ID),
+		logger.String("file", filename),
+		logger.Int("linesTouched", risk.LinesTouched),
+		logger.Int("netDeletions", risk.NetDeletions))
+
+	accepted := f.reviewer(proposal)
+	logger.Info("fix reviewed",
+		logger.String("id", proposal.ID),
+		logger.Bool("accepted", accepted))
+	return accepted
+}
+
+// applyReviewedFixes writes the fixes of one attempt at the given level that keep the helper
+// macros and pass the review, in file name order. It returns the files written and whether
+// the reviewer rejected a fix; rejected files are left unchanged and recorded in the history
+// under label.
+func (f *LaTeXFixer) applyReviewedFixes(level FixLevel, label, texDir string, fixes map[string]string, description string, result *HierarchicalFixResult) (applied []string, rejected bool) {
+	filenames := make([]string, 0, len(fixes))
+	for filename := range fixes {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		fixedContent := fixes[filename]
+		filePath := filepath.Join(texDir, filename)
+		if !keepsHelperMacros(filePath, filename, fixedContent) {
+			continue
+		}
+		if !f.reviewFix(level, filePath, filename, fixedContent, description) {
+			rejected = true
+			result.History = append(result.History, label+" rejected ("+filename+")")
+			continue
+		}
+		if err := os.WriteFile(filePath, []byte(fixedContent), 0644); err != nil {
+			logger.Warn("failed to write fixed file", logger.Err(err), logger.String("file", filename))
+			continue
+		}
+		result.FixedFiles[filename] = fixedContent
+		applied = append(applied, filename)
+	}
+	return applied, rejected
+}
+
+// AssessFixRisk returns the risk of replacing before with after
+func AssessFixRisk(before, after string) FixRisk {
+	return assessFixRisk(diffLines(before, after))
+}
+
+// UnifiedDiff returns the unified diff of a file changed from before to after
+func UnifiedDiff(filename, before, after string) string {
+	return formatUnifiedDiff(filename, diffLines(before, after))
+}
+
+// diffOp is the kind of a line of a diff
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+// diffLine is a line of a diff with its position in the old file (for deleted and equal
+// lines) or the old line it is inserted before
+type diffLine struct {
+	op      diffOp
+	text    string
+	oldLine int
+	newLine int
+}
+
+// maxDiffCells bounds the LCS table; larger changes are diffed as one replaced block
+const maxDiffCells = 4_000_000
+
+// diffLines returns the line diff of before and after
+func diffLines(before, after string) []diffLine {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	// Common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	var lines []diffLine
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, diffLine{diffEqual, a[i], i, i})
+	}
+
+	if len(midA)*len(midB) > maxDiffCells {
+		for i, text := range midA {
+			lines = append(lines, diffLine{diffDelete, text, prefix + i, prefix})
+		}
+		for j, text := range midB {
+			lines = append(lines, diffLine{diffInsert, text, prefix + len(midA), prefix + j})
+		}
+	} else {
+		// Longest common subsequence of the changed middle
+		n, m := len(midA), len(midB)
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && midA[i] == midB[j]:
+				lines = append(lines, diffLine{diffEqual, midA[i], prefix + i, prefix + j})
+				i++
+				j++
+			case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+				lines = append(lines, diffLine{diffInsert, midB[j], prefix + i, prefix + j})
+				j++
+			default:
+				lines = append(lines, diffLine{diffDelete, midA[i], prefix + i, prefix + j})
+				i++
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		i, j := len(a)-suffix+k, len(b)-suffix+k
+		lines = append(lines, diffLine{diffEqual, a[i], i, j})
+	}
+	return lines
+}
+
+// assessFixRisk counts the changed lines of a diff and the protected environments they are in
+func assessFixRisk(lines []diffLine) FixRisk {
+	var risk FixRisk
+	envs := make(map[string]bool)
+	var stack []string
+	for _, line := range lines {
+		if line.op != diffEqual {
+			if line.op == diffDelete {
+				risk.LinesRemoved++
+			} else {
+				risk.LinesAdded++
+			}
+			for _, env := range stack {
+				if reviewProtectedEnvironments[env] {
+					envs[env] = true
+				}
+			}
+		}
+		if line.op == diffInsert {
+			continue
+		}
+		// Track the environments of the old file; a changed \begin or \end line changes its environment
+		for _, m := range reviewEnvPattern.FindAllStringSubmatch(line.text, -1) {
+			if line.op == diffDelete && reviewProtectedEnvironments[m[2]] {
+				envs[m[2]] = true
+			}
+			if m[1] == "begin" {
+				stack = append(stack, m[2])
+			} else if len(stack) > 0 && stack[len(stack)-1] == m[2] {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	risk.LinesTouched = risk.LinesAdded + risk.LinesRemoved
+	if risk.LinesRemoved > risk.LinesAdded {
+		risk.NetDeletions = risk.LinesRemoved - risk.LinesAdded
+	}
+	for env := range envs {
+		risk.ProtectedEnvs = append(risk.ProtectedEnvs, env)
+	}
+	sort.Strings(risk.ProtectedEnvs)
+	return risk
+}
+
+// diffContext is the number of unchanged lines around each hunk of a unified diff
+const diffContext = 3
+
+// formatUnifiedDiff formats a line diff as a unified diff with three lines of context
+func formatUnifiedDiff(filename string, lines []diffLine) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", filename, filename)
+
+	for start := 0; start < len(lines); {
+		// Find the next change
+		first := start
+		for first < len(lines) && lines[first].op == diffEqual {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		// Extend the hunk while changes are within two contexts of each other
+		last := first
+		for k := first; k < len(lines); k++ {
+			if lines[k].op != diffEqual {
+				last = k
+			} else if k-last > 2*diffContext {
+				break
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(lines))
+
+		oldStart, newStart, oldCount, newCount := lines[from].oldLine+1, lines[from].newLine+1, 0, 0
+		for _, line := range lines[from:to] {
+			if line.op != diffInsert {
+				oldCount++
+			}
+			if line.op != diffDelete {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, line := range lines[from:to] {
+			sb.WriteByte(byte(line.op))
+			sb.WriteString(line.text)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
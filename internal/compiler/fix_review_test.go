@@ -0,0 +1,264 @@
+package compiler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns n lines "line 1" ... "line n"
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return lines
+}
+
+// fortyLineDeletion is a 60-line file and the same file with lines 11 to 50 deleted
+func fortyLineDeletion() (before, after string) {
+	lines := numberedLines(60)
+	return strings.Join(lines, "\n"), strings.Join(append(lines[:10:10], lines[50:]...), "\n")
+}
+
+func TestAssessFixRisk(t *testing.T) {
+	deleteBefore, deleteAfter := fortyLineDeletion()
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   FixRisk
+	}{
+		{
+			name:   "unchanged",
+			before: "a\nb\nc",
+			after:  "a\nb\nc",
+			want:   FixRisk{},
+		},
+		{
+			name:   "one line replaced",
+			before: "a\nb\nc",
+			after:  "a\nB\nc",
+			want:   FixRisk{LinesAdded: 1, LinesRemoved: 1, LinesTouched: 2},
+		},
+		{
+			name:   "lines added",
+			before: "a\nc",
+			after:  "a\nb1\nb2\nb3\nc",
+			want:   FixRisk{LinesAdded: 3, LinesTouched: 3},
+		},
+		{
+			name:   "more added than removed has no net deletions",
+			before: "a\nb\nc",
+			after:  "a\nx\ny\nz\nc",
+			want:   FixRisk{LinesAdded: 3, LinesRemoved: 1, LinesTouched: 4},
+		},
+		{
+			name:   "forty lines deleted",
+			before: deleteBefore,
+			after:  deleteAfter,
+			want:   FixRisk{LinesRemoved: 40, LinesTouched: 40, NetDeletions: 40},
+		},
+		{
+			name:   "edit inside equation",
+			before: "text\n\\begin{equation}\nx = 1\n\\end{equation}\nmore",
+			after:  "text\n\\begin{equation}\nx = 2\n\\end{equation}\nmore",
+			want:   FixRisk{LinesAdded: 1, LinesRemoved: 1, LinesTouched: 2, ProtectedEnvs: []string{"equation"}},
+		},
+		{
+			name:   "edit inside nested tabular",
+			before: "\\begin{table}\n\\begin{tabular}{cc}\na & b\n\\end{tabular}\n\\end{table}",
+			after:  "\\begin{table}\n\\begin{tabular}{cc}\na & c\n\\end{tabular}\n\\end{table}",
+			want:   FixRisk{LinesAdded: 1, LinesRemoved: 1, LinesTouched: 2, ProtectedEnvs: []string{"table", "tabular"}},
+		},
+		{
+			name:   "deleted table",
+			before: "a\n\\begin{table}\ncontent\n\\end{table}\nb",
+			after:  "a\nb",
+			want:   FixRisk{LinesRemoved: 3, LinesTouched: 3, NetDeletions: 3, ProtectedEnvs: []string{"table"}},
+		},
+		{
+			name:   "edit after a closed protected environment",
+			before: "\\begin{figure}\nx\n\\end{figure}\nold text",
+			after:  "\\begin{figure}\nx\n\\end{figure}\nnew text",
+			want:   FixRisk{LinesAdded: 1, LinesRemoved: 1, LinesTouched: 2},
+		},
+		{
+			name:   "edit inside an unprotected environment",
+			before: "\\begin{itemize}\n\\item a\n\\end{itemize}",
+			after:  "\\begin{itemize}\n\\item b\n\\end{itemize}",
+			want:   FixRisk{LinesAdded: 1, LinesRemoved: 1, LinesTouched: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AssessFixRisk(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssessFixRisk() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixRiskExceeds(t *testing.T) {
+	tests := []struct {
+		name   string
+		risk   FixRisk
+		policy FixReviewPolicy
+		want   []string
+	}{
+		{
+			name:   "below every threshold",
+			risk:   FixRisk{LinesTouched: 30, NetDeletions: 10},
+			policy: DefaultFixReviewPolicy,
+		},
+		{
+			name:   "too many lines touched",
+			risk:   FixRisk{LinesTouched: 31},
+			policy: DefaultFixReviewPolicy,
+			want:   []string{"修改了 31 行"},
+		},
+		{
+			name:   "forty lines deleted",
+			risk:   FixRisk{LinesRemoved: 40, LinesTouched: 40, NetDeletions: 40},
+			policy: DefaultFixReviewPolicy,
+			want:   []string{"修改了 40 行", "净删除 40 行"},
+		},
+		{
+			name:   "net deletions alone",
+			risk:   FixRisk{LinesTouched: 12, NetDeletions: 12},
+			policy: DefaultFixReviewPolicy,
+			want:   []string{"净删除 12 行"},
+		},
+		{
+			name:   "protected environments",
+			risk:   FixRisk{LinesTouched: 2, ProtectedEnvs: []string{"equation", "table"}},
+			policy: DefaultFixReviewPolicy,
+			want:   []string{"修改了受保护的环境: equation, table"},
+		},
+		{
+			name:   "protected environments not reviewed",
+			risk:   FixRisk{LinesTouched: 2, ProtectedEnvs: []string{"equation"}},
+			policy: FixReviewPolicy{MaxLinesTouched: 30, MaxNetDeletions: 10},
+		},
+		{
+			name:   "zero thresholds are disabled",
+			risk:   FixRisk{LinesTouched: 500, NetDeletions: 500},
+			policy: FixReviewPolicy{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.risk.Exceeds(tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exceeds() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyReviewedFixes(t *testing.T) {
+	deleteBefore, deleteAfter := fortyLineDeletion()
+	smallAfter := strings.Replace(deleteBefore, "line 5\n", "line five\n", 1)
+
+	tests := []struct {
+		name         string
+		reviewer     func(FixProposal) bool
+		fixed        string
+		wantReviewed bool
+		wantApplied  bool
+		wantHistory  []string
+	}{
+		{
+			name:        "no reviewer applies every fix",
+			fixed:       deleteAfter,
+			wantApplied: true,
+		},
+		{
+			name:        "fix below the threshold is not reviewed",
+			reviewer:    func(FixProposal) bool { return false },
+			fixed:       smallAfter,
+			wantApplied: true,
+		},
+		{
+			name:         "accepted risky fix is applied",
+			reviewer:     func(FixProposal) bool { return true },
+			fixed:        deleteAfter,
+			wantReviewed: true,
+			wantApplied:  true,
+		},
+		{
+			name:         "rejected risky fix leaves the file unchanged",
+			reviewer:     func(FixProposal) bool { return false },
+			fixed:        deleteAfter,
+			wantReviewed: true,
+			wantHistory:  []string{"llm rejected (main.tex)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			mainPath := filepath.Join(dir, "main.tex")
+			if err := os.WriteFile(mainPath, []byte(deleteBefore), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			fixer := NewLaTeXFixer("key", "", "")
+			var proposals []FixProposal
+			if tt.reviewer != nil {
+				fixer.SetFixReviewer(func(p FixProposal) bool {
+					proposals = append(proposals, p)
+					return tt.reviewer(p)
+				}, DefaultFixReviewPolicy)
+			}
+
+			result := &HierarchicalFixResult{FixedFiles: make(map[string]string)}
+			applied, rejected := fixer.applyReviewedFixes(FixLevelLLM, "llm", dir, map[string]string{"main.tex": tt.fixed}, "remove broken block", result)
+
+			if reviewed := len(proposals) > 0; reviewed != tt.wantReviewed {
+				t.Fatalf("reviewed = %v, want %v", reviewed, tt.wantReviewed)
+			}
+			if tt.wantReviewed {
+				p := proposals[0]
+				if p.File != "main.tex" || p.Level != FixLevelLLM || p.Description != "remove broken block" {
+					t.Errorf("unexpected proposal %+v", p)
+				}
+				if p.Risk.NetDeletions != 40 || !strings.Contains(p.Diff, "-line 11\n") || len(p.Reasons) == 0 {
+					t.Errorf("proposal does not describe the deletion: risk %+v, reasons %q\n%s", p.Risk, p.Reasons, p.Diff)
+				}
+			}
+
+			content, err := os.ReadFile(mainPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := deleteBefore
+			if tt.wantApplied {
+				want = tt.fixed
+			}
+			if string(content) != want {
+				t.Errorf("main.tex content does not match (applied = %v)", tt.wantApplied)
+			}
+			if (len(applied) == 1) != tt.wantApplied || rejected == tt.wantApplied {
+				t.Errorf("applied = %q, rejected = %v", applied, rejected)
+			}
+			if _, ok := result.FixedFiles["main.tex"]; ok != tt.wantApplied {
+				t.Errorf("FixedFiles recorded = %v, want %v", ok, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(result.History, tt.wantHistory) {
+				t.Errorf("History = %q, want %q", result.History, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := strings.Join(numberedLines(10), "\n")
+	after := strings.Replace(before, "line 5", "line five", 1)
+	want := "--- a/main.tex\n+++ b/main.tex\n" +
+		"@@ -2,7 +2,7 @@\n line 2\n line 3\n line 4\n-line 5\n+line five\n line 6\n line 7\n line 8\n"
+	if got := UnifiedDiff("main.tex", before, after); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -2,12 +2,9 @@
 package compiler
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -37,11 +34,30 @@ type LaTeXFixer struct {
 	apiURL       string
 	model        string
 	agentModel   string // Model for agent-level fixes (more capable)
-	client       *http.Client
+	provider     string // LLM provider of the settings (types.ProviderOpenAI by default)
+	timeout      time.Duration
+	clients      map[string]translator.LLMClient // LLM clients by model, created on first use
 	maxRetries   int
-	enableAgent  bool // Whether to enable agent-level fixes
+	enableAgent  bool                   // Whether to enable agent-level fixes
+	ctx          context.Context        // Cancels the remaining fix attempts (nil means never)
+	repairer     LaTeXRepairer          // Repair API used by the LLM level (nil uses the built-in JSON prompt)
+	maxLevel     FixLevel               // Highest fix level tried by HierarchicalFixCompilationErrors
+	reviewer     FixReviewer            // Reviews fixes above reviewPolicy (nil applies every fix)
+	reviewPolicy FixReviewPolicy        // Risk threshold of reviewed fixes
+	usage        *translator.UsageMeter // Tokens of the fix requests are added to it (nil: not metered)
+	originalLog  string                 // Log of the successful compile of the original document (see SetOriginalLog)
 }
 
+// LaTeXRepairer repairs LaTeX files for the LLM fix level (implemented by
+// translator.TranslationEngine)
+type LaTeXRepairer interface {
+	RepairLaTeX(request translator.RepairRequest) (*translator.RepairResult, error)
+}
+
+// maxRepairFileSize is the file size above which the LLM level sends only the sections
+// around the errors
+const maxRepairFileSize = 15000
+
 // NewLaTeXFixer creates a new LaTeXFixer instance.
 func NewLaTeXFixer(apiKey, apiURL, model string) *LaTeXFixer {
 	if apiURL == "" {
@@ -57,11 +73,11 @@ func NewLaTeXFixer(apiKey, apiURL, model string) *LaTeXFixer {
 		apiURL:      apiURL,
 		model:       model,
 		agentModel:  model, // Default to same model, can be overridden
-		client: &http.Client{
-			Timeout: 180 * time.Second, // Longer timeout for agent fixes
-		},
+		provider:    types.ProviderOpenAI,
+		timeout:     180 * time.Second, // Longer timeout for agent fixes
 		maxRetries:  3,
 		enableAgent: true, // Enable agent fixes by default
+		maxLevel:    FixLevelAgent,
 	}
 }
 
@@ -80,11 +96,115 @@ func (f *LaTeXFixer) SetAgentModel(model string) {
 	f.agentModel = model
 }
 
+// SetProvider sets the LLM provider of the fix requests. The tool-calling agents speak the
+// OpenAI function calling API, so other providers skip them for the prompt-based agent.
+func (f *LaTeXFixer) SetProvider(provider string) {
+	f.provider = types.NormalizeProvider(provider)
+	f.clients = nil
+}
+
+// complete sends a fix prompt to model through the LLM client of the provider
+func (f *LaTeXFixer) complete(model, systemPrompt, userPrompt string) (string, error) {
+	client, ok := f.clients[model]
+	if !ok {
+		client = translator.NewLLMClient(f.provider, f.apiKey, model, f.apiURL, f.timeout)
+		if f.clients == nil {
+			f.clients = make(map[string]translator.LLMClient)
+		}
+		f.clients[model] = client
+	}
+
+	content, usage, err := client.Complete(f.fixContext(), translator.Prompt{
+		System:    systemPrompt,
+		Messages:  []translator.Message{{Role: "user", Content: userPrompt}},
+		MaxTokens: 8192, // More tokens for comprehensive fixes
+	})
+	if err != nil {
+		return "", fmt.Errorf("API request failed: %w", err)
+	}
+	f.usage.Add(model, usage)
+	return content, nil
+}
+
 // SetEnableAgent enables or disables agent-level fixes.
 func (f *LaTeXFixer) SetEnableAgent(enable bool) {
 	f.enableAgent = enable
 }
 
+// SetMaxFixLevel sets the highest level tried by HierarchicalFixCompilationErrors;
+// FixLevelRule limits the fixes to the fast rule-based ones without any LLM call.
+func (f *LaTeXFixer) SetMaxFixLevel(level FixLevel) {
+	f.maxLevel = level
+}
+
+// SetRepairer sets the repair API used by the LLM fix level. Each file with errors is then
+// repaired with its own request, validated for structure instead of parsed from JSON.
+func (f *LaTeXFixer) SetRepairer(repairer LaTeXRepairer) {
+	f.repairer = repairer
+}
+
+// SetUsageMeter sets the meter the tokens of the fix requests, agents included, are added
+// to; nil stops the metering
+func (f *LaTeXFixer) SetUsageMeter(meter *translator.UsageMeter) {
+	f.usage = meter
+}
+
+// SetOriginalLog sets the log of the successful compile of the original document. The LLM
+// and agent prompts then get only the errors new in the translated build (see
+// CompareCompileLogs), so the model does not try to fix what was already broken upstream.
+func (f *LaTeXFixer) SetOriginalLog(log string) {
+	f.originalLog = log
+}
+
+// promptErrors returns the errors of currentLog to send to the LLM: the errors new in the
+// translated build when the original log is known, all errors when there is none or when
+// every error was already in the original build
+func (f *LaTeXFixer) promptErrors(currentLog string, errors []types.CompileError) []types.CompileError {
+	if f.originalLog == "" {
+		return errors
+	}
+	novel := NovelErrors(CompareCompileLogs(f.originalLog, currentLog))
+	if len(novel) == 0 {
+		return errors
+	}
+	if len(novel) < len(errors) {
+		logger.Info("sending only errors new in the translated build",
+			logger.Int("errors", len(errors)),
+			logger.Int("novel", len(novel)))
+	}
+	return novel
+}
+
+// SetContext sets a context that stops the fix loop when cancelled.
+// The loop then returns its best-so-far result with Aborted set, leaving the
+// partially fixed files on disk for manual editing.
+func (f *LaTeXFixer) SetContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+// fixContext returns the context of the fix loop
+func (f *LaTeXFixer) fixContext() context.Context {
+	if f.ctx == nil {
+		return context.Background()
+	}
+	return f.ctx
+}
+
+// aborted reports whether the remaining fixes have been skipped
+func (f *LaTeXFixer) aborted() bool {
+	return f.ctx != nil && f.ctx.Err() != nil
+}
+
+// abortResult marks result as aborted by the user
+func abortResult(result *HierarchicalFixResult, currentLog string) (*HierarchicalFixResult, error) {
+	logger.Info("fix loop aborted, remaining fixes skipped",
+		logger.Int("totalIterations", result.TotalIterations))
+	result.Aborted = true
+	result.Description = "已跳过剩余修复"
+	result.LastCompileLog = currentLog
+	return result, nil
+}
+
 // FixResult represents the result of a fix attempt.
 type FixResult struct {
 	Success     bool              `json:"success"`
@@ -104,6 +224,9 @@ type HierarchicalFixResult struct {
 	LLMFixAttempts   int               `json:"llm_fix_attempts"`
 	AgentFixAttempts int               `json:"agent_fix_attempts"`
 	FinalFixLevel    FixLevel          `json:"final_fix_level"`
+	Aborted          bool              `json:"aborted,omitempty"`          // remaining fixes were skipped (see SetContext)
+	LastCompileLog   string            `json:"last_compile_log,omitempty"` // latest compile log when aborted
+	History          []string          `json:"history,omitempty"`          // fixes applied in order, e.g. "rule:unicode-declarations (main.tex)"
 }
 
 // FixCompilationErrors attempts to fix LaTeX compilation errors using LLM.
@@ -133,7 +256,7 @@ func (f *LaTeXFixer) FixCompilationErrors(
 		logger.Info("fix iteration", logger.Int("iteration", i+1))
 
 		// Parse errors from log
-		errors := parseLatexErrors(currentLog)
+		errors := ParseLogErrors(currentLog)
 		if len(errors) == 0 {
 			logger.Info("no errors found in log, compilation may have succeeded")
 			result.Success = true
@@ -163,7 +286,7 @@ func (f *LaTeXFixer) FixCompilationErrors(
 		}
 
 		// Ask LLM to fix the errors
-		fixes, description, err := f.askLLMToFix(errors, fileContents)
+		fixes, description, err := f.askLLMToFix(mainTexFile, errors, fileContents)
 		if err != nil {
 			logger.Error("LLM fix request failed", err)
 			return result, err
@@ -178,6 +301,12 @@ func (f *LaTeXFixer) FixCompilationErrors(
 		// Apply fixes
 		for filename, fixedContent := range fixes {
 			filePath := filepath.Join(texDir, filename)
+			if !keepsHelperMacros(filePath, filename, fixedContent) {
+				continue
+			}
+			if !f.reviewFix(FixLevelLLM, filePath, filename, fixedContent, description) {
+				continue
+			}
 			if err := os.WriteFile(filePath, []byte(fixedContent), 0644); err != nil {
 				logger.Error("failed to write fixed file", err, logger.String("file", filename))
 				continue
@@ -246,8 +375,28 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 	}
 	currentContent := string(mainContent)
 
+	// ============ Level 0: Log-signature rules ============
+	// Error classes recognized by their log signature are fixed without the LLM or agent
+	if f.applyLogFixRules(texDir, mainTexFile, currentLog, result) {
+		if fixed, ok := result.FixedFiles[mainTexFile]; ok {
+			currentContent = fixed
+		}
+		compileResult, compileErr := compiler.Compile(mainTexPath, outputDir)
+		if compileErr == nil && compileResult.Success {
+			logger.Info("compilation succeeded after log-signature rules",
+				logger.String("history", strings.Join(result.History, ", ")))
+			result.Success = true
+			result.Description = "规则修复成功"
+			result.FinalFixLevel = FixLevelRule
+			return result, nil
+		}
+		if compileResult != nil {
+			currentLog = compileResult.Log
+		}
+	}
+
 	// Analyze error complexity to decide strategy
-	errors := parseLatexErrors(currentLog)
+	errors := ParseLogErrors(currentLog)
 	errorComplexity := analyzeErrorComplexity(errors, currentLog)
 	
 	logger.Info("error analysis",
@@ -271,6 +420,9 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 	}
 
 	for attempt := 1; attempt <= 2; attempt++ {
+		if f.aborted() {
+			return abortResult(result, currentLog)
+		}
 		result.RuleFixAttempts++
 		result.TotalIterations++
 
@@ -282,6 +434,7 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 				continue
 			}
 			result.FixedFiles[mainTexFile] = currentContent
+			result.History = append(result.History, "rule:quick-fix ("+mainTexFile+")")
 			logger.Info("applied rule-based fixes", logger.Int("attempt", attempt))
 
 			// Try to compile
@@ -300,6 +453,13 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 		}
 	}
 
+	if f.maxLevel < FixLevelLLM {
+		logger.Info("LLM fixes disabled, stopping at rule level")
+		result.Description = "规则修复未能解决所有问题，LLM 修复已禁用"
+		result.LastCompileLog = currentLog
+		return result, nil
+	}
+
 	// ============ Level 2: Simple LLM fixes ============
 	logger.Info("attempting Level 2: Simple LLM fixes")
 	if progressCallback != nil {
@@ -307,6 +467,9 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 	}
 
 	for attempt := 1; attempt <= f.maxRetries; attempt++ {
+		if f.aborted() {
+			return abortResult(result, currentLog)
+		}
 		result.LLMFixAttempts++
 		result.TotalIterations++
 
@@ -315,7 +478,7 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 		}
 
 		// Parse errors from log
-		errors := parseLatexErrors(currentLog)
+		errors := ParseLogErrors(currentLog)
 		if len(errors) == 0 {
 			logger.Info("no errors found in log after LLM fix")
 			result.Success = true
@@ -325,10 +488,11 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 		}
 
 		// Get file contents for fixing
+		errors = f.promptErrors(currentLog, errors)
 		fileContents := f.collectFileContents(texDir, mainTexFile, errors)
 
 		// Ask LLM to fix
-		fixes, description, err := f.askLLMToFix(errors, fileContents)
+		fixes, description, err := f.askLLMToFix(mainTexFile, errors, fileContents)
 		if err != nil {
 			logger.Warn("LLM fix request failed", logger.Err(err))
 			continue
@@ -340,19 +504,21 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 		}
 
 		// Apply fixes
-		for filename, fixedContent := range fixes {
-			filePath := filepath.Join(texDir, filename)
-			if err := os.WriteFile(filePath, []byte(fixedContent), 0644); err != nil {
-				logger.Warn("failed to write fixed file", logger.Err(err), logger.String("file", filename))
-				continue
-			}
-			result.FixedFiles[filename] = fixedContent
+		applied, rejected := f.applyReviewedFixes(FixLevelLLM, "llm", texDir, fixes, description, result)
+		for _, filename := range applied {
+			result.History = append(result.History, "llm ("+filename+")")
 			if filename == mainTexFile {
-				currentContent = fixedContent
+				currentContent = fixes[filename]
 			}
 		}
 		result.Description = description
 
+		// A rejected fix falls through to the agent level, which takes a different approach
+		if rejected && len(applied) == 0 {
+			logger.Info("LLM fix rejected in review, moving on to agent fixes")
+			break
+		}
+
 		// Try to compile
 		compileResult, compileErr := compiler.Compile(mainTexPath, outputDir)
 		if compileErr == nil && compileResult.Success {
@@ -362,9 +528,16 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 			return result, nil
 		}
 		currentLog = compileResult.Log
+		if rejected {
+			logger.Info("LLM fix partly rejected in review, moving on to agent fixes")
+			break
+		}
 	}
 
 	// ============ Level 3: Agent-based fixes ============
+	if f.aborted() {
+		return abortResult(result, currentLog)
+	}
 	if !f.enableAgent {
 		logger.Info("agent fixes disabled, stopping at LLM level")
 		result.Description = "LLM 修复未能解决所有问题，Agent 修复已禁用"
@@ -376,7 +549,7 @@ func (f *LaTeXFixer) HierarchicalFixCompilationErrors(
 }
 
 // collectFileContents collects file contents for the files mentioned in errors.
-func (f *LaTeXFixer) collectFileContents(texDir, mainTexFile string, errors []LaTeXError) map[string]string {
+func (f *LaTeXFixer) collectFileContents(texDir, mainTexFile string, errors []types.CompileError) map[string]string {
 	fileContents := make(map[string]string)
 
 	for _, err := range errors {
@@ -453,7 +626,7 @@ func (f *LaTeXFixer) collectAllRelatedFiles(texDir, mainTexFile string) map[stri
 
 // askAgentToFix uses a more sophisticated prompt for complex error fixing.
 // It provides comprehensive context and asks for detailed analysis.
-func (f *LaTeXFixer) askAgentToFix(errors []LaTeXError, fileContents map[string]string, compileLog string) (map[string]string, string, error) {
+func (f *LaTeXFixer) askAgentToFix(errors []types.CompileError, fileContents map[string]string, compileLog string) (map[string]string, string, error) {
 	systemPrompt := `You are an expert LaTeX debugging agent. Your task is to analyze and fix complex LaTeX compilation errors that simpler fixes couldn't resolve.
 
 ANALYSIS APPROACH:
@@ -533,64 +706,14 @@ If you cannot fix the error, return:
 	userPromptBuilder.WriteString("\n\nPlease provide your analysis and fixes in JSON format.")
 
 	// Make API request with agent model
-	reqBody := map[string]interface{}{
-		"model": f.agentModel,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userPromptBuilder.String()},
-		},
-		"max_tokens": 8192, // More tokens for comprehensive fixes
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, f.apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+f.apiKey)
-
 	logger.Info("sending agent fix request", logger.String("model", f.agentModel))
 
-	resp, err := f.client.Do(req)
-	if err != nil {
-		return nil, "", fmt.Errorf("API request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	content, err := f.complete(f.agentModel, systemPrompt, userPromptBuilder.String())
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var apiResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, "", fmt.Errorf("failed to parse API response: %w", err)
-	}
-
-	if len(apiResp.Choices) == 0 {
-		return nil, "", fmt.Errorf("API returned no choices")
+		return nil, "", err
 	}
 
 	// Extract JSON from response
-	content := apiResp.Choices[0].Message.Content
 	content = extractJSON(content)
 
 	// Parse the agent fix response
@@ -673,72 +796,12 @@ func min(a, b int) int {
 	return b
 }
 
-// LaTeXError represents a parsed LaTeX error.
-type LaTeXError struct {
-	File    string `json:"file"`
-	Line    int    `json:"line"`
-	Message string `json:"message"`
-	Context string `json:"context"` // surrounding lines
-}
-
-// parseLatexErrors extracts error information from a LaTeX log.
-func parseLatexErrors(log string) []LaTeXError {
-	var errors []LaTeXError
-
-	// Pattern for LaTeX errors: ! Error message
-	errorPattern := regexp.MustCompile(`(?m)^!\s*(.+)$`)
-	// Pattern for file:line info: l.123 or (./file.tex
-	linePattern := regexp.MustCompile(`(?m)^l\.(\d+)\s*(.*)$`)
-	filePattern := regexp.MustCompile(`\(\.?/?([^()]+\.tex)`)
-
-	lines := strings.Split(log, "\n")
-	var currentError *LaTeXError
-	var currentFile string
-
-	for i, line := range lines {
-		// Check for file references
-		if matches := filePattern.FindStringSubmatch(line); len(matches) > 1 {
-			currentFile = matches[1]
-		}
-
-		// Check for error start
-		if matches := errorPattern.FindStringSubmatch(line); len(matches) > 1 {
-			if currentError != nil {
-				errors = append(errors, *currentError)
-			}
-			currentError = &LaTeXError{
-				File:    currentFile,
-				Message: matches[1],
-			}
-			// Get context (surrounding lines)
-			start := i - 2
-			if start < 0 {
-				start = 0
-			}
-			end := i + 5
-			if end > len(lines) {
-				end = len(lines)
-			}
-			currentError.Context = strings.Join(lines[start:end], "\n")
-		}
-
-		// Check for line number
-		if currentError != nil {
-			if matches := linePattern.FindStringSubmatch(line); len(matches) > 1 {
-				fmt.Sscanf(matches[1], "%d", &currentError.Line)
-			}
-		}
-	}
-
-	if currentError != nil {
-		errors = append(errors, *currentError)
+// askLLMToFix sends the errors and file contents to LLM and gets fixes.
+func (f *LaTeXFixer) askLLMToFix(mainTexFile string, errors []types.CompileError, fileContents map[string]string) (map[string]string, string, error) {
+	if f.repairer != nil {
+		return f.askRepairerToFix(mainTexFile, errors, fileContents)
 	}
 
-	return errors
-}
-
-// askLLMToFix sends the errors and file contents to LLM and gets fixes.
-func (f *LaTeXFixer) askLLMToFix(errors []LaTeXError, fileContents map[string]string) (map[string]string, string, error) {
 	// Build the prompt
 	systemPrompt := `You are a LaTeX expert. Your task is to fix LaTeX compilation errors.
 
@@ -799,62 +862,12 @@ If you cannot fix the error, return:
 	userPromptBuilder.WriteString("\n\nPlease provide the fixed file contents in JSON format.")
 
 	// Make API request
-	reqBody := map[string]interface{}{
-		"model": f.model,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userPromptBuilder.String()},
-		},
-		"max_tokens": 8192,
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, f.apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+f.apiKey)
-
-	resp, err := f.client.Do(req)
+	content, err := f.complete(f.model, systemPrompt, userPromptBuilder.String())
 	if err != nil {
-		return nil, "", fmt.Errorf("API request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var apiResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, "", fmt.Errorf("failed to parse API response: %w", err)
-	}
-
-	if len(apiResp.Choices) == 0 {
-		return nil, "", fmt.Errorf("API returned no choices")
+		return nil, "", err
 	}
 
 	// Extract JSON from response (it might be wrapped in markdown code blocks)
-	content := apiResp.Choices[0].Message.Content
 	content = extractJSON(content)
 
 	// Parse the fix response
@@ -871,6 +884,74 @@ If you cannot fix the error, return:
 	return fixResp.Fixes, fixResp.Description, nil
 }
 
+// askRepairerToFix repairs each file with errors through the repair API. Files larger than
+// maxRepairFileSize are repaired by sections around the error lines. Errors without a known
+// file are attributed to the main file, or to the only file collected.
+func (f *LaTeXFixer) askRepairerToFix(mainTexFile string, errors []types.CompileError, fileContents map[string]string) (map[string]string, string, error) {
+	fallback := mainTexFile
+	if _, ok := fileContents[fallback]; !ok && len(fileContents) == 1 {
+		for filename := range fileContents {
+			fallback = filename
+		}
+	}
+
+	byFile := make(map[string][]types.CompileError)
+	for _, e := range errors {
+		file := e.File
+		if _, ok := fileContents[file]; !ok {
+			file = fallback
+		}
+		if _, ok := fileContents[file]; ok {
+			byFile[file] = append(byFile[file], e)
+		}
+	}
+
+	fixes := make(map[string]string)
+	var described []string
+	var lastErr error
+	for filename, fileErrors := range byFile {
+		content := fileContents[filename]
+		request := translator.RepairRequest{Content: content}
+		var errorLines []int
+		var contexts []string
+		for _, e := range fileErrors {
+			request.Errors = append(request.Errors, fmt.Sprintf("Line %d: %s", e.Line, e.Message))
+			if e.Line > 0 {
+				errorLines = append(errorLines, e.Line)
+			}
+			if e.Context != "" {
+				contexts = append(contexts, e.Context)
+			}
+		}
+		request.LogExcerpt = strings.Join(contexts, "\n")
+		if len(content) > maxRepairFileSize && len(errorLines) > 0 {
+			request.Content = ""
+			request.Sections = translator.RepairSectionsAround(content, errorLines, 30)
+		}
+
+		result, err := f.repairer.RepairLaTeX(request)
+		if err != nil {
+			logger.Warn("LaTeX repair failed", logger.String("file", filename), logger.Err(err))
+			lastErr = err
+			continue
+		}
+
+		fixed := result.Content
+		if len(request.Sections) > 0 {
+			fixed = translator.MergeRepairSections(content, result.Sections)
+		}
+		if fixed != content {
+			fixes[filename] = fixed
+			described = append(described, fmt.Sprintf("%s (%d 处错误)", filename, len(fileErrors)))
+		}
+	}
+
+	if len(fixes) == 0 && lastErr != nil {
+		return nil, "", lastErr
+	}
+	return fixes, "LLM 修复: " + strings.Join(described, ", "), nil
+}
+
 // extractJSON extracts JSON from a string that might be wrapped in markdown code blocks.
 func extractJSON(content string) string {
 	// Try to find JSON in code blocks
@@ -1869,7 +1950,7 @@ func PreprocessTexFiles(dir string) error {
 
 // analyzeErrorComplexity analyzes the complexity of LaTeX errors to determine fix strategy.
 // Returns "low", "medium", or "high" complexity.
-func analyzeErrorComplexity(errors []LaTeXError, compileLog string) string {
+func analyzeErrorComplexity(errors []types.CompileError, compileLog string) string {
 	if len(errors) == 0 {
 		return "low"
 	}
@@ -1936,80 +2017,93 @@ func (f *LaTeXFixer) runAgentFix(
 ) (*HierarchicalFixResult, error) {
 	mainTexPath := filepath.Join(texDir, mainTexFile)
 
-	// ============ Agent-based fixes ============
-	logger.Info("attempting Agent-based fixes (eino ReAct agent)")
-	if progressCallback != nil {
-		progressCallback(FixLevelAgent, 1, "使用 Eino Agent 智能修复...")
-	}
+	// The eino and tool-calling agents speak the OpenAI function calling API
+	if f.provider == types.ProviderOpenAI {
+		// ============ Agent-based fixes ============
+		logger.Info("attempting Agent-based fixes (eino ReAct agent)")
+		if progressCallback != nil {
+			progressCallback(FixLevelAgent, 1, "使用 Eino Agent 智能修复...")
+		}
 
-	// Try eino agent first (more sophisticated)
-	einoFixer := NewEinoAgentFixer(f.apiKey, f.apiURL, f.agentModel)
-	ctx := context.Background()
+		// Try eino agent first (more sophisticated)
+		einoFixer := NewEinoAgentFixer(f.apiKey, f.apiURL, f.agentModel)
+		einoFixer.usage = f.usage
+		ctx := f.fixContext()
 	
-	einoResult, einoErr := einoFixer.FixWithEinoAgent(
-		ctx,
-		texDir,
-		mainTexFile,
-		compileLog,
-		compiler,
-		outputDir,
-		func(step int, message string) {
-			result.AgentFixAttempts = step
-			result.TotalIterations++
-			if progressCallback != nil {
-				progressCallback(FixLevelAgent, step, message)
+		einoResult, einoErr := einoFixer.FixWithEinoAgent(
+			ctx,
+			texDir,
+			mainTexFile,
+			compileLog,
+			compiler,
+			outputDir,
+			func(step int, message string) {
+				result.AgentFixAttempts = step
+				result.TotalIterations++
+				if progressCallback != nil {
+					progressCallback(FixLevelAgent, step, message)
+				}
+			},
+		)
+
+		if einoErr != nil {
+			logger.Warn("Eino agent fix failed", logger.Err(einoErr))
+		} else if einoResult != nil && einoResult.Success {
+			logger.Info("compilation succeeded after Eino agent fix", logger.String("summary", einoResult.Summary))
+			result.Success = true
+			result.Description = einoResult.Summary
+			result.FinalFixLevel = FixLevelAgent
+			for filename, content := range einoResult.FixedFiles {
+				result.FixedFiles[filename] = content
+				result.History = append(result.History, "agent ("+filename+")")
 			}
-		},
-	)
-
-	if einoErr != nil {
-		logger.Warn("Eino agent fix failed", logger.Err(einoErr))
-	} else if einoResult != nil && einoResult.Success {
-		logger.Info("compilation succeeded after Eino agent fix", logger.String("summary", einoResult.Summary))
-		result.Success = true
-		result.Description = einoResult.Summary
-		result.FinalFixLevel = FixLevelAgent
-		for filename, content := range einoResult.FixedFiles {
-			result.FixedFiles[filename] = content
+			return result, nil
 		}
-		return result, nil
-	}
 
-	// Fallback to tool-calling agent if eino agent fails
-	logger.Info("eino agent did not succeed, trying tool-calling agent")
-	if progressCallback != nil {
-		progressCallback(FixLevelAgent, 2, "尝试备用 Agent 修复...")
-	}
+		if f.aborted() {
+			return abortResult(result, compileLog)
+		}
 
-	agentFixer := NewLaTeXAgentFixer(f.apiKey, f.apiURL, f.agentModel)
+		// Fallback to tool-calling agent if eino agent fails
+		logger.Info("eino agent did not succeed, trying tool-calling agent")
+		if progressCallback != nil {
+			progressCallback(FixLevelAgent, 2, "尝试备用 Agent 修复...")
+		}
+
+		agentFixer := NewLaTeXAgentFixer(f.apiKey, f.apiURL, f.agentModel)
+		agentFixer.usage = f.usage
 	
-	agentResult, agentErr := agentFixer.FixWithAgent(
-		ctx,
-		texDir,
-		mainTexFile,
-		compileLog,
-		compiler,
-		outputDir,
-		func(step int, message string) {
-			result.AgentFixAttempts++
-			result.TotalIterations++
-			if progressCallback != nil {
-				progressCallback(FixLevelAgent, step+10, message)
+		agentResult, agentErr := agentFixer.FixWithAgent(
+			ctx,
+			texDir,
+			mainTexFile,
+			compileLog,
+			compiler,
+			outputDir,
+			func(step int, message string) {
+				result.AgentFixAttempts++
+				result.TotalIterations++
+				if progressCallback != nil {
+					progressCallback(FixLevelAgent, step+10, message)
+				}
+			},
+		)
+
+		if agentErr != nil {
+			logger.Warn("Tool-calling agent fix failed", logger.Err(agentErr))
+		} else if agentResult != nil && agentResult.Success {
+			logger.Info("compilation succeeded after tool-calling agent fix", logger.String("summary", agentResult.Summary))
+			result.Success = true
+			result.Description = agentResult.Summary
+			result.FinalFixLevel = FixLevelAgent
+			for filename, content := range agentResult.FixedFiles {
+				result.FixedFiles[filename] = content
 			}
-		},
-	)
-
-	if agentErr != nil {
-		logger.Warn("Tool-calling agent fix failed", logger.Err(agentErr))
-	} else if agentResult != nil && agentResult.Success {
-		logger.Info("compilation succeeded after tool-calling agent fix", logger.String("summary", agentResult.Summary))
-		result.Success = true
-		result.Description = agentResult.Summary
-		result.FinalFixLevel = FixLevelAgent
-		for filename, content := range agentResult.FixedFiles {
-			result.FixedFiles[filename] = content
+			return result, nil
 		}
-		return result, nil
+	} else {
+		logger.Info("tool-calling agents need an OpenAI compatible API, using the prompt-based agent",
+			logger.String("provider", f.provider))
 	}
 
 	// Final fallback to prompt-based agent
@@ -2017,6 +2111,9 @@ func (f *LaTeXFixer) runAgentFix(
 	currentLog := compileLog
 	
 	for attempt := 1; attempt <= 2; attempt++ {
+		if f.aborted() {
+			return abortResult(result, currentLog)
+		}
 		result.AgentFixAttempts++
 		result.TotalIterations++
 
@@ -2025,7 +2122,7 @@ func (f *LaTeXFixer) runAgentFix(
 		}
 
 		// Parse errors from log
-		errors := parseLatexErrors(currentLog)
+		errors := ParseLogErrors(currentLog)
 		if len(errors) == 0 {
 			logger.Info("no errors found after agent fix")
 			result.Success = true
@@ -2038,7 +2135,7 @@ func (f *LaTeXFixer) runAgentFix(
 		allFiles := f.collectAllRelatedFiles(texDir, mainTexFile)
 
 		// Ask Agent to fix with comprehensive context
-		fixes, description, err := f.askAgentToFix(errors, allFiles, currentLog)
+		fixes, description, err := f.askAgentToFix(f.promptErrors(currentLog, errors), allFiles, currentLog)
 		if err != nil {
 			logger.Warn("Agent fix request failed", logger.Err(err))
 			continue
@@ -2050,14 +2147,7 @@ func (f *LaTeXFixer) runAgentFix(
 		}
 
 		// Apply fixes
-		for filename, fixedContent := range fixes {
-			filePath := filepath.Join(texDir, filename)
-			if err := os.WriteFile(filePath, []byte(fixedContent), 0644); err != nil {
-				logger.Warn("failed to write agent-fixed file", logger.Err(err), logger.String("file", filename))
-				continue
-			}
-			result.FixedFiles[filename] = fixedContent
-		}
+		f.applyReviewedFixes(FixLevelAgent, "agent", texDir, fixes, description, result)
 		result.Description = description
 
 		// Try to compile
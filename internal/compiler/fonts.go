@@ -0,0 +1,257 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+)
+
+// cjkFontCandidate is a Chinese font ctex can be set to, under its fontconfig family names
+// and the file names it is installed as
+type cjkFontCandidate struct {
+	Family   string   // family passed to \setCJKmainfont
+	Families []string // family names fc-list reports for it
+	Files    []string // font files, looked up in the Windows font folder and the TeX tree
+}
+
+// cjkFontCandidates are the fonts chosen from, in priority order
+var cjkFontCandidates = []cjkFontCandidate{
+	{Family: "Noto Sans CJK SC", Families: []string{"Noto Sans CJK SC"}, Files: []string{"NotoSansCJK-Regular.ttc", "NotoSansCJKsc-Regular.otf"}},
+	{Family: "Source Han Sans SC", Families: []string{"Source Han Sans SC", "Source Han Sans CN"}, Files: []string{"SourceHanSans-Regular.ttc", "SourceHanSansSC-Regular.otf", "SourceHanSansCN-Regular.otf"}},
+	{Family: "SimSun", Families: []string{"SimSun", "宋体"}, Files: []string{"simsun.ttc"}},
+	{Family: "FandolSong", Families: []string{"FandolSong"}, Files: []string{CJKFontFallbackFile}},
+}
+
+// CJKFontFallbackFile is the font of ctex's Linux fontset, loaded by file name from the TeX
+// tree when none of the fonts is found by family
+const CJKFontFallbackFile = "FandolSong-Regular.otf"
+
+// fontsetOptionPattern matches a ctex fontset option, e.g. "fontset=ubuntu"
+var fontsetOptionPattern = regexp.MustCompile(`\bfontset\s*=`)
+
+// ctexPackageLinePattern matches the line loading ctex, with its options
+var ctexPackageLinePattern = regexp.MustCompile(`\\usepackage(?:\[([^\]]*)\])?\{ctex\}`)
+
+// CJKFontCheck is the result of probing the Chinese fonts XeLaTeX can use with ctex
+type CJKFontCheck struct {
+	Fonts          []string // installed candidates, in priority order
+	DefaultsUsable bool     // the fonts of ctex's default fontset for this system are installed
+}
+
+// Usable reports whether ctex can typeset Chinese, with its defaults or a selected font
+func (c CJKFontCheck) Usable() bool {
+	return c.DefaultsUsable || len(c.Fonts) > 0
+}
+
+// Best returns the font selected when the ctex defaults are not installed, or ""
+func (c CJKFontCheck) Best() string {
+	if len(c.Fonts) == 0 {
+		return ""
+	}
+	return c.Fonts[0]
+}
+
+// CJKFontPriority returns the families of the Chinese fonts chosen from, in priority order
+func CJKFontPriority() []string {
+	families := make([]string, len(cjkFontCandidates))
+	for i, c := range cjkFontCandidates {
+		families[i] = c.Family
+	}
+	return families
+}
+
+// cjkFontProbe probes the installed fonts; replaced in tests
+var cjkFontProbe = CheckCJKFonts
+
+// CheckCJKFonts finds the installed Chinese fonts with fc-list, the Windows font folder and
+// kpsewhich (for fonts shipped with the TeX distribution, like Fandol), and whether the
+// default ctex fontset of this system can be loaded: Fandol on Linux, SimSun on Windows.
+// The macOS fonts of ctex ship with the system.
+func CheckCJKFonts() CJKFontCheck {
+	families := installedFontFamilies()
+	var files []string
+	for _, c := range cjkFontCandidates {
+		files = append(files, c.Files...)
+	}
+	texFiles := kpsewhichFiles(files)
+
+	var check CJKFontCheck
+	for _, c := range cjkFontCandidates {
+		installed := false
+		for _, family := range c.Families {
+			installed = installed || families[family]
+		}
+		for _, file := range c.Files {
+			installed = installed || texFiles[file] || windowsFontInstalled(file)
+		}
+		if installed {
+			check.Fonts = append(check.Fonts, c.Family)
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		check.DefaultsUsable = true
+	case "windows":
+		check.DefaultsUsable = containsString(check.Fonts, "SimSun")
+	default:
+		check.DefaultsUsable = texFiles[CJKFontFallbackFile]
+	}
+	logger.Debug("Chinese fonts checked",
+		logger.String("fonts", strings.Join(check.Fonts, ",")),
+		logger.Bool("defaultsUsable", check.DefaultsUsable))
+	return check
+}
+
+// CJKFontInstallSuggestions returns the commands or steps installing a Chinese font on this
+// system, for the error of a check that found none
+func CJKFontInstallSuggestions() []string {
+	switch runtime.GOOS {
+	case "windows":
+		return []string{
+			"MiKTeX: mpm --install=fandol",
+			"TeX Live: tlmgr install fandol",
+			"或在 Windows 设置中添加中文语言包（安装宋体 SimSun）",
+		}
+	default:
+		return []string{
+			"Debian/Ubuntu: sudo apt install fonts-noto-cjk",
+			"Fedora: sudo dnf install google-noto-sans-cjk-fonts",
+			"Alpine: apk add font-noto-cjk",
+			"TeX Live: tlmgr install fandol",
+		}
+	}
+}
+
+// selectCJKFont sets ctex to an installed Chinese font when the fonts of its default fontset
+// are not installed, which otherwise fails with "font not found" or typesets empty boxes.
+// Documents choosing a fontset or setting the font themselves are left unchanged.
+func selectCJKFont(content string) (string, bool) {
+	if !hasUncommentedPackage(content, "ctex") || strings.Contains(content, "\\setCJKmainfont{") {
+		return content, false
+	}
+	check := cjkFontProbe()
+	font := check.Best()
+	if check.DefaultsUsable || font == "" {
+		return content, false
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "%") {
+			continue
+		}
+		m := ctexPackageLinePattern.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		options := ""
+		if m[2] >= 0 {
+			options = line[m[2]:m[3]]
+		}
+		if fontsetOptionPattern.MatchString(options) {
+			return content, false
+		}
+		if options != "" {
+			options += ","
+		}
+		options += "fontset=none"
+		setup := "\\setCJKmainfont{" + font + "}\\setCJKsansfont{" + font + "}\\setCJKmonofont{" + font + "}"
+		lines[i] = line[:m[0]] + "\\usepackage[" + options + "]{ctex}" + line[m[1]:]
+		lines = append(lines[:i+1], append([]string{setup}, lines[i+1:]...)...)
+		logger.Info("ctex default fonts not installed, selected an installed Chinese font", logger.String("font", font))
+		return strings.Join(lines, "\n"), true
+	}
+	return content, false
+}
+
+// installedFontFamilies returns the families of the Chinese fonts fontconfig knows, which
+// is where XeLaTeX looks fonts up by name. Empty when fc-list is not installed.
+func installedFontFamilies() map[string]bool {
+	families := make(map[string]bool)
+	if _, err := exec.LookPath("fc-list"); err != nil {
+		logger.Debug("fc-list not installed, skipping fontconfig font check")
+		return families
+	}
+	ctx, cancel := context.WithTimeout(processCtx, 30*time.Second)
+	defer cancel()
+	cmd := commandContext(ctx, "fc-list", ":lang=zh", "family")
+	hideWindow(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		logger.Warn("fc-list failed", logger.Err(err))
+		return families
+	}
+	return parseFontFamilies(string(output))
+}
+
+// parseFontFamilies parses the "family" output of fc-list: one font per line, with its
+// family names separated by commas
+func parseFontFamilies(output string) map[string]bool {
+	families := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		for _, family := range strings.Split(line, ",") {
+			if family = strings.TrimSpace(strings.ReplaceAll(family, `\-`, "-")); family != "" {
+				families[family] = true
+			}
+		}
+	}
+	return families
+}
+
+// kpsewhichFiles looks up font files in the TeX tree with a single kpsewhich run and returns
+// the ones found
+func kpsewhichFiles(files []string) map[string]bool {
+	found := make(map[string]bool)
+	if _, err := exec.LookPath("kpsewhich"); err != nil {
+		return found
+	}
+	ctx, cancel := context.WithTimeout(processCtx, 30*time.Second)
+	defer cancel()
+	cmd := commandContext(ctx, "kpsewhich", files...)
+	hideWindow(cmd)
+	output, err := cmd.Output()
+	// kpsewhich exits with 1 when a file is not found; the found ones are still printed
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		logger.Warn("kpsewhich failed, skipping TeX font check", logger.Err(err))
+		return found
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			found[filepath.Base(line)] = true
+		}
+	}
+	return found
+}
+
+// windowsFontInstalled reports whether the font file is in the Windows font folder
+func windowsFontInstalled(file string) bool {
+	if runtime.GOOS != "windows" {
+		return false
+	}
+	windir := os.Getenv("WINDIR")
+	if windir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(windir, "Fonts", file))
+	return err == nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
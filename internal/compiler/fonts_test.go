@@ -0,0 +1,82 @@
+package compiler
+
+import "testing"
+
+// stubCJKFonts makes the font probe return check for the duration of the test
+func stubCJKFonts(t *testing.T, check CJKFontCheck) {
+	t.Helper()
+	probe := cjkFontProbe
+	cjkFontProbe = func() CJKFontCheck { return check }
+	t.Cleanup(func() { cjkFontProbe = probe })
+}
+
+func TestParseFontFamilies(t *testing.T) {
+	output := "Noto Sans CJK SC,Noto Sans CJK SC Regular\n" +
+		"FandolSong\n" +
+		"Source Han Sans CN,Source Han Sans CN Medium\n" +
+		"WenQuanYi Zen Hei,文泉驿正黑\n"
+	got := parseFontFamilies(output)
+	for _, family := range []string{"Noto Sans CJK SC", "FandolSong", "Source Han Sans CN", "文泉驿正黑"} {
+		if !got[family] {
+			t.Errorf("family %q not parsed from %v", family, got)
+		}
+	}
+}
+
+func TestSelectCJKFont(t *testing.T) {
+	doc := "\\documentclass{article}\n\\usepackage[UTF8]{ctex}\n\\begin{document}\n\\end{document}"
+	tests := []struct {
+		name  string
+		check CJKFontCheck
+		doc   string
+		want  string
+	}{
+		{
+			name:  "defaults installed",
+			check: CJKFontCheck{Fonts: []string{"Noto Sans CJK SC"}, DefaultsUsable: true},
+			doc:   doc,
+			want:  doc,
+		},
+		{
+			name:  "defaults missing",
+			check: CJKFontCheck{Fonts: []string{"Noto Sans CJK SC", "SimSun"}},
+			doc:   doc,
+			want: "\\documentclass{article}\n\\usepackage[UTF8,fontset=none]{ctex}\n" +
+				"\\setCJKmainfont{Noto Sans CJK SC}\\setCJKsansfont{Noto Sans CJK SC}\\setCJKmonofont{Noto Sans CJK SC}\n" +
+				"\\begin{document}\n\\end{document}",
+		},
+		{
+			name:  "no font installed",
+			check: CJKFontCheck{},
+			doc:   doc,
+			want:  doc,
+		},
+		{
+			name:  "fontset chosen by the document",
+			check: CJKFontCheck{Fonts: []string{"SimSun"}},
+			doc:   "\\documentclass{article}\n\\usepackage[fontset=ubuntu]{ctex}\n",
+			want:  "\\documentclass{article}\n\\usepackage[fontset=ubuntu]{ctex}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubCJKFonts(t, tt.check)
+			if got, _ := selectCJKFont(tt.doc); got != tt.want {
+				t.Errorf("selectCJKFont() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCJKFontCheck(t *testing.T) {
+	check := CJKFontCheck{Fonts: []string{"SimSun", "FandolSong"}}
+	if !check.Usable() || check.Best() != "SimSun" {
+		t.Errorf("Usable() = %v, Best() = %q", check.Usable(), check.Best())
+	}
+	if (CJKFontCheck{}).Usable() {
+		t.Error("check without fonts usable")
+	}
+	if got := CJKFontInstallSuggestions(); len(got) == 0 {
+		t.Errorf("no install suggestions: %v", got)
+	}
+}
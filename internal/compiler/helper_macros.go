@@ -0,0 +1,43 @@
+package compiler
+
+import (
+	"os"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// generatorHelperMacros are helper macros and code environments of generated sources
+// (pandoc, knitr, Sweave). They look like noise to a fixer, but the document needs them:
+// a fix that removes them is rejected.
+var generatorHelperMacros = []string{
+	`\tightlist`, `\hypertarget`, `\hyperlink`, `\passthrough`, `\pandocbounded`,
+	`\begin{Shaded}`, `\begin{Highlighting}`, `\begin{knitrout}`, `\begin{kframe}`, `\begin{Schunk}`,
+}
+
+// RemovedHelperMacros returns the generator helper macros that occur fewer times in the
+// fixed content than before the fix
+func RemovedHelperMacros(before, after string) []string {
+	var removed []string
+	for _, macro := range generatorHelperMacros {
+		if strings.Count(after, macro) < strings.Count(before, macro) {
+			removed = append(removed, macro)
+		}
+	}
+	return removed
+}
+
+// keepsHelperMacros reports whether a fix of a file keeps its generator helper macros
+func keepsHelperMacros(filePath, filename, fixedContent string) bool {
+	before, err := os.ReadFile(filePath)
+	if err != nil {
+		return true
+	}
+	if removed := RemovedHelperMacros(string(before), fixedContent); len(removed) > 0 {
+		logger.Warn("rejected fix that removes generator helper macros",
+			logger.String("file", filename),
+			logger.String("macros", strings.Join(removed, ", ")))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+var (
+	// includeOnlyPattern matches \includeonly with its list of files
+	includeOnlyPattern = regexp.MustCompile(`\\includeonly\s*\{([^}]*)\}`)
+	// includeFilePattern matches the files pulled in with \include (not \includegraphics)
+	includeFilePattern = regexp.MustCompile(`\\include\s*\{([^}]*)\}`)
+)
+
+// includeName normalizes a file name of \include or \includeonly as LaTeX resolves it:
+// relative to the main file, with the .tex extension implied
+func includeName(name string) string {
+	name = path.Clean(filepath.ToSlash(strings.TrimSpace(name)))
+	return strings.TrimPrefix(strings.TrimSuffix(name, ".tex"), "./")
+}
+
+// DetectIncludeOnly finds an \includeonly directive in the content of a main tex file,
+// ignoring comments. The excluded files are those pulled in with \include but not listed
+// by the directive, as paths relative to the main file with the .tex extension. Returns nil
+// when the file has no \includeonly.
+func DetectIncludeOnly(content string) *types.IncludeOnlyInfo {
+	text := uncommentedText(content)
+	loc := includeOnlyPattern.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil
+	}
+	info := &types.IncludeOnlyInfo{Directive: content[loc[0]:loc[1]]}
+	listed := make(map[string]bool)
+	for _, name := range strings.Split(text[loc[2]:loc[3]], ",") {
+		if name = includeName(name); name != "" && name != "." {
+			info.Included = append(info.Included, name)
+			listed[name] = true
+		}
+	}
+	seen := make(map[string]bool)
+	for _, m := range includeFilePattern.FindAllStringSubmatch(text, -1) {
+		name := includeName(m[1])
+		if name == "" || listed[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		info.Excluded = append(info.Excluded, name+".tex")
+	}
+	return info
+}
+
+// FormatIncludeOnly describes how the \includeonly directive of a document was handled
+func FormatIncludeOnly(info *types.IncludeOnlyInfo) string {
+	if info.Stripped {
+		return fmt.Sprintf("主文件使用了 %s，已移除该指令，原文和译文都编译完整文档", info.Directive)
+	}
+	msg := fmt.Sprintf("主文件使用了 %s，原文和译文都只编译其中列出的文件", info.Directive)
+	if len(info.Excluded) > 0 {
+		msg += fmt.Sprintf("，未翻译被排除的 %d 个文件: %s", len(info.Excluded), strings.Join(info.Excluded, "、"))
+	}
+	return msg + "（在设置中勾选忽略 \\includeonly 或使用 --ignore-includeonly 可翻译完整文档）"
+}
+
+// StripIncludeOnly comments out the \includeonly directive of a main tex file so the whole
+// document is compiled. Returns whether the file was changed.
+func StripIncludeOnly(mainTexPath string) (bool, error) {
+	data, err := os.ReadFile(mainTexPath)
+	if err != nil {
+		return false, err
+	}
+	content := string(data)
+	loc := includeOnlyPattern.FindStringIndex(uncommentedText(content))
+	if loc == nil {
+		return false, nil
+	}
+	// The rest of the line stays active on a line of its own
+	directive := content[loc[0]:loc[1]]
+	lineStart := strings.LastIndex(content[:loc[0]], "\n") + 1
+	prefix := content[lineStart:loc[0]]
+	replacement := "% " + directive + " % 已移除，翻译并编译全部文件\n"
+	if strings.TrimSpace(prefix) != "" {
+		replacement = "\n" + replacement
+	}
+	rest := strings.TrimPrefix(strings.TrimLeft(content[loc[1]:], " \t"), "\n")
+	content = content[:loc[0]] + replacement + rest
+	if err := os.WriteFile(mainTexPath, []byte(content), 0644); err != nil {
+		return false, err
+	}
+	logger.Info("stripped \\includeonly", logger.String("path", mainTexPath), logger.String("directive", directive))
+	return true, nil
+}
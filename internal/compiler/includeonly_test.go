@@ -0,0 +1,66 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const includeOnlyMain = `\documentclass{book}
+% \includeonly{chapters/ch1}
+\includeonly{chapters/ch3, ./appendix.tex}
+\begin{document}
+\include{chapters/ch1}
+\include{chapters/ch2}
+\include{chapters/ch3}
+\include{appendix}
+\includegraphics{figure}
+% \include{chapters/old}
+\end{document}
+`
+
+func TestDetectIncludeOnly(t *testing.T) {
+	info := DetectIncludeOnly(includeOnlyMain)
+	if info == nil {
+		t.Fatal("DetectIncludeOnly() = nil")
+	}
+	if info.Directive != `\includeonly{chapters/ch3, ./appendix.tex}` {
+		t.Errorf("Directive = %q", info.Directive)
+	}
+	if want := []string{"chapters/ch3", "appendix"}; !reflect.DeepEqual(info.Included, want) {
+		t.Errorf("Included = %v, want %v", info.Included, want)
+	}
+	if want := []string{"chapters/ch1.tex", "chapters/ch2.tex"}; !reflect.DeepEqual(info.Excluded, want) {
+		t.Errorf("Excluded = %v, want %v", info.Excluded, want)
+	}
+
+	if info := DetectIncludeOnly("% \\includeonly{ch1}\n\\include{ch1}\n"); info != nil {
+		t.Errorf("commented directive detected: %+v", info)
+	}
+}
+
+func TestStripIncludeOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.tex")
+	content := "\\documentclass{book}\n\\includeonly{ch3}\\usepackage{x}\n\\begin{document}\n\\include{ch3}\n\\end{document}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := StripIncludeOnly(path)
+	if err != nil || !changed {
+		t.Fatalf("StripIncludeOnly() = %v, %v", changed, err)
+	}
+	data, _ := os.ReadFile(path)
+	stripped := string(data)
+	if DetectIncludeOnly(stripped) != nil {
+		t.Errorf("directive still active:\n%s", stripped)
+	}
+	if !strings.Contains(stripped, "% \\includeonly{ch3}") || !strings.Contains(stripped, "\n\\usepackage{x}\n\\begin{document}") {
+		t.Errorf("unexpected result:\n%s", stripped)
+	}
+
+	if changed, err := StripIncludeOnly(path); err != nil || changed {
+		t.Errorf("second StripIncludeOnly() = %v, %v", changed, err)
+	}
+}
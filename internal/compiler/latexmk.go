@@ -0,0 +1,179 @@
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"latex-translator/internal/compiler/compilelimit"
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// latexmkRunPattern matches the line latexmk prints before each run of a rule, e.g.
+// "Run number 2 of rule 'pdflatex'"
+var latexmkRunPattern = regexp.MustCompile(`Run number \d+ of rule '([^']+)'`)
+
+var (
+	latexmkOnce      sync.Once
+	latexmkAvailable bool
+)
+
+// LatexmkInstalled reports whether latexmk is on the PATH. It is looked up once; the
+// application probes it at startup together with the LaTeX compilers.
+func LatexmkInstalled() bool {
+	latexmkOnce.Do(func() {
+		_, err := exec.LookPath("latexmk")
+		latexmkAvailable = err == nil
+		logger.Debug("latexmk lookup", logger.Bool("installed", latexmkAvailable))
+	})
+	return latexmkAvailable
+}
+
+// SetUseLatexmk sets whether documents are compiled with latexmk, which runs biber,
+// bibtex, makeindex and the engine as many times as the document needs. Without latexmk
+// installed, and in single-pass mode, the manual pass sequence is used either way.
+func (c *LaTeXCompiler) SetUseLatexmk(use bool) {
+	c.latexmk = use
+}
+
+// latexmkEnabled reports whether the passes of a compilation are left to latexmk
+func (c *LaTeXCompiler) latexmkEnabled() bool {
+	if !c.latexmk || c.passLimit() == 1 {
+		return false
+	}
+	if !LatexmkInstalled() {
+		logger.Debug("latexmk not installed, using manual compilation passes")
+		return false
+	}
+	return true
+}
+
+// CompileWithLatexmk compiles a tex file like Compile, with latexmk running the engine
+// selected for the document (-pdf, -xelatex or -lualatex) and the bibliography and index
+// tools. It falls back to the manual pass sequence when latexmk is not installed.
+func (c *LaTeXCompiler) CompileWithLatexmk(texPath string, outputDir string) (*types.CompileResult, error) {
+	logger.Info("compiling with latexmk", logger.String("texPath", texPath))
+	withLatexmk := *c
+	withLatexmk.latexmk = true
+	return withLatexmk.Compile(texPath, outputDir)
+}
+
+// runLatexmk compiles a document with latexmk. skipBibliography keeps latexmk from running
+// bibtex or biber, for documents whose .bbl was inlined or shipped. latexmk runs again when
+// the installer installed packages it reported missing. Returns the number of engine runs,
+// the log of the last one (from the .log file) and the extended logs.
+func (c *LaTeXCompiler) runLatexmk(compiler, texFileName, texBaseName, texDir, outputDir string, skipBibliography bool, installer *packageInstaller, allLogs []string) (int, string, []string) {
+	args := buildLatexmkArgs(compiler, texFileName, outputDir, texDir, skipBibliography)
+	for _, arg := range c.installerArgs() {
+		args = append([]string{"-latexoption=" + arg}, args...)
+	}
+
+	passes := 0
+	var log string
+	for {
+		log = c.execLatexmk(texFileName, texDir, outputDir, args)
+		allLogs = append(allLogs, "=== Latexmk ===", log)
+		passes += countLatexmkPasses(log)
+
+		var installed bool
+		if installed, allLogs = installer.installFromLog(log, allLogs); !installed || c.context().Err() != nil {
+			break
+		}
+	}
+
+	lastPassLog := log
+	if engineLog, err := os.ReadFile(filepath.Join(outputDir, texBaseName+".log")); err == nil {
+		lastPassLog = string(engineLog)
+	}
+	logger.Debug("latexmk finished", logger.Int("passes", passes))
+	return passes, lastPassLog, allLogs
+}
+
+// execLatexmk runs latexmk once and returns its output
+func (c *LaTeXCompiler) execLatexmk(texFileName, texDir, outputDir string, args []string) string {
+	// Wait for a slot before starting the timeout, queueing is not part of the compile time
+	release, err := compilelimit.Acquire(c.context(), "latexmk "+texFileName)
+	if err != nil {
+		return ""
+	}
+	defer release()
+
+	// One latexmk run holds all passes, each of which may take the compile timeout
+	ctx, cancel := processContext(c.context(), c.timeout*MaxCompilePasses)
+	defer cancel()
+
+	cmd := commandContext(ctx, "latexmk", args...)
+	cmd.Dir = texDir
+
+	// TEXINPUTS as for the manual passes; bibtex and biber look for .bib and .bst files next
+	// to the source, they run in the output directory
+	pathSep := ":"
+	if runtime.GOOS == "windows" {
+		pathSep = ";"
+	}
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("TEXINPUTS=.%s%s%s", pathSep, texDir, pathSep),
+		fmt.Sprintf("BIBINPUTS=%s%s%s%s", texDir, pathSep, outputDir, pathSep),
+		fmt.Sprintf("BSTINPUTS=%s%s%s%s", texDir, pathSep, outputDir, pathSep))
+	cmd.Env = append(cmd.Env, c.installerEnv()...)
+
+	// Hide console window on Windows
+	hideWindow(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	log := combineOutput(stdout.String(), stderr.String())
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Warn("latexmk timed out", logger.String("texFile", texFileName))
+	} else if err != nil {
+		// Like the manual passes, errors do not stop the compilation - -f makes latexmk go on
+		logger.Warn("latexmk had errors, continuing", logger.Err(err))
+	}
+	return log
+}
+
+// buildLatexmkArgs builds the command line arguments of latexmk for the given engine
+func buildLatexmkArgs(compiler string, texFileName string, outputDir string, texDir string, skipBibliography bool) []string {
+	var args []string
+	switch compiler {
+	case CompilerXeLaTeX:
+		args = append(args, "-xelatex")
+	case CompilerLuaLaTeX:
+		args = append(args, "-lualatex")
+	default:
+		args = append(args, "-pdf")
+	}
+	// -f goes on after errors, as the manual passes do: documents with non-fatal errors
+	// still produce a PDF
+	args = append(args, "-interaction=nonstopmode", "-f")
+	if skipBibliography {
+		args = append(args, "-bibtex-")
+	}
+	if outputDir != "" && outputDir != texDir {
+		args = append(args, "-outdir="+outputDir)
+	}
+	return append(args, texFileName)
+}
+
+// countLatexmkPasses returns the number of LaTeX engine runs in a latexmk log; runs of
+// bibtex, biber and makeindex are not counted
+func countLatexmkPasses(log string) int {
+	passes := 0
+	for _, m := range latexmkRunPattern.FindAllStringSubmatch(log, -1) {
+		if strings.HasSuffix(m[1], "latex") {
+			passes++
+		}
+	}
+	return passes
+}
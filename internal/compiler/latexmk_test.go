@@ -0,0 +1,62 @@
+package compiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildLatexmkArgs(t *testing.T) {
+	tests := []struct {
+		compiler, outputDir string
+		skipBibliography    bool
+		want                []string
+	}{
+		{CompilerPDFLaTeX, "/src", false, []string{"-pdf", "-interaction=nonstopmode", "-f", "main.tex"}},
+		{CompilerXeLaTeX, "/out", false, []string{"-xelatex", "-interaction=nonstopmode", "-f", "-outdir=/out", "main.tex"}},
+		{CompilerLuaLaTeX, "/src", true, []string{"-lualatex", "-interaction=nonstopmode", "-f", "-bibtex-", "main.tex"}},
+	}
+	for _, tt := range tests {
+		if got := buildLatexmkArgs(tt.compiler, "main.tex", tt.outputDir, "/src", tt.skipBibliography); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("buildLatexmkArgs(%s) = %v, want %v", tt.compiler, got, tt.want)
+		}
+	}
+}
+
+func TestCountLatexmkPasses(t *testing.T) {
+	log := `Rc files read:
+  NONE
+Latexmk: This is Latexmk, John Collins, 27 Dec. 2022. Version 4.79.
+------------
+Run number 1 of rule 'xelatex'
+------------
+This is XeTeX, Version 3.141592653-2.6-0.999995 (TeX Live 2023)
+------------
+Run number 1 of rule 'biber main'
+------------
+------------
+Run number 1 of rule 'makeindex main.idx'
+------------
+------------
+Run number 2 of rule 'xelatex'
+------------
+Latexmk: All targets (main.pdf) are up-to-date`
+	if got := countLatexmkPasses(log); got != 2 {
+		t.Errorf("countLatexmkPasses() = %d, want 2", got)
+	}
+}
+
+func TestLatexmkEnabled(t *testing.T) {
+	c := NewLaTeXCompiler("", "", 0)
+	if c.latexmkEnabled() {
+		t.Error("latexmk enabled by default")
+	}
+	c.SetUseLatexmk(true)
+	c.SetMaxPasses(1)
+	if c.latexmkEnabled() {
+		t.Error("latexmk enabled in single-pass mode")
+	}
+	c.SetMaxPasses(0)
+	if c.latexmkEnabled() != LatexmkInstalled() {
+		t.Error("latexmk enabled without being installed")
+	}
+}
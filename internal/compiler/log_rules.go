@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// LogFixRule fixes an error class recognized by its signature in the compile log, without
+// asking the LLM
+type LogFixRule struct {
+	Name      string
+	Signature *regexp.Regexp
+	Apply     func(content string) (string, bool)
+}
+
+// logFixRules are the rules tried before the other fix levels, in order
+var logFixRules = []LogFixRule{
+	{
+		// inputenc declarations under XeLaTeX, or declarations that clash with ctex
+		Name:      "unicode-declarations",
+		Signature: regexp.MustCompile(`Package inputenc Error|Package newunicodechar Error|(?m)^l\.\d+.*\\DeclareUnicodeCharacter`),
+		Apply:     NeutralizeUnicodeDeclarations,
+	},
+	{
+		// babel shorthands or a \catcode change acting on quotes around Chinese text
+		Name:      "active-quotes",
+		Signature: regexp.MustCompile(`active@prefix|language@active@arg|\\active@char`),
+		Apply:     ReplaceQuotedChinese,
+	},
+}
+
+// ApplyLogFixRules applies the rules whose signature occurs in the compile log to the
+// content of a file. It returns the fixed content and the names of the rules that changed it.
+func ApplyLogFixRules(content, compileLog string) (string, []string) {
+	var applied []string
+	for _, rule := range logFixRules {
+		if !rule.Signature.MatchString(compileLog) {
+			continue
+		}
+		if fixed, changed := rule.Apply(content); changed {
+			content = fixed
+			applied = append(applied, rule.Name)
+		}
+	}
+	return content, applied
+}
+
+// applyLogFixRules applies the log-signature rules to the main file and the files it
+// includes, recording each change in the fix history. It reports whether a file changed.
+func (f *LaTeXFixer) applyLogFixRules(texDir, mainTexFile, compileLog string, result *HierarchicalFixResult) bool {
+	matched := false
+	for _, rule := range logFixRules {
+		if rule.Signature.MatchString(compileLog) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	files := f.collectAllRelatedFiles(texDir, mainTexFile)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		fixed, applied := ApplyLogFixRules(files[name], compileLog)
+		if len(applied) == 0 {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(texDir, name), []byte(fixed), 0644); err != nil {
+			logger.Warn("failed to save log-rule fixed file", logger.Err(err), logger.String("file", name))
+			continue
+		}
+		result.FixedFiles[name] = fixed
+		for _, rule := range applied {
+			result.History = append(result.History, "rule:"+rule+" ("+name+")")
+		}
+		result.RuleFixAttempts++
+		changed = true
+		logger.Info("applied log-signature rules",
+			logger.String("file", name),
+			logger.String("rules", strings.Join(applied, ", ")))
+	}
+	if changed {
+		result.TotalIterations++
+	}
+	return changed
+}
@@ -0,0 +1,169 @@
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/types"
+)
+
+// LogEntryClass is the kind of a log entry, compared along with its message by
+// CompareCompileLogs
+type LogEntryClass string
+
+const (
+	LogClassUndefinedCommand LogEntryClass = "undefined-command" // Undefined control sequence, unknown environment
+	LogClassMissingFile      LogEntryClass = "missing-file"      // File not found (packages, images, inputs)
+	LogClassReference        LogEntryClass = "reference"         // Undefined or multiply defined references, citations and labels
+	LogClassFont             LogEntryClass = "font"              // Missing fonts and characters
+	LogClassError            LogEntryClass = "error"             // Other errors
+	LogClassWarning          LogEntryClass = "warning"           // Other warnings
+)
+
+// translatedFilePrefix is the prefix of the translated main tex file
+const translatedFilePrefix = "translated_"
+
+var (
+	// logPathPattern matches absolute and relative paths in log messages; they differ between
+	// the build directories of the original and translated documents
+	logPathPattern = regexp.MustCompile(`(^|[\s'"` + "`" + `(])((?:[A-Za-z]:[/\\]|\.{0,2}/)[^\s'"` + "`" + `(){}]+)`)
+	// logPagePattern matches page numbers, which shift as the translation changes the layout
+	logPagePattern = regexp.MustCompile(`\b(page|pages)\s+\d+`)
+	// logInputLinePattern matches source line numbers, which shift with the translated text
+	logInputLinePattern = regexp.MustCompile(`\b(line|lines)\s+\d+(?:--\d+)?`)
+	// logTimingPattern matches durations, e.g. "(0.12s)" or "12 ms"
+	logTimingPattern = regexp.MustCompile(`\b\d+(?:\.\d+)?\s*(?:ms|s|sec|seconds)\b`)
+	// logSpacePattern matches runs of white space
+	logSpacePattern = regexp.MustCompile(`\s+`)
+)
+
+// LogDiffGroup holds the entries new in the translated build for one file
+type LogDiffGroup struct {
+	File    string               `json:"file"` // file of the translated build, empty when unknown
+	Entries []types.CompileError `json:"entries"`
+}
+
+// CompareCompileLogs returns the errors and warnings of the translated build that the
+// original build does not have, grouped by file in log order. Both logs are parsed with
+// ParseLog; entries are compared by severity, class, file and message with paths, page
+// numbers, source lines and timing stripped, so an entry is only reported as new when the
+// original log has fewer entries of its kind. The translated main file (translated_main.tex)
+// is compared as the original one.
+func CompareCompileLogs(originalLog, translatedLog string) []LogDiffGroup {
+	counts := make(map[string]int)
+	for _, e := range ParseLog(originalLog) {
+		counts[logEntryKey(e)]++
+	}
+
+	var groups []LogDiffGroup
+	index := make(map[string]int)
+	for _, e := range ParseLog(translatedLog) {
+		key := logEntryKey(e)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		i, ok := index[e.File]
+		if !ok {
+			i = len(groups)
+			index[e.File] = i
+			groups = append(groups, LogDiffGroup{File: e.File})
+		}
+		groups[i].Entries = append(groups[i].Entries, e)
+	}
+	return groups
+}
+
+// NovelErrors returns the errors of the groups, without the warnings
+func NovelErrors(groups []LogDiffGroup) []types.CompileError {
+	var errs []types.CompileError
+	for _, group := range groups {
+		for _, e := range group.Entries {
+			if e.Severity == types.SeverityError {
+				errs = append(errs, e)
+			}
+		}
+	}
+	return errs
+}
+
+// FormatLogDiff describes the groups for the console, one file per paragraph
+func FormatLogDiff(groups []LogDiffGroup) string {
+	var b strings.Builder
+	for _, group := range groups {
+		file := group.File
+		if file == "" {
+			file = "(未知文件)"
+		}
+		fmt.Fprintf(&b, "%s:\n", file)
+		for _, e := range group.Entries {
+			severity := "错误"
+			if e.Severity == types.SeverityWarning {
+				severity = "警告"
+			}
+			location := ""
+			if e.Line > 0 {
+				location = fmt.Sprintf("l.%d ", e.Line)
+			}
+			fmt.Fprintf(&b, "  [%s/%s] %s%s\n", severity, ClassifyLogEntry(e), location, e.Message)
+		}
+	}
+	return b.String()
+}
+
+// ClassifyLogEntry returns the class of a log entry from its message
+func ClassifyLogEntry(e types.CompileError) LogEntryClass {
+	message := strings.ToLower(e.Message)
+	switch {
+	case strings.Contains(message, "undefined control sequence") ||
+		strings.Contains(message, "environment") && strings.Contains(message, "undefined"):
+		return LogClassUndefinedCommand
+	case strings.Contains(message, "not found") && (strings.Contains(message, "file") || strings.Contains(message, "package")):
+		return LogClassMissingFile
+	case strings.Contains(message, "reference") || strings.Contains(message, "citation") ||
+		strings.Contains(message, "label"):
+		return LogClassReference
+	case strings.Contains(message, "font") || strings.Contains(message, "missing character"):
+		return LogClassFont
+	case e.Severity == types.SeverityError:
+		return LogClassError
+	default:
+		return LogClassWarning
+	}
+}
+
+// logEntryKey identifies the kind of a log entry across the original and translated builds
+func logEntryKey(e types.CompileError) string {
+	return strings.Join([]string{e.Severity, string(ClassifyLogEntry(e)), normalizeLogFile(e.File), normalizeLogMessage(e.Message)}, "\x00")
+}
+
+// normalizeLogFile maps the translated main file to the original one. Absolute paths lie in
+// different build directories and are compared by their base name.
+func normalizeLogFile(file string) string {
+	if file == "" {
+		return ""
+	}
+	file = path.Clean(strings.ReplaceAll(file, `\`, "/"))
+	dir, base := path.Split(file)
+	if path.IsAbs(file) || drivePathPattern.MatchString(file) {
+		dir = ""
+	}
+	return dir + strings.TrimPrefix(base, translatedFilePrefix)
+}
+
+// normalizeLogMessage strips what differs between the builds of the same problem: paths
+// (kept as their base name), the translated file prefix, page and line numbers and timing
+func normalizeLogMessage(message string) string {
+	message = logPathPattern.ReplaceAllStringFunc(message, func(match string) string {
+		m := logPathPattern.FindStringSubmatch(match)
+		p := strings.TrimRight(strings.ReplaceAll(m[2], `\`, "/"), "/.,;:")
+		return m[1] + path.Base(p)
+	})
+	message = strings.ReplaceAll(message, translatedFilePrefix, "")
+	message = logPagePattern.ReplaceAllString(message, "$1 #")
+	message = logInputLinePattern.ReplaceAllString(message, "$1 #")
+	message = logTimingPattern.ReplaceAllString(message, "#s")
+	return strings.TrimSpace(logSpacePattern.ReplaceAllString(message, " "))
+}
@@ -0,0 +1,180 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+const originalBuildLog = `This is XeTeX, Version 3.141592653-2.6-0.999995 (TeX Live 2023) (preloaded format=xelatex)
+(/home/user/work/2301.00001/main.tex
+LaTeX2e <2023-11-01>
+(/usr/share/texlive/texmf-dist/tex/latex/base/article.cls
+Document Class: article 2023/05/17 v1.4n Standard LaTeX document class
+)
+LaTeX Warning: Reference ` + "`fig:arch'" + ` on page 3 undefined on input line 42.
+
+(./sections/intro.tex
+LaTeX Warning: Citation ` + "`vaswani2017'" + ` on page 1 undefined on input line 7.
+
+)
+! Undefined control sequence.
+l.88 \oldmacro
+              {x}
+Package hyperref Warning: Token not allowed in a PDF string (Unicode):
+(hyperref)                removing ` + "`math shift'" + ` on input line 12.
+
+)
+Output written on /home/user/work/2301.00001/output_original/main.pdf (12 pages).
+`
+
+const translatedBuildLog = `This is XeTeX, Version 3.141592653-2.6-0.999995 (TeX Live 2023) (preloaded format=xelatex)
+(/tmp/rpt-work/continue_2301.00001/translated_main.tex
+LaTeX2e <2023-11-01>
+(/usr/share/texlive/texmf-dist/tex/latex/base/article.cls
+Document Class: article 2023/05/17 v1.4n Standard LaTeX document class
+)
+LaTeX Warning: Reference ` + "`fig:arch'" + ` on page 4 undefined on input line 57.
+
+(./sections/intro.tex
+LaTeX Warning: Citation ` + "`vaswani2017'" + ` on page 2 undefined on input line 9.
+
+! Missing $ inserted.
+<inserted text>
+                $
+l.15 模型使用 x_i
+                  作为输入
+)
+! Undefined control sequence.
+l.102 \oldmacro
+               {x}
+! Undefined control sequence.
+l.140 \textbf{注意}\newmacro
+                            {y}
+Package hyperref Warning: Token not allowed in a PDF string (Unicode):
+(hyperref)                removing ` + "`math shift'" + ` on input line 20.
+
+)
+`
+
+func TestCompareCompileLogsReturnsOnlyNovelEntries(t *testing.T) {
+	groups := CompareCompileLogs(originalBuildLog, translatedBuildLog)
+	if len(groups) != 2 {
+		t.Fatalf("CompareCompileLogs() = %d groups, want 2: %+v", len(groups), groups)
+	}
+
+	// The missing $ is new in the included file
+	if groups[0].File != "./sections/intro.tex" && groups[0].File != "sections/intro.tex" {
+		t.Errorf("first group file = %q, want sections/intro.tex", groups[0].File)
+	}
+	if len(groups[0].Entries) != 1 || groups[0].Entries[0].Message != "Missing $ inserted." || groups[0].Entries[0].Line != 15 {
+		t.Errorf("intro.tex entries = %+v", groups[0].Entries)
+	}
+
+	// One of the two undefined control sequences was already in the original build
+	if !strings.HasSuffix(groups[1].File, "translated_main.tex") {
+		t.Errorf("second group file = %q, want the translated main file", groups[1].File)
+	}
+	if len(groups[1].Entries) != 1 || groups[1].Entries[0].Line != 140 {
+		t.Errorf("main file entries = %+v, want the undefined control sequence of l.140 only", groups[1].Entries)
+	}
+
+	if errs := NovelErrors(groups); len(errs) != 2 {
+		t.Errorf("NovelErrors() = %+v, want 2 errors", errs)
+	}
+}
+
+func TestCompareCompileLogsWithoutOriginal(t *testing.T) {
+	groups := CompareCompileLogs("", translatedBuildLog)
+	total := 0
+	for _, group := range groups {
+		total += len(group.Entries)
+	}
+	if want := len(ParseLog(translatedBuildLog)); total != want {
+		t.Errorf("entries = %d, want every entry of the translated log (%d)", total, want)
+	}
+	if groups := CompareCompileLogs(originalBuildLog, originalBuildLog); len(groups) != 0 {
+		t.Errorf("identical logs differ: %+v", groups)
+	}
+}
+
+func TestNormalizeLogMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"absolute path", "File `/home/user/work/figs/arch.pdf' not found", "File `arch.pdf' not found"},
+		{"windows path", `File C:\work\figs\arch.pdf not found`, "File arch.pdf not found"},
+		{"relative path", "File `./figs/arch.pdf' not found", "File `arch.pdf' not found"},
+		{"page and line", "Reference `fig:1' on page 3 undefined on input line 42.", "Reference `fig:1' on page # undefined on input line #."},
+		{"translated file", "Label(s) may have changed in translated_main.tex", "Label(s) may have changed in main.tex"},
+		{"timing", "Finished in 0.12s", "Finished in #s"},
+		{"fraction kept", "Ratio 1/2 used", "Ratio 1/2 used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeLogMessage(tt.in); got != tt.want {
+				t.Errorf("normalizeLogMessage(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyLogEntry(t *testing.T) {
+	tests := []struct {
+		message  string
+		severity string
+		want     LogEntryClass
+	}{
+		{"Undefined control sequence.", types.SeverityError, LogClassUndefinedCommand},
+		{"LaTeX Error: Environment algorithmic undefined.", types.SeverityError, LogClassUndefinedCommand},
+		{"LaTeX Error: File `xeCJK.sty' not found.", types.SeverityError, LogClassMissingFile},
+		{"Citation `a' on page 1 undefined on input line 3.", types.SeverityWarning, LogClassReference},
+		{"Font shape `TU/SimSun(0)/b/n' undefined", types.SeverityWarning, LogClassFont},
+		{"Missing $ inserted.", types.SeverityError, LogClassError},
+		{"Token not allowed in a PDF string", types.SeverityWarning, LogClassWarning},
+	}
+	for _, tt := range tests {
+		got := ClassifyLogEntry(types.CompileError{Message: tt.message, Severity: tt.severity})
+		if got != tt.want {
+			t.Errorf("ClassifyLogEntry(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLogDiff(t *testing.T) {
+	out := FormatLogDiff([]LogDiffGroup{{
+		File:    "translated_main.tex",
+		Entries: []types.CompileError{{Line: 140, Message: "Undefined control sequence.", Severity: types.SeverityError}},
+	}})
+	want := "translated_main.tex:\n  [错误/undefined-command] l.140 Undefined control sequence.\n"
+	if out != want {
+		t.Errorf("FormatLogDiff() = %q, want %q", out, want)
+	}
+}
+
+func TestFixerPromptErrorsSkipsUpstreamErrors(t *testing.T) {
+	fixer := NewLaTeXFixer("key", "", "")
+	errs := ParseLogErrors(translatedBuildLog)
+	if got := fixer.promptErrors(translatedBuildLog, errs); len(got) != len(errs) {
+		t.Errorf("promptErrors() without original log = %d errors, want %d", len(got), len(errs))
+	}
+
+	fixer.SetOriginalLog(originalBuildLog)
+	got := fixer.promptErrors(translatedBuildLog, errs)
+	if len(got) != 2 {
+		t.Fatalf("promptErrors() = %+v, want the 2 errors new in the translated build", got)
+	}
+	for _, e := range got {
+		if e.Line == 102 {
+			t.Errorf("promptErrors() kept the error already in the original build: %+v", e)
+		}
+	}
+
+	// Errors all present upstream are still sent, there is nothing else to fix
+	if got := fixer.promptErrors(originalBuildLog, ParseLogErrors(originalBuildLog)); len(got) != 1 {
+		t.Errorf("promptErrors() with only upstream errors = %+v, want them all", got)
+	}
+}
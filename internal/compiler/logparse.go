@@ -0,0 +1,239 @@
+package compiler
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"latex-translator/internal/types"
+)
+
+// logLineWidth is the width at which TeX wraps the lines it writes (max_print_line)
+const logLineWidth = 79
+
+// maxErrorBodyLines bounds how far after an error message its l.<num> marker is looked for
+const maxErrorBodyLines = 12
+
+var (
+	// logLinePattern matches the line marker TeX prints after an error, e.g. "l.14 The method"
+	logLinePattern = regexp.MustCompile(`^l\.(\d+)(?: |$)`)
+	// fileLineErrorPattern matches errors in -file-line-error style, e.g. "./main.tex:14: Undefined control sequence."
+	fileLineErrorPattern = regexp.MustCompile(`^(\S[^:]*\.[A-Za-z]+):(\d+): (.+)$`)
+	// logWarningPattern matches warnings of LaTeX, packages and classes
+	logWarningPattern = regexp.MustCompile(`^(?:LaTeX(?: \w+)?|Package \S+|Class \S+) Warning: (.*)$`)
+	// continuationPattern matches the continuation lines of package messages, e.g. "(natbib)   more text"
+	continuationPattern = regexp.MustCompile(`^\([^()\s]+\)\s+(.*)$`)
+	// inputLinePattern matches the source line of a warning
+	inputLinePattern = regexp.MustCompile(`on input line (\d+)`)
+	// logFileNamePattern matches bare file names opened in the log, e.g. "(main.aux"
+	logFileNamePattern = regexp.MustCompile(`^[A-Za-z][\w\-.]*\.[A-Za-z][A-Za-z0-9]{0,7}$`)
+	// drivePathPattern matches absolute Windows paths
+	drivePathPattern = regexp.MustCompile(`^[A-Za-z]:[/\\]`)
+)
+
+// ParseLog parses a LaTeX log into its errors and warnings, in log order and without the
+// repetitions of later passes. The file of each entry is found by following the file stack
+// of the log: "(file" opens a file and ")" closes the innermost one. Error lines come from
+// the l.<num> marker TeX prints after the message, warning lines from "on input line <num>".
+// Logs of several passes (separated by the engine banner or "=== ... ===") can be parsed
+// as a whole.
+func ParseLog(log string) []types.CompileError {
+	lines := unwrapLogLines(log)
+	var entries []types.CompileError
+	seen := make(map[types.CompileError]bool)
+	add := func(e types.CompileError) {
+		if !seen[e] {
+			seen[e] = true
+			entries = append(entries, e)
+		}
+	}
+
+	var stack []string
+	currentFile := func() string {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i] != "" {
+				return stack[i]
+			}
+		}
+		return ""
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "This is ") || strings.HasPrefix(line, "=== "):
+			// A new pass or tool starts with an empty file stack
+			stack = nil
+
+		case strings.HasPrefix(line, "!"):
+			e := types.CompileError{
+				File:     currentFile(),
+				Message:  strings.TrimSpace(strings.TrimPrefix(line, "!")),
+				Severity: types.SeverityError,
+			}
+			i = readErrorBody(lines, i, &e)
+			add(e)
+
+		case fileLineErrorPattern.MatchString(line):
+			m := fileLineErrorPattern.FindStringSubmatch(line)
+			lineNum, _ := strconv.Atoi(m[2])
+			e := types.CompileError{
+				File:     strings.TrimPrefix(m[1], "./"),
+				Message:  strings.TrimSpace(m[3]),
+				Severity: types.SeverityError,
+			}
+			i = readErrorBody(lines, i, &e)
+			e.Line = lineNum
+			add(e)
+
+		case logWarningPattern.MatchString(line):
+			message := logWarningPattern.FindStringSubmatch(line)[1]
+			for i+1 < len(lines) && continuationPattern.MatchString(lines[i+1]) {
+				i++
+				message += " " + continuationPattern.FindStringSubmatch(lines[i])[1]
+			}
+			e := types.CompileError{
+				File:     currentFile(),
+				Message:  strings.TrimSpace(message),
+				Severity: types.SeverityWarning,
+			}
+			if m := inputLinePattern.FindStringSubmatch(message); m != nil {
+				e.Line, _ = strconv.Atoi(m[1])
+			}
+			add(e)
+
+		case strings.HasPrefix(line, "Overfull \\") || strings.HasPrefix(line, "Underfull \\"):
+			// The box contents shown below the message are typeset text, their parentheses
+			// are not files; they end with an empty line
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+			}
+
+		case line == "Runaway argument?" || line == "Runaway definition?" || line == "Runaway text?":
+			// The next line is the text read so far, with unbalanced parentheses of its own
+			i++
+
+		default:
+			stack = trackLogFiles(line, stack)
+		}
+	}
+	return entries
+}
+
+// ParseLogErrors returns the errors of a LaTeX log, without the warnings
+func ParseLogErrors(log string) []types.CompileError {
+	var errs []types.CompileError
+	for _, e := range ParseLog(log) {
+		if e.Severity == types.SeverityError {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// readErrorBody reads the lines after the error message at lines[start]: continuation lines
+// of package errors ("(pkg)  text") and LaTeX errors (indented text) are added to the
+// message, the l.<num> marker and the line after it give the line and context. Returns the
+// index of the last line read; without a marker only the message lines are read, so the
+// lines after it are parsed as usual.
+func readErrorBody(lines []string, start int, e *types.CompileError) int {
+	end := start
+	for end+1 < len(lines) {
+		next := lines[end+1]
+		if m := continuationPattern.FindStringSubmatch(next); m != nil {
+			e.Message += " " + strings.TrimSpace(m[1])
+		} else if text := strings.TrimSpace(next); strings.HasPrefix(next, "  ") && text != "" && text != "..." {
+			e.Message += " " + text
+		} else {
+			break
+		}
+		end++
+	}
+
+	for i := end + 1; i < len(lines) && i <= end+maxErrorBodyLines; i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "!") || fileLineErrorPattern.MatchString(line) {
+			break
+		}
+		m := logLinePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Context = strings.TrimRight(line, " ")
+		if i+1 < len(lines) {
+			// The line after the marker holds the rest of the source line, not read yet
+			if rest := strings.TrimRight(lines[i+1], " "); strings.TrimSpace(rest) != "" {
+				e.Context += "\n" + rest
+			}
+			i++
+		}
+		return i
+	}
+	return end
+}
+
+// trackLogFiles updates the file stack with the parentheses of a log line. Parentheses that
+// do not open a file are pushed as "" so that their closing one pops the right entry.
+func trackLogFiles(line string, stack []string) []string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '(':
+			name, length := logFileToken(line[i+1:])
+			if looksLikeLogFile(name) {
+				stack = append(stack, strings.TrimPrefix(name, "./"))
+				i += length
+			} else {
+				stack = append(stack, "")
+			}
+		case ')':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return stack
+}
+
+// logFileToken returns the file name that may follow an opening parenthesis and the number
+// of bytes it takes. Names with spaces are quoted by recent TeX versions.
+func logFileToken(s string) (string, int) {
+	if strings.HasPrefix(s, `"`) {
+		if end := strings.IndexByte(s[1:], '"'); end >= 0 {
+			return s[1 : end+1], end + 2
+		}
+	}
+	end := strings.IndexAny(s, " \t()")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], end
+}
+
+// looksLikeLogFile reports whether the text after an opening parenthesis is a file name:
+// a path, or a name with an extension that does not start with a digit ("(12.3pt too wide)")
+func looksLikeLogFile(name string) bool {
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") {
+		return len(name) > 2
+	}
+	return drivePathPattern.MatchString(name) || logFileNamePattern.MatchString(name)
+}
+
+// unwrapLogLines splits a log into lines, joining the lines TeX wrapped at logLineWidth.
+// pdfTeX counts bytes, XeTeX and LuaTeX count characters.
+func unwrapLogLines(log string) []string {
+	raw := strings.Split(strings.ReplaceAll(log, "\r\n", "\n"), "\n")
+	var lines []string
+	var current strings.Builder
+	for i, line := range raw {
+		current.WriteString(line)
+		wrapped := len(line) == logLineWidth || utf8.RuneCountInString(line) == logLineWidth
+		if wrapped && i+1 < len(raw) {
+			continue
+		}
+		lines = append(lines, current.String())
+		current.Reset()
+	}
+	return lines
+}
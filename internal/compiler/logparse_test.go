@@ -0,0 +1,104 @@
+package compiler
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the log parser tests")
+
+// TestParseLogGolden parses the captured logs in testdata/logs and compares the entries with
+// the .golden.json file next to each log. Run with -update to rewrite the golden files.
+func TestParseLogGolden(t *testing.T) {
+	logs, err := filepath.Glob(filepath.Join("testdata", "logs", "*.log"))
+	if err != nil || len(logs) == 0 {
+		t.Fatalf("no test logs: %v", err)
+	}
+	for _, logPath := range logs {
+		t.Run(filepath.Base(logPath), func(t *testing.T) {
+			content, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(ParseLog(string(content)), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			goldenPath := strings.TrimSuffix(logPath, ".log") + ".golden.json"
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("ParseLog() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLogFileLineError(t *testing.T) {
+	log := "(./main.tex (./intro.tex\n" +
+		"./intro.tex:3: Undefined control sequence.\n" +
+		"l.3 \\foo\n" +
+		"         bar\n" +
+		"))\n"
+	want := []types.CompileError{{
+		File:     "intro.tex",
+		Line:     3,
+		Message:  "Undefined control sequence.",
+		Context:  "l.3 \\foo\n         bar",
+		Severity: types.SeverityError,
+	}}
+	if got := ParseLog(log); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLog() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTrackLogFiles(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`(./main.tex (./sec/intro.tex`, []string{"main.tex", "sec/intro.tex"}},
+		{`(./main.aux) (see the transcript file)`, []string{}},
+		{`("./my paper.tex"`, []string{"my paper.tex"}},
+		{`(C:/texlive/2023/texmf-dist/tex/latex/base/article.cls`, []string{"C:/texlive/2023/texmf-dist/tex/latex/base/article.cls"}},
+		{`Overfull (12.3pt too wide`, []string{""}},
+	}
+	for _, tt := range tests {
+		if got := trackLogFiles(tt.line, nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("trackLogFiles(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrorString(t *testing.T) {
+	tests := []struct {
+		err  types.CompileError
+		want string
+	}{
+		{types.CompileError{File: "sec/intro.tex", Line: 12, Message: "Missing $ inserted."}, "sec/intro.tex:12: Missing $ inserted."},
+		{types.CompileError{Line: 12, Message: "Missing $ inserted."}, "l.12: Missing $ inserted."},
+		{types.CompileError{File: "main.tex", Message: "Emergency stop."}, "main.tex: Emergency stop."},
+		{types.CompileError{Message: "Emergency stop."}, "Emergency stop."},
+	}
+	for _, tt := range tests {
+		if got := tt.err.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
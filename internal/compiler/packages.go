@@ -0,0 +1,203 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// TeX distributions recognized for install hints
+const (
+	DistributionMiKTeX  = "miktex"
+	DistributionTeXLive = "texlive"
+)
+
+// packageCommandPattern matches \usepackage[options]{a,b} and \RequirePackage{a}
+var packageCommandPattern = regexp.MustCompile(`\\(?:usepackage|RequirePackage)\s*(?:\[[^\]]*\])?\s*\{([^}]*)\}`)
+
+// styleDistPackages maps the .sty files whose distribution package has another name to
+// that package, for the install command
+var styleDistPackages = map[string]string{
+	"algorithm":     "algorithms",
+	"algorithmic":   "algorithms",
+	"algpseudocode": "algorithmicx",
+	"algcompatible": "algorithmicx",
+	"amssymb":       "amsfonts",
+	"graphicx":      "graphics",
+	"subcaption":    "caption",
+	"tikz":          "pgf",
+	"pgfplotstable": "pgfplots",
+	"xeCJK":         "xecjk",
+}
+
+// FindRequiredPackages returns the packages loaded by the .tex, .sty and .cls files under
+// texDir with \usepackage or \RequirePackage, sorted. Commented-out commands are ignored,
+// as are packages whose .sty ships with the source.
+func FindRequiredPackages(texDir string) ([]string, error) {
+	local := make(map[string]bool)
+	required := make(map[string]bool)
+	err := filepath.Walk(texDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext == ".sty" {
+			local[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = true
+		}
+		if ext != ".tex" && ext != ".sty" && ext != ".cls" {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, m := range packageCommandPattern.FindAllStringSubmatch(uncommentedText(string(content)), -1) {
+			for _, name := range strings.Split(m[1], ",") {
+				name = strings.TrimSpace(name)
+				// Arguments of macro definitions (\usepackage{#1}) are not package names
+				if name != "" && !strings.ContainsAny(name, `#\`) {
+					required[name] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []string
+	for name := range required {
+		if !local[filepath.Base(name)] {
+			packages = append(packages, name)
+		}
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
+
+// FindMissingPackages returns the packages required by the sources under texDir that
+// kpsewhich cannot find, with the command installing them. It returns nil when nothing is
+// missing, and when kpsewhich is not installed or fails, as the packages cannot be checked
+// then.
+func FindMissingPackages(texDir string) *types.MissingPackagesReport {
+	packages, err := FindRequiredPackages(texDir)
+	if err != nil || len(packages) == 0 {
+		return nil
+	}
+	found, ok := kpsewhichFound(packages)
+	if !ok {
+		return nil
+	}
+
+	var missing []string
+	for _, name := range packages {
+		if !found[filepath.Base(name)] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	distribution := DetectTeXDistribution()
+	logger.Warn("LaTeX packages missing",
+		logger.String("packages", strings.Join(missing, ",")),
+		logger.String("distribution", distribution))
+	return &types.MissingPackagesReport{
+		Packages:       missing,
+		Distribution:   distribution,
+		InstallCommand: InstallCommand(distribution, missing),
+	}
+}
+
+// kpsewhichFound looks up the .sty files of the packages with a single kpsewhich run and
+// returns the names of the ones found. ok is false when kpsewhich could not be run.
+func kpsewhichFound(packages []string) (map[string]bool, bool) {
+	if _, err := exec.LookPath("kpsewhich"); err != nil {
+		logger.Debug("kpsewhich not installed, skipping package check")
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(processCtx, 30*time.Second)
+	defer cancel()
+
+	args := make([]string, len(packages))
+	for i, name := range packages {
+		args[i] = filepath.Base(name) + ".sty"
+	}
+	cmd := commandContext(ctx, "kpsewhich", args...)
+	hideWindow(cmd)
+	output, err := cmd.Output()
+	// kpsewhich exits with 1 when a file is not found; the found ones are still printed
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		logger.Warn("kpsewhich failed, skipping package check", logger.Err(err))
+		return nil, false
+	}
+
+	found := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			found[strings.TrimSuffix(filepath.Base(line), ".sty")] = true
+		}
+	}
+	return found, true
+}
+
+// DetectTeXDistribution returns DistributionMiKTeX or DistributionTeXLive by their package
+// managers on the PATH, or "" when neither is found
+func DetectTeXDistribution() string {
+	for _, tool := range []string{"miktex", "mpm"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return DistributionMiKTeX
+		}
+	}
+	if _, err := exec.LookPath("tlmgr"); err == nil {
+		return DistributionTeXLive
+	}
+	return ""
+}
+
+// InstallCommand returns the command installing the distribution packages providing the
+// given LaTeX packages, or "" for an unknown distribution
+func InstallCommand(distribution string, packages []string) string {
+	names := distPackageNames(packages)
+	switch distribution {
+	case DistributionMiKTeX:
+		args := make([]string, len(names))
+		for i, name := range names {
+			args[i] = "--install=" + name
+		}
+		return "mpm " + strings.Join(args, " ")
+	case DistributionTeXLive:
+		return "tlmgr install " + strings.Join(names, " ")
+	}
+	return ""
+}
+
+// distPackageNames returns the distribution packages providing the given LaTeX packages,
+// without duplicates
+func distPackageNames(packages []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range packages {
+		name = filepath.Base(name)
+		if distName, ok := styleDistPackages[name]; ok {
+			name = distName
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
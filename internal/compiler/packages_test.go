@@ -0,0 +1,58 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindRequiredPackages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tex": "\\documentclass{article}\n" +
+			"\\usepackage[utf8]{inputenc}\n" +
+			"\\usepackage{amsmath, amssymb,algorithm2e}\n" +
+			"% \\usepackage{commented}\n" +
+			"\\usepackage{mystyle}\n" +
+			"\\newcommand{\\load}[1]{\\usepackage{#1}}\n",
+		"mystyle.sty":          "\\RequirePackage{xcolor}\n",
+		"sections/intro.tex":   "\\usepackage [ruled] { algorithm }\n",
+		"sections/custom.cls":  "\\RequirePackage{geometry}\n",
+		"sections/notes.txt":   "\\usepackage{ignored}\n",
+		"styles/localpkg.sty":  "",
+		"sections/more.tex":    "\\usepackage{styles/localpkg}\n",
+		"sections/percent.tex": "50\\% \\usepackage{booktabs}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := FindRequiredPackages(dir)
+	if err != nil {
+		t.Fatalf("FindRequiredPackages() error: %v", err)
+	}
+	want := []string{"algorithm", "algorithm2e", "amsmath", "amssymb", "booktabs", "geometry", "inputenc", "xcolor"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindRequiredPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestInstallCommand(t *testing.T) {
+	packages := []string{"algorithm", "algorithmic", "algorithm2e", "tikz"}
+	if got, want := InstallCommand(DistributionTeXLive, packages), "tlmgr install algorithms algorithm2e pgf"; got != want {
+		t.Errorf("TeX Live command = %q, want %q", got, want)
+	}
+	if got, want := InstallCommand(DistributionMiKTeX, packages), "mpm --install=algorithms --install=algorithm2e --install=pgf"; got != want {
+		t.Errorf("MiKTeX command = %q, want %q", got, want)
+	}
+	if got := InstallCommand("", packages); got != "" {
+		t.Errorf("command for an unknown distribution = %q", got)
+	}
+}
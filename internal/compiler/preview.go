@@ -0,0 +1,69 @@
+package compiler
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/types"
+)
+
+// previewMaxPasses is the number of LaTeX runs of a preview build: enough for most
+// references, fewer than the full build
+const previewMaxPasses = 2
+
+var (
+	// previewChapterPattern and previewSectionPattern match the headings a preview is cut at
+	previewChapterPattern = regexp.MustCompile(`\\chapter\*?\s*[\[{]`)
+	previewSectionPattern = regexp.MustCompile(`\\section\*?\s*[\[{]`)
+)
+
+// PreviewDocument shortens the content of a translated main file to the front matter and its
+// first chapter, for a preview built before the full document. A document split with
+// \include keeps only its first included file (\includeonly); otherwise the body is cut
+// before the second \chapter, or the second \section when it has no chapters. Returns false
+// when the document has nothing to cut, so a preview would take as long as the full build.
+func PreviewDocument(content string) (string, bool) {
+	text := uncommentedText(content)
+	begin := strings.Index(text, `\begin{document}`)
+	if begin < 0 {
+		return content, false
+	}
+
+	var includes []string
+	for _, m := range includeFilePattern.FindAllStringSubmatch(text[begin:], -1) {
+		if name := strings.TrimSpace(m[1]); name != "" {
+			includes = append(includes, name)
+		}
+	}
+	if len(includes) > 1 {
+		directive := `\includeonly{` + includes[0] + `}`
+		// An \includeonly of the document itself is replaced, as LaTeX uses the last one
+		if loc := includeOnlyPattern.FindStringIndex(text[:begin]); loc != nil {
+			return content[:loc[0]] + directive + content[loc[1]:], true
+		}
+		return content[:begin] + directive + "\n" + content[begin:], true
+	}
+
+	for _, pattern := range []*regexp.Regexp{previewChapterPattern, previewSectionPattern} {
+		locs := pattern.FindAllStringIndex(text[begin:], 2)
+		if len(locs) < 2 {
+			continue
+		}
+		cut := begin + locs[1][0]
+		return strings.TrimRight(content[:cut], " \t\n") + "\n\\end{document}\n", true
+	}
+	return content, false
+}
+
+// CompilePreviewContext builds a preview of a translated document: the main file at texPath,
+// already shortened with PreviewDocument, is compiled like CompileTranslatedWithFallbackContext
+// but with fewer passes. texPath must be a copy of the source tree made for the preview, as
+// the compilation writes its fixes next to it.
+func (c *LaTeXCompiler) CompilePreviewContext(ctx context.Context, texPath, outputDir, preferred string) (*types.CompileResult, error) {
+	preview := *c
+	if preview.passLimit() > previewMaxPasses {
+		preview.maxPasses = previewMaxPasses
+	}
+	return preview.CompileTranslatedWithFallbackContext(ctx, texPath, outputDir, preferred)
+}
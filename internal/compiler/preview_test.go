@@ -0,0 +1,66 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreviewDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    string
+		changed bool
+	}{
+		{
+			name:    "includes",
+			doc:     "\\documentclass{book}\n\\begin{document}\n\\include{front}\n\\include{ch1}\n\\include{ch2}\n\\end{document}\n",
+			want:    "\\documentclass{book}\n\\includeonly{front}\n\\begin{document}\n\\include{front}\n\\include{ch1}\n\\include{ch2}\n\\end{document}\n",
+			changed: true,
+		},
+		{
+			name:    "includeonly replaced",
+			doc:     "\\documentclass{book}\n\\includeonly{ch2,ch3}\n% \\include{old}\n\\begin{document}\n\\include{ch2}\n\\include{ch3}\n\\end{document}\n",
+			want:    "\\documentclass{book}\n\\includeonly{ch2}\n% \\include{old}\n\\begin{document}\n\\include{ch2}\n\\include{ch3}\n\\end{document}\n",
+			changed: true,
+		},
+		{
+			name:    "chapters",
+			doc:     "\\documentclass{book}\n\\section{Preamble?}\n\\begin{document}\n\\maketitle\n\\chapter{一}\n\\section{a}\n\\section{b}\n% \\chapter{commented}\n\\chapter*{二}\n\\end{document}\n",
+			want:    "\\documentclass{book}\n\\section{Preamble?}\n\\begin{document}\n\\maketitle\n\\chapter{一}\n\\section{a}\n\\section{b}\n% \\chapter{commented}\n\\end{document}\n",
+			changed: true,
+		},
+		{
+			name:    "sections",
+			doc:     "\\documentclass{article}\n\\begin{document}\n\\begin{abstract}\n摘要\n\\end{abstract}\n\\section{引言}\n正文\n\\section[短]{方法}\n\\end{document}\n",
+			want:    "\\documentclass{article}\n\\begin{document}\n\\begin{abstract}\n摘要\n\\end{abstract}\n\\section{引言}\n正文\n\\end{document}\n",
+			changed: true,
+		},
+		{
+			name: "single section",
+			doc:  "\\documentclass{article}\n\\begin{document}\n\\section{引言}\n\\include{only}\n\\end{document}\n",
+		},
+		{
+			name: "no document",
+			doc:  "\\section{a}\n\\section{b}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := PreviewDocument(tt.doc)
+			if changed != tt.changed {
+				t.Fatalf("changed = %v, want %v", changed, tt.changed)
+			}
+			want := tt.want
+			if !tt.changed {
+				want = tt.doc
+			}
+			if got != want {
+				t.Errorf("PreviewDocument() =\n%s\nwant\n%s", got, want)
+			}
+			if changed && strings.Count(got, "\\end{document}") != 1 {
+				t.Errorf("preview does not end the document once:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package compiler
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"latex-translator/internal/logger"
+)
+
+// processCtx is the parent context of every TeX process; StopAllProcesses cancels it
+var processCtx, stopProcesses = context.WithCancel(context.Background())
+
+// StopAllProcesses kills the running TeX processes together with their child processes and
+// makes later ones fail immediately. It is called when the application exits.
+func StopAllProcesses() {
+	logger.Info("stopping all TeX processes")
+	stopProcesses()
+}
+
+// processWaitDelay is how long a killed TeX process may keep its output pipes open through
+// child processes that outlived it
+const processWaitDelay = time.Second
+
+// commandContext is exec.CommandContext for TeX processes: the process is killed with its
+// child processes when ctx ends, so no compiler keeps output files locked
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd)
+	}
+	cmd.WaitDelay = processWaitDelay
+	return cmd
+}
+
+// processContext returns the context of one TeX process of a compilation under parent: it
+// ends after timeout, when parent is done or when StopAllProcesses is called. A nil parent
+// is never cancelled.
+func processContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	stop := context.AfterFunc(processCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,63 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeTeX puts an executable named engine that never finishes first on the PATH
+func fakeTeX(t *testing.T, engine string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake TeX engine is a shell script")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\nsleep 60\n"
+	if err := os.WriteFile(filepath.Join(bin, engine), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCancelledCompileReturnsPromptly(t *testing.T) {
+	fakeTeX(t, CompilerXeLaTeX)
+	dir := t.TempDir()
+	texPath := filepath.Join(dir, "main.tex")
+	if err := os.WriteFile(texPath, []byte("\\documentclass{article}\n\\begin{document}\nHello\n\\end{document}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	c := NewLaTeXCompiler(CompilerXeLaTeX, dir, time.Minute)
+	start := time.Now()
+	result, err := c.CompileWithXeLaTeXContext(ctx, texPath, filepath.Join(dir, "out"))
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("cancelled compile returned after %s", elapsed)
+	}
+	if err == nil || result == nil || result.Success {
+		t.Fatalf("CompileWithXeLaTeXContext() = %+v, %v; want a failed compilation", result, err)
+	}
+	if result.ErrorMsg != "compilation cancelled" {
+		t.Errorf("ErrorMsg = %q, want compilation cancelled", result.ErrorMsg)
+	}
+}
+
+func TestCompileWithoutContextIsNotCancelled(t *testing.T) {
+	c := NewLaTeXCompiler(CompilerXeLaTeX, t.TempDir(), time.Minute)
+	if err := c.context().Err(); err != nil {
+		t.Errorf("context of a compiler without one: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.withContext(ctx).context().Err() == nil {
+		t.Error("copy does not carry the cancelled context")
+	}
+	if c.ctx != nil {
+		t.Error("withContext changed the original compiler")
+	}
+}
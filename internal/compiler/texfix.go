@@ -0,0 +1,380 @@
+package compiler
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// The preamble fixes of translated documents. Every code path that prepares a document for
+// compiling (the post-processing pipeline, the engine preprocessors and the cmd tools) uses
+// these functions, so a fix made here reaches all of them. They only look at uncommented
+// lines of the preamble, recognise packages loaded in a list or with options and are
+// idempotent.
+
+// Routes for typesetting Chinese in a translated document
+const (
+	RouteCtex     = "ctex"              // ctex, for XeLaTeX
+	RouteLuatexja = "luatexja-fontspec" // luatexja-fontspec with a main font, for LuaLaTeX
+	RouteXeCJK    = "xeCJK"             // xeCJK with a main font, for classes ctex breaks
+)
+
+// Markers of the setups added by EnsureCJKSupport
+const (
+	luatexjaSetupMarker = "% Chinese support for LuaLaTeX (auto-added by translator)"
+	xeCJKSetupMarker    = "% Chinese support with xeCJK (auto-added by translator)"
+)
+
+// Notes of the package lines commented out for the Unicode engines
+const unicodeEngineNote = "disabled for XeLaTeX/LuaLaTeX"
+
+var (
+	// usepackagePattern matches \usepackage with its options and list of packages
+	usepackagePattern = regexp.MustCompile(`\\usepackage\s*(?:\[([^\]]*)\])?\s*\{([^}]*)\}`)
+	// legacyFontPackages set Type 1 text fonts the Unicode engines have no TU version of;
+	// the text falls back to Latin Modern and mathptmx also switches the math fonts
+	legacyFontPackages = []string{"times", "mathptmx"}
+	// packageLoadedBy lists the packages that load a language support package themselves
+	packageLoadedBy = map[string][]string{
+		"luatexja": {"luatexja-fontspec", "luatexja-preset"},
+	}
+)
+
+// ChineseSupportRoute returns how Chinese is typeset for a document class and the engine
+// the document is compiled with: luatexja-fontspec for LuaLaTeX, xeCJK for the classes whose
+// adapter (ClassAdapterFor) asks for it and ctex otherwise. An empty engine means XeLaTeX.
+func ChineseSupportRoute(documentClass, engine string) string {
+	if engine == CompilerLuaLaTeX {
+		return RouteLuatexja
+	}
+	if adapter := ClassAdapterFor(documentClass); adapter != nil && adapter.CJKPackage == RouteXeCJK {
+		return RouteXeCJK
+	}
+	return RouteCtex
+}
+
+// EnsureCJKSupport loads the Chinese support of the route ChineseSupportRoute picks for the
+// document and engine. Documents that already load ctex, xeCJK or luatexja, or use a ctex
+// class, keep their setup. With pdfLaTeX the ctex setup is converted to CJKutf8 by the
+// engine preprocessor when compiling.
+func EnsureCJKSupport(content, engine string) string {
+	class := DocumentClass(content)
+	route := ChineseSupportRoute(class, engine)
+	if route == RouteCtex {
+		return EnsureCtexPackage(content)
+	}
+	if hasCJKSupport(content) {
+		logger.Debug("Chinese support already present", logger.String("route", route))
+		return content
+	}
+
+	var setup string
+	if route == RouteLuatexja {
+		setup = luatexjaSetupMarker + "\n\\usepackage{luatexja-fontspec}\n" + cjkMainFontChain("\\setmainjfont")
+	} else {
+		xeCJK := "\\usepackage{xeCJK}"
+		if adapter := ClassAdapterFor(class); adapter != nil && adapter.CJKOptions != "" {
+			xeCJK = "\\usepackage[" + adapter.CJKOptions + "]{xeCJK}"
+		}
+		setup = xeCJKSetupMarker + "\n" + xeCJK + "\n" + cjkMainFontChain("\\setCJKmainfont")
+	}
+	if fixed, added := insertAfterDocumentclass(content, setup); added {
+		logger.Info("added Chinese support", logger.String("route", route))
+		return fixed
+	}
+	logger.Warn("could not find \\documentclass to add Chinese support", logger.String("route", route))
+	return content
+}
+
+// EnsureCtexPackage prepares a document for compiling Chinese with XeLaTeX: it loads the
+// ctex package after \documentclass (unless ctex is already loaded or the class is a ctex
+// class), replaces CJKutf8 and its CJK* environment, which conflict with ctex, and disables
+// microtype font expansion (FixMicrotype).
+func EnsureCtexPackage(content string) string {
+	if hasUncommentedPackage(content, "ctex") || ctexClassPattern.MatchString(uncommentedText(content)) {
+		logger.Debug("ctex already present (uncommented)")
+	} else if fixed, added := insertAfterDocumentclass(content, "\\usepackage{ctex}"); added {
+		content = fixed
+		logger.Info("added ctex package for Chinese support")
+	} else {
+		logger.Warn("could not find \\documentclass to add ctex package")
+	}
+
+	content, _ = removeCJKutf8(content)
+	return FixMicrotype(content)
+}
+
+// EnsurePackage loads a package right after \documentclass unless it, or a package of
+// packageLoadedBy that loads it, is already loaded
+func EnsurePackage(content, name string) string {
+	for _, loaded := range append([]string{name}, packageLoadedBy[name]...) {
+		if hasUncommentedPackage(content, loaded) {
+			logger.Debug("package already present", logger.String("package", name), logger.String("loaded", loaded))
+			return content
+		}
+	}
+	if fixed, added := insertAfterDocumentclass(content, "\\usepackage{"+name+"}"); added {
+		logger.Info("added package", logger.String("package", name))
+		return fixed
+	}
+	logger.Warn("could not find \\documentclass to add the package", logger.String("package", name))
+	return content
+}
+
+// DisableIncompatiblePackages comments out the packages that fail or have no effect with
+// engine. For XeLaTeX and LuaLaTeX: fontenc with a legacy encoding, inputenc and the Type 1
+// font packages times and mathptmx. For pdfLaTeX: the packages and font commands that need a
+// Unicode engine (ctex, xeCJK, fontspec, luatexja, kotex).
+func DisableIncompatiblePackages(content, engine string) string {
+	if engine == CompilerPDFLaTeX {
+		content, _ = disableUnicodePackages(content)
+		return content
+	}
+	content, _ = disableLegacyFontenc(content)
+	content, _ = disableInputenc(content)
+	content, _ = disableLegacyFontPackages(content)
+	return content
+}
+
+// FixMicrotype turns off microtype protrusion and expansion, which fail with "Cannot use
+// XeTeXglyph" under XeLaTeX. The other options of the package are kept.
+func FixMicrotype(content string) string {
+	content, _ = disableMicrotypeExpansion(content)
+	return content
+}
+
+// hasCJKSupport reports whether a document already typesets CJK text: it loads ctex, xeCJK,
+// luatexja or luatexja-fontspec, or uses a ctex class
+func hasCJKSupport(content string) bool {
+	for _, name := range []string{"ctex", "xeCJK", "luatexja", "luatexja-fontspec"} {
+		if hasUncommentedPackage(content, name) {
+			return true
+		}
+	}
+	return ctexClassPattern.MatchString(uncommentedText(content))
+}
+
+// cjkMainFontChain sets the main Chinese font with command to the first installed font of
+// the priority list, and to the Fandol font file of the TeX tree when none is
+func cjkMainFontChain(command string) string {
+	fonts := CJKFontPriority()
+	chain := command + "{" + CJKFontFallbackFile + "}"
+	for i := len(fonts) - 1; i >= 0; i-- {
+		chain = "\\IfFontExistsTF{" + fonts[i] + "}{" + command + "{" + fonts[i] + "}}{%\n" + chain + "}"
+	}
+	return chain
+}
+
+// removeCJKutf8 comments out CJKutf8 and removes the CJK* environment: with ctex on XeLaTeX
+// or LuaLaTeX, CJKutf8 is incompatible and CJK* is not defined
+func removeCJKutf8(content string) (string, bool) {
+	content, changed := disablePackage(content, "CJKutf8", "Commented out - using ctex instead", nil)
+	if changed {
+		logger.Info("commented out CJKutf8 package (conflicts with ctex)")
+	}
+	if fixed, replaced := replaceUncommented(content, cjkBeginPattern, "% CJK* environment removed - using ctex instead\n"); replaced {
+		content = fixed
+		logger.Info("removed \\begin{CJK*} (conflicts with ctex)")
+		changed = true
+	}
+	if fixed, replaced := replaceUncommented(content, cjkEndPattern, "% \\end{CJK*} removed\n"); replaced {
+		content = fixed
+		logger.Info("removed \\end{CJK*} (conflicts with ctex)")
+		changed = true
+	}
+	return content, changed
+}
+
+// replaceUncommented replaces the matches of pattern outside comments with replacement
+func replaceUncommented(content string, pattern *regexp.Regexp, replacement string) (string, bool) {
+	locs := pattern.FindAllStringIndex(uncommentedText(content), -1)
+	for i := len(locs) - 1; i >= 0; i-- {
+		content = content[:locs[i][0]] + replacement + content[locs[i][1]:]
+	}
+	return content, len(locs) > 0
+}
+
+// disableMicrotypeExpansion sets protrusion=false and expansion=false on every uncommented
+// microtype load of the preamble, keeping its other options
+func disableMicrotypeExpansion(content string) (string, bool) {
+	content, changed := rewritePreambleLoads(content, func(line string, loc []int) (string, bool) {
+		options, names := loadParts(line, loc)
+		if !slices.Contains(names, "microtype") {
+			return line, false
+		}
+		var kept []string
+		disabled := 0
+		for _, opt := range splitOptions(options) {
+			key, value, _ := strings.Cut(opt, "=")
+			if key = strings.TrimSpace(key); key != "protrusion" && key != "expansion" {
+				kept = append(kept, opt)
+			} else if strings.TrimSpace(value) == "false" {
+				disabled++
+			}
+		}
+		if disabled == 2 {
+			return line, false
+		}
+		fixed := usepackageLine(strings.Join(append([]string{"protrusion=false", "expansion=false"}, kept...), ","), []string{"microtype"})
+		if others := withoutPackage(names, "microtype"); len(others) > 0 {
+			fixed = usepackageLine(options, others) + fixed
+		}
+		return line[:loc[0]] + fixed + line[loc[1]:], true
+	})
+	if changed {
+		logger.Info("fixed microtype package for XeLaTeX compatibility")
+	}
+	return content, changed
+}
+
+// disableLegacyFontenc comments out fontenc loaded with encodings other than TU, the encoding
+// of the Unicode engines' fonts
+func disableLegacyFontenc(content string) (string, bool) {
+	return disablePackage(content, "fontenc", unicodeEngineNote, func(options string) bool {
+		return !slices.Contains(splitOptions(options), "TU")
+	})
+}
+
+// disableInputenc comments out inputenc, the Unicode engines read UTF-8 and ignore it
+func disableInputenc(content string) (string, bool) {
+	return disablePackage(content, "inputenc", unicodeEngineNote, nil)
+}
+
+// disableLegacyFontPackages comments out the Type 1 font packages of legacyFontPackages
+func disableLegacyFontPackages(content string) (string, bool) {
+	changed := false
+	for _, name := range legacyFontPackages {
+		var disabled bool
+		content, disabled = disablePackage(content, name, unicodeEngineNote, nil)
+		changed = changed || disabled
+	}
+	return content, changed
+}
+
+// disableUnicodePackages comments out the CJK and font packages and font commands that need
+// XeLaTeX or LuaLaTeX
+func disableUnicodePackages(content string) (string, bool) {
+	changed := false
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if unicodeCJKPackagePattern.MatchString(line) || unicodeFontCommandPattern.MatchString(line) {
+			lines[i] = "% " + line + " % disabled for pdfLaTeX"
+			changed = true
+		}
+	}
+	return strings.Join(lines, "\n"), changed
+}
+
+// disablePackage comments out the uncommented loads of a package in the preamble whose
+// options satisfy match (every load when match is nil). A package loaded in a list, or on a
+// line with other commands, is removed from the line and commented out on the next one.
+func disablePackage(content, name, note string, match func(options string) bool) (string, bool) {
+	return rewritePreambleLoads(content, func(line string, loc []int) (string, bool) {
+		options, names := loadParts(line, loc)
+		if !slices.Contains(names, name) || (match != nil && !match(options)) {
+			return line, false
+		}
+		logger.Info("commented out package", logger.String("package", name), logger.String("note", note))
+		others := withoutPackage(names, name)
+		if len(others) == 0 && strings.TrimSpace(codeOf(line)) == line[loc[0]:loc[1]] {
+			trimmed := strings.TrimLeft(line, " \t")
+			return line[:len(line)-len(trimmed)] + "% " + trimmed + " % " + note, true
+		}
+		replacement := ""
+		if len(others) > 0 {
+			replacement = usepackageLine(options, others)
+		}
+		return line[:loc[0]] + replacement + line[loc[1]:] + "\n% " + usepackageLine(options, []string{name}) + " % " + note, true
+	})
+}
+
+// rewritePreambleLoads calls rewrite for each uncommented \usepackage of the preamble, with
+// its line and the submatch indexes of usepackagePattern in it. The loads of a line are
+// passed last first, so rewrite may change the line after loc[0].
+func rewritePreambleLoads(content string, rewrite func(line string, loc []int) (string, bool)) (string, bool) {
+	changed := false
+	lines := strings.Split(content, "\n")
+	for i := range lines {
+		code := codeOf(lines[i])
+		if strings.Contains(code, "\\begin{document}") {
+			break
+		}
+		locs := usepackagePattern.FindAllStringSubmatchIndex(code, -1)
+		for j := len(locs) - 1; j >= 0; j-- {
+			if fixed, ok := rewrite(lines[i], locs[j]); ok {
+				lines[i] = fixed
+				changed = true
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), changed
+}
+
+// codeOf returns a line without its comment
+func codeOf(line string) string {
+	if i := commentStart(line); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// loadParts returns the options and the packages of the \usepackage at loc in line
+func loadParts(line string, loc []int) (string, []string) {
+	options := ""
+	if loc[2] >= 0 {
+		options = line[loc[2]:loc[3]]
+	}
+	return options, splitOptions(line[loc[4]:loc[5]])
+}
+
+// splitOptions splits a comma-separated list of options or packages
+func splitOptions(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// withoutPackage returns the packages of names other than name
+func withoutPackage(names []string, name string) []string {
+	return slices.DeleteFunc(slices.Clone(names), func(n string) bool { return n == name })
+}
+
+// usepackageLine returns the \usepackage loading names with options
+func usepackageLine(options string, names []string) string {
+	if options == "" {
+		return "\\usepackage{" + strings.Join(names, ",") + "}"
+	}
+	return "\\usepackage[" + options + "]{" + strings.Join(names, ",") + "}"
+}
+
+// hasUncommentedPackage reports whether an uncommented \usepackage loads the package, alone
+// or in a list, with or without options. Lines like "% \usepackage{ctex}" do not count.
+func hasUncommentedPackage(content, name string) bool {
+	for _, m := range usepackagePattern.FindAllStringSubmatch(uncommentedText(content), -1) {
+		if slices.Contains(splitOptions(m[2]), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertAfterDocumentclass inserts text on the line after the first uncommented
+// \documentclass, which may span several lines. Returns false when there is none.
+func insertAfterDocumentclass(content, text string) (string, bool) {
+	loc := documentClassLinePattern.FindStringIndex(uncommentedText(content))
+	if loc == nil {
+		return content, false
+	}
+	end := strings.Index(content[loc[1]:], "\n")
+	if end < 0 {
+		return content + "\n" + text, true
+	}
+	end += loc[1] + 1
+	return content[:end] + text + "\n" + content[end:], true
+}
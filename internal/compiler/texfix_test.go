@@ -0,0 +1,247 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChineseSupportRoute(t *testing.T) {
+	tests := []struct {
+		class  string
+		engine string
+		want   string
+	}{
+		{"article", "", RouteCtex},
+		{"article", CompilerXeLaTeX, RouteCtex},
+		{"article", CompilerLuaLaTeX, RouteLuatexja},
+		{"IEEEtran", CompilerXeLaTeX, RouteXeCJK},
+		{"IEEEtran", CompilerLuaLaTeX, RouteLuatexja},
+		{"revtex4-2", CompilerXeLaTeX, RouteXeCJK},
+		{"revtex4-1", "", RouteXeCJK},
+		{"acmart", CompilerXeLaTeX, RouteXeCJK},
+		{"acmart", CompilerLuaLaTeX, RouteLuatexja},
+		{"revtex4", CompilerLuaLaTeX, RouteLuatexja},
+	}
+	for _, tt := range tests {
+		if got := ChineseSupportRoute(tt.class, tt.engine); got != tt.want {
+			t.Errorf("ChineseSupportRoute(%q, %q) = %q, want %q", tt.class, tt.engine, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureCJKSupport(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		engine  string
+		want    []string // in the output, in order
+		unwant  []string
+	}{
+		{
+			name:    "article with XeLaTeX",
+			content: "\\documentclass{article}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"\\documentclass{article}\n\\usepackage{ctex}\n"},
+			unwant:  []string{"xeCJK", "luatexja"},
+		},
+		{
+			name:    "article with LuaLaTeX",
+			content: "\\documentclass{article}\n\\begin{document}",
+			engine:  CompilerLuaLaTeX,
+			want: []string{
+				"\\documentclass{article}\n" + luatexjaSetupMarker + "\n\\usepackage{luatexja-fontspec}\n",
+				"\\IfFontExistsTF{Noto Sans CJK SC}{\\setmainjfont{Noto Sans CJK SC}}{%\n",
+				"\\setmainjfont{FandolSong-Regular.otf}}}}}\n\\begin{document}",
+			},
+			unwant: []string{"{ctex}", "xeCJK"},
+		},
+		{
+			name:    "acmart with XeLaTeX",
+			content: "\\documentclass[sigconf]{acmart}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{xeCJKSetupMarker + "\n\\usepackage[CJKmath=true]{xeCJK}\n\\IfFontExistsTF{Noto Sans CJK SC}{\\setCJKmainfont{Noto Sans CJK SC}}"},
+			unwant:  []string{"{ctex}", "luatexja"},
+		},
+		{
+			name:    "revtex with ctex already loaded",
+			content: "\\documentclass{revtex4-2}\n\\usepackage[UTF8]{ctex}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"\\documentclass{revtex4-2}\n\\usepackage[UTF8]{ctex}\n\\begin{document}"},
+			unwant:  []string{"xeCJK"},
+		},
+		{
+			name:    "LuaLaTeX with luatexja-fontspec already loaded",
+			content: "\\documentclass{article}\n\\usepackage{fontspec,luatexja-fontspec}\n\\begin{document}",
+			engine:  CompilerLuaLaTeX,
+			want:    []string{"\\documentclass{article}\n\\usepackage{fontspec,luatexja-fontspec}\n\\begin{document}"},
+			unwant:  []string{luatexjaSetupMarker},
+		},
+		{
+			name:    "commented documentclass",
+			content: "% \\documentclass{IEEEtran}\n\\documentclass{article}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"% \\documentclass{IEEEtran}\n\\documentclass{article}\n\\usepackage{ctex}\n\\begin{document}"},
+			unwant:  []string{"xeCJK"},
+		},
+		{
+			name:    "documentclass over several lines",
+			content: "\\documentclass[\n  sigconf,\n  review]{acmart}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"  review]{acmart}\n" + xeCJKSetupMarker + "\n"},
+		},
+		{
+			name:    "multiple documentclass lines",
+			content: "\\documentclass{article}\n\\iffalse\n\\documentclass{book}\n\\fi\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"\\documentclass{article}\n\\usepackage{ctex}\n\\iffalse\n\\documentclass{book}\n\\fi"},
+		},
+		{
+			name:    "ctex with options in a list",
+			content: "\\documentclass{article}\n\\usepackage[UTF8, scheme=plain]{amsmath, ctex}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"\\documentclass{article}\n\\usepackage[UTF8, scheme=plain]{amsmath, ctex}\n\\begin{document}"},
+		},
+		{
+			name:    "ctex class",
+			content: "\\documentclass[UTF8]{ctexart}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"\\documentclass[UTF8]{ctexart}\n\\begin{document}"},
+			unwant:  []string{"{ctex}"},
+		},
+		{
+			name:    "package with a longer name",
+			content: "\\documentclass{article}\n\\usepackage{ctexhook}\n\\begin{document}",
+			engine:  CompilerXeLaTeX,
+			want:    []string{"\\documentclass{article}\n\\usepackage{ctex}\n\\usepackage{ctexhook}\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EnsureCJKSupport(tt.content, tt.engine)
+			rest := got
+			for _, want := range tt.want {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("output is missing %q (in order):\n%s", want, got)
+				}
+				rest = rest[i+len(want):]
+			}
+			for _, unwant := range tt.unwant {
+				if strings.Contains(got, unwant) {
+					t.Errorf("output contains %q:\n%s", unwant, got)
+				}
+			}
+			if again := EnsureCJKSupport(got, tt.engine); again != got {
+				t.Errorf("Chinese support added twice:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestEnsureCtexPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{
+			name: "commented ctex",
+			doc:  "% \\usepackage{ctex}\n\\documentclass{article}\n\\usepackage{microtype}\n\\usepackage{CJKutf8}\n",
+			want: "% \\usepackage{ctex}\n\\documentclass{article}\n\\usepackage{ctex}\n\\usepackage[protrusion=false,expansion=false]{microtype}\n% \\usepackage{CJKutf8} % Commented out - using ctex instead\n",
+		},
+		{
+			name: "CJKutf8 in a list",
+			doc:  "\\documentclass{article}\n\\usepackage{ctex}\n\\usepackage{amsmath,CJKutf8} % packages\n\\begin{document}\n\\begin{CJK*}{UTF8}{gbsn}\n中文\n\\end{CJK*}\n\\end{document}",
+			want: "\\documentclass{article}\n\\usepackage{ctex}\n\\usepackage{amsmath} % packages\n% \\usepackage{CJKutf8} % Commented out - using ctex instead\n\\begin{document}\n% CJK* environment removed - using ctex instead\n中文\n% \\end{CJK*} removed\n\\end{document}",
+		},
+		{
+			name: "commented CJKutf8 before the active one",
+			doc:  "\\documentclass{article}\n% \\usepackage{CJKutf8}\n  \\usepackage[overlap]{CJKutf8}\n",
+			want: "\\documentclass{article}\n\\usepackage{ctex}\n% \\usepackage{CJKutf8}\n  % \\usepackage[overlap]{CJKutf8} % Commented out - using ctex instead\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EnsureCtexPackage(tt.doc)
+			if got != tt.want {
+				t.Errorf("EnsureCtexPackage() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if again := EnsureCtexPackage(got); again != got {
+				t.Errorf("second EnsureCtexPackage() changed the document:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestFixMicrotype(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"without options", "\\usepackage{microtype}", "\\usepackage[protrusion=false,expansion=false]{microtype}"},
+		{"other options kept", "\\usepackage[final, tracking=true]{microtype}", "\\usepackage[protrusion=false,expansion=false,final,tracking=true]{microtype}"},
+		{"enabled options replaced", "\\usepackage[protrusion=true,expansion]{microtype}", "\\usepackage[protrusion=false,expansion=false]{microtype}"},
+		{"already disabled", "\\usepackage[expansion=false,protrusion=false]{microtype}", "\\usepackage[expansion=false,protrusion=false]{microtype}"},
+		{"in a list", "\\usepackage[T1]{fontenc}\n\\usepackage{amsmath,microtype}", "\\usepackage[T1]{fontenc}\n\\usepackage{amsmath}\\usepackage[protrusion=false,expansion=false]{microtype}"},
+		{"commented", "% \\usepackage{microtype}\n\\usepackage{amsmath} % \\usepackage{microtype}", "% \\usepackage{microtype}\n\\usepackage{amsmath} % \\usepackage{microtype}"},
+		{"after begin document", "\\begin{document}\n\\usepackage{microtype}", "\\begin{document}\n\\usepackage{microtype}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FixMicrotype(tt.doc)
+			if got != tt.want {
+				t.Errorf("FixMicrotype() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if again := FixMicrotype(got); again != got {
+				t.Errorf("second FixMicrotype() changed the document:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestDisableIncompatiblePackages(t *testing.T) {
+	doc := "\\documentclass{article}\n" +
+		"\\usepackage{ctex}\n" +
+		"\\usepackage[T1]{fontenc}\n" +
+		"\\usepackage[TU]{fontenc}\n" +
+		"\\usepackage[latin1]{inputenc}\n" +
+		"\\usepackage{times,amsmath}\n" +
+		"% \\usepackage{mathptmx}\n" +
+		"\\begin{document}\n" +
+		"\\end{document}\n"
+	tests := []struct {
+		engine string
+		want   string
+	}{
+		{CompilerXeLaTeX, "\\documentclass{article}\n" +
+			"\\usepackage{ctex}\n" +
+			"% \\usepackage[T1]{fontenc} % disabled for XeLaTeX/LuaLaTeX\n" +
+			"\\usepackage[TU]{fontenc}\n" +
+			"% \\usepackage[latin1]{inputenc} % disabled for XeLaTeX/LuaLaTeX\n" +
+			"\\usepackage{amsmath}\n% \\usepackage{times} % disabled for XeLaTeX/LuaLaTeX\n" +
+			"% \\usepackage{mathptmx}\n" +
+			"\\begin{document}\n" +
+			"\\end{document}\n"},
+		{CompilerPDFLaTeX, "\\documentclass{article}\n" +
+			"% \\usepackage{ctex} % disabled for pdfLaTeX\n" +
+			"\\usepackage[T1]{fontenc}\n" +
+			"\\usepackage[TU]{fontenc}\n" +
+			"\\usepackage[latin1]{inputenc}\n" +
+			"\\usepackage{times,amsmath}\n" +
+			"% \\usepackage{mathptmx}\n" +
+			"\\begin{document}\n" +
+			"\\end{document}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.engine, func(t *testing.T) {
+			got := DisableIncompatiblePackages(doc, tt.engine)
+			if got != tt.want {
+				t.Errorf("DisableIncompatiblePackages() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if again := DisableIncompatiblePackages(got, tt.engine); again != got {
+				t.Errorf("second DisableIncompatiblePackages() changed the document:\n%s", again)
+			}
+		})
+	}
+}
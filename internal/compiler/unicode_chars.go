@@ -0,0 +1,225 @@
+package compiler
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// declareUnicodePattern matches \DeclareUnicodeCharacter{hex}{replacement}; the replacement
+// may contain one level of nested braces
+var declareUnicodePattern = regexp.MustCompile(`\\DeclareUnicodeCharacter\s*\{([0-9A-Fa-f]{2,6})\}\s*\{((?:[^{}]|\{[^{}]*\})*)\}`)
+
+// activeQuoteCatcodePattern matches catcode changes that make " active:
+// \catcode`\"=13, \catcode`"=\active, \catcode34=13
+var activeQuoteCatcodePattern = regexp.MustCompile("\\\\catcode\\s*(?:`\\\\?\"|34)\\s*=\\s*(?:13|\\\\active)")
+
+// babelQuoteShorthandPattern matches babel languages whose shorthands make " active
+var babelQuoteShorthandPattern = regexp.MustCompile(`\\usepackage\s*\[[^\]]*\b(?:n?german|n?austrian|n?swissgerman|dutch|afrikaans|finnish|swedish|danish|icelandic|portuges|portuguese|brazil|catalan|czech|slovak|russian|ukrainian|polish)\b[^\]]*\]\s*\{babel\}|\\useshorthands\*?\s*\{"\}`)
+
+// activeTildePattern matches redefinitions of ~ as an active character
+var activeTildePattern = regexp.MustCompile("\\\\catcode\\s*(?:`\\\\?~|126)\\s*=|\\\\(?:re)?newcommand\\s*\\{?~|\\\\def\\s*~")
+
+// quotedChinesePattern matches ASCII double quotes around text containing Chinese
+var quotedChinesePattern = regexp.MustCompile(`"([^"\n]*\p{Han}[^"\n]*)"`)
+
+// newunicodecharPackage loads the XeLaTeX replacement of \DeclareUnicodeCharacter
+const newunicodecharPackage = `\usepackage{newunicodechar}`
+
+// UnicodeDeclarations are the declarations of a document that change how input characters
+// are typeset; after translation they can conflict with ctex/xeCJK
+type UnicodeDeclarations struct {
+	Declared    []rune   // characters declared with \DeclareUnicodeCharacter
+	ActiveChars []string // characters made active by \catcode or babel shorthands
+}
+
+// Empty reports whether the document declares nothing
+func (d UnicodeDeclarations) Empty() bool {
+	return len(d.Declared) == 0 && len(d.ActiveChars) == 0
+}
+
+// uncommentedText returns content with comment lines and trailing comments blanked out
+// (same length, so positions stay valid)
+func uncommentedText(content string) string {
+	var sb strings.Builder
+	sb.Grow(len(content))
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if i := commentStart(line); i >= 0 {
+			sb.WriteString(line[:i])
+			sb.WriteString(strings.Repeat(" ", len(strings.TrimRight(line[i:], "\n"))))
+			if strings.HasSuffix(line, "\n") {
+				sb.WriteByte('\n')
+			}
+			continue
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// commentStart returns the index of the unescaped % of a line, or -1
+func commentStart(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '%':
+			return i
+		}
+	}
+	return -1
+}
+
+// DetectUnicodeDeclarations finds the \DeclareUnicodeCharacter declarations and active
+// characters of a document, ignoring comments
+func DetectUnicodeDeclarations(content string) UnicodeDeclarations {
+	text := uncommentedText(content)
+	var d UnicodeDeclarations
+	for _, m := range declareUnicodePattern.FindAllStringSubmatch(text, -1) {
+		if code, err := strconv.ParseInt(m[1], 16, 32); err == nil {
+			d.Declared = append(d.Declared, rune(code))
+		}
+	}
+	if activeQuoteCatcodePattern.MatchString(text) || babelQuoteShorthandPattern.MatchString(text) {
+		d.ActiveChars = append(d.ActiveChars, `"`)
+	}
+	if activeTildePattern.MatchString(text) {
+		d.ActiveChars = append(d.ActiveChars, "~")
+	}
+	return d
+}
+
+// HasActiveQuote reports whether a document makes " an active character
+func HasActiveQuote(content string) bool {
+	for _, c := range DetectUnicodeDeclarations(content).ActiveChars {
+		if c == `"` {
+			return true
+		}
+	}
+	return false
+}
+
+// chineseTextRune reports whether Chinese text uses a character, so XeLaTeX must typeset
+// it with the CJK font instead of the replacement of a \DeclareUnicodeCharacter
+func chineseTextRune(r rune) bool {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return true
+	case r >= 0x3000 && r <= 0x303F: // CJK punctuation
+		return true
+	case r >= 0xFF00 && r <= 0xFFEF: // full-width forms
+		return true
+	case r >= 0x2010 && r <= 0x2027: // dashes, curly quotes, ellipsis
+		return true
+	case r == 0x00B7: // middle dot
+		return true
+	}
+	return false
+}
+
+// NeutralizeUnicodeDeclarations adapts the \DeclareUnicodeCharacter declarations of a
+// document for XeLaTeX, where they are an inputenc feature that either fails or changes
+// how Chinese text is typeset. Declarations of characters Chinese text uses (quotes,
+// dashes, CJK punctuation) and declarations with an empty replacement (zero-width
+// characters) are commented out; the others are still needed for their symbol and become
+// \newunicodechar, with the package loaded before the first of them.
+func NeutralizeUnicodeDeclarations(content string) (string, bool) {
+	if !strings.Contains(content, `\DeclareUnicodeCharacter`) {
+		return content, false
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	changed := false
+	firstConverted := -1
+	for i, line := range lines {
+		code := line
+		if c := commentStart(line); c >= 0 {
+			code = line[:c]
+		}
+		if !declareUnicodePattern.MatchString(code) {
+			continue
+		}
+
+		var dropped []string
+		converted := declareUnicodePattern.ReplaceAllStringFunc(code, func(decl string) string {
+			m := declareUnicodePattern.FindStringSubmatch(decl)
+			value, err := strconv.ParseInt(m[1], 16, 32)
+			if err != nil {
+				return decl
+			}
+			r := rune(value)
+			replacement := strings.TrimSpace(m[2])
+			if chineseTextRune(r) || replacement == "" || replacement == `\relax` {
+				dropped = append(dropped, decl)
+				return ""
+			}
+			if firstConverted < 0 {
+				firstConverted = i
+			}
+			return `\newunicodechar{` + string(r) + `}{` + m[2] + `}`
+		})
+
+		newLine := converted + line[len(code):]
+		if len(dropped) > 0 {
+			note := "% " + strings.Join(dropped, " ") + " % not needed with XeLaTeX"
+			if strings.TrimSpace(converted) == "" {
+				newLine = strings.TrimRight(line[:len(line)-len(strings.TrimLeft(line, " \t"))], "\n") + note + lineEnding(line)
+			} else {
+				newLine = strings.TrimRight(newLine, "\n") + " " + note + lineEnding(line)
+			}
+		}
+		if newLine != line {
+			lines[i] = newLine
+			changed = true
+		}
+	}
+	if !changed {
+		return content, false
+	}
+
+	if firstConverted >= 0 && !strings.Contains(content, "{newunicodechar}") {
+		// The package must be loaded in the preamble
+		at := firstConverted
+		for i := 0; i < firstConverted; i++ {
+			if strings.Contains(lines[i], `\begin{document}`) && commentStart(lines[i]) != 0 {
+				at = i
+				break
+			}
+		}
+		lines[at] = newunicodecharPackage + "\n" + lines[at]
+	}
+	return strings.Join(lines, ""), true
+}
+
+// lineEnding returns the newline of a line, if it has one
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// ReplaceQuotedChinese replaces ASCII double quotes around Chinese text with Chinese
+// quotes. Where " is active (babel shorthands of German, Dutch, ... or a \catcode change)
+// the shorthand acts on the first Chinese character, which gives odd spacing around every
+// quote or errors such as "Argument of \language@active@arg" has an extra }".
+func ReplaceQuotedChinese(content string) (string, bool) {
+	lines := strings.SplitAfter(content, "\n")
+	changed := false
+	for i, line := range lines {
+		code := line
+		if c := commentStart(line); c >= 0 {
+			code = line[:c]
+		}
+		if !quotedChinesePattern.MatchString(code) {
+			continue
+		}
+		lines[i] = quotedChinesePattern.ReplaceAllString(code, "“$1”") + line[len(code):]
+		changed = true
+	}
+	if !changed {
+		return content, false
+	}
+	return strings.Join(lines, ""), true
+}
@@ -11,6 +11,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
@@ -21,7 +22,9 @@ import (
 
 	"latex-translator/internal/license"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
 	"latex-translator/internal/types"
+	"latex-translator/internal/workdir"
 )
 
 const (
@@ -45,6 +48,18 @@ const (
 	// DefaultConcurrency is the default translation concurrency
 	// Used for both LaTeX and PDF translation concurrent batch processing
 	DefaultConcurrency = 3
+	// DefaultMaxNetworkPauseMinutes is how long translation waits for a network outage to end
+	DefaultMaxNetworkPauseMinutes = 10
+	// DefaultStallWindowSeconds is how long a chunk request may go without a response
+	// before it is restarted
+	DefaultStallWindowSeconds = 180
+	// DefaultRequestTimeoutSeconds is how long one translation request may take before it is
+	// cancelled and retried
+	DefaultRequestTimeoutSeconds = 120
+	// DefaultMaxConcurrentCompiles is the default number of LaTeX processes running at the same time
+	DefaultMaxConcurrentCompiles = 2
+	// MaxConcurrentCompilesLimit is the highest accepted number of concurrent LaTeX processes
+	MaxConcurrentCompilesLimit = 16
 	// DefaultLibraryPageSize is the default number of papers to display per page in library browser
 	DefaultLibraryPageSize = 20
 	// localEncryptionSecret is the app-specific secret for local encryption
@@ -244,6 +259,12 @@ func (m *ConfigManager) Load() error {
 		m.config.Concurrency = DefaultConcurrency
 	}
 
+	// Route all HTTP clients through the configured proxy
+	if err := netproxy.SetProxy(m.config.Proxy); err != nil {
+		logger.Warn("invalid proxy in config file, using the environment", logger.Err(err))
+		netproxy.SetProxy("")
+	}
+
 	return nil
 }
 
@@ -434,6 +455,525 @@ func (m *ConfigManager) GetConcurrency() int {
 	return DefaultConcurrency
 }
 
+// GetMaxNetworkPause returns how long translation pauses waiting for connectivity
+// to return before failing the job.
+func (m *ConfigManager) GetMaxNetworkPause() time.Duration {
+	if m.config != nil && m.config.MaxNetworkPauseMinutes > 0 {
+		return time.Duration(m.config.MaxNetworkPauseMinutes) * time.Minute
+	}
+	return DefaultMaxNetworkPauseMinutes * time.Minute
+}
+
+// GetStallWindow returns how long a chunk request may go without a response before it is
+// cancelled and retried on a fresh connection.
+func (m *ConfigManager) GetStallWindow() time.Duration {
+	if m.config != nil && m.config.StallWindowSeconds > 0 {
+		return time.Duration(m.config.StallWindowSeconds) * time.Second
+	}
+	return DefaultStallWindowSeconds * time.Second
+}
+
+// GetRequestTimeout returns how long one translation request may take before it is
+// cancelled and retried.
+func (m *ConfigManager) GetRequestTimeout() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil && m.config.RequestTimeoutSeconds > 0 {
+		return time.Duration(m.config.RequestTimeoutSeconds) * time.Second
+	}
+	return DefaultRequestTimeoutSeconds * time.Second
+}
+
+// SetRequestTimeout saves the timeout of one translation request in seconds; 0 restores
+// the default
+func (m *ConfigManager) SetRequestTimeout(seconds int) error {
+	if seconds < 0 {
+		return types.NewAppError(types.ErrInvalidInput, "请求超时不能为负数", nil)
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.RequestTimeoutSeconds = seconds
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetTranslationBudget returns the budget of one translation run of a document: the
+// wall-clock time and the tokens after which it is stopped, 0 when unlimited.
+func (m *ConfigManager) GetTranslationBudget() (time.Duration, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config == nil {
+		return 0, 0
+	}
+	return time.Duration(m.config.BudgetMinutes) * time.Minute, m.config.BudgetTokens
+}
+
+// SetTranslationBudget saves the budget of one translation run of a document in minutes
+// and tokens; 0 removes a limit
+func (m *ConfigManager) SetTranslationBudget(minutes, tokens int) error {
+	if minutes < 0 || tokens < 0 {
+		return types.NewAppError(types.ErrInvalidInput, "翻译预算不能为负数", nil)
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.BudgetMinutes = minutes
+	m.config.BudgetTokens = tokens
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetMaxConcurrentCompiles returns how many LaTeX processes may run at the same time
+// across all features (translation, fix loop, per-chapter and bilingual compiles).
+func (m *ConfigManager) GetMaxConcurrentCompiles() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil && m.config.MaxConcurrentCompiles > 0 {
+		return m.config.MaxConcurrentCompiles
+	}
+	return DefaultMaxConcurrentCompiles
+}
+
+// SetMaxConcurrentCompiles validates and saves the maximum number of concurrent LaTeX processes
+func (m *ConfigManager) SetMaxConcurrentCompiles(n int) error {
+	if n < 1 || n > MaxConcurrentCompilesLimit {
+		return types.NewAppError(types.ErrInvalidInput,
+			fmt.Sprintf("同时编译数必须在 1 到 %d 之间", MaxConcurrentCompilesLimit), nil)
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.MaxConcurrentCompiles = n
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetStrictFontEmbedding returns whether produced PDFs must embed all fonts (archival output)
+func (m *ConfigManager) GetStrictFontEmbedding() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config != nil && m.config.StrictFontEmbedding
+}
+
+// SetStrictFontEmbedding saves whether produced PDFs must embed all fonts
+func (m *ConfigManager) SetStrictFontEmbedding(strict bool) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.StrictFontEmbedding = strict
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetTranslateComments returns whether full-line comments are sent to the model; by default
+// they are kept out of the chunks and put back verbatim
+func (m *ConfigManager) GetTranslateComments() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config != nil && m.config.TranslateComments
+}
+
+// SetTranslateComments saves whether full-line comments are sent to the model
+func (m *ConfigManager) SetTranslateComments(translate bool) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.TranslateComments = translate
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetTranslateBibliography returns whether the venues and notes of \bibitem entries are
+// translated; by default reference lists are kept verbatim
+func (m *ConfigManager) GetTranslateBibliography() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config != nil && m.config.TranslateBibliography
+}
+
+// SetTranslateBibliography saves whether the venues and notes of \bibitem entries are translated
+func (m *ConfigManager) SetTranslateBibliography(translate bool) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.TranslateBibliography = translate
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetChunkOverlap returns whether each chunk is sent with the last paragraph of the
+// previous one as read-only context; off by default
+func (m *ConfigManager) GetChunkOverlap() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config != nil && m.config.ChunkOverlap
+}
+
+// SetChunkOverlap saves whether each chunk is sent with the end of the previous one as context
+func (m *ConfigManager) SetChunkOverlap(overlap bool) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.ChunkOverlap = overlap
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetIgnoreIncludeOnly returns whether the \includeonly directive of a main file is removed
+// so every file is translated and compiled; off by default
+func (m *ConfigManager) GetIgnoreIncludeOnly() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config != nil && m.config.IgnoreIncludeOnly
+}
+
+// SetIgnoreIncludeOnly saves whether the \includeonly directive of a main file is removed
+func (m *ConfigManager) SetIgnoreIncludeOnly(ignore bool) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.IgnoreIncludeOnly = ignore
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetAutoInstallPackages returns whether packages missing from the TeX distribution are
+// installed while compiling; off by default
+func (m *ConfigManager) GetAutoInstallPackages() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config != nil && m.config.AutoInstallPackages
+}
+
+// SetAutoInstallPackages saves whether packages missing from the TeX distribution are
+// installed while compiling
+func (m *ConfigManager) SetAutoInstallPackages(enabled bool) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.AutoInstallPackages = enabled
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetIndexSortKeys returns how the sort keys of translated index entries are generated:
+// "pinyin" (default) or "original"
+func (m *ConfigManager) GetIndexSortKeys() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil && strings.EqualFold(strings.TrimSpace(m.config.IndexSortKeys), "original") {
+		return "original"
+	}
+	return "pinyin"
+}
+
+// GetGlossaryPath returns the glossary file applied to translations, empty when none is set
+func (m *ConfigManager) GetGlossaryPath() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return strings.TrimSpace(m.config.GlossaryPath)
+	}
+	return ""
+}
+
+// SetGlossaryPath saves the glossary file applied to translations; empty removes it
+func (m *ConfigManager) SetGlossaryPath(path string) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.GlossaryPath = strings.TrimSpace(path)
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetPromptPreset returns the prompt preset of translations, "default" when none is set
+func (m *ConfigManager) GetPromptPreset() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil && strings.TrimSpace(m.config.PromptPreset) != "" {
+		return strings.TrimSpace(m.config.PromptPreset)
+	}
+	return "default"
+}
+
+// SetPromptPreset saves the prompt preset of translations
+func (m *ConfigManager) SetPromptPreset(preset string) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.PromptPreset = strings.TrimSpace(preset)
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetPromptTemplatePath returns the user's prompt template file, which replaces the
+// preset; empty when none is set
+func (m *ConfigManager) GetPromptTemplatePath() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return strings.TrimSpace(m.config.PromptTemplatePath)
+	}
+	return ""
+}
+
+// GetDebugCaptureDir returns the directory the prompts and responses of failed chunks are
+// saved to, empty when the capture is off
+func (m *ConfigManager) GetDebugCaptureDir() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return strings.TrimSpace(m.config.DebugCaptureDir)
+	}
+	return ""
+}
+
+// SetDebugCaptureDir saves the directory the prompts and responses of failed chunks are
+// saved to; empty turns the capture off
+func (m *ConfigManager) SetDebugCaptureDir(dir string) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.DebugCaptureDir = strings.TrimSpace(dir)
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetOnCompleteHook returns the webhook URL or command notified when a translation
+// finishes or fails, empty when none is set
+func (m *ConfigManager) GetOnCompleteHook() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return strings.TrimSpace(m.config.OnCompleteHook)
+	}
+	return ""
+}
+
+// SetOnCompleteHook saves the webhook URL or command notified when a translation finishes
+// or fails; empty removes it
+func (m *ConfigManager) SetOnCompleteHook(hook string) error {
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.OnCompleteHook = strings.TrimSpace(hook)
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetRequestShape returns the adjustments of the translation requests for servers that
+// reject some OpenAI parameters
+func (m *ConfigManager) GetRequestShape() types.RequestShape {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil && m.config.RequestShape != nil {
+		shape := *m.config.RequestShape
+		shape.MaxTokensField = shape.TokensField()
+		return shape
+	}
+	return types.RequestShape{MaxTokensField: types.MaxTokensFieldDefault}
+}
+
+// SetRequestShape saves the adjustments of the translation requests; the defaults remove
+// them from the config file
+func (m *ConfigManager) SetRequestShape(shape types.RequestShape) error {
+	shape.MaxTokensField = shape.TokensField()
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	if shape == (types.RequestShape{MaxTokensField: types.MaxTokensFieldDefault}) {
+		m.config.RequestShape = nil
+	} else {
+		m.config.RequestShape = &shape
+	}
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetWorkspacePolicy returns the cleanup policy of the paper directories in the work directory
+func (m *ConfigManager) GetWorkspacePolicy() types.WorkspacePolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil && m.config.WorkspacePolicy != nil {
+		return *m.config.WorkspacePolicy
+	}
+	return workdir.DefaultPolicy
+}
+
+// SetWorkspacePolicy saves the cleanup policy of the paper directories; the default removes
+// it from the config file
+func (m *ConfigManager) SetWorkspacePolicy(policy types.WorkspacePolicy) error {
+	if policy.KeepRecent < 0 || policy.MaxAgeDays < 0 || policy.MaxSizeGB < 0 {
+		return types.NewAppError(types.ErrInvalidInput, "清理策略的限制不能为负数", nil)
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	if policy == workdir.DefaultPolicy {
+		m.config.WorkspacePolicy = nil
+	} else {
+		m.config.WorkspacePolicy = &policy
+	}
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetProvider returns the LLM provider of the API settings (OpenAI compatible by default)
+func (m *ConfigManager) GetProvider() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return types.NormalizeProvider(m.config.Provider)
+	}
+	return types.ProviderOpenAI
+}
+
+// SetProvider saves the LLM provider; the OpenAI default removes it from the config file
+func (m *ConfigManager) SetProvider(provider string) error {
+	provider = types.NormalizeProvider(provider)
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	if provider == types.ProviderOpenAI {
+		m.config.Provider = ""
+	} else {
+		m.config.Provider = provider
+	}
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetProxy returns the proxy of the config file, empty when the environment decides
+func (m *ConfigManager) GetProxy() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return strings.TrimSpace(m.config.Proxy)
+	}
+	return ""
+}
+
+// SetProxy validates and saves the proxy of all HTTP requests and applies it at once;
+// empty falls back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func (m *ConfigManager) SetProxy(proxy string) error {
+	proxy = strings.TrimSpace(proxy)
+	if err := netproxy.SetProxy(proxy); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.Proxy = proxy
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetChineseVariant returns the script of the translated Chinese text (simplified by default)
+func (m *ConfigManager) GetChineseVariant() types.ChineseVariant {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return types.NormalizeChineseVariant(m.config.ChineseVariant)
+	}
+	return types.ChineseSimplified
+}
+
+// GetChineseVariantPhrases returns the phrases that are rendered as configured instead of
+// being converted when translating to traditional Chinese
+func (m *ConfigManager) GetChineseVariantPhrases() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return m.config.ChineseVariantPhrases
+	}
+	return nil
+}
+
+// SetChineseVariant validates and saves the script of the translated Chinese text
+func (m *ConfigManager) SetChineseVariant(name string) error {
+	variant, err := types.ParseChineseVariant(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.ChineseVariant = string(variant)
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
+// GetTargetLanguage returns the language of the translation (Chinese by default)
+func (m *ConfigManager) GetTargetLanguage() types.TargetLanguage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		return types.NormalizeTargetLanguage(m.config.TargetLanguage)
+	}
+	return types.LanguageChinese
+}
+
+// SetTargetLanguage validates and saves the language of the translation
+func (m *ConfigManager) SetTargetLanguage(name string) error {
+	lang, err := types.ParseTargetLanguage(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	m.config.TargetLanguage = string(lang)
+	m.mu.Unlock()
+
+	return m.Save()
+}
+
 // GetLibraryPageSize returns the number of papers to display per page in library browser
 func (m *ConfigManager) GetLibraryPageSize() int {
 	if m.config != nil && m.config.LibraryPageSize > 0 {
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// knownContextWindows maps model name prefixes to the context window of the model in
+// tokens. A prefix matches a whole name component (see matchesModelPrefix) and the longest
+// matching prefix wins, so "gpt-4o-mini" is not taken for "gpt-4" or "gpt-4o". Users can
+// override or extend the table with model_context_windows in the config file.
+var knownContextWindows = map[string]int{
+	"gpt-3.5-turbo":     16385,
+	"gpt-4":             8192,
+	"gpt-4-32k":         32768,
+	"gpt-4-turbo":       128000,
+	"gpt-4o":            128000,
+	"gpt-4o-mini":       128000,
+	"gpt-4.1":           1047576,
+	"gpt-5":             400000,
+	"o1":                200000,
+	"o3":                200000,
+	"o4-mini":           200000,
+	"deepseek-chat":     64000,
+	"deepseek-reasoner": 64000,
+	"claude-":           200000,
+	"gemini-1.5":        1048576,
+	"gemini-2":          1048576,
+	"qwen-turbo":        131072,
+	"qwen-plus":         131072,
+	"qwen-max":          32768,
+	"glm-4":             128000,
+	"moonshot-v1-8k":    8192,
+	"moonshot-v1-32k":   32768,
+	"moonshot-v1-128k":  131072,
+}
+
+const (
+	// RecommendedContextWindowRatio is the share of the model maximum suggested as context
+	// window; the rest is left for the prompt overhead and the output
+	RecommendedContextWindowRatio = 0.6
+	// MaxRecommendedContextWindow caps the suggestion for very large windows: bigger
+	// batches do not translate better and run into output limits
+	MaxRecommendedContextWindow = 100000
+	// contextWindowTooSmallFactor flags windows this many times smaller than the
+	// recommended value as overly conservative
+	contextWindowTooSmallFactor = 4
+)
+
+// normalizeModelName lowercases a model name and drops a provider prefix such as
+// "openai/" used by routers
+func normalizeModelName(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return model
+}
+
+// matchesModelPrefix reports whether a model name starts with prefix as a whole component:
+// the name ends after the prefix or goes on with a separator, so "o1" matches "o1" and
+// "o1-mini" but not "o1x". A prefix ending with a separator, like "claude-", matches any
+// name that starts with it.
+func matchesModelPrefix(model, prefix string) bool {
+	if prefix == "" || !strings.HasPrefix(model, prefix) {
+		return false
+	}
+	if len(model) == len(prefix) || strings.ContainsRune(modelNameSeparators, rune(prefix[len(prefix)-1])) {
+		return true
+	}
+	return strings.ContainsRune(modelNameSeparators, rune(model[len(prefix)]))
+}
+
+// modelNameSeparators separate the components of a model name, as in "gpt-4.1-mini" or
+// "qwen-plus@2025"
+const modelNameSeparators = "-.:@_"
+
+// lookupContextWindow finds the longest prefix of the model name in a context window table
+func lookupContextWindow(table map[string]int, model string) (int, bool) {
+	best, window := "", 0
+	for prefix, size := range table {
+		prefix = normalizeModelName(prefix)
+		if size > 0 && matchesModelPrefix(model, prefix) && len(prefix) > len(best) {
+			best, window = prefix, size
+		}
+	}
+	return window, best != ""
+}
+
+// RecommendedContextWindow returns the context window suggested for a model with the
+// given maximum
+func RecommendedContextWindow(modelMax int) int {
+	recommended := int(float64(modelMax) * RecommendedContextWindowRatio)
+	if recommended > MaxRecommendedContextWindow {
+		recommended = MaxRecommendedContextWindow
+	}
+	return recommended
+}
+
+// ModelContextWindow returns the known context window of a model: the user's override
+// from model_context_windows first, then the built-in table
+func (m *ConfigManager) ModelContextWindow(model string) (int, bool) {
+	model = normalizeModelName(model)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		if window, ok := lookupContextWindow(m.config.ModelContextWindows, model); ok {
+			return window, true
+		}
+	}
+	return lookupContextWindow(knownContextWindows, model)
+}
+
+// CheckContextWindow compares a context window with the known size of the model and
+// suggests a value. Unknown models are never flagged; they get the window of an earlier
+// successful job as suggestion, if there was one.
+func (m *ConfigManager) CheckContextWindow(model string, contextWindow int) *types.ContextWindowAdvice {
+	advice := &types.ContextWindowAdvice{Model: model, Configured: contextWindow}
+
+	modelMax, known := m.ModelContextWindow(model)
+	if !known {
+		m.mu.RLock()
+		if m.config != nil {
+			advice.Recommended = m.config.LearnedContextWindows[normalizeModelName(model)]
+		}
+		m.mu.RUnlock()
+		advice.Learned = advice.Recommended > 0
+		return advice
+	}
+
+	advice.Known = true
+	advice.ModelMax = modelMax
+	advice.Recommended = RecommendedContextWindow(modelMax)
+	switch {
+	case contextWindow > modelMax:
+		advice.Mismatch = true
+		advice.Message = fmt.Sprintf("上下文窗口 %d 超出模型 %s 的上限 %d tokens，请求可能失败，推荐值: %d",
+			contextWindow, model, modelMax, advice.Recommended)
+	case contextWindow*contextWindowTooSmallFactor < advice.Recommended:
+		advice.Mismatch = true
+		advice.Message = fmt.Sprintf("上下文窗口 %d 远小于模型 %s 的 %d tokens，批次过小会增加请求次数和费用，推荐值: %d",
+			contextWindow, model, modelMax, advice.Recommended)
+	}
+	return advice
+}
+
+// CheckConfiguredContextWindow checks the configured context window against the
+// configured model
+func (m *ConfigManager) CheckConfiguredContextWindow() *types.ContextWindowAdvice {
+	return m.CheckContextWindow(m.GetModel(), m.GetContextWindow())
+}
+
+// ApplyRecommendedContextWindow sets the context window to the value suggested for the
+// configured model and saves the configuration
+func (m *ConfigManager) ApplyRecommendedContextWindow() (*types.ContextWindowAdvice, error) {
+	advice := m.CheckConfiguredContextWindow()
+	if advice.Recommended <= 0 {
+		return advice, types.NewAppError(types.ErrInvalidInput,
+			fmt.Sprintf("没有模型 %s 的推荐上下文窗口，请手动设置", advice.Model), nil)
+	}
+
+	m.mu.Lock()
+	m.config.ContextWindow = advice.Recommended
+	m.mu.Unlock()
+	if err := m.Save(); err != nil {
+		return advice, err
+	}
+
+	logger.Info("applied recommended context window",
+		logger.String("model", advice.Model),
+		logger.Int("previous", advice.Configured),
+		logger.Int("contextWindow", advice.Recommended))
+	return m.CheckConfiguredContextWindow(), nil
+}
+
+// RecordWorkingContextWindow remembers the context window of the first successful job
+// with a model that is not in the table, to suggest it later
+func (m *ConfigManager) RecordWorkingContextWindow(model string, contextWindow int) {
+	if contextWindow <= 0 {
+		return
+	}
+	if _, known := m.ModelContextWindow(model); known {
+		return
+	}
+	name := normalizeModelName(model)
+
+	m.mu.Lock()
+	if m.config == nil {
+		m.config = defaultConfig()
+	}
+	if _, ok := m.config.LearnedContextWindows[name]; ok {
+		m.mu.Unlock()
+		return
+	}
+	if m.config.LearnedContextWindows == nil {
+		m.config.LearnedContextWindows = make(map[string]int)
+	}
+	m.config.LearnedContextWindows[name] = contextWindow
+	m.mu.Unlock()
+
+	logger.Info("recorded working context window for unknown model",
+		logger.String("model", name),
+		logger.Int("contextWindow", contextWindow))
+	_ = m.Save()
+}
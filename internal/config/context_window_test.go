@@ -0,0 +1,200 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+// newTestConfigManager returns a ConfigManager saving to a temporary file, set to model
+// and contextWindow
+func newTestConfigManager(t *testing.T, model string, contextWindow int) *ConfigManager {
+	t.Helper()
+	m, err := NewConfigManager(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := defaultConfig()
+	config.OpenAIModel = model
+	config.ContextWindow = contextWindow
+	m.SetConfig(config)
+	return m
+}
+
+func TestModelContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int // 0 for unknown models
+	}{
+		{"gpt-4o-mini", 128000},
+		{"gpt-4o-2024-08-06", 128000},
+		{"GPT-4o", 128000},
+		{"openai/gpt-4o-mini", 128000},
+		{"gpt-4", 8192},
+		{"gpt-4-0613", 8192},
+		{"gpt-4-32k", 32768},
+		{"gpt-4-turbo-preview", 128000},
+		{"gpt-4.1-mini", 1047576},
+		{"gpt-3.5-turbo-0125", 16385},
+		{"o1", 200000},
+		{"o1-mini", 200000},
+		{"o3", 200000},
+		{"o3-mini-2025-01-31", 200000},
+		{"o4-mini", 200000},
+		{"claude-3-5-sonnet-20241022", 200000},
+		{"gemini-2.0-flash", 1048576},
+		{"deepseek-chat", 64000},
+		{"moonshot-v1-8k", 8192},
+		// Prefixes only match whole name components
+		{"o1x", 0},
+		{"o3pro", 0},
+		{"gpt-4x", 0},
+		{"glm-40", 0},
+		{"my-local-model", 0},
+		{"", 0},
+	}
+	m := newTestConfigManager(t, "gpt-4o-mini", DefaultContextWindow)
+	for _, tt := range tests {
+		got, known := m.ModelContextWindow(tt.model)
+		if known != (tt.want > 0) || got != tt.want {
+			t.Errorf("ModelContextWindow(%q) = %d, %v; want %d", tt.model, got, known, tt.want)
+		}
+	}
+}
+
+func TestModelContextWindowOverride(t *testing.T) {
+	m := newTestConfigManager(t, "gpt-4o-mini", DefaultContextWindow)
+	m.GetConfig().ModelContextWindows = map[string]int{"gpt-4o-mini": 64000, "my-model": 32000}
+
+	for model, want := range map[string]int{
+		"gpt-4o-mini":    64000,  // user value overrides the table
+		"gpt-4o":         128000, // other models keep the table
+		"my-model-v2":    32000,  // user prefixes extend the table
+		"my-modelling-x": 0,
+	} {
+		if got, _ := m.ModelContextWindow(model); got != want {
+			t.Errorf("ModelContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestRecommendedContextWindow(t *testing.T) {
+	for modelMax, want := range map[int]int{
+		8192:    4915,
+		128000:  76800,
+		200000:  100000, // capped
+		1047576: MaxRecommendedContextWindow,
+	} {
+		if got := RecommendedContextWindow(modelMax); got != want {
+			t.Errorf("RecommendedContextWindow(%d) = %d, want %d", modelMax, got, want)
+		}
+	}
+}
+
+func TestCheckContextWindow(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         string
+		contextWindow int
+		wantKnown     bool
+		wantMismatch  bool
+		wantMessage   string // substring of the message
+	}{
+		{"gpt-4o-mini with 4000 is too small", "gpt-4o-mini", 4000, true, true, "远小于"},
+		{"gpt-4o-mini with 200000 exceeds the model", "gpt-4o-mini", 200000, true, true, "超出"},
+		{"recommended value", "gpt-4o-mini", 76800, true, false, ""},
+		{"model maximum is allowed", "gpt-4o-mini", 128000, true, false, ""},
+		{"one above the maximum", "gpt-4o-mini", 128001, true, true, "超出"},
+		{"exactly a quarter of the recommendation", "gpt-4o-mini", 19200, true, false, ""},
+		{"just below a quarter of the recommendation", "gpt-4o-mini", 19199, true, true, "远小于"},
+		{"small model with the default window", "gpt-4", DefaultContextWindow, true, false, ""},
+		{"unknown model is never flagged", "my-local-model", 200000, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestConfigManager(t, tt.model, tt.contextWindow)
+			advice := m.CheckConfiguredContextWindow()
+			if advice.Known != tt.wantKnown || advice.Mismatch != tt.wantMismatch {
+				t.Fatalf("advice = %+v, want known %v, mismatch %v", advice, tt.wantKnown, tt.wantMismatch)
+			}
+			if !strings.Contains(advice.Message, tt.wantMessage) || (tt.wantMessage == "") != (advice.Message == "") {
+				t.Errorf("message = %q, want it to contain %q", advice.Message, tt.wantMessage)
+			}
+			if tt.wantKnown && advice.Recommended != RecommendedContextWindow(advice.ModelMax) {
+				t.Errorf("recommended = %d for model maximum %d", advice.Recommended, advice.ModelMax)
+			}
+		})
+	}
+}
+
+func TestApplyRecommendedContextWindow(t *testing.T) {
+	for _, contextWindow := range []int{4000, 200000} {
+		m := newTestConfigManager(t, "gpt-4o-mini", contextWindow)
+		advice, err := m.ApplyRecommendedContextWindow()
+		if err != nil {
+			t.Fatalf("window %d: %v", contextWindow, err)
+		}
+		if m.GetContextWindow() != 76800 || advice.Configured != 76800 || advice.Mismatch {
+			t.Errorf("window %d: context window %d after applying, advice %+v", contextWindow, m.GetContextWindow(), advice)
+		}
+
+		// The recommended value is saved for the next job
+		reloaded, err := NewConfigManager(m.GetConfigPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reloaded.GetContextWindow() != 76800 {
+			t.Errorf("window %d: saved context window %d, want 76800", contextWindow, reloaded.GetContextWindow())
+		}
+	}
+
+	// Unknown models without a learned window have nothing to apply
+	m := newTestConfigManager(t, "my-local-model", 4000)
+	if _, err := m.ApplyRecommendedContextWindow(); err == nil {
+		t.Error("expected an error for an unknown model")
+	} else if appErr, ok := err.(*types.AppError); !ok || appErr.Code != types.ErrInvalidInput {
+		t.Errorf("unexpected error %v", err)
+	}
+	if m.GetContextWindow() != 4000 {
+		t.Errorf("context window changed to %d", m.GetContextWindow())
+	}
+}
+
+func TestRecordWorkingContextWindow(t *testing.T) {
+	m := newTestConfigManager(t, "my-local-model", 12000)
+
+	// Invalid windows and known models are not recorded
+	m.RecordWorkingContextWindow("my-local-model", 0)
+	m.RecordWorkingContextWindow("gpt-4o-mini", 12000)
+	if learned := m.GetConfig().LearnedContextWindows; len(learned) != 0 {
+		t.Fatalf("unexpected learned windows %v", learned)
+	}
+
+	// The first successful job of an unknown model is remembered, later ones are not
+	m.RecordWorkingContextWindow("Provider/My-Local-Model", 12000)
+	m.RecordWorkingContextWindow("my-local-model", 3000)
+	if got := m.GetConfig().LearnedContextWindows["my-local-model"]; got != 12000 {
+		t.Fatalf("learned window = %d, want 12000", got)
+	}
+
+	// The learned window is suggested for the model, without flagging a mismatch
+	m.GetConfig().ContextWindow = 3000
+	advice := m.CheckConfiguredContextWindow()
+	if advice.Known || !advice.Learned || advice.Recommended != 12000 || advice.Mismatch {
+		t.Errorf("advice = %+v", advice)
+	}
+	if _, err := m.ApplyRecommendedContextWindow(); err != nil || m.GetContextWindow() != 12000 {
+		t.Errorf("apply learned window: %v, context window %d", err, m.GetContextWindow())
+	}
+
+	// The learned window is saved
+	reloaded, err := NewConfigManager(m.GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.GetConfig().LearnedContextWindows["my-local-model"]; got != 12000 {
+		t.Errorf("saved learned window = %d, want 12000", got)
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"latex-translator/internal/types"
+)
+
+// knownModelPrices maps model name prefixes to the list prices of the model in US dollars
+// per million tokens. The longest matching prefix wins, like for the context windows.
+// Prices change; users can correct or extend the table with model_prices in the config
+// file. Models without a price are metered but not costed.
+var knownModelPrices = map[string]types.ModelPrice{
+	"gpt-3.5-turbo":     {Input: 0.5, Output: 1.5},
+	"gpt-4":             {Input: 30, Output: 60},
+	"gpt-4-32k":         {Input: 60, Output: 120},
+	"gpt-4-turbo":       {Input: 10, Output: 30},
+	"gpt-4o":            {Input: 2.5, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+	"gpt-4.1":           {Input: 2, Output: 8},
+	"gpt-4.1-mini":      {Input: 0.4, Output: 1.6},
+	"gpt-4.1-nano":      {Input: 0.1, Output: 0.4},
+	"gpt-5":             {Input: 1.25, Output: 10},
+	"gpt-5-mini":        {Input: 0.25, Output: 2},
+	"gpt-5-nano":        {Input: 0.05, Output: 0.4},
+	"o1":                {Input: 15, Output: 60},
+	"o1-mini":           {Input: 1.1, Output: 4.4},
+	"o3":                {Input: 2, Output: 8},
+	"o3-mini":           {Input: 1.1, Output: 4.4},
+	"o4-mini":           {Input: 1.1, Output: 4.4},
+	"deepseek-chat":     {Input: 0.27, Output: 1.1},
+	"deepseek-reasoner": {Input: 0.55, Output: 2.19},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-haiku-4":    {Input: 1, Output: 5},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"gemini-1.5-flash":  {Input: 0.075, Output: 0.3},
+	"gemini-1.5-pro":    {Input: 1.25, Output: 5},
+	"gemini-2.0-flash":  {Input: 0.1, Output: 0.4},
+	"gemini-2.5-flash":  {Input: 0.3, Output: 2.5},
+	"gemini-2.5-pro":    {Input: 1.25, Output: 10},
+}
+
+// lookupModelPrice finds the longest prefix of the model name in a price table
+func lookupModelPrice(table map[string]types.ModelPrice, model string) (types.ModelPrice, bool) {
+	best, price := "", types.ModelPrice{}
+	for prefix, p := range table {
+		prefix = normalizeModelName(prefix)
+		if matchesModelPrefix(model, prefix) && len(prefix) > len(best) {
+			best, price = prefix, p
+		}
+	}
+	return price, best != ""
+}
+
+// ModelPrice returns the price of a model: the user's override from model_prices first,
+// then the built-in table
+func (m *ConfigManager) ModelPrice(model string) (types.ModelPrice, bool) {
+	model = normalizeModelName(model)
+	if model == "" {
+		return types.ModelPrice{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config != nil {
+		if price, ok := lookupModelPrice(m.config.ModelPrices, model); ok {
+			return price, true
+		}
+	}
+	return lookupModelPrice(knownModelPrices, model)
+}
+
+// EstimateCost returns the cost in US dollars of the tokens used with a model, 0 when
+// the price of the model is unknown
+func (m *ConfigManager) EstimateCost(model string, inputTokens, outputTokens int) float64 {
+	price, ok := m.ModelPrice(model)
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6
+}
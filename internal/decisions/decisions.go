@@ -0,0 +1,475 @@
+// Package decisions decides which files of a multi-file project are translated, copied
+// verbatim or skipped, and records every decision with the rule that made it and the
+// metrics it was based on in decisions.json. The same decisions drive the translation,
+// the chunking preview and the book CLI, and can be overridden per file on a re-run.
+// The schema is documented in docs/DECISIONS_JSON.md.
+package decisions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"latex-translator/internal/statusfile"
+	"latex-translator/internal/types"
+)
+
+const (
+	// SchemaVersion is the version of the decisions.json schema
+	SchemaVersion = 1
+	// FileName is the name of the decision log written to the project or output directory
+	FileName = "decisions.json"
+	// bookMinSize is the size below which the book CLI skips a file
+	bookMinSize = 50
+)
+
+// Rules that make decisions
+const (
+	RuleOverride          = "override"           // per-file override of the job options
+	RuleEmpty             = "empty"              // file has no content
+	RuleNoProse           = "no-prose"           // command definitions, tables or too little prose
+	RuleTooSmall          = "too-small"          // book CLI: file smaller than 50 bytes
+	RuleMostlyCode        = "mostly-code"        // book CLI: more than 80% code lines
+	RuleAlreadyTranslated = "already-translated" // book CLI: output file of an earlier run is up to date with its source
+	RuleNoChineseOutput   = "no-chinese-output"  // translation produced too little Chinese text
+	RuleSupportFile       = "support-file"       // .sty/.cls/.bib files are never translated
+	RuleIncludeOnly       = "includeonly"        // left out by the \includeonly of the main file
+	RuleProse             = "prose"              // file has prose to translate
+	RuleReadFailed        = "read-failed"
+	RuleTranslationFailed = "translation-failed"
+)
+
+// ruleReasons explains each rule in the decision log
+var ruleReasons = map[string]string{
+	RuleOverride:          "按任务参数中的单文件覆盖设置处理",
+	RuleEmpty:             "空文件，原样复制",
+	RuleNoProse:           "主要是命令定义或表格结构，正文太少（少于 3 行，或表格/命令占 80% 以上且正文少于 5 行），原样复制",
+	RuleTooSmall:          fmt.Sprintf("文件太小（少于 %d 字节），跳过", bookMinSize),
+	RuleMostlyCode:        "80% 以上的行是命令或绘图代码，原样复制",
+	RuleAlreadyTranslated: "输出目录中已有译文（之前的运行），源文件未改变，跳过",
+	RuleNoChineseOutput:   "译文中文字符过少（没有可翻译的文本），原样复制",
+	RuleSupportFile:       "宏包、文档类或参考文献数据库，原样保留",
+	RuleIncludeOnly:       "主文件的 \\includeonly 未包含该文件，不参与编译，原样保留（忽略 \\includeonly 可翻译完整文档）",
+	RuleProse:             "包含正文，翻译",
+	RuleReadFailed:        "读取文件失败",
+	RuleTranslationFailed: "翻译失败",
+}
+
+// overrideHelp explains how to change decisions on a re-run
+const overrideHelp = "命令行: --translate-files a.tex,b.tex 强制翻译，--copy-files c.tex 强制原样复制（路径相对于项目目录，也可以只写文件名）；" +
+	"图形界面: 在分块预览中为文件选择“翻译”或“原样复制”"
+
+// Profile selects the rules of a flow
+type Profile int
+
+const (
+	// Paper is the rule set of paper translation (GUI and --id/--url/--file)
+	Paper Profile = iota
+	// Book is the rule set of the book CLI (--book)
+	Book
+)
+
+// Overrides are per-file decisions given by the job options
+type Overrides struct {
+	translate map[string]bool
+	copy      map[string]bool
+}
+
+// NewOverrides builds overrides from lists of files to translate and to copy verbatim.
+// Entries are paths relative to the project directory or bare file names.
+func NewOverrides(translate, copy []string) Overrides {
+	o := Overrides{translate: make(map[string]bool), copy: make(map[string]bool)}
+	for _, f := range translate {
+		if f = normalize(f); f != "" {
+			o.translate[f] = true
+		}
+	}
+	for _, f := range copy {
+		if f = normalize(f); f != "" {
+			o.copy[f] = true
+		}
+	}
+	return o
+}
+
+// ParseList splits a comma-separated file list as given on the command line
+func ParseList(list string) []string {
+	var files []string
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Empty reports whether no file is overridden
+func (o Overrides) Empty() bool {
+	return len(o.translate) == 0 && len(o.copy) == 0
+}
+
+// Lookup returns the overridden decision for a file, or "" if it is not overridden
+func (o Overrides) Lookup(relPath string) string {
+	rel := normalize(relPath)
+	base := path.Base(rel)
+	switch {
+	case o.translate[rel] || o.translate[base]:
+		return types.FileDecisionTranslate
+	case o.copy[rel] || o.copy[base]:
+		return types.FileDecisionCopy
+	}
+	return ""
+}
+
+// String describes the overrides for job keys and logs
+func (o Overrides) String() string {
+	var parts []string
+	for f := range o.translate {
+		parts = append(parts, "+"+f)
+	}
+	for f := range o.copy {
+		parts = append(parts, "="+f)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// normalize returns a slash-separated relative path without leading "./"
+func normalize(relPath string) string {
+	rel := filepath.ToSlash(strings.TrimSpace(relPath))
+	return strings.TrimPrefix(path.Clean(rel), "./")
+}
+
+// Decide decides how a file of a project is handled. Overrides take precedence over the
+// rules of the profile.
+func Decide(relPath string, content []byte, profile Profile, overrides Overrides) types.FileDecision {
+	text := string(content)
+	d := newDecision(relPath, text)
+
+	if d.Kind != "tex" {
+		return d.with(types.FileDecisionCopy, RuleSupportFile)
+	}
+	if decision := overrides.Lookup(relPath); decision != "" {
+		return d.with(decision, RuleOverride)
+	}
+
+	switch {
+	case profile == Book && len(content) < bookMinSize:
+		return d.with(types.FileDecisionSkip, RuleTooSmall)
+	case strings.TrimSpace(text) == "":
+		return d.with(types.FileDecisionCopy, RuleEmpty)
+	case profile == Book && MostlyCode(text):
+		return d.with(types.FileDecisionCopy, RuleMostlyCode)
+	case profile == Paper && !NeedsTranslation(text):
+		return d.with(types.FileDecisionCopy, RuleNoProse)
+	}
+	return d.with(types.FileDecisionTranslate, RuleProse)
+}
+
+// Record returns the decision of a file that was made outside Decide (an existing output,
+// a failed translation)
+func Record(relPath string, content []byte, decision, rule string, err error) types.FileDecision {
+	d := newDecision(relPath, string(content)).with(decision, rule)
+	if err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// decision wraps types.FileDecision to set the rule and override hint together
+type decision types.FileDecision
+
+// newDecision returns an undecided entry for a file with its metrics
+func newDecision(relPath, content string) decision {
+	kind := strings.TrimPrefix(strings.ToLower(filepath.Ext(relPath)), ".")
+	if kind == "ltx" || kind == "" {
+		kind = "tex"
+	}
+	return decision{
+		File:    normalize(relPath),
+		Kind:    kind,
+		Metrics: Measure(content),
+	}
+}
+
+// with sets the decision, the rule and its explanation, and the option that changes it
+func (d decision) with(decision, rule string) types.FileDecision {
+	d.Decision = decision
+	d.Rule = rule
+	d.Reason = ruleReasons[rule]
+	switch {
+	case d.Kind != "tex" || decision == types.FileDecisionFailed:
+	case decision == types.FileDecisionTranslate:
+		d.Override = "--copy-files " + d.File
+	default:
+		d.Override = "--translate-files " + d.File
+	}
+	return types.FileDecision(d)
+}
+
+// NewLog returns an empty decision log for a project
+func NewLog(project, mainTexFile string) *types.FileDecisionLog {
+	return &types.FileDecisionLog{
+		SchemaVersion: SchemaVersion,
+		Project:       project,
+		MainTexFile:   filepath.ToSlash(mainTexFile),
+		Files:         []types.FileDecision{},
+		OverrideHelp:  overrideHelp,
+	}
+}
+
+// AddSupportFiles lists the .sty, .cls and .bib files of a project directory, which are
+// kept as they are
+func AddSupportFiles(log *types.FileDecisionLog, dir string) {
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			// Backups of originals and other hidden directories
+			if strings.HasPrefix(info.Name(), ".") && p != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".sty", ".cls", ".bib":
+		default:
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		content, _ := os.ReadFile(p)
+		log.Files = append(log.Files, Decide(rel, content, Paper, Overrides{}))
+		return nil
+	})
+}
+
+// Save writes the decision log to dir/decisions.json and returns its path
+func Save(log *types.FileDecisionLog, dir string) (string, error) {
+	p := filepath.Join(dir, FileName)
+	return p, statusfile.WriteJSONAtomic(p, log)
+}
+
+// Load reads a decision log
+func Load(p string) (*types.FileDecisionLog, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var log types.FileDecisionLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// Summary counts the decisions of a log, e.g. "翻译 12，原样复制 3，跳过 1"
+func Summary(log *types.FileDecisionLog) string {
+	counts := make(map[string]int)
+	for _, f := range log.Files {
+		counts[f.Decision]++
+	}
+	var parts []string
+	for _, d := range []struct{ decision, name string }{
+		{types.FileDecisionTranslate, "翻译"},
+		{types.FileDecisionCopy, "原样复制"},
+		{types.FileDecisionSkip, "跳过"},
+		{types.FileDecisionFailed, "失败"},
+	} {
+		if counts[d.decision] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", d.name, counts[d.decision]))
+		}
+	}
+	return strings.Join(parts, "，")
+}
+
+// proseWordPattern matches an English word of at least three letters
+var proseWordPattern = regexp.MustCompile(`[a-zA-Z]{3,}`)
+
+// lineCounts are the line classes NeedsTranslation decides on
+type lineCounts struct {
+	commandDefs, tableStructure, prose int
+}
+
+// countLines classifies the non-empty, non-comment lines of a tex file
+func countLines(content string) lineCounts {
+	var c lineCounts
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Skip empty lines and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "%") {
+			continue
+		}
+
+		// Count command definitions (including the whole line)
+		if strings.HasPrefix(trimmed, "\\newcommand") ||
+			strings.HasPrefix(trimmed, "\\renewcommand") ||
+			strings.HasPrefix(trimmed, "\\def") ||
+			strings.HasPrefix(trimmed, "\\let") ||
+			strings.HasPrefix(trimmed, "\\DeclareMathOperator") ||
+			strings.HasPrefix(trimmed, "\\newlength") ||
+			strings.HasPrefix(trimmed, "\\setlength") ||
+			strings.HasPrefix(trimmed, "\\newcounter") ||
+			strings.HasPrefix(trimmed, "\\setcounter") ||
+			strings.HasPrefix(trimmed, "\\DeclareOption") ||
+			strings.HasPrefix(trimmed, "\\ProcessOptions") ||
+			strings.HasPrefix(trimmed, "\\RequirePackage") ||
+			strings.HasPrefix(trimmed, "\\ProvidesPackage") ||
+			strings.HasPrefix(trimmed, "\\ProvidesClass") {
+			c.commandDefs++
+			continue
+		}
+
+		// Count table structure lines (tabular, toprule, midrule, etc.)
+		if strings.Contains(trimmed, "\\begin{tabular") ||
+			strings.Contains(trimmed, "\\end{tabular") ||
+			strings.Contains(trimmed, "\\begin{table") ||
+			strings.Contains(trimmed, "\\end{table") ||
+			strings.Contains(trimmed, "\\toprule") ||
+			strings.Contains(trimmed, "\\midrule") ||
+			strings.Contains(trimmed, "\\bottomrule") ||
+			strings.Contains(trimmed, "\\hline") ||
+			strings.Contains(trimmed, "\\cline") ||
+			strings.Contains(trimmed, "\\multicolumn") ||
+			strings.Contains(trimmed, "\\resizebox") ||
+			strings.Contains(trimmed, "\\centering") ||
+			strings.Contains(trimmed, "\\caption") ||
+			strings.Contains(trimmed, "\\label") ||
+			strings.HasPrefix(trimmed, "&") ||
+			strings.HasSuffix(trimmed, "\\\\") {
+			c.tableStructure++
+			continue
+		}
+
+		// Skip lines that are mostly LaTeX commands or symbols
+		if strings.HasPrefix(trimmed, "\\") ||
+			strings.HasPrefix(trimmed, "{") ||
+			strings.HasPrefix(trimmed, "}") ||
+			strings.HasPrefix(trimmed, "&") ||
+			strings.HasPrefix(trimmed, "$") {
+			continue
+		}
+
+		// Count lines that look like actual prose text content
+		// Must have multiple words and not be just numbers/symbols
+		if len(proseWordPattern.FindAllString(trimmed, -1)) >= 3 {
+			c.prose++
+		}
+	}
+	return c
+}
+
+// NeedsTranslation checks if a tex file contains translatable content.
+// Files that only contain LaTeX command definitions or tables don't need translation.
+func NeedsTranslation(content string) bool {
+	c := countLines(content)
+
+	// If the file is primarily a table with very little prose, skip translation
+	hasTableEnv := strings.Contains(content, "\\begin{table") || strings.Contains(content, "\\begin{tabular")
+	if hasTableEnv && c.prose < 5 {
+		return false
+	}
+
+	// If the file is mostly command definitions with very little text, skip translation
+	totalSignificant := c.commandDefs + c.tableStructure + c.prose
+	if totalSignificant > 0 {
+		nonTextRatio := float64(c.commandDefs+c.tableStructure) / float64(totalSignificant)
+		// If more than 80% of significant lines are non-text (commands or table structure), skip translation
+		if nonTextRatio > 0.8 && c.prose < 5 {
+			return false
+		}
+	}
+
+	// Also skip if there's very little text content overall
+	return c.prose >= 3
+}
+
+// codeRatio returns the share of non-empty, non-comment lines that are commands or
+// drawing code rather than English text
+func codeRatio(content string) float64 {
+	textLines := 0
+	codeLines := 0
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Skip empty lines and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "%") {
+			continue
+		}
+
+		// Check if line is mostly LaTeX commands (starts with \, or contains tikz/pgf commands)
+		if strings.HasPrefix(trimmed, "\\") ||
+			strings.Contains(trimmed, "\\draw") ||
+			strings.Contains(trimmed, "\\node") ||
+			strings.Contains(trimmed, "\\path") ||
+			strings.Contains(trimmed, "\\coordinate") ||
+			strings.Contains(trimmed, "\\fill") ||
+			strings.Contains(trimmed, "\\shade") {
+			codeLines++
+			continue
+		}
+
+		// Check if line has English words (simple heuristic)
+		hasEnglish := strings.IndexFunc(trimmed, func(r rune) bool {
+			return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		}) >= 0
+		if hasEnglish {
+			textLines++
+		} else {
+			codeLines++
+		}
+	}
+
+	total := textLines + codeLines
+	if total == 0 {
+		return 1
+	}
+	return float64(codeLines) / float64(total)
+}
+
+// MostlyCode checks if a LaTeX file is mostly code/figures with little translatable text:
+// more than 80% of its lines are code
+func MostlyCode(content string) bool {
+	return codeRatio(content) > 0.8
+}
+
+// Measure computes the metrics of a file recorded with its decision
+func Measure(content string) types.FileMetrics {
+	c := countLines(content)
+	letters, cjk := 0, 0
+	for _, r := range content {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			cjk++
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			letters++
+		}
+	}
+	cjkRatio := 0.0
+	if letters+cjk > 0 {
+		cjkRatio = float64(cjk) / float64(letters+cjk)
+	}
+	return types.FileMetrics{
+		Size:         len(content),
+		ProseLines:   c.prose,
+		CommandLines: c.commandDefs,
+		TableLines:   c.tableStructure,
+		CodeRatio:    round2(codeRatio(content)),
+		CJKRatio:     round2(cjkRatio),
+	}
+}
+
+// round2 rounds a ratio to two decimals for the log
+func round2(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
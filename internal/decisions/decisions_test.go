@@ -0,0 +1,148 @@
+package decisions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+const proseFile = `\section{Introduction}
+Large language models have changed how we translate scientific documents.
+We study the problem of translating LaTeX sources while keeping them compilable.
+Our approach splits the document into chunks and protects the math environments.
+The results show that the translated documents compile in most of the cases.
+`
+
+const macroFile = `\newcommand{\R}{\mathbb{R}}
+\newcommand{\E}{\mathbb{E}}
+\DeclareMathOperator{\argmin}{arg\,min}
+\def\eps{\varepsilon}
+`
+
+const tikzFile = `\begin{tikzpicture}
+\draw (0,0) -- (1,1);
+\node at (0,0) {A};
+\node at (1,1) {B};
+\draw[->] (0,1) -- (1,0);
+\end{tikzpicture}
+`
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		profile  Profile
+		decision string
+		rule     string
+	}{
+		{"paper prose", "intro.tex", proseFile, Paper, types.FileDecisionTranslate, RuleProse},
+		{"paper macros", "macros.tex", macroFile, Paper, types.FileDecisionCopy, RuleNoProse},
+		{"paper empty", "empty.tex", "  \n", Paper, types.FileDecisionCopy, RuleEmpty},
+		{"book too small", "tiny.tex", "\\chapter{A}", Book, types.FileDecisionSkip, RuleTooSmall},
+		{"book tikz", "fig.tex", tikzFile, Book, types.FileDecisionCopy, RuleMostlyCode},
+		{"book prose", "ch1.tex", proseFile, Book, types.FileDecisionTranslate, RuleProse},
+		{"bibliography", "refs.bib", "@article{a, title={Attention}}", Paper, types.FileDecisionCopy, RuleSupportFile},
+	}
+	for _, tt := range tests {
+		d := Decide(tt.file, []byte(tt.content), tt.profile, Overrides{})
+		if d.Decision != tt.decision || d.Rule != tt.rule || d.Reason == "" {
+			t.Errorf("%s: got %s/%s (%q), want %s/%s", tt.name, d.Decision, d.Rule, d.Reason, tt.decision, tt.rule)
+		}
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	overrides := NewOverrides([]string{"macros.tex", " ./appendix/b.tex "}, ParseList("intro.tex, ,"))
+	if overrides.Empty() {
+		t.Fatal("overrides are empty")
+	}
+
+	d := Decide(filepath.Join("sections", "macros.tex"), []byte(macroFile), Paper, overrides)
+	if d.Decision != types.FileDecisionTranslate || d.Rule != RuleOverride || d.Override != "--copy-files sections/macros.tex" {
+		t.Errorf("base name override: %+v", d)
+	}
+	if got := overrides.Lookup("appendix/b.tex"); got != types.FileDecisionTranslate {
+		t.Errorf("relative path override = %q", got)
+	}
+	if got := overrides.Lookup("other/b.tex"); got != "" {
+		t.Errorf("override of a different directory = %q", got)
+	}
+
+	d = Decide("intro.tex", []byte(proseFile), Paper, overrides)
+	if d.Decision != types.FileDecisionCopy || d.Override != "--translate-files intro.tex" {
+		t.Errorf("copy override: %+v", d)
+	}
+
+	// Support files are never translated
+	if d := Decide("macros.sty", []byte(macroFile), Paper, NewOverrides([]string{"macros.sty"}, nil)); d.Decision != types.FileDecisionCopy {
+		t.Errorf("support file override: %+v", d)
+	}
+
+	if got := overrides.String(); got != "+appendix/b.tex,+macros.tex,=intro.tex" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	m := Measure(proseFile)
+	if m.Size != len(proseFile) || m.ProseLines != 4 || m.CodeRatio != 0.2 || m.CJKRatio != 0 {
+		t.Errorf("prose metrics: %+v", m)
+	}
+	if m := Measure(macroFile); m.CommandLines != 4 || m.ProseLines != 0 || m.CodeRatio != 1 {
+		t.Errorf("macro metrics: %+v", m)
+	}
+	if m := Measure("深度学习 deep"); m.CJKRatio != 0.5 {
+		t.Errorf("CJK ratio = %v, want 0.5", m.CJKRatio)
+	}
+}
+
+func TestSaveWithSupportFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.tex":        proseFile,
+		"refs.bib":        "@book{b}",
+		"style/paper.sty": macroFile,
+		"notes.txt":       "not listed",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log := NewLog(dir, "main.tex")
+	log.Files = append(log.Files, Decide("main.tex", []byte(proseFile), Paper, Overrides{}))
+	log.Files = append(log.Files, Record("broken.tex", nil, types.FileDecisionFailed, RuleReadFailed, os.ErrNotExist))
+	AddSupportFiles(log, dir)
+
+	path, err := Save(log, dir)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var files []string
+	for _, f := range loaded.Files {
+		files = append(files, f.File+":"+f.Decision)
+	}
+	want := "main.tex:translate,broken.tex:failed,refs.bib:copy,style/paper.sty:copy"
+	if strings.Join(files, ",") != want {
+		t.Errorf("files = %v, want %s", files, want)
+	}
+	if loaded.Files[1].Error == "" || loaded.OverrideHelp == "" {
+		t.Errorf("missing error or override help: %+v", loaded)
+	}
+	if got := Summary(loaded); got != "翻译 1，原样复制 2，失败 1" {
+		t.Errorf("Summary() = %q", got)
+	}
+}
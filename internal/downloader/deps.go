@@ -0,0 +1,175 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// DefaultInputDepth is the nesting depth up to which FindTexDependencies follows
+// \input and friends; real projects (book, part, chapter, section) stay well below
+const DefaultInputDepth = 16
+
+// texInputPattern matches the commands pulling another tex file into a document:
+// \input, \include and \subfile with a braced argument (groups 1 and 2),
+// \import and \subimport with a directory and a file (groups 3 to 5), and the
+// TeX primitive form \input file (group 6).
+var texInputPattern = regexp.MustCompile(`\\(input|include|subfile)\s*\{([^}]+)\}|\\(import|subimport)\*?\s*\{([^}]*)\}\s*\{([^}]+)\}|\\input\s+([A-Za-z0-9_./-]+)`)
+
+// texInput is a file pulled in by a command of a tex file
+type texInput struct {
+	command string // input, include, subfile, import or subimport
+	dir     string // directory argument of \import and \subimport
+	file    string
+}
+
+// parseTexInputs returns the files a tex file pulls in, outside comments, in order
+func parseTexInputs(content string) []texInput {
+	var inputs []texInput
+	for _, m := range texInputPattern.FindAllStringSubmatch(stripTexComments(content), -1) {
+		switch {
+		case m[1] != "":
+			inputs = append(inputs, texInput{command: m[1], file: strings.TrimSpace(m[2])})
+		case m[3] != "":
+			inputs = append(inputs, texInput{command: m[3], dir: strings.TrimSpace(m[4]), file: strings.TrimSpace(m[5])})
+		case m[6] != "":
+			inputs = append(inputs, texInput{command: "input", file: m[6]})
+		}
+	}
+	return inputs
+}
+
+// TexDependencies is the result of FindTexDependencies
+type TexDependencies struct {
+	// Files are the tex files the main file pulls in, transitively, relative to
+	// the project root, each once, in document order
+	Files []string
+	// Missing are the referenced files that are not in the source, often stripped
+	// from arXiv uploads; they are skipped
+	Missing []string
+}
+
+// FindTexDependencies walks the \input, \include, \subfile, \import and
+// \subimport commands of the main file mainRel (relative to root) and of every
+// file they pull in, down to maxDepth levels (DefaultInputDepth when 0).
+//
+// Paths are resolved as LaTeX does: relative to the main file's directory, or,
+// inside files pulled in by \import, \subimport and \subfile, relative to the
+// directory of that import. The including file's directory and the project root
+// are tried as well, as sources are often compiled from either. Only .tex files
+// are followed; commented-out commands and cycles are ignored.
+func FindTexDependencies(root, mainRel string, maxDepth int) *TexDependencies {
+	if maxDepth <= 0 {
+		maxDepth = DefaultInputDepth
+	}
+	w := &dependencyWalker{
+		root:     root,
+		mainDir:  filepath.Dir(filepath.Clean(mainRel)),
+		maxDepth: maxDepth,
+		visited:  map[string]bool{filepath.Clean(mainRel): true},
+		missing:  make(map[string]bool),
+	}
+	w.walk(filepath.Clean(mainRel), w.mainDir, 1)
+
+	if len(w.deps.Missing) > 0 {
+		logger.Info("referenced tex files not in the source, skipped",
+			logger.String("main", mainRel),
+			logger.String("files", strings.Join(w.deps.Missing, ", ")))
+	}
+	return &w.deps
+}
+
+// dependencyWalker is the state of FindTexDependencies
+type dependencyWalker struct {
+	root     string
+	mainDir  string
+	maxDepth int
+	visited  map[string]bool
+	missing  map[string]bool
+	deps     TexDependencies
+}
+
+// walk adds the files pulled in by file (relative to root); dir is the directory
+// its plain \input commands are resolved against, depth the level of its inputs
+func (w *dependencyWalker) walk(file, dir string, depth int) {
+	content, err := os.ReadFile(filepath.Join(w.root, file))
+	if err != nil {
+		logger.Debug("cannot read tex file for dependencies", logger.String("file", file), logger.Err(err))
+		return
+	}
+
+	for _, in := range parseTexInputs(string(content)) {
+		target, targetDir := w.resolve(in, file, dir)
+		if target == "" {
+			continue
+		}
+		if w.visited[target] {
+			continue
+		}
+		if depth > w.maxDepth {
+			logger.Warn("tex input nesting too deep, not followed",
+				logger.String("file", target),
+				logger.String("from", file),
+				logger.Int("maxDepth", w.maxDepth))
+			continue
+		}
+		w.visited[target] = true
+		w.deps.Files = append(w.deps.Files, target)
+		logger.Debug("found input file", logger.String("file", target), logger.String("from", file))
+		w.walk(target, targetDir, depth+1)
+	}
+}
+
+// resolve returns the file (relative to root) an input of file refers to and
+// the directory the inputs of that file resolve against, or "" when the input
+// is not a tex file of the source
+func (w *dependencyWalker) resolve(in texInput, file, dir string) (string, string) {
+	// LaTeX looks for file.tex before file
+	name := filepath.FromSlash(in.file)
+	if !strings.EqualFold(filepath.Ext(name), ".tex") {
+		name += ".tex"
+	}
+
+	importDir := filepath.FromSlash(in.dir)
+	var bases []string
+	switch in.command {
+	case "import":
+		bases = []string{filepath.Join(w.mainDir, importDir), filepath.Join(filepath.Dir(file), importDir), importDir}
+	case "subimport":
+		bases = []string{filepath.Join(dir, importDir), filepath.Join(filepath.Dir(file), importDir)}
+	default:
+		bases = []string{dir, filepath.Dir(file)}
+	}
+	bases = append(bases, w.mainDir, ".")
+
+	for _, base := range bases {
+		path := filepath.Clean(filepath.Join(base, name))
+		if filepath.IsAbs(path) || strings.HasPrefix(path, "..") {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(w.root, path))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		switch in.command {
+		case "import", "subimport":
+			return path, filepath.Clean(base)
+		case "subfile":
+			return path, filepath.Dir(path)
+		}
+		return path, dir
+	}
+
+	if ext := filepath.Ext(in.file); ext != "" && !strings.EqualFold(ext, ".tex") {
+		// \input{table.csv}, \input{figure.pgf}: not a tex file to translate
+		return "", ""
+	}
+	if !w.missing[name] {
+		w.missing[name] = true
+		w.deps.Missing = append(w.deps.Missing, filepath.ToSlash(name))
+	}
+	return "", ""
+}
@@ -0,0 +1,46 @@
+package downloader
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindTexDependencies(t *testing.T) {
+	dir := writeTexTree(t, map[string]string{
+		"book.tex":            "\\documentclass{book}\n\\begin{document}\n\\include{parts/part1}\n\\import{figs/}{arch}\n% \\input{old}\n\\input{stripped}\n\\input{data.csv}\n\\end{document}\n",
+		"parts/part1.tex":     "\\part{One}\n\\input{chapters/ch1}\n",
+		"chapters/ch1.tex":    "\\chapter{Intro}\n\\input chapters/sec1\n\\subfile{sub/doc}\n",
+		"chapters/sec1.tex":   "Text.\n\\input{parts/part1}\n",
+		"sub/doc.tex":         "\\documentclass[../book]{subfiles}\n\\begin{document}\n\\input{shared}\n\\end{document}\n",
+		"sub/shared.tex":      "Shared.\n",
+		"figs/arch.tex":       "\\input{inner}\n",
+		"figs/inner.tex":      "Inner.\n",
+		"old.tex":             "Old.\n",
+		"data.csv":            "a,b\n",
+		"unrelated/notes.tex": "Notes.\n",
+	})
+
+	deps := FindTexDependencies(dir, "book.tex", 0)
+	want := []string{
+		filepath.Join("parts", "part1.tex"),
+		filepath.Join("chapters", "ch1.tex"),
+		filepath.Join("chapters", "sec1.tex"),
+		filepath.Join("sub", "doc.tex"),
+		filepath.Join("sub", "shared.tex"),
+		filepath.Join("figs", "arch.tex"),
+		filepath.Join("figs", "inner.tex"),
+	}
+	if !reflect.DeepEqual(deps.Files, want) {
+		t.Errorf("Files = %v, want %v", deps.Files, want)
+	}
+	if !reflect.DeepEqual(deps.Missing, []string{"stripped.tex"}) {
+		t.Errorf("Missing = %v, want [stripped.tex]", deps.Missing)
+	}
+
+	// Inputs nested deeper than the limit are not followed
+	shallow := FindTexDependencies(dir, "book.tex", 2)
+	if len(shallow.Files) != 4 {
+		t.Errorf("depth 2 found %v", shallow.Files)
+	}
+}
@@ -4,6 +4,7 @@ package downloader
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -12,9 +13,13 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
 	"latex-translator/internal/types"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 const (
@@ -23,6 +28,8 @@ const (
 	DefaultTimeout = 300 * time.Second
 	// ArxivEprintBaseURL is the base URL for arXiv e-print downloads
 	ArxivEprintBaseURL = "https://arxiv.org/e-print/"
+	// ArxivPDFBaseURL is the base URL for the PDFs compiled by arXiv
+	ArxivPDFBaseURL = "https://arxiv.org/pdf/"
 	// MaxRetries is the maximum number of retry attempts for network errors
 	MaxRetries = 3
 	// BaseRetryDelay is the base delay between retries (will be multiplied by attempt number)
@@ -40,7 +47,8 @@ type SourceDownloader struct {
 func NewSourceDownloader(workDir string) *SourceDownloader {
 	return &SourceDownloader{
 		httpClient: &http.Client{
-			Timeout: DefaultTimeout,
+			Timeout:   DefaultTimeout,
+			Transport: netproxy.NewTransport(),
 			// Don't follow redirects automatically to handle them manually if needed
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				if len(via) >= 10 {
@@ -57,7 +65,8 @@ func NewSourceDownloader(workDir string) *SourceDownloader {
 func NewSourceDownloaderWithTimeout(workDir string, timeout time.Duration) *SourceDownloader {
 	return &SourceDownloader{
 		httpClient: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: netproxy.NewTransport(),
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				if len(via) >= 10 {
 					return types.NewAppError(types.ErrNetwork, "too many redirects", nil)
@@ -144,6 +153,10 @@ func (d *SourceDownloader) DownloadFromURL(url string) (*types.SourceInfo, error
 	if err := d.downloadWithRetry(url, destPath); err != nil {
 		return nil, err
 	}
+	destPath, err := nameByFormat(destPath)
+	if err != nil {
+		return nil, err
+	}
 
 	logger.Info("download completed successfully", logger.String("url", url), logger.String("destPath", destPath))
 	return &types.SourceInfo{
@@ -188,6 +201,10 @@ func (d *SourceDownloader) DownloadByID(arxivID string) (*types.SourceInfo, erro
 	if err := d.downloadWithRetry(url, destPath); err != nil {
 		return nil, err
 	}
+	destPath, err := nameByFormat(destPath)
+	if err != nil {
+		return nil, err
+	}
 
 	logger.Info("download by ID completed successfully", logger.String("arxivID", arxivID), logger.String("destPath", destPath))
 	return &types.SourceInfo{
@@ -197,6 +214,27 @@ func (d *SourceDownloader) DownloadByID(arxivID string) (*types.SourceInfo, erro
 	}, nil
 }
 
+// DownloadArxivPDF downloads the PDF compiled by arXiv for a paper into destDir and
+// returns its path. It is used when the source cannot be compiled locally.
+func (d *SourceDownloader) DownloadArxivPDF(arxivID, destDir string) (string, error) {
+	logger.Info("downloading arXiv PDF", logger.String("arxivID", arxivID))
+
+	if arxivID == "" {
+		return "", types.NewAppError(types.ErrInvalidInput, "arXiv ID cannot be empty", nil)
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", types.NewAppError(types.ErrInternal, "failed to create download directory", err)
+	}
+
+	destPath := filepath.Join(destDir, strings.ReplaceAll(arxivID, "/", "_")+".pdf")
+	if err := d.downloadWithRetry(ArxivPDFBaseURL+arxivID, destPath); err != nil {
+		return "", err
+	}
+
+	logger.Info("arXiv PDF downloaded", logger.String("arxivID", arxivID), logger.String("destPath", destPath))
+	return destPath, nil
+}
+
 // downloadWithRetry performs an HTTP GET request with retry logic for network errors.
 // It retries up to MaxRetries times with increasing delays between attempts.
 //
@@ -248,8 +286,8 @@ func (d *SourceDownloader) downloadFile(url, destPath string) error {
 	// Set User-Agent header (arXiv may require this)
 	req.Header.Set("User-Agent", "LaTeX-Translator/1.0")
 
-	// Perform the request
-	resp, err := d.httpClient.Do(req)
+	// Perform the request, spaced and retried on throttling by the shared limiter
+	resp, err := d.do(req)
 	if err != nil {
 		return types.NewAppError(types.ErrNetwork, "network request failed", err)
 	}
@@ -266,12 +304,7 @@ func (d *SourceDownloader) downloadFile(url, destPath string) error {
 		logger.Warn("arXiv returned PDF instead of source code", 
 			logger.String("url", url),
 			logger.String("contentType", contentType))
-		return types.NewAppErrorWithDetails(
-			types.ErrDownload,
-			"论文源码不可用",
-			"arXiv 返回的是 PDF 文件而不是 LaTeX 源码。这篇论文可能没有上传源码，或者作者选择不公开源码。",
-			nil,
-		)
+		return sourceIsPDFError()
 	}
 
 	// Create the destination file
@@ -292,6 +325,55 @@ func (d *SourceDownloader) downloadFile(url, destPath string) error {
 	return nil
 }
 
+// sourceIsPDFError is the error of a download that returned a PDF instead of the source
+func sourceIsPDFError() error {
+	return types.NewAppErrorWithDetails(
+		types.ErrDownload,
+		"论文源码不可用",
+		"arXiv 返回的是 PDF 文件而不是 LaTeX 源码。这篇论文可能没有上传源码，或者作者选择不公开源码。",
+		nil,
+	)
+}
+
+// nameByFormat renames a downloaded source file to the extension of the format detected
+// from its content (arXiv serves tarballs, single gzipped .tex files and zips from the
+// same URL) and returns the new path. A PDF fails the download, as a PDF Content-Type
+// does; a file of unknown format is left for ExtractZip to report.
+func nameByFormat(destPath string) (string, error) {
+	format, err := DetectArchiveFormat(destPath)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("detected downloaded source format",
+		logger.String("path", destPath),
+		logger.String("format", string(format)))
+
+	switch format {
+	case FormatPDF:
+		os.Remove(destPath)
+		return "", sourceIsPDFError()
+	case FormatUnknown:
+		return destPath, nil
+	}
+
+	base := destPath
+	for _, ext := range []string{".tar.gz", ".tgz", ".gz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(base), ext) {
+			base = base[:len(base)-len(ext)]
+			break
+		}
+	}
+	target := base + format.Extension()
+	if target == destPath {
+		return destPath, nil
+	}
+	if err := os.Rename(destPath, target); err != nil {
+		logger.Warn("could not rename download to its format", logger.String("path", destPath), logger.Err(err))
+		return destPath, nil
+	}
+	return target, nil
+}
+
 // extractFilenameFromURL extracts a filename from a URL.
 // For arXiv URLs, it uses the paper ID as the filename.
 func extractFilenameFromURL(url string) string {
@@ -401,14 +483,14 @@ func handleHTTPError(statusCode int, url string) error {
 			fmt.Sprintf("URL: %s returned 403", url),
 			nil,
 		)
-	case http.StatusTooManyRequests:
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
 		return types.NewAppErrorWithDetails(
 			types.ErrAPIRateLimit,
 			"rate limit exceeded",
-			"too many requests, please try again later",
+			fmt.Sprintf("URL: %s returned %d after %d retries, please try again later", url, statusCode, MaxThrottleRetries),
 			nil,
 		)
-	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+	case http.StatusInternalServerError, http.StatusBadGateway:
 		return types.NewAppErrorWithDetails(
 			types.ErrNetwork,
 			"server error",
@@ -426,7 +508,8 @@ func handleHTTPError(statusCode int, url string) error {
 }
 
 // isRetryableError determines if an error should trigger a retry.
-// Network errors and server errors (5xx) are retryable.
+// Network errors and server errors (5xx) are retryable. Rate limit errors are not:
+// the request was already retried with backoff before it became one.
 func isRetryableError(err error) bool {
 	if err == nil {
 		return false
@@ -437,8 +520,6 @@ func isRetryableError(err error) bool {
 		switch appErr.Code {
 		case types.ErrNetwork:
 			return true
-		case types.ErrAPIRateLimit:
-			return true
 		default:
 			return false
 		}
@@ -498,21 +579,9 @@ func (d *SourceDownloader) ExtractZip(zipPath string) (*types.SourceInfo, error)
 		return nil, types.NewAppError(types.ErrInternal, "failed to create extraction directory", err)
 	}
 
-	// Determine archive type and extract accordingly
-	var err error
-	lowerPath := strings.ToLower(zipPath)
-	if strings.HasSuffix(lowerPath, ".tar.gz") || strings.HasSuffix(lowerPath, ".tgz") {
-		logger.Debug("extracting tar.gz archive")
-		err = d.extractTarGz(zipPath, extractDir)
-	} else if strings.HasSuffix(lowerPath, ".zip") {
-		logger.Debug("extracting zip archive")
-		err = d.extractZipFile(zipPath, extractDir)
-	} else {
-		// Try to detect format by reading file header
-		logger.Debug("detecting archive format by header")
-		err = d.extractByDetection(zipPath, extractDir)
-	}
-
+	// Detect the archive type from its content: arXiv serves tarballs, single gzipped
+	// .tex files and zips, and the file name does not tell them apart
+	err := d.extractByDetection(zipPath, extractDir)
 	if err != nil {
 		// Clean up on error
 		os.RemoveAll(extractDir)
@@ -554,8 +623,23 @@ func (d *SourceDownloader) extractTarGz(archivePath, destDir string) error {
 	}
 	defer gzReader.Close()
 
-	// Create tar reader
-	tarReader := tar.NewReader(gzReader)
+	return extractTarStream(gzReader, destDir)
+}
+
+// extractTar extracts an uncompressed .tar archive to the destination directory.
+func (d *SourceDownloader) extractTar(archivePath, destDir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return types.NewAppError(types.ErrExtract, "failed to open archive", err)
+	}
+	defer file.Close()
+
+	return extractTarStream(file, destDir)
+}
+
+// extractTarStream extracts the tar archive read from r to the destination directory.
+func extractTarStream(r io.Reader, destDir string) error {
+	tarReader := tar.NewReader(r)
 
 	for {
 		header, err := tarReader.Next()
@@ -629,16 +713,29 @@ func (d *SourceDownloader) extractTarGz(archivePath, destDir string) error {
 
 // extractZipFile extracts a .zip archive to the destination directory.
 func (d *SourceDownloader) extractZipFile(archivePath, destDir string) error {
+	return ExtractZipArchive(archivePath, destDir)
+}
+
+// ExtractZipArchive extracts a .zip archive into destDir, creating nested directories as
+// needed. Entries that would land outside destDir (absolute paths, ../ components) are
+// rejected, and names not stored as UTF-8 are decoded as GBK, the code page of zips
+// created on Chinese Windows. It needs no external tools and works on every platform.
+func ExtractZipArchive(archivePath, destDir string) error {
 	reader, err := zip.OpenReader(archivePath)
 	if err != nil {
 		return types.NewAppError(types.ErrExtract, "failed to open zip file", err)
 	}
 	defer reader.Close()
 
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return types.NewAppError(types.ErrExtract, "failed to create destination directory", err)
+	}
+
 	for _, file := range reader.File {
 		// Sanitize the path to prevent directory traversal attacks
-		targetPath, err := sanitizePath(destDir, file.Name)
+		targetPath, err := sanitizePath(destDir, zipEntryName(file))
 		if err != nil {
+			logger.Warn("rejected zip entry", logger.String("name", file.Name), logger.Err(err))
 			return err
 		}
 
@@ -656,7 +753,7 @@ func (d *SourceDownloader) extractZipFile(archivePath, destDir string) error {
 		}
 
 		// Extract file
-		if err := d.extractZipEntry(file, targetPath); err != nil {
+		if err := extractZipEntry(file, targetPath); err != nil {
 			return err
 		}
 	}
@@ -664,8 +761,21 @@ func (d *SourceDownloader) extractZipFile(archivePath, destDir string) error {
 	return nil
 }
 
+// zipEntryName returns the name of a zip entry as UTF-8 with forward slashes. Zip tools
+// on Windows may store backslash separators and names in the local code page without
+// setting the UTF-8 flag.
+func zipEntryName(file *zip.File) string {
+	name := file.Name
+	if file.NonUTF8 && !utf8.ValidString(name) {
+		if decoded, err := simplifiedchinese.GBK.NewDecoder().String(name); err == nil {
+			name = decoded
+		}
+	}
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
 // extractZipEntry extracts a single zip entry to the target path.
-func (d *SourceDownloader) extractZipEntry(file *zip.File, targetPath string) error {
+func extractZipEntry(file *zip.File, targetPath string) error {
 	srcFile, err := file.Open()
 	if err != nil {
 		return types.NewAppError(types.ErrExtract, "failed to open zip entry", err)
@@ -692,34 +802,169 @@ func (d *SourceDownloader) extractZipEntry(file *zip.File, targetPath string) er
 	return nil
 }
 
-// extractByDetection tries to detect the archive format by reading the file header
-// and extracts accordingly.
+// ArchiveFormat is the format of a source file, detected from its content
+type ArchiveFormat string
+
+const (
+	// FormatUnknown is a file that is not a supported source archive
+	FormatUnknown ArchiveFormat = ""
+	// FormatTarGz is a gzipped tarball, the usual arXiv e-print
+	FormatTarGz ArchiveFormat = "tar.gz"
+	// FormatGzip is a single gzipped file, the e-print of a paper with one .tex file
+	FormatGzip ArchiveFormat = "gzip"
+	// FormatTar is an uncompressed tarball
+	FormatTar ArchiveFormat = "tar"
+	// FormatZip is a zip archive
+	FormatZip ArchiveFormat = "zip"
+	// FormatPDF is a PDF, served by arXiv for papers without source
+	FormatPDF ArchiveFormat = "pdf"
+)
+
+// Extension returns the file extension of the format, e.g. ".tar.gz"
+func (f ArchiveFormat) Extension() string {
+	switch f {
+	case FormatTarGz:
+		return ".tar.gz"
+	case FormatGzip:
+		return ".gz"
+	case FormatTar:
+		return ".tar"
+	case FormatZip:
+		return ".zip"
+	case FormatPDF:
+		return ".pdf"
+	}
+	return ""
+}
+
+// DetectArchiveFormat detects the format of a source file from its magic bytes. A gzip
+// file is a tarball when its decompressed content starts with a tar header, and a single
+// gzipped file otherwise.
+func DetectArchiveFormat(path string) (ArchiveFormat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FormatUnknown, types.NewAppError(types.ErrExtract, "failed to open file", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatUnknown, types.NewAppError(types.ErrExtract, "failed to read file header", err)
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return FormatUnknown, types.NewAppError(types.ErrExtract, "failed to read file", err)
+		}
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return FormatUnknown, types.NewAppError(types.ErrExtract, "invalid gzip file", err)
+		}
+		defer gzReader.Close()
+		if isTarStream(gzReader) {
+			return FormatTarGz, nil
+		}
+		return FormatGzip, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case bytes.HasPrefix(header, []byte("%PDF")):
+		return FormatPDF, nil
+	case isTarStream(bytes.NewReader(header)):
+		return FormatTar, nil
+	}
+	return FormatUnknown, nil
+}
+
+// isTarStream reports whether r starts with a valid tar header
+func isTarStream(r io.Reader) bool {
+	_, err := tar.NewReader(r).Next()
+	return err == nil
+}
+
+// extractByDetection detects the archive format from the file content and extracts
+// accordingly.
 func (d *SourceDownloader) extractByDetection(archivePath, destDir string) error {
+	format, err := DetectArchiveFormat(archivePath)
+	if err != nil {
+		return err
+	}
+	logger.Debug("detected archive format",
+		logger.String("path", archivePath),
+		logger.String("format", string(format)))
+
+	switch format {
+	case FormatTarGz:
+		return d.extractTarGz(archivePath, destDir)
+	case FormatGzip:
+		return d.extractGzipFile(archivePath, destDir)
+	case FormatTar:
+		return d.extractTar(archivePath, destDir)
+	case FormatZip:
+		return d.extractZipFile(archivePath, destDir)
+	case FormatPDF:
+		return types.NewAppErrorWithDetails(
+			types.ErrExtract,
+			"论文源码不可用",
+			"文件是 PDF 而不是 LaTeX 源码压缩包。请使用 PDF 翻译，或提供 LaTeX 源码。",
+			nil,
+		)
+	}
+	return types.NewAppErrorWithDetails(
+		types.ErrExtract,
+		"不支持的源码格式",
+		"需要 zip、tar.gz、tar 或 gzip 压缩的单个 .tex 文件",
+		nil,
+	)
+}
+
+// extractGzipFile extracts a single gzipped file, the arXiv e-print of a paper with one
+// .tex file. The file keeps the name stored in the gzip header; without one it is named
+// after the archive. A name without a LaTeX extension gets .tex (arXiv IDs contain dots,
+// so 2301.00001 becomes 2301.00001.tex).
+func (d *SourceDownloader) extractGzipFile(archivePath, destDir string) error {
 	file, err := os.Open(archivePath)
 	if err != nil {
-		return types.NewAppError(types.ErrExtract, "failed to open file", err)
+		return types.NewAppError(types.ErrExtract, "failed to open archive", err)
 	}
 	defer file.Close()
 
-	// Read first few bytes to detect format
-	header := make([]byte, 4)
-	_, err = file.Read(header)
+	gzReader, err := gzip.NewReader(file)
 	if err != nil {
-		return types.NewAppError(types.ErrExtract, "failed to read file header", err)
+		return types.NewAppError(types.ErrExtract, "failed to create gzip reader", err)
 	}
-	file.Close()
+	defer gzReader.Close()
 
-	// Check for gzip magic number (1f 8b)
-	if header[0] == 0x1f && header[1] == 0x8b {
-		return d.extractTarGz(archivePath, destDir)
+	name := filepath.Base(filepath.FromSlash(strings.ReplaceAll(gzReader.Name, "\\", "/")))
+	if name == "." || name == string(filepath.Separator) || name == "" {
+		name = filepath.Base(archivePath)
+		for _, ext := range []string{".gz", ".tgz", ".tar"} {
+			name = strings.TrimSuffix(name, ext)
+		}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tex", ".ltx", ".latex":
+	default:
+		name += ".tex"
 	}
 
-	// Check for zip magic number (50 4b 03 04)
-	if header[0] == 0x50 && header[1] == 0x4b && header[2] == 0x03 && header[3] == 0x04 {
-		return d.extractZipFile(archivePath, destDir)
+	targetPath, err := sanitizePath(destDir, name)
+	if err != nil {
+		return err
 	}
+	outFile, err := os.Create(targetPath)
+	if err != nil {
+		return types.NewAppError(types.ErrExtract, "failed to create file", err)
+	}
+	defer outFile.Close()
 
-	return types.NewAppError(types.ErrExtract, "unsupported archive format", nil)
+	if _, err := io.Copy(outFile, gzReader); err != nil {
+		return types.NewAppError(types.ErrExtract, "failed to write file content", err)
+	}
+	logger.Debug("extracted single gzipped file", logger.String("file", name))
+	return nil
 }
 
 // sanitizePath ensures the target path is within the destination directory
@@ -797,6 +1042,7 @@ var preferredMainTexNames = []string{
 	"document.tex",
 	"thesis.tex",
 	"report.tex",
+	"ms.tex",
 }
 
 // FindMainTexFile finds the main tex file in a directory by searching for files
@@ -804,110 +1050,142 @@ var preferredMainTexNames = []string{
 //
 // The function searches all .tex files in the directory and its subdirectories
 // for the \documentclass command. If multiple files contain this command,
-// it prefers files with common main tex file names (main.tex, paper.tex, etc.).
+// it returns the top candidate of FindMainTexCandidates.
 //
 // Property 3: For any set of tex files containing the \documentclass command,
 // FindMainTexFile should return the path of a file containing that command.
 //
 // Validates: Requirements 1.5
 func (d *SourceDownloader) FindMainTexFile(dir string) (string, error) {
+	candidates, err := d.FindMainTexCandidates(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(candidates) > 1 {
+		logger.Info("selected main tex file from multiple candidates",
+			logger.String("file", candidates[0].Path),
+			logger.Int("score", candidates[0].Score),
+			logger.Int("candidates", len(candidates)))
+	} else {
+		logger.Info("found main tex file", logger.String("file", candidates[0].Path))
+	}
+	return candidates[0].Path, nil
+}
+
+// FindMainTexCandidates returns all tex files containing \begin{document} or
+// \documentclass, ranked from most to least likely to be the main file (see
+// scoreMainTexCandidates). The first element is the file FindMainTexFile would
+// return; callers can let the user choose when several candidates are Strong,
+// and fall back to the following entries when the first choice fails to compile.
+func (d *SourceDownloader) FindMainTexCandidates(dir string) ([]TexCandidate, error) {
 	logger.Info("finding main tex file", logger.String("dir", dir))
 
 	if dir == "" {
 		logger.Warn("find main tex file failed: empty directory path")
-		return "", types.NewAppError(types.ErrInvalidInput, "directory path cannot be empty", nil)
+		return nil, types.NewAppError(types.ErrInvalidInput, "directory path cannot be empty", nil)
 	}
 
 	// Check if directory exists
 	info, err := os.Stat(dir)
 	if os.IsNotExist(err) {
 		logger.Error("directory not found", err, logger.String("dir", dir))
-		return "", types.NewAppError(types.ErrFileNotFound, "directory not found", err)
+		return nil, types.NewAppError(types.ErrFileNotFound, "directory not found", err)
 	}
 	if err != nil {
 		logger.Error("failed to access directory", err, logger.String("dir", dir))
-		return "", types.NewAppError(types.ErrInternal, "failed to access directory", err)
+		return nil, types.NewAppError(types.ErrInternal, "failed to access directory", err)
 	}
 	if !info.IsDir() {
 		logger.Warn("path is not a directory", logger.String("path", dir))
-		return "", types.NewAppError(types.ErrInvalidInput, "path is not a directory", nil)
+		return nil, types.NewAppError(types.ErrInvalidInput, "path is not a directory", nil)
 	}
 
 	// Find all tex files in the directory
 	texFiles, err := d.findTexFiles(dir)
 	if err != nil {
 		logger.Error("failed to scan for tex files", err, logger.String("dir", dir))
-		return "", types.NewAppError(types.ErrInternal, "failed to scan for tex files", err)
+		return nil, types.NewAppError(types.ErrInternal, "failed to scan for tex files", err)
 	}
 
 	if len(texFiles) == 0 {
 		logger.Warn("no tex files found in directory", logger.String("dir", dir))
-		return "", types.NewAppError(types.ErrFileNotFound, "no tex files found in directory", nil)
+		return nil, types.NewAppError(types.ErrFileNotFound, "no tex files found in directory", nil)
 	}
 
 	logger.Debug("found tex files", logger.Int("count", len(texFiles)))
 
-	// Find all files containing \documentclass
+	// Find all files containing \documentclass or a document body, remembering
+	// which ones look like standalone fragments (figures, tables) rather than
+	// full documents. The contents are kept to follow \input and \include.
 	var filesWithDocumentclass []string
+	fragments := make(map[string]bool)
+	contents := make(map[string]string, len(texFiles))
 	for _, texFile := range texFiles {
 		fullPath := filepath.Join(dir, texFile)
-		hasDocumentclass, err := fileContainsDocumentclass(fullPath)
+		content, err := os.ReadFile(fullPath)
 		if err != nil {
 			// Skip files that can't be read
 			logger.Debug("skipping unreadable file", logger.String("file", texFile), logger.Err(err))
 			continue
 		}
-		if hasDocumentclass {
+		contentStr := string(content)
+		contents[texFile] = contentStr
+		if strings.Contains(contentStr, "\\documentclass") || strings.Contains(contentStr, "\\begin{document}") {
 			filesWithDocumentclass = append(filesWithDocumentclass, texFile)
+			if isFragmentDocument(contentStr) {
+				fragments[texFile] = true
+			}
 		}
 	}
 
 	if len(filesWithDocumentclass) == 0 {
 		logger.Warn("no main tex file found (no file contains \\documentclass)", logger.String("dir", dir))
-		return "", types.NewAppError(types.ErrFileNotFound, "no main tex file found (no file contains \\documentclass)", nil)
-	}
-
-	// If only one file has \documentclass, return it
-	if len(filesWithDocumentclass) == 1 {
-		logger.Info("found main tex file", logger.String("file", filesWithDocumentclass[0]))
-		return filesWithDocumentclass[0], nil
+		return nil, types.NewAppError(types.ErrFileNotFound, "no main tex file found (no file contains \\documentclass)", nil)
 	}
 
-	// Multiple files have \documentclass, prefer common main file names
-	mainFile := selectPreferredMainFile(filesWithDocumentclass)
-	logger.Info("selected main tex file from multiple candidates",
-		logger.String("file", mainFile),
-		logger.Int("candidates", len(filesWithDocumentclass)))
-	return mainFile, nil
+	ranked := rankMainFileCandidates(filesWithDocumentclass, fragments)
+	return scoreMainTexCandidates(ranked, fragments, contents), nil
 }
 
-// fileContainsDocumentclass checks if a file contains the \documentclass command.
-func fileContainsDocumentclass(filePath string) (bool, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return false, err
+// isFragmentDocument reports whether a tex file is a standalone fragment
+// (e.g. a TikZ figure built with the standalone class) or lacks a document body.
+// Such files contain \documentclass but are rarely the paper's main file.
+func isFragmentDocument(content string) bool {
+	if !strings.Contains(content, "\\begin{document}") {
+		return true
 	}
-
-	// Search for \documentclass command
-	// It can appear as \documentclass{...} or \documentclass[...]{...}
-	contentStr := string(content)
-	return strings.Contains(contentStr, "\\documentclass"), nil
+	idx := strings.Index(content, "\\documentclass")
+	if idx == -1 {
+		// The preamble comes from an \input file
+		return false
+	}
+	line := content[idx:]
+	if end := strings.Index(line, "\n"); end >= 0 {
+		line = line[:end]
+	}
+	return strings.Contains(line, "{standalone}")
 }
 
-// selectPreferredMainFile selects the preferred main file from a list of candidates.
+// rankMainFileCandidates orders candidates from most to least likely main file.
 // It prefers files with common main tex file names in the root directory first,
-// then preferred names in subdirectories, then any root file, and finally
-// returns the first file alphabetically.
-func selectPreferredMainFile(candidates []string) string {
+// then any root file, then preferred names in subdirectories, and finally the
+// remaining files alphabetically. Files marked as fragments always rank last.
+func rankMainFileCandidates(candidates []string, fragments map[string]bool) []string {
 	// Sort candidates for consistent ordering
 	sortedCandidates := make([]string, len(candidates))
 	copy(sortedCandidates, candidates)
 	sortStrings(sortedCandidates)
 
-	// Separate root files and nested files
+	// Separate root files, nested files and fragments
 	var rootFiles []string
 	var nestedFiles []string
+	var fragmentFiles []string
 	for _, candidate := range sortedCandidates {
+		if fragments[candidate] {
+			fragmentFiles = append(fragmentFiles, candidate)
+			continue
+		}
 		// Check for both Unix and Windows path separators
 		if !strings.Contains(candidate, "/") && !strings.Contains(candidate, "\\") {
 			rootFiles = append(rootFiles, candidate)
@@ -916,34 +1194,47 @@ func selectPreferredMainFile(candidates []string) string {
 		}
 	}
 
-	// First, check for preferred file names in root directory
+	ranked := make([]string, 0, len(sortedCandidates))
+	added := make(map[string]bool)
+	add := func(candidate string) {
+		if !added[candidate] {
+			added[candidate] = true
+			ranked = append(ranked, candidate)
+		}
+	}
+
+	// First, preferred file names in root directory
 	for _, preferredName := range preferredMainTexNames {
 		for _, candidate := range rootFiles {
-			baseName := filepath.Base(candidate)
-			if strings.EqualFold(baseName, preferredName) {
-				return candidate
+			if strings.EqualFold(filepath.Base(candidate), preferredName) {
+				add(candidate)
 			}
 		}
 	}
 
-	// Second, prefer any root file over nested files
-	if len(rootFiles) > 0 {
-		// Return the first root file alphabetically (already sorted)
-		return rootFiles[0]
+	// Second, any root file over nested files (alphabetically, already sorted)
+	for _, candidate := range rootFiles {
+		add(candidate)
 	}
 
-	// Third, check for preferred file names in nested directories
+	// Third, preferred file names in nested directories
 	for _, preferredName := range preferredMainTexNames {
 		for _, candidate := range nestedFiles {
-			baseName := filepath.Base(candidate)
-			if strings.EqualFold(baseName, preferredName) {
-				return candidate
+			if strings.EqualFold(filepath.Base(candidate), preferredName) {
+				add(candidate)
 			}
 		}
 	}
 
-	// Return the first candidate alphabetically (already sorted)
-	return sortedCandidates[0]
+	// Finally, remaining nested files and fragments alphabetically
+	for _, candidate := range nestedFiles {
+		add(candidate)
+	}
+	for _, candidate := range fragmentFiles {
+		add(candidate)
+	}
+
+	return ranked
 }
 
 // sortStrings sorts a slice of strings in place alphabetically
@@ -0,0 +1,225 @@
+package downloader
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// zipEntry is a file of a test archive
+type zipEntry struct {
+	name    string
+	content string
+	nonUTF8 bool
+}
+
+// writeTestZip writes a zip archive with the given entries and returns its path
+func writeTestZip(t *testing.T, entries []zipEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, NonUTF8: e.nonUTF8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return path
+}
+
+func TestExtractZipArchiveNestedDirectories(t *testing.T) {
+	gbkName, err := simplifiedchinese.GBK.NewEncoder().String("book/图片/说明.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := writeTestZip(t, []zipEntry{
+		{name: "book/"},
+		{name: "book/main.tex", content: "\\documentclass{book}"},
+		{name: "book/chapters/part1/intro.tex", content: "\\chapter{Intro}"},
+		{name: "book\\figures\\plot.pdf", content: "%PDF"},
+		{name: "book/章节/第一章.tex", content: "\\chapter{One}"},
+		{name: gbkName, content: "GBK", nonUTF8: true},
+	})
+
+	dest := filepath.Join(t.TempDir(), "out")
+	if err := ExtractZipArchive(archive, dest); err != nil {
+		t.Fatalf("ExtractZipArchive() error: %v", err)
+	}
+
+	want := map[string]string{
+		"book/main.tex":                 "\\documentclass{book}",
+		"book/chapters/part1/intro.tex": "\\chapter{Intro}",
+		"book/figures/plot.pdf":         "%PDF",
+		"book/章节/第一章.tex":               "\\chapter{One}",
+		"book/图片/说明.txt":                "GBK",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s not extracted: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+}
+
+func TestExtractZipArchiveRejectsPathTraversal(t *testing.T) {
+	for _, name := range []string{"../evil.tex", "book/../../evil.tex", "..\\evil.tex", "/tmp/evil.tex"} {
+		t.Run(name, func(t *testing.T) {
+			archive := writeTestZip(t, []zipEntry{
+				{name: "book/main.tex", content: "ok"},
+				{name: name, content: "pwned"},
+			})
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "out")
+
+			if err := ExtractZipArchive(archive, dest); err == nil {
+				t.Fatal("expected an error for a path outside the destination")
+			}
+			if _, err := os.Stat(filepath.Join(parent, "evil.tex")); err == nil {
+				t.Error("entry was written outside the destination")
+			}
+		})
+	}
+}
+
+// copyFixture copies a fixture from testdata into a temporary directory under name
+func copyFixture(t *testing.T, fixture, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// slashPaths returns paths with forward slashes
+func slashPaths(paths []string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = filepath.ToSlash(p)
+	}
+	return out
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    ArchiveFormat
+	}{
+		{"paper.tar.gz", FormatTarGz},
+		{"single.gz", FormatGzip},
+		{"paper.tar", FormatTar},
+		{"paper.zip", FormatZip},
+		{"paper.pdf", FormatPDF},
+		{"../extract_test.go", FormatUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := DetectArchiveFormat(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("DetectArchiveFormat() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectArchiveFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractZipDetectsFormatFromContent(t *testing.T) {
+	// Every fixture gets the name of an arXiv download, whatever its format
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{"paper.tar.gz", []string{"main.tex", "sections/intro.tex"}},
+		{"paper.tar", []string{"main.tex", "sections/intro.tex"}},
+		{"paper.zip", []string{"main.tex", "sections/intro.tex"}},
+		{"single.gz", []string{"2301.00001.tex"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			archive := copyFixture(t, tt.fixture, "2301.00001.tar.gz")
+			d := NewSourceDownloader(t.TempDir())
+
+			info, err := d.ExtractZip(archive)
+			if err != nil {
+				t.Fatalf("ExtractZip() error: %v", err)
+			}
+			got := slashPaths(info.AllTexFiles)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("AllTexFiles = %v, want %v", got, tt.want)
+			}
+			for _, f := range info.AllTexFiles {
+				data, err := os.ReadFile(filepath.Join(info.ExtractDir, f))
+				if err != nil || !strings.Contains(string(data), "\\") {
+					t.Errorf("%s not extracted: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractZipRejectsPDF(t *testing.T) {
+	archive := copyFixture(t, "paper.pdf", "2301.00001.tar.gz")
+	_, err := NewSourceDownloader(t.TempDir()).ExtractZip(archive)
+	appErr, ok := err.(*types.AppError)
+	if !ok || appErr.Code != types.ErrExtract || !strings.Contains(appErr.Details, "PDF") {
+		t.Errorf("expected an extract error naming the PDF, got %v", err)
+	}
+}
+
+func TestDownloadNamesFileByDetectedFormat(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "single.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-eprint")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	d := NewSourceDownloader(t.TempDir())
+	info, err := d.DownloadFromURL(server.URL + "/e-print/2301.00001")
+	if err != nil {
+		t.Fatalf("DownloadFromURL() error: %v", err)
+	}
+	if filepath.Base(info.ExtractDir) != "2301.00001.gz" {
+		t.Errorf("download saved as %s, want 2301.00001.gz", filepath.Base(info.ExtractDir))
+	}
+
+	info, err = d.ExtractZip(info.ExtractDir)
+	if err != nil {
+		t.Fatalf("ExtractZip() error: %v", err)
+	}
+	if got := slashPaths(info.AllTexFiles); len(got) != 1 || got[0] != "2301.00001.tex" {
+		t.Errorf("AllTexFiles = %v, want [2301.00001.tex]", got)
+	}
+}
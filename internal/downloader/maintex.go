@@ -0,0 +1,205 @@
+package downloader
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TexCandidate is a tex file that may be the main file of a project, with the
+// evidence FindMainTexCandidates ranked it by.
+type TexCandidate struct {
+	// Path is relative to the extraction directory
+	Path  string `json:"path"`
+	Score int    `json:"score"`
+	// Inputs is the number of files pulled in by \input, \include, \subfile and
+	// \import, followed transitively
+	Inputs int `json:"inputs"`
+	// Words counts the words of the file and of its inputs
+	Words int `json:"words"`
+	// Preferred is set for common main file names (main.tex, paper.tex, ms.tex...),
+	// Secondary for slides, posters, rebuttals and the like
+	Preferred bool `json:"preferred"`
+	Secondary bool `json:"secondary"`
+	// Included is set when another candidate pulls this file in
+	Included bool `json:"included"`
+	// Fragment is set for standalone figures and files without a document body
+	Fragment bool `json:"fragment"`
+	// Strong is set for candidates nearly as likely as the top one; when more
+	// than one candidate is strong the choice is worth asking the user about
+	Strong bool `json:"strong"`
+}
+
+// Weights of the main file score
+const (
+	inputScore       = 10  // per file pulled in
+	wordsPerPoint    = 200 // words per point, up to maxWordScore
+	maxWordScore     = 50
+	preferredScore   = 30
+	secondaryScore   = -20
+	rootScore        = 5
+	includedScore    = -100
+	fragmentScore    = -1000
+	inputSearchDepth = 10
+)
+
+// secondaryTexNameHints are parts of file names of documents that sit next to
+// the paper in a repository without being it
+var secondaryTexNameHints = []string{"slide", "poster", "beamer", "talk", "presentation", "rebuttal", "response", "letter", "cover"}
+
+// scoreMainTexCandidates scores the candidates of rankMainFileCandidates and
+// sorts them by score, keeping the order of ranked among equal scores. A main
+// file pulls in the most files and holds the most words; common main file names
+// and the root directory add to the score, slides and posters and files pulled
+// in by another candidate take from it, and fragments always rank last.
+func scoreMainTexCandidates(ranked []string, fragments map[string]bool, contents map[string]string) []TexCandidate {
+	candidates := make([]TexCandidate, len(ranked))
+	reached := make([]map[string]bool, len(ranked))
+	for i, path := range ranked {
+		reached[i] = reachableTexFiles(path, contents)
+		words := 0
+		for file := range reached[i] {
+			words += countTexWords(contents[file])
+		}
+		candidates[i] = TexCandidate{
+			Path:      path,
+			Inputs:    len(reached[i]) - 1,
+			Words:     words,
+			Preferred: isPreferredMainTexName(path),
+			Secondary: isSecondaryTexName(path, contents[path]),
+			Fragment:  fragments[path],
+		}
+	}
+	for i := range candidates {
+		for j := range candidates {
+			if i != j && reached[j][candidates[i].Path] {
+				candidates[i].Included = true
+			}
+		}
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		c.Score = c.Inputs*inputScore + min(c.Words/wordsPerPoint, maxWordScore)
+		if c.Preferred {
+			c.Score += preferredScore
+		}
+		if c.Secondary {
+			c.Score += secondaryScore
+		}
+		if !strings.ContainsAny(c.Path, `/\`) {
+			c.Score += rootScore
+		}
+		if c.Included {
+			c.Score += includedScore
+		}
+		if c.Fragment {
+			c.Score += fragmentScore
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+
+	top := candidates[0].Score
+	for i := range candidates {
+		c := &candidates[i]
+		c.Strong = !c.Fragment && !c.Included && (c.Score == top || c.Score*2 >= top)
+	}
+	return candidates
+}
+
+// reachableTexFiles returns file and the tex files it pulls in, transitively.
+// Inputs are looked up next to the including file and at the project root,
+// with and without the .tex extension, as LaTeX would find them.
+func reachableTexFiles(file string, contents map[string]string) map[string]bool {
+	reached := map[string]bool{file: true}
+	queue := []string{file}
+	for depth := 0; depth < inputSearchDepth && len(queue) > 0; depth++ {
+		var next []string
+		for _, current := range queue {
+			for _, in := range parseTexInputs(contents[current]) {
+				target := resolveTexInput(filepath.Join(filepath.FromSlash(in.dir), filepath.FromSlash(in.file)), filepath.Dir(current), contents)
+				if target != "" && !reached[target] {
+					reached[target] = true
+					next = append(next, target)
+				}
+			}
+		}
+		queue = next
+	}
+	return reached
+}
+
+// resolveTexInput returns the key of contents an \input argument refers to, or ""
+func resolveTexInput(name, fromDir string, contents map[string]string) string {
+	for _, base := range []string{fromDir, "."} {
+		for _, candidate := range []string{name, name + ".tex"} {
+			path := filepath.Clean(filepath.Join(base, candidate))
+			if _, ok := contents[path]; ok {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// stripTexComments removes the comments of a tex file, leaving escaped \%
+func stripTexComments(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+			} else if line[j] == '%' {
+				lines[i] = line[:j]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// countTexWords counts the words of a tex file outside comments and commands
+func countTexWords(content string) int {
+	words := 0
+	for _, field := range strings.Fields(stripTexComments(content)) {
+		if strings.HasPrefix(field, "\\") {
+			continue
+		}
+		if strings.IndexFunc(field, unicode.IsLetter) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// isPreferredMainTexName reports whether path is named like a main file
+func isPreferredMainTexName(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	for _, name := range preferredMainTexNames {
+		if base == name {
+			return true
+		}
+	}
+	return strings.HasPrefix(base, "main")
+}
+
+// isSecondaryTexName reports whether a document is named like, or is, a slide
+// deck, a poster or a reply to reviewers
+func isSecondaryTexName(path, content string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	for _, hint := range secondaryTexNameHints {
+		if strings.Contains(base, hint) {
+			return true
+		}
+	}
+	idx := strings.Index(content, "\\documentclass")
+	if idx == -1 {
+		return false
+	}
+	line := content[idx:]
+	if end := strings.Index(line, "\n"); end >= 0 {
+		line = line[:end]
+	}
+	return strings.Contains(line, "{beamer}") || strings.Contains(line, "poster}")
+}
@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTexTree writes files below a new temporary directory and returns it
+func writeTexTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFindMainTexCandidates(t *testing.T) {
+	body := strings.Repeat("Words of the thesis chapter. ", 200)
+	dir := writeTexTree(t, map[string]string{
+		"thesis.tex":           "\\documentclass{book}\n\\begin{document}\n\\include{chapters/intro}\n\\input{chapters/method.tex}\n% \\input{chapters/old}\n\\end{document}\n",
+		"chapters/intro.tex":   body,
+		"chapters/method.tex":  body + "\\input{figures/arch}\n",
+		"chapters/old.tex":     body,
+		"figures/arch.tex":     "\\documentclass{standalone}\n\\begin{document}\nx\n\\end{document}\n",
+		"slides.tex":           "\\documentclass{beamer}\n\\begin{document}\n\\input{chapters/intro}\n\\end{document}\n",
+		"poster.tex":           "\\documentclass{article}\n\\begin{document}\nPoster text.\n\\end{document}\n",
+		"appendix/results.tex": "\\documentclass{article}\n\\begin{document}\nResults.\n\\end{document}\n",
+	})
+
+	d := NewSourceDownloader(t.TempDir())
+	candidates, err := d.FindMainTexCandidates(dir)
+	if err != nil {
+		t.Fatalf("FindMainTexCandidates failed: %v", err)
+	}
+	if len(candidates) != 5 {
+		t.Fatalf("got %d candidates, want 5: %+v", len(candidates), candidates)
+	}
+	top := candidates[0]
+	if top.Path != "thesis.tex" || top.Inputs != 3 || !top.Preferred || !top.Strong {
+		t.Errorf("top candidate = %+v", top)
+	}
+	for _, c := range candidates[1:] {
+		if c.Strong {
+			t.Errorf("candidate %s is strong next to the thesis: %+v", c.Path, c)
+		}
+	}
+	if last := candidates[len(candidates)-1]; last.Path != filepath.Join("figures", "arch.tex") || !last.Fragment || !last.Included {
+		t.Errorf("last candidate = %+v", last)
+	}
+
+	if main, err := d.FindMainTexFile(dir); err != nil || main != "thesis.tex" {
+		t.Errorf("FindMainTexFile() = %q, %v", main, err)
+	}
+}
+
+func TestFindMainTexCandidatesAmbiguous(t *testing.T) {
+	paper := "\\documentclass{article}\n\\begin{document}\n" + strings.Repeat("Paper text. ", 1000) + "\n\\end{document}\n"
+	dir := writeTexTree(t, map[string]string{
+		"neurips.tex": paper,
+		"arxiv.tex":   paper,
+	})
+
+	candidates, err := NewSourceDownloader(t.TempDir()).FindMainTexCandidates(dir)
+	if err != nil {
+		t.Fatalf("FindMainTexCandidates failed: %v", err)
+	}
+	if len(candidates) != 2 || !candidates[0].Strong || !candidates[1].Strong {
+		t.Errorf("candidates = %+v, want two strong ones", candidates)
+	}
+	if candidates[0].Path != "arxiv.tex" {
+		t.Errorf("equal candidates not in alphabetical order: %+v", candidates)
+	}
+}
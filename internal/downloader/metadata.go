@@ -0,0 +1,202 @@
+package downloader
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// ArxivAPIBaseURL is the base URL of the arXiv metadata API (Atom feed)
+	ArxivAPIBaseURL = "https://export.arxiv.org/api/query?id_list="
+	// ArxivAbsBaseURL is the base URL of arXiv abstract pages
+	ArxivAbsBaseURL = "https://arxiv.org/abs/"
+	// MetadataTimeout bounds the metadata requests, which run before the user confirms a job
+	MetadataTimeout = 10 * time.Second
+)
+
+// ArxivMetadata is what arXiv knows about a paper, fetched before downloading its source
+type ArxivMetadata struct {
+	ID              string // arXiv ID without version
+	Title           string
+	Authors         []string
+	PrimaryCategory string    // e.g. cs.CL
+	Categories      []string  // all categories, primary first
+	Published       time.Time // submission of the first version
+	Updated         time.Time // submission of the latest version
+	Versions        int       // number of versions
+	Comment         string    // author comment, e.g. "10 pages, 3 figures"
+	License         string    // license URL from the abstract page; empty if unknown
+	Withdrawn       bool      // the latest version withdraws the paper
+}
+
+// arxivVersionPattern matches the version suffix of an arXiv ID
+var arxivVersionPattern = regexp.MustCompile(`v(\d+)$`)
+
+// arxivWithdrawnPattern matches the comments arXiv and authors put on withdrawn papers
+var arxivWithdrawnPattern = regexp.MustCompile(`(?i)\b(has been |was )?withdrawn\b`)
+
+// arxivLicensePattern matches the license link of an abstract page
+var arxivLicensePattern = regexp.MustCompile(`(?s)class="abs-license"[^>]*>.*?<a[^>]+href="([^"]+)"`)
+
+// arxivFeed is the part of the arXiv API Atom feed used by ParseArxivFeed
+type arxivFeed struct {
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Authors   []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		PrimaryCategory struct {
+			Term string `xml:"term,attr"`
+		} `xml:"http://arxiv.org/schemas/atom primary_category"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+		Comment string `xml:"http://arxiv.org/schemas/atom comment"`
+	} `xml:"entry"`
+}
+
+// ParseArxivFeed parses the arXiv API response for a single paper
+func ParseArxivFeed(data []byte) (*ArxivMetadata, error) {
+	var feed arxivFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, types.NewAppError(types.ErrAPICall, "failed to parse arXiv metadata", err)
+	}
+	// Unknown IDs return an entry without a title (or an error entry titled "Error")
+	if len(feed.Entries) == 0 || strings.TrimSpace(feed.Entries[0].Title) == "" || feed.Entries[0].Title == "Error" {
+		return nil, types.NewAppError(types.ErrFileNotFound, "paper not found on arXiv", nil)
+	}
+	entry := feed.Entries[0]
+
+	id := entry.ID
+	if i := strings.Index(id, "/abs/"); i >= 0 {
+		id = id[i+len("/abs/"):]
+	}
+	meta := &ArxivMetadata{
+		Title:           collapseSpace(entry.Title),
+		PrimaryCategory: entry.PrimaryCategory.Term,
+		Comment:         collapseSpace(entry.Comment),
+		Versions:        1,
+	}
+	if m := arxivVersionPattern.FindStringSubmatch(id); m != nil {
+		meta.Versions, _ = strconv.Atoi(m[1])
+		id = strings.TrimSuffix(id, m[0])
+	}
+	meta.ID = id
+	for _, author := range entry.Authors {
+		meta.Authors = append(meta.Authors, collapseSpace(author.Name))
+	}
+	if meta.PrimaryCategory != "" {
+		meta.Categories = append(meta.Categories, meta.PrimaryCategory)
+	}
+	for _, c := range entry.Categories {
+		if c.Term != "" && c.Term != meta.PrimaryCategory {
+			meta.Categories = append(meta.Categories, c.Term)
+		}
+	}
+	meta.Published, _ = time.Parse(time.RFC3339, strings.TrimSpace(entry.Published))
+	meta.Updated, _ = time.Parse(time.RFC3339, strings.TrimSpace(entry.Updated))
+	meta.Withdrawn = arxivWithdrawnPattern.MatchString(meta.Comment)
+	return meta, nil
+}
+
+// FillJobSummary copies the metadata into the confirmation summary of a job and flags a
+// withdrawn paper
+func (m *ArxivMetadata) FillJobSummary(summary *types.JobSummary) {
+	summary.ArxivID = m.ID
+	summary.Title = m.Title
+	summary.Authors = m.Authors
+	summary.PrimaryCategory = m.PrimaryCategory
+	summary.Categories = m.Categories
+	summary.Versions = m.Versions
+	summary.License = m.License
+	summary.Withdrawn = m.Withdrawn
+	if !m.Published.IsZero() {
+		summary.Published = m.Published.Format("2006-01-02")
+	}
+	if !m.Updated.IsZero() {
+		summary.Updated = m.Updated.Format("2006-01-02")
+	}
+	if m.Withdrawn {
+		summary.RedFlags = append(summary.RedFlags, "论文已撤回: "+m.Comment)
+	}
+}
+
+// SourceRedFlag is the red flag of a job whose source could not be downloaded or
+// extracted, including papers arXiv only has as PDF
+func SourceRedFlag(err error) string {
+	return fmt.Sprintf("没有可翻译的 LaTeX 源码: %v", err)
+}
+
+// collapseSpace joins the lines of an Atom text field
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// FetchArxivMetadata fetches the metadata of an arXiv paper: title, authors, categories,
+// dates, number of versions, withdrawal and license. The license comes from the
+// abstract page and is left empty when that page cannot be read.
+func (d *SourceDownloader) FetchArxivMetadata(arxivID string) (*ArxivMetadata, error) {
+	arxivID = arxivVersionPattern.ReplaceAllString(strings.TrimSpace(arxivID), "")
+	if arxivID == "" {
+		return nil, types.NewAppError(types.ErrInvalidInput, "arXiv ID cannot be empty", nil)
+	}
+	logger.Info("fetching arXiv metadata", logger.String("arxivID", arxivID))
+
+	data, err := d.fetchPage(ArxivAPIBaseURL + arxivID)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := ParseArxivFeed(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if page, err := d.fetchPage(ArxivAbsBaseURL + arxivID); err != nil {
+		logger.Warn("failed to fetch arXiv abstract page for the license", logger.Err(err))
+	} else if m := arxivLicensePattern.FindSubmatch(page); m != nil {
+		meta.License = string(m[1])
+	}
+
+	logger.Info("fetched arXiv metadata",
+		logger.String("arxivID", meta.ID),
+		logger.String("primaryCategory", meta.PrimaryCategory),
+		logger.Int("versions", meta.Versions),
+		logger.Bool("withdrawn", meta.Withdrawn))
+	return meta, nil
+}
+
+// fetchPage reads a small page with MetadataTimeout
+func (d *SourceDownloader) fetchPage(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), MetadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrNetwork, "failed to create request", err)
+	}
+	resp, err := d.do(req)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrNetwork, "failed to fetch "+url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleHTTPError(resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, types.NewAppError(types.ErrNetwork, fmt.Sprintf("failed to read %s", url), err)
+	}
+	return data, nil
+}
@@ -0,0 +1,274 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// arxivFeedXML wraps entries in an arXiv API Atom feed
+func arxivFeedXML(entries string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:arxiv="http://arxiv.org/schemas/atom">
+  <title type="html">ArXiv Query: id_list=2301.00001</title>
+` + entries + `
+</feed>`)
+}
+
+const paperEntry = `<entry>
+    <id>http://arxiv.org/abs/2301.00001v3</id>
+    <updated>2023-03-05T12:00:00Z</updated>
+    <published>2023-01-02T09:30:00Z</published>
+    <title>Attention Is
+      All You Need, Again</title>
+    <author><name>Ada Lovelace</name></author>
+    <author><name>  Alan
+      Turing </name></author>
+    <arxiv:comment>10 pages,
+      3 figures</arxiv:comment>
+    <arxiv:primary_category term="cs.CL" scheme="http://arxiv.org/schemas/atom"/>
+    <category term="cs.CL" scheme="http://arxiv.org/schemas/atom"/>
+    <category term="cs.LG" scheme="http://arxiv.org/schemas/atom"/>
+    <category term="stat.ML" scheme="http://arxiv.org/schemas/atom"/>
+  </entry>`
+
+func TestParseArxivFeed(t *testing.T) {
+	meta, err := ParseArxivFeed(arxivFeedXML(paperEntry))
+	if err != nil {
+		t.Fatalf("ParseArxivFeed() error: %v", err)
+	}
+	want := &ArxivMetadata{
+		ID:              "2301.00001",
+		Title:           "Attention Is All You Need, Again",
+		Authors:         []string{"Ada Lovelace", "Alan Turing"},
+		PrimaryCategory: "cs.CL",
+		Categories:      []string{"cs.CL", "cs.LG", "stat.ML"},
+		Published:       time.Date(2023, 1, 2, 9, 30, 0, 0, time.UTC),
+		Updated:         time.Date(2023, 3, 5, 12, 0, 0, 0, time.UTC),
+		Versions:        3,
+		Comment:         "10 pages, 3 figures",
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("ParseArxivFeed() =\n%+v\nwant\n%+v", meta, want)
+	}
+}
+
+func TestParseArxivFeedOldStyleID(t *testing.T) {
+	entry := `<entry>
+    <id>http://arxiv.org/abs/hep-th/9901001v1</id>
+    <title>Old Paper</title>
+    <category term="hep-th"/>
+  </entry>`
+	meta, err := ParseArxivFeed(arxivFeedXML(entry))
+	if err != nil {
+		t.Fatalf("ParseArxivFeed() error: %v", err)
+	}
+	if meta.ID != "hep-th/9901001" || meta.Versions != 1 {
+		t.Errorf("ID = %q, versions = %d; want hep-th/9901001, 1", meta.ID, meta.Versions)
+	}
+	// Without a primary category, the categories keep their feed order
+	if !reflect.DeepEqual(meta.Categories, []string{"hep-th"}) || meta.PrimaryCategory != "" {
+		t.Errorf("categories = %v, primary %q", meta.Categories, meta.PrimaryCategory)
+	}
+	if !meta.Published.IsZero() {
+		t.Errorf("missing published date parsed as %v", meta.Published)
+	}
+}
+
+func TestParseArxivFeedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want types.ErrorCode
+	}{
+		{"no entries", arxivFeedXML(""), types.ErrFileNotFound},
+		{"entry without title", arxivFeedXML(`<entry><id>http://arxiv.org/api/errors</id><title> </title></entry>`), types.ErrFileNotFound},
+		{"error entry", arxivFeedXML(`<entry><id>http://arxiv.org/api/errors#incorrect_id_format</id><title>Error</title></entry>`), types.ErrFileNotFound},
+		{"invalid XML", []byte("<feed><entry>"), types.ErrAPICall},
+		{"not XML", []byte("Rate exceeded."), types.ErrAPICall},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ParseArxivFeed(tt.data)
+			appErr, ok := err.(*types.AppError)
+			if meta != nil || !ok || appErr.Code != tt.want {
+				t.Errorf("ParseArxivFeed() = %v, %v; want error %s", meta, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArxivFeedWithdrawn(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    bool
+	}{
+		{"This paper has been withdrawn by the author due to a crucial error in Eq. 3", true},
+		{"Withdrawn: superseded by arXiv:2302.00002", true},
+		{"paper was withdrawn", true},
+		{"10 pages, 3 figures", false},
+		{"Discusses withdrawal of funding", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		entry := strings.Replace(paperEntry, "10 pages,\n      3 figures", tt.comment, 1)
+		meta, err := ParseArxivFeed(arxivFeedXML(entry))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.comment, err)
+		}
+		if meta.Withdrawn != tt.want {
+			t.Errorf("comment %q: Withdrawn = %v, want %v", tt.comment, meta.Withdrawn, tt.want)
+		}
+	}
+}
+
+// redirectTransport sends every request to a test server, keeping the path and query
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newArxivTestDownloader returns a downloader whose arXiv requests go to handler
+func newArxivTestDownloader(t *testing.T, handler http.HandlerFunc) *SourceDownloader {
+	t.Helper()
+	withoutRateLimit(t)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	d := NewSourceDownloader(t.TempDir())
+	d.httpClient.Transport = redirectTransport{target: target}
+	return d
+}
+
+func TestFetchArxivMetadata(t *testing.T) {
+	d := newArxivTestDownloader(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/query" && r.URL.Query().Get("id_list") == "2301.00001":
+			w.Write(arxivFeedXML(paperEntry))
+		case r.URL.Path == "/abs/2301.00001":
+			w.Write([]byte(`<div class="abs-license"><a href="http://creativecommons.org/licenses/by/4.0/" title="Rights to this article">View license</a></div>`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// The version of the requested ID is ignored
+	meta, err := d.FetchArxivMetadata(" 2301.00001v2 ")
+	if err != nil {
+		t.Fatalf("FetchArxivMetadata() error: %v", err)
+	}
+	if meta.ID != "2301.00001" || meta.Versions != 3 {
+		t.Errorf("ID = %q, versions = %d", meta.ID, meta.Versions)
+	}
+	if meta.License != "http://creativecommons.org/licenses/by/4.0/" {
+		t.Errorf("License = %q", meta.License)
+	}
+
+	if _, err := d.FetchArxivMetadata(""); err == nil {
+		t.Error("expected an error for an empty ID")
+	}
+}
+
+func TestFetchArxivMetadataWithoutAbstractPage(t *testing.T) {
+	d := newArxivTestDownloader(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/query" {
+			w.Write(arxivFeedXML(paperEntry))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// The license is optional: the metadata is still returned
+	meta, err := d.FetchArxivMetadata("2301.00001")
+	if err != nil {
+		t.Fatalf("FetchArxivMetadata() error: %v", err)
+	}
+	if meta.License != "" || meta.Title == "" {
+		t.Errorf("License = %q, Title = %q", meta.License, meta.Title)
+	}
+}
+
+func TestFillJobSummary(t *testing.T) {
+	meta, err := ParseArxivFeed(arxivFeedXML(paperEntry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := &types.JobSummary{Input: "2301.00001"}
+	meta.FillJobSummary(summary)
+	if summary.ArxivID != "2301.00001" || summary.Title != meta.Title || summary.Versions != 3 ||
+		summary.Published != "2023-01-02" || summary.Updated != "2023-03-05" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if summary.Withdrawn || len(summary.RedFlags) != 0 {
+		t.Errorf("paper flagged: %v", summary.RedFlags)
+	}
+
+	// A withdrawn paper is flagged with the withdrawal comment
+	meta.Withdrawn, meta.Comment = true, "This paper has been withdrawn"
+	summary = &types.JobSummary{}
+	meta.FillJobSummary(summary)
+	if !summary.Withdrawn || !reflect.DeepEqual(summary.RedFlags, []string{"论文已撤回: This paper has been withdrawn"}) {
+		t.Errorf("withdrawn paper: Withdrawn = %v, RedFlags = %q", summary.Withdrawn, summary.RedFlags)
+	}
+}
+
+func TestSourceRedFlagForPDFOnlyPaper(t *testing.T) {
+	d := newArxivTestDownloader(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		if r.Method == http.MethodGet {
+			w.Write([]byte("%PDF-1.5\n"))
+		}
+	})
+
+	available, err := d.CheckSourceAvailable("2301.00001")
+	if err != nil || available {
+		t.Errorf("CheckSourceAvailable() = %v, %v; want false", available, err)
+	}
+
+	_, err = d.DownloadByID("2301.00001")
+	appErr, ok := err.(*types.AppError)
+	if !ok || appErr.Code != types.ErrDownload {
+		t.Fatalf("expected a download error, got %v", err)
+	}
+	flag := SourceRedFlag(err)
+	if !strings.HasPrefix(flag, "没有可翻译的 LaTeX 源码: ") || !strings.Contains(flag, "论文源码不可用") {
+		t.Errorf("SourceRedFlag() = %q", flag)
+	}
+}
+
+func TestCheckSourceAvailable(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		want        bool
+	}{
+		{"tarball", http.StatusOK, "application/x-eprint-tar", true},
+		{"gzipped tex", http.StatusOK, "application/x-eprint", true},
+		{"PDF only", http.StatusOK, "application/pdf", false},
+		{"unknown paper", http.StatusNotFound, "text/html", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newArxivTestDownloader(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+			})
+			if got, err := d.CheckSourceAvailable("2301.00001"); err != nil || got != tt.want {
+				t.Errorf("CheckSourceAvailable() = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package downloader
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// DefaultRequestInterval is the spacing arXiv asks of automated clients: one
+	// request every three seconds
+	DefaultRequestInterval = 3 * time.Second
+	// MaxThrottleRetries is the number of retries of a request answered with 429 or 503
+	MaxThrottleRetries = 4
+	// maxRetryAfter is the longest Retry-After honored; a server asking for more
+	// gets the error instead of a process stalled for hours
+	maxRetryAfter = 10 * time.Minute
+)
+
+var (
+	// throttleBaseDelay is the first backoff delay after a 429 or 503 without Retry-After
+	throttleBaseDelay = 5 * time.Second
+	// throttleMaxDelay caps the exponential backoff
+	throttleMaxDelay = 2 * time.Minute
+)
+
+// requestLimiter spaces all requests of the process: every SourceDownloader, the
+// metadata fetches and the tools built on this package share it
+var requestLimiter = NewRateLimiter(DefaultRequestInterval, 1)
+
+// RateLimiter is a token bucket holding up to burst tokens, refilled at one token
+// per interval. It is kept as the time the bucket is full again (virtual
+// scheduling), which lets waiters reserve their slot without holding the lock
+// while they sleep. A zero interval disables it.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	full     time.Time
+}
+
+// NewRateLimiter creates a limiter allowing burst requests at once and one more
+// per interval
+func NewRateLimiter(interval time.Duration, burst int) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(interval, burst)
+	return l
+}
+
+// SetRate changes the rate of the limiter; an interval of zero or less disables it
+func (l *RateLimiter) SetRate(interval time.Duration, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = max(interval, 0)
+	l.burst = max(burst, 1)
+}
+
+// Wait blocks until the limiter grants a request, or ctx is done. A request
+// cancelled while waiting keeps its slot.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token and returns how long to wait for it
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.interval == 0 {
+		return 0
+	}
+	full := l.full
+	if full.Before(now) {
+		full = now
+	}
+	// The token is there once the bucket lacks fewer than burst of them
+	allowed := full.Add(-time.Duration(l.burst-1) * l.interval)
+	l.full = full.Add(l.interval)
+	return max(allowed.Sub(now), 0)
+}
+
+// Pause holds off every request for d: the server rate limits the whole address,
+// not a single request
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.interval == 0 {
+		return
+	}
+	// Empty the bucket until now+d
+	full := time.Now().Add(d + time.Duration(l.burst-1)*l.interval)
+	if full.After(l.full) {
+		l.full = full
+	}
+}
+
+// SetRateLimit sets the spacing of the requests of the process (DefaultRequestInterval
+// with a burst of 1 by default). An interval of zero disables the limiter, e.g. for
+// tests against a local server.
+func SetRateLimit(interval time.Duration, burst int) {
+	requestLimiter.SetRate(interval, burst)
+	logger.Debug("download rate limit set", logger.String("interval", interval.String()), logger.Int("burst", burst))
+}
+
+// WaitForRequest waits for the shared limiter; for arXiv requests made outside
+// SourceDownloader, such as API searches
+func WaitForRequest(ctx context.Context) error {
+	return requestLimiter.Wait(ctx)
+}
+
+// do sends req once the shared limiter allows it. Responses 429 and 503 are retried
+// up to MaxThrottleRetries times after the Retry-After the server asked for, or else
+// after an exponential backoff with jitter; the delay pauses every request of the
+// process. The last throttled response is returned to the caller as it is.
+func (d *SourceDownloader) do(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := requestLimiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := d.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !isThrottled(resp.StatusCode) || attempt > MaxThrottleRetries {
+			return resp, nil
+		}
+
+		delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			delay = throttleDelay(attempt)
+		} else if delay > maxRetryAfter {
+			logger.Warn("server asked to retry too late, giving up",
+				logger.String("url", req.URL.String()),
+				logger.String("retryAfter", delay.String()))
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		logger.Warn("rate limited by server, backing off",
+			logger.String("url", req.URL.String()),
+			logger.Int("status", resp.StatusCode),
+			logger.Int("attempt", attempt),
+			logger.String("delay", delay.String()))
+		requestLimiter.Pause(delay)
+	}
+}
+
+// isThrottled reports whether a status asks the client to slow down; arXiv
+// answers 503 when an address sends too many requests
+func isThrottled(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// throttleDelay is the backoff before retry attempt: throttleBaseDelay doubled for
+// every attempt, capped at throttleMaxDelay, plus up to half of it as jitter so
+// that processes throttled together do not retry together
+func throttleDelay(attempt int) time.Duration {
+	delay := throttleBaseDelay << min(attempt-1, 16)
+	if delay > throttleMaxDelay || delay <= 0 {
+		delay = throttleMaxDelay
+	}
+	if half := int64(delay / 2); half > 0 {
+		delay += time.Duration(rand.Int64N(half))
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// CheckSourceAvailable reports whether arXiv has a LaTeX source for a paper, with a
+// HEAD request on its e-print URL. Papers submitted as PDF only answer with a PDF.
+func (d *SourceDownloader) CheckSourceAvailable(arxivID string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, BuildArxivURL(arxivID), nil)
+	if err != nil {
+		return false, types.NewAppError(types.ErrInternal, "failed to create HTTP request", err)
+	}
+	req.Header.Set("User-Agent", "LaTeX-Translator/1.0")
+
+	resp, err := d.do(req)
+	if err != nil {
+		return false, types.NewAppError(types.ErrNetwork, "network request failed", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, handleHTTPError(resp.StatusCode, req.URL.String())
+	}
+	return !strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "pdf"), nil
+}
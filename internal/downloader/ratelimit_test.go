@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// withoutRateLimit disables the shared limiter and shortens the backoff for a test
+func withoutRateLimit(t *testing.T) {
+	t.Helper()
+	base, maxDelay := throttleBaseDelay, throttleMaxDelay
+	throttleBaseDelay, throttleMaxDelay = time.Millisecond, 5*time.Millisecond
+	SetRateLimit(0, 1)
+	t.Cleanup(func() {
+		throttleBaseDelay, throttleMaxDelay = base, maxDelay
+		SetRateLimit(DefaultRequestInterval, 1)
+	})
+}
+
+func TestRateLimiterSpacesRequests(t *testing.T) {
+	l := NewRateLimiter(time.Second, 2)
+	now := time.Now()
+	for i, want := range []time.Duration{0, 0, time.Second, 2 * time.Second} {
+		if got := l.reserve(now); got.Round(time.Millisecond) != want {
+			t.Errorf("request %d waits %v, want %v", i, got, want)
+		}
+	}
+	if got := l.reserve(now.Add(10 * time.Second)); got > 0 {
+		t.Errorf("request after the bucket refilled waits %v", got)
+	}
+
+	l.SetRate(0, 1)
+	if got := l.reserve(now); got != 0 {
+		t.Errorf("disabled limiter waits %v", got)
+	}
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("disabled limiter Wait() error: %v", err)
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	l := NewRateLimiter(time.Second, 1)
+	l.Pause(time.Minute)
+	if got := l.reserve(time.Now()); got < 59*time.Second {
+		t.Errorf("request during a pause waits %v, want about a minute", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Errorf("Wait() with a cancelled context = %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDownloadBacksOffWhenThrottled(t *testing.T) {
+	withoutRateLimit(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/x-tex")
+			w.Write([]byte("\\documentclass{article}\n"))
+		}
+	}))
+	defer server.Close()
+
+	d := NewSourceDownloader(t.TempDir())
+	if _, err := d.DownloadFromURL(server.URL + "/e-print/2301.00001"); err != nil {
+		t.Fatalf("DownloadFromURL() error: %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("server got %d requests, want 3", n)
+	}
+}
+
+func TestDownloadGivesUpWhenAlwaysThrottled(t *testing.T) {
+	withoutRateLimit(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := NewSourceDownloader(t.TempDir()).DownloadFromURL(server.URL + "/e-print/2301.00001")
+	appErr, ok := err.(*types.AppError)
+	if !ok || appErr.Code != types.ErrAPIRateLimit {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	// The throttled request is not retried again by the network retry loop
+	if n := atomic.LoadInt32(&requests); n != MaxThrottleRetries+1 {
+		t.Errorf("server got %d requests, want %d", n, MaxThrottleRetries+1)
+	}
+}
@@ -8,11 +8,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
+
+	"latex-translator/internal/netproxy"
+	"latex-translator/internal/results"
 )
 
 // Uploader handles file uploads to GitHub
@@ -44,7 +48,8 @@ func NewUploader(token, owner, repo string) *Uploader {
 		owner: owner,
 		repo:  repo,
 		client: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout:   60 * time.Second,
+			Transport: netproxy.NewTransport(),
 		},
 	}
 }
@@ -81,9 +86,19 @@ resp, err := u.client.Do(req)
 	return nil
 }
 
+// contentsURL returns the contents API URL of a repository path.
+// Each path segment is escaped so titles with spaces, "#" or "?" do not break the URL.
+func (u *Uploader) contentsURL(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = neturl.PathEscape(segment)
+	}
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", u.owner, u.repo, strings.Join(segments, "/"))
+}
+
 // CheckFileExists checks if a file exists in the repository
 func (u *Uploader) CheckFileExists(path string) (*FileExistsResult, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", u.owner, u.repo, path)
+	url := u.contentsURL(path)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -165,7 +180,7 @@ func (u *Uploader) UploadFile(localPath, remotePath, commitMsg string, overwrite
 	}
 
 	// Create the API request
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", u.owner, u.repo, remotePath)
+	url := u.contentsURL(remotePath)
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -496,7 +511,7 @@ type ArxivFileInfo struct {
 // This is used to find old files that need to be deleted when uploading new ones
 func (u *Uploader) ListFilesForArxivID(arxivID string) ([]ArxivFileInfo, error) {
 	// Normalize arXiv ID for matching
-	safeID := strings.ReplaceAll(arxivID, "/", "_")
+	safeID := results.SanitizeFileName(arxivID)
 
 	// List all files in the repository root
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/", u.owner, u.repo)
@@ -578,7 +593,7 @@ func (u *Uploader) DeleteFile(path, sha, commitMsg string) error {
 		return errors.New("GitHub token is required for deleting files")
 	}
 
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", u.owner, u.repo, path)
+	url := u.contentsURL(path)
 
 	requestBody := map[string]interface{}{
 		"message": commitMsg,
@@ -0,0 +1,210 @@
+// Package hooks notifies a webhook or runs a command when a translation finishes or fails,
+// e.g. to get a message when an overnight run is done. Delivery failures are logged and
+// never fail the translation.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
+)
+
+// Terminal states reported to a hook
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+// Modes of the translation reported to a hook
+const (
+	ModeLaTeX = "latex"
+	ModePDF   = "pdf"
+)
+
+// Limits of a hook, so a dead endpoint or a stuck command cannot hold up the pipeline
+// for long; variables so tests can shorten them
+var (
+	WebhookTimeout  = 5 * time.Second // per request
+	WebhookAttempts = 3
+	webhookBackoff  = time.Second // doubled after each failed attempt
+	CommandTimeout  = time.Minute
+)
+
+// Payload describes a finished translation. It is the JSON body posted to a webhook, and
+// the LT_* environment variables of a command.
+type Payload struct {
+	Source          string    `json:"source"` // arXiv ID, URL or path as given
+	Mode            string    `json:"mode"`   // latex or pdf
+	Status          string    `json:"status"` // success or error
+	Error           string    `json:"error,omitempty"`
+	OriginalPDF     string    `json:"original_pdf,omitempty"`
+	TranslatedPDF   string    `json:"translated_pdf,omitempty"`
+	BilingualPDF    string    `json:"bilingual_pdf,omitempty"`
+	TokensUsed      int       `json:"tokens_used"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	FinishedAt      time.Time `json:"finished_at"`
+}
+
+// Env returns the payload as the environment variables of a command hook
+func (p Payload) Env() []string {
+	payload, _ := json.Marshal(p)
+	return []string{
+		"LT_SOURCE=" + p.Source,
+		"LT_MODE=" + p.Mode,
+		"LT_STATUS=" + p.Status,
+		"LT_ERROR=" + p.Error,
+		"LT_ORIGINAL_PDF=" + p.OriginalPDF,
+		"LT_TRANSLATED_PDF=" + p.TranslatedPDF,
+		"LT_BILINGUAL_PDF=" + p.BilingualPDF,
+		"LT_TOKENS_USED=" + strconv.Itoa(p.TokensUsed),
+		"LT_DURATION_SECONDS=" + strconv.FormatFloat(p.DurationSeconds, 'f', 0, 64),
+		"LT_PAYLOAD=" + string(payload),
+	}
+}
+
+// IsWebhook reports whether a hook is a webhook URL rather than a command
+func IsWebhook(hook string) bool {
+	lower := strings.ToLower(strings.TrimSpace(hook))
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// Validate checks a hook: a webhook must be an https URL (http only on this machine, for
+// local receivers); anything else is a command. Empty is valid and means no hook.
+func Validate(hook string) error {
+	hook = strings.TrimSpace(hook)
+	if !IsWebhook(hook) {
+		return nil
+	}
+	u, err := url.Parse(hook)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("无效的 webhook 地址: %s", hook)
+	}
+	if strings.EqualFold(u.Scheme, "http") && !isLoopback(u.Hostname()) {
+		return fmt.Errorf("webhook 必须使用 HTTPS (HTTP 只允许本机地址): %s", hook)
+	}
+	return nil
+}
+
+// isLoopback reports whether host names this machine
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Run delivers the payload to the hook: a POST of the JSON payload to a webhook URL, with
+// retries, or the command run by the shell with the payload in its environment. It returns
+// when the delivery is done or has failed; failures are logged, not returned.
+func Run(hook string, payload Payload) {
+	hook = strings.TrimSpace(hook)
+	if hook == "" {
+		return
+	}
+	if payload.FinishedAt.IsZero() {
+		payload.FinishedAt = time.Now()
+	}
+
+	var err error
+	if IsWebhook(hook) {
+		if err = Validate(hook); err == nil {
+			err = postWebhook(hook, payload)
+		}
+	} else {
+		err = runCommand(hook, payload)
+	}
+	if err != nil {
+		logger.Warn("completion hook failed",
+			logger.String("source", payload.Source),
+			logger.String("status", payload.Status),
+			logger.Err(err))
+		return
+	}
+	logger.Info("completion hook delivered",
+		logger.String("source", payload.Source),
+		logger.String("status", payload.Status),
+		logger.Bool("webhook", IsWebhook(hook)))
+}
+
+// postWebhook posts the payload, retrying network errors, timeouts and server errors
+func postWebhook(endpoint string, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := netproxy.NewClient(WebhookTimeout)
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postOnce(client, endpoint, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= WebhookAttempts {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		logger.Debug("webhook failed, retrying", logger.Int("attempt", attempt), logger.Err(err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postOnce posts the body once; retry reports whether the failure may be temporary
+func postOnce(client *http.Client, endpoint string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "latex-translator")
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	temporary := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return temporary, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// runCommand runs the command with the shell, the payload in its environment
+func runCommand(command string, payload Payload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), payload.Env()...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("command timed out after %v", CommandTimeout)
+	}
+	if err != nil {
+		out := strings.TrimSpace(string(output))
+		if len(out) > 500 {
+			out = out[:500] + "..."
+		}
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func shortenLimits(t *testing.T) {
+	t.Helper()
+	timeout, backoff := WebhookTimeout, webhookBackoff
+	WebhookTimeout, webhookBackoff = time.Second, 10*time.Millisecond
+	t.Cleanup(func() { WebhookTimeout, webhookBackoff = timeout, backoff })
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	shortenLimits(t)
+	var calls atomic.Int32
+	var got Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	err := postWebhook(server.URL, Payload{Source: "2301.00001", Status: StatusSuccess, TranslatedPDF: "out/zh.pdf", TokensUsed: 1200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+	if got.Source != "2301.00001" || got.TranslatedPDF != "out/zh.pdf" || got.TokensUsed != 1200 {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	shortenLimits(t)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	if err := postWebhook(server.URL, Payload{}); err == nil {
+		t.Error("failing endpoint reported as delivered")
+	}
+	if int(calls.Load()) != WebhookAttempts {
+		t.Errorf("calls = %d, want %d", calls.Load(), WebhookAttempts)
+	}
+
+	// Client errors are not retried
+	calls.Store(0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if err := postWebhook(server.URL, Payload{}); err == nil || calls.Load() != 1 {
+		t.Errorf("err = %v, calls = %d", err, calls.Load())
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "hook.txt")
+	command := `printf '%s %s %s' "$LT_STATUS" "$LT_SOURCE" "$LT_TOKENS_USED" > ` + out
+	if err := runCommand(command, Payload{Source: "2301.00001", Status: StatusError, TokensUsed: 42}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(out)
+	if string(got) != "error 2301.00001 42" {
+		t.Errorf("command saw %q", got)
+	}
+
+	if err := runCommand("echo broken >&2; exit 3", Payload{}); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing command: err = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for hook, valid := range map[string]bool{
+		"":                                 true,
+		"https://hooks.example.com/notify": true,
+		"http://localhost:8080/done":       true,
+		"http://127.0.0.1/done":            true,
+		"http://hooks.example.com/notify":  false,
+		"https://":                         false,
+		"notify-send \"$LT_STATUS\"":       true,
+		"curl -d @- https://example.com/x": true,
+	} {
+		if err := Validate(hook); (err == nil) != valid {
+			t.Errorf("Validate(%q) = %v, want valid=%v", hook, err, valid)
+		}
+	}
+}
@@ -0,0 +1,776 @@
+// Package htmlexport renders translated LaTeX as a standalone, best-effort HTML page.
+//
+// It is used when the translation cannot be compiled (no TeX distribution on the machine),
+// so the reader still gets readable text. Only the structure a reader needs is rendered:
+// title, abstract, sections, paragraphs, lists, tables, captions, footnotes and the
+// bibliography. Math is kept as LaTeX source between MathJax delimiters; figures are
+// reduced to their captions. Unknown commands are dropped and their arguments kept.
+package htmlexport
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/parser"
+)
+
+// Options controls the rendered page
+type Options struct {
+	// Title is used when the document has no \title
+	Title string
+	// Notice is shown in a box at the top of the page, e.g. that the document was not compiled
+	Notice string
+	// ReadInput returns the content of a file included with \input or \include, by the name
+	// used in the command; ok is false when the file is not available
+	ReadInput func(name string) (content string, ok bool)
+}
+
+// maxInputDepth limits nested \input expansion, against files that include each other
+const maxInputDepth = 10
+
+// placeholder delimits rendered fragments in the text still to be converted; NUL never
+// occurs in LaTeX source
+const placeholder = "\x00"
+
+var (
+	inputPattern       = regexp.MustCompile(`\\(?:input|include)\s*\{([^}]+)\}`)
+	placeholderPattern = regexp.MustCompile("\x00(\\d+)\x00")
+	blankLinePattern   = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	environmentPattern = regexp.MustCompile(`\\begin\s*\{([a-zA-Z*]+)\}`)
+	itemPattern        = regexp.MustCompile(`\\item\b\s*(?:\[([^\]]*)\])?`)
+	bibitemPattern     = regexp.MustCompile(`\\bibitem\s*(?:\[[^\]]*\])?\s*\{[^}]*\}`)
+	tableRulePattern   = regexp.MustCompile(`\\(?:hline|toprule|midrule|bottomrule|cline\s*\{[^}]*\}|cmidrule\s*(?:\([^)]*\))?\s*\{[^}]*\})`)
+)
+
+// sectionTags maps sectioning commands to heading tags
+var sectionTags = map[string]string{
+	"part": "h2", "chapter": "h2", "section": "h2", "subsection": "h3",
+	"subsubsection": "h4", "paragraph": "h5", "subparagraph": "h5",
+}
+
+// mathEnvironments are rendered as display math
+var mathEnvironments = map[string]bool{
+	"equation": true, "equation*": true, "align": true, "align*": true, "gather": true,
+	"gather*": true, "multline": true, "multline*": true, "eqnarray": true, "eqnarray*": true,
+	"displaymath": true, "math": true, "flalign": true, "flalign*": true, "alignat": true,
+	"alignat*": true,
+}
+
+// verbatimEnvironments are rendered as preformatted text
+var verbatimEnvironments = map[string]bool{
+	"verbatim": true, "verbatim*": true, "Verbatim": true, "lstlisting": true, "minted": true,
+	"alltt": true,
+}
+
+// droppedEnvironments are not rendered at all
+var droppedEnvironments = map[string]bool{
+	"comment": true, "filecontents": true, "filecontents*": true, "tikzpicture": true,
+	"picture": true,
+}
+
+// theoremNames are the headings of theorem-like environments
+var theoremNames = map[string]string{
+	"theorem": "定理", "lemma": "引理", "corollary": "推论", "proposition": "命题",
+	"definition": "定义", "remark": "注", "example": "例", "proof": "证明", "claim": "断言",
+	"assumption": "假设", "conjecture": "猜想",
+}
+
+// droppedCommands are removed together with their arguments
+var droppedCommands = map[string]int{
+	"label": 1, "vspace": 1, "vspace*": 1, "hspace": 1, "hspace*": 1, "includegraphics": 1,
+	"bibliographystyle": 1, "bibliography": 1, "usepackage": 1, "newcommand": 2,
+	"renewcommand": 2, "setlength": 2, "addtolength": 2, "setcounter": 2, "pagestyle": 1,
+	"thispagestyle": 1, "input": 1, "include": 1, "fontsize": 2, "color": 1, "index": 1,
+	"caption":   1,
+	"maketitle": 0, "tableofcontents": 0, "centering": 0, "noindent": 0, "clearpage": 0,
+	"newpage": 0, "cleardoublepage": 0, "appendix": 0, "small": 0, "footnotesize": 0,
+	"scriptsize": 0, "tiny": 0, "large": 0, "Large": 0, "LARGE": 0, "huge": 0, "Huge": 0,
+	"normalsize": 0, "bfseries": 0, "itshape": 0, "ttfamily": 0, "rmfamily": 0, "sffamily": 0,
+	"medskip": 0, "smallskip": 0, "bigskip": 0, "par": 0, "hfill": 0, "vfill": 0,
+	"protect": 0, "nocite": 1, "thanks": 1, "and": 0, "today": 0, "linebreak": 0,
+	"pagebreak": 0, "sloppy": 0, "raggedright": 0,
+}
+
+// inlineTags maps text style commands to HTML tags
+var inlineTags = map[string]string{
+	"textbf": "strong", "emph": "em", "textit": "em", "textsl": "em", "texttt": "code",
+	"underline": "u", "textsc": "span", "textsuperscript": "sup", "textsubscript": "sub",
+}
+
+// symbolCommands are commands that stand for a piece of text
+var symbolCommands = map[string]string{
+	"LaTeX": "LaTeX", "TeX": "TeX", "ldots": "…", "dots": "…", "textendash": "–",
+	"textemdash": "—", "S": "§", "P": "¶", "copyright": "©", "textbackslash": "\\",
+	"ie": "i.e.", "eg": "e.g.", "etal": "et al.",
+}
+
+// renderer holds the rendered fragments referenced by placeholders
+type renderer struct {
+	opts      Options
+	fragments []string
+}
+
+// RenderHTML renders LaTeX source as a standalone HTML page
+func RenderHTML(content string, opts Options) string {
+	r := &renderer{opts: opts}
+	content = r.expandInputs(stripComments(content), 0)
+
+	title := opts.Title
+	if t, ok := commandArgument(content, "title"); ok {
+		title = strings.TrimSpace(r.plainText(t))
+	}
+	author, _ := commandArgument(content, "author")
+
+	body := parser.DocumentBody(content)
+	if i := strings.Index(body, `\begin{document}`); i >= 0 {
+		body = body[i+len(`\begin{document}`):]
+	}
+	body = strings.TrimSuffix(strings.TrimSpace(body), `\end{document}`)
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html lang=\"zh\">\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	sb.WriteString(pageStyle)
+	sb.WriteString("</head>\n<body>\n")
+	if opts.Notice != "" {
+		sb.WriteString("<div class=\"notice\">" + html.EscapeString(opts.Notice) + "</div>\n")
+	}
+	if title != "" {
+		sb.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	}
+	if a := strings.TrimSpace(r.plainText(author)); a != "" {
+		sb.WriteString("<p class=\"author\">" + html.EscapeString(a) + "</p>\n")
+	}
+	sb.WriteString(r.restore(r.renderBlocks(body)))
+	sb.WriteString("\n</body>\n</html>\n")
+	return sb.String()
+}
+
+// stripComments removes LaTeX comments, keeping escaped percent signs and the contents of
+// verbatim-like environments
+func stripComments(content string) string {
+	lines := strings.Split(content, "\n")
+	inVerbatim := ""
+	for i, line := range lines {
+		if inVerbatim != "" {
+			if strings.Contains(line, `\end{`+inVerbatim+`}`) {
+				inVerbatim = ""
+			}
+			continue
+		}
+		for env := range verbatimEnvironments {
+			if strings.Contains(line, `\begin{`+env+`}`) && !strings.Contains(line, `\end{`+env+`}`) {
+				inVerbatim = env
+			}
+		}
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if line[j] == '%' {
+				lines[i] = line[:j]
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expandInputs replaces \input and \include with the content of the included files
+func (r *renderer) expandInputs(content string, depth int) string {
+	if r.opts.ReadInput == nil || depth >= maxInputDepth {
+		return content
+	}
+	return inputPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := strings.TrimSpace(inputPattern.FindStringSubmatch(match)[1])
+		included, ok := r.opts.ReadInput(name)
+		if !ok && path.Ext(name) == "" {
+			included, ok = r.opts.ReadInput(name + ".tex")
+		}
+		if !ok {
+			return ""
+		}
+		return "\n" + r.expandInputs(stripComments(included), depth+1) + "\n"
+	})
+}
+
+// fragment stores rendered HTML and returns its placeholder
+func (r *renderer) fragment(rendered string) string {
+	r.fragments = append(r.fragments, rendered)
+	return fmt.Sprintf("%s%d%s", placeholder, len(r.fragments)-1, placeholder)
+}
+
+// block stores rendered block-level HTML; the placeholder gets its own paragraph
+func (r *renderer) block(rendered string) string {
+	return "\n\n" + r.fragment(rendered) + "\n\n"
+}
+
+// restore replaces placeholders with their fragments, which may contain placeholders
+func (r *renderer) restore(s string) string {
+	for strings.Contains(s, placeholder) {
+		s = placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+			var i int
+			fmt.Sscanf(strings.Trim(m, placeholder), "%d", &i)
+			return r.fragments[i]
+		})
+	}
+	return s
+}
+
+// renderBlocks renders block-level LaTeX: environments, display math, headings and paragraphs
+func (r *renderer) renderBlocks(s string) string {
+	s = r.replaceDisplayMath(s)
+	s = r.replaceEnvironments(s)
+	s = r.replaceHeadings(s)
+
+	var sb strings.Builder
+	for _, para := range blankLinePattern.Split(s, -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if placeholderPattern.FindString(para) == para && strings.HasPrefix(r.restore(para), "<") &&
+			!strings.HasPrefix(r.restore(para), "<span") {
+			sb.WriteString(para + "\n")
+			continue
+		}
+		if text := strings.TrimSpace(r.renderInline(para)); text != "" {
+			sb.WriteString("<p>" + text + "</p>\n")
+		}
+	}
+	return sb.String()
+}
+
+// replaceDisplayMath turns \[...\] and $$...$$ into display math blocks
+func (r *renderer) replaceDisplayMath(s string) string {
+	for _, delims := range [][2]string{{`\[`, `\]`}, {"$$", "$$"}} {
+		for {
+			start := strings.Index(s, delims[0])
+			if start < 0 {
+				break
+			}
+			end := strings.Index(s[start+len(delims[0]):], delims[1])
+			if end < 0 {
+				break
+			}
+			end += start + len(delims[0])
+			math := s[start+len(delims[0]) : end]
+			s = s[:start] + r.block(`<div class="math">\[`+html.EscapeString(math)+`\]</div>`) + s[end+len(delims[1]):]
+		}
+	}
+	return s
+}
+
+// replaceEnvironments renders the top-level environments of s
+func (r *renderer) replaceEnvironments(s string) string {
+	var sb strings.Builder
+	for {
+		loc := environmentPattern.FindStringSubmatchIndex(s)
+		if loc == nil {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		name := s[loc[2]:loc[3]]
+		innerStart := loc[1]
+		innerEnd, end := matchingEnd(s, name, innerStart)
+		if innerEnd < 0 {
+			// Unterminated: drop the \begin and render the rest as text
+			sb.WriteString(s[:loc[0]])
+			s = s[loc[1]:]
+			continue
+		}
+		sb.WriteString(s[:loc[0]])
+		sb.WriteString(r.renderEnvironment(name, s[innerStart:innerEnd]))
+		s = s[end:]
+	}
+}
+
+// matchingEnd finds the \end of an environment whose content starts at pos, taking nested
+// environments of the same name into account. It returns the offsets of the \end command
+// and of the text after it, or -1.
+func matchingEnd(s, name string, pos int) (int, int) {
+	begin, end := `\begin{`+name+`}`, `\end{`+name+`}`
+	depth := 1
+	for i := pos; i < len(s); {
+		nextBegin := strings.Index(s[i:], begin)
+		nextEnd := strings.Index(s[i:], end)
+		if nextEnd < 0 {
+			return -1, -1
+		}
+		if nextBegin >= 0 && nextBegin < nextEnd {
+			depth++
+			i += nextBegin + len(begin)
+			continue
+		}
+		depth--
+		if depth == 0 {
+			return i + nextEnd, i + nextEnd + len(end)
+		}
+		i += nextEnd + len(end)
+	}
+	return -1, -1
+}
+
+// renderEnvironment renders one environment and returns its placeholder
+func (r *renderer) renderEnvironment(name, inner string) string {
+	base := strings.TrimSuffix(name, "*")
+	switch {
+	case droppedEnvironments[name]:
+		return ""
+	case mathEnvironments[name]:
+		return r.block(`<div class="math">\begin{` + name + `}` + html.EscapeString(inner) + `\end{` + name + `}</div>`)
+	case verbatimEnvironments[name]:
+		inner = strings.TrimPrefix(skipOptional(inner), "\n")
+		return r.block("<pre>" + html.EscapeString(inner) + "</pre>")
+	}
+
+	switch base {
+	case "abstract":
+		return r.block("<section class=\"abstract\"><h2>摘要</h2>\n" + r.renderBlocks(inner) + "</section>")
+	case "itemize", "enumerate", "description":
+		return r.block(r.renderList(base, inner))
+	case "thebibliography":
+		_, rest := readGroup(inner, 0)
+		return r.block(r.renderBibliography(inner[rest:]))
+	case "tabular", "tabularx", "tabulary", "longtable", "array":
+		return r.block(r.renderTabular(base, inner))
+	case "figure", "table", "wrapfigure", "wraptable", "sidewaysfigure", "sidewaystable":
+		return r.block(r.renderFloat(base, inner))
+	case "quote", "quotation":
+		return r.block("<blockquote>\n" + r.renderBlocks(inner) + "</blockquote>")
+	}
+	if heading, ok := theoremNames[base]; ok {
+		inner = skipOptional(inner)
+		return r.block("<div class=\"theorem\"><strong>" + heading + "</strong>\n" + r.renderBlocks(inner) + "</div>")
+	}
+	// Anything else (center, minipage, document-specific environments) renders its content
+	_, afterArgs := skipArguments(inner)
+	return r.block("<div>\n" + r.renderBlocks(inner[afterArgs:]) + "</div>")
+}
+
+// renderList renders itemize, enumerate and description environments
+func (r *renderer) renderList(kind, inner string) string {
+	// Nested environments are rendered first so their \item do not split this list
+	inner = r.replaceEnvironments(inner)
+	tag := map[string]string{"itemize": "ul", "enumerate": "ol", "description": "dl"}[kind]
+
+	var sb strings.Builder
+	sb.WriteString("<" + tag + ">\n")
+	locs := itemPattern.FindAllStringSubmatchIndex(inner, -1)
+	for i, loc := range locs {
+		end := len(inner)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		text := r.renderBlocks(inner[loc[1]:end])
+		label := ""
+		if loc[2] >= 0 {
+			label = r.renderInline(inner[loc[2]:loc[3]])
+		}
+		if kind == "description" {
+			sb.WriteString("<dt>" + label + "</dt><dd>" + text + "</dd>\n")
+		} else {
+			sb.WriteString("<li>" + text + "</li>\n")
+		}
+	}
+	sb.WriteString("</" + tag + ">")
+	return sb.String()
+}
+
+// renderBibliography renders thebibliography as a numbered list
+func (r *renderer) renderBibliography(inner string) string {
+	var sb strings.Builder
+	sb.WriteString("<section class=\"bibliography\"><h2>参考文献</h2>\n<ol>\n")
+	locs := bibitemPattern.FindAllStringIndex(inner, -1)
+	for i, loc := range locs {
+		end := len(inner)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		entry := strings.ReplaceAll(inner[loc[1]:end], `\newblock`, " ")
+		sb.WriteString("<li>" + strings.Join(strings.Fields(r.renderInline(entry)), " ") + "</li>\n")
+	}
+	sb.WriteString("</ol></section>")
+	return sb.String()
+}
+
+// renderTabular renders a table environment as an HTML table
+func (r *renderer) renderTabular(kind, inner string) string {
+	// Skip the width argument of tabularx/tabulary and the column specification
+	if kind == "tabularx" || kind == "tabulary" {
+		_, end := readGroup(inner, 0)
+		inner = inner[end:]
+	}
+	inner = skipOptional(inner)
+	if _, end := readGroup(inner, 0); end > 0 {
+		inner = inner[end:]
+	}
+	inner = tableRulePattern.ReplaceAllString(inner, "")
+	inner = r.replaceEnvironments(inner)
+
+	var sb strings.Builder
+	sb.WriteString("<table>\n")
+	for _, row := range splitUnescaped(inner, `\\`) {
+		if strings.TrimSpace(row) == "" {
+			continue
+		}
+		sb.WriteString("<tr>")
+		for _, cell := range splitUnescaped(row, "&") {
+			sb.WriteString("<td>" + strings.TrimSpace(r.renderInline(cell)) + "</td>")
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+// renderFloat renders a figure or table: its caption and, for tables, the table itself
+func (r *renderer) renderFloat(kind, inner string) string {
+	caption, _ := commandArgument(inner, "caption")
+	label := "图"
+	if strings.Contains(kind, "table") {
+		label = "表"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<figure>\n")
+	if strings.Contains(kind, "table") {
+		if loc := environmentPattern.FindStringIndex(inner); loc != nil {
+			sb.WriteString(r.renderBlocks(inner[loc[0]:]))
+		}
+	} else if strings.Contains(inner, `\includegraphics`) {
+		sb.WriteString("<div class=\"figure-placeholder\">[图片未在 HTML 中显示]</div>\n")
+	}
+	if caption != "" {
+		sb.WriteString("<figcaption>" + label + "：" + strings.TrimSpace(r.renderInline(caption)) + "</figcaption>\n")
+	}
+	sb.WriteString("</figure>")
+	return sb.String()
+}
+
+// replaceHeadings turns sectioning commands into headings
+func (r *renderer) replaceHeadings(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+		name := commandAt(s, i+1)
+		tag, ok := sectionTags[strings.TrimSuffix(name, "*")]
+		if !ok {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+		after := skipOptional(s[i+1+len(name):])
+		title, end := readGroup(after, 0)
+		if end < 0 {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+		sb.WriteString(r.block("<" + tag + ">" + strings.TrimSpace(r.renderInline(title)) + "</" + tag + ">"))
+		i = len(s) - len(after) + end
+	}
+	return sb.String()
+}
+
+// renderInline renders the inline LaTeX of a paragraph: math, text styles, links and
+// citations. Everything that is not markup is HTML-escaped.
+func (r *renderer) renderInline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == placeholder[0]:
+			end := strings.Index(s[i+1:], placeholder)
+			if end < 0 {
+				i++
+				continue
+			}
+			sb.WriteString(s[i : i+end+2])
+			i += end + 2
+		case c == '$':
+			end := strings.IndexByte(s[i+1:], '$')
+			if end < 0 {
+				sb.WriteString("$")
+				i++
+				continue
+			}
+			sb.WriteString(`<span class="math">\(` + html.EscapeString(s[i+1:i+1+end]) + `\)</span>`)
+			i += end + 2
+		case c == '\\':
+			n, out := r.renderCommand(s, i)
+			sb.WriteString(out)
+			i = n
+		case c == '{' || c == '}':
+			i++
+		case c == '~':
+			sb.WriteString("&nbsp;")
+			i++
+		case strings.HasPrefix(s[i:], "---"):
+			sb.WriteString("—")
+			i += 3
+		case strings.HasPrefix(s[i:], "--"):
+			sb.WriteString("–")
+			i += 2
+		case strings.HasPrefix(s[i:], "``"):
+			sb.WriteString("“")
+			i += 2
+		case strings.HasPrefix(s[i:], "''"):
+			sb.WriteString("”")
+			i += 2
+		case c == '\n':
+			sb.WriteString(" ")
+			i++
+		default:
+			// Byte-wise, so multi-byte UTF-8 sequences are copied unchanged
+			switch c {
+			case '<', '>', '&', '"', '\'':
+				sb.WriteString(html.EscapeString(string(c)))
+			default:
+				sb.WriteByte(c)
+			}
+			i++
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// renderCommand renders the command at s[i] (a backslash) and returns the offset after it
+func (r *renderer) renderCommand(s string, i int) (int, string) {
+	name := commandAt(s, i+1)
+	if name == "" {
+		if i+1 >= len(s) {
+			return i + 1, ""
+		}
+		switch s[i+1] {
+		case '\\':
+			return skipOptionalAt(s, i+2), "<br>"
+		case '(':
+			if end := strings.Index(s[i+2:], `\)`); end >= 0 {
+				return i + 2 + end + 2, `<span class="math">\(` + html.EscapeString(s[i+2:i+2+end]) + `\)</span>`
+			}
+		case ',', ' ', ';', '!', '/':
+			return i + 2, " "
+		}
+		// Escaped character such as \% or \&
+		return i + 2, html.EscapeString(s[i+1 : i+2])
+	}
+	pos := i + 1 + len(name)
+
+	if argCount, ok := droppedCommands[name]; ok {
+		pos = skipOptionalAt(s, pos)
+		for n := 0; n < argCount; n++ {
+			if _, end := readGroup(s, pos); end > 0 {
+				pos = end
+			}
+		}
+		return pos, ""
+	}
+	if text, ok := symbolCommands[name]; ok {
+		if strings.HasPrefix(s[pos:], "{}") {
+			pos += 2
+		}
+		return pos, html.EscapeString(text)
+	}
+
+	switch {
+	case inlineTags[name] != "":
+		arg, end := readGroup(s, pos)
+		if end < 0 {
+			return pos, ""
+		}
+		tag := inlineTags[name]
+		return end, "<" + tag + ">" + r.renderInline(arg) + "</" + tag + ">"
+	case name == "url":
+		arg, end := readGroup(s, pos)
+		if end < 0 {
+			return pos, ""
+		}
+		return end, `<a href="` + html.EscapeString(arg) + `">` + html.EscapeString(arg) + "</a>"
+	case name == "href":
+		target, end := readGroup(s, pos)
+		if end < 0 {
+			return pos, ""
+		}
+		text, end2 := readGroup(s, end)
+		if end2 < 0 {
+			return end, ""
+		}
+		return end2, `<a href="` + html.EscapeString(target) + `">` + r.renderInline(text) + "</a>"
+	case name == "footnote":
+		arg, end := readGroup(s, skipOptionalAt(s, pos))
+		if end < 0 {
+			return pos, ""
+		}
+		return end, `<span class="footnote">（注：` + r.renderInline(arg) + "）</span>"
+	case strings.HasPrefix(name, "cite") || strings.HasPrefix(name, "Cite"):
+		pos = skipOptionalAt(s, skipOptionalAt(s, pos))
+		keys, end := readGroup(s, pos)
+		if end < 0 {
+			return pos, ""
+		}
+		return end, `<span class="cite">[` + html.EscapeString(keys) + "]</span>"
+	case name == "ref" || name == "eqref" || name == "autoref" || name == "cref" || name == "Cref" || name == "pageref":
+		label, end := readGroup(s, pos)
+		if end < 0 {
+			return pos, ""
+		}
+		return end, `<span class="ref">[` + html.EscapeString(label) + "]</span>"
+	}
+
+	// Unknown command: drop it and keep its arguments
+	return skipOptionalAt(s, pos), ""
+}
+
+// plainText renders inline LaTeX and strips the HTML tags, for titles and authors
+func (r *renderer) plainText(s string) string {
+	s = r.restore(r.renderInline(s))
+	s = regexp.MustCompile(`<[^>]*>`).ReplaceAllString(s, "")
+	return html.UnescapeString(strings.Join(strings.Fields(s), " "))
+}
+
+// commandAt returns the name of the command starting at s[pos] (after the backslash), with
+// a trailing star, or "" when s[pos] is not a letter
+func commandAt(s string, pos int) string {
+	end := pos
+	for end < len(s) && (s[end] >= 'a' && s[end] <= 'z' || s[end] >= 'A' && s[end] <= 'Z') {
+		end++
+	}
+	if end == pos {
+		return ""
+	}
+	if end < len(s) && s[end] == '*' {
+		end++
+	}
+	return s[pos:end]
+}
+
+// commandArgument returns the first argument of the first occurrence of \name
+func commandArgument(s, name string) (string, bool) {
+	pattern := regexp.MustCompile(`\\` + regexp.QuoteMeta(name) + `\*?\s*(?:\[[^\]]*\])?\s*\{`)
+	loc := pattern.FindStringIndex(s)
+	if loc == nil {
+		return "", false
+	}
+	arg, end := readGroup(s, loc[1]-1)
+	return arg, end > 0
+}
+
+// readGroup reads the brace group starting at s[pos] (after optional spaces) and returns its
+// content and the offset after the closing brace, or -1 when there is no complete group
+func readGroup(s string, pos int) (string, int) {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t' || s[pos] == '\n') {
+		pos++
+	}
+	if pos >= len(s) || s[pos] != '{' {
+		return "", -1
+	}
+	depth := 0
+	for i := pos; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[pos+1 : i], i + 1
+			}
+		}
+	}
+	return "", -1
+}
+
+// skipOptional drops a leading optional argument [...]
+func skipOptional(s string) string {
+	return s[skipOptionalAt(s, 0):]
+}
+
+// skipOptionalAt returns the offset after an optional argument [...] at s[pos], or pos
+func skipOptionalAt(s string, pos int) int {
+	i := pos
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	if i >= len(s) || s[i] != '[' {
+		return pos
+	}
+	if end := strings.IndexByte(s[i:], ']'); end >= 0 {
+		return i + end + 1
+	}
+	return pos
+}
+
+// skipArguments skips the optional and brace arguments directly after an environment's
+// \begin (such as the width of a minipage) and returns them with the offset after them
+func skipArguments(s string) ([]string, int) {
+	var args []string
+	pos := skipOptionalAt(s, 0)
+	for {
+		// Arguments follow \begin{...} directly; a group on a later line is content
+		if pos < len(s) && s[pos] != '{' {
+			return args, pos
+		}
+		arg, end := readGroup(s, pos)
+		if end < 0 {
+			return args, pos
+		}
+		args = append(args, arg)
+		pos = end
+	}
+}
+
+// splitUnescaped splits s at sep, ignoring occurrences escaped with a backslash (\&) and
+// inside braces
+func splitUnescaped(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], sep) && depth == 0:
+			parts = append(parts, s[start:i])
+			i += len(sep) - 1
+			start = i + 1
+		case s[i] == '\\':
+			i++
+		case s[i] == '{':
+			depth++
+		case s[i] == '}':
+			depth--
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// pageStyle is the stylesheet of the page and the MathJax loader; without network access the
+// math stays readable as LaTeX source
+const pageStyle = `<style>
+body { max-width: 820px; margin: 2em auto; padding: 0 1em; font-family: "Noto Serif CJK SC", "Source Han Serif SC", SimSun, serif; line-height: 1.8; color: #222; }
+h1 { text-align: center; }
+.author { text-align: center; color: #555; }
+.notice { border: 1px solid #e65100; background: #fff3e0; color: #e65100; padding: 0.6em 1em; border-radius: 6px; }
+.abstract { background: #f7f7f7; padding: 0.5em 1.2em; border-radius: 6px; }
+.math { overflow-x: auto; }
+div.math { margin: 1em 0; text-align: center; }
+pre { background: #f4f4f4; padding: 0.8em; overflow-x: auto; }
+table { border-collapse: collapse; margin: 0 auto; }
+td { border: 1px solid #ccc; padding: 0.2em 0.6em; }
+figure { margin: 1.5em 0; text-align: center; }
+figcaption { color: #444; font-size: 0.95em; }
+.figure-placeholder { color: #999; border: 1px dashed #ccc; padding: 1em; }
+.theorem { margin: 1em 0; }
+.footnote { color: #666; font-size: 0.9em; }
+.cite, .ref { color: #1565c0; }
+</style>
+<script>window.MathJax = { tex: { inlineMath: [['\\(', '\\)']] } };</script>
+<script async src="https://cdn.jsdelivr.net/npm/mathjax@3/es5/tex-chtml.js"></script>
+`
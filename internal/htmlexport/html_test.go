@@ -0,0 +1,113 @@
+package htmlexport
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleDocument = `\documentclass{article}
+\usepackage{amsmath}
+\title{关于 \emph{翻译} 的研究}
+\author{张三 \and 李四}
+\begin{document}
+\maketitle
+\begin{abstract}
+本文研究翻译。% a comment
+\end{abstract}
+\section{引言}\label{sec:intro}
+我们提出一种方法\cite{smith2020}，见第~\ref{sec:method}节。成本为 5\% 以下\footnote{粗略估计。}。
+公式 $a<b$ 成立。
+
+\[ x^2 \]
+\begin{itemize}
+\item 第一点
+\item 第二点 \textbf{重要}
+\end{itemize}
+\begin{table}
+\centering
+\begin{tabular}{|c|c|}
+\hline
+A & B \\
+1 & 2 \\
+\hline
+\end{tabular}
+\caption{结果对比}
+\end{table}
+\input{appendix}
+\begin{thebibliography}{9}
+\bibitem{smith2020} J. Smith. \newblock A paper.
+\end{thebibliography}
+\end{document}
+`
+
+func render(t *testing.T) string {
+	t.Helper()
+	return RenderHTML(sampleDocument, Options{
+		Title:  "fallback",
+		Notice: "未编译",
+		ReadInput: func(name string) (string, bool) {
+			if name == "appendix.tex" {
+				return "\\section{附录}\n附录内容。", true
+			}
+			return "", false
+		},
+	})
+}
+
+func TestRenderHTMLStructure(t *testing.T) {
+	got := render(t)
+	for _, want := range []string{
+		"<title>关于 翻译 的研究</title>",
+		"<h1>关于 翻译 的研究</h1>",
+		`<p class="author">张三 李四</p>`,
+		`<div class="notice">未编译</div>`,
+		"<h2>摘要</h2>",
+		"<p>本文研究翻译。</p>",
+		"<h2>引言</h2>",
+		`<span class="cite">[smith2020]</span>`,
+		`<span class="ref">[sec:method]</span>`,
+		"5% 以下",
+		"（注：粗略估计。）",
+		`<span class="math">\(a&lt;b\)</span>`,
+		`<div class="math">\[ x^2 \]</div>`,
+		"<li><p>第二点 <strong>重要</strong></p>\n</li>",
+		"<tr><td>A</td><td>B</td></tr>",
+		"<figcaption>表：结果对比</figcaption>",
+		"<h2>附录</h2>",
+		"<h2>参考文献</h2>",
+		"<li>J. Smith. A paper.</li>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"a comment", `\label`, `\maketitle`, `\centering`, "\x00"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestRenderHTMLWithoutTitleUsesOption(t *testing.T) {
+	got := RenderHTML("\\begin{document}\nText & more\n\\end{document}", Options{Title: "paper.tex"})
+	if !strings.Contains(got, "<h1>paper.tex</h1>") {
+		t.Errorf("fallback title not used:\n%s", got)
+	}
+	if !strings.Contains(got, "<p>Text &amp; more</p>") {
+		t.Errorf("text not escaped:\n%s", got)
+	}
+}
+
+func TestRenderHTMLVerbatimIsNotInterpreted(t *testing.T) {
+	got := RenderHTML("\\begin{document}\n\\begin{verbatim}\n\\textbf{x} % kept\n\\end{verbatim}\n\\end{document}", Options{})
+	if !strings.Contains(got, "<pre>\\textbf{x} % kept\n</pre>") {
+		t.Errorf("verbatim content changed:\n%s", got)
+	}
+}
+
+func TestRenderHTMLNestedLists(t *testing.T) {
+	got := RenderHTML("\\begin{document}\n\\begin{enumerate}\n\\item A\n\\begin{itemize}\n\\item B\n\\end{itemize}\n\\item C\n\\end{enumerate}\n\\end{document}", Options{})
+	if strings.Count(got, "<li>") != 3 || !strings.Contains(got, "<ol>") || !strings.Contains(got, "<ul>") {
+		t.Errorf("nested lists not rendered:\n%s", got)
+	}
+}
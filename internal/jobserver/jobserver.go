@@ -0,0 +1,569 @@
+// Package jobserver accepts translation jobs over HTTP for --serve, so papers can be
+// submitted from another machine to one running the translator. Jobs wait in a queue and
+// are run by a fixed set of runners, each translating one paper at a time; the job
+// records keep the status reported by the runner and the paths of the results.
+package jobserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
+	"latex-translator/internal/pdfserve"
+	"latex-translator/internal/types"
+)
+
+// States of a job
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateDone      = "done"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// MaxQueuedJobs is the number of jobs waiting for a runner; more submissions are refused
+// until one starts
+const MaxQueuedJobs = 100
+
+// Finished jobs are kept for their status and result paths until there are more than
+// MaxFinishedJobs of them or they finished FinishedJobTTL ago; the result files stay on disk
+const (
+	MaxFinishedJobs = 200
+	FinishedJobTTL  = 24 * time.Hour
+)
+
+// CancelWait bounds how long DELETE /jobs/{id} waits for the runner to stop a running job
+const CancelWait = 30 * time.Second
+
+// Runner translates one paper at a time through the normal pipeline
+type Runner interface {
+	// Run translates input and returns its result, reporting every status change to
+	// onStatus. It returns an AppError with ErrCancelled when Cancel stopped it.
+	Run(input string, onStatus func(*types.Status)) (*types.ProcessResult, error)
+	// Cancel stops the translation Run is running, if any
+	Cancel()
+}
+
+// Outputs are the result files of a finished job
+type Outputs struct {
+	OriginalPDF    string `json:"original_pdf,omitempty"`
+	TranslatedPDF  string `json:"translated_pdf,omitempty"`
+	BilingualPDF   string `json:"bilingual_pdf,omitempty"`
+	TranslatedTex  string `json:"translated_tex,omitempty"`
+	TranslatedHTML string `json:"translated_html,omitempty"`
+}
+
+// Job is the record of a submitted job returned by the API
+type Job struct {
+	ID         string       `json:"id"`
+	Input      string       `json:"input"`
+	State      string       `json:"state"`
+	Status     types.Status `json:"status"` // as App.GetStatus reports it
+	SourceID   string       `json:"source_id,omitempty"`
+	Outputs    *Outputs     `json:"outputs,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	ErrorCode  string       `json:"error_code,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+// finished reports whether the job reached a terminal state
+func (j *Job) finished() bool {
+	return j.State == StateDone || j.State == StateFailed || j.State == StateCancelled
+}
+
+// job is a Job with the runner running it
+type job struct {
+	Job
+	runner Runner
+	done   chan struct{} // closed when the job reaches a terminal state
+}
+
+// Server queues the submitted jobs and runs them with its runners
+type Server struct {
+	token   string
+	pathDir string // directory of the zip files clients may submit by path; empty for none
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	order   []string // job IDs in submission order
+	closing bool
+
+	maxFinished int
+	finishedTTL time.Duration
+
+	queue   chan *job
+	workers sync.WaitGroup
+}
+
+// New returns a server running jobs with runners, one job per runner at a time. With a
+// token, every request must carry it as a bearer token.
+func New(runners []Runner, token string) *Server {
+	s := &Server{
+		token:       token,
+		jobs:        make(map[string]*job),
+		queue:       make(chan *job, MaxQueuedJobs),
+		maxFinished: MaxFinishedJobs,
+		finishedTTL: FinishedJobTTL,
+	}
+	for _, runner := range runners {
+		s.workers.Add(1)
+		go s.work(runner)
+	}
+	return s
+}
+
+// AllowLocalPaths lets clients submit the zip files in dir and its subdirectories by
+// path. Without it only arXiv IDs and URLs are accepted, so clients cannot make the server
+// read its own files. It must be called before the server handles requests.
+func (s *Server) AllowLocalPaths(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	if err != nil {
+		return types.NewAppError(types.ErrInvalidInput, "目录不存在: "+dir, err)
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return types.NewAppError(types.ErrInvalidInput, "不是目录: "+dir, err)
+	}
+	s.pathDir = abs
+	return nil
+}
+
+// checkInput returns what to translate for a submitted input: arXiv IDs and URLs as they
+// are, zip files by their resolved path when it lies in the directory of AllowLocalPaths.
+// Other inputs are refused with ErrInvalidInput.
+func (s *Server) checkInput(input string) (string, error) {
+	sourceType, err := parser.ParseInput(input)
+	if err != nil {
+		return "", types.NewAppError(types.ErrInvalidInput, "input 不是有效的 arXiv ID 或 URL", err)
+	}
+	switch sourceType {
+	case types.SourceTypeURL, types.SourceTypeArxivID:
+		return input, nil
+	}
+	if s.pathDir == "" {
+		return "", types.NewAppError(types.ErrInvalidInput, "服务只接受 arXiv ID 或 URL (启动时用 --serve-allow-paths 允许提交服务器上的 zip 文件)", nil)
+	}
+	if sourceType != types.SourceTypeLocalZip {
+		return "", types.NewAppError(types.ErrInvalidInput, "只能提交 zip 文件", nil)
+	}
+
+	path := filepath.FromSlash(input)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.pathDir, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		var rel string
+		if rel, err = filepath.Rel(s.pathDir, resolved); err == nil && !filepath.IsLocal(rel) {
+			err = fmt.Errorf("outside of %s", s.pathDir)
+		}
+	}
+	if err != nil {
+		// Whether a file outside of the directory exists is not revealed
+		return "", types.NewAppError(types.ErrInvalidInput, "zip 文件不在允许的目录中或不存在", nil)
+	}
+	return resolved, nil
+}
+
+// Submit queues a translation of input and returns its job. An input already queued or
+// running returns the job translating it.
+func (s *Server) Submit(input string) (Job, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return Job{}, types.NewAppError(types.ErrInvalidInput, "input 不能为空", nil)
+	}
+	input, err := s.checkInput(input)
+	if err != nil {
+		return Job{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return Job{}, types.NewAppError(types.ErrInternal, "服务正在关闭", nil)
+	}
+	s.prune()
+	for _, id := range s.order {
+		if j := s.jobs[id]; j.Input == input && !j.finished() {
+			return j.Job, nil
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, types.NewAppError(types.ErrInternal, "无法生成任务 ID", err)
+	}
+	j := &job{Job: Job{
+		ID:        id,
+		Input:     input,
+		State:     StateQueued,
+		Status:    types.Status{Phase: types.PhaseIdle, Message: "排队中"},
+		CreatedAt: time.Now(),
+	}, done: make(chan struct{})}
+	select {
+	case s.queue <- j:
+	default:
+		return Job{}, types.NewAppError(types.ErrInternal, fmt.Sprintf("排队的任务已达上限 (%d 个)，请稍后再提交", MaxQueuedJobs), nil)
+	}
+	s.jobs[id] = j
+	s.order = append(s.order, id)
+	logger.Info("job queued", logger.String("job", id), logger.String("input", input))
+	return j.Job, nil
+}
+
+// Get returns the job with the given ID
+func (s *Server) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+// Wait waits until the job with the given ID finishes or ctx is done and returns it. It
+// reports whether the job exists.
+func (s *Server) Wait(ctx context.Context, id string) (Job, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return j.Job, true
+}
+
+// List returns every job kept by the server in submission order
+func (s *Server) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	jobs := make([]Job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, s.jobs[id].Job)
+	}
+	return jobs
+}
+
+// Cancel cancels a job: a queued job is dropped, a running one is stopped by its runner
+// and becomes cancelled once the runner returns. It reports whether the job exists.
+func (s *Server) Cancel(id string) (Job, bool) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Job{}, false
+	}
+	var runner Runner
+	switch j.State {
+	case StateQueued:
+		s.finish(j, StateCancelled, "已取消", "")
+	case StateRunning:
+		runner = j.runner
+	}
+	snapshot := j.Job
+	s.mu.Unlock()
+
+	if runner != nil {
+		logger.Info("cancelling running job", logger.String("job", id))
+		runner.Cancel()
+	}
+	return snapshot, true
+}
+
+// Shutdown stops accepting jobs, cancels the queued and running ones and waits for the
+// runners to return until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closing {
+		s.closing = true
+		close(s.queue)
+	}
+	var running []Runner
+	for _, id := range s.order {
+		j := s.jobs[id]
+		switch j.State {
+		case StateQueued:
+			s.finish(j, StateCancelled, "服务已关闭", "")
+		case StateRunning:
+			running = append(running, j.runner)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, runner := range running {
+		runner.Cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work runs the queued jobs with runner until the queue is closed
+func (s *Server) work(runner Runner) {
+	defer s.workers.Done()
+	for j := range s.queue {
+		s.mu.Lock()
+		if j.State != StateQueued {
+			// Cancelled while waiting
+			s.mu.Unlock()
+			continue
+		}
+		now := time.Now()
+		j.State = StateRunning
+		j.StartedAt = &now
+		j.runner = runner
+		s.mu.Unlock()
+
+		logger.Info("job started", logger.String("job", j.ID), logger.String("input", j.Input))
+		result, err := runner.Run(j.Input, func(status *types.Status) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if j.State == StateRunning {
+				j.Status = *status
+			}
+		})
+
+		s.mu.Lock()
+		j.runner = nil
+		switch {
+		case err == nil:
+			j.Outputs = &Outputs{}
+			if result != nil {
+				j.SourceID = result.SourceID
+				j.Outputs = &Outputs{
+					OriginalPDF:    result.OriginalPDFPath,
+					TranslatedPDF:  result.TranslatedPDFPath,
+					BilingualPDF:   result.BilingualPDFPath,
+					TranslatedTex:  result.TranslatedTexPath,
+					TranslatedHTML: result.TranslatedHTMLPath,
+				}
+			}
+			j.Status = types.Status{Phase: types.PhaseComplete, Progress: 100, Message: "处理完成"}
+			s.finish(j, StateDone, "", "")
+		case errorCode(err) == string(types.ErrCancelled):
+			s.finish(j, StateCancelled, err.Error(), errorCode(err))
+		default:
+			s.finish(j, StateFailed, err.Error(), errorCode(err))
+		}
+		logger.Info("job finished", logger.String("job", j.ID), logger.String("state", j.State))
+		s.prune()
+		s.mu.Unlock()
+	}
+}
+
+// finish puts j into a terminal state; s.mu must be held
+func (s *Server) finish(j *job, state, message, code string) {
+	now := time.Now()
+	j.State = state
+	j.FinishedAt = &now
+	j.Error = message
+	j.ErrorCode = code
+	if state != StateDone {
+		j.Status.Phase = types.PhaseError
+		j.Status.Error = message
+	}
+	close(j.done)
+}
+
+// prune forgets the finished jobs beyond the newest s.maxFinished and those that
+// finished more than s.finishedTTL ago; s.mu must be held
+func (s *Server) prune() {
+	now := time.Now()
+	keep := make([]bool, len(s.order))
+	finished := 0
+	for i := len(s.order) - 1; i >= 0; i-- {
+		j := s.jobs[s.order[i]]
+		if !j.finished() {
+			keep[i] = true
+			continue
+		}
+		finished++
+		keep[i] = finished <= s.maxFinished && now.Sub(*j.FinishedAt) < s.finishedTTL
+	}
+
+	order := s.order[:0]
+	for i, id := range s.order {
+		if keep[i] {
+			order = append(order, id)
+		} else {
+			delete(s.jobs, id)
+		}
+	}
+	s.order = order
+}
+
+// Handler returns the HTTP API of the server:
+//
+//	POST   /jobs                 {"input":"2301.00001"} queues a job and returns it; zip
+//	                             paths only with AllowLocalPaths
+//	GET    /jobs                 lists the jobs
+//	GET    /jobs/{id}            returns a job with its status and result paths
+//	GET    /jobs/{id}/pdf?kind=  streams the original, translated (default) or bilingual PDF
+//	DELETE /jobs/{id}            cancels a job and returns it once it stopped
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.List())
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := s.Get(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "任务不存在")
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	})
+	mux.HandleFunc("GET /jobs/{id}/pdf", s.handlePDF)
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := s.Cancel(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "任务不存在")
+			return
+		}
+		if !j.finished() {
+			// A running job stops when its runner returns
+			ctx, cancel := context.WithTimeout(r.Context(), CancelWait)
+			if stopped, ok := s.Wait(ctx, j.ID); ok {
+				j = stopped
+			}
+			cancel()
+		}
+		status := http.StatusOK
+		if !j.finished() {
+			status = http.StatusAccepted
+		}
+		writeJSON(w, status, j)
+	})
+	return s.authorize(mux)
+}
+
+// authorize refuses requests without the bearer token, when the server has one
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="latex-translator"`)
+			writeError(w, http.StatusUnauthorized, "缺少或错误的访问令牌")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleSubmit queues the job of a POST /jobs request
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input string `json:"input"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体不是有效的 JSON: "+err.Error())
+		return
+	}
+	j, err := s.Submit(req.Input)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		if errorCode(err) == string(types.ErrInvalidInput) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// handlePDF streams a result PDF of a finished job
+func (s *Server) handlePDF(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "任务不存在")
+		return
+	}
+	if j.Outputs == nil {
+		writeError(w, http.StatusConflict, "任务尚未完成")
+		return
+	}
+	var path string
+	switch kind := r.URL.Query().Get("kind"); kind {
+	case "original":
+		path = j.Outputs.OriginalPDF
+	case "translated", "":
+		path = j.Outputs.TranslatedPDF
+	case "bilingual":
+		path = j.Outputs.BilingualPDF
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("不支持的 kind %q (可选: original, translated, bilingual)", kind))
+		return
+	}
+	if path == "" {
+		writeError(w, http.StatusNotFound, "任务没有生成该 PDF")
+		return
+	}
+	pdfserve.ServeFile(w, r, path)
+}
+
+// newJobID returns a random job ID
+func newJobID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// errorCode returns the code of an AppError, or "" for other errors
+func errorCode(err error) string {
+	var appErr *types.AppError
+	if errors.As(err, &appErr) {
+		return string(appErr.Code)
+	}
+	return ""
+}
+
+// writeJSON writes v as the JSON body of a response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response with a JSON body {"error": message}
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
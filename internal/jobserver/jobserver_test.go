@@ -0,0 +1,330 @@
+package jobserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// fakeRunner reports a translating status and waits for release or Cancel
+type fakeRunner struct {
+	pdf     string
+	started chan string
+	release chan struct{}
+
+	mu        sync.Mutex
+	cancelled chan struct{}
+}
+
+func newFakeRunner(pdf string) *fakeRunner {
+	return &fakeRunner{pdf: pdf, started: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (r *fakeRunner) Run(input string, onStatus func(*types.Status)) (*types.ProcessResult, error) {
+	r.mu.Lock()
+	r.cancelled = make(chan struct{})
+	cancelled := r.cancelled
+	r.mu.Unlock()
+
+	onStatus(&types.Status{Phase: types.PhaseTranslating, Progress: 40, Message: "翻译中"})
+	r.started <- input
+	select {
+	case <-r.release:
+		return &types.ProcessResult{SourceID: input, OriginalPDFPath: r.pdf, TranslatedPDFPath: r.pdf}, nil
+	case <-cancelled:
+		return nil, types.NewAppError(types.ErrCancelled, "已取消", nil)
+	}
+}
+
+func (r *fakeRunner) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelled != nil {
+		close(r.cancelled)
+		r.cancelled = nil
+	}
+}
+
+// do sends a request to handler and decodes the JSON response into v
+func do(t *testing.T, handler http.Handler, method, target, body, token string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		json.Unmarshal(rec.Body.Bytes(), v)
+	}
+	return rec.Code
+}
+
+// waitState waits for a job to finish and checks it reached state
+func waitState(t *testing.T, s *Server, id, state string) Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	j, _ := s.Wait(ctx, id)
+	if j.State != state {
+		t.Fatalf("job %s is %s, want %s", id, j.State, state)
+	}
+	return j
+}
+
+func TestJobLifecycle(t *testing.T) {
+	pdf := filepath.Join(t.TempDir(), "translated.pdf")
+	if err := os.WriteFile(pdf, []byte("%PDF-1.5 translated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runner := newFakeRunner(pdf)
+	s := New([]Runner{runner}, "")
+	handler := s.Handler()
+
+	var first, second, again Job
+	if code := do(t, handler, "POST", "/jobs", `{"input":"2301.00001"}`, "", &first); code != http.StatusAccepted {
+		t.Fatalf("POST /jobs = %d", code)
+	}
+	do(t, handler, "POST", "/jobs", `{"input":"2301.00002"}`, "", &second)
+	// Submitting a paper still in progress returns its job
+	do(t, handler, "POST", "/jobs", `{"input":"2301.00001"}`, "", &again)
+	if again.ID != first.ID {
+		t.Errorf("duplicate submission got job %s, want %s", again.ID, first.ID)
+	}
+
+	<-runner.started
+	var running Job
+	do(t, handler, "GET", "/jobs/"+first.ID, "", "", &running)
+	if running.State != StateRunning || running.Status.Phase != types.PhaseTranslating || running.Status.Progress != 40 {
+		t.Errorf("running job = %+v", running)
+	}
+	// One runner: the second job waits
+	if j, _ := s.Get(second.ID); j.State != StateQueued {
+		t.Errorf("second job is %s while the runner is busy", j.State)
+	}
+	if code := do(t, handler, "GET", "/jobs/"+first.ID+"/pdf", "", "", nil); code != http.StatusConflict {
+		t.Errorf("PDF of a running job = %d", code)
+	}
+
+	runner.release <- struct{}{}
+	done := waitState(t, s, first.ID, StateDone)
+	if done.Outputs == nil || done.Outputs.TranslatedPDF != pdf || done.Status.Phase != types.PhaseComplete {
+		t.Errorf("finished job = %+v", done)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/jobs/"+first.ID+"/pdf?kind=translated", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "%PDF-1.5 translated" {
+		t.Errorf("GET pdf = %d %q", rec.Code, rec.Body.String())
+	}
+	if code := do(t, handler, "GET", "/jobs/"+first.ID+"/pdf?kind=bilingual", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("missing bilingual PDF = %d", code)
+	}
+	if code := do(t, handler, "GET", "/jobs/"+first.ID+"/pdf?kind=draft", "", "", nil); code != http.StatusBadRequest {
+		t.Errorf("unknown kind = %d", code)
+	}
+
+	// Cancelling the running second job goes through the runner, and DELETE returns
+	// once the runner stopped
+	<-runner.started
+	var cancelled Job
+	if code := do(t, handler, "DELETE", "/jobs/"+second.ID, "", "", &cancelled); code != http.StatusOK {
+		t.Errorf("DELETE = %d", code)
+	}
+	if cancelled.State != StateCancelled || cancelled.ErrorCode != string(types.ErrCancelled) || cancelled.Outputs != nil {
+		t.Errorf("cancelled job = %+v", cancelled)
+	}
+
+	var jobs []Job
+	do(t, handler, "GET", "/jobs", "", "", &jobs)
+	if len(jobs) != 2 || jobs[0].ID != first.ID {
+		t.Errorf("GET /jobs = %+v", jobs)
+	}
+	if code := do(t, handler, "GET", "/jobs/unknown", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("unknown job = %d", code)
+	}
+	if code := do(t, handler, "POST", "/jobs", `{"input":"  "}`, "", nil); code != http.StatusBadRequest {
+		t.Errorf("empty input = %d", code)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	s := New(nil, "secret")
+	handler := s.Handler()
+	if code := do(t, handler, "GET", "/jobs", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("without token = %d", code)
+	}
+	if code := do(t, handler, "GET", "/jobs", "", "wrong", nil); code != http.StatusUnauthorized {
+		t.Errorf("wrong token = %d", code)
+	}
+	if code := do(t, handler, "GET", "/jobs", "", "secret", nil); code != http.StatusOK {
+		t.Errorf("with token = %d", code)
+	}
+}
+
+func TestShutdownCancelsJobs(t *testing.T) {
+	runner := newFakeRunner("")
+	s := New([]Runner{runner}, "")
+	running, _ := s.Submit("2301.00001")
+	queued, _ := s.Submit("2301.00002")
+	<-runner.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	for _, id := range []string{running.ID, queued.ID} {
+		if j, _ := s.Get(id); j.State != StateCancelled {
+			t.Errorf("job %s is %s after shutdown", j.Input, j.State)
+		}
+	}
+	if _, err := s.Submit("2301.00003"); err == nil {
+		t.Error("submission accepted after shutdown")
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	runner := newFakeRunner("")
+	s := New([]Runner{runner}, "")
+	handler := s.Handler()
+	running, _ := s.Submit("2301.00001")
+	queued, _ := s.Submit("2301.00002")
+	<-runner.started
+
+	// A queued job is cancelled without waiting for a runner
+	var j Job
+	if code := do(t, handler, "DELETE", "/jobs/"+queued.ID, "", "", &j); code != http.StatusOK || j.State != StateCancelled {
+		t.Errorf("DELETE queued job = %d, %+v", code, j)
+	}
+	if code := do(t, handler, "DELETE", "/jobs/unknown", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("DELETE unknown job = %d", code)
+	}
+
+	runner.release <- struct{}{}
+	waitState(t, s, running.ID, StateDone)
+	s.Shutdown(context.Background())
+}
+
+func TestFinishedJobsArePruned(t *testing.T) {
+	runner := newFakeRunner("")
+	s := New([]Runner{runner}, "")
+	s.maxFinished = 2
+
+	var ids []string
+	for _, input := range []string{"2301.00001", "2301.00002", "2301.00003"} {
+		j, _ := s.Submit(input)
+		<-runner.started
+		runner.release <- struct{}{}
+		waitState(t, s, j.ID, StateDone)
+		ids = append(ids, j.ID)
+	}
+
+	// Only the newest finished jobs are kept
+	if _, ok := s.Get(ids[0]); ok {
+		t.Error("oldest finished job was kept")
+	}
+	jobs := s.List()
+	if len(jobs) != 2 || jobs[0].ID != ids[1] || jobs[1].ID != ids[2] {
+		t.Errorf("List() = %+v", jobs)
+	}
+
+	// Expired jobs are forgotten, unfinished ones are kept
+	running, _ := s.Submit("2301.00004")
+	<-runner.started
+	s.mu.Lock()
+	s.finishedTTL = 0
+	s.mu.Unlock()
+	if jobs := s.List(); len(jobs) != 1 || jobs[0].ID != running.ID {
+		t.Errorf("List() after expiry = %+v", jobs)
+	}
+
+	runner.release <- struct{}{}
+	waitState(t, s, running.ID, StateDone)
+	s.Shutdown(context.Background())
+}
+
+func TestSubmitRefusesLocalPaths(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "paper.zip")
+	if err := os.WriteFile(outside, []byte("PK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := New(nil, "")
+	defer s.Shutdown(context.Background())
+	handler := s.Handler()
+
+	// Only arXiv IDs and URLs are accepted by default
+	for _, input := range []string{"2301.00001", "https://arxiv.org/abs/2301.00002"} {
+		if code := do(t, handler, "POST", "/jobs", `{"input":"`+input+`"}`, "", nil); code != http.StatusAccepted {
+			t.Errorf("POST %s = %d", input, code)
+		}
+	}
+	for _, input := range []string{outside, "/etc/passwd", "paper.pdf", "hello"} {
+		body, _ := json.Marshal(map[string]string{"input": input})
+		if code := do(t, handler, "POST", "/jobs", string(body), "", nil); code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d, want 400", input, code)
+		}
+	}
+}
+
+func TestSubmitAllowedPaths(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "uploads")
+	if err := os.MkdirAll(filepath.Join(allowed, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(allowed, "a.zip"), filepath.Join(allowed, "sub", "b.zip"), filepath.Join(root, "secret.zip")} {
+		if err := os.WriteFile(name, []byte("PK"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(allowed, "link.zip")
+	hasLink := os.Symlink(filepath.Join(root, "secret.zip"), link) == nil
+
+	s := New(nil, "")
+	defer s.Shutdown(context.Background())
+	if err := s.AllowLocalPaths(filepath.Join(root, "missing")); err == nil {
+		t.Error("AllowLocalPaths accepted a missing directory")
+	}
+	if err := s.AllowLocalPaths(allowed); err != nil {
+		t.Fatal(err)
+	}
+	resolvedDir, _ := filepath.EvalSymlinks(allowed)
+
+	for input, want := range map[string]string{
+		filepath.Join(allowed, "a.zip"): filepath.Join(resolvedDir, "a.zip"),
+		"sub/b.zip":                     filepath.Join(resolvedDir, "sub", "b.zip"),
+	} {
+		j, err := s.Submit(input)
+		if err != nil || j.Input != want {
+			t.Errorf("Submit(%q) = %q, %v; want %q", input, j.Input, err, want)
+		}
+	}
+
+	refused := []string{
+		filepath.Join(root, "secret.zip"),
+		"../secret.zip",
+		filepath.Join(allowed, "missing.zip"),
+		filepath.Join(allowed, "notes.txt"),
+	}
+	if hasLink {
+		refused = append(refused, link)
+	}
+	for _, input := range refused {
+		_, err := s.Submit(input)
+		if appErr, ok := err.(*types.AppError); !ok || appErr.Code != types.ErrInvalidInput {
+			t.Errorf("Submit(%q) error = %v, want an invalid input error", input, err)
+		}
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"latex-translator/internal/netproxy"
 )
 
 const (
@@ -208,7 +210,8 @@ func (c *Client) Activate(serialNumber string) (*ActivationResponse, *Activation
 
 	// Step 5: Send the request with timeout
 	client := &http.Client{
-		Timeout: httpTimeout,
+		Timeout:   httpTimeout,
+		Transport: netproxy.NewTransport(),
 	}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -347,7 +350,8 @@ func (c *Client) RequestSN(email string) (*RequestSNResult, error) {
 
 	// Step 5: Send the request with timeout
 	httpClient := &http.Client{
-		Timeout: httpTimeout,
+		Timeout:   httpTimeout,
+		Transport: netproxy.NewTransport(),
 	}
 	resp, err := httpClient.Do(req)
 	if err != nil {
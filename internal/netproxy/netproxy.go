@@ -0,0 +1,178 @@
+// Package netproxy builds the HTTP clients of the application. Every client routes its
+// requests through the proxy set in the config file, or else through the one named by
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, so downloads, the
+// translation API and the license server all work behind the same corporate proxy.
+package netproxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+var (
+	mu sync.RWMutex
+	// configured is the proxy of the config file; nil defers to the environment
+	configured *url.URL
+)
+
+// ParseProxyURL validates a proxy URL. A bare host:port is taken as an HTTP proxy;
+// http, https and socks5 proxies are supported. An empty string returns nil.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, types.NewAppErrorWithDetails(types.ErrInvalidInput, "代理地址无效", "例如 http://127.0.0.1:7890 或 socks5://127.0.0.1:1080", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, types.NewAppErrorWithDetails(types.ErrInvalidInput, "不支持的代理协议: "+u.Scheme, "支持 http、https 和 socks5 代理", nil)
+	}
+	return u, nil
+}
+
+// SetProxy sets the proxy of the config file, which takes precedence over the
+// environment; an empty string goes back to the environment variables.
+func SetProxy(raw string) error {
+	u, err := ParseProxyURL(raw)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	configured = u
+	mu.Unlock()
+	return nil
+}
+
+// ProxyFor returns the proxy of a request, or nil for a direct connection. It is the
+// Proxy function of the transports of this package and is evaluated on every request,
+// so a proxy changed in the settings applies to clients created before.
+//
+// Hosts listed in NO_PROXY, localhost and loopback addresses are always reached
+// directly. Otherwise the configured proxy is used, or HTTPS_PROXY for https and
+// HTTP_PROXY for http URLs.
+func ProxyFor(req *http.Request) (*url.URL, error) {
+	mu.RLock()
+	proxy := configured
+	mu.RUnlock()
+	return selectProxy(req.URL, proxy)
+}
+
+// selectProxy returns the proxy of u: nil for hosts reached directly, else proxy, or
+// the proxy of the environment when proxy is nil
+func selectProxy(u, proxy *url.URL) (*url.URL, error) {
+	if !useProxy(u, getenv("NO_PROXY")) {
+		return nil, nil
+	}
+	if proxy != nil {
+		return proxy, nil
+	}
+
+	raw := getenv("HTTP_PROXY")
+	if u.Scheme == "https" {
+		raw = getenv("HTTPS_PROXY")
+	}
+	return ParseProxyURL(raw)
+}
+
+// getenv reads an environment variable in its upper or lower case spelling
+func getenv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return os.Getenv(strings.ToLower(name))
+}
+
+// useProxy reports whether a URL goes through the proxy given the NO_PROXY list:
+// comma separated hosts (matching their subdomains too), .domains (matching
+// subdomains only), IP addresses and CIDR ranges, each optionally with a port, or *
+func useProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	if host == "" || host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+	port := u.Port()
+
+	for _, entry := range strings.Split(strings.ToLower(noProxy), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return false
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return false
+			}
+			continue
+		}
+		if strings.HasPrefix(entry, ".") {
+			if strings.HasSuffix(host, entry) {
+				return false
+			}
+			continue
+		}
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewTransport returns a transport with the settings of http.DefaultTransport and
+// the proxy selection of ProxyFor
+func NewTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = ProxyFor
+	return tr
+}
+
+// NewTransportWithProxy returns a transport using proxyURL in place of the proxy of
+// the config file, with the same NO_PROXY rules and the environment as fallback when
+// proxyURL is empty; for trying a proxy before it is saved
+func NewTransportWithProxy(proxyURL string) (*http.Transport, error) {
+	proxy, err := ParseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL, proxy)
+	}
+	return tr, nil
+}
+
+// NewClient returns an HTTP client on NewTransport with the given timeout
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: NewTransport()}
+}
@@ -0,0 +1,158 @@
+package netproxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// fakeProxy is a forward proxy answering every request itself with its name and the
+// host the request was meant for
+func fakeProxy(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, name+" "+r.URL.Host)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// clearProxyEnv unsets the proxy variables and the configured proxy for a test
+func clearProxyEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+	SetProxy("")
+	t.Cleanup(func() { SetProxy("") })
+}
+
+func get(t *testing.T, client *http.Client, target string) string {
+	t.Helper()
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestClientUsesProxy(t *testing.T) {
+	clearProxyEnv(t)
+	envProxy := fakeProxy(t, "env")
+	configProxy := fakeProxy(t, "config")
+	client := NewClient(0)
+
+	t.Setenv("HTTP_PROXY", envProxy.URL)
+	if got := get(t, client, "http://arxiv.test/e-print/2301.00001"); got != "env arxiv.test" {
+		t.Errorf("with HTTP_PROXY, response = %q", got)
+	}
+
+	// The config file takes precedence, also for clients created before it was set
+	if err := SetProxy(configProxy.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, client, "http://arxiv.test/e-print/2301.00001"); got != "config arxiv.test" {
+		t.Errorf("with a configured proxy, response = %q", got)
+	}
+
+	// Loopback servers are reached directly
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer direct.Close()
+	if got := get(t, client, direct.URL); got != "direct" {
+		t.Errorf("loopback response = %q, want a direct connection", got)
+	}
+
+	// A proxy for trying before saving replaces the configured one
+	tr, err := NewTransportWithProxy(envProxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, &http.Client{Transport: tr}, "http://api.test/v1"); got != "env api.test" {
+		t.Errorf("with a transport for another proxy, response = %q", got)
+	}
+}
+
+func TestProxyForNoProxy(t *testing.T) {
+	clearProxyEnv(t)
+	t.Setenv("HTTPS_PROXY", "proxy.corp:3128")
+	t.Setenv("NO_PROXY", "internal.corp, .lan,10.0.0.0/8,192.168.1.5,api.test:8443")
+
+	tests := []struct {
+		target string
+		proxy  string
+	}{
+		{"https://arxiv.org/e-print/2301.00001", "http://proxy.corp:3128"},
+		{"http://arxiv.org/e-print/2301.00001", ""}, // HTTP_PROXY is not set
+		{"https://internal.corp/x", ""},
+		{"https://license.internal.corp/x", ""},
+		{"https://lan/x", "http://proxy.corp:3128"},
+		{"https://nas.lan/x", ""},
+		{"https://10.1.2.3/x", ""},
+		{"https://192.168.1.5/x", ""},
+		{"https://192.168.1.6/x", "http://proxy.corp:3128"},
+		{"https://api.test:8443/v1", ""},
+		{"https://api.test/v1", "http://proxy.corp:3128"},
+		{"https://localhost:8080/", ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.target)
+		proxy, err := ProxyFor(&http.Request{URL: u})
+		if err != nil {
+			t.Errorf("ProxyFor(%s) error: %v", tt.target, err)
+			continue
+		}
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != tt.proxy {
+			t.Errorf("ProxyFor(%s) = %q, want %q", tt.target, got, tt.proxy)
+		}
+	}
+
+	t.Setenv("NO_PROXY", "*")
+	if proxy, _ := ProxyFor(&http.Request{URL: &url.URL{Scheme: "https", Host: "arxiv.org"}}); proxy != nil {
+		t.Errorf("NO_PROXY=* still proxies through %s", proxy)
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"":                          "",
+		"127.0.0.1:7890":            "http://127.0.0.1:7890",
+		" socks5://127.0.0.1:1080 ": "socks5://127.0.0.1:1080",
+		"http://user:pw@proxy:8080": "http://user:pw@proxy:8080",
+	} {
+		u, err := ParseProxyURL(raw)
+		if err != nil {
+			t.Errorf("ParseProxyURL(%q) error: %v", raw, err)
+			continue
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != want {
+			t.Errorf("ParseProxyURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	for _, raw := range []string{"ftp://proxy:21", "http://", "http://[::1"} {
+		if _, err := ParseProxyURL(raw); err == nil {
+			t.Errorf("ParseProxyURL(%q) accepted an invalid proxy", raw)
+		}
+	}
+	if err := SetProxy("ftp://proxy:21"); err == nil {
+		t.Error("SetProxy accepted an invalid proxy")
+	}
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"strings"
+)
+
+// opaqueEnvironments are environments whose content TeX does not execute as commands, so an
+// \end{document} or \endinput inside them does not end the file
+var opaqueEnvironments = []string{
+	"verbatim", "verbatim*", "Verbatim", "Verbatim*", "BVerbatim", "LVerbatim",
+	"lstlisting", "minted", "comment", "filecontents", "filecontents*",
+}
+
+// SplitAtDocumentEnd splits LaTeX source at the first effective \end{document} or \endinput.
+// body is everything TeX reads from the file; trailing is everything after it, which TeX
+// never reads (old drafts, notes, binary junk) and must neither be translated nor validated.
+//
+// \end{document} ends the body right after the command. \endinput only ends the current file
+// and TeX still reads the rest of its line, so the body ends at the end of that line.
+// Occurrences in comments and verbatim-like environments are ignored, and so is \endinput
+// inside braces (e.g. in a macro definition). \end{document} is honoured at any brace depth
+// so that a translation with an unbalanced brace is still cut at the same place.
+// If there is no such command, body is content and trailing is "".
+func SplitAtDocumentEnd(content string) (body, trailing string) {
+	if end := documentEnd(content); end >= 0 {
+		return content[:end], content[end:]
+	}
+	return content, ""
+}
+
+// DocumentBody returns the part of content that TeX reads, see SplitAtDocumentEnd
+func DocumentBody(content string) string {
+	body, _ := SplitAtDocumentEnd(content)
+	return body
+}
+
+// documentEnd returns the offset at which the file effectively ends, or -1
+func documentEnd(content string) int {
+	depth := 0
+	for i := 0; i < len(content); {
+		switch content[i] {
+		case '%':
+			i = lineEnd(content, i)
+			continue
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '\\':
+			name := commandName(content, i+1)
+			if name == "" {
+				i += 2 // escaped character such as \% or \{
+				continue
+			}
+			after := i + 1 + len(name)
+			switch name {
+			case "endinput":
+				if depth == 0 {
+					return lineEnd(content, after)
+				}
+			case "verb":
+				i = skipVerb(content, after)
+				continue
+			case "begin", "end":
+				env, argEnd := environmentArgument(content, after)
+				if name == "end" && env == "document" {
+					return argEnd
+				}
+				if name == "begin" && isOpaqueEnvironment(env) {
+					closing := "\\end{" + env + "}"
+					if j := strings.Index(content[argEnd:], closing); j >= 0 {
+						i = argEnd + j + len(closing)
+						continue
+					}
+					return -1
+				}
+				if argEnd > after {
+					i = argEnd
+					continue
+				}
+			}
+			i = after
+			continue
+		}
+		i++
+	}
+	return -1
+}
+
+// commandName returns the letters of a control word starting at pos
+func commandName(content string, pos int) string {
+	end := pos
+	for end < len(content) && isLetter(content[end]) {
+		end++
+	}
+	return content[pos:end]
+}
+
+// environmentArgument reads the {name} argument of \begin or \end at pos, allowing spaces
+// before the brace. It returns the name and the offset after the closing brace, or "" and
+// pos when there is no argument.
+func environmentArgument(content string, pos int) (string, int) {
+	i := pos
+	for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+		i++
+	}
+	if i >= len(content) || content[i] != '{' {
+		return "", pos
+	}
+	closing := strings.IndexByte(content[i:], '}')
+	if closing < 0 || strings.ContainsAny(content[i+1:i+closing], "\n{") {
+		return "", pos
+	}
+	return strings.TrimSpace(content[i+1 : i+closing]), i + closing + 1
+}
+
+// skipVerb skips the argument of \verb (or \verb*) starting at pos
+func skipVerb(content string, pos int) int {
+	if pos < len(content) && content[pos] == '*' {
+		pos++
+	}
+	if pos >= len(content) {
+		return pos
+	}
+	delimiter := content[pos]
+	if end := strings.IndexByte(content[pos+1:], delimiter); end >= 0 {
+		if nl := strings.IndexByte(content[pos+1:], '\n'); nl < 0 || end < nl {
+			return pos + 1 + end + 1
+		}
+	}
+	return pos + 1
+}
+
+// lineEnd returns the offset of the line break ending the line containing pos, or len(content)
+func lineEnd(content string, pos int) int {
+	if j := strings.IndexByte(content[pos:], '\n'); j >= 0 {
+		return pos + j
+	}
+	return len(content)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '@'
+}
+
+func isOpaqueEnvironment(env string) bool {
+	for _, name := range opaqueEnvironments {
+		if env == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/types"
+)
+
+// sectioningLevels maps sectioning commands to their outline level
+var sectioningLevels = map[string]int{
+	"part":          0,
+	"chapter":       1,
+	"section":       2,
+	"subsection":    3,
+	"subsubsection": 4,
+}
+
+// sectioningPattern matches sectioning commands (including starred forms) and \appendix.
+// The title argument is read separately so nested braces are handled.
+var sectioningPattern = regexp.MustCompile(`\\(part|chapter|section|subsection|subsubsection|appendix)\b(\*)?`)
+
+// chapterPattern detects whether a document uses chapters
+var chapterPattern = regexp.MustCompile(`\\chapter\*?\s*[\[{]`)
+
+// outlineLabelPattern matches \label{...} inside a title
+var outlineLabelPattern = regexp.MustCompile(`\\label\{[^}]*\}`)
+
+// ExtractOutline extracts the document outline (parts, chapters and sections) from LaTeX
+// source, so the structure is available before any PDF exists.
+// Entries are numbered like LaTeX does: section numbers include the chapter number when the
+// document has chapters, starred commands are unnumbered, and after \appendix the top-level
+// divisions are lettered A, B, ... Start offsets refer to content.
+func ExtractOutline(content string) []*types.OutlineEntry {
+	var roots []*types.OutlineEntry
+	var stack []*types.OutlineEntry
+
+	topLevel := sectioningLevels["section"]
+	if chapterPattern.MatchString(content) {
+		topLevel = sectioningLevels["chapter"]
+	}
+
+	counters := make([]int, len(sectioningLevels))
+	inAppendix := false
+
+	for _, m := range sectioningPattern.FindAllStringSubmatchIndex(content, -1) {
+		start := m[0]
+		if isCommentedOut(content, start) {
+			continue
+		}
+		command := content[m[2]:m[3]]
+		if command == "appendix" {
+			inAppendix = true
+			counters[topLevel] = 0
+			continue
+		}
+		starred := m[4] != -1
+
+		title, ok := readSectionTitle(content, m[1])
+		if !ok {
+			continue
+		}
+
+		level := sectioningLevels[command]
+		entry := &types.OutlineEntry{
+			Command:  command,
+			Level:    level,
+			Title:    title,
+			Starred:  starred,
+			Appendix: inAppendix,
+			Start:    start,
+		}
+
+		if !starred {
+			counters[level]++
+			for deeper := level + 1; deeper < len(counters); deeper++ {
+				counters[deeper] = 0
+			}
+			entry.Number = outlineNumber(counters, level, topLevel, inAppendix)
+		}
+
+		// Attach to the nearest preceding entry of a higher level
+		for len(stack) > 0 && stack[len(stack)-1].Level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+	}
+
+	return roots
+}
+
+// FlattenOutline returns all outline entries in document order
+func FlattenOutline(outline []*types.OutlineEntry) []*types.OutlineEntry {
+	var flat []*types.OutlineEntry
+	var walk func(entries []*types.OutlineEntry)
+	walk = func(entries []*types.OutlineEntry) {
+		for _, e := range entries {
+			flat = append(flat, e)
+			walk(e.Children)
+		}
+	}
+	walk(outline)
+	return flat
+}
+
+// SectionAt returns the innermost outline entry containing the source position pos,
+// or nil if pos comes before the first sectioning command
+func SectionAt(outline []*types.OutlineEntry, pos int) *types.OutlineEntry {
+	var current *types.OutlineEntry
+	for _, e := range FlattenOutline(outline) {
+		if e.Start > pos {
+			break
+		}
+		current = e
+	}
+	return current
+}
+
+// FormatOutlineEntry formats an entry for status messages,
+// e.g. "第4节 Experiments", "3.2 Experimental Setup" or "附录A Proofs"
+func FormatOutlineEntry(e *types.OutlineEntry) string {
+	if e == nil {
+		return ""
+	}
+	if e.Number == "" {
+		return e.Title
+	}
+	if e.Appendix && !strings.Contains(e.Number, ".") && e.Level > 0 {
+		return fmt.Sprintf("附录%s %s", e.Number, e.Title)
+	}
+	switch {
+	case e.Level == 0:
+		return fmt.Sprintf("第%s部分 %s", e.Number, e.Title)
+	case e.Level == 1:
+		return fmt.Sprintf("第%s章 %s", e.Number, e.Title)
+	case !strings.Contains(e.Number, "."):
+		return fmt.Sprintf("第%s节 %s", e.Number, e.Title)
+	default:
+		return fmt.Sprintf("%s %s", e.Number, e.Title)
+	}
+}
+
+// outlineNumber builds the number of an entry at level from the counters, starting at the
+// top numbered level (chapter or section). Parts are numbered on their own in Roman numerals.
+func outlineNumber(counters []int, level, topLevel int, inAppendix bool) string {
+	if level == 0 {
+		return romanNumeral(counters[0])
+	}
+	if level < topLevel {
+		return ""
+	}
+	parts := make([]string, 0, level-topLevel+1)
+	for l := topLevel; l <= level; l++ {
+		if l == topLevel && inAppendix {
+			parts = append(parts, appendixLetter(counters[l]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", counters[l]))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// appendixLetter converts 1, 2, ... to A, B, ...
+func appendixLetter(n int) string {
+	if n < 1 || n > 26 {
+		return fmt.Sprintf("%d", n)
+	}
+	return string(rune('A' + n - 1))
+}
+
+// romanNumeral converts a small positive number to upper-case Roman numerals
+func romanNumeral(n int) string {
+	values := []int{10, 9, 5, 4, 1}
+	symbols := []string{"X", "IX", "V", "IV", "I"}
+	if n < 1 || n >= 40 {
+		return fmt.Sprintf("%d", n)
+	}
+	var sb strings.Builder
+	for i, v := range values {
+		for n >= v {
+			sb.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return sb.String()
+}
+
+// readSectionTitle reads the title argument of a sectioning command starting at pos,
+// skipping an optional short title in brackets. Returns false if there is no argument.
+func readSectionTitle(content string, pos int) (string, bool) {
+	pos = skipSpaces(content, pos)
+	if pos < len(content) && content[pos] == '[' {
+		end := strings.IndexByte(content[pos:], ']')
+		if end == -1 {
+			return "", false
+		}
+		pos = skipSpaces(content, pos+end+1)
+	}
+	if pos >= len(content) || content[pos] != '{' {
+		return "", false
+	}
+
+	depth := 0
+	for i := pos; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return cleanSectionTitle(content[pos+1 : i]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// cleanSectionTitle removes labels and collapses whitespace in a title
+func cleanSectionTitle(title string) string {
+	title = outlineLabelPattern.ReplaceAllString(title, "")
+	title = strings.ReplaceAll(title, "~", " ")
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// skipSpaces returns the first position at or after pos that is not whitespace
+func skipSpaces(content string, pos int) int {
+	for pos < len(content) && strings.ContainsRune(" \t\r\n", rune(content[pos])) {
+		pos++
+	}
+	return pos
+}
+
+// isCommentedOut reports whether pos is preceded by an unescaped % on the same line
+func isCommentedOut(content string, pos int) bool {
+	lineStart := strings.LastIndexByte(content[:pos], '\n') + 1
+	line := content[lineStart:pos]
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '%' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerbatimRange is the byte range of a verbatim-like environment, from its \begin to the
+// end of its \end
+type VerbatimRange struct {
+	Start, End int
+}
+
+// VerbatimRanges returns the verbatim-like environments of content (opaqueEnvironments:
+// verbatim, lstlisting, minted, comment, ...) in document order. TeX reads their content as
+// text, so the braces, % signs and commands inside them must be neither counted nor changed
+// by the passes working on the LaTeX structure. Environments in comments and \verb
+// arguments are skipped; an environment without its \end runs to the end of content, as
+// it does for TeX.
+func VerbatimRanges(content string) []VerbatimRange {
+	var ranges []VerbatimRange
+	for i := 0; i < len(content); {
+		switch content[i] {
+		case '%':
+			i = lineEnd(content, i)
+			continue
+		case '\\':
+			name := commandName(content, i+1)
+			if name == "" {
+				i += 2 // escaped character such as \% or \{
+				continue
+			}
+			after := i + 1 + len(name)
+			switch name {
+			case "verb":
+				i = skipVerb(content, after)
+				continue
+			case "begin":
+				env, argEnd := environmentArgument(content, after)
+				if isOpaqueEnvironment(env) {
+					end := len(content)
+					closing := "\\end{" + env + "}"
+					if j := strings.Index(content[argEnd:], closing); j >= 0 {
+						end = argEnd + j + len(closing)
+					}
+					ranges = append(ranges, VerbatimRange{Start: i, End: end})
+					i = end
+					continue
+				}
+			}
+			i = after
+			continue
+		}
+		i++
+	}
+	return ranges
+}
+
+// BlankVerbatim returns content with the bytes of its verbatim-like environments replaced
+// by spaces; line breaks are kept, so offsets and line numbers stay valid for the passes
+// that only count or locate structure
+func BlankVerbatim(content string) string {
+	ranges := VerbatimRanges(content)
+	if len(ranges) == 0 {
+		return content
+	}
+	b := []byte(content)
+	for _, r := range ranges {
+		for i := r.Start; i < r.End; i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	return string(b)
+}
+
+// verbatimPlaceholder is the placeholder of the verbatim-like environment with index i.
+// Like the other placeholders of protected blocks it is a comment, so it is neither
+// translated nor parsed as structure.
+func verbatimPlaceholder(i int) string {
+	return fmt.Sprintf("%%VERBATIM_ENV_PLACEHOLDER_%d%%", i)
+}
+
+// ProtectVerbatim replaces the verbatim-like environments of content by placeholders for
+// the passes that change content. restore puts the environments back, byte for byte, into
+// the content the passes returned.
+func ProtectVerbatim(content string) (masked string, restore func(string) string) {
+	ranges := VerbatimRanges(content)
+	if len(ranges) == 0 {
+		return content, func(s string) string { return s }
+	}
+
+	originals := make([]string, len(ranges))
+	var sb strings.Builder
+	last := 0
+	for i, r := range ranges {
+		sb.WriteString(content[last:r.Start])
+		sb.WriteString(verbatimPlaceholder(i))
+		originals[i] = content[r.Start:r.End]
+		last = r.End
+	}
+	sb.WriteString(content[last:])
+
+	return sb.String(), func(s string) string {
+		for i, original := range originals {
+			s = strings.Replace(s, verbatimPlaceholder(i), original, 1)
+		}
+		return s
+	}
+}
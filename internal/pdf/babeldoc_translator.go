@@ -29,6 +29,9 @@ type BabelDocTranslator struct {
 	workDir    string
 	fontPath   string // Path to Chinese font (TTF/OTF)
 	conf       *model.Configuration
+	usage      Usage // Token usage of the translation requests so far
+
+	outlineTranslated int // Outline entries translated by the last TranslatePDFWithGoPDF2Progressive call
 }
 
 // BabelDocConfig holds configuration for BabelDocTranslator
@@ -1197,6 +1200,17 @@ func FormatPageCountError(result *PageCountResult) string {
 // PageCompleteCallback is called when a page translation is complete
 type PageCompleteCallback func(currentPage, totalPages int, outputPath string)
 
+// Usage returns the token usage of the translation requests made so far, retries included
+func (t *BabelDocTranslator) Usage() Usage {
+	return t.usage
+}
+
+// OutlineTranslated returns the number of outline (bookmark) entries translated by the last
+// TranslatePDFWithGoPDF2Progressive call
+func (t *BabelDocTranslator) OutlineTranslated() int {
+	return t.outlineTranslated
+}
+
 // TranslatePDFWithGoPDF2 translates a PDF using pure Go (GoPDF2).
 // Extract text → translate via API → overlay with GoPDF2.
 func (t *BabelDocTranslator) TranslatePDFWithGoPDF2(inputPath, outputPath, apiKey, baseURL, model string, progressCallback func(message string)) error {
@@ -1256,7 +1270,37 @@ func (t *BabelDocTranslator) TranslatePDFWithGoPDF2Progressive(inputPath, output
 		logger.Int("total", len(blocks)),
 		logger.Int("translatable", totalBlocks))
 
-	if totalBlocks == 0 {
+	// The outline (bookmark) titles and the title/subject metadata go through the same
+	// cache and batch as the text blocks, but are written back into the outline instead of
+	// overlaid on the pages. Formula-like titles are kept verbatim, like formula blocks.
+	t.outlineTranslated = 0
+	outline, err := ExtractOutline(inputPath)
+	if err != nil {
+		logger.Warn("failed to read PDF outline", logger.Err(err))
+	}
+	outlineEntries := outline.Entries()
+	var outlineBlocks []TextBlock
+	for i, entry := range outlineEntries {
+		if strings.TrimSpace(entry.Title) == "" || t.isMathFormula(entry.Title) {
+			continue
+		}
+		outlineBlocks = append(outlineBlocks, TextBlock{
+			ID:        fmt.Sprintf("outline-%d", i),
+			Page:      entry.Page,
+			Text:      entry.Title,
+			BlockType: "heading",
+		})
+	}
+	if outline != nil {
+		for _, meta := range []struct{ id, text string }{{"meta-title", outline.Title}, {"meta-subject", outline.Subject}} {
+			if strings.TrimSpace(meta.text) == "" || t.isMathFormula(meta.text) {
+				continue
+			}
+			outlineBlocks = append(outlineBlocks, TextBlock{ID: meta.id, Text: meta.text, BlockType: "metadata"})
+		}
+	}
+
+	if totalBlocks == 0 && len(outlineBlocks) == 0 {
 		// No translatable blocks, just copy original
 		if progressCallback != nil {
 			progressCallback("没有可翻译的文本块，复制原始文件...")
@@ -1309,6 +1353,9 @@ func (t *BabelDocTranslator) TranslatePDFWithGoPDF2Progressive(inputPath, output
 		})
 	}
 
+	textBlocks = append(textBlocks, outlineBlocks...)
+	totalBlocks = len(textBlocks)
+
 	// Check cache and separate cached vs uncached
 	var uncachedBlocks []TextBlock
 	translations := make(map[string]string)
@@ -1349,6 +1396,10 @@ func (t *BabelDocTranslator) TranslatePDFWithGoPDF2Progressive(inputPath, output
 		if err != nil {
 			logger.Warn("batch translation had errors", logger.Err(err))
 		}
+		usage := batchTranslator.Usage()
+		t.usage.PromptTokens += usage.PromptTokens
+		t.usage.CompletionTokens += usage.CompletionTokens
+		t.usage.TotalTokens += usage.TotalTokens
 
 		for _, tb := range translated {
 			if tb.TranslatedText != "" {
@@ -1394,13 +1445,43 @@ func (t *BabelDocTranslator) TranslatePDFWithGoPDF2Progressive(inputPath, output
 		})
 	}
 
-	gen := NewGoPDF2Generator(t.workDir)
-	if t.fontPath != "" {
-		gen.fontPath = t.fontPath
+	if len(translatableBlocks) == 0 {
+		// Only the outline and metadata have text to translate: keep the pages as they are
+		if err := t.copyFile(inputPath, outputPath); err != nil {
+			return err
+		}
+	} else {
+		gen := NewGoPDF2Generator(t.workDir)
+		if t.fontPath != "" {
+			gen.fontPath = t.fontPath
+		}
+
+		if err := gen.GenerateTranslatedPDF(inputPath, translatedBlocks, outputPath); err != nil {
+			return fmt.Errorf("PDF generation failed: %w", err)
+		}
 	}
 
-	if err := gen.GenerateTranslatedPDF(inputPath, translatedBlocks, outputPath); err != nil {
-		return fmt.Errorf("PDF generation failed: %w", err)
+	// The generated PDF has no outline of its own: write the original one back, with the
+	// translated titles, onto the same pages (a copied PDF has its outline replaced)
+	if outline != nil {
+		translatedEntries := 0
+		for i, entry := range outlineEntries {
+			if translated := outlineText(translations[fmt.Sprintf("outline-%d", i)]); translated != "" {
+				entry.Title = translated
+				translatedEntries++
+			}
+		}
+		if translated := outlineText(translations["meta-title"]); translated != "" {
+			outline.Title = translated
+		}
+		if translated := outlineText(translations["meta-subject"]); translated != "" {
+			outline.Subject = translated
+		}
+		if err := WriteOutline(outputPath, outline); err != nil {
+			logger.Warn("failed to write translated outline", logger.Err(err))
+		} else {
+			t.outlineTranslated = translatedEntries
+		}
 	}
 
 	// Final page callback
@@ -1415,7 +1496,13 @@ func (t *BabelDocTranslator) TranslatePDFWithGoPDF2Progressive(inputPath, output
 	logger.Info("Go PDF translation completed",
 		logger.String("output", outputPath),
 		logger.Int("translated", len(translatedBlocks)),
-		logger.Int("cached", cachedCount))
+		logger.Int("cached", cachedCount),
+		logger.Int("outlineTranslated", t.outlineTranslated))
 
 	return nil
 }
+
+// outlineText flattens a translated outline title or metadata value to a single line
+func outlineText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
 )
 
 // BatchSeparator is the delimiter used to separate text blocks in a batch for translation
@@ -39,6 +40,8 @@ type BatchTranslator struct {
 	contextWindow int
 	concurrency   int
 	client        *http.Client
+	usageMu       sync.Mutex
+	usage         Usage // 已完成请求的 token 用量累计（含重试）
 }
 
 // BatchTranslatorConfig holds configuration options for creating a BatchTranslator
@@ -76,7 +79,8 @@ func NewBatchTranslator(cfg BatchTranslatorConfig) *BatchTranslator {
 		contextWindow: contextWindow,
 		concurrency:   concurrency,
 		client: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: netproxy.NewTransport(),
 		},
 	}
 }
@@ -415,6 +419,8 @@ func (b *BatchTranslator) callOpenAIAPI(batchText string) (string, error) {
 		return "", NewPDFErrorWithDetails(ErrAPIFailed, "API returned error", chatResp.Error.Message, nil)
 	}
 
+	b.addUsage(chatResp.Usage)
+
 	// Extract translated content
 	if len(chatResp.Choices) == 0 {
 		return "", NewPDFError(ErrAPIFailed, "API returned no choices", nil)
@@ -423,6 +429,22 @@ func (b *BatchTranslator) callOpenAIAPI(batchText string) (string, error) {
 	return chatResp.Choices[0].Message.Content, nil
 }
 
+// addUsage 累计一次请求的 token 用量
+func (b *BatchTranslator) addUsage(usage Usage) {
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	b.usage.PromptTokens += usage.PromptTokens
+	b.usage.CompletionTokens += usage.CompletionTokens
+	b.usage.TotalTokens += usage.TotalTokens
+}
+
+// Usage 返回到目前为止所有请求（含重试）的 token 用量
+func (b *BatchTranslator) Usage() Usage {
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	return b.usage
+}
+
 // normalizeAPIURL ensures the API URL ends with /chat/completions
 func (b *BatchTranslator) normalizeAPIURL(url string) string {
 	if url == "" {
@@ -5,12 +5,14 @@
 package pdf
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"latex-translator/internal/compiler/compilelimit"
 	"latex-translator/internal/logger"
 )
 
@@ -192,6 +194,10 @@ func copyFileSimple(src, dst string) error {
 }
 
 func compileLatexInDir(dir, texFile string) error {
+	// 与其他编译共享并发编译数限制
+	release, _ := compilelimit.Acquire(context.Background(), "xelatex "+texFile)
+	defer release()
+
 	cmd := exec.Command("xelatex", "-interaction=nonstopmode", texFile)
 	cmd.Dir = dir
 	output, err := cmd.CombinedOutput()
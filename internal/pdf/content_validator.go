@@ -137,6 +137,14 @@ func (v *ContentValidator) ValidateContent(originalPath, translatedPath string)
 	return result, nil
 }
 
+// ExtractSections extracts the section structure of a single PDF, sorted by page
+func (v *ContentValidator) ExtractSections(pdfPath string) ([]SectionInfo, error) {
+	blocks, err := v.translator.ExtractBlocks(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract PDF blocks: %w", err)
+	}
+	return v.extractSections(blocks), nil
+}
 
 // extractSections extracts section information from PDF blocks
 func (v *ContentValidator) extractSections(blocks []BabelDocBlock) []SectionInfo {
@@ -0,0 +1,166 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// AuditFonts 列出 PDF 中的全部字体及其嵌入状态
+// Type3 字体的字形直接定义在 PDF 中，视为已嵌入
+func AuditFonts(pdfPath string) (*types.FontAudit, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开 PDF: %w", err)
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	info, err := api.PDFInfo(f, pdfPath, nil, true, conf)
+	if err != nil {
+		return nil, fmt.Errorf("读取 PDF 字体信息失败: %w", err)
+	}
+
+	audit := &types.FontAudit{PDFPath: pdfPath}
+	seen := make(map[string]int)
+	for _, fi := range info.Fonts {
+		embedded := fi.Embedded || fi.Type == "Type3"
+		key := fi.Name + "|" + fi.Type
+		// 同一字体可能对应多个字体对象（如不同子集），任一未嵌入即视为未嵌入
+		if idx, ok := seen[key]; ok {
+			audit.Fonts[idx].Embedded = audit.Fonts[idx].Embedded && embedded
+			continue
+		}
+		seen[key] = len(audit.Fonts)
+		audit.Fonts = append(audit.Fonts, types.PDFFont{Name: fi.Name, Type: fi.Type, Embedded: embedded})
+	}
+
+	for _, font := range audit.Fonts {
+		if !font.Embedded {
+			audit.NotEmbedded = append(audit.NotEmbedded, font.Name)
+		}
+	}
+	sort.Strings(audit.NotEmbedded)
+
+	logger.Debug("PDF fonts audited",
+		logger.String("pdfPath", pdfPath),
+		logger.Int("fonts", len(audit.Fonts)),
+		logger.Int("notEmbedded", len(audit.NotEmbedded)))
+	return audit, nil
+}
+
+// FindGhostscript 查找可用的 Ghostscript 可执行文件，未安装时返回空字符串
+func FindGhostscript() string {
+	candidates := []string{"gs"}
+	if runtime.GOOS == "windows" {
+		candidates = []string{"gswin64c", "gswin32c", "gs"}
+	}
+	for _, name := range candidates {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// EmbedFontsWithGhostscript 使用 Ghostscript 重写 PDF 并嵌入全部字体（原地修改文件）
+// 未嵌入的标准字体会被替换为 Ghostscript 自带的等效字体
+func EmbedFontsWithGhostscript(gsPath, pdfPath string) error {
+	tmpPath := pdfPath + ".embedded.pdf"
+	defer os.Remove(tmpPath)
+
+	cmd := exec.Command(gsPath,
+		"-dNOPAUSE", "-dBATCH", "-dSAFER", "-dQUIET",
+		"-sDEVICE=pdfwrite",
+		"-dPDFSETTINGS=/prepress",
+		"-dEmbedAllFonts=true",
+		"-dSubsetFonts=true",
+		"-dCompatibilityLevel=1.6",
+		"-sOutputFile="+tmpPath,
+		pdfPath)
+	hideWindowOnWindows(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Ghostscript 执行失败: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := os.Rename(tmpPath, pdfPath); err != nil {
+		return fmt.Errorf("替换 PDF 失败: %w", err)
+	}
+	return nil
+}
+
+// EnsureFontsEmbedded 检查 PDF 的字体嵌入情况，有未嵌入字体时尝试用 Ghostscript 修复
+// 修复失败时不返回错误，而是在检查结果的 RemediationError 中给出原因和处理建议
+func EnsureFontsEmbedded(pdfPath string) (*types.FontAudit, error) {
+	audit, err := AuditFonts(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	if audit.FullyEmbedded() {
+		return audit, nil
+	}
+
+	missing := strings.Join(audit.NotEmbedded, ", ")
+	gsPath := FindGhostscript()
+	if gsPath == "" {
+		audit.RemediationError = fmt.Sprintf("字体 %s 未嵌入，且未找到 Ghostscript 无法自动嵌入。请安装 Ghostscript (gs/gswin64c) 并加入 PATH，或在 LaTeX 中改用可嵌入的字体后重新编译", missing)
+		return audit, nil
+	}
+
+	logger.Info("embedding fonts with Ghostscript",
+		logger.String("pdfPath", pdfPath),
+		logger.String("fonts", missing))
+	if err := EmbedFontsWithGhostscript(gsPath, pdfPath); err != nil {
+		audit.RemediationError = fmt.Sprintf("字体 %s 未嵌入，Ghostscript 修复失败: %v", missing, err)
+		return audit, nil
+	}
+
+	fixed, err := AuditFonts(pdfPath)
+	if err != nil {
+		audit.RemediationError = fmt.Sprintf("Ghostscript 修复后无法读取 PDF: %v", err)
+		return audit, nil
+	}
+	fixed.Remediated = true
+	if !fixed.FullyEmbedded() {
+		fixed.RemediationError = fmt.Sprintf("Ghostscript 修复后字体 %s 仍未嵌入，可能是字体许可禁止嵌入，请在 LaTeX 中改用其他字体后重新编译",
+			strings.Join(fixed.NotEmbedded, ", "))
+	}
+	return fixed, nil
+}
+
+// FormatFontAudit 将字体检查结果格式化为报告文本，逐行列出每个字体及其嵌入状态
+func FormatFontAudit(audit *types.FontAudit) string {
+	var sb strings.Builder
+	if audit.FullyEmbedded() {
+		sb.WriteString(fmt.Sprintf("%s: 全部 %d 个字体已嵌入", audit.PDFPath, len(audit.Fonts)))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s: %d 个字体中有 %d 个未嵌入", audit.PDFPath, len(audit.Fonts), len(audit.NotEmbedded)))
+	}
+	if audit.Remediated {
+		sb.WriteString("（已通过 Ghostscript 修复）")
+	}
+	for _, font := range audit.Fonts {
+		status := "已嵌入"
+		if !font.Embedded {
+			status = "未嵌入"
+		}
+		sb.WriteString(fmt.Sprintf("\n  %s [%s] %s", font.Name, font.Type, status))
+	}
+	if audit.RemediationError != "" {
+		sb.WriteString("\n  " + audit.RemediationError)
+	}
+	return sb.String()
+}
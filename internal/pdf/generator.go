@@ -3,6 +3,7 @@
 package pdf
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"latex-translator/internal/compiler/compilelimit"
 	"latex-translator/internal/logger"
 
 	ledongthucpdf "github.com/ledongthuc/pdf"
@@ -25,6 +27,10 @@ type PDFGenerator struct {
 	workDir  string
 	fontPath string
 	conf     *model.Configuration
+
+	// AlignBySections 为 true 时，GenerateSideBySidePDF 和 GenerateInterleavedPDF 按章节对齐
+	// 原文与译文页面：在较短的一侧插入空白页，使每个编号章节在同一对照页开始
+	AlignBySections bool
 }
 
 // NewPDFGenerator creates a new PDFGenerator with the specified working directory
@@ -2007,9 +2013,49 @@ func (g *PDFGenerator) generateBilingualLatex(leftPDF, rightPDF string) string {
 `, leftPDF, rightPDF)
 }
 
-// GenerateBilingualPDFAlternate 生成交替页面的双语PDF
-// 每一页原文后面紧跟对应的译文页
-func (g *PDFGenerator) GenerateBilingualPDFAlternate(leftPDF, rightPDF, outputPath string) error {
+// GenerateInterleavedPDF 生成交替页面的双语PDF，便于 A4 打印
+// 原文每一页后面紧跟对应的译文页；页数不同时较短一侧补空白页，交替顺序不会错位
+func (g *PDFGenerator) GenerateInterleavedPDF(leftPDF, rightPDF, outputPath string) error {
+	return g.generatePagedBilingualPDF(leftPDF, rightPDF, outputPath, g.generateInterleavedLatex)
+}
+
+// generateInterleavedLatex 生成交替页面的LaTeX代码，页码为 0 的一侧插入空白页
+func (g *PDFGenerator) generateInterleavedLatex(leftPDF, rightPDF string, spreads []pageSpread) string {
+	var sb strings.Builder
+	sb.WriteString(`\documentclass[a4paper]{article}
+\usepackage[margin=0cm]{geometry}
+\usepackage{pdfpages}
+
+\begin{document}
+
+`)
+	
+	// 交替插入页面；pdfpages 的 {} 是与文档同尺寸的空白页
+	page := func(n int, file string) {
+		if n > 0 {
+			sb.WriteString(fmt.Sprintf("\\includepdf[pages={%d}]{%s}\n", n, file))
+		} else {
+			sb.WriteString(fmt.Sprintf("\\includepdf[pages={{}}]{%s}\n", file))
+		}
+	}
+	for _, s := range spreads {
+		page(s.left, leftPDF)
+		page(s.right, rightPDF)
+	}
+	
+	sb.WriteString("\n\\end{document}\n")
+	return sb.String()
+}
+
+// GenerateSideBySidePDF 生成真正的左右并排双栏PDF
+// 每一页左边显示原文对应页，右边显示译文对应页
+func (g *PDFGenerator) GenerateSideBySidePDF(leftPDF, rightPDF, outputPath string) error {
+	return g.generatePagedBilingualPDF(leftPDF, rightPDF, outputPath, g.generateSpreadLatex)
+}
+
+// generatePagedBilingualPDF 按原文、译文的页面配对生成双语PDF，render 负责把配对写成LaTeX
+// AlignBySections 为 true 时按章节对齐配对，否则逐页配对
+func (g *PDFGenerator) generatePagedBilingualPDF(leftPDF, rightPDF, outputPath string, render func(leftPDF, rightPDF string, spreads []pageSpread) string) error {
 	// 验证输入文件
 	if _, err := os.Stat(leftPDF); err != nil {
 		return NewPDFError(ErrPDFNotFound, "左侧PDF文件不存在", err)
@@ -2046,14 +2092,20 @@ func (g *PDFGenerator) GenerateBilingualPDFAlternate(leftPDF, rightPDF, outputPa
 		return err
 	}
 
-	// 生成交替页面的LaTeX
-	latexContent := g.generateAlternateLatex("left.pdf", "right.pdf", leftPages, rightPages)
+	// 生成双语页面的LaTeX
+	spreads := sequentialSpreads(leftPages, rightPages)
+	if g.AlignBySections {
+		if aligned, ok := g.sectionAlignedSpreads(leftCopy, rightCopy, leftPages, rightPages); ok {
+			spreads = aligned
+		}
+	}
+	latexContent := render("left.pdf", "right.pdf", spreads)
 	latexPath := filepath.Join(tempDir, "bilingual.tex")
 	if err := os.WriteFile(latexPath, []byte(latexContent), 0644); err != nil {
 		return NewPDFError(ErrGenerateFailed, "无法写入LaTeX文件", err)
 	}
 
-	// 编译LaTeX
+	// 编译LaTeX（在 Windows 上会隐藏命令行窗口）
 	if err := g.compileLatex(tempDir, "bilingual.tex"); err != nil {
 		return NewPDFError(ErrGenerateFailed, "LaTeX编译失败", err)
 	}
@@ -2067,98 +2119,136 @@ func (g *PDFGenerator) GenerateBilingualPDFAlternate(leftPDF, rightPDF, outputPa
 	return nil
 }
 
-// generateAlternateLatex 生成交替页面的LaTeX代码
-func (g *PDFGenerator) generateAlternateLatex(leftPDF, rightPDF string, leftPages, rightPages int) string {
-	var sb strings.Builder
-	sb.WriteString(`\documentclass[a4paper]{article}
-\usepackage[margin=0cm]{geometry}
-\usepackage{pdfpages}
-
-\begin{document}
+// pageSpread 是双语 PDF 的一页：左右两侧的页码，0 表示空白
+type pageSpread struct {
+	left, right int
+}
 
-`)
-	
-	// 交替插入页面
-	maxPages := leftPages
-	if rightPages > maxPages {
-		maxPages = rightPages
-	}
-	
+// sequentialSpreads 逐页配对：第 i 页对第 i 页，较短一侧末尾留空
+func sequentialSpreads(leftPages, rightPages int) []pageSpread {
+	maxPages := max(leftPages, rightPages)
+	spreads := make([]pageSpread, 0, maxPages)
 	for i := 1; i <= maxPages; i++ {
+		s := pageSpread{}
 		if i <= leftPages {
-			sb.WriteString(fmt.Sprintf("\\includepdf[pages={%d}]{%s}\n", i, leftPDF))
+			s.left = i
 		}
 		if i <= rightPages {
-			sb.WriteString(fmt.Sprintf("\\includepdf[pages={%d}]{%s}\n", i, rightPDF))
+			s.right = i
 		}
+		spreads = append(spreads, s)
 	}
-	
-	sb.WriteString("\n\\end{document}\n")
-	return sb.String()
+	return spreads
 }
 
-// GenerateSideBySidePDF 生成真正的左右并排双栏PDF
-// 每一页左边显示原文对应页，右边显示译文对应页
-func (g *PDFGenerator) GenerateSideBySidePDF(leftPDF, rightPDF, outputPath string) error {
-	// 验证输入文件
-	if _, err := os.Stat(leftPDF); err != nil {
-		return NewPDFError(ErrPDFNotFound, "左侧PDF文件不存在", err)
-	}
-	if _, err := os.Stat(rightPDF); err != nil {
-		return NewPDFError(ErrPDFNotFound, "右侧PDF文件不存在", err)
+// sectionAlignedSpreads 提取两份 PDF 的章节起始页，按章节对齐页面。
+// 章节提取失败或没有可对齐的章节时返回 false，调用方回退到逐页配对
+func (g *PDFGenerator) sectionAlignedSpreads(leftPDF, rightPDF string, leftPages, rightPages int) ([]pageSpread, bool) {
+	validator := NewContentValidator(g.workDir)
+	leftSections, err := validator.ExtractSections(leftPDF)
+	if err != nil {
+		logger.Warn("failed to extract sections of left PDF, pairing pages in order", logger.Err(err))
+		return nil, false
 	}
-
-	// 创建临时工作目录
-	tempDir, err := os.MkdirTemp("", "bilingual_pdf_*")
+	rightSections, err := validator.ExtractSections(rightPDF)
 	if err != nil {
-		return NewPDFError(ErrGenerateFailed, "无法创建临时目录", err)
+		logger.Warn("failed to extract sections of right PDF, pairing pages in order", logger.Err(err))
+		return nil, false
 	}
-	defer os.RemoveAll(tempDir)
 
-	// 复制PDF文件到临时目录
-	leftCopy := filepath.Join(tempDir, "left.pdf")
-	rightCopy := filepath.Join(tempDir, "right.pdf")
-	
-	if err := g.copyFile(leftPDF, leftCopy); err != nil {
-		return NewPDFError(ErrGenerateFailed, "无法复制左侧PDF", err)
-	}
-	if err := g.copyFile(rightPDF, rightCopy); err != nil {
-		return NewPDFError(ErrGenerateFailed, "无法复制右侧PDF", err)
+	anchors := sectionAnchors(leftSections, rightSections, leftPages, rightPages)
+	if len(anchors) == 0 {
+		logger.Info("no matching sections in both PDFs, pairing pages in order",
+			logger.Int("leftSections", len(leftSections)),
+			logger.Int("rightSections", len(rightSections)))
+		return nil, false
 	}
 
-	// 获取页数
-	leftPages, err := g.getPDFPageCount(leftCopy)
-	if err != nil {
-		return err
-	}
-	rightPages, err := g.getPDFPageCount(rightCopy)
-	if err != nil {
-		return err
-	}
+	spreads := alignSpreads(anchors, leftPages, rightPages)
+	logger.Info("aligned bilingual pages by section",
+		logger.Int("anchors", len(anchors)),
+		logger.Int("spreads", len(spreads)),
+		logger.Int("leftPages", leftPages),
+		logger.Int("rightPages", rightPages))
+	return spreads, true
+}
 
-	// 生成左右并排的LaTeX
-	latexContent := g.generateSideBySideLatex("left.pdf", "right.pdf", leftPages, rightPages)
-	latexPath := filepath.Join(tempDir, "bilingual.tex")
-	if err := os.WriteFile(latexPath, []byte(latexContent), 0644); err != nil {
-		return NewPDFError(ErrGenerateFailed, "无法写入LaTeX文件", err)
+// sectionAnchors 返回两侧都有的顶层编号章节（含附录）的起始页对，按页码严格递增。
+// 与前一个锚点不成递增关系的章节（识别错误或同页多个章节）被跳过
+func sectionAnchors(leftSections, rightSections []SectionInfo, leftPages, rightPages int) []pageSpread {
+	rightStarts := sectionStartPages(rightSections, rightPages)
+
+	var anchors []pageSpread
+	last := pageSpread{}
+	seen := make(map[string]bool)
+	for _, s := range leftSections {
+		key, ok := sectionAnchorKey(s)
+		if !ok || seen[key] || s.Page < 1 || s.Page > leftPages {
+			continue
+		}
+		seen[key] = true
+		rightPage, ok := rightStarts[key]
+		if !ok || s.Page <= last.left || rightPage <= last.right {
+			continue
+		}
+		last = pageSpread{left: s.Page, right: rightPage}
+		anchors = append(anchors, last)
 	}
+	return anchors
+}
 
-	// 编译LaTeX（在 Windows 上会隐藏命令行窗口）
-	if err := g.compileLatex(tempDir, "bilingual.tex"); err != nil {
-		return NewPDFError(ErrGenerateFailed, "LaTeX编译失败", err)
+// sectionStartPages 返回每个顶层编号章节第一次出现的页码
+func sectionStartPages(sections []SectionInfo, pages int) map[string]int {
+	starts := make(map[string]int)
+	for _, s := range sections {
+		key, ok := sectionAnchorKey(s)
+		if !ok || s.Page < 1 || s.Page > pages {
+			continue
+		}
+		if _, exists := starts[key]; !exists {
+			starts[key] = s.Page
+		}
 	}
+	return starts
+}
 
-	// 复制输出文件
-	compiledPDF := filepath.Join(tempDir, "bilingual.pdf")
-	if err := g.copyFile(compiledPDF, outputPath); err != nil {
-		return NewPDFError(ErrGenerateFailed, "无法复制输出PDF", err)
+// sectionAnchorKey 返回用于两侧匹配的章节标识，如 "section 3"、"appendix B"；
+// 只有顶层编号章节可以作为锚点
+func sectionAnchorKey(s SectionInfo) (string, bool) {
+	if s.Level != 1 || s.Number == "" {
+		return "", false
 	}
+	return s.Type + " " + s.Number, true
+}
 
-	return nil
+// alignSpreads 按锚点生成对照页：每个锚点之前的页面依次配对，较短一侧补空白页，
+// 锚点（章节起始页）总是落在同一对照页
+func alignSpreads(anchors []pageSpread, leftPages, rightPages int) []pageSpread {
+	var spreads []pageSpread
+	left, right := 1, 1
+	emit := func(leftEnd, rightEnd int) {
+		for left < leftEnd || right < rightEnd {
+			s := pageSpread{}
+			if left < leftEnd {
+				s.left = left
+				left++
+			}
+			if right < rightEnd {
+				s.right = right
+				right++
+			}
+			spreads = append(spreads, s)
+		}
+	}
+	for _, a := range anchors {
+		emit(a.left, a.right)
+	}
+	emit(leftPages+1, rightPages+1)
+	return spreads
 }
 
-// generateSideBySideLatex 生成左右并排的LaTeX代码
-func (g *PDFGenerator) generateSideBySideLatex(leftPDF, rightPDF string, leftPages, rightPages int) string {
+// generateSpreadLatex 按给定的对照页生成左右并排的LaTeX代码，页码为 0 的一侧留空
+func (g *PDFGenerator) generateSpreadLatex(leftPDF, rightPDF string, spreads []pageSpread) string {
 	var sb strings.Builder
 	sb.WriteString(`\documentclass[a4paper,landscape]{article}
 \usepackage[margin=0.2cm]{geometry}
@@ -2170,18 +2260,13 @@ func (g *PDFGenerator) generateSideBySideLatex(leftPDF, rightPDF string, leftPag
 `)
 	
 	// 逐页左右并排
-	maxPages := leftPages
-	if rightPages > maxPages {
-		maxPages = rightPages
-	}
-	
-	for i := 1; i <= maxPages; i++ {
+	for _, s := range spreads {
 		sb.WriteString("\\begin{figure}[p]\n")
 		sb.WriteString("\\centering\n")
 		
 		// 左侧页面
-		if i <= leftPages {
-			sb.WriteString(fmt.Sprintf("\\includegraphics[page=%d,width=0.48\\textwidth,keepaspectratio]{%s}\n", i, leftPDF))
+		if s.left > 0 {
+			sb.WriteString(fmt.Sprintf("\\includegraphics[page=%d,width=0.48\\textwidth,keepaspectratio]{%s}\n", s.left, leftPDF))
 		} else {
 			sb.WriteString("\\hspace{0.48\\textwidth}\n")
 		}
@@ -2189,8 +2274,8 @@ func (g *PDFGenerator) generateSideBySideLatex(leftPDF, rightPDF string, leftPag
 		sb.WriteString("\\hfill\n")
 		
 		// 右侧页面
-		if i <= rightPages {
-			sb.WriteString(fmt.Sprintf("\\includegraphics[page=%d,width=0.48\\textwidth,keepaspectratio]{%s}\n", i, rightPDF))
+		if s.right > 0 {
+			sb.WriteString(fmt.Sprintf("\\includegraphics[page=%d,width=0.48\\textwidth,keepaspectratio]{%s}\n", s.right, rightPDF))
 		}
 		
 		sb.WriteString("\\end{figure}\n")
@@ -2203,6 +2288,10 @@ func (g *PDFGenerator) generateSideBySideLatex(leftPDF, rightPDF string, leftPag
 
 // compileLatex 编译LaTeX文件
 func (g *PDFGenerator) compileLatex(workDir, texFile string) error {
+	// 与其他编译共享并发编译数限制
+	release, _ := compilelimit.Acquire(context.Background(), "xelatex "+texFile)
+	defer release()
+
 	// 优先使用 xelatex（更好的中文支持）
 	cmd := exec.Command("xelatex", "-interaction=nonstopmode", texFile)
 	cmd.Dir = workDir
@@ -0,0 +1,387 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"latex-translator/internal/logger"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// OutlineItem 是 PDF 大纲（书签）中的一项
+type OutlineItem struct {
+	Title  string
+	Page   int  // 目标页码（从 1 开始），0 表示没有可解析的目标
+	Open   bool // 是否默认展开子项
+	Bold   bool
+	Italic bool
+	Kids   []*OutlineItem
+
+	view  types.Array // 目标视图参数（如 /XYZ left top zoom），不含页面引用
+	color types.Array // 标题颜色（/C），可为空
+}
+
+// DocumentOutline 是 PDF 的大纲与文档元数据（Info 字典中的标题与主题）
+type DocumentOutline struct {
+	Items   []*OutlineItem
+	Title   string
+	Subject string
+
+	pageMode types.Object // 文档目录中的 /PageMode，如 /UseOutlines
+}
+
+// Entries 按文档顺序（深度优先）返回全部大纲条目
+func (o *DocumentOutline) Entries() []*OutlineItem {
+	var entries []*OutlineItem
+	var walk func(items []*OutlineItem)
+	walk = func(items []*OutlineItem) {
+		for _, item := range items {
+			entries = append(entries, item)
+			walk(item.Kids)
+		}
+	}
+	if o != nil {
+		walk(o.Items)
+	}
+	return entries
+}
+
+// readOutlineContext 以宽松校验模式读取 PDF，大纲与元数据的读写都基于此上下文
+func readOutlineContext(pdfPath string) (*model.Context, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("PDF 文件不存在: %w", err)
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadAndValidate(f, conf)
+	if err != nil {
+		return nil, fmt.Errorf("读取 PDF 失败: %w", err)
+	}
+	return ctx, nil
+}
+
+// ExtractOutline 读取 PDF 的大纲（书签）树以及 Info 字典中的标题与主题
+// 没有大纲的 PDF 返回空的 Items
+func ExtractOutline(pdfPath string) (*DocumentOutline, error) {
+	ctx, err := readOutlineContext(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	outline := &DocumentOutline{}
+	if ctx.Info != nil {
+		if info, err := ctx.DereferenceDict(*ctx.Info); err == nil && info != nil {
+			outline.Title = infoText(ctx, info, "Title")
+			outline.Subject = infoText(ctx, info, "Subject")
+		}
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, fmt.Errorf("读取文档目录失败: %w", err)
+	}
+	outline.pageMode = root["PageMode"]
+
+	obj, ok := root.Find("Outlines")
+	if !ok {
+		return outline, nil
+	}
+	outlines, err := ctx.DereferenceDict(obj)
+	if err != nil || outlines == nil {
+		return outline, nil
+	}
+
+	// 记录已访问的对象，防止损坏的 Next/First 链形成环
+	visited := make(map[int]bool)
+	outline.Items = readOutlineItems(ctx, outlines["First"], visited)
+	return outline, nil
+}
+
+// readOutlineItems 沿 Next 链读取同一层级的大纲项，并递归读取其子项
+func readOutlineItems(ctx *model.Context, obj types.Object, visited map[int]bool) []*OutlineItem {
+	var items []*OutlineItem
+	for obj != nil {
+		if ir, ok := obj.(types.IndirectRef); ok {
+			if visited[ir.ObjectNumber.Value()] {
+				break
+			}
+			visited[ir.ObjectNumber.Value()] = true
+		}
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil || d == nil {
+			break
+		}
+
+		item := &OutlineItem{}
+		if title, err := ctx.Dereference(d["Title"]); err == nil && title != nil {
+			if s, err := model.Text(title); err == nil {
+				item.Title = s
+			}
+		}
+		item.Page, item.view = outlineDestination(ctx, d)
+		if count := d.IntEntry("Count"); count != nil {
+			item.Open = *count > 0
+		}
+		if f := d.IntEntry("F"); f != nil {
+			item.Italic = *f&0x01 > 0
+			item.Bold = *f&0x02 > 0
+		}
+		if c := d.ArrayEntry("C"); len(c) == 3 {
+			item.color = c
+		}
+		item.Kids = readOutlineItems(ctx, d["First"], visited)
+
+		items = append(items, item)
+		obj = d["Next"]
+	}
+	return items
+}
+
+// outlineDestination 解析大纲项的目标（/Dest 或 GoTo 动作），返回页码与视图参数
+func outlineDestination(ctx *model.Context, d types.Dict) (int, types.Array) {
+	dest, ok := d["Dest"]
+	if !ok {
+		action, err := ctx.DereferenceDict(d["A"])
+		if err != nil || action == nil {
+			return 0, nil
+		}
+		if s := action.NameEntry("S"); s == nil || *s != "GoTo" {
+			return 0, nil
+		}
+		dest = action["D"]
+	}
+
+	obj, err := ctx.Dereference(dest)
+	if err != nil || obj == nil {
+		return 0, nil
+	}
+
+	var arr types.Array
+	switch v := obj.(type) {
+	case types.Array:
+		arr = v
+	case types.Name:
+		arr, err = ctx.DereferenceDestArray(v.Value())
+	case types.StringLiteral, types.HexLiteral:
+		// 命名目标
+		var name string
+		if name, err = model.Text(v); err == nil {
+			arr, err = ctx.DereferenceDestArray(name)
+		}
+	}
+	if err != nil || len(arr) == 0 {
+		return 0, nil
+	}
+
+	page := 0
+	switch p := arr[0].(type) {
+	case types.IndirectRef:
+		if n, err := ctx.PageNumber(p.ObjectNumber.Value()); err == nil {
+			page = n
+		}
+	case types.Integer:
+		// 整数形式的页面索引从 0 开始
+		page = p.Value() + 1
+	}
+	return page, arr[1:]
+}
+
+// infoText 读取 Info 字典中的文本属性
+func infoText(ctx *model.Context, info types.Dict, key string) string {
+	obj, err := ctx.Dereference(info[key])
+	if err != nil || obj == nil {
+		return ""
+	}
+	s, err := model.Text(obj)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// WriteOutline 将大纲与元数据写入 pdfPath（原地修改文件），替换其中已有的大纲
+// 逐页对应的译文 PDF 沿用原文的页码与视图参数，因此书签的目标与层级保持不变
+// 标题与主题为空时保留文件中原有的值
+func WriteOutline(pdfPath string, outline *DocumentOutline) error {
+	if outline == nil {
+		return nil
+	}
+	ctx, err := readOutlineContext(pdfPath)
+	if err != nil {
+		return err
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("读取文档目录失败: %w", err)
+	}
+
+	if len(outline.Items) > 0 {
+		outlines := types.NewDict()
+		outlines.InsertName("Type", "Outlines")
+		rootRef, err := ctx.IndRefForNewObject(outlines)
+		if err != nil {
+			return fmt.Errorf("写入大纲失败: %w", err)
+		}
+		first, last, err := writeOutlineItems(ctx, outline.Items, *rootRef)
+		if err != nil {
+			return fmt.Errorf("写入大纲失败: %w", err)
+		}
+		outlines["First"] = *first
+		outlines["Last"] = *last
+		outlines["Count"] = types.Integer(visibleOutlineItems(outline.Items))
+		root["Outlines"] = *rootRef
+		if outline.pageMode != nil {
+			root["PageMode"] = outline.pageMode
+		}
+	}
+
+	if err := writeInfoText(ctx, "Title", outline.Title); err != nil {
+		return fmt.Errorf("写入文档标题失败: %w", err)
+	}
+	if err := writeInfoText(ctx, "Subject", outline.Subject); err != nil {
+		return fmt.Errorf("写入文档主题失败: %w", err)
+	}
+
+	// 先写入临时文件再替换，避免写入失败时损坏译文 PDF
+	tmpPath := filepath.Join(filepath.Dir(pdfPath), "."+filepath.Base(pdfPath)+".outline.tmp")
+	if err := api.WriteContextFile(ctx, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入 PDF 失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, pdfPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("替换 PDF 失败: %w", err)
+	}
+
+	logger.Debug("outline written into PDF",
+		logger.String("pdfPath", pdfPath),
+		logger.Int("entries", len(outline.Entries())))
+	return nil
+}
+
+// writeOutlineItems 创建同一层级的大纲项及其子项，返回首项与末项的引用
+func writeOutlineItems(ctx *model.Context, items []*OutlineItem, parent types.IndirectRef) (*types.IndirectRef, *types.IndirectRef, error) {
+	var first, prevRef *types.IndirectRef
+	var prev types.Dict
+
+	for _, item := range items {
+		title, err := types.EscapedUTF16String(item.Title)
+		if err != nil {
+			return nil, nil, err
+		}
+		d := types.Dict(map[string]types.Object{
+			"Title":  types.StringLiteral(*title),
+			"Parent": parent,
+		})
+
+		// 页码超出译文范围的目标不写入，书签仍保留在原来的层级中
+		if item.Page >= 1 && item.Page <= ctx.PageCount {
+			_, pageRef, _, err := ctx.PageDict(item.Page, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			dest := types.Array{*pageRef}
+			if len(item.view) > 0 {
+				dest = append(dest, item.view...)
+			} else {
+				dest = append(dest, types.Name("Fit"))
+			}
+			d["Dest"] = dest
+		}
+		if style := outlineStyle(item); style > 0 {
+			d["F"] = types.Integer(style)
+		}
+		if item.color != nil {
+			d["C"] = item.color
+		}
+
+		ref, err := ctx.IndRefForNewObject(d)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(item.Kids) > 0 {
+			kidsFirst, kidsLast, err := writeOutlineItems(ctx, item.Kids, *ref)
+			if err != nil {
+				return nil, nil, err
+			}
+			d["First"] = *kidsFirst
+			d["Last"] = *kidsLast
+			// Count 为正表示展开，为负表示折叠，绝对值是展开后可见的子孙项数
+			count := visibleOutlineItems(item.Kids)
+			if !item.Open {
+				count = -count
+			}
+			d["Count"] = types.Integer(count)
+		}
+
+		if first == nil {
+			first = ref
+		}
+		if prev != nil {
+			d["Prev"] = *prevRef
+			prev["Next"] = *ref
+		}
+		prev, prevRef = d, ref
+	}
+	return first, prevRef, nil
+}
+
+// visibleOutlineItems 计算一组大纲项在其父项展开时可见的条目数
+func visibleOutlineItems(items []*OutlineItem) int {
+	n := 0
+	for _, item := range items {
+		n++
+		if item.Open {
+			n += visibleOutlineItems(item.Kids)
+		}
+	}
+	return n
+}
+
+// outlineStyle 返回大纲项的 /F 样式标志（1 为斜体，2 为粗体）
+func outlineStyle(item *OutlineItem) int {
+	style := 0
+	if item.Italic {
+		style |= 0x01
+	}
+	if item.Bold {
+		style |= 0x02
+	}
+	return style
+}
+
+// writeInfoText 设置 Info 字典中的文本属性，值为空时不做修改
+func writeInfoText(ctx *model.Context, key, value string) error {
+	if value == "" {
+		return nil
+	}
+	if ctx.Info == nil {
+		ref, err := ctx.IndRefForNewObject(types.NewDict())
+		if err != nil {
+			return err
+		}
+		ctx.Info = ref
+	}
+	info, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("Info 字典无效")
+	}
+	s, err := types.EscapedUTF16String(value)
+	if err != nil {
+		return err
+	}
+	info.Update(key, types.StringLiteral(*s))
+	return nil
+}
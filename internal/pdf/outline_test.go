@@ -0,0 +1,220 @@
+package pdf
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gopdf "github.com/VantageDataChat/GoPDF2"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// writeBlankPDF 生成 pages 页的空白 PDF
+func writeBlankPDF(t *testing.T, path string, pages int) {
+	t.Helper()
+	p := gopdf.GoPdf{}
+	p.Start(gopdf.Config{Unit: gopdf.UnitPT, PageSize: *gopdf.PageSizeA4})
+	for i := 0; i < pages; i++ {
+		p.AddPage()
+	}
+	if err := p.WritePdf(path); err != nil {
+		t.Fatalf("write blank PDF: %v", err)
+	}
+}
+
+// sampleOutline 返回一个三层嵌套、含展开与折叠项的大纲
+func sampleOutline() *DocumentOutline {
+	xyz := types.Array{types.Name("XYZ"), types.Float(72), types.Float(700), types.Integer(0)}
+	fit := types.Array{types.Name("Fit")}
+	return &DocumentOutline{
+		Title:   "A Study of Things",
+		Subject: "Physics",
+		Items: []*OutlineItem{
+			{Title: "1 Introduction", Page: 1, Open: true, Bold: true, view: xyz, Kids: []*OutlineItem{
+				{Title: "1.1 Background", Page: 2, view: fit, Kids: []*OutlineItem{
+					{Title: "1.1.1 History", Page: 2, view: fit},
+				}},
+				{Title: "1.2 Outline", Page: 2, Italic: true, view: fit},
+			}},
+			{Title: "2 Method", Page: 3, view: fit},
+			// 页码超出文档范围的条目没有目标，但保留在大纲中
+			{Title: "References", Page: 9},
+		},
+	}
+}
+
+// outlineShape 将大纲转换为便于比较的形式：标题、页码、层级与样式
+type outlineShape struct {
+	Title        string
+	Page         int
+	Open         bool
+	Bold, Italic bool
+	View         string
+	Kids         []outlineShape
+}
+
+func shapeOf(items []*OutlineItem) []outlineShape {
+	var shapes []outlineShape
+	for _, item := range items {
+		view := ""
+		if item.view != nil {
+			view = item.view.PDFString()
+		}
+		shapes = append(shapes, outlineShape{
+			Title: item.Title, Page: item.Page, Open: item.Open,
+			Bold: item.Bold, Italic: item.Italic, View: view,
+			Kids: shapeOf(item.Kids),
+		})
+	}
+	return shapes
+}
+
+func TestOutlineRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.pdf")
+	writeBlankPDF(t, source, 3)
+	if err := WriteOutline(source, sampleOutline()); err != nil {
+		t.Fatalf("WriteOutline: %v", err)
+	}
+
+	outline, err := ExtractOutline(source)
+	if err != nil {
+		t.Fatalf("ExtractOutline: %v", err)
+	}
+	if outline.Title != "A Study of Things" || outline.Subject != "Physics" {
+		t.Errorf("metadata = %q, %q", outline.Title, outline.Subject)
+	}
+	want := sampleOutline()
+	want.Items[2].Page = 0 // 超出范围的目标不会写入
+	if got := shapeOf(outline.Items); !reflect.DeepEqual(got, shapeOf(want.Items)) {
+		t.Errorf("outline changed in round trip:\ngot  %+v\nwant %+v", got, shapeOf(want.Items))
+	}
+	if n := len(outline.Entries()); n != 6 {
+		t.Errorf("Entries() = %d items, want 6", n)
+	}
+
+	// 译文 PDF 与原文逐页对应：翻译后的标题与元数据写入另一份 PDF，目标与层级不变
+	translated := filepath.Join(dir, "translated.pdf")
+	writeBlankPDF(t, translated, 3)
+	for _, entry := range outline.Entries() {
+		entry.Title = "译 " + entry.Title
+	}
+	outline.Title, outline.Subject = "关于事物的研究", "物理"
+	if err := WriteOutline(translated, outline); err != nil {
+		t.Fatalf("WriteOutline translated: %v", err)
+	}
+	result, err := ExtractOutline(translated)
+	if err != nil {
+		t.Fatalf("ExtractOutline translated: %v", err)
+	}
+	if result.Title != "关于事物的研究" || result.Subject != "物理" {
+		t.Errorf("translated metadata = %q, %q", result.Title, result.Subject)
+	}
+	if got, want := shapeOf(result.Items), shapeOf(outline.Items); !reflect.DeepEqual(got, want) {
+		t.Errorf("translated outline differs:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestWriteOutlineCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "count.pdf")
+	writeBlankPDF(t, path, 3)
+	if err := WriteOutline(path, sampleOutline()); err != nil {
+		t.Fatalf("WriteOutline: %v", err)
+	}
+
+	ctx, err := readOutlineContext(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines, err := ctx.DereferenceDict(root["Outlines"])
+	if err != nil || outlines == nil {
+		t.Fatalf("no outline dictionary: %v", err)
+	}
+
+	counts := map[string]int{}
+	var walk func(obj types.Object)
+	walk = func(obj types.Object) {
+		for obj != nil {
+			d, err := ctx.DereferenceDict(obj)
+			if err != nil || d == nil {
+				t.Fatalf("bad outline item: %v", err)
+			}
+			title, _ := ctx.Dereference(d["Title"])
+			s, _ := types.StringOrHexLiteral(title)
+			if c := d.IntEntry("Count"); c != nil && s != nil {
+				counts[*s] = *c
+			}
+			walk(d["First"])
+			obj = d["Next"]
+		}
+	}
+	walk(outlines["First"])
+
+	// 顶层可见 3 项，展开的 "1 Introduction" 下可见 2 项
+	if c := outlines.IntEntry("Count"); c == nil || *c != 5 {
+		t.Errorf("root Count = %v, want 5", c)
+	}
+	want := map[string]int{
+		"1 Introduction": 2,  // 展开：正数
+		"1.1 Background": -1, // 折叠：负数，绝对值为展开后可见的子项数
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Count entries = %v, want %v", counts, want)
+	}
+}
+
+func TestExtractOutlineNamedDestinations(t *testing.T) {
+	dir := t.TempDir()
+	blank := filepath.Join(dir, "blank.pdf")
+	source := filepath.Join(dir, "source.pdf")
+	writeBlankPDF(t, blank, 3)
+
+	// pdfcpu 写入的书签使用命名目标
+	bookmarks := []pdfcpu.Bookmark{
+		{Title: "Introduction", PageFrom: 1, Kids: []pdfcpu.Bookmark{{Title: "Background", PageFrom: 2}}},
+		{Title: "Method", PageFrom: 3},
+	}
+	if err := api.AddBookmarksFile(blank, source, bookmarks, true, nil); err != nil {
+		t.Fatalf("AddBookmarksFile: %v", err)
+	}
+
+	outline, err := ExtractOutline(source)
+	if err != nil {
+		t.Fatalf("ExtractOutline: %v", err)
+	}
+	var got []string
+	for _, entry := range outline.Entries() {
+		got = append(got, entry.Title)
+		if entry.Page == 0 {
+			t.Errorf("%q: named destination not resolved", entry.Title)
+		}
+	}
+	if want := []string{"Introduction", "Background", "Method"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("titles = %v, want %v", got, want)
+	}
+	if len(outline.Items) != 2 || len(outline.Items[0].Kids) != 1 || outline.Items[0].Kids[0].Page != 2 {
+		t.Errorf("nesting not preserved: %+v", shapeOf(outline.Items))
+	}
+}
+
+func TestExtractOutlineWithoutOutline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.pdf")
+	writeBlankPDF(t, path, 1)
+	outline, err := ExtractOutline(path)
+	if err != nil {
+		t.Fatalf("ExtractOutline: %v", err)
+	}
+	if len(outline.Entries()) != 0 {
+		t.Errorf("expected no outline entries, got %d", len(outline.Entries()))
+	}
+	var empty *DocumentOutline
+	if len(empty.Entries()) != 0 {
+		t.Error("nil outline has entries")
+	}
+}
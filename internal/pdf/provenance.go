@@ -0,0 +1,92 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// provenancePrefix 是写入 PDF Info 字典的自定义属性名前缀
+const provenancePrefix = "RapidPaperTrans."
+
+// provenanceProperties 将来源信息转换为 PDF Info 字典属性
+func provenanceProperties(p *types.Provenance) map[string]string {
+	props := map[string]string{
+		provenancePrefix + "ToolVersion":    p.ToolVersion,
+		provenancePrefix + "GitCommit":      p.GitCommit,
+		provenancePrefix + "Model":          p.Model,
+		provenancePrefix + "PromptHash":     p.PromptHash,
+		provenancePrefix + "PromptPreset":   p.PromptPreset,
+		provenancePrefix + "GlossaryHash":   p.GlossaryHash,
+		provenancePrefix + "SourceSHA256":   p.SourceSHA256,
+		provenancePrefix + "JobTimestamp":   p.JobTimestamp,
+		provenancePrefix + "PostProcessing": p.PostProcessing,
+	}
+	// 空值不写入，避免 Info 字典中出现空属性
+	for k, v := range props {
+		if v == "" {
+			delete(props, k)
+		}
+	}
+	return props
+}
+
+// EmbedProvenance 将来源信息写入 PDF 的 Info 字典（原地修改文件）
+// 写入的属性可在 PDF 阅读器的"文档属性 → 自定义"中查看
+func EmbedProvenance(pdfPath string, p *types.Provenance) error {
+	if p == nil {
+		return nil
+	}
+	if _, err := os.Stat(pdfPath); err != nil {
+		return fmt.Errorf("PDF 文件不存在: %w", err)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	if err := api.AddPropertiesFile(pdfPath, "", provenanceProperties(p), conf); err != nil {
+		return fmt.Errorf("写入来源信息失败: %w", err)
+	}
+
+	logger.Debug("provenance embedded into PDF",
+		logger.String("pdfPath", pdfPath),
+		logger.String("model", p.Model),
+		logger.String("toolVersion", p.ToolVersion))
+	return nil
+}
+
+// ReadProvenance 从 PDF 的 Info 字典读取来源信息
+// 如果 PDF 中没有任何来源属性，返回 nil 和 nil 错误
+func ReadProvenance(pdfPath string) (*types.Provenance, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开 PDF 失败: %w", err)
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	props, err := api.Properties(f, conf)
+	if err != nil {
+		return nil, fmt.Errorf("读取 PDF 属性失败: %w", err)
+	}
+
+	p := &types.Provenance{
+		ToolVersion:    props[provenancePrefix+"ToolVersion"],
+		GitCommit:      props[provenancePrefix+"GitCommit"],
+		Model:          props[provenancePrefix+"Model"],
+		PromptHash:     props[provenancePrefix+"PromptHash"],
+		GlossaryHash:   props[provenancePrefix+"GlossaryHash"],
+		SourceSHA256:   props[provenancePrefix+"SourceSHA256"],
+		JobTimestamp:   props[provenancePrefix+"JobTimestamp"],
+		PostProcessing: props[provenancePrefix+"PostProcessing"],
+	}
+	if *p == (types.Provenance{}) {
+		return nil, nil
+	}
+	return p, nil
+}
@@ -263,12 +263,18 @@ func (p *PDFTranslator) TranslatePDF() (*TranslationResult, error) {
 	p.updateStatusLocked(PDFPhaseComplete, 100, "翻译完成")
 	p.mu.Unlock()
 
+	usage := babelTranslator.Usage()
 	result := &TranslationResult{
 		OriginalPDFPath:     p.currentFile,
 		TranslatedPDFPath:   outputPath,
 		TotalBlocks:         0,
 		TranslatedBlocks:    0,
 		CachedBlocks:        0,
+		TokensUsed:          usage.TotalTokens,
+		InputTokens:         usage.PromptTokens,
+		OutputTokens:        usage.CompletionTokens,
+		Model:               p.config.OpenAIModel,
+		OutlineEntries:      babelTranslator.OutlineTranslated(),
 		PageCountResult:     pageCountResult,
 		ContentValidation:   contentValidation,
 	}
@@ -76,6 +76,11 @@ type TranslationResult struct {
 	TranslatedBlocks  int                       `json:"translated_blocks"`
 	CachedBlocks      int                       `json:"cached_blocks"`
 	TokensUsed        int                       `json:"tokens_used"`
+	InputTokens       int                       `json:"input_tokens"`             // 输入 token 数（含重试）
+	OutputTokens      int                       `json:"output_tokens"`            // 输出 token 数（含重试）
+	Model             string                    `json:"model,omitempty"`          // 翻译使用的模型
+	EstimatedCost     float64                   `json:"estimated_cost,omitempty"` // 按价格表估算的费用（美元），模型价格未知时为 0
+	OutlineEntries    int                       `json:"outline_entries"`          // 已翻译的大纲（书签）条目数
 	PageCountResult   *PageCountResult          `json:"page_count_result,omitempty"`   // 页数检测结果
 	ContentValidation *ContentValidationResult  `json:"content_validation,omitempty"`  // 内容完整性检测结果
 }
@@ -0,0 +1,245 @@
+// Package pdfserve delivers local PDF files to the webview. Small files are returned as
+// data URLs; larger ones are registered under a random token and served by the asset
+// server from /pdf/token/<token> with range support, so the embedded viewer loads them
+// lazily instead of receiving the whole file base64-encoded over the Wails bridge.
+// Files under the directories of the application are also served by their path from
+// /pdf/<path>.
+package pdfserve
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// DataURLLimit is the largest file returned as a data URL. Base64 makes it a third
+	// larger, and the string is copied several times between Go, the bridge and the webview.
+	DataURLLimit = 8 << 20
+	// TokenRoute is the asset server route of registered files
+	TokenRoute = "/pdf/token/"
+	// PathRoute is the asset server route of files addressed by their path
+	PathRoute = "/pdf/"
+)
+
+// Registry maps tokens to the PDF files the webview may load by URL. Only registered
+// files are reachable through token URLs; path URLs reach the files under the roots and
+// the files allowed one by one.
+type Registry struct {
+	mu      sync.Mutex
+	paths   map[string]string // token -> path
+	tokens  map[string]string // path -> token
+	roots   func() []string   // directories served by path
+	allowed map[string]bool   // files outside of the roots served by path
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		paths:   make(map[string]string),
+		tokens:  make(map[string]string),
+		allowed: make(map[string]bool),
+	}
+}
+
+// SetRoots sets the directories whose files are served by path. roots is called for
+// every request, so directories changed in the settings take effect at once.
+func (r *Registry) SetRoots(roots func() []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roots = roots
+}
+
+// Allow serves a file outside of the roots by path, such as a PDF the user opened
+func (r *Registry) Allow(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allowed[realPath(path)] = true
+}
+
+// Register makes a file reachable under a token URL and returns the URL. A file
+// registered again keeps its token.
+func (r *Registry) Register(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[abs]; ok {
+		return TokenRoute + token, nil
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	r.paths[token] = abs
+	r.tokens[abs] = token
+	return TokenRoute + token, nil
+}
+
+// Resolve returns the file registered under a token
+func (r *Registry) Resolve(token string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path, ok := r.paths[token]
+	return path, ok
+}
+
+// URL returns a URL the webview can display a PDF from: a data URL for files up to
+// limit bytes, a token URL served by ServeToken for larger ones
+func (r *Registry) URL(path string, limit int64) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", types.NewAppError(types.ErrFileNotFound, "无法读取 PDF 文件", err)
+	}
+	if info.IsDir() {
+		return "", types.NewAppError(types.ErrInvalidInput, fmt.Sprintf("%s 不是文件", path), nil)
+	}
+
+	if info.Size() > limit {
+		url, err := r.Register(path)
+		if err != nil {
+			return "", types.NewAppError(types.ErrInternal, "无法注册 PDF 文件", err)
+		}
+		return url, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", types.NewAppError(types.ErrFileNotFound, "无法读取 PDF 文件", err)
+	}
+	return "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ServeToken serves a registered file for a request to TokenRoute and reports whether the
+// request was for that route. The file is streamed with range support: the viewer can
+// request the parts it displays and never holds the whole file in memory.
+func (r *Registry) ServeToken(w http.ResponseWriter, req *http.Request) bool {
+	if !strings.HasPrefix(req.URL.Path, TokenRoute) {
+		return false
+	}
+	path, ok := r.Resolve(strings.TrimPrefix(req.URL.Path, TokenRoute))
+	if !ok {
+		http.NotFound(w, req)
+		return true
+	}
+	ServeFile(w, req, path)
+	return true
+}
+
+// ServePath serves the PDF file addressed by the path of a request to PathRoute. The path
+// is percent-decoded as a whole, so directory names in any script and with reserved
+// characters work. Other files, and paths outside of the roots and the allowed files, are
+// refused before the file is looked at, so the answer does not tell whether they exist:
+// the webview must not read or probe arbitrary files of the disk.
+func (r *Registry) ServePath(w http.ResponseWriter, req *http.Request) {
+	path, err := PathFromURL(req.URL.EscapedPath())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".pdf") || !r.servable(path) {
+		logger.Warn("refused to serve file outside of the work directories", logger.String("path", path))
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	ServeFile(w, req, path)
+}
+
+// PathFromURL returns the file path of an escaped PathRoute URL path. Both
+// /pdf/C:/dir/file.pdf and /pdf//home/dir/file.pdf are accepted; the frontend only
+// turns backslashes into slashes.
+func PathFromURL(escapedPath string) (string, error) {
+	if !strings.HasPrefix(escapedPath, PathRoute) {
+		return "", fmt.Errorf("not a %s URL: %s", PathRoute, escapedPath)
+	}
+	path, err := url.PathUnescape(strings.TrimPrefix(escapedPath, PathRoute))
+	if err != nil {
+		return "", fmt.Errorf("invalid path escape: %w", err)
+	}
+	// A drive letter may come with the slash of the URL in front of it: /C:/dir
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' && isDriveLetter(path[1]) {
+		path = path[1:]
+	}
+	if len(path) >= 2 && path[1] == ':' && isDriveLetter(path[0]) {
+		path = strings.ToUpper(path[:1]) + path[1:]
+	}
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
+
+// isDriveLetter reports whether c can be a Windows drive letter
+func isDriveLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// servable reports whether path is an allowed file or lies under one of the roots. Links
+// are resolved first, so a link in a root does not lead out of it.
+func (r *Registry) servable(path string) bool {
+	real := realPath(path)
+
+	r.mu.Lock()
+	allowed, roots := r.allowed[real], r.roots
+	r.mu.Unlock()
+	if allowed {
+		return true
+	}
+	if roots == nil {
+		return false
+	}
+	for _, root := range roots() {
+		if root == "" {
+			continue
+		}
+		rel, err := filepath.Rel(realPath(root), real)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath returns the absolute path of path with its links resolved, or the absolute
+// path alone if it cannot be resolved
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// ServeFile streams a PDF file with range support
+func ServeFile(w http.ResponseWriter, req *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, req, filepath.Base(path), info.ModTime(), f)
+}
@@ -0,0 +1,225 @@
+package pdfserve
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePDF writes a fake PDF of size bytes
+func writePDF(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bilingual.pdf")
+	data := append([]byte("%PDF-1.5\n"), bytes.Repeat([]byte("x"), size-9)...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestURLUsesDataURLOnlyForSmallFiles(t *testing.T) {
+	registry := NewRegistry()
+
+	small := writePDF(t, 1024)
+	url, err := registry.URL(small, 4096)
+	if err != nil {
+		t.Fatalf("URL(small) failed: %v", err)
+	}
+	if !strings.HasPrefix(url, "data:application/pdf;base64,") {
+		t.Errorf("small file: got %.40q, want a data URL", url)
+	}
+
+	large := writePDF(t, 8192)
+	url, err = registry.URL(large, 4096)
+	if err != nil {
+		t.Fatalf("URL(large) failed: %v", err)
+	}
+	if !strings.HasPrefix(url, TokenRoute) || len(url) > 100 {
+		t.Errorf("large file: got %.60q, want a token URL", url)
+	}
+	if again, _ := registry.URL(large, 4096); again != url {
+		t.Errorf("registering again changed the URL: %q != %q", again, url)
+	}
+
+	if _, err := registry.URL(filepath.Join(t.TempDir(), "missing.pdf"), 4096); err == nil {
+		t.Error("URL of a missing file succeeded")
+	}
+}
+
+func TestServeTokenSupportsRanges(t *testing.T) {
+	registry := NewRegistry()
+	path := writePDF(t, 10000)
+	url, err := registry.Register(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Range", "bytes=0-8")
+	rec := httptest.NewRecorder()
+	if !registry.ServeToken(rec, req) {
+		t.Fatal("token route not handled")
+	}
+	body, _ := io.ReadAll(rec.Result().Body)
+	if rec.Code != http.StatusPartialContent || string(body) != "%PDF-1.5\n" {
+		t.Errorf("range request: status %d, body %q", rec.Code, body)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 0-8/10000" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	registry.ServeToken(rec, httptest.NewRequest(http.MethodGet, TokenRoute+"0123456789abcdef", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown token: status %d, want 404", rec.Code)
+	}
+
+	if registry.ServeToken(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pdf/C:/paper.pdf", nil)) {
+		t.Error("path route handled as token route")
+	}
+}
+
+// pathURL returns the escaped PathRoute URL of a file, as the webview requests it
+func pathURL(path string) string {
+	return (&url.URL{Path: PathRoute + filepath.ToSlash(path)}).EscapedPath()
+}
+
+func TestServePathDecodesPaths(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "论文 (v2)+最终版")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "译文 100%.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.5\nbody"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.SetRoots(func() []string { return []string{root} })
+
+	rec := httptest.NewRecorder()
+	registry.ServePath(rec, httptest.NewRequest(http.MethodGet, pathURL(path), nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	if rec.Code != http.StatusOK || string(body) != "%PDF-1.5\nbody" {
+		t.Errorf("encoded Chinese path: status %d, body %q", rec.Code, body)
+	}
+
+	// Range requests let the viewer load the parts it displays
+	req := httptest.NewRequest(http.MethodGet, pathURL(path), nil)
+	req.Header.Set("Range", "bytes=9-12")
+	rec = httptest.NewRecorder()
+	registry.ServePath(rec, req)
+	body, _ = io.ReadAll(rec.Result().Body)
+	if rec.Code != http.StatusPartialContent || string(body) != "body" {
+		t.Errorf("range request: status %d, body %q", rec.Code, body)
+	}
+}
+
+func TestServePathRefusesFilesOutsideRoots(t *testing.T) {
+	outside := writePDF(t, 100)
+	root := filepath.Join(t.TempDir(), "work")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.SetRoots(func() []string { return []string{root} })
+
+	rel, err := filepath.Rel(root, outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{
+		pathURL(outside),
+		pathURL(root) + "/" + filepath.ToSlash(rel),
+		pathURL(root) + "/" + strings.ReplaceAll(filepath.ToSlash(rel), "..", "%2e%2e"),
+	} {
+		rec := httptest.NewRecorder()
+		registry.ServePath(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", target, rec.Code)
+		}
+	}
+
+	// A link in a root does not lead out of it
+	link := filepath.Join(root, "link.pdf")
+	if err := os.Symlink(outside, link); err == nil {
+		rec := httptest.NewRecorder()
+		registry.ServePath(rec, httptest.NewRequest(http.MethodGet, pathURL(link), nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("link out of the root: status %d, want 403", rec.Code)
+		}
+	}
+
+	// Missing files outside of the roots are refused like existing ones, so the answer does
+	// not reveal whether a file exists
+	missing := filepath.Join(filepath.Dir(outside), "missing.pdf")
+	rec := httptest.NewRecorder()
+	registry.ServePath(rec, httptest.NewRequest(http.MethodGet, pathURL(missing), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("missing file outside of the roots: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	registry.ServePath(rec, httptest.NewRequest(http.MethodGet, pathURL(filepath.Join(root, "missing.pdf")), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing file in a root: status %d, want 404", rec.Code)
+	}
+
+	// A file the user opened is served wherever it is
+	registry.Allow(outside)
+	rec = httptest.NewRecorder()
+	registry.ServePath(rec, httptest.NewRequest(http.MethodGet, pathURL(outside), nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("allowed file: status %d, want 200", rec.Code)
+	}
+}
+
+func TestServePathServesOnlyPDFs(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"paper.PDF": "%PDF-1.5\n", "main.tex": "\\documentclass{article}", "notes": "x"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := NewRegistry()
+	registry.SetRoots(func() []string { return []string{root} })
+
+	for name, want := range map[string]int{
+		"paper.PDF":   http.StatusOK,
+		"main.tex":    http.StatusForbidden,
+		"notes":       http.StatusForbidden,
+		"missing.tex": http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		registry.ServePath(rec, httptest.NewRequest(http.MethodGet, pathURL(filepath.Join(root, name)), nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", name, rec.Code, want)
+		}
+	}
+}
+
+func TestPathFromURL(t *testing.T) {
+	tests := []struct {
+		escaped string
+		want    string
+	}{
+		{"/pdf/C:/Users/me/paper.pdf", "C:/Users/me/paper.pdf"},
+		{"/pdf//c:/Users/me/paper.pdf", "C:/Users/me/paper.pdf"},
+		{"/pdf/c%3A/%E8%AE%BA%E6%96%87/a%2Bb.pdf", "C:/论文/a+b.pdf"},
+		{"/pdf//home/me/paper.pdf", "/home/me/paper.pdf"},
+	}
+	for _, tt := range tests {
+		got, err := PathFromURL(tt.escaped)
+		if err != nil || got != filepath.Clean(filepath.FromSlash(tt.want)) {
+			t.Errorf("PathFromURL(%q) = %q, %v, want %q", tt.escaped, got, err, tt.want)
+		}
+	}
+	if _, err := PathFromURL("/pdf/bad%zz.pdf"); err == nil {
+		t.Error("PathFromURL accepted an invalid escape")
+	}
+}
@@ -0,0 +1,453 @@
+package postprocess
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/translator"
+)
+
+// Bibliography kinds detected by DetectBibliographyStrategy
+const (
+	BibliographyNone            = ""
+	BibliographyBiblatex        = "biblatex"
+	BibliographyBibTeX          = "bibtex"
+	BibliographyThebibliography = "thebibliography"
+)
+
+// BibliographyStrategy is how a document builds its bibliography
+type BibliographyStrategy struct {
+	Kind     string   // one of the Bibliography* kinds
+	Backend  string   // biblatex backend: "biber" (the default) or "bibtex"
+	BibFiles []string // database files as written in the source, without .bib
+}
+
+var (
+	biblatexPackagePattern = regexp.MustCompile(`\\usepackage\s*(?:\[([^\]]*)\])?\s*\{[^}]*\bbiblatex\b[^}]*\}`)
+	biblatexBackendPattern = regexp.MustCompile(`backend\s*=\s*(\w+)`)
+	addbibresourcePattern  = regexp.MustCompile(`\\addbibresource\s*(?:\[[^\]]*\])?\s*\{([^}]+)\}`)
+	bibliographyPattern    = regexp.MustCompile(`\\bibliography\s*\{([^}]+)\}`)
+)
+
+// DetectBibliographyStrategy finds how a document builds its bibliography: biblatex (and
+// its backend), BibTeX with \bibliography, or an inline thebibliography environment.
+// Commented lines are ignored.
+func DetectBibliographyStrategy(content string) BibliographyStrategy {
+	content = stripCommentLines(content)
+	var s BibliographyStrategy
+
+	if m := biblatexPackagePattern.FindStringSubmatch(content); m != nil {
+		s.Kind = BibliographyBiblatex
+		s.Backend = "biber"
+		if b := biblatexBackendPattern.FindStringSubmatch(m[1]); b != nil && strings.HasPrefix(b[1], "bibtex") {
+			s.Backend = "bibtex"
+		}
+		for _, m := range addbibresourcePattern.FindAllStringSubmatch(content, -1) {
+			s.BibFiles = append(s.BibFiles, strings.TrimSuffix(strings.TrimSpace(m[1]), ".bib"))
+		}
+	} else if strings.Contains(content, "\\begin{thebibliography}") {
+		s.Kind = BibliographyThebibliography
+		return s
+	}
+
+	// \bibliography is BibTeX's command; biblatex still accepts it
+	for _, m := range bibliographyPattern.FindAllStringSubmatch(content, -1) {
+		if s.Kind == BibliographyNone {
+			s.Kind = BibliographyBibTeX
+		}
+		for _, name := range strings.Split(m[1], ",") {
+			if name = strings.TrimSuffix(strings.TrimSpace(name), ".bib"); name != "" {
+				s.BibFiles = append(s.BibFiles, name)
+			}
+		}
+	}
+	return s
+}
+
+// stripCommentLines blanks lines that are comments
+func stripCommentLines(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "%") {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bibField is a field of a bib entry; start and end delimit its value in the entry text
+type bibField struct {
+	name       string
+	value      string
+	start, end int
+}
+
+// bibEntry is a parsed entry; the fields' offsets are relative to text
+type bibEntry struct {
+	text   string
+	fields []bibField
+}
+
+// field returns the value of a field, or ""
+func (e *bibEntry) field(name string) (bibField, bool) {
+	for _, f := range e.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return bibField{}, false
+}
+
+// splitBibEntries splits a bib database into entries and the text between them.
+// @string, @preamble and @comment blocks are returned as plain text.
+func splitBibEntries(bib string) (parts []string, entries map[int]*bibEntry) {
+	entries = make(map[int]*bibEntry)
+	rest := bib
+	for {
+		at := strings.IndexByte(rest, '@')
+		if at < 0 {
+			parts = append(parts, rest)
+			return parts, entries
+		}
+		open := strings.IndexAny(rest[at:], "{(")
+		if open < 0 {
+			parts = append(parts, rest)
+			return parts, entries
+		}
+		open += at
+		end := matchingBibDelimiter(rest, open)
+		if end < 0 {
+			parts = append(parts, rest)
+			return parts, entries
+		}
+		parts = append(parts, rest[:at])
+		text := rest[at : end+1]
+		kind := strings.ToLower(strings.TrimSpace(rest[at+1 : open]))
+		if kind != "string" && kind != "preamble" && kind != "comment" {
+			entries[len(parts)] = parseBibEntry(text, open-at)
+		}
+		parts = append(parts, text)
+		rest = rest[end+1:]
+	}
+}
+
+// matchingBibDelimiter returns the index of the delimiter closing the one at open
+func matchingBibDelimiter(s string, open int) int {
+	closer := byte('}')
+	if s[open] == '(' {
+		closer = ')'
+	}
+	depth := 0
+	for i := open + 1; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 && closer == '}' {
+				return i
+			}
+			depth--
+		case ')':
+			if depth == 0 && closer == ')' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseBibEntry parses the fields of an entry whose opening delimiter is at open
+func parseBibEntry(text string, open int) *bibEntry {
+	e := &bibEntry{text: text}
+	// Skip the citation key
+	i := strings.IndexByte(text[open:], ',')
+	if i < 0 {
+		return e
+	}
+	i += open + 1
+	for i < len(text)-1 {
+		eq := strings.IndexByte(text[i:], '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.ToLower(strings.Trim(strings.TrimSpace(text[i:i+eq]), ","))
+		j := i + eq + 1
+		for j < len(text) && unicode.IsSpace(rune(text[j])) {
+			j++
+		}
+		start := j
+		end := j
+		for end < len(text)-1 {
+			switch text[end] {
+			case '{':
+				end = matchingBibDelimiter(text, end) + 1
+			case '"':
+				close := strings.IndexByte(text[end+1:], '"')
+				if close < 0 {
+					return e
+				}
+				end += close + 2
+			default:
+				for end < len(text)-1 && !strings.ContainsRune(",# \t\r\n}", rune(text[end])) {
+					end++
+				}
+			}
+			if end <= start {
+				return e
+			}
+			// Concatenation with #
+			k := end
+			for k < len(text)-1 && unicode.IsSpace(rune(text[k])) {
+				k++
+			}
+			if k < len(text)-1 && text[k] == '#' {
+				end = k + 1
+				for end < len(text)-1 && unicode.IsSpace(rune(text[end])) {
+					end++
+				}
+				continue
+			}
+			break
+		}
+		e.fields = append(e.fields, bibField{name: name, value: text[start:end], start: start, end: end})
+		i = end
+		if comma := strings.IndexByte(text[i:], ','); comma >= 0 {
+			i += comma + 1
+		} else {
+			break
+		}
+	}
+	return e
+}
+
+// cjkWeight is how many Latin letters a CJK character is counted as when deciding the
+// language of an entry: a Chinese word is one or two characters, an English word five or
+// six letters
+const cjkWeight = 3
+
+// isCJKDominant reports whether an entry's author, title and venue are mostly CJK text
+func isCJKDominant(e *bibEntry) bool {
+	cjk, latin := 0, 0
+	for _, name := range []string{"author", "editor", "title", "booktitle", "journal", "journaltitle", "publisher"} {
+		f, ok := e.field(name)
+		if !ok {
+			continue
+		}
+		for _, r := range stripLatexCommands(f.value) {
+			switch {
+			case unicode.Is(unicode.Han, r):
+				cjk++
+			case r < unicode.MaxASCII && unicode.IsLetter(r):
+				latin++
+			}
+		}
+	}
+	return cjk > 0 && cjk*cjkWeight >= latin
+}
+
+var latexCommandPattern = regexp.MustCompile(`\\[a-zA-Z]+`)
+
+// stripLatexCommands removes command names, whose letters say nothing about the language
+func stripLatexCommands(s string) string {
+	return latexCommandPattern.ReplaceAllString(s, "")
+}
+
+// unwrap returns a field value without its outer braces or quotes
+func unwrap(value string) string {
+	if len(value) >= 2 && ((value[0] == '{' && value[len(value)-1] == '}') || (value[0] == '"' && value[len(value)-1] == '"')) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// entrySortKey returns the pinyin sort key of an entry: of its first author, or its
+// title for entries without authors
+func entrySortKey(e *bibEntry) string {
+	source := ""
+	if f, ok := e.field("author"); ok {
+		source = strings.Split(unwrap(f.value), " and ")[0]
+	} else if f, ok := e.field("editor"); ok {
+		source = strings.Split(unwrap(f.value), " and ")[0]
+	} else if f, ok := e.field("title"); ok {
+		source = unwrap(f.value)
+	}
+	return translator.PinyinSortKey(stripLatexCommands(source))
+}
+
+// tagBibEntry tags a CJK-dominant entry for the bibliography kind:
+//   - biblatex: langid and a pinyin sortkey, which biber and biblatex's BibTeX backend sort by
+//   - BibTeX: a language field (used by Chinese styles such as gbt7714), the title wrapped
+//     in an extra brace group so styles do not change its case, and the first author
+//     prefixed with {\noopsort{<pinyin key>}}, so BibTeX sorts it by pinyin while
+//     \noopsort prints nothing. CJK environments are not used as guards: they conflict
+//     with ctex, which every translation loads.
+//
+// Entries that already have langid or language are left alone, so tagging is idempotent.
+func tagBibEntry(e *bibEntry, kind string) (string, bool) {
+	if _, ok := e.field("langid"); ok {
+		return e.text, false
+	}
+	if _, ok := e.field("language"); ok {
+		return e.text, false
+	}
+	if !isCJKDominant(e) {
+		return e.text, false
+	}
+
+	key := entrySortKey(e)
+	text := e.text
+	var added []string
+	if kind == BibliographyBiblatex {
+		added = append(added, "langid = {chinese}")
+		if _, ok := e.field("sortkey"); !ok && key != "" {
+			added = append(added, fmt.Sprintf("sortkey = {%s}", key))
+		}
+	} else {
+		added = append(added, "language = {chinese}")
+		// Replace values back to front so earlier offsets stay valid
+		replacements := map[string]func(string) string{
+			"title": func(v string) string {
+				if strings.HasPrefix(v, "{{") {
+					return v
+				}
+				return "{" + v + "}"
+			},
+			"author": func(v string) string {
+				if key == "" || strings.Contains(v, "\\noopsort") || !strings.HasPrefix(v, "{") {
+					return v
+				}
+				return "{{\\noopsort{" + key + "}}" + v[1:]
+			},
+		}
+		fields := append([]bibField(nil), e.fields...)
+		for i := len(fields) - 1; i >= 0; i-- {
+			if replace, ok := replacements[fields[i].name]; ok {
+				f := fields[i]
+				text = text[:f.start] + replace(f.value) + text[f.end:]
+			}
+		}
+	}
+
+	// Append the new fields before the closing delimiter
+	body := strings.TrimRight(text[:len(text)-1], " \t\r\n")
+	sep := ",\n  "
+	if strings.HasSuffix(body, ",") {
+		sep = "\n  "
+	}
+	return body + sep + strings.Join(added, ",\n  ") + "\n" + text[len(text)-1:], true
+}
+
+// TagBibLanguages tags the CJK-dominant entries of a bib database for the bibliography
+// kind and returns the new database and the number of entries tagged
+func TagBibLanguages(bib string, kind string) (string, int) {
+	parts, entries := splitBibEntries(bib)
+	tagged := 0
+	for i, e := range entries {
+		if text, ok := tagBibEntry(e, kind); ok {
+			parts[i] = text
+			tagged++
+		}
+	}
+	return strings.Join(parts, ""), tagged
+}
+
+// bibLanguageMarker marks the preamble setup added for tagged bibliographies
+const bibLanguageMarker = "% Mixed-language bibliography (added by latex-translator)"
+
+// bibLanguagePreamble returns the preamble setup for a tagged bibliography: pinyin
+// collation for biber, which keeps Latin entries in alphabetical order before the Chinese
+// ones, and the \noopsort command for BibTeX
+func bibLanguagePreamble(s BibliographyStrategy) string {
+	switch {
+	case s.Kind == BibliographyBiblatex && s.Backend == "biber":
+		return bibLanguageMarker + "\n\\ExecuteBibliographyOptions{sortlocale=zh__pinyin}\n"
+	case s.Kind == BibliographyBibTeX:
+		return bibLanguageMarker + "\n\\providecommand{\\noopsort}[1]{}\n"
+	}
+	return ""
+}
+
+// TaggedBibSuffix is appended to the name of the tagged copy of a bib database, which the
+// translated document uses instead of the original
+const TaggedBibSuffix = "_zh"
+
+// TagBibliographyLanguages tags the Chinese entries of the bib databases of a translated
+// main file so Chinese and English references sort and render correctly together. The
+// tagged databases are written as copies (refs.bib -> refs_zh.bib) so the original
+// document is unchanged; the main file is switched to the copies and gets the preamble
+// setup for the bibliography kind. Returns the new main file content and the number of
+// entries tagged; content is returned unchanged when no entry needed tagging.
+func TagBibliographyLanguages(content, texDir string) (string, int) {
+	strategy := DetectBibliographyStrategy(content)
+	if strategy.Kind != BibliographyBiblatex && strategy.Kind != BibliographyBibTeX {
+		return content, 0
+	}
+	if strings.Contains(content, bibLanguageMarker) {
+		return content, 0
+	}
+
+	total := 0
+	renamed := make(map[string]string)
+	for _, name := range strategy.BibFiles {
+		if strings.HasSuffix(name, TaggedBibSuffix) {
+			continue
+		}
+		bib, err := os.ReadFile(filepath.Join(texDir, name+".bib"))
+		if err != nil {
+			logger.Debug("bib database not found for language tagging", logger.String("file", name))
+			continue
+		}
+		tagged, n := TagBibLanguages(string(bib), strategy.Kind)
+		if n == 0 {
+			continue
+		}
+		copyName := name + TaggedBibSuffix
+		if err := os.WriteFile(filepath.Join(texDir, copyName+".bib"), []byte(tagged), 0644); err != nil {
+			logger.Warn("failed to write tagged bib database", logger.String("file", copyName), logger.Err(err))
+			continue
+		}
+		renamed[name] = copyName
+		total += n
+	}
+	if total == 0 {
+		return content, 0
+	}
+
+	// Switch the document to the tagged copies
+	switchNames := func(pattern *regexp.Regexp) {
+		content = pattern.ReplaceAllStringFunc(content, func(cmd string) string {
+			open := strings.LastIndex(cmd, "{")
+			names := strings.Split(cmd[open+1:len(cmd)-1], ",")
+			for i, name := range names {
+				trimmed := strings.TrimSpace(name)
+				ext := ""
+				if strings.HasSuffix(trimmed, ".bib") {
+					ext = ".bib"
+				}
+				if copyName, ok := renamed[strings.TrimSuffix(trimmed, ".bib")]; ok {
+					names[i] = copyName + ext
+				}
+			}
+			return cmd[:open+1] + strings.Join(names, ",") + "}"
+		})
+	}
+	switchNames(addbibresourcePattern)
+	switchNames(bibliographyPattern)
+
+	if setup := bibLanguagePreamble(strategy); setup != "" {
+		if idx := strings.Index(content, "\\begin{document}"); idx >= 0 {
+			content = content[:idx] + setup + content[idx:]
+		}
+	}
+
+	logger.Info("tagged Chinese bibliography entries",
+		logger.String("kind", strategy.Kind),
+		logger.String("backend", strategy.Backend),
+		logger.Int("entries", total))
+	return content, total
+}
@@ -0,0 +1,151 @@
+package postprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mixedBib = `@string{nips = "Advances in Neural Information Processing Systems"}
+
+@inproceedings{vaswani2017,
+  author = {Ashish Vaswani and Noam Shazeer},
+  title = {Attention Is All You Need},
+  booktitle = nips,
+  year = 2017
+}
+
+@article{zhang2020,
+  author = {张三 and 李四},
+  title = {基于 Transformer 的神经机器翻译},
+  journal = {计算机学报},
+  year = {2020},
+}
+
+@book{li2019,
+  author = {李明},
+  title = "深度学习",
+  publisher = {清华大学出版社},
+  year = {2019},
+  language = {chinese}
+}
+
+@misc{wang2021,
+  title = {{大模型}综述},
+  year = {2021}
+}
+`
+
+func TestDetectBibliographyStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		kind    string
+		backend string
+		files   string
+	}{
+		{"biblatex", "\\usepackage[style=authoryear]{biblatex}\n\\addbibresource{refs.bib}\n\\addbibresource{more.bib}", BibliographyBiblatex, "biber", "refs,more"},
+		{"biblatex bibtex backend", "\\usepackage[backend=bibtex8]{biblatex}\n\\bibliography{refs}", BibliographyBiblatex, "bibtex", "refs"},
+		{"bibtex", "\\usepackage{natbib}\n\\bibliographystyle{plainnat}\n\\bibliography{refs, other}", BibliographyBibTeX, "", "refs,other"},
+		{"thebibliography", "\\begin{thebibliography}{9}\\bibitem{a} A.\\end{thebibliography}", BibliographyThebibliography, "", ""},
+		{"commented", "% \\bibliography{refs}", BibliographyNone, "", ""},
+	}
+	for _, tt := range tests {
+		s := DetectBibliographyStrategy(tt.content)
+		if s.Kind != tt.kind || s.Backend != tt.backend || strings.Join(s.BibFiles, ",") != tt.files {
+			t.Errorf("%s: got %+v", tt.name, s)
+		}
+	}
+}
+
+func TestTagBibLanguagesBiblatex(t *testing.T) {
+	got, n := TagBibLanguages(mixedBib, BibliographyBiblatex)
+	if n != 2 {
+		t.Fatalf("tagged %d entries, want 2 (zhang2020, wang2021):\n%s", n, got)
+	}
+	zhang := got[strings.Index(got, "@article{zhang2020"):strings.Index(got, "@book{li2019")]
+	if !strings.Contains(zhang, "langid = {chinese}") || !strings.Contains(zhang, "sortkey = {z") {
+		t.Errorf("zhang2020 not tagged for biblatex:\n%s", zhang)
+	}
+	if !strings.Contains(got, "{大模型}综述},\n  year = {2021},\n  langid = {chinese}") {
+		t.Errorf("wang2021 not tagged:\n%s", got)
+	}
+	// English entries, @string blocks and entries with a language keep their text
+	for _, unchanged := range []string{
+		"@string{nips = \"Advances in Neural Information Processing Systems\"}",
+		"  booktitle = nips,\n  year = 2017\n}",
+		"  year = {2019},\n  language = {chinese}\n}",
+	} {
+		if !strings.Contains(got, unchanged) {
+			t.Errorf("result is missing %q", unchanged)
+		}
+	}
+
+	again, n := TagBibLanguages(got, BibliographyBiblatex)
+	if n != 0 || again != got {
+		t.Errorf("tagging again changed %d entries", n)
+	}
+}
+
+func TestTagBibLanguagesBibTeX(t *testing.T) {
+	got, n := TagBibLanguages(mixedBib, BibliographyBibTeX)
+	if n != 2 {
+		t.Fatalf("tagged %d entries, want 2:\n%s", n, got)
+	}
+	for _, want := range []string{
+		"author = {{\\noopsort{z",
+		"title = {{基于 Transformer 的神经机器翻译}}",
+		"  year = {2020},\n  language = {chinese}\n}",
+		"title = {{大模型}综述}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("result is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Vaswani}") || strings.Contains(got, "{{Attention") {
+		t.Errorf("English entry was changed:\n%s", got)
+	}
+}
+
+func TestTagBibliographyLanguages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "refs.bib"), []byte(mixedBib), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "english.bib"), []byte("@misc{a, title = {English}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	main := "\\documentclass{article}\n\\usepackage[backend=biber]{biblatex}\n\\addbibresource{refs.bib}\n\\addbibresource{english.bib}\n\\begin{document}\n\\printbibliography\n\\end{document}\n"
+	got, n := TagBibliographyLanguages(main, dir)
+	if n != 2 {
+		t.Fatalf("tagged %d entries, want 2", n)
+	}
+	for _, want := range []string{
+		"\\addbibresource{refs_zh.bib}",
+		"\\addbibresource{english.bib}",
+		"\\ExecuteBibliographyOptions{sortlocale=zh__pinyin}\n\\begin{document}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("main file is missing %q:\n%s", want, got)
+		}
+	}
+	tagged, err := os.ReadFile(filepath.Join(dir, "refs_zh.bib"))
+	if err != nil || !strings.Contains(string(tagged), "langid = {chinese}") {
+		t.Errorf("tagged copy: %v\n%s", err, tagged)
+	}
+	if original, _ := os.ReadFile(filepath.Join(dir, "refs.bib")); string(original) != mixedBib {
+		t.Error("original database was changed")
+	}
+
+	if again, n := TagBibliographyLanguages(got, dir); n != 0 || again != got {
+		t.Errorf("running again tagged %d entries", n)
+	}
+
+	bibtexMain := "\\documentclass{article}\n\\begin{document}\n\\bibliographystyle{plain}\n\\bibliography{refs,english}\n\\end{document}\n"
+	got, _ = TagBibliographyLanguages(bibtexMain, dir)
+	if !strings.Contains(got, "\\bibliography{refs_zh,english}") || !strings.Contains(got, "\\providecommand{\\noopsort}[1]{}\n\\begin{document}") {
+		t.Errorf("bibtex main file:\n%s", got)
+	}
+}
@@ -0,0 +1,484 @@
+package postprocess
+
+import (
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/compiler"
+	"latex-translator/internal/logger"
+	"latex-translator/internal/translator"
+	"latex-translator/internal/types"
+)
+
+// EnsureLanguagePackage loads the CJK support package of the target language: the Chinese
+// support of the engine the document is compiled with (see compiler.EnsureCJKSupport),
+// luatexja for Japanese (compiled with LuaLaTeX) and kotex for Korean. Other languages need
+// no extra package and are left unchanged.
+func EnsureLanguagePackage(content string, lang types.TargetLanguage, engine string) string {
+	switch {
+	case lang.IsChinese():
+		return compiler.EnsureCJKSupport(content, engine)
+	case lang == types.LanguageJapanese:
+		return compiler.EnsurePackage(content, "luatexja")
+	case lang == types.LanguageKorean:
+		return compiler.EnsurePackage(content, "kotex")
+	}
+	return content
+}
+
+// fixNestedTabularStructure fixes nested tabular structures that were incorrectly split across multiple lines.
+// This happens when the translator breaks \begin{tabular}...\end{tabular} into multiple lines,
+// which causes LaTeX compilation errors like "Missing \cr inserted".
+func fixNestedTabularStructure(content string) string {
+	// Pattern to match nested tabular that should be on single line
+	// e.g., \begin{tabular}[c]{@{}c@{}}...\end{tabular}}
+	pattern := regexp.MustCompile(`(\\begin\{tabular\}\[[^\]]+\]\{[^}]+\})([\s\S]*?)(\\end\{tabular\}\})`)
+
+	fixed := false
+	content = pattern.ReplaceAllStringFunc(content, func(match string) string {
+		// Check if the match spans multiple lines
+		if strings.Contains(match, "\n") {
+			// Merge into single line, replacing newlines with spaces
+			result := strings.ReplaceAll(match, "\n", " ")
+			result = strings.ReplaceAll(result, "\r", "")
+			// Clean up multiple spaces
+			for strings.Contains(result, "  ") {
+				result = strings.ReplaceAll(result, "  ", " ")
+			}
+			fixed = true
+			return result
+		}
+		return match
+	})
+
+	if fixed {
+		logger.Debug("fixed nested tabular structures that were split across lines")
+	}
+
+	// Fix standalone } on a line after \end{tabular} or \end{tabular}}
+	// Pattern: \end{tabular}\n}\n or \end{tabular}}\n}\n -> remove the extra }
+	extraBracePattern := regexp.MustCompile(`(\\end\{tabular\}\}?)\s*\n\}\s*\n`)
+	for extraBracePattern.MatchString(content) {
+		content = extraBracePattern.ReplaceAllString(content, "$1\n")
+		logger.Debug("removed extra closing braces after tabular")
+	}
+
+	// Fix \end{table without closing brace - this happens when LLM corrupts the structure
+	// Pattern: \end{table followed by space or backslash (not })
+	// e.g., \end{table \subsection -> \end{table} \subsection
+	incompleteEndTablePattern := regexp.MustCompile(`\\end\{table([^}*])`)
+	if incompleteEndTablePattern.MatchString(content) {
+		content = incompleteEndTablePattern.ReplaceAllString(content, "\\end{table}$1")
+		logger.Debug("fixed incomplete \\end{table} commands")
+	}
+
+	// Fix \end{tabular without closing brace
+	incompleteEndTabularPattern := regexp.MustCompile(`\\end\{tabular([^}*])`)
+	if incompleteEndTabularPattern.MatchString(content) {
+		content = incompleteEndTabularPattern.ReplaceAllString(content, "\\end{tabular}$1")
+		logger.Debug("fixed incomplete \\end{tabular} commands")
+	}
+
+	// Fix \multirow{ without proper closing - ensure nested tabular inside multirow is complete
+	// Pattern: \multirow{...}{\begin{tabular}...\end{tabular} (missing final })
+	// This is complex, so we use a simpler approach: ensure all \begin{tabular} have matching \end{tabular}}
+
+	return content
+}
+
+// traditionalChineseSetup selects traditional Chinese fonts (when installed) and caption names
+// for ctex. The fonts are tried in order; the ctex default is kept if none is available.
+const traditionalChineseSetup = `% Traditional Chinese fonts and names (auto-added by translator)
+\ifdefined\setCJKmainfont
+\IfFontExistsTF{Noto Serif CJK TC}{\setCJKmainfont{Noto Serif CJK TC}\setCJKsansfont{Noto Sans CJK TC}}{%
+\IfFontExistsTF{MingLiU}{\setCJKmainfont{MingLiU}\setCJKsansfont{Microsoft JhengHei}}{%
+\IfFontExistsTF{Microsoft JhengHei}{\setCJKmainfont{Microsoft JhengHei}\setCJKsansfont{Microsoft JhengHei}}{}}}
+\fi
+\ifdefined\ctexset
+\ctexset{contentsname={目錄},listfigurename={插圖},listtablename={表格},figurename={圖},tablename={表},abstractname={摘要},appendixname={附錄},refname={參考文獻},bibname={參考文獻},indexname={索引}}
+\fi`
+
+// applyChineseVariantFonts configures ctex for the script of the translation. Simplified
+// Chinese uses the ctex defaults; for traditional Chinese, fonts with traditional glyphs and
+// traditional caption names are set right after the ctex package.
+func applyChineseVariantFonts(content string, variant types.ChineseVariant) string {
+	if variant != types.ChineseTraditional || strings.Contains(content, "% Traditional Chinese fonts and names") {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "%") {
+			continue
+		}
+		if strings.Contains(line, "\\usepackage{ctex}") || (strings.Contains(line, "\\usepackage[") && strings.Contains(line, "ctex}")) {
+			lines = append(lines[:i+1], append([]string{traditionalChineseSetup}, lines[i+1:]...)...)
+			logger.Info("added traditional Chinese font setup")
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	logger.Warn("could not find the ctex package to add traditional Chinese fonts")
+	return content
+}
+
+// quickModeNotice is placed at the top of the first page of a quick-mode translation so it
+// is not mistaken for a full-quality translation
+const quickModeNotice = `% Quick mode notice (auto-added by translator)
+\noindent\fbox{\parbox{\dimexpr\linewidth-2\fboxsep-2\fboxrule\relax}{\textbf{快速模式译文}：本译文以快速模式生成，未经语法校验和完整的编译修复，交叉引用可能未解析，也没有双语对照版本，仅供快速阅读。需要完整质量的译文请在应用中选择“升级为完整翻译”。}}\par\medskip`
+
+// quickModeNoticeEnglish is the quick mode notice of translations into other languages
+// than Chinese, whose fonts may lack Chinese glyphs
+const quickModeNoticeEnglish = `% Quick mode notice (auto-added by translator)
+\noindent\fbox{\parbox{\dimexpr\linewidth-2\fboxsep-2\fboxrule\relax}{\textbf{Quick mode translation}: this translation was produced in quick mode without syntax validation or full compile fixes. Cross-references may be unresolved and there is no bilingual version. Choose \emph{Upgrade to full translation} in the app for a full-quality translation.}}\par\medskip`
+
+// addQuickModeNotice inserts quickModeNotice right after \begin{document}
+func addQuickModeNotice(content string, variant types.ChineseVariant, lang types.TargetLanguage) string {
+	if strings.Contains(content, "% Quick mode notice") {
+		return content
+	}
+	notice := quickModeNotice
+	if !lang.IsChinese() {
+		notice = quickModeNoticeEnglish
+	} else if variant == types.ChineseTraditional {
+		notice = translator.ConvertToTraditional(notice, nil)
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "%") {
+			continue
+		}
+		if strings.Contains(line, "\\begin{document}") {
+			lines = append(lines[:i+1], append([]string{notice}, lines[i+1:]...)...)
+			logger.Info("added quick mode notice")
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	logger.Warn("could not find \\begin{document} to add the quick mode notice")
+	return content
+}
+
+// fixDuplicateThebibliographyInPreamble removes thebibliography environment from preamble.
+// The thebibliography environment should NEVER be in the preamble (before \begin{document}).
+// This can happen when LLM incorrectly inserts .bbl content during translation.
+func fixDuplicateThebibliographyInPreamble(content string) string {
+	// Find \begin{document} position
+	beginDocIdx := strings.Index(content, `\begin{document}`)
+	if beginDocIdx == -1 {
+		logger.Debug("fixDuplicateThebibliographyInPreamble: no \\begin{document} found")
+		return content
+	}
+
+	preamble := content[:beginDocIdx]
+	body := content[beginDocIdx:]
+
+	// Check if thebibliography exists in preamble
+	preambleHasBib := strings.Contains(preamble, `\begin{thebibliography}`)
+
+	logger.Debug("fixDuplicateThebibliographyInPreamble: checking for thebibliography in preamble",
+		logger.Bool("preambleHasBib", preambleHasBib))
+
+	if !preambleHasBib {
+		// No thebibliography in preamble, return as is
+		return content
+	}
+
+	logger.Info("fixDuplicateThebibliographyInPreamble: found thebibliography in preamble, removing")
+
+	// Remove thebibliography environment from preamble
+	// Find the start and end of the thebibliography environment in preamble
+	bibStartIdx := strings.Index(preamble, `\begin{thebibliography}`)
+	if bibStartIdx == -1 {
+		return content
+	}
+
+	bibEndIdx := strings.Index(preamble[bibStartIdx:], `\end{thebibliography}`)
+	if bibEndIdx == -1 {
+		logger.Warn("fixDuplicateThebibliographyInPreamble: no \\end{thebibliography} found in preamble")
+		return content
+	}
+	bibEndIdx += bibStartIdx + len(`\end{thebibliography}`)
+
+	// Remove the thebibliography environment from preamble
+	// Also remove any trailing newlines
+	for bibEndIdx < len(preamble) && (preamble[bibEndIdx] == '\n' || preamble[bibEndIdx] == '\r') {
+		bibEndIdx++
+	}
+
+	newPreamble := preamble[:bibStartIdx] + preamble[bibEndIdx:]
+	logger.Info("removed thebibliography from preamble",
+		logger.Int("removedBytes", bibEndIdx-bibStartIdx))
+
+	return newPreamble + body
+}
+
+// fixSplitCommentLinesInPreamble fixes cases where LLM translation incorrectly splits
+// comment lines in the preamble, putting "%" on one line and the comment text on the next.
+// This is critical because uncommented text in the preamble causes "Missing \begin{document}" errors.
+//
+// Pattern detected:
+//
+//	Line N:   % (or "% ")
+//	Line N+1: comment text (without %)
+//
+// This function merges such split lines or comments the orphaned text.
+func fixSplitCommentLinesInPreamble(content string) string {
+	// Find \begin{document}
+	beginDocIdx := strings.Index(content, `\begin{document}`)
+	if beginDocIdx == -1 {
+		return content
+	}
+
+	preamble := content[:beginDocIdx]
+	body := content[beginDocIdx:]
+
+	lines := strings.Split(preamble, "\n")
+	fixed := false
+
+	// Common comment indicators that suggest a line should be commented
+	commentIndicators := []string{
+		"recommended", "optional", "packages", "figures", "typesetting",
+		"hyperref", "hyperlinks", "resulting", "build breaks",
+		"comment out", "following", "attempt", "algorithmic",
+		"initial blind", "submitted", "review", "preprint",
+		"accepted", "camera-ready", "submission", "setting:",
+		"above.", "better", "work together", "for the",
+		"use the", "instead use", "if accepted", "for preprint",
+		"spans a page", "please comment", "use the following",
+		"with \\usepackage", "nohyperref",
+	}
+
+	// Process lines to fix split comments
+	for i := 0; i < len(lines)-1; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+
+		// Check if this line is just "%" or "% " (isolated percent sign)
+		if trimmed == "%" || trimmed == "% " {
+			// Check the next line
+			nextLine := lines[i+1]
+			nextTrimmed := strings.TrimSpace(nextLine)
+
+			// Skip if next line is empty or already commented
+			if nextTrimmed == "" || strings.HasPrefix(nextTrimmed, "%") {
+				continue
+			}
+
+			// Check if next line looks like comment text
+			lowerNext := strings.ToLower(nextTrimmed)
+			shouldComment := false
+
+			for _, indicator := range commentIndicators {
+				if strings.Contains(lowerNext, indicator) {
+					shouldComment = true
+					break
+				}
+			}
+
+			// Special case: line starts with a LaTeX command but contains comment-like text
+			// e.g., "\usepackage{icml2026} with \usepackage[nohyperref]{icml2026} above."
+			// This should be fully commented
+			if strings.HasPrefix(nextTrimmed, "\\") {
+				// Check if it contains comment indicators
+				for _, indicator := range commentIndicators {
+					if strings.Contains(lowerNext, indicator) {
+						shouldComment = true
+						break
+					}
+				}
+			}
+
+			// Also check if the line doesn't look like valid LaTeX
+			// (no backslash commands, no braces at start)
+			if !shouldComment && !strings.HasPrefix(nextTrimmed, "\\") {
+				if !strings.ContainsAny(nextTrimmed[:min(10, len(nextTrimmed))], "\\{}$") {
+					// Line starts with regular text - likely a comment
+					if len(nextTrimmed) > 5 {
+						shouldComment = true
+					}
+				}
+			}
+
+			if shouldComment {
+				// Comment the next line
+				leadingWhitespace := nextLine[:len(nextLine)-len(strings.TrimLeft(nextLine, " \t"))]
+				lines[i+1] = leadingWhitespace + "% " + nextTrimmed
+				fixed = true
+				logger.Info("fixSplitCommentLinesInPreamble: commented orphaned text",
+					logger.Int("lineNum", i+1),
+					logger.String("text", nextTrimmed[:min(50, len(nextTrimmed))]))
+			}
+		}
+	}
+
+	if fixed {
+		return strings.Join(lines, "\n") + body
+	}
+
+	return content
+}
+
+// fixMergedCommentLinesInPreamble fixes cases where LLM translation incorrectly merges
+// comment text with the next line in the preamble.
+// This is critical because it can cause LaTeX commands to be commented out or syntax errors.
+//
+// Pattern detected:
+//
+//	Original: "% comment text\n\usepackage{pkg}"
+//	Broken:   "% comment text\usepackage{pkg}" (merged on same line)
+//
+// This function splits such merged lines back into separate lines by comparing with original.
+func fixMergedCommentLinesInPreamble(content, original string) string {
+	if original == "" {
+		return content
+	}
+
+	// Find \begin{document}
+	beginDocIdx := strings.Index(content, `\begin{document}`)
+	if beginDocIdx == -1 {
+		return content
+	}
+
+	origBeginDocIdx := strings.Index(original, `\begin{document}`)
+	if origBeginDocIdx == -1 {
+		return content
+	}
+
+	preamble := content[:beginDocIdx]
+	body := content[beginDocIdx:]
+	origPreamble := original[:origBeginDocIdx]
+
+	lines := strings.Split(preamble, "\n")
+	origLines := strings.Split(origPreamble, "\n")
+	fixed := false
+
+	// Build a set of lines from original that are pure comment lines (not commenting out code)
+	// A pure comment line is one where the text after % doesn't start with a LaTeX command
+	origPureCommentLines := make(map[string]bool)
+	for _, line := range origLines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "%%") {
+			// Check if this is a pure comment (not commenting out a command)
+			afterPercent := strings.TrimSpace(strings.TrimPrefix(trimmed, "%"))
+			if afterPercent != "" && !strings.HasPrefix(afterPercent, "\\") {
+				// This is a pure comment line (text, not a commented-out command)
+				origPureCommentLines[trimmed] = true
+			}
+		}
+	}
+
+	// Build a set of uncommented lines from original (lines that should NOT be commented)
+	origUncommentedLines := make(map[string]bool)
+	for _, line := range origLines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "%") {
+			origUncommentedLines[trimmed] = true
+		}
+	}
+
+	// Now check each line in the translated preamble
+	var newLines []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		leadingWhitespace := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		// Case 1: Check for non-comment lines that have comment text merged with a command
+		// Pattern: "above.\usepackage{...}" or "following:\newcommand{...}"
+		// These are cases where comment continuation text lost its % and got merged with the next line
+		if !strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "\\") && len(trimmed) > 5 {
+			// Look for text followed by a LaTeX command
+			textCmdPattern := regexp.MustCompile(`^([^\\]+)(\\[a-zA-Z]+.*)$`)
+			if matches := textCmdPattern.FindStringSubmatch(trimmed); len(matches) == 3 {
+				textPart := strings.TrimSpace(matches[1])
+				commandPart := strings.TrimSpace(matches[2])
+
+				// Check if the text part looks like comment continuation
+				// (ends with punctuation or common comment words)
+				lowerText := strings.ToLower(textPart)
+				isCommentContinuation := false
+
+				// Check for common comment endings
+				commentEndings := []string{":", ".", ",", "above", "below", "following", "use", "instead"}
+				for _, ending := range commentEndings {
+					if strings.HasSuffix(lowerText, ending) {
+						isCommentContinuation = true
+						break
+					}
+				}
+
+				// Also check if the command part exists as an uncommented line in original
+				commandExistsInOrig := false
+				for origLine := range origUncommentedLines {
+					if strings.HasPrefix(origLine, commandPart) || strings.HasPrefix(commandPart, origLine) {
+						commandExistsInOrig = true
+						break
+					}
+				}
+
+				if isCommentContinuation && commandExistsInOrig {
+					// Split: comment the text part, keep command uncommented
+					newLines = append(newLines, leadingWhitespace+"% "+textPart)
+					newLines = append(newLines, leadingWhitespace+commandPart)
+					fixed = true
+					logger.Info("fixMergedCommentLinesInPreamble: split and commented merged line",
+						logger.String("text", textPart),
+						logger.String("command", commandPart))
+					continue
+				}
+			}
+		}
+
+		// Case 2: Check for comment lines where comment text is merged with a command
+		// Pattern: "% comment text\usepackage{...}"
+		// But NOT: "% \usepackage{...}" (this is intentionally commented out)
+		if strings.HasPrefix(trimmed, "%") && !strings.HasPrefix(trimmed, "%%") {
+			afterPercent := strings.TrimSpace(strings.TrimPrefix(trimmed, "%"))
+
+			// Skip if this is a commented-out command (starts with \)
+			if strings.HasPrefix(afterPercent, "\\") {
+				newLines = append(newLines, line)
+				continue
+			}
+
+			// Look for pattern: "text \command" or "text\command"
+			// The text part should have at least 3 characters of actual text
+			mergedPattern := regexp.MustCompile(`^(.{3,}?)(\\[a-zA-Z]+.*)$`)
+			if matches := mergedPattern.FindStringSubmatch(afterPercent); len(matches) == 3 {
+				textPart := strings.TrimSpace(matches[1])
+				commandPart := strings.TrimSpace(matches[2])
+
+				// Verify this is a real merge by checking:
+				// 1. The text part looks like comment text (not just whitespace or symbols)
+				// 2. The command part exists as an uncommented line in original
+				hasLetters := regexp.MustCompile(`[a-zA-Z]{2,}`).MatchString(textPart)
+				commandExistsInOrig := false
+				for origLine := range origUncommentedLines {
+					if strings.HasPrefix(origLine, commandPart) || strings.HasPrefix(commandPart, origLine) {
+						commandExistsInOrig = true
+						break
+					}
+				}
+
+				if hasLetters && commandExistsInOrig {
+					// Split the line: keep comment part as comment, uncomment the command
+					newLines = append(newLines, leadingWhitespace+"% "+textPart)
+					newLines = append(newLines, leadingWhitespace+commandPart)
+					fixed = true
+					logger.Info("fixMergedCommentLinesInPreamble: split merged comment line",
+						logger.String("comment", textPart),
+						logger.String("command", commandPart))
+					continue
+				}
+			}
+		}
+
+		newLines = append(newLines, line)
+	}
+
+	if fixed {
+		return strings.Join(newLines, "\n") + body
+	}
+
+	return content
+}
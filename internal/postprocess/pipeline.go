@@ -0,0 +1,168 @@
+// Package postprocess is the canonical post-processing pipeline for translated LaTeX files.
+//
+// Every path that turns a translation into a tex file to compile (the GUI and CLI in
+// app.go, the batch tool and the test commands) runs the same ordered list of passes, so
+// the same translation yields byte-identical tex no matter which path produced it. The
+// order of the passes matters; the constraints are documented on the pass list.
+package postprocess
+
+import (
+	"fmt"
+
+	"latex-translator/internal/compiler"
+	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
+	"latex-translator/internal/translator"
+	"latex-translator/internal/types"
+)
+
+// Options are the job settings that change the post-processing output
+type Options struct {
+	Variant   types.ChineseVariant // script of the translation; empty means simplified
+	Language  types.TargetLanguage // language of the translation; empty means Chinese
+	QuickMode bool                 // mark the main file as a quick-mode translation
+	Engine    string               // engine the main file is compiled with; empty means XeLaTeX
+}
+
+// File is a translated file to post-process
+type File struct {
+	Content  string // translated content
+	Original string // original source of the file; empty when unavailable
+	Main     bool   // the main file of the document (gets the preamble setup)
+}
+
+// Pass is one named step of the pipeline. Version is increased whenever a change to the
+// pass can change its output, so reports tell which pipeline produced a tex file.
+type Pass struct {
+	Name     string
+	Version  int
+	MainOnly bool // applied to the main file only
+	Apply    func(f File, opts Options) string
+}
+
+// ID returns the name and version of the pass, e.g. "ctex-package@1"
+func (p Pass) ID() string {
+	return fmt.Sprintf("%s@%d", p.Name, p.Version)
+}
+
+// passes is the pipeline, in order. ctex-package loads the CJK support package of the
+// target language (for Chinese the one of the engine: ctex, xeCJK or luatexja-fontspec;
+// luatexja or kotex; none for other languages); variant-fonts only applies to Chinese
+// translations. Ordering constraints:
+//   - variant-fonts inserts its setup right after the ctex line, so it runs after
+//     ctex-package.
+//   - nested-tabular used to be the last step of ctex-package and still runs right after
+//     it, on the main file only.
+//   - reference-fixes (QuickFixWithReference) runs before the other passes for all files:
+//     they repair what it can leave behind.
+//   - label-placement runs after reference-fixes, which can move environment boundaries.
+//   - tabular-column-spec runs after reference-fixes, which can remove the closing braces
+//     it adds.
+//   - split-preamble-comments runs after reference-fixes, which can reintroduce comment
+//     lines split by the model.
+//   - merged-preamble-comments runs after split-preamble-comments.
+//   - unicode-declarations runs after reference-fixes, so quotes it replaces around Chinese
+//     text are not restored from the original.
+//
+// All passes are idempotent, so the pipeline can be run again after a later step (such
+// as the LLM syntax fix) changed a file. Verbatim-like environments (parser.VerbatimRanges)
+// are masked in the content and the original while the passes run: code listings keep
+// unbalanced braces and quotes no pass must repair.
+var passes = []Pass{
+	{Name: "ctex-package", Version: 5, MainOnly: true, Apply: func(f File, opts Options) string {
+		return EnsureLanguagePackage(f.Content, opts.Language, opts.Engine)
+	}},
+	{Name: "nested-tabular", Version: 2, MainOnly: true, Apply: func(f File, _ Options) string {
+		return fixNestedTabularStructure(f.Content)
+	}},
+	{Name: "variant-fonts", Version: 2, MainOnly: true, Apply: func(f File, opts Options) string {
+		if !opts.Language.IsChinese() {
+			return f.Content
+		}
+		return applyChineseVariantFonts(f.Content, opts.Variant)
+	}},
+	{Name: "quick-mode-notice", Version: 2, MainOnly: true, Apply: func(f File, opts Options) string {
+		if !opts.QuickMode {
+			return f.Content
+		}
+		return addQuickModeNotice(f.Content, opts.Variant, opts.Language)
+	}},
+	{Name: "reference-fixes", Version: 2, Apply: func(f File, _ Options) string {
+		fixed, _ := compiler.QuickFixWithReference(f.Content, f.Original)
+		return fixed
+	}},
+	{Name: "label-placement", Version: 2, Apply: func(f File, _ Options) string {
+		if f.Original == "" {
+			return f.Content
+		}
+		fixed, repairs := translator.RepairLabelPlacement(f.Content, f.Original)
+		for _, r := range repairs {
+			logger.Info("moved label back to its original enclosure",
+				logger.String("label", r.Label),
+				logger.String("from", r.From),
+				logger.String("to", r.To))
+		}
+		return fixed
+	}},
+	{Name: "preamble-bibliography", Version: 1, Apply: func(f File, _ Options) string {
+		return fixDuplicateThebibliographyInPreamble(f.Content)
+	}},
+	{Name: "tabular-column-spec", Version: 2, Apply: func(f File, _ Options) string {
+		return translator.FixIncompleteTabularColumnSpec(f.Content)
+	}},
+	{Name: "split-preamble-comments", Version: 1, Apply: func(f File, _ Options) string {
+		return fixSplitCommentLinesInPreamble(f.Content)
+	}},
+	{Name: "merged-preamble-comments", Version: 1, Apply: func(f File, _ Options) string {
+		return fixMergedCommentLinesInPreamble(f.Content, f.Original)
+	}},
+	{Name: "unicode-declarations", Version: 2, Apply: func(f File, _ Options) string {
+		content := f.Content
+		if fixed, changed := compiler.NeutralizeUnicodeDeclarations(content); changed {
+			logger.Info("adapted \\DeclareUnicodeCharacter declarations for XeLaTeX")
+			content = fixed
+		}
+		if compiler.HasActiveQuote(content) {
+			if fixed, changed := compiler.ReplaceQuotedChinese(content); changed {
+				logger.Info("replaced ASCII quotes around Chinese text where \" is active")
+				content = fixed
+			}
+		}
+		return content
+	}},
+}
+
+// Passes returns the passes of the pipeline in order
+func Passes() []Pass {
+	return append([]Pass(nil), passes...)
+}
+
+// Describe returns the IDs of the passes in order, for job reports
+func Describe() []string {
+	ids := make([]string, len(passes))
+	for i, p := range passes {
+		ids[i] = p.ID()
+	}
+	return ids
+}
+
+// Run applies the pipeline to a translated file and returns the content to save
+func Run(f File, opts Options) string {
+	var restore func(string) string
+	f.Content, restore = parser.ProtectVerbatim(f.Content)
+	f.Original, _ = parser.ProtectVerbatim(f.Original)
+
+	for _, p := range passes {
+		if p.MainOnly && !f.Main {
+			continue
+		}
+		before := f.Content
+		f.Content = p.Apply(f, opts)
+		if f.Content != before {
+			logger.Debug("post-processing pass changed content",
+				logger.String("pass", p.ID()),
+				logger.Bool("main", f.Main))
+		}
+	}
+	return restore(f.Content)
+}
@@ -0,0 +1,314 @@
+package postprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"latex-translator/internal/compiler"
+	"latex-translator/internal/types"
+)
+
+const sampleOriginal = `\documentclass{article}
+\usepackage{microtype}
+% Use the following for preprint:
+\usepackage{hyperref}
+\begin{document}
+\section{Results}
+\begin{table}
+\begin{tabular}{cc}
+\begin{tabular}[c]{@{}c@{}}Top\\ Bottom\end{tabular}} & Value \\
+\end{tabular}
+\end{table}
+\end{document}
+`
+
+// sampleTranslated has the defects the passes repair: a comment split by the model, a
+// thebibliography copied into the preamble and a nested tabular split across lines
+const sampleTranslated = `\documentclass{article}
+\usepackage{microtype}
+%
+Use the following for preprint:
+\usepackage{hyperref}
+\begin{thebibliography}{1}
+\bibitem{a} A.
+\end{thebibliography}
+\begin{document}
+\section{结果}
+\begin{table}
+\begin{tabular}{cc}
+\begin{tabular}[c]{@{}c@{}}上
+\\ 下\end{tabular}} & 值 \\
+\end{tabular}
+\end{table}
+\end{document}
+`
+
+func TestPassOrder(t *testing.T) {
+	want := []string{
+		"ctex-package@5",
+		"nested-tabular@2",
+		"variant-fonts@2",
+		"quick-mode-notice@2",
+		"reference-fixes@2",
+		"label-placement@2",
+		"preamble-bibliography@1",
+		"tabular-column-spec@2",
+		"split-preamble-comments@1",
+		"merged-preamble-comments@1",
+		"unicode-declarations@2",
+	}
+	got := Describe()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Describe() = %v, want %v", got, want)
+	}
+}
+
+func TestPassesReturnsACopy(t *testing.T) {
+	p := Passes()
+	p[0].Name = "changed"
+	if Describe()[0] != "ctex-package@5" {
+		t.Error("modifying the result of Passes() changed the pipeline")
+	}
+}
+
+func TestEnsureLanguagePackage(t *testing.T) {
+	content := "% \\documentclass{book}\n\\documentclass{article}\n\\begin{document}"
+	tests := []struct {
+		lang types.TargetLanguage
+		want string
+	}{
+		{types.LanguageJapanese, "\\documentclass{article}\n\\usepackage{luatexja}\n\\begin{document}"},
+		{types.LanguageKorean, "\\documentclass{article}\n\\usepackage{kotex}\n\\begin{document}"},
+		{types.LanguageChinese, "\\documentclass{article}\n\\usepackage{ctex}\n\\begin{document}"},
+	}
+	for _, tt := range tests {
+		got := EnsureLanguagePackage(content, tt.lang, "")
+		if !strings.HasSuffix(got, tt.want) {
+			t.Errorf("EnsureLanguagePackage(%s) =\n%s", tt.lang, got)
+		}
+		if again := EnsureLanguagePackage(got, tt.lang, ""); again != got {
+			t.Errorf("EnsureLanguagePackage(%s) added the package twice:\n%s", tt.lang, again)
+		}
+	}
+	if got := EnsureLanguagePackage(content, types.LanguageGerman, ""); got != content {
+		t.Errorf("German translation got a CJK package:\n%s", got)
+	}
+	if got := EnsureLanguagePackage("\\documentclass{article}\n\\usepackage{luatexja-fontspec}\n", types.LanguageJapanese, ""); strings.Contains(got, "{luatexja}") {
+		t.Errorf("luatexja added next to luatexja-fontspec:\n%s", got)
+	}
+}
+
+func TestRunSkipsChineseSetupForOtherLanguages(t *testing.T) {
+	got := Run(File{Content: sampleTranslated, Original: sampleOriginal, Main: true}, Options{Language: types.LanguageRussian, Variant: types.ChineseTraditional, QuickMode: true})
+	for _, unwanted := range []string{"{ctex}", "luatexja", "Traditional Chinese fonts", "快速模式"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Russian translation contains %q:\n%s", unwanted, got)
+		}
+	}
+	if !strings.Contains(got, "Quick mode translation") {
+		t.Errorf("quick mode notice missing:\n%s", got)
+	}
+}
+
+func TestFixNestedTabularStructure(t *testing.T) {
+	got := fixNestedTabularStructure("\\begin{tabular}[c]{@{}c@{}}A\n\\\\ B\\end{tabular}} & C\n\\end{table \\section{X}")
+	want := "\\begin{tabular}[c]{@{}c@{}}A \\\\ B\\end{tabular}} & C\n\\end{table} \\section{X}"
+	if got != want {
+		t.Errorf("fixNestedTabularStructure() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyChineseVariantFonts(t *testing.T) {
+	content := "\\documentclass{article}\n\\usepackage{ctex}\n\\begin{document}"
+	if got := applyChineseVariantFonts(content, types.ChineseSimplified); got != content {
+		t.Errorf("simplified Chinese changed the document:\n%s", got)
+	}
+	got := applyChineseVariantFonts(content, types.ChineseTraditional)
+	if !strings.Contains(got, "\\usepackage{ctex}\n"+traditionalChineseSetup+"\n\\begin{document}") {
+		t.Errorf("traditional setup not placed after ctex:\n%s", got)
+	}
+}
+
+func TestAddQuickModeNotice(t *testing.T) {
+	got := addQuickModeNotice("\\begin{document}\nText", types.ChineseSimplified, types.LanguageChinese)
+	if got != "\\begin{document}\n"+quickModeNotice+"\nText" {
+		t.Errorf("addQuickModeNotice() =\n%s", got)
+	}
+	traditional := addQuickModeNotice("\\begin{document}\nText", types.ChineseTraditional, types.LanguageChinese)
+	if !strings.Contains(traditional, "快速模式譯文") {
+		t.Errorf("notice not converted to traditional Chinese:\n%s", traditional)
+	}
+}
+
+func TestFixDuplicateThebibliographyInPreamble(t *testing.T) {
+	content := "\\usepackage{x}\n\\begin{thebibliography}{1}\n\\bibitem{a} A.\n\\end{thebibliography}\n\\begin{document}\n\\begin{thebibliography}{1}\n\\end{thebibliography}"
+	want := "\\usepackage{x}\n\\begin{document}\n\\begin{thebibliography}{1}\n\\end{thebibliography}"
+	if got := fixDuplicateThebibliographyInPreamble(content); got != want {
+		t.Errorf("fixDuplicateThebibliographyInPreamble() = %q, want %q", got, want)
+	}
+}
+
+func TestFixSplitCommentLinesInPreamble(t *testing.T) {
+	content := "%\nUse the following for preprint:\n\\usepackage{x}\n\\begin{document}\n%\nBody text here"
+	want := "%\n% Use the following for preprint:\n\\usepackage{x}\n\\begin{document}\n%\nBody text here"
+	if got := fixSplitCommentLinesInPreamble(content); got != want {
+		t.Errorf("fixSplitCommentLinesInPreamble() = %q, want %q", got, want)
+	}
+}
+
+func TestFixMergedCommentLinesInPreamble(t *testing.T) {
+	original := "% Use the following:\n\\usepackage{hyperref}\n\\begin{document}"
+	content := "% Use the following:\\usepackage{hyperref}\n\\begin{document}"
+	want := "% Use the following:\n\\usepackage{hyperref}\n\\begin{document}"
+	if got := fixMergedCommentLinesInPreamble(content, original); got != want {
+		t.Errorf("fixMergedCommentLinesInPreamble() = %q, want %q", got, want)
+	}
+	if got := fixMergedCommentLinesInPreamble(content, ""); got != content {
+		t.Errorf("changed content without an original: %q", got)
+	}
+}
+
+func TestRunRepairsSampleDocument(t *testing.T) {
+	got := Run(File{Content: sampleTranslated, Original: sampleOriginal, Main: true}, Options{})
+
+	for _, want := range []string{
+		"\\documentclass{article}\n\\usepackage{ctex}\n",
+		"[protrusion=false,expansion=false]{microtype}",
+		"% Use the following for preprint:",
+		"\\begin{tabular}[c]{@{}c@{}}上 \\\\ 下\\end{tabular} & 值",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %q:\n%s", want, got)
+		}
+	}
+	preamble := got[:strings.Index(got, "\\begin{document}")]
+	if strings.Contains(preamble, "luatexja") {
+		t.Errorf("LuaLaTeX Chinese support added for XeLaTeX:\n%s", got)
+	}
+	if strings.Contains(preamble, "thebibliography") {
+		t.Errorf("thebibliography left in the preamble:\n%s", got)
+	}
+}
+
+func TestRunIsDeterministicAndIdempotent(t *testing.T) {
+	opts := Options{Variant: types.ChineseTraditional, QuickMode: true}
+	f := File{Content: sampleTranslated, Original: sampleOriginal, Main: true}
+	first := Run(f, opts)
+	if second := Run(f, opts); second != first {
+		t.Fatalf("two runs with the same input differ:\n%s\n---\n%s", first, second)
+	}
+
+	f.Content = first
+	if again := Run(f, opts); again != first {
+		t.Errorf("running the pipeline again changed the output:\n%s\n---\n%s", first, again)
+	}
+}
+
+func TestRunSkipsMainOnlyPassesForInputFiles(t *testing.T) {
+	// Only ctex-package would change this file
+	content := "\\documentclass{article}\n\\usepackage{microtype}\n\\section{结果}\n"
+	if got := Run(File{Content: content}, Options{QuickMode: true}); got != content {
+		t.Errorf("input file changed by main-only passes:\n%s", got)
+	}
+	if got := Run(File{Content: content, Main: true}, Options{}); !strings.Contains(got, "\\usepackage{ctex}") {
+		t.Errorf("main file did not get the ctex package:\n%s", got)
+	}
+}
+
+// readFixture reads a translated document and its original from testdata
+func readFixture(t *testing.T, name string) File {
+	t.Helper()
+	original, err := os.ReadFile(filepath.Join("testdata", name+"_original.tex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	translated, err := os.ReadFile(filepath.Join("testdata", name+"_translated.tex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return File{Content: string(translated), Original: string(original), Main: true}
+}
+
+func TestRunAdaptsUnicodeDeclarations(t *testing.T) {
+	f := readFixture(t, "declare_unicode")
+	got := Run(f, Options{})
+
+	for _, want := range []string{
+		"\\usepackage{newunicodechar}\n\\newunicodechar{−}{\\ensuremath{-}}",
+		"\\newunicodechar{≈}{\\ensuremath{\\approx}}",
+		"% \\DeclareUnicodeCharacter{201C}{``} \\DeclareUnicodeCharacter{201D}{''} % not needed with XeLaTeX",
+		"% \\DeclareUnicodeCharacter{2014}{---} % not needed with XeLaTeX",
+		"% \\DeclareUnicodeCharacter{200B}{} % not needed with XeLaTeX",
+		"%\\DeclareUnicodeCharacter{00A0}{~}",
+		"正如“预期”的那样",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %q:\n%s", want, got)
+		}
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if strings.HasPrefix(line, "\\DeclareUnicodeCharacter") {
+			t.Errorf("declaration left active: %s", line)
+		}
+	}
+	if again := Run(File{Content: got, Original: f.Original, Main: true}, Options{}); again != got {
+		t.Errorf("running the pipeline again changed the output:\n%s\n---\n%s", got, again)
+	}
+}
+
+func TestRunReplacesQuotesAroundChineseWhereQuoteIsActive(t *testing.T) {
+	f := readFixture(t, "active_quotes")
+	got := Run(f, Options{})
+	if !strings.Contains(got, "我们称这一步为“对齐”，其结果称为“草稿”。") {
+		t.Errorf("quotes around Chinese text not replaced:\n%s", got)
+	}
+	if !strings.Contains(got, "% 原文使用 \"alignment\" 一词") {
+		t.Errorf("comment changed:\n%s", got)
+	}
+
+	// Without an active " the quotes are left to the other passes
+	plain := strings.Replace(f.Content, "\\usepackage[ngerman,english]{babel}\n", "", 1)
+	if got := Run(File{Content: plain, Main: true}, Options{}); !strings.Contains(got, "\"对齐\"") {
+		t.Errorf("quotes replaced although \" is not active:\n%s", got)
+	}
+}
+
+func TestApplyLogFixRules(t *testing.T) {
+	f := readFixture(t, "declare_unicode")
+	log := "! Package inputenc Error: inputenc is not designed for xetex or luatex.\n"
+	fixed, applied := compiler.ApplyLogFixRules(f.Content, log)
+	if strings.Join(applied, ",") != "unicode-declarations" {
+		t.Errorf("applied rules = %v, want [unicode-declarations]", applied)
+	}
+	if !strings.Contains(fixed, "\\newunicodechar{≈}") {
+		t.Errorf("declarations not adapted:\n%s", fixed)
+	}
+	if _, applied := compiler.ApplyLogFixRules(f.Content, "! Undefined control sequence.\n"); len(applied) != 0 {
+		t.Errorf("rules applied without their log signature: %v", applied)
+	}
+
+	quotes := readFixture(t, "active_quotes")
+	log = "! Argument of \\language@active@arg\" has an extra }.\n"
+	if _, applied := compiler.ApplyLogFixRules(quotes.Content, log); strings.Join(applied, ",") != "active-quotes" {
+		t.Errorf("applied rules = %v, want [active-quotes]", applied)
+	}
+}
+
+func TestRunLeavesListingsUntouched(t *testing.T) {
+	listing := "\\begin{lstlisting}\nif (c == '{') { depth++;\nprintf(\"引号\");\n\\end{lstlisting}"
+	translated := strings.Replace(sampleTranslated, "\\end{document}", listing+"\n\\end{document}", 1)
+	original := strings.Replace(sampleOriginal, "\\end{document}", listing+"\n\\end{document}", 1)
+	got := Run(File{Content: translated, Original: original, Main: true}, Options{Language: types.LanguageChinese})
+	if !strings.Contains(got, listing+"\n\\end{document}") {
+		t.Errorf("listing changed by the pipeline:\n%s", got)
+	}
+}
+
+func TestRunWithLuaLaTeX(t *testing.T) {
+	got := Run(File{Content: sampleTranslated, Original: sampleOriginal, Main: true}, Options{Engine: compiler.CompilerLuaLaTeX})
+	if !strings.Contains(got, "\\usepackage{luatexja-fontspec}") || strings.Contains(got, "\\usepackage{ctex}") {
+		t.Errorf("LuaLaTeX Chinese support not used:\n%s", got)
+	}
+}
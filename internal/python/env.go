@@ -14,6 +14,8 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+
+	"latex-translator/internal/netproxy"
 )
 
 // AppDataDirName is the directory name used in user's home directory
@@ -209,7 +211,7 @@ func (e *Env) getUvDownloadURL() string {
 
 // downloadFile downloads a file from URL to the specified directory
 func (e *Env) downloadFile(url, destDir string) (string, error) {
-	resp, err := http.Get(url)
+	resp, err := netproxy.NewClient(0).Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,164 @@
+package qa
+
+import (
+	"fmt"
+	"strings"
+
+	"latex-translator/internal/compiler"
+	"latex-translator/internal/pdf"
+)
+
+// FormatText returns the full human-readable report
+func FormatText(r *Report) string {
+	var b strings.Builder
+	b.WriteString("=== 翻译质量报告 ===\n")
+	if r.Source != "" {
+		fmt.Fprintf(&b, "来源: %s\n", r.Source)
+	}
+	fmt.Fprintf(&b, "生成时间: %s\n", r.GeneratedAt)
+
+	if len(r.Structure) > 0 {
+		b.WriteString("\n--- 结构对比 ---\n")
+		writeCounts(&b, r.Structure)
+		fmt.Fprintf(&b, "行数: 原文 %d，译文 %d (%.2f)\n", r.OriginalLines, r.TranslatedLines, r.LineRatio)
+		fmt.Fprintf(&b, "中文字符占比: %.1f%%\n", r.ChineseRatio*100)
+	}
+	if len(r.Environments) > 0 {
+		b.WriteString("\n--- 环境平衡 ---\n")
+		fmt.Fprintf(&b, "%-20s %13s %13s\n", "环境", "原文 begin/end", "译文 begin/end")
+		for _, env := range r.Environments {
+			mark := ""
+			if !env.Balanced {
+				mark = "  ✗ 不平衡"
+			}
+			fmt.Fprintf(&b, "%-20s %6d/%-6d %6d/%-6d%s\n", env.Name, env.OriginalBegin, env.OriginalEnd, env.TranslatedBegin, env.TranslatedEnd, mark)
+		}
+	}
+	if len(r.CommentedEnvironments) > 0 {
+		b.WriteString("\n--- 注释掉的环境 ---\n")
+		writeCounts(&b, r.CommentedEnvironments)
+	}
+	if len(r.OriginalSections) > 0 || len(r.TranslatedSections) > 0 {
+		b.WriteString("\n--- 章节标题 ---\n")
+		for i := 0; i < max(len(r.OriginalSections), len(r.TranslatedSections)); i++ {
+			var orig, trans string
+			if i < len(r.OriginalSections) {
+				orig = r.OriginalSections[i]
+			}
+			if i < len(r.TranslatedSections) {
+				trans = r.TranslatedSections[i]
+			}
+			fmt.Fprintf(&b, "%2d. %s → %s\n", i+1, orig, trans)
+		}
+	}
+	if len(r.MissingLabels) > 0 {
+		fmt.Fprintf(&b, "\n译文缺少的标签 (%d):\n", len(r.MissingLabels))
+		for _, label := range r.MissingLabels {
+			fmt.Fprintf(&b, "  - %s\n", label)
+		}
+	}
+	if r.Translation != nil {
+		b.WriteString("\n--- 翻译过程 ---\n")
+		b.WriteString(formatTranslation(r.Translation) + "\n")
+	}
+	if r.Fixes != nil {
+		b.WriteString("\n--- 编译修复 ---\n")
+		b.WriteString(formatFixes(r.Fixes) + "\n")
+		for _, entry := range r.Fixes.History {
+			fmt.Fprintf(&b, "  - %s\n", entry)
+		}
+	}
+	if r.IncludeOnly != nil {
+		b.WriteString("\n--- \\includeonly ---\n")
+		b.WriteString(compiler.FormatIncludeOnly(r.IncludeOnly) + "\n")
+	}
+	if r.Content != nil {
+		b.WriteString("\n--- PDF 内容 ---\n")
+		b.WriteString(pdf.FormatValidationResult(r.Content) + "\n")
+	} else if r.Pages != nil {
+		b.WriteString("\n--- 页数 ---\n")
+		b.WriteString(formatPages(r.Pages) + "\n")
+	}
+
+	b.WriteString("\n--- 问题 ---\n")
+	if len(r.Issues) == 0 {
+		b.WriteString("未发现问题\n")
+	}
+	for _, issue := range r.Issues {
+		fmt.Fprintf(&b, "  ⚠ %s\n", issue)
+	}
+	return b.String()
+}
+
+// FormatSummary returns the condensed table the CLI prints after a translation
+func FormatSummary(r *Report) string {
+	var b strings.Builder
+	b.WriteString("翻译质量:\n")
+	fmt.Fprintf(&b, "  %-14s %6s %6s %6s\n", "", "原文", "译文", "差异")
+	for _, count := range r.Structure {
+		if count.Original == 0 && count.Translated == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  %-14s %6d %6d %6s\n", count.Name, count.Original, count.Translated, formatDiff(count.Diff))
+	}
+	unbalanced := 0
+	for _, env := range r.Environments {
+		if !env.Balanced {
+			unbalanced++
+		}
+	}
+	fmt.Fprintf(&b, "  中文字符 %.0f%%，行数比 %.2f，不平衡环境 %d 个\n", r.ChineseRatio*100, r.LineRatio, unbalanced)
+	if r.Translation != nil {
+		b.WriteString("  " + formatTranslation(r.Translation) + "\n")
+	}
+	if r.Fixes != nil {
+		b.WriteString("  " + formatFixes(r.Fixes) + "\n")
+	}
+	if r.Pages != nil {
+		b.WriteString("  " + formatPages(r.Pages) + "\n")
+	}
+	if r.IncludeOnly != nil {
+		b.WriteString("  " + compiler.FormatIncludeOnly(r.IncludeOnly) + "\n")
+	}
+	if len(r.Issues) > 0 {
+		fmt.Fprintf(&b, "  问题 %d 个:\n", len(r.Issues))
+		for _, issue := range r.Issues {
+			fmt.Fprintf(&b, "    ⚠ %s\n", issue)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeCounts writes a table of counts of both versions
+func writeCounts(b *strings.Builder, counts []StructureCount) {
+	fmt.Fprintf(b, "%-20s %6s %6s %6s\n", "", "原文", "译文", "差异")
+	for _, count := range counts {
+		fmt.Fprintf(b, "%-20s %6d %6d %6s\n", count.Name, count.Original, count.Translated, formatDiff(count.Diff))
+	}
+}
+
+// formatDiff returns a signed difference, or nothing when there is none
+func formatDiff(diff int) string {
+	if diff == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+d", diff)
+}
+
+func formatTranslation(s *TranslationStats) string {
+	return fmt.Sprintf("翻译: %d 个文件，重试分块 %d，续写 %d (仍截断 %d)，拆分重译 %d，无响应重连 %d，缓存分块 %d",
+		s.Files, s.RetriedChunks, s.Continuations, s.TruncatedChunks, s.SplitChunks, s.Stalls, s.CachedChunks)
+}
+
+func formatFixes(s *FixStats) string {
+	result := "成功"
+	if !s.Success {
+		result = "未成功"
+	}
+	return fmt.Sprintf("编译修复 (%s): 规则 %d/%d，LLM %d/%d，Agent %d/%d (应用/尝试)，拒绝 %d",
+		result, s.RuleFixes, s.RuleAttempts, s.LLMFixes, s.LLMAttempts, s.AgentFixes, s.AgentAttempts, s.RejectedFixes)
+}
+
+func formatPages(p *pdf.PageCountResult) string {
+	return fmt.Sprintf("页数: 原文 %d，译文 %d (%+.1f%%)", p.OriginalPages, p.TranslatedPages, -p.DiffPercent*100)
+}
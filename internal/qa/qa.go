@@ -0,0 +1,379 @@
+// Package qa reports the quality of a translation in numbers: the structure of the
+// translated LaTeX next to the original (sections, floats, equations, labels, citations
+// and the balance of every environment), the share of Chinese text, what the translator
+// had to retry, the fixes the compile-error fixer applied at each level and the page
+// counts of the two PDFs. ProcessSource writes the report next to the translated PDF; the
+// analyze_tex and check_pages commands print it for a pair of files.
+package qa
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"latex-translator/internal/compiler"
+	"latex-translator/internal/pdf"
+	"latex-translator/internal/translator"
+	"latex-translator/internal/types"
+)
+
+const (
+	// SchemaVersion is the version of the report schema
+	SchemaVersion = 1
+	// ReportFileName is the JSON report written next to the translated PDF
+	ReportFileName = "quality_report.json"
+	// TextReportFileName is the human-readable report written with it
+	TextReportFileName = "quality_report.txt"
+
+	// minLineRatio is the share of the original lines below which the translation has
+	// probably lost content
+	minLineRatio = 0.8
+	// minChineseRatio is the share of Chinese characters below which a Chinese translation
+	// has probably left prose untranslated
+	minChineseRatio = 0.3
+)
+
+// Report is the quality report of one document
+type Report struct {
+	SchemaVersion int    `json:"schema_version"`
+	Source        string `json:"source,omitempty"` // arXiv ID or file name
+	GeneratedAt   string `json:"generated_at"`     // RFC 3339
+
+	Structure             []StructureCount     `json:"structure"`
+	Environments          []EnvironmentBalance `json:"environments"`
+	CommentedEnvironments []StructureCount     `json:"commented_environments,omitempty"`
+	MissingLabels         []string             `json:"missing_labels,omitempty"`
+	OriginalSections      []string             `json:"original_sections,omitempty"`
+	TranslatedSections    []string             `json:"translated_sections,omitempty"`
+
+	// ChineseRatio is the share of Chinese characters among the letters of the translated prose
+	ChineseRatio    float64 `json:"chinese_ratio"`
+	OriginalLines   int     `json:"original_lines"`
+	TranslatedLines int     `json:"translated_lines"`
+	LineRatio       float64 `json:"line_ratio"` // translated lines / original lines
+
+	Translation *TranslationStats            `json:"translation,omitempty"`
+	Fixes       *FixStats                    `json:"fixes,omitempty"`
+	Pages       *pdf.PageCountResult         `json:"pages,omitempty"`
+	Content     *pdf.ContentValidationResult `json:"content,omitempty"` // sections found in the two PDFs (check_pages)
+	// IncludeOnly is the \includeonly directive of the main file; both PDFs were built the
+	// same way, with or without it
+	IncludeOnly *types.IncludeOnlyInfo `json:"include_only,omitempty"`
+
+	// Issues lists what looks wrong, for the reader of the report
+	Issues []string `json:"issues,omitempty"`
+}
+
+// StructureCount is a structural element counted in both versions
+type StructureCount struct {
+	Name       string `json:"name"`
+	Original   int    `json:"original"`
+	Translated int    `json:"translated"`
+	Diff       int    `json:"diff"` // translated - original
+}
+
+// EnvironmentBalance is the \begin and \end count of one environment in both versions
+type EnvironmentBalance struct {
+	Name            string `json:"name"`
+	OriginalBegin   int    `json:"original_begin"`
+	OriginalEnd     int    `json:"original_end"`
+	TranslatedBegin int    `json:"translated_begin"`
+	TranslatedEnd   int    `json:"translated_end"`
+	// Balanced is whether the translation opens and closes the environment equally often
+	Balanced bool `json:"balanced"`
+}
+
+// TranslationStats sums what the translator reported for the files of a document
+type TranslationStats struct {
+	Files               int `json:"files"`
+	TokensUsed          int `json:"tokens_used"`
+	RetriedChunks       int `json:"retried_chunks"`
+	Continuations       int `json:"continuations"`
+	TruncatedChunks     int `json:"truncated_chunks"`
+	SplitChunks         int `json:"split_chunks"`
+	Stalls              int `json:"stalls"`
+	CachedChunks        int `json:"cached_chunks"`
+	SanitizedResponses  int `json:"sanitized_responses"`
+	ParagraphBreakFixes int `json:"paragraph_break_fixes"`
+	GlossaryCorrections int `json:"glossary_corrections"`
+}
+
+// Add counts the result of one translated file
+func (s *TranslationStats) Add(result *types.TranslationResult) {
+	if result == nil {
+		return
+	}
+	s.Files++
+	s.TokensUsed += result.TokensUsed
+	s.RetriedChunks += result.RetriedChunks
+	s.Continuations += result.Continuations
+	s.TruncatedChunks += result.TruncatedChunks
+	s.SplitChunks += result.SplitChunks
+	s.Stalls += result.Stalls
+	s.CachedChunks += result.CachedChunks
+	s.SanitizedResponses += result.SanitizedResponses
+	s.ParagraphBreakFixes += result.ParagraphBreakFixes
+	s.GlossaryCorrections += len(result.GlossaryCorrections)
+}
+
+// FixStats counts the attempts and applied fixes of each level of the compile-error fixer
+type FixStats struct {
+	RuleAttempts  int      `json:"rule_attempts"`
+	LLMAttempts   int      `json:"llm_attempts"`
+	AgentAttempts int      `json:"agent_attempts"`
+	RuleFixes     int      `json:"rule_fixes"`
+	LLMFixes      int      `json:"llm_fixes"`
+	AgentFixes    int      `json:"agent_fixes"`
+	RejectedFixes int      `json:"rejected_fixes"` // fixes declined in the review
+	Success       bool     `json:"success"`
+	History       []string `json:"history,omitempty"`
+}
+
+// NewFixStats summarizes the fix loop of a document; nil when there was none
+func NewFixStats(result *compiler.HierarchicalFixResult) *FixStats {
+	if result == nil {
+		return nil
+	}
+	stats := &FixStats{
+		RuleAttempts:  result.RuleFixAttempts,
+		LLMAttempts:   result.LLMFixAttempts,
+		AgentAttempts: result.AgentFixAttempts,
+		Success:       result.Success,
+		History:       result.History,
+	}
+	// History entries look like "rule:unicode-declarations (main.tex)", "llm (main.tex)"
+	// and "agent rejected (main.tex)"
+	for _, entry := range result.History {
+		switch {
+		case strings.Contains(entry, " rejected "):
+			stats.RejectedFixes++
+		case strings.HasPrefix(entry, "rule:"):
+			stats.RuleFixes++
+		case strings.HasPrefix(entry, "llm "):
+			stats.LLMFixes++
+		case strings.HasPrefix(entry, "agent "):
+			stats.AgentFixes++
+		}
+	}
+	return stats
+}
+
+// structurePatterns are the elements counted in both versions, in report order
+var structurePatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"section", regexp.MustCompile(`\\section\*?\s*[\[{]`)},
+	{"subsection", regexp.MustCompile(`\\subsection\*?\s*[\[{]`)},
+	{"subsubsection", regexp.MustCompile(`\\subsubsection\*?\s*[\[{]`)},
+	{"figure", regexp.MustCompile(`\\begin\{(?:figure|wrapfigure|sidewaysfigure)\*?\}`)},
+	{"table", regexp.MustCompile(`\\begin\{(?:table|wraptable|sidewaystable)\*?\}`)},
+	{"equation", regexp.MustCompile(`\\begin\{(?:equation|align|gather|multline|eqnarray|flalign)\*?\}|(?:^|[^\\])\\\[`)},
+	{"itemize", regexp.MustCompile(`\\begin\{itemize\}`)},
+	{"enumerate", regexp.MustCompile(`\\begin\{enumerate\}`)},
+	{"label", regexp.MustCompile(`\\label\{`)},
+	{"citation", regexp.MustCompile(`\\[a-zA-Z]*cite[a-zA-Z]*\*?(?:\[[^\]]*\])*\{`)},
+}
+
+// commentedPatterns count floats disabled with a comment, which translations sometimes
+// uncomment or drop
+var commentedPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{`% \begin{figure}`, regexp.MustCompile(`%\s*\\begin\{figure`)},
+	{`% \end{figure}`, regexp.MustCompile(`%\s*\\end\{figure`)},
+	{`% \begin{table}`, regexp.MustCompile(`%\s*\\begin\{table`)},
+	{`% \end{table}`, regexp.MustCompile(`%\s*\\end\{table`)},
+}
+
+var (
+	commentPattern      = regexp.MustCompile(`(?m)(^|[^\\])%.*$`)
+	labelPattern        = regexp.MustCompile(`\\label\{([^}]+)\}`)
+	sectionTitlePattern = regexp.MustCompile(`\\section\*?\s*(?:\[[^\]]*\])?\{([^}]+)\}`)
+)
+
+// stripComments removes the comments of LaTeX source, keeping escaped percent signs
+func stripComments(content string) string {
+	return commentPattern.ReplaceAllString(content, "$1")
+}
+
+// CompareTeX compares a translated document with its original. lang is the language of
+// the translation; the share of Chinese text is only checked for Chinese.
+func CompareTeX(original, translated string, lang types.TargetLanguage) *Report {
+	report := &Report{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   time.Now().Format(time.RFC3339),
+	}
+	orig, trans := stripComments(original), stripComments(translated)
+
+	for _, p := range structurePatterns {
+		count := StructureCount{
+			Name:       p.name,
+			Original:   len(p.pattern.FindAllStringIndex(orig, -1)),
+			Translated: len(p.pattern.FindAllStringIndex(trans, -1)),
+		}
+		count.Diff = count.Translated - count.Original
+		report.Structure = append(report.Structure, count)
+	}
+	for _, p := range commentedPatterns {
+		count := StructureCount{
+			Name:       p.name,
+			Original:   len(p.pattern.FindAllStringIndex(original, -1)),
+			Translated: len(p.pattern.FindAllStringIndex(translated, -1)),
+		}
+		if count.Original > 0 || count.Translated > 0 {
+			count.Diff = count.Translated - count.Original
+			report.CommentedEnvironments = append(report.CommentedEnvironments, count)
+		}
+	}
+	report.Environments = compareEnvironments(orig, trans)
+
+	translatedLabels := make(map[string]bool)
+	for _, m := range labelPattern.FindAllStringSubmatch(trans, -1) {
+		translatedLabels[m[1]] = true
+	}
+	seen := make(map[string]bool)
+	for _, m := range labelPattern.FindAllStringSubmatch(orig, -1) {
+		if !translatedLabels[m[1]] && !seen[m[1]] {
+			report.MissingLabels = append(report.MissingLabels, m[1])
+		}
+		seen[m[1]] = true
+	}
+	for _, m := range sectionTitlePattern.FindAllStringSubmatch(orig, -1) {
+		report.OriginalSections = append(report.OriginalSections, strings.TrimSpace(m[1]))
+	}
+	for _, m := range sectionTitlePattern.FindAllStringSubmatch(trans, -1) {
+		report.TranslatedSections = append(report.TranslatedSections, strings.TrimSpace(m[1]))
+	}
+
+	report.ChineseRatio = translator.DetectSourceLanguage(translated).CJKRatio
+	report.OriginalLines = strings.Count(original, "\n") + 1
+	report.TranslatedLines = strings.Count(translated, "\n") + 1
+	report.LineRatio = float64(report.TranslatedLines) / float64(report.OriginalLines)
+
+	report.Evaluate(lang)
+	return report
+}
+
+// compareEnvironments returns the balance of every environment of either version,
+// by name
+func compareEnvironments(original, translated string) []EnvironmentBalance {
+	origEnvs := translator.ValidateEnvironments(original).Environments
+	transEnvs := translator.ValidateEnvironments(translated).Environments
+	names := make(map[string]bool)
+	for name := range origEnvs {
+		names[name] = true
+	}
+	for name := range transEnvs {
+		names[name] = true
+	}
+
+	balances := make([]EnvironmentBalance, 0, len(names))
+	for name := range names {
+		o, t := origEnvs[name], transEnvs[name]
+		balances = append(balances, EnvironmentBalance{
+			Name:            name,
+			OriginalBegin:   o.BeginCount,
+			OriginalEnd:     o.EndCount,
+			TranslatedBegin: t.BeginCount,
+			TranslatedEnd:   t.EndCount,
+			Balanced:        t.BeginCount == t.EndCount,
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Name < balances[j].Name })
+	return balances
+}
+
+// Evaluate lists the issues of the report: structure lost or added by the translation,
+// unbalanced environments, missing labels, too few lines or too little Chinese text and
+// a suspicious page count. It runs again after the translation and PDF data are added.
+func (r *Report) Evaluate(lang types.TargetLanguage) {
+	r.Issues = nil
+	for _, count := range r.Structure {
+		if count.Diff != 0 {
+			r.Issues = append(r.Issues, fmt.Sprintf("%s 数量不一致: 原文 %d，译文 %d", count.Name, count.Original, count.Translated))
+		}
+	}
+	for _, env := range r.Environments {
+		// An environment the original already leaves open is not the translation's fault
+		if !env.Balanced && env.OriginalBegin == env.OriginalEnd {
+			r.Issues = append(r.Issues, fmt.Sprintf("译文中环境 %s 不平衡: \\begin %d 次，\\end %d 次", env.Name, env.TranslatedBegin, env.TranslatedEnd))
+		}
+	}
+	if len(r.MissingLabels) > 0 {
+		r.Issues = append(r.Issues, fmt.Sprintf("译文缺少 %d 个标签: %s", len(r.MissingLabels), strings.Join(r.MissingLabels, ", ")))
+	}
+	if r.OriginalLines > 0 && r.LineRatio < minLineRatio {
+		r.Issues = append(r.Issues, fmt.Sprintf("译文行数只有原文的 %.0f%% (%d/%d)，可能丢失了内容", r.LineRatio*100, r.TranslatedLines, r.OriginalLines))
+	}
+	if lang.IsChinese() && r.ChineseRatio < minChineseRatio {
+		r.Issues = append(r.Issues, fmt.Sprintf("译文正文中文字符只占 %.0f%%，可能有未翻译的段落", r.ChineseRatio*100))
+	}
+	if r.Translation != nil && r.Translation.TruncatedChunks > 0 {
+		r.Issues = append(r.Issues, fmt.Sprintf("%d 个分块的译文在续写后仍不完整", r.Translation.TruncatedChunks))
+	}
+	if r.Pages != nil && r.Pages.IsSuspicious {
+		r.Issues = append(r.Issues, pdf.FormatPageCountError(r.Pages))
+	}
+	if r.Content != nil {
+		r.Issues = append(r.Issues, r.Content.Warnings...)
+	}
+}
+
+// ComparePages compares the page counts of the original and translated PDFs
+func ComparePages(originalPDF, translatedPDF string) (*pdf.PageCountResult, error) {
+	return pdf.NewBabelDocTranslator(pdf.BabelDocConfig{}).CheckPageCountDifference(originalPDF, translatedPDF)
+}
+
+// ComparePDFs compares the page counts and the section structure found in the text of
+// the original and translated PDFs
+func ComparePDFs(originalPDF, translatedPDF string) (*Report, error) {
+	content, err := pdf.NewContentValidator("").ValidateContent(originalPDF, translatedPDF)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   time.Now().Format(time.RFC3339),
+		Pages:         content.PageCountResult,
+		Content:       content,
+	}
+	report.Evaluate(types.LanguageChinese)
+	return report, nil
+}
+
+// Save writes the report as ReportFileName and TextReportFileName into dir and returns the
+// path of the JSON report
+func (r *Report) Save(dir string) (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, ReportFileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, TextReportFileName), []byte(FormatText(r)), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Load reads a JSON report
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
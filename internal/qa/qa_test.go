@@ -0,0 +1,153 @@
+package qa
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"latex-translator/internal/compiler"
+	"latex-translator/internal/pdf"
+	"latex-translator/internal/types"
+)
+
+const original = `\documentclass{article}
+\begin{document}
+\section{Introduction}\label{sec:intro}
+Deep networks are widely used \cite{lecun2015,he2016}. Line breaks\\[2pt] are not math.
+\begin{figure}
+\centering
+\caption{Overview.}\label{fig:overview}
+\end{figure}
+% \begin{table}
+% \end{table}
+\section{Method}
+\begin{equation}
+y = f(x) \label{eq:model}
+\end{equation}
+\[ z = g(y) \]
+\begin{itemize}
+\item First point.
+\end{itemize}
+\end{document}
+`
+
+func countOf(t *testing.T, counts []StructureCount, name string) StructureCount {
+	t.Helper()
+	for _, count := range counts {
+		if count.Name == name {
+			return count
+		}
+	}
+	t.Fatalf("no count named %q", name)
+	return StructureCount{}
+}
+
+func TestCompareTeXIdentical(t *testing.T) {
+	translated := strings.NewReplacer(
+		"Introduction", "引言", "Method", "方法",
+		"Deep networks are widely used", "深度网络被广泛使用",
+		"Line breaks", "换行", "are not math.", "不是公式。",
+		"Overview.", "概览。", "First point.", "第一点。",
+	).Replace(original)
+	report := CompareTeX(original, translated, types.LanguageChinese)
+
+	want := map[string]int{"section": 2, "figure": 1, "table": 0, "equation": 2, "itemize": 1, "label": 3, "citation": 1}
+	for name, n := range want {
+		if count := countOf(t, report.Structure, name); count.Original != n || count.Translated != n || count.Diff != 0 {
+			t.Errorf("%s = %+v, want %d in both", name, count, n)
+		}
+	}
+	if commented := countOf(t, report.CommentedEnvironments, `% \begin{table}`); commented.Original != 1 || commented.Translated != 1 {
+		t.Errorf("commented table = %+v", commented)
+	}
+	if len(report.Issues) != 0 || len(report.MissingLabels) != 0 {
+		t.Errorf("issues = %v, missing labels = %v", report.Issues, report.MissingLabels)
+	}
+	if report.ChineseRatio < minChineseRatio || report.LineRatio != 1 {
+		t.Errorf("chinese ratio %.2f, line ratio %.2f", report.ChineseRatio, report.LineRatio)
+	}
+	if strings.Join(report.TranslatedSections, ",") != "引言,方法" {
+		t.Errorf("translated sections = %v", report.TranslatedSections)
+	}
+}
+
+func TestCompareTeXFindsLostStructure(t *testing.T) {
+	// The model dropped the figure and an \end{itemize} and left the prose in English
+	translated := strings.Replace(original, "\\begin{figure}\n\\centering\n\\caption{Overview.}\\label{fig:overview}\n\\end{figure}\n", "", 1)
+	translated = strings.Replace(translated, "\\end{itemize}\n", "", 1)
+	report := CompareTeX(original, translated, types.LanguageChinese)
+
+	if figure := countOf(t, report.Structure, "figure"); figure.Diff != -1 {
+		t.Errorf("figure = %+v", figure)
+	}
+	var itemize *EnvironmentBalance
+	for i := range report.Environments {
+		if report.Environments[i].Name == "itemize" {
+			itemize = &report.Environments[i]
+		}
+	}
+	if itemize == nil || itemize.Balanced || itemize.TranslatedBegin != 1 || itemize.TranslatedEnd != 0 {
+		t.Errorf("itemize = %+v", itemize)
+	}
+	if strings.Join(report.MissingLabels, ",") != "fig:overview" {
+		t.Errorf("missing labels = %v", report.MissingLabels)
+	}
+	issues := strings.Join(report.Issues, "\n")
+	for _, want := range []string{"figure 数量不一致", "环境 itemize 不平衡", "fig:overview", "中文字符"} {
+		if !strings.Contains(issues, want) {
+			t.Errorf("issues lack %q:\n%s", want, issues)
+		}
+	}
+
+	// The share of Chinese text only matters for Chinese
+	if english := CompareTeX(original, translated, types.LanguageFrench); strings.Contains(strings.Join(english.Issues, "\n"), "中文字符") {
+		t.Errorf("French translation checked for Chinese text: %v", english.Issues)
+	}
+}
+
+func TestNewFixStats(t *testing.T) {
+	if NewFixStats(nil) != nil {
+		t.Error("no fix loop gives fix stats")
+	}
+	stats := NewFixStats(&compiler.HierarchicalFixResult{
+		Success:         true,
+		RuleFixAttempts: 2,
+		LLMFixAttempts:  2,
+		History:         []string{"rule:unicode-declarations (main.tex)", "rule:quick-fix (main.tex)", "llm rejected (main.tex)", "llm (main.tex)", "agent (intro.tex)"},
+	})
+	if stats.RuleFixes != 2 || stats.LLMFixes != 1 || stats.AgentFixes != 1 || stats.RejectedFixes != 1 || stats.RuleAttempts != 2 {
+		t.Errorf("fix stats = %+v", stats)
+	}
+}
+
+func TestReportSaveAndLoad(t *testing.T) {
+	report := CompareTeX(original, original, types.LanguageChinese)
+	report.Source = "2301.00001"
+	report.Translation = &TranslationStats{}
+	report.Translation.Add(&types.TranslationResult{TokensUsed: 100, RetriedChunks: 2, TruncatedChunks: 1})
+	report.Pages = &pdf.PageCountResult{OriginalPages: 10, TranslatedPages: 8, Difference: 2, DiffPercent: 0.2, IsSuspicious: true}
+	report.Evaluate(types.LanguageEnglish)
+
+	dir := t.TempDir()
+	path, err := report.Save(dir)
+	if err != nil || path != filepath.Join(dir, ReportFileName) {
+		t.Fatalf("Save() = %q, %v", path, err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Source != "2301.00001" || loaded.Translation.RetriedChunks != 2 || loaded.Pages.TranslatedPages != 8 || len(loaded.Issues) != 2 {
+		t.Errorf("loaded report = %+v", loaded)
+	}
+
+	summary := FormatSummary(loaded)
+	for _, want := range []string{"section", "重试分块 2", "页数: 原文 10，译文 8 (-20.0%)", "问题 2 个"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if text := FormatText(loaded); !strings.Contains(text, "环境平衡") || !strings.Contains(text, "2301.00001") {
+		t.Errorf("text report:\n%s", text)
+	}
+}
@@ -0,0 +1,405 @@
+package results
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// LibraryArchiveVersion is the version of the library archive format written by
+	// ExportLibrary. Archives of older versions are migrated on import; newer ones are
+	// refused.
+	LibraryArchiveVersion = 1
+	// libraryManifestName is the name of the manifest in a library archive
+	libraryManifestName = "manifest.json"
+	// libraryPapersDir is the directory of the paper directories in a library archive
+	libraryPapersDir = "papers"
+)
+
+// LibraryManifest is the index of a library archive
+type LibraryManifest struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Papers     []ManifestPaper `json:"papers"`
+}
+
+// ManifestPaper is a paper of a library archive
+type ManifestPaper struct {
+	ArxivID string `json:"arxiv_id"`
+	Title   string `json:"title"`
+	Dir     string `json:"dir"`      // directory of the paper under papers/ in the archive
+	BaseDir string `json:"base_dir"` // directory of the paper on the exporting machine
+}
+
+// Import outcomes of a paper
+const (
+	ImportImported = "imported"
+	ImportSkipped  = "skipped"
+	ImportFailed   = "failed"
+)
+
+// ImportResult is the outcome of importing one paper of a library archive
+type ImportResult struct {
+	ArxivID string `json:"arxiv_id"`
+	Title   string `json:"title"`
+	Status  string `json:"status"` // ImportImported, ImportSkipped or ImportFailed
+	Error   string `json:"error,omitempty"`
+}
+
+// ImportReport is the outcome of ImportLibrary
+type ImportReport struct {
+	Version  int            `json:"version"` // format version of the archive
+	Results  []ImportResult `json:"results"`
+	Imported int            `json:"imported"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+}
+
+// ExportLibrary writes the directories of the papers with the given IDs, or of all papers
+// when arxivIDs is empty, to a zip archive at outputZip with a manifest, so the library can
+// be moved to another machine with ImportLibrary. It returns the manifest written.
+func (m *ResultManager) ExportLibrary(outputZip string, arxivIDs []string) (*LibraryManifest, error) {
+	if len(arxivIDs) == 0 {
+		papers, err := m.ListPapers()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range papers {
+			arxivIDs = append(arxivIDs, p.ArxivID)
+		}
+	}
+
+	manifest := &LibraryManifest{Version: LibraryArchiveVersion, ExportedAt: time.Now(), Papers: []ManifestPaper{}}
+	for _, id := range arxivIDs {
+		info, err := m.LoadPaperInfo(id)
+		if err != nil {
+			return nil, fmt.Errorf("paper %s is not in the library: %w", id, err)
+		}
+		dir := m.GetPaperDir(id)
+		manifest.Papers = append(manifest.Papers, ManifestPaper{
+			ArxivID: info.ArxivID,
+			Title:   info.Title,
+			Dir:     filepath.Base(dir),
+			BaseDir: dir,
+		})
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputZip), 0755); err != nil {
+		return nil, err
+	}
+	// Written next to the target and renamed, so a failed export leaves no partial archive
+	tmpPath := outputZip + ".tmp"
+	if err := writeLibraryArchive(tmpPath, manifest); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, outputZip); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeLibraryArchive writes the manifest and the directories of its papers to a zip file
+func writeLibraryArchive(zipPath string, manifest *LibraryManifest) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := zw.Create(libraryManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	for _, paper := range manifest.Papers {
+		err := filepath.Walk(paper.BaseDir, func(file string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(paper.BaseDir, file)
+			if err != nil {
+				return err
+			}
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = path.Join(libraryPapersDir, paper.Dir, filepath.ToSlash(rel))
+			header.Method = zip.Deflate
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			src, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(w, src)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", paper.ArxivID, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ImportLibrary adds the papers of a library archive written by ExportLibrary to the
+// library. Papers already in the library are skipped unless overwrite is set. The error
+// is for an unreadable archive or an invalid manifest; the outcome of each paper is in
+// the report.
+func (m *ResultManager) ImportLibrary(zipPath string, overwrite bool) (*ImportReport, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open library archive: %w", err)
+	}
+	defer zr.Close()
+
+	manifest, err := readLibraryManifest(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files of each paper directory
+	files := make(map[string][]*zip.File)
+	for _, f := range zr.File {
+		name, ok := strings.CutPrefix(f.Name, libraryPapersDir+"/")
+		if !ok || f.FileInfo().IsDir() {
+			continue
+		}
+		dir, rel, ok := strings.Cut(name, "/")
+		if !ok {
+			continue
+		}
+		if !isLocalArchivePath(rel) {
+			return nil, fmt.Errorf("library archive holds an unsafe path: %s", f.Name)
+		}
+		files[dir] = append(files[dir], f)
+	}
+
+	report := &ImportReport{Version: manifest.Version, Results: []ImportResult{}}
+	for _, paper := range manifest.Papers {
+		result := ImportResult{ArxivID: paper.ArxivID, Title: paper.Title, Status: ImportImported}
+		if m.PaperExists(paper.ArxivID) && !overwrite {
+			result.Status = ImportSkipped
+		} else if err := m.importPaper(paper, files[paper.Dir]); err != nil {
+			result.Status = ImportFailed
+			result.Error = err.Error()
+		}
+		switch result.Status {
+		case ImportImported:
+			report.Imported++
+		case ImportSkipped:
+			report.Skipped++
+		case ImportFailed:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// readLibraryManifest reads and validates the manifest of a library archive, migrating
+// archives of older format versions
+func readLibraryManifest(zr *zip.Reader) (*LibraryManifest, error) {
+	f, err := zr.Open(libraryManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("not a library archive: %s is missing", libraryManifestName)
+	}
+	defer f.Close()
+
+	var manifest LibraryManifest
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid library manifest: %w", err)
+	}
+	switch {
+	case manifest.Version < 1:
+		return nil, fmt.Errorf("invalid library manifest: version %d", manifest.Version)
+	case manifest.Version > LibraryArchiveVersion:
+		return nil, fmt.Errorf("library archive version %d is newer than the supported version %d", manifest.Version, LibraryArchiveVersion)
+	}
+	// Version 1 is the current format; migrations of older versions go here
+
+	seen := make(map[string]bool)
+	for _, paper := range manifest.Papers {
+		if paper.ArxivID == "" || paper.Dir == "" || !isLocalArchivePath(paper.Dir) || strings.Contains(paper.Dir, "/") {
+			return nil, fmt.Errorf("invalid library manifest: paper %q in directory %q", paper.ArxivID, paper.Dir)
+		}
+		if seen[paper.ArxivID] {
+			return nil, fmt.Errorf("invalid library manifest: paper %s listed twice", paper.ArxivID)
+		}
+		seen[paper.ArxivID] = true
+	}
+	return &manifest, nil
+}
+
+// isLocalArchivePath reports whether a slash-separated archive path stays inside the
+// directory it is extracted to
+func isLocalArchivePath(name string) bool {
+	return name != "" && !strings.Contains(name, `\`) && filepath.IsLocal(filepath.FromSlash(name))
+}
+
+// Suffixes of the sibling directories an import extracts a paper into and moves the
+// replaced paper to; ListPapers skips them
+const (
+	importingDirSuffix = ".importing"
+	replacedDirSuffix  = ".replaced"
+)
+
+// isImportScratchDir reports whether a library directory is left over from an import
+func isImportScratchDir(name string) bool {
+	return strings.HasSuffix(name, importingDirSuffix) || strings.HasSuffix(name, replacedDirSuffix)
+}
+
+// importPaper extracts the files of a paper into its directory in the library and points
+// the paths of its metadata there. The paper is extracted next to its directory and only
+// replaces it once complete, so a paper that fails to import leaves the library unchanged.
+func (m *ResultManager) importPaper(paper ManifestPaper, files []*zip.File) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no files in the archive")
+	}
+	dir := m.GetPaperDir(paper.ArxivID)
+	staging := dir + importingDirSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+
+	err := extractPaperFiles(staging, paper.Dir, files)
+	if err == nil {
+		err = rebasePaperInfo(paper, staging, dir)
+	}
+	if err == nil {
+		err = replacePaperDir(staging, dir)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	return nil
+}
+
+// replacePaperDir moves the extracted paper in staging to dir. An existing paper is moved
+// aside first and only removed once the new one is in place.
+func replacePaperDir(staging, dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return os.Rename(staging, dir)
+	} else if err != nil {
+		return err
+	}
+
+	old := dir + replacedDirSuffix
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dir, old); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		if restoreErr := os.Rename(old, dir); restoreErr != nil {
+			return fmt.Errorf("%w; the previous paper is kept in %s", err, old)
+		}
+		return err
+	}
+	// A replaced paper left behind is skipped by ListPapers and removed by the next import
+	os.RemoveAll(old)
+	return nil
+}
+
+// extractPaperFiles writes the files of archive directory papers/<archiveDir> to dir
+func extractPaperFiles(dir, archiveDir string, files []*zip.File) error {
+	prefix := libraryPapersDir + "/" + archiveDir + "/"
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(f.Name, prefix)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractArchiveFile(f, target); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractArchiveFile writes one file of an archive to target
+func extractArchiveFile(f *zip.File, target string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// rebasePaperInfo points the paths of the metadata of a paper extracted to staging from
+// its directory on the exporting machine to its directory dir in this library
+func rebasePaperInfo(paper ManifestPaper, staging, dir string) error {
+	metaPath := filepath.Join(staging, "metadata.json")
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return fmt.Errorf("no metadata in the archive: %w", err)
+	}
+	var info PaperInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("invalid metadata: %w", err)
+	}
+	if info.ArxivID != paper.ArxivID {
+		return fmt.Errorf("metadata is for %s", info.ArxivID)
+	}
+
+	for _, p := range []*string{&info.OriginalPDF, &info.TranslatedPDF, &info.BilingualPDF, &info.SourceDir, &info.TranslatedHTML} {
+		*p = rebasePath(*p, paper.BaseDir, dir)
+	}
+	data, err = json.MarshalIndent(&info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, data, 0644)
+}
+
+// rebasePath moves a path under oldBase to newBase. The exporting machine may use the
+// other path separator, so both are compared with slashes. Paths outside of oldBase are
+// dropped, so imported metadata cannot point at other files of this machine.
+func rebasePath(p, oldBase, newBase string) string {
+	if p == "" || oldBase == "" {
+		return ""
+	}
+	slashed := strings.ReplaceAll(p, `\`, "/")
+	base := strings.TrimSuffix(strings.ReplaceAll(oldBase, `\`, "/"), "/")
+	if slashed == base {
+		return newBase
+	}
+	if rel, ok := strings.CutPrefix(slashed, base+"/"); ok && isLocalArchivePath(rel) {
+		return filepath.Join(newBase, filepath.FromSlash(rel))
+	}
+	return ""
+}
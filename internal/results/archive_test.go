@@ -0,0 +1,204 @@
+package results
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImportLibrary(t *testing.T) {
+	src := newLibrary(t)
+	info, _ := src.LoadPaperInfo("2301.00001")
+	info.TranslatedPDF = filepath.Join(src.GetPaperDir("2301.00001"), "translated.pdf")
+	info.SourceDir = filepath.Join(src.GetPaperDir("2301.00001"), "latex")
+	if err := src.SavePaperInfo(info); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "library.zip")
+	manifest, err := src.ExportLibrary(archive, []string{"2301.00001", "hep-th/9901001"})
+	if err != nil {
+		t.Fatalf("ExportLibrary() error: %v", err)
+	}
+	if manifest.Version != LibraryArchiveVersion || len(manifest.Papers) != 2 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	dst, err := NewResultManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := dst.ImportLibrary(archive, false)
+	if err != nil {
+		t.Fatalf("ImportLibrary() error: %v", err)
+	}
+	if report.Imported != 2 || report.Failed != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	imported, err := dst.LoadPaperInfo("2301.00001")
+	if err != nil {
+		t.Fatalf("imported paper not in the library: %v", err)
+	}
+	if want := filepath.Join(dst.GetPaperDir("2301.00001"), "translated.pdf"); imported.TranslatedPDF != want {
+		t.Errorf("TranslatedPDF = %q, want %q", imported.TranslatedPDF, want)
+	}
+	if data, err := os.ReadFile(imported.TranslatedPDF); err != nil || len(data) != 1000 {
+		t.Errorf("translated PDF not imported: %d bytes, %v", len(data), err)
+	}
+	if !dst.PaperExists("hep-th/9901001") || dst.PaperExists("2302.00002") {
+		t.Error("imported other papers than the exported ones")
+	}
+
+	// Papers already in the library are kept unless overwritten
+	report, _ = dst.ImportLibrary(archive, false)
+	if report.Skipped != 2 || report.Imported != 0 {
+		t.Errorf("second import = %+v, want both skipped", report)
+	}
+	report, _ = dst.ImportLibrary(archive, true)
+	if report.Imported != 2 {
+		t.Errorf("overwriting import = %+v", report)
+	}
+}
+
+// writeArchive writes a zip file with the given entries
+func writeArchive(t *testing.T, entries map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "library.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range entries {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+	return path
+}
+
+func TestImportLibraryValidatesArchive(t *testing.T) {
+	m, err := NewResultManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]map[string]string{
+		"missing manifest": {"papers/a/metadata.json": "{}"},
+		"newer version":    {"manifest.json": `{"version":99,"papers":[]}`},
+		"unsafe directory": {"manifest.json": `{"version":1,"papers":[{"arxiv_id":"2301.00001","dir":".."}]}`},
+		"traversal": {
+			"manifest.json":              `{"version":1,"papers":[{"arxiv_id":"2301.00001","dir":"a"}]}`,
+			"papers/a/../../../evil.txt": "x",
+			"papers/a/metadata.json":     `{"arxiv_id":"2301.00001"}`,
+		},
+	}
+	for name, entries := range tests {
+		if _, err := m.ImportLibrary(writeArchive(t, entries), false); err == nil {
+			t.Errorf("%s: ImportLibrary() accepted the archive", name)
+		}
+	}
+	if papers, _ := m.ListPapers(); len(papers) != 0 {
+		t.Errorf("invalid archives imported %d papers", len(papers))
+	}
+
+	// A paper without metadata fails alone
+	report, err := m.ImportLibrary(writeArchive(t, map[string]string{
+		"manifest.json":          `{"version":1,"papers":[{"arxiv_id":"2301.00001","dir":"a"},{"arxiv_id":"2301.00002","dir":"b"}]}`,
+		"papers/a/metadata.json": `{"arxiv_id":"2301.00001","title":"A"}`,
+		"papers/b/original.pdf":  "%PDF",
+	}), false)
+	if err != nil {
+		t.Fatalf("ImportLibrary() error: %v", err)
+	}
+	if report.Imported != 1 || report.Failed != 1 || !strings.Contains(report.Results[1].Error, "metadata") {
+		t.Errorf("report = %+v", report)
+	}
+	if _, err := os.Stat(m.GetPaperDir("2301.00002")); !os.IsNotExist(err) {
+		t.Error("failed paper left its directory behind")
+	}
+}
+
+func TestImportOverwriteKeepsPaperOnFailure(t *testing.T) {
+	m := newLibrary(t)
+	dir := m.GetPaperDir("2301.00001")
+
+	// The translated PDF of the archive is truncated: its checksum does not match
+	path := filepath.Join(t.TempDir(), "library.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"manifest.json":          `{"version":1,"papers":[{"arxiv_id":"2301.00001","dir":"a","base_dir":"/old/2301.00001"}]}`,
+		"papers/a/metadata.json": `{"arxiv_id":"2301.00001","title":"Imported"}`,
+	} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "papers/a/translated.pdf",
+		Method:             zip.Store,
+		CRC32:              1,
+		CompressedSize64:   4,
+		UncompressedSize64: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("%PDF"))
+	zw.Close()
+	f.Close()
+
+	report, err := m.ImportLibrary(path, true)
+	if err != nil {
+		t.Fatalf("ImportLibrary() error: %v", err)
+	}
+	if report.Failed != 1 || report.Imported != 0 {
+		t.Errorf("report = %+v", report)
+	}
+
+	// The paper in the library is untouched and no import directory is left behind
+	info, err := m.LoadPaperInfo("2301.00001")
+	if err != nil || info.Title != "Attention Is All You Need" {
+		t.Fatalf("existing paper lost: %+v, %v", info, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "translated.pdf")); err != nil || len(data) != 1000 {
+		t.Errorf("existing translated PDF lost: %d bytes, %v", len(data), err)
+	}
+	for _, suffix := range []string{importingDirSuffix, replacedDirSuffix} {
+		if _, err := os.Stat(dir + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s directory left behind", suffix)
+		}
+	}
+	if papers, _ := m.ListPapers(); len(papers) != 3 {
+		t.Errorf("library holds %d papers, want 3", len(papers))
+	}
+}
+
+func TestRebasePath(t *testing.T) {
+	newBase := filepath.Join("home", "results", "2301.00001")
+	tests := []struct {
+		name    string
+		path    string
+		oldBase string
+		want    string
+	}{
+		{"Windows path", `C:\Users\me\latex-translator-results\2301.00001\translated.pdf`, `C:\Users\me\latex-translator-results\2301.00001`, filepath.Join(newBase, "translated.pdf")},
+		{"nested path", "/home/me/results/2301.00001/latex/main.tex", "/home/me/results/2301.00001/", filepath.Join(newBase, "latex", "main.tex")},
+		{"paper directory", "/home/me/results/2301.00001", "/home/me/results/2301.00001", newBase},
+		{"outside of the paper directory", "/etc/passwd", "/home/me/results/2301.00001", ""},
+		{"sibling with the same prefix", "/home/me/results/2301.000012/a.pdf", "/home/me/results/2301.00001", ""},
+		{"escaping the paper directory", "/home/me/results/2301.00001/../../.ssh/id_rsa", "/home/me/results/2301.00001", ""},
+		{"no base directory", "/tmp/work/original.pdf", "", ""},
+		{"empty path", "", "/home/me/results/2301.00001", ""},
+	}
+	for _, tt := range tests {
+		if got := rebasePath(tt.path, tt.oldBase, newBase); got != tt.want {
+			t.Errorf("%s: rebasePath(%q) = %q, want %q", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
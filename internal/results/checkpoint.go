@@ -0,0 +1,80 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"latex-translator/internal/types"
+)
+
+// CheckpointDirName is the directory (inside the LaTeX source directory) holding the
+// translations finished before a job was cancelled
+const CheckpointDirName = ".checkpoint"
+
+// checkpointFileName is the checkpoint file inside CheckpointDirName
+const checkpointFileName = "translations.json"
+
+// Checkpoint holds the raw translations of the files finished before a job was cancelled,
+// keyed by slash-separated path relative to the LaTeX source directory. A translation is
+// reused only while the original it was made from is unchanged.
+type Checkpoint struct {
+	Files map[string]*types.TranslationPair `json:"files"`
+}
+
+// CheckpointPath returns the path of the checkpoint of a LaTeX source directory
+func CheckpointPath(sourceDir string) string {
+	return filepath.Join(sourceDir, CheckpointDirName, checkpointFileName)
+}
+
+// SaveCheckpoint writes the translations finished so far to the source directory, so they
+// are copied to the library with the source and reused by ContinueTranslation
+func SaveCheckpoint(sourceDir string, files map[string]*types.TranslationPair) error {
+	normalized := make(map[string]*types.TranslationPair, len(files))
+	for rel, pair := range files {
+		normalized[filepath.ToSlash(rel)] = pair
+	}
+	data, err := json.MarshalIndent(&Checkpoint{Files: normalized}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := CheckpointPath(sourceDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadCheckpoint reads the checkpoint of a source directory; it returns nil when there is none
+func LoadCheckpoint(sourceDir string) *Checkpoint {
+	data, err := os.ReadFile(CheckpointPath(sourceDir))
+	if err != nil {
+		return nil
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Files) == 0 {
+		return nil
+	}
+	return &c
+}
+
+// Lookup returns the checkpointed translation of a file if it was made from original
+func (c *Checkpoint) Lookup(relPath, original string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	pair, ok := c.Files[filepath.ToSlash(relPath)]
+	if !ok || pair == nil || pair.Original != original {
+		return "", false
+	}
+	return pair.Translated, true
+}
+
+// ClearCheckpoint removes the checkpoint of a source directory once the job finished
+func ClearCheckpoint(sourceDir string) {
+	os.RemoveAll(filepath.Join(sourceDir, CheckpointDirName))
+}
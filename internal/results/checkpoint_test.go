@@ -0,0 +1,44 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if LoadCheckpoint(dir) != nil {
+		t.Fatal("checkpoint loaded from an empty directory")
+	}
+
+	err := SaveCheckpoint(dir, map[string]*types.TranslationPair{
+		"main.tex":                             {Original: "Hello", Translated: "你好"},
+		filepath.Join("sections", "intro.tex"): {Original: "Intro", Translated: "引言"},
+	})
+	if err != nil {
+		t.Fatalf("SaveCheckpoint failed: %v", err)
+	}
+
+	c := LoadCheckpoint(dir)
+	if got, ok := c.Lookup("main.tex", "Hello"); !ok || got != "你好" {
+		t.Errorf("Lookup(main.tex) = %q, %v", got, ok)
+	}
+	if got, ok := c.Lookup(filepath.Join("sections", "intro.tex"), "Intro"); !ok || got != "引言" {
+		t.Errorf("Lookup(sections/intro.tex) = %q, %v", got, ok)
+	}
+	if _, ok := c.Lookup("main.tex", "Hello, changed"); ok {
+		t.Error("translation of a changed original was reused")
+	}
+
+	ClearCheckpoint(dir)
+	if _, err := os.Stat(filepath.Join(dir, CheckpointDirName)); !os.IsNotExist(err) {
+		t.Errorf("checkpoint directory left after ClearCheckpoint: %v", err)
+	}
+	var nilCheckpoint *Checkpoint
+	if _, ok := nilCheckpoint.Lookup("main.tex", "Hello"); ok {
+		t.Error("Lookup on nil checkpoint succeeded")
+	}
+}
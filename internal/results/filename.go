@@ -0,0 +1,128 @@
+package results
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxFileNameLength is the maximum length in bytes of a sanitized file name (without extension).
+// It leaves room for suffixes such as "_biling.pdf" and for the library directory prefix
+// within the Windows MAX_PATH limit.
+const MaxFileNameLength = 100
+
+// fileNameHashLength is the number of hex characters of the hash suffix
+const fileNameHashLength = 8
+
+// reservedFileNames are device names that Windows refuses as file names, with any extension
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// punctuationReplacements maps common full-width and typographic punctuation to ASCII
+var punctuationReplacements = map[rune]string{
+	'，': ",", '。': ".", '、': ",", '；': ";", '！': "!", '？': "?",
+	'（': "(", '）': ")", '【': "[", '】': "]", '《': "", '》': "",
+	'「': "", '」': "", '『': "", '』': "", '“': "", '”': "", '‘': "'", '’': "'",
+	'—': "-", '–': "-", '…': "...", '·': "-", '～': "-",
+}
+
+// SanitizeFileName turns an arbitrary title or identifier into a file name that is valid
+// on Windows, macOS and Linux and safe to use as a URL path segment.
+// The same rules are applied on every platform so that a library created on one system
+// can be copied to another. Letters and digits of any script are kept; reserved characters,
+// whitespace and separators become "_", emoji and other symbols are dropped.
+// Names longer than MaxFileNameLength are truncated and suffixed with a short hash of the
+// original so that different long titles do not collide.
+// The result is never empty. The original title should be kept in metadata, not derived
+// from the file name.
+func SanitizeFileName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if rep, ok := punctuationReplacements[r]; ok {
+			sb.WriteString(rep)
+			continue
+		}
+		// Full-width ASCII variants (Ａ, ０, ：...)
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		switch {
+		case strings.ContainsRune(`<>:"/\|?*#%`, r):
+			sb.WriteByte('_')
+		case unicode.IsSpace(r), unicode.IsControl(r):
+			sb.WriteByte('_')
+		case r == utf8.RuneError:
+			// Invalid UTF-8
+		case r < utf8.RuneSelf:
+			sb.WriteRune(r)
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r):
+			sb.WriteRune(r)
+		}
+	}
+
+	safe := collapseUnderscores(sb.String())
+	safe = strings.Trim(safe, "_. ")
+	if safe == "" {
+		return "untitled"
+	}
+
+	base := safe
+	if dot := strings.IndexByte(base, '.'); dot != -1 {
+		base = base[:dot]
+	}
+	if reservedFileNames[strings.ToUpper(base)] {
+		safe = "_" + safe
+	}
+
+	if len(safe) > MaxFileNameLength {
+		safe = truncateUTF8(safe, MaxFileNameLength-fileNameHashLength-1)
+		safe = strings.TrimRight(safe, "_. ") + "_" + fileNameHash(name)
+	}
+	return safe
+}
+
+// UniqueFileName returns a file name for name that no other name maps to.
+// Names that are already valid file names are kept as they are; any other name is
+// sanitized and suffixed with a short hash of the original, so different names that
+// sanitize to the same file name get distinct files. The result depends on name alone,
+// never on which files exist.
+func UniqueFileName(name string) string {
+	safe := SanitizeFileName(name)
+	hash := fileNameHash(name)
+	if safe == name || strings.HasSuffix(safe, "_"+hash) {
+		return safe
+	}
+	return strings.TrimRight(truncateUTF8(safe, MaxFileNameLength-fileNameHashLength-1), "_. ") + "_" + hash
+}
+
+// fileNameHash returns a short, stable hash of name
+func fileNameHash(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])[:fileNameHashLength]
+}
+
+// collapseUnderscores replaces runs of "_" with a single "_"
+func collapseUnderscores(s string) string {
+	for strings.Contains(s, "__") {
+		s = strings.ReplaceAll(s, "__", "_")
+	}
+	return s
+}
+
+// truncateUTF8 truncates s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
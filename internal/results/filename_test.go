@@ -0,0 +1,228 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// pathologicalTitles are titles that have broken saving, exporting or sharing before
+var pathologicalTitles = []string{
+	"Attention: Is It All You Need? 🤔 / Part 1",
+	"CON",
+	"con.pdf",
+	"LPT1",
+	"Title...",
+	"  leading and trailing spaces  ",
+	"...",
+	"",
+	"🤔🤔🤔",
+	"a/b\\c:d*e?f\"g<h>i|j",
+	"100% #1 Result",
+	"深度学习：综述（第二版）",
+	"《注意力机制》研究——“全文”",
+	"Ｆｕｌｌｗｉｄｔｈ　Ｔｉｔｌｅ",
+	"tab\tnew\nline\x00null",
+	"hep-th/9901001",
+	strings.Repeat("Very Long Title ", 20),
+	strings.Repeat("长标题", 60),
+	"Ångström-scale Über Café",
+	"Invalid \xff UTF-8",
+}
+
+func TestSanitizeFileNamePathologicalTitles(t *testing.T) {
+	for _, title := range pathologicalTitles {
+		name := SanitizeFileName(title)
+
+		if name == "" {
+			t.Errorf("%q: empty file name", title)
+			continue
+		}
+		if !utf8.ValidString(name) {
+			t.Errorf("%q: invalid UTF-8 in %q", title, name)
+		}
+		if len(name) > MaxFileNameLength {
+			t.Errorf("%q: %q is %d bytes, limit %d", title, name, len(name), MaxFileNameLength)
+		}
+		if strings.ContainsAny(name, `<>:"/\|?*#% `) {
+			t.Errorf("%q: %q contains reserved characters", title, name)
+		}
+		for _, r := range name {
+			if r < 0x20 {
+				t.Errorf("%q: %q contains control characters", title, name)
+			}
+		}
+		if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+			t.Errorf("%q: %q ends with a dot or space", title, name)
+		}
+		base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+		if reservedFileNames[base] {
+			t.Errorf("%q: %q is a reserved device name", title, name)
+		}
+		if SanitizeFileName(title) != name {
+			t.Errorf("%q: sanitizing is not deterministic", title)
+		}
+	}
+}
+
+func TestSanitizeFileNameExamples(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2301.00001", "2301.00001"},
+		{"2301.00001v2", "2301.00001v2"},
+		{"hep-th/9901001", "hep-th_9901001"},
+		{"md5_0123456789abcdef", "md5_0123456789abcdef"},
+		{"Attention: Is It All You Need? 🤔 / Part 1", "Attention_Is_It_All_You_Need_Part_1"},
+		{"CON", "_CON"},
+		{"Title...", "Title"},
+		{"深度学习：综述（第二版）", "深度学习_综述(第二版)"},
+		{"", "untitled"},
+		{"🤔", "untitled"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFileName(tt.input); got != tt.want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFileNameLongTitlesDoNotCollide(t *testing.T) {
+	prefix := strings.Repeat("A very long paper title ", 10)
+	a := SanitizeFileName(prefix + "part one")
+	b := SanitizeFileName(prefix + "part two")
+	if a == b {
+		t.Errorf("long titles with different endings collide: %q", a)
+	}
+}
+
+func TestPathologicalTitlesCanBeWritten(t *testing.T) {
+	dir := t.TempDir()
+	for _, title := range pathologicalTitles {
+		for _, suffix := range []string{".pdf", "_biling.pdf", "_latex.zip"} {
+			path := filepath.Join(dir, SanitizeFileName(title)+suffix)
+			if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+				t.Errorf("%q: cannot write %s: %v", title, path, err)
+			}
+		}
+	}
+}
+
+func TestUniqueFileName(t *testing.T) {
+	// Valid names are kept as they are
+	for _, name := range []string{"2301.12345", "2301.12345v2", "md5_0123456789abcdef"} {
+		if got := UniqueFileName(name); got != name {
+			t.Errorf("UniqueFileName(%q) = %q, want it unchanged", name, got)
+		}
+	}
+
+	// Names that sanitize to the same file name get distinct, stable hash suffixes
+	first := UniqueFileName("Paper: Part 1")
+	second := UniqueFileName("Paper? Part 1")
+	if first == second || !strings.HasPrefix(first, "Paper_Part_1_") || !strings.HasPrefix(second, "Paper_Part_1_") {
+		t.Errorf("collision not resolved: %q, %q", first, second)
+	}
+	if again := UniqueFileName("Paper? Part 1"); again != second {
+		t.Errorf("hash suffix is not stable: %q vs %q", again, second)
+	}
+
+	// Long names already carry the hash of SanitizeFileName and get no second one
+	long := strings.Repeat("Long title: ", 20)
+	if got := UniqueFileName(long); got != SanitizeFileName(long) || len(got) > MaxFileNameLength {
+		t.Errorf("UniqueFileName(long) = %q", got)
+	}
+}
+
+func TestPaperDirCollision(t *testing.T) {
+	m, err := NewResultManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title := "Attention: Is It All You Need? 🤔 / Part 1"
+	if err := m.SavePaperInfo(&PaperInfo{ArxivID: title, Title: title}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	other := "Attention? Is It All You Need / Part 1"
+	if err := m.SavePaperInfo(&PaperInfo{ArxivID: other, Title: other}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if m.GetPaperDir(title) == m.GetPaperDir(other) {
+		t.Fatal("different papers share a directory")
+	}
+
+	// The original title round-trips through metadata
+	info, err := m.LoadPaperInfo(title)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if info.Title != title {
+		t.Errorf("title = %q, want %q", info.Title, title)
+	}
+	info, err = m.LoadPaperInfo(other)
+	if err != nil || info.Title != other {
+		t.Errorf("second paper not loaded correctly: %v", err)
+	}
+}
+
+func TestPaperDirAfterDelete(t *testing.T) {
+	m, err := NewResultManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := "hep-th/9901001"
+	second := "hep-th:9901001"
+	for _, id := range []string{first, second} {
+		if err := m.SavePaperInfo(&PaperInfo{ArxivID: id, Title: id}); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+	secondDir := m.GetPaperDir(second)
+
+	// Deleting one paper must not move the other to a different directory
+	if err := m.DeletePaper(first); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if dir := m.GetPaperDir(second); dir != secondDir {
+		t.Errorf("directory of the remaining paper changed from %q to %q", secondDir, dir)
+	}
+	info, err := m.LoadPaperInfo(second)
+	if err != nil || info.ArxivID != second {
+		t.Errorf("remaining paper not loaded correctly: %v", err)
+	}
+}
+
+func TestMigrateLegacyPaperDirs(t *testing.T) {
+	baseDir := t.TempDir()
+	id := "hep-th/9901001"
+	legacyDir := filepath.Join(baseDir, "hep-th_9901001")
+	if err := os.MkdirAll(filepath.Join(legacyDir, "latex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"arxiv_id": "hep-th/9901001", "translated_pdf": ` + strconv.Quote(filepath.Join(legacyDir, "translated.pdf")) +
+		`, "source_dir": ` + strconv.Quote(filepath.Join(legacyDir, "latex")) + `}`
+	if err := os.WriteFile(filepath.Join(legacyDir, "metadata.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewResultManager(baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := m.LoadPaperInfo(id)
+	if err != nil {
+		t.Fatalf("legacy paper not found after migration: %v", err)
+	}
+	paperDir := m.GetPaperDir(id)
+	if info.TranslatedPDF != filepath.Join(paperDir, "translated.pdf") || info.SourceDir != filepath.Join(paperDir, "latex") {
+		t.Errorf("paths not moved with the directory: %q, %q", info.TranslatedPDF, info.SourceDir)
+	}
+	if _, err := os.Stat(legacyDir); !os.IsNotExist(err) {
+		t.Errorf("legacy directory still exists: %v", err)
+	}
+}
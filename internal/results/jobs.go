@@ -0,0 +1,198 @@
+package results
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// JobHeartbeatInterval is how often a running job refreshes its registry entry
+	JobHeartbeatInterval = 5 * time.Second
+	// jobStaleAfter is how long an entry without heartbeat is considered alive;
+	// entries of crashed processes expire after this
+	jobStaleAfter = 30 * time.Second
+	// jobRegistryLockTimeout is how long to wait for the registry lock
+	jobRegistryLockTimeout = 3 * time.Second
+	// jobRegistryLockStale is the age after which a lock file is assumed to be left over
+	jobRegistryLockStale = 10 * time.Second
+)
+
+// ActiveJob is a translation job registered by a running process
+type ActiveJob struct {
+	Key       string    `json:"key"`
+	Input     string    `json:"input"`
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	StartedAt time.Time `json:"started_at"`
+	Heartbeat time.Time `json:"heartbeat"`
+	Progress  int       `json:"progress"`
+	Phase     string    `json:"phase,omitempty"`
+}
+
+// IsCurrentProcess reports whether the job was registered by this process
+func (j *ActiveJob) IsCurrentProcess() bool {
+	return j.PID == os.Getpid() && j.Host == hostName()
+}
+
+// JobRegistry records the translation jobs that are currently running, in a small file
+// shared by all processes (GUI and CLI) using the same results directory. It lets a
+// process refuse to start a job that another process is already running.
+type JobRegistry struct {
+	path     string
+	lockPath string
+}
+
+// NewJobRegistry creates a job registry stored in dir
+func NewJobRegistry(dir string) *JobRegistry {
+	return &JobRegistry{
+		path:     filepath.Join(dir, "active_jobs.json"),
+		lockPath: filepath.Join(dir, "active_jobs.lock"),
+	}
+}
+
+// JobKey identifies a job by its normalized input and a hash of the options that affect
+// the result (e.g. the model), so the same paper with the same options maps to one key.
+func JobKey(input, options string) string {
+	sum := sha256.Sum256([]byte(NormalizeJobInput(input) + "\n" + options))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// NormalizeJobInput normalizes an input so different spellings of the same source match:
+// arXiv URLs and IDs become "arxiv:<id>", local paths become absolute paths.
+func NormalizeJobInput(input string) string {
+	input = strings.TrimSpace(input)
+	if id := ExtractArxivID(input); id != "" {
+		return "arxiv:" + strings.ToLower(id)
+	}
+	if abs, err := filepath.Abs(input); err == nil {
+		input = abs
+	}
+	input = filepath.Clean(input)
+	if filepath.Separator == '\\' {
+		// Windows paths are case-insensitive
+		input = strings.ToLower(input)
+	}
+	return "path:" + input
+}
+
+// Register records job as running. If another live process already runs a job with the
+// same key, nothing is recorded and that job is returned instead.
+func (r *JobRegistry) Register(job ActiveJob) (*ActiveJob, error) {
+	var existing *ActiveJob
+	err := r.update(func(jobs map[string]*ActiveJob) {
+		if other, ok := jobs[job.Key]; ok && !other.IsCurrentProcess() {
+			existing = other
+			return
+		}
+		now := time.Now()
+		job.PID = os.Getpid()
+		job.Host = hostName()
+		job.StartedAt = now
+		job.Heartbeat = now
+		jobs[job.Key] = &job
+	})
+	return existing, err
+}
+
+// Heartbeat refreshes a job registered by this process and records its progress
+func (r *JobRegistry) Heartbeat(key string, progress int, phase string) error {
+	return r.update(func(jobs map[string]*ActiveJob) {
+		if job, ok := jobs[key]; ok && job.IsCurrentProcess() {
+			job.Heartbeat = time.Now()
+			job.Progress = progress
+			job.Phase = phase
+		}
+	})
+}
+
+// Unregister removes a job registered by this process
+func (r *JobRegistry) Unregister(key string) error {
+	return r.update(func(jobs map[string]*ActiveJob) {
+		if job, ok := jobs[key]; ok && job.IsCurrentProcess() {
+			delete(jobs, key)
+		}
+	})
+}
+
+// Active returns the jobs that are currently running in any process
+func (r *JobRegistry) Active() ([]*ActiveJob, error) {
+	var active []*ActiveJob
+	err := r.update(func(jobs map[string]*ActiveJob) {
+		for _, job := range jobs {
+			active = append(active, job)
+		}
+	})
+	return active, err
+}
+
+// update loads the registry under the lock, drops expired entries, applies fn and saves it
+func (r *JobRegistry) update(fn func(jobs map[string]*ActiveJob)) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	jobs := make(map[string]*ActiveJob)
+	if data, err := os.ReadFile(r.path); err == nil {
+		if err := json.Unmarshal(data, &jobs); err != nil {
+			// A corrupt registry only loses the duplicate check, start over
+			jobs = make(map[string]*ActiveJob)
+		}
+	}
+	for key, job := range jobs {
+		if job == nil || time.Since(job.Heartbeat) > jobStaleAfter {
+			delete(jobs, key)
+		}
+	}
+
+	fn(jobs)
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0644)
+}
+
+// lock acquires the registry lock file. Returns a function that releases it.
+func (r *JobRegistry) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(r.lockPath), 0755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(jobRegistryLockTimeout)
+	for {
+		f, err := os.OpenFile(r.lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d", os.Getpid())
+			f.Close()
+			return func() { os.Remove(r.lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		// Remove locks left behind by crashed processes
+		if info, statErr := os.Stat(r.lockPath); statErr == nil && time.Since(info.ModTime()) > jobRegistryLockStale {
+			os.Remove(r.lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for job registry lock %s", r.lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// hostName returns the host name, or "unknown" if it cannot be determined
+func hostName() string {
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "unknown"
+}
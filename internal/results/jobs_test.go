@@ -0,0 +1,113 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJobKeyNormalizesInput(t *testing.T) {
+	same := []string{
+		"2301.00001",
+		" 2301.00001 ",
+		"https://arxiv.org/abs/2301.00001",
+		"arxiv.org/pdf/2301.00001",
+	}
+	want := JobKey(same[0], "model")
+	for _, input := range same[1:] {
+		if got := JobKey(input, "model"); got != want {
+			t.Errorf("JobKey(%q) = %s, want %s", input, got, want)
+		}
+	}
+	if JobKey("2301.00001", "other-model") == want {
+		t.Error("different options share a job key")
+	}
+	if JobKey("2301.00002", "model") == want {
+		t.Error("different papers share a job key")
+	}
+}
+
+// writeForeignJob stores a job as if it was registered by another process
+func writeForeignJob(t *testing.T, r *JobRegistry, job *ActiveJob) {
+	t.Helper()
+	data, err := json.Marshal(map[string]*ActiveJob{job.Key: job})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(r.path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobRegistryRefusesJobOfOtherProcess(t *testing.T) {
+	r := NewJobRegistry(t.TempDir())
+	key := JobKey("2301.00001", "")
+	writeForeignJob(t, r, &ActiveJob{
+		Key:       key,
+		PID:       os.Getpid() + 1,
+		Host:      "other-host",
+		Heartbeat: time.Now(),
+		Progress:  40,
+	})
+
+	other, err := r.Register(ActiveJob{Key: key, Input: "2301.00001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == nil || other.Progress != 40 || other.Host != "other-host" {
+		t.Fatalf("expected the other process's job, got %+v", other)
+	}
+
+	// A different paper is not affected
+	other, err = r.Register(ActiveJob{Key: JobKey("2301.00002", "")})
+	if err != nil || other != nil {
+		t.Fatalf("unrelated job refused: %+v, %v", other, err)
+	}
+}
+
+func TestJobRegistryExpiresStaleJobs(t *testing.T) {
+	r := NewJobRegistry(t.TempDir())
+	key := JobKey("2301.00001", "")
+	writeForeignJob(t, r, &ActiveJob{
+		Key:       key,
+		PID:       os.Getpid() + 1,
+		Host:      "crashed-host",
+		Heartbeat: time.Now().Add(-2 * jobStaleAfter),
+	})
+
+	other, err := r.Register(ActiveJob{Key: key})
+	if err != nil || other != nil {
+		t.Fatalf("stale job not expired: %+v, %v", other, err)
+	}
+}
+
+func TestJobRegistryLifecycle(t *testing.T) {
+	r := NewJobRegistry(t.TempDir())
+	key := JobKey("2301.00001", "")
+
+	if other, err := r.Register(ActiveJob{Key: key, Input: "2301.00001"}); err != nil || other != nil {
+		t.Fatalf("register failed: %+v, %v", other, err)
+	}
+	if err := r.Heartbeat(key, 55, "translating"); err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := r.Active()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].Progress != 55 || !active[0].IsCurrentProcess() {
+		t.Fatalf("unexpected active jobs: %+v", active)
+	}
+
+	if err := r.Unregister(key); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := r.Active(); len(active) != 0 {
+		t.Errorf("job still active after unregister: %+v", active)
+	}
+	if _, err := os.Stat(r.lockPath); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
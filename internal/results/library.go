@@ -0,0 +1,179 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LibraryPaper is a paper of the library with the disk space of its directory
+type LibraryPaper struct {
+	*PaperInfo
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// LibraryPage is one page of the papers of the library matching a query, newest first
+type LibraryPage struct {
+	Papers   []LibraryPaper `json:"papers"`
+	Total    int            `json:"total"` // papers matching the query on all pages
+	Page     int            `json:"page"`  // 1-based
+	PageSize int            `json:"page_size"`
+}
+
+// LibraryStats summarizes the library
+type LibraryStats struct {
+	TotalPapers    int   `json:"total_papers"`
+	CompletePapers int   `json:"complete_papers"`
+	DiskUsage      int64 `json:"disk_usage"` // bytes of the results directory
+}
+
+// UsageStats sums the token usage recorded by the papers of the library. Failed and
+// cancelled translations count too: their tokens were paid for as well.
+type UsageStats struct {
+	Since             time.Time         `json:"since"`  // zero for the whole library
+	Papers            int               `json:"papers"` // papers with recorded usage
+	InputTokens       int               `json:"input_tokens"`
+	OutputTokens      int               `json:"output_tokens"`
+	TotalTokens       int               `json:"total_tokens"`
+	EstimatedCost     float64           `json:"estimated_cost"` // US dollars
+	AvgTokensPerPaper int               `json:"avg_tokens_per_paper"`
+	AvgCostPerPaper   float64           `json:"avg_cost_per_paper"`
+	Models            []ModelUsageStats `json:"models"` // by model, the most tokens first
+}
+
+// ModelUsageStats is the usage of the papers translated with one model
+type ModelUsageStats struct {
+	Model         string  `json:"model"`
+	Papers        int     `json:"papers"`
+	InputTokens   int     `json:"input_tokens"`
+	OutputTokens  int     `json:"output_tokens"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// SearchPapers returns page (1-based) of the papers whose title contains query or whose
+// arXiv ID or source file name matches it, ignoring case. An empty query matches all
+// papers. Pages past the end are empty.
+func (m *ResultManager) SearchPapers(page, pageSize int, query string) (*LibraryPage, error) {
+	papers, err := m.ListPapers()
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 1
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []*PaperInfo
+	for _, p := range papers {
+		if query == "" || paperMatches(p, query) {
+			matches = append(matches, p)
+		}
+	}
+
+	result := &LibraryPage{Papers: []LibraryPaper{}, Total: len(matches), Page: page, PageSize: pageSize}
+	start := (page - 1) * pageSize
+	if start >= len(matches) {
+		return result, nil
+	}
+	end := min(start+pageSize, len(matches))
+	for _, p := range matches[start:end] {
+		result.Papers = append(result.Papers, LibraryPaper{PaperInfo: p, SizeBytes: dirSize(m.GetPaperDir(p.ArxivID))})
+	}
+	return result, nil
+}
+
+// paperMatches reports whether a paper matches a lower-case query
+func paperMatches(p *PaperInfo, query string) bool {
+	if strings.Contains(strings.ToLower(p.Title), query) || strings.Contains(strings.ToLower(p.SourceFileName), query) {
+		return true
+	}
+	// IDs also match when the query is an arXiv URL or a versioned ID
+	id := strings.ToLower(p.ArxivID)
+	if strings.Contains(id, query) {
+		return true
+	}
+	if queryID := strings.ToLower(ExtractArxivID(query)); queryID != "" {
+		return queryID == id || strings.HasPrefix(queryID, id+"v")
+	}
+	return false
+}
+
+// Stats returns the number of papers of the library and the disk space it takes
+func (m *ResultManager) Stats() (*LibraryStats, error) {
+	papers, err := m.ListPapers()
+	if err != nil {
+		return nil, err
+	}
+	stats := &LibraryStats{TotalPapers: len(papers), DiskUsage: dirSize(m.baseDir)}
+	for _, p := range papers {
+		if p.Status == StatusComplete {
+			stats.CompletePapers++
+		}
+	}
+	return stats, nil
+}
+
+// UsageStats sums the token usage of the papers translated at or after since; a zero
+// since covers the whole library. Papers translated before usage was recorded are left out.
+func (m *ResultManager) UsageStats(since time.Time) (*UsageStats, error) {
+	papers, err := m.ListPapers()
+	if err != nil {
+		return nil, err
+	}
+	stats := &UsageStats{Since: since, Models: []ModelUsageStats{}}
+	byModel := make(map[string]*ModelUsageStats)
+	for _, p := range papers {
+		if p.InputTokens+p.OutputTokens == 0 || (!since.IsZero() && p.TranslatedAt.Before(since)) {
+			continue
+		}
+		stats.Papers++
+		stats.InputTokens += p.InputTokens
+		stats.OutputTokens += p.OutputTokens
+		stats.EstimatedCost += p.EstimatedCost
+
+		model := byModel[p.Model]
+		if model == nil {
+			model = &ModelUsageStats{Model: p.Model}
+			byModel[p.Model] = model
+		}
+		model.Papers++
+		model.InputTokens += p.InputTokens
+		model.OutputTokens += p.OutputTokens
+		model.EstimatedCost += p.EstimatedCost
+	}
+	stats.TotalTokens = stats.InputTokens + stats.OutputTokens
+	if stats.Papers > 0 {
+		stats.AvgTokensPerPaper = stats.TotalTokens / stats.Papers
+		stats.AvgCostPerPaper = stats.EstimatedCost / float64(stats.Papers)
+	}
+
+	for _, model := range byModel {
+		stats.Models = append(stats.Models, *model)
+	}
+	sort.Slice(stats.Models, func(i, j int) bool {
+		ti := stats.Models[i].InputTokens + stats.Models[i].OutputTokens
+		tj := stats.Models[j].InputTokens + stats.Models[j].OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		return stats.Models[i].Model < stats.Models[j].Model
+	})
+	return stats, nil
+}
+
+// dirSize returns the total size of the files under dir; unreadable files are not counted
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,120 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newLibrary returns a result manager holding three papers, the newest first
+func newLibrary(t *testing.T) *ResultManager {
+	t.Helper()
+	m, err := NewResultManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, info := range []*PaperInfo{
+		{ArxivID: "2301.00001", Title: "Attention Is All You Need", Status: StatusComplete},
+		{ArxivID: "2302.00002", Title: "Diffusion Models Beat GANs", Status: StatusError},
+		{ArxivID: "hep-th/9901001", Title: "String Theory Notes", Status: StatusComplete},
+	} {
+		info.TranslatedAt = now.Add(-time.Duration(i) * time.Hour)
+		if err := m.SavePaperInfo(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(m.GetPaperDir("2301.00001"), "translated.pdf"), make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSearchPapers(t *testing.T) {
+	m := newLibrary(t)
+
+	page, err := m.SearchPapers(1, 2, "")
+	if err != nil {
+		t.Fatalf("SearchPapers() error: %v", err)
+	}
+	if page.Total != 3 || len(page.Papers) != 2 || page.Papers[0].ArxivID != "2301.00001" {
+		t.Errorf("first page = %+v", page)
+	}
+	if page.Papers[0].SizeBytes < 1000 {
+		t.Errorf("size of %s = %d, want its files", page.Papers[0].ArxivID, page.Papers[0].SizeBytes)
+	}
+	if page, _ := m.SearchPapers(2, 2, ""); len(page.Papers) != 1 || page.Papers[0].ArxivID != "hep-th/9901001" {
+		t.Errorf("second page = %+v", page)
+	}
+	if page, _ := m.SearchPapers(5, 2, ""); page.Total != 3 || len(page.Papers) != 0 {
+		t.Errorf("page past the end = %+v", page)
+	}
+
+	for query, want := range map[string]string{
+		"diffusion":                          "2302.00002",
+		"2301.000":                           "2301.00001",
+		"https://arxiv.org/abs/2301.00001v2": "2301.00001",
+		"HEP-TH":                             "hep-th/9901001",
+	} {
+		page, _ := m.SearchPapers(1, 10, query)
+		if page.Total != 1 || page.Papers[0].ArxivID != want {
+			t.Errorf("query %q matched %+v, want %s", query, page.Papers, want)
+		}
+	}
+	if page, _ := m.SearchPapers(1, 10, "quantum"); page.Total != 0 {
+		t.Errorf("query without matches returned %+v", page.Papers)
+	}
+}
+
+func TestLibraryStats(t *testing.T) {
+	m := newLibrary(t)
+	stats, err := m.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error: %v", err)
+	}
+	if stats.TotalPapers != 3 || stats.CompletePapers != 2 || stats.DiskUsage < 1000 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	if err := m.DeletePaper("2301.00001"); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := m.Stats(); stats.TotalPapers != 2 || stats.DiskUsage >= 1000 {
+		t.Errorf("Stats() after deleting = %+v", stats)
+	}
+}
+
+func TestUsageStats(t *testing.T) {
+	m := newLibrary(t)
+	now := time.Now()
+	for _, info := range []*PaperInfo{
+		{ArxivID: "2401.00001", Status: StatusComplete, TranslatedAt: now, Model: "gpt-4o", InputTokens: 8000, OutputTokens: 4000, EstimatedCost: 0.06},
+		{ArxivID: "2401.00002", Status: StatusError, TranslatedAt: now.Add(-time.Hour), Model: "gpt-4o", InputTokens: 2000, OutputTokens: 0, EstimatedCost: 0.005},
+		{ArxivID: "2312.00003", Status: StatusComplete, TranslatedAt: now.AddDate(0, -1, 0), Model: "deepseek-chat", InputTokens: 10000, OutputTokens: 6000, EstimatedCost: 0.01},
+	} {
+		if err := m.SavePaperInfo(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The papers of newLibrary have no recorded usage
+	stats, err := m.UsageStats(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Papers != 3 || stats.InputTokens != 20000 || stats.OutputTokens != 10000 || stats.TotalTokens != 30000 || stats.AvgTokensPerPaper != 10000 {
+		t.Errorf("UsageStats() = %+v", stats)
+	}
+	if len(stats.Models) != 2 || stats.Models[0].Model != "deepseek-chat" || stats.Models[1].Papers != 2 {
+		t.Errorf("models = %+v", stats.Models)
+	}
+
+	stats, _ = m.UsageStats(now.AddDate(0, 0, -7))
+	if stats.Papers != 2 || stats.TotalTokens != 14000 || len(stats.Models) != 1 {
+		t.Errorf("UsageStats(last week) = %+v", stats)
+	}
+	if cost := stats.EstimatedCost; cost < 0.0649 || cost > 0.0651 {
+		t.Errorf("cost = %v, want 0.065", cost)
+	}
+}
@@ -4,6 +4,7 @@ package results
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"latex-translator/internal/types"
 )
 
 // TranslationStatus represents the status of a translation
@@ -37,6 +40,12 @@ const (
 	StatusComplete TranslationStatus = "complete"
 	// StatusError indicates an error occurred during translation
 	StatusError TranslationStatus = "error"
+	// StatusNeedsManualFix indicates the automatic fixes were skipped; the translated
+	// LaTeX source is kept for hand editing and recompiling
+	StatusNeedsManualFix TranslationStatus = "needs_manual_fix"
+	// StatusCancelled indicates the job was cancelled; the finished work (source, translation
+	// checkpoint, original PDF) is kept and ContinueTranslation resumes it
+	StatusCancelled TranslationStatus = "cancelled"
 )
 
 // SourceType represents the type of source for translation
@@ -67,12 +76,37 @@ type PaperInfo struct {
 	LastPhase      string            `json:"last_phase,omitempty"`
 	OriginalInput  string            `json:"original_input,omitempty"`
 	MainTexFile    string            `json:"main_tex_file,omitempty"`
+	MainTexFallbackFrom string       `json:"main_tex_fallback_from,omitempty"` // 自动切换主文件前的首选文件
 	// Source identification fields
 	SourceType     SourceType        `json:"source_type,omitempty"`
 	SourceMD5      string            `json:"source_md5,omitempty"`      // MD5 hash of source file (zip or PDF)
 	SourceFileName string            `json:"source_file_name,omitempty"` // Original file name
+	ChineseVariant string            `json:"chinese_variant,omitempty"`  // Script of the translation (zh-Hans or zh-Hant); empty means zh-Hans
+	Origin         string            `json:"origin,omitempty"`           // What produced the entry: OriginBatch, or empty for the app
+	TranslationMode string           `json:"translation_mode,omitempty"` // TranslationModeQuick, TranslationModeUncompiled, or empty for a full-quality translation
+	TranslatedHTML string            `json:"translated_html,omitempty"`  // HTML export of an uncompiled translation
+	// Token usage of the job, retries and the validator and compile fixer requests included
+	InputTokens    int               `json:"input_tokens,omitempty"`
+	OutputTokens   int               `json:"output_tokens,omitempty"`
+	Model          string            `json:"model,omitempty"`          // Model of the translation
+	EstimatedCost  float64           `json:"estimated_cost,omitempty"` // US dollars by the price table; 0 when the price is unknown
+	// Chunks that kept their original text because they could not be translated, by tex
+	// file relative to SourceDir; they can be retried alone with RetryFailedChunks
+	FailedChunks   map[string][]int  `json:"failed_chunks,omitempty"`
 }
 
+// OriginBatch marks library entries produced by the batch processing tool
+const OriginBatch = "batch"
+
+// TranslationModeQuick marks library entries translated in quick mode; a later full-quality
+// translation replaces them
+const TranslationModeQuick = "quick"
+
+// TranslationModeUncompiled marks library entries translated without compiling (no TeX
+// distribution or --no-compile): they have the translated tex and an HTML export, but no
+// translated PDF
+const TranslationModeUncompiled = "uncompiled"
+
 // ResultManager manages translation results stored in user directory
 type ResultManager struct {
 	baseDir string // Base directory for storing results (e.g., ~/latex-translator-results)
@@ -94,7 +128,9 @@ func NewResultManager(baseDir string) (*ResultManager, error) {
 		return nil, err
 	}
 
-	return &ResultManager{baseDir: baseDir}, nil
+	m := &ResultManager{baseDir: baseDir}
+	m.migrateLegacyPaperDirs()
+	return m, nil
 }
 
 // GetBaseDir returns the base directory for results
@@ -104,9 +140,64 @@ func (m *ResultManager) GetBaseDir() string {
 
 // GetPaperDir returns the directory path for a specific paper
 func (m *ResultManager) GetPaperDir(arxivID string) string {
-	// Sanitize arxiv ID for use as directory name
-	safeID := sanitizeArxivID(arxivID)
-	return filepath.Join(m.baseDir, safeID)
+	// IDs that are not valid directory names get a hash suffix, so IDs that sanitize to
+	// the same name never share a directory
+	return filepath.Join(m.baseDir, UniqueFileName(arxivID))
+}
+
+// legacyPaperDirNames returns the directory names earlier versions derived from a paper ID
+func legacyPaperDirNames(arxivID string) []string {
+	return []string{
+		strings.NewReplacer("/", "_", ":", "_", "\\", "_").Replace(arxivID),
+		SanitizeFileName(arxivID),
+	}
+}
+
+// migrateLegacyPaperDirs renames paper directories named by an earlier version of
+// GetPaperDir to their current name, so their papers can still be found by ID.
+// The paths recorded in the metadata are moved along with the directory.
+func (m *ResultManager) migrateLegacyPaperDirs() {
+	entries, err := os.ReadDir(m.baseDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		oldDir := filepath.Join(m.baseDir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(oldDir, "metadata.json"))
+		if err != nil {
+			continue
+		}
+		var info PaperInfo
+		if err := json.Unmarshal(data, &info); err != nil || info.ArxivID == "" {
+			continue
+		}
+		newDir := m.GetPaperDir(info.ArxivID)
+		if newDir == oldDir {
+			continue
+		}
+		legacy := false
+		for _, name := range legacyPaperDirNames(info.ArxivID) {
+			legacy = legacy || name == entry.Name()
+		}
+		if !legacy {
+			continue
+		}
+		if _, err := os.Stat(newDir); err == nil {
+			continue
+		}
+		if err := os.Rename(oldDir, newDir); err != nil {
+			continue
+		}
+		for _, path := range []*string{&info.OriginalPDF, &info.TranslatedPDF, &info.BilingualPDF, &info.SourceDir, &info.TranslatedHTML} {
+			if rel, err := filepath.Rel(oldDir, *path); *path != "" && err == nil && !strings.HasPrefix(rel, "..") {
+				*path = filepath.Join(newDir, rel)
+			}
+		}
+		m.SavePaperInfo(&info)
+	}
 }
 
 // SavePaperInfo saves paper metadata to the paper's directory
@@ -158,7 +249,7 @@ func (m *ResultManager) ListPapers() ([]*PaperInfo, error) {
 
 	var papers []*PaperInfo
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() || isImportScratchDir(entry.Name()) {
 			continue
 		}
 
@@ -262,18 +353,115 @@ func (m *ResultManager) GetBilingualPDFPath(arxivID string) string {
 	return filepath.Join(m.GetPaperDir(arxivID), "bilingual.pdf")
 }
 
+// GetTranslatedHTMLPath returns the path to the HTML export of an uncompiled translation
+func (m *ResultManager) GetTranslatedHTMLPath(arxivID string) string {
+	return filepath.Join(m.GetPaperDir(arxivID), "translated.html")
+}
+
 // GetLatexSourceDir returns the path to the LaTeX source directory
 func (m *ResultManager) GetLatexSourceDir(arxivID string) string {
 	return filepath.Join(m.GetPaperDir(arxivID), "latex")
 }
 
-// sanitizeArxivID converts an arXiv ID to a safe directory name
-func sanitizeArxivID(arxivID string) string {
-	// Replace characters that might cause issues in file paths
-	safe := strings.ReplaceAll(arxivID, "/", "_")
-	safe = strings.ReplaceAll(safe, ":", "_")
-	safe = strings.ReplaceAll(safe, "\\", "_")
-	return safe
+// OriginalSourcesDirName is the directory (inside the LaTeX source directory) holding
+// the original content of input files that were overwritten by their translation
+const OriginalSourcesDirName = ".original"
+
+// OriginalSourceSuffix is appended to backed-up original files so they are not
+// picked up as .tex sources when the directory is scanned
+const OriginalSourceSuffix = ".orig"
+
+// LoadTranslationPairs loads the original/translated content of every file of a
+// previously translated paper, keyed by slash-separated path relative to the LaTeX
+// source directory. Used to bootstrap translating a new version of the paper.
+func (m *ResultManager) LoadTranslationPairs(arxivID string) (map[string]*types.TranslationPair, error) {
+	info, err := m.LoadPaperInfo(arxivID)
+	if err != nil {
+		return nil, err
+	}
+	if !info.HasLatexSource {
+		return nil, fmt.Errorf("no LaTeX source saved for %s", arxivID)
+	}
+
+	latexDir := info.SourceDir
+	if latexDir == "" {
+		latexDir = m.GetLatexSourceDir(arxivID)
+	}
+
+	pairs := make(map[string]*types.TranslationPair)
+
+	// Main file: the original is kept, the translation is saved with a "translated_" prefix
+	if info.MainTexFile != "" {
+		original, origErr := os.ReadFile(filepath.Join(latexDir, info.MainTexFile))
+		translatedPath := filepath.Join(latexDir, filepath.Dir(info.MainTexFile), "translated_"+filepath.Base(info.MainTexFile))
+		translated, transErr := os.ReadFile(translatedPath)
+		if origErr == nil && transErr == nil {
+			pairs[filepath.ToSlash(info.MainTexFile)] = &types.TranslationPair{
+				Original:   string(original),
+				Translated: string(translated),
+			}
+		}
+	}
+
+	// Input files: translated in place, originals backed up under OriginalSourcesDirName
+	backupDir := filepath.Join(latexDir, OriginalSourcesDirName)
+	filepath.Walk(backupDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !strings.HasSuffix(path, OriginalSourceSuffix) {
+			return nil
+		}
+		rel, relErr := filepath.Rel(backupDir, strings.TrimSuffix(path, OriginalSourceSuffix))
+		if relErr != nil {
+			return nil
+		}
+		original, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return nil
+		}
+		translated, readErr := os.ReadFile(filepath.Join(latexDir, rel))
+		if readErr != nil {
+			return nil
+		}
+		pairs[filepath.ToSlash(rel)] = &types.TranslationPair{
+			Original:   string(original),
+			Translated: string(translated),
+		}
+		return nil
+	})
+
+	return pairs, nil
+}
+
+// GetProvenancePath returns the path to the provenance record of a paper
+func (m *ResultManager) GetProvenancePath(arxivID string) string {
+	return filepath.Join(m.GetPaperDir(arxivID), "provenance.json")
+}
+
+// SaveProvenance saves the provenance record alongside the paper's metadata
+func (m *ResultManager) SaveProvenance(arxivID string, provenance *types.Provenance) error {
+	if provenance == nil {
+		return nil
+	}
+	if err := os.MkdirAll(m.GetPaperDir(arxivID), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.GetProvenancePath(arxivID), data, 0644)
+}
+
+// LoadProvenance loads the provenance record of a paper
+func (m *ResultManager) LoadProvenance(arxivID string) (*types.Provenance, error) {
+	data, err := os.ReadFile(m.GetProvenancePath(arxivID))
+	if err != nil {
+		return nil, err
+	}
+	var provenance types.Provenance
+	if err := json.Unmarshal(data, &provenance); err != nil {
+		return nil, err
+	}
+	return &provenance, nil
 }
 
 // ExtractArxivID extracts arXiv ID from various input formats
@@ -419,6 +607,22 @@ func CalculateFileMD5(filePath string) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// CalculateFileSHA256 calculates the SHA256 hash of a file
+func CalculateFileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // FindByMD5 finds a paper by its source file MD5 hash
 func (m *ResultManager) FindByMD5(md5Hash string) (*PaperInfo, error) {
 	papers, err := m.ListPapers()
@@ -550,6 +754,14 @@ func (m *ResultManager) CheckExistingTranslation(input string, sourceType Source
 		info.IsComplete = false
 		info.CanContinue = true
 		info.Message = fmt.Sprintf("该文档翻译失败: %s，可以继续尝试", paper.ErrorMessage)
+	case StatusNeedsManualFix:
+		info.IsComplete = false
+		info.CanContinue = true
+		info.Message = "该文档已翻译，但编译错误需要手动修复，修复后可重新编译译文"
+	case StatusCancelled:
+		info.IsComplete = false
+		info.CanContinue = true
+		info.Message = "该文档翻译已取消，已完成的部分已保存，可以继续"
 	default:
 		info.IsComplete = false
 		info.CanContinue = true
@@ -0,0 +1,98 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResumeEntry is a job interrupted by closing the application. Jobs continued from the
+// library have a LibraryID; the others are started again from their Input, which continues
+// them from the library entry saved on cancellation. A job that was still waiting for the
+// user's confirmation is confirmed again before it starts.
+type ResumeEntry struct {
+	Input             string    `json:"input,omitempty"`
+	LibraryID         string    `json:"library_id,omitempty"`
+	Phase             string    `json:"phase,omitempty"`
+	NeedsConfirmation bool      `json:"needs_confirmation,omitempty"`
+	InterruptedAt     time.Time `json:"interrupted_at"`
+}
+
+// key identifies the job of the entry
+func (e ResumeEntry) key() string {
+	if e.LibraryID != "" {
+		return "library:" + e.LibraryID
+	}
+	return NormalizeJobInput(e.Input)
+}
+
+// ResumeQueue holds the jobs interrupted by closing the application, so the next launch
+// resumes them
+type ResumeQueue struct {
+	path string
+}
+
+// NewResumeQueue creates a resume queue stored in dir
+func NewResumeQueue(dir string) *ResumeQueue {
+	return &ResumeQueue{path: filepath.Join(dir, "resume_queue.json")}
+}
+
+// Add appends entries to the queue; entries of jobs already queued replace them
+func (q *ResumeQueue) Add(entries ...ResumeEntry) error {
+	queued, err := q.load()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		replaced := false
+		for i := range queued {
+			if queued[i].key() == entry.key() {
+				queued[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			queued = append(queued, entry)
+		}
+	}
+
+	data, err := json.MarshalIndent(queued, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// Take returns the queued entries in order and empties the queue
+func (q *ResumeQueue) Take() ([]ResumeEntry, error) {
+	queued, err := q.load()
+	if err != nil || len(queued) == 0 {
+		return nil, err
+	}
+	if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return queued, nil
+}
+
+// load reads the queue; a missing file is an empty queue
+func (q *ResumeQueue) load() ([]ResumeEntry, error) {
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var queued []ResumeEntry
+	if err := json.Unmarshal(data, &queued); err != nil {
+		return nil, err
+	}
+	return queued, nil
+}
@@ -0,0 +1,40 @@
+package results
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResumeQueueKeepsInterruptedJobsUntilTaken(t *testing.T) {
+	q := NewResumeQueue(t.TempDir())
+	now := time.Now()
+
+	if err := q.Add(
+		ResumeEntry{Input: "https://arxiv.org/abs/2301.00001", Phase: "translating", InterruptedAt: now},
+		ResumeEntry{LibraryID: "2302.00002", InterruptedAt: now},
+	); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	// The same paper under another spelling replaces its entry
+	if err := q.Add(ResumeEntry{Input: "2301.00001", Phase: "compiling", InterruptedAt: now}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+
+	entries, err := q.Take()
+	if err != nil {
+		t.Fatalf("Take() error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Take() returned %d entries, want 2: %+v", len(entries), entries)
+	}
+	if entries[0].Input != "2301.00001" || entries[0].Phase != "compiling" {
+		t.Errorf("first entry = %+v, want the replaced 2301.00001 entry", entries[0])
+	}
+	if entries[1].LibraryID != "2302.00002" {
+		t.Errorf("second entry = %+v, want library entry 2302.00002", entries[1])
+	}
+
+	if entries, err := q.Take(); err != nil || len(entries) != 0 {
+		t.Errorf("second Take() = %+v, %v, want an empty queue", entries, err)
+	}
+}
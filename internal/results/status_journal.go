@@ -0,0 +1,131 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalEntry is the last status of the job in flight. It outlives a crash of the
+// application, so the next launch can offer to continue the job from its work directory.
+type JournalEntry struct {
+	Input       string    `json:"input,omitempty"`
+	ArxivID     string    `json:"arxiv_id,omitempty"`
+	Phase       string    `json:"phase"`
+	Progress    int       `json:"progress"` // 0-100
+	Message     string    `json:"message,omitempty"`
+	WorkDir     string    `json:"work_dir,omitempty"`
+	MainTexFile string    `json:"main_tex_file,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// StatusJournal persists the status of the job in flight on every update and is cleared
+// when the job returns, so a journal left behind at startup is a job interrupted by a
+// crash. A nil StatusJournal records nothing. All methods are safe for concurrent use.
+type StatusJournal struct {
+	path  string
+	mu    sync.Mutex
+	entry *JournalEntry // job in flight, nil between jobs
+}
+
+// NewStatusJournal creates a status journal stored in dir
+func NewStatusJournal(dir string) *StatusJournal {
+	return &StatusJournal{path: filepath.Join(dir, "status_journal.json")}
+}
+
+// Begin starts the journal of a new job
+func (j *StatusJournal) Begin(input, arxivID string) {
+	if j == nil {
+		return
+	}
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entry = &JournalEntry{Input: input, ArxivID: arxivID, StartedAt: now, UpdatedAt: now}
+	j.write()
+}
+
+// SetWorkDir records where the job keeps its sources once they are extracted
+func (j *StatusJournal) SetWorkDir(workDir, mainTexFile string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entry == nil {
+		return
+	}
+	j.entry.WorkDir = workDir
+	j.entry.MainTexFile = mainTexFile
+	j.write()
+}
+
+// Record saves the current status of the job; it does nothing between jobs
+func (j *StatusJournal) Record(phase string, progress int, message string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entry == nil {
+		return
+	}
+	j.entry.Phase = phase
+	j.entry.Progress = progress
+	j.entry.Message = message
+	j.entry.UpdatedAt = time.Now()
+	j.write()
+}
+
+// Clear ends the journal of the job and removes the file
+func (j *StatusJournal) Clear() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entry = nil
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Load reads the journal left behind by the previous process; nil when it ended cleanly
+func (j *StatusJournal) Load() (*JournalEntry, error) {
+	if j == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry JournalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// write replaces the file with the current entry; the caller holds j.mu. Failures are
+// ignored: the journal only helps recovering after a crash.
+func (j *StatusJournal) write() {
+	data, err := json.MarshalIndent(j.entry, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, j.path)
+}
@@ -0,0 +1,59 @@
+package results
+
+import (
+	"testing"
+)
+
+func TestStatusJournalSurvivesUntilCleared(t *testing.T) {
+	dir := t.TempDir()
+	j := NewStatusJournal(dir)
+
+	// Nothing is recorded between jobs
+	j.Record("translating", 10, "翻译中")
+	if entry, err := j.Load(); err != nil || entry != nil {
+		t.Fatalf("Load() before Begin = %+v, %v, want nothing", entry, err)
+	}
+
+	j.Begin("https://arxiv.org/abs/2301.00001", "2301.00001")
+	j.SetWorkDir("/tmp/work/2301.00001", "main.tex")
+	j.Record("translating", 62, "翻译中 (5/8)")
+
+	// A new process reads the journal the crashed one left behind
+	entry, err := NewStatusJournal(dir).Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if entry == nil {
+		t.Fatal("Load() = nil, want the job in flight")
+	}
+	if entry.ArxivID != "2301.00001" || entry.Phase != "translating" || entry.Progress != 62 ||
+		entry.WorkDir != "/tmp/work/2301.00001" || entry.MainTexFile != "main.tex" {
+		t.Errorf("Load() = %+v", entry)
+	}
+	if entry.StartedAt.IsZero() || entry.UpdatedAt.Before(entry.StartedAt) {
+		t.Errorf("times = %s, %s", entry.StartedAt, entry.UpdatedAt)
+	}
+
+	if err := j.Clear(); err != nil {
+		t.Fatalf("Clear() error: %v", err)
+	}
+	if entry, err := j.Load(); err != nil || entry != nil {
+		t.Errorf("Load() after Clear = %+v, %v, want nothing", entry, err)
+	}
+	j.Record("compiling", 80, "编译中")
+	if entry, err := j.Load(); err != nil || entry != nil {
+		t.Errorf("Load() after a record following Clear = %+v, %v, want nothing", entry, err)
+	}
+}
+
+func TestNilStatusJournalRecordsNothing(t *testing.T) {
+	var j *StatusJournal
+	j.Begin("2301.00001", "2301.00001")
+	j.Record("translating", 10, "")
+	if entry, err := j.Load(); err != nil || entry != nil {
+		t.Errorf("Load() = %+v, %v", entry, err)
+	}
+	if err := j.Clear(); err != nil {
+		t.Errorf("Clear() error: %v", err)
+	}
+}
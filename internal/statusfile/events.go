@@ -0,0 +1,134 @@
+package statusfile
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress event types written by EventWriter
+const (
+	EventStatus   = "status"
+	EventComplete = "complete"
+	EventError    = "error"
+)
+
+// Event is one line of the newline-delimited JSON progress stream of --progress-format json.
+// Like the status file, fields are only ever added.
+type Event struct {
+	Event       string            `json:"event"` // status, complete or error
+	Mode        string            `json:"mode"`  // arxiv, pdf or book
+	Phase       string            `json:"phase"`
+	Progress    int               `json:"progress"` // 0-100
+	Message     string            `json:"message"`
+	File        string            `json:"file,omitempty"`
+	Chunk       int               `json:"chunk,omitempty"`
+	TotalChunks int               `json:"total_chunks,omitempty"`
+	TokensUsed  int               `json:"tokens_used"`
+	Outputs     map[string]string `json:"outputs,omitempty"`   // complete: output paths by kind
+	Warnings    []string          `json:"warnings,omitempty"`  // complete and error
+	Error       string            `json:"error,omitempty"`     // error: failure reason
+	Code        string            `json:"code,omitempty"`      // error: error code, if known
+	ExitCode    int               `json:"exit_code,omitempty"` // error: exit code of the process
+	Time        time.Time         `json:"time"`
+}
+
+// EventWriter writes progress events as newline-delimited JSON, one event per line.
+// A nil EventWriter writes nothing, so callers need not check whether the stream is on.
+// All methods are safe for concurrent use.
+type EventWriter struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	last Event // last status event, to skip unchanged ones
+	done bool  // a terminal event was written
+}
+
+// NewEventWriter creates an event writer writing to out
+func NewEventWriter(out io.Writer) *EventWriter {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	return &EventWriter{enc: enc}
+}
+
+// Follow writes a status event whenever the status written by w changes while the job
+// runs. It sets w.OnWrite and must be called before w.Start.
+func (e *EventWriter) Follow(w *Writer) {
+	if e == nil {
+		return
+	}
+	w.OnWrite = func(s Status) {
+		if s.State == StateRunning {
+			e.Status(s)
+		}
+	}
+}
+
+// Status writes a status event, unless nothing shown in it changed since the last one
+func (e *EventWriter) Status(s Status) {
+	if e == nil {
+		return
+	}
+	event := eventFromStatus(EventStatus, s)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done || (event.Phase == e.last.Phase && event.Progress == e.last.Progress && event.Message == e.last.Message &&
+		event.File == e.last.File && event.Chunk == e.last.Chunk && event.TotalChunks == e.last.TotalChunks) {
+		return
+	}
+	e.last = event
+	e.enc.Encode(event)
+}
+
+// Complete writes the terminal event of a successful job with its output paths
+func (e *EventWriter) Complete(s Status, outputs map[string]string) {
+	if e == nil {
+		return
+	}
+	event := eventFromStatus(EventComplete, s)
+	event.Progress = 100
+	event.Outputs = outputs
+	event.Warnings = s.Warnings
+	e.terminal(event)
+}
+
+// Fail writes the terminal event of a failed job; code is the error code, if known, and
+// exitCode the exit code the process ends with
+func (e *EventWriter) Fail(s Status, err error, code string, exitCode int) {
+	if e == nil {
+		return
+	}
+	event := eventFromStatus(EventError, s)
+	event.Error = err.Error()
+	event.Code = code
+	event.ExitCode = exitCode
+	event.Warnings = s.Warnings
+	e.terminal(event)
+}
+
+// terminal writes a complete or error event; only the first one of a job is written
+func (e *EventWriter) terminal(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done {
+		return
+	}
+	e.done = true
+	e.enc.Encode(event)
+}
+
+// eventFromStatus returns an event of the given type with the progress of s
+func eventFromStatus(eventType string, s Status) Event {
+	return Event{
+		Event:       eventType,
+		Mode:        s.Mode,
+		Phase:       s.Phase,
+		Progress:    s.Percent,
+		Message:     s.Message,
+		File:        s.File,
+		Chunk:       s.Chunk,
+		TotalChunks: s.TotalChunks,
+		TokensUsed:  s.TokensUsed,
+		Time:        time.Now(),
+	}
+}
@@ -0,0 +1,88 @@
+package statusfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readEvents(t *testing.T, out *bytes.Buffer) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line is not valid JSON: %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventWriterFollowsStatus(t *testing.T) {
+	var out bytes.Buffer
+	events := NewEventWriter(&out)
+	w := NewWriter("", "arxiv", "2301.00001")
+	events.Follow(w)
+
+	w.Start(time.Hour, nil) // nothing to show yet: no event
+	w.Update(func(s *Status) {
+		s.Phase = "translating"
+		s.Percent = 57
+		s.Message = "翻译中 <块 3/5>"
+	})
+	w.Flush()
+	w.Flush() // unchanged: no event
+	w.Update(func(s *Status) { s.TokensUsed = 1200 })
+	w.Flush() // only the tokens changed: no event
+	w.Finish(nil)
+	events.Complete(w.Snapshot(), map[string]string{"translated_pdf": "out/paper_zh.pdf"})
+	events.Fail(w.Snapshot(), errors.New("late"), "", 1) // after the terminal event: ignored
+
+	got := readEvents(t, &out)
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2:\n%s", len(got), out.String())
+	}
+	if got[0].Event != EventStatus || got[0].Mode != "arxiv" || got[0].Phase != "translating" || got[0].Progress != 57 {
+		t.Errorf("status event = %+v", got[0])
+	}
+	if !strings.Contains(out.String(), "<块 3/5>") {
+		t.Errorf("message was escaped:\n%s", out.String())
+	}
+	if got[1].Event != EventComplete || got[1].Progress != 100 || got[1].TokensUsed != 1200 || got[1].Outputs["translated_pdf"] != "out/paper_zh.pdf" {
+		t.Errorf("complete event = %+v", got[1])
+	}
+}
+
+func TestEventWriterFail(t *testing.T) {
+	var out bytes.Buffer
+	events := NewEventWriter(&out)
+	events.Fail(Status{Mode: "pdf", Phase: "translating", Percent: 30, TokensUsed: 50}, errors.New("API 错误"), "API_CALL_FAILED", 1)
+	events.Status(Status{State: StateRunning, Phase: "translating", Percent: 40}) // after the terminal event: ignored
+
+	got := readEvents(t, &out)
+	if len(got) != 1 {
+		t.Fatalf("got %d events, want 1:\n%s", len(got), out.String())
+	}
+	if got[0].Event != EventError || got[0].Error != "API 错误" || got[0].Code != "API_CALL_FAILED" || got[0].ExitCode != 1 || got[0].TokensUsed != 50 {
+		t.Errorf("error event = %+v", got[0])
+	}
+}
+
+func TestNilEventWriter(t *testing.T) {
+	var events *EventWriter
+	w := NewWriter("", "book", "book")
+	events.Follow(w)
+	events.Status(Status{})
+	events.Complete(Status{}, nil)
+	events.Fail(Status{}, errors.New("failed"), "", 1)
+	if w.OnWrite != nil {
+		t.Error("nil event writer followed the status")
+	}
+}
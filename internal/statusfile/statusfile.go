@@ -0,0 +1,349 @@
+// Package statusfile writes small JSON status files that are continuously updated during
+// CLI runs, so that wrapper scripts and dashboards can follow progress without parsing
+// stdout. The schema is documented in docs/STATUS_JSON.md.
+//
+// Compatibility: fields are only ever added. Existing fields keep their name, type and
+// meaning; SchemaVersion is increased only if that promise has to be broken.
+package statusfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// SchemaVersion is the version of the status file schema
+	SchemaVersion = 1
+	// FileName is the name of the per-job status file
+	FileName = "status.json"
+	// BatchFileName is the name of the aggregate status file of a batch run
+	BatchFileName = "batch_status.json"
+	// DefaultInterval is how often status files are rewritten while a job runs
+	DefaultInterval = 2 * time.Second
+	// maxWarnings is the number of most recent warnings kept in a status file
+	maxWarnings = 20
+)
+
+// Job states
+const (
+	StatePending = "pending"
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+// Status is the content of a per-job status file
+type Status struct {
+	SchemaVersion int       `json:"schema_version"`
+	PID           int       `json:"pid"`
+	Host          string    `json:"host"`
+	Mode          string    `json:"mode"`  // arxiv, pdf, book or batch
+	Input         string    `json:"input"` // arXiv ID, URL or path as given
+	State         string    `json:"state"` // pending, running, done or failed
+	Phase         string    `json:"phase"`
+	Percent       int       `json:"percent"` // 0-100
+	Message       string    `json:"message"`
+	Section       string    `json:"section,omitempty"` // section being translated
+	File          string    `json:"file,omitempty"`    // file being translated (multi-file documents)
+	Chunk         int       `json:"chunk"`             // chunks of the current file translated so far
+	TotalChunks   int       `json:"total_chunks"`
+	TokensUsed    int       `json:"tokens_used"`
+	Warnings      []string  `json:"warnings"`
+	Error         string    `json:"error,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Writer keeps a status file up to date while a job runs.
+// All methods are safe for concurrent use.
+type Writer struct {
+	path string
+
+	mu     sync.Mutex
+	status Status
+	stop   chan struct{}
+	done   chan struct{}
+
+	// OnWrite is called with a copy of the status after each write, e.g. to update
+	// an aggregate batch status. It must be set before Start.
+	OnWrite func(status Status)
+}
+
+// NewWriter creates a writer for the status file at path
+func NewWriter(path, mode, input string) *Writer {
+	now := time.Now()
+	return &Writer{
+		path: path,
+		status: Status{
+			SchemaVersion: SchemaVersion,
+			PID:           os.Getpid(),
+			Host:          hostName(),
+			Mode:          mode,
+			Input:         input,
+			State:         StatePending,
+			Warnings:      []string{},
+			StartedAt:     now,
+			UpdatedAt:     now,
+		},
+	}
+}
+
+// Path returns the path of the status file
+func (w *Writer) Path() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.path
+}
+
+// Update changes the status; the change is written on the next tick or Flush
+func (w *Writer) Update(fn func(s *Status)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fn(&w.status)
+}
+
+// Warn records a warning
+func (w *Writer) Warn(warning string) {
+	w.Update(func(s *Status) {
+		s.Warnings = append(s.Warnings, warning)
+		if len(s.Warnings) > maxWarnings {
+			s.Warnings = s.Warnings[len(s.Warnings)-maxWarnings:]
+		}
+	})
+}
+
+// Start marks the job as running and rewrites the status file every interval until
+// Finish is called. poll, if not nil, is called before each write to refresh the status.
+func (w *Writer) Start(interval time.Duration, poll func(s *Status)) {
+	w.Update(func(s *Status) { s.State = StateRunning })
+	w.Flush()
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	w.mu.Lock()
+	w.stop, w.done = stop, done
+	w.mu.Unlock()
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if poll != nil {
+					w.Update(poll)
+				}
+				w.Flush()
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic updates started by Start without finishing the job, so a
+// following step can Start its own poll
+func (w *Writer) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop = nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Finish stops the periodic updates and writes the final status: done, or failed with err
+func (w *Writer) Finish(err error) {
+	w.Stop()
+	w.Update(func(s *Status) {
+		if err != nil {
+			s.State = StateFailed
+			s.Error = err.Error()
+		} else {
+			s.State = StateDone
+			s.Percent = 100
+		}
+	})
+	w.Flush()
+}
+
+// Snapshot returns a copy of the current status
+func (w *Writer) Snapshot() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.Warnings = append([]string(nil), w.status.Warnings...)
+	return status
+}
+
+// Flush writes the status file now. Errors are ignored: monitoring must never
+// interrupt the job.
+func (w *Writer) Flush() {
+	w.mu.Lock()
+	w.status.UpdatedAt = time.Now()
+	w.mu.Unlock()
+
+	status := w.Snapshot()
+	if path := w.Path(); path != "" {
+		WriteJSONAtomic(path, status)
+	}
+	if w.OnWrite != nil {
+		w.OnWrite(status)
+	}
+}
+
+// BatchStatus is the content of the aggregate status file of a batch run
+type BatchStatus struct {
+	SchemaVersion int           `json:"schema_version"`
+	PID           int           `json:"pid"`
+	Host          string        `json:"host"`
+	Phase         string        `json:"phase"`
+	Total         int           `json:"total"`
+	Completed     int           `json:"completed"`
+	Failed        int           `json:"failed"`
+	Running       int           `json:"running"`
+	TokensUsed    int           `json:"tokens_used"`
+	Papers        []PaperStatus `json:"papers"`
+	StartedAt     time.Time     `json:"started_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// PaperStatus is the summary of one paper in a batch status file
+type PaperStatus struct {
+	ID         string `json:"id"`
+	State      string `json:"state"`
+	Phase      string `json:"phase,omitempty"`
+	Percent    int    `json:"percent"`
+	TokensUsed int    `json:"tokens_used"`
+	StatusFile string `json:"status_file,omitempty"` // per-paper status file
+	Error      string `json:"error,omitempty"`
+}
+
+// BatchWriter keeps the aggregate status file of a batch run up to date.
+// All methods are safe for concurrent use.
+type BatchWriter struct {
+	path string
+
+	mu     sync.Mutex
+	status BatchStatus
+	index  map[string]int
+}
+
+// NewBatchWriter creates an aggregate status writer for the given papers and writes the
+// initial aggregate file
+func NewBatchWriter(path, phase string, ids []string) *BatchWriter {
+	now := time.Now()
+	b := &BatchWriter{
+		path: path,
+		status: BatchStatus{
+			SchemaVersion: SchemaVersion,
+			PID:           os.Getpid(),
+			Host:          hostName(),
+			Phase:         phase,
+			Total:         len(ids),
+			Papers:        make([]PaperStatus, 0, len(ids)),
+			StartedAt:     now,
+		},
+		index: make(map[string]int, len(ids)),
+	}
+	for _, id := range ids {
+		b.index[id] = len(b.status.Papers)
+		b.status.Papers = append(b.status.Papers, PaperStatus{ID: id, State: StatePending})
+	}
+	b.status.UpdatedAt = now
+	WriteJSONAtomic(path, b.status)
+	return b
+}
+
+// Track returns a per-paper writer whose updates are reflected in the aggregate
+func (b *BatchWriter) Track(id, statusPath string) *Writer {
+	w := NewWriter(statusPath, "batch", id)
+	w.OnWrite = func(s Status) {
+		b.Update(id, func(p *PaperStatus) {
+			p.State = s.State
+			p.Phase = s.Phase
+			p.Percent = s.Percent
+			p.TokensUsed = s.TokensUsed
+			p.StatusFile = w.Path()
+			p.Error = s.Error
+		})
+	}
+	return w
+}
+
+// Update changes the summary of one paper and rewrites the aggregate file
+func (b *BatchWriter) Update(id string, fn func(p *PaperStatus)) {
+	b.mu.Lock()
+	i, ok := b.index[id]
+	if !ok {
+		i = len(b.status.Papers)
+		b.index[id] = i
+		b.status.Papers = append(b.status.Papers, PaperStatus{ID: id, State: StatePending})
+		b.status.Total++
+	}
+	fn(&b.status.Papers[i])
+
+	b.status.Completed, b.status.Failed, b.status.Running = 0, 0, 0
+	b.status.TokensUsed = 0
+	for _, p := range b.status.Papers {
+		b.status.TokensUsed += p.TokensUsed
+		switch p.State {
+		case StateDone:
+			b.status.Completed++
+		case StateFailed:
+			b.status.Failed++
+		case StateRunning:
+			b.status.Running++
+		}
+	}
+	b.status.UpdatedAt = time.Now()
+	status := b.status
+	status.Papers = append([]PaperStatus(nil), b.status.Papers...)
+	b.mu.Unlock()
+
+	WriteJSONAtomic(b.path, status)
+}
+
+// WriteJSONAtomic writes v as JSON to path by writing a temporary file in the same
+// directory and renaming it, so readers never see a partially written file
+func WriteJSONAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// hostName returns the host name, or "unknown" if it cannot be determined
+func hostName() string {
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "unknown"
+}
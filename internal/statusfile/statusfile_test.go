@@ -0,0 +1,131 @@
+package statusfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s is not valid JSON: %v", path, err)
+	}
+}
+
+func TestWriterLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	w := NewWriter(path, "arxiv", "2301.00001")
+
+	polls := 0
+	w.Start(10*time.Millisecond, func(s *Status) {
+		polls++
+		s.Phase = "translating"
+		s.Percent = 42
+		s.TokensUsed = 100
+	})
+	w.Warn("network paused")
+	time.Sleep(50 * time.Millisecond)
+
+	var running Status
+	readJSON(t, path, &running)
+	if running.State != StateRunning || running.Percent != 42 || running.PID != os.Getpid() {
+		t.Errorf("unexpected running status: %+v", running)
+	}
+	if len(running.Warnings) != 1 {
+		t.Errorf("warnings = %v", running.Warnings)
+	}
+
+	w.Finish(errors.New("compile failed"))
+	var final Status
+	readJSON(t, path, &final)
+	if final.State != StateFailed || final.Error != "compile failed" || final.SchemaVersion != SchemaVersion {
+		t.Errorf("unexpected final status: %+v", final)
+	}
+	if polls == 0 {
+		t.Error("poll was never called")
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the status file, found %d entries", len(entries))
+	}
+}
+
+func TestWriterStopKeepsJobRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	w := NewWriter(path, "book", "book.zip")
+
+	w.Start(time.Millisecond, func(s *Status) { s.Phase = "translating" })
+	time.Sleep(10 * time.Millisecond)
+	w.Stop()
+	w.Update(func(s *Status) { s.Phase = "compiling" })
+	time.Sleep(10 * time.Millisecond)
+	w.Flush()
+
+	var stopped Status
+	readJSON(t, path, &stopped)
+	if stopped.State != StateRunning || stopped.Phase != "compiling" {
+		t.Errorf("poll still running after Stop or job finished: %+v", stopped)
+	}
+
+	// A following step starts its own poll
+	w.Start(time.Hour, func(s *Status) { s.Percent = 90 })
+	w.Finish(nil)
+	var final Status
+	readJSON(t, path, &final)
+	if final.State != StateDone || final.Phase != "compiling" {
+		t.Errorf("unexpected final status: %+v", final)
+	}
+}
+
+func TestBatchWriterAggregatesPapers(t *testing.T) {
+	dir := t.TempDir()
+	batchPath := filepath.Join(dir, BatchFileName)
+	ids := []string{"2301.00001", "2301.00002", "2301.00003"}
+	b := NewBatchWriter(batchPath, "translate", ids)
+
+	var initial BatchStatus
+	readJSON(t, batchPath, &initial)
+	if initial.Total != 3 || len(initial.Papers) != 3 || initial.Papers[0].State != StatePending {
+		t.Fatalf("unexpected initial batch status: %+v", initial)
+	}
+
+	first := b.Track(ids[0], filepath.Join(dir, "status", "2301.00001.json"))
+	first.Start(time.Hour, nil)
+	first.Update(func(s *Status) { s.TokensUsed = 50 })
+	first.Finish(nil)
+
+	second := b.Track(ids[1], filepath.Join(dir, "status", "2301.00002.json"))
+	second.Start(time.Hour, nil)
+	second.Update(func(s *Status) {
+		s.Phase = "translating"
+		s.Percent = 30
+		s.TokensUsed = 20
+	})
+	second.Flush()
+
+	var batch BatchStatus
+	readJSON(t, batchPath, &batch)
+	if batch.Completed != 1 || batch.Running != 1 || batch.Failed != 0 || batch.TokensUsed != 70 {
+		t.Errorf("unexpected counts: %+v", batch)
+	}
+	if p := batch.Papers[1]; p.Percent != 30 || p.StatusFile == "" {
+		t.Errorf("unexpected paper summary: %+v", p)
+	}
+
+	var paper Status
+	readJSON(t, batch.Papers[1].StatusFile, &paper)
+	if paper.Input != ids[1] || paper.Mode != "batch" {
+		t.Errorf("unexpected paper status: %+v", paper)
+	}
+	second.Finish(nil)
+}
@@ -0,0 +1,303 @@
+package translator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// AnthropicAPIURL is the Anthropic Messages API endpoint
+	AnthropicAPIURL = "https://api.anthropic.com/v1/messages"
+	// AnthropicVersion is the version of the Messages API the requests are written for
+	AnthropicVersion = "2023-06-01"
+	// anthropicDefaultMaxTokens is the output limit of requests without one; the Messages
+	// API requires a limit on every request
+	anthropicDefaultMaxTokens = 4096
+)
+
+// anthropicRequest is the request body of the Messages API
+type anthropicRequest struct {
+	Model     string    `json:"model"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens"`
+	Stream    bool      `json:"stream,omitempty"`
+}
+
+// anthropicMessage is the response of the Messages API
+type anthropicMessage struct {
+	ID         string             `json:"id"`
+	Model      string             `json:"model"`
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+	Error      *APIError          `json:"error,omitempty"`
+}
+
+// anthropicContent is a content block of a Messages API response
+type anthropicContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// anthropicUsage is the token usage of a Messages API response
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicStreamEvent is one server-sent event of a streamed Messages API response
+type anthropicStreamEvent struct {
+	Type    string            `json:"type"`
+	Message *anthropicMessage `json:"message"` // message_start
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`        // content_block_delta
+		StopReason string `json:"stop_reason"` // message_delta
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"` // message_delta
+	Error *APIError       `json:"error"`
+}
+
+// anthropicMessagesURL derives the Messages API endpoint from the API URL of the settings,
+// which is completed with /chat/completions for OpenAI. The default OpenAI URL stands for
+// the Anthropic API itself.
+func anthropicMessagesURL(apiURL string) string {
+	url := strings.TrimSuffix(strings.TrimSuffix(apiURL, "/"), "/chat/completions")
+	switch {
+	case url == "" || url == strings.TrimSuffix(OpenAIAPIURL, "/chat/completions"):
+		return AnthropicAPIURL
+	case strings.HasSuffix(url, "/messages"):
+		return url
+	case strings.HasSuffix(url, "/v1"):
+		return url + "/messages"
+	}
+	return url + "/v1/messages"
+}
+
+// newAnthropicRequest converts chat messages to a Messages API request: system messages
+// become the system prompt and consecutive messages of one role are joined, since the API
+// expects the roles to alternate.
+func newAnthropicRequest(model string, messages []Message, maxTokens int, stream bool) anthropicRequest {
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	req := anthropicRequest{Model: model, MaxTokens: maxTokens, Stream: stream}
+	var system []string
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == m.Role {
+			req.Messages[n-1].Content += "\n\n" + m.Content
+			continue
+		}
+		req.Messages = append(req.Messages, m)
+	}
+	req.System = strings.Join(system, "\n\n")
+	return req
+}
+
+// anthropicFinishReason maps a stop reason of the Messages API to the finish reason of
+// a chat completion
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	}
+	return stopReason
+}
+
+// chatCompletion converts the response to a chat completion with one choice
+func (m *anthropicMessage) chatCompletion() (*ChatCompletionResponse, error) {
+	var content strings.Builder
+	for _, block := range m.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 && m.StopReason == "" {
+		logger.Error("API returned no content", nil)
+		return nil, types.NewAppError(types.ErrAPICall, "API returned no choices", nil)
+	}
+	return &ChatCompletionResponse{
+		ID:      m.ID,
+		Object:  "chat.completion",
+		Model:   m.Model,
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: content.String()}, FinishReason: anthropicFinishReason(m.StopReason)}},
+		Usage: Usage{
+			PromptTokens:     m.Usage.InputTokens,
+			CompletionTokens: m.Usage.OutputTokens,
+			TotalTokens:      m.Usage.InputTokens + m.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// anthropicStreamError converts an error event of a stream; an overloaded API is reported
+// as the server error it answers with outside of a stream, so the request is retried
+func anthropicStreamError(apiErr *APIError) error {
+	logger.Error("API returned error in stream", nil, logger.String("errorMessage", apiErr.Message))
+	if apiErr.Type == "overloaded_error" {
+		return types.NewAppErrorWithDetails(types.ErrAPICall, "API server error", fmt.Sprintf("status 529: %s", apiErr.Message), nil)
+	}
+	return types.NewAppErrorWithDetails(types.ErrAPICall, "API returned error", apiErr.Message, nil)
+}
+
+// anthropicCompletion is chatCompletionContext for the Anthropic Messages API. A streamed
+// request rejected with 400 is sent again without streaming, and streaming is disabled
+// when that goes through.
+func (t *TranslationEngine) anthropicCompletion(ctx context.Context, messages []Message, maxTokens int) (*ChatCompletionResponse, error) {
+	stream := !t.noStreaming.Load()
+	chatResp, err := t.doAnthropicMessages(ctx, messages, maxTokens, stream)
+	var rejected *requestRejected
+	if stream && errors.As(err, &rejected) {
+		// Gateways in front of the API do not always pass streamed responses through
+		chatResp, err = t.doAnthropicMessages(ctx, messages, maxTokens, false)
+		if err == nil {
+			logger.Warn("API rejects streamed requests, disabling streaming", logger.String("model", t.model))
+			t.noStreaming.Store(true)
+			shape := t.RequestShape()
+			t.recordAdjustment(shape, shape, "关闭流式输出（stream）")
+		}
+	}
+	if errors.As(err, &rejected) {
+		logger.Error("API returned error status", nil, logger.Int("statusCode", rejected.status))
+		return nil, rejected.err
+	}
+	return chatResp, err
+}
+
+// doAnthropicMessages sends a Messages API request and reads its response, streamed or not
+func (t *TranslationEngine) doAnthropicMessages(ctx context.Context, messages []Message, maxTokens int, stream bool) (*ChatCompletionResponse, error) {
+	jsonBody, err := json.Marshal(newAnthropicRequest(t.model, messages, maxTokens, stream))
+	if err != nil {
+		logger.Error("failed to marshal request body", err)
+		return nil, types.NewAppError(types.ErrInternal, "failed to marshal request body", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, anthropicMessagesURL(t.apiURL), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		logger.Error("failed to create HTTP request", err)
+		return nil, types.NewAppError(types.ErrInternal, "failed to create HTTP request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", t.apiKey)
+	req.Header.Set("anthropic-version", AnthropicVersion)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := t.httpClient().Do(req)
+	if err != nil {
+		logger.Error("API request failed", err)
+		return nil, types.NewAppError(types.ErrNetwork, "API request failed", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readAnthropicStream(ctx, resp.Body, messages)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("failed to read API response", err)
+		return nil, types.NewAppError(types.ErrNetwork, "failed to read API response", err)
+	}
+	heartbeat(ctx)
+
+	// The error bodies of the Messages API have the shape of OpenAI's
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		return nil, &requestRejected{status: resp.StatusCode, body: string(body), err: handleAPIHTTPError(resp.StatusCode, body)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("API returned error status", nil, logger.Int("statusCode", resp.StatusCode))
+		return nil, handleAPIHTTPError(resp.StatusCode, body)
+	}
+
+	var msg anthropicMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		logger.Error("failed to parse API response", err)
+		return nil, types.NewAppError(types.ErrAPICall, "failed to parse API response", err)
+	}
+	if msg.Error != nil {
+		logger.Error("API returned error in response", nil, logger.String("errorMessage", msg.Error.Message))
+		return nil, types.NewAppErrorWithDetails(types.ErrAPICall, "API returned error", msg.Error.Message, nil)
+	}
+	return msg.chatCompletion()
+}
+
+// readAnthropicStream assembles a streamed Messages API response from its server-sent
+// events, which feed the stall watch and the stream meter of ctx like readChatStream
+func readAnthropicStream(ctx context.Context, body io.Reader, messages []Message) (*ChatCompletionResponse, error) {
+	var msg anthropicMessage
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			// Event names and blank separators
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		heartbeat(ctx)
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logger.Error("failed to parse API stream event", err, logger.String("event", truncateString(data, 100)))
+			return nil, types.NewAppError(types.ErrAPICall, "failed to parse API response", err)
+		}
+		if event.Type == "message_stop" {
+			break
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				msg = *event.Message
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				content.WriteString(event.Delta.Text)
+				streamed(ctx, EstimateTokens(event.Delta.Text))
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				msg.StopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				msg.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return nil, anthropicStreamError(event.Error)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("failed to read API stream", err)
+		return nil, types.NewAppError(types.ErrNetwork, "failed to read API response", err)
+	}
+
+	if msg.Usage.InputTokens == 0 && msg.Usage.OutputTokens == 0 {
+		for _, m := range messages {
+			msg.Usage.InputTokens += EstimateTokens(m.Content)
+		}
+		msg.Usage.OutputTokens = EstimateTokens(content.String())
+	}
+	msg.Content = []anthropicContent{{Type: "text", Text: content.String()}}
+	return msg.chatCompletion()
+}
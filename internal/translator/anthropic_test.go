@@ -0,0 +1,135 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestAnthropicMessagesURL(t *testing.T) {
+	tests := []struct {
+		apiURL string
+		want   string
+	}{
+		{"", AnthropicAPIURL},
+		{OpenAIAPIURL, AnthropicAPIURL},
+		{"https://api.anthropic.com/v1/chat/completions", "https://api.anthropic.com/v1/messages"},
+		{"https://gateway.example.com/anthropic/", "https://gateway.example.com/anthropic/v1/messages"},
+		{"https://gateway.example.com/v1/messages", "https://gateway.example.com/v1/messages"},
+	}
+	for _, tt := range tests {
+		if got := anthropicMessagesURL(tt.apiURL); got != tt.want {
+			t.Errorf("anthropicMessagesURL(%q) = %q, want %q", tt.apiURL, got, tt.want)
+		}
+	}
+}
+
+func TestNewAnthropicRequest(t *testing.T) {
+	req := newAnthropicRequest("claude", []Message{
+		{Role: "system", Content: "Translate."},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "你好"},
+		{Role: "user", Content: "Continue."},
+		{Role: "user", Content: "Please."},
+	}, 0, false)
+	if req.System != "Translate." || req.MaxTokens != anthropicDefaultMaxTokens {
+		t.Errorf("system %q, max tokens %d", req.System, req.MaxTokens)
+	}
+	if len(req.Messages) != 3 || req.Messages[2].Content != "Continue.\n\nPlease." {
+		t.Errorf("messages = %+v, want alternating roles", req.Messages)
+	}
+}
+
+// anthropicServer answers Messages API requests with "你好世界", streamed when asked
+func anthropicServer(t *testing.T, stopReason string) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, http.StatusUnauthorized)
+			return
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MaxTokens == 0 || req.Messages[0].Role != "user" {
+			http.Error(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad request"}}`, http.StatusBadRequest)
+			return
+		}
+		if !req.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"你好世界"}],"stop_reason":%q,"usage":{"input_tokens":6,"output_tokens":4}}`, stopReason)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"content\":[],\"usage\":{\"input_tokens\":6,\"output_tokens\":1}}}\n\n")
+		fmt.Fprint(w, "event: ping\ndata: {\"type\":\"ping\"}\n\n")
+		for _, delta := range []string{"你好", "世界"} {
+			fmt.Fprintf(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":%q}}\n\n", delta)
+		}
+		fmt.Fprintf(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":%q},\"usage\":{\"output_tokens\":4}}\n\n", stopReason)
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestAnthropicCompletion(t *testing.T) {
+	for _, streaming := range []bool{true, false} {
+		server, _ := anthropicServer(t, "end_turn")
+		engine := NewTranslationEngineWithConfig("test-key", "claude-test", server.URL, time.Minute, 1)
+		engine.SetProvider(types.ProviderAnthropic)
+		engine.SetStreaming(streaming)
+
+		content, usage, err := engine.Complete(context.Background(), Prompt{
+			System:   "Translate to Chinese.",
+			Messages: []Message{{Role: "user", Content: "Hello world"}},
+		})
+		if err != nil {
+			t.Fatalf("streaming %v: Complete() error: %v", streaming, err)
+		}
+		if content != "你好世界" || usage.TotalTokens != 10 {
+			t.Errorf("streaming %v: Complete() = %q, %+v", streaming, content, usage)
+		}
+	}
+}
+
+func TestAnthropicTruncationIsReported(t *testing.T) {
+	server, _ := anthropicServer(t, "max_tokens")
+	engine := NewTranslationEngineWithConfig("test-key", "claude-test", server.URL, time.Minute, 1)
+	engine.SetProvider(types.ProviderAnthropic)
+
+	chatResp, err := engine.chatCompletion([]Message{{Role: "user", Content: "Hello world"}}, 10)
+	if err != nil {
+		t.Fatalf("chatCompletion() error: %v", err)
+	}
+	if got := chatResp.Choices[0].FinishReason; got != "length" {
+		t.Errorf("finish reason = %q, want length", got)
+	}
+}
+
+func TestAnthropicConnectionTest(t *testing.T) {
+	server, requests := anthropicServer(t, "end_turn")
+	engine := NewTranslationEngineWithConfig("test-key", "claude-test", server.URL+"/v1", time.Minute, 1)
+	engine.SetProvider(types.ProviderAnthropic)
+	// The server answers "你好世界", which is not the expected "ok"
+	if _, err := engine.TestConnectionWithAdjustments(); err == nil {
+		t.Error("TestConnectionWithAdjustments() accepted an unexpected answer")
+	}
+	if atomic.LoadInt32(requests) != 1 {
+		t.Errorf("%d requests, want 1", atomic.LoadInt32(requests))
+	}
+
+	// A wrong key is an authentication error, not a request to adjust
+	engine = NewTranslationEngineWithConfig("wrong-key", "claude-test", server.URL+"/v1", time.Minute, 1)
+	engine.SetProvider(types.ProviderAnthropic)
+	_, err := engine.TestConnectionWithAdjustments()
+	if appErr, ok := err.(*types.AppError); !ok || appErr.Message != "API authentication failed" {
+		t.Errorf("TestConnectionWithAdjustments() error = %v, want an authentication failure", err)
+	}
+}
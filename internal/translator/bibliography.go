@@ -0,0 +1,199 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// bibliographyEnvName is the reference list environment written by BibTeX styles and by hand
+const bibliographyEnvName = "thebibliography"
+
+// bibProtectedPattern matches the parts of a reference that are never translated: links,
+// DOIs and arXiv identifiers, with or without a command around them, and comments
+var bibProtectedPattern = regexp.MustCompile(`(?m:(?:^|[^\\])%.*$)` +
+	`|\\(?:url|href|doi|path|nolinkurl)\s*\{[^}]*\}(?:\s*\{[^}]*\})?` +
+	`|https?://[^\s{}]+` +
+	`|(?i:doi:)\s*[^\s{}]+` +
+	`|\b10\.\d{4,9}/[^\s{}]+` +
+	`|(?i:arxiv:)\s*[^\s{}]+`)
+
+// bibWordPattern matches a word worth translating; runs holding only page ranges, years,
+// volumes and initials are kept as they are
+var bibWordPattern = regexp.MustCompile(`[A-Za-z]{3,}`)
+
+// SetTranslateBibliography sets whether the descriptive text of \bibitem entries (venues,
+// notes) is translated. By default thebibliography environments are kept verbatim.
+func (t *TranslationEngine) SetTranslateBibliography(translate bool) {
+	t.translateBibliography = translate
+}
+
+// protectBibliographies replaces every thebibliography environment of content with a
+// %BIBLIOGRAPHY_PLACEHOLDER_N% line, so a reference list is one atomic block whatever its
+// size: it never straddles a chunk boundary and its keys, authors and links are never
+// seen by the chunk translation. With translateEntries the free text of each entry is
+// translated first through translateFunc (see translateBibliographyEntries) and the
+// placeholder restores the translated environment.
+func protectBibliographies(content string, translateEntries bool, translateFunc func(string) (string, error)) (string, []commentPlaceholder, int) {
+	var sb strings.Builder
+	var placeholders []commentPlaceholder
+	translated := 0
+	beginTag := `\begin{` + bibliographyEnvName + `}`
+	last := 0
+	for searchPos := 0; ; {
+		i := strings.Index(content[searchPos:], beginTag)
+		if i == -1 {
+			break
+		}
+		begin := searchPos + i
+		searchPos = begin + len(beginTag)
+		lineStart := strings.LastIndexByte(content[:begin], '\n') + 1
+		if isCommentedOut(content[lineStart:begin]) {
+			continue
+		}
+		end := findMatchingEndTag(content, begin, bibliographyEnvName)
+		if end == -1 {
+			logger.Warn("unmatched thebibliography environment", logger.Int("position", begin))
+			break
+		}
+
+		env := content[begin:end]
+		if translateEntries {
+			var n int
+			env, n = translateBibliographyEntries(env, translateFunc)
+			translated += n
+		}
+		placeholder := fmt.Sprintf("%%BIBLIOGRAPHY_PLACEHOLDER_%d%%", len(placeholders))
+		sb.WriteString(content[last:begin])
+		sb.WriteString(placeholder)
+		placeholders = append(placeholders, commentPlaceholder{placeholder: placeholder, original: env})
+		logger.Debug("protected bibliography",
+			logger.Int("index", len(placeholders)-1),
+			logger.Int("length", end-begin))
+		last = end
+		searchPos = end
+	}
+	if len(placeholders) == 0 {
+		return content, nil, 0
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), placeholders, translated
+}
+
+// translateBibliographyEntries translates the descriptive text of the \bibitem entries of
+// a thebibliography environment, one entry at a time. BibTeX styles separate the blocks of
+// an entry with \newblock: the authors, the title, then the venue and notes ("In
+// Proceedings of ...", "accessed 2023"). Only the blocks after the title are translated,
+// and within them only the text between links and DOIs. Entries without \newblock cannot
+// be told apart and are kept. Returns the environment and the number of text runs
+// translated.
+func translateBibliographyEntries(env string, translateFunc func(string) (string, error)) (string, int) {
+	type splice struct {
+		start, end int
+		text       string
+	}
+	var splices []splice
+	for _, entry := range findBibitems(env) {
+		blocks := strings.Split(env[entry.bodyStart:entry.end], `\newblock`)
+		pos := entry.bodyStart
+		for i, block := range blocks {
+			if i >= 2 {
+				last := 0
+				for _, m := range append(bibProtectedPattern.FindAllStringIndex(block, -1), []int{len(block), len(block)}) {
+					if text, ok := translateBibliographyText(block[last:m[0]], translateFunc); ok {
+						splices = append(splices, splice{pos + last, pos + m[0], text})
+					}
+					last = m[1]
+				}
+			}
+			pos += len(block) + len(`\newblock`)
+		}
+	}
+
+	for i := len(splices) - 1; i >= 0; i-- {
+		s := splices[i]
+		env = env[:s.start] + s.text + env[s.end:]
+	}
+	return env, len(splices)
+}
+
+// translateBibliographyText translates one run of reference text, keeping its surrounding
+// whitespace. It reports false when the run is left as it is.
+func translateBibliographyText(text string, translateFunc func(string) (string, error)) (string, bool) {
+	if !bibWordPattern.MatchString(text) {
+		return text, false
+	}
+	return translateCaptionText(text, translateFunc)
+}
+
+// bibitem is a \bibitem entry of a thebibliography environment; its body starts after the
+// key and ends at the next entry or at the end of the environment
+type bibitem struct {
+	start, bodyStart, end int
+	key                   string
+}
+
+// findBibitems returns the uncommented \bibitem entries of a thebibliography environment
+func findBibitems(env string) []bibitem {
+	var items []bibitem
+	for searchPos := 0; ; {
+		i := strings.Index(env[searchPos:], `\bibitem`)
+		if i == -1 {
+			break
+		}
+		start := searchPos + i
+		searchPos = start + len(`\bibitem`)
+		lineStart := strings.LastIndexByte(env[:start], '\n') + 1
+		if isCommentedOut(env[lineStart:start]) || isLetterAt(env, searchPos) {
+			continue
+		}
+		item, ok := parseBibitem(env, start)
+		if !ok {
+			continue
+		}
+		if len(items) > 0 {
+			items[len(items)-1].end = start
+		}
+		items = append(items, item)
+		searchPos = item.bodyStart
+	}
+	if len(items) > 0 {
+		end := strings.LastIndex(env, `\end{`+bibliographyEnvName+`}`)
+		if end < items[len(items)-1].bodyStart {
+			end = len(env)
+		}
+		items[len(items)-1].end = end
+	}
+	return items
+}
+
+// parseBibitem parses \bibitem[label]{key} at start. The optional label may hold braces,
+// as natbib's \bibitem[{Vaswani et~al.(2017)}]{vaswani2017} does.
+func parseBibitem(env string, start int) (bibitem, bool) {
+	pos := skipSpaces(env, start+len(`\bibitem`), len(env))
+	if pos < len(env) && env[pos] == '[' {
+		depth := 0
+		for pos < len(env) {
+			c := env[pos]
+			pos++
+			if c == '{' {
+				depth++
+			} else if c == '}' {
+				depth--
+			} else if c == ']' && depth == 0 {
+				break
+			}
+		}
+		pos = skipSpaces(env, pos, len(env))
+	}
+	if pos >= len(env) || env[pos] != '{' {
+		return bibitem{}, false
+	}
+	keyEnd := strings.IndexByte(env[pos:], '}')
+	if keyEnd == -1 {
+		return bibitem{}, false
+	}
+	return bibitem{start: start, bodyStart: pos + keyEnd + 1, key: env[pos+1 : pos+keyEnd]}, true
+}
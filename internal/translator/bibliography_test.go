@@ -0,0 +1,107 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+const testBibliography = `\begin{thebibliography}{2}
+\bibitem[{Vaswani et~al.(2017)}]{vaswani2017}
+A.~Vaswani and N.~Shazeer.
+\newblock Attention is all you need.
+\newblock In \emph{Advances in Neural Information Processing Systems}, pages 5998--6008, 2017.
+\newblock doi:10.5555/3295222.3295349.
+
+% \bibitem{old} Old entry.
+\bibitem{web}
+OpenAI.
+\newblock GPT-4 technical report.
+\newblock \url{https://arxiv.org/abs/2303.08774}, accessed 2023.
+
+\bibitem{plain} J. Doe. A book without blocks. Publisher, 2001.
+\end{thebibliography}`
+
+// upperTranslate marks every translated fragment by upper-casing it
+func upperTranslate(calls *[]string) func(string) (string, error) {
+	return func(s string) (string, error) {
+		*calls = append(*calls, s)
+		return strings.ToUpper(s), nil
+	}
+}
+
+func TestFindBibitems(t *testing.T) {
+	items := findBibitems(testBibliography)
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.key)
+	}
+	if strings.Join(keys, ",") != "vaswani2017,web,plain" {
+		t.Errorf("keys = %v", keys)
+	}
+	if last := items[len(items)-1]; strings.Contains(testBibliography[last.bodyStart:last.end], `\end{`) {
+		t.Error("last entry includes the end of the environment")
+	}
+}
+
+func TestProtectBibliographiesKeepsReferenceListAtomic(t *testing.T) {
+	content := "Text.\n\n" + testBibliography + "\n\\end{document}\n"
+	var calls []string
+	protected, placeholders, translated := protectBibliographies(content, false, upperTranslate(&calls))
+	if len(placeholders) != 1 || translated != 0 || len(calls) != 0 {
+		t.Fatalf("placeholders = %d, translated = %d, calls = %v", len(placeholders), translated, calls)
+	}
+	if protected != "Text.\n\n%BIBLIOGRAPHY_PLACEHOLDER_0%\n\\end{document}\n" {
+		t.Errorf("protected = %q", protected)
+	}
+	if placeholders[0].original != testBibliography {
+		t.Error("placeholder does not restore the environment verbatim")
+	}
+
+	// However small the chunks, the reference list is never split
+	plan := planChunks(content+strings.Repeat("More text here. ", 50), 200, true, false, func(s string) (string, error) { return s, nil })
+	for _, chunk := range plan.chunks {
+		if strings.Contains(chunk, `\bibitem`) {
+			t.Errorf("chunk holds a bibliography entry: %q", chunk)
+		}
+	}
+	if restored := restoreCommentEnvironments(strings.Join(plan.chunks, ""), plan.bibPlaceholders); !strings.Contains(restored, testBibliography) {
+		t.Error("bibliography not restored")
+	}
+
+	// Commented-out environments are left to the comment handling
+	if _, placeholders, _ := protectBibliographies("% \\begin{thebibliography}{1}\n% \\end{thebibliography}\n", false, nil); len(placeholders) != 0 {
+		t.Error("protected a commented-out bibliography")
+	}
+}
+
+func TestTranslateBibliographyEntries(t *testing.T) {
+	var calls []string
+	_, placeholders, translated := protectBibliographies(testBibliography, true, upperTranslate(&calls))
+	got := placeholders[0].original
+
+	for _, kept := range []string{
+		`\bibitem[{Vaswani et~al.(2017)}]{vaswani2017}`,
+		"A.~Vaswani and N.~Shazeer.",
+		`\newblock Attention is all you need.`,
+		`doi:10.5555/3295222.3295349.`,
+		`\url{https://arxiv.org/abs/2303.08774}`,
+		`\newblock GPT-4 technical report.`,
+		`% \bibitem{old} Old entry.`,
+		`\bibitem{plain} J. Doe. A book without blocks. Publisher, 2001.`,
+	} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("lost %q in:\n%s", kept, got)
+		}
+	}
+	for _, want := range []string{
+		`\newblock IN \EMPH{ADVANCES IN NEURAL INFORMATION PROCESSING SYSTEMS}, PAGES 5998--6008, 2017.`,
+		`\url{https://arxiv.org/abs/2303.08774}, ACCESSED 2023.`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing translated %q in:\n%s", want, got)
+		}
+	}
+	if translated != 2 || len(calls) != 2 {
+		t.Errorf("translated %d runs with calls %q, want the venue and the note", translated, calls)
+	}
+}
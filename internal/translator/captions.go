@@ -0,0 +1,207 @@
+package translator
+
+import (
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// floatCaption is a \caption command of a float environment. The short argument is
+// absent when shortStart is -1; the ranges exclude the brackets and braces.
+type floatCaption struct {
+	start, end           int
+	shortStart, shortEnd int
+	longStart, longEnd   int
+}
+
+// findFloatCaptions returns the \caption and \caption[short] commands inside the float
+// environments of content (floatEnvNames), in document order. Commented-out captions
+// are skipped. The arguments are delimited by brace matching, so captions with nested
+// groups such as \caption{Results for \textbf{ours} vs.\ baseline} are found whole.
+func findFloatCaptions(content string) []floatCaption {
+	type span struct{ start, end int }
+	var floats []span
+	for _, envName := range floatEnvNames {
+		beginTag := "\\begin{" + envName + "}"
+		for searchPos := 0; ; {
+			i := strings.Index(content[searchPos:], beginTag)
+			if i == -1 {
+				break
+			}
+			begin := searchPos + i
+			end := findMatchingEndTag(content, begin, envName)
+			if end == -1 {
+				searchPos = begin + len(beginTag)
+				continue
+			}
+			floats = append(floats, span{begin, end})
+			searchPos = end
+		}
+	}
+
+	seen := make(map[int]bool)
+	var captions []floatCaption
+	for _, f := range floats {
+		for searchPos := f.start; ; {
+			i := strings.Index(content[searchPos:f.end], "\\caption")
+			if i == -1 {
+				break
+			}
+			start := searchPos + i
+			searchPos = start + len("\\caption")
+			if seen[start] {
+				continue
+			}
+			lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+			if isCommentedOut(content[lineStart:start]) {
+				continue
+			}
+			if caption, ok := parseCaption(content, start, f.end); ok {
+				seen[start] = true
+				captions = append(captions, caption)
+				searchPos = caption.end
+			}
+		}
+	}
+	sort.Slice(captions, func(i, j int) bool { return captions[i].start < captions[j].start })
+	return captions
+}
+
+// parseCaption parses the \caption command at start, which must end before limit
+func parseCaption(content string, start, limit int) (floatCaption, bool) {
+	c := floatCaption{start: start, shortStart: -1}
+	pos := start + len("\\caption")
+	// \captionof, \captionsetup and the like are other commands
+	if pos < limit && isLetterAt(content, pos) {
+		return c, false
+	}
+	if pos < limit && content[pos] == '*' {
+		pos++
+	}
+	pos = skipSpaces(content, pos, limit)
+
+	if pos < limit && content[pos] == '[' {
+		close := findMatchingBracket(content, pos, limit)
+		if close == -1 {
+			return c, false
+		}
+		c.shortStart, c.shortEnd = pos+1, close
+		pos = skipSpaces(content, close+1, limit)
+	}
+
+	if pos >= limit || content[pos] != '{' {
+		return c, false
+	}
+	close := findMatchingBrace(content, pos)
+	if close == -1 || close >= limit {
+		return c, false
+	}
+	c.longStart, c.longEnd = pos+1, close
+	c.end = close + 1
+	return c, true
+}
+
+// findMatchingBracket returns the position of the ] closing the [ at pos, ignoring
+// brackets inside brace groups, or -1
+func findMatchingBracket(content string, pos, limit int) int {
+	depth := 0
+	for i := pos + 1; i < limit; i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ']':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipSpaces returns the position of the first character at or after pos, before limit,
+// that is not a space or tab
+func skipSpaces(content string, pos, limit int) int {
+	for pos < limit && (content[pos] == ' ' || content[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+// TranslateCaptionsInFloats translates the captions of the float environments (table,
+// figure and their starred and sideways variants) before the chunk translation, which
+// keeps tables out of the model's reach and so would leave their captions in English.
+// The short and the long argument of each caption are translated as units of their own
+// and spliced back; everything else in the environment (\includegraphics, tabular data,
+// labels) is left untouched. Math in a caption comes back verbatim, as in any chunk.
+//
+// A caption that fails to translate, or whose translation has unbalanced braces, keeps
+// its original text. The returned count is the number of arguments translated.
+func TranslateCaptionsInFloats(content string, translateFunc func(string) (string, error)) (string, int) {
+	captions := findFloatCaptions(content)
+	if len(captions) == 0 {
+		return content, 0
+	}
+
+	// Translate in document order, then splice from the end so positions stay valid
+	type splice struct {
+		start, end int
+		text       string
+	}
+	var splices []splice
+	translated := 0
+	for _, c := range captions {
+		if c.shortStart >= 0 {
+			if text, ok := translateCaptionText(content[c.shortStart:c.shortEnd], translateFunc); ok {
+				splices = append(splices, splice{c.shortStart, c.shortEnd, text})
+				translated++
+			}
+		}
+		if text, ok := translateCaptionText(content[c.longStart:c.longEnd], translateFunc); ok {
+			splices = append(splices, splice{c.longStart, c.longEnd, text})
+			translated++
+		}
+	}
+
+	result := content
+	for i := len(splices) - 1; i >= 0; i-- {
+		s := splices[i]
+		result = result[:s.start] + s.text + result[s.end:]
+	}
+	return result, translated
+}
+
+// translateCaptionText translates one caption argument, keeping its surrounding
+// whitespace. It reports false when the text is left as it is.
+func translateCaptionText(text string, translateFunc func(string) (string, error)) (string, bool) {
+	body := strings.TrimSpace(text)
+	if body == "" || !containsTranslatableText(body) {
+		return text, false
+	}
+
+	translated, err := translateFunc(body)
+	if err != nil {
+		logger.Warn("failed to translate caption, keeping original",
+			logger.Err(err),
+			logger.String("caption", truncateString(body, 50)))
+		return text, false
+	}
+	translated = strings.TrimSpace(translated)
+	if translated == "" || translated == body {
+		return text, false
+	}
+	if strings.Count(translated, "{")-strings.Count(translated, "\\{") != strings.Count(body, "{")-strings.Count(body, "\\{") ||
+		strings.Count(translated, "}")-strings.Count(translated, "\\}") != strings.Count(body, "}")-strings.Count(body, "\\}") {
+		logger.Warn("caption translation has unbalanced braces, keeping original",
+			logger.String("caption", truncateString(body, 50)))
+		return text, false
+	}
+
+	leading := text[:strings.Index(text, body)]
+	trailing := text[len(leading)+len(body):]
+	return leading + translated + trailing, true
+}
@@ -0,0 +1,52 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateCaptionsInFloats(t *testing.T) {
+	content := "Intro text with \\caption{Not in a float}.\n" +
+		"\\begin{table}[t]\n\\centering\n" +
+		"\\caption[Short results]{Results for \\textbf{ours} vs.\\ baseline with $\\alpha = 0.5$}\\label{tab:res}\n" +
+		"\\begin{tabular}{lc}\nMethod & Acc \\\\\nOurs & 0.9 \\\\\n\\end{tabular}\n\\end{table}\n" +
+		"\\begin{figure*}\n\\includegraphics[width=\\linewidth]{arch.pdf}\n" +
+		"% \\caption{Old caption}\n\\captionsetup{font=small}\n\\caption{Model architecture.}\n\\end{figure*}\n" +
+		"\\begin{figure}\\caption{\\ref{fig:a}}\\end{figure}\n"
+
+	var units []string
+	translated, count := TranslateCaptionsInFloats(content, func(s string) (string, error) {
+		units = append(units, s)
+		return "译:" + s, nil
+	})
+
+	wantUnits := []string{
+		"Short results",
+		"Results for \\textbf{ours} vs.\\ baseline with $\\alpha = 0.5$",
+		"Model architecture.",
+	}
+	if count != len(wantUnits) || strings.Join(units, "|") != strings.Join(wantUnits, "|") {
+		t.Fatalf("translated %d units %q, want %q", count, units, wantUnits)
+	}
+
+	for _, want := range []string{
+		"\\caption[译:Short results]{译:Results for \\textbf{ours} vs.\\ baseline with $\\alpha = 0.5$}\\label{tab:res}\n\\begin{tabular}{lc}\nMethod & Acc \\\\",
+		"\\includegraphics[width=\\linewidth]{arch.pdf}\n% \\caption{Old caption}\n\\captionsetup{font=small}\n\\caption{译:Model architecture.}",
+		"Intro text with \\caption{Not in a float}.",
+		"\\caption{\\ref{fig:a}}",
+	} {
+		if !strings.Contains(translated, want) {
+			t.Errorf("translation is missing %q:\n%s", want, translated)
+		}
+	}
+}
+
+func TestTranslateCaptionsKeepsUnbalancedTranslation(t *testing.T) {
+	content := "\\begin{figure}\n\\caption{A {nested} caption}\n\\end{figure}\n"
+	translated, count := TranslateCaptionsInFloats(content, func(s string) (string, error) {
+		return "一个 {嵌套 的标题", nil
+	})
+	if count != 0 || translated != content {
+		t.Errorf("unbalanced translation was spliced in:\n%s", translated)
+	}
+}
@@ -0,0 +1,173 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"latex-translator/internal/logger"
+)
+
+// MaxCaptureRuns is how many run directories a debug capture directory keeps; the oldest
+// are removed when a new run starts
+const MaxCaptureRuns = 10
+
+// captureRunPrefix starts the names of the run directories, which sort by start time
+const captureRunPrefix = "run-"
+
+// DebugCapture saves the prompts and responses of the chunk requests that failed or came
+// back damaged, for bug reports. Each run writes to its own subdirectory, created with the
+// first capture; the API key is never written.
+type DebugCapture struct {
+	baseDir  string
+	runDir   string
+	keepRuns int
+
+	mu      sync.Mutex
+	count   int
+	created bool
+}
+
+// CaptureMetadata describes a captured chunk request
+type CaptureMetadata struct {
+	Model            string    `json:"model"`
+	Provider         string    `json:"provider,omitempty"`
+	Reason           string    `json:"reason"`
+	FinishReason     string    `json:"finish_reason,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	ChunkLength      int       `json:"chunk_length"`
+	Time             time.Time `json:"time"`
+}
+
+// NewDebugCapture returns a capture writing to a new run directory under baseDir, keeping
+// at most keepRuns runs (MaxCaptureRuns when keepRuns <= 0). Nothing is written before
+// the first capture.
+func NewDebugCapture(baseDir string, keepRuns int) *DebugCapture {
+	if keepRuns <= 0 {
+		keepRuns = MaxCaptureRuns
+	}
+	run := captureRunPrefix + time.Now().Format("20060102-150405.000")
+	return &DebugCapture{
+		baseDir:  baseDir,
+		runDir:   filepath.Join(baseDir, strings.ReplaceAll(run, ".", "-")),
+		keepRuns: keepRuns,
+	}
+}
+
+// Dir returns the run directory of the capture
+func (c *DebugCapture) Dir() string {
+	if c == nil {
+		return ""
+	}
+	return c.runDir
+}
+
+// Count returns the number of chunk requests captured so far
+func (c *DebugCapture) Count() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// record writes prompt_NNN.txt, response_NNN.txt and meta_NNN.json for a chunk request.
+// Occurrences of secret (the API key) are redacted. Failures are logged, never returned:
+// the capture must not fail the translation.
+func (c *DebugCapture) record(messages []Message, response string, meta CaptureMetadata, secret string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.created {
+		if err := os.MkdirAll(c.runDir, 0755); err != nil {
+			logger.Warn("failed to create debug capture directory", logger.String("dir", c.runDir), logger.Err(err))
+			return
+		}
+		c.created = true
+		pruneCaptureRuns(c.baseDir, c.keepRuns, c.runDir)
+	}
+	c.count++
+	n := c.count
+
+	var prompt strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&prompt, "=== %s ===\n%s\n\n", m.Role, m.Content)
+	}
+	meta.Time = meta.Time.UTC()
+	metaJSON, _ := json.MarshalIndent(meta, "", "  ")
+	files := map[string]string{
+		fmt.Sprintf("prompt_%03d.txt", n):   prompt.String(),
+		fmt.Sprintf("response_%03d.txt", n): response,
+		fmt.Sprintf("meta_%03d.json", n):    string(metaJSON),
+	}
+	for name, content := range files {
+		if secret != "" {
+			content = strings.ReplaceAll(content, secret, "[REDACTED]")
+		}
+		if err := os.WriteFile(filepath.Join(c.runDir, name), []byte(content), 0644); err != nil {
+			logger.Warn("failed to write debug capture", logger.String("file", name), logger.Err(err))
+		}
+	}
+	logger.Info("captured chunk request",
+		logger.String("dir", c.runDir),
+		logger.Int("capture", n),
+		logger.String("reason", meta.Reason))
+}
+
+// pruneCaptureRuns removes the oldest run directories under baseDir so that at most keep
+// remain, never the current one
+func pruneCaptureRuns(baseDir string, keep int, current string) {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return
+	}
+	var runs []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), captureRunPrefix) && filepath.Join(baseDir, e.Name()) != current {
+			runs = append(runs, e.Name())
+		}
+	}
+	sort.Strings(runs)
+	// The current run counts towards the limit
+	for len(runs) > keep-1 {
+		if err := os.RemoveAll(filepath.Join(baseDir, runs[0])); err != nil {
+			logger.Warn("failed to remove old debug capture", logger.String("run", runs[0]), logger.Err(err))
+		}
+		runs = runs[1:]
+	}
+}
+
+// SetDebugCapture sets where the prompts and responses of failed or damaged chunk requests
+// are saved; nil disables the capture
+func (t *TranslationEngine) SetDebugCapture(c *DebugCapture) {
+	t.debugCapture = c
+}
+
+// captureChunk saves a chunk request of the engine, if capturing
+func (t *TranslationEngine) captureChunk(messages []Message, response string, usage Usage, reason, finishReason string, chunkLength int) {
+	if t.debugCapture == nil {
+		return
+	}
+	t.debugCapture.record(messages, response, CaptureMetadata{
+		Model:            t.model,
+		Provider:         t.provider,
+		Reason:           reason,
+		FinishReason:     finishReason,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		ChunkLength:      chunkLength,
+		Time:             time.Now(),
+	}, t.apiKey)
+}
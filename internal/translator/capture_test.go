@@ -0,0 +1,92 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugCaptureLostPlaceholders(t *testing.T) {
+	// The model drops the protected commands and echoes the key it was sent with
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		translated := "如文献 sk-secret 所述。"
+		json.NewEncoder(w).Encode(ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+			Usage:   Usage{PromptTokens: 40, CompletionTokens: 10, TotalTokens: 50},
+		})
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("sk-secret", "test-model", server.URL, 5*time.Second, 1)
+	capture := NewDebugCapture(t.TempDir(), 0)
+	engine.SetDebugCapture(capture)
+	if _, err := os.Stat(capture.Dir()); !os.IsNotExist(err) {
+		t.Fatalf("run directory created before the first capture: %v", err)
+	}
+
+	engine.doTranslateChunk(context.Background(), "As in \\cite{smith2021}.")
+	if capture.Count() == 0 {
+		t.Fatal("damaged response not captured")
+	}
+
+	for _, name := range []string{"prompt_001.txt", "response_001.txt", "meta_001.json"} {
+		content, err := os.ReadFile(filepath.Join(capture.Dir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), "sk-secret") {
+			t.Errorf("%s contains the API key:\n%s", name, content)
+		}
+	}
+	prompt, _ := os.ReadFile(filepath.Join(capture.Dir(), "prompt_001.txt"))
+	if !strings.Contains(string(prompt), "=== system ===") || !strings.Contains(string(prompt), "=== user ===") {
+		t.Errorf("prompt misses the messages:\n%s", prompt)
+	}
+	var meta CaptureMetadata
+	raw, _ := os.ReadFile(filepath.Join(capture.Dir(), "meta_001.json"))
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Model != "test-model" || meta.TotalTokens != 50 || !strings.HasPrefix(meta.Reason, "placeholders lost: ") {
+		t.Errorf("metadata = %+v", meta)
+	}
+}
+
+func TestDebugCaptureKeepsRecentRuns(t *testing.T) {
+	base := t.TempDir()
+	for _, run := range []string{"run-20260101-000000-000", "run-20260102-000000-000", "run-20260103-000000-000", "other"} {
+		if err := os.Mkdir(filepath.Join(base, run), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	capture := NewDebugCapture(base, 3)
+	capture.record([]Message{{Role: "user", Content: "chunk"}}, "", CaptureMetadata{Reason: "test"}, "")
+
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"other", "run-20260102-000000-000", "run-20260103-000000-000", filepath.Base(capture.Dir())}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("runs = %v, want %v", names, want)
+	}
+}
+
+func TestNilDebugCapture(t *testing.T) {
+	var capture *DebugCapture
+	capture.record(nil, "", CaptureMetadata{}, "")
+	if capture.Count() != 0 || capture.Dir() != "" {
+		t.Error("nil capture recorded something")
+	}
+}
@@ -0,0 +1,117 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCoalesceChunksMergesSingleSentenceChunks(t *testing.T) {
+	var chunks []string
+	for i := 0; i < 400; i++ {
+		chunks = append(chunks, fmt.Sprintf("Sentence number %d stands alone in its own paragraph.\n\n", i))
+	}
+	content := strings.Join(chunks, "")
+
+	merged := coalesceChunks(content, chunks, MinChunkSize, MaxChunkSize)
+	if len(merged) > 40 {
+		t.Errorf("expected a few dozen chunks, got %d", len(merged))
+	}
+	if strings.Join(merged, "") != content {
+		t.Error("merged chunks do not concatenate to the content")
+	}
+	for i, c := range merged {
+		if len(c) > MaxChunkSize {
+			t.Errorf("chunk %d exceeds the maximum size: %d", i, len(c))
+		}
+	}
+
+	// Fewer chunks means fewer prompt repetitions
+	promptTokens := func(chunks []string) int {
+		total := 0
+		for _, c := range chunks {
+			total += EstimateTokens(buildSystemPromptWithProtection()) + EstimateTokens(buildUserPromptWithProtection(c, 0))
+		}
+		return total
+	}
+	if before, after := promptTokens(chunks), promptTokens(merged); after*4 > before {
+		t.Errorf("prompt tokens only shrank from %d to %d", before, after)
+	}
+}
+
+func TestCoalesceChunksKeepsLargeChunks(t *testing.T) {
+	large := strings.Repeat("a", 3000)
+	chunks := []string{large, large, large}
+	content := strings.Join(chunks, "")
+
+	merged := coalesceChunks(content, chunks, MinChunkSize, MaxChunkSize)
+	if len(merged) != 3 {
+		t.Errorf("large chunks should not be merged, got %d chunks", len(merged))
+	}
+}
+
+func TestCoalesceChunksRespectsBoundaries(t *testing.T) {
+	// A split inside an oversized environment must stay where it is
+	envStart := "\\begin{table}\n" + strings.Repeat("x", 200)
+	envEnd := strings.Repeat("y", 200) + "\n\\end{table}\n"
+	chunks := []string{envStart, envEnd}
+	if merged := coalesceChunks(envStart+envEnd, chunks, MinChunkSize, MaxChunkSize); len(merged) != 2 {
+		t.Errorf("chunks split inside an environment were merged")
+	}
+
+	// \chapter always starts a new chunk
+	chunks = []string{"Short intro.\n\n", "\\chapter{Methods}\nShort text.\n\n", "More text.\n"}
+	merged := coalesceChunks(strings.Join(chunks, ""), chunks, MinChunkSize, MaxChunkSize)
+	if len(merged) != 2 || !strings.HasPrefix(merged[1], "\\chapter{Methods}") {
+		t.Errorf("unexpected merge across \\chapter: %q", merged)
+	}
+}
+
+func TestPlanChunksMergesSmallChunks(t *testing.T) {
+	// Sections slightly too large for one chunk are split by paragraph and leave a small
+	// remainder chunk, followed by a short section in its own chunk
+	var sb strings.Builder
+	for s := 0; s < 30; s++ {
+		sb.WriteString(fmt.Sprintf("\\section{Long %d}\n", s))
+		sb.WriteString(strings.Repeat("A sentence of the long section.\n\n", 140))
+		sb.WriteString(fmt.Sprintf("\\section{Short %d}\nOne sentence.\n\n", s))
+	}
+	content := sb.String()
+
+	split := splitIntoChunks(content, MaxChunkSize)
+	plan := planChunks(content, MaxChunkSize, true, false, func(s string) (string, error) { return s, nil })
+	if len(plan.chunks) >= len(split) {
+		t.Errorf("expected fewer chunks after merging: split %d, planned %d", len(split), len(plan.chunks))
+	}
+	if strings.Join(plan.chunks, "") != plan.prepared {
+		t.Error("planned chunks do not concatenate to the prepared content")
+	}
+}
+
+func TestQuickChunkSizePlansFewerChunks(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 60; i++ {
+		sb.WriteString("This paragraph describes one part of the method in enough words to matter.\n")
+		sb.WriteString(strings.Repeat("More detail follows here. ", 10) + "\n\n")
+	}
+	content := sb.String()
+
+	engine := NewTranslationEngine("test-key")
+	if got := engine.maxChunkSize(); got != MaxChunkSize {
+		t.Fatalf("default maxChunkSize() = %d, want %d", got, MaxChunkSize)
+	}
+	engine.SetChunkSize(QuickChunkSize)
+	defer engine.SetChunkSize(0)
+
+	identity := func(s string) (string, error) { return s, nil }
+	normal := planChunks(content, MaxChunkSize, true, false, identity)
+	quick := planChunks(content, engine.maxChunkSize(), true, false, identity)
+	if len(quick.chunks) >= len(normal.chunks) {
+		t.Errorf("quick chunk size planned %d chunks, default planned %d", len(quick.chunks), len(normal.chunks))
+	}
+	for _, chunk := range quick.chunks {
+		if len(chunk) > QuickChunkSize {
+			t.Errorf("chunk of %d characters exceeds QuickChunkSize", len(chunk))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package translator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunksNeverStartMidWord(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.tex")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no testdata documents: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := string(data)
+		for size := 40; size < len(content); size += 7 {
+			chunks := splitIntoChunks(content, size)
+			if strings.Join(chunks, "") != content {
+				t.Fatalf("%s, size %d: chunks do not concatenate to the document", file, size)
+			}
+			for i := 1; i < len(chunks); i++ {
+				last, _ := utf8.DecodeLastRuneInString(chunks[i-1])
+				first, _ := utf8.DecodeRuneInString(chunks[i])
+				if !utf8.ValidString(chunks[i]) || isWordRune(last) && isWordRune(first) {
+					t.Errorf("%s, size %d: chunk %d starts mid-word: %q | %q", file, size, i,
+						chunks[i-1][max(0, len(chunks[i-1])-20):], chunks[i][:min(20, len(chunks[i]))])
+				}
+			}
+		}
+	}
+}
+
+func TestFindBreakPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		maxSize int
+		want    string // the text before the break
+	}{
+		{
+			"paragraph break before a sentence end",
+			"The first paragraph ends here, late.\n\nSecond one. It goes on and on",
+			60,
+			"The first paragraph ends here, late.\n\n",
+		},
+		{
+			"sentence end, not an abbreviation",
+			"The model of J. Smith is trained on text. Results are in Fig. 3, e.g. in Tab. 2 and more words",
+			80,
+			"The model of J. Smith is trained on text. ",
+		},
+		{
+			"line break before a space",
+			"\\usepackage{amsmath}\n\\usepackage{graphicx} % graphics and more",
+			50,
+			"\\usepackage{amsmath}\n",
+		},
+		{
+			"edge of a word",
+			"\\usepackage{longtable,booktabs,array,calc,multirow}",
+			40,
+			"\\usepackage{longtable,booktabs,array,",
+		},
+		{
+			"character boundary",
+			"翻译翻译翻译翻译",
+			10,
+			"翻译翻",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.text[:findBreakPoint(tt.text, tt.maxSize)]; got != tt.want {
+				t.Errorf("findBreakPoint() breaks after %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
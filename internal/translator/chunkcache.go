@@ -0,0 +1,168 @@
+package translator
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// ChunkCache keeps the model's translations of the chunks of one source on disk, so a
+// translation that stopped midway (network failure, crash) continues where it stopped
+// instead of paying for the translated chunks again. Every translation is appended to a
+// JSON-lines file as soon as the chunk is done; a line torn by a crash is ignored.
+// All methods are safe for concurrent use.
+type ChunkCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]string // cache key -> raw translation of the chunk
+	file    *os.File          // opened for appending on the first put
+}
+
+// chunkCacheEntry is one line of the cache file
+type chunkCacheEntry struct {
+	Key         string `json:"key"`
+	Translation string `json:"translation"`
+}
+
+// OpenChunkCache loads the chunk cache at path; a missing file is an empty cache
+func OpenChunkCache(path string) (*ChunkCache, error) {
+	c := &ChunkCache{path: path, entries: make(map[string]string)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	skipped := 0
+	for scanner.Scan() {
+		var entry chunkCacheEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Key == "" {
+			skipped++
+			continue
+		}
+		c.entries[entry.Key] = entry.Translation
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	logger.Debug("loaded chunk cache",
+		logger.String("path", path),
+		logger.Int("entries", len(c.entries)),
+		logger.Int("skippedLines", skipped))
+	return c, nil
+}
+
+// Len returns the number of cached chunks
+func (c *ChunkCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get returns the cached translation of a key
+func (c *ChunkCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	translated, ok := c.entries[key]
+	return translated, ok
+}
+
+// put caches the translation of a key and appends it to the cache file
+func (c *ChunkCache) put(key, translated string) error {
+	line, err := json.Marshal(chunkCacheEntry{Key: key, Translation: translated})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = translated
+	if c.file == nil {
+		if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		c.file = f
+	}
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the cache file; the cache can still be read
+func (c *ChunkCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// chunkCacheKey returns the cache key of a chunk: a hash of the chunk and of the settings
+// that change its translation (model, language, Chinese variant with its pinned phrases,
+// the user's glossary and a prompt template other than the default one)
+func chunkCacheKey(model string, lang types.TargetLanguage, variant types.ChineseVariant, phrasesHash, glossaryHash, promptHash, chunk string) string {
+	h := sha256.New()
+	parts := []string{model, string(lang), string(variant), glossaryHash, chunk}
+	if promptHash != "" {
+		parts = append(parts, promptHash)
+	}
+	if phrasesHash != "" {
+		parts = append(parts, "phrases:"+phrasesHash)
+	}
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SetChunkCache sets the cache of chunk translations used by the following translations;
+// nil disables caching
+func (t *TranslationEngine) SetChunkCache(cache *ChunkCache) {
+	t.chunkCache = cache
+}
+
+// translateChunkCached returns the cached translation of a chunk, or translates it and
+// caches the result
+func (t *TranslationEngine) translateChunkCached(ctx context.Context, chunk string) (string, int, error) {
+	cache := t.chunkCache
+	if cache == nil {
+		return t.translateChunkSplitting(ctx, chunk)
+	}
+
+	key := chunkCacheKey(t.model, t.TargetLanguage(), t.outputVariant(), t.variantPhrasesHash(), t.glossary.Hash(), t.promptCacheKey(), chunk)
+	if cached, ok := cache.get(key); ok {
+		t.updateProgress(func(p *TranslationProgress) {
+			p.CachedChunks++
+		})
+		return cached, 0, nil
+	}
+
+	translated, tokens, err := t.translateChunkSplitting(ctx, chunk)
+	if err == nil && translated != "" {
+		if putErr := cache.put(key, translated); putErr != nil {
+			logger.Warn("failed to write chunk cache", logger.String("path", cache.path), logger.Err(putErr))
+		}
+	}
+	return translated, tokens, err
+}
@@ -0,0 +1,153 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// countingServer translates "Paragraph" in every chunk and counts the requests
+func countingServer(t *testing.T) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		translated := strings.ReplaceAll(chunk, "Paragraph", "段落")
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// cacheTestDocument has several chunks of translatable text
+func cacheTestDocument() string {
+	var sb strings.Builder
+	sb.WriteString("\\documentclass{article}\n\\begin{document}\n")
+	for i := 0; i < 6; i++ {
+		sb.WriteString("\\section{Part}\n")
+		sb.WriteString(strings.Repeat("Paragraph text that is long enough to fill the chunk. ", 30))
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("\\end{document}\n")
+	return sb.String()
+}
+
+func TestChunkCacheResumesTranslation(t *testing.T) {
+	server, requests := countingServer(t)
+	path := filepath.Join(t.TempDir(), "cache", "paper.jsonl")
+	content := cacheTestDocument()
+
+	newEngine := func() (*TranslationEngine, *ChunkCache) {
+		cache, err := OpenChunkCache(path)
+		if err != nil {
+			t.Fatalf("OpenChunkCache failed: %v", err)
+		}
+		engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 2)
+		engine.SetChunkCache(cache)
+		return engine, cache
+	}
+
+	engine, cache := newEngine()
+	first, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("first translation failed: %v", err)
+	}
+	cache.Close()
+	sent := atomic.LoadInt32(requests)
+	if sent < 2 || first.CachedChunks != 0 {
+		t.Fatalf("first run sent %d requests, %d cached chunks", sent, first.CachedChunks)
+	}
+
+	// A torn line left by a crash is ignored
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"key":"abc","transl`)
+	f.Close()
+
+	engine, cache = newEngine()
+	defer cache.Close()
+	second, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("resumed translation failed: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != sent {
+		t.Errorf("resumed run sent %d requests, want none", got-sent)
+	}
+	if second.CachedChunks != int(sent) || engine.Progress().CachedChunks != int(sent) {
+		t.Errorf("CachedChunks = %d (progress %d), want %d", second.CachedChunks, engine.Progress().CachedChunks, sent)
+	}
+	if second.TranslatedContent != first.TranslatedContent {
+		t.Error("resumed translation differs from the first one")
+	}
+}
+
+func TestChunkCacheKeyDependsOnModelAndVariant(t *testing.T) {
+	key := chunkCacheKey("model-a", "zh", "zh-Hans", "", "", "", "Hello")
+	others := []string{
+		chunkCacheKey("model-b", "zh", "zh-Hans", "", "", "", "Hello"),
+		chunkCacheKey("model-a", "ja", "", "", "", "", "Hello"),
+		chunkCacheKey("model-a", "zh", "zh-Hant", "", "", "", "Hello"),
+		chunkCacheKey("model-a", "zh", "zh-Hant", "0011223344556677", "", "", "Hello"),
+		chunkCacheKey("model-a", "zh", "zh-Hans", "", "0123456789abcdef", "", "Hello"),
+		chunkCacheKey("model-a", "zh", "zh-Hans", "", "", "fedcba9876543210", "Hello"),
+		chunkCacheKey("model-a", "zh", "zh-Hans", "", "", "", "Hello!"),
+	}
+	for i, other := range append(others, key) {
+		for _, earlier := range append(others, key)[:i] {
+			if other == earlier {
+				t.Error("different settings share a cache key")
+			}
+		}
+	}
+}
+
+func TestChunkCacheKeyDependsOnVariantPhrases(t *testing.T) {
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", "http://localhost/v1/chat/completions", time.Second, 1)
+	engine.SetChineseVariant(types.ChineseTraditional, nil)
+	if engine.variantPhrasesHash() != "" {
+		t.Error("traditional Chinese without phrases has a phrases hash")
+	}
+
+	engine.SetChineseVariant(types.ChineseTraditional, map[string]string{"提示词": "", "内存": "記憶體"})
+	first := engine.variantPhrasesHash()
+	engine.SetChineseVariant(types.ChineseTraditional, map[string]string{"内存": "記憶體", "提示词": ""})
+	if first == "" || engine.variantPhrasesHash() != first {
+		t.Errorf("phrases hash %q is not stable: %q", first, engine.variantPhrasesHash())
+	}
+	engine.SetChineseVariant(types.ChineseTraditional, map[string]string{"提示词": "提示詞", "内存": "記憶體"})
+	if engine.variantPhrasesHash() == first {
+		t.Error("editing a pinned phrase keeps the phrases hash")
+	}
+
+	// Phrases only apply to traditional Chinese output
+	engine.SetChineseVariant(types.ChineseSimplified, map[string]string{"提示词": ""})
+	if engine.variantPhrasesHash() != "" {
+		t.Error("simplified Chinese has a phrases hash")
+	}
+}
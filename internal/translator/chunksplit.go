@@ -0,0 +1,198 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// MaxChunkSplits bounds the splits of one original chunk whose translation came back
+	// truncated, over all its pieces
+	MaxChunkSplits = 4
+	// maxSplitDepth is how often a piece of a chunk may be split again
+	maxSplitDepth = 3
+	// minRatioLines is the number of non-blank lines a chunk needs before the line count
+	// of its translation is compared; short chunks are often reflowed
+	minRatioLines = 12
+	// minLineRatio is the smallest ratio of translated to original non-blank lines of a
+	// complete translation
+	minLineRatio = 0.5
+)
+
+// envTagPattern matches \begin{env} and \end{env}
+var envTagPattern = regexp.MustCompile(`\\(begin|end)\{([^}]+)\}`)
+
+// chunkTruncation tells why the translation of a chunk looks truncated, or "" if it looks
+// complete: an environment the original closes is left open, or the translation has far
+// fewer lines than the original. Both happen when the model stops near the context limit
+// without reporting it.
+func chunkTruncation(source, translated string) string {
+	srcOpen := openEnvironments(source)
+	for env, open := range openEnvironments(translated) {
+		if open > srcOpen[env] {
+			return fmt.Sprintf("environment %s not closed", env)
+		}
+	}
+
+	srcLines := countNonBlankLines(source)
+	if srcLines >= minRatioLines {
+		ratio := float64(countNonBlankLines(translated)) / float64(srcLines)
+		if ratio < minLineRatio {
+			return fmt.Sprintf("line count ratio %.2f", ratio)
+		}
+	}
+	return ""
+}
+
+// openEnvironments counts the \begin of each environment without a matching \end
+func openEnvironments(content string) map[string]int {
+	open := make(map[string]int)
+	for _, m := range envTagPattern.FindAllStringSubmatch(content, -1) {
+		if m[1] == "begin" {
+			open[m[2]]++
+		} else if open[m[2]] > 0 {
+			open[m[2]]--
+		}
+	}
+	return open
+}
+
+// countNonBlankLines counts the lines of content holding more than whitespace
+func countNonBlankLines(content string) int {
+	n := 0
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// splitChunkInHalf splits a chunk at the safe boundary closest to its middle: a paragraph
+// break or the end of a line closing an environment, outside every environment. Returns
+// false when the chunk has no such boundary.
+func splitChunkInHalf(chunk string) (string, string, bool) {
+	var candidates []int
+	for _, m := range paragraphBreakPattern.FindAllStringIndex(chunk, -1) {
+		candidates = append(candidates, m[1])
+	}
+	for _, m := range envTagPattern.FindAllStringSubmatchIndex(chunk, -1) {
+		if chunk[m[2]:m[3]] != "end" {
+			continue
+		}
+		if nl := strings.IndexByte(chunk[m[1]:], '\n'); nl >= 0 {
+			candidates = append(candidates, m[1]+nl+1)
+		}
+	}
+
+	best := -1
+	middle := len(chunk) / 2
+	for _, pos := range candidates {
+		first, second := chunk[:pos], chunk[pos:]
+		if strings.TrimSpace(first) == "" || strings.TrimSpace(second) == "" {
+			continue
+		}
+		if hasOpenEnvironment(first) {
+			continue
+		}
+		if best < 0 || abs(pos-middle) < abs(best-middle) {
+			best = pos
+		}
+	}
+	if best < 0 {
+		return "", "", false
+	}
+	return chunk[:best], chunk[best:], true
+}
+
+// hasOpenEnvironment reports whether content leaves an environment open
+func hasOpenEnvironment(content string) bool {
+	for _, open := range openEnvironments(content) {
+		if open > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// translateChunkSplitting translates a chunk and, when the translation looks truncated,
+// splits the chunk in half at a safe boundary and translates the halves instead,
+// recursing up to maxSplitDepth. The splits of a chunk share a budget of MaxChunkSplits;
+// once it is spent the last translation is kept. The tokens include every attempt.
+func (t *TranslationEngine) translateChunkSplitting(ctx context.Context, chunk string) (string, int, error) {
+	budget := MaxChunkSplits
+	translated, tokens, splits, err := t.translateSplit(ctx, chunk, 0, &budget)
+	if splits > 0 {
+		t.updateProgress(func(p *TranslationProgress) {
+			p.SplitChunks++
+		})
+		logger.Info("translated truncated chunk in pieces",
+			logger.Int("chunkLength", len(chunk)),
+			logger.Int("splits", splits))
+	}
+	return translated, tokens, err
+}
+
+// translateSplit is translateChunkSplitting for a piece at the given depth; it also
+// returns the number of splits made
+func (t *TranslationEngine) translateSplit(ctx context.Context, chunk string, depth int, budget *int) (string, int, int, error) {
+	translated, tokens, err := t.translateChunkWithRetry(ctx, chunk)
+	if err != nil {
+		return "", tokens, 0, err
+	}
+	reason := chunkTruncation(chunk, translated)
+	if reason == "" {
+		return translated, tokens, 0, nil
+	}
+	if depth >= maxSplitDepth || *budget <= 0 {
+		logger.Warn("translated chunk looks truncated, no splits left",
+			logger.String("reason", reason),
+			logger.Int("depth", depth))
+		return translated, tokens, 0, nil
+	}
+	first, second, ok := splitChunkInHalf(chunk)
+	if !ok {
+		logger.Warn("translated chunk looks truncated but has no safe boundary to split at",
+			logger.String("reason", reason),
+			logger.Int("chunkLength", len(chunk)))
+		return translated, tokens, 0, nil
+	}
+
+	*budget--
+	logger.Warn("translated chunk looks truncated, splitting it in half",
+		logger.String("reason", reason),
+		logger.Int("depth", depth),
+		logger.Int("chunkLength", len(chunk)),
+		logger.Int("firstLength", len(first)))
+
+	splits := 1
+	var parts [2]string
+	for i, piece := range []string{first, second} {
+		part, pieceTokens, pieceSplits, err := t.translateSplit(ctx, piece, depth+1, budget)
+		tokens += pieceTokens
+		splits += pieceSplits
+		if err != nil {
+			return "", tokens, splits, err
+		}
+		parts[i] = withEdgeWhitespace(part, piece)
+	}
+	return parts[0] + parts[1], tokens, splits, nil
+}
+
+// withEdgeWhitespace gives a translated piece the leading and trailing whitespace of its
+// original, so the pieces join the way the original did
+func withEdgeWhitespace(translated, original string) string {
+	lead, _, trail := splitOuterWhitespace(original)
+	_, body, _ := splitOuterWhitespace(translated)
+	return lead + body + trail
+}
+
+// FormatSplitSummary describes the chunks translated in pieces for job reports
+func FormatSplitSummary(result *types.TranslationResult) string {
+	return fmt.Sprintf("%d 个分块的译文被截断，已拆分为更小的分块重新翻译；频繁出现时请调小上下文窗口设置", result.SplitChunks)
+}
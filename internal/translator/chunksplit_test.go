@@ -0,0 +1,121 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChunkTruncation(t *testing.T) {
+	lines := strings.Repeat("Lorem ipsum.\n", 14)
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		truncated  bool
+	}{
+		{"complete", lines, strings.Repeat("中文。\n", 13), false},
+		{"lines lost", lines, strings.Repeat("中文。\n", 5), true},
+		{"short chunk reflowed", "One.\nTwo.\nThree.\n", "一二三。\n", false},
+		{"environment left open", "\\begin{itemize}\n\\item A.\n\\end{itemize}\n", "\\begin{itemize}\n\\item 甲。\n", true},
+		{"environment open in the source too", "\\begin{proof}\nSo.\n", "\\begin{proof}\n所以。\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkTruncation(tt.source, tt.translated) != ""; got != tt.truncated {
+				t.Errorf("chunkTruncation() truncated = %v, want %v", got, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestSplitChunkInHalf(t *testing.T) {
+	chunk := "First paragraph.\n\n\\begin{itemize}\n\\item A.\n\n\\item B.\n\\end{itemize}\nAfter the list.\n\nLast paragraph.\n"
+	first, second, ok := splitChunkInHalf(chunk)
+	if !ok {
+		t.Fatal("splitChunkInHalf() found no boundary")
+	}
+	if first+second != chunk {
+		t.Errorf("halves do not join to the chunk: %q + %q", first, second)
+	}
+	if hasOpenEnvironment(first) {
+		t.Errorf("split inside the itemize environment: %q", first)
+	}
+
+	if _, _, ok := splitChunkInHalf("A single paragraph without any break."); ok {
+		t.Error("split a chunk without a safe boundary")
+	}
+}
+
+// splittingServer translates every "Lorem" line of a request into a Chinese line, but
+// answers requests with more than maxLines of them with only two lines
+func splittingServer(t *testing.T, maxLines int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		n := strings.Count(req.Messages[len(req.Messages)-1].Content, "Lorem")
+		if n > maxLines {
+			n = 2
+		}
+		content := strings.TrimSuffix(strings.Repeat("中文句子。\n", n), "\n")
+		body, _ := json.Marshal(ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: content}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestTruncatedChunkIsSplit(t *testing.T) {
+	server, requests := splittingServer(t, 8)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+
+	paragraph := strings.Repeat("Lorem ipsum.\n", 7)
+	chunk := paragraph + "\n" + paragraph
+	translated, tokens, err := engine.translateChunkCached(context.Background(), chunk)
+	if err != nil {
+		t.Fatalf("translateChunkCached() error: %v", err)
+	}
+	if got := countNonBlankLines(translated); got != 14 {
+		t.Errorf("translation has %d lines, want 14:\n%s", got, translated)
+	}
+	if !strings.Contains(translated, "。\n\n中文") {
+		t.Errorf("paragraph break lost where the halves were joined:\n%s", translated)
+	}
+	// The truncated attempt and both halves are paid for
+	if got := atomic.LoadInt32(requests); got != 3 || tokens != 30 {
+		t.Errorf("%d requests for %d tokens, want 3 requests for 30 tokens", got, tokens)
+	}
+	if got := engine.Progress().SplitChunks; got != 1 {
+		t.Errorf("SplitChunks = %d, want 1", got)
+	}
+}
+
+func TestChunkSplitsAreBounded(t *testing.T) {
+	// The model truncates everything with more than one line
+	server, requests := splittingServer(t, 1)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+
+	var paragraphs []string
+	for i := 0; i < 32; i++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Lorem %d.\nLorem ipsum.", i))
+	}
+	if _, _, err := engine.translateChunkCached(context.Background(), strings.Join(paragraphs, "\n\n")); err != nil {
+		t.Fatalf("translateChunkCached() error: %v", err)
+	}
+	// One request per split plus the original chunk and each half
+	if got := atomic.LoadInt32(requests); got > int32(2*MaxChunkSplits+1) {
+		t.Errorf("%d requests for one chunk, want at most %d", got, 2*MaxChunkSplits+1)
+	}
+}
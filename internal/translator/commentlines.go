@@ -0,0 +1,104 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// verbatimEnvNames are the environments whose lines are never comments, even when they
+// start with %
+var verbatimEnvNames = []string{"verbatim", "verbatim*", "Verbatim", "Verbatim*", "BVerbatim", "lstlisting", "minted", "alltt"}
+
+// protectedLinePattern matches a line that is the placeholder of a block protected
+// earlier (comment environment, code chunk, title); such lines are left alone
+var protectedLinePattern = regexp.MustCompile(`^\s*%[A-Z_]+_PLACEHOLDER_\d+%\s*$`)
+
+// SetTranslateComments sets whether full-line comments are sent to the model with the
+// chunks. By default they are kept out of the chunks and put back verbatim.
+func (t *TranslationEngine) SetTranslateComments(translate bool) {
+	t.translateComments = translate
+}
+
+// stripCommentLines replaces every run of full-line comments of content with a
+// %COMMENT_LINES_PLACEHOLDER_N% line, so commented-out text and notes are neither sent
+// to the model nor counted in the chunk sizes. Comments after code on the same line and
+// lines inside verbatim environments are kept, as are runs shorter than their placeholder.
+// The placeholder is itself a comment line, so TeX reads the document the same way.
+func stripCommentLines(content string) (string, []commentPlaceholder) {
+	lines := strings.SplitAfter(content, "\n")
+	var sb strings.Builder
+	var placeholders []commentPlaceholder
+	verbatimEnd := ""
+
+	flush := func(run []string) {
+		if len(run) == 0 {
+			return
+		}
+		block := strings.Join(run, "")
+		newline := ""
+		if strings.HasSuffix(block, "\n") {
+			block, newline = block[:len(block)-1], "\n"
+		}
+		placeholder := fmt.Sprintf("%%COMMENT_LINES_PLACEHOLDER_%d%%", len(placeholders))
+		if len(block) <= len(placeholder) {
+			sb.WriteString(block + newline)
+			return
+		}
+		placeholders = append(placeholders, commentPlaceholder{placeholder: placeholder, original: block})
+		sb.WriteString(placeholder + newline)
+	}
+
+	var run []string
+	for _, line := range lines {
+		if verbatimEnd != "" {
+			sb.WriteString(line)
+			if strings.Contains(line, verbatimEnd) {
+				verbatimEnd = ""
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "%") && !protectedLinePattern.MatchString(line) {
+			run = append(run, line)
+			continue
+		}
+		flush(run)
+		run = nil
+
+		sb.WriteString(line)
+		for _, env := range verbatimEnvNames {
+			if strings.Contains(line, "\\begin{"+env+"}") && !strings.Contains(line, "\\end{"+env+"}") {
+				verbatimEnd = "\\end{" + env + "}"
+				break
+			}
+		}
+	}
+	flush(run)
+
+	if len(placeholders) == 0 {
+		return content, nil
+	}
+	return sb.String(), placeholders
+}
+
+// restoreCommentLines puts the comment lines back in place of their placeholders. A
+// placeholder the model dropped loses its comments, which TeX ignores anyway; the
+// number of such runs is returned.
+func restoreCommentLines(content string, placeholders []commentPlaceholder) (string, int) {
+	lost := 0
+	for _, p := range placeholders {
+		if !strings.Contains(content, p.placeholder) {
+			logger.Warn("comment lines dropped by the model, not restored",
+				logger.String("placeholder", p.placeholder),
+				logger.String("comment", truncateString(p.original, 50)))
+			lost++
+			continue
+		}
+		content = strings.Replace(content, p.placeholder, p.original, 1)
+	}
+	return content, lost
+}
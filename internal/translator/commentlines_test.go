@@ -0,0 +1,55 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripCommentLines(t *testing.T) {
+	content := "\\section{Intro}\n" +
+		"% An old version of the paragraph that was rewritten\n" +
+		"%   and is kept around for the reviewers\n" +
+		"We propose a method. % inline note stays\n" +
+		"%\n" +
+		"\\begin{lstlisting}\n% shell prompt, not a comment of the document\n\\end{lstlisting}\n" +
+		"%CODE_PLACEHOLDER_0%\n" +
+		"  % TODO: cite the baseline paper here and in the related work\n" +
+		"The end.\n"
+
+	stripped, placeholders := stripCommentLines(content)
+	if len(placeholders) != 2 {
+		t.Fatalf("got %d placeholders, want 2: %+v\n%s", len(placeholders), placeholders, stripped)
+	}
+	for _, gone := range []string{"An old version", "TODO: cite"} {
+		if strings.Contains(stripped, gone) {
+			t.Errorf("comment %q still in the stripped content:\n%s", gone, stripped)
+		}
+	}
+	for _, kept := range []string{
+		"We propose a method. % inline note stays\n%\n\\begin{lstlisting}",
+		"% shell prompt, not a comment of the document\n",
+		"%CODE_PLACEHOLDER_0%\n%COMMENT_LINES_PLACEHOLDER_1%\nThe end.",
+		"\\section{Intro}\n%COMMENT_LINES_PLACEHOLDER_0%\nWe propose",
+	} {
+		if !strings.Contains(stripped, kept) {
+			t.Errorf("stripped content is missing %q:\n%s", kept, stripped)
+		}
+	}
+
+	restored, lost := restoreCommentLines(stripped, placeholders)
+	if lost != 0 || restored != content {
+		t.Errorf("restore lost %d runs, got:\n%s\nwant:\n%s", lost, restored, content)
+	}
+
+	if _, lost := restoreCommentLines(strings.Replace(stripped, "%COMMENT_LINES_PLACEHOLDER_1%\n", "", 1), placeholders); lost != 1 {
+		t.Errorf("dropped placeholder counted as %d lost runs, want 1", lost)
+	}
+}
+
+func TestStripCommentLinesWithoutComments(t *testing.T) {
+	content := "Plain text with 50\\% accuracy.\n\\begin{equation}\nx = 1\n\\end{equation}\n"
+	stripped, placeholders := stripCommentLines(content)
+	if stripped != content || placeholders != nil {
+		t.Errorf("content without comment lines changed: %q, %+v", stripped, placeholders)
+	}
+}
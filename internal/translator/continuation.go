@@ -0,0 +1,150 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// MaxContinuations bounds the continuation requests for one truncated chunk
+	MaxContinuations = 3
+	// maxStitchOverlap is the longest repeated text removed when joining a continuation
+	maxStitchOverlap = 300
+	// minStitchOverlap is the shortest repeated text removed; shorter matches are taken
+	// as coincidence (a single repeated character is common in Chinese)
+	minStitchOverlap = 6
+)
+
+// continuationPrompt asks the model to resume a response that hit the output limit
+const continuationPrompt = "Your previous answer was cut off. Continue exactly from where you stopped. " +
+	"Output only the remaining part of the translation, without repeating anything you already wrote " +
+	"and without any explanation. Keep all placeholders (<<<...>>>) unchanged."
+
+// sentenceFinal are the characters a complete paragraph of the translation can end with
+const sentenceFinal = "。！？；：….!?;:”’」』）)】]"
+
+// truncationReason tells why a response is taken as truncated, or "" if it looks complete.
+// finish_reason is authoritative when the provider reports it; for providers that do not,
+// the end of the response is compared with the end of the source chunk.
+func truncationReason(finishReason, source, output string) string {
+	switch finishReason {
+	case "length", "max_tokens":
+		return "finish_reason=" + finishReason
+	case "":
+		if looksTruncated(source, output) {
+			return "incomplete tail"
+		}
+	}
+	return ""
+}
+
+// looksTruncated reports whether output stops before the end of its source: the source
+// ends a sentence but the output does not, or the source ends with a LaTeX token
+// (placeholder or closing brace) that the output lost.
+func looksTruncated(source, output string) bool {
+	src := strings.TrimSpace(source)
+	out := strings.TrimSpace(output)
+	if src == "" || out == "" {
+		// An empty answer is not a truncation; the translation validation reports it
+		return false
+	}
+
+	fields := strings.Fields(src)
+	srcTail := fields[len(fields)-1]
+	if strings.HasSuffix(out, srcTail) {
+		return false
+	}
+
+	if isLaTeXToken(srcTail) {
+		// The model may move the token within the sentence; only a lost token means a
+		// lost tail
+		token := srcTail
+		if i := strings.LastIndex(srcTail, "<<<"); i >= 0 {
+			token = srcTail[i:]
+		}
+		return !strings.Contains(out, token)
+	}
+
+	srcLast, _ := utf8.DecodeLastRuneInString(src)
+	outLast, _ := utf8.DecodeLastRuneInString(out)
+	return strings.ContainsRune(sentenceFinal, srcLast) && !strings.ContainsRune(sentenceFinal, outLast)
+}
+
+// isLaTeXToken reports whether the last word of a chunk is a protected LaTeX token
+func isLaTeXToken(word string) bool {
+	return strings.HasSuffix(word, ">>>") || strings.HasSuffix(word, "}") || strings.HasPrefix(word, "\\")
+}
+
+// stitchContinuation appends a continuation to a truncated response. Models often repeat
+// the last words before the cut; the longest such overlap is removed.
+func stitchContinuation(partial, continuation string) string {
+	limit := maxStitchOverlap
+	if len(partial) < limit {
+		limit = len(partial)
+	}
+	if len(continuation) < limit {
+		limit = len(continuation)
+	}
+	for k := limit; k >= minStitchOverlap; k-- {
+		if partial[len(partial)-k:] == continuation[:k] && utf8.ValidString(continuation[:k]) {
+			logger.Debug("removed repeated text from continuation", logger.Int("overlapBytes", k))
+			return partial + continuation[k:]
+		}
+	}
+	return partial + continuation
+}
+
+// FormatTruncationSummary describes the continuations of a translation for job reports
+func FormatTruncationSummary(result *types.TranslationResult) string {
+	summary := fmt.Sprintf("模型输出被截断，已续写 %d 次", result.Continuations)
+	if result.TruncatedChunks > 0 {
+		summary += fmt.Sprintf("，仍有 %d 个分块可能不完整", result.TruncatedChunks)
+	}
+	return summary
+}
+
+// completeTruncated sends continuation requests while the response looks truncated, at
+// most MaxContinuations times. It returns the stitched content, the tokens used by the
+// continuations, the number of continuations and whether the content still looks
+// truncated.
+func (t *TranslationEngine) completeTruncated(ctx context.Context, messages []Message, source, content, finishReason string, maxTokens int) (string, int, int, bool, error) {
+	tokens, continuations := 0, 0
+	reason := truncationReason(finishReason, source, content)
+	for reason != "" && continuations < MaxContinuations {
+		continuations++
+		logger.Warn("translation output truncated, requesting continuation",
+			logger.String("reason", reason),
+			logger.Int("continuation", continuations),
+			logger.Int("outputLength", len(content)))
+
+		conversation := append(append([]Message(nil), messages...),
+			Message{Role: "assistant", Content: content},
+			Message{Role: "user", Content: continuationPrompt})
+		resp, err := t.chatCompletionContext(ctx, conversation, maxTokens)
+		if err != nil {
+			return content, tokens, continuations, true, err
+		}
+		tokens += resp.Usage.TotalTokens
+
+		next := resp.Choices[0].Message.Content
+		if strings.TrimSpace(next) == "" {
+			// Nothing more to say: the model takes the answer as complete
+			reason = ""
+			break
+		}
+		content = stitchContinuation(content, next)
+		reason = truncationReason(resp.Choices[0].FinishReason, source, content)
+	}
+
+	if reason != "" {
+		logger.Warn("translation output still looks truncated",
+			logger.String("reason", reason),
+			logger.Int("continuations", continuations))
+	}
+	return content, tokens, continuations, reason != "", nil
+}
@@ -0,0 +1,125 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTruncationReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		finishReason string
+		source       string
+		output       string
+		truncated    bool
+	}{
+		{"length reported", "length", "The end.", "结束。", true},
+		{"anthropic style", "max_tokens", "The end.", "结束。", true},
+		{"stop is trusted", "stop", "The method works well.", "该方法效果", false},
+		{"complete sentence", "", "The method works well.", "该方法效果很好。", false},
+		{"cut mid-sentence", "", "The method works well.", "该方法效果", true},
+		{"same final token", "", "see <<<LATEX_CMD_3>>>", "见 <<<LATEX_CMD_3>>>", false},
+		{"token moved", "", "as shown in <<<LATEX_CMD_3>>>", "如<<<LATEX_CMD_3>>>所示", false},
+		{"token lost", "", "as shown in <<<LATEX_CMD_3>>>", "如图所", true},
+		{"source without ending", "", "Results", "结果", false},
+		{"empty output", "", "The end.", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncationReason(tt.finishReason, tt.source, tt.output) != ""
+			if got != tt.truncated {
+				t.Errorf("truncationReason(%q, %q, %q) truncated = %v, want %v", tt.finishReason, tt.source, tt.output, got, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestStitchContinuation(t *testing.T) {
+	tests := []struct {
+		partial, continuation, want string
+	}{
+		{"该方法效果很好。结果", "结果很好。", "该方法效果很好。结果很好。"},
+		{"该方法效果很好。", "结果很好。", "该方法效果很好。结果很好。"},
+		// A single repeated character is not taken as overlap
+		{"很好", "好的结果。", "很好好的结果。"},
+	}
+	for _, tt := range tests {
+		if got := stitchContinuation(tt.partial, tt.continuation); got != tt.want {
+			t.Errorf("stitchContinuation(%q, %q) = %q, want %q", tt.partial, tt.continuation, got, tt.want)
+		}
+	}
+}
+
+// translateTruncated translates content with a mock model that stops after 60% of its
+// answer with the given finish_reason and completes it, repeating a few words, when asked
+// to continue. It returns the translation, the continuation count and the requests made.
+func translateTruncated(t *testing.T, content, finishReason string) (string, int, int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	full := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+
+		var answer string
+		if last := req.Messages[len(req.Messages)-1].Content; last == continuationPrompt {
+			partial := req.Messages[len(req.Messages)-2].Content
+			// Repeat the last two characters before continuing
+			overlap := len(string([]rune(partial)[len([]rune(partial))-2:]))
+			answer = full[len(partial)-overlap:]
+			finishReason = ""
+		} else {
+			chunk := last
+			if i := strings.Index(last, "Now translate:\n\n"); i != -1 {
+				chunk = last[i+len("Now translate:\n\n"):]
+			}
+			full = strings.NewReplacer(
+				"The method works well.", "该方法效果很好，适用于各种不同的场景。",
+				"The results are good.", "实验结果很好，明显优于以往的方法。",
+			).Replace(chunk)
+			runes := []rune(full)
+			answer = string(runes[:len(runes)*6/10])
+		}
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: answer}, FinishReason: finishReason}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	result, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+	return result.TranslatedContent, result.Continuations, requests
+}
+
+func TestTruncatedChunkIsCompletedByContinuation(t *testing.T) {
+	content := "\\section{Method}\nThe method works well. The results are good.\n"
+	for _, finishReason := range []string{"length", ""} {
+		t.Run("finish_reason="+finishReason, func(t *testing.T) {
+			translated, continuations, requests := translateTruncated(t, content, finishReason)
+			if !strings.Contains(translated, "该方法效果很好，适用于各种不同的场景。 实验结果很好，明显优于以往的方法。\n") {
+				t.Errorf("translation is incomplete or repeats text: %q", translated)
+			}
+			if continuations != 1 || requests != 2 {
+				t.Errorf("continuations = %d, requests = %d, want 1 and 2", continuations, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package translator
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// MinDataBlobSize is the minimum size in bytes of a run of data-like lines
+// (base64, hex, numeric tables) before it is treated as an embedded data blob.
+// filecontents environments are always protected regardless of size.
+const MinDataBlobSize = 2048
+
+// minEncodedLineLength is the minimum length of a whitespace-free line
+// to be considered encoded data (base64/hex).
+const minEncodedLineLength = 64
+
+// minEncodedEntropy is the minimum Shannon entropy (bits per character)
+// of an encoded data line. English words rarely exceed ~4.2 without spaces,
+// but they also never come in 64+ character runs without whitespace.
+const minEncodedEntropy = 3.5
+
+// filecontentsBeginPattern matches \begin{filecontents}[opts]{name} and the starred variant
+var filecontentsBeginPattern = regexp.MustCompile(`\\begin\{(filecontents\*?)\}(?:\[[^\]]*\])?\{([^}]*)\}`)
+
+// dataBlobSpan is a detected data blob together with its byte range in the content
+type dataBlobSpan struct {
+	start int
+	end   int
+	info  types.DataBlob
+}
+
+// FindDataBlobs returns all embedded data blobs found in content,
+// in order of appearance.
+func FindDataBlobs(content string) []types.DataBlob {
+	spans := findDataBlobSpans(content)
+	blobs := make([]types.DataBlob, len(spans))
+	for i, s := range spans {
+		blobs[i] = s.info
+	}
+	return blobs
+}
+
+// EstimateTranslatableContent returns the size of content that would actually be
+// sent to the model (excluding embedded data blobs), and the blobs that were excluded.
+func EstimateTranslatableContent(content string) (int, []types.DataBlob) {
+	blobs := FindDataBlobs(content)
+	size := len(content)
+	for _, b := range blobs {
+		size -= b.Size
+	}
+	return size, blobs
+}
+
+// FormatDataBlobSummary formats a one-line summary such as "跳过 2.3MB 嵌入数据 (2 处)".
+// Returns an empty string if there are no blobs.
+func FormatDataBlobSummary(blobs []types.DataBlob) string {
+	if len(blobs) == 0 {
+		return ""
+	}
+	total := 0
+	for _, b := range blobs {
+		total += b.Size
+	}
+	return fmt.Sprintf("跳过 %s 嵌入数据 (%d 处)", FormatDataSize(total), len(blobs))
+}
+
+// DescribeDataBlob formats a single blob for reports, e.g. "filecontents data.csv 第 12 行 (2.3MB)".
+func DescribeDataBlob(b types.DataBlob) string {
+	if b.Name != "" {
+		return fmt.Sprintf("%s %s 第 %d 行 (%s)", b.Kind, b.Name, b.Line, FormatDataSize(b.Size))
+	}
+	return fmt.Sprintf("%s 第 %d 行 (%s)", b.Kind, b.Line, FormatDataSize(b.Size))
+}
+
+// FormatDataSize formats a byte count as B/KB/MB with one decimal place.
+func FormatDataSize(size int) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+// protectDataBlobs replaces embedded data blobs with placeholders so that they
+// are neither sent to the model nor counted towards the chunk count.
+// The placeholders are comment lines, like the comment environment placeholders,
+// so the model leaves them untouched.
+func protectDataBlobs(content string) (string, []commentPlaceholder, []types.DataBlob) {
+	spans := findDataBlobSpans(content)
+	if len(spans) == 0 {
+		return content, nil, nil
+	}
+
+	var sb strings.Builder
+	placeholders := make([]commentPlaceholder, 0, len(spans))
+	blobs := make([]types.DataBlob, 0, len(spans))
+	last := 0
+	for i, s := range spans {
+		placeholder := fmt.Sprintf("%%DATA_BLOB_PLACEHOLDER_%d%%", i)
+		sb.WriteString(content[last:s.start])
+		sb.WriteString(placeholder)
+		last = s.end
+
+		placeholders = append(placeholders, commentPlaceholder{
+			placeholder: placeholder,
+			original:    content[s.start:s.end],
+		})
+		blobs = append(blobs, s.info)
+
+		logger.Debug("protected data blob",
+			logger.String("kind", s.info.Kind),
+			logger.Int("line", s.info.Line),
+			logger.Int("size", s.info.Size))
+	}
+	sb.WriteString(content[last:])
+
+	return sb.String(), placeholders, blobs
+}
+
+// findDataBlobSpans detects filecontents environments first, then scans the
+// remaining lines for runs of encoded or numeric-only data.
+func findDataBlobSpans(content string) []dataBlobSpan {
+	var spans []dataBlobSpan
+
+	// filecontents environments are protected wholesale
+	for _, m := range filecontentsBeginPattern.FindAllStringSubmatchIndex(content, -1) {
+		start := m[0]
+		if len(spans) > 0 && start < spans[len(spans)-1].end {
+			continue
+		}
+		envName := content[m[2]:m[3]]
+		endTag := `\end{` + envName + `}`
+		endPos := strings.Index(content[m[1]:], endTag)
+		if endPos == -1 {
+			logger.Warn("unmatched filecontents environment", logger.Int("position", start))
+			continue
+		}
+		end := m[1] + endPos + len(endTag)
+		spans = append(spans, dataBlobSpan{
+			start: start,
+			end:   end,
+			info: types.DataBlob{
+				Kind: "filecontents",
+				Name: strings.TrimSpace(content[m[4]:m[5]]),
+				Line: strings.Count(content[:start], "\n") + 1,
+				Size: end - start,
+			},
+		})
+	}
+
+	// Runs of data-like lines outside filecontents
+	var lineSpans []dataBlobSpan
+	runStart, runEnd, runLine := -1, -1, 0
+	runKind := ""
+	flush := func() {
+		if runStart >= 0 && runEnd-runStart >= MinDataBlobSize {
+			lineSpans = append(lineSpans, dataBlobSpan{
+				start: runStart,
+				end:   runEnd,
+				info: types.DataBlob{
+					Kind: runKind,
+					Line: runLine,
+					Size: runEnd - runStart,
+				},
+			})
+		}
+		runStart, runEnd, runKind = -1, -1, ""
+	}
+
+	pos := 0
+	lineNum := 0
+	for pos < len(content) {
+		lineNum++
+		nl := strings.IndexByte(content[pos:], '\n')
+		lineEnd := len(content)
+		if nl != -1 {
+			lineEnd = pos + nl
+		}
+		line := content[pos:lineEnd]
+
+		if insideSpans(pos, spans) {
+			flush()
+		} else if kind := dataLineKind(line); kind != "" {
+			if runStart < 0 {
+				runStart, runLine, runKind = pos, lineNum, kind
+			} else if runKind != kind {
+				runKind = "mixed"
+			}
+			runEnd = lineEnd
+		} else {
+			flush()
+		}
+
+		pos = lineEnd + 1
+	}
+	flush()
+
+	if len(lineSpans) == 0 {
+		return spans
+	}
+
+	// Merge both lists by position
+	merged := make([]dataBlobSpan, 0, len(spans)+len(lineSpans))
+	i, j := 0, 0
+	for i < len(spans) || j < len(lineSpans) {
+		if j >= len(lineSpans) || (i < len(spans) && spans[i].start < lineSpans[j].start) {
+			merged = append(merged, spans[i])
+			i++
+		} else {
+			merged = append(merged, lineSpans[j])
+			j++
+		}
+	}
+	return merged
+}
+
+// insideSpans reports whether pos falls inside any of the given spans
+func insideSpans(pos int, spans []dataBlobSpan) bool {
+	for _, s := range spans {
+		if pos >= s.start && pos < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// dataLineKind classifies a single line as "numeric", "encoded" or "" (normal text).
+func dataLineKind(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "%") {
+		return ""
+	}
+
+	if isNumericLine(trimmed) {
+		return "numeric"
+	}
+
+	if len(trimmed) >= minEncodedLineLength &&
+		!strings.ContainsAny(trimmed, " \t\\{}") &&
+		shannonEntropy(trimmed) >= minEncodedEntropy {
+		return "encoded"
+	}
+
+	return ""
+}
+
+// isNumericLine reports whether a line consists only of numbers and separators
+// (CSV/TSV rows, pgfplots coordinate tables, tabular rows of numbers).
+func isNumericLine(line string) bool {
+	hasDigit := false
+	for _, r := range line {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case strings.ContainsRune(" \t.,;:+-eE&\\()[]", r):
+		default:
+			return false
+		}
+	}
+	return hasDigit
+}
+
+// shannonEntropy calculates the Shannon entropy of s in bits per byte
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var freq [256]int
+	for i := 0; i < len(s); i++ {
+		freq[s[i]]++
+	}
+	entropy := 0.0
+	n := float64(len(s))
+	for _, c := range freq {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
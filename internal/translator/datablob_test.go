@@ -0,0 +1,93 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindDataBlobs(t *testing.T) {
+	csvRows := strings.Repeat("0.123, 4.56e-3, 7890, -1.5\n", 200)
+	base64Rows := strings.Repeat("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA\n", 60)
+
+	tests := []struct {
+		name      string
+		input     string
+		wantKinds []string
+		wantName  string
+	}{
+		{
+			name:      "small filecontents is still protected",
+			input:     "\\begin{filecontents*}{data.csv}\na,b\n1,2\n\\end{filecontents*}\n\\documentclass{article}",
+			wantKinds: []string{"filecontents"},
+			wantName:  "data.csv",
+		},
+		{
+			name:      "large numeric block",
+			input:     "Some prose.\n\n" + csvRows + "\nMore prose.",
+			wantKinds: []string{"numeric"},
+		},
+		{
+			name:      "large base64 block",
+			input:     "Intro text.\n" + base64Rows + "Outro text.",
+			wantKinds: []string{"encoded"},
+		},
+		{
+			name:      "small numeric table is not a blob",
+			input:     "1 & 2 & 3 \\\\\n4 & 5 & 6 \\\\\n",
+			wantKinds: nil,
+		},
+		{
+			name:      "prose is not a blob",
+			input:     strings.Repeat("This is a normal sentence in the paper body.\n", 200),
+			wantKinds: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := FindDataBlobs(tt.input)
+			if len(blobs) != len(tt.wantKinds) {
+				t.Fatalf("FindDataBlobs() found %d blobs, want %d: %+v", len(blobs), len(tt.wantKinds), blobs)
+			}
+			for i, b := range blobs {
+				if b.Kind != tt.wantKinds[i] {
+					t.Errorf("blob %d kind = %q, want %q", i, b.Kind, tt.wantKinds[i])
+				}
+			}
+			if tt.wantName != "" && blobs[0].Name != tt.wantName {
+				t.Errorf("blob name = %q, want %q", blobs[0].Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestProtectDataBlobsRoundTrip(t *testing.T) {
+	data := strings.Repeat("1.0 2.0 3.0 4.0 5.0 6.0 7.0 8.0\n", 100000)
+	input := "\\section{Intro}\nWe study things.\n\\begin{filecontents}{big.dat}\n" + data +
+		"\\end{filecontents}\nResults follow.\n"
+
+	protected, placeholders, blobs := protectDataBlobs(input)
+	if len(blobs) != 1 || len(placeholders) != 1 {
+		t.Fatalf("expected 1 blob, got %d", len(blobs))
+	}
+	if len(protected) > 200 {
+		t.Errorf("protected content still contains the blob (%d bytes)", len(protected))
+	}
+	if chunks := splitIntoChunks(protected, MaxChunkSize); len(chunks) != 1 {
+		t.Errorf("expected 1 chunk after protection, got %d", len(chunks))
+	}
+
+	restored := restoreCommentEnvironments(protected, placeholders)
+	if restored != input {
+		t.Errorf("restored content differs from input")
+	}
+
+	size, _ := EstimateTranslatableContent(input)
+	if size != len(protected)-len(placeholders[0].placeholder) {
+		t.Errorf("EstimateTranslatableContent() = %d, want %d", size, len(protected)-len(placeholders[0].placeholder))
+	}
+
+	if summary := FormatDataBlobSummary(blobs); !strings.HasPrefix(summary, "跳过 3.1MB 嵌入数据") {
+		t.Errorf("FormatDataBlobSummary() = %q", summary)
+	}
+}
@@ -0,0 +1,148 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"latex-translator/internal/parser"
+)
+
+// deadContent is typical junk left after the end of a document: an old draft with sections
+// and unbalanced environments, and notes
+const deadContent = `
+% old draft below, kept for reference
+\section{Old Introduction}
+DEAD TEXT that must never be sent to the model.
+\begin{figure}
+\begin{itemize}
+\item DEAD TEXT in a list
+}}} unbalanced braces
+`
+
+// endDocumentFixture is a complete document followed by dead content
+const endDocumentFixture = "\\documentclass{article}\n\\begin{document}\n" +
+	"\\section{Introduction}\nThe method works well.\n\n" +
+	"The results are good.\n" +
+	"\\end{document}" + deadContent
+
+// endInputFixture is a section file ended by \endinput followed by dead content
+const endInputFixture = "\\section{Method}\nThe method works well.\n\n" +
+	"\\newcommand{\\stopfile}{\\endinput}\n" +
+	"The results are good.\n" +
+	"\\endinput % rest of this line is still read" + deadContent
+
+func TestSplitAtDocumentEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantBody string
+	}{
+		{"end document", endDocumentFixture, strings.TrimSuffix(endDocumentFixture, deadContent)},
+		{"endinput keeps rest of line", endInputFixture, strings.TrimSuffix(endInputFixture, deadContent)},
+		{"no end", "Text only.\n", "Text only.\n"},
+		{"verbatim", "\\begin{verbatim}\n\\end{document}\n\\end{verbatim}\nText.\n", "\\begin{verbatim}\n\\end{document}\n\\end{verbatim}\nText.\n"},
+		{"commented out", "Text.\n% \\end{document}\n\\verb|\\endinput| more.\n", "Text.\n% \\end{document}\n\\verb|\\endinput| more.\n"},
+		{"escaped percent", "50\\% \\endinput\nDEAD", "50\\% \\endinput"},
+		{"endinputs is another command", "\\endinputs\nText.\n", "\\endinputs\nText.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, trailing := parser.SplitAtDocumentEnd(tt.content)
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if body+trailing != tt.content {
+				t.Error("body and trailing do not add up to the content")
+			}
+		})
+	}
+}
+
+// translateFixture translates content with a mock model and returns the result and the
+// chunks sent to the model
+func translateFixture(t *testing.T, content string) (string, []string, int) {
+	t.Helper()
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		mu.Lock()
+		sent = append(sent, chunk)
+		mu.Unlock()
+
+		translated := strings.NewReplacer(
+			"The method works well.", "该方法效果很好。",
+			"The results are good.", "结果很好。",
+		).Replace(chunk)
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	result, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+	return result.TranslatedContent, sent, result.SkippedTrailingBytes
+}
+
+func TestTranslationSkipsContentAfterDocumentEnd(t *testing.T) {
+	for name, content := range map[string]string{"end document": endDocumentFixture, "endinput": endInputFixture} {
+		t.Run(name, func(t *testing.T) {
+			translated, sent, skipped := translateFixture(t, content)
+
+			for _, chunk := range sent {
+				if strings.Contains(chunk, "DEAD TEXT") {
+					t.Errorf("dead content was sent to the model: %q", chunk)
+				}
+			}
+			if !strings.HasSuffix(translated, deadContent) {
+				t.Errorf("dead content not preserved verbatim at the end: %q", translated)
+			}
+			if skipped != len(deadContent) {
+				t.Errorf("SkippedTrailingBytes = %d, want %d", skipped, len(deadContent))
+			}
+			if !strings.Contains(translated, "该方法效果很好。") || !strings.Contains(translated, "结果很好。") {
+				t.Errorf("live content was not translated: %q", translated)
+			}
+		})
+	}
+}
+
+func TestValidatorsIgnoreContentAfterDocumentEnd(t *testing.T) {
+	body := strings.TrimSuffix(endDocumentFixture, deadContent)
+	translated := strings.Replace(body, "The method works well.", "该方法效果很好。", 1) +
+		"\n% 另一份不同的残留内容\n\\subsection{Notes}\n\\begin{table}\n"
+
+	comparison := CompareStructure(endDocumentFixture, translated)
+	if !comparison.IsMatch || len(comparison.Differences) != 0 {
+		t.Errorf("phantom structure differences after \\end{document}:\n%s", FormatStructureComparison(comparison))
+	}
+	if result := NewTranslationValidator().ValidateTranslation(endDocumentFixture, translated); !result.IsValid || len(result.Warnings) != 0 {
+		t.Errorf("content after \\end{document} was validated:\n%s", FormatValidationErrors(result))
+	}
+	if comments := ValidateComments(endDocumentFixture, translated); !comments.IsValid {
+		t.Errorf("comments after \\end{document} were validated:\n%s", FormatCommentValidation(comments))
+	}
+}
@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"latex-translator/internal/decisions"
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// Rough throughput used for time and cost estimates
+const (
+	// EstimatedSecondsPerChunk is the time of one translation request
+	EstimatedSecondsPerChunk = 20
+	// EstimatedOutputTokenFactor is the number of output tokens per input token of a chunk
+	// (the translation is about as long as the source)
+	EstimatedOutputTokenFactor = 1
+)
+
+// EstimateTranslation estimates the cost of translating content without calling the model.
+// It runs the same chunking as TranslateTeXWithProgress with the engine's chunk size and
+// counts the tokens of every request (system prompt, user prompt and chunk) with
+// EstimateTokens. \title fragments and float captions, which are translated as requests
+// of their own, are included. The wall time assumes the chunks run with the engine's concurrency.
+// Content that is mostly commands or drawing code (TikZ, pgfplots) is not worth sending
+// to the model and estimates to zero.
+func (t *TranslationEngine) EstimateTranslation(content string) types.TranslationEstimate {
+	var estimate types.TranslationEstimate
+	if content == "" {
+		return estimate
+	}
+	if decisions.MostlyCode(content) {
+		estimate.MostlyCode = true
+		logger.Debug("content is mostly code, estimated as not translated", logger.Int("contentLength", len(content)))
+		return estimate
+	}
+
+	prompt := t.PromptTemplate()
+	systemTokens := EstimateTokens(localizePrompt(systemPromptForVariant(prompt.renderSystem(t.TargetLanguage()), t.outputVariant()), t.language))
+	request := func(text string) {
+		protected, placeholders := ProtectLaTeXCommands(text)
+		userPrompt := localizePrompt(prompt.renderUser(t.TargetLanguage(), "", protected, len(placeholders)), t.language)
+		estimate.InputTokens += systemTokens + EstimateTokens(userPrompt)
+		estimate.OutputTokens += EstimateTokens(text) * EstimatedOutputTokenFactor
+	}
+
+	titleRequests := 0
+	plan := planChunks(content, t.maxChunkSize(), !t.translateComments, t.translateBibliography, func(fragment string) (string, error) {
+		titleRequests++
+		request(fragment)
+		return fragment, nil
+	})
+	for i, chunk := range plan.chunks {
+		request(chunk)
+		if t.chunkOverlap && i > 0 {
+			estimate.InputTokens += EstimateTokens(precedingContext(plan.chunks[i-1]))
+		}
+	}
+	estimate.Chunks = len(plan.chunks)
+
+	// Titles and captions are translated before the chunks, which then run concurrency at a time
+	concurrency := max(t.concurrency, 1)
+	rounds := titleRequests + (estimate.Chunks+concurrency-1)/concurrency
+	estimate.EstimatedSeconds = rounds * EstimatedSecondsPerChunk
+
+	logger.Debug("translation estimated",
+		logger.Int("chunks", estimate.Chunks),
+		logger.Int("inputTokens", estimate.InputTokens),
+		logger.Int("outputTokens", estimate.OutputTokens),
+		logger.Int("estimatedSeconds", estimate.EstimatedSeconds))
+	return estimate
+}
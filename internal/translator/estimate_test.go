@@ -0,0 +1,55 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateTranslation(t *testing.T) {
+	content := cacheTestDocument()
+	previews, _ := PreviewChunks(content)
+	chunkTokens := 0
+	for _, chunk := range previews {
+		chunkTokens += chunk.EstimatedTokens
+	}
+
+	sequential := NewTranslationEngineWithConfig("", "test-model", "", time.Second, 1).EstimateTranslation(content)
+	if sequential.Chunks != len(previews) || sequential.Chunks < 2 {
+		t.Fatalf("Chunks = %d, preview has %d", sequential.Chunks, len(previews))
+	}
+	// Every request repeats the prompts
+	if sequential.InputTokens <= chunkTokens {
+		t.Errorf("InputTokens = %d, want more than the %d tokens of the chunks", sequential.InputTokens, chunkTokens)
+	}
+	if sequential.OutputTokens != chunkTokens*EstimatedOutputTokenFactor {
+		t.Errorf("OutputTokens = %d, want %d", sequential.OutputTokens, chunkTokens*EstimatedOutputTokenFactor)
+	}
+	if want := sequential.Chunks * EstimatedSecondsPerChunk; sequential.EstimatedSeconds != want {
+		t.Errorf("EstimatedSeconds = %d, want %d", sequential.EstimatedSeconds, want)
+	}
+
+	parallel := NewTranslationEngineWithConfig("", "test-model", "", time.Second, sequential.Chunks).EstimateTranslation(content)
+	if parallel.EstimatedSeconds != EstimatedSecondsPerChunk || parallel.InputTokens != sequential.InputTokens {
+		t.Errorf("with concurrency %d: %+v", sequential.Chunks, parallel)
+	}
+}
+
+func TestEstimateTranslationSkipsMostlyCode(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("% Architecture diagram\n\\begin{tikzpicture}[node distance=2cm]\n")
+	for i := 0; i < 40; i++ {
+		sb.WriteString("\\node[draw, rounded corners] (n")
+		sb.WriteString(strings.Repeat("x", i%5+1))
+		sb.WriteString(") at (0,0) {};\n\\draw[->] (a) -- (b);\n")
+	}
+	sb.WriteString("\\end{tikzpicture}\n")
+
+	estimate := NewTranslationEngine("").EstimateTranslation(sb.String())
+	if !estimate.MostlyCode {
+		t.Fatal("TikZ file not detected as mostly code")
+	}
+	if estimate.Chunks != 0 || estimate.InputTokens != 0 || estimate.EstimatedSeconds != 0 {
+		t.Errorf("TikZ file estimated as %+v, want zero", estimate)
+	}
+}
@@ -0,0 +1,240 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// Comment lines around the original text of a chunk that could not be translated. The
+// chunk number (from 1) follows the marker.
+const (
+	FailedChunkStartMarker = "% TRANSLATION-FAILED-CHUNK-START"
+	FailedChunkEndMarker   = "% TRANSLATION-FAILED-CHUNK-END"
+)
+
+// failedChunkStartPattern matches the start marker line of a failed chunk
+var failedChunkStartPattern = regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(FailedChunkStartMarker) + ` (\d+)[ \t]*\n`)
+
+// isChunkFailure reports whether err failed only its chunk (API errors, rate limits,
+// unusable responses), so the document keeps the original text of the chunk. Cancellation,
+// the budget, network outages and configuration errors stop the whole translation.
+func isChunkFailure(err error) bool {
+	if IsBudgetExceeded(err) {
+		return false
+	}
+	var appErr *types.AppError
+	if !errors.As(err, &appErr) {
+		return true
+	}
+	switch appErr.Code {
+	case types.ErrAPICall, types.ErrAPIRateLimit, types.ErrTranslation:
+		return true
+	}
+	return false
+}
+
+// markFailedChunk returns the original text of a chunk between the failed-chunk markers,
+// each on a line of its own
+func markFailedChunk(number int, original string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d\n", FailedChunkStartMarker, number)
+	b.WriteString(original)
+	if !strings.HasSuffix(original, "\n") {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%s %d\n", FailedChunkEndMarker, number)
+	return b.String()
+}
+
+// polishChunk applies the per-chunk fixes to the translation of a chunk: the post-processor
+// comparing it with the original, fullwidth punctuation in math and code arguments, and
+// the paragraph breaks of the original. It returns the normalized characters and restored
+// paragraph breaks.
+func polishChunk(translated, original string, chunkNum int) (string, int, int) {
+	beforeLen := len(translated)
+	translated = PostprocessChunk(translated, original)
+	if afterLen := len(translated); beforeLen != afterLen {
+		logger.Info("postprocessor changed chunk",
+			logger.Int("chunkIndex", chunkNum),
+			logger.Int("beforeLen", beforeLen),
+			logger.Int("afterLen", afterLen))
+	}
+
+	translated, normalized := normalizeTranslatedChunk(translated)
+
+	// Blank lines are paragraph breaks in LaTeX; keep them as in the original
+	translated, breakFixes := RestoreParagraphBreaks(translated, original)
+	if breakFixes > 0 {
+		logger.Info("restored paragraph breaks in chunk",
+			logger.Int("chunkIndex", chunkNum),
+			logger.Int("fixes", breakFixes))
+	}
+	return translated, normalized, breakFixes
+}
+
+// failedChunk is the original text of a failed chunk in a translated document
+type failedChunk struct {
+	number     int
+	start, end int // the marker lines included
+	original   string
+}
+
+// findFailedChunks returns the failed chunks of a translated document in document order.
+// A start marker without its end marker is ignored.
+func findFailedChunks(content string) []failedChunk {
+	var chunks []failedChunk
+	pos := 0
+	for {
+		loc := failedChunkStartPattern.FindStringSubmatchIndex(content[pos:])
+		if loc == nil {
+			return chunks
+		}
+		start, bodyStart := pos+loc[0], pos+loc[1]
+		number, _ := strconv.Atoi(content[pos+loc[2] : pos+loc[3]])
+		// The search starts at the newline of the start marker, so an empty chunk is found
+		endPattern := regexp.MustCompile(`\n` + regexp.QuoteMeta(FailedChunkEndMarker) + ` ` + strconv.Itoa(number) + `[ \t]*(?:\n|$)`)
+		endLoc := endPattern.FindStringIndex(content[bodyStart-1:])
+		if endLoc == nil {
+			pos = bodyStart
+			continue
+		}
+		bodyEnd := bodyStart + endLoc[0]
+		end := bodyStart - 1 + endLoc[1]
+		chunks = append(chunks, failedChunk{number: number, start: start, end: end, original: content[bodyStart:bodyEnd]})
+		pos = end
+	}
+}
+
+// FailedChunkNumbers returns the numbers of the chunks whose original text is still marked
+// as failed in a translated document
+func FailedChunkNumbers(content string) []int {
+	var numbers []int
+	for _, chunk := range findFailedChunks(content) {
+		numbers = append(numbers, chunk.number)
+	}
+	return numbers
+}
+
+// FormatChunkNumbers lists chunk numbers for messages, e.g. "3、7"
+func FormatChunkNumbers(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, "、")
+}
+
+// FormatFailedChunksSummary describes the chunks that kept their original text for job
+// reports
+func FormatFailedChunksSummary(result *types.TranslationResult) string {
+	return fmt.Sprintf("第 %s 块（共 %d 块）重试后仍翻译失败，保留了原文（位于 %s/END 注释之间），可以只重试失败的分块",
+		FormatChunkNumbers(result.FailedChunks), len(result.FailedChunks), FailedChunkStartMarker)
+}
+
+// RetryFailedChunks translates again the failed chunks of the translated document at path
+// and rewrites the file in place; the rest of the file is not touched. Each chunk goes
+// through the full translation of TranslateTeXWithProgressContext. Chunks that fail again
+// keep their markers and are listed in FailedChunks of the result. Cancellation, the budget
+// and network outages return the error and leave the file as it was.
+func (t *TranslationEngine) RetryFailedChunks(ctx context.Context, path string) (*types.TranslationResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, "读取译文失败", err)
+	}
+	content := string(data)
+	chunks := findFailedChunks(content)
+	result := &types.TranslationResult{OriginalContent: content, TranslatedContent: content}
+	if len(chunks) == 0 {
+		return result, nil
+	}
+	logger.Info("retrying failed chunks", logger.String("path", path), logger.String("chunks", FormatChunkNumbers(FailedChunkNumbers(content))))
+
+	var out strings.Builder
+	last := 0
+	for _, chunk := range chunks {
+		out.WriteString(content[last:chunk.start])
+		last = chunk.end
+
+		translated, err := t.TranslateTeXWithProgressContext(ctx, chunk.original, nil)
+		if err != nil {
+			if ctx.Err() != nil || !isChunkFailure(err) {
+				return nil, err
+			}
+			logger.Warn("failed chunk failed again", logger.Int("chunk", chunk.number), logger.Err(err))
+			result.FailedChunks = append(result.FailedChunks, chunk.number)
+			out.WriteString(content[chunk.start:chunk.end])
+			continue
+		}
+		result.TokensUsed += translated.TokensUsed
+		result.RetriedChunks += translated.RetriedChunks
+		if len(translated.FailedChunks) > 0 {
+			// The chunk was split again and some of its pieces failed; they carry markers
+			// of their own pieces, renumber them as the chunk they belong to
+			result.FailedChunks = append(result.FailedChunks, chunk.number)
+			out.WriteString(renumberFailedChunks(translated.TranslatedContent, chunk.number))
+			continue
+		}
+		out.WriteString(translated.TranslatedContent)
+		if !strings.HasSuffix(translated.TranslatedContent, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	out.WriteString(content[last:])
+	result.TranslatedContent = out.String()
+
+	if err := os.WriteFile(path, []byte(result.TranslatedContent), 0644); err != nil {
+		return nil, types.NewAppError(types.ErrInternal, "保存译文失败", err)
+	}
+	logger.Info("retried failed chunks",
+		logger.String("path", path),
+		logger.Int("retried", len(chunks)),
+		logger.Int("stillFailed", len(result.FailedChunks)),
+		logger.Int("tokens", result.TokensUsed))
+	return result, nil
+}
+
+// renumberFailedChunks gives every failed-chunk marker of content the number n
+func renumberFailedChunks(content string, n int) string {
+	for _, marker := range []string{FailedChunkStartMarker, FailedChunkEndMarker} {
+		pattern := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(marker) + ` \d+`)
+		content = pattern.ReplaceAllString(content, fmt.Sprintf("%s %d", marker, n))
+	}
+	return content
+}
+
+// FailedChunksInDir returns the numbers of the failed chunks of every tex file under dir,
+// keyed by the path relative to dir; nil when no file has one
+func FailedChunksInDir(dir string) map[string][]int {
+	var found map[string][]int
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".tex") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), FailedChunkStartMarker) {
+			return nil
+		}
+		if numbers := FailedChunkNumbers(string(data)); len(numbers) > 0 {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil
+			}
+			if found == nil {
+				found = make(map[string][]int)
+			}
+			found[filepath.ToSlash(rel)] = numbers
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,148 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const failedChunkFixture = `\section{Introduction}
+The method works well. The method works well. The method works well.
+The results are good. The results are good. The results are good.
+
+\section{Broken}
+The model rejects this section.
+
+\section{Method}
+The method works well. The method works well. The method works well.
+The results are good. The results are good. The results are good.
+
+\section{Results}
+The method works well. The method works well. The method works well.
+The results are good. The results are good. The results are good.
+`
+
+// failingChunkServer translates the fixture sentences and rejects every chunk with
+// "rejects" while *broken is set
+func failingChunkServer(t *testing.T, broken *atomic.Bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		if broken.Load() && strings.Contains(prompt, "rejects") {
+			http.Error(w, `{"error":{"message":"content rejected"}}`, http.StatusBadRequest)
+			return
+		}
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		translated := strings.NewReplacer(
+			"The method works well.", "该方法效果很好。",
+			"The results are good.", "结果很好。",
+			"The model rejects this section.", "模型拒绝了这一节。",
+		).Replace(chunk)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFailedChunkKeepsOriginalText(t *testing.T) {
+	var broken atomic.Bool
+	broken.Store(true)
+	server := failingChunkServer(t, &broken)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 3)
+	engine.SetChunkSize(200)
+
+	result, err := engine.TranslateTeX(failedChunkFixture)
+	if err != nil {
+		t.Fatalf("TranslateTeX() error: %v", err)
+	}
+	if !reflect.DeepEqual(result.FailedChunks, []int{2}) {
+		t.Fatalf("FailedChunks = %v, want [2]", result.FailedChunks)
+	}
+	want := FailedChunkStartMarker + " 2\n\\section{Broken}\nThe model rejects this section.\n\n" + FailedChunkEndMarker + " 2\n"
+	if !strings.Contains(result.TranslatedContent, want) {
+		t.Errorf("original text of the failed chunk not marked:\n%s", result.TranslatedContent)
+	}
+	// The chunks stay in document order whatever order they finished in
+	order := []string{`\section{Introduction}`, FailedChunkStartMarker, `\section{Method}`, `\section{Results}`}
+	last := -1
+	for _, part := range order {
+		i := strings.Index(result.TranslatedContent, part)
+		if i <= last {
+			t.Fatalf("%q out of order:\n%s", part, result.TranslatedContent)
+		}
+		last = i
+	}
+
+	// Retrying patches the file in place once the model accepts the chunk
+	path := filepath.Join(t.TempDir(), "translated_main.tex")
+	if err := os.WriteFile(path, []byte(result.TranslatedContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	retried, err := engine.RetryFailedChunks(t.Context(), path)
+	if err != nil || !reflect.DeepEqual(retried.FailedChunks, []int{2}) {
+		t.Fatalf("RetryFailedChunks() while still failing = %v, %v", retried.FailedChunks, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != result.TranslatedContent {
+		t.Errorf("chunk failing again changed the file:\n%s", data)
+	}
+
+	broken.Store(false)
+	retried, err = engine.RetryFailedChunks(t.Context(), path)
+	if err != nil || len(retried.FailedChunks) != 0 {
+		t.Fatalf("RetryFailedChunks() = %v, %v", retried.FailedChunks, err)
+	}
+	data, _ := os.ReadFile(path)
+	patched := string(data)
+	if strings.Contains(patched, "TRANSLATION-FAILED-CHUNK") || !strings.Contains(patched, "\\section{Broken}\n模型拒绝了这一节。\n\n\\section{Method}") {
+		t.Errorf("failed chunk not patched in place:\n%s", patched)
+	}
+	if len(FailedChunkNumbers(patched)) != 0 {
+		t.Errorf("FailedChunkNumbers() of the patched file = %v", FailedChunkNumbers(patched))
+	}
+}
+
+func TestEveryChunkFailingFailsTheDocument(t *testing.T) {
+	var broken atomic.Bool
+	broken.Store(true)
+	server := failingChunkServer(t, &broken)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 2)
+	if _, err := engine.TranslateTeX("\\section{Broken}\nThe model rejects this section.\n"); err == nil {
+		t.Error("a document without a translated chunk succeeded")
+	}
+}
+
+func TestFindFailedChunks(t *testing.T) {
+	content := "前文\n" + markFailedChunk(1, "First.\n") + "中间\n" + markFailedChunk(12, "Twelfth") +
+		FailedChunkStartMarker + " 3\nUnterminated.\n"
+	chunks := findFailedChunks(content)
+	if len(chunks) != 2 || chunks[0].original != "First.\n" || chunks[1].original != "Twelfth\n" {
+		t.Fatalf("findFailedChunks() = %+v", chunks)
+	}
+	if got := content[chunks[0].start:chunks[0].end]; got != markFailedChunk(1, "First.\n") {
+		t.Errorf("first block = %q", got)
+	}
+	// The end marker of chunk 1 is not taken for the one of chunk 12
+	if got := FormatChunkNumbers(FailedChunkNumbers(content)); got != "1、12" {
+		t.Errorf("FailedChunkNumbers() = %s", got)
+	}
+	if got := renumberFailedChunks(markFailedChunk(1, "A\n")+markFailedChunk(2, "B\n"), 7); strings.Count(got, " 7\n") != 4 {
+		t.Errorf("renumberFailedChunks() = %q", got)
+	}
+}
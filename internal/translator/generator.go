@@ -0,0 +1,103 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Generators of LaTeX sources recognized by DetectGenerator
+const (
+	GeneratorKnitr  = "knitr"
+	GeneratorSweave = "Sweave"
+	GeneratorPandoc = "pandoc"
+)
+
+var (
+	// rnwChunkStartPattern matches the header of a knitr/Sweave code chunk: <<label, opts>>=
+	rnwChunkStartPattern = regexp.MustCompile(`(?m)^[ \t]*<<[^\n]*>>=[ \t]*$`)
+	// rnwChunkEndPattern matches the line closing a knitr/Sweave code chunk
+	rnwChunkEndPattern = regexp.MustCompile(`(?m)^[ \t]*@[ \t]*$`)
+
+	// knitrSignaturePattern matches what knitr writes into the tex files it generates
+	knitrSignaturePattern = regexp.MustCompile(`\\begin\{knitrout\}|\\newenvironment\{kframe\}|\\definecolor\{fgcolor\}|\\usepackage\{knitr\}|\bopts_chunk\$set\b`)
+	// sweaveSignaturePattern matches what Sweave writes into the tex files it generates
+	sweaveSignaturePattern = regexp.MustCompile(`\\usepackage(?:\[[^\]]*\])?\{Sweave\}|\\SweaveOpts\b|\\begin\{Schunk\}`)
+	// pandocSignaturePattern matches the helper definitions of pandoc's LaTeX template
+	pandocSignaturePattern = regexp.MustCompile(`\\providecommand\{\\tightlist\}|\\def\\tightlist\b|pdfcreator=\{LaTeX via pandoc\}|\\newenvironment\{Shaded\}|\\providecommand\{\\pandocbounded\}`)
+
+	// generatorHelperPattern matches helper macros of generated sources that look like noise
+	// to the model; they are protected like other commands so they come back unchanged
+	generatorHelperPattern = regexp.MustCompile(`\\tightlist\b|\\passthrough\{(?:[^{}]|\{[^{}]*\})*\}`)
+)
+
+// codeChunkEnvironments are environments holding generated code and its output (knitr,
+// Sweave, pandoc syntax highlighting); they are never sent to the model
+var codeChunkEnvironments = []string{"knitrout", "Schunk", "Shaded"}
+
+// DetectGenerator returns the tool that generated a LaTeX source (GeneratorKnitr,
+// GeneratorSweave or GeneratorPandoc), or "" for a hand-written one. Code chunks in .Rnw
+// syntax count as knitr.
+func DetectGenerator(content string) string {
+	switch {
+	case knitrSignaturePattern.MatchString(content):
+		return GeneratorKnitr
+	case sweaveSignaturePattern.MatchString(content):
+		return GeneratorSweave
+	case rnwChunkStartPattern.MatchString(content):
+		return GeneratorKnitr
+	case pandocSignaturePattern.MatchString(content):
+		return GeneratorPandoc
+	}
+	return ""
+}
+
+// codeChunkSpan is the byte range of a code chunk
+type codeChunkSpan struct {
+	start, end int
+}
+
+// findCodeChunks returns the knitr/Sweave code chunks (<<>>= ... @, up to the next chunk
+// or the end when @ is missing) and the code chunk environments of content, in order
+func findCodeChunks(content string) []codeChunkSpan {
+	var spans []codeChunkSpan
+	for _, m := range rnwChunkStartPattern.FindAllStringIndex(content, -1) {
+		if len(spans) > 0 && m[0] < spans[len(spans)-1].end {
+			continue
+		}
+		end := len(content)
+		if e := rnwChunkEndPattern.FindStringIndex(content[m[1]:]); e != nil {
+			end = m[1] + e[1]
+		}
+		if next := rnwChunkStartPattern.FindStringIndex(content[m[1]:]); next != nil && m[1]+next[0] < end {
+			end = m[1] + next[0]
+		}
+		spans = append(spans, codeChunkSpan{m[0], end})
+	}
+
+	for _, env := range codeChunkEnvironments {
+		beginTag, endTag := `\begin{`+env+`}`, `\end{`+env+`}`
+		for offset := 0; ; {
+			begin := strings.Index(content[offset:], beginTag)
+			if begin < 0 {
+				break
+			}
+			begin += offset
+			end := strings.Index(content[begin:], endTag)
+			if end < 0 {
+				break
+			}
+			end += begin + len(endTag)
+			spans = append(spans, codeChunkSpan{begin, end})
+			offset = end
+		}
+	}
+
+	return outermostSpans(spans)
+}
+
+// protectCodeChunks replaces knitr/Sweave code chunks and generated code environments
+// with comment placeholders, so their code is neither translated nor taken apart by the
+// math and command protection (R code is full of $ and _)
+func protectCodeChunks(content string) (string, []commentPlaceholder) {
+	return replaceSpans(content, findCodeChunks(content), "CODE_CHUNK_PLACEHOLDER")
+}
@@ -0,0 +1,172 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// generatedFixtureTranslations are the prose of the generated fixtures and its translation
+var generatedFixtureTranslations = strings.NewReplacer(
+	// pandoc_paper.tex
+	"Sparse retrieval ranks documents by weighted term overlap. We compare\nthree weighting schemes:",
+	"稀疏检索按加权词项重叠对文档进行排序。我们比较\n三种加权方案：",
+	"term frequency,", "词频，",
+	"BM25, and", "BM25，以及",
+	"learned expansion.", "学习式扩展。",
+	"The index is built with the following call:", "索引通过以下调用构建：",
+	"for the schemes.", "中的各种方案。",
+	"Introduction", "引言",
+	"Setup", "实验设置",
+	// knitr_paper.tex
+	"We analyse", "我们分析了",
+	"admissions recorded over five years.\nThe monthly counts are summarised below.",
+	"条五年间记录的入院数据。\n每月的入院人数汇总如下。",
+	"Admissions peak in winter, as the following figure shows.", "如下图所示，入院人数在冬季达到高峰。",
+	"The effect persists after adjusting for population growth.", "在校正人口增长后，该效应依然存在。",
+	"Data", "数据",
+	"Results", "结果",
+)
+
+// translateGeneratedFixture translates a fixture from testdata with a mock model and returns
+// the original, the translation, the generator and the chunks sent to the model
+func translateGeneratedFixture(t *testing.T, name string) (string, string, string, []string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		mu.Lock()
+		sent = append(sent, chunk)
+		mu.Unlock()
+
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: generatedFixtureTranslations.Replace(chunk)}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	result, err := engine.TranslateTeX(string(data))
+	if err != nil {
+		t.Fatalf("translation of %s failed: %v", name, err)
+	}
+	return string(data), result.TranslatedContent, result.Generator, sent
+}
+
+func TestDetectGenerator(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"knitr output", "\\begin{knitrout}\n\\end{knitrout}\n", GeneratorKnitr},
+		{"Rnw chunk", "Text.\n<<plot, echo=FALSE>>=\nplot(x)\n@\n", GeneratorKnitr},
+		{"Sweave", "\\usepackage{Sweave}\n\\begin{Schunk}\n\\end{Schunk}\n", GeneratorSweave},
+		{"pandoc", "\\providecommand{\\tightlist}{%\n  \\setlength{\\itemsep}{0pt}}\n", GeneratorPandoc},
+		{"hand-written", "\\documentclass{article}\n\\begin{itemize}\n\\item A\n\\end{itemize}\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectGenerator(tt.content); got != tt.want {
+				t.Errorf("DetectGenerator() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedSourcesKeepCodeChunksAndHelperMacros(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		generator string
+		code      []string // code that must come back byte for byte and never reach the model
+		helpers   []string // helper macros that must keep their count
+		prose     string   // translated prose
+	}{
+		{
+			fixture:   "knitr_paper.tex",
+			generator: GeneratorKnitr,
+			code: []string{
+				"<<setup, include=FALSE>>=\nlibrary(ggplot2)\nopts_chunk$set(fig.width = 5, echo = TRUE)\n# Load the admissions data\nadmissions <- read.csv(\"admissions.csv\")\n@",
+				"<<summary-table, results='asis'>>=\n# Monthly totals\nmonthly <- aggregate(count ~ month, data = admissions, FUN = sum)\nprint(xtable::xtable(monthly), include.rownames = FALSE)\n@",
+				"\\hlkwd{summary}\\hlstd{(monthly}\\hlopt{$}\\hlstd{count)}",
+			},
+			helpers: []string{"\\Sexpr{nrow(admissions)}", "\\begin{knitrout}", "\\begin{kframe}"},
+			prose:   "如下图所示，入院人数在冬季达到高峰。",
+		},
+		{
+			fixture:   "pandoc_paper.tex",
+			generator: GeneratorPandoc,
+			code: []string{
+				"\\NormalTok{index }\\OperatorTok{=}\\NormalTok{ build\\_index(corpus, scheme}\\OperatorTok{=}\\StringTok{\"bm25\"}\\NormalTok{)}",
+				"\\KeywordTok{for}\\NormalTok{ doc }\\KeywordTok{in}\\NormalTok{ corpus:}",
+			},
+			helpers: []string{"\\tightlist", "\\hypertarget{introduction}{%\n\\section{Introduction}\\label{introduction}}", "\\hypertarget{setup}{%", "\\protect\\hyperlink{introduction}{1}"},
+			prose:   "稀疏检索按加权词项重叠对文档进行排序。",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			original, translated, generator, sent := translateGeneratedFixture(t, tt.fixture)
+
+			if generator != tt.generator {
+				t.Errorf("Generator = %q, want %q", generator, tt.generator)
+			}
+			for _, code := range tt.code {
+				if !strings.Contains(translated, code) {
+					t.Errorf("code chunk changed, missing %q:\n%s", code, translated)
+				}
+				firstLine := strings.SplitN(code, "\n", 2)[0]
+				for _, chunk := range sent {
+					if strings.Contains(chunk, firstLine) {
+						t.Errorf("code was sent to the model: %q", firstLine)
+					}
+				}
+			}
+			for _, helper := range tt.helpers {
+				want := strings.Count(original, helper)
+				if want == 0 {
+					want = 1 // contains translated text
+				}
+				if got := strings.Count(translated, helper); got != want {
+					t.Errorf("%q occurs %d times in the translation, want %d:\n%s", helper, got, want, translated)
+				}
+			}
+			if !strings.Contains(translated, tt.prose) {
+				t.Errorf("prose not translated:\n%s", translated)
+			}
+
+			if comparison := CompareStructure(original, translated); !comparison.IsMatch {
+				t.Errorf("structure differs:\n%s", FormatStructureComparison(comparison))
+			}
+			if result := NewTranslationValidator().ValidateTranslation(original, translated); !result.IsValid {
+				t.Errorf("validation failed:\n%s", FormatValidationErrors(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,479 @@
+package translator
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// maxGlossaryPromptTerms bounds the glossary terms added to one chunk prompt
+const maxGlossaryPromptTerms = 60
+
+// minAutoTermLength is the shortest macro body or section title added to the glossary
+// automatically; shorter ones ("Intro", "A") are too ambiguous to enforce
+const minAutoTermLength = 4
+
+// Glossary maps English terms to the rendering every chunk must use, so a term is not
+// translated differently from chunk to chunk. The user's terms come from a JSON or CSV file;
+// the engine adds the renderings of \newcommand macros and section titles it finds in the
+// first chunk of a document. Terms are added to the prompt of every chunk that contains them,
+// and terms the model left in English are replaced in its output. It is safe for concurrent
+// use, so the engines of a book can share one glossary.
+type Glossary struct {
+	mu    sync.Mutex
+	terms map[string]*glossaryTerm // lower-case source -> term
+}
+
+// glossaryTerm is one entry of a glossary
+type glossaryTerm struct {
+	source  string
+	target  string
+	auto    bool // learned from a first chunk, not from the user's file
+	pattern *regexp.Regexp
+}
+
+// glossaryFileEntry is one entry of a JSON glossary written as a list
+type glossaryFileEntry struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// NewGlossary returns an empty glossary; it still collects terms from first chunks
+func NewGlossary() *Glossary {
+	return &Glossary{terms: make(map[string]*glossaryTerm)}
+}
+
+// LoadGlossary reads a glossary file. A .json file is an object mapping terms to their
+// rendering or a list of {"source", "target"} objects; a .csv file has one term and its
+// rendering per row, an optional source,target header and # comment lines.
+func LoadGlossary(path string) (*Glossary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, types.NewAppError(types.ErrFileNotFound, fmt.Sprintf("无法读取术语表: %s", path), err)
+	}
+
+	g := NewGlossary()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = g.parseJSON(data)
+	case ".csv":
+		err = g.parseCSV(data)
+	default:
+		return nil, types.NewAppError(types.ErrInvalidInput, "术语表必须是 .json 或 .csv 文件", nil)
+	}
+	if err != nil {
+		return nil, types.NewAppErrorWithDetails(types.ErrInvalidInput, fmt.Sprintf("术语表格式错误: %s", path), err.Error(), err)
+	}
+
+	logger.Info("glossary loaded", logger.String("path", path), logger.Int("terms", g.Len()))
+	return g, nil
+}
+
+// parseJSON adds the terms of a JSON glossary
+func (g *Glossary) parseJSON(data []byte) error {
+	var object map[string]string
+	if err := json.Unmarshal(data, &object); err == nil {
+		for source, target := range object {
+			g.Add(source, target)
+		}
+		return nil
+	}
+	var list []glossaryFileEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, entry := range list {
+		g.Add(entry.Source, entry.Target)
+	}
+	return nil
+}
+
+// parseCSV adds the terms of a CSV glossary
+func (g *Glossary) parseCSV(data []byte) error {
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff")))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	for row := 0; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 2 {
+			continue
+		}
+		if row == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "source") {
+			continue
+		}
+		g.Add(record[0], record[1])
+	}
+}
+
+// SetGlossary sets the glossary of the following translations; nil disables it. With a
+// glossary, the first chunk of a document is translated before the others.
+func (t *TranslationEngine) SetGlossary(g *Glossary) {
+	t.glossary = g
+}
+
+// Len returns the number of terms
+func (g *Glossary) Len() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.terms)
+}
+
+// Add adds a user term, replacing an earlier rendering of the same term; empty terms are ignored
+func (g *Glossary) Add(source, target string) {
+	g.add(source, target, false)
+}
+
+// add adds a term; a learned term never replaces an existing one
+func (g *Glossary) add(source, target string, auto bool) bool {
+	source = strings.Join(strings.Fields(source), " ")
+	target = strings.TrimSpace(target)
+	if source == "" || target == "" {
+		return false
+	}
+	key := strings.ToLower(source)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if existing, ok := g.terms[key]; ok && (auto || !existing.auto && existing.target == target) {
+		return false
+	}
+	// Words are separated by any whitespace, line breaks included
+	words := strings.Fields(source)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	g.terms[key] = &glossaryTerm{
+		source:  source,
+		target:  target,
+		auto:    auto,
+		pattern: regexp.MustCompile(`(?i)` + strings.Join(words, `\s+`)),
+	}
+	return true
+}
+
+// Hash returns a short hash of the user's terms, which change the translation of a chunk;
+// learned terms are derived from the document and not included. Empty without user terms.
+func (g *Glossary) Hash() string {
+	if g == nil {
+		return ""
+	}
+	g.mu.Lock()
+	var lines []string
+	for _, term := range g.terms {
+		if !term.auto {
+			lines = append(lines, term.source+"\t"+term.target)
+		}
+	}
+	g.mu.Unlock()
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// sortedTerms returns the terms with an English occurrence in text, longest first so a
+// longer term wins over a term it contains
+func (g *Glossary) sortedTerms(text string) []*glossaryTerm {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	var terms []*glossaryTerm
+	for _, term := range g.terms {
+		if strings.EqualFold(term.source, term.target) {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	g.mu.Unlock()
+
+	found := terms[:0]
+	for _, term := range terms {
+		if len(termMatches(text, term)) > 0 {
+			found = append(found, term)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if len(found[i].source) != len(found[j].source) {
+			return len(found[i].source) > len(found[j].source)
+		}
+		return found[i].source < found[j].source
+	})
+	return found
+}
+
+// promptSection returns the glossary instructions for a chunk: the terms that occur in it
+func (g *Glossary) promptSection(chunk string) string {
+	terms := g.sortedTerms(chunk)
+	if len(terms) == 0 {
+		return ""
+	}
+	if len(terms) > maxGlossaryPromptTerms {
+		terms = terms[:maxGlossaryPromptTerms]
+	}
+	var sb strings.Builder
+	sb.WriteString("GLOSSARY: translate these terms exactly as given, every time they occur:\n")
+	for _, term := range terms {
+		fmt.Fprintf(&sb, "- %s => %s\n", term.source, term.target)
+	}
+	return sb.String()
+}
+
+// glossarySkipPattern matches command arguments that are identifiers or paths, never text
+var glossarySkipPattern = regexp.MustCompile(`\\(?:label|ref|eqref|pageref|autoref|cref|Cref|cite[a-zA-Z]*|url|href|input|include|includegraphics|bibliography|bibliographystyle|usepackage|documentclass|begin|end)\*?\s*(?:\[[^\]]*\]\s*)?\{[^}]*\}`)
+
+// termMatches returns the ranges of text where term occurs as whole words, outside command
+// names, identifier arguments (\label, \ref, \cite, paths) and comments
+func termMatches(text string, term *glossaryTerm) [][]int {
+	var matches [][]int
+	for _, m := range term.pattern.FindAllStringIndex(text, -1) {
+		if isWordRune(lastRune(text[:m[0]])) || isWordRune(firstRune(text[m[1]:])) {
+			continue
+		}
+		if m[0] > 0 && text[m[0]-1] == '\\' {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	skip := glossarySkipPattern.FindAllStringIndex(text, -1)
+	kept := matches[:0]
+	for _, m := range matches {
+		if inRanges(skip, m[0]) || inComment(text, m[0]) {
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// enforce replaces the terms the model left in English in a translated chunk by their
+// rendering and returns the corrections
+func (g *Glossary) enforce(translated string) (string, []types.GlossaryCorrection) {
+	var corrections []types.GlossaryCorrection
+	for _, term := range g.sortedTerms(translated) {
+		matches := termMatches(translated, term)
+		if len(matches) == 0 {
+			continue
+		}
+		var sb strings.Builder
+		last := 0
+		for _, m := range matches {
+			sb.WriteString(translated[last:m[0]])
+			sb.WriteString(term.target)
+			last = m[1]
+		}
+		sb.WriteString(translated[last:])
+		translated = sb.String()
+		corrections = append(corrections, types.GlossaryCorrection{
+			Term:   term.source,
+			Target: term.target,
+			Count:  len(matches),
+			Auto:   term.auto,
+		})
+	}
+	return translated, corrections
+}
+
+// glossaryHeadingPattern matches sectioning commands up to the brace of their title
+var glossaryHeadingPattern = regexp.MustCompile(`\\(?:part|chapter|section|subsection|subsubsection|paragraph)\*?\s*(?:\[[^\]]*\]\s*)?\{`)
+
+// glossaryMacroPattern matches \newcommand definitions without arguments up to the brace of the body
+var glossaryMacroPattern = regexp.MustCompile(`\\(?:re|provide)?newcommand\*?\s*(?:\{\s*\\([A-Za-z]+)\s*\}|\\([A-Za-z]+))\s*\{`)
+
+// learn adds the renderings the model chose for the bodies of \newcommand macros and for the
+// section titles of a translated chunk, so later chunks translate them the same way. Terms
+// already in the glossary are kept. It returns the number of terms added.
+func (g *Glossary) learn(original, translated string) int {
+	if g == nil {
+		return 0
+	}
+	added := 0
+	addPair := func(source, target string) {
+		if isLearnableTerm(source) && strings.TrimSpace(target) != "" && !strings.EqualFold(strings.TrimSpace(source), strings.TrimSpace(target)) &&
+			!strings.ContainsAny(target, "\\$") && g.add(source, target, true) {
+			added++
+		}
+	}
+
+	// Macros pair up by name
+	translatedMacros := macroBodies(translated)
+	for name, body := range macroBodies(original) {
+		addPair(body, translatedMacros[name])
+	}
+
+	// Section titles pair up by position when the chunk kept all of them
+	originalTitles := headingTitles(original)
+	translatedTitles := headingTitles(translated)
+	if len(originalTitles) == len(translatedTitles) {
+		for i := range originalTitles {
+			addPair(originalTitles[i], translatedTitles[i])
+		}
+	}
+
+	if added > 0 {
+		logger.Info("glossary terms learned from first chunk", logger.Int("terms", added), logger.Int("total", g.Len()))
+	}
+	return added
+}
+
+// isLearnableTerm reports whether a macro body or title is plain text worth enforcing
+func isLearnableTerm(s string) bool {
+	s = strings.TrimSpace(s)
+	if len(s) < minAutoTermLength || strings.ContainsAny(s, "\\$#{}%&~^_") {
+		return false
+	}
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			return false // already translated
+		}
+	}
+	return strings.IndexFunc(s, unicode.IsLetter) != -1
+}
+
+// macroBodies returns the bodies of the argument-free \newcommand definitions of content by
+// macro name, with a trailing \xspace removed
+func macroBodies(content string) map[string]string {
+	bodies := make(map[string]string)
+	for _, m := range glossaryMacroPattern.FindAllStringSubmatchIndex(content, -1) {
+		var name string
+		if m[2] != -1 {
+			name = content[m[2]:m[3]]
+		} else {
+			name = content[m[4]:m[5]]
+		}
+		open := m[1] - 1
+		end := findMatchingBrace(content, open)
+		if end == -1 {
+			continue
+		}
+		body := strings.TrimSuffix(strings.TrimSpace(content[open+1:end]), "\\xspace")
+		bodies[name] = strings.TrimSpace(body)
+	}
+	return bodies
+}
+
+// headingTitles returns the titles of the sectioning commands of content in order
+func headingTitles(content string) []string {
+	var titles []string
+	for _, m := range glossaryHeadingPattern.FindAllStringIndex(content, -1) {
+		open := m[1] - 1
+		end := findMatchingBrace(content, open)
+		if end == -1 {
+			continue
+		}
+		titles = append(titles, strings.TrimSpace(content[open+1:end]))
+	}
+	return titles
+}
+
+// isWordRune reports whether r continues a word
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// firstRune returns the first rune of s, or 0
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return 0
+	}
+	return r
+}
+
+// lastRune returns the last rune of s, or 0
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	if r == utf8.RuneError {
+		return 0
+	}
+	return r
+}
+
+// inRanges reports whether pos is inside one of the ranges
+func inRanges(ranges [][]int, pos int) bool {
+	for _, r := range ranges {
+		if pos >= r[0] && pos < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// inComment reports whether pos is after an unescaped % on its line
+func inComment(text string, pos int) bool {
+	lineStart := strings.LastIndexByte(text[:pos], '\n') + 1
+	line := text[lineStart:pos]
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '%' {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatGlossaryCorrections describes the force-corrected terms for the job report
+func FormatGlossaryCorrections(corrections []types.GlossaryCorrection) string {
+	parts := make([]string, 0, len(corrections))
+	for _, c := range corrections {
+		parts = append(parts, fmt.Sprintf("%s → %s (%d 处)", c.Term, c.Target, c.Count))
+	}
+	return "术语表强制修正: " + strings.Join(parts, "; ")
+}
+
+// MergeGlossaryCorrections adds the corrections of b to a, summing the counts of a term
+func MergeGlossaryCorrections(a, b []types.GlossaryCorrection) []types.GlossaryCorrection {
+	for _, c := range b {
+		merged := false
+		for i := range a {
+			if a[i].Term == c.Term && a[i].Target == c.Target {
+				a[i].Count += c.Count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			a = append(a, c)
+		}
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].Count != a[j].Count {
+			return a[i].Count > a[j].Count
+		}
+		return a[i].Term < a[j].Term
+	})
+	return a
+}
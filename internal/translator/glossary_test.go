@@ -0,0 +1,186 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestLoadGlossary(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"object.json": `{"attention head": "注意力头", "transformer": "Transformer"}`,
+		"list.json":   `[{"source": "attention head", "target": "注意力头"}, {"source": "transformer", "target": "Transformer"}]`,
+		"terms.csv":   "\ufeffsource,target\n# comment\nattention  head,注意力头\n\"transformer\",Transformer\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		g, err := LoadGlossary(path)
+		if err != nil {
+			t.Fatalf("LoadGlossary(%s) failed: %v", name, err)
+		}
+		if g.Len() != 2 {
+			t.Errorf("%s: %d terms, want 2", name, g.Len())
+		}
+		if got := g.promptSection("The attention head of the transformer."); !strings.Contains(got, "- attention head => 注意力头\n") {
+			t.Errorf("%s: prompt section misses the term:\n%s", name, got)
+		}
+	}
+
+	// The same terms hash the same, whatever the file format
+	a, _ := LoadGlossary(filepath.Join(dir, "object.json"))
+	b, _ := LoadGlossary(filepath.Join(dir, "terms.csv"))
+	if a.Hash() == "" || a.Hash() != b.Hash() {
+		t.Errorf("hashes differ: %q and %q", a.Hash(), b.Hash())
+	}
+
+	txt := filepath.Join(dir, "terms.txt")
+	os.WriteFile(txt, []byte("a,b"), 0644)
+	if _, err := LoadGlossary(txt); err == nil {
+		t.Error("LoadGlossary accepted a .txt file")
+	}
+}
+
+func TestGlossaryEnforce(t *testing.T) {
+	g := NewGlossary()
+	g.Add("attention head", "注意力头")
+	g.Add("attention", "注意力")
+	g.Add("Transformer", "Transformer")
+
+	translated := "每个 Attention\nhead 都很重要，见 \\ref{attention head}。attentional heads 与 attention 不同。\n% attention head 保留\n\\attention 宏，Transformer 模型。"
+	got, corrections := g.enforce(translated)
+
+	want := "每个 注意力头 都很重要，见 \\ref{attention head}。attentional heads 与 注意力 不同。\n% attention head 保留\n\\attention 宏，Transformer 模型。"
+	if got != want {
+		t.Errorf("enforce() =\n%s\nwant\n%s", got, want)
+	}
+	wantCorrections := []types.GlossaryCorrection{
+		{Term: "attention head", Target: "注意力头", Count: 1},
+		{Term: "attention", Target: "注意力", Count: 1},
+	}
+	if len(corrections) != len(wantCorrections) {
+		t.Fatalf("corrections = %+v, want %+v", corrections, wantCorrections)
+	}
+	for i := range wantCorrections {
+		if corrections[i] != wantCorrections[i] {
+			t.Errorf("correction %d = %+v, want %+v", i, corrections[i], wantCorrections[i])
+		}
+	}
+}
+
+func TestGlossaryLearn(t *testing.T) {
+	g := NewGlossary()
+	g.Add("Related Work", "相关研究")
+
+	original := "\\newcommand{\\method}{Sparse Mixer\\xspace}\n\\newcommand\\dataset{WikiText}\n\\newcommand{\\norm}[1]{\\lVert #1 \\rVert}\n" +
+		"\\section{Background and Motivation}\nText.\n\\subsection*{Related Work}\nMore.\n"
+	translated := "\\newcommand{\\method}{稀疏混合器\\xspace}\n\\newcommand\\dataset{WikiText}\n\\newcommand{\\norm}[1]{\\lVert #1 \\rVert}\n" +
+		"\\section{背景与动机}\n文本。\n\\subsection*{相关工作}\n更多。\n"
+
+	if added := g.learn(original, translated); added != 2 {
+		t.Errorf("learn() added %d terms, want 2", added)
+	}
+	prompt := g.promptSection("The Sparse Mixer beats prior Related Work; see Background and Motivation.")
+	for _, line := range []string{"- Sparse Mixer => 稀疏混合器", "- Background and Motivation => 背景与动机", "- Related Work => 相关研究"} {
+		if !strings.Contains(prompt, line) {
+			t.Errorf("prompt section misses %q:\n%s", line, prompt)
+		}
+	}
+	if g.Hash() != func() string { u := NewGlossary(); u.Add("Related Work", "相关研究"); return u.Hash() }() {
+		t.Error("learned terms changed the hash of the user's terms")
+	}
+}
+
+func TestTranslateWithGlossary(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+
+		// The model never translates the glossary terms
+		translated := strings.ReplaceAll(chunk, "Paragraph", "段落")
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	var sb strings.Builder
+	sb.WriteString("\\documentclass{article}\n\\newcommand{\\method}{Sparse Mixer}\n\\begin{document}\n")
+	sb.WriteString(strings.Repeat("Paragraph text that is long enough to fill the chunk. ", 60))
+	sb.WriteString("\n\n")
+	for i := 0; i < 4; i++ {
+		sb.WriteString("\\section{Part}\n")
+		sb.WriteString(strings.Repeat("Paragraph text about the Sparse Mixer and its attention head. ", 30))
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("\\end{document}\n")
+
+	glossary := NewGlossary()
+	glossary.Add("attention head", "注意力头")
+	glossary.Add("Sparse Mixer", "稀疏混合器")
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 3)
+	engine.SetGlossary(glossary)
+	result, err := engine.TranslateTeX(sb.String())
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+
+	// Macro definitions are kept verbatim
+	preamble, body, _ := strings.Cut(result.TranslatedContent, "\\begin{document}")
+	if !strings.Contains(preamble, "\\newcommand{\\method}{Sparse Mixer}") {
+		t.Errorf("macro definition changed:\n%s", preamble)
+	}
+	if strings.Contains(body, "attention head") || strings.Contains(body, "Sparse Mixer") {
+		t.Errorf("glossary terms left in English:\n%s", result.TranslatedContent)
+	}
+	if !strings.Contains(result.TranslatedContent, "稀疏混合器 和 its 注意力头") && !strings.Contains(result.TranslatedContent, "稀疏混合器 and its 注意力头") {
+		t.Errorf("glossary renderings missing:\n%s", result.TranslatedContent)
+	}
+
+	// Every chunk using the terms is told about both
+	if len(prompts) < 3 || strings.Contains(prompts[0], "GLOSSARY") {
+		t.Fatalf("%d prompts, first one with glossary: %v", len(prompts), len(prompts) > 0 && strings.Contains(prompts[0], "GLOSSARY"))
+	}
+	for _, prompt := range prompts[1:] {
+		if !strings.Contains(prompt, "- attention head => 注意力头") || !strings.Contains(prompt, "- Sparse Mixer => 稀疏混合器") {
+			t.Errorf("prompt misses glossary terms:\n%s", prompt[:min(len(prompt), 400)])
+		}
+	}
+
+	auto := map[string]bool{}
+	for _, c := range result.GlossaryCorrections {
+		auto[c.Term] = c.Auto
+	}
+	if len(auto) != 2 || auto["attention head"] || auto["Sparse Mixer"] {
+		t.Errorf("GlossaryCorrections = %+v", result.GlossaryCorrections)
+	}
+}
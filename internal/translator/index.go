@@ -0,0 +1,427 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"latex-translator/internal/logger"
+)
+
+const (
+	// IndexSortPinyin regenerates the sort keys of translated index entries from the
+	// pinyin of the translation, so the index is ordered and grouped like a Chinese index
+	IndexSortPinyin = "pinyin"
+	// IndexSortOriginal keeps the English terms as sort keys: the index keeps the order
+	// of the original book
+	IndexSortOriginal = "original"
+
+	// indexTermBatchSize is the number of index terms translated per request
+	indexTermBatchSize = 60
+)
+
+// indexCommandPattern matches the start of an \index command; imakeidx allows an optional
+// index name
+var indexCommandPattern = regexp.MustCompile(`\\index\s*(\[[^\]]*\])?\s*\{`)
+
+// indexSeePattern matches the cross references of an index entry (|see{...}, |seealso{...})
+var indexSeePattern = regexp.MustCompile(`^\|(see|seealso)\{(.*)\}$`)
+
+// indexTermLinePattern matches one numbered line of a term translation response
+var indexTermLinePattern = regexp.MustCompile(`^\s*(\d+)\s*[.、)．]\s*(.*)$`)
+
+// indexTermPrompt is the system prompt for translating index terms
+const indexTermPrompt = "You translate the index terms of an English academic book into Simplified Chinese. " +
+	"Each input line is a number, a period and one term. Answer with exactly one line per term, " +
+	"in the same order and with the same number, containing only the Chinese term as it would appear " +
+	"in the index of a Chinese book. Keep LaTeX commands, math ($...$) and braces unchanged. " +
+	"Keep names, acronyms and code identifiers that are not normally translated as they are."
+
+// pinyinInitials are the first characters of each pinyin initial in the CLDR pinyin
+// collation; a character belongs to the last initial that does not sort after it
+var pinyinInitials = []struct {
+	letter byte
+	first  string
+}{
+	{'a', "阿"}, {'b', "八"}, {'c', "嚓"}, {'d', "哒"}, {'e', "妸"}, {'f', "发"}, {'g', "旮"},
+	{'h', "哈"}, {'j', "讥"}, {'k', "咔"}, {'l', "垃"}, {'m', "痳"}, {'n', "拏"}, {'o', "噢"},
+	{'p', "妑"}, {'q', "七"}, {'r', "呥"}, {'s', "扨"}, {'t', "它"}, {'w', "穵"}, {'x', "夕"},
+	{'y', "丫"}, {'z', "帀"},
+}
+
+// indexLevel is one level of an index entry ("sort@text"); entries have subentries
+// separated by "!"
+type indexLevel struct {
+	key   string // explicit sort key, only if keyed
+	text  string // printed text
+	keyed bool
+}
+
+// indexEntry is the parsed argument of an \index command
+type indexEntry struct {
+	levels []indexLevel
+	encap  string // page number format or cross reference, including the leading "|"
+}
+
+// parseIndexEntry splits an \index argument in makeindex syntax into its levels and its
+// encapsulator. Characters quoted with '"' and everything inside braces are literal.
+func parseIndexEntry(arg string) indexEntry {
+	var entry indexEntry
+	var current indexLevel
+	var buf strings.Builder
+	depth := 0
+	for i := 0; i < len(arg); i++ {
+		c := arg[i]
+		switch {
+		case c == '"' && (i == 0 || arg[i-1] != '\\') && i+1 < len(arg):
+			buf.WriteByte(c)
+			buf.WriteByte(arg[i+1])
+			i++
+			continue
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case depth == 0 && c == '@' && !current.keyed:
+			current.key = buf.String()
+			current.keyed = true
+			buf.Reset()
+			continue
+		case depth == 0 && c == '!':
+			current.text = buf.String()
+			entry.levels = append(entry.levels, current)
+			current = indexLevel{}
+			buf.Reset()
+			continue
+		case depth == 0 && c == '|':
+			entry.encap = arg[i:]
+			i = len(arg)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	current.text = buf.String()
+	entry.levels = append(entry.levels, current)
+	return entry
+}
+
+// String formats the entry as an \index argument
+func (e indexEntry) String() string {
+	parts := make([]string, len(e.levels))
+	for i, level := range e.levels {
+		if level.keyed {
+			parts[i] = level.key + "@" + level.text
+		} else {
+			parts[i] = level.text
+		}
+	}
+	return strings.Join(parts, "!") + e.encap
+}
+
+// quoteIndexSpecials quotes the makeindex special characters in translated text that the
+// model added unquoted
+func quoteIndexSpecials(text string) string {
+	var sb strings.Builder
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"' && i+1 < len(text):
+			sb.WriteByte(c)
+			sb.WriteByte(text[i+1])
+			i++
+			continue
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case depth == 0 && (c == '@' || c == '!' || c == '|'):
+			sb.WriteByte('"')
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// indexCollator orders terms like a Chinese index: Latin letters first, then Chinese
+// characters by pinyin
+var indexCollator = collate.New(language.Chinese, collate.Loose)
+
+// pinyinInitial returns the lowercase initial letter a term is grouped under, or 0 for
+// terms starting with a digit or symbol
+func pinyinInitial(term string) byte {
+	r, _ := utf8.DecodeRuneInString(term)
+	switch {
+	case r >= 'a' && r <= 'z':
+		return byte(r)
+	case r >= 'A' && r <= 'Z':
+		return byte(r - 'A' + 'a')
+	case r < 0x3400:
+		return 0
+	}
+	letter := byte(0)
+	char := string(r)
+	for _, initial := range pinyinInitials {
+		if indexCollator.CompareString(initial.first, char) > 0 {
+			break
+		}
+		letter = initial.letter
+	}
+	return letter
+}
+
+// PinyinSortKey returns a makeindex sort key for a term: the pinyin initial, which makeindex
+// uses for the letter groups, followed by the collation key of the term written with the
+// letters a-p, so makeindex orders the terms by pinyin within each group
+func PinyinSortKey(term string) string {
+	plain := stripIndexMarkup(term)
+	var buf collate.Buffer
+	key := indexCollator.KeyFromString(&buf, plain)
+
+	var sb strings.Builder
+	if initial := pinyinInitial(plain); initial != 0 {
+		sb.WriteByte(initial)
+	}
+	for _, b := range key {
+		sb.WriteByte('a' + b>>4)
+		sb.WriteByte('a' + b&0x0f)
+	}
+	return sb.String()
+}
+
+// stripIndexMarkup removes LaTeX commands, braces and quote characters from a term so only
+// its text is used for sorting
+func stripIndexMarkup(term string) string {
+	var sb strings.Builder
+	for i := 0; i < len(term); i++ {
+		c := term[i]
+		switch {
+		case c == '\\':
+			j := i + 1
+			for j < len(term) && isLetterAt(term, j) {
+				j++
+			}
+			if j == i+1 && j < len(term) {
+				// Escaped character
+				sb.WriteByte(term[j])
+				j++
+			}
+			i = j - 1
+		case c == '"' && i+1 < len(term):
+			sb.WriteByte(term[i+1])
+			i++
+		case c == '{' || c == '}' || c == '$':
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// indexCommand is an \index command found in a document
+type indexCommand struct {
+	argStart, argEnd int // the argument without its braces
+	entry            indexEntry
+}
+
+// findIndexCommands returns the \index commands of content that are not commented out
+func findIndexCommands(content string) []indexCommand {
+	var commands []indexCommand
+	for _, loc := range indexCommandPattern.FindAllStringIndex(content, -1) {
+		lineStart := strings.LastIndexByte(content[:loc[0]], '\n') + 1
+		if isCommentedOut(content[lineStart:loc[0]]) {
+			continue
+		}
+		open := loc[1] - 1
+		end := findMatchingBrace(content, open)
+		if end == -1 {
+			continue
+		}
+		commands = append(commands, indexCommand{
+			argStart: open + 1,
+			argEnd:   end,
+			entry:    parseIndexEntry(content[open+1 : end]),
+		})
+	}
+	return commands
+}
+
+// isCommentedOut reports whether a line prefix contains an unescaped %
+func isCommentedOut(prefix string) bool {
+	for i := 0; i < len(prefix); i++ {
+		if prefix[i] == '\\' {
+			i++
+			continue
+		}
+		if prefix[i] == '%' {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIndexSortKeys sets how the sort keys of translated index entries are generated:
+// IndexSortPinyin (default) or IndexSortOriginal
+func (t *TranslationEngine) SetIndexSortKeys(mode string) {
+	t.indexSort = mode
+}
+
+// translateIndexEntries translates the text of the \index entries of content: entry
+// levels and see/seealso references are translated as a term list, sort keys of Chinese
+// translations are regenerated from the pinyin of the translation or kept as the English
+// terms (other languages sort by the translation), and
+// page formats and subentry structure are kept. It returns the content, the tokens used
+// and the number of entries changed. Terms that fail to translate stay in English.
+func (t *TranslationEngine) translateIndexEntries(content string) (string, int, int) {
+	commands := findIndexCommands(content)
+	if len(commands) == 0 {
+		return content, 0, 0
+	}
+
+	// Collect the distinct terms in document order
+	var terms []string
+	seen := make(map[string]bool)
+	addTerm := func(term string) {
+		term = strings.TrimSpace(term)
+		if term != "" && !seen[term] && containsTranslatableText(term) {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, cmd := range commands {
+		for _, level := range cmd.entry.levels {
+			addTerm(level.text)
+		}
+		if m := indexSeePattern.FindStringSubmatch(cmd.entry.encap); m != nil {
+			addTerm(m[2])
+		}
+	}
+
+	translations, tokens := t.translateIndexTerms(terms)
+	logger.Info("translated index terms",
+		logger.Int("entries", len(commands)),
+		logger.Int("terms", len(terms)),
+		logger.Int("translated", len(translations)))
+
+	pinyin := t.indexSort != IndexSortOriginal && t.language.IsChinese()
+	var sb strings.Builder
+	last, changed := 0, 0
+	for _, cmd := range commands {
+		entry := cmd.entry
+		for i, level := range entry.levels {
+			translated, ok := translations[strings.TrimSpace(level.text)]
+			if !ok {
+				continue
+			}
+			switch {
+			case pinyin:
+				level.key = PinyinSortKey(translated)
+				level.keyed = true
+			case !t.language.IsChinese():
+				// The translation sorts by itself unless the entry has its own key
+			case !level.keyed:
+				level.key = strings.TrimSpace(level.text)
+				level.keyed = true
+			}
+			level.text = translated
+			entry.levels[i] = level
+		}
+		if m := indexSeePattern.FindStringSubmatch(entry.encap); m != nil {
+			if translated, ok := translations[strings.TrimSpace(m[2])]; ok {
+				entry.encap = fmt.Sprintf("|%s{%s}", m[1], translated)
+			}
+		}
+
+		arg := entry.String()
+		if arg == content[cmd.argStart:cmd.argEnd] {
+			continue
+		}
+		sb.WriteString(content[last:cmd.argStart])
+		sb.WriteString(arg)
+		last = cmd.argEnd
+		changed++
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), tokens, changed
+}
+
+// translateIndexTerms translates index terms in batches of numbered lines. Terms already
+// translated by this engine reuse their translation. Terms whose translation is missing or
+// changes their LaTeX structure are left out of the result.
+func (t *TranslationEngine) translateIndexTerms(allTerms []string) (map[string]string, int) {
+	translations := make(map[string]string)
+	var terms []string
+	t.indexTermsMu.Lock()
+	for _, term := range allTerms {
+		if translated, ok := t.indexTerms[term]; ok {
+			translations[term] = translated
+		} else {
+			terms = append(terms, term)
+		}
+	}
+	t.indexTermsMu.Unlock()
+	defer func() {
+		t.indexTermsMu.Lock()
+		if t.indexTerms == nil {
+			t.indexTerms = make(map[string]string)
+		}
+		for term, translated := range translations {
+			t.indexTerms[term] = translated
+		}
+		t.indexTermsMu.Unlock()
+	}()
+
+	tokens := 0
+	for start := 0; start < len(terms); start += indexTermBatchSize {
+		end := start + indexTermBatchSize
+		if end > len(terms) {
+			end = len(terms)
+		}
+		batch := terms[start:end]
+
+		var input strings.Builder
+		for i, term := range batch {
+			fmt.Fprintf(&input, "%d. %s\n", i+1, term)
+		}
+		if err := t.breaker.wait(context.Background()); err != nil {
+			logger.Warn("index term translation skipped", logger.Err(err))
+			return translations, tokens
+		}
+		resp, err := t.chatCompletion([]Message{
+			{Role: "system", Content: localizePrompt(systemPromptForVariant(indexTermPrompt, t.outputVariant()), t.language)},
+			{Role: "user", Content: input.String()},
+		}, 64+len(input.String()))
+		if err != nil {
+			logger.Warn("failed to translate index terms, keeping originals",
+				logger.Int("batchStart", start),
+				logger.Int("batchSize", len(batch)),
+				logger.Err(err))
+			continue
+		}
+		tokens += resp.Usage.TotalTokens
+
+		for _, line := range strings.Split(resp.Choices[0].Message.Content, "\n") {
+			m := indexTermLinePattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n < 1 || n > len(batch) {
+				continue
+			}
+			term := batch[n-1]
+			translated := strings.TrimSpace(t.convertToVariant(m[2]))
+			if translated == "" || translated == term || !ValidateTitleStructure(term, translated) {
+				continue
+			}
+			translations[term] = quoteIndexSpecials(translated)
+		}
+	}
+	return translations, tokens
+}
@@ -0,0 +1,139 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIndexEntryRoundTrip(t *testing.T) {
+	tests := []struct {
+		arg    string
+		levels int
+		encap  string
+	}{
+		{"neural network", 1, ""},
+		{"network!neural", 2, ""},
+		{"alpha@$\\alpha$", 1, ""},
+		{"gradient descent|textbf", 1, "|textbf"},
+		{"loss|see{objective function}", 1, "|see{objective function}"},
+		{"optimizer|(", 1, "|("},
+		{"e-mail\"@address", 1, ""},
+		{"set {a!b}", 1, ""},
+	}
+	for _, tt := range tests {
+		entry := parseIndexEntry(tt.arg)
+		if len(entry.levels) != tt.levels || entry.encap != tt.encap {
+			t.Errorf("parseIndexEntry(%q) = %d levels, encap %q; want %d, %q", tt.arg, len(entry.levels), entry.encap, tt.levels, tt.encap)
+		}
+		if got := entry.String(); got != tt.arg {
+			t.Errorf("parseIndexEntry(%q).String() = %q", tt.arg, got)
+		}
+	}
+
+	entry := parseIndexEntry("alpha@$\\alpha$!beta")
+	if !entry.levels[0].keyed || entry.levels[0].key != "alpha" || entry.levels[0].text != "$\\alpha$" || entry.levels[1].text != "beta" {
+		t.Errorf("unexpected levels: %+v", entry.levels)
+	}
+}
+
+func TestPinyinSortKeyOrdersByPinyin(t *testing.T) {
+	for term, initial := range map[string]byte{
+		"神经网络": 's', "注意力": 'z', "梯度下降": 't', "卷积": 'j', "阿尔法": 'a', "Adam 优化器": 'a', "1 范数": 0,
+	} {
+		if got := pinyinInitial(term); got != initial {
+			t.Errorf("pinyinInitial(%q) = %q, want %q", term, got, initial)
+		}
+	}
+
+	terms := []string{"注意力", "神经网络", "损失函数", "梯度下降", "卷积", "\\emph{激活}函数"}
+	keys := make(map[string]string)
+	for _, term := range terms {
+		keys[term] = PinyinSortKey(term)
+		if strings.Trim(keys[term], "abcdefghijklmnopqrstuvwxyz") != "" {
+			t.Errorf("sort key of %q contains characters other than letters: %q", term, keys[term])
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool { return keys[terms[i]] < keys[terms[j]] })
+	want := []string{"\\emph{激活}函数", "卷积", "神经网络", "损失函数", "梯度下降", "注意力"}
+	if strings.Join(terms, ",") != strings.Join(want, ",") {
+		t.Errorf("terms sorted by key = %v, want %v", terms, want)
+	}
+}
+
+// newIndexTermServer returns a mock model that translates numbered term lists with the
+// given dictionary and counts the term requests
+func newIndexTermServer(t *testing.T, dictionary map[string]string, requests *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		*requests++
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(req.Messages[len(req.Messages)-1].Content), "\n") {
+			m := indexTermLinePattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			translated, ok := dictionary[m[2]]
+			if !ok {
+				translated = m[2]
+			}
+			lines = append(lines, m[1]+". "+translated)
+		}
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: strings.Join(lines, "\n")}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 7},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestTranslateIndexEntries(t *testing.T) {
+	requests := 0
+	server := newIndexTermServer(t, map[string]string{
+		"neural network":     "神经网络",
+		"network":            "网络",
+		"convolutional":      "卷积",
+		"objective function": "目标函数",
+		"loss":               "损失",
+	}, &requests)
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	content := "神经网络\\index{neural network}很重要。\n" +
+		"\\index{network!convolutional|textbf}\n" +
+		"\\index{loss|see{objective function}}\n" +
+		"\\index{alpha@$\\alpha$}\n" +
+		"% \\index{commented out}\n"
+
+	got, tokens, changed := engine.translateIndexEntries(content)
+	if changed != 3 || tokens != 7 || requests != 1 {
+		t.Errorf("changed = %d, tokens = %d, requests = %d; want 3, 7, 1", changed, tokens, requests)
+	}
+	for _, want := range []string{
+		"\\index{" + PinyinSortKey("神经网络") + "@神经网络}",
+		"\\index{" + PinyinSortKey("网络") + "@网络!" + PinyinSortKey("卷积") + "@卷积|textbf}",
+		"\\index{" + PinyinSortKey("损失") + "@损失|see{目标函数}}",
+		"\\index{alpha@$\\alpha$}",
+		"% \\index{commented out}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("result is missing %q:\n%s", want, got)
+		}
+	}
+
+	// Terms are translated once per engine, so the files of a book agree
+	engine.SetIndexSortKeys(IndexSortOriginal)
+	got, _, _ = engine.translateIndexEntries("\\index{neural network}")
+	if got != "\\index{neural network@神经网络}" || requests != 1 {
+		t.Errorf("original sort keys: got %q after %d requests", got, requests)
+	}
+}
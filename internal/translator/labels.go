@@ -0,0 +1,309 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// labelEnvironments are the environments whose counter a \label inside them refers to. A
+// label the model moves out of one of them (or into another) compiles, but every \ref to
+// it then prints the wrong number.
+var labelEnvironments = map[string]bool{
+	"figure": true, "figure*": true, "wrapfigure": true, "subfigure": true,
+	"table": true, "table*": true, "wraptable": true, "subtable": true,
+	"algorithm": true, "algorithm*": true,
+	"equation": true, "equation*": true, "align": true, "align*": true,
+	"gather": true, "gather*": true, "multline": true, "multline*": true,
+	"flalign": true, "flalign*": true, "alignat": true, "alignat*": true,
+	"eqnarray": true, "eqnarray*": true,
+	"theorem": true, "lemma": true, "proposition": true, "corollary": true,
+	"definition": true, "remark": true, "example": true,
+}
+
+// labelTokenPattern matches what places a label: environment boundaries, sectioning
+// commands, captions, row breaks of multi-line math and the labels themselves
+var labelTokenPattern = regexp.MustCompile(`\\(begin|end)\s*\{([^{}]+)\}|\\(part|chapter|section|subsection|subsubsection|paragraph)\*?\s*(?:\[[^\]]*\])?\s*\{|\\caption\s*(?:\[[^\]]*\])?\s*\{|\\label\s*\{([^{}]*)\}|\\\\`)
+
+// labelEnvOptionsPattern matches the placement options after \begin{figure}
+var labelEnvOptionsPattern = regexp.MustCompile(`^\s*\[[^\]]*\]`)
+
+// labelEnv is one instance of a label environment
+type labelEnv struct {
+	name        string
+	ordinal     int   // index among the environments of the same name
+	bodyStart   int   // after \begin{name} and its options
+	endStart    int   // start of \end{name}
+	captionEnds []int // end of each \caption that belongs to the environment
+	rowBreaks   []int // start of each \\ directly in the environment
+}
+
+// labelSite is where a \label is in a document
+type labelSite struct {
+	key        string
+	start, end int
+	env        string // innermost label environment, "" for a label at section level
+	ordinal    int    // index of the environment among those of its name, or of the sectioning command (-1 before the first)
+	captions   int    // captions of the environment before the label
+	rows       int    // row breaks of the environment before the label
+	atHeading  bool   // a section-level label right after its sectioning command
+}
+
+// labelLayout is the placement of the labels of a document
+type labelLayout struct {
+	labels   []labelSite
+	envs     map[string][]*labelEnv
+	headings []int // end of each sectioning command
+}
+
+// scanLabelLayout records the enclosure of every label of a document, ignoring comments
+func scanLabelLayout(content string) *labelLayout {
+	layout := &labelLayout{envs: make(map[string][]*labelEnv)}
+	type open struct {
+		name string
+		env  *labelEnv // nil for environments that do not take labels
+	}
+	var stack []open
+	innermost := func() *labelEnv {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].env != nil {
+				return stack[i].env
+			}
+		}
+		return nil
+	}
+
+	for _, m := range labelTokenPattern.FindAllStringSubmatchIndex(content, -1) {
+		start, end := m[0], m[1]
+		lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+		if isCommentedOut(content[lineStart:start]) {
+			continue
+		}
+		token := content[start:end]
+		switch {
+		case m[2] >= 0: // \begin or \end
+			name := strings.TrimSpace(content[m[4]:m[5]])
+			if content[m[2]:m[3]] == "begin" {
+				var env *labelEnv
+				if labelEnvironments[name] {
+					env = &labelEnv{name: name, ordinal: len(layout.envs[name]), bodyStart: end}
+					if opts := labelEnvOptionsPattern.FindString(content[end:]); opts != "" && !strings.Contains(opts, "\n\n") {
+						env.bodyStart = end + len(opts)
+					}
+					layout.envs[name] = append(layout.envs[name], env)
+				}
+				stack = append(stack, open{name: name, env: env})
+				continue
+			}
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					if stack[i].env != nil {
+						stack[i].env.endStart = start
+					}
+					stack = stack[:i]
+					break
+				}
+			}
+		case m[6] >= 0: // sectioning command
+			if close := findMatchingBrace(content, end-1); close >= 0 {
+				end = close + 1
+			}
+			layout.headings = append(layout.headings, end)
+		case strings.HasPrefix(token, "\\caption"):
+			if close := findMatchingBrace(content, end-1); close >= 0 {
+				end = close + 1
+			}
+			if env := innermost(); env != nil {
+				env.captionEnds = append(env.captionEnds, end)
+			}
+		case m[8] >= 0: // \label
+			site := labelSite{key: strings.TrimSpace(content[m[8]:m[9]]), start: start, end: end, ordinal: len(layout.headings) - 1}
+			if env := innermost(); env != nil {
+				site.env = env.name
+				site.ordinal = env.ordinal
+				site.captions = len(env.captionEnds)
+				site.rows = len(env.rowBreaks)
+			} else if len(layout.headings) > 0 {
+				site.atHeading = strings.TrimSpace(content[layout.headings[len(layout.headings)-1]:start]) == ""
+			}
+			layout.labels = append(layout.labels, site)
+		default: // \\
+			if len(stack) > 0 && stack[len(stack)-1].env != nil {
+				env := stack[len(stack)-1].env
+				env.rowBreaks = append(env.rowBreaks, start)
+			}
+		}
+	}
+	return layout
+}
+
+// uniqueLabels returns the labels that occur once, by key
+func (l *labelLayout) uniqueLabels() map[string]labelSite {
+	count := make(map[string]int)
+	for _, site := range l.labels {
+		count[site.key]++
+	}
+	sites := make(map[string]labelSite)
+	for _, site := range l.labels {
+		if count[site.key] == 1 {
+			sites[site.key] = site
+		}
+	}
+	return sites
+}
+
+// describe names the enclosure of a label for repair reports, e.g. "figure 2" or "section 3"
+func (s labelSite) describe() string {
+	if s.env != "" {
+		return fmt.Sprintf("%s %d", s.env, s.ordinal+1)
+	}
+	if s.ordinal < 0 {
+		return "document start"
+	}
+	return fmt.Sprintf("section %d", s.ordinal+1)
+}
+
+// LabelRepair records a \label moved back into the enclosure it had in the original
+type LabelRepair struct {
+	Label string // label key
+	From  string // enclosure in the translation, e.g. "section 2"
+	To    string // enclosure in the original, e.g. "figure 3"
+}
+
+// labelEdit is one change of RepairLabelPlacement: removing [start, end) or inserting text
+// at start
+type labelEdit struct {
+	start, end int
+	text       string
+}
+
+// RepairLabelPlacement moves labels the model placed in a different enclosure than in the
+// original (out of their figure, table, equation or theorem, into another one, or away
+// from their sectioning command) back to the corresponding enclosure of the translation,
+// matched by order. A label goes after the same caption, in the same row of multi-line
+// math, or right after its sectioning command, as in the original. Enclosures whose
+// number differs between the two documents cannot be matched by order and are left alone.
+func RepairLabelPlacement(translated, original string) (string, []LabelRepair) {
+	if !strings.Contains(translated, "\\label") {
+		return translated, nil
+	}
+	orig := scanLabelLayout(original)
+	trans := scanLabelLayout(translated)
+	translatedSites := trans.uniqueLabels()
+	originalSites := orig.uniqueLabels()
+
+	var edits []labelEdit
+	var repairs []LabelRepair
+	for _, o := range orig.labels {
+		if _, unique := originalSites[o.key]; !unique {
+			continue
+		}
+		t, ok := translatedSites[o.key]
+		if !ok || !labelMisplaced(o, t, orig, trans) {
+			continue
+		}
+
+		var insertAt int
+		var text string
+		label := translated[t.start:t.end]
+		switch {
+		case o.env != "":
+			if len(orig.envs[o.env]) != len(trans.envs[o.env]) {
+				continue
+			}
+			env := trans.envs[o.env][o.ordinal]
+			if env.endStart == 0 {
+				continue
+			}
+			insertAt, text = labelInsertion(translated, env, o, label)
+		case o.atHeading:
+			if len(orig.headings) != len(trans.headings) {
+				continue
+			}
+			insertAt, text = trans.headings[o.ordinal], label
+		default:
+			continue
+		}
+
+		removeStart, removeEnd := t.start, t.end
+		lineStart := strings.LastIndexByte(translated[:t.start], '\n') + 1
+		lineEnd := len(translated)
+		if i := strings.IndexByte(translated[t.end:], '\n'); i >= 0 {
+			lineEnd = t.end + i + 1
+		}
+		if strings.TrimSpace(translated[lineStart:t.start]) == "" && strings.TrimSpace(translated[t.end:lineEnd]) == "" {
+			removeStart, removeEnd = lineStart, lineEnd
+		} else if removeStart > lineStart && translated[removeStart-1] == ' ' {
+			removeStart--
+		}
+		edits = append(edits, labelEdit{start: removeStart, end: removeEnd}, labelEdit{start: insertAt, end: insertAt, text: text})
+		repairs = append(repairs, LabelRepair{Label: o.key, From: t.describe(), To: o.describe()})
+	}
+	if len(edits) == 0 {
+		return translated, nil
+	}
+
+	// Apply from the end so positions stay valid; at the same position a removal goes
+	// before an insertion, so the inserted label is not removed with it
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start > edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	result := translated
+	for _, e := range edits {
+		result = result[:e.start] + e.text + result[e.end:]
+	}
+	return result, repairs
+}
+
+// labelMisplaced reports whether a label of the translation is not where its number comes
+// from in the original: in another enclosure, or after another caption or row of the same
+// environment (when both environments have as many captions and rows)
+func labelMisplaced(o, t labelSite, orig, trans *labelLayout) bool {
+	if t.env != o.env || t.ordinal != o.ordinal {
+		return true
+	}
+	if o.env == "" || len(orig.envs[o.env]) != len(trans.envs[o.env]) {
+		return false
+	}
+	oe, te := orig.envs[o.env][o.ordinal], trans.envs[o.env][o.ordinal]
+	if t.captions != o.captions && len(oe.captionEnds) == len(te.captionEnds) {
+		return true
+	}
+	return t.rows != o.rows && len(oe.rowBreaks) == len(te.rowBreaks)
+}
+
+// labelInsertion returns where to put a label in an environment of the translation and
+// the text to insert, following the position of the label in the original environment
+func labelInsertion(content string, env *labelEnv, o labelSite, label string) (int, string) {
+	switch {
+	case o.captions > 0 && len(env.captionEnds) >= o.captions:
+		return env.captionEnds[o.captions-1], label
+	case o.rows < len(env.rowBreaks):
+		return env.rowBreaks[o.rows], label
+	case o.rows > 0 || !isFloatEnvironment(o.env):
+		// Last row of multi-line math, or the end of single-line math and theorems: append
+		// to the last line of the body
+		pos := env.endStart
+		if pos > env.bodyStart && content[pos-1] == '\n' {
+			if strings.TrimSpace(content[env.bodyStart:pos]) == "" {
+				return pos, label + "\n"
+			}
+			return pos - 1, " " + label
+		}
+		return pos, label
+	default:
+		// The label came before the first caption
+		return env.bodyStart, "\n" + label
+	}
+}
+
+// isFloatEnvironment reports whether an environment is a float that gets its number from
+// \caption
+func isFloatEnvironment(name string) bool {
+	name = strings.TrimSuffix(name, "*")
+	return strings.HasSuffix(name, "figure") || strings.HasSuffix(name, "table") || name == "algorithm"
+}
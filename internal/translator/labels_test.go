@@ -0,0 +1,132 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+const labelOriginal = `\documentclass{article}
+\begin{document}
+\section{Introduction}
+\label{sec:intro}
+We refer to Figure~\ref{fig:arch}, Table~\ref{tab:res}, Eq.~\ref{eq:loss} and Eq.~\ref{eq:b}.
+\begin{figure}[t]
+\centering
+\includegraphics{arch.pdf}
+\caption{Architecture.}
+\label{fig:arch}
+\end{figure}
+The model is trained with
+\begin{equation}
+L = -\log p
+\label{eq:loss}
+\end{equation}
+\begin{align}
+a &= b \label{eq:a} \\
+c &= d \label{eq:b}
+\end{align}
+\section{Results}
+\begin{table}
+\caption{Results.}\label{tab:res}
+\begin{tabular}{cc}
+A & B \\
+\end{tabular}
+\end{table}
+\end{document}
+`
+
+// labelDisplaced is a translation of labelOriginal whose labels were moved across
+// environment boundaries
+const labelDisplaced = `\documentclass{article}
+\begin{document}
+\section{引言}
+我们参考图~\ref{fig:arch}、表~\ref{tab:res}、式~\ref{eq:loss}和式~\ref{eq:b}。
+\begin{figure}[t]
+\centering
+\includegraphics{arch.pdf}
+\caption{架构。}
+\end{figure}
+\label{fig:arch}
+模型的训练目标为
+\label{eq:loss}
+\begin{equation}
+L = -\log p
+\end{equation}
+\begin{align}
+a &= b \label{eq:a} \label{eq:b} \\
+c &= d
+\end{align}
+\section{结果}
+\label{sec:intro}
+\begin{table}
+\caption{结果。}
+\begin{tabular}{cc}
+A & B \\ \label{tab:res}
+\end{tabular}
+\end{table}
+\end{document}
+`
+
+// assertSameLabelPlacement checks that every label of original has the same enclosure,
+// caption and row position in translated, so every \ref prints the same number
+func assertSameLabelPlacement(t *testing.T, translated, original string) {
+	t.Helper()
+	orig := scanLabelLayout(original).uniqueLabels()
+	trans := scanLabelLayout(translated).uniqueLabels()
+	for key, o := range orig {
+		got, ok := trans[key]
+		if !ok {
+			t.Errorf("label %s missing", key)
+			continue
+		}
+		if got.env != o.env || got.ordinal != o.ordinal || got.captions != o.captions || got.rows != o.rows {
+			t.Errorf("label %s: in %s (captions %d, rows %d), want %s (captions %d, rows %d)",
+				key, got.describe(), got.captions, got.rows, o.describe(), o.captions, o.rows)
+		}
+	}
+}
+
+func TestRepairLabelPlacement(t *testing.T) {
+	repaired, repairs := RepairLabelPlacement(labelDisplaced, labelOriginal)
+	if len(repairs) != 4 {
+		t.Errorf("got %d repairs, want 4 (fig:arch, eq:loss, eq:b, sec:intro): %+v", len(repairs), repairs)
+	}
+	assertSameLabelPlacement(t, repaired, labelOriginal)
+
+	for _, want := range []string{
+		"\\caption{架构。}\\label{fig:arch}\n\\end{figure}\n模型的训练目标为\n\\begin{equation}",
+		"L = -\\log p \\label{eq:loss}\n\\end{equation}",
+		"a &= b \\label{eq:a} \\\\\nc &= d \\label{eq:b}\n\\end{align}",
+		"\\section{引言}\\label{sec:intro}\n",
+		"\\section{结果}\n\\begin{table}",
+	} {
+		if !strings.Contains(repaired, want) {
+			t.Errorf("repaired document is missing %q:\n%s", want, repaired)
+		}
+	}
+	// tab:res stays in its table: moving it within the enclosure does not change its number
+	if !strings.Contains(repaired, "A & B \\\\ \\label{tab:res}") {
+		t.Errorf("label inside its table was moved:\n%s", repaired)
+	}
+
+	again, repairs := RepairLabelPlacement(repaired, labelOriginal)
+	if len(repairs) != 0 || again != repaired {
+		t.Errorf("repairing again changed %d labels", len(repairs))
+	}
+}
+
+func TestRepairLabelPlacementSkipsUnmatchedEnvironments(t *testing.T) {
+	original := "\\begin{figure}\\caption{A}\\label{fig:a}\\end{figure}\n\\begin{figure}\\caption{B}\\end{figure}\n"
+	// The model dropped a figure, so figures cannot be matched by order
+	translated := "\\begin{figure}\\caption{甲}\\end{figure}\n\\label{fig:a}\n"
+	if got, repairs := RepairLabelPlacement(translated, original); got != translated || len(repairs) != 0 {
+		t.Errorf("repaired unmatched figures: %+v\n%s", repairs, got)
+	}
+
+	// Commented labels are ignored
+	original = "\\begin{figure}\\caption{A}\\label{fig:a}\\end{figure}\n"
+	translated = "\\begin{figure}\\caption{甲}\\label{fig:a}\\end{figure}\n% \\label{fig:a}\n"
+	if got, repairs := RepairLabelPlacement(translated, original); got != translated || len(repairs) != 0 {
+		t.Errorf("commented label was moved: %+v", repairs)
+	}
+}
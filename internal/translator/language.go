@@ -0,0 +1,123 @@
+package translator
+
+import (
+	"strings"
+	"unicode"
+
+	"latex-translator/internal/types"
+)
+
+// languageProfile is what a target language other than Chinese changes in the prompts
+// and in the validation of the translation
+type languageProfile struct {
+	punctuation string   // punctuation guideline replacing the Chinese one
+	example     []string // translations of the two prose lines of the system prompt example
+	equation    string   // translation of the placeholder example of the user prompt
+	// script holds the characters only the target language's text contains; nil for
+	// Latin-script languages, whose translation is checked for differing from the source
+	script []*unicode.RangeTable
+}
+
+// languageProfiles are the profiles of the supported languages other than Chinese
+var languageProfiles = map[types.TargetLanguage]languageProfile{
+	types.LanguageJapanese: {
+		punctuation: "Use proper Japanese punctuation: 。、「」『』（）",
+		example:     []string{"素早い茶色の狐が", "怠惰な犬を飛び越える。"},
+		equation:    "式 <<<LATEX_CMD_0>>> は…を示している",
+		script:      []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana},
+	},
+	types.LanguageKorean: {
+		punctuation: "Use standard Korean punctuation and spacing",
+		example:     []string{"재빠른 갈색 여우가", "게으른 개를 뛰어넘는다."},
+		equation:    "방정식 <<<LATEX_CMD_0>>>은 ...을 보여준다",
+		script:      []*unicode.RangeTable{unicode.Hangul},
+	},
+	types.LanguageRussian: {
+		punctuation: "Use Russian punctuation and quotation marks «»",
+		example:     []string{"Быстрая бурая лиса", "перепрыгивает через ленивую собаку."},
+		equation:    "Уравнение <<<LATEX_CMD_0>>> показывает, что...",
+		script:      []*unicode.RangeTable{unicode.Cyrillic},
+	},
+	types.LanguageEnglish: {
+		punctuation: "Use standard English punctuation",
+		example:     []string{"The quick brown fox", "jumps over the lazy dog."},
+		equation:    "The equation <<<LATEX_CMD_0>>> shows that...",
+	},
+	types.LanguageFrench: {
+		punctuation: "Use French punctuation and quotation marks « »",
+		example:     []string{"Le rapide renard brun", "saute par-dessus le chien paresseux."},
+		equation:    "L'équation <<<LATEX_CMD_0>>> montre que...",
+	},
+	types.LanguageGerman: {
+		punctuation: "Use German punctuation and quotation marks „“",
+		example:     []string{"Der schnelle braune Fuchs", "springt über den faulen Hund."},
+		equation:    "Die Gleichung <<<LATEX_CMD_0>>> zeigt, dass...",
+	},
+	types.LanguageSpanish: {
+		punctuation: "Use Spanish punctuation, including ¿ and ¡",
+		example:     []string{"El rápido zorro marrón", "salta sobre el perro perezoso."},
+		equation:    "La ecuación <<<LATEX_CMD_0>>> muestra que...",
+	},
+}
+
+// SetTargetLanguage sets the language of the translation (Chinese unless set). The
+// Chinese variant only applies to Chinese translations.
+func (t *TranslationEngine) SetTargetLanguage(lang types.TargetLanguage) {
+	t.language = lang
+}
+
+// TargetLanguage returns the language of the translation
+func (t *TranslationEngine) TargetLanguage() types.TargetLanguage {
+	if t.language == "" {
+		return types.LanguageChinese
+	}
+	return t.language
+}
+
+// outputVariant returns the Chinese variant of the output, or "" when the translation is
+// not Chinese
+func (t *TranslationEngine) outputVariant() types.ChineseVariant {
+	if !t.language.IsChinese() {
+		return ""
+	}
+	return t.variant
+}
+
+// localizePrompt rewrites a prompt written for Chinese output for the target language:
+// the language name, the punctuation guideline and the examples. Chinese prompts are
+// returned unchanged.
+func localizePrompt(prompt string, lang types.TargetLanguage) string {
+	profile, ok := languageProfiles[lang]
+	if !ok {
+		return prompt
+	}
+	name := lang.EnglishName()
+	return strings.NewReplacer(
+		`Use proper Chinese punctuation: 。，、；：""''（）`, profile.punctuation,
+		"Maintain proper Chinese punctuation (use Chinese quotation marks, periods, etc.).", profile.punctuation+".",
+		"敏捷的棕色狐狸", profile.example[0],
+		"跳过了懒狗。", profile.example[1],
+		"方程 <<<LATEX_CMD_0>>> 表明...", profile.equation,
+		"Simplified Chinese", name,
+		"Chinese", name,
+	).Replace(prompt)
+}
+
+// countTargetScriptCharacters counts the characters of s in the script of the target
+// language. ok is false for languages without a script of their own (Latin-script
+// languages), whose translation is validated against the source instead.
+func countTargetScriptCharacters(s string, lang types.TargetLanguage) (count int, ok bool) {
+	if lang.IsChinese() {
+		return countChineseCharacters(s), true
+	}
+	profile, known := languageProfiles[lang]
+	if !known || profile.script == nil {
+		return 0, false
+	}
+	for _, r := range s {
+		if unicode.IsOneOf(profile.script, r) {
+			count++
+		}
+	}
+	return count, true
+}
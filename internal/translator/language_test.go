@@ -0,0 +1,75 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+func TestLocalizePrompt(t *testing.T) {
+	engine := NewTranslationEngine("test-key")
+	chinese := systemPromptForVariant(buildSystemPromptWithProtection(), engine.outputVariant())
+	if got := localizePrompt(chinese, types.LanguageChinese); got != chinese {
+		t.Error("Chinese prompt changed")
+	}
+
+	japanese := localizePrompt(chinese, types.LanguageJapanese)
+	if !strings.Contains(japanese, "Japanese") || !strings.Contains(japanese, "素早い茶色の狐が") {
+		t.Errorf("prompt not localized to Japanese:\n%s", japanese)
+	}
+	if strings.Contains(japanese, "Chinese") {
+		t.Errorf("Japanese prompt still mentions Chinese:\n%s", japanese)
+	}
+}
+
+func TestOutputVariantOnlyForChinese(t *testing.T) {
+	engine := NewTranslationEngine("test-key")
+	engine.SetChineseVariant(types.ChineseTraditional, nil)
+	if got := engine.outputVariant(); got != types.ChineseTraditional {
+		t.Errorf("outputVariant() = %q for Chinese", got)
+	}
+	engine.SetTargetLanguage(types.LanguageKorean)
+	if got := engine.outputVariant(); got != "" {
+		t.Errorf("outputVariant() = %q for Korean", got)
+	}
+}
+
+func TestValidateTranslationChecksTargetScript(t *testing.T) {
+	original := "\\section{Intro}\n" + strings.Repeat("The results show a clear improvement over the baseline.\n", 40)
+	japanese := "\\section{はじめに}\n" + strings.Repeat("結果はベースラインに対して明確な改善を示している。\n", 40)
+
+	v := NewTranslationValidator()
+	v.Language = types.LanguageJapanese
+	if result := v.ValidateTranslation(original, japanese); !result.IsValid {
+		t.Errorf("Japanese translation rejected:\n%s", FormatValidationErrors(result))
+	}
+	result := v.ValidateTranslation(original, original)
+	if result.IsValid {
+		t.Fatal("untranslated text accepted as Japanese")
+	}
+	if err := types.NewAppError(types.ErrTranslation, strings.Join(result.Errors, "; "), nil); !IsUntranslatedResult(err) {
+		t.Errorf("IsUntranslatedResult() = false for %v", err)
+	}
+}
+
+func TestValidateTranslationChecksLatinTargetDiffersFromSource(t *testing.T) {
+	var original, german strings.Builder
+	for i := 0; i < 20; i++ {
+		original.WriteString("The results show a clear improvement over the baseline.\n")
+		german.WriteString("Die Ergebnisse zeigen eine deutliche Verbesserung gegenüber der Basislinie.\n")
+	}
+
+	v := NewTranslationValidator()
+	v.Language = types.LanguageGerman
+	if result := v.ValidateTranslation(original.String(), german.String()); !result.IsValid {
+		t.Errorf("German translation rejected:\n%s", FormatValidationErrors(result))
+	}
+	result := v.ValidateTranslation(original.String(), original.String())
+	if result.IsValid {
+		t.Fatal("untranslated text accepted as German")
+	}
+	if !strings.Contains(strings.Join(result.Errors, "\n"), untranslatedResultMarker) {
+		t.Errorf("unexpected errors: %v", result.Errors)
+	}
+}
@@ -0,0 +1,167 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// maxTimeoutRetries is how often a chunk request that timed out is retried on a fresh
+// connection before the chunk is failed
+const maxTimeoutRetries = 2
+
+// errRequestTimeout is the cancellation cause of a request that exceeded the request timeout
+var errRequestTimeout = errors.New("request timed out")
+
+// ErrBudgetExceeded is the cause of the chunks failed because the translation budget of
+// the document ran out
+var ErrBudgetExceeded = errors.New("translation budget exceeded")
+
+// TranslationBudget limits the translation of one document; a zero field is no limit
+type TranslationBudget struct {
+	MaxDuration time.Duration // wall-clock time since the budget started
+	MaxTokens   int           // input and output tokens of the engine's requests
+}
+
+// IsSet reports whether the budget has a limit
+func (b TranslationBudget) IsSet() bool {
+	return b.MaxDuration > 0 || b.MaxTokens > 0
+}
+
+// IsBudgetExceeded reports whether err is the error of a translation stopped by its budget
+func IsBudgetExceeded(err error) bool {
+	var appErr *types.AppError
+	if errors.As(err, &appErr) && appErr.Code == types.ErrBudgetExceeded {
+		return true
+	}
+	return errors.Is(err, ErrBudgetExceeded)
+}
+
+// SetRequestTimeout sets how long one API request may take before it is cancelled and
+// retried
+func (t *TranslationEngine) SetRequestTimeout(d time.Duration) {
+	if d > 0 {
+		t.requestTimeout = d
+	}
+}
+
+// withRequestTimeout returns the context of one API request, cancelled with
+// errRequestTimeout after the request timeout
+func (t *TranslationEngine) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := t.requestTimeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeoutCause(ctx, timeout, errRequestTimeout)
+}
+
+// requestTimeoutError is the error of a request cancelled by the request timeout; like a
+// failure to reach the server it is retryable
+func (t *TranslationEngine) requestTimeoutError(err error) error {
+	timeout := t.requestTimeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	logger.Warn("API request timed out", logger.String("timeout", timeout.String()), logger.Err(err))
+	return types.NewAppErrorWithDetails(
+		types.ErrNetwork,
+		"翻译请求超时",
+		fmt.Sprintf("no complete response within %s", timeout),
+		errRequestTimeout,
+	)
+}
+
+// handleRequestTimeout rebuilds the transport after a chunk request timed out so the retry
+// does not reuse the connection. Returns the error failing the chunk once it timed out
+// more than maxTimeoutRetries times.
+func (t *TranslationEngine) handleRequestTimeout(timeouts int, err error) error {
+	if timeouts > maxTimeoutRetries {
+		logger.Error("chunk request timed out repeatedly, failing the chunk", err, logger.Int("timeouts", timeouts))
+		return types.NewAppErrorWithDetails(
+			types.ErrAPICall,
+			"翻译请求多次超时，该分块翻译失败",
+			fmt.Sprintf("timed out in %d attempts", timeouts),
+			err,
+		)
+	}
+	logger.Warn("chunk request timed out, retrying on a fresh connection", logger.Int("timeouts", timeouts))
+	t.rebuildTransport()
+	return nil
+}
+
+// StartBudget starts the budget of the document about to be translated: once its time or
+// tokens ran out, the chunk requests in flight are cancelled and the remaining chunks
+// fail with ErrBudgetExceeded. A zero budget removes the limits.
+func (t *TranslationEngine) StartBudget(budget TranslationBudget) {
+	t.budgetMu.Lock()
+	defer t.budgetMu.Unlock()
+	t.budget = budget
+	t.budgetStart = time.Now()
+	t.budgetTokens = 0
+}
+
+// budgetExceeded returns the error of a spent budget, nil while some is left
+func (t *TranslationEngine) budgetExceeded() error {
+	t.budgetMu.Lock()
+	defer t.budgetMu.Unlock()
+	if t.budget.MaxDuration > 0 && time.Since(t.budgetStart) >= t.budget.MaxDuration {
+		return budgetError(fmt.Sprintf("time limit of %s reached", t.budget.MaxDuration))
+	}
+	if t.budget.MaxTokens > 0 && t.budgetTokens >= t.budget.MaxTokens {
+		return budgetError(fmt.Sprintf("token limit of %d reached (%d used)", t.budget.MaxTokens, t.budgetTokens))
+	}
+	return nil
+}
+
+// budgetContext returns the context of a chunk, cancelled with the budget error when the
+// time of the budget runs out. Fails right away when the budget is already spent.
+func (t *TranslationEngine) budgetContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := t.budgetExceeded(); err != nil {
+		return ctx, func() {}, err
+	}
+	t.budgetMu.Lock()
+	budget, start := t.budget, t.budgetStart
+	t.budgetMu.Unlock()
+	if budget.MaxDuration <= 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithDeadlineCause(ctx, start.Add(budget.MaxDuration),
+		budgetError(fmt.Sprintf("time limit of %s reached", budget.MaxDuration)))
+	return ctx, cancel, nil
+}
+
+// budgetError is the error of the chunks failed because the budget ran out
+func budgetError(details string) error {
+	return types.NewAppErrorWithDetails(types.ErrBudgetExceeded, "已达到翻译预算", details, ErrBudgetExceeded)
+}
+
+// BudgetStatus describes what is left of the budget of the current document, e.g.
+// "预算剩余 12 分钟、35.2k tokens"; empty when no budget is set
+func (t *TranslationEngine) BudgetStatus() string {
+	t.budgetMu.Lock()
+	defer t.budgetMu.Unlock()
+	if !t.budget.IsSet() {
+		return ""
+	}
+	var parts []string
+	if t.budget.MaxDuration > 0 {
+		left := t.budget.MaxDuration - time.Since(t.budgetStart)
+		switch {
+		case left <= 0:
+			parts = append(parts, "0 秒")
+		case left < time.Minute:
+			parts = append(parts, fmt.Sprintf("%d 秒", int(left.Seconds())))
+		default:
+			parts = append(parts, fmt.Sprintf("%d 分钟", int(left.Minutes())))
+		}
+	}
+	if t.budget.MaxTokens > 0 {
+		parts = append(parts, formatTokenCount(max(t.budget.MaxTokens-t.budgetTokens, 0))+" tokens")
+	}
+	return "预算剩余 " + strings.Join(parts, "、")
+}
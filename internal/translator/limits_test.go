@@ -0,0 +1,108 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTranslateChunkRetriesTimedOutRequest(t *testing.T) {
+	server, requests := hangingServer(t, 1)
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 50*time.Millisecond, 1)
+	engine.SetStallWindow(time.Minute)
+
+	translated, tokens, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if err != nil {
+		t.Fatalf("translateChunkWithRetry() error: %v", err)
+	}
+	if translated != "你好世界" || tokens != 10 {
+		t.Errorf("translateChunkWithRetry() = %q, %d", translated, tokens)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestTranslateChunkFailsAfterRepeatedTimeouts(t *testing.T) {
+	server, requests := hangingServer(t, 1000)
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 30*time.Millisecond, 1)
+	engine.SetStallWindow(time.Minute)
+
+	_, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if err == nil {
+		t.Fatal("expected an error when every request times out")
+	}
+	if IsBudgetExceeded(err) {
+		t.Errorf("timeouts reported as budget exceeded: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != maxTimeoutRetries+1 {
+		t.Errorf("requests = %d, want %d", got, maxTimeoutRetries+1)
+	}
+}
+
+func TestTokenBudgetStopsTranslation(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"你好世界"},"finish_reason":"stop"}],"usage":{"prompt_tokens":60,"completion_tokens":40,"total_tokens":100}}`)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	engine.SetStreaming(false)
+	engine.StartBudget(TranslationBudget{MaxTokens: 150})
+
+	if _, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world"); err != nil {
+		t.Fatalf("first chunk: %v", err)
+	}
+	if got := engine.BudgetStatus(); got != "预算剩余 50 tokens" {
+		t.Errorf("BudgetStatus() = %q", got)
+	}
+	if _, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world"); err != nil {
+		t.Fatalf("second chunk: %v", err)
+	}
+
+	_, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if !IsBudgetExceeded(err) {
+		t.Fatalf("third chunk error = %v, want budget exceeded", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("requests = %d, want 2 (no request once the budget is spent)", got)
+	}
+
+	// A new budget starts from scratch; no budget means no limit
+	engine.StartBudget(TranslationBudget{})
+	if got := engine.BudgetStatus(); got != "" {
+		t.Errorf("BudgetStatus() without budget = %q", got)
+	}
+	if _, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world"); err != nil {
+		t.Errorf("chunk without budget: %v", err)
+	}
+}
+
+func TestTimeBudgetCancelsRequestInFlight(t *testing.T) {
+	server, _ := hangingServer(t, 1000)
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	engine.SetStallWindow(time.Minute)
+	engine.StartBudget(TranslationBudget{MaxDuration: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if !IsBudgetExceeded(err) {
+		t.Fatalf("error = %v, want budget exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request cancelled after %s", elapsed)
+	}
+	if got := engine.BudgetStatus(); got != "预算剩余 0 秒" {
+		t.Errorf("BudgetStatus() = %q", got)
+	}
+}
@@ -0,0 +1,397 @@
+package translator
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
+)
+
+// macroDefinitionCommands are the commands defining a macro, without the backslash.
+// \newcommand and its siblings take the name in braces or not, optional [n][default]
+// and the body in braces; \def and its siblings take the name, a parameter text and the body.
+var macroDefinitionCommands = map[string]bool{
+	"newcommand": true, "renewcommand": true, "providecommand": true, "DeclareRobustCommand": true,
+	"def": true, "gdef": true, "edef": true, "xdef": true,
+}
+
+// userMacro is a macro definition of the document
+type userMacro struct {
+	name       string // with the backslash, e.g. `\method`
+	definition string // source of the whole definition
+	body       string // replacement text
+	params     bool   // takes arguments
+	start, end int    // the definition in the scanned content
+}
+
+// MacroRepair describes a user macro RestoreUserMacros repaired
+type MacroRepair struct {
+	Name       string // with the backslash, e.g. `\method`
+	Definition bool   // the definition was changed or dropped and was restored from the original
+	Uses       int    // uses put back in place of the expansion the model wrote out
+	Lost       bool   // the macro is no longer used and its uses could not be found
+}
+
+// scanUserMacros returns the macro definitions of content outside comments and
+// verbatim-like environments, in document order. Definitions nested in the body of
+// another one are part of it.
+func scanUserMacros(content string) []userMacro {
+	scan := parser.BlankVerbatim(content)
+	var macros []userMacro
+	for i := 0; i < len(scan); i++ {
+		switch scan[i] {
+		case '%':
+			if end := strings.IndexByte(scan[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(scan)
+			}
+		case '\\':
+			name := commandNameAt(scan, i+1)
+			if name == "" {
+				i++ // escaped character such as \% or \{
+				continue
+			}
+			if m, ok := parseMacroDefinition(scan, i, name); ok {
+				m.definition = content[m.start:m.end]
+				macros = append(macros, m)
+				i = m.end - 1
+				continue
+			}
+			i += len(name)
+		}
+	}
+	return macros
+}
+
+// parseMacroDefinition parses the definition starting with the command cmd at start
+func parseMacroDefinition(s string, start int, cmd string) (userMacro, bool) {
+	if !macroDefinitionCommands[cmd] {
+		return userMacro{}, false
+	}
+	m := userMacro{start: start}
+	pos := start + 1 + len(cmd)
+
+	if strings.HasSuffix(cmd, "def") {
+		pos = skipMacroSpaces(s, pos)
+		m.name = macroNameAt(s, pos)
+		if m.name == "" {
+			return userMacro{}, false
+		}
+		pos += len(m.name)
+		// Parameter text up to the body, within the paragraph
+		open := strings.IndexByte(s[pos:], '{')
+		if open == -1 || strings.Contains(s[pos:pos+open], "\n\n") {
+			return userMacro{}, false
+		}
+		m.params = strings.Contains(s[pos:pos+open], "#")
+		pos += open
+	} else {
+		if pos < len(s) && s[pos] == '*' {
+			pos++
+		}
+		pos = skipMacroSpaces(s, pos)
+		if pos < len(s) && s[pos] == '{' {
+			closing := findMatchingBrace(s, pos)
+			if closing == -1 {
+				return userMacro{}, false
+			}
+			m.name = strings.TrimSpace(s[pos+1 : closing])
+			pos = closing + 1
+		} else {
+			m.name = macroNameAt(s, pos)
+			pos += len(m.name)
+		}
+		if !strings.HasPrefix(m.name, "\\") || len(m.name) < 2 {
+			return userMacro{}, false
+		}
+		// Number of arguments, then the default of the optional one
+		for n := 0; n < 2; n++ {
+			pos = skipMacroSpaces(s, pos)
+			if pos >= len(s) || s[pos] != '[' {
+				break
+			}
+			closing := findMatchingBracket(s, pos, len(s))
+			if closing == -1 {
+				return userMacro{}, false
+			}
+			if n == 0 && strings.TrimSpace(s[pos+1:closing]) != "0" {
+				m.params = true
+			}
+			pos = closing + 1
+		}
+		pos = skipMacroSpaces(s, pos)
+	}
+
+	if pos >= len(s) || s[pos] != '{' {
+		return userMacro{}, false
+	}
+	closing := findMatchingBrace(s, pos)
+	if closing == -1 {
+		return userMacro{}, false
+	}
+	m.body = s[pos+1 : closing]
+	m.end = closing + 1
+	return m, true
+}
+
+// macroNameAt returns the control sequence at pos: a backslash followed by letters and @
+// (as in \makeatletter code), or by a single other character
+func macroNameAt(s string, pos int) string {
+	if pos >= len(s) || s[pos] != '\\' || pos+1 >= len(s) {
+		return ""
+	}
+	end := pos + 1
+	for end < len(s) && (isMacroLetter(s[end]) || s[end] == '@') {
+		end++
+	}
+	if end == pos+1 {
+		end++
+	}
+	return s[pos:end]
+}
+
+// isMacroLetter reports whether c is an ASCII letter
+func isMacroLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// skipMacroSpaces returns the position of the first character at or after pos that is
+// not white space
+func skipMacroSpaces(s string, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t' || s[pos] == '\n' || s[pos] == '\r') {
+		pos++
+	}
+	return pos
+}
+
+// documentBodyStart returns the position of \begin{document} in content, or -1
+func documentBodyStart(content string) int {
+	return indexOutsideComments(content, "\\begin{document}")
+}
+
+// preambleMacros returns the macro definitions before \begin{document}; none for a file
+// without a preamble
+func preambleMacros(content string) []userMacro {
+	bodyStart := documentBodyStart(content)
+	if bodyStart == -1 {
+		return nil
+	}
+	return scanUserMacros(content[:bodyStart])
+}
+
+// userMacroNames returns the names of the macros content defines, sorted
+func userMacroNames(content string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range scanUserMacros(content) {
+		if !seen[m.name] {
+			seen[m.name] = true
+			names = append(names, m.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// protectMacroDefinitions replaces the macro definitions of content with comment
+// placeholders. The model translated the bodies of text macros and broke the braces of
+// the others; a definition is code and comes back byte for byte.
+func protectMacroDefinitions(content string) (string, []commentPlaceholder) {
+	macros := scanUserMacros(content)
+	spans := make([]codeChunkSpan, len(macros))
+	for i, m := range macros {
+		spans[i] = codeChunkSpan{m.start, m.end}
+	}
+	return replaceSpans(content, spans, "MACRO_DEF_PLACEHOLDER")
+}
+
+// countMacroUses counts the uses of the macro name in content outside comments. A name
+// made of letters must not be followed by another letter (\method is not \methods).
+func countMacroUses(content, name string) int {
+	letters := isMacroLetter(name[len(name)-1])
+	count := 0
+	for offset := 0; ; {
+		i := indexOutsideComments(content[offset:], name)
+		if i == -1 {
+			return count
+		}
+		end := offset + i + len(name)
+		if !letters || end >= len(content) || !(isMacroLetter(content[end]) || content[end] == '@') {
+			count++
+		}
+		offset = end
+	}
+}
+
+// userMacrosKey is the context key of the user macros of the document being translated
+type userMacrosKey struct{}
+
+// withUserMacros returns ctx carrying the names of the macros the document defines, for
+// the chunk prompts
+func withUserMacros(ctx context.Context, names []string) context.Context {
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, userMacrosKey{}, names)
+}
+
+// userMacroPromptSection returns the instructions on the user macros used in a chunk
+func userMacroPromptSection(ctx context.Context, chunk string) string {
+	names, _ := ctx.Value(userMacrosKey{}).([]string)
+	var used []string
+	for _, name := range names {
+		if countMacroUses(chunk, name) > 0 {
+			used = append(used, name)
+		}
+	}
+	if len(used) == 0 {
+		return ""
+	}
+	return "USER MACROS: these commands are defined by the document. Do not alter these commands: " +
+		"keep each of them exactly as written, never replace one with its expansion or translate its name:\n" +
+		strings.Join(used, ", ") + "\n"
+}
+
+// RestoreUserMacros checks the macros defined in the preamble of original against the
+// translation. A definition the translation changed or dropped is restored from the
+// original. A macro used in the original body whose uses all disappeared from the
+// translated body had its expansion written out by the model: the expansion is replaced
+// with the macro again, when it can be found.
+func RestoreUserMacros(translated, original string) (string, []MacroRepair) {
+	origMacros := preambleMacros(original)
+	transBodyStart := documentBodyStart(translated)
+	if len(origMacros) == 0 || transBodyStart == -1 {
+		return translated, nil
+	}
+
+	var repairs []MacroRepair
+	repairIndex := make(map[string]int)
+	repair := func(name string) *MacroRepair {
+		i, ok := repairIndex[name]
+		if !ok {
+			i = len(repairs)
+			repairIndex[name] = i
+			repairs = append(repairs, MacroRepair{Name: name})
+		}
+		return &repairs[i]
+	}
+
+	// The k-th definition of a name in the original is compared with the k-th one in the
+	// translation
+	transByName := make(map[string][]userMacro)
+	for _, m := range scanUserMacros(translated[:transBodyStart]) {
+		transByName[m.name] = append(transByName[m.name], m)
+	}
+	seen := make(map[string]int)
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+	var missing []string
+	for _, m := range origMacros {
+		k := seen[m.name]
+		seen[m.name]++
+		if k < len(transByName[m.name]) {
+			t := transByName[m.name][k]
+			if t.definition != m.definition {
+				replacements = append(replacements, replacement{t.start, t.end, m.definition})
+				repair(m.name).Definition = true
+			}
+			continue
+		}
+		missing = append(missing, m.definition)
+		repair(m.name).Definition = true
+	}
+	result := translated
+	for i := len(replacements) - 1; i >= 0; i-- {
+		r := replacements[i]
+		result = result[:r.start] + r.text + result[r.end:]
+	}
+	if len(missing) > 0 {
+		bodyStart := documentBodyStart(result)
+		result = result[:bodyStart] + strings.Join(missing, "\n") + "\n" + result[bodyStart:]
+	}
+
+	// Uses that collapsed to zero
+	origBody := original[documentBodyStart(original):]
+	bodyStart := documentBodyStart(result)
+	checked := make(map[string]bool)
+	for _, m := range origMacros {
+		if checked[m.name] {
+			continue
+		}
+		checked[m.name] = true
+		if countMacroUses(origBody, m.name) == 0 || countMacroUses(result[bodyStart:], m.name) > 0 {
+			continue
+		}
+		body, uses := restoreMacroUses(result[bodyStart:], m)
+		if uses > 0 {
+			result = result[:bodyStart] + body
+			repair(m.name).Uses = uses
+		} else {
+			repair(m.name).Lost = true
+		}
+	}
+
+	for _, r := range repairs {
+		if r.Lost {
+			logger.Warn("user macro no longer used in the translation",
+				logger.String("macro", r.Name))
+			continue
+		}
+		logger.Info("restored user macro",
+			logger.String("macro", r.Name),
+			logger.Bool("definition", r.Definition),
+			logger.Int("uses", r.Uses))
+	}
+	return result, repairs
+}
+
+// restoreMacroUses replaces the written-out expansion of a macro without arguments in
+// body with the macro, and returns the number of uses restored
+func restoreMacroUses(body string, m userMacro) (string, int) {
+	if m.params {
+		return body, 0
+	}
+	for _, expansion := range macroExpansions(m.body) {
+		if n := strings.Count(body, expansion); n > 0 {
+			return strings.ReplaceAll(body, expansion, m.name+"{}"), n
+		}
+	}
+	return body, 0
+}
+
+// macroExpansions returns the forms in which the model writes out a macro body, most
+// specific first: the body, the body without \xspace, and the text of a body made of a
+// single formatting command such as \textsc{OurMethod}
+func macroExpansions(body string) []string {
+	body = strings.TrimSpace(body)
+	var forms []string
+	add := func(form string) {
+		form = strings.TrimSpace(form)
+		if len([]rune(form)) < 2 || strings.ContainsAny(form, "#") {
+			return
+		}
+		for _, f := range forms {
+			if f == form {
+				return
+			}
+		}
+		forms = append(forms, form)
+	}
+	add(body)
+	body = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(body, "{}"), "\\xspace"))
+	add(body)
+	if name := macroNameAt(body, 0); len(name) > 1 && strings.HasPrefix(body[len(name):], "{") && strings.HasSuffix(body, "}") {
+		if closing := findMatchingBrace(body, len(name)); closing == len(body)-1 {
+			if inner := body[len(name)+1 : closing]; !strings.ContainsAny(inner, "\\{}$") {
+				add(inner)
+			}
+		}
+	}
+	return forms
+}
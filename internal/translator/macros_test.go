@@ -0,0 +1,158 @@
+package translator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// macroPreamble is the preamble of a typical ML paper with its shorthand macros
+const macroPreamble = `\documentclass{article}
+\usepackage{xspace}
+\usepackage{listings}
+\newcommand{\method}{OurCoolMethod\xspace}
+\newcommand*\dataset{\textsc{WebQA}}
+\newcommand{\norm}[1]{\left\lVert#1\right\rVert}
+\renewcommand{\vec}[2][n]{\mathbf{#2}_{#1}}
+\providecommand{\todo}[1]{{\color{red} TODO: #1}}
+\def\eg{e.g.,\xspace}
+\def\sgn#1{\operatorname{sgn}(#1)}
+% \newcommand{\old}{Old method}
+\makeatletter
+\def\@maketag@@@#1{\hbox{\m@th\normalfont#1}}
+\makeatother
+\begin{lstlisting}
+\newcommand{\notamacro}{listing}
+\end{lstlisting}
+`
+
+// macroBody uses the macros of macroPreamble
+const macroBody = `\begin{document}
+\method{} outperforms prior work on \dataset, \eg on long questions.
+We bound $\norm{x}$ and $\sgn{y}$. \todo{check this}
+The \method\ model is simple.
+\end{document}
+`
+
+func TestScanUserMacros(t *testing.T) {
+	macros := scanUserMacros(macroPreamble)
+	want := []struct {
+		name, body string
+		params     bool
+	}{
+		{`\method`, `OurCoolMethod\xspace`, false},
+		{`\dataset`, `\textsc{WebQA}`, false},
+		{`\norm`, `\left\lVert#1\right\rVert`, true},
+		{`\vec`, `\mathbf{#2}_{#1}`, true},
+		{`\todo`, `{\color{red} TODO: #1}`, true},
+		{`\eg`, `e.g.,\xspace`, false},
+		{`\sgn`, `\operatorname{sgn}(#1)`, true},
+		{`\@maketag@@@`, `\hbox{\m@th\normalfont#1}`, true},
+	}
+	if len(macros) != len(want) {
+		t.Fatalf("scanUserMacros() found %d macros, want %d: %+v", len(macros), len(want), macros)
+	}
+	for i, w := range want {
+		m := macros[i]
+		if m.name != w.name || m.body != w.body || m.params != w.params {
+			t.Errorf("macro %d = {%s %q %v}, want {%s %q %v}", i, m.name, m.body, m.params, w.name, w.body, w.params)
+		}
+		if macroPreamble[m.start:m.end] != m.definition || !strings.HasSuffix(m.definition, "}") {
+			t.Errorf("macro %s definition = %q", m.name, m.definition)
+		}
+	}
+}
+
+func TestProtectMacroDefinitions(t *testing.T) {
+	content := macroPreamble + macroBody
+	protected, placeholders := protectMacroDefinitions(content)
+	if len(placeholders) != 8 {
+		t.Fatalf("protected %d definitions, want 8", len(placeholders))
+	}
+	if strings.Contains(protected, "OurCoolMethod") || strings.Contains(protected, `\def\eg`) {
+		t.Errorf("definitions left in the chunks:\n%s", protected)
+	}
+	if !strings.Contains(protected, `\method{} outperforms`) {
+		t.Errorf("uses of the macros were protected too:\n%s", protected)
+	}
+	if got := restoreCommentEnvironments(protected, placeholders); got != content {
+		t.Errorf("restore changed the content:\n%s", got)
+	}
+}
+
+func TestUserMacroPromptSection(t *testing.T) {
+	ctx := withUserMacros(context.Background(), userMacroNames(macroPreamble))
+	got := userMacroPromptSection(ctx, "The \\method\\ model beats \\methods on \\dataset.")
+	if !strings.Contains(got, "Do not alter these commands") || !strings.HasSuffix(got, "\\dataset, \\method\n") {
+		t.Errorf("userMacroPromptSection() = %q", got)
+	}
+	if got := userMacroPromptSection(ctx, "No macros here."); got != "" {
+		t.Errorf("userMacroPromptSection() without uses = %q", got)
+	}
+	if got := userMacroPromptSection(context.Background(), "\\method"); got != "" {
+		t.Errorf("userMacroPromptSection() without macros = %q", got)
+	}
+}
+
+func TestRestoreUserMacros(t *testing.T) {
+	original := macroPreamble + macroBody
+
+	// The model translated one body, dropped a definition and wrote out two macros
+	translated := strings.NewReplacer(
+		`\newcommand{\method}{OurCoolMethod\xspace}`, `\newcommand{\method}{我们的酷方法\xspace}`,
+		"\\def\\eg{e.g.,\\xspace}\n", "",
+		`\method{} outperforms prior work on \dataset, \eg on long questions.`,
+		`OurCoolMethod 在 WebQA 上优于先前的工作，例如长问题。`,
+		`The \method\ model is simple.`, `OurCoolMethod 模型很简单。`,
+	).Replace(original)
+
+	fixed, repairs := RestoreUserMacros(translated, original)
+
+	for _, def := range []string{`\newcommand{\method}{OurCoolMethod\xspace}`, `\def\eg{e.g.,\xspace}`} {
+		if !strings.Contains(fixed[:documentBodyStart(fixed)], def) {
+			t.Errorf("definition %s not restored in the preamble:\n%s", def, fixed)
+		}
+	}
+	if strings.Contains(fixed, "我们的酷方法") {
+		t.Errorf("translated definition kept:\n%s", fixed)
+	}
+	if !strings.Contains(fixed, `\method{} 在 \dataset{} 上`) || !strings.Contains(fixed, `\method{} 模型很简单`) {
+		t.Errorf("uses not restored:\n%s", fixed)
+	}
+
+	byName := make(map[string]MacroRepair)
+	for _, r := range repairs {
+		byName[r.Name] = r
+	}
+	if r := byName[`\method`]; !r.Definition || r.Uses != 2 {
+		t.Errorf(`\method repair = %+v`, r)
+	}
+	if r := byName[`\dataset`]; r.Definition || r.Uses != 1 {
+		t.Errorf(`\dataset repair = %+v`, r)
+	}
+	if r := byName[`\eg`]; !r.Definition || !r.Lost {
+		t.Errorf(`\eg repair = %+v, want its definition restored and its uses lost`, r)
+	}
+	if _, ok := byName[`\norm`]; ok {
+		t.Error(`\norm was repaired but is intact`)
+	}
+}
+
+func TestRestoreUserMacrosLeavesIntactTranslation(t *testing.T) {
+	original := macroPreamble + macroBody
+	translated := strings.Replace(original, "The \\method\\ model is simple.", "\\method\\ 模型很简单。", 1)
+	fixed, repairs := RestoreUserMacros(translated, original)
+	if fixed != translated || len(repairs) != 0 {
+		t.Errorf("RestoreUserMacros() changed an intact translation: %+v", repairs)
+	}
+	if fixed, _ := RestoreUserMacros("Text.", "Text."); fixed != "Text." {
+		t.Errorf("RestoreUserMacros() without preamble = %q", fixed)
+	}
+}
+
+func TestCountMacroUses(t *testing.T) {
+	content := "\\method, \\methods and \\method{} % \\method\n\\method\\ again"
+	if got := countMacroUses(content, `\method`); got != 3 {
+		t.Errorf("countMacroUses() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,238 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// mathTextCommands are the commands whose argument is prose inside math, e.g.
+// $f(x) = 1 \text{ if } x > 0$. The words may be translated, the spaces around them and
+// everything else in the formula may not.
+var mathTextCommands = map[string]bool{
+	"text":   true,
+	"textrm": true,
+	"mbox":   true,
+}
+
+// mathNameCommands are the commands whose argument is part of the formula (operator and
+// function names) and is never translated, e.g. \operatorname{argmax} or \mathrm{softmax}
+var mathNameCommands = map[string]bool{
+	"operatorname": true,
+	"mathrm":       true,
+}
+
+// mathOperatorWords are words that name an operator even inside \text{}
+var mathOperatorWords = map[string]bool{
+	"argmax": true, "argmin": true, "softmax": true, "max": true, "min": true,
+	"sup": true, "inf": true, "lim": true, "log": true, "exp": true, "sign": true,
+	"sgn": true, "diag": true, "tr": true, "rank": true, "det": true, "var": true,
+	"cov": true, "mod": true, "const": true,
+}
+
+// mathWordPattern matches a lowercase word, which tells prose from symbols and acronyms
+var mathWordPattern = regexp.MustCompile(`[a-z]{2,}`)
+
+// mathTextSpan delimits the words of a text command argument inside math, without the
+// spaces around them
+type mathTextSpan struct {
+	start int
+	end   int
+}
+
+// findMathTextSpans returns the arguments of the text commands in a math region, in order.
+// Arguments of \operatorname and \mathrm are skipped, so text commands inside them are not
+// reported.
+func findMathTextSpans(math string) []mathTextSpan {
+	var spans []mathTextSpan
+	for i := 0; i < len(math); i++ {
+		if math[i] != '\\' {
+			continue
+		}
+		name := commandNameAt(math, i+1)
+		if name == "" {
+			i++ // escaped character such as \{ or \$
+			continue
+		}
+		end := i + 1 + len(name)
+		if !mathTextCommands[name] && !mathNameCommands[name] {
+			i = end - 1
+			continue
+		}
+		if end < len(math) && math[end] == '*' {
+			end++
+		}
+		for end < len(math) && (math[end] == ' ' || math[end] == '\t') {
+			end++
+		}
+		closing := findMatchingBrace(math, end)
+		if closing < 0 {
+			i = end - 1
+			continue
+		}
+		if mathTextCommands[name] {
+			inner := math[end+1 : closing]
+			start := end + 1 + len(inner) - len(strings.TrimLeft(inner, " \t\n~"))
+			stop := closing - (len(inner) - len(strings.TrimRight(inner, " \t\n~")))
+			if stop < start {
+				stop = start
+			}
+			spans = append(spans, mathTextSpan{start: start, end: stop})
+		}
+		i = closing
+	}
+	return spans
+}
+
+// commandNameAt returns the letters of a control word starting at pos
+func commandNameAt(s string, pos int) string {
+	end := pos
+	for end < len(s) && ((s[end] >= 'a' && s[end] <= 'z') || (s[end] >= 'A' && s[end] <= 'Z')) {
+		end++
+	}
+	return s[pos:end]
+}
+
+// isTranslatableMathText reports whether the words of a text command inside math are prose
+// to translate, rather than an acronym, a symbol name or an operator
+func isTranslatableMathText(words string) bool {
+	if strings.ContainsAny(words, "\\{}$^_") {
+		return false
+	}
+	if mathOperatorWords[strings.ToLower(strings.TrimSpace(words))] {
+		return false
+	}
+	return mathWordPattern.MatchString(words)
+}
+
+// isInlineMath reports whether a protected math region is inline math in prose
+func isInlineMath(math string) bool {
+	return (strings.HasPrefix(math, "$") && !strings.HasPrefix(math, "$$")) || strings.HasPrefix(math, "\\(")
+}
+
+// exposeInlineMathText lets the model translate the prose in \text{} spans of inline math.
+// Each inline math placeholder whose text spans contain prose is split around the words,
+// so the formula, including the spaces inside the braces, stays in placeholders:
+// "$1 \text{ if } x$" becomes "<<<LATEX_MATH_0>>>if<<<LATEX_MATH_7>>>" with the
+// placeholders holding "$1 \text{ " and " } x$". Display math stays fully protected.
+func exposeInlineMathText(content string, placeholders map[string]string) string {
+	keys := make([]string, 0, len(placeholders))
+	for key := range placeholders {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return extractPlaceholderNumber(keys[i]) < extractPlaceholderNumber(keys[j])
+	})
+
+	next := len(placeholders)
+	exposed := 0
+	for _, key := range keys {
+		math := placeholders[key]
+		if !isInlineMath(math) || !strings.Contains(content, key) {
+			continue
+		}
+		var translatable []mathTextSpan
+		for _, span := range findMathTextSpans(math) {
+			if isTranslatableMathText(math[span.start:span.end]) {
+				translatable = append(translatable, span)
+			}
+		}
+		if len(translatable) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString(key)
+		placeholders[key] = math[:translatable[0].start]
+		for i, span := range translatable {
+			sb.WriteString(math[span.start:span.end])
+			fragmentEnd := len(math)
+			if i+1 < len(translatable) {
+				fragmentEnd = translatable[i+1].start
+			}
+			placeholder := fmt.Sprintf("<<<LATEX_MATH_%d>>>", next)
+			next++
+			placeholders[placeholder] = math[span.end:fragmentEnd]
+			sb.WriteString(placeholder)
+		}
+		content = strings.Replace(content, key, sb.String(), 1)
+		exposed++
+	}
+
+	if exposed > 0 {
+		logger.Debug("exposed text in inline math for translation", logger.Int("regions", exposed))
+	}
+	return content
+}
+
+// RepairMathText makes the math in a translation identical to the math in the original,
+// except for the translated words in \text{}, \textrm{} and \mbox{}. The spaces around those
+// words, the operators next to them and the arguments of \operatorname and \mathrm are
+// taken from the original, which repairs a model eating "\text{ if }" into "\text{如果}" or
+// translating \operatorname{argmax}. Math regions are paired in order; if the translation
+// has a different number of math regions it is returned unchanged.
+func RepairMathText(original, translated string) string {
+	originalRegions := collectMathRegions(original)
+	if len(originalRegions) == 0 {
+		return translated
+	}
+	translatedRegions := collectMathRegions(translated)
+	if len(translatedRegions) != len(originalRegions) {
+		logger.Debug("math regions differ in translation, not repairing",
+			logger.Int("original", len(originalRegions)),
+			logger.Int("translated", len(translatedRegions)))
+		return translated
+	}
+
+	var sb strings.Builder
+	last := 0
+	repaired := 0
+	for i, region := range translatedRegions {
+		fixed := repairMathRegion(originalRegions[i].Original, region.Original)
+		if fixed != region.Original {
+			repaired++
+		}
+		sb.WriteString(translated[last:region.Start])
+		sb.WriteString(fixed)
+		last = region.End
+	}
+	sb.WriteString(translated[last:])
+
+	if repaired > 0 {
+		logger.Info("repaired math changed by translation", logger.Int("regions", repaired))
+	}
+	return sb.String()
+}
+
+// repairMathRegion rebuilds a translated math region from the original one, keeping only
+// the translated words of text spans that are prose
+func repairMathRegion(original, translated string) string {
+	if original == translated {
+		return original
+	}
+	originalSpans := findMathTextSpans(original)
+	translatedSpans := findMathTextSpans(translated)
+	if len(originalSpans) != len(translatedSpans) {
+		return original
+	}
+
+	var sb strings.Builder
+	last := 0
+	for i, span := range originalSpans {
+		sb.WriteString(original[last:span.start])
+		words := original[span.start:span.end]
+		if isTranslatableMathText(words) {
+			candidate := translated[translatedSpans[i].start:translatedSpans[i].end]
+			if candidate != "" && !strings.ContainsAny(candidate, "\\{}$") {
+				words = candidate
+			}
+		}
+		sb.WriteString(words)
+		last = span.end
+	}
+	sb.WriteString(original[last:])
+	return sb.String()
+}
@@ -0,0 +1,115 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+// Broken translations captured from real papers: the model ate the spaces inside \text{},
+// dropped the operator next to it, or translated an operator name
+var mathTextCases = []struct {
+	name       string
+	original   string
+	translated string
+	want       string
+}{
+	{
+		"spaces eaten in text",
+		`其中 $f(x) = 1 \text{ if } x > 0$。`,
+		`其中 $f(x) = 1 \text{如果} x > 0$。`,
+		`其中 $f(x) = 1 \text{ 如果 } x > 0$。`,
+	},
+	{
+		"operatorname translated",
+		`预测为 $\hat{y} = \operatorname{argmax}_{c} p(c \mid x)$。`,
+		`预测为 $\hat{y} = \operatorname{最大参数}_{c} p(c \mid x)$。`,
+		`预测为 $\hat{y} = \operatorname{argmax}_{c} p(c \mid x)$。`,
+	},
+	{
+		"mathrm translated",
+		`输出 $\mathrm{softmax}(z)_i$ 为概率。`,
+		`输出 $\mathrm{软最大值}(z)_i$ 为概率。`,
+		`输出 $\mathrm{softmax}(z)_i$ 为概率。`,
+	},
+	{
+		"adjacent operator dropped",
+		`$\forall i \text{ such that } i \leq n, \; a_i > 0$`,
+		`$\forall i \text{使得} i \le n, a_i > 0$`,
+		`$\forall i \text{ 使得 } i \leq n, \; a_i > 0$`,
+	},
+	{
+		"mbox in paren math",
+		`对于 \(a \mbox{ and } b\)，`,
+		`对于 \(a \mbox{和} b\)，`,
+		`对于 \(a \mbox{ 和 } b\)，`,
+	},
+	{
+		"display math with quad",
+		"\\[ x = \\begin{cases} 1 & \\textrm{for all } n \\\\ 0 & \\text{otherwise} \\end{cases} \\]",
+		"\\[ x = \\begin{cases} 1 & \\textrm{对所有} n \\\\ 0 & \\text{否则} \\end{cases} \\]",
+		"\\[ x = \\begin{cases} 1 & \\textrm{对所有 } n \\\\ 0 & \\text{否则} \\end{cases} \\]",
+	},
+	{
+		"acronym in text is not translated",
+		`散度 $D_{\text{KL}}(p \| q)$`,
+		`散度 $D_{\text{库尔贝克}}(p \| q)$`,
+		`散度 $D_{\text{KL}}(p \| q)$`,
+	},
+	{
+		"operator word in text is not translated",
+		`$\text{argmin}_x f(x)$`,
+		`$\text{最小参数}_x f(x)$`,
+		`$\text{argmin}_x f(x)$`,
+	},
+	{
+		"text inside operatorname is not a span",
+		`$\operatorname{\text{rank }}A$`,
+		`$\operatorname{\text{秩}}A$`,
+		`$\operatorname{\text{rank }}A$`,
+	},
+	{
+		"missing math region left alone",
+		`当 $x > 0$ 且 $y \text{ is small}$ 时`,
+		`当 $x > 0$ 时`,
+		`当 $x > 0$ 时`,
+	},
+}
+
+func TestRepairMathText(t *testing.T) {
+	for _, tt := range mathTextCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RepairMathText(tt.original, tt.translated); got != tt.want {
+				t.Errorf("RepairMathText() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// Repairing is idempotent and leaves correct math alone
+			if got := RepairMathText(tt.original, tt.want); got != tt.want {
+				t.Errorf("repairing a correct translation changed it:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestInlineMathTextRoundTrip(t *testing.T) {
+	original := "We set $y = 1 \\text{ if } x > 0$ and $\\hat{c} = \\operatorname{argmax}_c p_c$.\n" +
+		"\\begin{equation}\n  g(x) = 0 \\quad \\text{otherwise}\n\\end{equation}\n"
+
+	protected, placeholders := ProtectLaTeXCommands(original)
+	if !strings.Contains(protected, ">>>if<<<") {
+		t.Fatalf("prose in inline \\text{} was not exposed for translation: %q", protected)
+	}
+	for _, hidden := range []string{"argmax", "otherwise", "\\text"} {
+		if strings.Contains(protected, hidden) {
+			t.Errorf("%q was exposed for translation: %q", hidden, protected)
+		}
+	}
+
+	// The model translates the exposed words and keeps the placeholders
+	translated := strings.NewReplacer("We set", "我们令", ">>>if<<<", ">>>如果<<<", " and ", " 且 ").Replace(protected)
+	restored := RepairMathText(original, RestoreLaTeXCommands(translated, placeholders))
+
+	want := "我们令 $y = 1 \\text{ 如果 } x > 0$ 且 $\\hat{c} = \\operatorname{argmax}_c p_c$.\n" +
+		"\\begin{equation}\n  g(x) = 0 \\quad \\text{otherwise}\n\\end{equation}\n"
+	if restored != want {
+		t.Errorf("round trip =\n%s\nwant\n%s", restored, want)
+	}
+}
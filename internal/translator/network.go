@@ -0,0 +1,231 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// DefaultMaxNetworkPause is how long translation waits for connectivity to return
+	// before giving up on a network outage
+	DefaultMaxNetworkPause = 10 * time.Minute
+	// networkProbeTimeout is the dial timeout of a single connectivity probe
+	networkProbeTimeout = 5 * time.Second
+	// maxTransportRetriesPerChunk limits how often a single chunk may be retried for
+	// transport errors without consuming its retry budget (e.g. when the API host is
+	// reachable but requests keep timing out)
+	maxTransportRetriesPerChunk = 5
+)
+
+var (
+	// networkProbeBaseDelay is the initial delay between connectivity probes
+	networkProbeBaseDelay = 2 * time.Second
+	// networkProbeMaxDelay caps the exponential backoff between connectivity probes
+	networkProbeMaxDelay = 30 * time.Second
+)
+
+// networkOutage represents one period of lost connectivity.
+// done is closed when connectivity returns or the engine gives up waiting (err is set).
+type networkOutage struct {
+	done chan struct{}
+	err  error
+}
+
+// networkBreaker is a circuit breaker shared by all chunk workers of an engine.
+// When a worker hits a transport-level error the breaker opens: every worker waits
+// while a single probe loop checks connectivity with exponential backoff.
+type networkBreaker struct {
+	mu       sync.Mutex
+	outage   *networkOutage
+	pauses   int
+	paused   time.Duration
+	listener func(paused bool)
+}
+
+// newNetworkBreaker creates a closed circuit breaker
+func newNetworkBreaker() *networkBreaker {
+	return &networkBreaker{}
+}
+
+// setListener registers a callback invoked when translation pauses or resumes.
+// Returns a function that restores the previous listener.
+func (b *networkBreaker) setListener(listener func(paused bool)) func() {
+	b.mu.Lock()
+	previous := b.listener
+	b.listener = listener
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		b.listener = previous
+		b.mu.Unlock()
+	}
+}
+
+// stats returns the number of outages and the total time spent paused
+func (b *networkBreaker) stats() (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pauses, b.paused
+}
+
+// current returns the ongoing outage, or nil if the breaker is closed
+func (b *networkBreaker) current() *networkOutage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.outage
+}
+
+// wait blocks while an outage is in progress.
+// Returns an error if connectivity did not return in time or ctx is done first.
+func (b *networkBreaker) wait(ctx context.Context) error {
+	if o := b.current(); o != nil {
+		return o.wait(ctx)
+	}
+	return nil
+}
+
+// wait blocks until the outage is over or ctx is done. The probe loop keeps running for
+// the other workers when ctx is done.
+func (o *networkOutage) wait(ctx context.Context) error {
+	select {
+	case <-o.done:
+		return o.err
+	case <-ctx.Done():
+		return cancelledError(ctx)
+	}
+}
+
+// trip opens the breaker (unless it is already open) and starts probing connectivity.
+// Returns the ongoing outage so the caller can wait for it.
+func (b *networkBreaker) trip(probe func() error, maxPause time.Duration) *networkOutage {
+	b.mu.Lock()
+	if b.outage != nil {
+		o := b.outage
+		b.mu.Unlock()
+		return o
+	}
+	o := &networkOutage{done: make(chan struct{})}
+	b.outage = o
+	b.pauses++
+	listener := b.listener
+	b.mu.Unlock()
+
+	logger.Warn("network outage detected, pausing translation", logger.String("maxPause", maxPause.String()))
+	if listener != nil {
+		listener(true)
+	}
+
+	go b.probeUntilRecovered(o, probe, maxPause)
+	return o
+}
+
+// probeUntilRecovered probes connectivity with exponential backoff until it succeeds
+// or maxPause has elapsed, then closes the breaker.
+func (b *networkBreaker) probeUntilRecovered(o *networkOutage, probe func() error, maxPause time.Duration) {
+	start := time.Now()
+	delay := networkProbeBaseDelay
+	for {
+		time.Sleep(delay)
+		err := probe()
+		if err == nil {
+			logger.Info("network connectivity restored", logger.String("pausedFor", time.Since(start).Round(time.Second).String()))
+			break
+		}
+		if time.Since(start) >= maxPause {
+			o.err = types.NewAppErrorWithDetails(
+				types.ErrNetwork,
+				"网络中断时间过长，翻译已停止",
+				fmt.Sprintf("connectivity did not return within %s", maxPause),
+				err,
+			)
+			logger.Error("network did not recover in time", err, logger.String("maxPause", maxPause.String()))
+			break
+		}
+		logger.Debug("connectivity probe failed", logger.Err(err), logger.String("nextDelay", delay.String()))
+		delay *= 2
+		if delay > networkProbeMaxDelay {
+			delay = networkProbeMaxDelay
+		}
+	}
+
+	b.mu.Lock()
+	b.paused += time.Since(start)
+	b.outage = nil
+	listener := b.listener
+	b.mu.Unlock()
+
+	close(o.done)
+	if listener != nil && o.err == nil {
+		listener(false)
+	}
+}
+
+// isTransportError reports whether err is a transport-level failure (DNS, connection
+// refused/reset, timeouts) as opposed to an error in the API response or its content.
+func isTransportError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var appErr *types.AppError
+	if errors.As(err, &appErr) && appErr.Code != types.ErrNetwork {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	// doTranslateChunk only uses ErrNetwork for failures to send or receive the request
+	return appErr != nil
+}
+
+// SetMaxNetworkPause sets how long translation waits for connectivity to return
+func (t *TranslationEngine) SetMaxNetworkPause(d time.Duration) {
+	if d > 0 {
+		t.maxNetworkPause = d
+	}
+}
+
+// probeConnectivity checks whether the API host is reachable by opening a TCP connection.
+// This costs no tokens and works for OpenAI-compatible endpoints behind any path.
+func (t *TranslationEngine) probeConnectivity() error {
+	if t.connectivityProbe != nil {
+		return t.connectivityProbe()
+	}
+
+	u, err := url.Parse(t.endpoint())
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "http" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", host, networkProbeTimeout)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,186 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestIsTransportError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"network app error", types.NewAppError(types.ErrNetwork, "API request failed", fmt.Errorf("dial tcp: connection refused")), true},
+		{"rate limit", types.NewAppError(types.ErrAPIRateLimit, "rate limited", nil), false},
+		{"server error", types.NewAppErrorWithDetails(types.ErrAPICall, "API server error", "status 502: bad gateway", nil), false},
+		{"parse error", types.NewAppError(types.ErrAPICall, "failed to parse API response", fmt.Errorf("invalid character")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransportError(tt.err); got != tt.want {
+				t.Errorf("isTransportError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateChunkPausesOnNetworkOutage(t *testing.T) {
+	oldBase, oldMax := networkProbeBaseDelay, networkProbeMaxDelay
+	networkProbeBaseDelay, networkProbeMaxDelay = time.Millisecond, 5*time.Millisecond
+	defer func() { networkProbeBaseDelay, networkProbeMaxDelay = oldBase, oldMax }()
+
+	// The first requests fail at transport level (connection dropped),
+	// more often than the per-chunk retry budget allows
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= int32(MaxRetries+1) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"你好世界"},"finish_reason":"stop"}],"usage":{"total_tokens":10}}`)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	var probes int32
+	engine.connectivityProbe = func() error {
+		// Connectivity returns on the third probe
+		if atomic.AddInt32(&probes, 1) < 3 {
+			return fmt.Errorf("network unreachable")
+		}
+		return nil
+	}
+
+	var pausedEvents int32
+	restore := engine.breaker.setListener(func(paused bool) {
+		if paused {
+			atomic.AddInt32(&pausedEvents, 1)
+		}
+	})
+	defer restore()
+
+	translated, tokens, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if err != nil {
+		t.Fatalf("translateChunkWithRetry() error: %v", err)
+	}
+	if translated != "你好世界" || tokens != 10 {
+		t.Errorf("translateChunkWithRetry() = %q, %d", translated, tokens)
+	}
+	if pauses, _ := engine.breaker.stats(); pauses == 0 || atomic.LoadInt32(&pausedEvents) == 0 {
+		t.Errorf("expected the breaker to pause at least once, got %d pauses", pauses)
+	}
+	if retried := engine.Progress().RetriedChunks; retried != 0 {
+		t.Errorf("network pauses counted as %d retried chunks", retried)
+	}
+}
+
+func TestTranslateChunkCountsRetriedChunks(t *testing.T) {
+	// The first request fails with a server error, the retry succeeds
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"你好世界"},"finish_reason":"stop"}],"usage":{"total_tokens":10}}`)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	if _, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world"); err != nil {
+		t.Fatalf("translateChunkWithRetry() error: %v", err)
+	}
+	if retried := engine.Progress().RetriedChunks; retried != 1 {
+		t.Errorf("RetriedChunks = %d, want 1", retried)
+	}
+}
+
+func TestTranslateChunkGivesUpAfterMaxNetworkPause(t *testing.T) {
+	oldBase, oldMax := networkProbeBaseDelay, networkProbeMaxDelay
+	networkProbeBaseDelay, networkProbeMaxDelay = time.Millisecond, 2*time.Millisecond
+	defer func() { networkProbeBaseDelay, networkProbeMaxDelay = oldBase, oldMax }()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", "http://127.0.0.1:1/v1/chat/completions", time.Second, 1)
+	engine.SetMaxNetworkPause(20 * time.Millisecond)
+	engine.connectivityProbe = func() error { return fmt.Errorf("network unreachable") }
+
+	_, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if err == nil {
+		t.Fatal("expected an error when connectivity never returns")
+	}
+	if appErr, ok := err.(*types.AppError); !ok || appErr.Code != types.ErrNetwork {
+		t.Errorf("expected ErrNetwork, got %v", err)
+	}
+}
+
+func TestTranslateChunkCancelledDuringNetworkPause(t *testing.T) {
+	oldBase, oldMax := networkProbeBaseDelay, networkProbeMaxDelay
+	networkProbeBaseDelay, networkProbeMaxDelay = time.Millisecond, 5*time.Millisecond
+	defer func() { networkProbeBaseDelay, networkProbeMaxDelay = oldBase, oldMax }()
+
+	// Connectivity never returns, so the breaker stays open for the whole maximum pause,
+	// which is longer than a cancelled chunk may take to return
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", "http://127.0.0.1:1/v1/chat/completions", time.Second, 1)
+	engine.SetMaxNetworkPause(1500 * time.Millisecond)
+	engine.connectivityProbe = func() error { return fmt.Errorf("network unreachable") }
+	defer func() {
+		// The probe loop outlives the cancelled chunks; let it end before restoring the delays
+		if o := engine.breaker.current(); o != nil {
+			<-o.done
+		}
+	}()
+	paused := make(chan struct{}, 1)
+	restore := engine.breaker.setListener(func(p bool) {
+		if p {
+			paused <- struct{}{}
+		}
+	})
+	defer restore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := engine.translateChunkWithRetry(ctx, "Hello world")
+		errs <- err
+	}()
+	select {
+	case <-paused:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the breaker did not trip")
+	}
+	// A second chunk starting during the outage waits in breaker.wait
+	go func() {
+		_, _, err := engine.translateChunkWithRetry(ctx, "Second chunk")
+		errs <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err == nil || !strings.Contains(err.Error(), "翻译已取消") {
+				t.Errorf("expected a cancellation error, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("cancelled chunk still waiting for connectivity")
+		}
+	}
+}
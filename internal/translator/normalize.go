@@ -0,0 +1,253 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// normalizationRule replaces a character the model writes with the one LaTeX expects
+type normalizationRule struct {
+	from string
+	to   string
+}
+
+// mathNormalization applies inside math: fullwidth punctuation there gave "Missing $
+// inserted" and missing character errors under xelatex
+var mathNormalization = []normalizationRule{
+	{"（", "("}, {"）", ")"}, {"［", "["}, {"］", "]"},
+	{"，", ","}, {"、", ","}, {"：", ":"}, {"；", ";"}, {"．", "."},
+	{"＝", "="}, {"＋", "+"}, {"－", "-"}, {"−", "-"}, {"＜", "<"}, {"＞", ">"}, {"｜", "|"},
+	{"　", " "},
+}
+
+// argumentNormalization applies to the arguments of codeArgumentCommands, which are
+// identifiers, paths, code or lengths rather than prose: fullwidth punctuation and curly
+// quotes there broke keys and file names or had no glyph in the typewriter font
+var argumentNormalization = append([]normalizationRule{
+	{"“", "\""}, {"”", "\""}, {"‘", "'"}, {"’", "'"},
+}, mathNormalization...)
+
+// proseNormalization applies everywhere else; Chinese punctuation in prose is kept
+var proseNormalization = []normalizationRule{
+	{"−", "-"},
+}
+
+// codeArgumentCommands are the commands whose first mandatory argument is normalized with
+// argumentNormalization; every \cite variant is included
+var codeArgumentCommands = map[string]bool{
+	"texttt": true, "url": true, "path": true, "href": true,
+	"label": true, "ref": true, "eqref": true, "pageref": true, "autoref": true, "cref": true, "Cref": true, "nameref": true,
+	"includegraphics": true, "input": true, "include": true,
+	"hspace": true, "vspace": true,
+}
+
+// tieConfusionPattern matches a fullwidth space or fullwidth tilde the model wrote in
+// place of the tie before a reference or citation (Fig.~\ref{...})
+var tieConfusionPattern = regexp.MustCompile(`(?:\x{3000}|～)+(\\(?:ref|eqref|pageref|autoref|cref|Cref|nameref|cite[a-zA-Z]*)\b)`)
+
+// normalizationRegion is a part of a chunk normalized with rules; nil rules leave it alone
+type normalizationRegion struct {
+	start, end int
+	rules      []normalizationRule
+}
+
+// normalizeTranslatedChunk converts the fullwidth punctuation the model put in math and in
+// code arguments back to ASCII, replaces U+2212 minus signs with '-' and restores ties
+// written as fullwidth spaces or tildes before references. Chinese punctuation in prose,
+// including in \text{} inside math, comments and \verb are left alone. It returns the
+// content and the number of replacements.
+func normalizeTranslatedChunk(content string) (string, int) {
+	var sb strings.Builder
+	count := 0
+	apply := func(s string, rules []normalizationRule) {
+		for _, r := range rules {
+			if n := strings.Count(s, r.from); n > 0 {
+				s = strings.ReplaceAll(s, r.from, r.to)
+				count += n
+			}
+		}
+		sb.WriteString(s)
+	}
+
+	pos := 0
+	for _, r := range normalizationRegions(content) {
+		apply(content[pos:r.start], proseNormalization)
+		apply(content[r.start:r.end], r.rules)
+		pos = r.end
+	}
+	apply(content[pos:], proseNormalization)
+
+	result := sb.String()
+	if matches := tieConfusionPattern.FindAllStringIndex(result, -1); len(matches) > 0 {
+		result = tieConfusionPattern.ReplaceAllString(result, "~$1")
+		count += len(matches)
+	}
+	return result, count
+}
+
+// normalizationRegions returns the comments, \verb arguments, math and code arguments of
+// content in order
+func normalizationRegions(content string) []normalizationRegion {
+	var regions []normalizationRegion
+	addMath := func(start, end int) {
+		// Prose in \text{} inside math keeps its punctuation
+		for _, span := range findMathTextSpans(content[start:end]) {
+			regions = append(regions,
+				normalizationRegion{start, start + span.start, mathNormalization},
+				normalizationRegion{start + span.start, start + span.end, proseNormalization})
+			start += span.end
+		}
+		regions = append(regions, normalizationRegion{start, end, mathNormalization})
+	}
+
+	for i := 0; i < len(content); {
+		switch content[i] {
+		case '%':
+			end := strings.IndexByte(content[i:], '\n')
+			if end == -1 {
+				end = len(content) - i
+			}
+			regions = append(regions, normalizationRegion{i, i + end, nil})
+			i += end
+			continue
+		case '$':
+			delimiter := "$"
+			if strings.HasPrefix(content[i:], "$$") {
+				delimiter = "$$"
+			}
+			start := i + len(delimiter)
+			end := findMathClose(content, start, delimiter)
+			if end == -1 {
+				i = start
+				continue
+			}
+			addMath(start, end)
+			i = end + len(delimiter)
+			continue
+		case '\\':
+			if i+1 < len(content) && (content[i+1] == '(' || content[i+1] == '[') {
+				closing := `\)`
+				if content[i+1] == '[' {
+					closing = `\]`
+				}
+				end := strings.Index(content[i+2:], closing)
+				if end == -1 {
+					i += 2
+					continue
+				}
+				addMath(i+2, i+2+end)
+				i += 2 + end + len(closing)
+				continue
+			}
+			name := commandNameAt(content, i+1)
+			if name == "" {
+				i += 2 // escaped character such as \% or \$
+				continue
+			}
+			after := i + 1 + len(name)
+			switch {
+			case name == "verb":
+				end := verbEnd(content, after)
+				regions = append(regions, normalizationRegion{i, end, nil})
+				i = end
+				continue
+			case name == "begin":
+				if env, bodyStart := mathEnvironmentAt(content, after); env != "" {
+					if end := strings.Index(content[bodyStart:], "\\end{"+env+"}"); end != -1 {
+						addMath(bodyStart, bodyStart+end)
+						i = bodyStart + end
+						continue
+					}
+				}
+			case codeArgumentCommands[name] || strings.HasPrefix(name, "cite"):
+				if open := commandArgumentStart(content, after); open != -1 {
+					if closing := findMatchingBrace(content, open); closing != -1 {
+						regions = append(regions, normalizationRegion{open + 1, closing, argumentNormalization})
+						i = closing + 1
+						continue
+					}
+				}
+			}
+			i = after
+			continue
+		}
+		i++
+	}
+	return regions
+}
+
+// findMathClose returns the position of the delimiter closing math opened before start,
+// or -1 when the paragraph ends first
+func findMathClose(content string, start int, delimiter string) int {
+	for i := start; i < len(content); i++ {
+		switch {
+		case content[i] == '\\':
+			i++
+		case strings.HasPrefix(content[i:], "\n\n"):
+			return -1
+		case strings.HasPrefix(content[i:], delimiter):
+			return i
+		}
+	}
+	return -1
+}
+
+// mathEnvironmentAt returns the math environment (mathEnvNames, starred or not) whose name
+// argument starts at pos, and the position after it
+func mathEnvironmentAt(content string, pos int) (string, int) {
+	if pos >= len(content) || content[pos] != '{' {
+		return "", pos
+	}
+	closing := strings.IndexByte(content[pos:], '}')
+	if closing == -1 {
+		return "", pos
+	}
+	env := content[pos+1 : pos+closing]
+	for _, name := range mathEnvNames {
+		if env == name || env == name+"*" {
+			return env, pos + closing + 1
+		}
+	}
+	return "", pos
+}
+
+// commandArgumentStart returns the position of the brace opening the first mandatory
+// argument of a command ending at pos, after its star and optional arguments, or -1
+func commandArgumentStart(content string, pos int) int {
+	if pos < len(content) && content[pos] == '*' {
+		pos++
+	}
+	for {
+		pos = skipSpaces(content, pos, len(content))
+		if pos >= len(content) {
+			return -1
+		}
+		switch content[pos] {
+		case '{':
+			return pos
+		case '[':
+			closing := findMatchingBracket(content, pos, len(content))
+			if closing == -1 {
+				return -1
+			}
+			pos = closing + 1
+		default:
+			return -1
+		}
+	}
+}
+
+// verbEnd returns the end of the \verb argument starting at pos
+func verbEnd(content string, pos int) int {
+	if pos < len(content) && content[pos] == '*' {
+		pos++
+	}
+	if pos >= len(content) {
+		return pos
+	}
+	end := strings.IndexByte(content[pos+1:], content[pos])
+	if end == -1 {
+		return len(content)
+	}
+	return pos + 1 + end + 1
+}
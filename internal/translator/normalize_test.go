@@ -0,0 +1,80 @@
+package translator
+
+import "testing"
+
+func TestNormalizeTranslatedChunk(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		want      string
+		wantCount int
+	}{
+		{
+			"inline math in prose",
+			"当 $f（x，y）：x \\to y$ 时，损失为 $L = a － b$。",
+			"当 $f(x,y):x \\to y$ 时，损失为 $L = a - b$。",
+			5,
+		},
+		{
+			"text in math keeps prose punctuation",
+			"其中 $p = 1 \\text{，如果 } x > 0$，否则为零。",
+			"其中 $p = 1 \\text{，如果 } x > 0$，否则为零。",
+			0,
+		},
+		{
+			"display math and environments",
+			"如下：\\[a，b\\]以及\n\\begin{equation}\nx = −1；\n\\end{equation}\n结果：$$y＝0$$",
+			"如下：\\[a,b\\]以及\n\\begin{equation}\nx = -1;\n\\end{equation}\n结果：$$y=0$$",
+			4,
+		},
+		{
+			"code arguments",
+			"使用 \\texttt{print(“hello”，x)} 和 \\href{https://example.com/a：b}{链接，说明}。",
+			"使用 \\texttt{print(\"hello\",x)} 和 \\href{https://example.com/a:b}{链接，说明}。",
+			4,
+		},
+		{
+			"minus sign in prose",
+			"温度为 −5 度，误差 ±0.1。",
+			"温度为 -5 度，误差 ±0.1。",
+			1,
+		},
+		{
+			"tie before references",
+			"见图　\\ref{fig:arch}和文献～\\cite{a}，范围为 1～5。",
+			"见图~\\ref{fig:arch}和文献~\\cite{a}，范围为 1～5。",
+			2,
+		},
+		{
+			"comments, verb and escaped dollars",
+			"价格为 \\$5，折扣 \\$1。 \\verb|a，b| % 注释：$x，y$\n下一行，$a，b$",
+			"价格为 \\$5，折扣 \\$1。 \\verb|a，b| % 注释：$x，y$\n下一行，$a,b$",
+			1,
+		},
+		{
+			"unclosed math is prose",
+			"金额为 $5，共计三项。\n\n新段落，$x$。",
+			"金额为 $5，共计三项。\n\n新段落，$x$。",
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := normalizeTranslatedChunk(tt.content)
+			if got != tt.want {
+				t.Errorf("normalizeTranslatedChunk() = %q, want %q", got, tt.want)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestNormalizeTranslatedChunkIsIdempotent(t *testing.T) {
+	once, _ := normalizeTranslatedChunk("当 $f（x）$ 时，见图　\\ref{a}，$−1$。")
+	twice, count := normalizeTranslatedChunk(once)
+	if twice != once || count != 0 {
+		t.Errorf("second pass changed %q to %q (%d replacements)", once, twice, count)
+	}
+}
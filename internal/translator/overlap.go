@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"context"
+	"strings"
+)
+
+// MaxChunkContextChars limits the text of the previous chunk sent as context with a chunk
+const MaxChunkContextChars = 600
+
+// SetChunkOverlap sets whether each chunk is sent with the last paragraph of the previous
+// chunk as read-only context, so the model sees the sentence the chunk continues. It costs
+// a few hundred input tokens per chunk; the context is not translated again.
+func (t *TranslationEngine) SetChunkOverlap(overlap bool) {
+	t.chunkOverlap = overlap
+}
+
+// precedingContext returns the context a chunk is sent with: the last paragraph of the
+// previous chunk, without comment and placeholder lines, cut to its last
+// MaxChunkContextChars bytes at a sentence or word start. It is empty when the previous
+// chunk ends with no text.
+func precedingContext(previous string) string {
+	paragraphs := paragraphBreakPattern.Split(strings.TrimRight(previous, " \t\n"), -1)
+	for i := len(paragraphs) - 1; i >= 0; i-- {
+		var lines []string
+		for _, line := range strings.Split(paragraphs[i], "\n") {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "%") {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		text := strings.Join(lines, "\n")
+		if len(text) <= MaxChunkContextChars {
+			return text
+		}
+		tail := text[len(text)-MaxChunkContextChars:]
+		if pos := lastSentenceEnd(tail[:len(tail)/2], 0); pos != -1 {
+			return strings.TrimLeft(tail[pos:], " \n")
+		}
+		if pos := strings.IndexAny(tail, " \n"); pos != -1 {
+			return tail[pos+1:]
+		}
+		return tail
+	}
+	return ""
+}
+
+// precedingContextKey is the context key of the text preceding a chunk
+type precedingContextKey struct{}
+
+// withPrecedingContext returns ctx carrying the text preceding a chunk, for its prompt
+func withPrecedingContext(ctx context.Context, text string) context.Context {
+	if text == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, precedingContextKey{}, text)
+}
+
+// precedingContextPromptSection returns the read-only context section of a chunk prompt
+func precedingContextPromptSection(ctx context.Context) string {
+	text, _ := ctx.Value(precedingContextKey{}).(string)
+	if text == "" {
+		return ""
+	}
+	return "CONTEXT, DO NOT RETRANSLATE: the text below precedes the part to translate and is translated separately. " +
+		"Use it only to understand how the part begins; never translate it or include it in your output.\n" +
+		"[CONTEXT]\n" + text + "\n[/CONTEXT]\n"
+}
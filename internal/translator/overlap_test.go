@@ -0,0 +1,90 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrecedingContext(t *testing.T) {
+	previous := "\\section{Method}\nFirst paragraph.\n\nThe model reads\nthe input. % a note\n%COMMENT_LINES_PLACEHOLDER_0%\n\n% trailing comment\n"
+	if got := precedingContext(previous); got != "The model reads\nthe input. % a note" {
+		t.Errorf("precedingContext() = %q", got)
+	}
+	if got := precedingContext("% only a comment\n"); got != "" {
+		t.Errorf("precedingContext() of a comment = %q", got)
+	}
+
+	long := strings.Repeat("This sentence is long enough to count. ", 40)
+	got := precedingContext(long)
+	if len(got) > MaxChunkContextChars || !strings.HasPrefix(got, "This sentence") || !strings.HasSuffix(got, "count.") {
+		t.Errorf("precedingContext() of a long paragraph = %q", got)
+	}
+}
+
+func TestPrecedingContextPromptSection(t *testing.T) {
+	if got := precedingContextPromptSection(context.Background()); got != "" {
+		t.Errorf("section without context = %q", got)
+	}
+	got := precedingContextPromptSection(withPrecedingContext(context.Background(), "The model reads"))
+	if !strings.Contains(got, "DO NOT RETRANSLATE") || !strings.Contains(got, "[CONTEXT]\nThe model reads\n[/CONTEXT]") {
+		t.Errorf("section = %q", got)
+	}
+}
+
+func TestTranslateWithChunkOverlap(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: strings.ReplaceAll(chunk, "works well", "效果很好")}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	content := "\\section{One}\nThe first method works well.\n\n\\section{Two}\nThe second method works well.\n"
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	engine.SetChunkSize(60)
+	engine.SetChunkOverlap(true)
+	result, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+	if strings.Count(result.TranslatedContent, "效果很好") != 2 || strings.Contains(result.TranslatedContent, "[CONTEXT]") {
+		t.Errorf("translation = %q", result.TranslatedContent)
+	}
+
+	// Only the second chunk has a previous one
+	var withContext []string
+	for _, prompt := range prompts {
+		if strings.Contains(prompt, "[CONTEXT]") {
+			withContext = append(withContext, prompt)
+		}
+	}
+	if len(withContext) != 1 || !strings.Contains(withContext[0], "The first method works well.\n[/CONTEXT]") {
+		t.Errorf("prompts = %q", prompts)
+	}
+}
@@ -0,0 +1,321 @@
+package translator
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"latex-translator/internal/logger"
+)
+
+// paragraphBreakPattern matches a paragraph break: a line break followed by one or more
+// blank lines. LaTeX treats it like \par.
+var paragraphBreakPattern = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)*`)
+
+// Costs (in sentence units) of placing a paragraph break where the translation has none.
+// They make the alignment prefer keeping the model's breaks, then line ends, then
+// breaking a line after a sentence.
+const (
+	paragraphBreakAtLineCost     = 0.25
+	paragraphBreakInsideLineCost = 0.35
+)
+
+// breakCandidate is a position in a translated chunk where a paragraph break may go
+type breakCandidate struct {
+	start, end int  // bytes replaced by the break (empty at sentence ends)
+	existing   bool // the translation already has a paragraph break here
+	lineEnd    bool // the position is a line break
+	units      int  // sentence units completed before the position
+}
+
+// CountParagraphs returns the number of paragraphs in LaTeX source, i.e. the number of
+// blank-line separated blocks that contain text.
+func CountParagraphs(content string) int {
+	count := 0
+	for _, p := range paragraphBreakPattern.Split(content, -1) {
+		if strings.TrimSpace(p) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// RestoreParagraphBreaks makes the paragraph breaks (blank lines) of a translated chunk
+// match the original. Models often drop or add blank lines, which merges paragraphs or
+// splits them mid-sentence in the typeset output even when every command is intact.
+// When the number of paragraphs differs, the original paragraph boundaries are mapped
+// onto the translation by their relative position in sentences, missing breaks are
+// inserted and spurious ones removed. Leading and trailing blank lines, which separate the
+// chunk from its neighbours, are restored as well.
+// Returns the fixed chunk and the number of breaks inserted or removed.
+func RestoreParagraphBreaks(translated, original string) (string, int) {
+	if strings.TrimSpace(translated) == "" || strings.TrimSpace(original) == "" {
+		return translated, 0
+	}
+
+	origLead, origBody, origTrail := splitOuterWhitespace(original)
+	lead, body, trail := splitOuterWhitespace(translated)
+	fixes := 0
+
+	// The line breaks at the chunk edges combine with those of the neighbouring chunks,
+	// so they must match the original exactly
+	if strings.Count(lead, "\n") != strings.Count(origLead, "\n") {
+		lead = origLead
+		fixes++
+	}
+	if strings.Count(trail, "\n") != strings.Count(origTrail, "\n") {
+		trail = origTrail
+		fixes++
+	}
+
+	origParagraphs := paragraphBreakPattern.Split(origBody, -1)
+	if len(origParagraphs) != len(paragraphBreakPattern.FindAllStringIndex(body, -1))+1 {
+		var bodyFixes int
+		body, bodyFixes = alignParagraphBreaks(body, origParagraphs)
+		fixes += bodyFixes
+	}
+
+	if fixes == 0 {
+		return translated, 0
+	}
+	logger.Debug("restored paragraph breaks",
+		logger.Int("fixes", fixes),
+		logger.Int("originalParagraphs", len(origParagraphs)))
+	return lead + body + trail, fixes
+}
+
+// alignParagraphBreaks places len(origParagraphs)-1 paragraph breaks in body so that their
+// relative positions in sentence units best match the original boundaries
+func alignParagraphBreaks(body string, origParagraphs []string) (string, int) {
+	// Cumulative sentence units at each original paragraph boundary
+	targets := make([]int, 0, len(origParagraphs)-1)
+	origTotal := 0
+	for i, p := range origParagraphs {
+		origTotal += paragraphUnits(p)
+		if i < len(origParagraphs)-1 {
+			targets = append(targets, origTotal)
+		}
+	}
+
+	candidates, total := paragraphBreakCandidates(body)
+	if len(candidates) < len(targets) || total == 0 {
+		logger.Warn("not enough sentence boundaries to restore paragraph breaks",
+			logger.Int("breaks", len(targets)),
+			logger.Int("candidates", len(candidates)))
+		return body, 0
+	}
+	scale := float64(origTotal) / float64(total)
+
+	cost := func(k, j int) float64 {
+		c := candidates[j]
+		d := float64(c.units)*scale - float64(targets[k])
+		if d < 0 {
+			d = -d
+		}
+		switch {
+		case c.existing:
+		case c.lineEnd:
+			d += paragraphBreakAtLineCost
+		default:
+			d += paragraphBreakInsideLineCost
+		}
+		return d
+	}
+
+	// best[k][j] is the minimal cost of placing breaks 0..k with break k at candidate j
+	n, m := len(targets), len(candidates)
+	best := make([][]float64, n)
+	from := make([][]int, n)
+	for k := 0; k < n; k++ {
+		best[k] = make([]float64, m)
+		from[k] = make([]int, m)
+		minPrev, minAt := math.Inf(1), -1
+		for j := 0; j < m; j++ {
+			best[k][j] = math.Inf(1)
+			if k == 0 {
+				best[k][j] = cost(k, j)
+			} else if minAt >= 0 {
+				best[k][j] = minPrev + cost(k, j)
+				from[k][j] = minAt
+			}
+			if k > 0 && best[k-1][j] < minPrev {
+				minPrev, minAt = best[k-1][j], j
+			}
+		}
+	}
+
+	chosen := make(map[int]bool, n)
+	if n > 0 {
+		j := 0
+		for i := range best[n-1] {
+			if best[n-1][i] < best[n-1][j] {
+				j = i
+			}
+		}
+		for k := n - 1; k >= 0; k-- {
+			chosen[j] = true
+			j = from[k][j]
+		}
+	}
+
+	var sb strings.Builder
+	fixes, pos := 0, 0
+	for j, c := range candidates {
+		if !c.existing && !chosen[j] {
+			continue
+		}
+		sb.WriteString(body[pos:c.start])
+		switch {
+		case c.existing && chosen[j]:
+			sb.WriteString(body[c.start:c.end])
+		case c.existing:
+			// Spurious break: join the paragraphs with a line break
+			sb.WriteString("\n")
+			fixes++
+		default:
+			sb.WriteString("\n\n")
+			fixes++
+		}
+		pos = c.end
+	}
+	sb.WriteString(body[pos:])
+	return sb.String(), fixes
+}
+
+// paragraphBreakCandidates returns the positions in body where a paragraph break may be,
+// and the total number of sentence units in body
+func paragraphBreakCandidates(body string) ([]breakCandidate, int) {
+	var candidates []breakCandidate
+	breaks := paragraphBreakPattern.FindAllStringIndex(body, -1)
+	units, pos := 0, 0
+	for i := 0; i <= len(breaks); i++ {
+		end := len(body)
+		if i < len(breaks) {
+			end = breaks[i][0]
+		}
+		paragraph := body[pos:end]
+		before := units
+		for _, b := range sentenceUnitEnds(paragraph) {
+			units++
+			if b.pos == len(paragraph) {
+				continue // the paragraph end itself
+			}
+			c := breakCandidate{start: pos + b.pos, end: pos + b.pos, units: units}
+			if b.lineEnd {
+				c.end++ // replace the line break
+				c.lineEnd = true
+			} else {
+				// Drop the spaces after the sentence
+				for c.end < end && (body[c.end] == ' ' || body[c.end] == '\t') {
+					c.end++
+				}
+				if c.end == end {
+					continue
+				}
+			}
+			candidates = append(candidates, c)
+		}
+		if units == before {
+			units++ // a paragraph counts as at least one unit
+		}
+		if i < len(breaks) {
+			candidates = append(candidates, breakCandidate{
+				start: breaks[i][0], end: breaks[i][1], existing: true, lineEnd: true, units: units,
+			})
+			pos = breaks[i][1]
+		}
+	}
+	return candidates, units
+}
+
+// paragraphUnits returns the number of sentence units in an original paragraph
+func paragraphUnits(paragraph string) int {
+	if n := len(sentenceUnitEnds(paragraph)); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// unitEnd is the end of a sentence unit within a paragraph
+type unitEnd struct {
+	pos     int  // byte offset just after the unit
+	lineEnd bool // the unit ends at a line break (pos is the offset of the '\n')
+}
+
+// sentenceUnitEnds returns the ends of the sentence units of a paragraph. A unit is a
+// sentence (ended by . ? ! or 。？！) or a line holding a command or comment such as
+// \section{...}, which forms a unit of its own. Text after the last boundary forms a
+// final unit ending at the end of the paragraph.
+func sentenceUnitEnds(paragraph string) []unitEnd {
+	var ends []unitEnd
+	lineStart := 0
+	last := 0
+	for i := 0; i < len(paragraph); {
+		r, size := utf8.DecodeRuneInString(paragraph[i:])
+		switch {
+		case r == '\n':
+			pending := strings.TrimSpace(paragraph[last:i]) != ""
+			if pending && isStructuralLine(strings.TrimSpace(paragraph[lineStart:i])) {
+				ends = append(ends, unitEnd{pos: i, lineEnd: true})
+				last = i
+			} else if !pending && len(ends) > 0 && !ends[len(ends)-1].lineEnd {
+				// A sentence ending the line: break at the line end instead
+				ends[len(ends)-1] = unitEnd{pos: i, lineEnd: true}
+				last = i
+			}
+			lineStart = i + 1
+		case r == '。' || r == '？' || r == '！':
+			end := skipClosingMarks(paragraph, i+size)
+			ends = append(ends, unitEnd{pos: end})
+			last = end
+			i = end
+			continue
+		case (r == '.' || r == '?' || r == '!') && isSentenceEnd(paragraph, i+size):
+			end := skipClosingMarks(paragraph, i+size)
+			ends = append(ends, unitEnd{pos: end})
+			last = end
+			i = end
+			continue
+		}
+		i += size
+	}
+	if strings.TrimSpace(paragraph[last:]) != "" {
+		ends = append(ends, unitEnd{pos: len(paragraph)})
+	}
+	return ends
+}
+
+// isSentenceEnd reports whether an ASCII terminator ending at pos ends a sentence,
+// i.e. it is followed by whitespace or the end of the paragraph
+func isSentenceEnd(text string, pos int) bool {
+	pos = skipClosingMarks(text, pos)
+	return pos >= len(text) || text[pos] == ' ' || text[pos] == '\t' || text[pos] == '\n'
+}
+
+// skipClosingMarks skips closing quotes and brackets after a sentence terminator
+func skipClosingMarks(text string, pos int) int {
+	for pos < len(text) {
+		r, size := utf8.DecodeRuneInString(text[pos:])
+		if !strings.ContainsRune(`"')]”’」』）`, r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// isStructuralLine reports whether a line is a command or comment line that forms a unit
+// on its own, such as \section{...}, \label{...} or % comment
+func isStructuralLine(line string) bool {
+	return strings.HasPrefix(line, "\\") || strings.HasPrefix(line, "%")
+}
+
+// splitOuterWhitespace splits s into its leading whitespace, body and trailing whitespace
+func splitOuterWhitespace(s string) (lead, body, trail string) {
+	body = strings.TrimLeft(s, " \t\r\n")
+	lead = s[:len(s)-len(body)]
+	trimmed := strings.TrimRight(body, " \t\r\n")
+	trail = body[len(trimmed):]
+	return lead, trimmed, trail
+}
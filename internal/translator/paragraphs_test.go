@@ -0,0 +1,162 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentencePattern matches the fixture sentences
+var sentencePattern = regexp.MustCompile(`Sentence (\d+) of paragraph (\d+)\.`)
+
+// buildParagraphDocument builds a document of n paragraphs with a few sentences each,
+// some wrapped over several lines and some starting with a section heading
+func buildParagraphDocument(n int) string {
+	var sb strings.Builder
+	for p := 1; p <= n; p++ {
+		if p%20 == 1 {
+			sb.WriteString(fmt.Sprintf("\\section{Part %d}\n", p/20+1))
+		}
+		sentences := p%3 + 2
+		for s := 1; s <= sentences; s++ {
+			sb.WriteString(fmt.Sprintf("Sentence %d of paragraph %d.", s, p))
+			switch {
+			case s == sentences:
+			case s == 2:
+				sb.WriteString("\n")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// mangleParagraphs translates the fixture sentences the way a careless model does:
+// the break before every fifth paragraph is dropped, every seventh paragraph is split
+// after its first sentence, and trailing blank lines are lost
+func mangleParagraphs(content string) string {
+	paragraphs := paragraphBreakPattern.Split(strings.TrimSpace(content), -1)
+	var sb strings.Builder
+	for i, p := range paragraphs {
+		number := 0
+		if m := sentencePattern.FindStringSubmatch(p); m != nil {
+			number, _ = strconv.Atoi(m[2])
+		}
+		if number%7 == 0 {
+			p = strings.Replace(p, ". ", ".\n\n", 1)
+		}
+		if i > 0 {
+			if number%5 == 0 {
+				sb.WriteString(" ")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(p)
+	}
+	return sentencePattern.ReplaceAllString(sb.String(), "第${2}段第${1}句。")
+}
+
+func TestRestoreParagraphBreaksMergedParagraphs(t *testing.T) {
+	original := "First sentence. Second sentence.\n\nThird sentence. Fourth sentence.\n\nFifth sentence.\n"
+	translated := "第一句。第二句。第三句。第四句。\n第五句。"
+
+	fixed, fixes := RestoreParagraphBreaks(translated, original)
+	want := "第一句。第二句。\n\n第三句。第四句。\n\n第五句。\n"
+	if fixed != want {
+		t.Errorf("got %q, want %q", fixed, want)
+	}
+	if fixes != 3 {
+		t.Errorf("fixes = %d, want 3 (two breaks and the trailing line break)", fixes)
+	}
+}
+
+func TestRestoreParagraphBreaksSpuriousBreak(t *testing.T) {
+	original := "\\section{Intro}\nFirst sentence. Second sentence.\nThird sentence.\n\nFourth sentence."
+	translated := "\\section{引言}\n第一句。\n\n第二句。\n第三句。\n\n第四句。"
+
+	fixed, fixes := RestoreParagraphBreaks(translated, original)
+	want := "\\section{引言}\n第一句。\n第二句。\n第三句。\n\n第四句。"
+	if fixed != want {
+		t.Errorf("got %q, want %q", fixed, want)
+	}
+	if fixes != 1 {
+		t.Errorf("fixes = %d, want 1", fixes)
+	}
+}
+
+func TestRestoreParagraphBreaksHeadingParagraph(t *testing.T) {
+	original := "\\section{Method}\n\nWe propose a method. It works well.\n"
+	translated := "\\section{方法}\n我们提出一种方法。它效果很好。\n"
+
+	fixed, _ := RestoreParagraphBreaks(translated, original)
+	want := "\\section{方法}\n\n我们提出一种方法。它效果很好。\n"
+	if fixed != want {
+		t.Errorf("got %q, want %q", fixed, want)
+	}
+}
+
+func TestRestoreParagraphBreaksUnchanged(t *testing.T) {
+	original := "One. Two.\n\nThree.\n\n"
+	translated := "一。二。\n\n三。\n\n"
+	if fixed, fixes := RestoreParagraphBreaks(translated, original); fixed != translated || fixes != 0 {
+		t.Errorf("correct translation changed: %q (%d fixes)", fixed, fixes)
+	}
+}
+
+func TestTranslationPreservesParagraphCount(t *testing.T) {
+	content := buildParagraphDocument(120)
+	if got := CountParagraphs(content); got != 120 {
+		t.Fatalf("fixture has %d paragraphs, want 120", got)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: mangleParagraphs(chunk)}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 2)
+	result, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+
+	if got := CountParagraphs(result.TranslatedContent); got != 120 {
+		t.Errorf("translated tex has %d paragraphs, want 120", got)
+	}
+	if result.ParagraphBreakFixes == 0 {
+		t.Error("expected paragraph break corrections to be reported")
+	}
+
+	// Every paragraph keeps its own sentences
+	for i, p := range paragraphBreakPattern.Split(strings.TrimSpace(result.TranslatedContent), -1) {
+		if !strings.Contains(p, fmt.Sprintf("第%d段", i+1)) || strings.Contains(p, fmt.Sprintf("第%d段", i+2)) {
+			t.Errorf("paragraph %d has the wrong sentences: %q", i+1, p)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
 )
 
 // PostprocessChunk performs post-processing on a translated chunk by comparing
@@ -161,7 +162,9 @@ func fixLineStructure(content string) string {
 // FixIncompleteTabularColumnSpec fixes tabular column specs that are missing the closing brace.
 // This handles cases like: \begin{tabular}{@{}l cccc@{}\n\toprule
 // where the column spec should be {@{}l cccc@{}} but is missing the final }
+// Verbatim-like environments are left alone.
 func FixIncompleteTabularColumnSpec(content string) string {
+	content, restore := parser.ProtectVerbatim(content)
 	// Find all \begin{tabular} (and variants) patterns
 	tabularPattern := regexp.MustCompile(`\\begin\{(tabular|tabularx|longtable|array)\*?\}\{`)
 	
@@ -212,7 +215,7 @@ func FixIncompleteTabularColumnSpec(content string) string {
 		}
 	}
 	
-	return result
+	return restore(result)
 }
 
 // fixEnvironmentsByReference fixes environment issues by comparing with original.
@@ -2731,26 +2734,26 @@ func ensureCommandsOnSeparateLines(content string) string {
 
 	// Pattern: text\begin{env} -> text\n\begin{env}
 	// Match any non-whitespace character followed by \begin{
-	beginPattern := regexp.MustCompile(`([^\n\s])\s*(\\begin\{[^}]+\})`)
+	beginPattern := regexp.MustCompile(`([^\n\s])[ \t]*(\\begin\{[^}]+\})`)
 	result = beginPattern.ReplaceAllString(result, "$1\n$2")
 
 	// Pattern: \begin{env}text -> \begin{env}\ntext
 	// Match \begin{env} followed by non-whitespace (except [ for optional args)
-	beginFollowedPattern := regexp.MustCompile(`(\\begin\{[^}]+\}(?:\[[^\]]*\])?)\s*([^\n\s\[\\])`)
+	beginFollowedPattern := regexp.MustCompile(`(\\begin\{[^}]+\}(?:\[[^\]]*\])?)[ \t]*([^\n\s\[\\])`)
 	result = beginFollowedPattern.ReplaceAllString(result, "$1\n$2")
 
 	// Pattern: text\end{env} -> text\n\end{env}
-	endPattern := regexp.MustCompile(`([^\n\s])\s*(\\end\{[^}]+\})`)
+	endPattern := regexp.MustCompile(`([^\n\s])[ \t]*(\\end\{[^}]+\})`)
 	result = endPattern.ReplaceAllString(result, "$1\n$2")
 
 	// Pattern: \end{env}text -> \end{env}\ntext
 	// Match \end{env} followed by non-whitespace (except another \)
-	endFollowedPattern := regexp.MustCompile(`(\\end\{[^}]+\})\s*([^\n\s\\])`)
+	endFollowedPattern := regexp.MustCompile(`(\\end\{[^}]+\})[ \t]*([^\n\s\\])`)
 	result = endFollowedPattern.ReplaceAllString(result, "$1\n$2")
 
 	// Pattern: text\item -> text\n\item
 	// But be careful not to break \item that's already at line start
-	itemPattern := regexp.MustCompile(`([^\n\s])\s*(\\item\b)`)
+	itemPattern := regexp.MustCompile(`([^\n\s])[ \t]*(\\item\b)`)
 	result = itemPattern.ReplaceAllString(result, "$1\n$2")
 
 	// Pattern: \item text\item -> \item text\n\item
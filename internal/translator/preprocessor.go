@@ -839,26 +839,7 @@ type MathPlaceholder struct {
 func ProtectMathEnvironments(content string) (string, map[string]string) {
 	placeholders := make(map[string]string)
 	
-	// Collect all math regions with their positions
-	var mathRegions []MathPlaceholder
-	
-	// 1. Find math environments first (highest priority - they can contain other patterns)
-	mathRegions = append(mathRegions, findMathEnvironmentRegions(content)...)
-	
-	// 2. Find display math $$...$$ (before inline $...$)
-	mathRegions = append(mathRegions, findDoubleDollarRegions(content)...)
-	
-	// 3. Find bracket math \[...\]
-	mathRegions = append(mathRegions, findBracketMathRegions(content)...)
-	
-	// 4. Find parenthesis math \(...\)
-	mathRegions = append(mathRegions, findParenMathRegions(content)...)
-	
-	// 5. Find inline math $...$ (lowest priority)
-	mathRegions = append(mathRegions, findSingleDollarRegions(content)...)
-	
-	// Remove overlapping regions (keep the first/larger one)
-	mathRegions = removeOverlappingMathRegions(mathRegions)
+	mathRegions := collectMathRegions(content)
 	
 	// Sort by position (descending) for safe replacement
 	sort.Slice(mathRegions, func(i, j int) bool {
@@ -881,6 +862,29 @@ func ProtectMathEnvironments(content string) (string, map[string]string) {
 	return result, placeholders
 }
 
+// collectMathRegions finds all non-overlapping math regions in content, sorted by position
+func collectMathRegions(content string) []MathPlaceholder {
+	var mathRegions []MathPlaceholder
+	
+	// 1. Find math environments first (highest priority - they can contain other patterns)
+	mathRegions = append(mathRegions, findMathEnvironmentRegions(content)...)
+	
+	// 2. Find display math $$...$$ (before inline $...$)
+	mathRegions = append(mathRegions, findDoubleDollarRegions(content)...)
+	
+	// 3. Find bracket math \[...\]
+	mathRegions = append(mathRegions, findBracketMathRegions(content)...)
+	
+	// 4. Find parenthesis math \(...\)
+	mathRegions = append(mathRegions, findParenMathRegions(content)...)
+	
+	// 5. Find inline math $...$ (lowest priority)
+	mathRegions = append(mathRegions, findSingleDollarRegions(content)...)
+	
+	// Remove overlapping regions (keep the first/larger one), sorted by position
+	return removeOverlappingMathRegions(mathRegions)
+}
+
 // findMathEnvironmentRegions finds all named math environments
 func findMathEnvironmentRegions(content string) []MathPlaceholder {
 	var regions []MathPlaceholder
@@ -0,0 +1,121 @@
+package translator
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// chunkPreviewEdgeLength is the number of characters shown from the start and end of each chunk
+const chunkPreviewEdgeLength = 80
+
+// PreviewChunks shows how content will be split for translation without calling the model.
+// It runs the same preparation and chunking as TranslateTeXWithProgress (data blob, comment,
+// reference list and \title protection followed by splitIntoChunks and coalesceChunks), so
+// the preview matches the chunks actually sent with the default settings (comment lines kept
+// out of the chunks). Byte ranges are relative to the content after protection, i.e. with
+// data blobs, comment environments, reference lists, titles and runs of comment lines
+// replaced by their placeholders.
+func PreviewChunks(content string) ([]types.ChunkPreview, []types.DataBlob) {
+	if content == "" {
+		return nil, nil
+	}
+
+	// Titles are kept as they are; the placeholder replacing them does not depend on the translation
+	plan := planChunks(content, MaxChunkSize, true, false, func(fragment string) (string, error) {
+		return fragment, nil
+	})
+	boundaries := findEnvironmentBoundaries(plan.prepared)
+
+	previews := make([]types.ChunkPreview, 0, len(plan.chunks))
+	pos := 0
+	for i, chunk := range plan.chunks {
+		start := pos
+		if start+len(chunk) > len(plan.prepared) || plan.prepared[start:start+len(chunk)] != chunk {
+			// Chunks normally concatenate to the prepared content; fall back to searching
+			logger.Warn("chunk does not continue at the previous chunk end", logger.Int("chunkIndex", i+1))
+			start = indexFrom(plan.prepared, chunk, pos)
+		}
+		end := start + len(chunk)
+		pos = end
+
+		previews = append(previews, types.ChunkPreview{
+			Index:           i + 1,
+			Start:           start,
+			End:             end,
+			Head:            firstChars(chunk, chunkPreviewEdgeLength),
+			Tail:            lastChars(chunk, chunkPreviewEdgeLength),
+			Environments:    environmentsInRange(boundaries, start, end),
+			EstimatedTokens: EstimateTokens(chunk),
+			Section:         plan.sections[i],
+		})
+	}
+	return previews, plan.skippedBlobs
+}
+
+// EstimateTokens roughly estimates the number of tokens of text without a tokenizer:
+// about 4 bytes per token for English and LaTeX, one token per non-ASCII character.
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// environmentsInRange returns the names of protected environments starting within [start, end),
+// in order of appearance and without duplicates
+func environmentsInRange(boundaries []environmentBoundary, start, end int) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, b := range boundaries {
+		if b.start < start || b.start >= end || seen[b.envName] {
+			continue
+		}
+		seen[b.envName] = true
+		names = append(names, b.envName)
+	}
+	return names
+}
+
+// indexFrom returns the position of substr in s at or after from, or from if not found
+func indexFrom(s, substr string, from int) int {
+	if from > len(s) {
+		return len(s)
+	}
+	if idx := strings.Index(s[from:], substr); idx != -1 {
+		return from + idx
+	}
+	return from
+}
+
+// firstChars returns the first n characters of s
+func firstChars(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+// lastChars returns the last n characters of s
+func lastChars(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	i := len(s)
+	for count := 0; count < n; count++ {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return s[i:]
+}
@@ -0,0 +1,110 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildPreviewDocument builds a document large enough to be split into several chunks
+func buildPreviewDocument() string {
+	var sb strings.Builder
+	sb.WriteString("\\documentclass{article}\n\\begin{document}\n")
+	for s := 1; s <= 4; s++ {
+		sb.WriteString(fmt.Sprintf("\\section{Section %d}\n", s))
+		for p := 0; p < 12; p++ {
+			sb.WriteString("This paragraph describes the proposed method and its evaluation in detail. ")
+			sb.WriteString("We report results on several benchmarks and discuss limitations.\n\n")
+		}
+		sb.WriteString("\\begin{table}[t]\n\\begin{tabular}{cc}\na & b \\\\\n\\end{tabular}\n\\caption{Results.}\n\\end{table}\n\n")
+	}
+	sb.WriteString("\\end{document}\n")
+	return sb.String()
+}
+
+func TestPreviewChunksCoversContent(t *testing.T) {
+	content := buildPreviewDocument()
+	previews, _ := PreviewChunks(content)
+	plan := planChunks(content, MaxChunkSize, true, false, func(s string) (string, error) { return s, nil })
+
+	if len(previews) < 2 || len(previews) != len(plan.chunks) {
+		t.Fatalf("expected %d chunks (>1), got %d", len(plan.chunks), len(previews))
+	}
+	pos := 0
+	for i, p := range previews {
+		if p.Start != pos {
+			t.Errorf("chunk %d starts at %d, want %d", p.Index, p.Start, pos)
+		}
+		if plan.prepared[p.Start:p.End] != plan.chunks[i] {
+			t.Errorf("chunk %d byte range does not match the chunk content", p.Index)
+		}
+		if !strings.HasPrefix(plan.chunks[i], p.Head) || !strings.HasSuffix(plan.chunks[i], p.Tail) {
+			t.Errorf("chunk %d head/tail do not match", p.Index)
+		}
+		if p.EstimatedTokens <= 0 {
+			t.Errorf("chunk %d has no token estimate", p.Index)
+		}
+		pos = p.End
+	}
+	if pos != len(plan.prepared) {
+		t.Errorf("chunks cover %d bytes, want %d", pos, len(plan.prepared))
+	}
+
+	found := false
+	for _, p := range previews {
+		for _, env := range p.Environments {
+			if env == "table" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("expected table environments to be reported")
+	}
+}
+
+func TestPreviewChunksMatchesTranslation(t *testing.T) {
+	content := buildPreviewDocument()
+	previews, _ := PreviewChunks(content)
+
+	// Record the chunks actually sent to the model. The stub echoes the prompt, so no
+	// answer looks truncated and gets its chunk split.
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		prompt := "你好世界"
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil && len(req.Messages) > 0 {
+			prompt = req.Messages[len(req.Messages)-1].Content
+			mu.Lock()
+			sent = append(sent, prompt)
+			mu.Unlock()
+		}
+		body, _ := json.Marshal(ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: prompt}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	// The stub translation fails validation; only the requests matter here
+	engine.TranslateTeX(content)
+
+	// Float captions are translated as requests of their own before the chunks
+	captionRequests := 0
+	TranslateCaptionsInFloats(content, func(s string) (string, error) {
+		captionRequests++
+		return s, nil
+	})
+	if len(sent) != len(previews)+captionRequests {
+		t.Fatalf("translation sent %d requests, preview showed %d chunks and %d captions", len(sent), len(previews), captionRequests)
+	}
+}
@@ -0,0 +1,62 @@
+package translator
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTranslateTeXReportsChunkProgress(t *testing.T) {
+	var broken atomic.Bool
+	server := failingChunkServer(t, &broken)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 3)
+	engine.SetChunkSize(200)
+
+	var mu sync.Mutex
+	var reports []ChunkProgress
+	result, err := engine.TranslateTeXWithProgressContext(t.Context(), failedChunkFixture, func(p ChunkProgress) {
+		mu.Lock()
+		reports = append(reports, p)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("TranslateTeXWithProgressContext() error: %v", err)
+	}
+	if len(reports) == 0 {
+		t.Fatal("no progress reported")
+	}
+	maxChunk, withSection := 0, false
+	for _, p := range reports {
+		if p.TotalChunks != 4 {
+			t.Errorf("TotalChunks = %d, want 4", p.TotalChunks)
+		}
+		if p.Chunk > maxChunk {
+			maxChunk = p.Chunk
+		}
+		withSection = withSection || p.Section != ""
+	}
+	if maxChunk != 4 || !withSection {
+		t.Errorf("reports = %+v", reports)
+	}
+	if last := reports[len(reports)-1]; last.TokensUsed == 0 || last.TokensUsed > result.TokensUsed {
+		t.Errorf("TokensUsed = %d, result used %d", last.TokensUsed, result.TokensUsed)
+	}
+
+	// The old callback still gets the chunk counts
+	var calls atomic.Int32
+	legacy := TranslationProgressCallback(func(current, total int, message string) {
+		if total == 4 && message != "" {
+			calls.Add(1)
+		}
+	})
+	if _, err := engine.TranslateTeXWithProgress(failedChunkFixture, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() == 0 {
+		t.Error("TranslationProgressCallback not called")
+	}
+	if TranslationProgressCallback(nil).ProgressFunc() != nil {
+		t.Error("nil callback adapted to a non-nil ProgressFunc")
+	}
+}
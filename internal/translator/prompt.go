@@ -0,0 +1,245 @@
+package translator
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/types"
+)
+
+//go:embed prompts/default.tmpl
+var defaultPromptTemplateText string
+
+// Prompt template placeholders; see docs/PROMPT_TEMPLATES.md
+const (
+	promptUserMarker              = "{{user}}"
+	promptLanguagePlaceholder     = "{{language}}"
+	promptGlossaryPlaceholder     = "{{glossary}}"
+	promptChunkPlaceholder        = "{{chunk}}"
+	promptInstructionsPlaceholder = "{{instructions}}"
+)
+
+// Prompt presets; a template loaded from a user file is reported as PromptPresetCustom
+const (
+	PromptPresetDefault = "default"
+	PromptPresetMath    = "math"
+	PromptPresetCS      = "cs"
+	PromptPresetBiomed  = "biomed"
+	PromptPresetCustom  = "custom"
+)
+
+// promptDomainGuidance is appended to the default system prompt by the domain presets
+var promptDomainGuidance = map[string]string{
+	PromptPresetMath: `
+
+## DOMAIN: MATHEMATICS
+- Translate theorem-like labels with their standard terms (Theorem 定理, Lemma 引理, Corollary 推论, Proposition 命题, Definition 定义, Proof 证明)
+- Use the established mathematical terminology of the target language (e.g. "manifold" 流形, "sheaf" 层, "ring" 环)
+- Keep the names of theorems, conjectures and mathematicians in their usual written form
+- Never touch variables, symbols or formulas mentioned in the prose`,
+	PromptPresetCS: `
+
+## DOMAIN: COMPUTER SCIENCE
+- Use the established terminology of computer science and machine learning (e.g. "attention" 注意力, "fine-tuning" 微调, "throughput" 吞吐量)
+- Keep the names of algorithms, models, datasets, systems, libraries and APIs in English (e.g. Transformer, ImageNet, PyTorch)
+- Keep identifiers, function names, file names and commands exactly as written`,
+	PromptPresetBiomed: `
+
+## DOMAIN: BIOMEDICINE
+- Use the established biomedical terminology of the target language (e.g. "cohort" 队列, "placebo" 安慰剂, "knockout" 敲除)
+- Keep gene and protein symbols, drug names, strain names and species names in Latin or English as written (e.g. TP53, BRCA1, E. coli)
+- Keep statistical notation (p-values, CI, OR, HR) and units exactly as written`,
+}
+
+// PromptPresets returns the names of the built-in prompt presets, the default first
+func PromptPresets() []string {
+	presets := []string{PromptPresetDefault}
+	var domains []string
+	for name := range promptDomainGuidance {
+		domains = append(domains, name)
+	}
+	sort.Strings(domains)
+	return append(presets, domains...)
+}
+
+// PromptTemplate generates the prompts of the chunk requests: a system prompt and a user
+// message with the language, glossary, instructions and chunk placeholders
+type PromptTemplate struct {
+	// Name is the preset, or PromptPresetCustom for a template loaded from a file
+	Name string
+	// Path is the file of a custom template
+	Path string
+
+	system string
+	user   string
+}
+
+// defaultPromptTemplate returns the built-in template
+func defaultPromptTemplate() *PromptTemplate {
+	tpl, err := parsePromptTemplate(PromptPresetDefault, defaultPromptTemplateText)
+	if err != nil {
+		panic("invalid built-in prompt template: " + err.Error())
+	}
+	return tpl
+}
+
+// PromptPresetTemplate returns the template of a built-in preset ("" is the default one)
+func PromptPresetTemplate(preset string) (*PromptTemplate, error) {
+	tpl := defaultPromptTemplate()
+	if preset == "" || preset == PromptPresetDefault {
+		return tpl, nil
+	}
+	guidance, ok := promptDomainGuidance[preset]
+	if !ok {
+		return tpl, types.NewAppError(types.ErrInvalidInput,
+			fmt.Sprintf("未知的提示词预设: %s（可用: %s）", preset, strings.Join(PromptPresets(), ", ")), nil)
+	}
+	tpl.Name = preset
+	tpl.system += guidance
+	return tpl, nil
+}
+
+// LoadPromptTemplate returns the template of a translation: the user's file when path is
+// set, the preset otherwise. The file is validated here, so a template that cannot be read
+// or lacks a required placeholder fails before any request. The returned template is
+// never nil: on an error it is the default one and the error says why, for a warning.
+func LoadPromptTemplate(preset, path string) (*PromptTemplate, error) {
+	if path == "" {
+		return PromptPresetTemplate(preset)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaultPromptTemplate(), types.NewAppError(types.ErrFileNotFound,
+			fmt.Sprintf("无法读取提示词模板: %s，使用默认模板", path), err)
+	}
+	tpl, err := parsePromptTemplate(PromptPresetCustom, strings.TrimPrefix(string(data), "\ufeff"))
+	if err != nil {
+		return defaultPromptTemplate(), types.NewAppErrorWithDetails(types.ErrInvalidInput,
+			fmt.Sprintf("提示词模板无效: %s，使用默认模板", path), err.Error(), err)
+	}
+	tpl.Path = path
+	return tpl, nil
+}
+
+// parsePromptTemplate splits a template at its {{user}} line and checks its placeholders.
+// Without a {{user}} line the whole template is the user message and the system prompt is
+// the default one.
+func parsePromptTemplate(name, text string) (*PromptTemplate, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	tpl := &PromptTemplate{Name: name}
+	if before, after, ok := strings.Cut(text, "\n"+promptUserMarker+"\n"); ok {
+		tpl.system, tpl.user = before, after
+	} else {
+		tpl.system, tpl.user = defaultPromptTemplate().system, text
+	}
+	tpl.user = strings.TrimSuffix(tpl.user, "\n")
+
+	var missing []string
+	for _, placeholder := range []string{promptLanguagePlaceholder, promptGlossaryPlaceholder, promptChunkPlaceholder} {
+		if !strings.Contains(tpl.system+tpl.user, placeholder) {
+			missing = append(missing, placeholder)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing placeholders %s", strings.Join(missing, ", "))
+	}
+	if !strings.Contains(tpl.user, promptChunkPlaceholder) {
+		return nil, fmt.Errorf("%s must be in the user message, after the %s line", promptChunkPlaceholder, promptUserMarker)
+	}
+	return tpl, nil
+}
+
+// renderSystem returns the system prompt for the target language
+func (p *PromptTemplate) renderSystem(lang types.TargetLanguage) string {
+	return p.render(p.system, lang, "", 0, "")
+}
+
+// renderUser returns the user message of a chunk with placeholderCount protected commands;
+// glossary is the glossary section of the chunk or ""
+func (p *PromptTemplate) renderUser(lang types.TargetLanguage, glossary, chunk string, placeholderCount int) string {
+	return p.render(p.user, lang, glossary, placeholderCount, chunk)
+}
+
+// render replaces the placeholders of a template part. The chunk goes last, so
+// placeholders written in the chunk itself are left alone.
+func (p *PromptTemplate) render(text string, lang types.TargetLanguage, glossary string, placeholderCount int, chunk string) string {
+	if glossary != "" {
+		glossary += "\n"
+	}
+	text = strings.NewReplacer(
+		promptLanguagePlaceholder, lang.EnglishName(),
+		promptGlossaryPlaceholder, glossary,
+		promptInstructionsPlaceholder, placeholderInstructions(placeholderCount),
+	).Replace(text)
+	return strings.Replace(text, promptChunkPlaceholder, chunk, 1)
+}
+
+// placeholderInstructions tells the model how to handle the placeholders of a chunk
+func placeholderInstructions(placeholderCount int) string {
+	if placeholderCount == 0 {
+		return "Keep the same line structure."
+	}
+	return fmt.Sprintf(`This text contains %d placeholders (<<<LATEX_CMD_N>>> format).
+
+CRITICAL: Copy every placeholder EXACTLY as shown. Do not modify any placeholder.
+
+Example of correct handling:
+- Input: "The equation <<<LATEX_CMD_0>>> shows that..."
+- Output: "方程 <<<LATEX_CMD_0>>> 表明..."
+
+Now translate:`, placeholderCount)
+}
+
+// Hash returns a short hash identifying the template. It changes whenever the prompt
+// wording changes, so translated outputs can be traced back to the prompts that produced them.
+func (p *PromptTemplate) Hash() string {
+	templates := p.renderSystem(types.LanguageChinese) + "\n" +
+		p.renderUser(types.LanguageChinese, "", "", 0) + "\n" +
+		p.renderUser(types.LanguageChinese, "", "", 1)
+	hash := sha256.Sum256([]byte(templates))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// PromptTemplateHash returns the hash of the default prompt template
+func PromptTemplateHash() string {
+	return defaultPromptTemplate().Hash()
+}
+
+// SetPromptTemplate sets the template of the following chunk requests; nil restores the
+// default one
+func (t *TranslationEngine) SetPromptTemplate(tpl *PromptTemplate) {
+	t.promptTemplate = tpl
+}
+
+// PromptTemplate returns the template of the chunk requests
+func (t *TranslationEngine) PromptTemplate() *PromptTemplate {
+	if t.promptTemplate == nil {
+		return defaultPromptTemplate()
+	}
+	return t.promptTemplate
+}
+
+// promptCacheKey returns the part of the chunk cache key that identifies the prompt
+// template: empty for the default one, so caches written before templates stay valid
+func (t *TranslationEngine) promptCacheKey() string {
+	if t.promptTemplate == nil || t.promptTemplate.Hash() == PromptTemplateHash() {
+		return ""
+	}
+	return t.promptTemplate.Hash()
+}
+
+// buildSystemPromptWithProtection returns the default system prompt for protected
+// translation, used when LaTeX commands have been replaced with placeholders
+func buildSystemPromptWithProtection() string {
+	return defaultPromptTemplate().renderSystem(types.LanguageChinese)
+}
+
+// buildUserPromptWithProtection returns the default user prompt of protected translation
+func buildUserPromptWithProtection(content string, placeholderCount int) string {
+	return defaultPromptTemplate().renderUser(types.LanguageChinese, "", content, placeholderCount)
+}
@@ -0,0 +1,143 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestDefaultPromptTemplate(t *testing.T) {
+	tpl := defaultPromptTemplate()
+	if got := tpl.renderUser(types.LanguageChinese, "", "Hello.", 0); got != "Translate to Chinese. Keep the same line structure.\n\nHello." {
+		t.Errorf("user prompt without placeholders = %q", got)
+	}
+	withPlaceholders := tpl.renderUser(types.LanguageJapanese, "GLOSSARY:\n- a -> b\n", "<<<LATEX_CMD_0>>> Hello.", 1)
+	if !strings.HasPrefix(withPlaceholders, "GLOSSARY:\n- a -> b\n\nTranslate to Japanese. This text contains 1 placeholders") ||
+		!strings.HasSuffix(withPlaceholders, "Now translate:\n\n<<<LATEX_CMD_0>>> Hello.") {
+		t.Errorf("user prompt with placeholders = %q", withPlaceholders)
+	}
+	system := tpl.renderSystem(types.LanguageChinese)
+	if !strings.HasPrefix(system, "You are a STRICT LaTeX document translator.") || strings.Contains(system, "{{") {
+		t.Errorf("system prompt = %q", system)
+	}
+	if tpl.Name != PromptPresetDefault || tpl.Hash() != PromptTemplateHash() {
+		t.Errorf("default template = %s %s", tpl.Name, tpl.Hash())
+	}
+}
+
+func TestPromptPresetTemplate(t *testing.T) {
+	if got := PromptPresets(); strings.Join(got, ",") != "default,biomed,cs,math" {
+		t.Errorf("PromptPresets() = %v", got)
+	}
+	hashes := map[string]string{}
+	for _, preset := range PromptPresets() {
+		tpl, err := PromptPresetTemplate(preset)
+		if err != nil || tpl.Name != preset {
+			t.Fatalf("PromptPresetTemplate(%q) = %v, %v", preset, tpl, err)
+		}
+		if other, ok := hashes[tpl.Hash()]; ok {
+			t.Errorf("presets %s and %s have the same hash", preset, other)
+		}
+		hashes[tpl.Hash()] = preset
+	}
+	math, _ := PromptPresetTemplate(PromptPresetMath)
+	if !strings.Contains(math.renderSystem(types.LanguageChinese), "## DOMAIN: MATHEMATICS") {
+		t.Error("math preset lacks its domain guidance")
+	}
+
+	tpl, err := PromptPresetTemplate("physics")
+	if err == nil || tpl == nil || tpl.Name != PromptPresetDefault {
+		t.Errorf("unknown preset = %v, %v; want the default template and an error", tpl, err)
+	}
+}
+
+func TestLoadPromptTemplate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	full := write("full.tmpl", "You translate physics papers into {{language}}.\r\n{{user}}\r\n{{glossary}}Translate:\r\n\r\n{{chunk}}\r\n")
+	tpl, err := LoadPromptTemplate(PromptPresetMath, full)
+	if err != nil || tpl.Name != PromptPresetCustom || tpl.Path != full {
+		t.Fatalf("LoadPromptTemplate() = %+v, %v", tpl, err)
+	}
+	if got := tpl.renderSystem(types.LanguageKorean); got != "You translate physics papers into Korean." {
+		t.Errorf("system prompt = %q", got)
+	}
+	if got := tpl.renderUser(types.LanguageKorean, "", "Hello {{language}}.", 0); got != "Translate:\n\nHello {{language}}." {
+		t.Errorf("user prompt = %q", got)
+	}
+
+	userOnly := write("user.tmpl", "{{glossary}}Please translate into {{language}}, keeping every line.\n\n{{chunk}}")
+	if tpl, err := LoadPromptTemplate("", userOnly); err != nil || tpl.system != defaultPromptTemplate().system {
+		t.Errorf("template without {{user}} = %+v, %v; want the default system prompt", tpl, err)
+	}
+
+	for name, content := range map[string]string{
+		"missing.tmpl":   "Translate into {{language}}:\n\n{{chunk}}",
+		"misplaced.tmpl": "Translate {{chunk}} into {{language}}.\n{{user}}\n{{glossary}}",
+	} {
+		tpl, err := LoadPromptTemplate(PromptPresetCS, write(name, content))
+		if err == nil || tpl.Name != PromptPresetDefault {
+			t.Errorf("%s: LoadPromptTemplate() = %+v, %v; want the default template and an error", name, tpl, err)
+		}
+	}
+	if tpl, err := LoadPromptTemplate("", filepath.Join(dir, "absent.tmpl")); err == nil || tpl.Name != PromptPresetDefault {
+		t.Errorf("absent file: LoadPromptTemplate() = %+v, %v", tpl, err)
+	}
+}
+
+func TestTranslateWithPromptTemplate(t *testing.T) {
+	var systemPrompts, userPrompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		systemPrompts = append(systemPrompts, req.Messages[0].Content)
+		userPrompts = append(userPrompts, req.Messages[1].Content)
+		_, chunk, _ := strings.Cut(req.Messages[1].Content, "CHUNK:\n")
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: strings.ReplaceAll(chunk, "The method works well.", "该方法效果很好。")}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	tpl, err := parsePromptTemplate(PromptPresetCustom, "Translate to {{language}}. Be brief.\n{{user}}\n{{glossary}}CHUNK:\n{{chunk}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	engine.SetPromptTemplate(tpl)
+	result, err := engine.TranslateTeX("The method works well.\n")
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+	if !strings.Contains(result.TranslatedContent, "该方法效果很好。") || result.PromptPreset != PromptPresetCustom {
+		t.Errorf("result = %q, preset %q", result.TranslatedContent, result.PromptPreset)
+	}
+	if len(systemPrompts) != 1 || systemPrompts[0] != "Translate to Chinese. Be brief." || userPrompts[0] != "CHUNK:\nThe method works well.\n" {
+		t.Errorf("prompts = %q, %q", systemPrompts, userPrompts)
+	}
+
+	engine.SetPromptTemplate(nil)
+	if engine.PromptTemplate().Name != PromptPresetDefault || engine.promptCacheKey() != "" {
+		t.Error("engine without a template does not use the default one")
+	}
+}
@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"context"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// Prompt is one request to a chat model: the system prompt and the conversation so far
+type Prompt struct {
+	System    string
+	Messages  []Message
+	MaxTokens int // output limit; 0 leaves it to the provider
+}
+
+// LLMClient sends prompts to the chat model of a provider. TranslationEngine implements it
+// for the provider of its settings, so the syntax validator and the compile fixer share the
+// request path of the translations.
+type LLMClient interface {
+	// Complete returns the answer of the model to prompt and the tokens it used
+	Complete(ctx context.Context, prompt Prompt) (string, Usage, error)
+}
+
+var _ LLMClient = (*TranslationEngine)(nil)
+
+// NewLLMClient returns a client of the chat model of provider (types.ProviderOpenAI or
+// types.ProviderAnthropic) for requests outside of a translation
+func NewLLMClient(provider, apiKey, model, apiURL string, timeout time.Duration) LLMClient {
+	engine := NewTranslationEngineWithConfig(apiKey, model, apiURL, timeout, 1)
+	engine.SetProvider(provider)
+	return engine
+}
+
+// SetProvider sets the provider of the chat model: types.ProviderOpenAI (the default) for
+// OpenAI compatible APIs or types.ProviderAnthropic for the Anthropic Messages API. The API
+// key, URL and model keep their meaning; the default OpenAI URL stands for the default URL
+// of the provider.
+func (t *TranslationEngine) SetProvider(provider string) {
+	t.provider = types.NormalizeProvider(provider)
+}
+
+// Provider returns the provider of the chat model
+func (t *TranslationEngine) Provider() string {
+	return types.NormalizeProvider(t.provider)
+}
+
+// endpoint returns the URL the requests of the provider are sent to
+func (t *TranslationEngine) endpoint() string {
+	if t.Provider() == types.ProviderAnthropic {
+		return anthropicMessagesURL(t.apiURL)
+	}
+	return t.apiURL
+}
+
+// Complete sends a prompt through the request path of the translations, streamed and
+// adjusted like a chunk request, and returns the answer
+func (t *TranslationEngine) Complete(ctx context.Context, prompt Prompt) (string, Usage, error) {
+	messages := make([]Message, 0, len(prompt.Messages)+1)
+	if prompt.System != "" {
+		messages = append(messages, Message{Role: "system", Content: prompt.System})
+	}
+	messages = append(messages, prompt.Messages...)
+
+	chatResp, err := t.chatCompletionContext(ctx, messages, prompt.MaxTokens)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return chatResp.Choices[0].Message.Content, chatResp.Usage, nil
+}
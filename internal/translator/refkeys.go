@@ -0,0 +1,252 @@
+package translator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// referenceKeyPattern matches the commands whose argument is a key that must come out of
+// translation unchanged: labels, cross-references and citations, with their optional
+// arguments (\citep[see][p.~3]{key}). Group 1 is the command name, group 2 the key.
+var referenceKeyPattern = regexp.MustCompile(`\\(label|ref|eqref|pageref|autoref|cref|Cref|nameref|cite[a-zA-Z]*|nocite)\*?(?:\s*\[[^\]]*\])*\s*\{([^{}]*)\}`)
+
+// errReferenceKeysLost is the cause of a chunk whose translation dropped a reference key
+// placeholder; the chunk is retried
+var errReferenceKeysLost = errors.New("reference key placeholders missing from the translation")
+
+// protectReferenceKeys replaces the key of every \label, \ref, \eqref, \autoref and \cite
+// of a chunk with an opaque <<<LATEX_REF_N>>> placeholder, so the model cannot translate
+// or drop part of it even where the command itself is left in the text. The command
+// names stay, so ProtectLaTeXCommands still recognizes the commands.
+func protectReferenceKeys(content string) (string, []commentPlaceholder) {
+	matches := referenceKeyPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content, nil
+	}
+
+	var sb strings.Builder
+	var keys []commentPlaceholder
+	last := 0
+	for _, m := range matches {
+		key := content[m[4]:m[5]]
+		if strings.TrimSpace(key) == "" || strings.HasPrefix(key, "<<<") {
+			continue
+		}
+		placeholder := fmt.Sprintf("<<<LATEX_REF_%d>>>", len(keys))
+		keys = append(keys, commentPlaceholder{placeholder: placeholder, original: key})
+		sb.WriteString(content[last:m[4]])
+		sb.WriteString(placeholder)
+		last = m[5]
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), keys
+}
+
+// restoreReferenceKeys puts the keys back in place of their placeholders and returns the
+// keys whose placeholder the model dropped
+func restoreReferenceKeys(content string, keys []commentPlaceholder) (string, []string) {
+	var lost []string
+	for _, k := range keys {
+		if !strings.Contains(content, k.placeholder) {
+			lost = append(lost, k.original)
+			continue
+		}
+		content = strings.ReplaceAll(content, k.placeholder, k.original)
+	}
+	return content, lost
+}
+
+// referenceKey is one labelling, referencing or citing command of a document
+type referenceKey struct {
+	name       string // command name, e.g. "cite" or "label"
+	key        string
+	start, end int // the whole command
+	keyStart   int
+	keyEnd     int
+	line       int // 0-based line number
+}
+
+// scanReferenceKeys returns the reference commands of content outside comments, in order
+func scanReferenceKeys(content string) []referenceKey {
+	var refs []referenceKey
+	for _, m := range referenceKeyPattern.FindAllStringSubmatchIndex(content, -1) {
+		lineStart := strings.LastIndexByte(content[:m[0]], '\n') + 1
+		if isCommentedOut(content[lineStart:m[0]]) {
+			continue
+		}
+		refs = append(refs, referenceKey{
+			name:     content[m[2]:m[3]],
+			key:      content[m[4]:m[5]],
+			start:    m[0],
+			end:      m[1],
+			keyStart: m[4],
+			keyEnd:   m[5],
+			line:     strings.Count(content[:m[0]], "\n"),
+		})
+	}
+	return refs
+}
+
+// fixLostReferenceKeys compares the keys of the reference commands of the translation
+// with those of the original. A key the model changed (\cite{史密斯2021} for
+// \cite{smith2021}) is put back when the command occurs as often in both documents, by
+// order; a command that vanished is inserted again: a \label where RepairLabelPlacement
+// would put it, anything else at the end of the same line when the two documents are
+// line-aligned.
+func fixLostReferenceKeys(translated, original string) (string, bool) {
+	origRefs := scanReferenceKeys(original)
+	if len(origRefs) == 0 {
+		return translated, false
+	}
+
+	result, renamed := restoreChangedReferenceKeys(translated, origRefs)
+	result, reinserted := reinsertLostReferenceKeys(result, original, origRefs)
+	if renamed+reinserted == 0 {
+		return translated, false
+	}
+	logger.Info("restored reference keys lost in translation",
+		logger.Int("changed", renamed),
+		logger.Int("reinserted", reinserted))
+	return result, true
+}
+
+// restoreChangedReferenceKeys puts back keys that do not occur in the original, pairing
+// the commands of each name by order, and returns the number of keys restored
+func restoreChangedReferenceKeys(translated string, origRefs []referenceKey) (string, int) {
+	origByName := make(map[string][]referenceKey)
+	known := make(map[string]bool)
+	for _, r := range origRefs {
+		origByName[r.name] = append(origByName[r.name], r)
+		known[r.name+"\x00"+r.key] = true
+	}
+	transByName := make(map[string][]referenceKey)
+	for _, r := range scanReferenceKeys(translated) {
+		transByName[r.name] = append(transByName[r.name], r)
+	}
+
+	var edits []labelEdit
+	for name, trans := range transByName {
+		orig := origByName[name]
+		if len(orig) != len(trans) {
+			continue
+		}
+		for i, t := range trans {
+			if t.key == orig[i].key || known[name+"\x00"+t.key] {
+				continue
+			}
+			logger.Debug("restoring changed reference key",
+				logger.String("command", name),
+				logger.String("translated", t.key),
+				logger.String("original", orig[i].key))
+			edits = append(edits, labelEdit{start: t.keyStart, end: t.keyEnd, text: orig[i].key})
+		}
+	}
+	return applyReferenceEdits(translated, edits), len(edits)
+}
+
+// reinsertLostReferenceKeys inserts the reference commands of the original that have no
+// counterpart in the translation and returns the number inserted
+func reinsertLostReferenceKeys(translated, original string, origRefs []referenceKey) (string, int) {
+	remaining := make(map[string]int)
+	for _, r := range scanReferenceKeys(translated) {
+		remaining[r.name+"\x00"+r.key]++
+	}
+
+	var lost []referenceKey
+	for _, r := range origRefs {
+		id := r.name + "\x00" + r.key
+		if remaining[id] > 0 {
+			remaining[id]--
+			continue
+		}
+		lost = append(lost, r)
+	}
+	if len(lost) == 0 {
+		return translated, 0
+	}
+
+	var orig, trans *labelLayout
+	lineAligned := strings.Count(original, "\n") == strings.Count(translated, "\n")
+	lineEnds := lineEndOffsets(translated)
+
+	var edits []labelEdit
+	for _, r := range lost {
+		text := original[r.start:r.end]
+		insertAt := -1
+		if r.name == "label" {
+			if orig == nil {
+				orig, trans = scanLabelLayout(original), scanLabelLayout(translated)
+			}
+			insertAt, text = lostLabelInsertion(translated, r, text, orig, trans)
+		}
+		if insertAt < 0 && lineAligned {
+			insertAt = lineEnds[r.line]
+			lineStart := strings.LastIndexByte(translated[:insertAt], '\n') + 1
+			if strings.TrimSpace(translated[lineStart:insertAt]) != "" {
+				text = " " + text
+			}
+		}
+		if insertAt < 0 {
+			logger.Warn("reference command lost in translation, cannot place it",
+				logger.String("command", original[r.start:r.end]),
+				logger.Int("line", r.line+1))
+			continue
+		}
+		edits = append(edits, labelEdit{start: insertAt, end: insertAt, text: text})
+	}
+	return applyReferenceEdits(translated, edits), len(edits)
+}
+
+// lostLabelInsertion returns where a \label of the original goes in the translation, by
+// the enclosure it has in the original, or -1 when the enclosures do not match up
+func lostLabelInsertion(translated string, r referenceKey, label string, orig, trans *labelLayout) (int, string) {
+	for _, o := range orig.labels {
+		if o.start != r.start {
+			continue
+		}
+		switch {
+		case o.env != "":
+			if len(orig.envs[o.env]) != len(trans.envs[o.env]) {
+				return -1, label
+			}
+			env := trans.envs[o.env][o.ordinal]
+			if env.endStart == 0 {
+				return -1, label
+			}
+			return labelInsertion(translated, env, o, label)
+		case o.atHeading && len(orig.headings) == len(trans.headings):
+			return trans.headings[o.ordinal], label
+		}
+		return -1, label
+	}
+	return -1, label
+}
+
+// lineEndOffsets returns the offset of the end of every line of content
+func lineEndOffsets(content string) []int {
+	var ends []int
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			ends = append(ends, i)
+		}
+	}
+	return append(ends, len(content))
+}
+
+// applyReferenceEdits applies edits from the end of content so positions stay valid;
+// insertions at the same position keep their order
+func applyReferenceEdits(content string, edits []labelEdit) string {
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+	return content
+}
@@ -0,0 +1,100 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestProtectReferenceKeys(t *testing.T) {
+	content := "As shown by \\citep[see][p.~3]{smith2021,lee2020}, Figure~\\ref{fig:arch} and \\eqref{eq:loss}.\\label{sec:intro} \\cite{}"
+	protected, keys := protectReferenceKeys(content)
+	if len(keys) != 4 {
+		t.Fatalf("got %d keys, want 4: %+v", len(keys), keys)
+	}
+	for _, key := range []string{"smith2021", "fig:arch", "eq:loss", "sec:intro"} {
+		if strings.Contains(protected, key) {
+			t.Errorf("key %q left in protected content: %s", key, protected)
+		}
+	}
+	if !strings.Contains(protected, "\\citep[see][p.~3]{<<<LATEX_REF_0>>>}") {
+		t.Errorf("optional arguments not kept: %s", protected)
+	}
+
+	restored, lost := restoreReferenceKeys(protected, keys)
+	if restored != content || len(lost) != 0 {
+		t.Errorf("round trip = %q, lost %v", restored, lost)
+	}
+
+	dropped := strings.Replace(protected, "\\eqref{<<<LATEX_REF_2>>>}", "式", 1)
+	if _, lost := restoreReferenceKeys(dropped, keys); len(lost) != 1 || lost[0] != "eq:loss" {
+		t.Errorf("lost = %v, want [eq:loss]", lost)
+	}
+
+	err := types.NewAppErrorWithDetails(types.ErrTranslation, "reference keys lost in translation", "eq:loss", errReferenceKeysLost)
+	if !isRetryableAPIError(err) {
+		t.Error("lost reference keys do not trigger a retry")
+	}
+}
+
+func TestTranslateChunkKeepsReferenceKeys(t *testing.T) {
+	// The model translates every name it sees, keys included
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt[strings.LastIndex(prompt, "\n\n")+2:]
+		translated := strings.NewReplacer("smith", "史密斯", "Results", "结果").Replace(chunk)
+		json.NewEncoder(w).Encode(ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL, 5*time.Second, 1)
+	chunk := "Results of smith are in Table~\\ref{tab:smith} \\cite{smith2021}."
+	translated, err := engine.TranslateChunk(chunk)
+	if err != nil {
+		t.Fatalf("TranslateChunk failed: %v", err)
+	}
+	if !strings.Contains(translated, "\\ref{tab:smith}") || !strings.Contains(translated, "\\cite{smith2021}") || !strings.Contains(translated, "史密斯") {
+		t.Errorf("translation = %q", translated)
+	}
+}
+
+func TestFixLostReferenceKeys(t *testing.T) {
+	original := "\\section{Intro}\\label{sec:intro}\nAs in \\cite{smith2021} and \\cite{lee2020}.\n" +
+		"\\begin{figure}\n\\centering\n\\caption{Arch.}\\label{fig:arch}\n\\end{figure}\n" +
+		"See Figure~\\ref{fig:arch}.\n% \\label{unused}\n"
+	translated := "\\section{引言}\\label{sec:intro}\n如 \\cite{史密斯2021} 和 \\cite{lee2020} 所述。\n" +
+		"\\begin{figure}\n\\centering\n\\caption{架构。}\n\\end{figure}\n" +
+		"见图。\n% \\label{unused}\n"
+
+	got := ApplyReferenceBasedFixes(translated, original)
+	for _, want := range []string{
+		"如 \\cite{smith2021} 和 \\cite{lee2020} 所述。",
+		"\\caption{架构。}\\label{fig:arch}\n\\end{figure}",
+		"见图。 \\ref{fig:arch}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("fixed document is missing %q:\n%s", want, got)
+		}
+	}
+
+	if again, fixed := fixLostReferenceKeys(got, original); fixed || again != got {
+		t.Errorf("fixing again changed the document:\n%s", again)
+	}
+
+	// Citations the model reordered are left alone
+	reordered := "\\section{引言}\\label{sec:intro}\n\\cite{lee2020} 与 \\cite{smith2021}。\n" +
+		"\\begin{figure}\n\\centering\n\\caption{架构。}\\label{fig:arch}\n\\end{figure}\n" +
+		"见图~\\ref{fig:arch}。\n% \\label{unused}\n"
+	if again, fixed := fixLostReferenceKeys(reordered, original); fixed || again != reordered {
+		t.Errorf("reordered citations were changed:\n%s", again)
+	}
+}
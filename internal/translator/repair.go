@@ -0,0 +1,319 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// RepairSection is an excerpt of a LaTeX file sent for repair, identified by its 1-based
+// inclusive line range in the file
+type RepairSection struct {
+	StartLine int
+	EndLine   int
+	Content   string
+}
+
+// RepairRequest asks the model to fix LaTeX compilation errors. Either Content (the whole
+// file) or Sections (excerpts around the errors, for files too large to send) is set.
+type RepairRequest struct {
+	Content    string
+	Sections   []RepairSection
+	Errors     []string // one description per error, e.g. "Line 12: Undefined control sequence"
+	LogExcerpt string   // the relevant part of the compile log
+}
+
+// partial reports whether the request repairs excerpts instead of the whole file
+func (r RepairRequest) partial() bool {
+	return len(r.Sections) > 0
+}
+
+// RepairResult is the repaired LaTeX: the whole file for a full request, or one section
+// per requested section, with the same line ranges, for a partial request
+type RepairResult struct {
+	Content    string
+	Sections   []RepairSection
+	TokensUsed int
+}
+
+// repairSectionPattern matches a section of a partial repair in the model output
+var repairSectionPattern = regexp.MustCompile(`(?s)=== SECTION: lines (\d+)-(\d+) ===\n(.*?)\n?=== END SECTION ===`)
+
+// repairFencePattern matches a markdown code fence wrapped around the whole output
+var repairFencePattern = regexp.MustCompile("(?s)^```[a-zA-Z]*\\n(.*?)\\n?```$")
+
+// maxRepairLength is the largest repaired/original length ratio accepted; a repair only
+// touches a few lines, so much longer output means the model added prose or duplicated
+// content
+const maxRepairLength = 1.5
+
+// minRepairLength is the smallest repaired/original length ratio accepted; shorter output
+// usually means the model truncated or summarised the file
+const minRepairLength = 0.7
+
+const repairSystemPrompt = `You are a LaTeX expert fixing compilation errors in a LaTeX document.
+The document may be written in any language (often Chinese with ctex); never translate,
+rephrase or remove its text.
+
+RULES:
+1. Fix ONLY what causes the listed errors. Leave every other line exactly as it is.
+2. Keep the document structure: all \begin/\end pairs, sections, labels and packages that
+   are not part of an error.
+3. Typical fixes: missing or unmatched braces, undefined control sequences, math mode
+   issues, missing packages, commands incompatible with XeLaTeX.
+4. Output ONLY LaTeX. No explanations, no markdown code fences.`
+
+// buildRepairPrompt builds the user prompt of a repair request
+func buildRepairPrompt(request RepairRequest) string {
+	var sb strings.Builder
+	sb.WriteString("COMPILATION ERRORS:\n")
+	for _, e := range request.Errors {
+		sb.WriteString("- " + e + "\n")
+	}
+	if request.LogExcerpt != "" {
+		sb.WriteString("\nCOMPILATION LOG (relevant part):\n")
+		sb.WriteString(request.LogExcerpt)
+		sb.WriteString("\n")
+	}
+
+	if !request.partial() {
+		sb.WriteString("\nOutput the complete corrected document.\n\nDOCUMENT:\n")
+		sb.WriteString(request.Content)
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf(`
+Only excerpts of the file around the errors are shown. Output EVERY one of the %d sections
+below, corrected, between the same markers with the same line numbers:
+=== SECTION: lines X-Y ===
+...corrected lines...
+=== END SECTION ===
+A section may get more or fewer lines than the original. Output nothing outside the markers.
+
+SECTIONS:
+`, len(request.Sections)))
+	for _, s := range request.Sections {
+		sb.WriteString(formatRepairSection(s))
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// formatRepairSection writes a section between its markers
+func formatRepairSection(s RepairSection) string {
+	return fmt.Sprintf("=== SECTION: lines %d-%d ===\n%s\n=== END SECTION ===", s.StartLine, s.EndLine, s.Content)
+}
+
+// RepairLaTeX asks the model to fix LaTeX compilation errors. Unlike TranslateChunk it
+// uses a repair prompt, does not protect or translate anything and does not expect Chinese
+// output; instead the output must keep the structure of the input (environments, document
+// boundaries, roughly the same length) and, for a partial request, contain every requested
+// section between its markers. Output breaking this contract is retried like an API error.
+func (t *TranslationEngine) RepairLaTeX(request RepairRequest) (*RepairResult, error) {
+	if t.apiKey == "" {
+		return nil, types.NewAppError(types.ErrConfig, "OpenAI API key is not configured", nil)
+	}
+	if request.Content == "" && !request.partial() {
+		return nil, types.NewAppError(types.ErrInvalidInput, "nothing to repair", nil)
+	}
+
+	inputLength := len(request.Content)
+	for _, s := range request.Sections {
+		inputLength += len(s.Content)
+	}
+	// Repaired output is about as long as the input; 1 token ≈ 3 chars for mixed text
+	maxTokens := inputLength/3*3/2 + 512
+	if maxTokens > 16384 {
+		maxTokens = 16384
+	}
+
+	messages := []Message{
+		{Role: "system", Content: repairSystemPrompt},
+		{Role: "user", Content: buildRepairPrompt(request)},
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= MaxRetries; attempt++ {
+		if err := t.breaker.wait(context.Background()); err != nil {
+			return nil, err
+		}
+
+		chatResp, err := t.chatCompletion(messages, maxTokens)
+		if err != nil {
+			lastErr = err
+			logger.Warn("repair request failed", logger.Int("attempt", attempt), logger.Err(err))
+			if !isRetryableAPIError(err) {
+				return nil, err
+			}
+		} else {
+			output := chatResp.Choices[0].Message.Content
+			if chatResp.Choices[0].FinishReason == "length" {
+				err = fmt.Errorf("output truncated at the token limit")
+			} else {
+				var result *RepairResult
+				result, err = parseRepairOutput(request, output)
+				if err == nil {
+					result.TokensUsed = chatResp.Usage.TotalTokens
+					logger.Info("LaTeX repair succeeded",
+						logger.Int("attempt", attempt),
+						logger.Bool("partial", request.partial()),
+						logger.Int("tokensUsed", result.TokensUsed))
+					return result, nil
+				}
+			}
+			lastErr = types.NewAppErrorWithDetails(types.ErrTranslation, "repair output rejected", err.Error(), nil)
+			logger.Warn("repair output rejected", logger.Int("attempt", attempt), logger.Err(err))
+		}
+
+		if attempt < MaxRetries {
+			time.Sleep(BaseRetryDelay * time.Duration(attempt))
+		}
+	}
+
+	return nil, lastErr
+}
+
+// parseRepairOutput checks the model output against the repair contract and extracts the
+// repaired content
+func parseRepairOutput(request RepairRequest, output string) (*RepairResult, error) {
+	output = strings.TrimSpace(output)
+	if m := repairFencePattern.FindStringSubmatch(output); m != nil {
+		output = m[1]
+	}
+
+	if !request.partial() {
+		if err := validateRepair(request.Content, output); err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(request.Content, "\n") {
+			output += "\n"
+		}
+		return &RepairResult{Content: output}, nil
+	}
+
+	matches := repairSectionPattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no section markers in output")
+	}
+	repaired := make(map[[2]int]string, len(matches))
+	for _, m := range matches {
+		start, _ := strconv.Atoi(m[1])
+		end, _ := strconv.Atoi(m[2])
+		repaired[[2]int{start, end}] = m[3]
+	}
+
+	result := &RepairResult{}
+	for _, s := range request.Sections {
+		content, ok := repaired[[2]int{s.StartLine, s.EndLine}]
+		if !ok {
+			return nil, fmt.Errorf("section lines %d-%d missing from output", s.StartLine, s.EndLine)
+		}
+		if err := validateRepair(s.Content, content); err != nil {
+			return nil, fmt.Errorf("section lines %d-%d: %v", s.StartLine, s.EndLine, err)
+		}
+		result.Sections = append(result.Sections, RepairSection{StartLine: s.StartLine, EndLine: s.EndLine, Content: content})
+	}
+	return result, nil
+}
+
+// validateRepair checks that a repair kept the structure of the original: the document
+// boundaries, about the same length and no environment lost. Environments may be added or
+// closed (that is often the fix), but one that existed must still be there.
+func validateRepair(original, repaired string) error {
+	if strings.TrimSpace(repaired) == "" {
+		return fmt.Errorf("empty output")
+	}
+	for _, marker := range []string{`\documentclass`, `\begin{document}`, `\end{document}`} {
+		if strings.Contains(original, marker) && !strings.Contains(repaired, marker) {
+			return fmt.Errorf("%s removed", marker)
+		}
+	}
+
+	ratio := float64(len(repaired)) / float64(len(original))
+	if len(original) > 200 && (ratio < minRepairLength || ratio > maxRepairLength) {
+		return fmt.Errorf("output length changed too much (%.0f%% of the original)", ratio*100)
+	}
+
+	before := countEnvironments(original)
+	after := countEnvironments(repaired)
+	var lost []string
+	for env := range before {
+		if _, ok := after[env]; !ok {
+			lost = append(lost, env)
+		}
+	}
+	if len(lost) > 0 {
+		sort.Strings(lost)
+		return fmt.Errorf("environments removed: %s", strings.Join(lost, ", "))
+	}
+	return nil
+}
+
+// RepairSectionsAround cuts the excerpts of content around the given 1-based error lines,
+// with context lines before and after each. Overlapping excerpts are merged, so the
+// sections are disjoint and in file order.
+func RepairSectionsAround(content string, errorLines []int, context int) []RepairSection {
+	lines := strings.Split(content, "\n")
+	var ranges [][2]int
+	for _, line := range errorLines {
+		if line < 1 || line > len(lines) {
+			continue
+		}
+		start, end := line-context, line+context
+		if start < 1 {
+			start = 1
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	var sections []RepairSection
+	for _, r := range ranges {
+		if n := len(sections); n > 0 && r[0] <= sections[n-1].EndLine+1 {
+			if r[1] > sections[n-1].EndLine {
+				sections[n-1].EndLine = r[1]
+			}
+			continue
+		}
+		sections = append(sections, RepairSection{StartLine: r[0], EndLine: r[1]})
+	}
+	for i := range sections {
+		sections[i].Content = strings.Join(lines[sections[i].StartLine-1:sections[i].EndLine], "\n")
+	}
+	return sections
+}
+
+// MergeRepairSections replaces the line ranges of content with the repaired sections.
+// Sections are applied from the end of the file, so the line numbers of earlier sections
+// stay valid when a repair changes the number of lines.
+func MergeRepairSections(content string, sections []RepairSection) string {
+	sorted := append([]RepairSection(nil), sections...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartLine > sorted[j].StartLine })
+
+	lines := strings.Split(content, "\n")
+	for _, s := range sorted {
+		if s.StartLine < 1 || s.EndLine > len(lines) || s.StartLine > s.EndLine {
+			logger.Warn("invalid repair section line numbers",
+				logger.Int("startLine", s.StartLine),
+				logger.Int("endLine", s.EndLine),
+				logger.Int("totalLines", len(lines)))
+			continue
+		}
+		merged := make([]string, 0, len(lines))
+		merged = append(merged, lines[:s.StartLine-1]...)
+		merged = append(merged, strings.Split(s.Content, "\n")...)
+		merged = append(merged, lines[s.EndLine:]...)
+		lines = merged
+	}
+	return strings.Join(lines, "\n")
+}
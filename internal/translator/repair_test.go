@@ -0,0 +1,163 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// repairServer answers repair requests with the given outputs in turn and records the
+// prompts it received
+func repairServer(t *testing.T, outputs ...string) (*TranslationEngine, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		n := len(prompts)
+		prompts = append(prompts, req.Messages[1].Content)
+		mu.Unlock()
+
+		output := outputs[len(outputs)-1]
+		if n < len(outputs) {
+			output = outputs[n]
+		}
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: output}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 42},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	return engine, &prompts
+}
+
+const brokenDocument = `\documentclass{article}
+\begin{document}
+\section{Results}
+The loss is $\frac{1}{2}\sum_i x_i^2$ and the accuracy is \textbf{high.
+\begin{itemize}
+\item First
+\end{itemize}
+\end{document}
+`
+
+func TestRepairLaTeXAcceptsOutputWithoutChinese(t *testing.T) {
+	fixed := strings.Replace(brokenDocument, `\textbf{high.`, `\textbf{high}.`, 1)
+	engine, prompts := repairServer(t, "```latex\n"+fixed+"```")
+
+	result, err := engine.RepairLaTeX(RepairRequest{
+		Content:    brokenDocument,
+		Errors:     []string{"Line 4: Runaway argument?"},
+		LogExcerpt: "! File ended while scanning use of \\textbf.",
+	})
+	if err != nil {
+		t.Fatalf("RepairLaTeX() error = %v", err)
+	}
+	if result.Content != fixed {
+		t.Errorf("repaired content =\n%s\nwant\n%s", result.Content, fixed)
+	}
+	if result.TokensUsed != 42 {
+		t.Errorf("TokensUsed = %d, want 42", result.TokensUsed)
+	}
+
+	prompt := (*prompts)[0]
+	for _, want := range []string{"Runaway argument", "File ended while scanning", `\textbf{high.`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, "<<<LATEX_") {
+		t.Errorf("repair prompt contains translation placeholders:\n%s", prompt)
+	}
+}
+
+func TestRepairLaTeXRetriesOutputBreakingTheContract(t *testing.T) {
+	content := strings.Repeat("Some text line.\n", 40) + "\\begin{table}\nBroken & row \\\\\\\n\\end{table}\n" + strings.Repeat("More text.\n", 40)
+	sections := RepairSectionsAround(content, []int{42}, 3)
+	if len(sections) != 1 || sections[0].StartLine != 39 || sections[0].EndLine != 45 {
+		t.Fatalf("sections = %+v, want one section of lines 39-45", sections)
+	}
+	fixedSection := strings.Replace(sections[0].Content, `row \\\`, `row \\`, 1)
+
+	engine, prompts := repairServer(t,
+		// No markers at all
+		fixedSection,
+		// Markers, but the environment is gone
+		"=== SECTION: lines 39-45 ===\nSome text line.\n=== END SECTION ===",
+		// Correct
+		"=== SECTION: lines 39-45 ===\n"+fixedSection+"\n=== END SECTION ===",
+	)
+
+	result, err := engine.RepairLaTeX(RepairRequest{Sections: sections, Errors: []string{"Line 42: Misplaced \\noalign"}})
+	if err == nil {
+		t.Fatalf("expected the contract violations to exhaust the retries, got %+v", result)
+	}
+	if len(*prompts) != MaxRetries {
+		t.Errorf("requests = %d, want %d", len(*prompts), MaxRetries)
+	}
+	if !strings.Contains((*prompts)[0], "=== SECTION: lines 39-45 ===") {
+		t.Errorf("partial prompt does not contain the section markers:\n%s", (*prompts)[0])
+	}
+
+	engine, _ = repairServer(t, "=== SECTION: lines 39-45 ===\n"+fixedSection+"\n=== END SECTION ===")
+	result, err = engine.RepairLaTeX(RepairRequest{Sections: sections, Errors: []string{"Line 42: Misplaced \\noalign"}})
+	if err != nil {
+		t.Fatalf("RepairLaTeX() error = %v", err)
+	}
+	merged := MergeRepairSections(content, result.Sections)
+	if want := strings.Replace(content, `row \\\`, `row \\`, 1); merged != want {
+		t.Errorf("merged content =\n%s\nwant\n%s", merged, want)
+	}
+}
+
+func TestValidateRepair(t *testing.T) {
+	tests := []struct {
+		name     string
+		repaired string
+		wantErr  bool
+	}{
+		{"closing an environment", strings.Replace(brokenDocument, `\textbf{high.`, `\textbf{high}.`, 1), false},
+		{"empty", "  \n", true},
+		{"document end removed", strings.Replace(brokenDocument, `\end{document}`, "", 1), true},
+		{"environment removed", strings.Replace(strings.Replace(brokenDocument, "\\begin{itemize}\n", "", 1), "\\end{itemize}\n", "", 1), true},
+		{"truncated", brokenDocument[:len(brokenDocument)/2] + `\end{document}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRepair(brokenDocument, tt.repaired)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRepair() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeRepairSectionsKeepsLineNumbersWhenLinesChange(t *testing.T) {
+	content := "l1\nl2\nl3\nl4\nl5\nl6\nl7\nl8"
+	sections := RepairSectionsAround(content, []int{2, 7, 3}, 0)
+	if len(sections) != 2 {
+		t.Fatalf("sections = %+v, want lines 2-3 and 7", sections)
+	}
+
+	// The first repair adds a line, the second removes one
+	sections[0].Content = "l2\nnew\nl3"
+	sections[1].Content = ""
+	got := MergeRepairSections(content, sections)
+	want := "l1\nl2\nnew\nl3\nl4\nl5\nl6\n\nl8"
+	if got != want {
+		t.Errorf("MergeRepairSections() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,331 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
+	"latex-translator/internal/types"
+)
+
+// paragraphSeparatorPattern matches one or more blank lines between paragraphs
+var paragraphSeparatorPattern = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// paragraphCommandPattern extracts LaTeX command names used as a paragraph signature
+var paragraphCommandPattern = regexp.MustCompile(`\\[a-zA-Z@]+\*?`)
+
+// reuseSegmentMarkerPattern matches the markers separating changed segments
+// in the reduced document sent to the model
+var reuseSegmentMarkerPattern = regexp.MustCompile(`\n*[ \t]*%REUSE_SEGMENT_(\d+)%[ \t]*\n*`)
+
+// paragraphSegment is a paragraph together with the blank-line separator following it.
+// Concatenating text+sep of all segments reproduces the original content.
+type paragraphSegment struct {
+	text string
+	sep  string
+}
+
+// splitParagraphSegments splits content into paragraphs separated by blank lines
+func splitParagraphSegments(content string) []paragraphSegment {
+	var segs []paragraphSegment
+	last := 0
+	for _, m := range paragraphSeparatorPattern.FindAllStringIndex(content, -1) {
+		segs = append(segs, paragraphSegment{text: content[last:m[0]], sep: content[m[0]:m[1]]})
+		last = m[1]
+	}
+	segs = append(segs, paragraphSegment{text: content[last:]})
+	return segs
+}
+
+// normalizeParagraphKey normalizes whitespace so that re-wrapped but otherwise
+// identical paragraphs are still recognized as unchanged
+func normalizeParagraphKey(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// paragraphSignature returns the sequence of LaTeX commands in a paragraph.
+// Translation keeps commands intact, so original and translated paragraphs
+// share the same signature.
+func paragraphSignature(text string) string {
+	return strings.Join(paragraphCommandPattern.FindAllString(text, -1), ",")
+}
+
+// alignParagraphs pairs paragraphs of an original document with paragraphs of its translation.
+// If both have the same paragraph count they are paired by index. Otherwise paragraphs with
+// a non-empty command signature are matched via LCS and used as anchors; the gaps between
+// anchors are paired by index when both sides have the same number of paragraphs.
+func alignParagraphs(original, translated []string) [][2]int {
+	if len(original) == len(translated) {
+		pairs := make([][2]int, len(original))
+		for i := range original {
+			pairs[i] = [2]int{i, i}
+		}
+		return pairs
+	}
+
+	origSig := make([]string, len(original))
+	for i, p := range original {
+		origSig[i] = paragraphSignature(p)
+	}
+	transSig := make([]string, len(translated))
+	for i, p := range translated {
+		transSig[i] = paragraphSignature(p)
+	}
+
+	// LCS over signatures
+	n, m := len(origSig), len(transSig)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if origSig[i] == transSig[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	var anchors [][2]int
+	for i, j := 0, 0; i < n && j < m; {
+		if origSig[i] == transSig[j] {
+			if origSig[i] != "" {
+				anchors = append(anchors, [2]int{i, j})
+			}
+			i++
+			j++
+		} else if dp[i+1][j] >= dp[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	// Fill the gaps between anchors
+	var pairs [][2]int
+	prevI, prevJ := -1, -1
+	anchors = append(anchors, [2]int{n, m})
+	for _, a := range anchors {
+		gapI, gapJ := a[0]-prevI-1, a[1]-prevJ-1
+		if gapI == gapJ {
+			for k := 1; k <= gapI; k++ {
+				pairs = append(pairs, [2]int{prevI + k, prevJ + k})
+			}
+		}
+		if a[0] < n {
+			pairs = append(pairs, a)
+		}
+		prevI, prevJ = a[0], a[1]
+	}
+	return pairs
+}
+
+// BuildReuseMap maps each (normalized) paragraph of a previous original to its
+// translation, so that unchanged paragraphs of a new version can reuse it verbatim.
+func BuildReuseMap(pair *types.TranslationPair) map[string]string {
+	reuse := make(map[string]string)
+	if pair == nil {
+		return reuse
+	}
+
+	origSegs := splitParagraphSegments(pair.Original)
+	transSegs := splitParagraphSegments(pair.Translated)
+	original := make([]string, len(origSegs))
+	for i, s := range origSegs {
+		original[i] = s.text
+	}
+	translated := make([]string, len(transSegs))
+	for i, s := range transSegs {
+		translated[i] = s.text
+	}
+
+	for _, p := range alignParagraphs(original, translated) {
+		key := normalizeParagraphKey(original[p[0]])
+		if key == "" {
+			continue
+		}
+		if _, exists := reuse[key]; !exists {
+			reuse[key] = translated[p[1]]
+		}
+	}
+	return reuse
+}
+
+// TranslateTeXWithReference translates a new version of a document using the translation
+// of a previous version. Paragraphs that are unchanged since the previous version reuse the
+// existing (possibly hand-corrected) translation verbatim; only changed and new paragraphs
+// are sent to the model.
+//
+// Deprecated: use TranslateTeXWithReferenceContext. TranslateTeXWithReference runs under the
+// context set with SetJobContext, context.Background() when none is set.
+func (t *TranslationEngine) TranslateTeXWithReference(content string, reference *types.TranslationPair, progressCallback TranslationProgressCallback) (*types.TranslationResult, error) {
+	return t.TranslateTeXWithReferenceContext(t.jobContext(), content, reference, progressCallback.ProgressFunc())
+}
+
+// TranslateTeXWithReferenceContext is TranslateTeXWithReference cancelling the chunk
+// requests when ctx is done.
+func (t *TranslationEngine) TranslateTeXWithReferenceContext(ctx context.Context, content string, reference *types.TranslationPair, progressCallback ProgressFunc) (*types.TranslationResult, error) {
+	if reference == nil || reference.Original == "" || reference.Translated == "" {
+		return t.TranslateTeXWithProgressContext(ctx, content, progressCallback)
+	}
+
+	reuse := BuildReuseMap(reference)
+	// Content after the end of the document is neither translated nor reused
+	body, trailing := parser.SplitAtDocumentEnd(content)
+	segs := splitParagraphSegments(body)
+	stats := &types.ReuseStats{}
+
+	// Classify paragraphs and group consecutive changed ones
+	reused := make([]string, len(segs))
+	isChanged := make([]bool, len(segs))
+	var groups [][]int
+	for i, seg := range segs {
+		key := normalizeParagraphKey(seg.text)
+		if key == "" {
+			reused[i] = seg.text
+			continue
+		}
+		stats.TotalParagraphs++
+		stats.TotalBytes += len(seg.text)
+		if translated, ok := reuse[key]; ok {
+			reused[i] = translated
+			stats.ReusedParagraphs++
+			stats.ReusedBytes += len(seg.text)
+			continue
+		}
+		isChanged[i] = true
+		if len(groups) > 0 && groups[len(groups)-1][len(groups[len(groups)-1])-1] == i-1 {
+			groups[len(groups)-1] = append(groups[len(groups)-1], i)
+		} else {
+			groups = append(groups, []int{i})
+		}
+	}
+
+	logger.Info("version-diff translation",
+		logger.Int("totalParagraphs", stats.TotalParagraphs),
+		logger.Int("reusedParagraphs", stats.ReusedParagraphs),
+		logger.Int("changedGroups", len(groups)),
+		logger.Float64("reusePercent", stats.Percent()))
+
+	groupTranslations := make([]string, len(groups))
+	tokensUsed := 0
+	var skippedBlobs []types.DataBlob
+	networkPauses := 0
+	networkPausedSecs := 0.0
+	paragraphFixes := 0
+	continuations, truncatedChunks, stalls, splitChunks, sanitizedResponses, retriedChunks := 0, 0, 0, 0, 0, 0
+	var failedChunks []int
+
+	if len(groups) > 0 {
+		// Build a reduced document containing only the changed paragraphs,
+		// separated by marker comment lines that the model leaves untouched
+		var reduced strings.Builder
+		for g, group := range groups {
+			if g > 0 {
+				reduced.WriteString(fmt.Sprintf("\n\n%%REUSE_SEGMENT_%d%%\n\n", g))
+			}
+			for k, idx := range group {
+				reduced.WriteString(segs[idx].text)
+				if k < len(group)-1 {
+					reduced.WriteString(segs[idx].sep)
+				}
+			}
+		}
+
+		result, err := t.TranslateTeXWithProgressContext(ctx, reduced.String(), progressCallback)
+		if err != nil {
+			return nil, err
+		}
+		tokensUsed = result.TokensUsed
+		skippedBlobs = result.SkippedDataBlobs
+		networkPauses = result.NetworkPauses
+		networkPausedSecs = result.NetworkPausedSecs
+		paragraphFixes = result.ParagraphBreakFixes
+		continuations = result.Continuations
+		truncatedChunks = result.TruncatedChunks
+		stalls = result.Stalls
+		splitChunks = result.SplitChunks
+		sanitizedResponses = result.SanitizedResponses
+		retriedChunks = result.RetriedChunks
+		failedChunks = result.FailedChunks
+
+		parts, ok := splitReuseSegments(result.TranslatedContent, len(groups))
+		if !ok {
+			logger.Warn("segment markers lost in version-diff translation, falling back to full translation",
+				logger.Int("expectedSegments", len(groups)))
+			return t.TranslateTeXWithProgressContext(ctx, content, progressCallback)
+		}
+		copy(groupTranslations, parts)
+	} else if progressCallback != nil {
+		progressCallback(ChunkProgress{Chunk: 1, TotalChunks: 1, Message: "内容与旧版本一致，全部复用已有译文", TokensUsed: t.Progress().TokensUsed})
+	}
+
+	// Assemble the final document in the original paragraph order
+	var out strings.Builder
+	g := 0
+	for i, seg := range segs {
+		if isChanged[i] {
+			group := groups[g]
+			if i == group[len(group)-1] {
+				out.WriteString(groupTranslations[g])
+				out.WriteString(seg.sep)
+				g++
+			}
+			continue
+		}
+		out.WriteString(reused[i])
+		out.WriteString(seg.sep)
+	}
+	out.WriteString(trailing)
+
+	return &types.TranslationResult{
+		OriginalContent:      content,
+		TranslatedContent:    out.String(),
+		TokensUsed:           tokensUsed,
+		SkippedDataBlobs:     skippedBlobs,
+		ReuseStats:           stats,
+		NetworkPauses:        networkPauses,
+		NetworkPausedSecs:    networkPausedSecs,
+		ParagraphBreakFixes:  paragraphFixes,
+		SkippedTrailingBytes: len(trailing),
+		Continuations:        continuations,
+		TruncatedChunks:      truncatedChunks,
+		Stalls:               stalls,
+		SplitChunks:          splitChunks,
+		SanitizedResponses:   sanitizedResponses,
+		RetriedChunks:        retriedChunks,
+		FailedChunks:         failedChunks,
+		PromptPreset:         t.PromptTemplate().Name,
+		Generator:            DetectGenerator(content),
+	}, nil
+}
+
+// splitReuseSegments splits the translated reduced document back into its segments.
+// Returns false if markers are missing or out of order.
+func splitReuseSegments(content string, expected int) ([]string, bool) {
+	matches := reuseSegmentMarkerPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) != expected-1 {
+		return nil, false
+	}
+
+	parts := make([]string, 0, expected)
+	last := 0
+	for k, m := range matches {
+		num, err := strconv.Atoi(content[m[2]:m[3]])
+		if err != nil || num != k+1 {
+			return nil, false
+		}
+		parts = append(parts, content[last:m[0]])
+		last = m[1]
+	}
+	parts = append(parts, content[last:])
+	return parts, true
+}
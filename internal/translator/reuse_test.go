@@ -0,0 +1,107 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+func TestSplitParagraphSegmentsRoundTrip(t *testing.T) {
+	input := "\\section{Intro}\nFirst paragraph.\n\n  \nSecond paragraph.\n\nThird.\n"
+	segs := splitParagraphSegments(input)
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(segs))
+	}
+	var sb strings.Builder
+	for _, s := range segs {
+		sb.WriteString(s.text)
+		sb.WriteString(s.sep)
+	}
+	if sb.String() != input {
+		t.Errorf("segments do not reproduce input:\n%q", sb.String())
+	}
+}
+
+func TestBuildReuseMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		original   string
+		translated string
+		key        string
+		want       string
+	}{
+		{
+			name:       "same paragraph count pairs by index",
+			original:   "\\section{Intro}\n\nWe propose a method.\n\nIt works well.",
+			translated: "\\section{引言}\n\n我们提出了一种方法。\n\n它效果很好。",
+			key:        "It works well.",
+			want:       "它效果很好。",
+		},
+		{
+			name:       "whitespace differences are ignored",
+			original:   "We propose\na method.",
+			translated: "我们提出了一种方法。",
+			key:        "We propose a method.",
+			want:       "我们提出了一种方法。",
+		},
+		{
+			name:       "anchors align around an extra translated paragraph",
+			original:   "\\documentclass{article}\n\n\\section{Intro}\n\nPlain text.\n\n\\cite{x} shows this.",
+			translated: "\\documentclass{article}\n\n\\usepackage{ctex}\n\n\\section{引言}\n\n纯文本。\n\n\\cite{x} 表明了这一点。",
+			key:        "\\cite{x} shows this.",
+			want:       "\\cite{x} 表明了这一点。",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reuse := BuildReuseMap(&types.TranslationPair{Original: tt.original, Translated: tt.translated})
+			if got := reuse[tt.key]; got != tt.want {
+				t.Errorf("BuildReuseMap()[%q] = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateTeXWithReferenceFullReuse(t *testing.T) {
+	engine := NewTranslationEngine("test-key")
+	reference := &types.TranslationPair{
+		Original:   "\\section{Intro}\n\nWe propose a method.\n\nIt works well.\n",
+		Translated: "\\section{引言}\n\n我们提出了一种方法（已人工修订）。\n\n它效果很好。\n",
+	}
+
+	// Re-wrapped but otherwise unchanged: no API call should be needed
+	content := "\\section{Intro}\n\nWe propose\na method.\n\nIt works well.\n"
+	result, err := engine.TranslateTeXWithReference(content, reference, nil)
+	if err != nil {
+		t.Fatalf("TranslateTeXWithReference() error: %v", err)
+	}
+	if result.TranslatedContent != reference.Translated {
+		t.Errorf("TranslatedContent =\n%q\nwant:\n%q", result.TranslatedContent, reference.Translated)
+	}
+	if result.ReuseStats == nil || result.ReuseStats.ReusedParagraphs != 3 || result.ReuseStats.Percent() != 100 {
+		t.Errorf("unexpected reuse stats: %+v", result.ReuseStats)
+	}
+	if result.TokensUsed != 0 {
+		t.Errorf("TokensUsed = %d, want 0", result.TokensUsed)
+	}
+}
+
+func TestSplitReuseSegments(t *testing.T) {
+	content := "第一段。\n\n%REUSE_SEGMENT_1%\n\n第二段。\n\n%REUSE_SEGMENT_2%\n\n第三段。"
+	parts, ok := splitReuseSegments(content, 3)
+	if !ok {
+		t.Fatal("splitReuseSegments() failed")
+	}
+	want := []string{"第一段。", "第二段。", "第三段。"}
+	for i := range want {
+		if parts[i] != want[i] {
+			t.Errorf("part %d = %q, want %q", i, parts[i], want[i])
+		}
+	}
+
+	if _, ok := splitReuseSegments("第一段。\n\n第二段。", 2); ok {
+		t.Error("expected failure when markers are missing")
+	}
+}
@@ -0,0 +1,152 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// responseFencePattern matches a fenced markdown block of a model response; group 1 is its
+// content. The closing fence is optional, for responses cut off inside the block.
+var responseFencePattern = regexp.MustCompile("(?ms)^[ \t]*```[A-Za-z0-9_+-]*[ \t]*\n(.*?)(?:\n[ \t]*```[ \t]*$|\\z)")
+
+// responsePreamblePattern matches a line in which the model announces its answer instead of
+// giving it, e.g. "Here is the translated text:" or "以下是翻译结果："
+var responsePreamblePattern = regexp.MustCompile(`(?i)^(?:\*\*)?(?:here is|here's|here are|sure|certainly|of course|okay|ok,|below is|the following is|translation|translated (?:text|content|version|latex)|以下是|下面是|翻译如下|翻译结果|译文如下|译文：|好的)`)
+
+// responseMetaPattern matches the words by which a line of a response is about the
+// translation rather than part of it
+var responseMetaPattern = regexp.MustCompile(`(?i)translat|placeholder|latex|command|翻译|译文|占位符|命令|格式`)
+
+// responseEpiloguePattern matches a line in which the model comments on its answer after
+// giving it, e.g. "Note: all placeholders were kept." or "注：保留了所有占位符。"
+var responseEpiloguePattern = regexp.MustCompile(`(?i)^(?:\*\*)?\(?(?:note|notes|please note|i have|i've|i kept|i preserved|all (?:latex|placeholders|commands)|the (?:latex|placeholders) |let me know|注[:：]|注意[:：]|说明[:：]|（注|备注[:：])`)
+
+// sanitizeModelOutput removes what a model wraps around the translation of input despite
+// the prompt: markdown code fences, and announcing or commenting lines in natural language
+// that are not plausible LaTeX. When the response holds several fenced blocks, the one whose
+// environments best match input is kept. It returns the sanitized response and the text
+// removed, empty when the response was clean.
+func sanitizeModelOutput(response, input string) (string, string) {
+	var removed []string
+	result := response
+
+	// Fences are the model's unless the input had them too
+	if !strings.Contains(input, "```") {
+		if blocks := responseFencePattern.FindAllStringSubmatchIndex(result, -1); len(blocks) > 0 {
+			best := blocks[0]
+			if len(blocks) > 1 {
+				bestScore := -1
+				for _, b := range blocks {
+					if score := structureDistance(result[b[2]:b[3]], input); bestScore == -1 || score < bestScore ||
+						score == bestScore && b[3]-b[2] > best[3]-best[2] {
+						best, bestScore = b, score
+					}
+				}
+			}
+			removed = append(removed, result[:best[2]], result[best[3]:])
+			result = result[best[2]:best[3]]
+		}
+	}
+
+	// Announcing lines before the translation and comments after it
+	inputLines := nonEmptyLines(input)
+	for {
+		line, rest, ok := firstNonEmptyLine(result)
+		if !ok || !isResponseChatter(line, responsePreamblePattern, inputLines) {
+			break
+		}
+		removed = append(removed, result[:len(result)-len(rest)])
+		result = rest
+	}
+	for {
+		line, rest, ok := lastNonEmptyLine(result)
+		if !ok || !isResponseChatter(line, responseEpiloguePattern, inputLines) {
+			break
+		}
+		rest = strings.TrimRight(rest, " \t\r\n")
+		removed = append(removed, result[len(rest):])
+		result = rest
+	}
+
+	if result == response {
+		return response, ""
+	}
+	return result, strings.TrimSpace(strings.Join(removed, "\n"))
+}
+
+// isResponseChatter reports whether a line of a response is the model talking: it matches
+// pattern, is about the translation (or announces it with a colon), is not plausible LaTeX
+// and is not a line of the input. Translated prose such as "注：结果……" is kept.
+func isResponseChatter(line string, pattern *regexp.Regexp, inputLines map[string]bool) bool {
+	line = strings.TrimSpace(line)
+	if inputLines[line] || isPlausibleLaTeXLine(line) || !pattern.MatchString(line) {
+		return false
+	}
+	end := strings.TrimRight(line, "* ")
+	announces := pattern == responsePreamblePattern && (strings.HasSuffix(end, ":") || strings.HasSuffix(end, "："))
+	return announces || responseMetaPattern.MatchString(line)
+}
+
+// isPlausibleLaTeXLine reports whether a line reads as LaTeX source: a command, a
+// comment, a placeholder or math
+func isPlausibleLaTeXLine(line string) bool {
+	return strings.HasPrefix(line, "\\") || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "$") ||
+		strings.Contains(line, "<<<") || strings.Contains(line, "\\begin{") || strings.Contains(line, "\\end{")
+}
+
+// structureDistance compares the \begin and \end counts and placeholders of a candidate
+// translation with those of the input; 0 is a perfect match
+func structureDistance(candidate, input string) int {
+	distance := 0
+	for _, token := range []string{"\\begin{", "\\end{", "<<<", "\\item", "\\section"} {
+		d := strings.Count(candidate, token) - strings.Count(input, token)
+		if d < 0 {
+			d = -d
+		}
+		distance += d
+	}
+	return distance
+}
+
+// nonEmptyLines returns the set of the trimmed non-empty lines of content
+func nonEmptyLines(content string) map[string]bool {
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines[line] = true
+		}
+	}
+	return lines
+}
+
+// firstNonEmptyLine returns the first non-empty line of content and what follows it
+func firstNonEmptyLine(content string) (string, string, bool) {
+	for rest := content; rest != ""; {
+		line, after, _ := strings.Cut(rest, "\n")
+		if strings.TrimSpace(line) != "" {
+			return line, after, true
+		}
+		rest = after
+	}
+	return "", "", false
+}
+
+// lastNonEmptyLine returns the last non-empty line of content and what precedes it,
+// without the line break ending it
+func lastNonEmptyLine(content string) (string, string, bool) {
+	for rest := content; rest != ""; {
+		i := strings.LastIndexByte(rest, '\n')
+		line := rest[i+1:]
+		if strings.TrimSpace(line) != "" {
+			if i == -1 {
+				return line, "", true
+			}
+			return line, rest[:i], true
+		}
+		if i == -1 {
+			break
+		}
+		rest = rest[:i]
+	}
+	return "", "", false
+}
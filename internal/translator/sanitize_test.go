@@ -0,0 +1,86 @@
+package translator
+
+import "testing"
+
+func TestSanitizeModelOutput(t *testing.T) {
+	input := "<<<LATEX_CMD_0>>>\nThe method works well.\n<<<LATEX_CMD_1>>>\n\\item First point.\n<<<LATEX_CMD_2>>>"
+	translation := "<<<LATEX_CMD_0>>>\n该方法效果很好。\n<<<LATEX_CMD_1>>>\n\\item 第一点。\n<<<LATEX_CMD_2>>>"
+
+	tests := []struct {
+		name        string
+		response    string
+		want        string
+		wantRemoved string
+	}{
+		{
+			"clean response",
+			translation,
+			translation,
+			"",
+		},
+		{
+			"latex fence",
+			"```latex\n" + translation + "\n```",
+			translation,
+			"",
+		},
+		{
+			"preamble and fence",
+			"Here is the translated text:\n\n```tex\n" + translation + "\n```\n",
+			translation,
+			"",
+		},
+		{
+			"chinese preamble without fence",
+			"以下是翻译结果：\n" + translation,
+			translation,
+			"以下是翻译结果：",
+		},
+		{
+			"bold preamble and epilogue",
+			"**Translation:**\n" + translation + "\n\nNote: all placeholders and LaTeX commands were preserved exactly.",
+			translation,
+			"**Translation:**\n\n\n\nNote: all placeholders and LaTeX commands were preserved exactly.",
+		},
+		{
+			"unterminated fence of a truncated response",
+			"```latex\n" + translation,
+			translation,
+			"",
+		},
+		{
+			"several fenced blocks",
+			"The original:\n```latex\n" + input + "\n```\nAnd a shorter draft:\n```latex\n该方法效果很好。\n```\nFinal translation:\n```latex\n" + translation + "\n```\nLet me know if you need changes.",
+			translation,
+			"",
+		},
+		{
+			"translated prose that looks like chatter is kept",
+			"注：结果见下表。\n" + translation + "\n好的方法总是简单的。",
+			"注：结果见下表。\n" + translation + "\n好的方法总是简单的。",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := sanitizeModelOutput(tt.response, input)
+			if got != tt.want {
+				t.Errorf("sanitizeModelOutput() = %q, want %q", got, tt.want)
+			}
+			if tt.wantRemoved != "" && removed != tt.wantRemoved {
+				t.Errorf("removed = %q, want %q", removed, tt.wantRemoved)
+			}
+			if (got == tt.response) != (removed == "") {
+				t.Errorf("removed = %q for a response that was changed: %v", removed, got != tt.response)
+			}
+		})
+	}
+}
+
+func TestSanitizeModelOutputKeepsFencesOfTheInput(t *testing.T) {
+	input := "Use the block:\n```\ncode\n```\n"
+	response := "使用以下代码块：\n```\ncode\n```\n"
+	if got, removed := sanitizeModelOutput(response, input); got != response || removed != "" {
+		t.Errorf("sanitizeModelOutput() = %q, removed %q", got, removed)
+	}
+}
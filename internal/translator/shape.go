@@ -0,0 +1,111 @@
+package translator
+
+import (
+	"encoding/json"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// SetRequestShape sets the adjustments of the request body for OpenAI compatible servers
+// that reject some parameters, such as llama.cpp or vLLM serving a local model. A server
+// answering 400 makes the engine adjust the shape further on its own.
+func (t *TranslationEngine) SetRequestShape(shape types.RequestShape) {
+	shape.MaxTokensField = shape.TokensField()
+	t.shapeMu.Lock()
+	defer t.shapeMu.Unlock()
+	t.shape = shape
+}
+
+// RequestShape returns the adjustments of the request body in use, including those made
+// after a server rejected a request
+func (t *TranslationEngine) RequestShape() types.RequestShape {
+	t.shapeMu.Lock()
+	defer t.shapeMu.Unlock()
+	shape := t.shape
+	shape.MaxTokensField = shape.TokensField()
+	return shape
+}
+
+// Adjustments returns the descriptions of the adjustments made after a server rejected
+// a request, in order
+func (t *TranslationEngine) Adjustments() []string {
+	t.shapeMu.Lock()
+	defer t.shapeMu.Unlock()
+	return append([]string(nil), t.adjustments...)
+}
+
+// recordAdjustment moves the shape from old to next and records the adjustment, unless
+// another worker adjusted the shape since old was read
+func (t *TranslationEngine) recordAdjustment(old, next types.RequestShape, adjustment string) {
+	t.shapeMu.Lock()
+	defer t.shapeMu.Unlock()
+	current := t.shape
+	current.MaxTokensField = current.TokensField()
+	if current != old {
+		return
+	}
+	t.shape = next
+	t.adjustments = append(t.adjustments, adjustment)
+}
+
+// shapeRequestBody marshals a request and applies shape to it. Returns the body and the
+// fields it holds.
+func shapeRequestBody(request any, shape types.RequestShape) ([]byte, map[string]bool, error) {
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	if shape.OmitTemperature {
+		delete(fields, "temperature")
+	}
+	if shape.OmitResponseFormat {
+		delete(fields, "response_format")
+	}
+	if limit, ok := fields[types.MaxTokensFieldDefault]; ok {
+		delete(fields, types.MaxTokensFieldDefault)
+		switch shape.TokensField() {
+		case types.MaxTokensFieldDefault:
+			fields[types.MaxTokensFieldDefault] = limit
+		case types.MaxTokensFieldCompletion:
+			fields[types.MaxTokensFieldCompletion] = limit
+		}
+	}
+
+	sent := make(map[string]bool, len(fields))
+	for name := range fields {
+		sent[name] = true
+	}
+	body, err := json.Marshal(fields)
+	return body, sent, err
+}
+
+// adaptRequestShape derives from the error body of a rejected request the shape that
+// leaves out or renames the parameter the server complained about. Only a parameter the
+// request actually held is adjusted, so every adjustment changes the next request.
+// Returns false when the error names no such parameter.
+func adaptRequestShape(shape types.RequestShape, sent map[string]bool, errBody string) (types.RequestShape, string, bool) {
+	message := strings.ToLower(errBody)
+	switch {
+	case sent["temperature"] && strings.Contains(message, "temperature"):
+		shape.OmitTemperature = true
+		return shape, "不发送 temperature", true
+	case sent["response_format"] && strings.Contains(message, "response_format"):
+		shape.OmitResponseFormat = true
+		return shape, "不发送 response_format", true
+	case sent[types.MaxTokensFieldDefault] && strings.Contains(message, types.MaxTokensFieldDefault):
+		shape.MaxTokensField = types.MaxTokensFieldCompletion
+		return shape, "max_tokens 改为 max_completion_tokens", true
+	case sent[types.MaxTokensFieldCompletion] && strings.Contains(message, types.MaxTokensFieldCompletion):
+		shape.MaxTokensField = types.MaxTokensFieldNone
+		return shape, "不发送最大输出 token 数", true
+	}
+	logger.Debug("rejected request names no adjustable parameter", logger.String("response", truncateString(errBody, 200)))
+	return shape, "", false
+}
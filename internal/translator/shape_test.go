@@ -0,0 +1,120 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestShapeRequestBody(t *testing.T) {
+	request := map[string]any{"model": "m", "max_tokens": 100, "temperature": 0.2, "response_format": map[string]string{"type": "text"}}
+	tests := []struct {
+		shape types.RequestShape
+		want  []string
+		drop  []string
+	}{
+		{types.RequestShape{}, []string{"max_tokens", "temperature", "response_format"}, []string{"max_completion_tokens"}},
+		{types.RequestShape{MaxTokensField: "max_completion_tokens", OmitTemperature: true}, []string{"max_completion_tokens", "response_format"}, []string{"max_tokens", "temperature"}},
+		{types.RequestShape{MaxTokensField: "none", OmitResponseFormat: true}, []string{"model", "temperature"}, []string{"max_tokens", "max_completion_tokens", "response_format"}},
+	}
+	for _, tt := range tests {
+		body, sent, err := shapeRequestBody(request, tt.shape)
+		if err != nil {
+			t.Fatalf("shapeRequestBody(%+v) error: %v", tt.shape, err)
+		}
+		var fields map[string]json.RawMessage
+		json.Unmarshal(body, &fields)
+		for _, name := range tt.want {
+			if _, ok := fields[name]; !ok || !sent[name] {
+				t.Errorf("shape %+v: %s not sent in %s", tt.shape, name, body)
+			}
+		}
+		for _, name := range tt.drop {
+			if _, ok := fields[name]; ok || sent[name] {
+				t.Errorf("shape %+v: %s sent in %s", tt.shape, name, body)
+			}
+		}
+	}
+	if body, _, _ := shapeRequestBody(request, types.RequestShape{MaxTokensField: "max_completion_tokens"}); !json.Valid(body) {
+		t.Errorf("shaped body is not valid JSON: %s", body)
+	}
+}
+
+func TestAdaptRequestShape(t *testing.T) {
+	sent := map[string]bool{"model": true, "messages": true, "max_tokens": true, "temperature": true}
+	shape, adjustment, ok := adaptRequestShape(types.RequestShape{}, sent,
+		`{"error":{"message":"Unsupported parameter: 'max_tokens' is not supported with this model. Use 'max_completion_tokens' instead."}}`)
+	if !ok || shape.MaxTokensField != types.MaxTokensFieldCompletion || adjustment == "" {
+		t.Errorf("max_tokens error adapted to %+v, %q, %v", shape, adjustment, ok)
+	}
+
+	shape, _, ok = adaptRequestShape(types.RequestShape{}, sent, `temperature is not supported for this model`)
+	if !ok || !shape.OmitTemperature {
+		t.Errorf("temperature error adapted to %+v, %v", shape, ok)
+	}
+
+	// A parameter the request did not hold is not adjusted
+	if _, _, ok := adaptRequestShape(types.RequestShape{}, sent, `response_format is not supported`); ok {
+		t.Error("adapted a parameter that was not sent")
+	}
+	if _, _, ok := adaptRequestShape(types.RequestShape{}, sent, `model not found`); ok {
+		t.Error("adapted to an error naming no parameter")
+	}
+}
+
+func TestConnectionAdjustsRejectedRequest(t *testing.T) {
+	// A local server that neither streams nor accepts max_tokens
+	var rejections []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var fields map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&fields)
+		if _, ok := fields["max_tokens"]; ok {
+			rejections = append(rejections, "max_tokens")
+			http.Error(w, `{"error":{"message":"Unrecognized request argument supplied: max_tokens"}}`, http.StatusBadRequest)
+			return
+		}
+		if _, ok := fields["stream"]; ok {
+			rejections = append(rejections, "stream")
+			http.Error(w, `{"error":{"message":"invalid request"}}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	adjustments, err := engine.TestConnectionWithAdjustments()
+	if err != nil {
+		t.Fatalf("TestConnectionWithAdjustments() error: %v", err)
+	}
+	if len(adjustments) != 2 {
+		t.Errorf("adjustments = %q, want the max tokens field and streaming", adjustments)
+	}
+	if got := engine.RequestShape().MaxTokensField; got != types.MaxTokensFieldCompletion {
+		t.Errorf("MaxTokensField = %q after the test", got)
+	}
+
+	// The next requests go through at once
+	rejections = nil
+	if _, err := engine.TestConnectionWithAdjustments(); err != nil || len(rejections) != 0 {
+		t.Errorf("second test: error %v, rejections %q", err, rejections)
+	}
+
+	// A rejection naming no parameter is reported as the API error
+	engine.SetStreaming(false)
+	engine.SetRequestShape(types.RequestShape{})
+	strict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"model not found"}}`, http.StatusBadRequest)
+	}))
+	defer strict.Close()
+	engine.SetAPIURL(strict.URL)
+	if _, err := engine.TestConnectionWithAdjustments(); err == nil {
+		t.Error("TestConnectionWithAdjustments() succeeded against a server rejecting everything")
+	}
+}
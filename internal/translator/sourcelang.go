@@ -0,0 +1,78 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"latex-translator/internal/types"
+)
+
+const (
+	// ChineseSourceRatio is the share of Chinese characters among the letters of the prose
+	// above which a source is taken as already Chinese. English papers quoting a Chinese
+	// name or title stay far below it; translated papers keep English terms but stay above.
+	ChineseSourceRatio = 0.3
+	// minLanguageLetters is the number of letters needed to decide the language; shorter
+	// sources are never taken as Chinese
+	minLanguageLetters = 200
+	// maxLanguageSample bounds the bytes of prose examined over all files
+	maxLanguageSample = 64 * 1024
+)
+
+// texCommentPattern matches a comment up to the end of its line, keeping an escaped \%
+var texCommentPattern = regexp.MustCompile(`(?m)(^|[^\\])%.*$`)
+
+// SourceLanguage is the measured script of the prose of a source document
+type SourceLanguage struct {
+	CJKRatio float64 // Chinese characters per letter of the prose
+	Letters  int     // letters sampled
+}
+
+// Chinese reports whether the prose is already mostly Chinese
+func (l SourceLanguage) Chinese() bool {
+	return l.Letters >= minLanguageLetters && l.CJKRatio >= ChineseSourceRatio
+}
+
+// DetectSourceLanguage measures the share of Chinese characters in the prose of the files
+// of a document: their body without preamble, comments, math and commands, up to
+// maxLanguageSample bytes in total.
+func DetectSourceLanguage(contents ...string) SourceLanguage {
+	han, letters, budget := 0, 0, maxLanguageSample
+	for _, content := range contents {
+		if budget <= 0 {
+			break
+		}
+		if i := strings.Index(content, `\begin{document}`); i >= 0 {
+			content = content[i+len(`\begin{document}`):]
+		}
+		content = texCommentPattern.ReplaceAllString(content, "$1")
+		if len(content) > budget {
+			content = strings.ToValidUTF8(content[:budget], "")
+		}
+		budget -= len(content)
+
+		for _, r := range GetNonMathText(content) {
+			switch {
+			case unicode.Is(unicode.Han, r):
+				han++
+				letters++
+			case unicode.IsLetter(r):
+				letters++
+			}
+		}
+	}
+
+	lang := SourceLanguage{Letters: letters}
+	if letters > 0 {
+		lang.CJKRatio = float64(han) / float64(letters)
+	}
+	return lang
+}
+
+// AlreadyChineseError is the error of a job refused because its source is already Chinese
+func AlreadyChineseError(lang SourceLanguage) error {
+	return types.NewAppErrorWithDetails(types.ErrSourceAlreadyChinese, "源文档似乎已是中文",
+		fmt.Sprintf("中文字符占比 %.0f%%，翻译会消耗完整的 token 预算；如确需翻译，请选择继续或使用 --force-translate", lang.CJKRatio*100), nil)
+}
@@ -0,0 +1,42 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"latex-translator/internal/types"
+)
+
+func TestDetectSourceLanguage(t *testing.T) {
+	preamble := "\\documentclass{article}\n\\usepackage{ctex}\n% 中文注释不计入正文\n\\begin{document}\n"
+	english := preamble + strings.Repeat("We study the convergence of gradient descent on $\\mathbb{R}^n$. ", 20) + "\\end{document}\n"
+	chinese := preamble + strings.Repeat("本文研究了梯度下降法在 $\\mathbb{R}^n$ 上的收敛性，并给出了 Transformer 模型的实验。", 20) + "\\end{document}\n"
+
+	if lang := DetectSourceLanguage(english); lang.Chinese() || lang.CJKRatio != 0 {
+		t.Errorf("English source detected as %+v", lang)
+	}
+	if lang := DetectSourceLanguage(chinese); !lang.Chinese() {
+		t.Errorf("Chinese source detected as %+v", lang)
+	}
+	// An English paper quoting a Chinese title stays English
+	quoting := english + "\\cite{张三2020深度学习}\n参见《深度学习》。\n"
+	if lang := DetectSourceLanguage(quoting); lang.Chinese() {
+		t.Errorf("English source quoting Chinese detected as %+v", lang)
+	}
+	// Too little prose to decide
+	if lang := DetectSourceLanguage("\\begin{document}\n中文摘要\n\\end{document}"); lang.Chinese() {
+		t.Errorf("short source detected as %+v", lang)
+	}
+	// The files of a document are measured together
+	if lang := DetectSourceLanguage(english, chinese); lang.Letters <= DetectSourceLanguage(english).Letters {
+		t.Errorf("second file not sampled: %+v", lang)
+	}
+}
+
+func TestAlreadyChineseError(t *testing.T) {
+	err := AlreadyChineseError(SourceLanguage{CJKRatio: 0.82, Letters: 1000})
+	appErr, ok := err.(*types.AppError)
+	if !ok || appErr.Code != types.ErrSourceAlreadyChinese || !strings.Contains(appErr.Details, "82%") {
+		t.Errorf("AlreadyChineseError() = %v", err)
+	}
+}
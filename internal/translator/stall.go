@@ -0,0 +1,171 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// DefaultStallWindow is how long a request for a chunk of MaxChunkSize characters may go
+	// without a response before it is considered stalled; larger chunks get proportionally more
+	DefaultStallWindow = 3 * time.Minute
+	// maxStallRestarts is how often a stalled chunk is retried on a fresh connection before
+	// it is failed
+	maxStallRestarts = 2
+)
+
+// errChunkStalled is the cancellation cause of a request that exceeded its stall window
+var errChunkStalled = errors.New("no response within the stall window")
+
+// stallWatch is the heartbeat of one chunk request: it cancels the request when no
+// response arrived within the window since the last beat
+type stallWatch struct {
+	window time.Duration
+	timer  *time.Timer
+}
+
+// stallWatchKey is the context key of the stallWatch of a request
+type stallWatchKey struct{}
+
+// heartbeat resets the stall window of the chunk request of ctx, if it is watched. Every
+// response of the model (including continuation requests) is a heartbeat.
+func heartbeat(ctx context.Context) {
+	if w, ok := ctx.Value(stallWatchKey{}).(*stallWatch); ok {
+		w.timer.Reset(w.window)
+	}
+}
+
+// SetStallWindow sets how long a chunk request of MaxChunkSize characters may go without
+// a response before it is restarted
+func (t *TranslationEngine) SetStallWindow(d time.Duration) {
+	if d > 0 {
+		t.stallWindow = d
+	}
+}
+
+// stallWindowFor returns the stall window of a chunk, scaled by its size
+func (t *TranslationEngine) stallWindowFor(chunkLen int) time.Duration {
+	window := t.stallWindow
+	if window <= 0 {
+		window = DefaultStallWindow
+	}
+	if chunkLen > MaxChunkSize {
+		window = window * time.Duration(chunkLen) / MaxChunkSize
+	}
+	return window
+}
+
+// setStallListener registers a callback invoked when a chunk request stalled and is
+// restarted. Returns a function that restores the previous listener.
+func (t *TranslationEngine) setStallListener(listener func(restart int, window time.Duration)) func() {
+	t.progressMu.Lock()
+	previous := t.stallListener
+	t.stallListener = listener
+	t.progressMu.Unlock()
+	return func() {
+		t.progressMu.Lock()
+		t.stallListener = previous
+		t.progressMu.Unlock()
+	}
+}
+
+// translateChunkWatched translates a chunk under a stall watch. A request without a
+// response within the window is cancelled and errChunkStalled returned; the worker does
+// not wait for a request that ignores the cancellation. A request cancelled through
+// parent returns the error of parent.
+func (t *TranslationEngine) translateChunkWatched(parent context.Context, chunk string) (string, int, error) {
+	window := t.stallWindowFor(len(chunk))
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	w := &stallWatch{window: window}
+	w.timer = time.AfterFunc(window, func() { cancel(errChunkStalled) })
+	defer w.timer.Stop()
+	ctx = context.WithValue(ctx, stallWatchKey{}, w)
+
+	type outcome struct {
+		translated string
+		tokens     int
+		err        error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		translated, tokens, err := t.doTranslateChunk(ctx, chunk)
+		done <- outcome{translated, tokens, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil && errors.Is(context.Cause(ctx), errChunkStalled) {
+			return "", 0, errChunkStalled
+		}
+		return o.translated, o.tokens, o.err
+	case <-ctx.Done():
+		if parent.Err() != nil {
+			return "", 0, context.Cause(parent)
+		}
+		return "", 0, errChunkStalled
+	}
+}
+
+// handleStall records a stalled chunk request and rebuilds the transport so the retry
+// does not reuse the stuck connection. Returns the error failing the chunk once it
+// stalled more than maxStallRestarts times.
+func (t *TranslationEngine) handleStall(chunkLen, stalls int) error {
+	window := t.stallWindowFor(chunkLen)
+	t.progressMu.Lock()
+	t.progress.Stalls++
+	listener := t.stallListener
+	t.progressMu.Unlock()
+
+	if stalls > maxStallRestarts {
+		logger.Error("chunk request stalled repeatedly, failing the chunk", nil,
+			logger.Int("stalls", stalls),
+			logger.String("window", window.String()))
+		return types.NewAppErrorWithDetails(
+			types.ErrAPICall,
+			"翻译请求多次无响应，该分块翻译失败",
+			fmt.Sprintf("no response within %s in %d attempts", window, stalls),
+			errChunkStalled,
+		)
+	}
+
+	logger.Warn("chunk request stalled, restarting on a fresh connection",
+		logger.Int("stalls", stalls),
+		logger.String("window", window.String()))
+	t.rebuildTransport()
+	if listener != nil {
+		listener(stalls, window)
+	}
+	return nil
+}
+
+// httpClient returns the client for API requests
+func (t *TranslationEngine) httpClient() *http.Client {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+	return t.client
+}
+
+// rebuildTransport replaces the HTTP client with one on a new transport, dropping the
+// pooled connections of the old one. A custom round tripper that is not an
+// *http.Transport is kept.
+func (t *TranslationEngine) rebuildTransport() {
+	t.clientMu.Lock()
+	old := t.client
+	client := &http.Client{Timeout: old.Timeout, Transport: old.Transport}
+	switch tr := old.Transport.(type) {
+	case nil:
+		client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		client.Transport = tr.Clone()
+	}
+	t.client = client
+	t.clientMu.Unlock()
+	old.CloseIdleConnections()
+}
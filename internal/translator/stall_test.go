@@ -0,0 +1,105 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// hangingServer never answers the first hangs requests and translates the others
+func hangingServer(t *testing.T, hangs int32) (*httptest.Server, *int32) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= hangs {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"你好世界"},"finish_reason":"stop"}],"usage":{"total_tokens":10}}`)
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server, &requests
+}
+
+func TestTranslateChunkRestartsStalledRequest(t *testing.T) {
+	server, requests := hangingServer(t, 1)
+
+	// The client timeout would only end the request after a minute
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	engine.SetStallWindow(50 * time.Millisecond)
+	var restarts int32
+	restore := engine.setStallListener(func(restart int, window time.Duration) {
+		atomic.AddInt32(&restarts, 1)
+	})
+	defer restore()
+
+	start := time.Now()
+	translated, tokens, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if err != nil {
+		t.Fatalf("translateChunkWithRetry() error: %v", err)
+	}
+	if translated != "你好世界" || tokens != 10 {
+		t.Errorf("translateChunkWithRetry() = %q, %d", translated, tokens)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stalled request recovered after %s", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+	if got := atomic.LoadInt32(&restarts); got != 1 {
+		t.Errorf("stall listener called %d times, want 1", got)
+	}
+	if got := engine.Progress().Stalls; got != 1 {
+		t.Errorf("Progress().Stalls = %d, want 1", got)
+	}
+}
+
+func TestTranslateChunkFailsAfterRepeatedStalls(t *testing.T) {
+	server, requests := hangingServer(t, 1000)
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	engine.SetStallWindow(30 * time.Millisecond)
+
+	start := time.Now()
+	_, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+	if err == nil {
+		t.Fatal("expected an error when the endpoint never responds")
+	}
+	if appErr, ok := err.(*types.AppError); !ok || appErr.Code != types.ErrAPICall {
+		t.Errorf("expected ErrAPICall, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stalled chunk failed after %s", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != maxStallRestarts+1 {
+		t.Errorf("requests = %d, want %d", got, maxStallRestarts+1)
+	}
+}
+
+func TestStallWindowScalesWithChunkSize(t *testing.T) {
+	engine := NewTranslationEngine("test-key")
+	if got := engine.stallWindowFor(100); got != DefaultStallWindow {
+		t.Errorf("stallWindowFor(100) = %s, want %s", got, DefaultStallWindow)
+	}
+	engine.SetStallWindow(time.Minute)
+	if got := engine.stallWindowFor(MaxChunkSize); got != time.Minute {
+		t.Errorf("stallWindowFor(MaxChunkSize) = %s, want 1m", got)
+	}
+	if got := engine.stallWindowFor(3 * MaxChunkSize); got != 3*time.Minute {
+		t.Errorf("stallWindowFor(3*MaxChunkSize) = %s, want 3m", got)
+	}
+}
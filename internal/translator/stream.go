@@ -0,0 +1,197 @@
+package translator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// streamReportInterval is the minimum time between two received-token updates of a chunk
+const streamReportInterval = 500 * time.Millisecond
+
+// maxStreamLine is the longest server-sent event line accepted
+const maxStreamLine = 4 << 20
+
+// StreamOptions asks the API to append the token usage to a streamed response
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatStreamChunk is one server-sent event of a streamed chat completion
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage    `json:"usage"`
+	Error *APIError `json:"error"`
+}
+
+// SetStreaming sets whether chunk translations are streamed (the default). Streaming
+// reports the tokens received while a chunk is translated, feeds the stall watch on
+// every token and lets a cancelled job tear down its requests at once. Servers that
+// do not stream are handled either way.
+func (t *TranslationEngine) SetStreaming(enabled bool) {
+	t.noStreaming.Store(!enabled)
+}
+
+// SetJobContext sets the context of the current job: when it is done, the chunk
+// requests in flight are torn down and the remaining chunks fail without being sent.
+// nil means a job that is never cancelled.
+func (t *TranslationEngine) SetJobContext(ctx context.Context) {
+	t.progressMu.Lock()
+	defer t.progressMu.Unlock()
+	t.jobCtx = ctx
+}
+
+// jobContext returns the context of the current job
+func (t *TranslationEngine) jobContext() context.Context {
+	t.progressMu.Lock()
+	defer t.progressMu.Unlock()
+	if t.jobCtx == nil {
+		return context.Background()
+	}
+	return t.jobCtx
+}
+
+// cancelledError is the error of a chunk whose job was cancelled, or the budget error of a
+// chunk cancelled because the budget ran out
+func cancelledError(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	if IsBudgetExceeded(cause) {
+		return cause
+	}
+	return types.NewAppError(types.ErrInternal, "翻译已取消", cause)
+}
+
+// streamMeter counts the tokens received for a chunk and reports them at most every
+// streamReportInterval
+type streamMeter struct {
+	mu         sync.Mutex
+	received   int
+	lastReport time.Time
+	report     func(received int)
+}
+
+// streamMeterKey is the context key of the streamMeter of a chunk request
+type streamMeterKey struct{}
+
+// withStreamMeter returns ctx with a meter reporting the tokens received to report
+func withStreamMeter(ctx context.Context, report func(received int)) context.Context {
+	return context.WithValue(ctx, streamMeterKey{}, &streamMeter{report: report})
+}
+
+// resetStreamMeter restarts the count of the chunk of ctx, for a new attempt
+func resetStreamMeter(ctx context.Context) {
+	if m, ok := ctx.Value(streamMeterKey{}).(*streamMeter); ok {
+		m.mu.Lock()
+		m.received = 0
+		m.mu.Unlock()
+	}
+}
+
+// streamed adds tokens to the count of the chunk of ctx, if it is metered
+func streamed(ctx context.Context, tokens int) {
+	m, ok := ctx.Value(streamMeterKey{}).(*streamMeter)
+	if !ok || tokens == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.received += tokens
+	received := m.received
+	due := time.Since(m.lastReport) >= streamReportInterval
+	if due {
+		m.lastReport = time.Now()
+	}
+	m.mu.Unlock()
+	if due {
+		m.report(received)
+	}
+}
+
+// readChatStream assembles a streamed chat completion from its server-sent events.
+// Every event is a heartbeat of the stall watch of ctx, and the content tokens are
+// counted by its stream meter. Without a usage event the tokens are estimated.
+func readChatStream(ctx context.Context, body io.Reader, messages []Message) (*ChatCompletionResponse, error) {
+	var content strings.Builder
+	finishReason := ""
+	var usage *Usage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			// Blank separators, comments (keep-alives) and other fields
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		heartbeat(ctx)
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Error("failed to parse API stream event", err, logger.String("event", truncateString(data, 100)))
+			return nil, types.NewAppError(types.ErrAPICall, "failed to parse API response", err)
+		}
+		if chunk.Error != nil {
+			logger.Error("API returned error in stream", nil, logger.String("errorMessage", chunk.Error.Message))
+			return nil, types.NewAppErrorWithDetails(types.ErrAPICall, "API returned error", chunk.Error.Message, nil)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				streamed(ctx, EstimateTokens(choice.Delta.Content))
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("failed to read API stream", err)
+		return nil, types.NewAppError(types.ErrNetwork, "failed to read API response", err)
+	}
+
+	if usage == nil {
+		prompt := 0
+		for _, m := range messages {
+			prompt += EstimateTokens(m.Content)
+		}
+		completion := EstimateTokens(content.String())
+		usage = &Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+	}
+	if content.Len() == 0 && finishReason == "" {
+		logger.Error("API stream ended without content", nil)
+		return nil, types.NewAppError(types.ErrAPICall, "API returned no choices", nil)
+	}
+	return &ChatCompletionResponse{
+		Object:  "chat.completion",
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: content.String()}, FinishReason: finishReason}},
+		Usage:   *usage,
+	}, nil
+}
+
+// formatTokenCount formats a token count for progress messages: 950, 2.1k
+func formatTokenCount(tokens int) string {
+	if tokens < 1000 {
+		return fmt.Sprintf("%d", tokens)
+	}
+	return fmt.Sprintf("%.1fk", float64(tokens)/1000)
+}
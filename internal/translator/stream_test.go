@@ -0,0 +1,149 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// streamingServer answers streamed requests with the events of "你好世界" and the usage,
+// then holds the stream open until hold is closed or the client goes away
+func streamingServer(t *testing.T, hold chan struct{}) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream || req.StreamOptions == nil {
+			http.Error(w, "expected a streamed request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		for _, delta := range []string{"你好", "世界"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", delta)
+			w.(http.Flusher).Flush()
+			if hold != nil {
+				select {
+				case <-hold:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":6,\"completion_tokens\":4,\"total_tokens\":10}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestTranslateChunkStreamed(t *testing.T) {
+	server, _ := streamingServer(t, nil)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+
+	var reports int32
+	ctx := withStreamMeter(context.Background(), func(received int) {
+		atomic.AddInt32(&reports, 1)
+	})
+	translated, tokens, err := engine.translateChunkWithRetry(ctx, "Hello world")
+	if err != nil {
+		t.Fatalf("translateChunkWithRetry() error: %v", err)
+	}
+	if translated != "你好世界" || tokens != 10 {
+		t.Errorf("translateChunkWithRetry() = %q, %d", translated, tokens)
+	}
+	if atomic.LoadInt32(&reports) == 0 {
+		t.Error("received tokens were never reported")
+	}
+}
+
+func TestStreamingDisabledWhenRejected(t *testing.T) {
+	// A server that does not stream rejects the stream parameters
+	var rejected, plain int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Stream {
+			atomic.AddInt32(&rejected, 1)
+			http.Error(w, `{"error":{"message":"unknown field stream"}}`, http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(&plain, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"你好世界"},"finish_reason":"stop"}],"usage":{"total_tokens":10}}`)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	for i := 0; i < 2; i++ {
+		translated, _, err := engine.translateChunkWithRetry(context.Background(), "Hello world")
+		if err != nil {
+			t.Fatalf("translateChunkWithRetry() error: %v", err)
+		}
+		if translated != "你好世界" {
+			t.Errorf("translateChunkWithRetry() = %q", translated)
+		}
+	}
+	if got := atomic.LoadInt32(&rejected); got != 1 {
+		t.Errorf("server rejected %d streamed requests, want 1", got)
+	}
+	if got := atomic.LoadInt32(&plain); got != 2 {
+		t.Errorf("%d plain requests, want 2", got)
+	}
+}
+
+func TestCancelledJobAbortsStream(t *testing.T) {
+	hold := make(chan struct{})
+	defer close(hold)
+	server, requests := streamingServer(t, hold)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	var once atomic.Bool
+	ctx := withStreamMeter(jobCtx, func(received int) {
+		// Cancel the job once the first tokens arrived
+		if once.CompareAndSwap(false, true) {
+			cancel()
+		}
+	})
+
+	start := time.Now()
+	_, _, err := engine.translateChunkWithRetry(ctx, "Hello world")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("translateChunkWithRetry() error = %v, want a cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled request returned after %s", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("server got %d requests, want 1: a cancelled chunk is not retried", got)
+	}
+	if pauses, _ := engine.breaker.stats(); pauses != 0 {
+		t.Errorf("cancellation tripped the network breaker %d times", pauses)
+	}
+}
+
+func TestTranslateTeXContextCancelled(t *testing.T) {
+	server, _ := hangingServer(t, 1000)
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", time.Minute, 1)
+	engine.SetStallWindow(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := engine.TranslateTeXContext(ctx, "\\section{Intro}\nHello world, this is a paragraph.\n")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("TranslateTeXContext() error = %v, want a cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled translation returned after %s", elapsed)
+	}
+}
@@ -0,0 +1,325 @@
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"latex-translator/internal/logger"
+)
+
+// titleCommandPattern matches the start of a \title command
+var titleCommandPattern = regexp.MustCompile(`\\title\s*(\[|\{)`)
+
+// titleNoteCommands are commands inside \title whose argument is a note
+// (not part of the title text) and must be translated separately
+var titleNoteCommands = []string{`\thanks`, `\footnotetext`, `\footnote`}
+
+// titleSkeletonPattern extracts the command skeleton of a title
+var titleSkeletonPattern = regexp.MustCompile(`\\\\\*?|\\[a-zA-Z@]+\*?|[{}$]`)
+
+// titlePart is one structural unit of a \title argument
+type titlePart struct {
+	kind string // "text", "break" or "note"
+	raw  string // text content, the raw line break command, or the note command with options
+	body string // note argument (only for kind "note")
+}
+
+// parseTitleParts splits a \title argument into plain-text fragments, line breaks
+// (\\, \\[len], \newline) and notes (\thanks{...}, \footnote{...}).
+func parseTitleParts(arg string) []titlePart {
+	var parts []titlePart
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			parts = append(parts, titlePart{kind: "text", raw: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(arg); {
+		rest := arg[i:]
+
+		// Line break: \\ with optional * and [length]
+		if strings.HasPrefix(rest, `\\`) {
+			end := 2
+			if end < len(rest) && rest[end] == '*' {
+				end++
+			}
+			if end < len(rest) && rest[end] == '[' {
+				if close := strings.IndexByte(rest[end:], ']'); close != -1 {
+					end += close + 1
+				}
+			}
+			flushText()
+			parts = append(parts, titlePart{kind: "break", raw: rest[:end]})
+			i += end
+			continue
+		}
+		if strings.HasPrefix(rest, `\newline`) && !isLetterAt(rest, len(`\newline`)) {
+			flushText()
+			parts = append(parts, titlePart{kind: "break", raw: `\newline`})
+			i += len(`\newline`)
+			continue
+		}
+
+		// Notes: \thanks{...}, \footnote[opt]{...}
+		if note, consumed := parseTitleNote(rest); consumed > 0 {
+			flushText()
+			parts = append(parts, note)
+			i += consumed
+			continue
+		}
+
+		// Escaped character or other command: keep the backslash and next byte together
+		if rest[0] == '\\' && len(rest) > 1 {
+			text.WriteString(rest[:2])
+			i += 2
+			continue
+		}
+		text.WriteByte(rest[0])
+		i++
+	}
+	flushText()
+	return parts
+}
+
+// parseTitleNote parses a note command at the start of s.
+// Returns the note and the number of bytes consumed, or 0 if s does not start with a note.
+func parseTitleNote(s string) (titlePart, int) {
+	for _, cmd := range titleNoteCommands {
+		if !strings.HasPrefix(s, cmd) || isLetterAt(s, len(cmd)) {
+			continue
+		}
+		pos := len(cmd)
+		for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+			pos++
+		}
+		if pos < len(s) && s[pos] == '[' {
+			close := strings.IndexByte(s[pos:], ']')
+			if close == -1 {
+				return titlePart{}, 0
+			}
+			pos += close + 1
+		}
+		if pos >= len(s) || s[pos] != '{' {
+			return titlePart{}, 0
+		}
+		end := findMatchingBrace(s, pos)
+		if end == -1 {
+			return titlePart{}, 0
+		}
+		return titlePart{kind: "note", raw: s[:pos], body: s[pos+1 : end]}, end + 1
+	}
+	return titlePart{}, 0
+}
+
+// isLetterAt reports whether s[i] is an ASCII letter (i.e. a command name continues)
+func isLetterAt(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// titleSkeleton returns the command/brace skeleton of a title argument.
+// A correctly translated title has exactly the same skeleton as the original.
+func titleSkeleton(arg string) string {
+	return strings.Join(titleSkeletonPattern.FindAllString(arg, -1), " ")
+}
+
+// ValidateTitleStructure reports whether the translated \title argument has the
+// same command skeleton (line breaks, notes, braces, math) as the original.
+func ValidateTitleStructure(original, translated string) bool {
+	return titleSkeleton(original) == titleSkeleton(translated)
+}
+
+// translateTitleFragment translates a single plain-text fragment, keeping its
+// surrounding whitespace. The original fragment is kept if translation fails
+// or changes the fragment's command skeleton.
+func translateTitleFragment(fragment string, translateFunc func(string) (string, error)) string {
+	trimmed := strings.TrimSpace(fragment)
+	if trimmed == "" || !containsTranslatableText(trimmed) {
+		return fragment
+	}
+
+	translated, err := translateFunc(trimmed)
+	if err != nil {
+		logger.Warn("failed to translate title fragment, keeping original",
+			logger.Err(err),
+			logger.String("fragment", truncateString(trimmed, 50)))
+		return fragment
+	}
+	translated = strings.TrimSpace(translated)
+	if translated == "" || !ValidateTitleStructure(trimmed, translated) {
+		logger.Warn("translated title fragment changed structure, keeping original",
+			logger.String("fragment", truncateString(trimmed, 50)),
+			logger.String("translated", truncateString(translated, 50)))
+		return fragment
+	}
+
+	leading := fragment[:len(fragment)-len(strings.TrimLeft(fragment, " \t\n"))]
+	trailing := fragment[len(strings.TrimRight(fragment, " \t\n")):]
+	return leading + translated + trailing
+}
+
+// TranslateTitle translates a \title argument as a structured unit: plain-text fragments
+// and note arguments are translated independently, while line breaks and note commands
+// are reassembled mechanically so the command structure is always preserved.
+func TranslateTitle(arg string, translateFunc func(string) (string, error)) string {
+	var sb strings.Builder
+	for _, part := range parseTitleParts(arg) {
+		switch part.kind {
+		case "text":
+			sb.WriteString(translateTitleFragment(part.raw, translateFunc))
+		case "note":
+			sb.WriteString(part.raw)
+			sb.WriteString("{")
+			sb.WriteString(translateTitleFragment(part.body, translateFunc))
+			sb.WriteString("}")
+		default:
+			sb.WriteString(part.raw)
+		}
+	}
+
+	result := sb.String()
+	if !ValidateTitleStructure(arg, result) {
+		logger.Warn("translated title structure mismatch, keeping original title",
+			logger.String("original", truncateString(arg, 80)))
+		return arg
+	}
+	return result
+}
+
+// TranslateTitleCommands finds every \title[short]{long} command outside comments and
+// translates its arguments with TranslateTitle.
+func TranslateTitleCommands(content string, translateFunc func(string) (string, error)) string {
+	result := content
+	for searchFrom := 0; searchFrom < len(result); {
+		loc := titleCommandPattern.FindStringIndex(result[searchFrom:])
+		if loc == nil {
+			break
+		}
+		start := searchFrom + loc[0]
+		argPos := searchFrom + loc[1] - 1
+
+		// Skip commented-out titles
+		lineStart := strings.LastIndexByte(result[:start], '\n') + 1
+		if strings.Contains(result[lineStart:start], "%") {
+			searchFrom = argPos + 1
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString(result[start:argPos])
+
+		// Optional short title
+		if result[argPos] == '[' {
+			close := strings.IndexByte(result[argPos:], ']')
+			if close == -1 {
+				searchFrom = argPos + 1
+				continue
+			}
+			short := result[argPos+1 : argPos+close]
+			sb.WriteString("[" + translateTitleFragment(short, translateFunc) + "]")
+			argPos += close + 1
+			for argPos < len(result) && (result[argPos] == ' ' || result[argPos] == '\t') {
+				sb.WriteByte(result[argPos])
+				argPos++
+			}
+			if argPos >= len(result) || result[argPos] != '{' {
+				searchFrom = argPos
+				continue
+			}
+		}
+
+		end := findMatchingBrace(result, argPos)
+		if end == -1 {
+			logger.Warn("unbalanced braces in \\title, skipping structured translation")
+			searchFrom = argPos + 1
+			continue
+		}
+
+		sb.WriteString("{" + TranslateTitle(result[argPos+1:end], translateFunc) + "}")
+		replacement := sb.String()
+		result = result[:start] + replacement + result[end+1:]
+		searchFrom = start + len(replacement)
+	}
+	return result
+}
+
+// protectTitleCommands translates \title commands structurally and replaces them with
+// comment placeholders so the chunk translation cannot mangle them.
+func protectTitleCommands(content string, translateFunc func(string) (string, error)) (string, []commentPlaceholder) {
+	var placeholders []commentPlaceholder
+	result := content
+	for searchFrom := 0; searchFrom < len(result); {
+		loc := titleCommandPattern.FindStringIndex(result[searchFrom:])
+		if loc == nil {
+			break
+		}
+		start := searchFrom + loc[0]
+		lineStart := strings.LastIndexByte(result[:start], '\n') + 1
+		if strings.Contains(result[lineStart:start], "%") {
+			searchFrom = searchFrom + loc[1]
+			continue
+		}
+
+		// Determine the end of the command: optional [short] then {long}
+		pos := searchFrom + loc[1] - 1
+		if result[pos] == '[' {
+			close := strings.IndexByte(result[pos:], ']')
+			if close == -1 {
+				break
+			}
+			pos += close + 1
+			for pos < len(result) && (result[pos] == ' ' || result[pos] == '\t') {
+				pos++
+			}
+		}
+		end := findMatchingBrace(result, pos)
+		if end == -1 {
+			searchFrom = searchFrom + loc[1]
+			continue
+		}
+
+		command := result[start : end+1]
+		placeholder := fmt.Sprintf("%%TITLE_PLACEHOLDER_%d%%", len(placeholders))
+		// The placeholder is a comment, so it must end its line
+		suffix := ""
+		if end+1 < len(result) && result[end+1] != '\n' && result[end+1] != '\r' {
+			suffix = "\n"
+		}
+		placeholders = append(placeholders, commentPlaceholder{
+			placeholder: placeholder + suffix,
+			original:    TranslateTitleCommands(command, translateFunc),
+		})
+		result = result[:start] + placeholder + suffix + result[end+1:]
+		searchFrom = start + len(placeholder) + len(suffix)
+	}
+	return result, placeholders
+}
+
+// restoreTitleCommands restores the structurally translated titles.
+// If the model dropped a placeholder, the title is re-inserted before \begin{document}.
+func restoreTitleCommands(content string, placeholders []commentPlaceholder) string {
+	result := content
+	for _, p := range placeholders {
+		if strings.Contains(result, p.placeholder) {
+			// Drops the newline that was added after the placeholder, if any
+			result = strings.Replace(result, p.placeholder, p.original, 1)
+			continue
+		}
+		bare := strings.TrimSuffix(p.placeholder, "\n")
+		if strings.Contains(result, bare) {
+			result = strings.Replace(result, bare, p.original, 1)
+			continue
+		}
+		logger.Warn("title placeholder lost during translation, re-inserting title")
+		if idx := strings.Index(result, `\begin{document}`); idx != -1 {
+			result = result[:idx] + p.original + "\n" + result[idx:]
+		}
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeTitleTranslate marks each fragment as translated so the result is predictable
+func fakeTitleTranslate(s string) (string, error) {
+	return "译[" + s + "]", nil
+}
+
+func TestTranslateTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain title",
+			input:    `Attention Is All You Need`,
+			expected: `译[Attention Is All You Need]`,
+		},
+		{
+			name:     "title with thanks",
+			input:    `Deep Learning\thanks{This work was supported by NSF.}`,
+			expected: `译[Deep Learning]\thanks{译[This work was supported by NSF.]}`,
+		},
+		{
+			name:     "title with manual line break",
+			input:    `Scaling Laws for\\ Neural Language Models`,
+			expected: `译[Scaling Laws for]\\ 译[Neural Language Models]`,
+		},
+		{
+			name:     "line break with spacing argument",
+			input:    `A Study of Things \\[2mm] Second Line`,
+			expected: `译[A Study of Things] \\[2mm] 译[Second Line]`,
+		},
+		{
+			name:     "footnote with optional argument and newline",
+			input:    "Robust Methods\\footnote[1]{Extended version.}\\newline for Translation",
+			expected: "译[Robust Methods]\\footnote[1]{译[Extended version.]}\\newline 译[for Translation]",
+		},
+		{
+			name:     "thanks with nested braces",
+			input:    `Graph Networks\thanks{Code at \url{https://example.com}.}`,
+			expected: `译[Graph Networks]\thanks{译[Code at \url{https://example.com}.]}`,
+		},
+		{
+			name:     "math-only fragment is kept",
+			input:    `$\alpha$\\ Estimation of Parameters`,
+			expected: `$\alpha$\\ 译[Estimation of Parameters]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TranslateTitle(tt.input, fakeTitleTranslate)
+			if result != tt.expected {
+				t.Errorf("TranslateTitle() =\n%q\nwant:\n%q", result, tt.expected)
+			}
+			if !ValidateTitleStructure(tt.input, result) {
+				t.Errorf("command skeleton changed: %q -> %q", titleSkeleton(tt.input), titleSkeleton(result))
+			}
+		})
+	}
+}
+
+func TestTranslateTitleKeepsStructureOnBadTranslation(t *testing.T) {
+	// A translation that merges the note into the title text must be rejected
+	mangling := func(s string) (string, error) {
+		return s + `\thanks{merged}`, nil
+	}
+	input := `Deep Learning\thanks{Supported by NSF.}`
+	result := TranslateTitle(input, mangling)
+	if result != input {
+		t.Errorf("TranslateTitle() = %q, want original %q", result, input)
+	}
+
+	failing := func(s string) (string, error) {
+		return "", fmt.Errorf("api error")
+	}
+	if result := TranslateTitle(input, failing); result != input {
+		t.Errorf("TranslateTitle() with failing translator = %q, want original", result)
+	}
+}
+
+func TestProtectAndRestoreTitleCommands(t *testing.T) {
+	input := "\\documentclass{article}\n% \\title{Old Title}\n\\title[Short]{Long Title\\thanks{Note.}}\\author{A}\n\\begin{document}\n\\maketitle\n\\end{document}\n"
+
+	protected, placeholders := protectTitleCommands(input, fakeTitleTranslate)
+	if len(placeholders) != 1 {
+		t.Fatalf("expected 1 title placeholder, got %d", len(placeholders))
+	}
+	if strings.Contains(protected, `\title[`) {
+		t.Errorf("title still present in protected content:\n%s", protected)
+	}
+	if !strings.Contains(protected, `% \title{Old Title}`) {
+		t.Errorf("commented title should be left untouched")
+	}
+
+	restored := restoreTitleCommands(protected, placeholders)
+	expected := "\\documentclass{article}\n% \\title{Old Title}\n\\title[译[Short]]{译[Long Title]\\thanks{译[Note.]}}\\author{A}\n\\begin{document}\n\\maketitle\n\\end{document}\n"
+	if restored != expected {
+		t.Errorf("restoreTitleCommands() =\n%q\nwant:\n%q", restored, expected)
+	}
+
+	// Lost placeholder: title is re-inserted before \begin{document}
+	lost := strings.Replace(protected, placeholders[0].placeholder, "", 1)
+	if restored := restoreTitleCommands(lost, placeholders); !strings.Contains(restored, "\\title[译[Short]]") {
+		t.Errorf("lost title was not re-inserted:\n%s", restored)
+	}
+}
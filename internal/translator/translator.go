@@ -3,7 +3,9 @@ package translator
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,16 +13,22 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/netproxy"
+	"latex-translator/internal/parser"
 	"latex-translator/internal/types"
 )
 
 const (
 	// DefaultModel is the default OpenAI model to use for translation
 	DefaultModel = "gpt-4o"
-	// DefaultTimeout is the default HTTP client timeout for API calls
+	// DefaultTimeout is the default timeout of one API request, after which it is cancelled
+	// and retried
 	DefaultTimeout = 120 * time.Second
 	// MaxRetries is the maximum number of retry attempts for API errors
 	MaxRetries = 2
@@ -29,6 +37,12 @@ const (
 	// MaxChunkSize is the maximum size of a text chunk for translation (in characters)
 	// This helps avoid token limits and ensures reliable translation
 	MaxChunkSize = 4000
+	// QuickChunkSize is the chunk size of quick mode: fewer, larger requests finish sooner
+	// at the cost of some fidelity on long chunks
+	QuickChunkSize = 10000
+	// MinChunkSize is the size below which a chunk is merged with its neighbours.
+	// Every chunk repeats the full prompt, so many tiny chunks multiply the cost.
+	MinChunkSize = 1000
 	// OpenAIAPIURL is the OpenAI chat completions API endpoint
 	OpenAIAPIURL = "https://api.openai.com/v1/chat/completions"
 )
@@ -39,10 +53,134 @@ const (
 // Validates: Requirements 3.1, 3.2
 type TranslationEngine struct {
 	apiKey      string
+	clientMu    sync.Mutex // guards client, which rebuildTransport replaces
 	client      *http.Client
 	model       string
 	apiURL      string
 	concurrency int
+
+	// Language of the translation (Chinese unless set)
+	language types.TargetLanguage
+
+	// Script of the generated Chinese text (simplified unless set) and phrases exempt from conversion
+	variant        types.ChineseVariant
+	variantPhrases map[string]string
+
+	// Network outage handling shared by all chunk workers
+	breaker           *networkBreaker
+	maxNetworkPause   time.Duration
+	connectivityProbe func() error // overrides probeConnectivity (used in tests)
+
+	// Maximum chunk size in characters; 0 uses MaxChunkSize
+	chunkSize int
+
+	// Stall window of a chunk request of MaxChunkSize characters; 0 uses DefaultStallWindow
+	stallWindow time.Duration
+
+	// Timeout of one API request; 0 uses DefaultTimeout
+	requestTimeout time.Duration
+
+	// Budget of the current document, when it started and the tokens used since
+	budgetMu     sync.Mutex
+	budget       TranslationBudget
+	budgetStart  time.Time
+	budgetTokens int
+
+	// Persistent translations of the chunks of the current source; nil disables caching
+	chunkCache *ChunkCache
+
+	// Where failed or damaged chunk requests are saved for debugging; nil disables the capture
+	debugCapture *DebugCapture
+
+	// Token usage of the current job by model; nil disables the metering
+	usageMu    sync.Mutex
+	usageMeter *UsageMeter
+
+	// Terms every chunk must translate the same way; nil disables the glossary
+	glossary *Glossary
+
+	// Template of the chunk prompts; nil uses the default one
+	promptTemplate *PromptTemplate
+
+	// Send each chunk with the last paragraph of the previous one as read-only context
+	chunkOverlap bool
+
+	// Sort keys of translated \index entries: IndexSortPinyin ("" too) or IndexSortOriginal
+	indexSort string
+
+	// Send full-line comments to the model; by default they are kept out of the chunks
+	translateComments bool
+
+	// Translate the venues and notes of \bibitem entries; by default reference lists are kept verbatim
+	translateBibliography bool
+
+	// Request plain responses instead of streamed ones (streaming is the default)
+	noStreaming atomic.Bool
+	// Provider of the chat model: types.ProviderOpenAI ("" too) or types.ProviderAnthropic
+	provider string
+	// Adjustments of the request body for servers rejecting some parameters, and the
+	// descriptions of those made after a rejection
+	shapeMu     sync.Mutex
+	shape       types.RequestShape
+	adjustments []string
+	// Context of the current job, cancelling its chunk requests; nil is never cancelled
+	jobCtx context.Context
+
+	// Index term translations so far, shared by the files of a book so a term has one
+	// translation and one index entry
+	indexTermsMu sync.Mutex
+	indexTerms   map[string]string
+
+	// Progress of the current document, for status reporting
+	progressMu    sync.Mutex
+	progress      TranslationProgress
+	stallListener func(restart int, window time.Duration)
+}
+
+// TranslationProgress is a snapshot of the engine's translation progress
+type TranslationProgress struct {
+	Section     string // section of the most recently translated chunk
+	Chunk       int    // chunks of the current document translated so far
+	TotalChunks int    // chunks in the current document
+	TokensUsed  int    // tokens used by this engine so far, across documents
+
+	// Continuation requests for truncated responses and chunks still truncated after
+	// MaxContinuations, so far across documents
+	Continuations   int
+	TruncatedChunks int
+
+	// Chunk requests restarted or failed because no response arrived within the stall
+	// window, so far across documents
+	Stalls int
+
+	// Chunks served from the chunk cache instead of the model, so far across documents
+	CachedChunks int
+
+	// Chunks whose translation came back truncated and were translated in pieces, so far
+	// across documents
+	SplitChunks int
+
+	// Responses wrapped in markdown fences or natural-language comments that were
+	// sanitized, so far across documents
+	SanitizedResponses int
+
+	// Chunks sent again after a failed attempt (API or content error), so far across
+	// documents
+	RetriedChunks int
+}
+
+// Progress returns the progress of the document being translated
+func (t *TranslationEngine) Progress() TranslationProgress {
+	t.progressMu.Lock()
+	defer t.progressMu.Unlock()
+	return t.progress
+}
+
+// updateProgress applies fn to the engine's progress
+func (t *TranslationEngine) updateProgress(fn func(p *TranslationProgress)) {
+	t.progressMu.Lock()
+	defer t.progressMu.Unlock()
+	fn(&t.progress)
 }
 
 // NewTranslationEngine creates a new TranslationEngine with the specified API key.
@@ -51,11 +189,14 @@ func NewTranslationEngine(apiKey string) *TranslationEngine {
 	return &TranslationEngine{
 		apiKey: apiKey,
 		client: &http.Client{
-			Timeout: DefaultTimeout,
+			Transport: netproxy.NewTransport(),
 		},
-		model:       DefaultModel,
-		apiURL:      OpenAIAPIURL,
-		concurrency: 3,
+		requestTimeout:  DefaultTimeout,
+		model:           DefaultModel,
+		apiURL:          OpenAIAPIURL,
+		concurrency:     3,
+		breaker:         newNetworkBreaker(),
+		maxNetworkPause: DefaultMaxNetworkPause,
 	}
 }
 
@@ -64,11 +205,14 @@ func NewTranslationEngineWithModel(apiKey, model string) *TranslationEngine {
 	return &TranslationEngine{
 		apiKey: apiKey,
 		client: &http.Client{
-			Timeout: DefaultTimeout,
+			Transport: netproxy.NewTransport(),
 		},
-		model:       model,
-		apiURL:      OpenAIAPIURL,
-		concurrency: 3,
+		requestTimeout:  DefaultTimeout,
+		model:           model,
+		apiURL:          OpenAIAPIURL,
+		concurrency:     3,
+		breaker:         newNetworkBreaker(),
+		maxNetworkPause: DefaultMaxNetworkPause,
 	}
 }
 
@@ -92,11 +236,14 @@ func NewTranslationEngineWithConfig(apiKey, model, apiURL string, timeout time.D
 	return &TranslationEngine{
 		apiKey: apiKey,
 		client: &http.Client{
-			Timeout: timeout,
+			Transport: netproxy.NewTransport(),
 		},
-		model:       model,
-		apiURL:      apiURL,
-		concurrency: concurrency,
+		requestTimeout:  timeout,
+		model:           model,
+		apiURL:          apiURL,
+		concurrency:     concurrency,
+		breaker:         newNetworkBreaker(),
+		maxNetworkPause: DefaultMaxNetworkPause,
 	}
 }
 
@@ -117,6 +264,20 @@ func normalizeAPIURL(url string) string {
 	return result
 }
 
+// SetChunkSize sets the maximum chunk size in characters for TranslateTeX (0 restores
+// MaxChunkSize). Larger chunks mean fewer requests.
+func (t *TranslationEngine) SetChunkSize(size int) {
+	t.chunkSize = size
+}
+
+// maxChunkSize returns the maximum chunk size in characters
+func (t *TranslationEngine) maxChunkSize() int {
+	if t.chunkSize > 0 {
+		return t.chunkSize
+	}
+	return MaxChunkSize
+}
+
 // GetAPIKey returns the API key used by the engine.
 func (t *TranslationEngine) GetAPIKey() string {
 	return t.apiKey
@@ -127,6 +288,11 @@ func (t *TranslationEngine) GetModel() string {
 	return t.model
 }
 
+// GetConcurrency returns the number of chunks translated at the same time.
+func (t *TranslationEngine) GetConcurrency() int {
+	return t.concurrency
+}
+
 // SetModel sets the model to use for translation.
 func (t *TranslationEngine) SetModel(model string) {
 	t.model = model
@@ -137,97 +303,91 @@ func (t *TranslationEngine) SetAPIURL(url string) {
 	t.apiURL = url
 }
 
+// SetTransport replaces the transport of the engine's HTTP client, e.g. to try a
+// proxy from the settings form before it is saved
+func (t *TranslationEngine) SetTransport(tr http.RoundTripper) {
+	t.clientMu.Lock()
+	t.client = &http.Client{Transport: tr}
+	t.clientMu.Unlock()
+}
+
 // TestConnection tests the API connection by sending a simple request.
 // It asks the LLM to respond with just "ok" to minimize token usage.
 // Returns nil if successful, or an error if the connection fails.
 func (t *TranslationEngine) TestConnection() error {
-	logger.Info("testing API connection", logger.String("apiURL", t.apiURL), logger.String("model", t.model))
+	_, err := t.TestConnectionWithAdjustments()
+	return err
+}
 
-	if t.apiKey == "" {
-		return types.NewAppError(types.ErrConfig, "API key is not configured", nil)
-	}
+// connectionTestMaxTokens is the output limit of the connection test; it is sent so a
+// server rejecting the max tokens field is detected by the test already
+const connectionTestMaxTokens = 512
 
-	// Build a minimal test request
-	reqBody := ChatCompletionRequest{
-		Model: t.model,
-		Messages: []Message{
-			{Role: "user", Content: "Reply with only the word 'ok', nothing else."},
-		},
-	}
+// TestConnectionWithAdjustments is TestConnection through the request path of the
+// translations: a server rejecting some parameters gets the request adjusted the way
+// a translation would. Returns the descriptions of the adjustments that were needed;
+// RequestShape returns the shape that worked.
+func (t *TranslationEngine) TestConnectionWithAdjustments() ([]string, error) {
+	logger.Info("testing API connection", logger.String("provider", t.Provider()), logger.String("apiURL", t.endpoint()), logger.String("model", t.model))
 
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		logger.Error("failed to marshal test request", err)
-		return types.NewAppError(types.ErrInternal, "failed to create test request", err)
+	if t.apiKey == "" {
+		return nil, types.NewAppError(types.ErrConfig, "API key is not configured", nil)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest(http.MethodPost, t.apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		logger.Error("failed to create HTTP request", err)
-		return types.NewAppError(types.ErrInternal, "failed to create HTTP request", err)
+	adjustedBefore := len(t.Adjustments())
+	messages := []Message{
+		{Role: "user", Content: "Reply with only the word 'ok', nothing else."},
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
-
-	// Send the request
-	resp, err := t.client.Do(req)
+	chatResp, err := t.chatCompletionContext(context.Background(), messages, connectionTestMaxTokens)
 	if err != nil {
 		logger.Error("API test request failed", err)
-		return types.NewAppError(types.ErrNetwork, "API 连接失败", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("failed to read API response", err)
-		return types.NewAppError(types.ErrNetwork, "读取响应失败", err)
-	}
-
-	// Handle HTTP errors
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("API test returned error status", nil, logger.Int("statusCode", resp.StatusCode))
-		return handleAPIHTTPError(resp.StatusCode, body)
-	}
-
-	// Parse response to verify it's valid
-	var chatResp ChatCompletionResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		logger.Error("failed to parse API response", err)
-		return types.NewAppError(types.ErrAPICall, "响应格式错误", err)
-	}
-
-	// Check for API error in response
-	if chatResp.Error != nil {
-		logger.Error("API returned error", nil, logger.String("errorMessage", chatResp.Error.Message))
-		return types.NewAppErrorWithDetails(types.ErrAPICall, "API 错误", chatResp.Error.Message, nil)
-	}
-
-	// Verify LLM actually responded
-	if len(chatResp.Choices) == 0 {
-		logger.Error("API returned no choices", nil)
-		return types.NewAppError(types.ErrAPICall, "LLM 未返回响应", nil)
+		if appErr, ok := err.(*types.AppError); ok && appErr.Code == types.ErrNetwork {
+			return nil, types.NewAppError(types.ErrNetwork, "API 连接失败", err)
+		}
+		return nil, err
 	}
 
 	// Check if LLM response contains "ok"
 	llmResponse := strings.ToLower(strings.TrimSpace(chatResp.Choices[0].Message.Content))
 	logger.Info("LLM test response", logger.String("response", llmResponse))
-	
+
 	if !strings.Contains(llmResponse, "ok") {
 		logger.Error("LLM did not respond with 'ok'", nil, logger.String("response", llmResponse))
-		return types.NewAppErrorWithDetails(types.ErrAPICall, "LLM 响应异常", 
+		return nil, types.NewAppErrorWithDetails(types.ErrAPICall, "LLM 响应异常",
 			fmt.Sprintf("期望返回 'ok'，实际返回: %s", llmResponse), nil)
 	}
 
-	logger.Info("API connection test successful - LLM responded correctly")
-	return nil
+	adjustments := t.Adjustments()[adjustedBefore:]
+	logger.Info("API connection test successful - LLM responded correctly", logger.Int("adjustments", len(adjustments)))
+	return adjustments, nil
+}
+
+// ChunkProgress is the progress of a document translation reported to a ProgressFunc
+type ChunkProgress struct {
+	Chunk       int    // chunks of the document translated so far
+	TotalChunks int    // chunks in the document
+	Section     string // section of the chunk the report is about, if known
+	Message     string // description for display
+	TokensUsed  int    // tokens used by the engine so far, across documents
 }
 
-// TranslationProgressCallback is called during translation to report progress
+// ProgressFunc is called during translation to report progress
+type ProgressFunc func(progress ChunkProgress)
+
+// TranslationProgressCallback is the progress callback of TranslateTeXWithProgress and
+// TranslateTeXWithReference, which only pass the chunk counts and the message
 type TranslationProgressCallback func(current, total int, message string)
 
+// ProgressFunc adapts the callback to TranslateTeXWithProgressContext
+func (cb TranslationProgressCallback) ProgressFunc() ProgressFunc {
+	if cb == nil {
+		return nil
+	}
+	return func(p ChunkProgress) {
+		cb(p.Chunk, p.TotalChunks, p.Message)
+	}
+}
+
 // TranslateTeX translates a complete LaTeX document from English to Chinese.
 // It handles large documents by splitting them into chunks and translating each chunk separately.
 // All LaTeX commands and mathematical formulas are preserved during translation.
@@ -236,13 +396,32 @@ type TranslationProgressCallback func(current, total int, message string)
 // translated content, and the total number of tokens used.
 //
 // Validates: Requirements 3.1, 3.2
+//
+// Deprecated: use TranslateTeXContext. TranslateTeX runs under the context set with
+// SetJobContext, context.Background() when none is set.
 func (t *TranslationEngine) TranslateTeX(content string) (*types.TranslationResult, error) {
-	return t.TranslateTeXWithProgress(content, nil)
+	return t.TranslateTeXContext(t.jobContext(), content)
+}
+
+// TranslateTeXContext translates a complete LaTeX document like TranslateTeX. When ctx is
+// done the chunk requests in flight are torn down and the remaining chunks fail.
+func (t *TranslationEngine) TranslateTeXContext(ctx context.Context, content string) (*types.TranslationResult, error) {
+	return t.TranslateTeXWithProgressContext(ctx, content, nil)
 }
 
 // TranslateTeXWithProgress translates a LaTeX document with progress callback.
-// The callback is called after each chunk is translated with (current, total, message).
+//
+// Deprecated: use TranslateTeXWithProgressContext. TranslateTeXWithProgress runs under the
+// context set with SetJobContext, context.Background() when none is set.
 func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCallback TranslationProgressCallback) (*types.TranslationResult, error) {
+	return t.TranslateTeXWithProgressContext(t.jobContext(), content, progressCallback.ProgressFunc())
+}
+
+// TranslateTeXWithProgressContext translates a LaTeX document with progress callback.
+// The callback is called after each chunk is translated, while chunks stream in and on
+// network pauses. When ctx is done the chunk requests in flight are torn down and the
+// remaining chunks fail.
+func (t *TranslationEngine) TranslateTeXWithProgressContext(ctx context.Context, content string, progressCallback ProgressFunc) (*types.TranslationResult, error) {
 	logger.Info("starting LaTeX translation", logger.Int("contentLength", len(content)), logger.Int("concurrency", t.concurrency))
 
 	if t.apiKey == "" {
@@ -259,37 +438,48 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 		}, nil
 	}
 
-	// Protect comment environments - they should not be translated
-	// The comment package in LaTeX treats everything between \begin{comment} and \end{comment} as comments
-	contentWithProtectedComments, commentPlaceholders := protectCommentEnvironments(content)
-	if len(commentPlaceholders) > 0 {
-		logger.Info("protected comment environments", logger.Int("count", len(commentPlaceholders)))
-	}
+	pausesBefore, pausedBefore := t.breaker.stats()
+	progressBefore := t.Progress()
 
-	// Note: Caption pre-translation is disabled for now because it may cause issues
-	// with the main translation flow. The table/figure environments are protected
-	// in protectedEnvNames, so their structure will be preserved.
-	// TODO: Re-enable caption translation after fixing the issues
-	contentWithTranslatedCaptions := contentWithProtectedComments
+	generator := DetectGenerator(content)
+	if generator != "" {
+		logger.Info("source generated by a tool, protecting its code chunks and helper macros",
+			logger.String("generator", generator))
+	}
 
-	// Note: Preprocessing (comment removal) is disabled for now because:
-	// 1. Some documents have intentionally commented-out code that affects structure
-	// 2. Removing comments can change the document structure unexpectedly
-	// 3. The post-processor can handle most issues without preprocessing
-	// 
-	// To enable preprocessing in the future, uncomment the following:
-	// preprocessedContent := PreprocessLaTeX(content, DefaultPreprocessOptions())
-	// and use preprocessedContent instead of content for splitting
+	// The prompts of the chunks name the macros the document defines, so the model keeps them
+	ctx = withUserMacros(ctx, userMacroNames(content))
 
-	// Split content into chunks for translation
-	chunks := splitIntoChunks(contentWithTranslatedCaptions, MaxChunkSize)
+	// Protect data blobs, comment environments and \title, then split into chunks.
+	// PreviewChunks runs exactly the same preparation without calling the model.
+	titleTokens := 0
+	plan := planChunks(content, t.maxChunkSize(), !t.translateComments, t.translateBibliography, func(fragment string) (string, error) {
+		translated, tokens, err := t.translateChunkCached(ctx, fragment)
+		titleTokens += tokens
+		return translated, err
+	})
+	contentWithoutBlobs := plan.contentWithoutBlobs
+	blobPlaceholders := plan.blobPlaceholders
+	skippedBlobs := plan.skippedBlobs
+	commentPlaceholders := plan.commentPlaceholders
+	titlePlaceholders := plan.titlePlaceholders
+	chunks := plan.chunks
+	sections := plan.sections
 	totalChunks := len(chunks)
 	logger.Info("content split into chunks", logger.Int("chunkCount", totalChunks))
+	t.updateProgress(func(p *TranslationProgress) {
+		p.Section = ""
+		p.Chunk = 0
+		p.TotalChunks = totalChunks
+		p.TokensUsed += titleTokens
+	})
 
 	// Prepare result storage
 	translatedChunks := make([]string, totalChunks)
 	tokenCounts := make([]int, totalChunks)
 	errors := make([]error, totalChunks)
+	paragraphFixes := 0
+	normalizedChars := 0
 
 	// Use semaphore for concurrency control
 	sem := make(chan struct{}, t.concurrency)
@@ -297,10 +487,58 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 	var completedCount int32
 	var mu sync.Mutex
 
+	// With a glossary the first chunk is translated alone, so the terms learned from it
+	// reach the prompts of all other chunks
+	glossary := t.glossary
+	var glossaryCorrections []types.GlossaryCorrection
+	firstChunkDone := make(chan struct{})
+	if glossary == nil {
+		close(firstChunkDone)
+	}
+
+	// report passes the progress of the document to the progress callback
+	report := func(completed int, section, message string) {
+		progressCallback(ChunkProgress{
+			Chunk:       completed,
+			TotalChunks: totalChunks,
+			Section:     section,
+			Message:     message,
+			TokensUsed:  t.Progress().TokensUsed,
+		})
+	}
+
+	// Report network pauses through the progress callback
+	if progressCallback != nil {
+		restoreListener := t.breaker.setListener(func(paused bool) {
+			mu.Lock()
+			completed := int(completedCount)
+			mu.Unlock()
+			if paused {
+				report(completed, "", "网络中断，等待恢复…")
+			} else {
+				report(completed, "", "网络已恢复，继续翻译...")
+			}
+		})
+		defer restoreListener()
+		restoreStallListener := t.setStallListener(func(restart int, window time.Duration) {
+			mu.Lock()
+			completed := int(completedCount)
+			mu.Unlock()
+			report(completed, "", fmt.Sprintf("翻译请求超过 %s 无响应，已重建连接并重试（第 %d 次）...", window.Round(time.Second), restart))
+		})
+		defer restoreStallListener()
+	}
+
+	jobCtx := ctx
 	for i, chunk := range chunks {
 		wg.Add(1)
 		go func(idx int, chunkContent string) {
 			defer wg.Done()
+			if idx > 0 {
+				<-firstChunkDone
+			} else if glossary != nil {
+				defer close(firstChunkDone)
+			}
 
 			// Acquire semaphore
 			sem <- struct{}{}
@@ -309,19 +547,40 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 			chunkNum := idx + 1
 			logger.Debug("translating chunk", logger.Int("chunkIndex", chunkNum), logger.Int("totalChunks", totalChunks))
 
-			translated, tokens, err := t.translateChunkWithRetry(chunkContent)
+			// The end of the previous chunk shows the model the sentence this one continues
+			ctx := jobCtx
+			if t.chunkOverlap && idx > 0 {
+				ctx = withPrecedingContext(ctx, precedingContext(chunks[idx-1]))
+			}
+
+			// Report the tokens received while the chunk streams in
+			if progressCallback != nil {
+				ctx = withStreamMeter(ctx, func(received int) {
+					mu.Lock()
+					completed := int(completedCount)
+					mu.Unlock()
+					if sections[idx] != "" {
+						report(completed, sections[idx], fmt.Sprintf("翻译中：%s (块 %d/%d) — 已接收 %s tokens", sections[idx], chunkNum, totalChunks, formatTokenCount(received)))
+					} else {
+						report(completed, "", fmt.Sprintf("正在翻译块 %d/%d — 已接收 %s tokens", chunkNum, totalChunks, formatTokenCount(received)))
+					}
+				})
+			}
+
+			translated, tokens, err := t.translateChunkCached(ctx, chunkContent)
 
 			// Post-process each chunk immediately after translation
-			// Compare with original chunk to fix format issues
+			normalized, breakFixes := 0, 0
 			if err == nil && translated != "" {
-				beforeLen := len(translated)
-				translated = PostprocessChunk(translated, chunkContent)
-				afterLen := len(translated)
-				if beforeLen != afterLen {
-					logger.Info("postprocessor changed chunk",
-						logger.Int("chunkIndex", chunkNum),
-						logger.Int("beforeLen", beforeLen),
-						logger.Int("afterLen", afterLen))
+				translated, normalized, breakFixes = polishChunk(translated, chunkContent, chunkNum)
+			}
+
+			// Terms the model left in English get their glossary rendering
+			var corrections []types.GlossaryCorrection
+			if err == nil && translated != "" && glossary != nil {
+				translated, corrections = glossary.enforce(translated)
+				if idx == 0 {
+					glossary.learn(chunkContent, translated)
 				}
 			}
 
@@ -329,13 +588,26 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 			translatedChunks[idx] = translated
 			tokenCounts[idx] = tokens
 			errors[idx] = err
+			paragraphFixes += breakFixes
+			normalizedChars += normalized
+			glossaryCorrections = MergeGlossaryCorrections(glossaryCorrections, corrections)
 			completedCount++
 			completed := int(completedCount)
 			mu.Unlock()
 
+			t.updateProgress(func(p *TranslationProgress) {
+				p.Section = sections[idx]
+				p.Chunk = completed
+				p.TokensUsed += tokens
+			})
+
 			// Report progress after translating
 			if progressCallback != nil {
-				progressCallback(completed, totalChunks, fmt.Sprintf("翻译中 (%d/%d 分块)...", completed, totalChunks))
+				if sections[idx] != "" {
+					report(completed, sections[idx], fmt.Sprintf("翻译中：%s (块 %d/%d)", sections[idx], completed, totalChunks))
+				} else {
+					report(completed, "", fmt.Sprintf("翻译中 (%d/%d 分块)...", completed, totalChunks))
+				}
 			}
 
 			if err != nil {
@@ -349,9 +621,22 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 	// Wait for all translations to complete
 	wg.Wait()
 
-	// Check for errors
-	for i, err := range errors {
+	// Check for errors. A chunk that failed after its retries keeps its original text
+	// between marker comments so the rest of the document is not lost; cancellation, the
+	// budget, network outages and a document without a single translated chunk still fail
+	// the whole translation.
+	failedCount := 0
+	for _, err := range errors {
 		if err != nil {
+			failedCount++
+		}
+	}
+	var failedChunks []int
+	for i, err := range errors {
+		if err == nil {
+			continue
+		}
+		if jobCtx.Err() != nil || !isChunkFailure(err) || failedCount == totalChunks {
 			// If it's already an AppError, add chunk info to details and return it
 			if appErr, ok := err.(*types.AppError); ok {
 				appErr.Details = fmt.Sprintf("chunk %d: %s", i+1, appErr.Details)
@@ -365,10 +650,21 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 				err,
 			)
 		}
+		failedChunks = append(failedChunks, i+1)
+		marked := markFailedChunk(i+1, chunks[i])
+		if i > 0 && !strings.HasSuffix(translatedChunks[i-1], "\n") {
+			marked = "\n" + marked
+		}
+		translatedChunks[i] = marked
+	}
+	if len(failedChunks) > 0 {
+		logger.Warn("kept the original text of failed chunks",
+			logger.String("chunks", FormatChunkNumbers(failedChunks)),
+			logger.Int("totalChunks", totalChunks))
 	}
 
 	// Calculate total tokens
-	totalTokens := 0
+	totalTokens := titleTokens
 	for _, tokens := range tokenCounts {
 		totalTokens += tokens
 	}
@@ -376,23 +672,77 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 	// Join translated chunks back together
 	translatedContent := strings.Join(translatedChunks, "")
 
-	// Restore protected comment environments
+	// Put back the comment lines kept out of the chunks
+	if len(plan.commentLines) > 0 {
+		var lost int
+		translatedContent, lost = restoreCommentLines(translatedContent, plan.commentLines)
+		logger.Info("restored comment lines",
+			logger.Int("blocks", len(plan.commentLines)),
+			logger.Int("lost", lost))
+	}
+
+	// Restore structurally translated titles
+	if len(titlePlaceholders) > 0 {
+		translatedContent = restoreTitleCommands(translatedContent, titlePlaceholders)
+	}
+
+	// \index arguments are protected in the chunks; translate them as a term list so the
+	// book gets an index in the target language
+	translatedContent, indexTokens, indexEntries := t.translateIndexEntries(translatedContent)
+	if indexEntries > 0 {
+		totalTokens += indexTokens
+		t.updateProgress(func(p *TranslationProgress) {
+			p.TokensUsed += indexTokens
+		})
+		logger.Info("translated index entries", logger.Int("entries", indexEntries))
+	}
+
+	// Restore protected algorithms, reference lists, code chunks, macro definitions, listings
+	// and comment environments; each may contain placeholders of the ones after it, so they
+	// go first
+	if len(plan.algorithmPlaceholders) > 0 {
+		translatedContent = restoreCommentEnvironments(translatedContent, plan.algorithmPlaceholders)
+		logger.Info("restored algorithm environments", logger.Int("count", len(plan.algorithmPlaceholders)))
+	}
+	if len(plan.bibPlaceholders) > 0 {
+		translatedContent = restoreCommentEnvironments(translatedContent, plan.bibPlaceholders)
+		logger.Info("restored bibliographies", logger.Int("count", len(plan.bibPlaceholders)))
+	}
+	if len(plan.codePlaceholders) > 0 {
+		translatedContent = restoreCommentEnvironments(translatedContent, plan.codePlaceholders)
+		logger.Info("restored code chunks", logger.Int("count", len(plan.codePlaceholders)))
+	}
+	if len(plan.macroPlaceholders) > 0 {
+		translatedContent = restoreCommentEnvironments(translatedContent, plan.macroPlaceholders)
+		logger.Info("restored macro definitions", logger.Int("count", len(plan.macroPlaceholders)))
+	}
+	if len(plan.verbatimPlaceholders) > 0 {
+		translatedContent = restoreCommentEnvironments(translatedContent, plan.verbatimPlaceholders)
+		logger.Info("restored verbatim environments", logger.Int("count", len(plan.verbatimPlaceholders)))
+	}
 	if len(commentPlaceholders) > 0 {
 		translatedContent = restoreCommentEnvironments(translatedContent, commentPlaceholders)
 		logger.Info("restored comment environments", logger.Int("count", len(commentPlaceholders)))
 	}
 
-	// Final fixes on the complete translated content
+	// Final fixes on the complete translated content; the listings are masked in both
+	// versions so the fixes neither count nor change what is inside them
+	maskedContent, restoreVerbatim := parser.ProtectVerbatim(translatedContent)
+	maskedOriginal, _ := parser.ProtectVerbatim(contentWithoutBlobs)
+
 	// Fix LaTeX line structure issues caused by LLM merging lines
-	translatedContent = FixLaTeXLineStructure(translatedContent)
+	maskedContent = FixLaTeXLineStructure(maskedContent)
 
 	// Apply reference-based fixes using original content
 	// This compares the translated content with the original to fix structural issues
-	translatedContent = ApplyReferenceBasedFixes(translatedContent, content)
+	// Data blobs are still protected here so they don't skew the comparison
+	maskedContent = ApplyReferenceBasedFixes(maskedContent, maskedOriginal)
+	translatedContent = restoreVerbatim(maskedContent)
 
 	// Validate the translation result to detect anomalies
 	validator := NewTranslationValidator()
-	validationResult := validator.ValidateTranslation(content, translatedContent)
+	validator.Language = t.language
+	validationResult := validator.ValidateTranslation(contentWithoutBlobs, translatedContent)
 	
 	if !validationResult.IsValid {
 		errorMsg := FormatValidationErrors(validationResult)
@@ -415,22 +765,231 @@ func (t *TranslationEngine) TranslateTeXWithProgress(content string, progressCal
 		logger.Warn("translation validation warning", logger.String("warning", warning))
 	}
 
+	// Restore embedded data blobs
+	if len(blobPlaceholders) > 0 {
+		translatedContent = restoreCommentEnvironments(translatedContent, blobPlaceholders)
+		logger.Info("restored embedded data blobs", logger.Int("count", len(blobPlaceholders)))
+	}
+
+	// Dead content after the end of the document is kept byte for byte
+	translatedContent += plan.trailing
+
+	pausesAfter, pausedAfter := t.breaker.stats()
+	progressAfter := t.Progress()
+
 	logger.Info("translation completed successfully", 
 		logger.Int("totalTokens", totalTokens),
+		logger.Int("continuations", progressAfter.Continuations-progressBefore.Continuations),
+		logger.Int("networkPauses", pausesAfter-pausesBefore),
+		logger.Int("stalls", progressAfter.Stalls-progressBefore.Stalls),
+		logger.Int("cachedChunks", progressAfter.CachedChunks-progressBefore.CachedChunks),
+		logger.Int("splitChunks", progressAfter.SplitChunks-progressBefore.SplitChunks),
+		logger.Int("sanitizedResponses", progressAfter.SanitizedResponses-progressBefore.SanitizedResponses),
+		logger.Int("retriedChunks", progressAfter.RetriedChunks-progressBefore.RetriedChunks),
+		logger.Int("failedChunks", len(failedChunks)),
+		logger.String("promptPreset", t.PromptTemplate().Name),
+		logger.Int("paragraphBreakFixes", paragraphFixes),
+		logger.Int("normalizedCharacters", normalizedChars),
+		logger.Int("glossaryCorrections", len(glossaryCorrections)),
 		logger.Int("chineseCharCount", validationResult.ChineseCharCount),
 		logger.Float64("lengthRatio", validationResult.LengthRatio))
 	return &types.TranslationResult{
-		OriginalContent:   content,
-		TranslatedContent: translatedContent,
-		TokensUsed:        totalTokens,
+		OriginalContent:      content,
+		TranslatedContent:    translatedContent,
+		TokensUsed:           totalTokens,
+		SkippedDataBlobs:     skippedBlobs,
+		NetworkPauses:        pausesAfter - pausesBefore,
+		NetworkPausedSecs:    (pausedAfter - pausedBefore).Seconds(),
+		ParagraphBreakFixes:  paragraphFixes,
+		SkippedTrailingBytes: len(plan.trailing),
+		Continuations:        progressAfter.Continuations - progressBefore.Continuations,
+		TruncatedChunks:      progressAfter.TruncatedChunks - progressBefore.TruncatedChunks,
+		Stalls:               progressAfter.Stalls - progressBefore.Stalls,
+		CachedChunks:         progressAfter.CachedChunks - progressBefore.CachedChunks,
+		SplitChunks:          progressAfter.SplitChunks - progressBefore.SplitChunks,
+		SanitizedResponses:   progressAfter.SanitizedResponses - progressBefore.SanitizedResponses,
+		RetriedChunks:        progressAfter.RetriedChunks - progressBefore.RetriedChunks,
+		FailedChunks:         failedChunks,
+		PromptPreset:         t.PromptTemplate().Name,
+		Generator:            generator,
+		GlossaryCorrections:  glossaryCorrections,
 	}, nil
 }
 
+// chunkPlan holds a document prepared for chunked translation
+type chunkPlan struct {
+	trailing            string // content after \end{document} or \endinput; TeX never reads it
+	contentWithoutBlobs string
+	blobPlaceholders    []commentPlaceholder
+	skippedBlobs        []types.DataBlob
+	commentPlaceholders []commentPlaceholder
+	codePlaceholders    []commentPlaceholder // knitr/Sweave code chunks and generated code environments
+	verbatimPlaceholders  []commentPlaceholder // verbatim-like environments (parser.VerbatimRanges)
+	macroPlaceholders     []commentPlaceholder // \newcommand, \def, ... definitions
+	algorithmPlaceholders []commentPlaceholder // algorithm environments, captions translated
+	bibPlaceholders     []commentPlaceholder // thebibliography environments, translated or not
+	titlePlaceholders   []commentPlaceholder
+	commentLines        []commentPlaceholder // runs of full-line comments kept out of the chunks
+	prepared            string   // content actually split into chunks
+	chunks              []string // chunks in document order; they concatenate to prepared
+	sections            []string // for each chunk, the outline section it starts in ("" before the first section)
+}
+
+// planChunks protects everything that is not sent to the chunk translation (content after
+// the end of the document, data blobs, comment environments, \title, reference lists and,
+// with stripComments, full-line comments) and splits the remaining content into chunks of
+// at most maxChunkSize characters. With translateBibliography the venues and notes of
+// reference lists are translated through translateTitle before they are protected.
+// translateTitle is used to translate \title fragments and float captions; the title is
+// replaced by a placeholder either way, so the chunks do not depend on its translation.
+// Translated captions are spliced into the chunks, so a translateTitle that returns its
+// input leaves the chunks as they would be without caption translation.
+func planChunks(content string, maxChunkSize int, stripComments, translateBibliography bool, translateTitle func(string) (string, error)) *chunkPlan {
+	plan := &chunkPlan{}
+
+	// Content after the effective end of the file is dead: it is neither translated nor
+	// validated, and is appended to the translation verbatim
+	content, plan.trailing = parser.SplitAtDocumentEnd(content)
+	if strings.TrimSpace(plan.trailing) != "" {
+		logger.Info("excluded content after end of document",
+			logger.Int("bytes", len(plan.trailing)))
+	}
+
+	// Protect embedded data blobs (filecontents, base64 figures, inline CSV) -
+	// they are never sent to the model and do not count towards the chunk count
+	plan.contentWithoutBlobs, plan.blobPlaceholders, plan.skippedBlobs = protectDataBlobs(content)
+	if len(plan.skippedBlobs) > 0 {
+		logger.Info("protected embedded data blobs",
+			logger.Int("count", len(plan.skippedBlobs)),
+			logger.String("summary", FormatDataBlobSummary(plan.skippedBlobs)))
+		for _, b := range plan.skippedBlobs {
+			logger.Debug("skipped data blob", logger.String("blob", DescribeDataBlob(b)))
+		}
+	}
+
+	// Protect comment environments - they should not be translated
+	// The comment package in LaTeX treats everything between \begin{comment} and \end{comment} as comments
+	contentWithProtectedComments, commentPlaceholders := protectCommentEnvironments(plan.contentWithoutBlobs)
+	plan.commentPlaceholders = commentPlaceholders
+	if len(commentPlaceholders) > 0 {
+		logger.Info("protected comment environments", logger.Int("count", len(commentPlaceholders)))
+	}
+
+	// Code listings (verbatim, lstlisting, minted) are kept byte for byte: the model
+	// translated their comments and changed their indentation
+	contentWithProtectedVerbatim, verbatimPlaceholders := protectVerbatimEnvironments(contentWithProtectedComments)
+	plan.verbatimPlaceholders = verbatimPlaceholders
+	if len(verbatimPlaceholders) > 0 {
+		logger.Info("protected verbatim environments", logger.Int("count", len(verbatimPlaceholders)))
+	}
+
+	// Macro definitions are code: the model translated the bodies of text macros and
+	// broke the braces of the others
+	contentWithProtectedMacros, macroPlaceholders := protectMacroDefinitions(contentWithProtectedVerbatim)
+	plan.macroPlaceholders = macroPlaceholders
+	if len(macroPlaceholders) > 0 {
+		logger.Info("protected macro definitions", logger.Int("count", len(macroPlaceholders)))
+	}
+
+	// Protect code chunks of generated sources (knitr, Sweave, pandoc) wholesale
+	contentWithProtectedCode, codePlaceholders := protectCodeChunks(contentWithProtectedMacros)
+	plan.codePlaceholders = codePlaceholders
+	if len(codePlaceholders) > 0 {
+		logger.Info("protected code chunks", logger.Int("count", len(codePlaceholders)))
+	}
+
+	// Reference lists are atomic: chunk boundaries falling inside one made the model mangle
+	// keys and author names. Only the descriptive text of the entries may be translated.
+	contentWithProtectedBibliography, bibliographyPlaceholders, bibTexts := protectBibliographies(contentWithProtectedCode, translateBibliography, translateTitle)
+	plan.bibPlaceholders = bibliographyPlaceholders
+	if len(bibliographyPlaceholders) > 0 {
+		logger.Info("protected bibliographies",
+			logger.Int("count", len(bibliographyPlaceholders)),
+			logger.Int("translatedTexts", bibTexts))
+	}
+
+	// Translate \title as a structured unit (\thanks, \footnote and \\ are reassembled
+	// mechanically) and keep it out of the chunk translation, which tends to mangle it
+	contentWithProtectedTitle, titlePlaceholders := protectTitleCommands(contentWithProtectedBibliography, translateTitle)
+	plan.titlePlaceholders = titlePlaceholders
+	if len(titlePlaceholders) > 0 {
+		logger.Info("protected title commands", logger.Int("count", len(titlePlaceholders)))
+	}
+
+	// Tables are protected from the chunk translation as a whole, so the captions of
+	// floats are translated on their own first and spliced back
+	contentWithTranslatedCaptions, captions := TranslateCaptionsInFloats(contentWithProtectedTitle, translateTitle)
+	if captions > 0 {
+		logger.Info("translated float captions", logger.Int("count", captions))
+	}
+
+	// Algorithm blocks keep everything but their caption
+	contentWithTranslatedCaptions, plan.algorithmPlaceholders = protectAlgorithms(contentWithTranslatedCaptions)
+	if len(plan.algorithmPlaceholders) > 0 {
+		logger.Info("protected algorithm environments", logger.Int("count", len(plan.algorithmPlaceholders)))
+	}
+
+	// Commented-out text and notes waste tokens, inflate the chunks and are sometimes
+	// un-commented by the model. Each run of full-line comments becomes one placeholder
+	// line and is put back verbatim after translation; comments after code stay.
+	plan.prepared = contentWithTranslatedCaptions
+	if stripComments {
+		plan.prepared, plan.commentLines = stripCommentLines(plan.prepared)
+		if len(plan.commentLines) > 0 {
+			logger.Info("kept comment lines out of the chunks",
+				logger.Int("blocks", len(plan.commentLines)),
+				logger.Int("bytes", len(contentWithTranslatedCaptions)-len(plan.prepared)))
+		}
+	}
+
+	// Split content into chunks for translation
+	plan.chunks = splitIntoChunks(plan.prepared, maxChunkSize)
+
+	// Documents that put every sentence in its own paragraph can produce many tiny chunks
+	if merged := coalesceChunks(plan.prepared, plan.chunks, MinChunkSize, maxChunkSize); len(merged) < len(plan.chunks) {
+		logger.Info("merged small chunks",
+			logger.Int("chunksBefore", len(plan.chunks)),
+			logger.Int("chunksAfter", len(merged)))
+		plan.chunks = merged
+	}
+
+	plan.sections = chunkSections(plan.prepared, plan.chunks)
+	return plan
+}
+
+// chunkSections maps each chunk onto the document outline extracted from content,
+// returning the formatted section (e.g. "第4节 Experiments") each chunk starts in.
+// Leading whitespace is skipped so a chunk starting right before \section belongs to it.
+func chunkSections(content string, chunks []string) []string {
+	outline := parser.ExtractOutline(content)
+	sections := make([]string, len(chunks))
+	if len(outline) == 0 {
+		return sections
+	}
+
+	pos := 0
+	for i, chunk := range chunks {
+		start := pos + len(chunk) - len(strings.TrimLeft(chunk, " \t\r\n"))
+		sections[i] = parser.FormatOutlineEntry(parser.SectionAt(outline, start))
+		pos += len(chunk)
+	}
+	return sections
+}
+
 // TranslateChunk translates a single text chunk from English to Chinese.
 // It preserves all LaTeX commands and mathematical formulas.
 //
 // Validates: Requirements 3.1, 3.2
+//
+// Deprecated: use TranslateChunkContext. TranslateChunk runs under the context set with
+// SetJobContext, context.Background() when none is set.
 func (t *TranslationEngine) TranslateChunk(chunk string) (string, error) {
+	return t.TranslateChunkContext(t.jobContext(), chunk)
+}
+
+// TranslateChunkContext translates a single text chunk like TranslateChunk; the request is
+// cancelled when ctx is done.
+func (t *TranslationEngine) TranslateChunkContext(ctx context.Context, chunk string) (string, error) {
 	if t.apiKey == "" {
 		return "", types.NewAppError(types.ErrConfig, "OpenAI API key is not configured", nil)
 	}
@@ -439,20 +998,83 @@ func (t *TranslationEngine) TranslateChunk(chunk string) (string, error) {
 		return "", nil
 	}
 
-	translated, _, err := t.translateChunkWithRetry(chunk)
+	translated, _, err := t.translateChunkWithRetry(ctx, chunk)
 	return translated, err
 }
 
 // translateChunkWithRetry translates a chunk with retry logic for transient errors.
-func (t *TranslationEngine) translateChunkWithRetry(chunk string) (string, int, error) {
+// Transport-level errors (DNS, connection refused, timeouts) pause all workers via the
+// shared network breaker and do not consume the chunk's retry budget; only content and
+// API errors do. When ctx is done the chunk fails at once with a cancellation error.
+func (t *TranslationEngine) translateChunkWithRetry(ctx context.Context, chunk string) (string, int, error) {
 	var lastErr error
+	transportRetries := 0
+	stalls := 0
+	timeouts := 0
+	retried := false
+
+	// The chunk is not sent once the budget of the document is spent, and running out of
+	// time cancels it
+	ctx, stopBudget, err := t.budgetContext(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer stopBudget()
 
 	for attempt := 1; attempt <= MaxRetries; attempt++ {
+		// Wait while another worker is waiting for connectivity to return
+		if err := t.breaker.wait(ctx); err != nil {
+			return "", 0, err
+		}
+		if ctx.Err() != nil {
+			return "", 0, cancelledError(ctx)
+		}
+		if err := t.budgetExceeded(); err != nil {
+			return "", 0, err
+		}
+
 		logger.Debug("translation attempt", logger.Int("attempt", attempt))
-		translated, tokens, err := t.doTranslateChunk(chunk)
+		resetStreamMeter(ctx)
+		translated, tokens, err := t.translateChunkWatched(ctx, chunk)
 		if err == nil {
 			return translated, tokens, nil
 		}
+		if ctx.Err() != nil {
+			// The job was cancelled: the aborted request is not a network error
+			return "", 0, cancelledError(ctx)
+		}
+
+		if errors.Is(err, errChunkStalled) {
+			stalls++
+			if failErr := t.handleStall(len(chunk), stalls); failErr != nil {
+				return "", 0, failErr
+			}
+			// Retry the same attempt on the fresh connection
+			attempt--
+			continue
+		}
+
+		if errors.Is(err, errRequestTimeout) {
+			timeouts++
+			if failErr := t.handleRequestTimeout(timeouts, err); failErr != nil {
+				return "", 0, failErr
+			}
+			// Retry the same attempt on the fresh connection
+			attempt--
+			continue
+		}
+
+		if isTransportError(err) && transportRetries < maxTransportRetriesPerChunk {
+			transportRetries++
+			logger.Warn("transport error, waiting for connectivity", logger.Err(err), logger.Int("transportRetries", transportRetries))
+			outage := t.breaker.trip(t.probeConnectivity, t.maxNetworkPause)
+			if err := outage.wait(ctx); err != nil {
+				return "", 0, err
+			}
+			// Retry the same attempt once connectivity is back
+			attempt--
+			continue
+		}
 
 		lastErr = err
 		logger.Warn("translation attempt failed", logger.Int("attempt", attempt), logger.Err(err))
@@ -465,9 +1087,19 @@ func (t *TranslationEngine) translateChunkWithRetry(chunk string) (string, int,
 
 		// Don't sleep after the last attempt
 		if attempt < MaxRetries {
+			if !retried {
+				retried = true
+				t.progressMu.Lock()
+				t.progress.RetriedChunks++
+				t.progressMu.Unlock()
+			}
 			delay := BaseRetryDelay * time.Duration(attempt)
 			logger.Debug("retrying after delay", logger.String("delay", delay.String()))
-			time.Sleep(delay)
+			select {
+			case <-ctx.Done():
+				return "", 0, cancelledError(ctx)
+			case <-time.After(delay):
+			}
 		}
 	}
 
@@ -485,6 +1117,10 @@ type ChatCompletionRequest struct {
 	Model     string    `json:"model"`
 	Messages  []Message `json:"messages"`
 	MaxTokens int       `json:"max_tokens,omitempty"`
+
+	// Stream the response as server-sent events, with the usage in the last one
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
 }
 
 // Message represents a message in the chat completion request.
@@ -525,20 +1161,29 @@ type APIError struct {
 	Code    string `json:"code"`
 }
 
-// doTranslateChunk performs the actual API call to translate a chunk.
-func (t *TranslationEngine) doTranslateChunk(chunk string) (string, int, error) {
+// doTranslateChunk performs the actual API call to translate a chunk;
+// ctx cancels the request and carries its stall watch.
+func (t *TranslationEngine) doTranslateChunk(ctx context.Context, chunk string) (string, int, error) {
 	logger.Debug("calling OpenAI API for translation", logger.String("model", t.model))
 
-	// Step 1: Protect LaTeX commands before translation
-	protectedContent, placeholders := ProtectLaTeXCommands(chunk)
+	// Step 1: Replace reference keys with opaque placeholders, then protect LaTeX commands
+	keyedContent, refKeys := protectReferenceKeys(chunk)
+	protectedContent, placeholders := ProtectLaTeXCommands(keyedContent)
 	logger.Debug("protected LaTeX commands",
 		logger.Int("originalLength", len(chunk)),
 		logger.Int("protectedLength", len(protectedContent)),
 		logger.Int("placeholderCount", len(placeholders)))
 
 	// Build the translation prompt with protected content
-	systemPrompt := buildSystemPromptWithProtection()
-	userPrompt := buildUserPromptWithProtection(protectedContent, len(placeholders))
+	prompt := t.PromptTemplate()
+	systemPrompt := localizePrompt(systemPromptForVariant(prompt.renderSystem(t.TargetLanguage()), t.outputVariant()), t.language)
+	userPrompt := localizePrompt(prompt.renderUser(t.TargetLanguage(), t.glossary.promptSection(chunk), protectedContent, len(placeholders)), t.language)
+	if macros := userMacroPromptSection(ctx, chunk); macros != "" {
+		userPrompt = macros + "\n" + userPrompt
+	}
+	if preceding := precedingContextPromptSection(ctx); preceding != "" {
+		userPrompt = preceding + "\n" + userPrompt
+	}
 
 	// Create the request body
 	// Set max_tokens based on input size to avoid truncation
@@ -554,63 +1199,220 @@ func (t *TranslationEngine) doTranslateChunk(chunk string) (string, int, error)
 		estimatedOutputTokens = 8192 // Cap at 8192 to avoid API limits
 	}
 	
-	reqBody := ChatCompletionRequest{
-		Model: t.model,
-		Messages: []Message{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
-		MaxTokens: estimatedOutputTokens,
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
 	}
-
-	jsonBody, err := json.Marshal(reqBody)
+	chatResp, err := t.chatCompletionContext(ctx, messages, estimatedOutputTokens)
 	if err != nil {
-		logger.Error("failed to marshal request body", err)
-		return "", 0, types.NewAppError(types.ErrInternal, "failed to marshal request body", err)
+		// Network problems and cancellation say nothing about the chunk
+		if ctx.Err() == nil && !isTransportError(err) && !errors.Is(err, errChunkStalled) {
+			t.captureChunk(messages, "", Usage{}, "request failed: "+err.Error(), "", len(chunk))
+		}
+		return "", 0, err
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest(http.MethodPost, t.apiURL, bytes.NewBuffer(jsonBody))
+	// A response cut off by the output limit (finish_reason=length, or an end that does not
+	// match the end of the chunk) is completed with continuation requests
+	finishReason := chatResp.Choices[0].FinishReason
+	translatedContent, continuationTokens, continuations, truncated, err := t.completeTruncated(
+		ctx, messages, protectedContent, chatResp.Choices[0].Message.Content, finishReason, estimatedOutputTokens)
 	if err != nil {
-		logger.Error("failed to create HTTP request", err)
-		return "", 0, types.NewAppError(types.ErrInternal, "failed to create HTTP request", err)
+		if ctx.Err() == nil {
+			t.captureChunk(messages, chatResp.Choices[0].Message.Content, chatResp.Usage, "continuation failed: "+err.Error(), finishReason, len(chunk))
+		}
+		return "", 0, err
+	}
+	tokensUsed := chatResp.Usage.TotalTokens + continuationTokens
+	usage := chatResp.Usage
+	usage.TotalTokens = tokensUsed
+	if truncated {
+		t.captureChunk(messages, translatedContent, usage, "response still truncated after continuations", finishReason, len(chunk))
+	}
+	if continuations > 0 || truncated {
+		t.updateProgress(func(p *TranslationProgress) {
+			p.Continuations += continuations
+			if truncated {
+				p.TruncatedChunks++
+			}
+		})
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	// Strip the markdown fences and comments some models put around the translation
+	if sanitized, removed := sanitizeModelOutput(translatedContent, protectedContent); removed != "" || sanitized != translatedContent {
+		logger.Info("sanitized model response",
+			logger.String("removed", truncateString(removed, 200)))
+		translatedContent = sanitized
+		t.updateProgress(func(p *TranslationProgress) {
+			p.SanitizedResponses++
+		})
+	}
 
-	// Send the request
-	resp, err := t.client.Do(req)
+	// Clean up translation result to remove JSON formatting artifacts
+	translatedContent = cleanTranslationResult(translatedContent)
+
+	// Convert to the selected script while LaTeX commands are still placeholders,
+	// so only generated text is touched
+	translatedContent = t.convertToVariant(translatedContent)
+
+	// Step 2: Validate and restore LaTeX commands after translation
+	if len(placeholders) > 0 {
+		// Validate that all placeholders are present in the translation
+		missingPlaceholders := validatePlaceholders(translatedContent, placeholders)
+		if len(missingPlaceholders) > 0 {
+			logger.Warn("some placeholders were lost during translation",
+				logger.Int("missingCount", len(missingPlaceholders)),
+				logger.String("missing", strings.Join(missingPlaceholders, ", ")))
+			t.captureChunk(messages, translatedContent, usage, "placeholders lost: "+strings.Join(missingPlaceholders, ", "), finishReason, len(chunk))
+			// Try to recover by re-inserting missing placeholders at reasonable positions
+			translatedContent = recoverMissingPlaceholders(translatedContent, placeholders, missingPlaceholders)
+		}
+
+		translatedContent = RestoreLaTeXCommands(translatedContent, placeholders)
+		logger.Debug("restored LaTeX commands",
+			logger.Int("placeholderCount", len(placeholders)),
+			logger.Int("finalLength", len(translatedContent)))
+	}
+
+	// A reference key the model dropped fails the chunk, which is then retried
+	translatedContent, lostKeys := restoreReferenceKeys(translatedContent, refKeys)
+	if len(lostKeys) > 0 {
+		logger.Warn("reference keys lost during translation",
+			logger.Int("lostCount", len(lostKeys)),
+			logger.String("lost", strings.Join(lostKeys, ", ")))
+		t.captureChunk(messages, translatedContent, usage, "reference keys lost: "+strings.Join(lostKeys, ", "), finishReason, len(chunk))
+		return "", tokensUsed, types.NewAppErrorWithDetails(
+			types.ErrTranslation,
+			"reference keys lost in translation",
+			strings.Join(lostKeys, ", "),
+			errReferenceKeysLost,
+		)
+	}
+
+	// Math must come out as it went in, apart from the translated words in its \text{} spans
+	translatedContent = RepairMathText(chunk, translatedContent)
+
+	logger.Debug("API call successful", logger.Int("tokensUsed", tokensUsed), logger.String("finishReason", finishReason))
+	return translatedContent, tokensUsed, nil
+}
+
+// chatCompletion sends a chat completion request and returns the parsed response, which
+// is guaranteed to have at least one choice.
+func (t *TranslationEngine) chatCompletion(messages []Message, maxTokens int) (*ChatCompletionResponse, error) {
+	return t.chatCompletionContext(context.Background(), messages, maxTokens)
+}
+
+// chatCompletionContext is chatCompletion with a context that cancels the request; a
+// response, and every event of a streamed one, is a heartbeat of the stall watch of ctx.
+// Responses are streamed unless streaming is disabled or the server rejects it. The
+// usage of every response is added to the usage meter. A request still running after the
+// request timeout is cancelled and fails with errRequestTimeout.
+func (t *TranslationEngine) chatCompletionContext(ctx context.Context, messages []Message, maxTokens int) (*ChatCompletionResponse, error) {
+	reqCtx, cancel := t.withRequestTimeout(ctx)
+	defer cancel()
+	chatResp, err := t.requestCompletion(reqCtx, messages, maxTokens)
+	if err == nil {
+		t.recordUsage(chatResp.Usage)
+	} else if ctx.Err() == nil && errors.Is(context.Cause(reqCtx), errRequestTimeout) {
+		return nil, t.requestTimeoutError(err)
+	}
+	return chatResp, err
+}
+
+// requestCompletion sends the request of chatCompletionContext through the API of the provider
+func (t *TranslationEngine) requestCompletion(ctx context.Context, messages []Message, maxTokens int) (*ChatCompletionResponse, error) {
+	if t.Provider() == types.ProviderAnthropic {
+		return t.anthropicCompletion(ctx, messages, maxTokens)
+	}
+	stream := !t.noStreaming.Load()
+	streamRejected := false
+	for {
+		shape := t.RequestShape()
+		chatResp, err := t.doChatCompletion(ctx, messages, maxTokens, stream, shape)
+		var rejected *requestRejected
+		if !errors.As(err, &rejected) {
+			if err == nil && streamRejected {
+				// The request went through once it was not streamed
+				logger.Warn("API rejects streamed requests, disabling streaming", logger.String("model", t.model))
+				t.noStreaming.Store(true)
+				t.recordAdjustment(shape, shape, "关闭流式输出（stream）")
+			}
+			return chatResp, err
+		}
+
+		// Some OpenAI compatible servers reject the parameters they do not implement:
+		// adjust the request to the error and send it again
+		if next, adjustment, ok := adaptRequestShape(shape, rejected.sent, rejected.body); ok {
+			logger.Warn("API rejected a request parameter, adjusting the request",
+				logger.String("adjustment", adjustment),
+				logger.String("response", truncateString(rejected.body, 200)))
+			t.recordAdjustment(shape, next, adjustment)
+			continue
+		}
+		if stream {
+			stream = false
+			streamRejected = true
+			continue
+		}
+		logger.Error("API returned error status", nil, logger.Int("statusCode", rejected.status))
+		return nil, rejected.err
+	}
+}
+
+// requestRejected is the error of a request answered with 400 or 422, which may be
+// accepted once the request is adjusted
+type requestRejected struct {
+	status int
+	body   string
+	sent   map[string]bool // fields of the rejected request
+	err    error           // error reported when no adjustment helps
+}
+
+func (r *requestRejected) Error() string { return r.err.Error() }
+func (r *requestRejected) Unwrap() error { return r.err }
+
+// doChatCompletion sends a chat completion request and reads its response, streamed
+// or not
+func (t *TranslationEngine) doChatCompletion(ctx context.Context, messages []Message, maxTokens int, stream bool, shape types.RequestShape) (*ChatCompletionResponse, error) {
+	resp, sent, err := t.sendChatCompletion(ctx, messages, maxTokens, stream, shape)
 	if err != nil {
-		logger.Error("API request failed", err)
-		return "", 0, types.NewAppError(types.ErrNetwork, "API request failed", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	// Servers ignoring the stream parameter answer with a plain response
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readChatStream(ctx, resp.Body, messages)
+	}
+
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error("failed to read API response", err)
-		return "", 0, types.NewAppError(types.ErrNetwork, "failed to read API response", err)
+		return nil, types.NewAppError(types.ErrNetwork, "failed to read API response", err)
 	}
+	heartbeat(ctx)
 
 	// Handle HTTP errors
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		return nil, &requestRejected{status: resp.StatusCode, body: string(body), sent: sent, err: handleAPIHTTPError(resp.StatusCode, body)}
+	}
 	if resp.StatusCode != http.StatusOK {
 		logger.Error("API returned error status", nil, logger.Int("statusCode", resp.StatusCode))
-		return "", 0, handleAPIHTTPError(resp.StatusCode, body)
+		return nil, handleAPIHTTPError(resp.StatusCode, body)
 	}
 
 	// Parse response
 	var chatResp ChatCompletionResponse
 	if err := json.Unmarshal(body, &chatResp); err != nil {
 		logger.Error("failed to parse API response", err)
-		return "", 0, types.NewAppError(types.ErrAPICall, "failed to parse API response", err)
+		return nil, types.NewAppError(types.ErrAPICall, "failed to parse API response", err)
 	}
 
 	// Check for API error in response
 	if chatResp.Error != nil {
 		logger.Error("API returned error in response", nil, logger.String("errorMessage", chatResp.Error.Message))
-		return "", 0, types.NewAppErrorWithDetails(
+		return nil, types.NewAppErrorWithDetails(
 			types.ErrAPICall,
 			"API returned error",
 			chatResp.Error.Message,
@@ -618,49 +1420,54 @@ func (t *TranslationEngine) doTranslateChunk(chunk string) (string, int, error)
 		)
 	}
 
-	// Extract translated content
 	if len(chatResp.Choices) == 0 {
 		logger.Error("API returned no choices", nil)
-		return "", 0, types.NewAppError(types.ErrAPICall, "API returned no choices", nil)
+		return nil, types.NewAppError(types.ErrAPICall, "API returned no choices", nil)
 	}
 
-	// Check if output was truncated due to length limit
-	finishReason := chatResp.Choices[0].FinishReason
-	if finishReason == "length" {
-		logger.Warn("translation output was truncated due to length limit",
-			logger.Int("completionTokens", chatResp.Usage.CompletionTokens),
-			logger.Int("inputLength", len(chunk)))
-		// Continue with truncated content but log warning
-		// The content may be incomplete, but we'll try to use what we have
-		// Consider reducing MaxChunkSize if this happens frequently
+	return &chatResp, nil
+}
+
+// sendChatCompletion posts a chat completion request, streamed or not and adjusted to
+// shape, and returns the response whatever its status with the fields sent
+func (t *TranslationEngine) sendChatCompletion(ctx context.Context, messages []Message, maxTokens int, stream bool, shape types.RequestShape) (*http.Response, map[string]bool, error) {
+	reqBody := ChatCompletionRequest{
+		Model:     t.model,
+		Messages:  messages,
+		MaxTokens: maxTokens,
+	}
+	if stream {
+		reqBody.Stream = true
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
 	}
 
-	translatedContent := chatResp.Choices[0].Message.Content
-	tokensUsed := chatResp.Usage.TotalTokens
+	jsonBody, sent, err := shapeRequestBody(reqBody, shape)
+	if err != nil {
+		logger.Error("failed to marshal request body", err)
+		return nil, nil, types.NewAppError(types.ErrInternal, "failed to marshal request body", err)
+	}
 
-	// Clean up translation result to remove JSON formatting artifacts
-	translatedContent = cleanTranslationResult(translatedContent)
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		logger.Error("failed to create HTTP request", err)
+		return nil, nil, types.NewAppError(types.ErrInternal, "failed to create HTTP request", err)
+	}
 
-	// Step 2: Validate and restore LaTeX commands after translation
-	if len(placeholders) > 0 {
-		// Validate that all placeholders are present in the translation
-		missingPlaceholders := validatePlaceholders(translatedContent, placeholders)
-		if len(missingPlaceholders) > 0 {
-			logger.Warn("some placeholders were lost during translation",
-				logger.Int("missingCount", len(missingPlaceholders)),
-				logger.String("missing", strings.Join(missingPlaceholders, ", ")))
-			// Try to recover by re-inserting missing placeholders at reasonable positions
-			translatedContent = recoverMissingPlaceholders(translatedContent, placeholders, missingPlaceholders)
-		}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
 
-		translatedContent = RestoreLaTeXCommands(translatedContent, placeholders)
-		logger.Debug("restored LaTeX commands",
-			logger.Int("placeholderCount", len(placeholders)),
-			logger.Int("finalLength", len(translatedContent)))
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
 	}
 
-	logger.Debug("API call successful", logger.Int("tokensUsed", tokensUsed), logger.String("finishReason", finishReason))
-	return translatedContent, tokensUsed, nil
+	// Send the request
+	resp, err := t.httpClient().Do(req)
+	if err != nil {
+		logger.Error("API request failed", err)
+		return nil, nil, types.NewAppError(types.ErrNetwork, "API request failed", err)
+	}
+	return resp, sent, nil
 }
 
 // validatePlaceholders checks if all placeholders are present in the translated content.
@@ -1161,106 +1968,6 @@ LaTeX content to translate:
 %s`, content)
 }
 
-// buildSystemPromptWithProtection creates the system prompt for protected translation.
-// This prompt is used when LaTeX commands have been replaced with placeholders.
-func buildSystemPromptWithProtection() string {
-	return `You are a STRICT LaTeX document translator. You translate English to Chinese while preserving EXACT document structure.
-
-## CRITICAL CONTEXT
-- This input is a FRAGMENT of a larger LaTeX document, NOT a complete document
-- You may see \begin{...} without matching \end{...} - THIS IS NORMAL
-- You may see \end{...} without matching \begin{...} - THIS IS NORMAL
-- You may see incomplete tables, figures, or environments - THIS IS NORMAL
-- DO NOT try to "fix" or "complete" anything - just translate the text
-
-## YOUR ROLE: FAITHFUL TRANSLATOR
-- You are a translation tool, NOT an editor or improver
-- Your output must be a MIRROR of the input structure with only text translated
-- NEVER add, remove, or modify anything except translating English text to Chinese
-
-## ABSOLUTE RULES (VIOLATION = CRITICAL FAILURE):
-
-### Rule 1: COMMENT PRESERVATION (MOST CRITICAL)
-- Lines starting with % are COMMENTS - they MUST stay as comments
-- Lines NOT starting with % are CODE - they MUST stay as code
-- NEVER add % to any line that doesn't have it in the input
-- NEVER remove % from any line that has it in the input
-- This rule has NO exceptions - even if the code looks "wrong" or "incomplete"
-
-### Rule 2: PLACEHOLDER PRESERVATION (CRITICAL)
-- Placeholders look like: <<<LATEX_CMD_0>>>, <<<LATEX_CMD_1>>>, etc.
-- Copy each placeholder EXACTLY - character by character, position by position
-- NEVER modify, translate, explain, or interpret placeholders
-- NEVER change the number in a placeholder
-- NEVER add spaces inside placeholders
-- NEVER split a placeholder across lines
-
-### Rule 3: STRUCTURE PRESERVATION (CRITICAL)
-- Output MUST have EXACTLY the same number of lines as input
-- Each line in output corresponds to the same line in input
-- If input line N has a placeholder, output line N must have that SAME placeholder
-- If input line starts with %, output line must start with %
-- If input line is empty, output line must be empty
-- NEVER merge multiple input lines into one output line
-- NEVER split one input line into multiple output lines
-- NEVER add new lines that don't exist in input
-- NEVER remove lines that exist in input
-
-### Rule 4: NO ADDITIONS OR MODIFICATIONS
-- Do NOT add \end{document}, \end{table}, \end{tabular} or any LaTeX commands
-- Do NOT add comments (% lines) that don't exist in input
-- Do NOT remove comments that exist in input
-- Do NOT add explanations, notes, or annotations
-- Do NOT "fix", "complete", or "improve" anything
-- Do NOT add content that wasn't in the original
-- Even if you see \begin{table} without \end{table}, DO NOT add \end{table}
-- Translate ONLY the English text, leave everything else UNCHANGED
-
-### Rule 5: OUTPUT FORMAT
-- Output ONLY the translated text
-- No JSON, no code blocks, no markdown formatting
-- No "Translation:" prefix or similar labels
-- Start directly with the translated content
-- End exactly where the input ends
-
-## TRANSLATION GUIDELINES:
-- Use proper Chinese punctuation: 。，、；：""''（）
-- Maintain academic/formal tone
-- Preserve technical terms when appropriate
-
-## EXAMPLE:
-Input (3 lines):
-The quick brown fox
-<<<LATEX_CMD_0>>>
-jumps over the lazy dog.
-
-Output (MUST be exactly 3 lines):
-敏捷的棕色狐狸
-<<<LATEX_CMD_0>>>
-跳过了懒狗。`
-}
-
-// buildUserPromptWithProtection creates the user prompt for protected translation.
-func buildUserPromptWithProtection(content string, placeholderCount int) string {
-	if placeholderCount == 0 {
-		return fmt.Sprintf(`Translate to Chinese. Keep the same line structure.
-
-%s`, content)
-	}
-
-	return fmt.Sprintf(`Translate to Chinese. This text contains %d placeholders (<<<LATEX_CMD_N>>> format).
-
-CRITICAL: Copy every placeholder EXACTLY as shown. Do not modify any placeholder.
-
-Example of correct handling:
-- Input: "The equation <<<LATEX_CMD_0>>> shows that..."
-- Output: "方程 <<<LATEX_CMD_0>>> 表明..."
-
-Now translate:
-
-%s`, placeholderCount, content)
-}
-
 // handleAPIHTTPError creates an appropriate AppError based on the HTTP status code and response body.
 func handleAPIHTTPError(statusCode int, body []byte) error {
 	// Try to parse error message from response body
@@ -1322,6 +2029,11 @@ func isRetryableAPIError(err error) bool {
 		return false
 	}
 
+	// The model dropped a reference key; another attempt usually keeps it
+	if errors.Is(err, errReferenceKeysLost) {
+		return true
+	}
+
 	if appErr, ok := err.(*types.AppError); ok {
 		switch appErr.Code {
 		case types.ErrNetwork:
@@ -1479,7 +2191,8 @@ func findSafeBreakPoint(content string, targetPos int, boundaries []environmentB
 // 2. Try to split by LaTeX section commands (\section, \subsection, etc.)
 // 3. If sections are too large, split by paragraphs (double newlines)
 // 4. Ensure no split occurs inside a protected environment
-// 5. As a last resort, split by character count at safe positions
+// 5. As a last resort, split a paragraph too large for one chunk at a sentence end, then
+//    at a line break or between words (see findBreakPoint), never inside an environment
 func splitIntoChunks(content string, maxSize int) []string {
 	if len(content) <= maxSize {
 		return []string{content}
@@ -1504,6 +2217,53 @@ func splitIntoChunks(content string, maxSize int) []string {
 	return splitBySizeWithEnvProtection(content, maxSize, boundaries)
 }
 
+// majorDivisionPattern matches a chunk starting with \part or \chapter
+var majorDivisionPattern = regexp.MustCompile(`^\s*\\(part|chapter)\*?\s*[\[{]`)
+
+// coalesceChunks merges adjacent chunks when one of them is smaller than minSize and
+// the result stays within maxSize. content is the text the chunks were split from;
+// the merged chunks still concatenate to it.
+//
+// Chunks are not merged across a protected environment (the split only happens inside
+// one when the environment is too large for a single chunk) or across \part and
+// \chapter, which always start a new chunk.
+func coalesceChunks(content string, chunks []string, minSize, maxSize int) []string {
+	if len(chunks) < 2 {
+		return chunks
+	}
+
+	boundaries := findEnvironmentBoundaries(content)
+	merged := make([]string, 0, len(chunks))
+	merged = append(merged, chunks[0])
+	pos := len(chunks[0])
+
+	for _, chunk := range chunks[1:] {
+		last := merged[len(merged)-1]
+		canMerge := (len(last) < minSize || len(chunk) < minSize) &&
+			len(last)+len(chunk) <= maxSize &&
+			!splitsEnvironment(pos, boundaries) &&
+			!majorDivisionPattern.MatchString(chunk)
+		if canMerge {
+			merged[len(merged)-1] = last + chunk
+		} else {
+			merged = append(merged, chunk)
+		}
+		pos += len(chunk)
+	}
+
+	return merged
+}
+
+// splitsEnvironment reports whether pos lies strictly inside a protected environment
+func splitsEnvironment(pos int, boundaries []environmentBoundary) bool {
+	for _, b := range boundaries {
+		if pos > b.start && pos < b.end {
+			return true
+		}
+	}
+	return false
+}
+
 // sectionPattern matches LaTeX section commands
 var sectionPattern = regexp.MustCompile(`(?m)^\\(section|subsection|subsubsection|chapter|part)\s*[\[{]`)
 
@@ -1706,48 +2466,105 @@ func splitBySize(content string, maxSize int) []string {
 	return splitBySizeWithEnvProtection(content, maxSize, boundaries)
 }
 
-// findBreakPoint finds a good position to break the text, preferring sentence boundaries.
+// findBreakPoint finds where to cut text that has to be split within maxSize bytes. In
+// order of preference it cuts after a blank line or a sentence end in the second half of
+// the window, then after a line break or a space anywhere in it, then at the edge of a word;
+// a single word longer than maxSize is cut at a character boundary. The next chunk never
+// starts in the middle of a word unless the word itself is too long.
 func findBreakPoint(text string, maxSize int) int {
 	if len(text) <= maxSize {
 		return len(text)
 	}
+	window := text[:maxSize]
+	searchStart := maxSize / 2
 
-	// Look for sentence endings within the last 20% of maxSize
-	searchStart := maxSize * 80 / 100
-	searchEnd := maxSize
+	// A paragraph break, then the end of a sentence, late enough to fill the chunk
+	if idx := strings.LastIndex(window[searchStart:], "\n\n"); idx != -1 {
+		return searchStart + idx + 2
+	}
+	if pos := lastSentenceEnd(window, searchStart); pos != -1 {
+		return pos
+	}
 
-	// Sentence ending patterns (period, question mark, exclamation followed by space or newline)
-	sentenceEndings := []string{". ", ".\n", "? ", "?\n", "! ", "!\n", "。", "？", "！"}
+	// A line break or a space anywhere
+	if idx := strings.LastIndexByte(window, '\n'); idx > 0 {
+		return idx + 1
+	}
+	if idx := strings.LastIndexByte(window, ' '); idx > 0 {
+		return idx + 1
+	}
 
-	bestBreak := -1
-	for _, ending := range sentenceEndings {
-		idx := strings.LastIndex(text[searchStart:searchEnd], ending)
-		if idx != -1 {
-			breakPos := searchStart + idx + len(ending)
-			if breakPos > bestBreak {
-				bestBreak = breakPos
-			}
+	// The edge of a word, e.g. after the comma of a package list
+	hardBreak := maxSize
+	for hardBreak > 0 && !utf8.RuneStart(text[hardBreak]) {
+		hardBreak--
+	}
+	for pos := hardBreak; pos > 0; {
+		r, size := utf8.DecodeLastRuneInString(text[:pos])
+		next, _ := utf8.DecodeRuneInString(text[pos:])
+		if !isWordRune(r) || !isWordRune(next) {
+			return pos
 		}
+		pos -= size
 	}
 
-	if bestBreak > 0 {
-		return bestBreak
+	// Hard break at maxSize, on a character boundary
+	if hardBreak == 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		return size
 	}
+	return hardBreak
+}
 
-	// Look for newlines as secondary break points
-	idx := strings.LastIndex(text[searchStart:searchEnd], "\n")
-	if idx != -1 {
-		return searchStart + idx + 1
-	}
+// sentenceEndings end a sentence when followed by the next one
+var sentenceEndings = []string{". ", ".\n", "? ", "?\n", "! ", "!\n", "。", "？", "！"}
 
-	// Look for spaces as last resort
-	idx = strings.LastIndex(text[:maxSize], " ")
-	if idx != -1 && idx > maxSize/2 {
-		return idx + 1
+// sentenceAbbreviations end with a period that does not end the sentence
+var sentenceAbbreviations = []string{
+	"e.g", "i.e", "et al", "cf", "vs", "etc", "resp", "approx",
+	"Fig", "Figs", "Eq", "Eqs", "Sec", "Secs", "Tab", "Ref", "Refs", "Ch", "No", "Dr", "Mr", "Ms", "Prof",
+}
+
+// lastSentenceEnd returns the position after the last sentence end of text at or after
+// from, or -1. Periods of abbreviations (e.g., Fig., et al.) and of initials are not
+// sentence ends.
+func lastSentenceEnd(text string, from int) int {
+	best := -1
+	for _, ending := range sentenceEndings {
+		for end := len(text); end > from; {
+			idx := strings.LastIndex(text[from:end], ending)
+			if idx == -1 {
+				break
+			}
+			pos := from + idx
+			if ending[0] != '.' || !isAbbreviationAt(text, pos) {
+				best = max(best, pos+len(ending))
+				break
+			}
+			end = pos
+		}
 	}
+	return best
+}
 
-	// Hard break at maxSize if no good break point found
-	return maxSize
+// isAbbreviationAt reports whether the period at pos ends an abbreviation or an initial
+func isAbbreviationAt(text string, pos int) bool {
+	word := pos
+	for word > 0 && (isWordRune(rune(text[word-1])) || text[word-1] == '.') {
+		word--
+	}
+	if pos-word == 1 && unicode.IsUpper(rune(text[word])) {
+		return true // an initial, as in "J. Smith"
+	}
+	for _, abbreviation := range sentenceAbbreviations {
+		if strings.HasSuffix(text[:pos], abbreviation) {
+			start := pos - len(abbreviation)
+			if start == 0 || !isWordRune(rune(text[start-1])) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // =============================================================================
@@ -1823,8 +2640,8 @@ var (
 		"split", "subequations",
 	}
 	
-	// Float environments that need caption translation (not currently used)
-	floatEnvNames = []string{"table", "table*", "figure", "figure*", "sidewaystable", "sidewaysfigure"}
+	// Float environments whose captions are translated by TranslateCaptionsInFloats
+	floatEnvNames = []string{"table", "table*", "figure", "figure*", "sidewaystable", "sidewaysfigure", "algorithm", "algorithm*"}
 
 	// Document structure command patterns
 	// Section commands: \section{...}, \subsection{...}, etc.
@@ -2244,6 +3061,16 @@ func extractOtherCommands(content string) []LaTeXCommand {
 		})
 	}
 
+	// Helper macros of generated sources (pandoc's \tightlist)
+	for _, match := range generatorHelperPattern.FindAllStringIndex(content, -1) {
+		commands = append(commands, LaTeXCommand{
+			Command: content[match[0]:match[1]],
+			Start:   match[0],
+			End:     match[1],
+			Type:    CommandTypeOther,
+		})
+	}
+
 	return commands
 }
 
@@ -2312,120 +3139,6 @@ func GetLaTeXCommandStrings(content string) []string {
 	return result
 }
 
-// TranslateCaptionsInFloats finds all float environments (table, figure, etc.) and translates
-// only the \caption content within them. The rest of the float environment is preserved as-is.
-// This is called BEFORE the main translation to handle captions in protected environments.
-//
-// Parameters:
-//   - content: the LaTeX content to process
-//   - translateFunc: a function that translates a single caption text
-//
-// Returns:
-//   - the content with translated captions
-//   - error if translation fails
-func TranslateCaptionsInFloats(content string, translateFunc func(string) (string, error)) (string, error) {
-	result := content
-	
-	// Pattern to match \caption{...} or \caption[short]{long}
-	// We need to handle nested braces in the caption content
-	captionPattern := regexp.MustCompile(`\\caption\s*(\[[^\]]*\])?\s*\{`)
-	
-	// Find all float environments and process captions within them
-	for _, envName := range floatEnvNames {
-		beginTag := fmt.Sprintf("\\begin{%s}", envName)
-		// endTag is used internally by findMatchingEndTag
-		
-		searchPos := 0
-		for {
-			// Find the begin tag
-			beginPos := strings.Index(result[searchPos:], beginTag)
-			if beginPos == -1 {
-				break
-			}
-			beginPos += searchPos
-			
-			// Find the matching end tag
-			endPos := findMatchingEndTag(result, beginPos, envName)
-			if endPos == -1 {
-				searchPos = beginPos + len(beginTag)
-				continue
-			}
-			
-			// Extract the float environment content
-			floatContent := result[beginPos:endPos]
-			
-			// Find and translate captions within this float
-			translatedFloat, err := translateCaptionsInContent(floatContent, captionPattern, translateFunc)
-			if err != nil {
-				return "", err
-			}
-			
-			// Replace the float content with translated version
-			result = result[:beginPos] + translatedFloat + result[endPos:]
-			
-			// Move search position past this float
-			searchPos = beginPos + len(translatedFloat)
-		}
-	}
-	
-	return result, nil
-}
-
-// translateCaptionsInContent translates all \caption commands within the given content.
-func translateCaptionsInContent(content string, captionPattern *regexp.Regexp, translateFunc func(string) (string, error)) (string, error) {
-	result := content
-	offset := 0
-	
-	matches := captionPattern.FindAllStringIndex(content, -1)
-	for _, match := range matches {
-		captionStart := match[0] + offset
-		
-		// Find the opening brace position
-		braceStart := strings.Index(result[captionStart:], "{")
-		if braceStart == -1 {
-			continue
-		}
-		braceStart += captionStart
-		
-		// Find the matching closing brace
-		braceEnd := findMatchingBrace(result, braceStart)
-		if braceEnd == -1 {
-			continue
-		}
-		
-		// Extract the caption text (without braces)
-		captionText := result[braceStart+1 : braceEnd]
-		
-		// Skip if caption is empty or only contains LaTeX commands
-		if strings.TrimSpace(captionText) == "" {
-			continue
-		}
-		
-		// Check if caption contains any translatable text (not just commands/refs)
-		if !containsTranslatableText(captionText) {
-			continue
-		}
-		
-		// Translate the caption text
-		translatedCaption, err := translateFunc(captionText)
-		if err != nil {
-			logger.Warn("failed to translate caption, keeping original",
-				logger.Err(err),
-				logger.String("caption", truncateString(captionText, 50)))
-			continue
-		}
-		
-		// Replace the caption text
-		newCaption := result[:braceStart+1] + translatedCaption + result[braceEnd:]
-		
-		// Update offset for subsequent matches
-		offset += len(translatedCaption) - len(captionText)
-		result = newCaption
-	}
-	
-	return result, nil
-}
-
 // findMatchingBrace finds the position of the closing brace that matches the opening brace at pos.
 func findMatchingBrace(content string, pos int) int {
 	if pos >= len(content) || content[pos] != '{' {
@@ -2525,6 +3238,10 @@ func ProtectLaTeXCommands(content string) (string, map[string]string) {
 	// This ensures complete math expressions are protected as single units
 	protectedContent, mathPlaceholders := ProtectMathEnvironments(protectedContent)
 	
+	// Step 2b: Expose the prose in \text{} of inline math for translation, keeping the
+	// formula and the spaces around the words in placeholders
+	protectedContent = exposeInlineMathText(protectedContent, mathPlaceholders)
+	
 	// Merge math placeholders into the main placeholders map
 	for k, v := range mathPlaceholders {
 		placeholders[k] = v
@@ -2719,7 +3436,7 @@ func FixLaTeXLineStructure(content string) string {
 	result = endSectionPattern.ReplaceAllString(result, "$1\n\n$2")
 
 	// Fix text followed immediately by \begin{itemize/enumerate/description}
-	textBeginListPattern := regexp.MustCompile(`([^\n\s])\s*(\\begin\{(?:itemize|enumerate|description)\})`)
+	textBeginListPattern := regexp.MustCompile(`([^\n\s])[ \t]*(\\begin\{(?:itemize|enumerate|description)\})`)
 	result = textBeginListPattern.ReplaceAllString(result, "$1\n$2")
 
 	// Fix \end{itemize/enumerate/description} followed by text (not a command)
@@ -3025,6 +3742,8 @@ func fixSuperLongLines(content string) string {
 // - Wrongly commented environments (LLM added % to environments that shouldn't be commented)
 // - Duplicate or misplaced \end{document}
 // - Extra closing braces after commands like \textit{...}}
+// - \label, \ref and \cite keys that were changed or dropped
+// - User macro definitions that were changed, and macro uses replaced with their expansion
 func ApplyReferenceBasedFixes(translated, original string) string {
 	if original == "" {
 		return translated
@@ -3077,6 +3796,15 @@ func ApplyReferenceBasedFixes(translated, original string) string {
 		logger.Debug("reference-based fix: fixed wrongly uncommented preamble lines")
 	}
 
+	// 1.9. Restore user macro definitions the model changed, and macro uses it expanded
+	macrosFixed, macroRepairs := RestoreUserMacros(result, original)
+	if macrosFixed != result {
+		result = macrosFixed
+		anyFixed = true
+		logger.Debug("reference-based fix: restored user macros",
+			logger.Int("macros", len(macroRepairs)))
+	}
+
 	// Count commented tables after fix for debugging
 	afterCount := strings.Count(result, "% \\begin{table")
 	logger.Debug("ApplyReferenceBasedFixes: after wrongly commented fix",
@@ -3220,6 +3948,13 @@ func ApplyReferenceBasedFixes(translated, original string) string {
 		logger.Debug("reference-based fix: cleaned CCS math italics")
 	}
 
+	// 17. Final check: restore \label, \ref and \cite keys the model changed or dropped
+	result, refKeysFixed := fixLostReferenceKeys(result, original)
+	if refKeysFixed {
+		anyFixed = true
+		logger.Debug("reference-based fix: restored lost reference keys")
+	}
+
 	return result
 }
 
@@ -0,0 +1,100 @@
+package translator
+
+import (
+	"sort"
+	"sync"
+)
+
+// ModelUsage is the token usage of the requests to one model
+type ModelUsage struct {
+	Model        string `json:"model"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	Requests     int    `json:"requests"`
+}
+
+// UsageMeter adds up the tokens of the LLM requests of a job by model: chunks, retries and
+// continuations of the engine, and the requests of the syntax validator and the compile
+// fixer. A nil UsageMeter records nothing. All methods are safe for concurrent use.
+type UsageMeter struct {
+	mu     sync.Mutex
+	models map[string]*ModelUsage
+}
+
+// NewUsageMeter creates an empty usage meter
+func NewUsageMeter() *UsageMeter {
+	return &UsageMeter{models: make(map[string]*ModelUsage)}
+}
+
+// Add records the usage of one successful request to model
+func (m *UsageMeter) Add(model string, usage Usage) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.models[model]
+	if !ok {
+		u = &ModelUsage{Model: model}
+		m.models[model] = u
+	}
+	u.InputTokens += usage.PromptTokens
+	u.OutputTokens += usage.CompletionTokens
+	u.Requests++
+}
+
+// Models returns the usage of each model, the most used first
+func (m *UsageMeter) Models() []ModelUsage {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	models := make([]ModelUsage, 0, len(m.models))
+	for _, u := range m.models {
+		models = append(models, *u)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		ti, tj := models[i].InputTokens+models[i].OutputTokens, models[j].InputTokens+models[j].OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		return models[i].Model < models[j].Model
+	})
+	return models
+}
+
+// Total returns the usage of all models; its Model is the most used one
+func (m *UsageMeter) Total() ModelUsage {
+	var total ModelUsage
+	for i, u := range m.Models() {
+		if i == 0 {
+			total.Model = u.Model
+		}
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+		total.Requests += u.Requests
+	}
+	return total
+}
+
+// SetUsageMeter sets the meter the tokens of the engine's requests are added to; nil stops
+// the metering
+func (t *TranslationEngine) SetUsageMeter(meter *UsageMeter) {
+	t.usageMu.Lock()
+	t.usageMeter = meter
+	t.usageMu.Unlock()
+}
+
+// recordUsage adds the usage of a successful request to the usage meter, if any, and to
+// the tokens spent from the translation budget
+func (t *TranslationEngine) recordUsage(usage Usage) {
+	t.usageMu.Lock()
+	meter := t.usageMeter
+	t.usageMu.Unlock()
+	meter.Add(t.model, usage)
+
+	t.budgetMu.Lock()
+	t.budgetTokens += usage.PromptTokens + usage.CompletionTokens
+	t.budgetMu.Unlock()
+}
@@ -0,0 +1,73 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUsageMeterCountsRetries(t *testing.T) {
+	// The first answer is cut off, so the chunk takes a continuation request
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: "这是译文。"}, FinishReason: "stop"}},
+			Usage:   Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+		}
+		if calls.Add(1) == 1 {
+			resp.Choices[0].Message.Content = "这是"
+			resp.Choices[0].FinishReason = "length"
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	engine := NewTranslationEngineWithConfig("sk-test", "test-model", server.URL, 5*time.Second, 1)
+	engine.noStreaming.Store(true)
+	meter := NewUsageMeter()
+	engine.SetUsageMeter(meter)
+	engine.doTranslateChunk(context.Background(), "This is the text.")
+	if _, _, err := engine.Complete(context.Background(), Prompt{Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	total := meter.Total()
+	if total.Requests != int(calls.Load()) || total.InputTokens != 100*total.Requests || total.OutputTokens != 20*total.Requests {
+		t.Errorf("total = %+v after %d requests", total, calls.Load())
+	}
+	if total.Requests < 3 {
+		t.Errorf("requests = %d, want the continuation counted", total.Requests)
+	}
+
+	// Failed requests are not metered
+	server.Close()
+	engine.Complete(context.Background(), Prompt{Messages: []Message{{Role: "user", Content: "hi"}}})
+	if meter.Total().Requests != total.Requests {
+		t.Errorf("failed request metered: %+v", meter.Total())
+	}
+}
+
+func TestUsageMeterModels(t *testing.T) {
+	meter := NewUsageMeter()
+	meter.Add("gpt-4o-mini", Usage{PromptTokens: 10, CompletionTokens: 5})
+	meter.Add("gpt-4o", Usage{PromptTokens: 1000, CompletionTokens: 500})
+	meter.Add("gpt-4o", Usage{PromptTokens: 1000, CompletionTokens: 500})
+
+	models := meter.Models()
+	if len(models) != 2 || models[0].Model != "gpt-4o" || models[0].Requests != 2 || models[0].InputTokens != 2000 {
+		t.Errorf("models = %+v", models)
+	}
+	if total := meter.Total(); total.Model != "gpt-4o" || total.InputTokens != 2010 || total.OutputTokens != 1005 || total.Requests != 3 {
+		t.Errorf("total = %+v", total)
+	}
+
+	var none *UsageMeter
+	none.Add("gpt-4o", Usage{PromptTokens: 1})
+	if none.Models() != nil || none.Total() != (ModelUsage{}) {
+		t.Error("nil meter recorded usage")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"unicode"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
+	"latex-translator/internal/types"
 )
 
 // EnvironmentValidation 环境验证结果
@@ -268,8 +270,11 @@ type TranslationValidator struct {
 	MinLengthRatio float64
 	// MaxLengthRatio is the maximum acceptable ratio of translated/original length
 	MaxLengthRatio float64
-	// MinChineseRatio is the minimum ratio of Chinese characters in translated content
+	// MinChineseRatio is the minimum ratio of Chinese characters in translated content; for
+	// other target languages it applies to the characters of the target script
 	MinChineseRatio float64
+	// Language is the target language of the translation; empty means Chinese
+	Language types.TargetLanguage
 	// RequiredPatterns are patterns that must be preserved in translation
 	RequiredPatterns []string
 	// ForbiddenPatterns are patterns that indicate a bad translation (e.g., template text)
@@ -309,6 +314,10 @@ func NewTranslationValidator() *TranslationValidator {
 
 // ValidateTranslation checks if the translation result is valid
 func (v *TranslationValidator) ValidateTranslation(original, translated string) *TranslationValidationResult {
+	// Content after \end{document} or \endinput is not translated and must not skew the checks
+	original = parser.DocumentBody(original)
+	translated = parser.DocumentBody(translated)
+
 	result := &TranslationValidationResult{
 		IsValid:          true,
 		OriginalLength:   len(original),
@@ -348,9 +357,51 @@ func (v *TranslationValidator) ValidateTranslation(original, translated string)
 			logger.Float64("maxRatio", v.MaxLengthRatio))
 	}
 
-	// Check Chinese character ratio
+	// Check that the result is in the target language: Chinese by its character ratio,
+	// other languages by their script or by differing from the source
+	if v.Language.IsChinese() {
+		v.checkChineseRatio(result)
+	} else {
+		v.checkTargetLanguage(original, translated, result)
+	}
+
+	// Check required patterns
+	for _, pattern := range v.RequiredPatterns {
+		re := regexp.MustCompile(pattern)
+		if re.MatchString(original) && !re.MatchString(translated) {
+			result.IsValid = false
+			result.Errors = append(result.Errors,
+				"翻译结果丢失了必要的 LaTeX 结构: "+pattern)
+			logger.Warn("required pattern missing in translation",
+				logger.String("pattern", pattern))
+		}
+	}
+
+	// Check forbidden patterns (template text)
+	for _, pattern := range v.ForbiddenPatterns {
+		if strings.Contains(translated, pattern) {
+			result.IsValid = false
+			result.Errors = append(result.Errors,
+				"翻译结果包含模板占位符文本，表明翻译失败")
+			logger.Warn("forbidden pattern found in translation",
+				logger.String("pattern", pattern))
+			break // One forbidden pattern is enough to fail
+		}
+	}
+
+	// Check if original document structure is preserved
+	if !v.checkStructurePreserved(original, translated) {
+		result.Warnings = append(result.Warnings,
+			"翻译结果的文档结构可能与原文不一致")
+	}
+
+	return result
+}
+
+// checkChineseRatio fails a translation with too few Chinese characters
+func (v *TranslationValidator) checkChineseRatio(result *TranslationValidationResult) {
 	chineseRatio := float64(result.ChineseCharCount) / float64(result.TranslatedLength)
-	
+
 	// For files with mostly configuration (< 10000 chars), be more lenient
 	minChineseRatio := v.MinChineseRatio
 	if result.TranslatedLength < 10000 {
@@ -389,38 +440,97 @@ func (v *TranslationValidator) ValidateTranslation(original, translated string)
 			logger.Float64("chineseRatio", chineseRatio),
 			logger.Int("chineseCount", result.ChineseCharCount))
 	}
+}
 
-	// Check required patterns
-	for _, pattern := range v.RequiredPatterns {
-		re := regexp.MustCompile(pattern)
-		if re.MatchString(original) && !re.MatchString(translated) {
+// checkTargetLanguage fails a translation to a language other than Chinese that has too
+// few characters of the target script, or for Latin-script languages, that left most of
+// the source text unchanged
+func (v *TranslationValidator) checkTargetLanguage(original, translated string, result *TranslationValidationResult) {
+	name := v.Language.DisplayName()
+	count, hasScript := countTargetScriptCharacters(translated, v.Language)
+	if hasScript {
+		ratio := float64(count) / float64(result.TranslatedLength)
+		minRatio := v.MinChineseRatio
+		if result.TranslatedLength < 10000 {
+			minRatio = 0.02
+		}
+		if ratio < minRatio && result.TranslatedLength > 1000 {
 			result.IsValid = false
 			result.Errors = append(result.Errors,
-				"翻译结果丢失了必要的 LaTeX 结构: "+pattern)
-			logger.Warn("required pattern missing in translation",
-				logger.String("pattern", pattern))
+				fmt.Sprintf("翻译结果中%s字符过少，可能翻译失败 (%s比例: %.2f%%, 需要至少 %.2f%%)",
+					name, name, ratio*100, minRatio*100))
+			logger.Warn("too few target script characters in translation",
+				logger.String("language", string(v.Language)),
+				logger.Float64("ratio", ratio),
+				logger.Int("count", count))
 		}
+		return
 	}
 
-	// Check forbidden patterns (template text)
-	for _, pattern := range v.ForbiddenPatterns {
-		if strings.Contains(translated, pattern) {
-			result.IsValid = false
-			result.Errors = append(result.Errors,
-				"翻译结果包含模板占位符文本，表明翻译失败")
-			logger.Warn("forbidden pattern found in translation",
-				logger.String("pattern", pattern))
-			break // One forbidden pattern is enough to fail
-		}
+	unchanged, prose := countUnchangedProseLines(original, translated)
+	if prose >= minProseLinesForSourceCheck && float64(unchanged) > maxUnchangedProseRatio*float64(prose) {
+		result.IsValid = false
+		result.Errors = append(result.Errors,
+			fmt.Sprintf("%s%s (未翻译的文本行: %d/%d)", untranslatedResultMarker, name, unchanged, prose))
+		logger.Warn("translation left the source text unchanged",
+			logger.String("language", string(v.Language)),
+			logger.Int("unchangedLines", unchanged),
+			logger.Int("proseLines", prose))
 	}
+}
 
-	// Check if original document structure is preserved
-	if !v.checkStructurePreserved(original, translated) {
-		result.Warnings = append(result.Warnings,
-			"翻译结果的文档结构可能与原文不一致")
+const (
+	// minProseLinesForSourceCheck is the number of prose lines a document needs before
+	// a Latin-script translation is checked for differing from the source
+	minProseLinesForSourceCheck = 10
+	// maxUnchangedProseRatio is the share of prose lines a Latin-script translation may
+	// leave unchanged
+	maxUnchangedProseRatio = 0.9
+)
+
+// untranslatedResultMarker starts the validation error of a Latin-script translation
+// that is the source text
+const untranslatedResultMarker = "译文与原文几乎相同，可能没有翻译成"
+
+// countUnchangedProseLines returns how many prose lines of original (lines with at least
+// 20 letters that are not comments or commands) appear unchanged in translated, and the
+// number of prose lines
+func countUnchangedProseLines(original, translated string) (unchanged, prose int) {
+	translatedLines := make(map[string]bool)
+	for _, line := range strings.Split(translated, "\n") {
+		translatedLines[strings.TrimSpace(line)] = true
+	}
+	for _, line := range strings.Split(original, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "\\") {
+			continue
+		}
+		letters := 0
+		for _, r := range line {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		if letters < 20 {
+			continue
+		}
+		prose++
+		if translatedLines[line] {
+			unchanged++
+		}
 	}
+	return unchanged, prose
+}
 
-	return result
+// IsUntranslatedResult reports whether err is a validation failure because the result
+// has (almost) no text in the target language. Files without translatable text fail this
+// way and are copied unchanged.
+func IsUntranslatedResult(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "字符过少") || strings.Contains(msg, untranslatedResultMarker)
 }
 
 // checkStructurePreserved checks if key document structure is preserved
@@ -498,8 +608,8 @@ func (v *TranslationValidator) ValidateChunkTranslation(originalChunk, translate
 		}
 	}
 
-	// For chunks with substantial text, check Chinese ratio
-	if result.OriginalLength > 500 {
+	// For Chinese chunks with substantial text, check Chinese ratio
+	if result.OriginalLength > 500 && v.Language.IsChinese() {
 		chineseRatio := float64(result.ChineseCharCount) / float64(result.TranslatedLength)
 		if chineseRatio < 0.02 {
 			result.Warnings = append(result.Warnings,
@@ -811,6 +921,10 @@ func ValidateComments(original, translated string) *CommentValidation {
 		UncommentedEnvTags:     []UncommentedEnvTag{},
 	}
 
+	// \end{document} 或 \endinput 之后的内容不会被 TeX 读取，不参与比较
+	original = parser.DocumentBody(original)
+	translated = parser.DocumentBody(translated)
+
 	// 提取原始内容中的注释行信息
 	originalComments := extractCommentLines(original)
 	result.OriginalCommentLines = getLineNumbers(originalComments)
@@ -1180,6 +1294,11 @@ func CompareStructure(original, translated string) *StructureComparison {
 		Differences: []StructureDifference{},
 	}
 
+	// \end{document} 之后的残留内容（旧稿、笔记等）不属于文档结构，
+	// 否则会报告并不存在的差异
+	original = parser.DocumentBody(original)
+	translated = parser.DocumentBody(translated)
+
 	// 提取原始文档结构
 	result.OriginalStructure = extractDocumentStructure(original)
 	
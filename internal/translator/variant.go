@@ -0,0 +1,219 @@
+package translator
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+//go:embed zhdata/s2t_chars.txt zhdata/s2t_phrases.txt
+var zhDataFS embed.FS
+
+// traditionalChinesePrompt is appended to the system prompt when translating to traditional
+// Chinese. The output is converted afterwards anyway, so the model's occasional simplified
+// characters do not matter.
+const traditionalChinesePrompt = `
+
+## OUTPUT SCRIPT
+- Write all Chinese text in Traditional Chinese characters (繁體中文), never Simplified Chinese
+- Use Traditional Chinese punctuation: 「」『』，。、；：（）`
+
+// s2tTable is the simplified to traditional conversion table, loaded on first use
+var s2tTable struct {
+	once      sync.Once
+	chars     map[rune]rune
+	phrases   map[string]string
+	maxPhrase int // length of the longest phrase in runes
+}
+
+// loadS2TTable loads the embedded conversion tables
+func loadS2TTable() {
+	s2tTable.chars = make(map[rune]rune)
+	s2tTable.phrases = make(map[string]string)
+	readZhData("zhdata/s2t_chars.txt", func(from, to string) {
+		f, _ := utf8.DecodeRuneInString(from)
+		t, _ := utf8.DecodeRuneInString(to)
+		s2tTable.chars[f] = t
+	})
+	readZhData("zhdata/s2t_phrases.txt", func(from, to string) {
+		s2tTable.phrases[from] = to
+		if n := utf8.RuneCountInString(from); n > s2tTable.maxPhrase {
+			s2tTable.maxPhrase = n
+		}
+	})
+	logger.Debug("loaded Chinese conversion tables",
+		logger.Int("characters", len(s2tTable.chars)),
+		logger.Int("phrases", len(s2tTable.phrases)))
+}
+
+// readZhData calls fn for each "from<TAB>to" line of an embedded table, skipping comments
+func readZhData(name string, fn func(from, to string)) {
+	data, err := zhDataFS.ReadFile(name)
+	if err != nil {
+		logger.Error("failed to read embedded Chinese conversion table", err, logger.String("file", name))
+		return
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if from, to, ok := strings.Cut(line, "\t"); ok && from != "" && to != "" {
+			fn(from, to)
+		}
+	}
+}
+
+// ConvertToTraditional converts simplified Chinese characters in text to traditional ones,
+// OpenCC style: the longest matching phrase is converted as a unit, other characters one by
+// one, and everything that is not simplified Chinese is left untouched.
+// exceptions pins the rendering of phrases (e.g. terms fixed by a glossary): a key found in
+// the text, in simplified or already converted form, is replaced by its value, or kept as the
+// key when the value is empty, instead of being converted.
+func ConvertToTraditional(text string, exceptions map[string]string) string {
+	s2tTable.once.Do(loadS2TTable)
+	if !containsHan(text) {
+		return text
+	}
+
+	// Exceptions match both as written and as the character table would convert them,
+	// since the model is asked to write traditional characters already
+	pinned := make(map[string]string, len(exceptions)*2)
+	maxPinned := 0
+	for from, to := range exceptions {
+		if from == "" {
+			continue
+		}
+		if to == "" {
+			to = from
+		}
+		pinned[from] = to
+		pinned[convertCharacters(from)] = to
+		if n := utf8.RuneCountInString(from); n > maxPinned {
+			maxPinned = n
+		}
+	}
+
+	runes := []rune(text)
+	var sb strings.Builder
+	sb.Grow(len(text))
+	for i := 0; i < len(runes); {
+		if n, to := longestMatch(runes, i, maxPinned, 1, pinned); n > 0 {
+			sb.WriteString(to)
+			i += n
+			continue
+		}
+		if n, to := longestMatch(runes, i, s2tTable.maxPhrase, 2, s2tTable.phrases); n > 0 {
+			sb.WriteString(to)
+			i += n
+			continue
+		}
+		if t, ok := s2tTable.chars[runes[i]]; ok {
+			sb.WriteRune(t)
+		} else {
+			sb.WriteRune(runes[i])
+		}
+		i++
+	}
+	return sb.String()
+}
+
+// convertCharacters converts text character by character, without phrases
+func convertCharacters(text string) string {
+	return strings.Map(func(r rune) rune {
+		if t, ok := s2tTable.chars[r]; ok {
+			return t
+		}
+		return r
+	}, text)
+}
+
+// longestMatch returns the length in runes and the replacement of the longest key of table
+// (between minLen and maxLen runes) starting at runes[i], or 0 if none matches
+func longestMatch(runes []rune, i, maxLen, minLen int, table map[string]string) (int, string) {
+	if len(table) == 0 || !isHan(runes[i]) {
+		return 0, ""
+	}
+	if maxLen > len(runes)-i {
+		maxLen = len(runes) - i
+	}
+	for n := maxLen; n >= minLen; n-- {
+		if to, ok := table[string(runes[i:i+n])]; ok {
+			return n, to
+		}
+	}
+	return 0, ""
+}
+
+// containsHan reports whether text contains CJK ideographs
+func containsHan(text string) bool {
+	for _, r := range text {
+		if isHan(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHan reports whether r is a CJK unified ideograph (including extension A)
+func isHan(r rune) bool {
+	return r >= 0x3400 && r <= 0x9FFF
+}
+
+// SetChineseVariant sets the script of the generated Chinese text. For traditional Chinese
+// the model is prompted accordingly and its output is converted; phrases are rendered as
+// configured instead of being converted (see ConvertToTraditional).
+func (t *TranslationEngine) SetChineseVariant(variant types.ChineseVariant, phrases map[string]string) {
+	t.variant = variant
+	t.variantPhrases = phrases
+}
+
+// ChineseVariant returns the script of the generated Chinese text
+func (t *TranslationEngine) ChineseVariant() types.ChineseVariant {
+	if t.variant == "" {
+		return types.ChineseSimplified
+	}
+	return t.variant
+}
+
+// systemPromptForVariant adds the output script instruction to a system prompt
+func systemPromptForVariant(prompt string, variant types.ChineseVariant) string {
+	if variant == types.ChineseTraditional {
+		return prompt + traditionalChinesePrompt
+	}
+	return prompt
+}
+
+// variantPhrasesHash returns a hash of the phrases pinned for traditional Chinese output.
+// They change the converted text, so they are part of the chunk cache key; the hash is
+// empty for other scripts and without phrases.
+func (t *TranslationEngine) variantPhrasesHash() string {
+	if t.outputVariant() != types.ChineseTraditional || len(t.variantPhrases) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(t.variantPhrases))
+	for phrase, rendering := range t.variantPhrases {
+		lines = append(lines, phrase+"\t"+rendering)
+	}
+	sort.Strings(lines)
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// convertToVariant converts text generated by the model to the engine's script. It must
+// only be applied to model output, never to protected content.
+func (t *TranslationEngine) convertToVariant(text string) string {
+	if t.outputVariant() != types.ChineseTraditional {
+		return text
+	}
+	return ConvertToTraditional(text, t.variantPhrases)
+}
@@ -0,0 +1,131 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+func TestConvertToTraditional(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		exceptions map[string]string
+		want       string
+	}{
+		{"characters", "这个方法计算简单", nil, "這個方法計算簡單"},
+		{"phrases", "复杂的关系", nil, "複雜的關係"},
+		{"ambiguous character by phrase", "头发很干净", nil, "頭髮很乾淨"},
+		{"ambiguous character alone", "干扰", nil, "干擾"},
+		{"non Chinese untouched", "\\section{Results} $x^2$ 100%", nil, "\\section{Results} $x^2$ 100%"},
+		{"already traditional", "這個關係", nil, "這個關係"},
+		{"pinned term kept", "提示词很重要", map[string]string{"提示词": ""}, "提示词很重要"},
+		{"pinned term replaced", "使用数据集", map[string]string{"数据集": "資料集"}, "使用資料集"},
+		{"pinned term already converted by model", "使用數據集", map[string]string{"数据集": "資料集"}, "使用資料集"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConvertToTraditional(tt.text, tt.exceptions); got != tt.want {
+				t.Errorf("ConvertToTraditional(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChineseVariant(t *testing.T) {
+	for name, want := range map[string]types.ChineseVariant{
+		"": types.ChineseSimplified, "zh-CN": types.ChineseSimplified, "zh-Hans": types.ChineseSimplified,
+		"zh-Hant": types.ChineseTraditional, "zh_TW": types.ChineseTraditional, "繁體": types.ChineseTraditional,
+	} {
+		if got, err := types.ParseChineseVariant(name); err != nil || got != want {
+			t.Errorf("ParseChineseVariant(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := types.ParseChineseVariant("ja"); err == nil {
+		t.Error("expected an error for an unknown variant")
+	}
+}
+
+func TestTranslationInTraditionalChinese(t *testing.T) {
+	var mu sync.Mutex
+	var systemPrompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		systemPrompts = append(systemPrompts, req.Messages[0].Content)
+		mu.Unlock()
+
+		prompt := req.Messages[len(req.Messages)-1].Content
+		chunk := prompt
+		for _, header := range []string{"Keep the same line structure.\n\n", "Now translate:\n\n"} {
+			if i := strings.Index(prompt, header); i != -1 {
+				chunk = prompt[i+len(header):]
+			}
+		}
+		// The model answers in simplified Chinese regardless of the prompt
+		translated := strings.NewReplacer(
+			"The relation is complex.", "这个关系很复杂。",
+			"Prompt engineering matters.", "提示词工程很重要。",
+		).Replace(chunk)
+		resp := ChatCompletionResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: translated}, FinishReason: "stop"}},
+			Usage:   Usage{TotalTokens: 10},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	content := "\\section{Intro}\nThe relation is complex.\n\n" +
+		"Prompt engineering matters. \\label{sec:简体}\n"
+
+	engine := NewTranslationEngineWithConfig("test-key", "test-model", server.URL+"/v1/chat/completions", 5*time.Second, 1)
+	engine.SetChineseVariant(types.ChineseTraditional, map[string]string{"提示词": ""})
+	result, err := engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+	translated := result.TranslatedContent
+	if !strings.Contains(translated, "這個關係很複雜。") {
+		t.Errorf("model output was not converted to traditional Chinese: %q", translated)
+	}
+	if !strings.Contains(translated, "提示词工程很重要。") {
+		t.Errorf("pinned term was not kept: %q", translated)
+	}
+	if !strings.Contains(translated, "\\label{sec:简体}") {
+		t.Errorf("protected content was converted: %q", translated)
+	}
+	for _, prompt := range systemPrompts {
+		if !strings.Contains(prompt, "Traditional Chinese") {
+			t.Errorf("system prompt does not ask for traditional Chinese")
+		}
+	}
+
+	// Switching back leaves the output as generated
+	engine.SetChineseVariant(types.ChineseSimplified, nil)
+	mu.Lock()
+	systemPrompts = nil
+	mu.Unlock()
+	result, err = engine.TranslateTeX(content)
+	if err != nil {
+		t.Fatalf("translation failed: %v", err)
+	}
+	if !strings.Contains(result.TranslatedContent, "这个关系很复杂。") {
+		t.Errorf("simplified output was converted: %q", result.TranslatedContent)
+	}
+	for _, prompt := range systemPrompts {
+		if strings.Contains(prompt, "Traditional Chinese") {
+			t.Errorf("system prompt still asks for traditional Chinese")
+		}
+	}
+}
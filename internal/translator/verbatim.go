@@ -0,0 +1,111 @@
+package translator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
+)
+
+// algorithmEnvNames are the environments of pseudocode blocks. Their captions are
+// translated with those of the other floats; the rest is kept out of the chunks, since the
+// model translated the comments and changed the indentation of the steps.
+var algorithmEnvNames = []string{"algorithm", "algorithm*", "algorithmic", "algorithm2e"}
+
+// protectVerbatimEnvironments replaces the verbatim-like environments of content (see
+// parser.VerbatimRanges) with comment placeholders, so code listings come back byte for
+// byte
+func protectVerbatimEnvironments(content string) (string, []commentPlaceholder) {
+	ranges := parser.VerbatimRanges(content)
+	spans := make([]codeChunkSpan, len(ranges))
+	for i, r := range ranges {
+		spans[i] = codeChunkSpan{r.Start, r.End}
+	}
+	return replaceSpans(content, spans, "VERBATIM_PLACEHOLDER")
+}
+
+// protectAlgorithms replaces the outermost algorithm environments of content with comment
+// placeholders; their captions must have been translated already
+func protectAlgorithms(content string) (string, []commentPlaceholder) {
+	var spans []codeChunkSpan
+	for _, envName := range algorithmEnvNames {
+		beginTag := "\\begin{" + envName + "}"
+		for searchPos := 0; ; {
+			i := indexOutsideComments(content[searchPos:], beginTag)
+			if i == -1 {
+				break
+			}
+			begin := searchPos + i
+			end := findMatchingEndTag(content, begin, envName)
+			if end == -1 {
+				searchPos = begin + len(beginTag)
+				continue
+			}
+			spans = append(spans, codeChunkSpan{begin, end})
+			searchPos = end
+		}
+	}
+	return replaceSpans(content, outermostSpans(spans), "ALGORITHM_PLACEHOLDER")
+}
+
+// replaceSpans replaces the non-overlapping spans of content, in document order, with
+// placeholders %<kind>_<n>%
+func replaceSpans(content string, spans []codeChunkSpan, kind string) (string, []commentPlaceholder) {
+	if len(spans) == 0 {
+		return content, nil
+	}
+	var sb strings.Builder
+	placeholders := make([]commentPlaceholder, 0, len(spans))
+	last := 0
+	for i, s := range spans {
+		placeholder := fmt.Sprintf("%%%s_%d%%", kind, i)
+		sb.WriteString(content[last:s.start])
+		sb.WriteString(placeholder)
+		placeholders = append(placeholders, commentPlaceholder{
+			placeholder: placeholder,
+			original:    content[s.start:s.end],
+		})
+		last = s.end
+		logger.Debug("protected environment",
+			logger.String("kind", kind),
+			logger.Int("index", i),
+			logger.Int("length", s.end-s.start))
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), placeholders
+}
+
+// outermostSpans returns the spans not inside another one, in document order
+func outermostSpans(spans []codeChunkSpan) []codeChunkSpan {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var merged []codeChunkSpan
+	for _, s := range spans {
+		if len(merged) > 0 && s.start < merged[len(merged)-1].end {
+			if s.end > merged[len(merged)-1].end {
+				merged[len(merged)-1].end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// indexOutsideComments returns the index of the first occurrence of substr in content
+// that is not commented out, or -1
+func indexOutsideComments(content, substr string) int {
+	for offset := 0; ; {
+		i := strings.Index(content[offset:], substr)
+		if i == -1 {
+			return -1
+		}
+		i += offset
+		lineStart := strings.LastIndexByte(content[:i], '\n') + 1
+		if !isCommentedOut(content[lineStart:i]) {
+			return i
+		}
+		offset = i + len(substr)
+	}
+}
@@ -0,0 +1,105 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"latex-translator/internal/parser"
+)
+
+// listingFixture is a code listing whose comments read like prose and whose braces are
+// unbalanced, as in the source of a parser
+const listingFixture = "\\begin{lstlisting}[language=C]\n" +
+	"// The method works well.\n" +
+	"if (c == '{') {   depth++;\n" +
+	"    %  not a TeX comment\n" +
+	"\\end{lstlisting}"
+
+// algorithmFixture is an algorithm block with a caption
+const algorithmFixture = "\\begin{algorithm}[t]\n" +
+	"\\caption{The results are good.}\n" +
+	"\\begin{algorithmic}[1]\n" +
+	"\\State The method works well.\n" +
+	"\\end{algorithmic}\n" +
+	"\\end{algorithm}"
+
+func TestVerbatimRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"lstlisting", "Text.\n" + listingFixture + "\nMore.", []string{listingFixture}},
+		{"minted and verbatim", "\\begin{minted}{go}\nx := \"{\"\n\\end{minted}\n\\begin{verbatim}\n}\n\\end{verbatim}",
+			[]string{"\\begin{minted}{go}\nx := \"{\"\n\\end{minted}", "\\begin{verbatim}\n}\n\\end{verbatim}"}},
+		{"starred Verbatim", "\\begin{Verbatim*}\n{\n\\end{Verbatim*}", []string{"\\begin{Verbatim*}\n{\n\\end{Verbatim*}"}},
+		{"commented out", "% \\begin{verbatim}\nText {x}.\n", nil},
+		{"verb argument", "\\verb|\\begin{verbatim}| text", nil},
+		{"unterminated", "\\begin{verbatim}\n{ never closed", []string{"\\begin{verbatim}\n{ never closed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range parser.VerbatimRanges(tt.content) {
+				got = append(got, tt.content[r.Start:r.End])
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("VerbatimRanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlankVerbatimKeepsLines(t *testing.T) {
+	content := "A {b}.\n" + listingFixture + "\nC.\n"
+	blanked := parser.BlankVerbatim(content)
+	if len(blanked) != len(content) || strings.Count(blanked, "\n") != strings.Count(content, "\n") {
+		t.Fatalf("BlankVerbatim() changed offsets or lines: %q", blanked)
+	}
+	if strings.Count(blanked, "{") != strings.Count(blanked, "}") {
+		t.Errorf("braces of the listing were kept: %q", blanked)
+	}
+	if !strings.HasPrefix(blanked, "A {b}.\n") || !strings.HasSuffix(blanked, "\nC.\n") {
+		t.Errorf("content around the listing changed: %q", blanked)
+	}
+}
+
+func TestProtectVerbatimRestoresListings(t *testing.T) {
+	content := "Text.\n" + listingFixture + "\n\\begin{verbatim}\n}\n\\end{verbatim}\n"
+	masked, restore := parser.ProtectVerbatim(content)
+	if strings.Contains(masked, "depth++") || strings.Contains(masked, "\\begin{verbatim}") {
+		t.Fatalf("listings not masked: %q", masked)
+	}
+	if got := restore(masked); got != content {
+		t.Errorf("restore() = %q, want %q", got, content)
+	}
+}
+
+func TestTranslationKeepsListingsAndAlgorithms(t *testing.T) {
+	content := "\\documentclass{article}\n\\usepackage{listings}\n\\begin{document}\n" +
+		"The method works well.\n\n" + listingFixture + "\n\n" + algorithmFixture + "\n\n" +
+		"The results are good.\n\\end{document}\n"
+
+	translated, sent, _ := translateFixture(t, content)
+	for _, chunk := range sent {
+		if strings.Contains(chunk, "depth++") || strings.Contains(chunk, "\\State") {
+			t.Errorf("protected code sent to the model: %q", chunk)
+		}
+	}
+	if !strings.Contains(translated, listingFixture) {
+		t.Errorf("listing changed:\n%s", translated)
+	}
+	if !strings.Contains(translated, "\\State The method works well.") {
+		t.Errorf("algorithm body changed:\n%s", translated)
+	}
+	if !strings.Contains(translated, "\\caption{结果很好。}") {
+		t.Errorf("algorithm caption not translated:\n%s", translated)
+	}
+}
+
+func TestFixIncompleteTabularColumnSpecSkipsListings(t *testing.T) {
+	listing := "\\begin{verbatim}\n\\begin{tabular}{@{}ll@{}\n\\toprule\n\\end{verbatim}"
+	if got := FixIncompleteTabularColumnSpec(listing); got != listing {
+		t.Errorf("FixIncompleteTabularColumnSpec() changed a listing: %q", got)
+	}
+}
@@ -1,18 +1,75 @@
 // Package types defines core data types and enums for the LaTeX translator application.
 package types
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Config 应用配置
 type Config struct {
 	// OpenAI/LLM 配置 (开源模式使用)
 	OpenAIAPIKey    string `json:"openai_api_key"`
 	OpenAIBaseURL   string `json:"openai_base_url"`   // OpenAI 兼容 API 的 Base URL
 	OpenAIModel     string `json:"openai_model"`
+	// LLM 服务提供方: openai（OpenAI 及兼容 API，默认）或 anthropic（Claude Messages API）；API Key、Base URL 和模型共用上面三项
+	Provider        string `json:"provider,omitempty"`
 	ContextWindow   int    `json:"context_window"`    // 上下文窗口大小（tokens），用于 LaTeX 和 PDF 翻译的批次大小控制
 	DefaultCompiler string `json:"default_compiler"`  // "pdflatex" 或 "xelatex"
 	WorkDirectory   string `json:"work_directory"`
 	LastInput       string `json:"last_input"`        // 最后一次输入的 ID/URL/路径
 	InputHistory    []InputHistoryItem `json:"input_history"` // 输入历史记录
 	Concurrency     int    `json:"concurrency"`       // 翻译并发数，用于 LaTeX 和 PDF 翻译的并发批次处理，默认为 3
+	MaxNetworkPauseMinutes int `json:"max_network_pause_minutes,omitempty"` // 网络中断时最长等待恢复的时间（分钟），默认为 10
+	StallWindowSeconds int `json:"stall_window_seconds,omitempty"` // 单个分块请求无响应多久视为停滞并重试（秒，按分块大小放大），默认为 180
+	RequestTimeoutSeconds int `json:"request_timeout_seconds,omitempty"` // 单个翻译请求的超时（秒），超时后取消并重试，默认为 120
+	// 单篇文档每次翻译的预算：最长用时（分钟）和 token 上限，为 0 表示不限；超出后中止翻译并保存进度，可继续翻译
+	BudgetMinutes int `json:"budget_minutes,omitempty"`
+	BudgetTokens  int `json:"budget_tokens,omitempty"`
+	ChineseVariant  string `json:"chinese_variant,omitempty"` // 译文字形: zh-Hans（简体，默认）或 zh-Hant（繁体）
+	TargetLanguage  string `json:"target_language,omitempty"` // 译文语言: zh（中文，默认）、ja、ko、ru、en、fr、de、es
+	// 繁体输出时的词语例外（如术语表固定的译法）：键为简体词语，值为应呈现的写法，值为空表示保持键的写法
+	ChineseVariantPhrases map[string]string `json:"chinese_variant_phrases,omitempty"`
+	MaxConcurrentCompiles int `json:"max_concurrent_compiles,omitempty"` // 同时运行的 LaTeX 编译进程数上限（所有功能共享），默认为 2
+	// 严格归档模式：生成的 PDF 必须嵌入全部字体，否则尝试修复，修复失败则任务失败
+	StrictFontEmbedding bool `json:"strict_font_embedding,omitempty"`
+	// 各模型的上下文窗口（tokens），覆盖或补充内置的已知模型表，键为模型名前缀
+	ModelContextWindows map[string]int `json:"model_context_windows,omitempty"`
+	// 各模型的价格（美元/百万 tokens），覆盖或补充内置价格表，用于估算翻译费用，键为模型名前缀
+	ModelPrices map[string]ModelPrice `json:"model_prices,omitempty"`
+	// 未知模型首次翻译成功时使用的上下文窗口，作为该模型以后的推荐值
+	LearnedContextWindows map[string]int `json:"learned_context_windows,omitempty"`
+	// 翻译后索引词条（\index）的排序键: pinyin（按译文拼音排序，默认）或 original（保留英文原词排序）
+	IndexSortKeys string `json:"index_sort_keys,omitempty"`
+	// 术语表文件（JSON 或 CSV），英文术语到固定译法的映射，注入每个分块的提示词并强制替换
+	GlossaryPath string `json:"glossary_path,omitempty"`
+	// 提示词预设: default（默认）、math、cs 或 biomed，在默认提示词中加入该领域的翻译说明
+	PromptPreset string `json:"prompt_preset,omitempty"`
+	// 自定义提示词模板文件，替代所选预设；缺少必需占位符时使用默认模板（见 docs/PROMPT_TEMPLATES.md）
+	PromptTemplatePath string `json:"prompt_template_path,omitempty"`
+	// 是否翻译整行注释；默认不翻译：整行注释不发送给模型，翻译后原样放回
+	TranslateComments bool `json:"translate_comments,omitempty"`
+	// 是否翻译参考文献条目中的描述性文字（会议名称、备注等）；默认不翻译：thebibliography 环境整体原样保留
+	TranslateBibliography bool `json:"translate_bibliography,omitempty"`
+	// 每个分块附带上一分块的最后一段作为只读上下文（不重复翻译），改善分块衔接处的译文；默认关闭
+	ChunkOverlap bool `json:"chunk_overlap,omitempty"`
+	// 编译时自动安装缺少的宏包：MiKTeX 即时安装，TeX Live 用 tlmgr install；默认关闭
+	AutoInstallPackages bool `json:"auto_install_packages,omitempty"`
+	// 忽略主文件中的 \includeonly：移除该指令，翻译并编译全部文件；默认关闭，只翻译指令列出的文件并保留指令
+	IgnoreIncludeOnly bool `json:"ignore_includeonly,omitempty"`
+	// 调试捕获目录：设置后，校验失败或需要重试的分块的提示词、响应和元数据保存到其中每次运行的子目录（不含 API Key），
+	// 相对路径位于工作目录下；为空时不捕获
+	DebugCaptureDir string `json:"debug_capture_dir,omitempty"`
+	// 完成通知：翻译成功或失败时调用。HTTPS 地址会收到 POST 的 JSON（来源、状态、PDF 路径、token 数、耗时），
+	// 其他内容作为命令由 shell 执行，这些值通过 LT_* 环境变量传入；为空时不通知
+	OnCompleteHook string `json:"on_complete_hook,omitempty"`
+	// 代理地址（如 http://127.0.0.1:7890、socks5://127.0.0.1:1080），用于下载、翻译 API 和授权服务器等全部网络请求；
+	// 为空时使用 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量
+	Proxy string `json:"proxy,omitempty"`
+	// 翻译请求体的调整，用于只支持部分 OpenAI 参数的服务（llama.cpp、vLLM 等本地模型）
+	RequestShape *RequestShape `json:"request_shape,omitempty"`
+	// 工作目录中论文子目录的清理策略，启动时执行；为空时保留最近 20 篇、总大小不超过 10 GB
+	WorkspacePolicy *WorkspacePolicy `json:"workspace_policy,omitempty"`
 	// GitHub 分享配置
 	GitHubToken     string `json:"github_token"`      // GitHub Personal Access Token
 	GitHubOwner     string `json:"github_owner"`      // GitHub 仓库所有者
@@ -28,6 +85,162 @@ type Config struct {
 	EncryptedLicenseInfo string `json:"encrypted_license_info,omitempty"` // 加密的授权信息
 }
 
+// ChineseVariant 中文译文的字形
+type ChineseVariant string
+
+const (
+	ChineseSimplified  ChineseVariant = "zh-Hans" // 简体中文
+	ChineseTraditional ChineseVariant = "zh-Hant" // 繁体中文
+)
+
+// ParseChineseVariant 解析字形名称，支持 zh-Hans/zh-Hant 及 zh-CN、zh-TW、zh-HK 等常见写法，空字符串表示简体
+func ParseChineseVariant(name string) (ChineseVariant, error) {
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-") {
+	case "", "zh-hans", "hans", "zh-cn", "zh-sg", "simplified", "简体":
+		return ChineseSimplified, nil
+	case "zh-hant", "hant", "zh-tw", "zh-hk", "zh-mo", "traditional", "繁体", "繁體":
+		return ChineseTraditional, nil
+	}
+	return ChineseSimplified, NewAppError(ErrInvalidInput, "不支持的中文字形: "+name+"（可选 zh-Hans 或 zh-Hant）", nil)
+}
+
+// NormalizeChineseVariant 返回规范的字形名称，无法识别时视为简体
+func NormalizeChineseVariant(name string) ChineseVariant {
+	variant, _ := ParseChineseVariant(name)
+	return variant
+}
+
+// DisplayName 返回字形的中文名称
+func (v ChineseVariant) DisplayName() string {
+	if v == ChineseTraditional {
+		return "繁体中文"
+	}
+	return "简体中文"
+}
+
+// TargetLanguage 译文语言
+type TargetLanguage string
+
+const (
+	LanguageChinese  TargetLanguage = "zh" // 中文（默认，字形由 ChineseVariant 决定）
+	LanguageJapanese TargetLanguage = "ja" // 日语
+	LanguageKorean   TargetLanguage = "ko" // 韩语
+	LanguageRussian  TargetLanguage = "ru" // 俄语
+	LanguageEnglish  TargetLanguage = "en" // 英语
+	LanguageFrench   TargetLanguage = "fr" // 法语
+	LanguageGerman   TargetLanguage = "de" // 德语
+	LanguageSpanish  TargetLanguage = "es" // 西班牙语
+)
+
+// TargetLanguages 支持的译文语言，按界面显示顺序
+var TargetLanguages = []TargetLanguage{
+	LanguageChinese, LanguageJapanese, LanguageKorean, LanguageRussian,
+	LanguageEnglish, LanguageFrench, LanguageGerman, LanguageSpanish,
+}
+
+// ParseTargetLanguage 解析语言名称，支持 ISO 639-1 代码（可带地区，如 zh-CN、ja-JP）及英文名称，空字符串表示中文
+func ParseTargetLanguage(name string) (TargetLanguage, error) {
+	code := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+	switch code {
+	case "", "chinese", "中文":
+		return LanguageChinese, nil
+	case "japanese", "日语", "日本語":
+		return LanguageJapanese, nil
+	case "korean", "韩语", "한국어":
+		return LanguageKorean, nil
+	case "russian", "俄语":
+		return LanguageRussian, nil
+	case "english", "英语":
+		return LanguageEnglish, nil
+	case "french", "法语":
+		return LanguageFrench, nil
+	case "german", "德语":
+		return LanguageGerman, nil
+	case "spanish", "西班牙语":
+		return LanguageSpanish, nil
+	}
+	if base, _, _ := strings.Cut(code, "-"); base != "" {
+		for _, lang := range TargetLanguages {
+			if base == string(lang) {
+				return lang, nil
+			}
+		}
+	}
+	return LanguageChinese, NewAppError(ErrInvalidInput, "不支持的译文语言: "+name+"（可选 zh、ja、ko、ru、en、fr、de、es）", nil)
+}
+
+// BilingualLayout 双语对照 PDF 的版式
+type BilingualLayout string
+
+const (
+	BilingualSideBySide  BilingualLayout = "side-by-side" // 左右并排（横向），原文在左、译文在右
+	BilingualInterleaved BilingualLayout = "interleaved"  // 原文页与译文页交替（纵向 A4），便于打印
+)
+
+// ParseBilingualLayout 解析双语版式名称，空字符串表示左右并排
+func ParseBilingualLayout(name string) (BilingualLayout, error) {
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-") {
+	case "", "side-by-side", "sidebyside", "side":
+		return BilingualSideBySide, nil
+	case "interleaved", "interleave", "alternate", "alternating":
+		return BilingualInterleaved, nil
+	}
+	return BilingualSideBySide, NewAppError(ErrInvalidInput, "不支持的双语版式: "+name+"（可选 side-by-side 或 interleaved）", nil)
+}
+
+// NormalizeTargetLanguage 返回规范的语言代码，无法识别时视为中文
+func NormalizeTargetLanguage(name string) TargetLanguage {
+	lang, _ := ParseTargetLanguage(name)
+	return lang
+}
+
+// IsChinese 是否为中文译文（空值视为中文）
+func (l TargetLanguage) IsChinese() bool {
+	return l == "" || l == LanguageChinese
+}
+
+// DisplayName 返回语言的中文名称
+func (l TargetLanguage) DisplayName() string {
+	switch l {
+	case LanguageJapanese:
+		return "日语"
+	case LanguageKorean:
+		return "韩语"
+	case LanguageRussian:
+		return "俄语"
+	case LanguageEnglish:
+		return "英语"
+	case LanguageFrench:
+		return "法语"
+	case LanguageGerman:
+		return "德语"
+	case LanguageSpanish:
+		return "西班牙语"
+	}
+	return "中文"
+}
+
+// EnglishName 返回语言的英文名称，用于提示词
+func (l TargetLanguage) EnglishName() string {
+	switch l {
+	case LanguageJapanese:
+		return "Japanese"
+	case LanguageKorean:
+		return "Korean"
+	case LanguageRussian:
+		return "Russian"
+	case LanguageEnglish:
+		return "English"
+	case LanguageFrench:
+		return "French"
+	case LanguageGerman:
+		return "German"
+	case LanguageSpanish:
+		return "Spanish"
+	}
+	return "Chinese"
+}
+
 // InputHistoryItem 输入历史记录项
 type InputHistoryItem struct {
 	Input     string `json:"input"`      // 输入内容（arXiv ID、URL 或文件路径）
@@ -129,6 +342,8 @@ type SourceInfo struct {
 	ExtractDir  string     `json:"extract_dir"`
 	MainTexFile string     `json:"main_tex_file"`
 	AllTexFiles []string   `json:"all_tex_files"`
+	// MainTexFallbackFrom 首选主文件编译失败后自动切换时，记录最初选择的主文件
+	MainTexFallbackFrom string `json:"main_tex_fallback_from,omitempty"`
 }
 
 // ProcessPhase 处理阶段枚举
@@ -151,15 +366,185 @@ type Status struct {
 	Progress int          `json:"progress"` // 0-100
 	Message  string       `json:"message"`
 	Error    string       `json:"error,omitempty"`
+	// CachedChunks 本次翻译中从分块缓存取得的分块数（继续中断的翻译时不再请求模型）
+	CachedChunks int `json:"cached_chunks,omitempty"`
+	// DebugCaptureDir 本次翻译保存失败分块提示词和响应的目录（开启调试捕获且有分块被捕获时），可附在问题报告中
+	DebugCaptureDir string `json:"debug_capture_dir,omitempty"`
+	// Translation 翻译阶段的逐文件进度，其他阶段为空
+	Translation *TranslationDetail `json:"translation,omitempty"`
+}
+
+// TranslationDetail 翻译阶段的逐文件进度，多文件文档的进度条长时间停在同一段时显示正在翻译哪个文件的哪一块
+type TranslationDetail struct {
+	File        string `json:"file"`              // 当前文件，相对源码根目录
+	FileIndex   int    `json:"file_index"`        // 当前文件的序号（从 1 开始）
+	TotalFiles  int    `json:"total_files"`       // 文档的文件总数（包括原样保留的文件）
+	Chunk       int    `json:"chunk"`             // 当前文件已翻译的分块数
+	TotalChunks int    `json:"total_chunks"`      // 当前文件的分块数；原样保留或复用译文的文件为 0
+	Section     string `json:"section,omitempty"` // 当前分块所在的章节
+	TokensUsed  int    `json:"tokens_used"`       // 本次翻译累计消耗的 token
 }
 
 // ProcessResult 处理结果
 type ProcessResult struct {
-	OriginalPDFPath   string      `json:"original_pdf_path"`
-	TranslatedPDFPath string      `json:"translated_pdf_path"`
-	BilingualPDFPath  string      `json:"bilingual_pdf_path"` // 双语并排 PDF 路径
-	SourceInfo        *SourceInfo `json:"source_info"`
-	SourceID          string      `json:"source_id"` // arXiv ID 或 zip 文件名（不含扩展名）
+	OriginalPDFPath   string       `json:"original_pdf_path"`
+	TranslatedPDFPath string       `json:"translated_pdf_path"`
+	BilingualPDFPath  string       `json:"bilingual_pdf_path"` // 双语并排 PDF 路径
+	SourceInfo        *SourceInfo  `json:"source_info"`
+	SourceID          string       `json:"source_id"` // arXiv ID 或 zip 文件名（不含扩展名）
+	Provenance        *Provenance  `json:"provenance,omitempty"`
+	ReuseStats        *ReuseStats  `json:"reuse_stats,omitempty"` // 基于旧版本译文翻译时的复用统计
+	FontAudits        []*FontAudit `json:"font_audits,omitempty"` // 翻译 PDF 和双语 PDF 的字体嵌入检查结果
+	QuickMode         bool         `json:"quick_mode,omitempty"`  // 是否以快速模式翻译（降低质量换取速度）
+	// 未编译模式（没有 LaTeX 环境或 --no-compile）：没有编译 PDF，只有译文 tex 和 HTML 预览
+	Uncompiled         bool   `json:"uncompiled,omitempty"`
+	TranslatedHTMLPath string `json:"translated_html_path,omitempty"` // 译文的 HTML 导出
+	TranslatedTexPath  string `json:"translated_tex_path,omitempty"`  // 翻译后的主 tex 文件
+	// 工作目录位于同步文件夹或不可写时，任务在本地临时目录中运行，最终文件复制到该目录
+	PublishedDir string `json:"published_dir,omitempty"`
+	// QualityReportPath 译文 PDF 旁的质量报告（JSON，同目录有文本版），对比原文和译文的结构、环境平衡、重试和修复次数
+	QualityReportPath string `json:"quality_report_path,omitempty"`
+}
+
+// WorkDirCheck 工作目录检查结果
+type WorkDirCheck struct {
+	Path         string `json:"path"`
+	SyncProvider string `json:"sync_provider,omitempty"` // 所在同步文件夹的客户端，如 OneDrive、Dropbox
+	Writable     bool   `json:"writable"`                // 写入探测（创建、重命名、删除临时文件）是否成功
+	ProbeError   string `json:"probe_error,omitempty"`
+	UseScratch   bool   `json:"use_scratch"`       // 任务是否改在本地临时目录中运行
+	Message      string `json:"message,omitempty"` // 给用户的说明，目录没有问题时为空
+}
+
+// WorkspacePolicy 工作目录清理策略。每篇论文在工作目录的 papers 子目录下有自己的目录，
+// 清理时从最久未使用的开始删除，直到满足全部限制；字段为 0 表示不限制
+type WorkspacePolicy struct {
+	KeepRecent int     `json:"keep_recent"`  // 最多保留的论文目录数
+	MaxAgeDays int     `json:"max_age_days"` // 超过这么多天未使用的目录被删除
+	MaxSizeGB  float64 `json:"max_size_gb"`  // 论文目录的总大小上限（GB）
+}
+
+// PaperWorkspace 一篇论文的工作目录
+type PaperWorkspace struct {
+	Name      string `json:"name"` // 目录名：arXiv ID，本地 zip 为 md5_ 加文件哈希
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	LastUsed  string `json:"last_used"`           // 目录中最新文件的修改时间（RFC 3339）
+	Protected bool   `json:"protected,omitempty"` // 论文正在翻译或可以继续翻译，清理时保留
+}
+
+// WorkspaceUsage 工作目录的磁盘占用
+type WorkspaceUsage struct {
+	Root       string           `json:"root"`
+	Papers     []PaperWorkspace `json:"papers"`      // 按最近使用排序
+	PapersSize int64            `json:"papers_size"` // 论文目录的总大小
+	OtherSize  int64            `json:"other_size"`  // 工作目录中其他文件（分块缓存、旧版目录等）的大小
+	TotalSize  int64            `json:"total_size"`
+}
+
+// WorkspaceCleanResult 一次工作目录清理的结果
+type WorkspaceCleanResult struct {
+	DryRun     bool             `json:"dry_run"` // 只列出要删除的目录，没有删除
+	Removed    []PaperWorkspace `json:"removed"` // 删除（或预演中将删除）的目录
+	FreedBytes int64            `json:"freed_bytes"`
+	Kept       int              `json:"kept"`             // 保留的目录数
+	Errors     []string         `json:"errors,omitempty"` // 删除失败的目录
+}
+
+// PDFFont PDF 中使用的一个字体
+type PDFFont struct {
+	Name     string `json:"name"`     // 字体名称（不含子集前缀）
+	Type     string `json:"type"`     // 字体类型，如 Type1、TrueType、Type0、Type3
+	Embedded bool   `json:"embedded"` // 是否已嵌入
+}
+
+// FontAudit 一个 PDF 的字体嵌入检查结果
+// 期刊和机构库通常拒收未嵌入全部字体的 PDF
+type FontAudit struct {
+	PDFPath          string    `json:"pdf_path"`
+	Fonts            []PDFFont `json:"fonts"`                       // PDF 中的全部字体
+	NotEmbedded      []string  `json:"not_embedded,omitempty"`      // 未嵌入的字体名称
+	Remediated       bool      `json:"remediated,omitempty"`        // 是否经过自动修复后才全部嵌入
+	RemediationError string    `json:"remediation_error,omitempty"` // 严格模式下修复失败的原因及处理建议
+}
+
+// FullyEmbedded 返回 PDF 是否已嵌入全部字体
+func (f *FontAudit) FullyEmbedded() bool {
+	return len(f.NotEmbedded) == 0
+}
+
+// LLM 服务提供方
+const (
+	ProviderOpenAI    = "openai"    // OpenAI 及兼容 API
+	ProviderAnthropic = "anthropic" // Anthropic Messages API（Claude）
+)
+
+// NormalizeProvider 返回规范的服务提供方名称，空字符串或无法识别时为 openai
+func NormalizeProvider(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case ProviderAnthropic, "claude":
+		return ProviderAnthropic
+	}
+	return ProviderOpenAI
+}
+
+// 最大输出 token 数的字段名
+const (
+	MaxTokensFieldDefault    = "max_tokens"            // OpenAI 旧版及大多数兼容服务
+	MaxTokensFieldCompletion = "max_completion_tokens" // OpenAI 新模型及部分 vLLM 版本
+	MaxTokensFieldNone       = "none"                  // 不发送，由服务决定
+)
+
+// RequestShape 发送给 OpenAI 兼容 API 的请求体调整。服务拒绝请求（HTTP 400）时，翻译器会根据错误信息自动调整，
+// 这里的设置是调整的起点
+type RequestShape struct {
+	OmitTemperature    bool   `json:"omit_temperature,omitempty"`     // 不发送 temperature
+	MaxTokensField     string `json:"max_tokens_field,omitempty"`     // 最大输出 token 数的字段名，为空时为 max_tokens
+	OmitResponseFormat bool   `json:"omit_response_format,omitempty"` // 不发送 response_format
+}
+
+// TokensField 返回规范的最大输出 token 数字段名，无法识别时为 max_tokens
+func (s RequestShape) TokensField() string {
+	switch strings.ToLower(strings.TrimSpace(s.MaxTokensField)) {
+	case MaxTokensFieldCompletion:
+		return MaxTokensFieldCompletion
+	case MaxTokensFieldNone:
+		return MaxTokensFieldNone
+	}
+	return MaxTokensFieldDefault
+}
+
+// ContextWindowAdvice 上下文窗口设置与所选模型是否匹配的检查结果及推荐值
+type ContextWindowAdvice struct {
+	Model       string `json:"model"`
+	Configured  int    `json:"configured"`          // 当前设置的上下文窗口
+	ModelMax    int    `json:"model_max,omitempty"` // 模型的上下文窗口，未知模型为 0
+	Recommended int    `json:"recommended"`         // 推荐值，0 表示没有推荐
+	Known       bool   `json:"known"`               // 模型是否在已知模型表中
+	Learned     bool   `json:"learned,omitempty"`   // 推荐值来自该模型此前成功的翻译任务
+	Mismatch    bool   `json:"mismatch"`            // 设置值与模型明显不符
+	Message     string `json:"message,omitempty"`   // 不符时的提示
+}
+
+// ModelPrice 模型价格（美元/百万 tokens），用于估算翻译费用
+type ModelPrice struct {
+	Input  float64 `json:"input"`  // 每百万输入 tokens 的价格
+	Output float64 `json:"output"` // 每百万输出 tokens 的价格
+}
+
+// Provenance 翻译产物的来源信息，嵌入到生成的 PDF 并保存为 provenance.json，
+// 用于事后追溯某个 PDF 是由哪个版本的工具、模型和提示词生成的
+type Provenance struct {
+	ToolVersion  string `json:"tool_version"`            // 工具版本
+	GitCommit    string `json:"git_commit,omitempty"`    // 构建时的 git commit (通过 ldflags 注入)
+	Model        string `json:"model"`                   // 翻译使用的模型名称
+	PromptHash   string `json:"prompt_hash"`             // 提示词模板哈希
+	PromptPreset string `json:"prompt_preset,omitempty"` // 提示词预设，自定义模板为 custom
+	GlossaryHash string `json:"glossary_hash,omitempty"` // 术语表哈希
+	SourceSHA256 string `json:"source_sha256,omitempty"` // 源码压缩包 SHA256
+	JobTimestamp string `json:"job_timestamp"`           // 任务开始时间 (RFC3339)
+	// PostProcessing 译文后处理流水线的步骤及版本，按执行顺序以逗号分隔
+	PostProcessing string `json:"post_processing,omitempty"`
 }
 
 // TranslationResult 翻译结果
@@ -167,6 +552,244 @@ type TranslationResult struct {
 	OriginalContent   string `json:"original_content"`
 	TranslatedContent string `json:"translated_content"`
 	TokensUsed        int    `json:"tokens_used"`
+	// SkippedDataBlobs 未发送给模型、原样保留的嵌入数据块
+	SkippedDataBlobs []DataBlob `json:"skipped_data_blobs,omitempty"`
+	// ReuseStats 版本差异翻译时复用旧版本译文的统计
+	ReuseStats *ReuseStats `json:"reuse_stats,omitempty"`
+	// NetworkPauses 翻译期间因网络中断而暂停的次数
+	NetworkPauses int `json:"network_pauses,omitempty"`
+	// NetworkPausedSecs 因网络中断暂停的总时长（秒）
+	NetworkPausedSecs float64 `json:"network_paused_secs,omitempty"`
+	// ParagraphBreakFixes 按原文恢复的段落分隔（空行）数量
+	ParagraphBreakFixes int `json:"paragraph_break_fixes,omitempty"`
+	// SkippedTrailingBytes \end{document} 或 \endinput 之后未翻译、原样保留的字节数
+	SkippedTrailingBytes int `json:"skipped_trailing_bytes,omitempty"`
+	// Continuations 模型输出被截断（finish_reason=length 或结尾不完整）后发送的续写请求次数
+	Continuations int `json:"continuations,omitempty"`
+	// TruncatedChunks 续写次数用完后仍不完整的分块数
+	TruncatedChunks int `json:"truncated_chunks,omitempty"`
+	// Stalls 翻译请求超过停滞窗口无响应、被取消并重建连接重试的次数
+	Stalls int `json:"stalls,omitempty"`
+	// CachedChunks 从分块缓存中取得、没有再次请求模型的分块数（继续中断的翻译时）
+	CachedChunks int `json:"cached_chunks,omitempty"`
+	// SplitChunks 译文被截断（环境未闭合或行数明显少于原文）、拆分为更小的分块重新翻译的分块数
+	SplitChunks int `json:"split_chunks,omitempty"`
+	// SanitizedResponses 模型把译文包在 markdown 代码块中或附加了说明文字、已清理的响应数
+	SanitizedResponses int `json:"sanitized_responses,omitempty"`
+	// RetriedChunks 请求失败（接口错误或译文不合格）后重新发送的分块数
+	RetriedChunks int `json:"retried_chunks,omitempty"`
+	// FailedChunks 重试后仍翻译失败、在译文中保留原文的分块序号（从 1 开始），原文位于
+	// % TRANSLATION-FAILED-CHUNK-START/END 注释之间，可用 RetryFailedChunks 重新翻译
+	FailedChunks []int `json:"failed_chunks,omitempty"`
+	// PromptPreset 生成提示词使用的预设（default、math、cs、biomed），自定义模板文件为 custom
+	PromptPreset string `json:"prompt_preset,omitempty"`
+	// Generator 生成该 LaTeX 源文件的工具（knitr、Sweave、pandoc），手写的源文件为空
+	Generator string `json:"generator,omitempty"`
+	// GlossaryCorrections 模型未按术语表翻译、在译文中被强制替换的术语
+	GlossaryCorrections []GlossaryCorrection `json:"glossary_corrections,omitempty"`
+}
+
+// GlossaryCorrection 被强制修正的术语：模型把术语原样留在译文中，按术语表替换
+type GlossaryCorrection struct {
+	Term   string `json:"term"`   // 英文术语
+	Target string `json:"target"` // 术语表中的译法
+	Count  int    `json:"count"`  // 替换次数
+	Auto   bool   `json:"auto"`   // 术语来自首个分块的自动术语表（宏定义、章节标题）
+}
+
+// TranslationPair 同一文件的原文与译文（用于新版本论文复用旧版本译文）
+type TranslationPair struct {
+	Original   string `json:"original"`
+	Translated string `json:"translated"`
+}
+
+// ReuseStats 版本差异翻译的复用统计
+type ReuseStats struct {
+	TotalParagraphs  int `json:"total_paragraphs"`  // 新版本段落总数
+	ReusedParagraphs int `json:"reused_paragraphs"` // 直接复用旧译文的段落数
+	TotalBytes       int `json:"total_bytes"`       // 新版本内容字节数
+	ReusedBytes      int `json:"reused_bytes"`      // 复用段落的字节数
+}
+
+// Add 累加另一个文件的复用统计
+func (s *ReuseStats) Add(other *ReuseStats) {
+	if other == nil {
+		return
+	}
+	s.TotalParagraphs += other.TotalParagraphs
+	s.ReusedParagraphs += other.ReusedParagraphs
+	s.TotalBytes += other.TotalBytes
+	s.ReusedBytes += other.ReusedBytes
+}
+
+// Percent 返回按字节计算的复用比例（0-100）
+func (s *ReuseStats) Percent() float64 {
+	if s == nil || s.TotalBytes == 0 {
+		return 0
+	}
+	return float64(s.ReusedBytes) * 100 / float64(s.TotalBytes)
+}
+
+// DataBlob 源文件中的大块嵌入数据（filecontents 环境、base64 图片、内联 CSV 等）
+// 这些内容不需要翻译，整体保护后原样写回
+type DataBlob struct {
+	Kind string `json:"kind"`           // filecontents / encoded / numeric
+	Name string `json:"name,omitempty"` // filecontents 的目标文件名
+	Line int    `json:"line"`           // 起始行号（从 1 开始）
+	Size int    `json:"size"`           // 字节数
+}
+
+// ChunkPreview 单个翻译分块的预览信息（与实际翻译使用同一分块逻辑，不调用 LLM）
+type ChunkPreview struct {
+	Index           int      `json:"index"`                  // 分块序号（从 1 开始）
+	Start           int      `json:"start"`                  // 起始字节偏移（相对于保护占位后的内容）
+	End             int      `json:"end"`                    // 结束字节偏移（不含）
+	Head            string   `json:"head"`                   // 开头 80 个字符
+	Tail            string   `json:"tail"`                   // 结尾 80 个字符
+	Environments    []string `json:"environments,omitempty"` // 包含的受保护环境（不会被拆分）
+	EstimatedTokens int      `json:"estimated_tokens"`       // 估算的输入 token 数
+	Section         string   `json:"section,omitempty"`      // 分块开头所在的章节，如 "第4节 Experiments"
+}
+
+// OutlineEntry 从 LaTeX 源码提取的文档大纲条目（章节），在 PDF 生成之前即可用于导航和进度显示
+type OutlineEntry struct {
+	Command  string          `json:"command"`            // 分节命令，如 section、subsection
+	Level    int             `json:"level"`              // 层级：part=0, chapter=1, section=2, subsection=3, subsubsection=4
+	Number   string          `json:"number,omitempty"`   // 编号，如 "3.2"、附录中的 "A"；带星号的命令没有编号
+	Title    string          `json:"title"`              // 标题文本
+	Starred  bool            `json:"starred,omitempty"`  // 是否为带星号的形式（\section*）
+	Appendix bool            `json:"appendix,omitempty"` // 是否位于 \appendix 之后
+	Start    int             `json:"start"`              // 分节命令在源码中的字节偏移
+	Children []*OutlineEntry `json:"children,omitempty"` // 下级章节
+}
+
+// FileChunkPreview 单个 tex 文件的分块预览
+type FileChunkPreview struct {
+	File             string         `json:"file"`                         // 相对于解压目录的路径
+	Size             int            `json:"size"`                         // 原始文件字节数
+	Skipped          bool           `json:"skipped"`                      // 是否整体跳过翻译
+	SkipReason       string         `json:"skip_reason,omitempty"`        // 跳过原因
+	SkippedDataBlobs []DataBlob     `json:"skipped_data_blobs,omitempty"` // 未发送给模型的嵌入数据
+	Chunks           []ChunkPreview `json:"chunks"`
+	Decision         *FileDecision  `json:"decision,omitempty"` // 与翻译时相同的文件处理决定
+}
+
+// 多文件项目中每个文件的处理决定
+const (
+	FileDecisionTranslate = "translate" // 翻译
+	FileDecisionCopy      = "copy"      // 原样复制
+	FileDecisionSkip      = "skip"      // 跳过（不写输出）
+	FileDecisionFailed    = "failed"    // 读取或翻译失败
+)
+
+// FileMetrics 决定文件是否翻译时使用的指标
+type FileMetrics struct {
+	Size         int     `json:"size"`          // 字节数
+	ProseLines   int     `json:"prose_lines"`   // 含 3 个以上英文单词的正文行数
+	CommandLines int     `json:"command_lines"` // 命令定义行数（\newcommand、\def 等）
+	TableLines   int     `json:"table_lines"`   // 表格结构行数
+	CodeRatio    float64 `json:"code_ratio"`    // 非空非注释行中代码行（命令、绘图）的比例
+	CJKRatio     float64 `json:"cjk_ratio"`     // 中日韩字符占字母和中日韩字符总数的比例
+}
+
+// FileDecision 单个文件的处理决定及其依据
+type FileDecision struct {
+	File     string      `json:"file"`               // 相对于项目目录的路径（/ 分隔）
+	Kind     string      `json:"kind"`               // 文件类型: tex、sty、cls 或 bib
+	Decision string      `json:"decision"`           // translate、copy、skip 或 failed
+	Rule     string      `json:"rule"`               // 做出决定的规则，如 no-prose、mostly-code、override
+	Reason   string      `json:"reason"`             // 规则的中文说明
+	Metrics  FileMetrics `json:"metrics"`            // 决定所依据的指标
+	Override string      `json:"override,omitempty"` // 重新运行时改变该决定的参数，如 --translate-files appendix.tex
+	Error    string      `json:"error,omitempty"`    // 失败原因（decision 为 failed 时）
+}
+
+// IncludeOnlyInfo 主 tex 文件中的 \includeonly 指令：原文编译只包含列出的文件，页数会远少于完整文档
+type IncludeOnlyInfo struct {
+	Directive string   `json:"directive"`          // 指令原文，如 \includeonly{chapter3}
+	Included  []string `json:"included"`           // 指令列出的文件
+	Excluded  []string `json:"excluded,omitempty"` // 用 \include 引入但未列出的文件（相对于主文件，含 .tex）
+	Stripped  bool     `json:"stripped"`           // 是否移除了指令，翻译并编译全部文件；否则只翻译列出的文件
+}
+
+// FileDecisionLog 多文件项目的文件处理决定（decisions.json）
+type FileDecisionLog struct {
+	SchemaVersion int            `json:"schema_version"`
+	Project       string         `json:"project"`                 // 项目目录
+	MainTexFile   string         `json:"main_tex_file,omitempty"` // 主 tex 文件（书籍模式为空）
+	Files         []FileDecision `json:"files"`
+	OverrideHelp  string         `json:"override_help"` // 如何在重新运行时覆盖决定
+}
+
+// ChunkingPreview 整篇论文的分块预览结果
+type ChunkingPreview struct {
+	ExtractDir      string             `json:"extract_dir"`
+	MainTexFile     string             `json:"main_tex_file"`
+	Files           []FileChunkPreview `json:"files"`
+	TotalChunks     int                `json:"total_chunks"`
+	EstimatedTokens int                `json:"estimated_tokens"`
+}
+
+// JobSummary 开始翻译前的确认信息：已获取元数据并下载解压源码，尚未调用翻译模型
+type JobSummary struct {
+	JobID            string   `json:"job_id"`                     // 传给 ConfirmJob 的任务 ID；无法翻译时为空
+	Input            string   `json:"input"`                      // 用户输入
+	ArxivID          string   `json:"arxiv_id,omitempty"`         // arXiv ID（不含版本号）
+	Title            string   `json:"title"`                      // 论文标题
+	Authors          []string `json:"authors,omitempty"`          // 作者
+	PrimaryCategory  string   `json:"primary_category,omitempty"` // 主分类，如 cs.CL
+	Categories       []string `json:"categories,omitempty"`       // 全部分类，主分类在前
+	Published        string   `json:"published,omitempty"`        // 首次提交日期 (YYYY-MM-DD)
+	Updated          string   `json:"updated,omitempty"`          // 最新版本提交日期 (YYYY-MM-DD)
+	Versions         int      `json:"versions,omitempty"`         // 版本数
+	License          string   `json:"license,omitempty"`          // 许可证链接；未知时为空
+	Withdrawn        bool     `json:"withdrawn"`                  // 论文已撤回
+	MainTexFile      string   `json:"main_tex_file,omitempty"`    // 主 tex 文件
+	TexFiles         int      `json:"tex_files"`                  // tex 文件数
+	SourceSize       int64    `json:"source_size"`                // 解压后的源码字节数
+	TotalChunks      int      `json:"total_chunks"`               // 翻译分块数
+	EstimatedTokens  int      `json:"estimated_tokens"`           // 估算的 token 消耗（输入 + 输出）
+	EstimatedMinutes int      `json:"estimated_minutes"`          // 估算的处理时间（分钟）
+	RedFlags         []string `json:"red_flags,omitempty"`        // 需要注意的问题，如已撤回、没有源码、项目过大
+}
+
+// TranslationEstimate 翻译一段 LaTeX 内容的预估消耗，只分块不调用翻译模型
+type TranslationEstimate struct {
+	Chunks           int  `json:"chunks"`            // 翻译分块数
+	InputTokens      int  `json:"input_tokens"`      // 估算的输入 token 数（含每个请求重复的提示词）
+	OutputTokens     int  `json:"output_tokens"`     // 估算的输出 token 数
+	EstimatedSeconds int  `json:"estimated_seconds"` // 按配置的并发数估算的翻译耗时（秒）
+	MostlyCode       bool `json:"mostly_code"`       // 内容主要是命令或绘图代码（如 TikZ），不会发送给模型
+}
+
+// FileEstimate 多文件项目中一个文件的预估消耗
+type FileEstimate struct {
+	File     string              `json:"file"`     // 相对源码目录的路径
+	Decision *FileDecision       `json:"decision"` // 翻译、原样复制或跳过的决定
+	Estimate TranslationEstimate `json:"estimate"` // 不翻译的文件为零
+}
+
+// SourceEstimate 整篇论文的预估消耗：已下载解压源码，尚未调用翻译模型
+type SourceEstimate struct {
+	Input       string              `json:"input"`         // 用户输入
+	ExtractDir  string              `json:"extract_dir"`   // 解压后的源码目录
+	MainTexFile string              `json:"main_tex_file"` // 主 tex 文件
+	Concurrency int                 `json:"concurrency"`   // 估算耗时所用的并发数
+	Files       []FileEstimate      `json:"files"`
+	Total       TranslationEstimate `json:"total"` // 所有文件之和；文件依次翻译，耗时相加
+}
+
+// CloseSummary 关闭窗口时将被取消的工作
+type CloseSummary struct {
+	Busy          bool     `json:"busy"`                    // 有需要确认才能退出的工作
+	LaTeXPhase    string   `json:"latex_phase,omitempty"`   // 正在进行的 LaTeX 翻译所处阶段
+	LaTeXProgress int      `json:"latex_progress"`          // LaTeX 翻译进度 (0-100)
+	LaTeXMessage  string   `json:"latex_message,omitempty"` // LaTeX 翻译的当前状态信息
+	PDFPhase      string   `json:"pdf_phase,omitempty"`     // 正在进行的 PDF 翻译所处阶段
+	PDFProgress   int      `json:"pdf_progress"`            // PDF 翻译进度 (0-100)
+	QueuedJobs    int      `json:"queued_jobs"`             // 排队等待的任务数（已下载、等待确认的任务）
+	PendingFixes  int      `json:"pending_fixes"`           // 等待确认的编译修复数
+	Items         []string `json:"items,omitempty"`         // 将被取消的工作，用于退出确认对话框
 }
 
 // ValidationResult 语法验证结果
@@ -189,6 +812,60 @@ type CompileResult struct {
 	PDFPath  string `json:"pdf_path"`
 	Log      string `json:"log"`
 	ErrorMsg string `json:"error_msg,omitempty"`
+	Passes   int    `json:"passes,omitempty"` // 实际执行的 LaTeX 编译遍数（最终 PDF 来自最后一遍）
+	// 文档用 \printindex 打印索引，但索引文件（.ind）没有生成，最终 PDF 中没有索引
+	IndexMissing bool `json:"index_missing,omitempty"`
+	// 开启自动安装宏包时，本次编译中安装的宏包（MiKTeX 即时安装或 tlmgr install）
+	InstalledPackages []string `json:"installed_packages,omitempty"`
+	// 从最后一遍编译日志中解析出的错误和警告
+	Errors []CompileError `json:"errors,omitempty"`
+	// 生成（或最后尝试生成）PDF 的编译引擎，按引擎回退编译时设置
+	Engine string `json:"engine,omitempty"`
+	// 编译前为该引擎调整文档内容的预处理器（如 ctex、fontenc、cjkutf8）
+	Preprocessors []string `json:"preprocessors,omitempty"`
+}
+
+// 编译日志条目的严重程度
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// CompileError 编译日志中的一条错误或警告
+type CompileError struct {
+	File     string `json:"file,omitempty"`    // 出错的文件（日志中的路径，去掉开头的 ./），无法确定时为空
+	Line     int    `json:"line,omitempty"`    // 出错的行号（l.<行号> 或 on input line <行号>），无法确定时为 0
+	Message  string `json:"message"`           // 错误信息，多行信息合并为一行
+	Context  string `json:"context,omitempty"` // TeX 给出的出错位置: l.<行号> 行（已读入的部分）及其下一行（未读入的部分）
+	Severity string `json:"severity"`          // error 或 warning
+}
+
+// String 返回 "文件:行号: 信息" 形式的描述；文件未知时为 "l.行号: 信息"，都未知时只有信息
+func (e CompileError) String() string {
+	location := e.File
+	switch {
+	case e.Line > 0 && location == "":
+		location = "l." + strconv.Itoa(e.Line)
+	case e.Line > 0:
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	if location == "" {
+		return e.Message
+	}
+	return location + ": " + e.Message
+}
+
+// CompileErrorsReport 编译失败时发给界面的错误列表
+type CompileErrorsReport struct {
+	Stage  string         `json:"stage"` // original_compile（原始文档）或 translated_compile（译文）
+	Errors []CompileError `json:"errors"`
+}
+
+// MissingPackagesReport 编译前扫描发现的、本机 TeX 发行版中找不到的宏包
+type MissingPackagesReport struct {
+	Packages       []string `json:"packages"`        // \usepackage / \RequirePackage 中的宏包名
+	Distribution   string   `json:"distribution"`    // TeX 发行版: miktex 或 texlive，未识别时为空
+	InstallCommand string   `json:"install_command"` // 安装全部缺失宏包的命令，未识别发行版时为空
 }
 
 // ErrorCode 错误代码枚举
@@ -206,6 +883,21 @@ const (
 	ErrConfig       ErrorCode = "CONFIG_ERROR"
 	ErrInternal     ErrorCode = "INTERNAL_ERROR"
 	ErrTranslation  ErrorCode = "TRANSLATION_ERROR"
+
+	// ErrNeedsManualFix 自动修复被跳过，需要手动修复译文后重新编译
+	ErrNeedsManualFix ErrorCode = "NEEDS_MANUAL_FIX"
+	// ErrDuplicateJob 同一输入已在另一进程中翻译
+	ErrDuplicateJob ErrorCode = "DUPLICATE_JOB"
+	// ErrCancelled 任务已取消，已完成的部分保存在结果库中，可继续翻译
+	ErrCancelled ErrorCode = "CANCELLED"
+	// ErrBudgetExceeded 翻译用时或 token 数超出预算而中止，已完成的部分保存在结果库中，可继续翻译
+	ErrBudgetExceeded ErrorCode = "BUDGET_EXCEEDED"
+	// ErrSourceAlreadyChinese 源文档的正文已是中文，确认后才翻译，避免把中文“翻译”成中文
+	ErrSourceAlreadyChinese ErrorCode = "SOURCE_ALREADY_CHINESE"
+	// ErrMissingPackages 文档使用的宏包未安装，编译前即报告，安装后重新翻译
+	ErrMissingPackages ErrorCode = "MISSING_PACKAGES"
+	// ErrMissingFonts 未安装可用的中文字体，编译前即报告，避免生成满是方框的 PDF
+	ErrMissingFonts ErrorCode = "MISSING_FONTS"
 )
 
 // AppError 应用错误
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/parser"
 	"latex-translator/internal/types"
 )
 
@@ -58,15 +59,20 @@ func (v *LaTeXValidator) ValidateMainFile(mainTexPath string) (*ValidationResult
 		return nil, types.NewAppError(types.ErrInternal, "failed to read main tex file", err)
 	}
 
-	contentStr := string(content)
+	// Content after \end{document} or \endinput is never read by TeX, so it is not validated
+	contentStr, trailing := parser.SplitAtDocumentEnd(string(content))
 	fileName := filepath.Base(mainTexPath)
 
+	// Code listings are text to TeX: their braces and commands are not checked
+	contentStr = parser.BlankVerbatim(contentStr)
+
 	// Run validation checks
 	v.checkBraceBalance(fileName, contentStr, result)
 	v.checkDocumentStructure(fileName, contentStr, result)
 	v.checkCommandDefinitions(fileName, contentStr, result)
 	v.checkEnvironments(fileName, contentStr, result)
 	v.checkCommonErrors(fileName, contentStr, result)
+	v.checkTrailingContent(fileName, trailing, result)
 
 	// Check included files
 	v.checkIncludedFiles(mainTexPath, result)
@@ -195,32 +201,23 @@ func (v *LaTeXValidator) checkDocumentStructure(fileName, content string, result
 			Details:  "LaTeX document must end with \\end{document}",
 		})
 	}
+}
 
-	// Check for content after \end{document}
-	if hasEndDocument {
-		endDocIdx := strings.LastIndex(content, "\\end{document}")
-		afterEnd := strings.TrimSpace(content[endDocIdx+len("\\end{document}"):])
-		if len(afterEnd) > 0 {
-			// Check if it's just comments or whitespace
-			lines := strings.Split(afterEnd, "\n")
-			hasNonComment := false
-			for _, line := range lines {
-				trimmed := strings.TrimSpace(line)
-				if trimmed != "" && !strings.HasPrefix(trimmed, "%") {
-					hasNonComment = true
-					break
-				}
-			}
-			if hasNonComment {
-				result.Valid = false
-				result.Issues = append(result.Issues, ValidationIssue{
-					Severity: "error",
-					File:     fileName,
-					Line:     0,
-					Message:  "Content after \\end{document}",
-					Details:  fmt.Sprintf("Found %d characters after \\end{document}: %s", len(afterEnd), truncate(afterEnd, 50)),
-				})
-			}
+// checkTrailingContent reports text after \end{document} or \endinput. TeX ignores it,
+// so it is only a warning.
+func (v *LaTeXValidator) checkTrailingContent(fileName, trailing string, result *ValidationResult) {
+	for _, line := range strings.Split(trailing, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "%") {
+			afterEnd := strings.TrimSpace(trailing)
+			result.Issues = append(result.Issues, ValidationIssue{
+				Severity: "warning",
+				File:     fileName,
+				Line:     0,
+				Message:  "Content after end of document is ignored",
+				Details:  fmt.Sprintf("Found %d characters after \\end{document} or \\endinput: %s", len(afterEnd), truncate(afterEnd, 50)),
+			})
+			return
 		}
 	}
 }
@@ -420,7 +417,7 @@ func (v *LaTeXValidator) checkIncludedFiles(mainTexPath string, result *Validati
 		return
 	}
 
-	contentStr := string(content)
+	contentStr := parser.BlankVerbatim(string(content))
 	baseDir := filepath.Dir(mainTexPath)
 
 	// Find \input{...} and \include{...}
@@ -1,20 +1,18 @@
 // Package validator provides LaTeX syntax validation and fixing functionality.
-// It detects syntax errors in LaTeX documents and uses OpenAI API to fix them.
+// It detects syntax errors in LaTeX documents and uses the LLM of the settings to fix them.
 //
 // Validates: Requirements 3.3, 3.4, 3.5
 package validator
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"latex-translator/internal/logger"
+	"latex-translator/internal/translator"
 	"latex-translator/internal/types"
 )
 
@@ -36,22 +34,18 @@ const (
 //
 // Validates: Requirements 3.3, 3.4
 type SyntaxValidator struct {
-	apiKey string
-	client *http.Client
-	model  string
-	apiURL string
+	apiKey   string
+	model    string
+	apiURL   string
+	provider string
+	timeout  time.Duration
+	llm      translator.LLMClient   // sends the fix requests; rebuilt when the settings change
+	usage    *translator.UsageMeter // tokens of the fix requests are added to it (nil: not metered)
 }
 
 // NewSyntaxValidator creates a new SyntaxValidator with the specified API key.
 func NewSyntaxValidator(apiKey string) *SyntaxValidator {
-	return &SyntaxValidator{
-		apiKey: apiKey,
-		client: &http.Client{
-			Timeout: DefaultTimeout,
-		},
-		model:  DefaultModel,
-		apiURL: OpenAIAPIURL,
-	}
+	return NewSyntaxValidatorWithConfig(apiKey, DefaultModel, "", DefaultTimeout)
 }
 
 // NewSyntaxValidatorWithConfig creates a new SyntaxValidator with full configuration.
@@ -68,14 +62,20 @@ func NewSyntaxValidatorWithConfig(apiKey, model, apiURL string, timeout time.Dur
 	if timeout == 0 {
 		timeout = DefaultTimeout
 	}
-	return &SyntaxValidator{
-		apiKey: apiKey,
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		model:  model,
-		apiURL: apiURL,
+	v := &SyntaxValidator{
+		apiKey:   apiKey,
+		model:    model,
+		apiURL:   apiURL,
+		provider: types.ProviderOpenAI,
+		timeout:  timeout,
 	}
+	v.rebuildClient()
+	return v
+}
+
+// rebuildClient creates the LLM client for the current settings
+func (v *SyntaxValidator) rebuildClient() {
+	v.llm = translator.NewLLMClient(v.provider, v.apiKey, v.model, v.apiURL, v.timeout)
 }
 
 // normalizeAPIURL ensures the API URL ends with /chat/completions
@@ -105,11 +105,25 @@ func (v *SyntaxValidator) GetModel() string {
 // SetModel sets the model to use for fixing.
 func (v *SyntaxValidator) SetModel(model string) {
 	v.model = model
+	v.rebuildClient()
 }
 
 // SetAPIURL sets the API URL (useful for testing with mock servers).
 func (v *SyntaxValidator) SetAPIURL(url string) {
 	v.apiURL = url
+	v.rebuildClient()
+}
+
+// SetUsageMeter sets the meter the tokens of the fix requests are added to; nil stops the
+// metering
+func (v *SyntaxValidator) SetUsageMeter(meter *translator.UsageMeter) {
+	v.usage = meter
+}
+
+// SetProvider sets the LLM provider of the fix requests (types.ProviderOpenAI by default).
+func (v *SyntaxValidator) SetProvider(provider string) {
+	v.provider = types.NormalizeProvider(provider)
+	v.rebuildClient()
 }
 
 // Validate checks LaTeX content for syntax errors.
@@ -177,7 +191,7 @@ func (v *SyntaxValidator) Fix(content string, errors []types.SyntaxError) (strin
 
 	if v.apiKey == "" {
 		logger.Error("API key not configured", nil)
-		return "", types.NewAppError(types.ErrConfig, "OpenAI API key is not configured", nil)
+		return "", types.NewAppError(types.ErrConfig, "API key is not configured", nil)
 	}
 
 	if content == "" {
@@ -238,129 +252,19 @@ func (v *SyntaxValidator) fixWithRetry(content string, errors []types.SyntaxErro
 	)
 }
 
-// ChatCompletionRequest represents the request body for OpenAI chat completions API.
-type ChatCompletionRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-}
-
-// Message represents a message in the chat completion request.
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-// ChatCompletionResponse represents the response from OpenAI chat completions API.
-type ChatCompletionResponse struct {
-	ID      string    `json:"id"`
-	Object  string    `json:"object"`
-	Created int64     `json:"created"`
-	Model   string    `json:"model"`
-	Choices []Choice  `json:"choices"`
-	Usage   Usage     `json:"usage"`
-	Error   *APIError `json:"error,omitempty"`
-}
-
-// Choice represents a choice in the chat completion response.
-type Choice struct {
-	Index        int     `json:"index"`
-	Message      Message `json:"message"`
-	FinishReason string  `json:"finish_reason"`
-}
-
-// Usage represents token usage information.
-type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
-}
-
-// APIError represents an error response from the OpenAI API.
-type APIError struct {
-	Message string `json:"message"`
-	Type    string `json:"type"`
-	Code    string `json:"code"`
-}
-
 // doFix performs the actual API call to fix syntax errors.
 func (v *SyntaxValidator) doFix(content string, errors []types.SyntaxError) (string, error) {
-	logger.Debug("calling OpenAI API for syntax fix", logger.String("model", v.model))
-
-	// Build the fix prompt
-	systemPrompt := buildFixSystemPrompt()
-	userPrompt := buildFixUserPrompt(content, errors)
-
-	// Create the request body
-	reqBody := ChatCompletionRequest{
-		Model: v.model,
-		Messages: []Message{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
-	}
+	logger.Debug("calling LLM for syntax fix", logger.String("provider", v.provider), logger.String("model", v.model))
 
-	jsonBody, err := json.Marshal(reqBody)
+	fixedContent, usage, err := v.llm.Complete(context.Background(), translator.Prompt{
+		System:   buildFixSystemPrompt(),
+		Messages: []translator.Message{{Role: "user", Content: buildFixUserPrompt(content, errors)}},
+	})
 	if err != nil {
-		logger.Error("failed to marshal request body", err)
-		return "", types.NewAppError(types.ErrInternal, "failed to marshal request body", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequest(http.MethodPost, v.apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		logger.Error("failed to create HTTP request", err)
-		return "", types.NewAppError(types.ErrInternal, "failed to create HTTP request", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+v.apiKey)
-
-	// Send the request
-	resp, err := v.client.Do(req)
-	if err != nil {
-		logger.Error("API request failed", err)
-		return "", types.NewAppError(types.ErrNetwork, "API request failed", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("failed to read API response", err)
-		return "", types.NewAppError(types.ErrNetwork, "failed to read API response", err)
-	}
-
-	// Handle HTTP errors
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("API returned error status", nil, logger.Int("statusCode", resp.StatusCode))
-		return "", handleAPIHTTPError(resp.StatusCode, body)
-	}
-
-	// Parse response
-	var chatResp ChatCompletionResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		logger.Error("failed to parse API response", err)
-		return "", types.NewAppError(types.ErrAPICall, "failed to parse API response", err)
-	}
-
-	// Check for API error in response
-	if chatResp.Error != nil {
-		logger.Error("API returned error in response", nil, logger.String("errorMessage", chatResp.Error.Message))
-		return "", types.NewAppErrorWithDetails(
-			types.ErrAPICall,
-			"API returned error",
-			chatResp.Error.Message,
-			nil,
-		)
-	}
-
-	// Extract fixed content
-	if len(chatResp.Choices) == 0 {
-		logger.Error("API returned no choices", nil)
-		return "", types.NewAppError(types.ErrAPICall, "API returned no choices", nil)
+		return "", err
 	}
+	v.usage.Add(v.model, usage)
 
-	fixedContent := chatResp.Choices[0].Message.Content
 	logger.Debug("API call successful")
 	return fixedContent, nil
 }
@@ -399,61 +303,6 @@ DOCUMENT:
 %s`, strings.Join(errorDescriptions, "\n"), content)
 }
 
-// handleAPIHTTPError creates an appropriate AppError based on the HTTP status code.
-func handleAPIHTTPError(statusCode int, body []byte) error {
-	// Try to parse error message from response body
-	var errResp struct {
-		Error struct {
-			Message string `json:"message"`
-			Type    string `json:"type"`
-			Code    string `json:"code"`
-		} `json:"error"`
-	}
-
-	errorDetails := ""
-	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-		errorDetails = errResp.Error.Message
-	}
-
-	switch statusCode {
-	case http.StatusUnauthorized:
-		return types.NewAppErrorWithDetails(
-			types.ErrAPICall,
-			"API authentication failed",
-			"invalid API key or unauthorized access",
-			nil,
-		)
-	case http.StatusTooManyRequests:
-		return types.NewAppErrorWithDetails(
-			types.ErrAPIRateLimit,
-			"API rate limit exceeded",
-			errorDetails,
-			nil,
-		)
-	case http.StatusBadRequest:
-		return types.NewAppErrorWithDetails(
-			types.ErrAPICall,
-			"invalid API request",
-			errorDetails,
-			nil,
-		)
-	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
-		return types.NewAppErrorWithDetails(
-			types.ErrAPICall,
-			"API server error",
-			fmt.Sprintf("status %d: %s", statusCode, errorDetails),
-			nil,
-		)
-	default:
-		return types.NewAppErrorWithDetails(
-			types.ErrAPICall,
-			"API request failed",
-			fmt.Sprintf("status %d: %s", statusCode, errorDetails),
-			nil,
-		)
-	}
-}
-
 // isRetryableAPIError determines if an error should trigger a retry.
 func isRetryableAPIError(err error) bool {
 	if err == nil {
@@ -0,0 +1,254 @@
+// Package workdir checks that a work directory can take the file churn of a translation
+// job. Sync clients (OneDrive, Dropbox, Google Drive, ...) lock files while uploading
+// them, which makes writes fail at random with "access denied" or leaves partial files,
+// and every LaTeX pass rewrites aux files that are then uploaded again. Jobs whose work
+// directory is in a sync folder or not writable run in a local scratch directory and only
+// publish their final artifacts to the configured directory. Each paper has its own
+// directory under the work directory, which a cleanup policy keeps from growing forever.
+package workdir
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+const (
+	// probeAttempts is how often the write probe is tried; sync clients release their
+	// locks after a moment
+	probeAttempts = 3
+	// probeRetryDelay is the delay between write probe attempts
+	probeRetryDelay = 500 * time.Millisecond
+)
+
+// syncFolderNames maps lowercase directory names that are roots of sync folders to the
+// name of the sync client. "OneDrive - Company" folders are matched by prefix.
+var syncFolderNames = map[string]string{
+	"onedrive":            "OneDrive",
+	"dropbox":             "Dropbox",
+	"google drive":        "Google Drive",
+	"googledrive":         "Google Drive",
+	"my drive":            "Google Drive",
+	"icloud drive":        "iCloud Drive",
+	"iclouddrive":         "iCloud Drive",
+	"com~apple~clouddocs": "iCloud Drive",
+	"box":                 "Box",
+	"box sync":            "Box",
+	"pcloud drive":        "pCloud",
+	"nextcloud":           "Nextcloud",
+	"owncloud":            "ownCloud",
+	"synologydrive":       "Synology Drive",
+}
+
+// syncFolderEnv are environment variables set by sync clients to their root folder
+var syncFolderEnv = map[string]string{
+	"OneDrive":           "OneDrive",
+	"OneDriveConsumer":   "OneDrive",
+	"OneDriveCommercial": "OneDrive",
+}
+
+// DetectSyncFolder returns the name of the sync client whose folder contains path, or ""
+func DetectSyncFolder(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	for env, provider := range syncFolderEnv {
+		if root := os.Getenv(env); root != "" && isWithin(abs, root) {
+			return provider
+		}
+	}
+
+	for _, part := range strings.FieldsFunc(filepath.ToSlash(abs), func(r rune) bool { return r == '/' }) {
+		name := strings.ToLower(part)
+		if provider, ok := syncFolderNames[name]; ok {
+			return provider
+		}
+		if strings.HasPrefix(name, "onedrive - ") || strings.HasPrefix(name, "dropbox (") {
+			return syncFolderNames[strings.Fields(name)[0]]
+		}
+	}
+	return ""
+}
+
+// isWithin reports whether path is root or inside it
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// Probe checks that files can be created, renamed and deleted in dir, the operations a
+// job performs. Each step is retried a few times, as sync clients hold short locks.
+func Probe(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return types.NewAppError(types.ErrInternal, fmt.Sprintf("无法创建工作目录 %s", dir), err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= probeAttempts; attempt++ {
+		if lastErr = probeOnce(dir); lastErr == nil {
+			return nil
+		}
+		logger.Warn("work directory write probe failed",
+			logger.String("dir", dir),
+			logger.Int("attempt", attempt),
+			logger.Err(lastErr))
+		if attempt < probeAttempts {
+			time.Sleep(probeRetryDelay)
+		}
+	}
+	return types.NewAppError(types.ErrInternal, fmt.Sprintf("工作目录 %s 不可写", dir), lastErr)
+}
+
+// probeOnce creates, renames and deletes a temporary file in dir
+func probeOnce(dir string) error {
+	f, err := os.CreateTemp(dir, ".latex-translator-probe-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, writeErr := f.WriteString("probe")
+	closeErr := f.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(name)
+		if writeErr != nil {
+			return writeErr
+		}
+		return closeErr
+	}
+
+	renamed := name + ".renamed"
+	if err := os.Rename(name, renamed); err != nil {
+		os.Remove(name)
+		return err
+	}
+	return os.Remove(renamed)
+}
+
+// Check examines a work directory setting: whether it is in a sync folder and whether it
+// is writable. An empty dir (temporary work directory) is always fine.
+func Check(dir string) *types.WorkDirCheck {
+	check := &types.WorkDirCheck{Path: dir, Writable: true}
+	if dir == "" {
+		return check
+	}
+
+	check.SyncProvider = DetectSyncFolder(dir)
+	if err := Probe(dir); err != nil {
+		check.Writable = false
+		check.ProbeError = err.Error()
+	}
+
+	switch {
+	case !check.Writable:
+		check.UseScratch = true
+		check.Message = fmt.Sprintf("工作目录 %s 不可写（只读卷或权限不足）：任务将在本地临时目录中运行，最终文件可能无法复制回该目录", dir)
+	case check.SyncProvider != "":
+		check.UseScratch = true
+		check.Message = fmt.Sprintf("工作目录位于 %s 同步文件夹中：同步客户端会在上传时锁定文件，导致“拒绝访问”或文件不完整，编译产生的大量临时文件也会反复同步。"+
+			"任务将在本地临时目录中运行，只把最终的 PDF 和 tex 文件复制到该目录；建议改用不同步的目录", check.SyncProvider)
+	}
+	return check
+}
+
+// ScratchDir returns the local scratch directory used instead of workDir, creating it
+func ScratchDir(workDir string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	dir := filepath.Join(base, "latex-translator", "scratch", filepath.Base(filepath.Clean(workDir)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// IsArtifact reports whether a file is a final artifact published from the scratch
+// directory: PDFs, tex sources and HTML exports. Aux files, logs and archives stay local.
+func IsArtifact(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf", ".tex", ".html":
+		return true
+	}
+	return false
+}
+
+// Publish copies the artifacts under srcDir to dstDir, keeping their relative paths.
+// Directories holding backups of originals are skipped. It returns the number of files
+// copied.
+func Publish(srcDir, dstDir string) (int, error) {
+	copied := 0
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") && path != srcDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsArtifact(info.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if err := CopyFile(path, filepath.Join(dstDir, rel)); err != nil {
+			return err
+		}
+		copied++
+		return nil
+	})
+	return copied, err
+}
+
+// Relocate returns the path Publish gives to path when publishing srcDir to dstDir, and
+// false if path is not inside srcDir
+func Relocate(path, srcDir, dstDir string) (string, bool) {
+	if path == "" || !isWithin(path, srcDir) {
+		return path, false
+	}
+	rel, err := filepath.Rel(srcDir, path)
+	if err != nil {
+		return path, false
+	}
+	return filepath.Join(dstDir, rel), true
+}
+
+// CopyFile copies src to dst, creating the parent directory
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
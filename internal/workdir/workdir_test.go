@@ -0,0 +1,118 @@
+package workdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectSyncFolder(t *testing.T) {
+	for _, env := range []string{"OneDrive", "OneDriveConsumer", "OneDriveCommercial"} {
+		t.Setenv(env, "")
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/user/OneDrive/papers", "OneDrive"},
+		{"/home/user/OneDrive - Contoso/papers", "OneDrive"},
+		{"/home/user/Dropbox (Personal)/work", "Dropbox"},
+		{"/home/user/dropbox", "Dropbox"},
+		{"/Volumes/GoogleDrive/My Drive/papers", "Google Drive"},
+		{"/Users/me/Library/Mobile Documents/com~apple~CloudDocs/work", "iCloud Drive"},
+		{"/home/user/papers", ""},
+		{"/home/user/onedrive-backup", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectSyncFolder(filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("DetectSyncFolder(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDetectSyncFolderFromEnvironment(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("OneDriveCommercial", root)
+	if got := DetectSyncFolder(filepath.Join(root, "work")); got != "OneDrive" {
+		t.Errorf("DetectSyncFolder inside $OneDriveCommercial = %q, want OneDrive", got)
+	}
+	if got := DetectSyncFolder(root + "-other"); got != "" {
+		t.Errorf("DetectSyncFolder of sibling directory = %q, want empty", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, env := range []string{"OneDrive", "OneDriveConsumer", "OneDriveCommercial"} {
+		t.Setenv(env, "")
+	}
+	plain := Check(t.TempDir())
+	if !plain.Writable || plain.UseScratch || plain.Message != "" {
+		t.Errorf("plain directory: %+v", plain)
+	}
+
+	synced := Check(filepath.Join(t.TempDir(), "OneDrive", "papers"))
+	if !synced.Writable || !synced.UseScratch || synced.SyncProvider != "OneDrive" || synced.Message == "" {
+		t.Errorf("OneDrive directory: %+v", synced)
+	}
+
+	entries, err := os.ReadDir(synced.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("write probe left files behind: %v", entries)
+	}
+}
+
+func TestPublishCopiesOnlyArtifacts(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "published")
+	files := map[string]bool{
+		"main.tex":             true,
+		"translated_main.pdf":  true,
+		"sections/intro.tex":   true,
+		"translated_main.aux":  false,
+		"translated_main.log":  false,
+		"output_main/main.pdf": true,
+		".original/main.tex":   false,
+		"figures/plot.png":     false,
+		"translated_main.html": true,
+	}
+	for name := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copied, err := Publish(src, dst)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	want := 0
+	for name, published := range files {
+		_, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if published {
+			want++
+			if err != nil {
+				t.Errorf("%s was not published", name)
+			}
+		} else if err == nil {
+			t.Errorf("%s should stay in scratch", name)
+		}
+	}
+	if copied != want {
+		t.Errorf("copied = %d, want %d", copied, want)
+	}
+
+	if got, ok := Relocate(filepath.Join(src, "sections", "intro.tex"), src, dst); !ok || got != filepath.Join(dst, "sections", "intro.tex") {
+		t.Errorf("Relocate = %q, %v", got, ok)
+	}
+	if _, ok := Relocate(filepath.Join(t.TempDir(), "x.pdf"), src, dst); ok {
+		t.Error("Relocate accepted a path outside the source directory")
+	}
+}
@@ -0,0 +1,169 @@
+package workdir
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"latex-translator/internal/logger"
+	"latex-translator/internal/types"
+)
+
+// PapersDirName is the directory of the work directory holding one subdirectory per paper.
+// Cleanup only ever removes directories inside it.
+const PapersDirName = "papers"
+
+// DefaultPolicy is the cleanup policy used when none is configured
+var DefaultPolicy = types.WorkspacePolicy{KeepRecent: 20, MaxSizeGB: 10}
+
+// PaperDir returns the directory of the paper whose directory name is name
+func PaperDir(root, name string) string {
+	return filepath.Join(root, PapersDirName, name)
+}
+
+// workspace is a paper directory with its last use as a time for sorting
+type workspace struct {
+	types.PaperWorkspace
+	lastUsed time.Time
+}
+
+// Usage reports the size of every paper directory under root, most recently used first,
+// and the size of the rest of root. protected marks the directories cleanup must keep.
+func Usage(root string, protected func(name string) bool) (*types.WorkspaceUsage, error) {
+	papers, err := scanPapers(root, protected)
+	if err != nil {
+		return nil, err
+	}
+	usage := &types.WorkspaceUsage{Root: root, Papers: []types.PaperWorkspace{}}
+	for _, paper := range papers {
+		usage.Papers = append(usage.Papers, paper.PaperWorkspace)
+		usage.PapersSize += paper.SizeBytes
+	}
+
+	papersDir := filepath.Join(root, PapersDirName)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path == papersDir {
+			return filepath.SkipDir
+		}
+		if info, err := d.Info(); err == nil && d.Type().IsRegular() {
+			usage.OtherSize += info.Size()
+		}
+		return nil
+	})
+	usage.TotalSize = usage.PapersSize + usage.OtherSize
+	return usage, nil
+}
+
+// Clean removes the least recently used paper directories under root until the rest meets
+// policy. Protected directories are never removed but count towards the limits. With dryRun
+// the directories are only listed.
+func Clean(root string, policy types.WorkspacePolicy, protected func(name string) bool, dryRun bool, now time.Time) (*types.WorkspaceCleanResult, error) {
+	papers, err := scanPapers(root, protected)
+	if err != nil {
+		return nil, err
+	}
+	result := &types.WorkspaceCleanResult{DryRun: dryRun, Removed: []types.PaperWorkspace{}}
+
+	kept := 0
+	var keptBytes int64
+	for _, paper := range papers {
+		if paper.Protected {
+			kept++
+			keptBytes += paper.SizeBytes
+		}
+	}
+	maxBytes := int64(policy.MaxSizeGB * (1 << 30))
+	maxAge := time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+
+	for _, paper := range papers {
+		if paper.Protected {
+			continue
+		}
+		remove := (policy.KeepRecent > 0 && kept >= policy.KeepRecent) ||
+			(maxAge > 0 && now.Sub(paper.lastUsed) > maxAge) ||
+			(maxBytes > 0 && keptBytes+paper.SizeBytes > maxBytes)
+		if !remove {
+			kept++
+			keptBytes += paper.SizeBytes
+			continue
+		}
+
+		if !dryRun {
+			if err := os.RemoveAll(paper.Path); err != nil {
+				logger.Warn("failed to remove paper work directory", logger.String("path", paper.Path), logger.Err(err))
+				result.Errors = append(result.Errors, paper.Name+": "+err.Error())
+				kept++
+				keptBytes += paper.SizeBytes
+				continue
+			}
+		}
+		result.Removed = append(result.Removed, paper.PaperWorkspace)
+		result.FreedBytes += paper.SizeBytes
+	}
+	result.Kept = kept
+	return result, nil
+}
+
+// scanPapers lists the paper directories under root, most recently used first. A missing
+// papers directory has no papers.
+func scanPapers(root string, protected func(name string) bool) ([]workspace, error) {
+	entries, err := os.ReadDir(filepath.Join(root, PapersDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var papers []workspace
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := PaperDir(root, entry.Name())
+		size, lastUsed := dirStats(path)
+		papers = append(papers, workspace{
+			PaperWorkspace: types.PaperWorkspace{
+				Name:      entry.Name(),
+				Path:      path,
+				SizeBytes: size,
+				LastUsed:  lastUsed.Format(time.RFC3339),
+				Protected: protected != nil && protected(entry.Name()),
+			},
+			lastUsed: lastUsed,
+		})
+	}
+	sort.SliceStable(papers, func(i, j int) bool {
+		return papers[i].lastUsed.After(papers[j].lastUsed)
+	})
+	return papers, nil
+}
+
+// dirStats returns the total size of the files under dir and the newest modification time
+// of dir and its contents
+func dirStats(dir string) (int64, time.Time) {
+	var size int64
+	var newest time.Time
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		if d.Type().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, newest
+}
@@ -0,0 +1,116 @@
+package workdir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"latex-translator/internal/types"
+)
+
+// makePaper creates a paper directory holding size bytes, last used age ago
+func makePaper(t *testing.T, root, name string, size int, age time.Duration) {
+	t.Helper()
+	dir := PaperDir(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, name+"_extracted"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, name+"_extracted", "main.aux")
+	if err := os.WriteFile(file, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(-age)
+	for _, path := range []string{file, filepath.Dir(file), dir} {
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func names(papers []types.PaperWorkspace) string {
+	var out []string
+	for _, paper := range papers {
+		out = append(out, paper.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestUsage(t *testing.T) {
+	root := t.TempDir()
+	makePaper(t, root, "2301.00001", 100, 48*time.Hour)
+	makePaper(t, root, "2301.00002", 50, time.Hour)
+	if err := os.WriteFile(filepath.Join(root, "legacy.tar.gz"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := Usage(root, func(name string) bool { return name == "2301.00001" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(usage.Papers) != "2301.00002,2301.00001" {
+		t.Errorf("papers = %s, want most recent first", names(usage.Papers))
+	}
+	if usage.PapersSize != 150 || usage.OtherSize != 10 || usage.TotalSize != 160 {
+		t.Errorf("sizes = %d + %d = %d", usage.PapersSize, usage.OtherSize, usage.TotalSize)
+	}
+	if !usage.Papers[1].Protected || usage.Papers[0].Protected {
+		t.Errorf("protection = %+v", usage.Papers)
+	}
+
+	empty, err := Usage(t.TempDir(), nil)
+	if err != nil || len(empty.Papers) != 0 {
+		t.Errorf("Usage() of an empty work directory = %+v, %v", empty, err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	root := t.TempDir()
+	makePaper(t, root, "new", 10, time.Hour)
+	makePaper(t, root, "middle", 10, 24*time.Hour)
+	makePaper(t, root, "resumable", 10, 72*time.Hour)
+	makePaper(t, root, "old", 10, 96*time.Hour)
+	protected := func(name string) bool { return name == "resumable" }
+	policy := types.WorkspacePolicy{KeepRecent: 2}
+
+	result, err := Clean(root, policy, protected, true, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(result.Removed) != "middle,old" || result.FreedBytes != 20 || result.Kept != 2 {
+		t.Errorf("dry run = %+v", result)
+	}
+	if _, err := os.Stat(PaperDir(root, "old")); err != nil {
+		t.Error("dry run removed a directory")
+	}
+
+	if result, err = Clean(root, policy, protected, false, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"new": true, "middle": false, "resumable": true, "old": false} {
+		if _, err := os.Stat(PaperDir(root, name)); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestCleanByAgeAndSize(t *testing.T) {
+	root := t.TempDir()
+	makePaper(t, root, "new", 600, time.Hour)
+	makePaper(t, root, "week", 600, 7*24*time.Hour)
+	makePaper(t, root, "month", 100, 30*24*time.Hour)
+
+	result, err := Clean(root, types.WorkspacePolicy{MaxAgeDays: 14}, nil, true, time.Now())
+	if err != nil || names(result.Removed) != "month" {
+		t.Errorf("by age = %+v, %v", result, err)
+	}
+	// The cap keeps the newest paper and the small old one that still fits
+	result, err = Clean(root, types.WorkspacePolicy{MaxSizeGB: 1000.0 / (1 << 30)}, nil, true, time.Now())
+	if err != nil || names(result.Removed) != "week" {
+		t.Errorf("by size = %+v, %v", result, err)
+	}
+	if result, _ := Clean(root, types.WorkspacePolicy{}, nil, true, time.Now()); len(result.Removed) != 0 {
+		t.Errorf("no limits removed %s", names(result.Removed))
+	}
+}
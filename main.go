@@ -1,20 +1,42 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"embed"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"latex-translator/internal/bookmanifest"
+	"latex-translator/internal/compiler"
 	"latex-translator/internal/config"
+	"latex-translator/internal/decisions"
+	"latex-translator/internal/downloader"
+	"latex-translator/internal/jobserver"
 	"latex-translator/internal/logger"
+	"latex-translator/internal/pdf"
+	"latex-translator/internal/pdfserve"
+	"latex-translator/internal/postprocess"
+	"latex-translator/internal/qa"
+	"latex-translator/internal/results"
+	"latex-translator/internal/statusfile"
 	"latex-translator/internal/translator"
+	"latex-translator/internal/types"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -25,18 +47,65 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
+// Build information, overridden at build time via
+// -ldflags "-X main.AppVersion=1.0.0 -X main.GitCommit=abc1234"
+var (
+	AppVersion = "1.0.0"
+	GitCommit  = ""
+)
+
 // Command line flags
 var (
-	urlFlag    = flag.String("url", "", "arXiv URL to download and process (e.g., https://arxiv.org/abs/2301.00001)")
-	idFlag     = flag.String("id", "", "arXiv ID to download and process (e.g., 2301.00001)")
-	fileFlag   = flag.String("file", "", "Local zip file path to process")
-	pdfFlag    = flag.String("pdf", "", "PDF file path to translate directly")
-	bookFlag   = flag.String("book", "", "Book directory or zip file to translate (LaTeX book project)")
-	maxFiles   = flag.Int("max-files", 0, "Maximum number of files to translate (0 = all, for book mode)")
-	outputDir  = flag.String("output", "", "Output directory for translated files (for book mode)")
-	cliFlag    = flag.Bool("cli", false, "Run in CLI mode without GUI")
+	urlFlag       = flag.String("url", "", "arXiv URL to download and process (e.g., https://arxiv.org/abs/2301.00001)")
+	idFlag        = flag.String("id", "", "arXiv ID to download and process (e.g., 2301.00001)")
+	fileFlag      = flag.String("file", "", "Local zip file path to process")
+	pdfFlag       = flag.String("pdf", "", "PDF file path to translate directly")
+	bookFlag      = flag.String("book", "", "Book directory or zip file to translate (LaTeX book project)")
+	maxFiles      = flag.Int("max-files", 0, "Maximum number of files to translate (0 = all, for book mode)")
+	jobsFlag      = flag.Int("jobs", 0, "Number of book files translated at the same time (0 = concurrency from settings)")
+	outputDir     = flag.String("output", "", "Output directory for translated files (for book mode)")
+	cliFlag       = flag.Bool("cli", false, "Run in CLI mode without GUI")
+	previewChunks = flag.Bool("preview-chunks", false, "Print how the document will be split into translation chunks, without translating")
+	estimateFlag  = flag.Bool("estimate", false, "Print the estimated chunks, tokens and translation time of the document, without translating")
+	allowDup      = flag.Bool("allow-duplicate", false, "Translate even if another process is already translating the same input")
+	forceFlag     = flag.Bool("force-translate", false, "Translate to Chinese even if the source already appears to be Chinese")
+	statusFile    = flag.String("status-file", "", "Path of the status JSON file updated during CLI runs (default: status.json in the work/output directory)")
+	maxCompiles   = flag.Int("max-compiles", 0, "Maximum number of LaTeX processes running at the same time (0 = from settings, default 2)")
+	variantFlag   = flag.String("variant", "", "Script of the Chinese translation: zh-Hans (simplified) or zh-Hant (traditional); default from settings")
+	langFlag      = flag.String("lang", "", "Language of the translation: zh, ja, ko, ru, en, fr, de or es; default from settings")
+	glossaryFlag  = flag.String("glossary", "", "Glossary file (JSON or CSV) mapping English terms to their fixed translation; default from settings")
+	promptPreset  = flag.String("prompt-preset", "", "Prompt preset: default, math, cs or biomed (domain guidance added to the prompt); default from settings")
+	mainTexFlag   = flag.String("main-tex", "", "Main tex file of the source, relative to its root, for projects holding several documents (default: the top ranked candidate)")
+	bilingualFlag = flag.String("bilingual-layout", "side-by-side", "Layout of the bilingual PDF: side-by-side (landscape) or interleaved (original and translated pages alternating, for printing)")
+	quickFlag     = flag.Bool("quick", false, "Quick translation mode: faster but lower quality (larger chunks, one compile pass, rule-based fixes only, no bilingual PDF)")
+	autoContext   = flag.Bool("auto-context", false, "Set the context window to the value recommended for the configured model and save it")
+	noCompileFlag = flag.Bool("no-compile", false, "Translate without compiling (no TeX distribution needed): save the translated tex files and an HTML export")
+	translatePDF  = flag.Bool("translate-arxiv-pdf", false, "With --no-compile, also translate the PDF compiled by arXiv with the PDF translator")
+	translateList = flag.String("translate-files", "", "Comma-separated files of a multi-file project to translate regardless of the rules in decisions.json")
+	copyList      = flag.String("copy-files", "", "Comma-separated files of a multi-file project to copy verbatim instead of translating")
+	confirmFlag   = flag.Bool("confirm", false, "Download the source, show the paper summary and the estimated cost, and ask before translating")
+	compileBook   = flag.Bool("compile", false, "After translating a book, copy its assets into the output directory and compile the translated main file (for book mode)")
+	diffReport    = flag.Bool("diff-report", false, "Print which files of a book are new, changed or unchanged since its last translation, without translating (for book mode)")
+	staleModel    = flag.Bool("invalidate-on-model-change", false, "Translate again the files of a book translated with another model or prompt preset (for book mode)")
+	arxivInterval = flag.Duration("arxiv-interval", downloader.DefaultRequestInterval, "Minimum spacing of the requests to arXiv, shared by all downloads of the process (0 = no limit, e.g. against a local mirror)")
+	compilerFlag  = flag.String("compiler", "", "LaTeX compiler for --compile: xelatex, lualatex or pdflatex (default xelatex, lualatex for Japanese)")
+	autoInstall   = flag.Bool("auto-install-packages", false, "Install LaTeX packages missing from the TeX distribution while compiling (MiKTeX on the fly, TeX Live with tlmgr); default from settings")
+	ignoreInclude = flag.Bool("ignore-includeonly", false, "Remove the \\includeonly directive of the main file and translate and compile every file (default: translate only the files it lists and keep it); default from settings")
+	batchFlag     = flag.String("batch", "", "File of arXiv IDs or URLs to translate one after another (one per line, # starts a comment); always runs in CLI mode")
+	parallelFlag  = flag.Int("parallel", 1, "Number of papers of --batch translated at the same time")
+	continueOnErr = flag.Bool("continue-on-error", false, "Keep translating the papers of --batch after one failed (default: stop starting new papers)")
+	onComplete    = flag.String("on-complete", "", "Webhook URL (HTTPS) posted a JSON summary, or command run with LT_* environment variables, when a translation finishes or fails; default from settings")
+	progressFmt   = flag.String("progress-format", "text", "Progress output of CLI runs: text, or json for newline-delimited JSON events on stdout with the human output on stderr")
+	serveFlag     = flag.Bool("serve", false, "Run an HTTP server accepting translation jobs from other machines instead of the GUI (POST /jobs, GET /jobs/{id}, GET /jobs/{id}/pdf, DELETE /jobs/{id})")
+	listenFlag    = flag.String("listen", "127.0.0.1:8765", "Address the --serve HTTP server listens on, e.g. 0.0.0.0:8765 for every network interface")
+	serveToken    = flag.String("serve-token", "", "Bearer token required by every request to the --serve API (default: the LT_SERVE_TOKEN environment variable; empty allows any client)")
+	serveJobs     = flag.Int("serve-jobs", 1, "Number of jobs of --serve translated at the same time")
+	servePaths    = flag.String("serve-allow-paths", "", "Directory whose zip files --serve clients may submit by path (default: only arXiv IDs and URLs are accepted)")
 )
 
+// progressEvents writes the JSON progress events of --progress-format json; nil otherwise
+var progressEvents *statusfile.EventWriter
+
 // printHelp displays the help information for command line usage.
 func printHelp() {
 	fmt.Println("LaTeX Translator - 将英文 LaTeX 文档翻译成中文并生成 PDF")
@@ -50,25 +119,125 @@ func printHelp() {
 	fmt.Println("  --file <PATH>      本地 zip 文件路径 (LaTeX 源码)")
 	fmt.Println("  --pdf <PATH>       PDF 文件路径 (直接翻译 PDF)")
 	fmt.Println("  --book <PATH>      书籍目录或 zip 文件 (LaTeX 书籍项目)")
+	fmt.Println("  --batch <PATH>     批量翻译: 每行一个 arXiv ID 或 URL 的文件 (# 开头为注释), 总是以命令行模式运行")
+	fmt.Println("  --parallel <N>     批量模式同时翻译的论文数 (默认 1)")
+	fmt.Println("  --continue-on-error 批量模式: 某篇论文失败后继续翻译其余论文 (默认不再开始新的论文)")
 	fmt.Println("  --max-files <N>    最大翻译文件数 (0=全部, 用于书籍模式)")
-	fmt.Println("  --output <PATH>    输出目录 (用于书籍模式)")
+	fmt.Println("  --jobs <N>         书籍模式同时翻译的文件数 (0=使用设置中的并发数)")
+	fmt.Println("  --output <PATH>    输出目录 (用于书籍模式; 批量模式的报告目录, 默认为列表文件旁的 <文件名>_batch)")
 	fmt.Println("  --cli              命令行模式运行 (不启动 GUI)")
+	fmt.Println("  --preview-chunks   仅预览翻译分块 (不调用 LLM, 可配合 --id/--url/--file, --file 也可为已解压目录)")
+	fmt.Println("  --estimate         仅估算分块数、输入/输出 token 和翻译耗时 (不调用 LLM, 可配合 --quick/--lang)")
+	fmt.Println("  --allow-duplicate  即使同一论文正在另一进程 (GUI 或 CLI) 中翻译也继续")
+	fmt.Println("  --force-translate  即使源文档似乎已是中文也翻译成中文")
+	fmt.Println("  --status-file <PATH> CLI 模式下持续更新的状态 JSON 文件 (默认: 工作/输出目录下的 status.json)")
+	fmt.Println("  --max-compiles <N> 同时运行的 LaTeX 编译进程数上限 (0=使用设置, 默认 2, 低内存机器建议 1)")
+	fmt.Println("  --variant <V>      译文字形: zh-Hans (简体) 或 zh-Hant (繁体), 默认使用设置中的选项")
+	fmt.Println("  --lang <L>         译文语言: zh、ja、ko、ru、en、fr、de 或 es, 默认使用设置中的选项 (中文)")
+	fmt.Println("  --glossary <PATH>  术语表文件 (JSON 或 CSV, 英文术语 → 固定译法), 注入每个分块的提示词并强制替换, 默认使用设置中的文件")
+	fmt.Println("  --prompt-preset <NAME>  提示词预设: default, math, cs, biomed (加入该领域的翻译说明), 默认使用设置中的预设")
+	fmt.Println("  --main-tex <FILE>  指定主 tex 文件 (相对源码根目录), 用于包含多个独立文档 (论文、幻灯片、海报) 的项目, 默认自动选择得分最高的文件")
+	fmt.Println("  --bilingual-layout <L> 双语 PDF 版式: side-by-side (左右并排, 默认) 或 interleaved (原文/译文交替页, 适合打印)")
+	fmt.Println("  --quick            快速模式: 更大分块、只编译一遍、仅规则修复、不生成双语 PDF, 译文首页标注“快速模式”")
+	fmt.Println("  --auto-context     将上下文窗口设为当前模型的推荐值 (模型上限的 60%) 并保存到设置")
+	fmt.Println("  --no-compile       未编译模式: 不需要 LaTeX, 只生成译文 tex 文件和 HTML 预览, 结果库中标注“未编译”")
+	fmt.Println("  --translate-arxiv-pdf 配合 --no-compile, 同时用 PDF 翻译器翻译 arXiv 提供的 PDF")
+	fmt.Println("  --translate-files <F1,F2> 多文件项目中强制翻译的文件 (覆盖 decisions.json 中的自动决定)")
+	fmt.Println("  --copy-files <F1,F2>      多文件项目中原样复制、不翻译的文件")
+	fmt.Println("  --confirm          下载源码后显示论文信息、预计消耗和风险提示, 确认后才开始翻译")
+	fmt.Println("  --compile          书籍模式: 翻译完成后复制图片等资源文件并编译译文主文件, 生成 PDF")
+	fmt.Println("  --diff-report      书籍模式: 只列出自上次翻译以来新增、已改变和未改变的文件, 不翻译 (不需要 API 密钥)")
+	fmt.Println("  --invalidate-on-model-change 书籍模式: 用其他模型或提示词预设翻译的文件也重新翻译")
+	fmt.Println("  --compiler <C>     --compile 使用的编译器: xelatex (默认, 日语译文默认 lualatex)、lualatex 或 pdflatex")
+	fmt.Println("  --auto-install-packages 编译时自动安装缺少的宏包 (MiKTeX 即时安装, TeX Live 使用 tlmgr), 安装的宏包记录在编译日志中, 默认使用设置中的选项")
+	fmt.Println("  --ignore-includeonly 移除主文件中的 \\includeonly, 翻译并编译全部文件 (默认只翻译其中列出的文件并保留该指令, 原文和译文页数一致), 默认使用设置中的选项")
+	fmt.Println("  --arxiv-interval <D> 访问 arXiv 的最小请求间隔 (默认 3s, 0=不限速, 仅用于本地镜像); 遇到 429/503 时自动指数退避并遵守 Retry-After")
+	fmt.Println("  --on-complete <H>  翻译成功或失败时通知: HTTPS 地址收到 POST 的 JSON (source、mode、status、error、original_pdf、translated_pdf、bilingual_pdf、tokens_used、duration_seconds),")
+	fmt.Println("                     其他内容作为命令由 shell 执行, 这些值通过环境变量 LT_SOURCE、LT_STATUS、LT_ERROR、LT_TRANSLATED_PDF、LT_TOKENS_USED 等传入 (LT_PAYLOAD 为完整 JSON);")
+	fmt.Println("                     webhook 超时 5 秒、最多尝试 3 次, 通知失败只记录日志, 默认使用设置中的选项")
+	fmt.Println("  --progress-format <F> CLI 模式的进度输出: text (默认, 供人阅读) 或 json (标准输出逐行输出 JSON 事件, 其余提示改到标准错误)")
+	fmt.Println("  --serve            以 HTTP 服务运行 (不启动 GUI), 接收其他电脑提交的翻译任务, 接口见下方“HTTP 服务”")
+	fmt.Println("  --listen <ADDR>    --serve 监听的地址 (默认 127.0.0.1:8765, 0.0.0.0:8765 接受所有网卡的连接)")
+	fmt.Println("  --serve-token <T>  --serve 要求每个请求携带的访问令牌 (Authorization: Bearer <T>), 默认读取环境变量 LT_SERVE_TOKEN, 为空时不验证")
+	fmt.Println("  --serve-jobs <N>   --serve 同时翻译的任务数 (默认 1), 其余任务排队")
+	fmt.Println("  --serve-allow-paths <DIR>  允许 --serve 的客户端按路径提交该目录 (含子目录) 中的 zip 文件, 默认只接受 arXiv ID 和 URL")
 	fmt.Println("  -h, --help         显示帮助信息")
 	fmt.Println()
+	fmt.Println("结果库:")
+	fmt.Println("  library list [--query <Q>] [--page <N>] [--page-size <N>]  列出已翻译的论文 (按标题、arXiv ID 搜索, 每页数量默认使用设置)")
+	fmt.Println("  library delete <ID>...                                     删除论文及其全部文件")
+	fmt.Println("  library export <ZIP> [ID...]                               导出论文 (默认全部) 到 zip 归档, 用于迁移到其他电脑")
+	fmt.Println("  library import <ZIP> [--overwrite]                         从 zip 归档导入论文, 已有的论文默认跳过")
+	fmt.Println("  usage [--since <日期|N天>] [--json]                        统计 token 用量和估算费用 (含重试、语法修复和编译修复的请求),")
+	fmt.Println("                                                             --since 为 2026-01-02 或 30d, 默认全部; 价格可用设置中的 model_prices 修正")
+	fmt.Println()
+	fmt.Println("工作目录:")
+	fmt.Println("  每篇论文在工作目录的 papers/<arXiv ID 或 md5_哈希> 下有自己的目录; 启动时按设置中的清理策略 (默认保留最近 20 篇、总大小 10 GB)")
+	fmt.Println("  从最久未使用的目录开始删除, 正在翻译、出错可继续、已取消或需要手动修复的论文的目录始终保留")
+	fmt.Println("  clean [--keep <N>] [--max-age-days <N>] [--max-size-gb <N>]  按清理策略删除论文目录, 参数覆盖设置, 0 表示不限制")
+	fmt.Println("        [--work-dir <D>] [--dry-run] [--list] [--json]           --dry-run 只列出将删除的目录, --list 显示磁盘占用")
+	fmt.Println()
+	fmt.Println("HTTP 服务 (--serve):")
+	fmt.Println(`  POST   /jobs                  请求体 {"input":"2301.00001"} (arXiv ID 或 URL; 指定 --serve-allow-paths 时也可以是该目录中的 zip 路径), 返回任务; 同一输入排队或翻译中时返回已有任务`)
+	fmt.Println("  GET    /jobs                  列出全部任务")
+	fmt.Println("  GET    /jobs/{id}             任务状态: state (queued、running、done、failed、cancelled)、status (与 GUI 的 GetStatus 相同)、outputs (结果文件路径)、error")
+	fmt.Println("  GET    /jobs/{id}/pdf?kind=K  下载 PDF, K 为 original、translated (默认) 或 bilingual, 支持断点续传")
+	fmt.Println("  DELETE /jobs/{id}             取消任务; 按 Ctrl+C 停止服务时取消排队和进行中的任务")
+	fmt.Println()
+	fmt.Println("JSON 进度 (--progress-format json):")
+	fmt.Println("  标准输出每行一个 JSON 对象 (NDJSON), 适用于 --pdf、--book 和 --id/--url/--file 的 CLI 模式, 字段只增不改:")
+	fmt.Println(`    {"event":"status","mode":"arxiv","phase":"translating","progress":57,"message":"...","file":"intro.tex","chunk":3,"total_chunks":8,"tokens_used":18342,"time":"..."}`)
+	fmt.Println(`    {"event":"complete","mode":"arxiv","phase":"complete","progress":100,"message":"...","tokens_used":40211,"outputs":{"translated_pdf":"..."},"time":"..."}`)
+	fmt.Println(`    {"event":"error","mode":"arxiv","phase":"error","progress":57,"message":"...","tokens_used":18342,"error":"...","code":"COMPILE_FAILED","exit_code":1,"time":"..."}`)
+	fmt.Println("  event       status (进度变化时输出), complete 或 error (终止事件, 最多一个, 之后进程退出)")
+	fmt.Println("  mode        arxiv、pdf 或 book")
+	fmt.Println("  phase       当前阶段, 与状态文件的 phase 相同; progress 为 0-100; message 仅供展示, 不要解析")
+	fmt.Println("  file/chunk/total_chunks  正在翻译的文件及分块进度 (可能缺省); tokens_used 为目前消耗的 token 数")
+	fmt.Println("  outputs     complete: 输出路径, 键为 original_pdf、translated_pdf、bilingual_pdf、translated_tex、translated_html、quality_report、output_dir、work_dir 中适用的几个")
+	fmt.Println("  warnings    complete/error: 最近的警告")
+	fmt.Println("  error/code/exit_code  error: 失败原因、错误码 (可能缺省) 和进程退出码 (1=失败, 2=需要手动修复, 130=已取消, 3=已达到翻译预算)")
+	fmt.Println("  开始处理前的参数或配置错误只输出到标准错误; 无论哪种格式, 退出码 0 表示成功")
+	fmt.Println()
 	fmt.Println("示例:")
 	fmt.Println("  latex-translator                           # 启动 GUI 界面")
 	fmt.Println("  latex-translator --url https://arxiv.org/abs/2301.00001")
 	fmt.Println("  latex-translator --id 2301.00001")
 	fmt.Println("  latex-translator --file /path/to/paper.zip")
 	fmt.Println("  latex-translator --pdf /path/to/paper.pdf --cli")
+	fmt.Println("  latex-translator --id 2301.00001 --preview-chunks")
+	fmt.Println("  latex-translator --id 2301.00001 --estimate")
+	fmt.Println("  latex-translator --id 2301.00001 --cli --no-compile")
+	fmt.Println("  latex-translator --id 2301.00001 --cli --glossary terms.csv")
+	fmt.Println("  latex-translator --id 2301.00001 --cli --bilingual-layout interleaved")
+	fmt.Println("  latex-translator --id 2301.00001 --cli --progress-format json > events.ndjson")
+	fmt.Println("  latex-translator --file /path/to/thesis.zip --cli --main-tex thesis.tex")
 	fmt.Println("  latex-translator --book /path/to/book.zip --cli --max-files 5")
 	fmt.Println("  latex-translator --book /path/to/book --output /path/to/output --cli")
+	fmt.Println("  latex-translator --book /path/to/book --cli --jobs 4")
+	fmt.Println("  latex-translator --book /path/to/book --cli --compile --compiler lualatex")
+	fmt.Println("  latex-translator --book /path/to/book --cli --translate-files appendix.tex --copy-files macros.tex")
+	fmt.Println("  latex-translator --book /path/to/book --output /path/to/output --cli --diff-report")
+	fmt.Println("  latex-translator --batch ids.txt --parallel 2 --continue-on-error --output reports")
+	fmt.Println("  latex-translator --id 2301.00001 --cli --on-complete https://hooks.example.com/translated")
+	fmt.Println("  LT_SERVE_TOKEN=secret latex-translator --serve --listen 0.0.0.0:8765 --serve-jobs 2")
+	fmt.Println(`  curl -H "Authorization: Bearer secret" -d '{"input":"2301.00001"}' http://server:8765/jobs`)
+	fmt.Println(`  latex-translator --id 2301.00001 --cli --on-complete 'notify-send "翻译$LT_STATUS" "$LT_SOURCE"'`)
+	fmt.Println("  latex-translator library list --query diffusion")
+	fmt.Println("  latex-translator library delete 2301.00001")
+	fmt.Println("  latex-translator library export /path/to/library.zip")
+	fmt.Println("  latex-translator library import /path/to/library.zip --overwrite")
+	fmt.Println("  latex-translator usage --since 30d")
+	fmt.Println("  latex-translator clean --list")
+	fmt.Println("  latex-translator clean --keep 10 --dry-run")
 	fmt.Println()
 	fmt.Println("说明:")
 	fmt.Println("  如果不提供任何参数，程序将启动图形界面。")
 	fmt.Println("  如果提供了 --url、--id 或 --file 参数，程序将启动后自动开始处理。")
 	fmt.Println("  使用 --pdf 和 --cli 可以在命令行模式下直接翻译 PDF 文件。")
-	fmt.Println("  使用 --book 和 --cli 可以在命令行模式下翻译整本书籍。")
+	fmt.Println("  使用 --book 和 --cli 可以在命令行模式下翻译整本书籍。输出目录中的 translation-manifest.json 记录每个源文件的哈希,")
+	fmt.Println("  再次运行时只翻译新增或内容已改变的文件。")
+	fmt.Println("  使用 --batch 可以依次翻译列表中的论文, 结果库中已完成的论文会跳过; 每篇论文的 JSON 报告 (下载/编译/翻译状态、PDF 路径、错误、token 数)")
+	fmt.Println("  写入报告目录, 汇总写入 batch_report.json, 按 Ctrl+C 会等待进行中的论文取消后保存报告。")
 }
 
 // getInputFromFlags returns the input string from command line flags.
@@ -104,16 +273,24 @@ func getInputFromFlags() (string, string, error) {
 		input = *bookFlag
 		inputType = "book"
 	}
+	if *batchFlag != "" {
+		count++
+		input = *batchFlag
+		inputType = "batch"
+	}
 
 	if count > 1 {
-		return "", "", fmt.Errorf("只能指定一个输入源 (--url, --id, --file, --pdf, 或 --book)")
+		return "", "", fmt.Errorf("只能指定一个输入源 (--url, --id, --file, --pdf, --book 或 --batch)")
 	}
 
 	return input, inputType, nil
 }
 
 // PDFHandler handles requests for PDF files from the local filesystem
-type PDFHandler struct{}
+type PDFHandler struct {
+	// files are the PDFs registered by GetPDFDataURL, served from /pdf/token/<token>
+	files *pdfserve.Registry
+}
 
 func (h *PDFHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Only handle /pdf/ requests
@@ -122,23 +299,18 @@ func (h *PDFHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract the file path from the URL
+	// Registered large PDFs
+	if h.files != nil && h.files.ServeToken(w, r) {
+		return
+	}
+
+	// Files of the work and results directories by path
 	// URL format: /pdf/C:/path/to/file.pdf or /pdf/path/to/file.pdf
-	filePath := strings.TrimPrefix(r.URL.Path, "/pdf/")
-	
-	// URL decode the path
-	filePath = strings.ReplaceAll(filePath, "%20", " ")
-	filePath = strings.ReplaceAll(filePath, "%3A", ":")
-	
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	if h.files == nil {
 		http.NotFound(w, r)
 		return
 	}
-
-	// Serve the PDF file
-	w.Header().Set("Content-Type", "application/pdf")
-	http.ServeFile(w, r, filePath)
+	h.files.ServePath(w, r)
 }
 
 func main() {
@@ -148,6 +320,20 @@ func main() {
 	// Parse command line flags
 	flag.Parse()
 
+	// Library management subcommand
+	if flag.NArg() > 0 && flag.Arg(0) == "library" {
+		runLibraryCLI(flag.Args()[1:])
+		return
+	}
+	if flag.NArg() > 0 && flag.Arg(0) == "usage" {
+		runUsageCLI(flag.Args()[1:])
+		return
+	}
+	if flag.NArg() > 0 && flag.Arg(0) == "clean" {
+		runCleanCLI(flag.Args()[1:])
+		return
+	}
+
 	// Get input from flags
 	input, inputType, err := getInputFromFlags()
 	if err != nil {
@@ -156,6 +342,74 @@ func main() {
 		printHelp()
 		os.Exit(1)
 	}
+	if _, err := types.ParseChineseVariant(*variantFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := types.ParseTargetLanguage(*langFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := types.ParseBilingualLayout(*bilingualFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if *arxivInterval < 0 {
+		fmt.Fprintln(os.Stderr, "错误: --arxiv-interval 不能为负数")
+		os.Exit(1)
+	}
+	downloader.SetRateLimit(*arxivInterval, 1)
+	if *progressFmt != "text" && *progressFmt != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的进度格式 %q (可选: text, json)\n", *progressFmt)
+		os.Exit(1)
+	}
+
+	// HTTP server accepting jobs from other machines
+	if *serveFlag {
+		if input != "" {
+			fmt.Fprintln(os.Stderr, "错误: --serve 不能与 --url、--id、--file、--pdf、--book 或 --batch 同时使用")
+			os.Exit(1)
+		}
+		token := *serveToken
+		if token == "" {
+			token = os.Getenv("LT_SERVE_TOKEN")
+		}
+		runServeCLI(*listenFlag, token, *serveJobs, *servePaths)
+		return
+	}
+
+	// Chunking preview (no translation)
+	if *previewChunks {
+		if input == "" || inputType == "pdf" || inputType == "book" || inputType == "batch" {
+			fmt.Fprintln(os.Stderr, "错误: --preview-chunks 需要配合 --id、--url 或 --file 使用")
+			os.Exit(1)
+		}
+		runChunkPreviewCLI(input)
+		return
+	}
+
+	// Cost estimate (no translation)
+	if *estimateFlag {
+		if input == "" || inputType == "pdf" || inputType == "book" || inputType == "batch" {
+			fmt.Fprintln(os.Stderr, "错误: --estimate 需要配合 --id、--url 或 --file 使用")
+			os.Exit(1)
+		}
+		runEstimateCLI(input)
+		return
+	}
+
+	// Batch translation of a list of papers, with or without --cli
+	if inputType == "batch" {
+		runBatchTranslationCLI(input, *outputDir, *parallelFlag, *continueOnErr)
+		return
+	}
+
+	// JSON progress events own stdout; everything printed for humans, including the
+	// console log, goes to stderr
+	if *cliFlag && *progressFmt == "json" {
+		progressEvents = statusfile.NewEventWriter(os.Stdout)
+		os.Stdout = os.Stderr
+	}
 
 	// CLI mode for PDF translation
 	if *cliFlag && inputType == "pdf" {
@@ -177,9 +431,10 @@ func main() {
 
 	// Create an instance of the app structure
 	app := NewApp()
-	
+
 	// Mark as running in Wails environment
 	app.SetWailsRuntime(true)
+	applySessionFlags(app)
 
 	// Wrap the startup function to handle command line input
 	startupFunc := func(ctx context.Context) {
@@ -214,44 +469,36 @@ func main() {
 		Height: 768,
 		AssetServer: &assetserver.Options{
 			Assets:  assets,
-			Handler: &PDFHandler{},
+			Handler: &PDFHandler{files: app.PDFFiles()},
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        startupFunc,
+		OnDomReady:       app.domReady,
 		OnBeforeClose: func(ctx context.Context) (prevent bool) {
-			// Check if there's any translation task in progress (LaTeX or PDF)
-			if app.IsAnyTranslationInProgress() {
-				// Determine which type of translation is in progress for the message
-				var taskType string
-				if app.IsProcessing() && app.IsPDFTranslating() {
-					taskType = "LaTeX 和 PDF 翻译任务"
-				} else if app.IsProcessing() {
-					taskType = "LaTeX 翻译任务"
-				} else {
-					taskType = "PDF 翻译任务"
-				}
-				
-				// Show confirmation dialog
-				result, err := runtime.MessageDialog(ctx, runtime.MessageDialogOptions{
-					Type:          runtime.QuestionDialog,
-					Title:         "确认退出",
-					Message:       fmt.Sprintf("%s正在进行中，确定要退出吗？\n退出后当前任务将被取消。", taskType),
-					Buttons:       []string{"取消", "退出"},
-					DefaultButton: "取消",
-					CancelButton:  "取消",
-				})
-				if err != nil {
-					// If dialog fails, allow close
-					return false
-				}
-				// If user clicked "取消" (Cancel), prevent close
-				if result == "取消" {
-					return true
-				}
-				// User clicked "退出" (Exit), cancel the processes and allow close
-				app.CancelProcess()
-				app.CancelPDFTranslation()
+			// Running translations, jobs waiting for confirmation and fixes waiting for review
+			// are cancelled by closing
+			summary := app.GetCloseSummary()
+			if !summary.Busy {
+				return false
 			}
+
+			var items strings.Builder
+			for _, item := range summary.Items {
+				items.WriteString("• " + item + "\n")
+			}
+			result, err := runtime.MessageDialog(ctx, runtime.MessageDialogOptions{
+				Type:          runtime.QuestionDialog,
+				Title:         "确认退出",
+				Message:       fmt.Sprintf("以下工作正在进行，退出后将被取消：\n%s\n已完成的进度会保存，下次启动时自动继续。确定要退出吗？", items.String()),
+				Buttons:       []string{"取消", "退出"},
+				DefaultButton: "取消",
+				CancelButton:  "取消",
+			})
+			if err == nil && result == "取消" {
+				return true
+			}
+			// User clicked "退出" (Exit), or the dialog failed: stop the work in order and close
+			app.shutdownForExit(15 * time.Second)
 			return false
 		},
 		Bind: []interface{}{
@@ -279,6 +526,7 @@ func runPDFTranslationCLI(pdfPath string) {
 	// Create app and initialize
 	app := NewApp()
 	app.startup(context.Background())
+	applyCompleteHookFlag(app)
 
 	// Print config info for debugging
 	if app.config != nil {
@@ -286,6 +534,7 @@ func runPDFTranslationCLI(pdfPath string) {
 		fmt.Printf("Model: %s\n", app.config.GetModel())
 		fmt.Printf("Context Window: %d\n", app.config.GetContextWindow())
 	}
+	checkContextWindowCLI(app)
 
 	// Load PDF
 	fmt.Println("正在加载 PDF...")
@@ -298,7 +547,20 @@ func runPDFTranslationCLI(pdfPath string) {
 
 	// Start translation with progress monitoring
 	fmt.Println("正在翻译...")
-	
+
+	// Machine-readable progress for wrapper scripts
+	statusWriter := statusfile.NewWriter(statusFilePath(app.GetWorkDir()), "pdf", pdfPath)
+	fmt.Printf("状态文件: %s\n", statusWriter.Path())
+	progressEvents.Follow(statusWriter)
+	statusWriter.Start(statusfile.DefaultInterval, func(s *statusfile.Status) {
+		status := app.GetPDFStatus()
+		s.Phase = string(status.Phase)
+		s.Percent = status.Progress
+		s.Message = status.Message
+		s.Chunk = status.CompletedBlocks
+		s.TotalChunks = status.TotalBlocks
+	})
+
 	// Start a goroutine to monitor progress
 	done := make(chan bool)
 	go func() {
@@ -311,7 +573,7 @@ func runPDFTranslationCLI(pdfPath string) {
 			case <-ticker.C:
 				status := app.GetPDFStatus()
 				if status != nil {
-					fmt.Printf("  状态: %s - %s (进度: %d%%)\n", 
+					fmt.Printf("  状态: %s - %s (进度: %d%%)\n",
 						status.Phase, status.Message, status.Progress)
 				}
 			}
@@ -320,11 +582,20 @@ func runPDFTranslationCLI(pdfPath string) {
 
 	result, err := app.TranslatePDF()
 	close(done)
+	if result != nil {
+		statusWriter.Update(func(s *statusfile.Status) { s.TokensUsed = result.TokensUsed })
+	}
+	statusWriter.Finish(err)
 
 	if err != nil {
+		progressEvents.Fail(statusWriter.Snapshot(), err, appErrorCode(err), 1)
 		fmt.Fprintf(os.Stderr, "错误: 翻译失败: %v\n", err)
 		os.Exit(1)
 	}
+	progressEvents.Complete(statusWriter.Snapshot(), map[string]string{
+		"original_pdf":   result.OriginalPDFPath,
+		"translated_pdf": result.TranslatedPDFPath,
+	})
 
 	fmt.Println()
 	fmt.Println("=== 翻译完成 ===")
@@ -338,6 +609,76 @@ func runPDFTranslationCLI(pdfPath string) {
 	app.shutdown(context.Background())
 }
 
+// applySessionFlags applies the command line options that override the settings for this
+// run only
+func applySessionFlags(app *App) {
+	app.SetAllowDuplicateJobs(*allowDup)
+	app.SetForceTranslate(*forceFlag)
+	if *autoInstall {
+		app.UseAutoInstallPackages()
+	}
+	if *ignoreInclude {
+		app.UseIgnoreIncludeOnly()
+	}
+	if *variantFlag != "" {
+		app.UseChineseVariant(*variantFlag)
+	}
+	if *langFlag != "" {
+		app.UseTargetLanguage(*langFlag)
+	}
+	if *glossaryFlag != "" {
+		if err := app.UseGlossary(*glossaryFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *promptPreset != "" {
+		if err := app.UsePromptPreset(*promptPreset); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	app.UseBilingualLayout(*bilingualFlag)
+	if *maxCompiles > 0 {
+		app.UseMaxConcurrentCompiles(*maxCompiles)
+	}
+	app.SetQuickMode(*quickFlag)
+	app.SetNoCompileMode(*noCompileFlag, *translatePDF)
+	app.SetFileOverrides(decisions.ParseList(*translateList), decisions.ParseList(*copyList))
+	applyCompleteHookFlag(app)
+}
+
+// applyCompleteHookFlag sets the completion hook of --on-complete for this run
+func applyCompleteHookFlag(app *App) {
+	if *onComplete == "" {
+		return
+	}
+	if err := app.UseOnCompleteHook(*onComplete); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// checkContextWindowCLI applies the recommended context window with --auto-context, or
+// prints a hint when the configured window does not fit the model
+func checkContextWindowCLI(app *App) {
+	if app.config == nil {
+		return
+	}
+	if *autoContext {
+		advice, err := app.ApplyRecommendedContextWindow()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法设置推荐的上下文窗口: %v\n", err)
+			return
+		}
+		fmt.Printf("上下文窗口已设为模型 %s 的推荐值: %d\n", advice.Model, advice.Configured)
+		return
+	}
+	if advice := app.config.CheckConfiguredContextWindow(); advice.Mismatch {
+		fmt.Printf("提示: %s，可使用 --auto-context 自动设置\n", advice.Message)
+	}
+}
+
 // runArxivTranslationCLI runs arXiv LaTeX translation in CLI mode without GUI
 func runArxivTranslationCLI(input string) {
 	// Initialize logger with console output for CLI mode
@@ -354,146 +695,1466 @@ func runArxivTranslationCLI(input string) {
 	// Create app and initialize
 	app := NewApp()
 	app.startup(context.Background())
+	applySessionFlags(app)
+	app.UseMainTexFile(*mainTexFlag)
+	if stdinIsTerminal() {
+		app.setFixReviewPrompt(reviewFixCLI)
+	}
+	if *noCompileFlag {
+		fmt.Println("未编译模式已开启，跳过 LaTeX 编译，只生成译文 tex 文件和 HTML 预览")
+	}
+	if *quickFlag {
+		fmt.Println("快速模式已开启，将降低以下质量换取速度:")
+		for _, downgrade := range app.GetQuickModeDowngrades() {
+			fmt.Printf("  - %s\n", downgrade)
+		}
+	}
 
 	// Print config info for debugging
 	if app.config != nil {
 		fmt.Printf("API Base URL: %s\n", app.config.GetBaseURL())
 		fmt.Printf("Model: %s\n", app.config.GetModel())
 	}
+	checkContextWindowCLI(app)
 
 	// Print work directory for debugging
 	fmt.Printf("工作目录: %s\n", app.GetWorkDir())
 
+	jobID := ""
+	if *confirmFlag {
+		jobID = confirmJobCLI(app, input)
+	}
+
+	// Machine-readable progress for wrapper scripts
+	statusWriter := statusfile.NewWriter(statusFilePath(app.GetWorkDir()), "arxiv", input)
+	fmt.Printf("状态文件: %s\n", statusWriter.Path())
+	progressEvents.Follow(statusWriter)
+	statusWriter.Start(statusfile.DefaultInterval, func(s *statusfile.Status) {
+		fillStatusFromApp(app, s)
+	})
+
+	// Name each file of a multi-file document as its translation starts; the callback runs
+	// on the chunk goroutines
+	var fileMu sync.Mutex
+	lastFile := ""
+	app.SetStatusCallback(func(s *types.Status) {
+		detail := s.Translation
+		if detail == nil || detail.TotalFiles < 2 {
+			return
+		}
+		fileMu.Lock()
+		defer fileMu.Unlock()
+		if detail.File != lastFile {
+			lastFile = detail.File
+			fmt.Printf("  📄 文件 %d/%d: %s\n", detail.FileIndex, detail.TotalFiles, detail.File)
+		}
+	})
+
 	// Start a goroutine to monitor progress
 	done := make(chan bool)
 	go func() {
 		ticker := time.NewTicker(3 * time.Second)
 		defer ticker.Stop()
 		lastProgress := -1
+		lastMessage := ""
 		for {
 			select {
 			case <-done:
 				return
 			case <-ticker.C:
 				status := app.GetStatus()
-				if status != nil && status.Progress != lastProgress {
+				if status != nil && (status.Progress != lastProgress || status.Message != lastMessage) {
 					fmt.Printf("  [%d%%] %s: %s\n", status.Progress, status.Phase, status.Message)
 					lastProgress = status.Progress
+					lastMessage = status.Message
 				}
 			}
 		}
 	}()
 
-	// Process the source
-	result, err := app.ProcessSource(input)
+	// First Ctrl+C during the compile-error fix loop only skips the remaining fixes and
+	// keeps the translation for manual fixing; a second Ctrl+C aborts everything
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		skipped := false
+		cancelled := false
+		for range interrupts {
+			if !skipped && app.IsFixInProgress() {
+				skipped = true
+				if err := app.SkipRemainingFixes(); err == nil {
+					fmt.Println("\n已跳过剩余修复，正在保存译文以便手动修复（再按一次 Ctrl+C 终止全部处理）")
+					continue
+				}
+			}
+			// Cancelling lets the current file finish so the partial result can be saved;
+			// another Ctrl+C exits right away
+			if !cancelled {
+				cancelled = true
+				fmt.Println("\n正在取消，等待当前文件完成后保存已完成的部分（再按一次 Ctrl+C 立即退出）...")
+				app.CancelProcess()
+				continue
+			}
+			fmt.Println("\n正在终止处理...")
+			statusWriter.Finish(fmt.Errorf("已取消"))
+			progressEvents.Fail(statusWriter.Snapshot(), fmt.Errorf("已取消"), string(types.ErrCancelled), 130)
+			fmt.Fprintf(os.Stderr, "工作目录保留在: %s\n", app.GetWorkDir())
+			os.Exit(130)
+		}
+	}()
+
+	// Process the source (the confirmed job reuses the source downloaded for its summary)
+	var result *types.ProcessResult
+	var err error
+	if jobID != "" {
+		result, err = app.ConfirmJob(jobID)
+	} else {
+		result, err = app.ProcessSource(input)
+	}
 	close(done)
+	statusWriter.Update(func(s *statusfile.Status) { fillStatusFromApp(app, s) })
+	statusWriter.Finish(err)
+
+	if appErr, ok := err.(*types.AppError); ok && appErr.Code == types.ErrNeedsManualFix {
+		progressEvents.Fail(statusWriter.Snapshot(), err, string(appErr.Code), 2)
+		fmt.Println()
+		fmt.Println("=== 需要手动修复 ===")
+		fmt.Println(appErr.Details)
+		fmt.Println("修复后可在 GUI 的结果列表中点击“重新编译”，无需重新翻译")
+		os.Exit(2)
+	}
+
+	if appErr, ok := err.(*types.AppError); ok && appErr.Code == types.ErrCancelled {
+		progressEvents.Fail(statusWriter.Snapshot(), err, string(appErr.Code), 130)
+		fmt.Println()
+		fmt.Println("=== 已取消（可继续） ===")
+		fmt.Println(appErr.Details)
+		fmt.Println("可在 GUI 的结果列表中点击“继续”，已翻译的文件不会重新翻译")
+		os.Exit(130)
+	}
+
+	if appErr, ok := err.(*types.AppError); ok && appErr.Code == types.ErrBudgetExceeded {
+		progressEvents.Fail(statusWriter.Snapshot(), err, string(appErr.Code), 3)
+		fmt.Println()
+		fmt.Println("=== 已达到翻译预算（可继续） ===")
+		fmt.Println(appErr.Details)
+		fmt.Println("可在 GUI 的结果列表中点击“继续”，已翻译的部分不会重新翻译，继续时重新计算预算")
+		os.Exit(3)
+	}
+
+	if appErr, ok := err.(*types.AppError); ok && appErr.Code == types.ErrDuplicateJob {
+		progressEvents.Fail(statusWriter.Snapshot(), err, string(appErr.Code), 1)
+		fmt.Fprintf(os.Stderr, "\n错误: %s\n%s\n", appErr.Message, appErr.Details)
+		os.Exit(1)
+	}
 
 	if err != nil {
+		progressEvents.Fail(statusWriter.Snapshot(), err, appErrorCode(err), 1)
 		fmt.Fprintf(os.Stderr, "\n错误: 翻译失败: %v\n", err)
+		if diff := app.compileLogDiff(); len(diff) > 0 {
+			fmt.Fprintln(os.Stderr, "\n译文编译新增的错误和警告（原始文档编译日志中没有）:")
+			fmt.Fprint(os.Stderr, compiler.FormatLogDiff(diff))
+		}
 		fmt.Fprintf(os.Stderr, "工作目录保留在: %s\n", app.GetWorkDir())
 		// Don't cleanup on error so we can inspect the files
 		os.Exit(1)
 	}
 
+	progressEvents.Complete(statusWriter.Snapshot(), processOutputs(result, app.GetWorkDir()))
+
 	fmt.Println()
 	fmt.Println("=== 翻译完成 ===")
-	fmt.Printf("原始 PDF: %s\n", result.OriginalPDFPath)
-	fmt.Printf("翻译 PDF: %s\n", result.TranslatedPDFPath)
+	if result.SourceInfo != nil && result.SourceInfo.MainTexFallbackFrom != "" {
+		fmt.Printf("主文件自动切换为 %s (首选 %s 编译失败)\n",
+			filepath.ToSlash(result.SourceInfo.MainTexFile), filepath.ToSlash(result.SourceInfo.MainTexFallbackFrom))
+	}
+	if result.Uncompiled {
+		fmt.Println("注意: 译文未编译，安装 LaTeX 后去掉 --no-compile 重新运行即可生成 PDF")
+		fmt.Printf("翻译 tex: %s\n", result.TranslatedTexPath)
+		fmt.Printf("HTML 预览: %s\n", result.TranslatedHTMLPath)
+		if result.TranslatedPDFPath != "" {
+			fmt.Printf("arXiv PDF: %s\n", result.OriginalPDFPath)
+			fmt.Printf("翻译 PDF (PDF 翻译器): %s\n", result.TranslatedPDFPath)
+		}
+	} else {
+		fmt.Printf("原始 PDF: %s\n", result.OriginalPDFPath)
+		fmt.Printf("翻译 PDF: %s\n", result.TranslatedPDFPath)
+	}
+	if result.QuickMode {
+		fmt.Println("注意: 这是快速模式译文，去掉 --quick 重新运行即可升级为完整翻译并替换结果库中的记录")
+	}
+	if len(result.FontAudits) > 0 {
+		fmt.Println("字体嵌入:")
+		for _, audit := range result.FontAudits {
+			fmt.Println(pdf.FormatFontAudit(audit))
+		}
+	}
+	if result.QualityReportPath != "" {
+		if report, err := qa.Load(result.QualityReportPath); err == nil {
+			fmt.Println(qa.FormatSummary(report))
+		}
+		fmt.Printf("质量报告: %s\n", result.QualityReportPath)
+	}
 	fmt.Printf("工作目录: %s\n", app.GetWorkDir())
+	if result.PublishedDir != "" {
+		fmt.Printf("工作目录位于同步文件夹或不可写，已在本地临时目录中编译，最终文件复制到: %s\n", result.PublishedDir)
+	}
 
 	// Don't cleanup - keep the files for user to access
 	// app.shutdown(context.Background())
 }
 
-// runBookTranslationCLI runs book translation in CLI mode without GUI
-func runBookTranslationCLI(bookPath, outputPath string, maxFiles int) {
+// batchReportFile is the summary report of a --batch run, in its report directory
+const batchReportFile = "batch_report.json"
+
+// Outcomes of a paper of a --batch run
+const (
+	batchDone      = "done"
+	batchFailed    = "failed"
+	batchSkipped   = "skipped" // already complete in the results library
+	batchCancelled = "cancelled"
+)
+
+// batchPaperReport is the JSON report of one paper of a --batch run
+type batchPaperReport struct {
+	Input         string    `json:"input"`
+	ArxivID       string    `json:"arxiv_id,omitempty"`
+	State         string    `json:"state"` // done, failed, skipped or cancelled
+	Downloaded    bool      `json:"downloaded"`
+	Compiled      bool      `json:"compiled"` // the original compiled
+	Translated    bool      `json:"translated"`
+	PDFGenerated  bool      `json:"pdf_generated"`
+	OriginalPDF   string    `json:"original_pdf,omitempty"`
+	TranslatedPDF string    `json:"translated_pdf,omitempty"`
+	BilingualPDF  string    `json:"bilingual_pdf,omitempty"`
+	TranslatedTex string    `json:"translated_tex,omitempty"`
+	FailedPhase   string    `json:"failed_phase,omitempty"` // phase the paper failed in
+	Error         string    `json:"error,omitempty"`
+	ErrorCode     string    `json:"error_code,omitempty"`
+	TokensUsed    int       `json:"tokens_used"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
+
+// batchReport is the summary report of a --batch run, rewritten after every paper
+type batchReport struct {
+	List         string             `json:"list"`
+	Total        int                `json:"total"`
+	Done         int                `json:"done"`
+	Failed       int                `json:"failed"`
+	Skipped      int                `json:"skipped"`
+	Cancelled    int                `json:"cancelled"`
+	NotStarted   int                `json:"not_started"`
+	Downloaded   int                `json:"downloaded"`
+	Compiled     int                `json:"compiled"`
+	Translated   int                `json:"translated"`
+	PDFGenerated int                `json:"pdf_generated"`
+	TokensUsed   int                `json:"tokens_used"`
+	Interrupted  bool               `json:"interrupted"`
+	Papers       []batchPaperReport `json:"papers"` // finished papers, in finishing order
+	StartedAt    time.Time          `json:"started_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// add counts a finished paper
+func (r *batchReport) add(paper batchPaperReport) {
+	r.Papers = append(r.Papers, paper)
+	switch paper.State {
+	case batchDone:
+		r.Done++
+	case batchFailed:
+		r.Failed++
+	case batchSkipped:
+		r.Skipped++
+	case batchCancelled:
+		r.Cancelled++
+	}
+	if paper.Downloaded {
+		r.Downloaded++
+	}
+	if paper.Compiled {
+		r.Compiled++
+	}
+	if paper.Translated {
+		r.Translated++
+	}
+	if paper.PDFGenerated {
+		r.PDFGenerated++
+	}
+	r.TokensUsed += paper.TokensUsed
+	r.NotStarted = r.Total - len(r.Papers)
+}
+
+// runBatchTranslationCLI translates the papers listed in listPath through the normal
+// pipeline, up to parallel at a time, writing a JSON report per paper and a summary into
+// reportDir. Papers complete in the results library are skipped. Unless continueOnError,
+// no new paper is started after one failed. The first Ctrl+C cancels the papers in flight
+// and saves their reports; another one saves the summary and exits right away.
+func runBatchTranslationCLI(listPath, reportDir string, parallel int, continueOnError bool) {
 	// Initialize logger with console output for CLI mode
 	logger.Init(&logger.Config{
-		LogFilePath:   "latex-translator-book.log",
+		LogFilePath:   "latex-translator-batch.log",
 		Level:         logger.LevelInfo,
 		EnableConsole: true,
 	})
 	defer logger.Close()
 
-	fmt.Println("=== LaTeX 书籍翻译 (CLI 模式) ===")
-	fmt.Printf("输入: %s\n", bookPath)
-
-	// Load configuration
-	configMgr, err := config.NewConfigManager("latex-translator-config.json")
+	fmt.Println("=== 批量翻译 (CLI 模式) ===")
+	fmt.Printf("列表文件: %s\n", listPath)
+	inputs, err := readBatchList(listPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "错误: 无法加载配置: %v\n", err)
+		fmt.Fprintf(os.Stderr, "错误: 读取列表文件失败: %v\n", err)
 		os.Exit(1)
 	}
-
-	if err := configMgr.Load(); err != nil {
-		fmt.Fprintf(os.Stderr, "错误: 加载配置失败: %v\n", err)
+	if len(inputs) == 0 {
+		fmt.Fprintln(os.Stderr, "错误: 列表文件中没有论文")
 		os.Exit(1)
 	}
-
-	// Get API key from config or environment
-	apiKey := configMgr.GetAPIKey()
-	if apiKey == "" {
-		fmt.Fprintf(os.Stderr, "错误: API 密钥未配置\n")
-		fmt.Fprintf(os.Stderr, "请在配置文件中设置 API 密钥: latex-translator-config.json\n")
-		fmt.Fprintf(os.Stderr, "或设置环境变量:\n")
-		fmt.Fprintf(os.Stderr, "  Windows CMD: set OPENAI_API_KEY=your-key\n")
-		fmt.Fprintf(os.Stderr, "  PowerShell:  $env:OPENAI_API_KEY=\"your-key\"\n")
+	if reportDir == "" {
+		reportDir = strings.TrimSuffix(listPath, filepath.Ext(listPath)) + "_batch"
+	}
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 创建报告目录失败: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Get API configuration
-	baseURL := configMgr.GetBaseURL()
-	model := configMgr.GetModel()
-	
-	fmt.Printf("API Base URL: %s\n", baseURL)
-	fmt.Printf("Model: %s\n", model)
-
-	// Determine input directory
-	inputDir := bookPath
-	
-	// If it's a zip file, extract it first
-	if strings.HasSuffix(strings.ToLower(bookPath), ".zip") {
-		fmt.Println("正在解压 ZIP 文件...")
-		extractDir := strings.TrimSuffix(bookPath, ".zip") + "_extracted"
-		
-		// Create extract directory
-		if err := os.MkdirAll(extractDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "错误: 创建解压目录失败: %v\n", err)
-			os.Exit(1)
+	fmt.Printf("报告目录: %s\n", reportDir)
+
+	// The first app checks the library; every worker translates with its own app, as an
+	// app translates one paper at a time
+	first := newBatchApp()
+	if first.config != nil {
+		fmt.Printf("API Base URL: %s\n", first.config.GetBaseURL())
+		fmt.Printf("Model: %s\n", first.config.GetModel())
+	}
+	checkContextWindowCLI(first)
+
+	report := &batchReport{List: listPath, Total: len(inputs), Papers: []batchPaperReport{}, StartedAt: time.Now()}
+	var pending []string
+	for _, input := range inputs {
+		paper, complete := completedInLibrary(first, input)
+		if !complete {
+			pending = append(pending, input)
+			continue
 		}
+		fmt.Printf("跳过 %s: 结果库中已完成\n", input)
+		report.add(paper)
+		writeBatchPaperReport(reportDir, paper)
+	}
+	report.UpdatedAt = time.Now()
+	statusfile.WriteJSONAtomic(filepath.Join(reportDir, batchReportFile), report)
+	fmt.Printf("共 %d 篇, 已完成 %d 篇, 待翻译 %d 篇\n", len(inputs), len(inputs)-len(pending), len(pending))
 
-		// Extract zip
-		if err := extractZip(bookPath, extractDir); err != nil {
-			fmt.Fprintf(os.Stderr, "错误: 解压失败: %v\n", err)
-			os.Exit(1)
+	apps := []*App{first}
+	for len(apps) < min(max(parallel, 1), len(pending)) {
+		apps = append(apps, newBatchApp())
+	}
+	if len(apps) > 1 {
+		fmt.Printf("同时翻译 %d 篇论文\n", len(apps))
+	}
+
+	// The report is shared by the workers, guarded by mu
+	var mu sync.Mutex
+	saveReport := func(interrupted bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Interrupted = report.Interrupted || interrupted
+		report.UpdatedAt = time.Now()
+		if err := statusfile.WriteJSONAtomic(filepath.Join(reportDir, batchReportFile), report); err != nil {
+			logger.Warn("failed to write batch report", logger.Err(err))
 		}
+	}
 
-		// Find the actual book directory (might be nested)
-		entries, err := os.ReadDir(extractDir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "错误: 读取解压目录失败: %v\n", err)
-			os.Exit(1)
+	// New papers stop being started after the first Ctrl+C, or after a failure without
+	// --continue-on-error
+	stopScheduling := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(stopScheduling) }) }
+	interrupted := false
+	allDone := make(chan struct{})
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for n := 0; ; n++ {
+			select {
+			case <-allDone:
+				return
+			case <-interrupts:
+			}
+			if n == 0 {
+				fmt.Println("\n正在中断: 不再开始新的论文，正在取消进行中的论文并保存报告（再按一次 Ctrl+C 立即退出）...")
+				mu.Lock()
+				interrupted = true
+				mu.Unlock()
+				stop()
+				for _, app := range apps {
+					app.CancelProcess()
+				}
+				continue
+			}
+			saveReport(true)
+			fmt.Fprintf(os.Stderr, "已中断，已完成论文的报告保存在: %s\n", reportDir)
+			os.Exit(130)
 		}
+	}()
 
-		// If there's only one directory, use it
-		if len(entries) == 1 && entries[0].IsDir() {
-			inputDir = filepath.Join(extractDir, entries[0].Name())
-		} else {
-			inputDir = extractDir
+	batchStatus := statusfile.NewBatchWriter(filepath.Join(reportDir, statusfile.BatchFileName), "translate", pending)
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range pending {
+			select {
+			case queue <- i:
+			case <-stopScheduling:
+				return
+			}
 		}
+	}()
 
-		fmt.Printf("解压到: %s\n", inputDir)
+	printMu := &sync.Mutex{}
+	var wg sync.WaitGroup
+	for _, app := range apps {
+		wg.Add(1)
+		go func(app *App) {
+			defer wg.Done()
+			for i := range queue {
+				// A paper handed over just before stopping is not started either
+				select {
+				case <-stopScheduling:
+					continue
+				default:
+				}
+				input := pending[i]
+				printMu.Lock()
+				fmt.Printf("\n[%d/%d] 开始翻译 %s\n", i+1, len(pending), input)
+				printMu.Unlock()
+
+				status := batchStatus.Track(input, filepath.Join(reportDir, "status", batchFileName(input)))
+				paper := translateBatchPaper(app, input, status)
+				writeBatchPaperReport(reportDir, paper)
+				mu.Lock()
+				report.add(paper)
+				mu.Unlock()
+				saveReport(false)
+
+				printMu.Lock()
+				switch paper.State {
+				case batchDone:
+					fmt.Printf("  ✓ %s: %s\n", input, paper.TranslatedPDF)
+				case batchCancelled:
+					fmt.Printf("  - %s: 已取消\n", input)
+				default:
+					fmt.Printf("  ✗ %s: %s\n", input, paper.Error)
+				}
+				printMu.Unlock()
+				if paper.State == batchFailed && !continueOnError {
+					printMu.Lock()
+					fmt.Println("  论文翻译失败，不再开始新的论文（使用 --continue-on-error 继续翻译其余论文）")
+					printMu.Unlock()
+					stop()
+				}
+			}
+		}(app)
 	}
-
-	// Check if directory exists
-	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "错误: 目录不存在: %s\n", inputDir)
+	wg.Wait()
+	close(allDone)
+	mu.Lock()
+	wasInterrupted := interrupted
+	mu.Unlock()
+	saveReport(wasInterrupted)
+
+	// Like cmd/batch_process, the summary counts how far the papers got
+	fmt.Println("\n=== 批量翻译完成 ===")
+	fmt.Printf("成功: %d, 失败: %d, 跳过 (已完成): %d, 已取消: %d, 未开始: %d\n",
+		report.Done, report.Failed, report.Skipped, report.Cancelled, report.NotStarted)
+	fmt.Printf("已下载: %d, 原文已编译: %d, 已翻译: %d, 已生成 PDF: %d\n",
+		report.Downloaded, report.Compiled, report.Translated, report.PDFGenerated)
+	fmt.Printf("消耗 token: %d\n", report.TokensUsed)
+	fmt.Printf("报告: %s\n", filepath.Join(reportDir, batchReportFile))
+
+	switch {
+	case wasInterrupted:
+		os.Exit(130)
+	case report.Failed > 0:
 		os.Exit(1)
 	}
+}
 
-	// Set default output directory if not specified
-	if outputPath == "" {
-		outputPath = filepath.Join("testdata", "output", "book_translated")
+// newBatchApp returns an app set up with the command line options of a --batch or
+// --serve run
+func newBatchApp() *App {
+	app := NewApp()
+	app.startup(context.Background())
+	applySessionFlags(app)
+	return app
+}
+
+// readBatchList reads the inputs of a --batch list: one arXiv ID or URL per line. Blank
+// lines, lines starting with # and repeated inputs are skipped.
+func readBatchList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var inputs []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") && !seen[line] {
+			seen[line] = true
+			inputs = append(inputs, line)
+		}
+	}
+	return inputs, scanner.Err()
+}
+
+// batchFileName returns the name of the report and status files of a paper
+func batchFileName(input string) string {
+	name := results.ExtractArxivID(input)
+	if name == "" {
+		name = filepath.Base(input)
+	}
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name) + ".json"
+}
+
+// writeBatchPaperReport writes the report of a paper into the report directory
+func writeBatchPaperReport(reportDir string, paper batchPaperReport) {
+	if err := statusfile.WriteJSONAtomic(filepath.Join(reportDir, batchFileName(paper.Input)), paper); err != nil {
+		logger.Warn("failed to write paper report", logger.String("input", paper.Input), logger.Err(err))
+	}
+}
+
+// completedInLibrary returns the report of a paper whose translation is complete in the
+// results library, as ProcessSourceWithForce would return it instead of translating again
+func completedInLibrary(app *App, input string) (batchPaperReport, bool) {
+	existing, err := app.CheckExistingTranslation(input)
+	if err != nil || existing == nil || !existing.Exists || !existing.IsComplete || existing.PaperInfo == nil {
+		return batchPaperReport{}, false
+	}
+	info := existing.PaperInfo
+	if app.variantMismatch(info) || app.replacesQuickTranslation(info) {
+		return batchPaperReport{}, false
+	}
+	now := time.Now()
+	return batchPaperReport{
+		Input:         input,
+		ArxivID:       info.ArxivID,
+		State:         batchSkipped,
+		Downloaded:    true,
+		Compiled:      info.OriginalPDF != "",
+		Translated:    true,
+		PDFGenerated:  info.TranslatedPDF != "",
+		OriginalPDF:   info.OriginalPDF,
+		TranslatedPDF: info.TranslatedPDF,
+		BilingualPDF:  info.BilingualPDF,
+		StartedAt:     now,
+		FinishedAt:    now,
+	}, true
+}
+
+// translateBatchPaper translates one paper of a --batch run with app, keeping its status
+// file up to date, and returns its report
+func translateBatchPaper(app *App, input string, status *statusfile.Writer) batchPaperReport {
+	paper := batchPaperReport{Input: input, ArxivID: results.ExtractArxivID(input), StartedAt: time.Now()}
+
+	// The phases the paper went through tell how far it got when it fails
+	var mu sync.Mutex
+	reached := make(map[types.ProcessPhase]bool)
+	lastPhase := types.PhaseIdle
+	app.SetStatusCallback(func(s *types.Status) {
+		if s.Phase == types.PhaseError {
+			return
+		}
+		mu.Lock()
+		reached[s.Phase] = true
+		lastPhase = s.Phase
+		mu.Unlock()
+	})
+	defer app.SetStatusCallback(nil)
+
+	// The tokens of the app's translator add up over the papers it translated
+	tokensBefore := 0
+	if app.translator != nil {
+		tokensBefore = app.translator.Progress().TokensUsed
+	}
+	poll := func(s *statusfile.Status) {
+		fillStatusFromApp(app, s)
+		s.TokensUsed = max(s.TokensUsed-tokensBefore, 0)
+	}
+	status.Start(statusfile.DefaultInterval, poll)
+	result, err := app.ProcessSource(input)
+	status.Update(poll)
+	status.Finish(err)
+	paper.TokensUsed = status.Snapshot().TokensUsed
+	paper.FinishedAt = time.Now()
+
+	mu.Lock()
+	defer mu.Unlock()
+	paper.Downloaded = reached[types.PhaseExtracting]
+	paper.Compiled = reached[types.PhaseTranslating] && !app.IsNoCompileMode()
+	paper.Translated = reached[types.PhaseValidating]
+	if err != nil {
+		paper.State = batchFailed
+		if appErrorCode(err) == string(types.ErrCancelled) {
+			paper.State = batchCancelled
+		}
+		paper.FailedPhase = string(lastPhase)
+		paper.Error = err.Error()
+		paper.ErrorCode = appErrorCode(err)
+		return paper
+	}
+
+	paper.State = batchDone
+	paper.Downloaded, paper.Translated = true, true
+	if result.SourceID != "" {
+		paper.ArxivID = result.SourceID
+	}
+	paper.PDFGenerated = !result.Uncompiled && result.TranslatedPDFPath != ""
+	paper.OriginalPDF = result.OriginalPDFPath
+	paper.TranslatedPDF = result.TranslatedPDFPath
+	paper.BilingualPDF = result.BilingualPDFPath
+	paper.TranslatedTex = result.TranslatedTexPath
+	return paper
+}
+
+// appJobRunner runs the jobs of --serve with an app of its own, as an app translates one
+// paper at a time
+type appJobRunner struct {
+	app     *App
+	running atomic.Bool
+}
+
+// Run translates input with ProcessSource, feeding the status changes of the app to onStatus
+func (r *appJobRunner) Run(input string, onStatus func(*types.Status)) (*types.ProcessResult, error) {
+	r.running.Store(true)
+	defer r.running.Store(false)
+	r.app.SetStatusCallback(onStatus)
+	defer r.app.SetStatusCallback(nil)
+	return r.app.ProcessSource(input)
+}
+
+// Cancel cancels the running job with CancelProcess. A job that has not set up its
+// cancellation yet is tried again until it has, or has returned.
+func (r *appJobRunner) Cancel() {
+	for r.running.Load() {
+		if r.app.CancelProcess() == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// runServeCLI serves the job API of --serve on addr until Ctrl+C or SIGTERM, translating up
+// to jobs papers at a time. Clients may submit zip files by path only from pathDir, when
+// set. Stopping cancels the queued and running jobs; cancelled papers are saved to the
+// results library like in the GUI and can be continued later.
+func runServeCLI(addr, token string, jobs int, pathDir string) {
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-serve.log",
+		Level:         logger.LevelInfo,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	fmt.Println("=== 翻译服务 (HTTP API) ===")
+	runners := make([]jobserver.Runner, max(jobs, 1))
+	for i := range runners {
+		app := newBatchApp()
+		if i == 0 {
+			if app.config != nil {
+				fmt.Printf("API Base URL: %s\n", app.config.GetBaseURL())
+				fmt.Printf("Model: %s\n", app.config.GetModel())
+			}
+			checkContextWindowCLI(app)
+		}
+		runners[i] = &appJobRunner{app: app}
+	}
+	server := jobserver.New(runners, token)
+	if pathDir != "" {
+		if err := server.AllowLocalPaths(pathDir); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: --serve-allow-paths: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 无法监听 %s: %v\n", addr, err)
+		os.Exit(1)
+	}
+	httpServer := &http.Server{Handler: server.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.Serve(listener) }()
+
+	fmt.Printf("监听地址: http://%s\n", listener.Addr())
+	fmt.Printf("同时翻译: %d 篇\n", len(runners))
+	if pathDir != "" {
+		fmt.Printf("允许提交的 zip 目录: %s\n", pathDir)
+	}
+	if token == "" {
+		fmt.Println("⚠ 未设置访问令牌 (--serve-token 或 LT_SERVE_TOKEN), 任何能连接到该地址的人都可以提交任务和下载结果")
+	}
+	fmt.Println("按 Ctrl+C 停止服务")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	select {
+	case <-signals:
+	case err := <-serveErr:
+		fmt.Fprintf(os.Stderr, "错误: HTTP 服务异常退出: %v\n", err)
+	}
+
+	fmt.Println("\n正在停止服务: 不再接收新任务，正在取消排队和进行中的任务...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Warn("failed to shut down the HTTP server", logger.Err(err))
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "警告: 等待任务取消超时")
+		os.Exit(1)
+	}
+	fmt.Println("服务已停止")
+}
+
+// confirmJobCLI prepares a job, prints its summary and asks whether to translate it. It
+// returns the job ID to confirm and exits when the user declines or the job cannot run.
+func confirmJobCLI(app *App, input string) string {
+	fmt.Println("正在获取论文信息并下载源码...")
+	summary, err := app.PrepareJob(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println("=== 翻译确认 ===")
+	if summary.ArxivID != "" {
+		fmt.Printf("arXiv ID: %s\n", summary.ArxivID)
+	}
+	fmt.Printf("标题: %s\n", summary.Title)
+	if len(summary.Authors) > 0 {
+		fmt.Printf("作者: %s\n", strings.Join(summary.Authors, ", "))
+	}
+	if summary.PrimaryCategory != "" {
+		fmt.Printf("分类: %s\n", strings.Join(summary.Categories, ", "))
+	}
+	if summary.Published != "" {
+		fmt.Printf("提交日期: %s (最新版本 %s, 共 %d 个版本)\n", summary.Published, summary.Updated, summary.Versions)
+	}
+	if summary.ArxivID != "" {
+		license := summary.License
+		if license == "" {
+			license = "未知"
+		}
+		fmt.Printf("许可证: %s\n", license)
+	}
+	if summary.JobID != "" {
+		fmt.Printf("源码: %s, %d 个 tex 文件, %.1f MB\n", summary.MainTexFile, summary.TexFiles, float64(summary.SourceSize)/(1<<20))
+		fmt.Printf("预计消耗: 约 %d token (%d 个分块), 约 %d 分钟\n", summary.EstimatedTokens, summary.TotalChunks, summary.EstimatedMinutes)
+	}
+	for _, flag := range summary.RedFlags {
+		fmt.Printf("⚠ %s\n", flag)
+	}
+	if summary.JobID == "" {
+		fmt.Fprintln(os.Stderr, "无法翻译该输入")
+		os.Exit(1)
+	}
+
+	fmt.Print("开始翻译? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		app.DiscardJob(summary.JobID)
+		fmt.Println("已取消")
+		os.Exit(0)
+	}
+	return summary.JobID
+}
+
+// stdinIsTerminal reports whether standard input is an interactive terminal
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// reviewFixCLI prints the diff of a risky compile fix and asks whether to apply it.
+// Without an answer the fix is rejected and the next fix level is tried.
+func reviewFixCLI(proposal compiler.FixProposal) bool {
+	fmt.Println()
+	fmt.Printf("=== 待确认的修复: %s ===\n", proposal.File)
+	if proposal.Description != "" {
+		fmt.Printf("说明: %s\n", proposal.Description)
+	}
+	fmt.Printf("风险: %s\n", strings.Join(proposal.Reasons, "；"))
+	fmt.Println(proposal.Diff)
+	fmt.Print("应用该修复? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// statusFilePath returns the path of the status JSON file: --status-file if given,
+// otherwise status.json in dir
+func statusFilePath(dir string) string {
+	if *statusFile != "" {
+		return *statusFile
+	}
+	return filepath.Join(dir, statusfile.FileName)
+}
+
+// fillStatusFromApp copies the app's processing status into a status file
+func fillStatusFromApp(app *App, s *statusfile.Status) {
+	status := app.GetStatus()
+	s.Phase = string(status.Phase)
+	s.Percent = status.Progress
+	s.Message = status.Message
+	if status.Error != "" {
+		s.Error = status.Error
+	}
+	if status.Translation != nil && status.Translation.TotalFiles > 1 {
+		s.File = status.Translation.File
+	}
+	if app.translator != nil {
+		progress := app.translator.Progress()
+		s.Section = progress.Section
+		s.Chunk = progress.Chunk
+		s.TotalChunks = progress.TotalChunks
+		s.TokensUsed = progress.TokensUsed
+	}
+	s.Warnings = app.GetWarnings()
+	if s.Warnings == nil {
+		s.Warnings = []string{}
+	}
+}
+
+// appErrorCode returns the code of an AppError, for the error event of --progress-format
+// json, or "" for other errors
+func appErrorCode(err error) string {
+	var appErr *types.AppError
+	if errors.As(err, &appErr) {
+		return string(appErr.Code)
+	}
+	return ""
+}
+
+// processOutputs returns the output paths of a processed paper by kind, for the complete
+// event of --progress-format json
+func processOutputs(result *types.ProcessResult, workDir string) map[string]string {
+	outputs := map[string]string{"work_dir": workDir}
+	for kind, path := range map[string]string{
+		"original_pdf":    result.OriginalPDFPath,
+		"translated_pdf":  result.TranslatedPDFPath,
+		"bilingual_pdf":   result.BilingualPDFPath,
+		"translated_tex":  result.TranslatedTexPath,
+		"translated_html": result.TranslatedHTMLPath,
+		"quality_report":  result.QualityReportPath,
+	} {
+		if path != "" {
+			outputs[kind] = path
+		}
+	}
+	return outputs
+}
+
+// runChunkPreviewCLI prints how a paper will be split into translation chunks
+func runChunkPreviewCLI(input string) {
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-cli.log",
+		Level:         logger.LevelWarn,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	app := NewApp()
+	app.startup(context.Background())
+	app.SetFileOverrides(decisions.ParseList(*translateList), decisions.ParseList(*copyList))
+	app.UseMainTexFile(*mainTexFlag)
+
+	preview, err := app.PreviewChunking(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 分块预览失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("=== 翻译分块预览 ===")
+	fmt.Printf("源码目录: %s\n", preview.ExtractDir)
+	fmt.Printf("主文件: %s\n", filepath.ToSlash(preview.MainTexFile))
+	for _, file := range preview.Files {
+		fmt.Println()
+		if file.Skipped {
+			fmt.Printf("%s (%d 字节): 原样复制 [%s], %s\n", filepath.ToSlash(file.File), file.Size, file.Decision.Rule, file.SkipReason)
+			fmt.Printf("  %s\n", formatFileMetrics(file.Decision.Metrics))
+			fmt.Printf("  改为翻译: %s\n", file.Decision.Override)
+			continue
+		}
+		fmt.Printf("%s (%d 字节, %d 个分块) [%s]\n", filepath.ToSlash(file.File), file.Size, len(file.Chunks), file.Decision.Rule)
+		fmt.Printf("  %s\n", formatFileMetrics(file.Decision.Metrics))
+		if len(file.SkippedDataBlobs) > 0 {
+			fmt.Printf("  %s\n", translator.FormatDataBlobSummary(file.SkippedDataBlobs))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  #\t章节\t字节范围\t大小\t估算 token\t受保护环境\t开头 / 结尾")
+		for _, chunk := range file.Chunks {
+			envs := strings.Join(chunk.Environments, ",")
+			if envs == "" {
+				envs = "-"
+			}
+			section := chunk.Section
+			if section == "" {
+				section = "-"
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%d-%d\t%d\t%d\t%s\t%s\n", chunk.Index, section, chunk.Start, chunk.End, chunk.End-chunk.Start,
+				chunk.EstimatedTokens, envs, previewLine(chunk.Head))
+			fmt.Fprintf(w, "\t\t\t\t\t\t%s\n", previewLine(chunk.Tail))
+		}
+		w.Flush()
+	}
+
+	fmt.Println()
+	fmt.Printf("共 %d 个分块, 估算输入 %d token\n", preview.TotalChunks, preview.EstimatedTokens)
+}
+
+// runEstimateCLI prints the estimated chunks, tokens and translation time of a paper
+// without calling the model
+func runEstimateCLI(input string) {
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-cli.log",
+		Level:         logger.LevelWarn,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	app := NewApp()
+	app.startup(context.Background())
+	if *variantFlag != "" {
+		app.UseChineseVariant(*variantFlag)
+	}
+	if *langFlag != "" {
+		app.UseTargetLanguage(*langFlag)
+	}
+	app.SetQuickMode(*quickFlag)
+	app.SetFileOverrides(decisions.ParseList(*translateList), decisions.ParseList(*copyList))
+	app.UseMainTexFile(*mainTexFlag)
+
+	estimate, err := app.EstimateSource(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 估算失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("=== 翻译消耗估算 ===")
+	fmt.Printf("源码目录: %s\n", estimate.ExtractDir)
+	fmt.Printf("主文件: %s\n", filepath.ToSlash(estimate.MainTexFile))
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "文件\t分块\t输入 token\t输出 token\t耗时\t说明")
+	for _, file := range estimate.Files {
+		note := "-"
+		switch {
+		case file.Decision.Decision != types.FileDecisionTranslate:
+			note = fmt.Sprintf("不翻译 [%s]", file.Decision.Rule)
+		case file.Estimate.MostlyCode:
+			note = "主要是代码或绘图, 不发送给模型"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", filepath.ToSlash(file.File), file.Estimate.Chunks,
+			file.Estimate.InputTokens, file.Estimate.OutputTokens, formatEstimatedDuration(file.Estimate.EstimatedSeconds), note)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("共 %d 个分块, 估算输入 %d token, 输出 %d token, 翻译约 %s (并发 %d, 不含编译)\n",
+		estimate.Total.Chunks, estimate.Total.InputTokens, estimate.Total.OutputTokens,
+		formatEstimatedDuration(estimate.Total.EstimatedSeconds), estimate.Concurrency)
+}
+
+// runLibraryCLI lists, deletes, exports or imports the papers of the results library:
+// library list [--query Q] [--page N] [--page-size N], library delete ID...,
+// library export ZIP [ID...] or library import ZIP [--overwrite]
+func runLibraryCLI(args []string) {
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-cli.log",
+		Level:         logger.LevelWarn,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	if len(args) == 0 || (args[0] != "list" && args[0] != "delete" && args[0] != "export" && args[0] != "import") {
+		fmt.Fprintln(os.Stderr, "用法: latex-translator library list [--query <Q>] [--page <N>] [--page-size <N>]")
+		fmt.Fprintln(os.Stderr, "      latex-translator library delete <ID>...")
+		fmt.Fprintln(os.Stderr, "      latex-translator library export <ZIP> [ID...]")
+		fmt.Fprintln(os.Stderr, "      latex-translator library import <ZIP> [--overwrite]")
+		os.Exit(1)
+	}
+	app := newLibraryApp()
+
+	if args[0] == "delete" {
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "错误: library delete 需要至少一个 arXiv ID")
+			os.Exit(1)
+		}
+		failed := false
+		for _, id := range args[1:] {
+			if err := app.DeleteLibraryPaper(id); err != nil {
+				fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+				failed = true
+				continue
+			}
+			fmt.Printf("已删除: %s\n", id)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "export" {
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "错误: library export 需要 zip 文件路径")
+			os.Exit(1)
+		}
+		manifest, err := app.ExportLibraryTo(args[1], args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("已导出 %d 篇论文到: %s\n", len(manifest.Papers), args[1])
+		return
+	}
+
+	if args[0] == "import" {
+		fs := flag.NewFlagSet("library import", flag.ExitOnError)
+		overwrite := fs.Bool("overwrite", false, "Replace papers already in the library")
+		// The archive may come before or after the flags
+		var zipPath string
+		if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+			zipPath = args[1]
+			fs.Parse(args[2:])
+		} else {
+			fs.Parse(args[1:])
+			zipPath = fs.Arg(0)
+		}
+		if zipPath == "" {
+			fmt.Fprintln(os.Stderr, "错误: library import 需要 zip 文件路径")
+			os.Exit(1)
+		}
+		report, err := app.ImportLibraryFrom(zipPath, *overwrite)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		statusNames := map[string]string{results.ImportImported: "已导入", results.ImportSkipped: "已存在, 跳过", results.ImportFailed: "失败"}
+		for _, r := range report.Results {
+			line := fmt.Sprintf("%s  %s  %s", statusNames[r.Status], r.ArxivID, previewLine(r.Title))
+			if r.Error != "" {
+				line += ": " + r.Error
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("\n导入 %d 篇, 跳过 %d 篇, 失败 %d 篇\n", report.Imported, report.Skipped, report.Failed)
+		if report.Failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fs := flag.NewFlagSet("library list", flag.ExitOnError)
+	query := fs.String("query", "", "Title substring or arXiv ID to search for")
+	page := fs.Int("page", 1, "Page to list, starting at 1")
+	pageSize := fs.Int("page-size", 0, "Papers per page (0 = page size from settings)")
+	fs.Parse(args[1:])
+
+	library, err := app.GetLibraryPapers(*page, *pageSize, *query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	stats, err := app.GetLibraryStats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t标题\t状态\t翻译时间\t大小")
+	for _, p := range library.Papers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.ArxivID, previewLine(p.Title), p.Status,
+			p.TranslatedAt.Format("2006-01-02 15:04"), formatDiskSize(p.SizeBytes))
+	}
+	w.Flush()
+
+	pages := max((library.Total+library.PageSize-1)/library.PageSize, 1)
+	fmt.Printf("\n第 %d/%d 页, 匹配 %d 篇; 结果库共 %d 篇 (完成 %d 篇), 占用 %s\n",
+		library.Page, pages, library.Total, stats.TotalPapers, stats.CompletePapers, formatDiskSize(stats.DiskUsage))
+}
+
+// newLibraryApp returns an app with only the settings and the results store, which is all
+// the library subcommands need; it exits when they cannot be opened
+func newLibraryApp() *App {
+	app := NewApp()
+	configMgr, err := config.NewConfigManager("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 无法加载设置: %v\n", err)
+		os.Exit(1)
+	}
+	if err := configMgr.Load(); err != nil {
+		logger.Warn("failed to load config, using defaults", logger.Err(err))
+	}
+	app.config = configMgr
+	if app.results, err = results.NewResultManager(""); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 无法打开结果库: %v\n", err)
+		os.Exit(1)
+	}
+	return app
+}
+
+// runUsageCLI prints the token usage and estimated cost recorded in the results library:
+// usage [--since DATE|Nd] [--json]
+func runUsageCLI(args []string) {
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-cli.log",
+		Level:         logger.LevelWarn,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	sinceFlag := fs.String("since", "", "Only papers translated since this date (2006-01-02) or this many days (30d)")
+	jsonOut := fs.Bool("json", false, "Print the statistics as JSON")
+	fs.Parse(args)
+
+	since, err := parseUsageSince(*sinceFlag, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	app := newLibraryApp()
+	stats, err := app.GetUsageStats(since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(stats)
+		return
+	}
+
+	if since.IsZero() {
+		fmt.Println("token 用量 (全部):")
+	} else {
+		fmt.Printf("token 用量 (自 %s 起):\n", since.Format("2006-01-02"))
+	}
+	fmt.Printf("  论文:     %d 篇\n", stats.Papers)
+	fmt.Printf("  输入:     %d token\n", stats.InputTokens)
+	fmt.Printf("  输出:     %d token\n", stats.OutputTokens)
+	fmt.Printf("  合计:     %d token\n", stats.TotalTokens)
+	fmt.Printf("  估算费用: $%.4f\n", stats.EstimatedCost)
+	fmt.Printf("  平均每篇: %d token, $%.4f\n", stats.AvgTokensPerPaper, stats.AvgCostPerPaper)
+	if len(stats.Models) == 0 {
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "模型\t论文\t输入 token\t输出 token\t估算费用")
+	for _, m := range stats.Models {
+		model := m.Model
+		if model == "" {
+			model = "(未知)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t$%.4f\n", model, m.Papers, m.InputTokens, m.OutputTokens, m.EstimatedCost)
+	}
+	w.Flush()
+	fmt.Println()
+	fmt.Println("费用按内置价格表估算 (美元), 价格未知的模型计为 0; 可在设置文件的 model_prices 中修正")
+}
+
+// parseUsageSince parses the --since value of the usage subcommand: a date (2006-01-02)
+// or a number of days before now (30d). Empty means no limit.
+func parseUsageSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	since, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的 --since: %s (应为 2006-01-02 或 30d 这样的天数)", value)
+	}
+	return since, nil
+}
+
+// runCleanCLI removes the least recently used paper directories of the work directory by
+// the cleanup policy of the settings:
+// clean [--keep N] [--max-age-days N] [--max-size-gb N] [--work-dir DIR] [--dry-run] [--list] [--json]
+func runCleanCLI(args []string) {
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-cli.log",
+		Level:         logger.LevelWarn,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	keep := fs.Int("keep", -1, "Keep at most this many paper directories (0 = no limit, default: the setting)")
+	maxAge := fs.Int("max-age-days", -1, "Remove directories unused for this many days (0 = no limit, default: the setting)")
+	maxSize := fs.Float64("max-size-gb", -1, "Total size cap of the paper directories in GB (0 = no limit, default: the setting)")
+	workDirFlag := fs.String("work-dir", "", "Work directory to clean (default: the setting)")
+	dryRun := fs.Bool("dry-run", false, "Only list the directories that would be removed")
+	list := fs.Bool("list", false, "Print the disk usage of the work directory instead of cleaning it")
+	jsonOut := fs.Bool("json", false, "Print the result as JSON")
+	fs.Parse(args)
+
+	app := newLibraryApp()
+	app.workDir = *workDirFlag
+	if app.workDir == "" {
+		app.workDir = app.config.GetWorkDirectory()
+	}
+	if app.workDir == "" {
+		fmt.Fprintln(os.Stderr, "错误: 设置中没有工作目录 (使用临时目录时退出即删除), 请用 --work-dir 指定")
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if *list {
+		usage, err := app.GetWorkspaceUsage()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		if *jsonOut {
+			enc.Encode(usage)
+			return
+		}
+		fmt.Printf("工作目录: %s\n", usage.Root)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "论文\t大小\t最近使用\t")
+		for _, paper := range usage.Papers {
+			mark := ""
+			if paper.Protected {
+				mark = "保留 (未完成)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", paper.Name, formatDiskSize(paper.SizeBytes), formatWorkspaceTime(paper.LastUsed), mark)
+		}
+		w.Flush()
+		fmt.Printf("论文目录 %d 个, 共 %s; 其他文件 %s; 合计 %s\n", len(usage.Papers),
+			formatDiskSize(usage.PapersSize), formatDiskSize(usage.OtherSize), formatDiskSize(usage.TotalSize))
+		return
+	}
+
+	policy := app.GetWorkspacePolicy()
+	if *keep >= 0 {
+		policy.KeepRecent = *keep
+	}
+	if *maxAge >= 0 {
+		policy.MaxAgeDays = *maxAge
+	}
+	if *maxSize >= 0 {
+		policy.MaxSizeGB = *maxSize
+	}
+	result, err := app.cleanWorkspaces(policy, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if *jsonOut {
+		enc.Encode(result)
+	} else {
+		verb := "已删除"
+		if result.DryRun {
+			verb = "将删除"
+		}
+		for _, paper := range result.Removed {
+			fmt.Printf("%s: %s (%s, 最近使用 %s)\n", verb, paper.Name, formatDiskSize(paper.SizeBytes), formatWorkspaceTime(paper.LastUsed))
+		}
+		for _, message := range result.Errors {
+			fmt.Fprintf(os.Stderr, "删除失败: %s\n", message)
+		}
+		fmt.Printf("%s %d 个论文目录, 释放 %s, 保留 %d 个\n", verb, len(result.Removed), formatDiskSize(result.FreedBytes), result.Kept)
+	}
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// formatWorkspaceTime formats the RFC 3339 last use of a paper directory in local time
+func formatWorkspaceTime(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// formatDiskSize formats a number of bytes in KB, MB or GB
+func formatDiskSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// formatEstimatedDuration formats an estimated number of seconds as minutes and seconds
+func formatEstimatedDuration(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d 秒", seconds)
+	}
+	return fmt.Sprintf("%d 分 %d 秒", seconds/60, seconds%60)
+}
+
+// formatFileMetrics formats the metrics a file decision is based on
+func formatFileMetrics(m types.FileMetrics) string {
+	return fmt.Sprintf("正文 %d 行, 命令定义 %d 行, 表格 %d 行, 代码比例 %.0f%%, 中日韩文字比例 %.0f%%",
+		m.ProseLines, m.CommandLines, m.TableLines, m.CodeRatio*100, m.CJKRatio*100)
+}
+
+// previewLine collapses whitespace so a chunk excerpt fits on one table row
+func previewLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// runBookTranslationCLI runs book translation in CLI mode without GUI
+func runBookTranslationCLI(bookPath, outputPath string, maxFiles int) {
+	// Initialize logger with console output for CLI mode
+	logger.Init(&logger.Config{
+		LogFilePath:   "latex-translator-book.log",
+		Level:         logger.LevelInfo,
+		EnableConsole: true,
+	})
+	defer logger.Close()
+
+	fmt.Println("=== LaTeX 书籍翻译 (CLI 模式) ===")
+	fmt.Printf("输入: %s\n", bookPath)
+
+	// Load configuration
+	configMgr, err := config.NewConfigManager("latex-translator-config.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 无法加载配置: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := configMgr.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get API key from config or environment
+	// The diff report sends no request
+	apiKey := configMgr.GetAPIKey()
+	if apiKey == "" && !*diffReport {
+		fmt.Fprintf(os.Stderr, "错误: API 密钥未配置\n")
+		fmt.Fprintf(os.Stderr, "请在配置文件中设置 API 密钥: latex-translator-config.json\n")
+		fmt.Fprintf(os.Stderr, "或设置环境变量:\n")
+		fmt.Fprintf(os.Stderr, "  Windows CMD: set OPENAI_API_KEY=your-key\n")
+		fmt.Fprintf(os.Stderr, "  PowerShell:  $env:OPENAI_API_KEY=\"your-key\"\n")
+		os.Exit(1)
+	}
+
+	// Get API configuration
+	baseURL := configMgr.GetBaseURL()
+	model := configMgr.GetModel()
+
+	fmt.Printf("API Base URL: %s\n", baseURL)
+	fmt.Printf("Model: %s\n", model)
+
+	// The command line overrides the configured script
+	variant := configMgr.GetChineseVariant()
+	if *variantFlag != "" {
+		variant, _ = types.ParseChineseVariant(*variantFlag)
+	}
+	lang := configMgr.GetTargetLanguage()
+	if *langFlag != "" {
+		lang, _ = types.ParseTargetLanguage(*langFlag)
+	}
+	fmt.Printf("译文语言: %s\n", lang.DisplayName())
+	if lang.IsChinese() {
+		fmt.Printf("译文字形: %s\n", variant.DisplayName())
+	}
+
+	// Check the compiler before spending tokens on the translation; Japanese needs
+	// LuaLaTeX for luatexja
+	bookCompiler := strings.ToLower(strings.TrimSpace(*compilerFlag))
+	if bookCompiler == "" {
+		bookCompiler = compiler.CompilerXeLaTeX
+		if lang == types.LanguageJapanese {
+			bookCompiler = compiler.CompilerLuaLaTeX
+		}
+	}
+	if *compileBook {
+		switch bookCompiler {
+		case compiler.CompilerXeLaTeX, compiler.CompilerLuaLaTeX, compiler.CompilerPDFLaTeX:
+		default:
+			fmt.Fprintf(os.Stderr, "错误: 不支持的编译器 %q (可选: xelatex, lualatex, pdflatex)\n", *compilerFlag)
+			os.Exit(1)
+		}
+		fmt.Printf("编译器: %s\n", bookCompiler)
+	}
+
+	// Determine input directory
+	inputDir := bookPath
+
+	// If it's a zip file, extract it first
+	if strings.HasSuffix(strings.ToLower(bookPath), ".zip") {
+		fmt.Println("正在解压 ZIP 文件...")
+		extractDir := strings.TrimSuffix(bookPath, filepath.Ext(bookPath)) + "_extracted"
+
+		// Create extract directory
+		if err := os.MkdirAll(extractDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 创建解压目录失败: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Extract zip
+		if err := downloader.ExtractZipArchive(bookPath, extractDir); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 解压失败: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Find the actual book directory (might be nested)
+		entries, err := os.ReadDir(extractDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 读取解压目录失败: %v\n", err)
+			os.Exit(1)
+		}
+
+		// If there's only one directory, use it
+		if len(entries) == 1 && entries[0].IsDir() {
+			inputDir = filepath.Join(extractDir, entries[0].Name())
+		} else {
+			inputDir = extractDir
+		}
+
+		fmt.Printf("解压到: %s\n", inputDir)
+	}
+
+	// Check if directory exists
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "错误: 目录不存在: %s\n", inputDir)
+		os.Exit(1)
+	}
+
+	// Set default output directory if not specified
+	if outputPath == "" {
+		outputPath = filepath.Join("testdata", "output", "book_translated")
 	}
 
 	fmt.Printf("输出目录: %s\n", outputPath)
@@ -509,44 +2170,153 @@ func runBookTranslationCLI(bookPath, outputPath string, maxFiles int) {
 		os.Exit(1)
 	}
 
-	// Find all .tex files
-	fmt.Println("\n正在扫描 LaTeX 文件...")
-	texFiles, err := findTexFiles(inputDir)
+	// Find all .tex files
+	fmt.Println("\n正在扫描 LaTeX 文件...")
+	texFiles, err := findTexFiles(inputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 扫描文件失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("找到 %d 个 .tex 文件\n", len(texFiles))
+
+	// Limit files if specified
+	if maxFiles > 0 && len(texFiles) > maxFiles {
+		fmt.Printf("限制为前 %d 个文件\n", maxFiles)
+		texFiles = texFiles[:maxFiles]
+	}
+
+	// The prompt template is checked before any request; a broken one falls back to the default
+	preset := *promptPreset
+	if preset == "" {
+		preset = configMgr.GetPromptPreset()
+	} else if _, err := translator.PromptPresetTemplate(preset); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	prompt, err := translator.LoadPromptTemplate(preset, configMgr.GetPromptTemplatePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+	if prompt.Name != translator.PromptPresetDefault {
+		fmt.Printf("提示词预设: %s\n", prompt.Name)
+	}
+
+	// The manifest of the output directory tells which files changed since their translation
+	manifest, err := bookmanifest.Open(outputPath, model, prompt.Name, *staleModel)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "错误: 扫描文件失败: %v\n", err)
+		fmt.Fprintf(os.Stderr, "错误: 无法读取翻译清单 %s: %v\n", filepath.Join(outputPath, bookmanifest.FileName), err)
 		os.Exit(1)
 	}
+	if *diffReport {
+		printBookDiffReport(inputDir, outputPath, texFiles, manifest)
+		return
+	}
 
-	fmt.Printf("找到 %d 个 .tex 文件\n", len(texFiles))
+	// A book already written in Chinese is not translated to Chinese unless forced
+	if lang == types.LanguageChinese {
+		contents := make([]string, 0, len(texFiles))
+		for _, texFile := range texFiles {
+			if content, err := os.ReadFile(texFile); err == nil {
+				contents = append(contents, string(content))
+			}
+		}
+		sourceLang := translator.DetectSourceLanguage(contents...)
+		logger.Info("source language detected",
+			logger.Float64("cjkRatio", sourceLang.CJKRatio),
+			logger.Int("letters", sourceLang.Letters),
+			logger.Bool("alreadyChinese", sourceLang.Chinese()),
+			logger.Bool("force", *forceFlag))
+		if sourceLang.Chinese() {
+			if !*forceFlag {
+				fmt.Fprintf(os.Stderr, "错误: %v\n", translator.AlreadyChineseError(sourceLang))
+				os.Exit(1)
+			}
+			fmt.Printf("源文档似乎已是中文（中文字符占比 %.0f%%），按 --force-translate 继续翻译\n", sourceLang.CJKRatio*100)
+		}
+	}
 
-	// Limit files if specified
-	if maxFiles > 0 && len(texFiles) > maxFiles {
-		fmt.Printf("限制为前 %d 个文件\n", maxFiles)
-		texFiles = texFiles[:maxFiles]
+	// Machine-readable progress for wrapper scripts
+	statusWriter := statusfile.NewWriter(statusFilePath(outputPath), "book", bookPath)
+	fmt.Printf("状态文件: %s\n", statusWriter.Path())
+	progressEvents.Follow(statusWriter)
+
+	// The command line overrides the configured concurrency
+	jobs := configMgr.GetConcurrency()
+	if *jobsFlag > 0 {
+		jobs = *jobsFlag
+	}
+
+	// One glossary for the whole book, so a term keeps its translation across files
+	glossary := translator.NewGlossary()
+	glossaryPath := *glossaryFlag
+	if glossaryPath == "" {
+		glossaryPath = configMgr.GetGlossaryPath()
+	}
+	if glossaryPath != "" {
+		if glossary, err = translator.LoadGlossary(glossaryPath); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("术语表: %s (%d 个术语)\n", glossaryPath, glossary.Len())
 	}
 
 	// Translate the book
-	if err := translateBook(inputDir, outputPath, apiKey, baseURL, model, texFiles); err != nil {
-		fmt.Fprintf(os.Stderr, "\n错误: 翻译失败: %v\n", err)
-		os.Exit(1)
+	err = translateBook(inputDir, outputPath, apiKey, baseURL, model, texFiles, jobs, lang, variant, configMgr.GetChineseVariantPhrases(), configMgr.GetIndexSortKeys(), configMgr.GetTranslateComments(), configMgr.GetTranslateBibliography(), configMgr.GetChunkOverlap(), configMgr.GetRequestShape(), configMgr.GetProvider(),
+		glossary, prompt, decisions.NewOverrides(decisions.ParseList(*translateList), decisions.ParseList(*copyList)), manifest, statusWriter)
+
+	// An interrupted book is not compiled; running the command again continues it
+	if errors.Is(err, errBookInterrupted) {
+		statusWriter.Finish(err)
+		progressEvents.Fail(statusWriter.Snapshot(), err, string(types.ErrCancelled), 130)
+		fmt.Fprintf(os.Stderr, "\n翻译已中断，已完成的译文保留在: %s\n", outputPath)
+		fmt.Println("再次运行相同的命令会跳过已翻译的文件")
+		os.Exit(130)
 	}
 
-	fmt.Println("\n=== 翻译完成 ===")
-	fmt.Printf("输出目录: %s\n", outputPath)
-}
+	// Compile even when some files failed: their originals are compiled instead
+	var compilation bookCompilation
+	if *compileBook {
+		statusWriter.Stop()
+		statusWriter.Update(func(s *statusfile.Status) {
+			s.Phase = string(types.PhaseCompiling)
+			s.Message = "编译译文"
+			s.File = ""
+		})
+		statusWriter.Flush()
+		compilation = compileTranslatedBook(inputDir, outputPath, bookCompiler, *autoInstall || configMgr.GetAutoInstallPackages(), postprocess.Options{Variant: variant, Language: lang, Engine: bookCompiler})
+		if compilation.Err != nil {
+			statusWriter.Warn(fmt.Sprintf("编译失败: %v", compilation.Err))
+		}
+	}
+
+	finishErr := err
+	if finishErr == nil {
+		finishErr = compilation.Err
+	}
+	statusWriter.Finish(finishErr)
+	if finishErr != nil {
+		progressEvents.Fail(statusWriter.Snapshot(), finishErr, appErrorCode(finishErr), 1)
+	} else {
+		outputs := map[string]string{"output_dir": outputPath}
+		if compilation.PDFPath != "" {
+			outputs["translated_pdf"] = compilation.PDFPath
+		}
+		progressEvents.Complete(statusWriter.Snapshot(), outputs)
+	}
 
-// extractZip extracts a zip file to the specified directory
-func extractZip(zipPath, destDir string) error {
-	// Use PowerShell Expand-Archive on Windows
-	cmd := exec.Command("powershell", "-Command", 
-		fmt.Sprintf("Expand-Archive -Path '%s' -DestinationPath '%s' -Force", zipPath, destDir))
-	
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("解压失败: %v\n%s", err, string(output))
+		fmt.Fprintf(os.Stderr, "\n错误: 翻译失败: %v\n", err)
+	} else {
+		fmt.Println("\n=== 翻译完成 ===")
+	}
+	fmt.Printf("输出目录: %s\n", outputPath)
+	if *compileBook {
+		printBookCompilation(compilation)
+	}
+	if finishErr != nil {
+		os.Exit(1)
 	}
-	
-	return nil
 }
 
 // findTexFiles finds all .tex files in a directory recursively
@@ -571,120 +2341,251 @@ func findTexFiles(dir string) ([]string, error) {
 	return texFiles, err
 }
 
-// translateBook translates all LaTeX files in the book
-func translateBook(inputDir, outputDir, apiKey, baseURL, model string, texFiles []string) error {
+// bookInterruptGrace is how long translateBook waits for the files in flight after Ctrl+C
+const bookInterruptGrace = 2 * time.Minute
+
+// errBookInterrupted is returned by translateBook when Ctrl+C stopped the translation
+var errBookInterrupted = errors.New("翻译已中断")
+
+// bookFileOutput prints the progress lines of one file of the book. When several files are
+// translated at once every line is prefixed with the file name, so the lines of concurrent
+// files stay readable.
+type bookFileOutput struct {
+	mu     *sync.Mutex
+	prefix string
+}
+
+// printf prints one progress line of the file
+func (o bookFileOutput) printf(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	fmt.Printf(o.prefix+format, args...)
+}
+
+// bookFileResult is the outcome of translating one file of the book
+type bookFileResult struct {
+	record      types.FileDecision
+	success     bool
+	skipped     bool
+	err         string // entry of the error list; empty unless the file failed
+	corrections []types.GlossaryCorrection
+}
+
+// translateBook translates the LaTeX files of the book, up to jobs files at once, reporting
+// progress to statusWriter. The first Ctrl+C stops starting new files and waits up to
+// bookInterruptGrace for the files in flight; errBookInterrupted is returned then.
+func translateBook(inputDir, outputDir, apiKey, baseURL, model string, texFiles []string, jobs int, lang types.TargetLanguage, variant types.ChineseVariant, variantPhrases map[string]string, indexSortKeys string, translateComments, translateBibliography, chunkOverlap bool, requestShape types.RequestShape, provider string, glossary *translator.Glossary, prompt *translator.PromptTemplate, overrides decisions.Overrides, manifest *bookmanifest.Manifest, statusWriter *statusfile.Writer) error {
 	fmt.Println("\n=== 开始翻译 ===")
-	
-	// Create translator with custom configuration
-	trans := translator.NewTranslationEngineWithConfig(apiKey, model, baseURL, 120*time.Second, 3)
+	jobs = max(min(jobs, len(texFiles)), 1)
+	if jobs > 1 {
+		fmt.Printf("同时翻译 %d 个文件\n", jobs)
+	}
 
-	// Track statistics
-	startTime := time.Now()
+	// Every worker has its own translator, so the progress of files translated at the same
+	// time stays apart
+	engines := make([]*translator.TranslationEngine, jobs)
+	for w := range engines {
+		trans := translator.NewTranslationEngineWithConfig(apiKey, model, baseURL, 120*time.Second, 3)
+		trans.SetTargetLanguage(lang)
+		trans.SetChineseVariant(variant, variantPhrases)
+		trans.SetIndexSortKeys(indexSortKeys)
+		trans.SetTranslateComments(translateComments)
+		trans.SetTranslateBibliography(translateBibliography)
+		trans.SetChunkOverlap(chunkOverlap)
+		trans.SetRequestShape(requestShape)
+		trans.SetProvider(provider)
+		trans.SetGlossary(glossary)
+		trans.SetPromptTemplate(prompt)
+		engines[w] = trans
+	}
+
+	// State shared by the workers, guarded by mu
+	var mu sync.Mutex
+	active := make(map[int]int) // worker -> index of the file it translates
+	finished := 0
 	successCount := 0
 	errorCount := 0
 	skipCount := 0
-	var errors []string
-
-	// Translate each file
-	for i, texFile := range texFiles {
-		relPath, _ := filepath.Rel(inputDir, texFile)
-		fmt.Printf("\n[%d/%d] %s\n", i+1, len(texFiles), relPath)
-
-		// Create output path first to check if already translated
-		outputPath := filepath.Join(outputDir, relPath)
-		outputPath = strings.TrimSuffix(outputPath, ".tex") + "_zh.tex"
-
-		// Skip if already translated
-		if _, err := os.Stat(outputPath); err == nil {
-			fmt.Printf("  ⏭️  跳过 (已翻译)\n")
-			skipCount++
-			successCount++ // Count as success since it's already done
-			continue
+	records := make([]*types.FileDecision, len(texFiles))
+	fileErrors := make([]string, len(texFiles))
+	var glossaryCorrections []types.GlossaryCorrection
+
+	// The percentage advances per file and within the files in flight per chunk
+	statusWriter.Start(statusfile.DefaultInterval, func(s *statusfile.Status) {
+		mu.Lock()
+		count := finished
+		done := float64(finished)
+		working := make(map[int]int, len(active))
+		for w, i := range active {
+			working[w] = i
 		}
-
-		// Read file
-		content, err := os.ReadFile(texFile)
-		if err != nil {
-			fmt.Printf("  ❌ 读取失败: %v\n", err)
-			errorCount++
-			errors = append(errors, fmt.Sprintf("%s: 读取失败", relPath))
-			continue
+		mu.Unlock()
+
+		var files []string
+		s.TokensUsed = 0
+		s.Section, s.Chunk, s.TotalChunks = "", 0, 0
+		for w, trans := range engines {
+			progress := trans.Progress()
+			s.TokensUsed += progress.TokensUsed
+			i, ok := working[w]
+			if !ok {
+				continue
+			}
+			if progress.TotalChunks > 0 {
+				done += float64(progress.Chunk) / float64(progress.TotalChunks)
+			}
+			// Section and chunks are those of the first file in flight
+			if len(files) == 0 {
+				s.Section = progress.Section
+				s.Chunk = progress.Chunk
+				s.TotalChunks = progress.TotalChunks
+			}
+			relPath, _ := filepath.Rel(inputDir, texFiles[i])
+			files = append(files, filepath.ToSlash(relPath))
 		}
+		s.Phase = string(types.PhaseTranslating)
+		s.File = strings.Join(files, ", ")
+		s.Percent = int(done / float64(len(texFiles)) * 100)
+		s.Message = fmt.Sprintf("翻译中 (已完成 %d/%d 文件)", count, len(texFiles))
+	})
 
-		// Skip if too small
-		if len(content) < 50 {
-			fmt.Printf("  ⏭️  跳过 (文件太小: %d 字节)\n", len(content))
-			skipCount++
-			continue
+	// The first Ctrl+C stops scheduling new files, another one stops waiting for the files
+	// in flight
+	stopScheduling := make(chan struct{})
+	stopWaiting := make(chan struct{})
+	allDone := make(chan struct{})
+	// Giving up on the files in flight tears down their chunk requests
+	filesCtx, cancelFiles := context.WithCancel(context.Background())
+	defer cancelFiles()
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for n := 0; ; n++ {
+			select {
+			case <-allDone:
+				return
+			case <-interrupts:
+			}
+			if n == 0 {
+				fmt.Printf("\n正在中断: 不再开始新文件，最多等待 %v 让进行中的文件完成（再按一次 Ctrl+C 不再等待）...\n", bookInterruptGrace)
+				close(stopScheduling)
+				continue
+			}
+			close(stopWaiting)
+			return
 		}
+	}()
 
-		// Check if file is mostly TikZ/figure code (no translatable text)
-		contentStr := string(content)
-		if isMostlyCode(contentStr) {
-			fmt.Printf("  ⏭️  跳过 (主要是代码/图形，无需翻译)\n")
-			skipCount++
-			// Copy original file as-is
-			if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err == nil {
-				os.WriteFile(outputPath, content, 0644)
+	startTime := time.Now()
+	printMu := &sync.Mutex{}
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range texFiles {
+			select {
+			case <-stopScheduling:
+				return
+			default:
+			}
+			select {
+			case queue <- i:
+			case <-stopScheduling:
+				return
 			}
-			continue
 		}
+	}()
 
-		// Translate
-		fmt.Printf("  📝 翻译中... (%d 字节)\n", len(content))
-		translateStart := time.Now()
+	var wg sync.WaitGroup
+	for w := range engines {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range queue {
+				relPath, _ := filepath.Rel(inputDir, texFiles[i])
+				out := bookFileOutput{mu: printMu, prefix: "  "}
+				if jobs > 1 {
+					out.prefix = fmt.Sprintf("  [%s] ", relPath)
+					out.printf("开始 (%d/%d)\n", i+1, len(texFiles))
+				} else {
+					out.mu.Lock()
+					fmt.Printf("\n[%d/%d] %s\n", i+1, len(texFiles), relPath)
+					out.mu.Unlock()
+				}
 
-		result, err := trans.TranslateTeX(contentStr)
-		if err != nil {
-			// Check if it's a validation error for code-only files
-			if strings.Contains(err.Error(), "中文字符过少") {
-				fmt.Printf("  ⏭️  跳过 (无可翻译文本)\n")
-				skipCount++
-				// Copy original file as-is
-				if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err == nil {
-					os.WriteFile(outputPath, content, 0644)
+				mu.Lock()
+				active[w] = i
+				mu.Unlock()
+				result := translateBookFile(filesCtx, engines[w], inputDir, outputDir, texFiles[i], overrides, manifest, statusWriter, out)
+
+				mu.Lock()
+				delete(active, w)
+				finished++
+				done := finished
+				records[i] = &result.record
+				fileErrors[i] = result.err
+				glossaryCorrections = translator.MergeGlossaryCorrections(glossaryCorrections, result.corrections)
+				switch {
+				case result.err != "":
+					errorCount++
+				case result.skipped:
+					skipCount++
+				}
+				if result.success {
+					successCount++
+				}
+				mu.Unlock()
+
+				// Progress update every 5 files
+				if done%5 == 0 {
+					totalElapsed := time.Since(startTime)
+					avgTime := totalElapsed / time.Duration(done)
+					remaining := avgTime * time.Duration(len(texFiles)-done)
+					printMu.Lock()
+					fmt.Printf("\n📊 进度: %d/%d (%.1f%%), 预计剩余: %v\n",
+						done, len(texFiles), float64(done)/float64(len(texFiles))*100,
+						remaining.Round(time.Second))
+					printMu.Unlock()
 				}
-				continue
 			}
-			
-			fmt.Printf("  ❌ 翻译失败: %v\n", err)
-			errorCount++
-			errors = append(errors, fmt.Sprintf("%s: 翻译失败 - %v", relPath, err))
-			continue
-		}
-
-		elapsed := time.Since(translateStart)
-		fmt.Printf("  ⏱️  耗时: %v\n", elapsed.Round(time.Millisecond))
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
 
-		// Create output directory
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			fmt.Printf("  ❌ 创建目录失败: %v\n", err)
-			errorCount++
-			errors = append(errors, fmt.Sprintf("%s: 创建目录失败", relPath))
-			continue
+	select {
+	case <-allDone:
+	case <-stopScheduling:
+		select {
+		case <-allDone:
+		case <-stopWaiting:
+			fmt.Println("\n不再等待进行中的文件")
+			cancelFiles()
+		case <-time.After(bookInterruptGrace):
+			fmt.Printf("\n等待 %v 后仍有文件未完成，放弃这些文件\n", bookInterruptGrace)
+			cancelFiles()
 		}
+	}
 
-		// Write translated file
-		if err := os.WriteFile(outputPath, []byte(result.TranslatedContent), 0644); err != nil {
-			fmt.Printf("  ❌ 写入失败: %v\n", err)
-			errorCount++
-			errors = append(errors, fmt.Sprintf("%s: 写入失败", relPath))
-			continue
+	// Files in flight after an abort are cancelled; only the outcomes recorded so far are
+	// reported
+	mu.Lock()
+	interrupted := finished < len(texFiles)
+	decisionLog := decisions.NewLog(inputDir, "")
+	var errorList []string
+	for i, record := range records {
+		if record != nil {
+			decisionLog.Files = append(decisionLog.Files, *record)
 		}
-
-		fmt.Printf("  ✅ 成功\n")
-		successCount++
-
-		// Progress update every 5 files
-		if (i+1)%5 == 0 {
-			totalElapsed := time.Since(startTime)
-			avgTime := totalElapsed / time.Duration(i+1)
-			remaining := avgTime * time.Duration(len(texFiles)-(i+1))
-			fmt.Printf("\n📊 进度: %d/%d (%.1f%%), 预计剩余: %v\n", 
-				i+1, len(texFiles), float64(i+1)/float64(len(texFiles))*100, 
-				remaining.Round(time.Second))
+		if fileErrors[i] != "" {
+			errorList = append(errorList, fileErrors[i])
 		}
 	}
+	translated, succeeded, skipped, failed := finished, successCount, skipCount, errorCount
+	corrections := glossaryCorrections
+	mu.Unlock()
 
 	// Print summary
 	totalElapsed := time.Since(startTime)
@@ -692,90 +2593,511 @@ func translateBook(inputDir, outputDir, apiKey, baseURL, model string, texFiles
 	fmt.Println("=== 翻译摘要 ===")
 	fmt.Println(strings.Repeat("=", 60))
 	fmt.Printf("总文件数:    %d\n", len(texFiles))
-	fmt.Printf("成功翻译:    %d\n", successCount)
-	fmt.Printf("跳过:        %d\n", skipCount)
-	fmt.Printf("错误:        %d\n", errorCount)
+	if interrupted {
+		fmt.Printf("已处理:      %d (已中断)\n", translated)
+	}
+	fmt.Printf("成功翻译:    %d\n", succeeded)
+	fmt.Printf("跳过:        %d\n", skipped)
+	fmt.Printf("错误:        %d\n", failed)
 	fmt.Printf("总耗时:      %v\n", totalElapsed.Round(time.Second))
 
-	if successCount > 0 {
-		avgTime := totalElapsed / time.Duration(successCount)
+	if succeeded > 0 {
+		avgTime := totalElapsed / time.Duration(succeeded)
 		fmt.Printf("平均耗时:    %v/文件\n", avgTime.Round(time.Millisecond))
 	}
 
-	if len(errors) > 0 {
+	if len(errorList) > 0 {
 		fmt.Println("\n=== 错误列表 ===")
-		for i, e := range errors {
+		for i, e := range errorList {
 			fmt.Printf("%d. %s\n", i+1, e)
 		}
 	}
 
+	if len(corrections) > 0 {
+		fmt.Println("\n=== 术语表强制修正 ===")
+		for _, c := range corrections {
+			source := "术语表"
+			if c.Auto {
+				source = "自动术语"
+			}
+			fmt.Printf("%s → %s: %d 处 (%s)\n", c.Term, c.Target, c.Count, source)
+		}
+		statusWriter.Warn(translator.FormatGlossaryCorrections(corrections))
+	}
+
 	fmt.Println(strings.Repeat("=", 60))
 
-	if errorCount > 0 {
-		return fmt.Errorf("翻译完成，但有 %d 个错误", errorCount)
+	// Record the decision for every file in decisions.json in the output directory
+	decisions.AddSupportFiles(decisionLog, inputDir)
+	if path, err := decisions.Save(decisionLog, outputDir); err != nil {
+		fmt.Printf("⚠️  写入 %s 失败: %v\n", decisions.FileName, err)
+	} else {
+		fmt.Printf("文件决定: %s (%s)\n", path, decisions.Summary(decisionLog))
+	}
+
+	if interrupted {
+		return errBookInterrupted
+	}
+	if failed > 0 {
+		return fmt.Errorf("翻译完成，但有 %d 个错误", failed)
 	}
 
 	return nil
 }
 
-// isMostlyCode checks if a LaTeX file is mostly code/figures with little translatable text
-func isMostlyCode(content string) bool {
-	// Count lines with actual English text vs code/commands
-	lines := strings.Split(content, "\n")
-	textLines := 0
-	codeLines := 0
-	
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		
-		// Skip empty lines and comments
-		if trimmed == "" || strings.HasPrefix(trimmed, "%") {
+// bookOutputPath returns the translated file of a book file
+func bookOutputPath(outputDir, relPath string) string {
+	return strings.TrimSuffix(filepath.Join(outputDir, relPath), ".tex") + "_zh.tex"
+}
+
+// printBookDiffReport prints which files of the book a run would translate, from the
+// manifest of the output directory
+func printBookDiffReport(inputDir, outputDir string, texFiles []string, manifest *bookmanifest.Manifest) {
+	groups := make(map[bookmanifest.Status][]string)
+	for _, texFile := range texFiles {
+		relPath, _ := filepath.Rel(inputDir, texFile)
+		content, err := os.ReadFile(texFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法读取 %s: %v\n", relPath, err)
 			continue
 		}
-		
-		// Check if line is mostly LaTeX commands (starts with \, or contains tikz/pgf commands)
-		if strings.HasPrefix(trimmed, "\\") || 
-		   strings.Contains(trimmed, "\\draw") ||
-		   strings.Contains(trimmed, "\\node") ||
-		   strings.Contains(trimmed, "\\path") ||
-		   strings.Contains(trimmed, "\\coordinate") ||
-		   strings.Contains(trimmed, "\\fill") ||
-		   strings.Contains(trimmed, "\\shade") {
-			codeLines++
+		_, statErr := os.Stat(bookOutputPath(outputDir, relPath))
+		status := manifest.Status(relPath, content, statErr == nil)
+		groups[status] = append(groups[status], filepath.ToSlash(relPath))
+	}
+
+	fmt.Printf("\n=== 变更报告 (翻译清单: %s) ===\n", manifest.Path())
+	for _, group := range []struct {
+		status bookmanifest.Status
+		label  string
+	}{
+		{bookmanifest.StatusNew, "新增 (将翻译)"},
+		{bookmanifest.StatusChanged, "已改变 (将重新翻译)"},
+		{bookmanifest.StatusSettingsChanged, "模型或提示词预设已改变 (将重新翻译)"},
+		{bookmanifest.StatusUnchanged, "未改变 (跳过)"},
+		{bookmanifest.StatusUntracked, "已有译文但不在清单中 (跳过, 记录当前源文件)"},
+	} {
+		files := groups[group.status]
+		if len(files) == 0 {
 			continue
 		}
-		
-		// Check if line has English words (simple heuristic)
-		hasEnglish := false
-		words := strings.Fields(trimmed)
-		for _, word := range words {
-			// Remove LaTeX commands
-			word = strings.TrimPrefix(word, "\\")
-			// Check if word has letters
-			for _, r := range word {
-				if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
-					hasEnglish = true
-					break
-				}
-			}
-			if hasEnglish {
-				break
+		fmt.Printf("\n%s: %d 个文件\n", group.label, len(files))
+		for _, file := range files {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	pending := len(groups[bookmanifest.StatusNew]) + len(groups[bookmanifest.StatusChanged]) + len(groups[bookmanifest.StatusSettingsChanged])
+	fmt.Printf("\n共 %d 个文件, 其中 %d 个需要翻译 (本次未翻译; --translate-files 可强制重新翻译其他文件)\n", len(texFiles), pending)
+}
+
+// recordBookManifest records the source of a file written to the output directory
+func recordBookManifest(manifest *bookmanifest.Manifest, relPath string, content []byte, translated bool, out bookFileOutput) {
+	if err := manifest.Record(relPath, content, translated); err != nil {
+		out.printf("⚠️  无法更新翻译清单: %v\n", err)
+	}
+}
+
+// translateBookFile translates one file of the book into outputDir. Files whose translation
+// is up to date with their source, per the manifest, are skipped unless overridden; files
+// without translatable text are copied. Cancelling ctx tears down its chunk requests.
+func translateBookFile(ctx context.Context, trans *translator.TranslationEngine, inputDir, outputDir, texFile string, overrides decisions.Overrides, manifest *bookmanifest.Manifest, statusWriter *statusfile.Writer, out bookFileOutput) bookFileResult {
+	relPath, _ := filepath.Rel(inputDir, texFile)
+
+	// Create output path first to check if already translated
+	outputPath := bookOutputPath(outputDir, relPath)
+
+	// Read file
+	content, err := os.ReadFile(texFile)
+	if err != nil {
+		out.printf("❌ 读取失败: %v\n", err)
+		return bookFileResult{
+			record: decisions.Record(relPath, nil, types.FileDecisionFailed, decisions.RuleReadFailed, err),
+			err:    fmt.Sprintf("%s: 读取失败", relPath),
+		}
+	}
+
+	// Skip if already translated from this source, unless the file is overridden. An output
+	// from before the manifest is kept and its source recorded.
+	_, statErr := os.Stat(outputPath)
+	status := manifest.Status(relPath, content, statErr == nil)
+	if !status.NeedsTranslation() && overrides.Lookup(relPath) == "" {
+		if status == bookmanifest.StatusUntracked {
+			if err := manifest.Adopt(relPath, content); err != nil {
+				out.printf("⚠️  无法更新翻译清单: %v\n", err)
 			}
 		}
-		
-		if hasEnglish {
-			textLines++
+		out.printf("⏭️  跳过 (已翻译)\n")
+		// Counted as success since it's already done
+		return bookFileResult{
+			record:  decisions.Record(relPath, content, types.FileDecisionSkip, decisions.RuleAlreadyTranslated, nil),
+			success: true,
+			skipped: true,
+		}
+	}
+	switch status {
+	case bookmanifest.StatusChanged:
+		out.printf("🔄 源文件已改变，重新翻译\n")
+	case bookmanifest.StatusSettingsChanged:
+		out.printf("🔄 模型或提示词预设已改变，重新翻译\n")
+	}
+
+	// Skip files that are too small, copy files that are mostly TikZ/figure code
+	// (no translatable text) as-is
+	decision := decisions.Decide(relPath, content, decisions.Book, overrides)
+	switch decision.Decision {
+	case types.FileDecisionSkip:
+		out.printf("⏭️  跳过 (文件太小: %d 字节)\n", len(content))
+		return bookFileResult{record: decision, skipped: true}
+	case types.FileDecisionCopy:
+		if decision.Rule == decisions.RuleOverride {
+			out.printf("⏭️  原样复制 (--copy-files)\n")
 		} else {
-			codeLines++
+			out.printf("⏭️  跳过 (主要是代码/图形，无需翻译)\n")
+		}
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err == nil && os.WriteFile(outputPath, content, 0644) == nil {
+			recordBookManifest(manifest, relPath, content, false, out)
+		}
+		return bookFileResult{record: decision, skipped: true}
+	}
+
+	// Translate
+	out.printf("📝 翻译中... (%d 字节)\n", len(content))
+	translateStart := time.Now()
+
+	result, err := trans.TranslateTeXContext(ctx, string(content))
+	if err != nil {
+		// Check if it's a validation error for code-only files
+		if translator.IsUntranslatedResult(err) {
+			out.printf("⏭️  跳过 (无可翻译文本)\n")
+			// Copy original file as-is
+			if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err == nil && os.WriteFile(outputPath, content, 0644) == nil {
+				recordBookManifest(manifest, relPath, content, false, out)
+			}
+			return bookFileResult{
+				record:  decisions.Record(relPath, content, types.FileDecisionCopy, decisions.RuleNoChineseOutput, nil),
+				skipped: true,
+			}
+		}
+
+		out.printf("❌ 翻译失败: %v\n", err)
+		statusWriter.Warn(fmt.Sprintf("%s: 翻译失败 - %v", relPath, err))
+		return bookFileResult{
+			record: decisions.Record(relPath, content, types.FileDecisionFailed, decisions.RuleTranslationFailed, err),
+			err:    fmt.Sprintf("%s: 翻译失败 - %v", relPath, err),
+		}
+	}
+
+	elapsed := time.Since(translateStart)
+	out.printf("⏱️  耗时: %v\n", elapsed.Round(time.Millisecond))
+	if result.NetworkPauses > 0 {
+		out.printf("📡 网络中断 %d 次，暂停 %.0f 秒后恢复\n", result.NetworkPauses, result.NetworkPausedSecs)
+		statusWriter.Warn(fmt.Sprintf("%s: 网络中断 %d 次，暂停 %.0f 秒", relPath, result.NetworkPauses, result.NetworkPausedSecs))
+	}
+	if result.Stalls > 0 {
+		out.printf("⏳ 翻译请求 %d 次无响应，已重建连接重试\n", result.Stalls)
+		statusWriter.Warn(fmt.Sprintf("%s: 翻译请求 %d 次无响应，已重建连接重试", relPath, result.Stalls))
+	}
+	if result.Generator != "" {
+		out.printf("🧩 由 %s 生成，代码块和辅助宏已原样保留\n", result.Generator)
+		statusWriter.Warn(fmt.Sprintf("%s: 由 %s 生成，代码块和辅助宏已原样保留", relPath, result.Generator))
+	}
+	if result.ParagraphBreakFixes > 0 {
+		out.printf("📐 已按原文修正 %d 处段落分隔 (空行)\n", result.ParagraphBreakFixes)
+		statusWriter.Warn(fmt.Sprintf("%s: 已按原文修正 %d 处段落分隔", relPath, result.ParagraphBreakFixes))
+	}
+	if result.Continuations > 0 || result.TruncatedChunks > 0 {
+		out.printf("✂️  %s\n", translator.FormatTruncationSummary(result))
+		statusWriter.Warn(fmt.Sprintf("%s: %s", relPath, translator.FormatTruncationSummary(result)))
+	}
+	if result.SplitChunks > 0 {
+		out.printf("🪓 %s\n", translator.FormatSplitSummary(result))
+		statusWriter.Warn(fmt.Sprintf("%s: %s", relPath, translator.FormatSplitSummary(result)))
+	}
+	if len(result.FailedChunks) > 0 {
+		out.printf("⚠️  %s\n", translator.FormatFailedChunksSummary(result))
+		statusWriter.Warn(fmt.Sprintf("%s: %s", relPath, translator.FormatFailedChunksSummary(result)))
+	}
+	if len(result.GlossaryCorrections) > 0 {
+		out.printf("📖 %s\n", translator.FormatGlossaryCorrections(result.GlossaryCorrections))
+	}
+	if len(result.SkippedDataBlobs) > 0 {
+		out.printf("📦 %s\n", translator.FormatDataBlobSummary(result.SkippedDataBlobs))
+		for _, blob := range result.SkippedDataBlobs {
+			out.printf("   - %s\n", translator.DescribeDataBlob(blob))
+		}
+	}
+
+	// Create output directory
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		out.printf("❌ 创建目录失败: %v\n", err)
+		return bookFileResult{record: decision, err: fmt.Sprintf("%s: 创建目录失败", relPath)}
+	}
+
+	// Write translated file
+	if err := os.WriteFile(outputPath, []byte(result.TranslatedContent), 0644); err != nil {
+		out.printf("❌ 写入失败: %v\n", err)
+		return bookFileResult{record: decision, err: fmt.Sprintf("%s: 写入失败", relPath)}
+	}
+
+	recordBookManifest(manifest, relPath, content, true, out)
+	out.printf("✅ 成功\n")
+	return bookFileResult{record: decision, success: true, corrections: result.GlossaryCorrections}
+}
+
+// bookCompilation is the outcome of compiling a translated book
+type bookCompilation struct {
+	MainFile string // tex file that was compiled
+	PDFPath  string // compiled PDF; empty when compilation failed
+	LogPath  string // LaTeX log of the compilation
+	Err      error
+}
+
+var (
+	// bookIncludePattern matches \input, \include and \subfile with their file argument
+	bookIncludePattern = regexp.MustCompile(`\\(input|include|subfile)(\s*)\{([^}]+)\}`)
+	// bookIncludeOnlyPattern matches \includeonly with its list of files
+	bookIncludeOnlyPattern = regexp.MustCompile(`\\includeonly(\s*)\{([^}]*)\}`)
+)
+
+// bookBuildArtifacts are the extensions of LaTeX build files not copied from the book
+var bookBuildArtifacts = map[string]bool{
+	".aux": true, ".log": true, ".out": true, ".toc": true, ".lof": true, ".lot": true,
+	".fls": true, ".fdb_latexmk": true, ".blg": true, ".synctex": true,
+}
+
+// compileTranslatedBook compiles the translated book in outputDir with compilerName. It
+// copies the assets of the book and the tex files without translation into outputDir,
+// post-processes the translated files, points their \input, \include and \subfile
+// references at the translated files and compiles the translated main file. The
+// translated sources are kept whether or not compilation succeeds. With autoInstallPackages
+// packages missing from the TeX distribution are installed while compiling.
+func compileTranslatedBook(inputDir, outputDir, compilerName string, autoInstallPackages bool, opts postprocess.Options) bookCompilation {
+	fmt.Println("\n=== 开始编译 ===")
+
+	mainRel, err := downloader.NewSourceDownloader(inputDir).FindMainTexFile(inputDir)
+	if err != nil {
+		return bookCompilation{Err: err}
+	}
+
+	copied, err := copyBookAssets(inputDir, outputDir)
+	if err != nil {
+		return bookCompilation{Err: types.NewAppError(types.ErrInternal, "复制资源文件失败", err)}
+	}
+	fmt.Printf("已复制 %d 个资源文件和未翻译的 tex 文件\n", copied)
+
+	mainFile := filepath.Join(outputDir, strings.TrimSuffix(mainRel, ".tex")+"_zh.tex")
+	if _, err := os.Stat(mainFile); err != nil {
+		fmt.Printf("⚠️  主文件 %s 没有译文，编译原文主文件\n", mainRel)
+		mainFile = filepath.Join(outputDir, mainRel)
+	}
+	fmt.Printf("主文件: %s\n", mainFile)
+
+	if err := prepareTranslatedBook(inputDir, outputDir, mainFile, opts); err != nil {
+		return bookCompilation{MainFile: mainFile, Err: types.NewAppError(types.ErrInternal, "处理译文文件失败", err)}
+	}
+
+	mainDir := filepath.Dir(mainFile)
+	fmt.Printf("正在使用 %s 编译...\n", compilerName)
+	c := compiler.NewLaTeXCompiler(compilerName, mainDir, 0)
+	c.SetUseLatexmk(compiler.LatexmkInstalled())
+	c.SetAutoInstallPackages(autoInstallPackages)
+	var result *types.CompileResult
+	switch compilerName {
+	case compiler.CompilerLuaLaTeX:
+		result, err = c.CompileWithLuaLaTeX(mainFile, mainDir)
+	case compiler.CompilerPDFLaTeX:
+		result, err = c.CompileWithPDFLaTeX(mainFile, mainDir)
+	default:
+		result, err = c.CompileWithXeLaTeX(mainFile, mainDir)
+	}
+	if result != nil && len(result.InstalledPackages) > 0 {
+		fmt.Printf("已自动安装宏包: %s\n", strings.Join(result.InstalledPackages, ", "))
+	}
+
+	compilation := bookCompilation{
+		MainFile: mainFile,
+		LogPath:  strings.TrimSuffix(mainFile, ".tex") + ".log",
+	}
+	if _, statErr := os.Stat(compilation.LogPath); statErr != nil {
+		compilation.LogPath = ""
+		if result != nil && result.Log != "" {
+			logPath := strings.TrimSuffix(mainFile, ".tex") + "_compile.log"
+			if os.WriteFile(logPath, []byte(result.Log), 0644) == nil {
+				compilation.LogPath = logPath
+			}
+		}
+	}
+	switch {
+	case result != nil && result.Success:
+		compilation.PDFPath = result.PDFPath
+	case err != nil:
+		compilation.Err = err
+	default:
+		details := ""
+		if result != nil {
+			details = result.ErrorMsg
+		}
+		compilation.Err = types.NewAppErrorWithDetails(types.ErrCompile, "编译失败", details, nil)
+	}
+	return compilation
+}
+
+// copyBookAssets copies the files the translated book needs to compile from inputDir to
+// outputDir: figures, bibliographies, styles, classes and the tex files that have no
+// translation. Build files, hidden directories and outputDir itself are skipped, and
+// files already up to date are not copied again. Returns the number of files copied.
+func copyBookAssets(inputDir, outputDir string) (int, error) {
+	absOutput, _ := filepath.Abs(outputDir)
+	copied := 0
+	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if abs, _ := filepath.Abs(path); abs == absOutput {
+				return filepath.SkipDir
+			}
+			if path != inputDir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if bookBuildArtifacts[ext] || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		if ext == ".tex" {
+			if strings.HasSuffix(path, "_zh.tex") || strings.HasSuffix(path, "_ro.tex") {
+				return nil
+			}
+			translated := filepath.Join(outputDir, strings.TrimSuffix(relPath, filepath.Ext(relPath))+"_zh.tex")
+			if _, err := os.Stat(translated); err == nil {
+				return nil
+			}
+		}
+
+		dst := filepath.Join(outputDir, relPath)
+		if dstInfo, err := os.Stat(dst); err == nil && dstInfo.Size() == info.Size() && !dstInfo.ModTime().Before(info.ModTime()) {
+			return nil
+		}
+		if err := copyFile(path, dst); err != nil {
+			return err
+		}
+		copied++
+		return nil
+	})
+	return copied, err
+}
+
+// prepareTranslatedBook runs the post-processing pipeline on the translated files of the
+// book and on its main file, and points their file references at the translated files.
+// Both steps are idempotent, so the book can be compiled again after a later run.
+func prepareTranslatedBook(inputDir, outputDir, mainFile string, opts postprocess.Options) error {
+	files := []string{mainFile}
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
+		if !info.IsDir() && strings.HasSuffix(path, "_zh.tex") && path != mainFile {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mainDir := filepath.Dir(mainFile)
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(outputDir, path)
+		original, _ := os.ReadFile(filepath.Join(inputDir, strings.TrimSuffix(relPath, "_zh.tex")+".tex"))
+
+		processed := postprocess.Run(postprocess.File{
+			Content:  string(content),
+			Original: string(original),
+			Main:     path == mainFile,
+		}, opts)
+		processed, rewritten := rewriteBookIncludes(processed, mainDir)
+		if rewritten > 0 {
+			logger.Info("pointed file references at translated files",
+				logger.String("file", relPath),
+				logger.Int("references", rewritten))
+		}
+		if processed == string(content) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(processed), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteBookIncludes points the \input, \include, \subfile and \includeonly references
+// of content at the translated (_zh) files that exist in baseDir, the directory the
+// references are resolved against. Returns the content and the number of references
+// changed; references already pointing at a translation are left alone.
+func rewriteBookIncludes(content, baseDir string) (string, int) {
+	rewritten := 0
+	translatedName := func(name string) string {
+		name = strings.TrimSpace(name)
+		stem, ext := name, ""
+		if strings.HasSuffix(stem, ".tex") {
+			stem, ext = strings.TrimSuffix(stem, ".tex"), ".tex"
+		}
+		if stem == "" || strings.HasSuffix(stem, "_zh") {
+			return ""
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, filepath.FromSlash(stem)+"_zh.tex")); err != nil {
+			return ""
+		}
+		rewritten++
+		return stem + "_zh" + ext
+	}
+
+	content = bookIncludePattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := bookIncludePattern.FindStringSubmatch(match)
+		name := translatedName(m[3])
+		if name == "" {
+			return match
+		}
+		return `\` + m[1] + m[2] + "{" + name + "}"
+	})
+	content = bookIncludeOnlyPattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := bookIncludeOnlyPattern.FindStringSubmatch(match)
+		names := strings.Split(m[2], ",")
+		for i, n := range names {
+			if name := translatedName(n); name != "" {
+				names[i] = name
+			}
+		}
+		return `\includeonly` + m[1] + "{" + strings.Join(names, ",") + "}"
+	})
+	return content, rewritten
+}
+
+// printBookCompilation prints where the PDF of the book is, or why it was not produced
+func printBookCompilation(c bookCompilation) {
+	fmt.Println("\n=== 编译结果 ===")
+	if c.MainFile != "" {
+		fmt.Printf("主文件: %s\n", c.MainFile)
+	}
+	if c.PDFPath != "" {
+		fmt.Printf("✅ 已生成 PDF: %s\n", c.PDFPath)
+		return
 	}
-	
-	// If more than 80% is code, consider it code-only
-	total := textLines + codeLines
-	if total == 0 {
-		return true
+	fmt.Printf("❌ 未生成 PDF: %v\n", c.Err)
+	if c.LogPath != "" {
+		fmt.Printf("编译日志: %s\n", c.LogPath)
 	}
-	
-	codeRatio := float64(codeLines) / float64(total)
-	return codeRatio > 0.8
+	fmt.Println("译文 tex 文件已保留在输出目录中，可修改后重新编译")
 }